@@ -0,0 +1,110 @@
+// Package main ingests a captured provider response into a translator contract fixture.
+// The capture is sanitized before it is written; golden files are then generated with
+//
+//	go test ./internal/translator/contract -update
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/translator/contract"
+)
+
+func main() {
+	var (
+		name         = flag.String("name", "", "fixture name, e.g. claude-tool-use")
+		provider     = flag.String("provider", "", "format of the captured response: claude, codex, gemini, gemini-cli, antigravity or openai")
+		model        = flag.String("model", "", "model the client asked for")
+		requestPath  = flag.String("request", "", "file holding the upstream request body")
+		responsePath = flag.String("response", "-", "file holding the response body or SSE stream ('-' reads stdin, '' records client requests only)")
+		stream       = flag.Bool("stream", false, "the response is an SSE stream")
+		dir          = flag.String("dir", filepath.Join("internal", "translator", "contract", "testdata", "fixtures"), "fixture directory")
+	)
+	clientRequests := make(map[string]json.RawMessage)
+	flag.Func("client-request", "dialect=file holding the client request of that dialect (repeatable)", func(value string) error {
+		dialect, path, ok := strings.Cut(value, "=")
+		if !ok {
+			return fmt.Errorf("want dialect=file, got %q", value)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		clientRequests[strings.TrimSpace(dialect)] = contract.Sanitize(data)
+		return nil
+	})
+	flag.Parse()
+
+	if *name == "" || *provider == "" {
+		fmt.Fprintln(os.Stderr, "contract-fixture: -name and -provider are required")
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err := run(*name, *provider, *model, *requestPath, *responsePath, *stream, *dir, clientRequests); err != nil {
+		fmt.Fprintf(os.Stderr, "contract-fixture: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(name, provider, model, requestPath, responsePath string, stream bool, dir string, clientRequests map[string]json.RawMessage) error {
+	fixture := contract.Fixture{Provider: provider, Model: model}
+	if len(clientRequests) > 0 {
+		fixture.ClientRequests = clientRequests
+	}
+	if requestPath != "" {
+		data, err := os.ReadFile(requestPath)
+		if err != nil {
+			return err
+		}
+		fixture.Request = contract.Sanitize(data)
+	}
+	if responsePath == "" {
+		if len(clientRequests) == 0 {
+			return fmt.Errorf("a fixture without a response needs -client-request")
+		}
+		return write(fixture, name, dir)
+	}
+	response, err := readInput(responsePath)
+	if err != nil {
+		return err
+	}
+	if stream {
+		lines := strings.Split(strings.ReplaceAll(string(response), "\r\n", "\n"), "\n")
+		fixture.Stream = contract.SanitizeStream(lines)
+	} else {
+		if !json.Valid(response) {
+			return fmt.Errorf("response is not JSON; pass -stream for SSE captures")
+		}
+		fixture.Response = contract.Sanitize(response)
+	}
+	return write(fixture, name, dir)
+}
+
+func write(fixture contract.Fixture, name, dir string) error {
+	data, err := json.MarshalIndent(fixture, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	path := filepath.Join(dir, name+".json")
+	if err = os.WriteFile(path, append(data, '\n'), 0o644); err != nil {
+		return err
+	}
+	fmt.Printf("wrote %s\nreview it for remaining personal data, then run: go test ./internal/translator/contract -update\n", path)
+	return nil
+}
+
+func readInput(path string) ([]byte, error) {
+	if path == "-" {
+		return io.ReadAll(os.Stdin)
+	}
+	return os.ReadFile(path)
+}
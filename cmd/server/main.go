@@ -17,12 +17,15 @@ import (
 
 	"github.com/joho/godotenv"
 	configaccess "github.com/router-for-me/CLIProxyAPI/v6/internal/access/config_access"
+	signatureaccess "github.com/router-for-me/CLIProxyAPI/v6/internal/access/signature_access"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/buildinfo"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/cmd"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/managementasset"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/misc"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/offline"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/selfupdate"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/store"
 	_ "github.com/router-for-me/CLIProxyAPI/v6/internal/translator"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/usage"
@@ -51,8 +54,6 @@ func init() {
 // It parses command-line flags, loads configuration, and starts the appropriate
 // service based on the provided flags (login, codex-login, or server mode).
 func main() {
-	fmt.Printf("CLIProxyAPI Version: %s, Commit: %s, BuiltAt: %s\n", buildinfo.Version, buildinfo.Commit, buildinfo.BuildDate)
-
 	// Command-line flags to control the application's behavior.
 	var login bool
 	var codexLogin bool
@@ -67,6 +68,7 @@ func main() {
 	var vertexImport string
 	var configPath string
 	var password string
+	var offlineMode bool
 
 	// Define command-line flags for different operation modes.
 	flag.BoolVar(&login, "login", false, "Login Google Account")
@@ -82,6 +84,7 @@ func main() {
 	flag.StringVar(&configPath, "config", DefaultConfigPath, "Configure File Path")
 	flag.StringVar(&vertexImport, "vertex-import", "", "Import Vertex service account key JSON file")
 	flag.StringVar(&password, "password", "", "")
+	flag.BoolVar(&offlineMode, "offline", false, "Serve only local backends (mock providers, local base URLs) and fail other requests fast")
 
 	flag.CommandLine.Usage = func() {
 		out := flag.CommandLine.Output()
@@ -108,11 +111,33 @@ func main() {
 			}
 			_, _ = fmt.Fprint(out, s+"\n")
 		})
+		cmd.PrintSubcommandUsage(out)
 	}
 
 	// Parse the command-line flags.
 	flag.Parse()
 
+	// A leading positional argument selects a subcommand such as "doctor"; its flags
+	// (including -config) may follow the subcommand name.
+	subcommand, subcommandArgs, errSubcommand := cmd.ParseSubcommand(flag.Args(), &configPath)
+	if errSubcommand != nil {
+		_, _ = fmt.Fprintln(os.Stderr, errSubcommand)
+		os.Exit(2)
+	}
+	// Subcommands print output meant to be piped or pasted, so keep the banner out of it.
+	if subcommand == nil {
+		fmt.Printf("CLIProxyAPI Version: %s, Commit: %s, BuiltAt: %s\n", buildinfo.Version, buildinfo.Commit, buildinfo.BuildDate)
+	}
+	// Loading the config already migrates legacy keys in place, so commands that inspect the
+	// raw file run first.
+	if subcommand != nil && subcommand.SkipConfigLoad {
+		rawConfigPath := configPath
+		if rawConfigPath == "" {
+			rawConfigPath = "config.yaml"
+		}
+		os.Exit(subcommand.Run(nil, rawConfigPath, subcommandArgs))
+	}
+
 	// Core application variables.
 	var err error
 	var cfg *config.Config
@@ -412,7 +437,9 @@ func main() {
 		return
 	}
 
-	log.Infof("CLIProxyAPI Version: %s, Commit: %s, BuiltAt: %s", buildinfo.Version, buildinfo.Commit, buildinfo.BuildDate)
+	if subcommand == nil {
+		log.Infof("CLIProxyAPI Version: %s, Commit: %s, BuiltAt: %s", buildinfo.Version, buildinfo.Commit, buildinfo.BuildDate)
+	}
 
 	// Set the log level based on the configuration.
 	util.SetLogLevel(cfg)
@@ -424,6 +451,10 @@ func main() {
 		cfg.AuthDir = resolvedAuthDir
 	}
 	managementasset.SetCurrentConfig(cfg)
+	if offlineMode {
+		offline.Default().Force()
+	}
+	offline.Default().Configure(cfg.Offline)
 
 	// Create login options to be used in authentication flows.
 	options := &cmd.LoginOptions{
@@ -444,10 +475,13 @@ func main() {
 
 	// Register built-in access providers before constructing services.
 	configaccess.Register()
+	signatureaccess.Register()
 
 	// Handle different command modes based on the provided flags.
 
-	if vertexImport != "" {
+	if subcommand != nil {
+		os.Exit(subcommand.Run(cfg, configFilePath, subcommandArgs))
+	} else if vertexImport != "" {
 		// Handle Vertex service account import
 		cmd.DoVertexImport(cfg, vertexImport)
 	} else if login {
@@ -476,7 +510,12 @@ func main() {
 			return
 		}
 		// Start the main proxy service
-		managementasset.StartAutoUpdater(context.Background(), configFilePath)
+		if offline.Default().Enabled() {
+			log.Info("offline mode: serving local backends only")
+		} else {
+			managementasset.StartAutoUpdater(context.Background(), configFilePath)
+			selfupdate.StartChecker(context.Background(), cfg, buildinfo.Version)
+		}
 		cmd.StartService(cfg, configFilePath, password)
 	}
 }
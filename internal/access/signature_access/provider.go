@@ -0,0 +1,190 @@
+// Package signatureaccess provides the hmac-signature access provider, which accepts
+// requests signed with a shared secret instead of a bearer API key.
+//
+// A signed request carries X-CLIProxy-Timestamp (Unix seconds) and X-CLIProxy-Signature
+// ("sha256=" plus the hex HMAC-SHA256 of "<timestamp>.<METHOD>.<request URI>.<body>"),
+// and X-CLIProxy-Key-Id naming the secret when several are configured.
+package signatureaccess
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	sdkaccess "github.com/router-for-me/CLIProxyAPI/v6/sdk/access"
+	sdkconfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+)
+
+const (
+	// ProviderType is the auth.providers type of the provider.
+	ProviderType = "hmac-signature"
+
+	// Request headers of a signed request.
+	TimestampHeader = "X-CLIProxy-Timestamp"
+	SignatureHeader = "X-CLIProxy-Signature"
+	KeyIDHeader     = "X-CLIProxy-Key-Id"
+
+	// defaultKeyID names the secret configured with "secret".
+	defaultKeyID = "default"
+	// defaultTolerance bounds the clock skew between signer and proxy.
+	defaultTolerance = 5 * time.Minute
+	// maxSignedBody bounds the request bodies read for verification.
+	maxSignedBody = 64 << 20
+)
+
+var registerOnce sync.Once
+
+// Register makes the hmac-signature provider available to the access manager.
+func Register() {
+	registerOnce.Do(func() {
+		sdkaccess.RegisterProvider(ProviderType, newProvider)
+	})
+}
+
+type provider struct {
+	name      string
+	secrets   map[string][]byte
+	tolerance time.Duration
+	now       func() time.Time
+
+	// seen remembers accepted signatures until they leave the tolerance window, so a
+	// captured request cannot be replayed.
+	mu        sync.Mutex
+	seen      map[string]time.Time
+	lastPrune time.Time
+}
+
+// newProvider reads the provider config:
+//
+//	secret: "..."                  # key id "default"
+//	secrets: {automation: "..."}   # key id -> secret
+//	tolerance-seconds: 300
+func newProvider(cfg *sdkconfig.AccessProvider, _ *sdkconfig.SDKConfig) (sdkaccess.Provider, error) {
+	name := strings.TrimSpace(cfg.Name)
+	if name == "" {
+		name = ProviderType
+	}
+	p := &provider{name: name, secrets: make(map[string][]byte), tolerance: defaultTolerance, now: time.Now, seen: make(map[string]time.Time)}
+	if secret, ok := cfg.Config["secret"].(string); ok && strings.TrimSpace(secret) != "" {
+		p.secrets[defaultKeyID] = []byte(strings.TrimSpace(secret))
+	}
+	if secrets, ok := cfg.Config["secrets"].(map[string]any); ok {
+		for id, value := range secrets {
+			secret, _ := value.(string)
+			if id = strings.TrimSpace(id); id != "" && strings.TrimSpace(secret) != "" {
+				p.secrets[id] = []byte(strings.TrimSpace(secret))
+			}
+		}
+	}
+	if len(p.secrets) == 0 {
+		return nil, fmt.Errorf("%s: no secret configured", ProviderType)
+	}
+	switch seconds := cfg.Config["tolerance-seconds"].(type) {
+	case int:
+		if seconds > 0 {
+			p.tolerance = time.Duration(seconds) * time.Second
+		}
+	case float64:
+		if seconds > 0 {
+			p.tolerance = time.Duration(seconds * float64(time.Second))
+		}
+	}
+	return p, nil
+}
+
+func (p *provider) Identifier() string {
+	if p == nil || p.name == "" {
+		return ProviderType
+	}
+	return p.name
+}
+
+func (p *provider) Authenticate(_ context.Context, r *http.Request) (*sdkaccess.Result, error) {
+	if p == nil || len(p.secrets) == 0 {
+		return nil, sdkaccess.ErrNotHandled
+	}
+	signature := strings.TrimSpace(r.Header.Get(SignatureHeader))
+	if signature == "" {
+		return nil, sdkaccess.ErrNoCredentials
+	}
+	keyID := strings.TrimSpace(r.Header.Get(KeyIDHeader))
+	if keyID == "" {
+		keyID = defaultKeyID
+	}
+	secret, ok := p.secrets[keyID]
+	if !ok {
+		return nil, sdkaccess.ErrInvalidCredential
+	}
+	timestamp := strings.TrimSpace(r.Header.Get(TimestampHeader))
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return nil, sdkaccess.ErrInvalidCredential
+	}
+	now := p.now()
+	if skew := now.Sub(time.Unix(unix, 0)); skew > p.tolerance || skew < -p.tolerance {
+		return nil, sdkaccess.ErrInvalidCredential
+	}
+	given, err := hex.DecodeString(strings.TrimPrefix(signature, "sha256="))
+	if err != nil {
+		return nil, sdkaccess.ErrInvalidCredential
+	}
+
+	var body []byte
+	if r.Body != nil {
+		body, err = io.ReadAll(io.LimitReader(r.Body, maxSignedBody+1))
+		_ = r.Body.Close()
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		if err != nil || len(body) > maxSignedBody {
+			return nil, sdkaccess.ErrInvalidCredential
+		}
+	}
+	if !hmac.Equal(given, Sign(secret, timestamp, r.Method, r.URL.RequestURI(), body)) {
+		return nil, sdkaccess.ErrInvalidCredential
+	}
+	// Replays are keyed on the decoded MAC, so re-encoding it (hex case) does not pass.
+	if !p.firstUse(keyID+"\x00"+hex.EncodeToString(given), now) {
+		return nil, sdkaccess.ErrInvalidCredential
+	}
+	return &sdkaccess.Result{
+		Provider:  p.Identifier(),
+		Principal: keyID,
+		Metadata:  map[string]string{"source": ProviderType},
+	}, nil
+}
+
+// firstUse records a signature and reports whether it had not been accepted before.
+func (p *provider) firstUse(signature string, now time.Time) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if now.Sub(p.lastPrune) >= time.Second {
+		for key, expires := range p.seen {
+			if now.After(expires) {
+				delete(p.seen, key)
+			}
+		}
+		p.lastPrune = now
+	}
+	if _, replayed := p.seen[signature]; replayed {
+		return false
+	}
+	p.seen[signature] = now.Add(2 * p.tolerance)
+	return true
+}
+
+// Sign returns the HMAC-SHA256 signature of a request, as expected in SignatureHeader
+// after the "sha256=" prefix (hex encoded).
+func Sign(secret []byte, timestamp, method, requestURI string, body []byte) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(timestamp + "." + strings.ToUpper(method) + "." + requestURI + "."))
+	mac.Write(body)
+	return mac.Sum(nil)
+}
@@ -0,0 +1,83 @@
+package signatureaccess
+
+import (
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	sdkaccess "github.com/router-for-me/CLIProxyAPI/v6/sdk/access"
+	sdkconfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+)
+
+func signedRequest(secret string, at time.Time, keyID, body string) *http.Request {
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions?stream=false", strings.NewReader(body))
+	timestamp := strconv.FormatInt(at.Unix(), 10)
+	req.Header.Set(TimestampHeader, timestamp)
+	req.Header.Set(SignatureHeader, "sha256="+hex.EncodeToString(Sign([]byte(secret), timestamp, req.Method, req.URL.RequestURI(), []byte(body))))
+	if keyID != "" {
+		req.Header.Set(KeyIDHeader, keyID)
+	}
+	return req
+}
+
+func TestAuthenticateSignedRequests(t *testing.T) {
+	built, err := newProvider(&sdkconfig.AccessProvider{
+		Type:   ProviderType,
+		Config: map[string]any{"secrets": map[string]any{"ci": "ci-secret"}, "tolerance-seconds": 60},
+	}, nil)
+	if err != nil {
+		t.Fatalf("newProvider: %v", err)
+	}
+	p := built.(*provider)
+	now := time.Unix(1_700_000_000, 0)
+	p.now = func() time.Time { return now }
+	ctx := t.Context()
+
+	req := signedRequest("ci-secret", now, "ci", `{"model":"gpt-4o"}`)
+	result, err := p.Authenticate(ctx, req)
+	if err != nil || result.Principal != "ci" {
+		t.Fatalf("Authenticate = %+v, %v; want principal ci", result, err)
+	}
+	if body, _ := io.ReadAll(req.Body); string(body) != `{"model":"gpt-4o"}` {
+		t.Fatalf("body not restored: %q", body)
+	}
+
+	replay := signedRequest("ci-secret", now, "ci", `{"model":"gpt-4o"}`)
+	if _, err = p.Authenticate(ctx, replay); !errors.Is(err, sdkaccess.ErrInvalidCredential) {
+		t.Fatalf("replayed request: %v, want ErrInvalidCredential", err)
+	}
+	reencoded := signedRequest("ci-secret", now, "ci", `{"model":"gpt-4o"}`)
+	reencoded.Header.Set(SignatureHeader, strings.ToUpper(strings.TrimPrefix(reencoded.Header.Get(SignatureHeader), "sha256=")))
+	if _, err = p.Authenticate(ctx, reencoded); !errors.Is(err, sdkaccess.ErrInvalidCredential) {
+		t.Fatalf("replay with a re-encoded signature: %v, want ErrInvalidCredential", err)
+	}
+
+	cases := map[string]*http.Request{
+		"wrong secret":  signedRequest("other", now, "ci", "{}"),
+		"unknown key":   signedRequest("ci-secret", now, "nope", "{}"),
+		"stale":         signedRequest("ci-secret", now.Add(-2*time.Minute), "ci", "{}"),
+		"tampered body": signedRequest("ci-secret", now, "ci", "{}"),
+	}
+	cases["tampered body"].Body = io.NopCloser(strings.NewReader(`{"model":"o1"}`))
+	for name, req := range cases {
+		if _, err = p.Authenticate(ctx, req); !errors.Is(err, sdkaccess.ErrInvalidCredential) {
+			t.Fatalf("%s: %v, want ErrInvalidCredential", name, err)
+		}
+	}
+
+	if _, err = p.Authenticate(ctx, httptest.NewRequest(http.MethodGet, "/v1/models", nil)); !errors.Is(err, sdkaccess.ErrNoCredentials) {
+		t.Fatalf("unsigned request: %v, want ErrNoCredentials", err)
+	}
+}
+
+func TestNewProviderRequiresSecret(t *testing.T) {
+	if _, err := newProvider(&sdkconfig.AccessProvider{Type: ProviderType}, nil); err == nil {
+		t.Fatal("provider without secret built")
+	}
+}
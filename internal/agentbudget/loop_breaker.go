@@ -0,0 +1,317 @@
+package agentbudget
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/tidwall/gjson"
+)
+
+// LoopErrorCode is returned for requests rejected by the loop breaker.
+const LoopErrorCode = "agent_loop_detected"
+
+var defaultBreaker = NewBreaker()
+
+// DefaultBreaker returns the process-wide loop breaker.
+func DefaultBreaker() *Breaker { return defaultBreaker }
+
+type messageRun struct {
+	fingerprint string
+	count       int
+	last        time.Time
+}
+
+// Breaker detects agents repeating themselves. Repeated tool calls are read from the
+// conversation each request carries; repeated messages are tracked per session.
+type Breaker struct {
+	mu        sync.Mutex
+	cfg       config.LoopBreakerConfig
+	runs      map[string]*messageRun
+	lastPrune time.Time
+	nowFunc   func() time.Time
+}
+
+// NewBreaker constructs a disabled loop breaker.
+func NewBreaker() *Breaker {
+	return &Breaker{runs: make(map[string]*messageRun), nowFunc: time.Now}
+}
+
+// Configure replaces the loop breaker settings. Tracked sessions are kept.
+func (b *Breaker) Configure(cfg config.LoopBreakerConfig) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	b.cfg = cfg
+	b.mu.Unlock()
+}
+
+// Enabled reports whether loop detection is on.
+func (b *Breaker) Enabled() bool {
+	if b == nil {
+		return false
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.cfg.Enabled
+}
+
+// SessionHeader returns the request header identifying sessions.
+func (b *Breaker) SessionHeader() string {
+	if b == nil {
+		return config.DefaultAgentBudgetSessionHeader
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.cfg.SessionHeader == "" {
+		return config.DefaultAgentBudgetSessionHeader
+	}
+	return b.cfg.SessionHeader
+}
+
+// Check inspects a request body in any dialect and returns the error message to answer
+// it with when it continues a loop, or false when the request may proceed.
+func (b *Breaker) Check(apiKey, session string, body []byte) (string, bool) {
+	if b == nil {
+		return "", false
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.cfg.Enabled {
+		return "", false
+	}
+	threshold := b.thresholdLocked(apiKey)
+	if threshold <= 0 {
+		return "", false
+	}
+
+	parsed := gjson.ParseBytes(body)
+	if name, repeats := trailingToolRepeats(parsed); repeats >= threshold {
+		return b.messageLocked(fmt.Sprintf("the tool call %s with the same arguments %d times in a row", name, repeats)), true
+	}
+
+	fingerprint := lastMessageFingerprint(parsed)
+	if fingerprint == "" {
+		return "", false
+	}
+	now := b.nowFunc()
+	window := b.windowLocked()
+	b.pruneLocked(now, window)
+	key := apiKey + "\x00" + session
+	run, exists := b.runs[key]
+	if !exists || run.fingerprint != fingerprint || now.Sub(run.last) > window {
+		run = &messageRun{fingerprint: fingerprint}
+		b.runs[key] = run
+	}
+	run.count++
+	run.last = now
+	if run.count >= threshold {
+		return b.messageLocked(fmt.Sprintf("a near-identical message %d times in a row", run.count)), true
+	}
+	return "", false
+}
+
+func (b *Breaker) thresholdLocked(apiKey string) int {
+	for _, key := range b.cfg.Keys {
+		if containsString(key.APIKeys, apiKey) {
+			return key.Threshold
+		}
+	}
+	if b.cfg.Threshold <= 0 {
+		return config.DefaultLoopBreakerThreshold
+	}
+	return b.cfg.Threshold
+}
+
+func (b *Breaker) windowLocked() time.Duration {
+	if b.cfg.WindowSeconds <= 0 {
+		return config.DefaultLoopBreakerWindowSeconds * time.Second
+	}
+	return time.Duration(b.cfg.WindowSeconds) * time.Second
+}
+
+func (b *Breaker) messageLocked(repeated string) string {
+	if b.cfg.Message != "" {
+		return b.cfg.Message
+	}
+	return "Loop detected: the agent sent " + repeated + ". The proxy stopped the loop; try a different approach instead of repeating the same step."
+}
+
+// pruneLocked drops sessions idle for longer than window, at most once per window.
+func (b *Breaker) pruneLocked(now time.Time, window time.Duration) {
+	if now.Sub(b.lastPrune) < window {
+		return
+	}
+	b.lastPrune = now
+	for key, run := range b.runs {
+		if now.Sub(run.last) > window {
+			delete(b.runs, key)
+		}
+	}
+}
+
+// trailingToolRepeats returns the name of the last tool call in the conversation and how
+// many calls in a row at its end used that tool with the same arguments. Only calls made
+// since the last user turn that is not a tool result count, so a conversation continues
+// normally once the user steps in after a loop.
+func trailingToolRepeats(body gjson.Result) (string, int) {
+	type call struct{ name, args string }
+	var calls []call
+	add := func(name string, args gjson.Result) {
+		calls = append(calls, call{name: name, args: canonicalArguments(args)})
+	}
+	body.Get("messages").ForEach(func(_, message gjson.Result) bool {
+		if message.Get("role").String() == "user" && !carriesToolResults(message.Get("content")) {
+			calls = calls[:0]
+			return true
+		}
+		if message.Get("role").String() != "assistant" {
+			return true
+		}
+		message.Get("tool_calls").ForEach(func(_, tc gjson.Result) bool {
+			add(tc.Get("function.name").String(), tc.Get("function.arguments"))
+			return true
+		})
+		message.Get("content").ForEach(func(_, block gjson.Result) bool {
+			if block.Get("type").String() == "tool_use" {
+				add(block.Get("name").String(), block.Get("input"))
+			}
+			return true
+		})
+		return true
+	})
+	body.Get("contents").ForEach(func(_, content gjson.Result) bool {
+		parts := content.Get("parts")
+		if content.Get("role").String() == "user" && len(parts.Get("#.functionResponse").Array()) == 0 {
+			calls = calls[:0]
+			return true
+		}
+		parts.ForEach(func(_, part gjson.Result) bool {
+			if fc := part.Get("functionCall"); fc.Exists() {
+				add(fc.Get("name").String(), fc.Get("args"))
+			}
+			return true
+		})
+		return true
+	})
+	body.Get("input").ForEach(func(_, item gjson.Result) bool {
+		switch {
+		case item.Get("type").String() == "function_call":
+			add(item.Get("name").String(), item.Get("arguments"))
+		case item.Get("role").String() == "user":
+			calls = calls[:0]
+		}
+		return true
+	})
+	if len(calls) == 0 {
+		return "", 0
+	}
+	last := calls[len(calls)-1]
+	repeats := 0
+	for i := len(calls) - 1; i >= 0 && calls[i] == last; i-- {
+		repeats++
+	}
+	return last.name, repeats
+}
+
+// carriesToolResults reports whether message content holds tool_result blocks.
+func carriesToolResults(content gjson.Result) bool {
+	found := false
+	content.ForEach(func(_, block gjson.Result) bool {
+		found = block.Get("type").String() == "tool_result"
+		return !found
+	})
+	return found
+}
+
+// canonicalArguments renders tool arguments, given as JSON or a JSON string, with sorted
+// keys so equal arguments compare equal.
+func canonicalArguments(args gjson.Result) string {
+	raw := args.Raw
+	if args.Type == gjson.String {
+		raw = args.String()
+	}
+	var value any
+	if err := json.Unmarshal([]byte(raw), &value); err != nil {
+		return strings.TrimSpace(raw)
+	}
+	canonical, err := json.Marshal(value)
+	if err != nil {
+		return raw
+	}
+	return string(canonical)
+}
+
+// lastMessageFingerprint hashes the normalized text of the last turn of the conversation.
+// Case, whitespace, digits and identifiers are ignored, so near-identical turns match.
+func lastMessageFingerprint(body gjson.Result) string {
+	var last gjson.Result
+	for _, path := range []string{"messages", "contents", "input"} {
+		value := body.Get(path)
+		if value.Type == gjson.String {
+			last = value
+			break
+		}
+		if items := value.Array(); len(items) > 0 {
+			last = items[len(items)-1]
+			break
+		}
+	}
+	if !last.Exists() {
+		return ""
+	}
+	var text strings.Builder
+	collectText(last, &text)
+	normalized := normalizeText(text.String())
+	if normalized == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:])
+}
+
+// collectText appends the string values of node, skipping identifiers and signatures
+// that differ between otherwise identical turns.
+func collectText(node gjson.Result, b *strings.Builder) {
+	switch {
+	case node.Type == gjson.String:
+		b.WriteString(node.String())
+		b.WriteByte(' ')
+	case node.IsArray() || node.IsObject():
+		node.ForEach(func(key, value gjson.Result) bool {
+			name := key.String()
+			if name == "id" || name == "role" || name == "type" || strings.HasSuffix(name, "_id") || strings.HasSuffix(name, "Id") || strings.HasSuffix(strings.ToLower(name), "signature") {
+				return true
+			}
+			collectText(value, b)
+			return true
+		})
+	}
+}
+
+func normalizeText(text string) string {
+	var b strings.Builder
+	space := false
+	for _, r := range strings.ToLower(text) {
+		switch {
+		case unicode.IsSpace(r):
+			space = b.Len() > 0
+			continue
+		case unicode.IsDigit(r):
+			r = '#'
+		}
+		if space {
+			b.WriteByte(' ')
+			space = false
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
@@ -0,0 +1,140 @@
+package agentbudget
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/tidwall/gjson"
+)
+
+func newTestBreaker(now *time.Time) *Breaker {
+	breaker := NewBreaker()
+	breaker.nowFunc = func() time.Time { return *now }
+	breaker.Configure(config.LoopBreakerConfig{
+		Enabled:       true,
+		Threshold:     3,
+		WindowSeconds: 60,
+		Keys:          []config.LoopBreakerKey{{APIKeys: []string{"free"}, Threshold: 0}},
+	})
+	return breaker
+}
+
+func openAIToolLoop(calls int, args string) []byte {
+	var messages []string
+	messages = append(messages, `{"role":"user","content":"read the file"}`)
+	for i := 0; i < calls; i++ {
+		messages = append(messages,
+			fmt.Sprintf(`{"role":"assistant","tool_calls":[{"id":"call_%d","type":"function","function":{"name":"read_file","arguments":%q}}]}`, i, args),
+			fmt.Sprintf(`{"role":"tool","tool_call_id":"call_%d","content":"error: not found (attempt %d)"}`, i, i))
+	}
+	return []byte(`{"model":"gpt-5","messages":[` + strings.Join(messages, ",") + `]}`)
+}
+
+func TestBreaker_RepeatedToolCalls(t *testing.T) {
+	now := time.Unix(1000, 0)
+	breaker := newTestBreaker(&now)
+
+	if _, broken := breaker.Check("k", "toolsA", openAIToolLoop(2, `{"path":"a.go"}`)); broken {
+		t.Fatal("two repeated calls should not break a threshold of 3")
+	}
+	message, broken := breaker.Check("k", "toolsB", openAIToolLoop(3, `{"path": "a.go"}`))
+	if !broken || !strings.Contains(message, "read_file") || !strings.Contains(message, "3 times") {
+		t.Fatalf("message = %q, broken = %v", message, broken)
+	}
+	if _, broken = breaker.Check("free", "toolsC", openAIToolLoop(10, `{"path":"a.go"}`)); broken {
+		t.Fatal("threshold 0 should disable detection for the key")
+	}
+}
+
+func TestTrailingToolRepeats_Dialects(t *testing.T) {
+	body := []byte(`{"model":"claude-sonnet-4","messages":[
+		{"role":"user","content":"go"},
+		{"role":"assistant","content":[{"type":"tool_use","id":"t1","name":"grep","input":{"q":"a"}}]},
+		{"role":"user","content":[{"type":"tool_result","tool_use_id":"t1","content":"none"}]},
+		{"role":"assistant","content":[{"type":"tool_use","id":"t2","name":"grep","input":{"q":"b"}}]},
+		{"role":"user","content":[{"type":"tool_result","tool_use_id":"t2","content":"none"}]},
+		{"role":"assistant","content":[{"type":"tool_use","id":"t3","name":"grep","input":{"q":"b"}}]},
+		{"role":"user","content":[{"type":"tool_result","tool_use_id":"t3","content":"none"}]}
+	]}`)
+	if name, repeats := trailingToolRepeats(gjson.ParseBytes(body)); name != "grep" || repeats != 2 {
+		t.Fatalf("trailing repeats = %s x%d, want grep x2", name, repeats)
+	}
+
+	gemini := []byte(`{"contents":[
+		{"role":"model","parts":[{"functionCall":{"name":"ls","args":{"dir":"."}}}]},
+		{"role":"user","parts":[{"functionResponse":{"name":"ls","response":{}}}]},
+		{"role":"model","parts":[{"functionCall":{"name":"ls","args":{"dir":"."}}}]}
+	]}`)
+	if name, repeats := trailingToolRepeats(gjson.ParseBytes(gemini)); name != "ls" || repeats != 2 {
+		t.Fatalf("gemini trailing repeats = %s x%d, want ls x2", name, repeats)
+	}
+
+	responses := []byte(`{"input":[
+		{"type":"function_call","call_id":"c1","name":"shell","arguments":"{\"cmd\":\"make\"}"},
+		{"type":"function_call_output","call_id":"c1","output":"fail"},
+		{"type":"function_call","call_id":"c2","name":"shell","arguments":"{\"cmd\":\"make\"}"}
+	]}`)
+	if name, repeats := trailingToolRepeats(gjson.ParseBytes(responses)); name != "shell" || repeats != 2 {
+		t.Fatalf("responses trailing repeats = %s x%d, want shell x2", name, repeats)
+	}
+}
+
+func TestBreaker_RepeatedMessages(t *testing.T) {
+	now := time.Unix(1000, 0)
+	breaker := newTestBreaker(&now)
+	request := func(text string) []byte {
+		return []byte(fmt.Sprintf(`{"model":"gpt-5","messages":[{"role":"user","content":%q}]}`, text))
+	}
+
+	if _, broken := breaker.Check("k", "s1", request("Retry the build, attempt 1")); broken {
+		t.Fatal("first message should pass")
+	}
+	now = now.Add(5 * time.Second)
+	if _, broken := breaker.Check("k", "s1", request("retry  the build, attempt 2")); broken {
+		t.Fatal("second message should pass")
+	}
+	if _, broken := breaker.Check("k", "s2", request("Retry the build, attempt 3")); broken {
+		t.Fatal("other sessions are tracked separately")
+	}
+	now = now.Add(5 * time.Second)
+	message, broken := breaker.Check("k", "s1", request("Retry the build, attempt 3"))
+	if !broken || !strings.Contains(message, "near-identical message 3 times") {
+		t.Fatalf("message = %q, broken = %v", message, broken)
+	}
+
+	if _, broken = breaker.Check("k", "s1", request("Try a different approach")); broken {
+		t.Fatal("a different message should reset the run")
+	}
+	now = now.Add(61 * time.Second)
+	for i := 0; i < 2; i++ {
+		if _, broken = breaker.Check("k", "s1", request("Try a different approach")); broken {
+			t.Fatal("the run should restart after the window lapses")
+		}
+	}
+}
+
+func TestBreaker_CustomMessageAndDisabled(t *testing.T) {
+	breaker := NewBreaker()
+	body := openAIToolLoop(6, `{}`)
+	if _, broken := breaker.Check("k", "", body); broken {
+		t.Fatal("a disabled breaker should not break loops")
+	}
+	breaker.Configure(config.LoopBreakerConfig{Enabled: true, Message: "stop looping"})
+	if message, broken := breaker.Check("k", "", body); !broken || message != "stop looping" {
+		t.Fatalf("message = %q, broken = %v", message, broken)
+	}
+}
+
+func TestTrailingToolRepeats_ResetByUserTurn(t *testing.T) {
+	loop := openAIToolLoop(3, `{"path":"a.go"}`)
+	if _, repeats := trailingToolRepeats(gjson.ParseBytes(loop)); repeats != 3 {
+		t.Fatalf("repeats = %d, want 3", repeats)
+	}
+	continued := strings.TrimSuffix(string(loop), `]}`) + `,{"role":"user","content":"try b.go instead"},{"role":"assistant","tool_calls":[{"id":"call_x","type":"function","function":{"name":"read_file","arguments":"{\"path\":\"a.go\"}"}}]}]}`
+	if _, repeats := trailingToolRepeats(gjson.Parse(continued)); repeats != 1 {
+		t.Fatalf("repeats after a user turn = %d, want 1", repeats)
+	}
+}
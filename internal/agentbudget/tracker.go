@@ -0,0 +1,282 @@
+// Package agentbudget detects agent loops, runs of rapid sequential requests on one
+// session, and enforces step and token budgets on them. The tracker counts the steps of a
+// loop as requests arrive and its tokens from the usage pipeline.
+package agentbudget
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	coreusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+)
+
+const (
+	// Header reports the budget of the current loop on responses.
+	Header = "X-CLIProxy-Agent-Budget"
+	// SessionContextKey is the gin context key holding the tracked loop of a request.
+	SessionContextKey = "agent_budget_session"
+	// ErrorCode is returned for requests blocked by an exhausted budget.
+	ErrorCode = "agent_budget_exceeded"
+)
+
+var defaultTracker = NewTracker()
+
+func init() {
+	coreusage.RegisterPlugin(defaultTracker)
+}
+
+// Default returns the process-wide tracker fed by the usage pipeline.
+func Default() *Tracker { return defaultTracker }
+
+// Request describes the attributes of a request that rules match and loops are keyed by.
+type Request struct {
+	APIKey  string
+	Session string
+	Model   string
+}
+
+// Decision is the state of the loop a request belongs to.
+type Decision struct {
+	Rule     config.AgentBudgetRule
+	Key      string
+	Steps    int
+	Tokens   int64
+	Exceeded bool
+}
+
+// Status describes an active loop.
+type Status struct {
+	Session   string    `json:"session"`
+	APIKey    string    `json:"api_key,omitempty"`
+	Rule      string    `json:"rule"`
+	Steps     int       `json:"steps"`
+	Tokens    int64     `json:"tokens"`
+	MaxSteps  int       `json:"max_steps,omitempty"`
+	MaxTokens int64     `json:"max_tokens,omitempty"`
+	Exceeded  bool      `json:"exceeded"`
+	StartedAt time.Time `json:"started_at"`
+	LastSeen  time.Time `json:"last_seen"`
+}
+
+type loop struct {
+	apiKey  string
+	session string
+	rule    config.AgentBudgetRule
+	steps   int
+	tokens  int64
+	started time.Time
+	last    time.Time
+}
+
+// Tracker implements coreusage.Plugin.
+type Tracker struct {
+	mu        sync.Mutex
+	cfg       config.AgentBudgetConfig
+	loops     map[string]*loop
+	lastPrune time.Time
+	nowFunc   func() time.Time
+}
+
+// NewTracker constructs an unconfigured tracker.
+func NewTracker() *Tracker {
+	return &Tracker{loops: make(map[string]*loop), nowFunc: time.Now}
+}
+
+// Configure replaces the active budgets. Loops in progress keep their counts.
+func (t *Tracker) Configure(cfg config.AgentBudgetConfig) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	t.cfg = cfg
+	t.mu.Unlock()
+}
+
+// Enabled reports whether any budget is configured.
+func (t *Tracker) Enabled() bool {
+	if t == nil {
+		return false
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return len(t.cfg.Rules) > 0
+}
+
+// SessionHeader returns the request header identifying sessions.
+func (t *Tracker) SessionHeader() string {
+	if t == nil {
+		return config.DefaultAgentBudgetSessionHeader
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.cfg.SessionHeader == "" {
+		return config.DefaultAgentBudgetSessionHeader
+	}
+	return t.cfg.SessionHeader
+}
+
+// Begin counts req as the next step of its session's loop, or as the first step of a new
+// loop when the session paused longer than the loop gap. It reports false when no rule
+// applies to req.
+func (t *Tracker) Begin(req Request) (Decision, bool) {
+	if t == nil {
+		return Decision{}, false
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	rule, ok := t.matchLocked(req)
+	if !ok {
+		return Decision{}, false
+	}
+	now := t.nowFunc()
+	gap := t.gapLocked()
+	t.pruneLocked(now, gap)
+
+	key := req.APIKey + "\x00" + req.Session
+	l, exists := t.loops[key]
+	if !exists || now.Sub(l.last) > gap || l.rule.Name != rule.Name {
+		l = &loop{apiKey: req.APIKey, session: req.Session, started: now}
+		t.loops[key] = l
+	}
+	l.rule = rule
+	l.steps++
+	l.last = now
+	return Decision{
+		Rule:     rule,
+		Key:      key,
+		Steps:    l.steps,
+		Tokens:   l.tokens,
+		Exceeded: exhausted(rule, l.steps, l.tokens),
+	}, true
+}
+
+// HandleUsage implements coreusage.Plugin, adding the tokens of a request to its loop and
+// extending the loop from the time the response finished.
+func (t *Tracker) HandleUsage(ctx context.Context, record coreusage.Record) {
+	if t == nil || ctx == nil {
+		return
+	}
+	ginCtx, ok := ctx.Value("gin").(*gin.Context)
+	if !ok || ginCtx == nil {
+		return
+	}
+	key := ginCtx.GetString(SessionContextKey)
+	if key == "" {
+		return
+	}
+	tokens := record.Detail.TotalTokens
+	if tokens == 0 {
+		tokens = record.Detail.InputTokens + record.Detail.OutputTokens + record.Detail.ReasoningTokens
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if l, exists := t.loops[key]; exists {
+		l.tokens += tokens
+		l.last = t.nowFunc()
+	}
+}
+
+// Snapshot returns the active loops, most recently seen first.
+func (t *Tracker) Snapshot() []Status {
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := t.nowFunc()
+	gap := t.gapLocked()
+	out := make([]Status, 0, len(t.loops))
+	for _, l := range t.loops {
+		if now.Sub(l.last) > gap {
+			continue
+		}
+		out = append(out, Status{
+			Session:   l.session,
+			APIKey:    util.HideAPIKey(l.apiKey),
+			Rule:      l.rule.Name,
+			Steps:     l.steps,
+			Tokens:    l.tokens,
+			MaxSteps:  l.rule.MaxSteps,
+			MaxTokens: l.rule.MaxTokens,
+			Exceeded:  exhausted(l.rule, l.steps, l.tokens),
+			StartedAt: l.started,
+			LastSeen:  l.last,
+		})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].LastSeen.After(out[j].LastSeen) })
+	return out
+}
+
+// Reset ends the loops of session, or every loop when session is empty.
+func (t *Tracker) Reset(session string) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for key, l := range t.loops {
+		if session == "" || l.session == session {
+			delete(t.loops, key)
+		}
+	}
+}
+
+func (t *Tracker) matchLocked(req Request) (config.AgentBudgetRule, bool) {
+	for _, rule := range t.cfg.Rules {
+		if len(rule.APIKeys) > 0 && !containsString(rule.APIKeys, req.APIKey) {
+			continue
+		}
+		if len(rule.Models) > 0 && !matchesAny(rule.Models, req.Model) {
+			continue
+		}
+		return rule, true
+	}
+	return config.AgentBudgetRule{}, false
+}
+
+func (t *Tracker) gapLocked() time.Duration {
+	if t.cfg.LoopGapSeconds <= 0 {
+		return config.DefaultAgentBudgetLoopGapSeconds * time.Second
+	}
+	return time.Duration(t.cfg.LoopGapSeconds) * time.Second
+}
+
+// pruneLocked drops ended loops, at most once per gap.
+func (t *Tracker) pruneLocked(now time.Time, gap time.Duration) {
+	if now.Sub(t.lastPrune) < gap {
+		return
+	}
+	t.lastPrune = now
+	for key, l := range t.loops {
+		if now.Sub(l.last) > gap {
+			delete(t.loops, key)
+		}
+	}
+}
+
+func exhausted(rule config.AgentBudgetRule, steps int, tokens int64) bool {
+	return (rule.MaxSteps > 0 && steps > rule.MaxSteps) || (rule.MaxTokens > 0 && tokens >= rule.MaxTokens)
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func matchesAny(patterns []string, model string) bool {
+	for _, pattern := range patterns {
+		if util.MatchWildcard(pattern, model) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,90 @@
+package agentbudget
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	coreusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+)
+
+func newTestTracker(now *time.Time) *Tracker {
+	tracker := NewTracker()
+	tracker.nowFunc = func() time.Time { return *now }
+	tracker.Configure(config.AgentBudgetConfig{LoopGapSeconds: 30, Rules: []config.AgentBudgetRule{
+		{Name: "premium", Models: []string{"claude-opus-*"}, MaxSteps: 3, Action: config.AgentBudgetActionBlock},
+		{Name: "tokens", MaxTokens: 1000, Action: config.AgentBudgetActionWarn},
+	}})
+	return tracker
+}
+
+func TestTracker_CountsStepsUntilLoopPauses(t *testing.T) {
+	now := time.Unix(1000, 0)
+	tracker := newTestTracker(&now)
+	req := Request{APIKey: "k", Session: "s1", Model: "claude-opus-4"}
+
+	var decision Decision
+	for step := 1; step <= 4; step++ {
+		var ok bool
+		decision, ok = tracker.Begin(req)
+		if !ok || decision.Rule.Name != "premium" || decision.Steps != step {
+			t.Fatalf("step %d: decision = %+v, ok = %v", step, decision, ok)
+		}
+		now = now.Add(10 * time.Second)
+	}
+	if !decision.Exceeded {
+		t.Fatal("fourth step should exceed max-steps 3")
+	}
+
+	// Another session of the same key keeps its own loop.
+	if other, _ := tracker.Begin(Request{APIKey: "k", Session: "s2", Model: "claude-opus-4"}); other.Steps != 1 {
+		t.Fatalf("other session steps = %d", other.Steps)
+	}
+
+	// A pause longer than the loop gap starts over.
+	now = now.Add(31 * time.Second)
+	if decision, _ = tracker.Begin(req); decision.Steps != 1 || decision.Exceeded {
+		t.Fatalf("after pause: %+v", decision)
+	}
+}
+
+func TestTracker_AddsTokensFromUsage(t *testing.T) {
+	now := time.Unix(1000, 0)
+	tracker := newTestTracker(&now)
+	req := Request{APIKey: "k", Model: "gpt-5"}
+
+	decision, _ := tracker.Begin(req)
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Set(SessionContextKey, decision.Key)
+	ctx := context.WithValue(context.Background(), "gin", c)
+	tracker.HandleUsage(ctx, coreusage.Record{Detail: coreusage.Detail{InputTokens: 700, OutputTokens: 400}})
+
+	// The loop gap counts from the response, not the request.
+	now = now.Add(40 * time.Second)
+	tracker.HandleUsage(ctx, coreusage.Record{})
+	now = now.Add(20 * time.Second)
+	if decision, _ = tracker.Begin(req); decision.Tokens != 1100 || !decision.Exceeded || decision.Rule.Name != "tokens" {
+		t.Fatalf("decision = %+v", decision)
+	}
+
+	status := tracker.Snapshot()
+	if len(status) != 1 || status[0].Steps != 2 || status[0].Tokens != 1100 || !status[0].Exceeded {
+		t.Fatalf("snapshot = %+v", status)
+	}
+	tracker.Reset("")
+	if len(tracker.Snapshot()) != 0 {
+		t.Fatal("reset kept loops")
+	}
+}
+
+func TestTracker_IgnoresUnmatchedRequests(t *testing.T) {
+	tracker := NewTracker()
+	tracker.Configure(config.AgentBudgetConfig{Rules: []config.AgentBudgetRule{{APIKeys: []string{"agent-key"}, MaxSteps: 1}}})
+	if _, ok := tracker.Begin(Request{APIKey: "other", Model: "gpt-5"}); ok {
+		t.Fatal("rule restricted to agent-key matched another key")
+	}
+}
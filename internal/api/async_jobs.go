@@ -0,0 +1,213 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/asyncjob"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/vhost"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers"
+	"github.com/tidwall/gjson"
+)
+
+// asyncJobSkipHeaders are headers of the submitting request not stored with a job.
+var asyncJobSkipHeaders = map[string]bool{
+	"Content-Length":  true,
+	"Idempotency-Key": true,
+	"Accept":          true,
+	"Accept-Encoding": true,
+	"Connection":      true,
+	"Cookie":          true,
+}
+
+// asyncJobJSON renders a job with the URL its result is fetched from once it ended.
+func asyncJobJSON(c *gin.Context, j asyncjob.Job) gin.H {
+	out := gin.H(j.Summary())
+	out["result_url"] = nil
+	if j.Ended() {
+		scheme := "http"
+		if c.Request.TLS != nil {
+			scheme = "https"
+		}
+		out["result_url"] = scheme + "://" + c.Request.Host + "/v1/jobs/" + j.ID + "/result"
+	}
+	return out
+}
+
+func asyncJobError(c *gin.Context, status int, message string) {
+	c.Data(status, "application/json", handlers.BuildDialectErrorBody(handlers.DialectOpenAI, status, "", message))
+}
+
+func asyncJobLookupError(c *gin.Context, err error) {
+	if errors.Is(err, asyncjob.ErrNotFound) {
+		asyncJobError(c, http.StatusNotFound, "job "+c.Param("id")+" not found")
+		return
+	}
+	asyncJobError(c, http.StatusConflict, err.Error())
+}
+
+// asyncJobsEnabled answers 404 while the job API is disabled.
+func (s *Server) asyncJobsEnabled(c *gin.Context) bool {
+	if !s.asyncJobs.Enabled() {
+		asyncJobError(c, http.StatusNotFound, asyncjob.ErrDisabled.Error())
+		return false
+	}
+	return true
+}
+
+// submitAsyncJob serves POST /v1/jobs. The body names the dialect of the request (openai,
+// claude, responses or gemini) and the non-streaming request itself; the job runs in the
+// background with the submitting request's credentials and is answered with 202.
+func (s *Server) submitAsyncJob(c *gin.Context) {
+	if !s.asyncJobsEnabled(c) {
+		return
+	}
+	body, err := c.GetRawData()
+	if err != nil || !gjson.ValidBytes(body) {
+		asyncJobError(c, http.StatusBadRequest, "request body must be JSON")
+		return
+	}
+	dialect := strings.ToLower(strings.TrimSpace(gjson.GetBytes(body, "dialect").String()))
+	if dialect == "" {
+		dialect = "openai"
+	}
+	request := gjson.GetBytes(body, "request")
+	if !request.IsObject() {
+		asyncJobError(c, http.StatusBadRequest, "request must be a JSON object")
+		return
+	}
+	if request.Get("stream").Bool() {
+		asyncJobError(c, http.StatusBadRequest, "request.stream is not supported in jobs")
+		return
+	}
+	// Building the request validates the dialect and, for Gemini, the model.
+	if _, err = newBridgedRequest(c.Request.Context(), dialect, []byte(request.Raw), false, nil); err != nil {
+		asyncJobError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	webhookURL := strings.TrimSpace(gjson.GetBytes(body, "webhook_url").String())
+	if webhookURL != "" {
+		if !s.asyncJobs.AllowsClientWebhooks() {
+			asyncJobError(c, http.StatusBadRequest, "webhook_url is not allowed; the proxy posts to its configured webhook")
+			return
+		}
+		if parsed, errParse := url.Parse(webhookURL); errParse != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			asyncJobError(c, http.StatusBadRequest, "webhook_url must be an http or https URL")
+			return
+		}
+	}
+	header := make(http.Header)
+	for key, values := range c.Request.Header {
+		if !asyncJobSkipHeaders[http.CanonicalHeaderKey(key)] {
+			header[key] = append([]string(nil), values...)
+		}
+	}
+	job, err := s.asyncJobs.Submit(c.GetString("apiKey"), vhost.FromContext(c.Request.Context()), dialect, []byte(request.Raw), header, webhookURL)
+	if err != nil {
+		asyncJobError(c, http.StatusInternalServerError, "failed to persist job: "+err.Error())
+		return
+	}
+	c.JSON(http.StatusAccepted, asyncJobJSON(c, job))
+}
+
+// executeAsyncJob serves the request of a job through the engine with its stored headers
+// and virtual host.
+func (s *Server) executeAsyncJob(ctx context.Context, job asyncjob.Job) (int, []byte, error) {
+	req, err := newBridgedRequest(ctx, job.Dialect, job.Body, false, nil)
+	if err != nil {
+		return 0, nil, err
+	}
+	for key, values := range job.Header {
+		req.Header[key] = append([]string(nil), values...)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	var response []byte
+	writer := newWSBridgeWriter(func(data []byte) error {
+		response = append(response, data...)
+		return nil
+	})
+	s.serveBridged(writer, req, job.VirtualHost)
+	if err = writer.finish(); err != nil {
+		return 0, nil, err
+	}
+	return writer.status, response, nil
+}
+
+// listAsyncJobs serves GET /v1/jobs, newest first.
+func (s *Server) listAsyncJobs(c *gin.Context) {
+	if !s.asyncJobsEnabled(c) {
+		return
+	}
+	jobs := s.asyncJobs.List(c.GetString("apiKey"))
+	data := make([]gin.H, 0, len(jobs))
+	for _, j := range jobs {
+		data = append(data, asyncJobJSON(c, j))
+	}
+	c.JSON(http.StatusOK, gin.H{"object": "list", "data": data})
+}
+
+// getAsyncJob serves GET /v1/jobs/:id for polling.
+func (s *Server) getAsyncJob(c *gin.Context) {
+	if !s.asyncJobsEnabled(c) {
+		return
+	}
+	j, err := s.asyncJobs.Get(c.GetString("apiKey"), c.Param("id"))
+	if err != nil {
+		asyncJobLookupError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, asyncJobJSON(c, j))
+}
+
+// asyncJobResult serves GET /v1/jobs/:id/result: the status and body of the job's response
+// as the dialect's endpoint returned them.
+func (s *Server) asyncJobResult(c *gin.Context) {
+	if !s.asyncJobsEnabled(c) {
+		return
+	}
+	j, err := s.asyncJobs.Get(c.GetString("apiKey"), c.Param("id"))
+	if err != nil {
+		asyncJobLookupError(c, err)
+		return
+	}
+	switch {
+	case !j.Ended():
+		asyncJobLookupError(c, asyncjob.ErrNotEnded)
+	case j.Status == asyncjob.StatusCanceled:
+		asyncJobError(c, http.StatusConflict, "job was canceled")
+	case j.ResultStatus == 0 || len(j.Result) == 0:
+		asyncJobError(c, http.StatusBadGateway, "job failed: "+j.Error)
+	default:
+		c.Data(j.ResultStatus, "application/json", j.Result)
+	}
+}
+
+// cancelAsyncJob serves POST /v1/jobs/:id/cancel.
+func (s *Server) cancelAsyncJob(c *gin.Context) {
+	if !s.asyncJobsEnabled(c) {
+		return
+	}
+	j, err := s.asyncJobs.Cancel(c.GetString("apiKey"), c.Param("id"))
+	if err != nil {
+		asyncJobLookupError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, asyncJobJSON(c, j))
+}
+
+// deleteAsyncJob serves DELETE /v1/jobs/:id for ended jobs.
+func (s *Server) deleteAsyncJob(c *gin.Context) {
+	if !s.asyncJobsEnabled(c) {
+		return
+	}
+	id := c.Param("id")
+	if err := s.asyncJobs.Delete(c.GetString("apiKey"), id); err != nil {
+		asyncJobLookupError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"id": id, "object": "job", "deleted": true})
+}
@@ -0,0 +1,92 @@
+package api
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/asyncjob"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/tidwall/gjson"
+)
+
+func TestAsyncJobs(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	s := &Server{engine: gin.New()}
+	s.asyncJobs = asyncjob.NewStore(s.executeAsyncJob)
+	var authorization string
+	s.engine.POST("/v1/chat/completions", func(c *gin.Context) {
+		authorization = c.GetHeader("Authorization")
+		body, _ := c.GetRawData()
+		c.JSON(http.StatusOK, gin.H{"object": "chat.completion", "model": gjson.GetBytes(body, "model").String(), "stream": gjson.GetBytes(body, "stream").Bool()})
+	})
+	jobs := s.engine.Group("/v1/jobs")
+	jobs.POST("", s.submitAsyncJob)
+	jobs.GET("", s.listAsyncJobs)
+	jobs.GET("/:id", s.getAsyncJob)
+	jobs.GET("/:id/result", s.asyncJobResult)
+	jobs.DELETE("/:id", s.deleteAsyncJob)
+
+	serve := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, bytes.NewReader([]byte(body)))
+		req.Header.Set("Authorization", "Bearer client-key")
+		w := httptest.NewRecorder()
+		s.engine.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := serve(http.MethodPost, "/v1/jobs", `{"request":{"model":"m"}}`); w.Code != http.StatusNotFound {
+		t.Fatalf("disabled submit = %d %s", w.Code, w.Body.String())
+	}
+	s.asyncJobs.Configure(config.AsyncJobsConfig{Enable: true, Dir: t.TempDir(), Concurrency: 1, TimeoutSeconds: 60, RetentionHours: 1}, "")
+	defer s.asyncJobs.Stop()
+
+	for _, body := range []string{
+		`{"request":{"model":"m","stream":true}}`,
+		`{"dialect":"cobol","request":{"model":"m"}}`,
+		`{"request":{"model":"m"},"webhook_url":"http://example.com/hook"}`,
+	} {
+		if w := serve(http.MethodPost, "/v1/jobs", body); w.Code != http.StatusBadRequest {
+			t.Fatalf("submit %s = %d %s", body, w.Code, w.Body.String())
+		}
+	}
+
+	w := serve(http.MethodPost, "/v1/jobs", `{"dialect":"openai","request":{"model":"slow-reasoner","messages":[]}}`)
+	id := gjson.Get(w.Body.String(), "id").String()
+	if w.Code != http.StatusAccepted || !strings.HasPrefix(id, asyncjob.IDPrefix) || gjson.Get(w.Body.String(), "model").String() != "slow-reasoner" {
+		t.Fatalf("submit = %d %s", w.Code, w.Body.String())
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for gjson.Get(w.Body.String(), "status").String() != asyncjob.StatusSucceeded {
+		if time.Now().After(deadline) {
+			t.Fatalf("job did not succeed: %s", w.Body.String())
+		}
+		time.Sleep(5 * time.Millisecond)
+		w = serve(http.MethodGet, "/v1/jobs/"+id, "")
+	}
+	if got := gjson.Get(w.Body.String(), "result_url").String(); !strings.HasSuffix(got, "/v1/jobs/"+id+"/result") {
+		t.Fatalf("result_url = %q", got)
+	}
+	if authorization != "Bearer client-key" {
+		t.Fatalf("job ran with Authorization %q", authorization)
+	}
+
+	w = serve(http.MethodGet, "/v1/jobs/"+id+"/result", "")
+	if w.Code != http.StatusOK || gjson.Get(w.Body.String(), "model").String() != "slow-reasoner" || gjson.Get(w.Body.String(), "stream").Bool() {
+		t.Fatalf("result = %d %s", w.Code, w.Body.String())
+	}
+	if w = serve(http.MethodGet, "/v1/jobs", ""); gjson.Get(w.Body.String(), "data.#").Int() != 1 {
+		t.Fatalf("list = %s", w.Body.String())
+	}
+	if w = serve(http.MethodDelete, "/v1/jobs/"+id, ""); w.Code != http.StatusOK {
+		t.Fatalf("delete = %d %s", w.Code, w.Body.String())
+	}
+	if w = serve(http.MethodGet, "/v1/jobs/"+id, ""); w.Code != http.StatusNotFound {
+		t.Fatalf("deleted job = %d", w.Code)
+	}
+}
@@ -0,0 +1,65 @@
+package api
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync/atomic"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers"
+)
+
+// requestBodyLimitMiddleware rejects request bodies larger than max-request-body-bytes with
+// 413. It is installed first, before any middleware reads the body. Bodies declaring their
+// length are rejected up front; bodies of unknown length are read up to the limit, and kept
+// for the handler when they fit.
+func requestBodyLimitMiddleware(maxBytes *atomic.Int64) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		limit := maxBytes.Load()
+		if limit <= 0 || c.Request.Body == nil || c.Request.Body == http.NoBody {
+			c.Next()
+			return
+		}
+		if c.Request.ContentLength > limit {
+			rejectRequestBody(c, fmt.Sprintf("request body of %d bytes exceeds the limit of %d bytes", c.Request.ContentLength, limit))
+			return
+		}
+		if c.Request.ContentLength < 0 {
+			body, err := io.ReadAll(io.LimitReader(c.Request.Body, limit+1))
+			if err != nil {
+				c.AbortWithStatus(http.StatusBadRequest)
+				return
+			}
+			if int64(len(body)) > limit {
+				rejectRequestBody(c, fmt.Sprintf("request body exceeds the limit of %d bytes", limit))
+				return
+			}
+			c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		}
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, limit)
+		c.Next()
+	}
+}
+
+func rejectRequestBody(c *gin.Context, message string) {
+	code := handlers.ErrorCodeRequestTooLarge
+	c.Header(handlers.ErrorCodeHeader, string(code))
+	c.Header("Connection", "close")
+	c.Data(code.StatusFor(), "application/json", handlers.BuildDialectErrorBody(pathDialect(c.Request.URL.Path), code.StatusFor(), code, message))
+	c.Abort()
+}
+
+// pathDialect returns the error dialect of an API path, including the provider paths of
+// Amp (/api/provider/anthropic/v1/messages).
+func pathDialect(path string) handlers.Dialect {
+	switch {
+	case strings.Contains(path, "/v1beta"):
+		return handlers.DialectGemini
+	case strings.Contains(path, "/v1/messages"):
+		return handlers.DialectClaude
+	}
+	return handlers.DialectOpenAI
+}
@@ -0,0 +1,173 @@
+package api
+
+import (
+	"net/http"
+	"sort"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/buildinfo"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+)
+
+// capabilities is what /v0/capabilities reports, so clients can configure themselves
+// instead of probing.
+type capabilities struct {
+	Version   string              `json:"version"`
+	Dialects  []capabilityDialect `json:"dialects"`
+	Streaming capabilityStreaming `json:"streaming"`
+	Features  capabilityFeatures  `json:"features"`
+	Limits    capabilityLimits    `json:"limits"`
+	Models    []capabilityModel   `json:"models"`
+}
+
+// capabilityDialect is an API family the proxy accepts and the endpoints serving it.
+type capabilityDialect struct {
+	Name        string   `json:"name"`
+	Endpoints   []string `json:"endpoints"`
+	CountTokens bool     `json:"count_tokens"`
+	// route is the engine route whose registration means the dialect is served.
+	route string
+}
+
+type capabilityStreaming struct {
+	// SSE reports whether any dialect is served; every dialect streams server-sent events.
+	SSE bool `json:"sse"`
+	// WebSocketBridge lists the dialects served over /v1/ws-bridge/:dialect.
+	WebSocketBridge []string `json:"websocket_bridge"`
+	// Resumption reports whether interrupted streams can be resumed.
+	Resumption bool `json:"resumption"`
+	// NonStreamKeepAlive reports whether non-streaming responses are kept alive with
+	// blank lines.
+	NonStreamKeepAlive bool `json:"nonstream_keepalive"`
+	// GRPC reports whether the gRPC listener is enabled.
+	GRPC bool `json:"grpc"`
+}
+
+// capabilityFeatures reports the features whose routes are registered or which are
+// configured. Abilities of the upstream models, such as tool calling or vision, depend on
+// the model and are not reported.
+type capabilityFeatures struct {
+	// ThinkingSuffix reports whether any available model accepts a thinking level, the
+	// only models for which a model(level) suffix has an effect.
+	ThinkingSuffix  bool `json:"thinking_suffix"`
+	MessageBatches  bool `json:"message_batches"`
+	AsyncJobs       bool `json:"async_jobs"`
+	FanOut          bool `json:"fanout"`
+	Moderations     bool `json:"moderations"`
+	MCPServer       bool `json:"mcp_server"`
+	MCPTools        bool `json:"mcp_tools"`
+	PromptTemplates bool `json:"prompt_templates"`
+	SemanticCache   bool `json:"semantic_cache"`
+}
+
+type capabilityLimits struct {
+	// MaxRequestBodyBytes is the largest accepted request body; 0 is unlimited.
+	MaxRequestBodyBytes int64 `json:"max_request_body_bytes"`
+}
+
+// capabilityModel is an available model and what is known about its limits.
+type capabilityModel struct {
+	ID              string   `json:"id"`
+	OwnedBy         string   `json:"owned_by,omitempty"`
+	Type            string   `json:"type,omitempty"`
+	Providers       []string `json:"providers,omitempty"`
+	ContextLength   int      `json:"context_length,omitempty"`
+	MaxOutputTokens int      `json:"max_output_tokens,omitempty"`
+	Thinking        bool     `json:"thinking"`
+	ThinkingLevels  []string `json:"thinking_levels,omitempty"`
+}
+
+// capabilityDialects are the API families served by the routes of setupRoutes.
+var capabilityDialects = []capabilityDialect{
+	{Name: "openai-chat", Endpoints: []string{"POST /v1/chat/completions", "POST /v1/completions", "GET /v1/models"}, route: "POST /v1/chat/completions"},
+	{Name: "openai-responses", Endpoints: []string{"POST /v1/responses", "POST /v1/responses/compact"}, route: "POST /v1/responses"},
+	{Name: "claude", Endpoints: []string{"POST /v1/messages", "POST /v1/messages/count_tokens", "POST /v1/messages/batches"}, CountTokens: true, route: "POST /v1/messages"},
+	{Name: "gemini", Endpoints: []string{"POST /v1beta/models/{model}:generateContent", "POST /v1beta/models/{model}:streamGenerateContent", "POST /v1beta/models/{model}:countTokens", "GET /v1beta/models"}, CountTokens: true, route: "POST /v1beta/models/*action"},
+}
+
+// handleCapabilities describes the dialects, streaming modes, features, limits and models of
+// the proxy.
+func (s *Server) handleCapabilities(c *gin.Context) {
+	c.JSON(http.StatusOK, s.capabilities())
+}
+
+func (s *Server) capabilities() capabilities {
+	cfg := s.cfg
+	routes := make(map[string]bool)
+	for _, route := range s.engine.Routes() {
+		routes[route.Method+" "+route.Path] = true
+	}
+	dialects := make([]capabilityDialect, 0, len(capabilityDialects))
+	for _, dialect := range capabilityDialects {
+		if routes[dialect.route] {
+			dialects = append(dialects, dialect)
+		}
+	}
+	bridge := make([]string, 0, len(wsBridgeDialects))
+	if routes["GET /v1/ws-bridge/:dialect"] {
+		for dialect := range wsBridgeDialects {
+			bridge = append(bridge, dialect)
+		}
+		sort.Strings(bridge)
+	}
+	models := capabilityModels()
+	var thinking bool
+	for _, model := range models {
+		thinking = thinking || model.Thinking
+	}
+	return capabilities{
+		Version:  buildinfo.Version,
+		Dialects: dialects,
+		Streaming: capabilityStreaming{
+			SSE:                len(dialects) > 0,
+			WebSocketBridge:    bridge,
+			Resumption:         cfg.StreamResumption.Enable,
+			NonStreamKeepAlive: cfg.NonStreamKeepAliveInterval > 0,
+			GRPC:               cfg.GRPC.Enable,
+		},
+		Features: capabilityFeatures{
+			ThinkingSuffix:  thinking,
+			MessageBatches:  routes["POST /v1/messages/batches"],
+			AsyncJobs:       cfg.AsyncJobs.Enable,
+			FanOut:          routes["POST /v1/fanout"],
+			Moderations:     routes["POST /v1/moderations"],
+			MCPServer:       routes["POST /mcp"],
+			MCPTools:        len(cfg.MCPTools.Servers) > 0,
+			PromptTemplates: len(cfg.PromptTemplates) > 0,
+			SemanticCache:   cfg.SemanticCache.Enabled(),
+		},
+		Limits: capabilityLimits{MaxRequestBodyBytes: max(s.maxRequestBodyBytes.Load(), 0)},
+		Models: models,
+	}
+}
+
+// capabilityModels lists the available models, sorted by id.
+func capabilityModels() []capabilityModel {
+	reg := registry.GetGlobalRegistry()
+	available := reg.GetAvailableModels("openai")
+	models := make([]capabilityModel, 0, len(available))
+	for _, entry := range available {
+		id, _ := entry["id"].(string)
+		if id == "" {
+			continue
+		}
+		model := capabilityModel{ID: id, Providers: reg.GetModelProviders(id)}
+		if info := registry.LookupModelInfo(id); info != nil {
+			model.OwnedBy, model.Type = info.OwnedBy, info.Type
+			model.ContextLength = info.ContextLength
+			if info.InputTokenLimit > 0 {
+				model.ContextLength = info.InputTokenLimit
+			}
+			model.MaxOutputTokens = info.MaxCompletionTokens
+			if info.OutputTokenLimit > 0 {
+				model.MaxOutputTokens = info.OutputTokenLimit
+			}
+			if info.Thinking != nil {
+				model.Thinking, model.ThinkingLevels = true, info.Thinking.Levels
+			}
+		}
+		models = append(models, model)
+	}
+	sort.Slice(models, func(i, j int) bool { return models[i].ID < models[j].ID })
+	return models
+}
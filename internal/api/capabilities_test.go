@@ -0,0 +1,76 @@
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers"
+)
+
+func TestCapabilitiesEndpoint(t *testing.T) {
+	server := newTestServer(t)
+	server.maxRequestBodyBytes.Store(64)
+	server.cfg.StreamResumption.Enable = true
+	registry.GetGlobalRegistry().RegisterClient("capabilities-auth", "claude", []*registry.ModelInfo{
+		{ID: "caps-model", OwnedBy: "anthropic", Type: "claude", ContextLength: 200000, MaxCompletionTokens: 64000, Thinking: &registry.ThinkingSupport{Levels: []string{"low", "high"}}},
+	})
+	t.Cleanup(func() { registry.GetGlobalRegistry().UnregisterClient("capabilities-auth") })
+
+	rr := httptest.NewRecorder()
+	server.engine.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/v0/capabilities", nil))
+	var caps capabilities
+	if err := json.Unmarshal(rr.Body.Bytes(), &caps); err != nil || rr.Code != http.StatusOK {
+		t.Fatalf("status %d, body %s, err %v", rr.Code, rr.Body.String(), err)
+	}
+	if len(caps.Dialects) != 4 || !caps.Streaming.SSE || !caps.Streaming.Resumption || len(caps.Streaming.WebSocketBridge) != 4 || caps.Limits.MaxRequestBodyBytes != 64 ||
+		!caps.Features.ThinkingSuffix || !caps.Features.MessageBatches || !caps.Features.FanOut || !caps.Features.MCPServer {
+		t.Fatalf("capabilities = %+v", caps)
+	}
+	var found bool
+	for _, model := range caps.Models {
+		if model.ID == "caps-model" {
+			found = true
+			if model.ContextLength != 200000 || model.MaxOutputTokens != 64000 || !model.Thinking || len(model.Providers) != 1 || model.Providers[0] != "claude" {
+				t.Fatalf("model = %+v", model)
+			}
+		}
+	}
+	if !found {
+		t.Fatalf("caps-model missing from %+v", caps.Models)
+	}
+}
+
+func TestRequestBodyLimit(t *testing.T) {
+	server := newTestServer(t)
+	server.maxRequestBodyBytes.Store(16)
+
+	for _, tc := range []struct {
+		path    string
+		chunked bool
+		want    string
+	}{
+		{path: "/v1/messages", want: `"type":"error"`},
+		{path: "/v1/messages", chunked: true, want: `"type":"error"`},
+		{path: "/api/provider/openai/v1/chat/completions", want: `"error":{`},
+	} {
+		var body io.Reader = strings.NewReader(`{"model":"claude","messages":[]}`)
+		if tc.chunked {
+			body = io.MultiReader(body)
+		}
+		req := httptest.NewRequest(http.MethodPost, tc.path, body)
+		if tc.chunked {
+			req.ContentLength = -1
+		}
+		req.Header.Set("Authorization", "Bearer test-key")
+		rr := httptest.NewRecorder()
+		server.engine.ServeHTTP(rr, req)
+		if rr.Code != http.StatusRequestEntityTooLarge || rr.Header().Get(handlers.ErrorCodeHeader) != "request_too_large" || !strings.Contains(rr.Body.String(), tc.want) {
+			t.Fatalf("%s (chunked %v): status %d, headers %v, body %s", tc.path, tc.chunked, rr.Code, rr.Header(), rr.Body.String())
+		}
+	}
+}
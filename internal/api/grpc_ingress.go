@@ -0,0 +1,252 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/grpcingress"
+	log "github.com/sirupsen/logrus"
+	"github.com/tidwall/gjson"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// grpcStopTimeout bounds the wait for in-flight RPCs when the listener stops.
+const grpcStopTimeout = 5 * time.Second
+
+// grpcIngressSettings is the part of the config the listener depends on. An empty addr
+// means disabled.
+type grpcIngressSettings struct {
+	addr string
+	cert string
+	key  string
+}
+
+// grpcIngress runs the gRPC listener of cfg.GRPC. Like WebSocket bridge requests, its
+// RPCs are served by the handler of the HTTP listener, so they pass the same virtual host
+// selection, authentication, middleware and handlers as HTTP requests.
+type grpcIngress struct {
+	handler  http.Handler
+	mu       sync.Mutex
+	server   *grpc.Server
+	settings grpcIngressSettings
+}
+
+func newGRPCIngress(handler http.Handler) *grpcIngress {
+	return &grpcIngress{handler: handler}
+}
+
+// apply starts, restarts or stops the listener to match cfg.
+func (g *grpcIngress) apply(cfg *config.Config) {
+	var want grpcIngressSettings
+	if cfg != nil && cfg.GRPC.Enable {
+		want.addr = cfg.GRPC.Addr
+		if cfg.TLS.Enable {
+			want.cert, want.key = strings.TrimSpace(cfg.TLS.Cert), strings.TrimSpace(cfg.TLS.Key)
+		}
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.server != nil && g.settings == want {
+		return
+	}
+	g.stopLocked()
+	g.settings = want
+	if want.addr == "" {
+		return
+	}
+
+	var opts []grpc.ServerOption
+	if want.cert != "" || want.key != "" {
+		creds, err := credentials.NewServerTLSFromFile(want.cert, want.key)
+		if err != nil {
+			log.Errorf("grpc ingress: load tls credentials: %v", err)
+			return
+		}
+		opts = append(opts, grpc.Creds(creds))
+	}
+	listener, err := net.Listen("tcp", want.addr)
+	if err != nil {
+		log.Errorf("grpc ingress: listen on %s: %v", want.addr, err)
+		return
+	}
+	server := grpc.NewServer(opts...)
+	grpcingress.RegisterProxyServer(server, &grpcProxyService{handler: g.handler})
+	g.server = server
+	log.Infof("grpc ingress listening on %s", want.addr)
+	go func() {
+		if errServe := server.Serve(listener); errServe != nil && !errors.Is(errServe, grpc.ErrServerStopped) {
+			log.Errorf("grpc ingress failed on %s: %v", want.addr, errServe)
+		}
+	}()
+}
+
+// stop closes the listener, waiting briefly for in-flight RPCs.
+func (g *grpcIngress) stop() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.stopLocked()
+	g.settings = grpcIngressSettings{}
+}
+
+func (g *grpcIngress) stopLocked() {
+	server := g.server
+	if server == nil {
+		return
+	}
+	g.server = nil
+	done := make(chan struct{})
+	go func() {
+		server.GracefulStop()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(grpcStopTimeout):
+		server.Stop()
+	}
+	log.Infof("grpc ingress stopped on %s", g.settings.addr)
+}
+
+// grpcProxyService serves the RPCs of grpcingress.ProxyServer with an HTTP handler.
+type grpcProxyService struct {
+	handler http.Handler
+}
+
+// Complete serves the request on the dialect's endpoint without streaming.
+func (p *grpcProxyService) Complete(ctx context.Context, in *grpcingress.Request) (*grpcingress.Response, error) {
+	req, err := buildGRPCRequest(ctx, in, false)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	var body []byte
+	writer := newWSBridgeWriter(func(data []byte) error {
+		body = append(body, data...)
+		return nil
+	})
+	p.handler.ServeHTTP(writer, req)
+	if err = writer.finish(); err != nil {
+		return nil, err
+	}
+	if writer.status >= http.StatusBadRequest {
+		return nil, grpcStatusError(writer.status, body)
+	}
+	return &grpcingress.Response{Body: body}, nil
+}
+
+// Stream serves the request on the dialect's streaming endpoint, sending the data of
+// each SSE event as one chunk.
+func (p *grpcProxyService) Stream(in *grpcingress.Request, stream grpcingress.StreamServer) error {
+	req, err := buildGRPCRequest(stream.Context(), in, true)
+	if err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	var failure []byte
+	var writer *wsBridgeWriter
+	writer = newWSBridgeWriter(func(data []byte) error {
+		// Errors before the stream starts are JSON bodies; they become the RPC status.
+		if !writer.sse && writer.status >= http.StatusBadRequest {
+			failure = data
+			return nil
+		}
+		return stream.Send(&grpcingress.Chunk{Data: data})
+	})
+	p.handler.ServeHTTP(writer, req)
+	if err = writer.finish(); err != nil {
+		return err
+	}
+	if writer.status >= http.StatusBadRequest {
+		return grpcStatusError(writer.status, failure)
+	}
+	return nil
+}
+
+// buildGRPCRequest turns an RPC into a request for the dialect's endpoint, carrying the
+// call metadata as headers.
+func buildGRPCRequest(ctx context.Context, in *grpcingress.Request, stream bool) (*http.Request, error) {
+	dialect := strings.ToLower(strings.TrimSpace(in.Dialect))
+	if dialect == "" {
+		dialect = grpcingress.DialectOpenAI
+	}
+	// The listener address lets ip-access apply the rules of the gRPC listener.
+	if p, ok := peer.FromContext(ctx); ok && p.LocalAddr != nil {
+		ctx = context.WithValue(ctx, http.LocalAddrContextKey, p.LocalAddr)
+	}
+	req, err := newBridgedRequest(ctx, dialect, in.Body, stream, nil)
+	if err != nil {
+		return nil, err
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	for key, values := range md {
+		if key == ":authority" && len(values) > 0 {
+			req.Host = values[0]
+			continue
+		}
+		if strings.HasPrefix(key, ":") || strings.HasPrefix(key, "grpc-") || key == "content-type" || key == "te" {
+			continue
+		}
+		for _, value := range values {
+			req.Header.Add(key, value)
+		}
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if stream {
+		req.Header.Set("Accept", "text/event-stream")
+	}
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		req.RemoteAddr = p.Addr.String()
+	}
+	return req, nil
+}
+
+// grpcStatusError maps an HTTP error response to a status error carrying the error body.
+func grpcStatusError(httpStatus int, body []byte) error {
+	message := strings.TrimSpace(string(body))
+	if message == "" {
+		message = http.StatusText(httpStatus)
+	}
+	if detail := gjson.GetBytes(body, "error.message"); detail.Exists() && detail.String() != "" {
+		message = detail.String()
+	}
+	return status.Error(grpcCode(httpStatus), message)
+}
+
+func grpcCode(httpStatus int) codes.Code {
+	switch httpStatus {
+	case http.StatusBadRequest, http.StatusRequestEntityTooLarge, http.StatusUnprocessableEntity:
+		return codes.InvalidArgument
+	case http.StatusUnauthorized:
+		return codes.Unauthenticated
+	case http.StatusPaymentRequired, http.StatusForbidden:
+		return codes.PermissionDenied
+	case http.StatusNotFound:
+		return codes.NotFound
+	case http.StatusConflict:
+		return codes.Aborted
+	case http.StatusTooManyRequests:
+		return codes.ResourceExhausted
+	case 499:
+		return codes.Canceled
+	case http.StatusNotImplemented:
+		return codes.Unimplemented
+	case http.StatusBadGateway, http.StatusServiceUnavailable:
+		return codes.Unavailable
+	case http.StatusRequestTimeout, http.StatusGatewayTimeout:
+		return codes.DeadlineExceeded
+	}
+	if httpStatus >= http.StatusInternalServerError {
+		return codes.Internal
+	}
+	return codes.Unknown
+}
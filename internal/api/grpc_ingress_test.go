@@ -0,0 +1,109 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/grpcingress"
+	"github.com/tidwall/gjson"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func TestGRPCIngress_CompleteAndStream(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	engine.POST("/v1/chat/completions", func(c *gin.Context) {
+		if c.GetHeader("Authorization") != "Bearer test-key" {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": gin.H{"message": "invalid api key"}})
+			return
+		}
+		body, _ := c.GetRawData()
+		if !gjson.GetBytes(body, "stream").Bool() {
+			c.JSON(http.StatusOK, gin.H{"object": "chat.completion", "model": gjson.GetBytes(body, "model").String()})
+			return
+		}
+		c.Header("Content-Type", "text/event-stream")
+		_, _ = c.Writer.WriteString("data: {\"n\":1}\n\n: keep-alive\n\ndata: {\"n\":2}\n\ndata: [DONE]\n\n")
+	})
+	engine.POST("/v1beta/models/:action", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"action": c.Param("action")})
+	})
+
+	listener := bufconn.Listen(1 << 20)
+	server := grpc.NewServer()
+	grpcingress.RegisterProxyServer(server, &grpcProxyService{handler: engine})
+	go func() { _ = server.Serve(listener) }()
+	defer server.Stop()
+
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer func() { _ = conn.Close() }()
+	client := grpcingress.NewClient(conn)
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer test-key")
+
+	resp, err := client.Complete(ctx, &grpcingress.Request{Body: []byte(`{"model":"gpt-test","stream":true}`)})
+	if err != nil {
+		t.Fatalf("complete: %v", err)
+	}
+	if got := gjson.GetBytes(resp.Body, "model").String(); got != "gpt-test" {
+		t.Fatalf("complete body = %s", resp.Body)
+	}
+
+	resp, err = client.Complete(ctx, &grpcingress.Request{Dialect: "gemini", Body: []byte(`{"model":"gemini-test","contents":[]}`)})
+	if err != nil {
+		t.Fatalf("gemini complete: %v", err)
+	}
+	if got := gjson.GetBytes(resp.Body, "action").String(); got != "gemini-test:generateContent" {
+		t.Fatalf("gemini complete body = %s", resp.Body)
+	}
+
+	stream, err := client.Stream(ctx, &grpcingress.Request{Body: []byte(`{"model":"gpt-test"}`)})
+	if err != nil {
+		t.Fatalf("stream: %v", err)
+	}
+	var chunks []string
+	for {
+		chunk, errRecv := stream.Recv()
+		if errors.Is(errRecv, io.EOF) {
+			break
+		}
+		if errRecv != nil {
+			t.Fatalf("recv: %v", errRecv)
+		}
+		chunks = append(chunks, string(chunk.Data))
+	}
+	if len(chunks) != 2 || chunks[0] != `{"n":1}` || chunks[1] != `{"n":2}` {
+		t.Fatalf("chunks = %q", chunks)
+	}
+
+	_, err = client.Complete(context.Background(), &grpcingress.Request{Body: []byte(`{"model":"gpt-test"}`)})
+	if st := status.Convert(err); st.Code() != codes.Unauthenticated || st.Message() != "invalid api key" {
+		t.Fatalf("unauthenticated complete = %v", err)
+	}
+	stream, err = client.Stream(context.Background(), &grpcingress.Request{Body: []byte(`{"model":"gpt-test"}`)})
+	if err == nil {
+		_, err = stream.Recv()
+	}
+	if status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("unauthenticated stream = %v", err)
+	}
+
+	_, err = client.Complete(ctx, &grpcingress.Request{Dialect: "cohere", Body: []byte(`{}`)})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("unknown dialect = %v", err)
+	}
+}
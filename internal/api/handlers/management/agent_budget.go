@@ -0,0 +1,20 @@
+package management
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/agentbudget"
+)
+
+// GetAgentBudgetSessions returns the agent loops in progress and their budgets.
+func (h *Handler) GetAgentBudgetSessions(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"sessions": agentbudget.Default().Snapshot()})
+}
+
+// ResetAgentBudgetSessions ends the loops of ?session=, or every loop when omitted.
+func (h *Handler) ResetAgentBudgetSessions(c *gin.Context) {
+	agentbudget.Default().Reset(strings.TrimSpace(c.Query("session")))
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
@@ -0,0 +1,23 @@
+package management
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/metrics"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+)
+
+// GetAmpFairness reports the concurrency of every Amp route and, per requested model, its
+// share of the Amp traffic against its share of the local credentials able to serve it,
+// showing which Amp models dominate local quota. Counts start at launch or the last reset.
+func (h *Handler) GetAmpFairness(c *gin.Context) {
+	report := metrics.AmpFairness(registry.GetGlobalRegistry().GetModelCount)
+	c.JSON(http.StatusOK, gin.H{"routes": metrics.AmpRoutes(), "fairness": report})
+}
+
+// ResetAmpFairness clears the Amp route and model counts.
+func (h *Handler) ResetAmpFairness(c *gin.Context) {
+	metrics.ResetAmpRoutes()
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
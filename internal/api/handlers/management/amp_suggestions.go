@@ -0,0 +1,256 @@
+package management
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/thinking"
+)
+
+const (
+	defaultSuggestionDays = 7
+	maxSuggestionDays     = 90
+	suggestionAlternates  = 3
+)
+
+// ampTraffic is the AMP_CREDITS traffic of one requested model.
+type ampTraffic struct {
+	Model         string
+	Requests      int64
+	EstimatedCost float64
+}
+
+// mappingCandidate is a model served by a local provider.
+type mappingCandidate struct {
+	ID       string
+	Provider string
+	Info     *registry.ModelInfo
+}
+
+// ampMappingSuggestion proposes a model-mappings entry for a model that used Amp credits.
+type ampMappingSuggestion struct {
+	From          string   `json:"from"`
+	To            string   `json:"to"`
+	Provider      string   `json:"provider"`
+	Requests      int64    `json:"requests"`
+	EstimatedCost float64  `json:"estimated_cost"`
+	Reason        string   `json:"reason"`
+	Alternatives  []string `json:"alternatives,omitempty"`
+}
+
+// GetAmpModelMappingSuggestions analyzes the AMP_CREDITS traffic of the last ?days= days
+// (default 7) and suggests ampcode.model-mappings entries pointing each requested model at
+// the most capable locally available model, preferring the same model family. With
+// ?format=yaml the suggestions are returned as a ready-to-paste config snippet.
+func (h *Handler) GetAmpModelMappingSuggestions(c *gin.Context) {
+	days := defaultSuggestionDays
+	if raw := strings.TrimSpace(c.Query("days")); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed <= 0 || parsed > maxSuggestionDays {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("days must be between 1 and %d", maxSuggestionDays)})
+			return
+		}
+		days = parsed
+	}
+
+	traffic := h.recentAmpTraffic(days)
+	// Models that already have a mapping only reach Amp when its target is unavailable.
+	mapped := make(map[string]struct{})
+	if h.cfg != nil {
+		for _, mapping := range h.cfg.AmpCode.ModelMappings {
+			mapped[strings.ToLower(strings.TrimSpace(mapping.From))] = struct{}{}
+		}
+	}
+	unmapped := traffic[:0:0]
+	for _, entry := range traffic {
+		if _, ok := mapped[strings.ToLower(entry.Model)]; !ok {
+			unmapped = append(unmapped, entry)
+		}
+	}
+
+	suggestions, unmatched := suggestAmpModelMappings(unmapped, localMappingCandidates())
+	snippet := ampMappingSuggestionsYAML(suggestions, days)
+	if strings.EqualFold(c.Query("format"), "yaml") {
+		c.Data(http.StatusOK, "application/yaml; charset=utf-8", []byte(snippet))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"days":        days,
+		"suggestions": suggestions,
+		"unmatched":   unmatched,
+		"yaml":        snippet,
+	})
+}
+
+// recentAmpTraffic sums the AMP_CREDITS statistics of the last days days per model, most
+// expensive first.
+func (h *Handler) recentAmpTraffic(days int) []ampTraffic {
+	if h == nil || h.usageStats == nil {
+		return nil
+	}
+	cutoff := time.Now().AddDate(0, 0, -(days - 1)).Format("2006-01-02")
+	byModel := make(map[string]*ampTraffic)
+	for day, models := range h.usageStats.Snapshot().AmpCreditsByDay {
+		if day < cutoff {
+			continue
+		}
+		for model, stats := range models {
+			model = thinking.ParseSuffix(model).ModelName
+			if model == "" || model == "unknown" {
+				continue
+			}
+			entry, ok := byModel[model]
+			if !ok {
+				entry = &ampTraffic{Model: model}
+				byModel[model] = entry
+			}
+			entry.Requests += stats.Requests
+			entry.EstimatedCost += stats.EstimatedCost
+		}
+	}
+	out := make([]ampTraffic, 0, len(byModel))
+	for _, entry := range byModel {
+		out = append(out, *entry)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].EstimatedCost != out[j].EstimatedCost {
+			return out[i].EstimatedCost > out[j].EstimatedCost
+		}
+		if out[i].Requests != out[j].Requests {
+			return out[i].Requests > out[j].Requests
+		}
+		return out[i].Model < out[j].Model
+	})
+	return out
+}
+
+// localMappingCandidates lists the models local providers can currently serve.
+func localMappingCandidates() []mappingCandidate {
+	reg := registry.GetGlobalRegistry()
+	var out []mappingCandidate
+	for _, model := range reg.GetAvailableModels("openai") {
+		id, _ := model["id"].(string)
+		if id == "" {
+			continue
+		}
+		providers := reg.GetModelProviders(id)
+		if len(providers) == 0 {
+			continue
+		}
+		out = append(out, mappingCandidate{ID: id, Provider: providers[0], Info: registry.LookupModelInfo(id, providers[0])})
+	}
+	return out
+}
+
+// suggestAmpModelMappings picks a local model for every traffic entry. Candidates of the
+// requested model's family win; within a family the higher tier, larger context window and
+// thinking support win. Models without any candidate are returned as unmatched.
+func suggestAmpModelMappings(traffic []ampTraffic, candidates []mappingCandidate) ([]ampMappingSuggestion, []string) {
+	suggestions := make([]ampMappingSuggestion, 0, len(traffic))
+	var unmatched []string
+	for _, entry := range traffic {
+		family := modelFamily(entry.Model)
+		ranked := make([]mappingCandidate, 0, len(candidates))
+		for _, candidate := range candidates {
+			if !strings.EqualFold(candidate.ID, entry.Model) {
+				ranked = append(ranked, candidate)
+			}
+		}
+		if len(ranked) == 0 {
+			unmatched = append(unmatched, entry.Model)
+			continue
+		}
+		sort.SliceStable(ranked, func(i, j int) bool {
+			sameI, sameJ := modelFamily(ranked[i].ID) == family, modelFamily(ranked[j].ID) == family
+			if sameI != sameJ {
+				return sameI
+			}
+			scoreI, scoreJ := capabilityScore(ranked[i]), capabilityScore(ranked[j])
+			if scoreI != scoreJ {
+				return scoreI > scoreJ
+			}
+			// Newer releases usually sort later (dates, version numbers)
+			return ranked[i].ID > ranked[j].ID
+		})
+		best := ranked[0]
+		reason := fmt.Sprintf("most capable local %s model", family)
+		if modelFamily(best.ID) != family {
+			reason = fmt.Sprintf("no local %s model; most capable local model", family)
+		}
+		suggestion := ampMappingSuggestion{
+			From:          entry.Model,
+			To:            best.ID,
+			Provider:      best.Provider,
+			Requests:      entry.Requests,
+			EstimatedCost: entry.EstimatedCost,
+			Reason:        reason,
+		}
+		for _, alternative := range ranked[1:min(len(ranked), suggestionAlternates+1)] {
+			suggestion.Alternatives = append(suggestion.Alternatives, alternative.ID)
+		}
+		suggestions = append(suggestions, suggestion)
+	}
+	return suggestions, unmatched
+}
+
+// modelFamily groups model ids by vendor line so mappings keep a comparable model.
+func modelFamily(id string) string {
+	id = strings.ToLower(id)
+	switch {
+	case strings.Contains(id, "claude"):
+		return "claude"
+	case strings.Contains(id, "gemini"):
+		return "gemini"
+	case strings.HasPrefix(id, "gpt") || strings.Contains(id, "codex") || strings.HasPrefix(id, "o1") ||
+		strings.HasPrefix(id, "o3") || strings.HasPrefix(id, "o4"):
+		return "openai"
+	}
+	if idx := strings.IndexAny(id, "-_/."); idx > 0 {
+		return id[:idx]
+	}
+	return id
+}
+
+// capabilityScore ranks a candidate by model tier, context window and thinking support.
+func capabilityScore(candidate mappingCandidate) int {
+	tier := 2
+	for _, part := range strings.FieldsFunc(strings.ToLower(candidate.ID), func(r rune) bool { return r == '-' || r == '_' || r == '.' }) {
+		switch part {
+		case "opus", "pro", "max", "ultra":
+			tier = max(tier, 3)
+		case "haiku", "flash", "mini", "nano", "lite":
+			tier = 1
+		}
+	}
+	score := tier * 1_000_000
+	if info := candidate.Info; info != nil {
+		window := max(info.ContextLength, info.InputTokenLimit)
+		score += min(window/1000, 10_000) * 10
+		if info.Thinking != nil {
+			score += 5_000
+		}
+	}
+	return score
+}
+
+// ampMappingSuggestionsYAML renders suggestions as an ampcode.model-mappings snippet.
+func ampMappingSuggestionsYAML(suggestions []ampMappingSuggestion, days int) string {
+	var b strings.Builder
+	if len(suggestions) == 0 {
+		fmt.Fprintf(&b, "# No unmapped AMP_CREDITS traffic in the last %d days.\n", days)
+		return b.String()
+	}
+	fmt.Fprintf(&b, "# Suggested from AMP_CREDITS traffic of the last %d days.\n", days)
+	b.WriteString("ampcode:\n  model-mappings:\n")
+	for _, suggestion := range suggestions {
+		fmt.Fprintf(&b, "    - from: %s # %d requests, ~%.2f credits\n", strconv.Quote(suggestion.From), suggestion.Requests, suggestion.EstimatedCost)
+		fmt.Fprintf(&b, "      to: %s # %s via %s\n", strconv.Quote(suggestion.To), suggestion.Reason, suggestion.Provider)
+	}
+	return b.String()
+}
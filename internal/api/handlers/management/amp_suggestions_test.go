@@ -0,0 +1,51 @@
+package management
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+)
+
+func TestSuggestAmpModelMappings_PrefersFamilyAndCapability(t *testing.T) {
+	candidates := []mappingCandidate{
+		{ID: "gemini-2.5-flash", Provider: "gemini-cli"},
+		{ID: "gemini-2.5-pro", Provider: "gemini-cli", Info: &registry.ModelInfo{InputTokenLimit: 1_048_576}},
+		{ID: "claude-haiku-4-5-20251001", Provider: "claude"},
+		{ID: "claude-sonnet-4-5-20250929", Provider: "claude", Info: &registry.ModelInfo{ContextLength: 200_000, Thinking: &registry.ThinkingSupport{}}},
+	}
+	traffic := []ampTraffic{
+		{Model: "claude-opus-4-5", Requests: 12, EstimatedCost: 3.5},
+		{Model: "gpt-5", Requests: 2, EstimatedCost: 0.1},
+	}
+
+	suggestions, unmatched := suggestAmpModelMappings(traffic, candidates)
+	if len(unmatched) != 0 || len(suggestions) != 2 {
+		t.Fatalf("suggestions = %+v, unmatched = %v", suggestions, unmatched)
+	}
+	if got := suggestions[0]; got.To != "claude-sonnet-4-5-20250929" || got.Provider != "claude" {
+		t.Fatalf("claude suggestion = %+v", got)
+	}
+	if want := []string{"claude-haiku-4-5-20251001", "gemini-2.5-pro", "gemini-2.5-flash"}; !reflect.DeepEqual(suggestions[0].Alternatives, want) {
+		t.Fatalf("alternatives = %v, want %v", suggestions[0].Alternatives, want)
+	}
+	// No local OpenAI model: the most capable model of any family is suggested.
+	if got := suggestions[1]; got.To != "gemini-2.5-pro" || !strings.HasPrefix(got.Reason, "no local openai model") {
+		t.Fatalf("gpt suggestion = %+v", got)
+	}
+
+	snippet := ampMappingSuggestionsYAML(suggestions, 7)
+	for _, want := range []string{"ampcode:\n  model-mappings:\n", `- from: "claude-opus-4-5" # 12 requests, ~3.50 credits`, `to: "claude-sonnet-4-5-20250929"`} {
+		if !strings.Contains(snippet, want) {
+			t.Fatalf("yaml snippet missing %q:\n%s", want, snippet)
+		}
+	}
+}
+
+func TestSuggestAmpModelMappings_NoCandidates(t *testing.T) {
+	suggestions, unmatched := suggestAmpModelMappings([]ampTraffic{{Model: "claude-opus-4-5"}}, nil)
+	if len(suggestions) != 0 || !reflect.DeepEqual(unmatched, []string{"claude-opus-4-5"}) {
+		t.Fatalf("suggestions = %+v, unmatched = %v", suggestions, unmatched)
+	}
+}
@@ -0,0 +1,144 @@
+package management
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/audit"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	auditActorContextKey = "management_audit_actor"
+	auditLogFileName     = "management-audit.jsonl"
+)
+
+// setAuditActor records the management key the request authenticated with.
+func setAuditActor(c *gin.Context, kind, key string) {
+	sum := sha256.Sum256([]byte(key))
+	c.Set(auditActorContextKey, audit.Actor{
+		Kind:        kind,
+		Fingerprint: hex.EncodeToString(sum[:6]),
+		Label:       strings.TrimSpace(c.GetHeader("X-Management-Actor")),
+	})
+}
+
+// auditLog returns the configured audit log, or nil when auditing is disabled.
+func (h *Handler) auditLog() *audit.Log {
+	if h == nil || h.cfg == nil || h.cfg.RemoteManagement.DisableAuditLog {
+		return nil
+	}
+	path := strings.TrimSpace(h.cfg.RemoteManagement.AuditLog)
+	if path == "" {
+		dir := h.logDirectory()
+		if dir == "" {
+			return nil
+		}
+		path = filepath.Join(dir, auditLogFileName)
+	}
+	h.auditMu.Lock()
+	defer h.auditMu.Unlock()
+	if h.audit == nil || h.audit.Path() != path {
+		h.audit = audit.NewLog(path)
+	}
+	return h.audit
+}
+
+// auditSnapshot flattens the config and the state of the auth files, so mutations of
+// either show up in the diff.
+func (h *Handler) auditSnapshot() map[string]string {
+	snapshot := audit.Snapshot(h.cfg)
+	if h.authManager != nil {
+		for _, auth := range h.authManager.List() {
+			state := "active"
+			if auth.Disabled {
+				state = "disabled"
+			}
+			snapshot["auth-files."+auth.ID] = state
+		}
+	}
+	return snapshot
+}
+
+// AuditMiddleware appends every mutating management request (any method but GET, HEAD
+// and OPTIONS) to the audit log with its actor, response status and the resulting diff of
+// the config and auth files.
+func (h *Handler) AuditMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			c.Next()
+			return
+		}
+		auditLog := h.auditLog()
+		if auditLog == nil {
+			c.Next()
+			return
+		}
+		before := h.auditSnapshot()
+		c.Next()
+
+		entry := audit.Entry{
+			Time:     time.Now().UTC(),
+			ClientIP: c.ClientIP(),
+			Method:   c.Request.Method,
+			Path:     c.Request.URL.Path,
+			Query:    util.MaskSensitiveQuery(c.Request.URL.RawQuery),
+			Status:   c.Writer.Status(),
+			Changes:  audit.Diff(before, h.auditSnapshot()),
+		}
+		if actor, ok := c.Get(auditActorContextKey); ok {
+			entry.Actor, _ = actor.(audit.Actor)
+		}
+		if err := auditLog.Append(entry); err != nil {
+			log.Warnf("management audit: failed to record %s %s: %v", entry.Method, entry.Path, err)
+		}
+	}
+}
+
+// GetAuditLog returns the recorded management mutations, newest first. ?q= searches the
+// paths, actor labels and fingerprints, ?method= and ?since= (RFC 3339) filter the entries
+// and ?offset=/?limit= select a page.
+func (h *Handler) GetAuditLog(c *gin.Context) {
+	q, ok := parseListQuery(c)
+	if !ok {
+		return
+	}
+	var since time.Time
+	if raw := strings.TrimSpace(c.Query("since")); raw != "" {
+		parsed, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid since"})
+			return
+		}
+		since = parsed
+	}
+	method := strings.TrimSpace(c.Query("method"))
+
+	auditLog := h.auditLog()
+	entries, err := auditLog.Entries()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to read audit log"})
+		return
+	}
+	for i, j := 0, len(entries)-1; i < j; i, j = i+1, j-1 {
+		entries[i], entries[j] = entries[j], entries[i]
+	}
+	entries = filterItems(entries, func(entry audit.Entry) bool {
+		if method != "" && !strings.EqualFold(entry.Method, method) {
+			return false
+		}
+		if !since.IsZero() && entry.Time.Before(since) {
+			return false
+		}
+		return q.matches(entry.Path, entry.Actor.Label, entry.Actor.Fingerprint)
+	})
+	page, meta := paginate(entries, q)
+	c.JSON(http.StatusOK, gin.H{"enabled": auditLog != nil, "entries": page, "pagination": meta})
+}
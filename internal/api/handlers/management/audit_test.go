@@ -0,0 +1,56 @@
+package management
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/audit"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+func TestAuditMiddlewareRecordsMutations(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	dir := t.TempDir()
+	h := &Handler{cfg: &config.Config{}, logDir: dir}
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		setAuditActor(c, "secret-key", "management-key")
+		c.Next()
+	}, h.AuditMiddleware())
+	r.PUT("/debug", func(c *gin.Context) {
+		h.cfg.Debug = true
+		c.JSON(http.StatusOK, gin.H{"status": "ok"})
+	})
+	r.GET("/audit-log", h.GetAuditLog)
+
+	req := httptest.NewRequest(http.MethodPut, "/debug", bytes.NewReader([]byte(`{"value":true}`)))
+	req.Header.Set("X-Management-Actor", "deploy-bot")
+	r.ServeHTTP(httptest.NewRecorder(), req)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/audit-log?q=deploy", nil))
+	var resp struct {
+		Entries []audit.Entry `json:"entries"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(resp.Entries) != 1 {
+		t.Fatalf("entries = %+v, want only the PUT", resp.Entries)
+	}
+	entry := resp.Entries[0]
+	if entry.Actor.Kind != "secret-key" || entry.Actor.Label != "deploy-bot" || entry.Actor.Fingerprint == "" || entry.Status != http.StatusOK {
+		t.Fatalf("entry = %+v", entry)
+	}
+	if len(entry.Changes) != 1 || entry.Changes[0] != (audit.Change{Path: "debug", Before: "false", After: "true"}) {
+		t.Fatalf("changes = %+v, want the debug toggle", entry.Changes)
+	}
+	if _, err := audit.NewLog(filepath.Join(dir, auditLogFileName)).Entries(); err != nil {
+		t.Fatalf("audit log not readable: %v", err)
+	}
+}
@@ -267,7 +267,7 @@ func (h *Handler) ListAuthFiles(c *gin.Context) {
 		nameJ, _ := files[j]["name"].(string)
 		return strings.ToLower(nameI) < strings.ToLower(nameJ)
 	})
-	c.JSON(200, gin.H{"files": files})
+	writeAuthFileList(c, files)
 }
 
 // GetAuthFileModels returns the models supported by a specific auth file
@@ -349,7 +349,40 @@ func (h *Handler) listAuthFilesFromDisk(c *gin.Context) {
 			files = append(files, fileData)
 		}
 	}
-	c.JSON(200, gin.H{"files": files})
+	writeAuthFileList(c, files)
+}
+
+// writeAuthFileList answers with the page of files selected by the list parameters.
+// ?q= searches the name, email, label and id; ?provider= (or ?type=), ?status= and
+// ?disabled= filter on the matching fields.
+func writeAuthFileList(c *gin.Context, files []gin.H) {
+	q, ok := parseListQuery(c)
+	if !ok {
+		return
+	}
+	provider := strings.TrimSpace(c.Query("provider"))
+	if provider == "" {
+		provider = strings.TrimSpace(c.Query("type"))
+	}
+	status := strings.TrimSpace(c.Query("status"))
+	disabled := strings.TrimSpace(c.Query("disabled"))
+	field := func(file gin.H, key string) string {
+		return fmt.Sprint(file[key])
+	}
+	files = filterItems(files, func(file gin.H) bool {
+		if provider != "" && !strings.EqualFold(field(file, "type"), provider) {
+			return false
+		}
+		if status != "" && !strings.EqualFold(field(file, "status"), status) {
+			return false
+		}
+		if disabled != "" && !strings.EqualFold(field(file, "disabled"), disabled) {
+			return false
+		}
+		return q.matches(field(file, "name"), field(file, "email"), field(file, "label"), field(file, "id"))
+	})
+	page, meta := paginate(files, q)
+	c.JSON(200, gin.H{"files": page, "pagination": meta})
 }
 
 func (h *Handler) buildAuthFileEntry(auth *coreauth.Auth) gin.H {
@@ -592,13 +625,20 @@ func (h *Handler) UploadAuthFile(c *gin.Context) {
 	c.JSON(200, gin.H{"status": "ok"})
 }
 
-// Delete auth files: single by name or all
+// Delete auth files: single by name or all. Unless permanent=true or soft-delete is off, the
+// files are moved to the trash and can be restored within the retention window.
 func (h *Handler) DeleteAuthFile(c *gin.Context) {
 	if h.authManager == nil {
 		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "core auth manager unavailable"})
 		return
 	}
 	ctx := c.Request.Context()
+	permanent := c.Query("permanent") == "true" || c.Query("permanent") == "1"
+	restorable := !permanent && h.credentialRetention() > 0
+	if restorable {
+		// Purge the trashed files past their retention window.
+		_, _ = h.trashedAuthFiles()
+	}
 	if all := c.Query("all"); all == "true" || all == "1" || all == "*" {
 		entries, err := os.ReadDir(h.cfg.AuthDir)
 		if err != nil {
@@ -620,7 +660,7 @@ func (h *Handler) DeleteAuthFile(c *gin.Context) {
 					full = abs
 				}
 			}
-			if err = os.Remove(full); err == nil {
+			if err = h.removeAuthFile(full, permanent); err == nil {
 				if errDel := h.deleteTokenRecord(ctx, full); errDel != nil {
 					c.JSON(500, gin.H{"error": errDel.Error()})
 					return
@@ -629,7 +669,7 @@ func (h *Handler) DeleteAuthFile(c *gin.Context) {
 				h.disableAuth(ctx, full)
 			}
 		}
-		c.JSON(200, gin.H{"status": "ok", "deleted": deleted})
+		c.JSON(200, gin.H{"status": "ok", "deleted": deleted, "restorable": restorable})
 		return
 	}
 	name := c.Query("name")
@@ -643,7 +683,7 @@ func (h *Handler) DeleteAuthFile(c *gin.Context) {
 			full = abs
 		}
 	}
-	if err := os.Remove(full); err != nil {
+	if err := h.removeAuthFile(full, permanent); err != nil {
 		if os.IsNotExist(err) {
 			c.JSON(404, gin.H{"error": "file not found"})
 		} else {
@@ -656,7 +696,7 @@ func (h *Handler) DeleteAuthFile(c *gin.Context) {
 		return
 	}
 	h.disableAuth(ctx, full)
-	c.JSON(200, gin.H{"status": "ok"})
+	c.JSON(200, gin.H{"status": "ok", "restorable": restorable})
 }
 
 func (h *Handler) authIDForPath(path string) string {
@@ -0,0 +1,35 @@
+package management
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/authguard"
+)
+
+// GetAuthBans lists the client IPs tracked by the API key brute-force protection, banned
+// ones first. ?banned=true limits the list to active bans.
+func (h *Handler) GetAuthBans(c *gin.Context) {
+	q, ok := parseListQuery(c)
+	if !ok {
+		return
+	}
+	bannedOnly := queryBool(c, "banned", false)
+	entries := filterItems(authguard.Default().Snapshot(), func(status authguard.Status) bool {
+		if bannedOnly && status.BannedUntil == nil {
+			return false
+		}
+		return q.matches(status.IP)
+	})
+	page, meta := paginate(entries, q)
+	enabled := h.cfg != nil && h.cfg.AuthGuard.MaxFailures > 0
+	c.JSON(http.StatusOK, gin.H{"enabled": enabled, "entries": page, "pagination": meta})
+}
+
+// DeleteAuthBans lifts the ban and failure count of ?ip=, or of every IP when omitted.
+func (h *Handler) DeleteAuthBans(c *gin.Context) {
+	ip := strings.TrimSpace(c.Query("ip"))
+	authguard.Default().Clear(ip)
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
@@ -0,0 +1,198 @@
+package management
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// authTrashDir is the folder of the auth directory deleted credential files are kept in.
+	// The watcher and token stores only load *.json files, so the suffix keeps them inert.
+	authTrashDir    = ".trash"
+	authTrashSuffix = ".deleted"
+)
+
+// trashedAuthFile is a soft-deleted credential file.
+type trashedAuthFile struct {
+	Name      string    `json:"name"`
+	Size      int64     `json:"size"`
+	DeletedAt time.Time `json:"deleted_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// credentialRetention is how long deleted credential files are kept; 0 deletes them at once.
+// Soft-delete is opt-in so deleted refresh tokens do not linger on disk by default. With it
+// off, files trashed earlier are purged the next time the trash is read.
+func (h *Handler) credentialRetention() time.Duration {
+	hours := h.cfg.RemoteManagement.CredentialRetentionHours
+	if hours <= 0 {
+		return 0
+	}
+	return time.Duration(hours) * time.Hour
+}
+
+func (h *Handler) authTrashPath(name string) string {
+	return filepath.Join(h.cfg.AuthDir, authTrashDir, filepath.Base(name)+authTrashSuffix)
+}
+
+// removeAuthFile deletes the credential file at full, moving it to the trash unless
+// permanent is set or soft-delete is off. A file deleted again replaces the trashed copy.
+func (h *Handler) removeAuthFile(full string, permanent bool) error {
+	if permanent || h.credentialRetention() <= 0 {
+		return os.Remove(full)
+	}
+	if _, err := os.Stat(full); err != nil {
+		return err
+	}
+	dst := h.authTrashPath(full)
+	if err := os.MkdirAll(filepath.Dir(dst), 0o700); err != nil {
+		return fmt.Errorf("create trash dir: %w", err)
+	}
+	if err := os.Rename(full, dst); err != nil {
+		return err
+	}
+	now := time.Now()
+	// The modification time records the deletion for the retention window.
+	_ = os.Chtimes(dst, now, now)
+	return nil
+}
+
+// trashedAuthFiles purges the files past the retention window and returns the others,
+// newest first.
+func (h *Handler) trashedAuthFiles() ([]trashedAuthFile, error) {
+	dir := filepath.Join(h.cfg.AuthDir, authTrashDir)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	retention := h.credentialRetention()
+	now := time.Now()
+	files := make([]trashedAuthFile, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), authTrashSuffix) {
+			continue
+		}
+		info, errInfo := entry.Info()
+		if errInfo != nil {
+			continue
+		}
+		file := trashedAuthFile{
+			Name:      strings.TrimSuffix(entry.Name(), authTrashSuffix),
+			Size:      info.Size(),
+			DeletedAt: info.ModTime(),
+			ExpiresAt: info.ModTime().Add(retention),
+		}
+		if !now.Before(file.ExpiresAt) {
+			if errRemove := os.Remove(filepath.Join(dir, entry.Name())); errRemove != nil {
+				log.Warnf("management: failed to purge trashed credential %s: %v", file.Name, errRemove)
+			}
+			continue
+		}
+		files = append(files, file)
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].DeletedAt.After(files[j].DeletedAt) })
+	return files, nil
+}
+
+// ListTrashedAuthFiles lists the soft-deleted credential files still restorable.
+func (h *Handler) ListTrashedAuthFiles(c *gin.Context) {
+	files, err := h.trashedAuthFiles()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to read trash: %v", err)})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"files": files, "retention_hours": int(h.credentialRetention().Hours())})
+}
+
+// RestoreAuthFile moves a soft-deleted credential file back into the auth directory and
+// registers it again.
+func (h *Handler) RestoreAuthFile(c *gin.Context) {
+	if h.authManager == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "core auth manager unavailable"})
+		return
+	}
+	name := c.Query("name")
+	if name == "" || strings.Contains(name, string(os.PathSeparator)) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid name"})
+		return
+	}
+	if _, err := h.trashedAuthFiles(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to read trash: %v", err)})
+		return
+	}
+	src := h.authTrashPath(name)
+	data, err := os.ReadFile(src)
+	if err != nil {
+		if os.IsNotExist(err) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "file not in trash"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to read trashed file: %v", err)})
+		}
+		return
+	}
+	dst := filepath.Join(h.cfg.AuthDir, filepath.Base(name))
+	if !filepath.IsAbs(dst) {
+		if abs, errAbs := filepath.Abs(dst); errAbs == nil {
+			dst = abs
+		}
+	}
+	if _, err = os.Stat(dst); err == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "a credential file with this name exists"})
+		return
+	}
+	if err = os.Rename(src, dst); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to restore file: %v", err)})
+		return
+	}
+	if err = h.registerAuthFromFile(c.Request.Context(), dst, data); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// PurgeTrashedAuthFiles permanently deletes one soft-deleted credential file, or all with
+// all=true.
+func (h *Handler) PurgeTrashedAuthFiles(c *gin.Context) {
+	if all := c.Query("all"); all == "true" || all == "1" || all == "*" {
+		files, err := h.trashedAuthFiles()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to read trash: %v", err)})
+			return
+		}
+		purged := 0
+		for _, file := range files {
+			if os.Remove(h.authTrashPath(file.Name)) == nil {
+				purged++
+			}
+		}
+		c.JSON(http.StatusOK, gin.H{"status": "ok", "purged": purged})
+		return
+	}
+	name := c.Query("name")
+	if name == "" || strings.Contains(name, string(os.PathSeparator)) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid name"})
+		return
+	}
+	if err := os.Remove(h.authTrashPath(name)); err != nil {
+		if os.IsNotExist(err) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "file not in trash"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to purge file: %v", err)})
+		}
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
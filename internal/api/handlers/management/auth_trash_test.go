@@ -0,0 +1,108 @@
+package management
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	sdkAuth "github.com/router-for-me/CLIProxyAPI/v6/sdk/auth"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+)
+
+func TestDeletedAuthFileCanBeRestored(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	authDir := t.TempDir()
+	path := filepath.Join(authDir, "claude-user.json")
+	if err := os.WriteFile(path, []byte(`{"type":"claude","email":"user@example.com"}`), 0o600); err != nil {
+		t.Fatalf("write auth file: %v", err)
+	}
+	h := &Handler{cfg: &config.Config{AuthDir: authDir, RemoteManagement: config.RemoteManagement{CredentialRetentionHours: 1}}, authManager: coreauth.NewManager(nil, nil, nil), tokenStore: sdkAuth.NewFileTokenStore()}
+	r := gin.New()
+	r.DELETE("/auth-files", h.DeleteAuthFile)
+	r.GET("/auth-files/trash", h.ListTrashedAuthFiles)
+	r.DELETE("/auth-files/trash", h.PurgeTrashedAuthFiles)
+	r.POST("/auth-files/restore", h.RestoreAuthFile)
+	do := func(method, target string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(method, target, nil))
+		return w
+	}
+
+	if w := do(http.MethodDelete, "/auth-files?name=claude-user.json"); w.Code != http.StatusOK {
+		t.Fatalf("delete: status %d, body %s", w.Code, w.Body.String())
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("auth file still present after delete: %v", err)
+	}
+
+	var listed struct {
+		Files []trashedAuthFile `json:"files"`
+	}
+	w := do(http.MethodGet, "/auth-files/trash")
+	if err := json.Unmarshal(w.Body.Bytes(), &listed); err != nil || len(listed.Files) != 1 || listed.Files[0].Name != "claude-user.json" {
+		t.Fatalf("trash = %s (%v)", w.Body.String(), err)
+	}
+
+	if w = do(http.MethodPost, "/auth-files/restore?name=claude-user.json"); w.Code != http.StatusOK {
+		t.Fatalf("restore: status %d, body %s", w.Code, w.Body.String())
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Fatalf("auth file not restored: %v", err)
+	}
+	if auth, ok := h.authManager.GetByID("claude-user.json"); !ok || auth.Disabled {
+		t.Fatalf("restored auth = %+v, registered %v", auth, ok)
+	}
+	if w = do(http.MethodPost, "/auth-files/restore?name=claude-user.json"); w.Code != http.StatusNotFound {
+		t.Fatalf("second restore: status %d, want 404", w.Code)
+	}
+
+	// A permanent delete bypasses the trash.
+	do(http.MethodDelete, "/auth-files?name=claude-user.json&permanent=true")
+	if w = do(http.MethodGet, "/auth-files/trash"); json.Unmarshal(w.Body.Bytes(), &listed) != nil || len(listed.Files) != 0 {
+		t.Fatalf("trash after permanent delete = %s", w.Body.String())
+	}
+}
+
+func TestAuthFilesAreDeletedPermanentlyByDefault(t *testing.T) {
+	authDir := t.TempDir()
+	h := &Handler{cfg: &config.Config{AuthDir: authDir}}
+	path := filepath.Join(authDir, "user.json")
+	if err := os.WriteFile(path, []byte(`{}`), 0o600); err != nil {
+		t.Fatalf("write auth file: %v", err)
+	}
+	if err := h.removeAuthFile(path, false); err != nil {
+		t.Fatalf("removeAuthFile: %v", err)
+	}
+	if _, err := os.Stat(h.authTrashPath("user.json")); !os.IsNotExist(err) {
+		t.Fatalf("file trashed without credential-retention-hours: %v", err)
+	}
+}
+
+func TestTrashedAuthFilesExpire(t *testing.T) {
+	authDir := t.TempDir()
+	h := &Handler{cfg: &config.Config{AuthDir: authDir, RemoteManagement: config.RemoteManagement{CredentialRetentionHours: 1}}}
+	path := filepath.Join(authDir, "old.json")
+	if err := os.WriteFile(path, []byte(`{}`), 0o600); err != nil {
+		t.Fatalf("write auth file: %v", err)
+	}
+	if err := h.removeAuthFile(path, false); err != nil {
+		t.Fatalf("removeAuthFile: %v", err)
+	}
+	trashed := h.authTrashPath("old.json")
+	past := time.Now().Add(-2 * time.Hour)
+	if err := os.Chtimes(trashed, past, past); err != nil {
+		t.Fatalf("chtimes: %v", err)
+	}
+	if files, err := h.trashedAuthFiles(); err != nil || len(files) != 0 {
+		t.Fatalf("files = %+v, err %v; want the expired file purged", files, err)
+	}
+	if _, err := os.Stat(trashed); !os.IsNotExist(err) {
+		t.Fatalf("expired file still in trash: %v", err)
+	}
+}
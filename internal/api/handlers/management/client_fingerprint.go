@@ -0,0 +1,33 @@
+package management
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+// GetClientFingerprint returns the client fingerprint settings.
+func (h *Handler) GetClientFingerprint(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"client-fingerprint": h.cfg.ClientFingerprint})
+}
+
+// PutClientFingerprint replaces the client fingerprint settings.
+func (h *Handler) PutClientFingerprint(c *gin.Context) {
+	var body config.ClientFingerprintConfig
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid body"})
+		return
+	}
+	h.cfg.ClientFingerprint = body
+	h.cfg.SanitizeClientFingerprint()
+	h.persist(c)
+}
+
+// Client fingerprint toggle
+func (h *Handler) GetClientFingerprintEnable(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"enable": h.cfg.ClientFingerprint.Enable})
+}
+func (h *Handler) PutClientFingerprintEnable(c *gin.Context) {
+	h.updateBoolField(c, func(v bool) { h.cfg.ClientFingerprint.Enable = v })
+}
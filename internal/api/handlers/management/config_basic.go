@@ -115,52 +115,64 @@ func (h *Handler) PutConfigYAML(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_yaml", "message": "cannot read request body"})
 		return
 	}
-	var cfg config.Config
-	if err = yaml.Unmarshal(body, &cfg); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_yaml", "message": err.Error()})
+	if status, failure := h.replaceConfigYAML(body); failure != nil {
+		c.JSON(status, failure)
 		return
 	}
+	c.JSON(http.StatusOK, gin.H{"ok": true, "changed": []string{"config"}})
+}
+
+// replaceConfigYAML validates body as a full config, writes it to the config file and reloads
+// the handler's copy. On failure it returns the HTTP status and error payload to report.
+func (h *Handler) replaceConfigYAML(body []byte) (int, gin.H) {
+	if _, status, failure := h.parseConfigYAML(body); failure != nil {
+		return status, failure
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if WriteConfig(h.configFilePath, body) != nil {
+		return http.StatusInternalServerError, gin.H{"error": "write_failed", "message": "failed to write config"}
+	}
+	// Reload into handler to keep memory in sync
+	newCfg, err := config.LoadConfig(h.configFilePath)
+	if err != nil {
+		return http.StatusInternalServerError, gin.H{"error": "reload_failed", "message": err.Error()}
+	}
+	h.cfg = newCfg
+	return http.StatusOK, nil
+}
+
+// parseConfigYAML validates body as a full config and returns it as loaded from disk. On
+// failure it returns the HTTP status and error payload to report.
+func (h *Handler) parseConfigYAML(body []byte) (*config.Config, int, gin.H) {
+	var cfg config.Config
+	if err := yaml.Unmarshal(body, &cfg); err != nil {
+		return nil, http.StatusBadRequest, gin.H{"error": "invalid_yaml", "message": err.Error()}
+	}
 	// Validate config using LoadConfigOptional with optional=false to enforce parsing
 	tmpDir := filepath.Dir(h.configFilePath)
 	tmpFile, err := os.CreateTemp(tmpDir, "config-validate-*.yaml")
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "write_failed", "message": err.Error()})
-		return
+		return nil, http.StatusInternalServerError, gin.H{"error": "write_failed", "message": err.Error()}
 	}
 	tempFile := tmpFile.Name()
 	if _, errWrite := tmpFile.Write(body); errWrite != nil {
 		_ = tmpFile.Close()
 		_ = os.Remove(tempFile)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "write_failed", "message": errWrite.Error()})
-		return
+		return nil, http.StatusInternalServerError, gin.H{"error": "write_failed", "message": errWrite.Error()}
 	}
 	if errClose := tmpFile.Close(); errClose != nil {
 		_ = os.Remove(tempFile)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "write_failed", "message": errClose.Error()})
-		return
+		return nil, http.StatusInternalServerError, gin.H{"error": "write_failed", "message": errClose.Error()}
 	}
 	defer func() {
 		_ = os.Remove(tempFile)
 	}()
-	_, err = config.LoadConfigOptional(tempFile, false)
+	loaded, err := config.LoadConfigOptional(tempFile, false)
 	if err != nil {
-		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "invalid_config", "message": err.Error()})
-		return
+		return nil, http.StatusUnprocessableEntity, gin.H{"error": "invalid_config", "message": err.Error()}
 	}
-	h.mu.Lock()
-	defer h.mu.Unlock()
-	if WriteConfig(h.configFilePath, body) != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "write_failed", "message": "failed to write config"})
-		return
-	}
-	// Reload into handler to keep memory in sync
-	newCfg, err := config.LoadConfig(h.configFilePath)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "reload_failed", "message": err.Error()})
-		return
-	}
-	h.cfg = newCfg
-	c.JSON(http.StatusOK, gin.H{"ok": true, "changed": []string{"config"}})
+	return loaded, http.StatusOK, nil
 }
 
 // GetConfigYAML returns the raw config.yaml file bytes without re-encoding.
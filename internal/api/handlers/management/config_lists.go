@@ -105,7 +105,21 @@ func (h *Handler) deleteFromStringList(c *gin.Context, target *[]string, after f
 }
 
 // api-keys
-func (h *Handler) GetAPIKeys(c *gin.Context) { c.JSON(200, gin.H{"api-keys": h.cfg.APIKeys}) }
+// GetAPIKeys returns the client API keys. With ?q= or a page only a subset is returned; the
+// indexes used by PATCH and DELETE still refer to the full list.
+func (h *Handler) GetAPIKeys(c *gin.Context) {
+	q, ok := parseListQuery(c)
+	if !ok {
+		return
+	}
+	if q.Text == "" && q.Offset == 0 && q.Limit == 0 {
+		c.JSON(200, gin.H{"api-keys": h.cfg.APIKeys})
+		return
+	}
+	keys := filterItems(h.cfg.APIKeys, func(key string) bool { return q.matches(key) })
+	page, meta := paginate(keys, q)
+	c.JSON(200, gin.H{"api-keys": page, "pagination": meta})
+}
 func (h *Handler) PutAPIKeys(c *gin.Context) {
 	h.putStringList(c, func(v []string) {
 		h.cfg.APIKeys = append([]string(nil), v...)
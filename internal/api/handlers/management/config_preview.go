@@ -0,0 +1,135 @@
+package management
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/watcher/diff"
+)
+
+// configPreviewTTL bounds how long a staged config waits to be applied.
+const configPreviewTTL = 10 * time.Minute
+
+// configPreview is a validated config staged by PreviewConfig.
+type configPreview struct {
+	body     []byte
+	baseHash string
+	impact   diff.ConfigImpact
+	expires  time.Time
+}
+
+// PreviewConfig validates the config.yaml in the body, stages it without applying it and
+// returns the impact of applying it against the running config: mapping changes, added and
+// removed providers, toggled features and settings needing a restart. The staged config is
+// applied with ApplyConfigPreview or dropped with AbortConfigPreview.
+func (h *Handler) PreviewConfig(c *gin.Context) {
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_yaml", "message": "cannot read request body"})
+		return
+	}
+	newCfg, status, failure := h.parseConfigYAML(body)
+	if failure != nil {
+		c.JSON(status, failure)
+		return
+	}
+	baseHash, err := h.configFileHash()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "read_failed", "message": err.Error()})
+		return
+	}
+	impact := diff.BuildConfigImpact(comparableConfig(h.cfg), comparableConfig(newCfg))
+
+	var raw [16]byte
+	_, _ = rand.Read(raw[:])
+	id := "cp_" + hex.EncodeToString(raw[:])
+	now := time.Now()
+	expires := now.Add(configPreviewTTL).UTC()
+	h.previewMu.Lock()
+	if h.previews == nil {
+		h.previews = make(map[string]configPreview)
+	}
+	for key, pending := range h.previews {
+		if now.After(pending.expires) {
+			delete(h.previews, key)
+		}
+	}
+	h.previews[id] = configPreview{body: body, baseHash: baseHash, impact: impact, expires: expires}
+	h.previewMu.Unlock()
+
+	c.JSON(http.StatusOK, gin.H{"preview_id": id, "expires_at": expires, "empty": impact.Empty(), "impact": impact})
+}
+
+// ApplyConfigPreview writes the config staged under the id parameter, which then hot-reloads
+// like PUT /config.yaml. It answers 409 when the config file changed since the preview, so
+// the impact shown is the impact applied.
+func (h *Handler) ApplyConfigPreview(c *gin.Context) {
+	id := c.Param("id")
+	h.previewMu.Lock()
+	pending, ok := h.previews[id]
+	delete(h.previews, id)
+	h.previewMu.Unlock()
+	if !ok || time.Now().After(pending.expires) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "preview not found or expired"})
+		return
+	}
+	currentHash, err := h.configFileHash()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "read_failed", "message": err.Error()})
+		return
+	}
+	if currentHash != pending.baseHash {
+		c.JSON(http.StatusConflict, gin.H{"error": "config changed since the preview; preview again"})
+		return
+	}
+	if status, failure := h.replaceConfigYAML(pending.body); failure != nil {
+		c.JSON(status, failure)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"ok": true, "changed": []string{"config"}, "impact": pending.impact})
+}
+
+// AbortConfigPreview drops the config staged under the id parameter.
+func (h *Handler) AbortConfigPreview(c *gin.Context) {
+	id := c.Param("id")
+	h.previewMu.Lock()
+	_, ok := h.previews[id]
+	delete(h.previews, id)
+	h.previewMu.Unlock()
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "preview not found or expired"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// configFileHash returns the SHA-256 of the config file, or of nothing when it is missing.
+func (h *Handler) configFileHash() (string, error) {
+	data, err := os.ReadFile(h.configFilePath)
+	if err != nil && !os.IsNotExist(err) {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// comparableConfig returns cfg with its auth directory resolved, as the running config
+// holds it.
+func comparableConfig(cfg *config.Config) *config.Config {
+	if cfg == nil {
+		return &config.Config{}
+	}
+	out := *cfg
+	if resolved, err := util.ResolveAuthDir(out.AuthDir); err == nil {
+		out.AuthDir = resolved
+	}
+	return &out
+}
@@ -0,0 +1,184 @@
+package management
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/audit"
+)
+
+const (
+	// confirmTokenHeader carries the token that confirms a destructive request.
+	confirmTokenHeader = "X-Confirm-Token"
+	// confirmTokenTTL bounds how long a confirmation token stays valid.
+	confirmTokenTTL = 5 * time.Minute
+)
+
+// pendingConfirmation is an issued confirmation token.
+type pendingConfirmation struct {
+	fingerprint string
+	expires     time.Time
+}
+
+// replacingRoutes are the PUT routes that replace a configuration list or the whole
+// config file.
+var replacingRoutes = map[string]bool{
+	"/v0/management/config.yaml":               true,
+	"/v0/management/api-keys":                  true,
+	"/v0/management/gemini-api-key":            true,
+	"/v0/management/claude-api-key":            true,
+	"/v0/management/codex-api-key":             true,
+	"/v0/management/vertex-api-key":            true,
+	"/v0/management/openai-compatibility":      true,
+	"/v0/management/oauth-excluded-models":     true,
+	"/v0/management/oauth-model-alias":         true,
+	"/v0/management/ampcode/model-mappings":    true,
+	"/v0/management/ampcode/upstream-api-keys": true,
+	"/v0/management/prompt-templates":          true,
+}
+
+// destructiveAction reports whether the route deletes or overwrites state: every DELETE
+// but resuming a provider from maintenance, lifting auth bans, terminating connections and
+// dropping a staged config, the PUTs replacing a list or the config file, transcript
+// pruning and state and usage imports.
+func destructiveAction(method, route string) bool {
+	switch method {
+	case http.MethodDelete:
+		return route != "/v0/management/maintenance/providers/:provider" && route != "/v0/management/auth-bans" &&
+			route != "/v0/management/connections/:id" && route != "/v0/management/config/preview/:id"
+	case http.MethodPut:
+		return replacingRoutes[route]
+	case http.MethodPost:
+		return route == "/v0/management/transcripts/prune" || route == "/v0/management/state/import" ||
+			route == "/v0/management/usage/import"
+	}
+	return false
+}
+
+// ConfirmationMiddleware guards destructive management actions. ?dry_run=true (or an
+// X-Dry-Run: true header) describes the action without running it and returns a
+// confirmation token. With remote-management.confirm-destructive enabled, destructive
+// actions are refused with 428 Precondition Required until they are repeated with that
+// token in X-Confirm-Token (or ?confirm_token=). A token is single-use, expires after five
+// minutes and only confirms the identical request made with the same management key.
+func (h *Handler) ConfirmationMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !destructiveAction(c.Request.Method, c.FullPath()) {
+			c.Next()
+			return
+		}
+		dryRun := queryBool(c, "dry_run", false)
+		if header, err := strconv.ParseBool(strings.TrimSpace(c.GetHeader("X-Dry-Run"))); err == nil && header {
+			dryRun = true
+		}
+		required := h.cfg != nil && h.cfg.RemoteManagement.ConfirmDestructive
+		if !dryRun && !required {
+			c.Next()
+			return
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "failed to read request body"})
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		action := c.Request.Method + " " + c.Request.URL.Path
+		if query := confirmationQuery(c.Request.URL.Query()); query != "" {
+			action += "?" + query
+		}
+		fingerprint := confirmationFingerprint(c, action, body)
+
+		if dryRun {
+			token, expires := h.issueConfirmation(fingerprint)
+			c.AbortWithStatusJSON(http.StatusOK, gin.H{
+				"dry_run":       true,
+				"action":        action,
+				"confirm_token": token,
+				"expires_at":    expires,
+			})
+			return
+		}
+		token := strings.TrimSpace(c.GetHeader(confirmTokenHeader))
+		if token == "" {
+			token = strings.TrimSpace(c.Query("confirm_token"))
+		}
+		if token != "" && h.redeemConfirmation(token, fingerprint) {
+			c.Next()
+			return
+		}
+		message := "confirmation required"
+		if token != "" {
+			message = "invalid or expired confirmation token"
+		}
+		newToken, expires := h.issueConfirmation(fingerprint)
+		c.AbortWithStatusJSON(http.StatusPreconditionRequired, gin.H{
+			"error":         message,
+			"action":        action,
+			"confirm_token": newToken,
+			"expires_at":    expires,
+		})
+	}
+}
+
+// confirmationQuery renders the query without the confirmation parameters.
+func confirmationQuery(values url.Values) string {
+	values.Del("dry_run")
+	values.Del("confirm_token")
+	return values.Encode()
+}
+
+// confirmationFingerprint binds a token to the action, the body and the management key.
+func confirmationFingerprint(c *gin.Context, action string, body []byte) string {
+	var actor audit.Actor
+	if value, ok := c.Get(auditActorContextKey); ok {
+		actor, _ = value.(audit.Actor)
+	}
+	sum := sha256.New()
+	_, _ = sum.Write([]byte(actor.Kind + "\x00" + actor.Fingerprint + "\x00" + action + "\x00"))
+	_, _ = sum.Write(body)
+	return hex.EncodeToString(sum.Sum(nil))
+}
+
+// issueConfirmation stores a new token for fingerprint, dropping expired ones.
+func (h *Handler) issueConfirmation(fingerprint string) (string, time.Time) {
+	var raw [16]byte
+	_, _ = rand.Read(raw[:])
+	token := "ct_" + hex.EncodeToString(raw[:])
+	now := time.Now()
+	expires := now.Add(confirmTokenTTL).UTC()
+
+	h.confirmMu.Lock()
+	defer h.confirmMu.Unlock()
+	if h.confirmations == nil {
+		h.confirmations = make(map[string]pendingConfirmation)
+	}
+	for key, pending := range h.confirmations {
+		if now.After(pending.expires) {
+			delete(h.confirmations, key)
+		}
+	}
+	h.confirmations[token] = pendingConfirmation{fingerprint: fingerprint, expires: expires}
+	return token, expires
+}
+
+// redeemConfirmation consumes token and reports whether it confirms fingerprint.
+func (h *Handler) redeemConfirmation(token, fingerprint string) bool {
+	h.confirmMu.Lock()
+	defer h.confirmMu.Unlock()
+	pending, ok := h.confirmations[token]
+	if !ok {
+		return false
+	}
+	delete(h.confirmations, token)
+	return pending.fingerprint == fingerprint && time.Now().Before(pending.expires)
+}
@@ -0,0 +1,78 @@
+package management
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+func TestConfirmationMiddlewareRequiresToken(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	h := &Handler{cfg: &config.Config{RemoteManagement: config.RemoteManagement{ConfirmDestructive: true}}}
+	deleted := 0
+	r := gin.New()
+	mgmt := r.Group("/v0/management")
+	mgmt.Use(h.ConfirmationMiddleware())
+	mgmt.DELETE("/auth-files", func(c *gin.Context) {
+		deleted++
+		c.JSON(http.StatusOK, gin.H{"status": "ok"})
+	})
+	mgmt.DELETE("/maintenance/providers/:provider", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	send := func(target, token string) (int, string) {
+		req := httptest.NewRequest(http.MethodDelete, target, nil)
+		if token != "" {
+			req.Header.Set(confirmTokenHeader, token)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		var resp struct {
+			Token string `json:"confirm_token"`
+		}
+		_ = json.Unmarshal(w.Body.Bytes(), &resp)
+		return w.Code, resp.Token
+	}
+
+	status, token := send("/v0/management/auth-files?name=a.json&dry_run=true", "")
+	if status != http.StatusOK || token == "" || deleted != 0 {
+		t.Fatalf("dry run: status %d token %q deleted %d", status, token, deleted)
+	}
+	if status, _ = send("/v0/management/auth-files?name=b.json", token); status != http.StatusPreconditionRequired || deleted != 0 {
+		t.Fatalf("token of another request: status %d deleted %d, want 428 and nothing deleted", status, deleted)
+	}
+	status, token = send("/v0/management/auth-files?name=a.json", "")
+	if status != http.StatusPreconditionRequired || token == "" {
+		t.Fatalf("unconfirmed request: status %d token %q, want 428 with a token", status, token)
+	}
+	if status, _ = send("/v0/management/auth-files?name=a.json", token); status != http.StatusOK || deleted != 1 {
+		t.Fatalf("confirmed request: status %d deleted %d", status, deleted)
+	}
+	if status, _ = send("/v0/management/auth-files?name=a.json", token); status != http.StatusPreconditionRequired || deleted != 1 {
+		t.Fatalf("reused token: status %d deleted %d, want 428", status, deleted)
+	}
+	if status, _ = send("/v0/management/maintenance/providers/codex", ""); status != http.StatusOK {
+		t.Fatalf("non-destructive DELETE: status %d, want 200", status)
+	}
+}
+
+func TestDestructiveActionCoversReplacingWrites(t *testing.T) {
+	for _, tc := range []struct {
+		method, route string
+		want          bool
+	}{
+		{http.MethodPut, "/v0/management/config.yaml", true},
+		{http.MethodPut, "/v0/management/api-keys", true},
+		{http.MethodPut, "/v0/management/claude-api-key", true},
+		{http.MethodPost, "/v0/management/usage/import", true},
+		{http.MethodPut, "/v0/management/debug", false},
+		{http.MethodPatch, "/v0/management/api-keys", false},
+	} {
+		if got := destructiveAction(tc.method, tc.route); got != tc.want {
+			t.Errorf("destructiveAction(%s %s) = %v, want %v", tc.method, tc.route, got, tc.want)
+		}
+	}
+}
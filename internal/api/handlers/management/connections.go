@@ -0,0 +1,38 @@
+package management
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/connections"
+)
+
+// ListConnections lists the API requests in progress, longest-running first, with the
+// upstream provider and credential, elapsed and idle time and bytes streamed so far.
+// ?streaming=true limits the list to event streams; ?q= matches path, model, provider,
+// credential, API key and client IP.
+func (h *Handler) ListConnections(c *gin.Context) {
+	q, ok := parseListQuery(c)
+	if !ok {
+		return
+	}
+	streamingOnly := queryBool(c, "streaming", false)
+	entries := filterItems(connections.Default().Snapshot(), func(info connections.Info) bool {
+		if streamingOnly && !info.Streaming {
+			return false
+		}
+		return q.matches(info.Path, info.Model, info.Provider, info.AuthID, info.APIKey, info.ClientIP)
+	})
+	page, meta := paginate(entries, q)
+	c.JSON(http.StatusOK, gin.H{"connections": page, "pagination": meta})
+}
+
+// TerminateConnection cancels the request :id; its upstream call is aborted and a stream
+// ends at the next chunk.
+func (h *Handler) TerminateConnection(c *gin.Context) {
+	if !connections.Default().Terminate(c.Param("id")) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "connection not found"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "terminated"})
+}
@@ -0,0 +1,76 @@
+package management
+
+import (
+	"io"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/credimport"
+)
+
+// maxImportFiles bounds the files of one import upload.
+const maxImportFiles = 500
+
+// ImportAuthFiles imports the token files uploaded as multipart "file" fields: gemini CLI,
+// Claude CLI and Codex token files and credential files of this proxy. The project_id,
+// overwrite and dry_run form or query values map to the import-credentials flags.
+func (h *Handler) ImportAuthFiles(c *gin.Context) {
+	if h.authManager == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "core auth manager unavailable"})
+		return
+	}
+	form, err := c.MultipartForm()
+	if err != nil || len(form.File["file"]) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "expected multipart file fields"})
+		return
+	}
+	headers := form.File["file"]
+	if len(headers) > maxImportFiles {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "too many files"})
+		return
+	}
+	sources := make([]credimport.Source, 0, len(headers))
+	for _, header := range headers {
+		source := credimport.Source{Name: filepath.Base(header.Filename)}
+		if f, errOpen := header.Open(); errOpen == nil {
+			source.Data, _ = io.ReadAll(io.LimitReader(f, 1<<20+1))
+			_ = f.Close()
+			if len(source.Data) > 1<<20 {
+				source.Data = nil
+			}
+		}
+		sources = append(sources, source)
+	}
+	opts := credimport.Options{
+		ProjectID: strings.TrimSpace(c.DefaultPostForm("project_id", c.Query("project_id"))),
+		Overwrite: parseBoolish(c.DefaultPostForm("overwrite", c.Query("overwrite"))),
+		DryRun:    parseBoolish(c.DefaultPostForm("dry_run", c.Query("dry_run"))),
+	}
+	ctx := c.Request.Context()
+	results := credimport.Import(ctx, h.tokenStoreWithBaseDir(), h.cfg.AuthDir, sources, opts, time.Now())
+	imported := 0
+	for i, result := range results {
+		if result.Status != credimport.StatusImported {
+			continue
+		}
+		imported++
+		if opts.DryRun {
+			continue
+		}
+		if errReg := h.registerAuthFromFile(ctx, filepath.Join(h.cfg.AuthDir, result.File), nil); errReg != nil {
+			results[i].Error = errReg.Error()
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"imported": imported, "results": results})
+}
+
+func parseBoolish(value string) bool {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "1", "true", "yes", "on":
+		return true
+	}
+	return false
+}
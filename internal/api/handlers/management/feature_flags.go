@@ -0,0 +1,53 @@
+package management
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/featureflag"
+)
+
+// GetFeatureFlags lists the feature flags with their state and where it comes from.
+func (h *Handler) GetFeatureFlags(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"feature-flags": featureflag.Default().List()})
+}
+
+// featureFlagOverride is the response of PutFeatureFlag: the new state of the flag and a
+// reminder that the override only lives in memory.
+type featureFlagOverride struct {
+	featureflag.Flag
+	Persisted bool   `json:"persisted"`
+	Note      string `json:"note"`
+}
+
+// PutFeatureFlag toggles the known flag in the path at runtime. Body: {"enabled": true}.
+// The override is not written to the config file; it survives reloads until deleted but
+// not a restart, which the response says.
+func (h *Handler) PutFeatureFlag(c *gin.Context) {
+	name := strings.ToLower(strings.TrimSpace(c.Param("name")))
+	if !featureflag.IsKnown(name) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "unknown feature flag", "known": featureflag.Known()})
+		return
+	}
+	var body struct {
+		Enabled *bool `json:"enabled"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil || body.Enabled == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid body"})
+		return
+	}
+	featureflag.Default().Set(name, *body.Enabled)
+	c.JSON(http.StatusOK, featureFlagOverride{
+		Flag: featureflag.Default().Get(name),
+		Note: "in-memory override: kept across config reloads, lost on restart; set feature-flags in the config file to persist it",
+	})
+}
+
+// DeleteFeatureFlag drops the runtime override of the flag in the path, returning it to
+// its configured value.
+func (h *Handler) DeleteFeatureFlag(c *gin.Context) {
+	name := strings.ToLower(strings.TrimSpace(c.Param("name")))
+	featureflag.Default().Reset(name)
+	c.JSON(http.StatusOK, featureflag.Default().Get(name))
+}
@@ -13,8 +13,11 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/audit"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/buildinfo"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/oidc"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/transcript"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/usage"
 	sdkAuth "github.com/router-for-me/CLIProxyAPI/v6/sdk/auth"
 	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
@@ -42,11 +45,26 @@ type Handler struct {
 	failedAttempts      map[string]*attemptInfo // keyed by client IP
 	authManager         *coreauth.Manager
 	usageStats          *usage.RequestStatistics
+	transcripts         *transcript.Store
+	diffJobs            *transcript.DiffJobs
+	replayExecute       transcript.ExecuteFunc
+	modules             func() []string
 	tokenStore          coreauth.Store
 	localPassword       string
 	allowRemoteOverride bool
 	envSecret           string
 	logDir              string
+	auditMu             sync.Mutex
+	audit               *audit.Log
+	confirmMu           sync.Mutex
+	confirmations       map[string]pendingConfirmation
+	previewMu           sync.Mutex
+	previews            map[string]configPreview
+	oidcMu              sync.Mutex
+	oidc                *oidc.Provider
+	oidcKey             string
+	oidcLogins          map[string]oidcLogin
+	oidcSessions        map[string]oidcSession
 }
 
 // NewHandler creates a new management handler instance.
@@ -60,6 +78,8 @@ func NewHandler(cfg *config.Config, configFilePath string, manager *coreauth.Man
 		failedAttempts:      make(map[string]*attemptInfo),
 		authManager:         manager,
 		usageStats:          usage.GetRequestStatistics(),
+		transcripts:         transcript.GetStore(),
+		diffJobs:            transcript.NewDiffJobs(),
 		tokenStore:          sdkAuth.GetTokenStore(),
 		allowRemoteOverride: envSecret != "",
 		envSecret:           envSecret,
@@ -112,6 +132,12 @@ func (h *Handler) SetAuthManager(manager *coreauth.Manager) { h.authManager = ma
 // SetUsageStatistics allows replacing the usage statistics reference.
 func (h *Handler) SetUsageStatistics(stats *usage.RequestStatistics) { h.usageStats = stats }
 
+// SetTranscriptStore allows replacing the transcript store reference.
+func (h *Handler) SetTranscriptStore(store *transcript.Store) { h.transcripts = store }
+
+// SetReplayExecutor configures how response-diff jobs replay stored requests.
+func (h *Handler) SetReplayExecutor(execute transcript.ExecuteFunc) { h.replayExecute = execute }
+
 // SetLocalPassword configures the runtime-local password accepted for localhost requests.
 func (h *Handler) SetLocalPassword(password string) { h.localPassword = password }
 
@@ -129,7 +155,8 @@ func (h *Handler) SetLogDirectory(dir string) {
 }
 
 // Middleware enforces access control for management endpoints.
-// All requests (local and remote) require a valid management key.
+// All requests (local and remote) require a valid management key or, with OIDC login
+// configured, a session cookie from /v0/management/oidc/login.
 // Additionally, remote access requires allow-remote-management=true.
 func (h *Handler) Middleware() gin.HandlerFunc {
 	const maxFailures = 5
@@ -196,7 +223,10 @@ func (h *Handler) Middleware() gin.HandlerFunc {
 				h.attemptsMu.Unlock()
 			}
 		}
-		if secretHash == "" && envSecret == "" {
+		if h.authenticateOIDC(c) {
+			return
+		}
+		if secretHash == "" && envSecret == "" && (cfg == nil || !cfg.RemoteManagement.OIDC.Enabled()) {
 			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "remote management key not set"})
 			return
 		}
@@ -226,6 +256,7 @@ func (h *Handler) Middleware() gin.HandlerFunc {
 		if localClient {
 			if lp := h.localPassword; lp != "" {
 				if subtle.ConstantTimeCompare([]byte(provided), []byte(lp)) == 1 {
+					setAuditActor(c, "local-password", provided)
 					c.Next()
 					return
 				}
@@ -241,6 +272,7 @@ func (h *Handler) Middleware() gin.HandlerFunc {
 				}
 				h.attemptsMu.Unlock()
 			}
+			setAuditActor(c, "env-secret", provided)
 			c.Next()
 			return
 		}
@@ -262,6 +294,7 @@ func (h *Handler) Middleware() gin.HandlerFunc {
 			h.attemptsMu.Unlock()
 		}
 
+		setAuditActor(c, "secret-key", provided)
 		c.Next()
 	}
 }
@@ -0,0 +1,92 @@
+package management
+
+import (
+	"net/http"
+	"runtime"
+	"sort"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/buildinfo"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/featureflag"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/maintenance"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/offline"
+)
+
+// providerInfo counts the credentials registered for one provider.
+type providerInfo struct {
+	Credentials int `json:"credentials"`
+	Active      int `json:"active"`
+}
+
+// SetModules configures how the enabled server modules are listed by GetInfo.
+func (h *Handler) SetModules(list func() []string) { h.modules = list }
+
+// GetInfo returns a snapshot of the build, the enabled modules, the registered providers,
+// the active features and the feature flags, to attach to bug reports.
+func (h *Handler) GetInfo(c *gin.Context) {
+	var modules []string
+	if h.modules != nil {
+		modules = h.modules()
+	}
+	providers := make(map[string]providerInfo)
+	if h.authManager != nil {
+		for _, auth := range h.authManager.List() {
+			info := providers[auth.Provider]
+			info.Credentials++
+			if !auth.Disabled && !auth.Unavailable {
+				info.Active++
+			}
+			providers[auth.Provider] = info
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"version":       buildinfo.Version,
+		"commit":        buildinfo.Commit,
+		"build_date":    buildinfo.BuildDate,
+		"go_version":    runtime.Version(),
+		"platform":      runtime.GOOS + "/" + runtime.GOARCH,
+		"modules":       modules,
+		"providers":     providers,
+		"features":      featureFlags(h.cfg),
+		"feature_flags": featureflag.Default().List(),
+	})
+}
+
+// featureFlags lists the names of the switchable features active in cfg, sorted.
+func featureFlags(cfg *config.Config) []string {
+	if cfg == nil {
+		return nil
+	}
+	flags := map[string]bool{
+		"debug":                        cfg.Debug,
+		"commercial-mode":              cfg.CommercialMode,
+		"logging-to-file":              cfg.LoggingToFile,
+		"usage-statistics-enabled":     cfg.UsageStatisticsEnabled,
+		"request-log":                  cfg.RequestLog,
+		"ws-auth":                      cfg.WebsocketAuth,
+		"disable-cooling":              cfg.DisableCooling,
+		"force-model-prefix":           cfg.ForceModelPrefix,
+		"tls":                          cfg.TLS.Enable,
+		"pprof":                        cfg.Pprof.Enable,
+		"grpc":                         cfg.GRPC.Enable,
+		"metrics":                      cfg.Metrics.Enable,
+		"transcripts":                  cfg.Transcripts.Enable,
+		"mirror":                       cfg.Mirror.Enabled,
+		"fault-injection":              cfg.FaultInjection.Enable,
+		"dns-cache":                    cfg.DNSCache.Enable,
+		"offline":                      offline.Default().Enabled(),
+		"maintenance":                  maintenance.Default().State().Proxy != nil,
+		"routing-trace":                cfg.Routing.Trace,
+		"recording":                    cfg.Recording.Mode != "",
+		"ampcode-force-model-mappings": cfg.AmpCode.ForceModelMappings,
+	}
+	active := make([]string, 0, len(flags))
+	for name, on := range flags {
+		if on {
+			active = append(active, name)
+		}
+	}
+	sort.Strings(active)
+	return active
+}
@@ -0,0 +1,53 @@
+package management
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+)
+
+func TestGetInfoReportsModulesProvidersAndFlags(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	manager := coreauth.NewManager(nil, nil, nil)
+	for _, auth := range []*coreauth.Auth{
+		{ID: "c1", Provider: "claude"},
+		{ID: "c2", Provider: "claude", Disabled: true},
+		{ID: "g1", Provider: "gemini"},
+	} {
+		if _, err := manager.Register(context.Background(), auth); err != nil {
+			t.Fatalf("register %s: %v", auth.ID, err)
+		}
+	}
+	cfg := &config.Config{Debug: true, SDKConfig: config.SDKConfig{Recording: config.RecordingConfig{Mode: config.RecordingReplay}}}
+	h := &Handler{cfg: cfg, authManager: manager}
+	h.SetModules(func() []string { return []string{"amp-routing"} })
+	r := gin.New()
+	r.GET("/info", h.GetInfo)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/info", nil))
+	var resp struct {
+		Version   string                  `json:"version"`
+		Modules   []string                `json:"modules"`
+		Providers map[string]providerInfo `json:"providers"`
+		Features  []string                `json:"features"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if resp.Version == "" || len(resp.Modules) != 1 || resp.Modules[0] != "amp-routing" {
+		t.Fatalf("info = %s", w.Body.String())
+	}
+	if resp.Providers["claude"] != (providerInfo{Credentials: 2, Active: 1}) || resp.Providers["gemini"].Credentials != 1 {
+		t.Fatalf("providers = %+v", resp.Providers)
+	}
+	if len(resp.Features) != 2 || resp.Features[0] != "debug" || resp.Features[1] != "recording" {
+		t.Fatalf("features = %v", resp.Features)
+	}
+}
@@ -0,0 +1,205 @@
+package management
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// listQuery holds the pagination and filter parameters shared by the list endpoints:
+// ?offset= and ?limit= select a page (limit 0 returns everything after offset) and ?q=
+// keeps the items containing the text, case-insensitively.
+type listQuery struct {
+	Offset int
+	Limit  int
+	Text   string
+}
+
+// parseListQuery reads the list parameters of c. It answers 400 and returns false when one
+// is invalid.
+func parseListQuery(c *gin.Context) (listQuery, bool) {
+	var q listQuery
+	for _, param := range []struct {
+		name string
+		dst  *int
+	}{{"offset", &q.Offset}, {"limit", &q.Limit}} {
+		raw := strings.TrimSpace(c.Query(param.name))
+		if raw == "" {
+			continue
+		}
+		value, err := strconv.Atoi(raw)
+		if err != nil || value < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid " + param.name})
+			return listQuery{}, false
+		}
+		*param.dst = value
+	}
+	q.Text = strings.ToLower(strings.TrimSpace(c.Query("q")))
+	return q, true
+}
+
+// matches reports whether one of fields contains the ?q= text.
+func (q listQuery) matches(fields ...string) bool {
+	if q.Text == "" {
+		return true
+	}
+	for _, field := range fields {
+		if strings.Contains(strings.ToLower(field), q.Text) {
+			return true
+		}
+	}
+	return false
+}
+
+// filterItems returns the items keep accepts.
+func filterItems[T any](items []T, keep func(T) bool) []T {
+	out := make([]T, 0, len(items))
+	for _, item := range items {
+		if keep(item) {
+			out = append(out, item)
+		}
+	}
+	return out
+}
+
+// paginate returns the page of items selected by q and the pagination metadata reported
+// next to it. next_offset is omitted on the last page.
+func paginate[T any](items []T, q listQuery) ([]T, gin.H) {
+	total := len(items)
+	start := min(q.Offset, total)
+	end := total
+	if q.Limit > 0 {
+		end = min(start+q.Limit, total)
+	}
+	meta := gin.H{"total": total, "offset": start, "limit": q.Limit}
+	if end < total {
+		meta["next_offset"] = end
+	}
+	return items[start:end:end], meta
+}
+
+// ConditionalGET adds a strong ETag to successful JSON responses of GET requests and
+// answers 304 Not Modified when it matches the request's If-None-Match header, so polling
+// clients skip unchanged listings. Other responses are passed through untouched.
+func ConditionalGET() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.Method != http.MethodGet {
+			c.Next()
+			return
+		}
+		writer := &etagWriter{ResponseWriter: c.Writer, status: http.StatusOK}
+		c.Writer = writer
+		c.Next()
+		c.Writer = writer.ResponseWriter
+		writer.finish(c.Request.Header.Get("If-None-Match"))
+	}
+}
+
+// etagWriter buffers a 200 JSON response until the handler returns so its ETag can be
+// computed. The decision to buffer is made on the first write, once the headers are set.
+type etagWriter struct {
+	gin.ResponseWriter
+	status  int
+	decided bool
+	buffer  bool
+	body    bytes.Buffer
+}
+
+func (w *etagWriter) decide() {
+	if w.decided {
+		return
+	}
+	w.decided = true
+	contentType := w.ResponseWriter.Header().Get("Content-Type")
+	w.buffer = w.status == http.StatusOK && strings.HasPrefix(contentType, "application/json")
+	if !w.buffer {
+		w.ResponseWriter.WriteHeader(w.status)
+	}
+}
+
+func (w *etagWriter) WriteHeader(code int) {
+	if !w.decided {
+		w.status = code
+	}
+}
+
+func (w *etagWriter) WriteHeaderNow() {
+	w.decide()
+	if !w.buffer {
+		w.ResponseWriter.WriteHeaderNow()
+	}
+}
+
+func (w *etagWriter) Write(data []byte) (int, error) {
+	w.decide()
+	if w.buffer {
+		return w.body.Write(data)
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *etagWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+func (w *etagWriter) Status() int {
+	if !w.decided || w.buffer {
+		return w.status
+	}
+	return w.ResponseWriter.Status()
+}
+
+func (w *etagWriter) Written() bool {
+	return w.decided
+}
+
+func (w *etagWriter) Flush() {
+	w.decide()
+	if !w.buffer {
+		w.ResponseWriter.Flush()
+	}
+}
+
+// finish sends the buffered response, or 304 when ifNoneMatch names its ETag.
+func (w *etagWriter) finish(ifNoneMatch string) {
+	if !w.decided {
+		// Nothing was written; keep the status the handler chose, if any.
+		if w.status != http.StatusOK {
+			w.ResponseWriter.WriteHeader(w.status)
+		}
+		return
+	}
+	if !w.buffer {
+		return
+	}
+	sum := sha256.Sum256(w.body.Bytes())
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+	w.ResponseWriter.Header().Set("ETag", etag)
+	if etagMatches(ifNoneMatch, etag) {
+		header := w.ResponseWriter.Header()
+		header.Del("Content-Type")
+		header.Del("Content-Length")
+		w.ResponseWriter.WriteHeader(http.StatusNotModified)
+		w.ResponseWriter.WriteHeaderNow()
+		return
+	}
+	w.ResponseWriter.WriteHeader(http.StatusOK)
+	_, _ = w.ResponseWriter.Write(w.body.Bytes())
+}
+
+// etagMatches reports whether the If-None-Match header value lists etag or "*". Weak
+// validators match their strong counterpart.
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,79 @@
+package management
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+func TestGetAPIKeysPaginatesAndFilters(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	h := &Handler{cfg: &config.Config{SDKConfig: config.SDKConfig{APIKeys: []string{"team-a-1", "team-b-1", "team-a-2", "team-a-3"}}}}
+	r := gin.New()
+	r.GET("/api-keys", h.GetAPIKeys)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api-keys?q=TEAM-A&offset=1&limit=1", nil))
+	var resp struct {
+		Keys       []string       `json:"api-keys"`
+		Pagination map[string]int `json:"pagination"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(resp.Keys) != 1 || resp.Keys[0] != "team-a-2" {
+		t.Fatalf("keys = %v, want [team-a-2]", resp.Keys)
+	}
+	if resp.Pagination["total"] != 3 || resp.Pagination["next_offset"] != 2 {
+		t.Fatalf("pagination = %v, want total 3 and next_offset 2", resp.Pagination)
+	}
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api-keys?limit=-1", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("negative limit: status = %d, want 400", w.Code)
+	}
+}
+
+func TestConditionalGETAnswersNotModified(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	body := "v1"
+	r := gin.New()
+	r.Use(ConditionalGET())
+	r.GET("/state", func(c *gin.Context) { c.JSON(http.StatusOK, gin.H{"value": body}) })
+	r.GET("/text", func(c *gin.Context) { c.String(http.StatusOK, "plain") })
+	r.GET("/missing", func(c *gin.Context) { c.JSON(http.StatusNotFound, gin.H{"error": "missing"}) })
+
+	get := func(path, etag string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	first := get("/state", "")
+	etag := first.Header().Get("ETag")
+	if first.Code != http.StatusOK || etag == "" || first.Body.String() != `{"value":"v1"}` {
+		t.Fatalf("first response: %d %q etag %q", first.Code, first.Body.String(), etag)
+	}
+	if w := get("/state", "W/"+etag); w.Code != http.StatusNotModified || w.Body.Len() != 0 {
+		t.Fatalf("matching If-None-Match: %d %q, want an empty 304", w.Code, w.Body.String())
+	}
+	body = "v2"
+	if w := get("/state", etag); w.Code != http.StatusOK || w.Header().Get("ETag") == etag {
+		t.Fatalf("changed body: status %d etag %q, want a 200 with a new ETag", w.Code, w.Header().Get("ETag"))
+	}
+	if w := get("/text", ""); w.Code != http.StatusOK || w.Header().Get("ETag") != "" || w.Body.String() != "plain" {
+		t.Fatalf("non-JSON response was changed: %d %q etag %q", w.Code, w.Body.String(), w.Header().Get("ETag"))
+	}
+	if w := get("/missing", ""); w.Code != http.StatusNotFound || w.Header().Get("ETag") != "" {
+		t.Fatalf("error response: %d etag %q, want a 404 without ETag", w.Code, w.Header().Get("ETag"))
+	}
+}
@@ -206,7 +206,13 @@ func (h *Handler) GetRequestErrorLogs(c *gin.Context) {
 
 	sort.Slice(files, func(i, j int) bool { return files[i].Modified > files[j].Modified })
 
-	c.JSON(http.StatusOK, gin.H{"files": files})
+	q, ok := parseListQuery(c)
+	if !ok {
+		return
+	}
+	files = filterItems(files, func(file errorLog) bool { return q.matches(file.Name) })
+	page, meta := paginate(files, q)
+	c.JSON(http.StatusOK, gin.H{"files": page, "pagination": meta})
 }
 
 // GetRequestLogByID finds and downloads a request log file by its request ID.
@@ -0,0 +1,62 @@
+package management
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/maintenance"
+)
+
+// GetMaintenance returns the active maintenance and drain toggles and the number of API
+// requests still in flight.
+func (h *Handler) GetMaintenance(c *gin.Context) {
+	c.JSON(http.StatusOK, maintenance.Default().State())
+}
+
+// PutMaintenance turns proxy-wide maintenance on or off. Body: {"enabled": true,
+// "message": "..."}; the message defaults to maintenance.message from the config.
+func (h *Handler) PutMaintenance(c *gin.Context) {
+	var body struct {
+		Enabled *bool  `json:"enabled"`
+		Message string `json:"message"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil || body.Enabled == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid body"})
+		return
+	}
+	if *body.Enabled {
+		maintenance.Default().SetProxy(body.Message)
+	} else {
+		maintenance.Default().ClearProxy()
+	}
+	c.JSON(http.StatusOK, maintenance.Default().State())
+}
+
+// DrainProvider puts the provider in the path into drain mode. Body (optional):
+// {"action": "reroute"|"reject", "message": "..."}.
+func (h *Handler) DrainProvider(c *gin.Context) {
+	var body struct {
+		Action  string `json:"action"`
+		Message string `json:"message"`
+	}
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid body"})
+			return
+		}
+	}
+	if _, err := maintenance.Default().DrainProvider(c.Param("provider"), body.Action, body.Message); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, maintenance.Default().State())
+}
+
+// ResumeProvider ends the drain of the provider in the path.
+func (h *Handler) ResumeProvider(c *gin.Context) {
+	if !maintenance.Default().ResumeProvider(c.Param("provider")) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "provider is not draining"})
+		return
+	}
+	c.JSON(http.StatusOK, maintenance.Default().State())
+}
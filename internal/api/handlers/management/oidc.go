@@ -0,0 +1,324 @@
+package management
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/audit"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/oidc"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/oauth2"
+)
+
+const (
+	// oidcSessionCookie holds the management login session of OIDC users.
+	oidcSessionCookie = "cpa_management_session"
+	// oidcCallbackPath is the redirect target registered at the identity provider.
+	oidcCallbackPath = "/v0/management/oidc/callback"
+	// oidcLoginTTL bounds the time between starting a login and its callback.
+	oidcLoginTTL = 10 * time.Minute
+)
+
+// viewerRoutes are the management GET routes open to the viewer role, relative to
+// /v0/management. Routes returning keys, credentials, the config or request contents are
+// left out.
+var viewerRoutes = map[string]bool{
+	"/info":                                true,
+	"/feature-flags":                       true,
+	"/audit-log":                           true,
+	"/connections":                         true,
+	"/auth-bans":                           true,
+	"/usage":                               true,
+	"/usage-reports":                       true,
+	"/usage-reports/:month":                true,
+	"/usage/export":                        true,
+	"/usage/records":                       true,
+	"/maintenance":                         true,
+	"/synthetic-probes":                    true,
+	"/spend-limits/status":                 true,
+	"/slo":                                 true,
+	"/agent-budget/sessions":               true,
+	"/latest-version":                      true,
+	"/debug":                               true,
+	"/logging-to-file":                     true,
+	"/logs-max-total-size-mb":              true,
+	"/error-logs-max-files":                true,
+	"/usage-statistics-enabled":            true,
+	"/quota-exceeded/switch-project":       true,
+	"/quota-exceeded/switch-preview-model": true,
+	"/request-log":                         true,
+	"/ws-auth":                             true,
+	"/ampcode/restrict-management-to-localhost": true,
+	"/ampcode/model-mappings":                   true,
+	"/ampcode/force-model-mappings":             true,
+	"/ampcode/model-mapping-suggestions":        true,
+	"/ampcode/fairness":                         true,
+	"/client-fingerprint/enable":                true,
+	"/request-retry":                            true,
+	"/max-retry-interval":                       true,
+	"/force-model-prefix":                       true,
+	"/routing/strategy":                         true,
+	"/oauth-excluded-models":                    true,
+	"/oauth-model-alias":                        true,
+	"/auth-files":                               true,
+	"/auth-files/models":                        true,
+	"/model-definitions/:channel":               true,
+}
+
+// oidcLogin is a started login awaiting its callback.
+type oidcLogin struct {
+	nonce    string
+	verifier string
+	redirect string
+	expires  time.Time
+}
+
+// oidcSession is a logged-in OIDC user.
+type oidcSession struct {
+	subject string
+	email   string
+	role    string
+	expires time.Time
+}
+
+// oidcProvider returns the provider of the configured issuer, or nil when OIDC is off.
+func (h *Handler) oidcProvider() (*oidc.Provider, config.ManagementOIDC) {
+	if h == nil || h.cfg == nil || !h.cfg.RemoteManagement.OIDC.Enabled() {
+		return nil, config.ManagementOIDC{}
+	}
+	settings := h.cfg.RemoteManagement.OIDC
+	key := settings.Issuer + "\x00" + settings.ClientID
+	h.oidcMu.Lock()
+	defer h.oidcMu.Unlock()
+	if h.oidc == nil || h.oidcKey != key {
+		h.oidc, h.oidcKey = oidc.NewProvider(settings.Issuer, settings.ClientID, nil), key
+		h.oidcLogins, h.oidcSessions = nil, nil
+	}
+	return h.oidc, settings
+}
+
+// OIDCLogin starts the OIDC login by redirecting to the identity provider. ?redirect=
+// names the local page to return to after the login (default: the control panel).
+func (h *Handler) OIDCLogin(c *gin.Context) {
+	provider, settings := h.oidcProvider()
+	if provider == nil {
+		c.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+	oauthCfg, err := provider.OAuth2Config(c.Request.Context(), settings.ClientSecret, oidcRedirectURL(c, settings), settings.Scopes)
+	if err != nil {
+		log.Errorf("management oidc: %v", err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "identity provider unavailable"})
+		return
+	}
+	redirect := c.Query("redirect")
+	if !localRedirect(redirect) {
+		redirect = "/management.html"
+	}
+	state, nonce, verifier := randomToken(), randomToken(), oauth2.GenerateVerifier()
+
+	h.oidcMu.Lock()
+	now := time.Now()
+	if h.oidcLogins == nil {
+		h.oidcLogins = make(map[string]oidcLogin)
+	}
+	for key, login := range h.oidcLogins {
+		if now.After(login.expires) {
+			delete(h.oidcLogins, key)
+		}
+	}
+	h.oidcLogins[state] = oidcLogin{nonce: nonce, verifier: verifier, redirect: redirect, expires: now.Add(oidcLoginTTL)}
+	h.oidcMu.Unlock()
+
+	c.Redirect(http.StatusFound, oauthCfg.AuthCodeURL(state, oauth2.S256ChallengeOption(verifier), oauth2.SetAuthURLParam("nonce", nonce)))
+}
+
+// OIDCCallback completes the login: it exchanges the code, verifies the ID token, maps the
+// user's groups to a role and starts a session cookie.
+func (h *Handler) OIDCCallback(c *gin.Context) {
+	provider, settings := h.oidcProvider()
+	if provider == nil {
+		c.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+	if errParam := c.Query("error"); errParam != "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "login failed: " + errParam})
+		return
+	}
+	state := c.Query("state")
+	h.oidcMu.Lock()
+	login, ok := h.oidcLogins[state]
+	delete(h.oidcLogins, state)
+	h.oidcMu.Unlock()
+	if !ok || time.Now().After(login.expires) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "unknown or expired login state"})
+		return
+	}
+
+	ctx := provider.HTTPContext(c.Request.Context())
+	oauthCfg, err := provider.OAuth2Config(ctx, settings.ClientSecret, oidcRedirectURL(c, settings), settings.Scopes)
+	if err != nil {
+		log.Errorf("management oidc: %v", err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "identity provider unavailable"})
+		return
+	}
+	token, err := oauthCfg.Exchange(ctx, c.Query("code"), oauth2.VerifierOption(login.verifier))
+	if err != nil {
+		log.Warnf("management oidc: code exchange failed: %v", err)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "login failed"})
+		return
+	}
+	rawIDToken, _ := token.Extra("id_token").(string)
+	claims, err := provider.Verify(ctx, rawIDToken, login.nonce, settings.GroupsClaim)
+	if err != nil {
+		log.Warnf("management oidc: %v", err)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "login failed"})
+		return
+	}
+	role := oidcRole(settings, claims)
+	if role == "" {
+		log.Warnf("management oidc: %s has no management role", claimsIdentity(claims))
+		c.JSON(http.StatusForbidden, gin.H{"error": "no management role granted"})
+		return
+	}
+
+	sessionID := randomToken()
+	ttl := time.Duration(settings.SessionSeconds) * time.Second
+	h.oidcMu.Lock()
+	if h.oidcSessions == nil {
+		h.oidcSessions = make(map[string]oidcSession)
+	}
+	now := time.Now()
+	for key, session := range h.oidcSessions {
+		if now.After(session.expires) {
+			delete(h.oidcSessions, key)
+		}
+	}
+	h.oidcSessions[sessionID] = oidcSession{subject: claims.Subject, email: claims.Email, role: role, expires: now.Add(ttl)}
+	h.oidcMu.Unlock()
+
+	log.Infof("management oidc: %s logged in as %s", claimsIdentity(claims), role)
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     oidcSessionCookie,
+		Value:    sessionID,
+		Path:     "/",
+		MaxAge:   int(ttl / time.Second),
+		HttpOnly: true,
+		Secure:   requestIsHTTPS(c),
+		SameSite: http.SameSiteLaxMode,
+	})
+	c.Redirect(http.StatusFound, login.redirect)
+}
+
+// OIDCLogout ends the OIDC session of the request.
+func (h *Handler) OIDCLogout(c *gin.Context) {
+	if cookie, err := c.Request.Cookie(oidcSessionCookie); err == nil {
+		h.oidcMu.Lock()
+		delete(h.oidcSessions, cookie.Value)
+		h.oidcMu.Unlock()
+	}
+	http.SetCookie(c.Writer, &http.Cookie{Name: oidcSessionCookie, Value: "", Path: "/", MaxAge: -1, HttpOnly: true})
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// authenticateOIDC accepts requests carrying a valid OIDC session cookie. It reports
+// whether the request was handled: either authenticated (and passed on) or refused.
+func (h *Handler) authenticateOIDC(c *gin.Context) bool {
+	if provider, _ := h.oidcProvider(); provider == nil {
+		return false
+	}
+	cookie, err := c.Request.Cookie(oidcSessionCookie)
+	if err != nil || cookie.Value == "" {
+		return false
+	}
+	h.oidcMu.Lock()
+	session, ok := h.oidcSessions[cookie.Value]
+	h.oidcMu.Unlock()
+	if !ok || time.Now().After(session.expires) {
+		return false
+	}
+	readOnly := c.Request.Method == http.MethodGet || c.Request.Method == http.MethodHead
+	if readOnly && session.role != config.ManagementRoleAdmin && !viewerRoutes[strings.TrimPrefix(c.FullPath(), "/v0/management")] {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "the viewer role cannot read this resource"})
+		return true
+	}
+	if !readOnly {
+		if session.role != config.ManagementRoleAdmin {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "the viewer role is read-only"})
+			return true
+		}
+		// Cookies ride along on cross-site requests; refuse mutations from other origins.
+		if origin := c.GetHeader("Origin"); origin != "" {
+			if parsed, errParse := url.Parse(origin); errParse != nil || parsed.Host != c.Request.Host {
+				c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "cross-origin request refused"})
+				return true
+			}
+		}
+	}
+	sum := sha256.Sum256([]byte(session.subject))
+	c.Set(auditActorContextKey, audit.Actor{Kind: "oidc", Fingerprint: hex.EncodeToString(sum[:6]), Label: session.email})
+	c.Next()
+	return true
+}
+
+// oidcRole returns the most privileged role mapped from the user's groups or email.
+func oidcRole(settings config.ManagementOIDC, claims *oidc.Claims) string {
+	role := ""
+	for _, name := range append(append([]string(nil), claims.Groups...), claims.Email) {
+		switch settings.RoleMapping[name] {
+		case config.ManagementRoleAdmin:
+			return config.ManagementRoleAdmin
+		case config.ManagementRoleViewer:
+			role = config.ManagementRoleViewer
+		}
+	}
+	if role == "" {
+		role = settings.DefaultRole
+	}
+	return role
+}
+
+// localRedirect reports whether target is a path on this server. Browsers read "//host"
+// and "/\host" as other hosts, and drop tabs and newlines first, so only a single leading
+// slash with no backslash or control character passes.
+func localRedirect(target string) bool {
+	if !strings.HasPrefix(target, "/") || strings.HasPrefix(target, "//") {
+		return false
+	}
+	return !strings.ContainsFunc(target, func(r rune) bool { return r == '\\' || r < 0x20 || r == 0x7f })
+}
+
+func oidcRedirectURL(c *gin.Context, settings config.ManagementOIDC) string {
+	if settings.RedirectURL != "" {
+		return settings.RedirectURL
+	}
+	scheme := "http"
+	if requestIsHTTPS(c) {
+		scheme = "https"
+	}
+	return scheme + "://" + c.Request.Host + oidcCallbackPath
+}
+
+func requestIsHTTPS(c *gin.Context) bool {
+	return c.Request.TLS != nil || strings.EqualFold(c.GetHeader("X-Forwarded-Proto"), "https")
+}
+
+func claimsIdentity(claims *oidc.Claims) string {
+	if claims.Email != "" {
+		return claims.Email
+	}
+	return claims.Subject
+}
+
+func randomToken() string {
+	var raw [16]byte
+	_, _ = rand.Read(raw[:])
+	return hex.EncodeToString(raw[:])
+}
@@ -0,0 +1,107 @@
+package management
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/oidc"
+)
+
+func TestOIDCRole(t *testing.T) {
+	settings := config.ManagementOIDC{
+		RoleMapping: map[string]string{"sre": config.ManagementRoleViewer, "platform": config.ManagementRoleAdmin, "boss@example.com": config.ManagementRoleAdmin},
+	}
+	cases := []struct {
+		claims oidc.Claims
+		want   string
+	}{
+		{oidc.Claims{Groups: []string{"sre"}}, config.ManagementRoleViewer},
+		{oidc.Claims{Groups: []string{"sre", "platform"}}, config.ManagementRoleAdmin},
+		{oidc.Claims{Email: "boss@example.com"}, config.ManagementRoleAdmin},
+		{oidc.Claims{Groups: []string{"sales"}}, ""},
+	}
+	for _, tc := range cases {
+		if got := oidcRole(settings, &tc.claims); got != tc.want {
+			t.Errorf("oidcRole(%+v) = %q, want %q", tc.claims, got, tc.want)
+		}
+	}
+	settings.DefaultRole = config.ManagementRoleViewer
+	if got := oidcRole(settings, &oidc.Claims{Groups: []string{"sales"}}); got != config.ManagementRoleViewer {
+		t.Errorf("default role = %q, want viewer", got)
+	}
+}
+
+func TestMiddlewareAcceptsOIDCSessions(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	h := &Handler{cfg: &config.Config{RemoteManagement: config.RemoteManagement{
+		AllowRemote: true,
+		OIDC:        config.ManagementOIDC{Issuer: "https://idp.example.com", ClientID: "proxy"},
+	}}, failedAttempts: make(map[string]*attemptInfo)}
+	h.oidcProvider()
+	expires := time.Now().Add(time.Hour)
+	h.oidcSessions = map[string]oidcSession{
+		"admin-session":  {subject: "a", email: "admin@example.com", role: config.ManagementRoleAdmin, expires: expires},
+		"viewer-session": {subject: "v", email: "viewer@example.com", role: config.ManagementRoleViewer, expires: expires},
+	}
+	r := gin.New()
+	r.Use(h.Middleware())
+	r.GET("/config", func(c *gin.Context) { c.Status(http.StatusOK) })
+	r.GET("/usage", func(c *gin.Context) { c.Status(http.StatusOK) })
+	r.PUT("/debug", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	send := func(method, path, session, origin string) int {
+		req := httptest.NewRequest(method, path, nil)
+		if session != "" {
+			req.AddCookie(&http.Cookie{Name: oidcSessionCookie, Value: session})
+		}
+		if origin != "" {
+			req.Header.Set("Origin", origin)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	if code := send(http.MethodGet, "/config", "", ""); code != http.StatusUnauthorized {
+		t.Fatalf("no session: status %d, want 401", code)
+	}
+	if code := send(http.MethodGet, "/usage", "viewer-session", ""); code != http.StatusOK {
+		t.Fatalf("viewer GET: status %d, want 200", code)
+	}
+	if code := send(http.MethodGet, "/config", "viewer-session", ""); code != http.StatusForbidden {
+		t.Fatalf("viewer GET of the config: status %d, want 403", code)
+	}
+	if code := send(http.MethodGet, "/config", "admin-session", ""); code != http.StatusOK {
+		t.Fatalf("admin GET of the config: status %d, want 200", code)
+	}
+	if code := send(http.MethodPut, "/debug", "viewer-session", ""); code != http.StatusForbidden {
+		t.Fatalf("viewer PUT: status %d, want 403", code)
+	}
+	if code := send(http.MethodPut, "/debug", "admin-session", "http://example.com"); code != http.StatusOK {
+		t.Fatalf("admin PUT: status %d, want 200", code)
+	}
+	if code := send(http.MethodPut, "/debug", "admin-session", "https://evil.example"); code != http.StatusForbidden {
+		t.Fatalf("cross-origin PUT: status %d, want 403", code)
+	}
+}
+
+func TestLocalRedirect(t *testing.T) {
+	for target, want := range map[string]bool{
+		"/management.html": true,
+		"/usage?tab=keys":  true,
+		"":                 false,
+		"https://evil.com": false,
+		"//evil.com":       false,
+		"/\\evil.com":      false,
+		"/\t/evil.com":     false,
+		"/path\\..\\x":     false,
+	} {
+		if got := localRedirect(target); got != want {
+			t.Errorf("localRedirect(%q) = %v, want %v", target, got, want)
+		}
+	}
+}
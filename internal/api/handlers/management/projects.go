@@ -0,0 +1,62 @@
+package management
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/project"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/usage"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+)
+
+// ListProjects returns every configured project with its quota and current usage.
+// ?q= searches the project names.
+func (h *Handler) ListProjects(c *gin.Context) {
+	q, ok := parseListQuery(c)
+	if !ok {
+		return
+	}
+	projects := filterItems(project.GetRegistry().Snapshot(), func(p project.Status) bool { return q.matches(p.Name) })
+	page, meta := paginate(projects, q)
+	c.JSON(http.StatusOK, gin.H{"projects": page, "pagination": meta})
+}
+
+// GetProjectUsage returns the usage statistics restricted to the project's API keys.
+// Keys are masked in the response.
+func (h *Handler) GetProjectUsage(c *gin.Context) {
+	name := c.Param("name")
+	var status *project.Status
+	for _, p := range project.GetRegistry().Snapshot() {
+		if p.Name == name {
+			p := p
+			status = &p
+			break
+		}
+	}
+	if status == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "project not found"})
+		return
+	}
+
+	var snapshot usage.StatisticsSnapshot
+	if h != nil && h.usageStats != nil {
+		snapshot = h.usageStats.Snapshot()
+	}
+	apis := make(map[string]usage.APISnapshot)
+	var totalRequests, totalTokens int64
+	for _, key := range project.GetRegistry().Keys(name) {
+		api, ok := snapshot.APIs[key]
+		if !ok {
+			continue
+		}
+		apis[util.HideAPIKey(key)] = api
+		totalRequests += api.TotalRequests
+		totalTokens += api.TotalTokens
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"project":        status,
+		"total_requests": totalRequests,
+		"total_tokens":   totalTokens,
+		"apis":           apis,
+	})
+}
@@ -0,0 +1,90 @@
+package management
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+// GetPromptTemplates returns the configured prompt templates.
+func (h *Handler) GetPromptTemplates(c *gin.Context) {
+	if h == nil || h.cfg == nil {
+		c.JSON(http.StatusOK, gin.H{"prompt-templates": []config.PromptTemplate{}})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"prompt-templates": h.cfg.PromptTemplates})
+}
+
+// PutPromptTemplates replaces all prompt templates.
+func (h *Handler) PutPromptTemplates(c *gin.Context) {
+	var body struct {
+		Value []config.PromptTemplate `json:"value"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid body"})
+		return
+	}
+	h.cfg.PromptTemplates = body.Value
+	h.cfg.SanitizePromptTemplates()
+	h.persist(c)
+}
+
+// PatchPromptTemplates adds templates or replaces those with the same name.
+func (h *Handler) PatchPromptTemplates(c *gin.Context) {
+	var body struct {
+		Value []config.PromptTemplate `json:"value"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil || len(body.Value) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid body"})
+		return
+	}
+
+	existing := make(map[string]int, len(h.cfg.PromptTemplates))
+	for i, tmpl := range h.cfg.PromptTemplates {
+		existing[tmpl.Name] = i
+	}
+	for _, tmpl := range body.Value {
+		name := strings.TrimSpace(tmpl.Name)
+		if idx, ok := existing[name]; ok {
+			h.cfg.PromptTemplates[idx] = tmpl
+		} else {
+			h.cfg.PromptTemplates = append(h.cfg.PromptTemplates, tmpl)
+			existing[name] = len(h.cfg.PromptTemplates) - 1
+		}
+	}
+	h.cfg.SanitizePromptTemplates()
+	h.persist(c)
+}
+
+// DeletePromptTemplates removes the templates named by the "name" query parameter or the
+// "value" list of the body.
+func (h *Handler) DeletePromptTemplates(c *gin.Context) {
+	toRemove := make(map[string]bool)
+	if name := strings.TrimSpace(c.Query("name")); name != "" {
+		toRemove[name] = true
+	} else {
+		var body struct {
+			Value []string `json:"value"`
+		}
+		if err := c.ShouldBindJSON(&body); err == nil {
+			for _, name := range body.Value {
+				toRemove[strings.TrimSpace(name)] = true
+			}
+		}
+	}
+	if len(toRemove) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "missing name"})
+		return
+	}
+
+	kept := make([]config.PromptTemplate, 0, len(h.cfg.PromptTemplates))
+	for _, tmpl := range h.cfg.PromptTemplates {
+		if !toRemove[tmpl.Name] {
+			kept = append(kept, tmpl)
+		}
+	}
+	h.cfg.PromptTemplates = kept
+	h.persist(c)
+}
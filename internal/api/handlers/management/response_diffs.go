@@ -0,0 +1,74 @@
+package management
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/transcript"
+)
+
+// StartResponseDiff replays exchanges of a stored transcript session against an original
+// and a mapped model and compares the responses in the background. The body names the
+// session, the mapped model and optionally the original model (default: the recorded
+// one), explicit entry_ids or a limit of latest successful exchanges (default 10).
+func (h *Handler) StartResponseDiff(c *gin.Context) {
+	if h.replayExecute == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "request replay is not available"})
+		return
+	}
+	var req transcript.DiffRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid body"})
+		return
+	}
+	req.SessionID = transcript.NormalizeSessionID(strings.TrimSpace(req.SessionID))
+	if req.SessionID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "session is required"})
+		return
+	}
+	entries, err := h.transcriptStore().Entries(req.SessionID)
+	if err != nil {
+		writeTranscriptError(c, err)
+		return
+	}
+	report, err := h.diffJobs.Start(req, entries, h.replayExecute)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusAccepted, report)
+}
+
+// ListResponseDiffs returns the comparison jobs, newest first, without their entries.
+// ?status= filters on the job status and ?q= searches the ids and model names.
+func (h *Handler) ListResponseDiffs(c *gin.Context) {
+	q, ok := parseListQuery(c)
+	if !ok {
+		return
+	}
+	status := strings.TrimSpace(c.Query("status"))
+	jobs := filterItems(h.diffJobs.List(), func(job transcript.DiffReport) bool {
+		if status != "" && !strings.EqualFold(job.Status, status) {
+			return false
+		}
+		return q.matches(job.ID, job.SessionID, job.Original, job.Mapped)
+	})
+	page, meta := paginate(jobs, q)
+	c.JSON(http.StatusOK, gin.H{"jobs": page, "pagination": meta})
+}
+
+// GetResponseDiff returns the report of a comparison job.
+func (h *Handler) GetResponseDiff(c *gin.Context) {
+	report, err := h.diffJobs.Get(c.Param("id"))
+	if err != nil {
+		if errors.Is(err, transcript.ErrDiffJobNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "diff job not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
@@ -0,0 +1,34 @@
+package management
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/scheduledprompt"
+)
+
+// GetScheduledPrompts returns the run history of the scheduled prompts.
+func (h *Handler) GetScheduledPrompts(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"enabled": scheduledprompt.Default().Enabled(),
+		"prompts": scheduledprompt.Default().Snapshot(),
+	})
+}
+
+// RunScheduledPrompts starts the prompt given by ?name=, or every prompt, now instead of
+// waiting for its schedule.
+func (h *Handler) RunScheduledPrompts(c *gin.Context) {
+	name := strings.TrimSpace(c.Query("name"))
+	if name != "" {
+		known := false
+		for _, st := range scheduledprompt.Default().Snapshot() {
+			known = known || st.Name == name
+		}
+		if !known {
+			c.JSON(http.StatusNotFound, gin.H{"error": "unknown scheduled prompt " + name})
+			return
+		}
+	}
+	c.JSON(http.StatusAccepted, gin.H{"started": scheduledprompt.Default().RunNow(name)})
+}
@@ -0,0 +1,28 @@
+package management
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/sharedstate"
+)
+
+// GetSharedState reports the high-availability coordinator of this instance and the
+// credentials it currently leases when credential sharding is enabled.
+func (h *Handler) GetSharedState(c *gin.Context) {
+	coordinator := sharedstate.Active()
+	if coordinator == nil {
+		c.JSON(http.StatusOK, gin.H{"enabled": false})
+		return
+	}
+	resp := gin.H{
+		"enabled":  true,
+		"backend":  h.cfg.SharedState.Backend,
+		"instance": coordinator.Instance(),
+		"sharding": coordinator.Sharding(),
+	}
+	if coordinator.Sharding() {
+		resp["leases"] = coordinator.Leases()
+	}
+	c.JSON(http.StatusOK, resp)
+}
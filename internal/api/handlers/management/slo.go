@@ -0,0 +1,13 @@
+package management
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/slo"
+)
+
+// GetSLOStatus returns the current evaluation of every SLO rule.
+func (h *Handler) GetSLOStatus(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"rules": slo.Default().Snapshot()})
+}
@@ -0,0 +1,24 @@
+package management
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/spendlimit"
+)
+
+// GetSpendLimitStatus returns the current month's spend for every limited credential.
+func (h *Handler) GetSpendLimitStatus(c *gin.Context) {
+	tracker := spendlimit.Default()
+	c.JSON(http.StatusOK, gin.H{
+		"policy":      tracker.Policy(),
+		"credentials": tracker.Snapshot(),
+	})
+}
+
+// ResetSpendLimitStatus clears accumulated spend for ?auth_id=, or for every credential when omitted.
+func (h *Handler) ResetSpendLimitStatus(c *gin.Context) {
+	spendlimit.Default().Reset(strings.TrimSpace(c.Query("auth_id")))
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
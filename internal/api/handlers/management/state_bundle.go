@@ -0,0 +1,287 @@
+package management
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/usage"
+	log "github.com/sirupsen/logrus"
+	"github.com/tidwall/gjson"
+)
+
+const stateBundleVersion = 1
+
+// stateBundle is the portable snapshot produced by ExportState and consumed by ImportState.
+type stateBundle struct {
+	Version     int                       `json:"version"`
+	ExportedAt  time.Time                 `json:"exported_at"`
+	ConfigYAML  string                    `json:"config_yaml,omitempty"`
+	Credentials []bundleCredential        `json:"credentials,omitempty"`
+	Usage       *usage.StatisticsSnapshot `json:"usage,omitempty"`
+}
+
+// bundleCredential references an auth file. Content is only present when the export was
+// requested with include_credentials=true.
+type bundleCredential struct {
+	Name     string          `json:"name"`
+	Provider string          `json:"provider,omitempty"`
+	Email    string          `json:"email,omitempty"`
+	Disabled bool            `json:"disabled,omitempty"`
+	Content  json.RawMessage `json:"content,omitempty"`
+}
+
+func queryBool(c *gin.Context, name string, def bool) bool {
+	raw := strings.TrimSpace(c.Query(name))
+	if raw == "" {
+		return def
+	}
+	v, err := strconv.ParseBool(raw)
+	if err != nil {
+		return def
+	}
+	return v
+}
+
+// ExportState downloads the full proxy state: the raw config file (mappings, API keys,
+// projects, limits), credential references, and the usage statistics snapshot.
+// Query parameters: include_credentials=true embeds auth file contents; usage=false omits usage.
+func (h *Handler) ExportState(c *gin.Context) {
+	bundle := stateBundle{Version: stateBundleVersion, ExportedAt: time.Now().UTC()}
+
+	data, err := os.ReadFile(h.configFilePath)
+	if err != nil && !os.IsNotExist(err) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "read_failed", "message": err.Error()})
+		return
+	}
+	bundle.ConfigYAML = string(data)
+
+	creds, err := h.bundleCredentials(queryBool(c, "include_credentials", false))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "read_failed", "message": err.Error()})
+		return
+	}
+	bundle.Credentials = creds
+
+	if queryBool(c, "usage", true) && h.usageStats != nil {
+		snapshot := h.usageStats.Snapshot()
+		bundle.Usage = &snapshot
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"cliproxy-state-%s.json\"", bundle.ExportedAt.Format("20060102-150405")))
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, bundle)
+}
+
+// bundleCredentials lists the auth files in the auth directory.
+func (h *Handler) bundleCredentials(includeContent bool) ([]bundleCredential, error) {
+	authDir := strings.TrimSpace(h.cfg.AuthDir)
+	if authDir == "" {
+		return nil, nil
+	}
+	entries, err := os.ReadDir(authDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	disabled := make(map[string]bool)
+	if h.authManager != nil {
+		for _, auth := range h.authManager.List() {
+			if auth != nil && auth.FileName != "" {
+				disabled[filepath.Base(auth.FileName)] = auth.Disabled
+			}
+		}
+	}
+	out := make([]bundleCredential, 0, len(entries))
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasSuffix(strings.ToLower(name), ".json") {
+			continue
+		}
+		raw, errRead := os.ReadFile(filepath.Join(authDir, name))
+		if errRead != nil {
+			return nil, errRead
+		}
+		cred := bundleCredential{
+			Name:     name,
+			Provider: gjson.GetBytes(raw, "type").String(),
+			Email:    gjson.GetBytes(raw, "email").String(),
+			Disabled: disabled[name],
+		}
+		if includeContent && json.Valid(raw) {
+			cred.Content = raw
+		}
+		out = append(out, cred)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out, nil
+}
+
+// ImportState applies a bundle produced by ExportState. The config file is replaced (after
+// validation), embedded credentials are written to the current auth directory, and usage is merged.
+// Every section is validated before anything is applied, and a failure while writing restores
+// the config file and credentials as they were, so an import never leaves mixed state.
+// Query parameters: overwrite_credentials=true replaces existing auth files of the same name.
+func (h *Handler) ImportState(c *gin.Context) {
+	data, err := c.GetRawData()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "failed to read request body"})
+		return
+	}
+	var bundle stateBundle
+	if err = json.Unmarshal(data, &bundle); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid json"})
+		return
+	}
+	if bundle.Version != stateBundleVersion {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "unsupported version"})
+		return
+	}
+
+	replaceConfig := strings.TrimSpace(bundle.ConfigYAML) != ""
+	if replaceConfig {
+		if _, status, failure := h.parseConfigYAML([]byte(bundle.ConfigYAML)); failure != nil {
+			c.JSON(status, failure)
+			return
+		}
+	}
+	// Credentials land in this instance's auth directory, not the one named by the imported config.
+	plan, errPlan := planCredentialImport(strings.TrimSpace(h.cfg.AuthDir), bundle.Credentials, queryBool(c, "overwrite_credentials", false))
+	if errPlan != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": errPlan.Error()})
+		return
+	}
+
+	result := gin.H{}
+	previousConfig, errRead := os.ReadFile(h.configFilePath)
+	if errRead != nil && !os.IsNotExist(errRead) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "read_failed", "message": errRead.Error()})
+		return
+	}
+	if replaceConfig {
+		if status, failure := h.replaceConfigYAML([]byte(bundle.ConfigYAML)); failure != nil {
+			c.JSON(status, failure)
+			return
+		}
+		result["config"] = "replaced"
+	}
+	var applied []credentialWrite
+	for _, write := range plan.writes {
+		errWrite := os.WriteFile(write.path, write.content, 0o600)
+		if errWrite == nil {
+			applied = append(applied, write)
+			errWrite = h.registerAuthFromFile(c.Request.Context(), write.path, write.content)
+		}
+		if errWrite != nil {
+			h.rollbackImport(c.Request.Context(), applied, replaceConfig, previousConfig)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to import %s: %v; the previous state was restored", write.name, errWrite)})
+			return
+		}
+	}
+	written := make([]string, 0, len(plan.writes))
+	for _, write := range plan.writes {
+		written = append(written, write.name)
+	}
+	result["credentials_written"] = written
+	result["credentials_skipped"] = nonNilStrings(plan.skipped)
+	result["credentials_missing"] = nonNilStrings(plan.missing)
+
+	if bundle.Usage != nil && h.usageStats != nil {
+		merge := h.usageStats.MergeSnapshot(*bundle.Usage)
+		result["usage_added"] = merge.Added
+		result["usage_skipped"] = merge.Skipped
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// credentialWrite is an auth file an import writes, with what it replaces.
+type credentialWrite struct {
+	name     string
+	path     string
+	content  []byte
+	previous []byte
+	existed  bool
+}
+
+type credentialImportPlan struct {
+	writes  []credentialWrite
+	skipped []string
+	missing []string
+}
+
+// planCredentialImport decides which bundled credentials are written, rejecting the bundle
+// when one of them is not a JSON object.
+func planCredentialImport(authDir string, creds []bundleCredential, overwrite bool) (credentialImportPlan, error) {
+	var plan credentialImportPlan
+	for _, cred := range creds {
+		name := filepath.Base(strings.TrimSpace(cred.Name))
+		if name == "" || name != strings.TrimSpace(cred.Name) || !strings.HasSuffix(strings.ToLower(name), ".json") {
+			plan.skipped = append(plan.skipped, cred.Name)
+			continue
+		}
+		if authDir == "" {
+			plan.skipped = append(plan.skipped, name)
+			continue
+		}
+		dst := filepath.Join(authDir, name)
+		previous, errRead := os.ReadFile(dst)
+		exists := errRead == nil
+		if len(cred.Content) == 0 {
+			if !exists {
+				plan.missing = append(plan.missing, name)
+			}
+			continue
+		}
+		if exists && !overwrite {
+			plan.skipped = append(plan.skipped, name)
+			continue
+		}
+		var metadata map[string]any
+		if err := json.Unmarshal(cred.Content, &metadata); err != nil {
+			return credentialImportPlan{}, fmt.Errorf("invalid credential %s: %v", name, err)
+		}
+		plan.writes = append(plan.writes, credentialWrite{name: name, path: dst, content: cred.Content, previous: previous, existed: exists})
+	}
+	return plan, nil
+}
+
+// rollbackImport restores the credentials and config file an import already replaced.
+func (h *Handler) rollbackImport(ctx context.Context, applied []credentialWrite, configReplaced bool, previousConfig []byte) {
+	for i := len(applied) - 1; i >= 0; i-- {
+		write := applied[i]
+		if !write.existed {
+			_ = os.Remove(write.path)
+			h.disableAuth(ctx, write.path)
+			continue
+		}
+		if err := os.WriteFile(write.path, write.previous, 0o600); err != nil {
+			log.Errorf("state import: failed to restore %s: %v", write.name, err)
+			continue
+		}
+		if err := h.registerAuthFromFile(ctx, write.path, write.previous); err != nil {
+			log.Errorf("state import: failed to re-register %s: %v", write.name, err)
+		}
+	}
+	if configReplaced && previousConfig != nil {
+		if _, failure := h.replaceConfigYAML(previousConfig); failure != nil {
+			log.Errorf("state import: failed to restore config: %v", failure)
+		}
+	}
+}
+
+func nonNilStrings(values []string) []string {
+	if values == nil {
+		return []string{}
+	}
+	return values
+}
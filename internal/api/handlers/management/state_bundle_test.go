@@ -0,0 +1,100 @@
+package management
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+func TestStateBundle_ExportImportRoundTrip(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	newInstance := func(configYAML string) (*Handler, string) {
+		dir := t.TempDir()
+		authDir := filepath.Join(dir, "auths")
+		if err := os.MkdirAll(authDir, 0o700); err != nil {
+			t.Fatalf("mkdir: %v", err)
+		}
+		cfgPath := filepath.Join(dir, "config.yaml")
+		if err := os.WriteFile(cfgPath, []byte(configYAML), 0o600); err != nil {
+			t.Fatalf("write config: %v", err)
+		}
+		h := &Handler{cfg: &config.Config{AuthDir: authDir}, configFilePath: cfgPath}
+		return h, authDir
+	}
+
+	src, srcAuth := newInstance("port: 8317\napi-keys:\n  - \"k1\"\n")
+	if err := os.WriteFile(filepath.Join(srcAuth, "claude-a.json"), []byte(`{"type":"claude","email":"a@example.com"}`), 0o600); err != nil {
+		t.Fatalf("write auth: %v", err)
+	}
+
+	exportWith := func(query string) stateBundle {
+		rec := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(rec)
+		c.Request = httptest.NewRequest(http.MethodGet, "/v0/management/state/export"+query, nil)
+		src.ExportState(c)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("export status %d: %s", rec.Code, rec.Body.String())
+		}
+		var bundle stateBundle
+		if err := json.Unmarshal(rec.Body.Bytes(), &bundle); err != nil {
+			t.Fatalf("decode bundle: %v", err)
+		}
+		return bundle
+	}
+
+	refsOnly := exportWith("")
+	if len(refsOnly.Credentials) != 1 || refsOnly.Credentials[0].Provider != "claude" || len(refsOnly.Credentials[0].Content) != 0 {
+		t.Fatalf("expected credential reference without content, got %+v", refsOnly.Credentials)
+	}
+
+	full := exportWith("?include_credentials=true")
+	payload, _ := json.Marshal(full)
+
+	dst, dstAuth := newInstance("port: 9000\n")
+	rec := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(rec)
+	c.Request = httptest.NewRequest(http.MethodPost, "/v0/management/state/import", strings.NewReader(string(payload)))
+	dst.ImportState(c)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("import status %d: %s", rec.Code, rec.Body.String())
+	}
+	if dst.cfg == nil || len(dst.cfg.APIKeys) != 1 || dst.cfg.APIKeys[0] != "k1" {
+		t.Fatalf("expected imported config to be loaded, got %+v", dst.cfg)
+	}
+	if _, err := os.Stat(filepath.Join(dstAuth, "claude-a.json")); err != nil {
+		t.Fatalf("expected credential to be written to the local auth dir: %v", err)
+	}
+}
+
+func TestStateBundle_ImportValidatesBeforeApplying(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	dir := t.TempDir()
+	cfgPath := filepath.Join(dir, "config.yaml")
+	if err := os.WriteFile(cfgPath, []byte("port: 9000\n"), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	h := &Handler{cfg: &config.Config{AuthDir: dir}, configFilePath: cfgPath}
+	payload := `{"version":1,"config_yaml":"port: 8317\n","credentials":[{"name":"a.json","content":{"type":"claude"}},{"name":"b.json","content":[1]}]}`
+
+	rec := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(rec)
+	c.Request = httptest.NewRequest(http.MethodPost, "/v0/management/state/import", strings.NewReader(payload))
+	h.ImportState(c)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("import status %d: %s", rec.Code, rec.Body.String())
+	}
+	if data, _ := os.ReadFile(cfgPath); string(data) != "port: 9000\n" {
+		t.Fatalf("config changed by rejected import: %q", data)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "a.json")); !os.IsNotExist(err) {
+		t.Fatalf("credential written by rejected import: %v", err)
+	}
+}
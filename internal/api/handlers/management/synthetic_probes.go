@@ -0,0 +1,21 @@
+package management
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/probes"
+)
+
+// GetSyntheticProbes returns the latest probe results per credential and model.
+func (h *Handler) GetSyntheticProbes(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"enabled": probes.Default().Enabled(),
+		"results": probes.Default().Snapshot(),
+	})
+}
+
+// RunSyntheticProbes starts every probe target now instead of waiting for its schedule.
+func (h *Handler) RunSyntheticProbes(c *gin.Context) {
+	c.JSON(http.StatusAccepted, gin.H{"started": probes.Default().RunNow()})
+}
@@ -0,0 +1,30 @@
+package management
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/tempkey"
+)
+
+// ListTemporaryKeys returns the live temporary keys of every API key, without their
+// secrets.
+func (h *Handler) ListTemporaryKeys(c *gin.Context) {
+	q, ok := parseListQuery(c)
+	if !ok {
+		return
+	}
+	keys := filterItems(tempkey.Default().List(""), func(g tempkey.Grant) bool { return q.matches(g.ID, g.Label, g.Parent) })
+	page, meta := paginate(keys, q)
+	c.JSON(http.StatusOK, gin.H{"temporary-keys": page, "pagination": meta})
+}
+
+// DeleteTemporaryKey revokes the temporary key given by the id parameter.
+func (h *Handler) DeleteTemporaryKey(c *gin.Context) {
+	id := c.Param("id")
+	if !tempkey.Default().Revoke(id, "") {
+		c.JSON(http.StatusNotFound, gin.H{"error": "temporary key not found"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
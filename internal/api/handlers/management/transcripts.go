@@ -0,0 +1,168 @@
+package management
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/transcript"
+)
+
+// ListTranscriptSessions returns the stored transcript sessions ordered by last activity.
+// ?project= restricts the list to sessions recorded for one project, ?q= searches the
+// session ids and ?offset=/?limit= select a page.
+func (h *Handler) ListTranscriptSessions(c *gin.Context) {
+	q, ok := parseListQuery(c)
+	if !ok {
+		return
+	}
+	store := h.transcriptStore()
+	sessions, err := store.Sessions()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list transcripts"})
+		return
+	}
+	if projectName := strings.TrimSpace(c.Query("project")); projectName != "" {
+		filtered := make([]transcript.SessionInfo, 0, len(sessions))
+		for _, session := range sessions {
+			if transcript.InProject(session.ID, projectName) {
+				filtered = append(filtered, session)
+			}
+		}
+		sessions = filtered
+	}
+	sessions = filterItems(sessions, func(session transcript.SessionInfo) bool { return q.matches(session.ID) })
+	page, meta := paginate(sessions, q)
+	c.JSON(http.StatusOK, gin.H{
+		"enabled":    store.Enabled(),
+		"sessions":   page,
+		"pagination": meta,
+	})
+}
+
+// GetTranscriptSession returns every stored exchange of a session as JSON.
+func (h *Handler) GetTranscriptSession(c *gin.Context) {
+	sessionID := c.Param("id")
+	entries, err := h.transcriptStore().Entries(sessionID)
+	if err != nil {
+		writeTranscriptError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"session": sessionID, "entries": entries})
+}
+
+// ExportTranscriptSession downloads a session as JSONL (default) or markdown (?format=markdown).
+func (h *Handler) ExportTranscriptSession(c *gin.Context) {
+	sessionID := transcript.NormalizeSessionID(c.Param("id"))
+	entries, err := h.transcriptStore().Entries(sessionID)
+	if err != nil {
+		writeTranscriptError(c, err)
+		return
+	}
+
+	format := strings.ToLower(strings.TrimSpace(c.DefaultQuery("format", "jsonl")))
+	switch format {
+	case "jsonl", "ndjson":
+		c.Header("Content-Disposition", "attachment; filename=\""+sessionID+".jsonl\"")
+		c.Header("Content-Type", "application/x-ndjson")
+		c.Status(http.StatusOK)
+		_ = transcript.WriteJSONL(c.Writer, entries)
+	case "markdown", "md":
+		c.Header("Content-Disposition", "attachment; filename=\""+sessionID+".md\"")
+		c.Header("Content-Type", "text/markdown; charset=utf-8")
+		c.Status(http.StatusOK)
+		_ = transcript.WriteMarkdown(c.Writer, sessionID, entries)
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "unsupported format, use jsonl or markdown"})
+	}
+}
+
+// SearchTranscripts performs a full-text search over stored transcripts.
+// Query parameters: q (text), model, session, project, since/until (RFC3339 or unix seconds), failed=true, limit.
+func (h *Handler) SearchTranscripts(c *gin.Context) {
+	query := transcript.Query{
+		Text:      c.Query("q"),
+		Model:     c.Query("model"),
+		SessionID: c.Query("session"),
+		Project:   c.Query("project"),
+	}
+	var err error
+	if query.Since, err = parseTranscriptTime(c.Query("since")); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid since"})
+		return
+	}
+	if query.Until, err = parseTranscriptTime(c.Query("until")); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid until"})
+		return
+	}
+	if raw := strings.TrimSpace(c.Query("failed")); raw != "" {
+		if query.FailedOnly, err = strconv.ParseBool(raw); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid failed"})
+			return
+		}
+	}
+	if raw := strings.TrimSpace(c.Query("limit")); raw != "" {
+		if query.Limit, err = strconv.Atoi(raw); err != nil || query.Limit < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid limit"})
+			return
+		}
+	}
+
+	matches, more, err := h.transcriptStore().Search(query)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to search transcripts"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"matches": matches, "truncated": more})
+}
+
+// DeleteTranscriptSession removes a stored session.
+func (h *Handler) DeleteTranscriptSession(c *gin.Context) {
+	if err := h.transcriptStore().Delete(c.Param("id")); err != nil {
+		writeTranscriptError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// PruneTranscripts applies the configured retention policy immediately.
+func (h *Handler) PruneTranscripts(c *gin.Context) {
+	removed, err := h.transcriptStore().Prune()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to prune transcripts"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"removed": removed})
+}
+
+func (h *Handler) transcriptStore() *transcript.Store {
+	if h != nil && h.transcripts != nil {
+		return h.transcripts
+	}
+	return transcript.GetStore()
+}
+
+func writeTranscriptError(c *gin.Context, err error) {
+	if errors.Is(err, transcript.ErrSessionNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "session not found"})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to read transcript"})
+}
+
+func parseTranscriptTime(raw string) (time.Time, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return time.Time{}, nil
+	}
+	if unix, err := strconv.ParseInt(raw, 10, 64); err == nil {
+		return time.Unix(unix, 0), nil
+	}
+	if t, err := time.Parse(time.RFC3339, raw); err == nil {
+		return t, nil
+	}
+	return time.Parse("2006-01-02", raw)
+}
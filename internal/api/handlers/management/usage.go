@@ -3,6 +3,7 @@ package management
 import (
 	"encoding/json"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -77,3 +78,52 @@ func (h *Handler) ImportUsageStatistics(c *gin.Context) {
 		"failed_requests": snapshot.FailureCount,
 	})
 }
+
+// ExportUsageRecords returns the raw request records as a download for warehouse ingestion.
+// ?format= selects csv (default), parquet or json; ?from= and ?to= bound the request time
+// (RFC3339, unix seconds or YYYY-MM-DD, where a date-only ?to= includes that whole day).
+func (h *Handler) ExportUsageRecords(c *gin.Context) {
+	from, err := parseTranscriptTime(c.Query("from"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid from"})
+		return
+	}
+	rawTo := strings.TrimSpace(c.Query("to"))
+	to, err := parseTranscriptTime(rawTo)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid to"})
+		return
+	}
+	if len(rawTo) == len("2006-01-02") {
+		to = to.AddDate(0, 0, 1)
+	}
+	if !from.IsZero() && !to.IsZero() && !to.After(from) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "to must be after from"})
+		return
+	}
+
+	var records []usage.UsageRecord
+	if h != nil && h.usageStats != nil {
+		records = h.usageStats.Records(from, to)
+	}
+	filename := "usage-records-" + time.Now().UTC().Format("20060102T150405Z")
+	switch format := strings.ToLower(strings.TrimSpace(c.DefaultQuery("format", "csv"))); format {
+	case "csv":
+		c.Header("Content-Disposition", "attachment; filename=\""+filename+".csv\"")
+		c.Header("Content-Type", "text/csv; charset=utf-8")
+		c.Status(http.StatusOK)
+		_ = usage.WriteRecordsCSV(c.Writer, records)
+	case "parquet":
+		c.Header("Content-Disposition", "attachment; filename=\""+filename+".parquet\"")
+		c.Header("Content-Type", "application/vnd.apache.parquet")
+		c.Status(http.StatusOK)
+		_ = usage.WriteRecordsParquet(c.Writer, records)
+	case "json":
+		if records == nil {
+			records = []usage.UsageRecord{}
+		}
+		c.JSON(http.StatusOK, gin.H{"records": records})
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "unsupported format, use csv, parquet or json"})
+	}
+}
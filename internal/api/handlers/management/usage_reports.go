@@ -0,0 +1,57 @@
+package management
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/usagereport"
+)
+
+// ListUsageReports lists the stored monthly usage reports, newest first.
+func (h *Handler) ListUsageReports(c *gin.Context) {
+	reporter := usagereport.Default()
+	reports, err := reporter.List()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"enabled": reporter.Enabled(),
+		"current": reporter.Current().Month,
+		"reports": reports,
+	})
+}
+
+// GetUsageReport returns the report of :month (YYYY-MM), or of the running month when
+// :month is "current". ?format= selects json (default), csv or html.
+func (h *Handler) GetUsageReport(c *gin.Context) {
+	month := strings.TrimSpace(c.Param("month"))
+	format := strings.ToLower(strings.TrimSpace(c.DefaultQuery("format", "json")))
+	reporter := usagereport.Default()
+
+	var report *usagereport.Report
+	if month == "current" {
+		report = reporter.Current()
+	} else {
+		var err error
+		if report, err = reporter.Load(month); err != nil {
+			if errors.Is(err, usagereport.ErrReportNotFound) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "usage report not found"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	data, err := usagereport.Render(report, format)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "unsupported format, use json, csv or html"})
+		return
+	}
+	if format != "json" {
+		c.Header("Content-Disposition", "attachment; filename=\"usage-"+report.Month+"."+format+"\"")
+	}
+	c.Data(http.StatusOK, usagereport.ContentType(format), data)
+}
@@ -0,0 +1,193 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/maintenance"
+	sdkAuth "github.com/router-for-me/CLIProxyAPI/v6/sdk/auth"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
+)
+
+const healthCheckTimeout = 2 * time.Second
+
+// storeCheckTTL is how long the result of listing a token store that cannot be pinged is
+// reused, so frequent probes do not list every record each time.
+const storeCheckTTL = 30 * time.Second
+
+// healthCheck is the outcome of a single readiness dependency.
+type healthCheck struct {
+	OK      bool   `json:"ok"`
+	Message string `json:"message,omitempty"`
+	Healthy int    `json:"healthy,omitempty"`
+	Total   int    `json:"total,omitempty"`
+}
+
+// storeCheckCache holds the last store check of a token store without Ping.
+type storeCheckCache struct {
+	mu    sync.Mutex
+	store coreauth.Store
+	at    time.Time
+	check healthCheck
+}
+
+// registerHealthRoutes exposes unauthenticated probes for orchestrators such as Kubernetes:
+// /healthz (liveness), /readyz (readiness) and /startupz (startup).
+func (s *Server) registerHealthRoutes() {
+	s.engine.GET("/healthz", s.handleHealthz)
+	s.engine.GET("/readyz", s.handleReadyz)
+	s.engine.GET("/startupz", s.handleStartupz)
+}
+
+// handleHealthz reports liveness: the process is serving HTTP.
+func (s *Server) handleHealthz(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// handleReadyz reports whether the proxy can serve traffic: the config file parses, at
+// least one provider credential is usable, and the auth store answers. The probe is
+// unauthenticated, so failures carry a generic message and their details are logged.
+func (s *Server) handleReadyz(c *gin.Context) {
+	ready, checks := s.readiness(c.Request.Context())
+	status := http.StatusOK
+	state := "ready"
+	if !ready {
+		status = http.StatusServiceUnavailable
+		state = "not_ready"
+	}
+	c.JSON(status, gin.H{"status": state, "checks": checks})
+}
+
+// handleStartupz succeeds once the proxy has been ready at least once and keeps succeeding
+// afterwards, so a startup probe can hold off liveness checks during slow credential loading
+// without restarting the pod later when providers are temporarily exhausted.
+func (s *Server) handleStartupz(c *gin.Context) {
+	if s.started.Load() {
+		c.JSON(http.StatusOK, gin.H{"status": "started"})
+		return
+	}
+	ready, checks := s.readiness(c.Request.Context())
+	if !ready {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"status": "starting", "checks": checks})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "started"})
+}
+
+func (s *Server) readiness(ctx context.Context) (bool, map[string]healthCheck) {
+	checks := map[string]healthCheck{
+		"config":      s.checkConfig(),
+		"providers":   s.checkProviders(),
+		"store":       s.checkStore(ctx),
+		"maintenance": checkMaintenance(),
+	}
+	ready := true
+	for _, check := range checks {
+		ready = ready && check.OK
+	}
+	if ready {
+		s.started.Store(true)
+	}
+	return ready, checks
+}
+
+// checkConfig verifies that a configuration is loaded and that the file on disk still parses,
+// so a broken edit is surfaced before the next reload silently keeps the old settings.
+func (s *Server) checkConfig() healthCheck {
+	if s.cfg == nil {
+		return healthCheck{Message: "no configuration loaded"}
+	}
+	if s.configFilePath == "" {
+		return healthCheck{OK: true}
+	}
+	data, err := os.ReadFile(s.configFilePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return healthCheck{OK: true, Message: "config file not found; serving loaded configuration"}
+		}
+		log.Warnf("readiness: read config file: %v", err)
+		return healthCheck{Message: "config file unreadable"}
+	}
+	var parsed config.Config
+	if err = yaml.Unmarshal(data, &parsed); err != nil {
+		log.Warnf("readiness: parse config file: %v", err)
+		return healthCheck{Message: "config file does not parse"}
+	}
+	return healthCheck{OK: true}
+}
+
+// checkProviders counts credentials that are enabled and not cooling down.
+func (s *Server) checkProviders() healthCheck {
+	if s.handlers == nil || s.handlers.AuthManager == nil {
+		return healthCheck{Message: "auth manager not initialized"}
+	}
+	now := time.Now()
+	check := healthCheck{}
+	for _, auth := range s.handlers.AuthManager.List() {
+		check.Total++
+		if usableAuth(auth, now) {
+			check.Healthy++
+		}
+	}
+	check.OK = check.Healthy > 0
+	if !check.OK {
+		check.Message = "no usable provider credentials"
+	}
+	return check
+}
+
+func usableAuth(auth *coreauth.Auth, now time.Time) bool {
+	if auth == nil || auth.Disabled || auth.Status == coreauth.StatusDisabled {
+		return false
+	}
+	return !auth.Unavailable || !auth.NextRetryAfter.After(now)
+}
+
+// checkMaintenance fails during proxy-wide maintenance so load balancers stop routing
+// new traffic to the instance while in-flight requests finish.
+func checkMaintenance() healthCheck {
+	if mode, active := maintenance.Default().Proxy(); active {
+		return healthCheck{Message: mode.Message}
+	}
+	return healthCheck{OK: true}
+}
+
+// checkStore probes the token store backing credentials and management edits. Stores that
+// implement Ping are pinged; others are asked to list their records, at most once per
+// storeCheckTTL.
+func (s *Server) checkStore(ctx context.Context) healthCheck {
+	store := sdkAuth.GetTokenStore()
+	if store == nil {
+		return healthCheck{Message: "token store not configured"}
+	}
+	ctx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+	defer cancel()
+	if pinger, ok := store.(interface{ Ping(context.Context) error }); ok {
+		return storeCheckResult(pinger.Ping(ctx))
+	}
+
+	cache := &s.storeCheck
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	if cache.store == store && time.Since(cache.at) < storeCheckTTL {
+		return cache.check
+	}
+	_, err := store.List(ctx)
+	cache.store, cache.at, cache.check = store, time.Now(), storeCheckResult(err)
+	return cache.check
+}
+
+func storeCheckResult(err error) healthCheck {
+	if err != nil {
+		log.Warnf("readiness: token store check failed: %v", err)
+		return healthCheck{Message: "token store unavailable"}
+	}
+	return healthCheck{OK: true}
+}
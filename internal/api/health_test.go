@@ -0,0 +1,104 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	sdkAuth "github.com/router-for-me/CLIProxyAPI/v6/sdk/auth"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+)
+
+func probe(t *testing.T, server *Server, path string) (int, map[string]any) {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	rr := httptest.NewRecorder()
+	server.engine.ServeHTTP(rr, req)
+	var body map[string]any
+	if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+		t.Fatalf("invalid json from %s: %v", path, err)
+	}
+	return rr.Code, body
+}
+
+func TestHealthProbes(t *testing.T) {
+	server := newTestServer(t)
+	if setter, ok := sdkAuth.GetTokenStore().(interface{ SetBaseDir(string) }); ok {
+		setter.SetBaseDir(server.cfg.AuthDir)
+	}
+
+	if code, _ := probe(t, server, "/healthz"); code != http.StatusOK {
+		t.Fatalf("healthz: expected 200, got %d", code)
+	}
+
+	code, body := probe(t, server, "/readyz")
+	if code != http.StatusServiceUnavailable {
+		t.Fatalf("readyz without credentials: expected 503, got %d", code)
+	}
+	checks, _ := body["checks"].(map[string]any)
+	if providers, _ := checks["providers"].(map[string]any); providers["ok"] != false {
+		t.Fatalf("expected providers check to fail, got %v", checks)
+	}
+	if code, _ = probe(t, server, "/startupz"); code != http.StatusServiceUnavailable {
+		t.Fatalf("startupz before ready: expected 503, got %d", code)
+	}
+
+	if _, err := server.handlers.AuthManager.Register(context.Background(), &auth.Auth{ID: "claude-1", Provider: "claude"}); err != nil {
+		t.Fatalf("register auth: %v", err)
+	}
+	if code, body = probe(t, server, "/readyz"); code != http.StatusOK {
+		t.Fatalf("readyz with a credential: expected 200, got %d (%v)", code, body)
+	}
+
+	if err := os.WriteFile(server.configFilePath, []byte("port: [unterminated"), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	code, body = probe(t, server, "/readyz")
+	if code != http.StatusServiceUnavailable {
+		t.Fatalf("readyz with broken config: expected 503, got %d", code)
+	}
+	checks, _ = body["checks"].(map[string]any)
+	if cfgCheck, _ := checks["config"].(map[string]any); cfgCheck["message"] != "config file does not parse" {
+		t.Fatalf("readyz should not expose the parse error, got %v", cfgCheck)
+	}
+	if code, _ = probe(t, server, "/startupz"); code != http.StatusOK {
+		t.Fatalf("startupz after first ready: expected 200, got %d", code)
+	}
+}
+
+// listingStore is a token store without Ping that counts its listings.
+type listingStore struct {
+	lists int
+	err   error
+}
+
+func (s *listingStore) List(context.Context) ([]*auth.Auth, error) {
+	s.lists++
+	return nil, s.err
+}
+
+func (s *listingStore) Save(context.Context, *auth.Auth) (string, error) { return "", nil }
+
+func (s *listingStore) Delete(context.Context, string) error { return nil }
+
+func TestCheckStoreCachesListingAndHidesErrors(t *testing.T) {
+	previous := sdkAuth.GetTokenStore()
+	defer sdkAuth.RegisterTokenStore(previous)
+	store := &listingStore{err: errors.New("dial tcp 10.0.0.7:5432: connection refused")}
+	sdkAuth.RegisterTokenStore(store)
+
+	server := &Server{}
+	for i := 0; i < 3; i++ {
+		check := server.checkStore(context.Background())
+		if check.OK || check.Message != "token store unavailable" {
+			t.Fatalf("check = %+v, want a generic failure", check)
+		}
+	}
+	if store.lists != 1 {
+		t.Fatalf("store listed %d times, want 1", store.lists)
+	}
+}
@@ -0,0 +1,221 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/asyncjob"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/vhost"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers"
+	"github.com/tidwall/gjson"
+)
+
+// messageBatchIDPrefix starts the ids of message batches, as in Anthropic's API.
+const messageBatchIDPrefix = "msgbatch_"
+
+// messageBatchJSON renders a batch in the shape of Anthropic's message_batch object.
+func messageBatchJSON(c *gin.Context, b asyncjob.Batch) gin.H {
+	out := gin.H{
+		"id":                b.ID,
+		"type":              "message_batch",
+		"processing_status": b.Status,
+		"request_counts": gin.H{
+			"processing": b.Counts.Processing,
+			"succeeded":  b.Counts.Succeeded,
+			"errored":    b.Counts.Errored,
+			"canceled":   b.Counts.Canceled,
+			"expired":    b.Counts.Expired,
+		},
+		"created_at":          b.CreatedAt.UTC().Format(time.RFC3339),
+		"expires_at":          b.ExpiresAt.UTC().Format(time.RFC3339),
+		"ended_at":            nil,
+		"cancel_initiated_at": nil,
+		"archived_at":         nil,
+		"results_url":         nil,
+	}
+	if b.EndedAt != nil {
+		out["ended_at"] = b.EndedAt.UTC().Format(time.RFC3339)
+		scheme := "http"
+		if c.Request.TLS != nil {
+			scheme = "https"
+		}
+		out["results_url"] = scheme + "://" + c.Request.Host + "/v1/messages/batches/" + b.ID + "/results"
+	}
+	if b.CancelInitiatedAt != nil {
+		out["cancel_initiated_at"] = b.CancelInitiatedAt.UTC().Format(time.RFC3339)
+	}
+	return out
+}
+
+func messageBatchError(c *gin.Context, status int, message string) {
+	c.Data(status, "application/json", handlers.BuildDialectErrorBody(handlers.DialectClaude, status, "", message))
+}
+
+func messageBatchLookupError(c *gin.Context, err error) {
+	if errors.Is(err, asyncjob.ErrNotFound) {
+		messageBatchError(c, http.StatusNotFound, "message batch "+c.Param("id")+" not found")
+		return
+	}
+	messageBatchError(c, http.StatusConflict, err.Error())
+}
+
+// messageBatchOwner scopes batches to the API key that created them.
+func messageBatchOwner(c *gin.Context) string {
+	return c.GetString("apiKey")
+}
+
+// createMessageBatch serves POST /v1/messages/batches. Each request's params are a
+// /v1/messages body; they run as jobs of the async job store through the engine with the
+// creating request's credentials at batch priority.
+func (s *Server) createMessageBatch(c *gin.Context) {
+	body, err := c.GetRawData()
+	if err != nil || !gjson.ValidBytes(body) {
+		messageBatchError(c, http.StatusBadRequest, "request body must be JSON")
+		return
+	}
+	var requests []asyncjob.BatchRequest
+	for i, item := range gjson.GetBytes(body, "requests").Array() {
+		params := item.Get("params")
+		if !params.IsObject() || params.Get("model").String() == "" {
+			messageBatchError(c, http.StatusBadRequest, "requests."+strconv.Itoa(i)+".params must be a messages request with a model")
+			return
+		}
+		if params.Get("stream").Bool() {
+			messageBatchError(c, http.StatusBadRequest, "requests."+strconv.Itoa(i)+".params.stream is not supported in batches")
+			return
+		}
+		requests = append(requests, asyncjob.BatchRequest{CustomID: item.Get("custom_id").String(), Body: []byte(params.Raw)})
+	}
+	if err = asyncjob.ValidateBatch(requests); err != nil {
+		messageBatchError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	header := make(http.Header)
+	for key, values := range c.Request.Header {
+		if !asyncJobSkipHeaders[http.CanonicalHeaderKey(key)] {
+			header[key] = append([]string(nil), values...)
+		}
+	}
+	if header.Get(handlers.PriorityHeader) == "" {
+		header.Set(handlers.PriorityHeader, "batch")
+	}
+	created, err := s.asyncJobs.SubmitBatch(messageBatchOwner(c), vhost.FromContext(c.Request.Context()), "claude", messageBatchIDPrefix, requests, header)
+	if errors.Is(err, asyncjob.ErrTooManyBatches) {
+		messageBatchError(c, http.StatusTooManyRequests, err.Error())
+		return
+	}
+	if err != nil {
+		messageBatchError(c, http.StatusInternalServerError, "failed to persist message batch: "+err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, messageBatchJSON(c, created))
+}
+
+// listMessageBatches serves GET /v1/messages/batches, newest first, paged by limit,
+// before_id and after_id.
+func (s *Server) listMessageBatches(c *gin.Context) {
+	limit := 20
+	if raw := c.Query("limit"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed < 1 || parsed > 1000 {
+			messageBatchError(c, http.StatusBadRequest, "limit must be between 1 and 1000")
+			return
+		}
+		limit = parsed
+	}
+	all := s.asyncJobs.ListBatches(messageBatchOwner(c))
+	start, end := 0, len(all)
+	for i, b := range all {
+		if b.ID == c.Query("after_id") {
+			start = i + 1
+		}
+		if b.ID == c.Query("before_id") {
+			end = i
+		}
+	}
+	if start > end {
+		start = end
+	}
+	page := all[start:end]
+	hasMore := len(page) > limit
+	if hasMore {
+		page = page[:limit]
+	}
+	data := make([]gin.H, 0, len(page))
+	for _, b := range page {
+		data = append(data, messageBatchJSON(c, b))
+	}
+	out := gin.H{"data": data, "has_more": hasMore, "first_id": nil, "last_id": nil}
+	if len(page) > 0 {
+		out["first_id"], out["last_id"] = page[0].ID, page[len(page)-1].ID
+	}
+	c.JSON(http.StatusOK, out)
+}
+
+// getMessageBatch serves GET /v1/messages/batches/:id.
+func (s *Server) getMessageBatch(c *gin.Context) {
+	b, err := s.asyncJobs.GetBatch(messageBatchOwner(c), c.Param("id"))
+	if err != nil {
+		messageBatchLookupError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, messageBatchJSON(c, b))
+}
+
+// cancelMessageBatch serves POST /v1/messages/batches/:id/cancel.
+func (s *Server) cancelMessageBatch(c *gin.Context) {
+	b, err := s.asyncJobs.CancelBatch(messageBatchOwner(c), c.Param("id"))
+	if err != nil {
+		messageBatchLookupError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, messageBatchJSON(c, b))
+}
+
+// deleteMessageBatch serves DELETE /v1/messages/batches/:id for ended batches.
+func (s *Server) deleteMessageBatch(c *gin.Context) {
+	id := c.Param("id")
+	if err := s.asyncJobs.DeleteBatch(messageBatchOwner(c), id); err != nil {
+		messageBatchLookupError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"id": id, "type": "message_batch_deleted"})
+}
+
+// messageBatchResults serves GET /v1/messages/batches/:id/results as JSON Lines, one
+// result per request in the order of the batch.
+func (s *Server) messageBatchResults(c *gin.Context) {
+	results, err := s.asyncJobs.BatchResults(messageBatchOwner(c), c.Param("id"))
+	if err != nil {
+		messageBatchLookupError(c, err)
+		return
+	}
+	var buf bytes.Buffer
+	for _, r := range results {
+		result := gin.H{"type": r.Status}
+		switch r.Status {
+		case asyncjob.StatusSucceeded:
+			result["message"] = json.RawMessage(r.Result)
+		case asyncjob.StatusFailed:
+			result["type"] = "errored"
+			errBody := r.Result
+			if !gjson.ValidBytes(errBody) || len(bytes.TrimSpace(errBody)) == 0 {
+				status := r.ResultStatus
+				if status == 0 {
+					status = http.StatusInternalServerError
+				}
+				errBody = handlers.BuildDialectErrorBody(handlers.DialectClaude, status, "", r.Error)
+			}
+			result["error"] = json.RawMessage(errBody)
+		}
+		line, _ := json.Marshal(gin.H{"custom_id": r.CustomID, "result": result})
+		buf.Write(line)
+		buf.WriteByte('\n')
+	}
+	c.Data(http.StatusOK, "application/x-jsonl", buf.Bytes())
+}
@@ -0,0 +1,94 @@
+package api
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/asyncjob"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers"
+	"github.com/tidwall/gjson"
+)
+
+func TestMessageBatches(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	s := &Server{engine: gin.New()}
+	s.asyncJobs = asyncjob.NewStore(s.executeAsyncJob)
+	t.Cleanup(s.asyncJobs.Stop)
+	var priority string
+	s.engine.POST("/v1/messages", func(c *gin.Context) {
+		priority = c.GetHeader(handlers.PriorityHeader)
+		body, _ := c.GetRawData()
+		if gjson.GetBytes(body, "model").String() == "missing" {
+			c.JSON(http.StatusNotFound, gin.H{"type": "error", "error": gin.H{"type": "not_found_error", "message": "unknown model"}})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"type": "message", "model": gjson.GetBytes(body, "model").String()})
+	})
+	batches := s.engine.Group("/v1/messages/batches")
+	batches.POST("", s.createMessageBatch)
+	batches.GET("", s.listMessageBatches)
+	batches.GET("/:id", s.getMessageBatch)
+	batches.GET("/:id/results", s.messageBatchResults)
+	batches.DELETE("/:id", s.deleteMessageBatch)
+
+	serve := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, bytes.NewReader([]byte(body)))
+		w := httptest.NewRecorder()
+		s.engine.ServeHTTP(w, req)
+		return w
+	}
+
+	w := serve(http.MethodPost, "/v1/messages/batches", `{"requests":[{"custom_id":"x","params":{"model":"m","stream":true}}]}`)
+	if w.Code != http.StatusBadRequest || gjson.Get(w.Body.String(), "error.type").String() != "invalid_request_error" {
+		t.Fatalf("streaming params = %d %s", w.Code, w.Body.String())
+	}
+
+	w = serve(http.MethodPost, "/v1/messages/batches", `{"requests":[{"custom_id":"ok","params":{"model":"claude-test","max_tokens":8,"messages":[]}},{"custom_id":"bad","params":{"model":"missing"}}]}`)
+	id := gjson.Get(w.Body.String(), "id").String()
+	if w.Code != http.StatusOK || !strings.HasPrefix(id, messageBatchIDPrefix) || gjson.Get(w.Body.String(), "type").String() != "message_batch" {
+		t.Fatalf("create = %d %s", w.Code, w.Body.String())
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for gjson.Get(w.Body.String(), "processing_status").String() != asyncjob.BatchEnded {
+		if time.Now().After(deadline) {
+			t.Fatalf("batch did not end: %s", w.Body.String())
+		}
+		time.Sleep(5 * time.Millisecond)
+		w = serve(http.MethodGet, "/v1/messages/batches/"+id, "")
+	}
+	if got := gjson.Get(w.Body.String(), "results_url").String(); !strings.HasSuffix(got, "/v1/messages/batches/"+id+"/results") {
+		t.Fatalf("results_url = %q", got)
+	}
+	if priority != "batch" {
+		t.Fatalf("batch requests should run at batch priority, got %q", priority)
+	}
+
+	w = serve(http.MethodGet, "/v1/messages/batches/"+id+"/results", "")
+	lines := strings.Split(strings.TrimSpace(w.Body.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("results = %s", w.Body.String())
+	}
+	if gjson.Get(lines[0], "custom_id").String() != "ok" || gjson.Get(lines[0], "result.message.model").String() != "claude-test" {
+		t.Fatalf("succeeded line = %s", lines[0])
+	}
+	if gjson.Get(lines[1], "result.type").String() != "errored" || gjson.Get(lines[1], "result.error.error.type").String() != "not_found_error" {
+		t.Fatalf("errored line = %s", lines[1])
+	}
+
+	w = serve(http.MethodGet, "/v1/messages/batches", "")
+	if gjson.Get(w.Body.String(), "data.#").Int() != 1 || gjson.Get(w.Body.String(), "first_id").String() != id {
+		t.Fatalf("list = %s", w.Body.String())
+	}
+	if w = serve(http.MethodDelete, "/v1/messages/batches/"+id, ""); gjson.Get(w.Body.String(), "type").String() != "message_batch_deleted" {
+		t.Fatalf("delete = %s", w.Body.String())
+	}
+	if w = serve(http.MethodGet, "/v1/messages/batches/"+id, ""); w.Code != http.StatusNotFound {
+		t.Fatalf("deleted batch = %d", w.Code)
+	}
+}
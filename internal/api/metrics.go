@@ -0,0 +1,32 @@
+package api
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/metrics"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/slo"
+)
+
+// handleMetrics serves the usage counters and the SLO gauges in the Prometheus text format
+// when metrics.enable is set, guarded by metrics.token when configured.
+func (s *Server) handleMetrics(c *gin.Context) {
+	collector := metrics.Default()
+	if !collector.Enabled() {
+		c.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+	if token := collector.Token(); token != "" {
+		provided := strings.TrimSpace(strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer "))
+		if subtle.ConstantTimeCompare([]byte(provided), []byte(token)) != 1 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid metrics token"})
+			return
+		}
+	}
+	c.Header("Content-Type", metrics.ContentType)
+	c.Status(http.StatusOK)
+	_ = collector.WritePrometheus(c.Writer)
+	_ = slo.Default().WritePrometheus(c.Writer)
+}
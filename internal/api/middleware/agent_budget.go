@@ -0,0 +1,76 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/agentbudget"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	log "github.com/sirupsen/logrus"
+)
+
+// ApplyAgentBudget counts the request as a step of its session's agent loop and, once the
+// loop's budget is exhausted, applies the action of the matching rule. It returns false
+// after aborting a blocked request.
+func ApplyAgentBudget(c *gin.Context, tracker *agentbudget.Tracker, apiKey string) bool {
+	if tracker == nil || !tracker.Enabled() || c.Request.Method != http.MethodPost {
+		return true
+	}
+	model := requestModel(c)
+	if model == "" {
+		return true
+	}
+	decision, ok := tracker.Begin(agentbudget.Request{
+		APIKey:  apiKey,
+		Session: strings.TrimSpace(c.GetHeader(tracker.SessionHeader())),
+		Model:   model,
+	})
+	if !ok {
+		return true
+	}
+	c.Set(agentbudget.SessionContextKey, decision.Key)
+	rule := decision.Rule
+	c.Header(agentbudget.Header, budgetHeader(decision))
+	if !decision.Exceeded {
+		return true
+	}
+
+	switch rule.Action {
+	case config.AgentBudgetActionBlock:
+		log.Debugf("agent budget %s: blocked step %d (%d tokens)", rule.Name, decision.Steps, decision.Tokens)
+		c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": gin.H{
+			"message": fmt.Sprintf("agent loop budget %s exhausted after %d steps and %d tokens; pause before continuing", rule.Name, decision.Steps-1, decision.Tokens),
+			"type":    "rate_limit_error",
+			"code":    agentbudget.ErrorCode,
+		}})
+		return false
+	case config.AgentBudgetActionDegrade:
+		rewriteRequestModel(c, func(string) string { return rule.DegradeModel })
+		log.Debugf("agent budget %s: model %s -> %s", rule.Name, model, rule.DegradeModel)
+	default:
+		log.Debugf("agent budget %s: step %d over budget (%d tokens)", rule.Name, decision.Steps, decision.Tokens)
+	}
+	return true
+}
+
+// budgetHeader formats the loop's usage against its budget, e.g.
+// "rule=agents; steps=12/50; tokens=80412/200000; exceeded; action=degrade".
+func budgetHeader(d agentbudget.Decision) string {
+	parts := []string{"rule=" + d.Rule.Name}
+	if d.Rule.MaxSteps > 0 {
+		parts = append(parts, fmt.Sprintf("steps=%d/%d", d.Steps, d.Rule.MaxSteps))
+	} else {
+		parts = append(parts, fmt.Sprintf("steps=%d", d.Steps))
+	}
+	if d.Rule.MaxTokens > 0 {
+		parts = append(parts, fmt.Sprintf("tokens=%d/%d", d.Tokens, d.Rule.MaxTokens))
+	} else {
+		parts = append(parts, fmt.Sprintf("tokens=%d", d.Tokens))
+	}
+	if d.Exceeded {
+		parts = append(parts, "exceeded", "action="+d.Rule.Action)
+	}
+	return strings.Join(parts, "; ")
+}
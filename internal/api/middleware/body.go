@@ -0,0 +1,36 @@
+package middleware
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// peekBody reads at most limit bytes of the request body and puts them back in front of
+// the unread remainder, so later handlers still see the whole body while middleware never
+// buffers more than limit bytes. complete reports whether the prefix is the entire body.
+func peekBody(c *gin.Context, limit int) (prefix []byte, complete bool, err error) {
+	if c.Request.Body == nil || c.Request.Body == http.NoBody {
+		return nil, true, nil
+	}
+	original := c.Request.Body
+	prefix, err = io.ReadAll(io.LimitReader(original, int64(limit)+1))
+	complete = len(prefix) <= limit
+	if complete && err == nil {
+		c.Request.Body = io.NopCloser(bytes.NewReader(prefix))
+		return prefix, true, nil
+	}
+	c.Request.Body = replayBody{Reader: io.MultiReader(bytes.NewReader(prefix), original), Closer: original}
+	if len(prefix) > limit {
+		prefix = prefix[:limit]
+	}
+	return prefix, complete, err
+}
+
+// replayBody serves a peeked prefix followed by the rest of the original body.
+type replayBody struct {
+	io.Reader
+	io.Closer
+}
@@ -0,0 +1,34 @@
+package middleware
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestPeekBodyKeepsWholeBodyReadable(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	for _, tc := range []struct {
+		body         string
+		wantPrefix   string
+		wantComplete bool
+	}{
+		{body: "short", wantPrefix: "short", wantComplete: true},
+		{body: "0123456789abcdef", wantPrefix: "01234567", wantComplete: false},
+	} {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tc.body))
+		prefix, complete, err := peekBody(c, 8)
+		if err != nil || string(prefix) != tc.wantPrefix || complete != tc.wantComplete {
+			t.Fatalf("peekBody(%q) = %q, %v, %v", tc.body, prefix, complete, err)
+		}
+		rest, _ := io.ReadAll(c.Request.Body)
+		if string(rest) != tc.body {
+			t.Fatalf("body after peek = %q, want %q", rest, tc.body)
+		}
+	}
+}
@@ -0,0 +1,25 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/usage"
+	log "github.com/sirupsen/logrus"
+)
+
+// CancellationMiddleware counts requests whose client disconnected before the handler
+// finished. Handlers derive their upstream contexts from the request context, so the
+// disconnect has already aborted the upstream call by the time the handler returns.
+func CancellationMiddleware(stats *usage.RequestStatistics) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+		if c.Request == nil || !errors.Is(c.Request.Context().Err(), context.Canceled) {
+			return
+		}
+		route := c.FullPath()
+		log.Debugf("client cancelled %s %s", c.Request.Method, c.Request.URL.Path)
+		stats.RecordCancelled(route)
+	}
+}
@@ -0,0 +1,37 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/usage"
+)
+
+func TestCancellationMiddlewareCountsClientDisconnects(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	prev := usage.StatisticsEnabled()
+	usage.SetStatisticsEnabled(true)
+	t.Cleanup(func() { usage.SetStatisticsEnabled(prev) })
+
+	stats := usage.NewRequestStatistics()
+	engine := gin.New()
+	engine.Use(CancellationMiddleware(stats))
+	ctx, cancel := context.WithCancel(context.Background())
+	engine.POST("/v1/chat/completions", func(c *gin.Context) {
+		cancel()
+		<-c.Request.Context().Done()
+	})
+	engine.POST("/v1/messages", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil).WithContext(ctx)
+	engine.ServeHTTP(httptest.NewRecorder(), req)
+	engine.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/v1/messages", nil))
+
+	snapshot := stats.Snapshot()
+	if snapshot.CancelledCount != 1 || snapshot.CancelledByRoute["/v1/chat/completions"] != 1 {
+		t.Fatalf("cancelled = %d %v, want one for /v1/chat/completions", snapshot.CancelledCount, snapshot.CancelledByRoute)
+	}
+}
@@ -0,0 +1,98 @@
+package middleware
+
+import (
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/connections"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/ipaccess"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+)
+
+// untrackedPrefixes are the routes that never reach a provider.
+var untrackedPrefixes = []string{"/v0/management", "/management.html", "/healthz", "/readyz", "/startupz", "/metrics", "/keep-alive", "/version"}
+
+// proxiedPath reports whether path may reach a provider.
+func proxiedPath(path string) bool {
+	for _, prefix := range untrackedPrefixes {
+		if strings.HasPrefix(path, prefix) {
+			return false
+		}
+	}
+	return true
+}
+
+// connectionKey is the gin context key of the request's tracked connection.
+const connectionKey = "cliproxy.connection"
+
+// ConnectionsMiddleware registers every API request with tracker for the live connections
+// view. The request context is cancelled when an operator terminates the connection. The
+// model is filled in by TrackConnectionModel once the request is authenticated.
+func ConnectionsMiddleware(tracker *connections.Tracker) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		path := c.Request.URL.Path
+		if !proxiedPath(path) {
+			c.Next()
+			return
+		}
+		info := connections.Info{
+			Method:   c.Request.Method,
+			Path:     util.MaskSensitivePath(path),
+			ClientIP: ipaccess.Default().ClientIP(c.Request).String(),
+		}
+		conn, ctx, done := tracker.Begin(c.Request.Context(), info, func() string {
+			return util.HideAPIKey(c.GetString("apiKey"))
+		})
+		defer done()
+		ctx = coreauth.WithSelectionObserver(ctx, func(provider string, auth *coreauth.Auth) {
+			conn.SetUpstream(provider, auth.ID)
+		})
+		c.Set(connectionKey, conn)
+		c.Request = c.Request.WithContext(ctx)
+		c.Writer = &countingWriter{ResponseWriter: c.Writer, conn: conn}
+		c.Next()
+	}
+}
+
+// TrackConnectionModel records the requested model on the tracked connection. It reads the
+// body, so it runs after authentication rather than for every client.
+func TrackConnectionModel(c *gin.Context) {
+	if conn, ok := c.Get(connectionKey); ok {
+		if tracked, ok := conn.(*connections.Conn); ok {
+			tracked.SetModel(requestModel(c))
+		}
+	}
+}
+
+// countingWriter reports the bytes written to the client to its connection.
+type countingWriter struct {
+	gin.ResponseWriter
+	conn    *connections.Conn
+	checked bool
+}
+
+func (w *countingWriter) Write(data []byte) (int, error) {
+	w.observe()
+	n, err := w.ResponseWriter.Write(data)
+	w.conn.Wrote(n)
+	return n, err
+}
+
+func (w *countingWriter) WriteString(s string) (int, error) {
+	w.observe()
+	n, err := w.ResponseWriter.WriteString(s)
+	w.conn.Wrote(n)
+	return n, err
+}
+
+// observe marks event streams on the first write, once the headers are final.
+func (w *countingWriter) observe() {
+	if w.checked {
+		return
+	}
+	w.checked = true
+	if strings.HasPrefix(w.Header().Get("Content-Type"), "text/event-stream") {
+		w.conn.SetStreaming()
+	}
+}
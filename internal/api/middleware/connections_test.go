@@ -0,0 +1,69 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/connections"
+)
+
+func TestConnectionsMiddlewareTracksAndTerminates(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tracker := connections.NewTracker()
+	engine := gin.New()
+	engine.Use(ConnectionsMiddleware(tracker))
+	started := make(chan struct{})
+	cause := make(chan error, 1)
+	engine.POST("/v1/chat/completions", func(c *gin.Context) {
+		c.Set("apiKey", "sk-test-12345678")
+		TrackConnectionModel(c)
+		c.Header("Content-Type", "text/event-stream")
+		_, _ = c.Writer.WriteString("data: {}\n\n")
+		close(started)
+		<-c.Request.Context().Done()
+		cause <- context.Cause(c.Request.Context())
+	})
+	engine.GET("/v0/management/connections", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	engine.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/v0/management/connections", nil))
+	if got := tracker.Snapshot(); len(got) != 0 {
+		t.Fatalf("management requests should not be tracked: %+v", got)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(`{"model":"gpt-5","stream":true}`))
+		engine.ServeHTTP(httptest.NewRecorder(), req)
+	}()
+	<-started
+
+	active := tracker.Snapshot()
+	if len(active) != 1 {
+		t.Fatalf("active = %+v", active)
+	}
+	info := active[0]
+	if info.Model != "gpt-5" || info.APIKey != "sk-t...5678" || !info.Streaming || info.BytesStreamed != int64(len("data: {}\n\n")) {
+		t.Fatalf("info = %+v", info)
+	}
+	if !tracker.Terminate(info.ID) {
+		t.Fatal("Terminate reported the connection missing")
+	}
+	select {
+	case err := <-cause:
+		if err != connections.ErrTerminated {
+			t.Fatalf("cause = %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("handler was not cancelled")
+	}
+	<-done
+	if got := tracker.Snapshot(); len(got) != 0 || tracker.Terminate(info.ID) {
+		t.Fatalf("finished request still tracked: %+v", got)
+	}
+}
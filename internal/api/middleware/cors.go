@@ -0,0 +1,192 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+// defaultCORSMethods are allowed when a policy lists no methods.
+var defaultCORSMethods = []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"}
+
+// CORS answers browser clients with the configured cross-origin headers. Configure may be
+// called at any time to swap the policies.
+type CORS struct {
+	state atomic.Pointer[corsState]
+}
+
+type corsState struct {
+	fallback corsPolicy
+	routes   []corsRoute
+}
+
+type corsRoute struct {
+	prefix string
+	policy corsPolicy
+}
+
+type corsPolicy struct {
+	anyOrigin   bool
+	origins     map[string]bool
+	wildcards   []string
+	methods     string
+	anyHeader   bool
+	headers     string
+	expose      string
+	credentials bool
+	maxAge      string
+}
+
+// NewCORS compiles cfg.
+func NewCORS(cfg config.CORSConfig) *CORS {
+	c := &CORS{}
+	c.Configure(cfg)
+	return c
+}
+
+// Configure replaces the policies with cfg.
+func (c *CORS) Configure(cfg config.CORSConfig) {
+	st := &corsState{fallback: compileCORSPolicy(cfg.CORSPolicy)}
+	for _, route := range cfg.Routes {
+		policy := route.CORSPolicy
+		if len(policy.AllowOrigins) == 0 {
+			policy.AllowOrigins = cfg.AllowOrigins
+		}
+		if len(policy.AllowMethods) == 0 {
+			policy.AllowMethods = cfg.AllowMethods
+		}
+		if len(policy.AllowHeaders) == 0 {
+			policy.AllowHeaders = cfg.AllowHeaders
+		}
+		if len(policy.ExposeHeaders) == 0 {
+			policy.ExposeHeaders = cfg.ExposeHeaders
+		}
+		st.routes = append(st.routes, corsRoute{prefix: route.PathPrefix, policy: compileCORSPolicy(policy)})
+	}
+	c.state.Store(st)
+}
+
+// Handler sets the CORS headers of the policy matching the request path and answers
+// preflight (OPTIONS) requests with 204.
+func (c *CORS) Handler() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		c.policyFor(ctx.Request.URL.Path).apply(ctx)
+		if ctx.Request.Method == http.MethodOptions {
+			ctx.AbortWithStatus(http.StatusNoContent)
+			return
+		}
+		ctx.Next()
+	}
+}
+
+// AllowsOrigin reports whether the policy of path admits origin. Requests without an Origin
+// header come from non-browser clients and are admitted.
+func (c *CORS) AllowsOrigin(path, origin string) bool {
+	if c == nil || origin == "" {
+		return true
+	}
+	return c.policyFor(path).allowOrigin(origin)
+}
+
+// policyFor returns the policy of the longest route prefix matching path.
+func (c *CORS) policyFor(path string) *corsPolicy {
+	st := c.state.Load()
+	best, bestLen := &st.fallback, -1
+	for i := range st.routes {
+		prefix := st.routes[i].prefix
+		if len(prefix) > bestLen && (path == prefix || strings.HasPrefix(path, strings.TrimRight(prefix, "/")+"/")) {
+			best, bestLen = &st.routes[i].policy, len(prefix)
+		}
+	}
+	return best
+}
+
+func compileCORSPolicy(src config.CORSPolicy) corsPolicy {
+	p := corsPolicy{credentials: src.AllowCredentials, origins: make(map[string]bool)}
+	origins := src.AllowOrigins
+	if len(origins) == 0 {
+		origins = []string{"*"}
+	}
+	for _, origin := range origins {
+		switch {
+		case origin == "*":
+			p.anyOrigin = true
+		case strings.Contains(origin, "*."):
+			p.wildcards = append(p.wildcards, strings.ToLower(origin))
+		default:
+			p.origins[strings.ToLower(origin)] = true
+		}
+	}
+	methods := src.AllowMethods
+	if len(methods) == 0 {
+		methods = defaultCORSMethods
+	}
+	p.methods = strings.Join(methods, ", ")
+	headers := src.AllowHeaders
+	if len(headers) == 0 {
+		headers = []string{"*"}
+	}
+	for _, header := range headers {
+		if header == "*" {
+			p.anyHeader = true
+		}
+	}
+	p.headers = strings.Join(headers, ", ")
+	p.expose = strings.Join(src.ExposeHeaders, ", ")
+	if src.MaxAgeSeconds > 0 {
+		p.maxAge = strconv.Itoa(src.MaxAgeSeconds)
+	}
+	return p
+}
+
+func (p *corsPolicy) allowOrigin(origin string) bool {
+	if p.anyOrigin {
+		return true
+	}
+	origin = strings.ToLower(origin)
+	if p.origins[origin] {
+		return true
+	}
+	for _, pattern := range p.wildcards {
+		scheme, domain, _ := strings.Cut(pattern, "*.")
+		if strings.HasPrefix(origin, scheme) && strings.HasSuffix(origin, "."+domain) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *corsPolicy) apply(c *gin.Context) {
+	origin := c.GetHeader("Origin")
+	switch {
+	case p.anyOrigin && !p.credentials:
+		c.Header("Access-Control-Allow-Origin", "*")
+	case origin != "" && p.allowOrigin(origin):
+		c.Header("Access-Control-Allow-Origin", origin)
+		c.Writer.Header().Add("Vary", "Origin")
+	default:
+		return
+	}
+	c.Header("Access-Control-Allow-Methods", p.methods)
+	headers := p.headers
+	if p.anyHeader && p.credentials {
+		// Browsers read "*" literally on credentialed requests; echo what was asked for.
+		headers = c.GetHeader("Access-Control-Request-Headers")
+	}
+	if headers != "" {
+		c.Header("Access-Control-Allow-Headers", headers)
+	}
+	if p.expose != "" {
+		c.Header("Access-Control-Expose-Headers", p.expose)
+	}
+	if p.credentials {
+		c.Header("Access-Control-Allow-Credentials", "true")
+	}
+	if p.maxAge != "" && c.Request.Method == http.MethodOptions {
+		c.Header("Access-Control-Max-Age", p.maxAge)
+	}
+}
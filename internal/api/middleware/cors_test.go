@@ -0,0 +1,76 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+func corsRequest(t *testing.T, cors *CORS, method, path, origin string) http.Header {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	engine.Use(cors.Handler())
+	engine.Any("/*path", func(c *gin.Context) { c.Status(http.StatusOK) })
+	req := httptest.NewRequest(method, path, nil)
+	if origin != "" {
+		req.Header.Set("Origin", origin)
+	}
+	req.Header.Set("Access-Control-Request-Headers", "authorization, content-type")
+	rec := httptest.NewRecorder()
+	engine.ServeHTTP(rec, req)
+	if method == http.MethodOptions && rec.Code != http.StatusNoContent {
+		t.Fatalf("preflight status = %d, want 204", rec.Code)
+	}
+	return rec.Header()
+}
+
+func TestCORSDefaultsAllowAnyOrigin(t *testing.T) {
+	header := corsRequest(t, NewCORS(config.CORSConfig{}), http.MethodOptions, "/v1/chat/completions", "https://ide.example.com")
+	if got := header.Get("Access-Control-Allow-Origin"); got != "*" {
+		t.Fatalf("Allow-Origin = %q, want *", got)
+	}
+	if got := header.Get("Access-Control-Allow-Headers"); got != "*" {
+		t.Fatalf("Allow-Headers = %q, want *", got)
+	}
+}
+
+func TestCORSRoutePolicies(t *testing.T) {
+	cors := NewCORS(config.CORSConfig{
+		CORSPolicy: config.CORSPolicy{AllowOrigins: []string{"https://*.example.com"}, AllowMethods: []string{"GET", "POST"}},
+		Routes: []config.CORSRoute{{
+			PathPrefix: "/v1",
+			CORSPolicy: config.CORSPolicy{AllowOrigins: []string{"https://ide.example.org"}, AllowCredentials: true, MaxAgeSeconds: 600},
+		}},
+	})
+
+	header := corsRequest(t, cors, http.MethodOptions, "/v1/models", "https://ide.example.org")
+	if got := header.Get("Access-Control-Allow-Origin"); got != "https://ide.example.org" {
+		t.Fatalf("route Allow-Origin = %q", got)
+	}
+	if header.Get("Access-Control-Allow-Credentials") != "true" || header.Get("Access-Control-Max-Age") != "600" {
+		t.Fatalf("route credentials/max-age missing: %v", header)
+	}
+	if got := header.Get("Access-Control-Allow-Headers"); got != "authorization, content-type" {
+		t.Fatalf("credentialed Allow-Headers = %q, want the requested headers", got)
+	}
+	if got := header.Get("Access-Control-Allow-Methods"); got != "GET, POST" {
+		t.Fatalf("inherited Allow-Methods = %q", got)
+	}
+
+	header = corsRequest(t, cors, http.MethodGet, "/v1beta/models", "https://dash.example.com")
+	if got := header.Get("Access-Control-Allow-Origin"); got != "https://dash.example.com" {
+		t.Fatalf("/v1beta must use the default policy, Allow-Origin = %q", got)
+	}
+	header = corsRequest(t, cors, http.MethodGet, "/v1/models", "https://dash.example.com")
+	if got := header.Get("Access-Control-Allow-Origin"); got != "" {
+		t.Fatalf("origin outside the route policy admitted: %q", got)
+	}
+	header = corsRequest(t, cors, http.MethodGet, "/v0/management/config", "https://evil.test")
+	if got := header.Get("Access-Control-Allow-Origin"); got != "" {
+		t.Fatalf("unknown origin admitted: %q", got)
+	}
+}
@@ -0,0 +1,171 @@
+package middleware
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/pricing"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/routeinfo"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	coreusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+)
+
+const (
+	// CostHeader reports the token usage of a request and its cost estimated from the
+	// pricing table, e.g. "usd=0.004125; input=1200; output=35; cached=0; reasoning=0".
+	// usd is omitted for unpriced models.
+	CostHeader = "X-CLIProxy-Cost"
+	// RouteHeader reports how a request was served, e.g.
+	// "type=MODEL_MAPPING; provider=claude; model=claude-sonnet-4-5; requested_model=gpt-5".
+	RouteHeader = "X-CLIProxy-Route"
+)
+
+// CostHeadersMiddleware adds the cost and route headers of the upstream attempt that served
+// the request to its response. Streams send their headers before the usage is known, so
+// they carry the headers as HTTP trailers instead.
+func CostHeadersMiddleware(table *pricing.Table) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !table.ResponseHeaders() {
+			c.Next()
+			return
+		}
+		w := &costHeaderWriter{ResponseWriter: c.Writer, c: c, table: table}
+		c.Writer = w
+		c.Next()
+		c.Writer = w.ResponseWriter
+		w.finish()
+	}
+}
+
+// costHeaderWriter sets the headers just before the response headers are sent.
+type costHeaderWriter struct {
+	gin.ResponseWriter
+	c        *gin.Context
+	table    *pricing.Table
+	applied  bool
+	trailers bool
+	routed   bool
+}
+
+func (w *costHeaderWriter) apply() {
+	if w.applied || w.ResponseWriter.Written() {
+		return
+	}
+	w.applied = true
+	header := w.ResponseWriter.Header()
+	record, hasRecord := usageRecord(w.c)
+	info, hasInfo := routeinfo.Get(w.c)
+	if hasRecord || hasInfo {
+		header.Set(RouteHeader, routeHeaderValue(info, hasInfo, record, hasRecord))
+		w.routed = true
+	}
+	if hasRecord {
+		header.Set(CostHeader, costHeaderValue(w.table, record))
+		return
+	}
+	if !strings.HasPrefix(header.Get("Content-Type"), "text/event-stream") {
+		return
+	}
+	w.trailers = true
+	header.Add("Trailer", CostHeader)
+	if !w.routed {
+		header.Add("Trailer", RouteHeader)
+	}
+}
+
+// finish fills the declared trailers of a stream once its usage was reported.
+func (w *costHeaderWriter) finish() {
+	if !w.trailers {
+		return
+	}
+	record, ok := usageRecord(w.c)
+	if !ok {
+		return
+	}
+	header := w.ResponseWriter.Header()
+	header.Set(CostHeader, costHeaderValue(w.table, record))
+	if !w.routed {
+		info, hasInfo := routeinfo.Get(w.c)
+		header.Set(RouteHeader, routeHeaderValue(info, hasInfo, record, true))
+	}
+}
+
+func (w *costHeaderWriter) WriteHeader(code int) {
+	w.apply()
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *costHeaderWriter) WriteHeaderNow() {
+	w.apply()
+	w.ResponseWriter.WriteHeaderNow()
+}
+
+func (w *costHeaderWriter) Write(data []byte) (int, error) {
+	w.apply()
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *costHeaderWriter) WriteString(s string) (int, error) {
+	w.apply()
+	return w.ResponseWriter.WriteString(s)
+}
+
+func (w *costHeaderWriter) Flush() {
+	w.apply()
+	w.ResponseWriter.Flush()
+}
+
+// usageRecord returns the usage record of the upstream attempt that served the request.
+func usageRecord(c *gin.Context) (coreusage.Record, bool) {
+	value, ok := c.Get(util.UsageRecordKey)
+	if !ok {
+		return coreusage.Record{}, false
+	}
+	record, ok := value.(coreusage.Record)
+	return record, ok
+}
+
+func costHeaderValue(table *pricing.Table, record coreusage.Record) string {
+	d := record.Detail
+	parts := make([]string, 0, 5)
+	if cost, ok := table.Cost(record.Model, pricing.UsageOf(d)); ok {
+		parts = append(parts, fmt.Sprintf("usd=%.6f", cost))
+	}
+	parts = append(parts,
+		fmt.Sprintf("input=%d", d.InputTokens),
+		fmt.Sprintf("output=%d", d.OutputTokens),
+		fmt.Sprintf("cached=%d", d.CachedTokens),
+		fmt.Sprintf("reasoning=%d", d.ReasoningTokens),
+	)
+	return strings.Join(parts, "; ")
+}
+
+// routeHeaderValue describes the route from the routing decision, when one was recorded,
+// and the provider and model of the upstream attempt.
+func routeHeaderValue(info routeinfo.Info, hasInfo bool, record coreusage.Record, hasRecord bool) string {
+	routeType := routeinfo.LocalProvider
+	provider, model := "", ""
+	if hasInfo {
+		routeType = info.RouteType
+		provider = info.Provider
+		model = info.ResolvedModel
+		if model == "" {
+			model = info.RequestedModel
+		}
+	}
+	if hasRecord {
+		provider, model = record.Provider, record.Model
+	}
+	parts := []string{"type=" + string(routeType)}
+	if provider != "" {
+		parts = append(parts, "provider="+provider)
+	}
+	if model != "" {
+		parts = append(parts, "model="+model)
+	}
+	if hasInfo && info.RequestedModel != "" && info.RequestedModel != model {
+		parts = append(parts, "requested_model="+info.RequestedModel)
+	}
+	return strings.Join(parts, "; ")
+}
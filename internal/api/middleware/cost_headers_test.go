@@ -0,0 +1,88 @@
+package middleware
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/pricing"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/routeinfo"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	coreusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+)
+
+func TestCostHeadersMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	table := pricing.New(config.PricingConfig{Models: []config.ModelPrice{{Model: "claude-sonnet-4*", InputPer1K: 0.003, OutputPer1K: 0.015}}})
+	record := coreusage.Record{Provider: "claude", Model: "claude-sonnet-4-5", Detail: coreusage.Detail{InputTokens: 1000, OutputTokens: 100}}
+
+	engine := gin.New()
+	engine.Use(CostHeadersMiddleware(table))
+	engine.POST("/json", func(c *gin.Context) {
+		routeinfo.Set(c, routeinfo.Info{RequestedModel: "gpt-5", ResolvedModel: "claude-sonnet-4-5", RouteType: routeinfo.ModelMapping})
+		c.Set(util.UsageRecordKey, record)
+		c.JSON(http.StatusOK, gin.H{"ok": true})
+	})
+	engine.POST("/stream", func(c *gin.Context) {
+		c.Header("Content-Type", "text/event-stream")
+		_, _ = c.Writer.WriteString("data: {}\n\n")
+		c.Writer.Flush()
+		c.Set(util.UsageRecordKey, record)
+	})
+	engine.POST("/amp", func(c *gin.Context) {
+		routeinfo.Set(c, routeinfo.Info{RequestedModel: "claude-opus-4-5", RouteType: routeinfo.AmpCredits})
+		c.Status(http.StatusOK)
+	})
+	engine.GET("/plain", func(c *gin.Context) { c.String(http.StatusOK, "ok") })
+
+	srv := httptest.NewServer(engine)
+	defer srv.Close()
+	do := func(method, path string) *http.Response {
+		req, _ := http.NewRequest(method, srv.URL+path, nil)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+		_, _ = io.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+		return resp
+	}
+
+	wantCost := "usd=0.004500; input=1000; output=100; cached=0; reasoning=0"
+	resp := do(http.MethodPost, "/json")
+	if got := resp.Header.Get(CostHeader); got != wantCost {
+		t.Fatalf("cost header = %q", got)
+	}
+	if got := resp.Header.Get(RouteHeader); got != "type=MODEL_MAPPING; provider=claude; model=claude-sonnet-4-5; requested_model=gpt-5" {
+		t.Fatalf("route header = %q", got)
+	}
+
+	resp = do(http.MethodPost, "/stream")
+	if resp.Header.Get(CostHeader) != "" {
+		t.Fatalf("stream sent the cost before the usage was known")
+	}
+	if got := resp.Trailer.Get(CostHeader); got != wantCost {
+		t.Fatalf("cost trailer = %q", got)
+	}
+	if got := resp.Trailer.Get(RouteHeader); got != "type=LOCAL_PROVIDER; provider=claude; model=claude-sonnet-4-5" {
+		t.Fatalf("route trailer = %q", got)
+	}
+
+	resp = do(http.MethodPost, "/amp")
+	if got := resp.Header.Get(RouteHeader); got != "type=AMP_CREDITS; model=claude-opus-4-5" || resp.Header.Get(CostHeader) != "" {
+		t.Fatalf("amp headers = %q / %q", got, resp.Header.Get(CostHeader))
+	}
+
+	resp = do(http.MethodGet, "/plain")
+	if resp.Header.Get(RouteHeader) != "" || resp.Header.Get("Trailer") != "" {
+		t.Fatalf("non-model response got headers: %v", resp.Header)
+	}
+
+	table.Configure(config.PricingConfig{DisableResponseHeaders: true})
+	if resp = do(http.MethodPost, "/json"); resp.Header.Get(CostHeader) != "" {
+		t.Fatal("disabled headers were sent")
+	}
+}
@@ -0,0 +1,92 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync/atomic"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/faultinject"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	log "github.com/sirupsen/logrus"
+)
+
+// errInjectedDisconnect cancels requests whose connection was dropped on purpose.
+var errInjectedDisconnect = errors.New("fault injection: connection dropped")
+
+// FaultInjectionMiddleware injects the stream faults of the fault-injection rules into
+// event stream responses: malformed SSE events and dropped connections. Latency and error
+// faults are injected around the upstream attempts by the injector's executor wrapper.
+func FaultInjectionMiddleware(injector *faultinject.Injector) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !injector.Enabled() || !proxiedPath(c.Request.URL.Path) {
+			c.Next()
+			return
+		}
+		ctx, cancel := context.WithCancelCause(c.Request.Context())
+		defer cancel(nil)
+		w := &faultyWriter{ResponseWriter: c.Writer, injector: injector, model: requestModel(c), cancel: cancel}
+		ctx = coreauth.WithSelectionObserver(ctx, func(provider string, _ *coreauth.Auth) {
+			w.provider.Store(&provider)
+		})
+		c.Request = c.Request.WithContext(ctx)
+		c.Writer = w
+		c.Next()
+	}
+}
+
+// faultyWriter injects stream faults before the chunks of event streams.
+type faultyWriter struct {
+	gin.ResponseWriter
+	injector  *faultinject.Injector
+	model     string
+	provider  atomic.Pointer[string]
+	cancel    context.CancelCauseFunc
+	checked   bool
+	streaming bool
+	dropped   bool
+}
+
+func (w *faultyWriter) Write(data []byte) (int, error) {
+	if err := w.inject(); err != nil {
+		return 0, err
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *faultyWriter) WriteString(s string) (int, error) {
+	if err := w.inject(); err != nil {
+		return 0, err
+	}
+	return w.ResponseWriter.WriteString(s)
+}
+
+func (w *faultyWriter) inject() error {
+	if w.dropped {
+		return errInjectedDisconnect
+	}
+	if !w.checked {
+		w.checked = true
+		w.streaming = strings.HasPrefix(w.Header().Get("Content-Type"), "text/event-stream")
+	}
+	provider := w.provider.Load()
+	if !w.streaming || provider == nil {
+		return nil
+	}
+	switch w.injector.StreamChunkFault(*provider, w.model) {
+	case faultinject.MalformedChunk:
+		_, err := w.ResponseWriter.WriteString(faultinject.MalformedEvent)
+		return err
+	case faultinject.Disconnect:
+		log.Debugf("fault-injection: dropping %s stream of %s", *provider, w.model)
+		w.dropped = true
+		w.cancel(errInjectedDisconnect)
+		w.ResponseWriter.Flush()
+		if conn, _, err := w.ResponseWriter.Hijack(); err == nil {
+			_ = conn.Close()
+		}
+		return errInjectedDisconnect
+	}
+	return nil
+}
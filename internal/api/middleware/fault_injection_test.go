@@ -0,0 +1,134 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/faultinject"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+)
+
+type chunkExecutor struct {
+	calls int
+}
+
+func (e *chunkExecutor) Identifier() string { return "test" }
+
+func (e *chunkExecutor) Execute(context.Context, *coreauth.Auth, cliproxyexecutor.Request, cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+	e.calls++
+	return cliproxyexecutor.Response{Payload: []byte("{}")}, nil
+}
+
+func (e *chunkExecutor) ExecuteStream(context.Context, *coreauth.Auth, cliproxyexecutor.Request, cliproxyexecutor.Options) (<-chan cliproxyexecutor.StreamChunk, error) {
+	e.calls++
+	ch := make(chan cliproxyexecutor.StreamChunk, 2)
+	ch <- cliproxyexecutor.StreamChunk{Payload: []byte("one")}
+	ch <- cliproxyexecutor.StreamChunk{Payload: []byte("two")}
+	close(ch)
+	return ch, nil
+}
+
+func (e *chunkExecutor) Refresh(_ context.Context, auth *coreauth.Auth) (*coreauth.Auth, error) {
+	return auth, nil
+}
+
+func (e *chunkExecutor) CountTokens(context.Context, *coreauth.Auth, cliproxyexecutor.Request, cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+	return cliproxyexecutor.Response{}, nil
+}
+
+func (e *chunkExecutor) HttpRequest(context.Context, *coreauth.Auth, *http.Request) (*http.Response, error) {
+	return nil, errors.New("not implemented")
+}
+
+func newFaultyManager(t *testing.T, rule config.FaultInjectionRule) (*coreauth.Manager, *chunkExecutor, *faultinject.Injector) {
+	t.Helper()
+	cfg := &config.Config{FaultInjection: config.FaultInjectionConfig{Enable: true, Rules: []config.FaultInjectionRule{rule}}}
+	cfg.SanitizeFaultInjection()
+	injector := faultinject.NewInjector()
+	injector.Configure(cfg.FaultInjection)
+	executor := &chunkExecutor{}
+	manager := coreauth.NewManager(nil, nil, nil)
+	manager.RegisterExecutor(executor)
+	manager.SetExecutorWrapper(injector.Wrap)
+	if _, err := manager.Register(context.Background(), &coreauth.Auth{ID: "a", Provider: "test"}); err != nil {
+		t.Fatal(err)
+	}
+	return manager, executor, injector
+}
+
+// streamEngine serves the manager's stream as SSE behind the fault injection middleware.
+func streamEngine(manager *coreauth.Manager, injector *faultinject.Injector, cancelled chan<- error) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	engine.Use(FaultInjectionMiddleware(injector))
+	engine.GET("/v1/stream", func(c *gin.Context) {
+		c.Header("Content-Type", "text/event-stream")
+		chunks, err := manager.ExecuteStream(c.Request.Context(), []string{"test"}, cliproxyexecutor.Request{}, cliproxyexecutor.Options{})
+		if err != nil {
+			c.Status(http.StatusBadGateway)
+			return
+		}
+		for chunk := range chunks {
+			if _, errWrite := c.Writer.WriteString("data: " + string(chunk.Payload) + "\n\n"); errWrite != nil {
+				break
+			}
+			c.Writer.Flush()
+		}
+		if cancelled != nil {
+			cancelled <- context.Cause(c.Request.Context())
+		}
+	})
+	return engine
+}
+
+func TestFaultInjectionFailsUpstreamAttempts(t *testing.T) {
+	manager, executor, _ := newFaultyManager(t, config.FaultInjectionRule{Provider: "test", ErrorProbability: 1, ErrorStatus: 418})
+	_, err := manager.Execute(context.Background(), []string{"test"}, cliproxyexecutor.Request{}, cliproxyexecutor.Options{})
+	var se cliproxyexecutor.StatusError
+	if !errors.As(err, &se) || se.StatusCode() != 418 || executor.calls != 0 {
+		t.Fatalf("err = %v, calls = %d", err, executor.calls)
+	}
+}
+
+func TestFaultInjectionMalformsStreamChunks(t *testing.T) {
+	manager, _, injector := newFaultyManager(t, config.FaultInjectionRule{Provider: "*", MalformedChunkProbability: 1})
+	rec := httptest.NewRecorder()
+	streamEngine(manager, injector, nil).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/stream", nil))
+	want := faultinject.MalformedEvent + "data: one\n\n" + faultinject.MalformedEvent + "data: two\n\n"
+	if rec.Body.String() != want {
+		t.Fatalf("body = %q", rec.Body.String())
+	}
+}
+
+func TestFaultInjectionDropsStreams(t *testing.T) {
+	manager, _, injector := newFaultyManager(t, config.FaultInjectionRule{Provider: "test", DisconnectProbability: 1})
+	cancelled := make(chan error, 1)
+	srv := httptest.NewServer(streamEngine(manager, injector, cancelled))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/v1/stream")
+	if err == nil {
+		body, errRead := io.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+		if errRead == nil || strings.Contains(string(body), "data: one") {
+			t.Fatalf("stream was not dropped: %q, %v", body, errRead)
+		}
+	}
+	select {
+	case cause := <-cancelled:
+		if !errors.Is(cause, errInjectedDisconnect) {
+			t.Fatalf("cause = %v", cause)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("handler did not observe the disconnect")
+	}
+}
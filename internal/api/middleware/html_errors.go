@@ -0,0 +1,109 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tidwall/gjson"
+)
+
+// maxErrorBodyBytes caps the error body kept to extract the message for the status page.
+const maxErrorBodyBytes = 4 << 10
+
+// HTMLErrorPage replaces the error responses of browser navigations (GET or HEAD requests
+// accepting text/html) with the HTML page returned by render, which receives the status and
+// the message of the original JSON or text error. API clients keep their machine readable
+// errors.
+func HTMLErrorPage(render func(status int, message string) string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !acceptsHTML(c.Request) {
+			c.Next()
+			return
+		}
+		w := &htmlErrorWriter{ResponseWriter: c.Writer}
+		c.Writer = w
+		c.Next()
+		c.Writer = w.ResponseWriter
+		if !w.checked && !w.ResponseWriter.Written() && w.Status() >= http.StatusBadRequest {
+			// Errors without a body yet, such as gin's unmatched route 404, get the page too.
+			w.replaced = true
+		}
+		if !w.replaced {
+			return
+		}
+		header := w.ResponseWriter.Header()
+		header.Del("Content-Length")
+		header.Set("Content-Type", "text/html; charset=utf-8")
+		if c.Request.Method == http.MethodHead {
+			w.ResponseWriter.WriteHeaderNow()
+			return
+		}
+		_, _ = w.ResponseWriter.WriteString(render(w.Status(), errorMessage(w.body)))
+	}
+}
+
+// acceptsHTML reports whether req is a browser navigation.
+func acceptsHTML(req *http.Request) bool {
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		return false
+	}
+	return strings.Contains(req.Header.Get("Accept"), "text/html")
+}
+
+// errorMessage extracts the message of a JSON error body, or returns a text body as is.
+func errorMessage(body []byte) string {
+	if gjson.ValidBytes(body) {
+		for _, path := range []string{"error.message", "error", "message"} {
+			if value := gjson.GetBytes(body, path); value.Type == gjson.String {
+				return value.String()
+			}
+		}
+		return ""
+	}
+	return strings.TrimSpace(string(body))
+}
+
+// htmlErrorWriter holds back error bodies that are not HTML, so they can be replaced.
+type htmlErrorWriter struct {
+	gin.ResponseWriter
+	checked  bool
+	replaced bool
+	body     []byte
+}
+
+func (w *htmlErrorWriter) Write(data []byte) (int, error) {
+	if !w.replace() {
+		return w.ResponseWriter.Write(data)
+	}
+	w.keep(data)
+	return len(data), nil
+}
+
+func (w *htmlErrorWriter) WriteString(s string) (int, error) {
+	if !w.replace() {
+		return w.ResponseWriter.WriteString(s)
+	}
+	w.keep([]byte(s))
+	return len(s), nil
+}
+
+// replace decides on the first write, once status and headers are final, whether the body
+// is an error to replace.
+func (w *htmlErrorWriter) replace() bool {
+	if !w.checked {
+		w.checked = true
+		w.replaced = !w.ResponseWriter.Written() && w.Status() >= http.StatusBadRequest &&
+			!strings.HasPrefix(w.Header().Get("Content-Type"), "text/html")
+	}
+	return w.replaced
+}
+
+func (w *htmlErrorWriter) keep(data []byte) {
+	if room := maxErrorBodyBytes - len(w.body); room > 0 {
+		if len(data) > room {
+			data = data[:room]
+		}
+		w.body = append(w.body, data...)
+	}
+}
@@ -0,0 +1,43 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestHTMLErrorPage(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	engine.Use(HTMLErrorPage(func(status int, message string) string {
+		return fmt.Sprintf("<p>%d %s</p>", status, message)
+	}))
+	engine.GET("/error", func(c *gin.Context) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": gin.H{"message": "Missing API key"}})
+	})
+	engine.GET("/ok", func(c *gin.Context) { c.JSON(http.StatusOK, gin.H{"ok": true}) })
+
+	cases := []struct {
+		method, path, accept string
+		want                 string
+	}{
+		{http.MethodGet, "/error", "text/html,*/*;q=0.8", "<p>401 Missing API key</p>"},
+		{http.MethodGet, "/missing", "text/html", "<p>404 </p>"},
+		{http.MethodGet, "/error", "application/json", `{"error":{"message":"Missing API key"}}`},
+		{http.MethodPost, "/error", "text/html", `404 page not found`},
+		{http.MethodGet, "/ok", "text/html", `{"ok":true}`},
+	}
+	for _, tc := range cases {
+		req := httptest.NewRequest(tc.method, tc.path, nil)
+		req.Header.Set("Accept", tc.accept)
+		rec := httptest.NewRecorder()
+		engine.ServeHTTP(rec, req)
+		if got := strings.TrimSpace(rec.Body.String()); got != tc.want {
+			t.Errorf("%s %s (%s): body %q, want %q", tc.method, tc.path, tc.accept, got, tc.want)
+		}
+	}
+}
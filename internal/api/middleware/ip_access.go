@@ -0,0 +1,28 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/ipaccess"
+	log "github.com/sirupsen/logrus"
+)
+
+// IPAccessMiddleware refuses clients the ip-access rules do not admit with 403. The
+// management API and control panel use the management rules, every other route the
+// inference rules.
+func IPAccessMiddleware(filter *ipaccess.Filter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		surface := ipaccess.Inference
+		if path := c.Request.URL.Path; strings.HasPrefix(path, "/v0/management") || path == "/management.html" {
+			surface = ipaccess.Management
+		}
+		if filter.Allowed(c.Request, surface) {
+			c.Next()
+			return
+		}
+		log.Debugf("ip-access: refused %s %s from %s", c.Request.Method, c.Request.URL.Path, filter.ClientIP(c.Request))
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "access denied"})
+	}
+}
@@ -0,0 +1,39 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/agentbudget"
+	log "github.com/sirupsen/logrus"
+)
+
+// maxLoopBreakerBody bounds how much of a request body the loop breaker buffers. Longer
+// conversations are passed on unchecked.
+const maxLoopBreakerBody = 16 << 20
+
+// ApplyLoopBreaker rejects requests that continue an agent loop repeating the same tool
+// call or message, answering them with an error the agent can read. It returns false after
+// aborting the request.
+func ApplyLoopBreaker(c *gin.Context, breaker *agentbudget.Breaker, apiKey string) bool {
+	if breaker == nil || !breaker.Enabled() || c.Request.Method != http.MethodPost || c.Request.Body == nil {
+		return true
+	}
+	body, complete, err := peekBody(c, maxLoopBreakerBody)
+	if err != nil || !complete || len(body) == 0 {
+		return true
+	}
+	session := strings.TrimSpace(c.GetHeader(breaker.SessionHeader()))
+	message, broken := breaker.Check(apiKey, session, body)
+	if !broken {
+		return true
+	}
+	log.Warnf("loop breaker: stopped request on %s: %s", c.Request.URL.Path, message)
+	c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": gin.H{
+		"message": message,
+		"type":    "invalid_request_error",
+		"code":    agentbudget.LoopErrorCode,
+	}})
+	return false
+}
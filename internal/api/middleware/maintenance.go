@@ -0,0 +1,32 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/maintenance"
+)
+
+// ApplyMaintenance rejects new API requests during proxy-wide maintenance, and requests
+// for models that drained providers no longer serve. It returns false after aborting the
+// request.
+func ApplyMaintenance(c *gin.Context, controller *maintenance.Controller) bool {
+	if controller == nil {
+		return true
+	}
+	mode, active := controller.Proxy()
+	if !active && controller.Draining() {
+		mode, active = controller.RejectModel(requestModel(c))
+	}
+	if !active {
+		return true
+	}
+	c.Header("Retry-After", strconv.Itoa(controller.RetryAfterSeconds()))
+	c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": gin.H{
+		"message": mode.Message,
+		"type":    "service_unavailable_error",
+		"code":    maintenance.ErrorCode,
+	}})
+	return false
+}
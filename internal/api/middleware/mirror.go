@@ -0,0 +1,35 @@
+package middleware
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/featureflag"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/mirror"
+)
+
+// MirrorMiddleware queues a scrubbed copy of sampled API requests for the secondary instance.
+// Queueing never blocks, so the client request proceeds as if mirroring were off.
+func MirrorMiddleware(m *mirror.Mirror) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.Method != http.MethodPost || !shouldLogRequest(c.Request.URL.Path) || c.GetHeader(mirror.Header) != "" ||
+			!featureflag.Enabled(featureflag.ShadowTraffic) || !m.Sample(c.Request.URL.Path) {
+			c.Next()
+			return
+		}
+		var body []byte
+		if c.Request.Body != nil {
+			data, err := io.ReadAll(c.Request.Body)
+			if err != nil {
+				c.Next()
+				return
+			}
+			c.Request.Body = io.NopCloser(bytes.NewReader(data))
+			body = data
+		}
+		m.Submit(c.Request.Method, c.Request.URL.Path, c.Request.URL.RawQuery, c.Request.Header, body)
+		c.Next()
+	}
+}
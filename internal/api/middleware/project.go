@@ -0,0 +1,117 @@
+package middleware
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/project"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/routing"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	log "github.com/sirupsen/logrus"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// ScopeProject attaches the project owning apiKey to the request, enforces the project's
+// quota and applies its model mappings. It returns false after aborting the request.
+func ScopeProject(c *gin.Context, registry *project.Registry, apiKey string) bool {
+	if registry == nil || !registry.Enabled() {
+		return true
+	}
+	name, err := registry.Admit(apiKey)
+	if name == "" {
+		return true
+	}
+	c.Set(project.ContextKey, name)
+	c.Request = c.Request.WithContext(project.WithName(c.Request.Context(), name))
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, project.ErrQuotaExceeded) {
+			status = http.StatusTooManyRequests
+		}
+		c.AbortWithStatusJSON(status, gin.H{"error": gin.H{
+			"message": err.Error(),
+			"type":    "rate_limit_error",
+			"code":    "project_quota_exceeded",
+		}})
+		return false
+	}
+	applyProjectModelMapping(c, registry, name)
+	return true
+}
+
+// applyProjectModelMapping rewrites the requested model using the project's mappings.
+func applyProjectModelMapping(c *gin.Context, registry *project.Registry, name string) {
+	rewriteRequestModel(c, func(model string) string {
+		mapped := registry.MapModel(name, model)
+		if mapped != "" {
+			log.Debugf("project %s: model mapping %s -> %s", name, model, mapped)
+			routing.Trace(c, "project %s: model mapping %s -> %s", name, model, mapped)
+		}
+		return mapped
+	})
+}
+
+// rewriteRequestModel replaces the requested model in the JSON body or, for Gemini-style
+// routes, in the "<model>:method" action parameter. mapFn returns "" to keep a model.
+func rewriteRequestModel(c *gin.Context, mapFn func(model string) string) {
+	for i := range c.Params {
+		if c.Params[i].Key != "action" {
+			continue
+		}
+		model, method, ok := util.ParseGeminiModelPath(c.Params[i].Value)
+		if !ok || method == "" {
+			continue
+		}
+		if mapped := mapFn(model); mapped != "" {
+			c.Params[i].Value = "/" + mapped + ":" + method
+		}
+	}
+
+	if c.Request.Method != http.MethodPost || c.Request.Body == nil {
+		return
+	}
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		return
+	}
+	if model := gjson.GetBytes(body, "model").String(); model != "" {
+		if mapped := mapFn(model); mapped != "" {
+			if updated, errSet := sjson.SetBytes(body, "model", mapped); errSet == nil {
+				body = updated
+			}
+		}
+	}
+	c.Request.Body = io.NopCloser(bytes.NewReader(body))
+	c.Request.ContentLength = int64(len(body))
+}
+
+// maxModelPeekBody bounds how much of a request body is buffered to find its model. The
+// model field precedes the message history in the bodies clients send.
+const maxModelPeekBody = 1 << 20
+
+// requestModel returns the model named by the JSON body or the Gemini-style action
+// parameter, leaving the body readable for later handlers.
+func requestModel(c *gin.Context) string {
+	if c.Request.Method == http.MethodPost && c.Request.Body != nil {
+		body, _, err := peekBody(c, maxModelPeekBody)
+		if err == nil {
+			if model := gjson.GetBytes(body, "model").String(); model != "" {
+				return model
+			}
+		}
+	}
+	for _, param := range c.Params {
+		if param.Key != "action" {
+			continue
+		}
+		if model, method, ok := util.ParseGeminiModelPath(param.Value); ok && method != "" {
+			return model
+		}
+	}
+	return ""
+}
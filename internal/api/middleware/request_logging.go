@@ -70,7 +70,7 @@ func RequestLoggingMiddleware(logger logging.RequestLogger) gin.HandlerFunc {
 func captureRequestInfo(c *gin.Context) (*RequestInfo, error) {
 	// Capture URL with sensitive query parameters masked
 	maskedQuery := util.MaskSensitiveQuery(c.Request.URL.RawQuery)
-	url := c.Request.URL.Path
+	url := util.MaskSensitivePath(c.Request.URL.Path)
 	if maskedQuery != "" {
 		url += "?" + maskedQuery
 	}
@@ -0,0 +1,91 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/project"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/routing"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/vhost"
+	log "github.com/sirupsen/logrus"
+)
+
+// ApplyRoutingRules evaluates the routing rules for the request and applies the action of
+// the first matching rule. It returns false after aborting a denied request.
+func ApplyRoutingRules(c *gin.Context, engine *routing.Engine, apiKey string) bool {
+	if engine == nil || !engine.Enabled() {
+		return true
+	}
+	req := routing.Request{
+		Model:  requestModel(c),
+		Path:   c.Request.URL.Path,
+		APIKey: apiKey,
+		Header: c.Request.Header,
+	}
+	for _, tag := range strings.Split(c.GetHeader(routing.TagsHeader), ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			req.Tags = append(req.Tags, tag)
+		}
+	}
+	if name := c.GetString(project.ContextKey); name != "" {
+		req.Tags = append(req.Tags, "project:"+name)
+	}
+	if name := c.GetString(vhost.ContextKey); name != "" {
+		req.Tags = append(req.Tags, "vhost:"+name)
+	}
+
+	var rule config.RoutingRule
+	var ok bool
+	if engine.TraceEnabled() {
+		var trace []string
+		rule, ok, trace = engine.Explain(req)
+		for _, entry := range trace {
+			routing.Trace(c, "%s", entry)
+		}
+	} else {
+		rule, ok = engine.Match(req)
+	}
+	if !ok {
+		return true
+	}
+	c.Set(routing.RuleContextKey, rule.Name)
+	action := rule.Action
+	if action.Deny {
+		message := action.Message
+		if message == "" {
+			message = "request denied by routing rule " + rule.Name
+		}
+		log.Debugf("routing rule %s: denied %s (model %q)", rule.Name, req.Path, req.Model)
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": gin.H{
+			"message": message,
+			"type":    "permission_error",
+			"code":    "routing_rule_denied",
+		}})
+		return false
+	}
+	if action.MapModel != "" && req.Model != "" {
+		rewriteRequestModel(c, func(string) string { return action.MapModel })
+		log.Debugf("routing rule %s: model %s -> %s", rule.Name, req.Model, action.MapModel)
+	}
+	if action.PinProvider != "" {
+		c.Set(routing.ProviderContextKey, action.PinProvider)
+		c.Request = c.Request.WithContext(routing.WithProvider(c.Request.Context(), action.PinProvider))
+		log.Debugf("routing rule %s: pinned provider %s", rule.Name, action.PinProvider)
+	}
+	if len(action.PinCredentials) > 0 {
+		c.Set(routing.CredentialsContextKey, action.PinCredentials)
+		c.Request = c.Request.WithContext(routing.WithCredentials(c.Request.Context(), action.PinCredentials))
+		log.Debugf("routing rule %s: pinned credentials %v", rule.Name, action.PinCredentials)
+	}
+	if action.PinGroup != "" {
+		c.Set(routing.GroupContextKey, action.PinGroup)
+		c.Request = c.Request.WithContext(routing.WithGroup(c.Request.Context(), action.PinGroup))
+		log.Debugf("routing rule %s: pinned provider group %s", rule.Name, action.PinGroup)
+	}
+	if action.ForceAmp {
+		c.Set(routing.ForceAmpContextKey, true)
+	}
+	return true
+}
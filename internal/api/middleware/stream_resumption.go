@@ -0,0 +1,193 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/resume"
+	"github.com/tidwall/gjson"
+)
+
+// ApplyStreamResumption makes streamed responses resumable. Requests carrying a resumption
+// token are answered from the buffered stream and aborted; streaming requests are detached
+// from client disconnects and buffered under a new token. The returned function must run
+// once the handler finished; it returns false after answering the request.
+func ApplyStreamResumption(c *gin.Context, store *resume.Store, apiKey string) (func(), bool) {
+	noop := func() {}
+	if store == nil || !store.Enabled() {
+		return noop, true
+	}
+	if token := strings.TrimSpace(c.GetHeader(resume.TokenHeader)); token != "" {
+		serveResumedStream(c, store, apiKey, token)
+		return noop, false
+	}
+	if !isStreamingRequest(c) {
+		return noop, true
+	}
+	stream, token := store.Start(apiKey)
+	if stream == nil {
+		return noop, true
+	}
+
+	client := c.Request.Context()
+	ctx, cancel := context.WithCancel(context.WithoutCancel(client))
+	c.Request = c.Request.WithContext(ctx)
+	writer := &resumableWriter{ResponseWriter: c.Writer, stream: stream, token: token, client: client, cancel: cancel}
+	c.Writer = writer
+	// Requests whose response turned out not to be resumable still stop with their client.
+	stop := context.AfterFunc(client, func() {
+		if writer.state.Load() != resumableYes {
+			cancel()
+		}
+	})
+	return func() {
+		stop()
+		if trailing := stream.Finish(); len(trailing) > 0 && writer.state.Load() == resumableYes && client.Err() == nil {
+			_, _ = writer.ResponseWriter.Write(trailing)
+		}
+		cancel()
+		if writer.state.Load() != resumableYes {
+			store.Drop(token)
+		}
+	}, true
+}
+
+// maxStreamFlagBody bounds how much of a request body is buffered to read its stream flag.
+const maxStreamFlagBody = 16 << 20
+
+// isStreamingRequest reports whether the request asks for a streamed response.
+func isStreamingRequest(c *gin.Context) bool {
+	if c.Request.Method != http.MethodPost {
+		return false
+	}
+	if strings.Contains(c.Request.URL.Path, ":streamGenerateContent") || c.Query("alt") == "sse" {
+		return true
+	}
+	body, _, err := peekBody(c, maxStreamFlagBody)
+	return err == nil && gjson.GetBytes(body, "stream").Bool()
+}
+
+const (
+	resumableUndecided int32 = iota
+	resumableYes
+	resumableNo
+)
+
+// resumableWriter copies an SSE response into its stream buffer. Once the client is gone,
+// writes only reach the buffer so the handler runs to completion for a later resume.
+type resumableWriter struct {
+	gin.ResponseWriter
+	stream *resume.Stream
+	token  string
+	client context.Context
+	cancel context.CancelFunc
+	state  atomic.Int32
+}
+
+// decide classifies the response when its headers are about to be sent: only successful
+// event streams are resumable, and only those get a token.
+func (w *resumableWriter) decide() {
+	if w.state.Load() != resumableUndecided {
+		return
+	}
+	header := w.ResponseWriter.Header()
+	if w.ResponseWriter.Status() < http.StatusMultipleChoices && strings.HasPrefix(strings.ToLower(header.Get("Content-Type")), "text/event-stream") {
+		header.Set(resume.TokenHeader, w.token)
+		w.stream.SetHeader(header)
+		w.state.Store(resumableYes)
+		return
+	}
+	w.state.Store(resumableNo)
+	if w.client.Err() != nil {
+		w.cancel()
+	}
+}
+
+func (w *resumableWriter) Write(p []byte) (int, error) {
+	w.decide()
+	out := p
+	if w.state.Load() == resumableYes {
+		var ok bool
+		if out, ok = w.stream.Write(p); !ok {
+			w.state.Store(resumableNo)
+		}
+	}
+	if w.client.Err() != nil {
+		if w.state.Load() != resumableYes {
+			w.cancel()
+			return 0, w.client.Err()
+		}
+		return len(p), nil
+	}
+	if len(out) == 0 {
+		return len(p), nil
+	}
+	if _, err := w.ResponseWriter.Write(out); err != nil {
+		if w.state.Load() == resumableYes {
+			return len(p), nil
+		}
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (w *resumableWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+func (w *resumableWriter) WriteHeaderNow() {
+	w.decide()
+	w.ResponseWriter.WriteHeaderNow()
+}
+
+func (w *resumableWriter) Flush() {
+	w.decide()
+	if w.client.Err() == nil {
+		w.ResponseWriter.Flush()
+	}
+}
+
+// serveResumedStream replays the events of token following the one whose id the client
+// sent as Last-Event-ID, and follows the stream until it completes or the client leaves.
+func serveResumedStream(c *gin.Context, store *resume.Store, apiKey, token string) {
+	stream, ok := store.Lookup(apiKey, token)
+	if !ok {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": gin.H{
+			"message": "unknown or expired stream resumption token",
+			"type":    "invalid_request_error",
+			"code":    resume.ErrorCodeUnknownToken,
+		}})
+		return
+	}
+	from, _ := strconv.Atoi(strings.TrimSpace(c.GetHeader(resume.LastEventHeader)))
+	if from < 0 {
+		from = 0
+	}
+	c.Abort()
+	for name, values := range stream.Header() {
+		c.Writer.Header()[name] = values
+	}
+	c.Status(http.StatusOK)
+	for {
+		events, done, changed := stream.Next(from)
+		for _, event := range events {
+			if _, err := c.Writer.Write(event); err != nil {
+				return
+			}
+		}
+		from += len(events)
+		c.Writer.Flush()
+		if done {
+			return
+		}
+		select {
+		case <-changed:
+		case <-c.Request.Context().Done():
+			return
+		}
+	}
+}
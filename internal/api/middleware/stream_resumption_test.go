@@ -0,0 +1,97 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/resume"
+)
+
+func TestStreamResumptionReplaysMissedEvents(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	store := resume.NewStore()
+	store.Configure(config.StreamResumptionConfig{Enable: true, TTLSeconds: 60, MaxBufferBytes: 1 << 20, MaxStreams: 4})
+
+	engine := gin.New()
+	engine.Use(func(c *gin.Context) {
+		finish, ok := ApplyStreamResumption(c, store, c.GetHeader("X-Key"))
+		if !ok {
+			return
+		}
+		defer finish()
+		c.Next()
+	})
+	client, disconnect := context.WithCancel(context.Background())
+	handlerErr := make(chan error, 1)
+	engine.POST("/v1/chat/completions", func(c *gin.Context) {
+		c.Header("Content-Type", "text/event-stream")
+		_, _ = c.Writer.Write([]byte("data: one\n\n: keep-alive\n\n"))
+		c.Writer.Flush()
+		disconnect()
+		_, _ = c.Writer.Write([]byte("data: two\n\ndata: [DO"))
+		_, _ = c.Writer.Write([]byte("NE]\n\n"))
+		handlerErr <- c.Request.Context().Err()
+	})
+
+	first := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(`{"stream":true}`)).WithContext(client)
+	req.Header.Set("X-Key", "team-a")
+	engine.ServeHTTP(first, req)
+	if err := <-handlerErr; err != nil {
+		t.Fatalf("handler context cancelled by the client disconnect: %v", err)
+	}
+	token := first.Header().Get(resume.TokenHeader)
+	if token == "" || first.Body.String() != "data: one\nid: 1\n\n: keep-alive\n\n" {
+		t.Fatalf("first response token %q body %q", token, first.Body.String())
+	}
+
+	resumed := httptest.NewRecorder()
+	req = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(`{"stream":true}`))
+	req.Header.Set("X-Key", "team-a")
+	req.Header.Set(resume.TokenHeader, token)
+	req.Header.Set(resume.LastEventHeader, "1")
+	engine.ServeHTTP(resumed, req)
+	if resumed.Code != http.StatusOK || resumed.Body.String() != "data: two\nid: 2\n\ndata: [DONE]\nid: 3\n\n" {
+		t.Fatalf("resumed = %d %q", resumed.Code, resumed.Body.String())
+	}
+	if got := resumed.Header().Get("Content-Type"); got != "text/event-stream" {
+		t.Fatalf("resumed content type = %q", got)
+	}
+
+	other := httptest.NewRecorder()
+	req = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+	req.Header.Set("X-Key", "team-b")
+	req.Header.Set(resume.TokenHeader, token)
+	engine.ServeHTTP(other, req)
+	if other.Code != http.StatusNotFound {
+		t.Fatalf("token of another key = %d", other.Code)
+	}
+}
+
+func TestStreamResumptionSkipsNonStreamResponses(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	store := resume.NewStore()
+	store.Configure(config.StreamResumptionConfig{Enable: true, TTLSeconds: 60, MaxBufferBytes: 1 << 20, MaxStreams: 4})
+	engine := gin.New()
+	engine.Use(func(c *gin.Context) {
+		finish, ok := ApplyStreamResumption(c, store, "")
+		if !ok {
+			return
+		}
+		defer finish()
+		c.Next()
+	})
+	engine.POST("/v1/chat/completions", func(c *gin.Context) {
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "quota"})
+	})
+	rec := httptest.NewRecorder()
+	engine.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(`{"stream":true}`)))
+	if rec.Code != http.StatusTooManyRequests || rec.Header().Get(resume.TokenHeader) != "" {
+		t.Fatalf("error response = %d token %q", rec.Code, rec.Header().Get(resume.TokenHeader))
+	}
+}
@@ -0,0 +1,140 @@
+package middleware
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/routing"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/tempkey"
+	sdkaccess "github.com/router-for-me/CLIProxyAPI/v6/sdk/access"
+)
+
+// AuthenticateTemporaryKey authenticates a request presenting a temporary key as the key's
+// parent, which access must still accept. It returns nil, nil when the request carries no
+// temporary key. Unknown and expired keys fail with sdkaccess.ErrInvalidCredential,
+// exhausted ones with tempkey.ErrQuotaExceeded. The request counts against the key's quota
+// only once ApplyTemporaryKey admits it.
+func AuthenticateTemporaryKey(c *gin.Context, store *tempkey.Store, access *sdkaccess.Manager) (*sdkaccess.Result, *tempkey.Grant, error) {
+	if !store.Enabled() {
+		return nil, nil, nil
+	}
+	key := presentedTemporaryKey(c.Request)
+	if key == "" {
+		return nil, nil, nil
+	}
+	parent, grant, err := store.Lookup(key)
+	switch {
+	case errors.Is(err, tempkey.ErrQuotaExceeded):
+		return nil, nil, err
+	case err != nil:
+		return nil, nil, fmt.Errorf("%w: %v", sdkaccess.ErrInvalidCredential, err)
+	}
+	probe := (&http.Request{Method: c.Request.Method, URL: c.Request.URL, Header: http.Header{}}).WithContext(c.Request.Context())
+	probe.Header.Set("Authorization", "Bearer "+parent)
+	if probe.URL != nil {
+		u := *probe.URL
+		u.RawQuery = ""
+		probe.URL = &u
+	}
+	result, err := access.Authenticate(c.Request.Context(), probe)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: parent key rejected", sdkaccess.ErrInvalidCredential)
+	}
+	if result == nil {
+		result = &sdkaccess.Result{Principal: parent}
+	}
+	metadata := make(map[string]string, len(result.Metadata)+1)
+	for k, v := range result.Metadata {
+		metadata[k] = v
+	}
+	metadata["temporary_key"] = grant.ID
+	result = &sdkaccess.Result{Provider: result.Provider, Principal: result.Principal, Metadata: metadata}
+
+	c.Set(tempkey.ContextKey, grant.ID)
+	c.Request = c.Request.WithContext(tempkey.WithID(c.Request.Context(), grant.ID))
+	return result, &grant, nil
+}
+
+// ApplyTemporaryKey rejects requests of a temporary key for models outside its grant and
+// counts the admitted ones against the key's quota. A POST or websocket upgrade whose
+// model cannot be determined is rejected too, so a grant limited to models cannot reach
+// others through bodies that nest or hide the model. It returns false after aborting the
+// request.
+func ApplyTemporaryKey(c *gin.Context, store *tempkey.Store, grant *tempkey.Grant) bool {
+	if grant == nil {
+		return true
+	}
+	if !allowTemporaryKeyModel(c, grant) {
+		return false
+	}
+	switch err := store.Commit(grant.ID); {
+	case errors.Is(err, tempkey.ErrQuotaExceeded):
+		c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": "Temporary key quota exceeded"})
+		return false
+	case err != nil:
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid API key"})
+		return false
+	}
+	return true
+}
+
+func allowTemporaryKeyModel(c *gin.Context, grant *tempkey.Grant) bool {
+	if len(grant.Models) == 0 {
+		return true
+	}
+	model := requestModel(c)
+	upgrade := strings.EqualFold(c.GetHeader("Upgrade"), "websocket")
+	if model == "" && c.Request.Method != http.MethodPost && !upgrade {
+		return true
+	}
+	if model == "" {
+		routing.Trace(c, "temporary key %s: model of the request unknown", grant.ID)
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": gin.H{
+			"message": "this temporary key is limited to specific models and the request names none",
+			"type":    "permission_error",
+			"code":    "model_not_allowed",
+		}})
+		return false
+	}
+	if grant.AllowsModel(model) {
+		return true
+	}
+	routing.Trace(c, "temporary key %s: model %s not allowed", grant.ID, model)
+	c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": gin.H{
+		"message": fmt.Sprintf("model %s is not allowed for this temporary key", model),
+		"type":    "permission_error",
+		"code":    "model_not_allowed",
+	}})
+	return false
+}
+
+// presentedTemporaryKey returns the temporary key of r from the headers and query
+// parameters the API key providers read, or "".
+func presentedTemporaryKey(r *http.Request) string {
+	candidates := []string{
+		bearerToken(r.Header.Get("Authorization")),
+		r.Header.Get("X-Goog-Api-Key"),
+		r.Header.Get("X-Api-Key"),
+	}
+	if r.URL != nil {
+		query := r.URL.Query()
+		candidates = append(candidates, query.Get("key"), query.Get("auth_token"))
+	}
+	for _, candidate := range candidates {
+		if candidate = strings.TrimSpace(candidate); strings.HasPrefix(candidate, tempkey.Prefix) {
+			return candidate
+		}
+	}
+	return ""
+}
+
+func bearerToken(header string) string {
+	parts := strings.SplitN(header, " ", 2)
+	if len(parts) == 2 && strings.EqualFold(parts[0], "bearer") {
+		return strings.TrimSpace(parts[1])
+	}
+	return header
+}
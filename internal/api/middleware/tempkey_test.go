@@ -0,0 +1,83 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/tempkey"
+)
+
+func newTemporaryKey(t *testing.T, models ...string) (*tempkey.Store, *tempkey.Grant) {
+	t.Helper()
+	store := tempkey.NewStore()
+	cfg := &config.Config{TemporaryKeys: config.TemporaryKeysConfig{Enable: true}}
+	cfg.SanitizeTemporaryKeys()
+	store.Configure(cfg.TemporaryKeys)
+	key, _, err := store.Mint("parent-key", tempkey.MintRequest{TTLSeconds: 60, Models: models, MaxRequests: 10})
+	if err != nil {
+		t.Fatalf("Mint: %v", err)
+	}
+	_, grant, err := store.Lookup(key)
+	if err != nil {
+		t.Fatalf("Lookup: %v", err)
+	}
+	return store, &grant
+}
+
+func TestApplyTemporaryKeyFailsClosedWithoutAModel(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	store, grant := newTemporaryKey(t, "gpt-5*")
+	for _, tc := range []struct {
+		method  string
+		body    string
+		upgrade bool
+		want    bool
+	}{
+		{method: http.MethodPost, body: `{"model":"gpt-5-mini"}`, want: true},
+		{method: http.MethodPost, body: `{"model":"claude-sonnet-4"}`, want: false},
+		{method: http.MethodPost, body: `{"dialect":"claude","request":{"model":"claude-sonnet-4"}}`, want: false},
+		{method: http.MethodGet, want: true},
+		{method: http.MethodGet, upgrade: true, want: false},
+	} {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(tc.method, "/v1/chat/completions", strings.NewReader(tc.body))
+		if tc.upgrade {
+			c.Request.Header.Set("Upgrade", "websocket")
+		}
+		if got := ApplyTemporaryKey(c, store, grant); got != tc.want {
+			t.Fatalf("%s %s: allowed = %v, want %v", tc.method, tc.body, got, tc.want)
+		}
+		if !tc.want && w.Code != http.StatusForbidden {
+			t.Fatalf("%s %s: status = %d", tc.method, tc.body, w.Code)
+		}
+	}
+}
+
+func TestApplyTemporaryKeyCountsOnlyAdmittedRequests(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	store, grant := newTemporaryKey(t, "gpt-5*")
+	apply := func(body string) bool {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(body))
+		return ApplyTemporaryKey(c, store, grant)
+	}
+	requests := func() int64 { return store.List("parent-key")[0].Requests }
+
+	if apply(`{"model":"claude-sonnet-4"}`) || apply(`{}`) {
+		t.Fatal("request for a model outside the grant was admitted")
+	}
+	if got := requests(); got != 0 {
+		t.Fatalf("requests after rejected models = %d, want 0", got)
+	}
+	if !apply(`{"model":"gpt-5-mini"}`) {
+		t.Fatal("request for a granted model was rejected")
+	}
+	if got := requests(); got != 1 {
+		t.Fatalf("requests after an admitted model = %d, want 1", got)
+	}
+}
@@ -0,0 +1,159 @@
+package middleware
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/project"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/transcript"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	log "github.com/sirupsen/logrus"
+	"github.com/tidwall/gjson"
+)
+
+// transcriptWriter tees the response body into a bounded buffer and, for streams when the
+// store tees them, into the stream's tee file.
+type transcriptWriter struct {
+	gin.ResponseWriter
+	body      bytes.Buffer
+	limit     int
+	truncated bool
+
+	// startTee opens the tee file on the first write of a streamed response; nil when
+	// streams are not teed.
+	startTee func() *transcript.Tee
+	tee      *transcript.Tee
+}
+
+func (w *transcriptWriter) Write(data []byte) (int, error) {
+	n, err := w.ResponseWriter.Write(data)
+	w.capture(data[:n])
+	return n, err
+}
+
+func (w *transcriptWriter) WriteString(s string) (int, error) {
+	n, err := w.ResponseWriter.WriteString(s)
+	w.capture([]byte(s[:n]))
+	return n, err
+}
+
+func (w *transcriptWriter) capture(data []byte) {
+	if w.startTee != nil {
+		if strings.HasPrefix(w.Header().Get("Content-Type"), "text/event-stream") {
+			w.tee = w.startTee()
+		}
+		w.startTee = nil
+	}
+	remaining := w.limit - w.body.Len()
+	if remaining <= 0 {
+		if len(data) > 0 {
+			w.truncated = true
+		}
+		return
+	}
+	if len(data) > remaining {
+		data = data[:remaining]
+		w.truncated = true
+	}
+	w.body.Write(data)
+	w.tee.Write(data)
+}
+
+// TranscriptMiddleware records proxied exchanges into the transcript store when it is enabled.
+// Sessions are keyed by the configured session header, falling back to the client API key.
+// With stream-tee, streamed responses are also written to disk as they flow.
+func TranscriptMiddleware(store *transcript.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if store == nil || !store.Enabled() || c.Request.Method != http.MethodPost || !shouldLogRequest(c.Request.URL.Path) {
+			c.Next()
+			return
+		}
+
+		limit := store.MaxBodyBytes()
+		// Only the captured prefix is buffered; the handler streams the rest of the body.
+		capturedRequest, complete, err := peekBody(c, limit)
+		if err != nil {
+			c.Next()
+			return
+		}
+		requestTruncated := !complete
+		sessionID := strings.TrimSpace(c.GetHeader(store.SessionHeader()))
+		start := time.Now()
+
+		// describe builds the exchange; the API key and project are known once the auth
+		// middleware ran.
+		describe := func() transcript.Entry {
+			apiKey := ""
+			if value, exists := c.Get("apiKey"); exists {
+				if s, ok := value.(string); ok {
+					apiKey = s
+				}
+			}
+			session := sessionID
+			if session == "" {
+				session = transcript.SessionIDForKey(apiKey)
+			}
+			projectName := c.GetString(project.ContextKey)
+			return transcript.Entry{
+				ID:          fmt.Sprintf("%d", start.UnixNano()),
+				SessionID:   transcript.ScopeSessionID(projectName, session),
+				Project:     projectName,
+				APIKey:      util.HideAPIKey(apiKey),
+				Method:      c.Request.Method,
+				Path:        util.MaskSensitivePath(c.Request.URL.Path),
+				Model:       transcriptModel(c.Request.URL.Path, capturedRequest),
+				Streaming:   true,
+				RequestedAt: start,
+				Request:     string(capturedRequest),
+				Truncated:   requestTruncated,
+			}
+		}
+
+		writer := &transcriptWriter{ResponseWriter: c.Writer, limit: limit}
+		if store.StreamTee() {
+			writer.startTee = func() *transcript.Tee {
+				tee, err := store.StartTee(describe())
+				if err != nil {
+					log.Warnf("transcript: failed to tee stream: %v", err)
+				}
+				return tee
+			}
+		}
+		c.Writer = writer
+
+		c.Next()
+
+		entry := describe()
+		entry.StatusCode = writer.Status()
+		entry.Streaming = strings.HasPrefix(writer.Header().Get("Content-Type"), "text/event-stream")
+		entry.DurationMs = time.Since(start).Milliseconds()
+		entry.Response = writer.body.String()
+		entry.Truncated = requestTruncated || writer.truncated
+		if err := store.Append(entry); err != nil {
+			log.Warnf("transcript: failed to store exchange: %v", err)
+			writer.tee.Detach()
+			return
+		}
+		writer.tee.Close()
+	}
+}
+
+// transcriptModel reads the model from the JSON body, falling back to Gemini-style "models/<name>:action" paths.
+func transcriptModel(path string, body []byte) string {
+	if model := gjson.GetBytes(body, "model").String(); model != "" {
+		return model
+	}
+	idx := strings.Index(path, "models/")
+	if idx < 0 {
+		return ""
+	}
+	model := path[idx+len("models/"):]
+	if colon := strings.Index(model, ":"); colon >= 0 {
+		model = model[:colon]
+	}
+	return model
+}
@@ -0,0 +1,40 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/routing"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/vhost"
+	sdkaccess "github.com/router-for-me/CLIProxyAPI/v6/sdk/access"
+	log "github.com/sirupsen/logrus"
+)
+
+// VirtualHostAccess records the virtual host selected by vhost.Handler on the gin context and
+// returns the access manager authenticating the request: the virtual host's own API keys when
+// it has any, else manager.
+func VirtualHostAccess(c *gin.Context, registry *vhost.Registry, manager *sdkaccess.Manager) *sdkaccess.Manager {
+	name := vhost.FromContext(c.Request.Context())
+	if name == "" {
+		return manager
+	}
+	c.Set(vhost.ContextKey, name)
+	if access := registry.Access(name); access != nil {
+		return access
+	}
+	return manager
+}
+
+// ScopeVirtualHost applies the model mappings of the request's virtual host.
+func ScopeVirtualHost(c *gin.Context, registry *vhost.Registry) {
+	name := c.GetString(vhost.ContextKey)
+	if name == "" {
+		return
+	}
+	rewriteRequestModel(c, func(model string) string {
+		mapped := registry.MapModel(name, model)
+		if mapped != "" {
+			log.Debugf("virtual host %s: model mapping %s -> %s", name, model, mapped)
+			routing.Trace(c, "virtual host %s: model mapping %s -> %s", name, model, mapped)
+		}
+		return mapped
+	})
+}
@@ -0,0 +1,38 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/moderation"
+	log "github.com/sirupsen/logrus"
+)
+
+// moderationsHandler serves /v1/moderations. In forward mode the request is relayed upstream;
+// upstream failures and the local mode are answered by the configured moderation rules.
+func (s *Server) moderationsHandler(c *gin.Context) {
+	body, err := c.GetRawData()
+	if err != nil {
+		openAIUsageError(c, "invalid request body")
+		return
+	}
+	classifier := moderation.Default()
+	if classifier.Forwarding() {
+		status, payload, errForward := classifier.Forward(c.Request.Context(), body)
+		if errForward == nil && status < http.StatusInternalServerError {
+			c.Data(status, "application/json", payload)
+			return
+		}
+		if errForward != nil {
+			log.Warnf("moderation: forward failed, using local rules: %v", errForward)
+		} else {
+			log.Warnf("moderation: upstream returned %d, using local rules", status)
+		}
+	}
+	resp, ok := classifier.Moderate(body)
+	if !ok {
+		openAIUsageError(c, "input must be a string, an array of strings or an array of content parts")
+		return
+	}
+	c.JSON(http.StatusOK, resp)
+}
@@ -105,6 +105,7 @@ type FallbackHandler struct {
 // NewFallbackHandler 创建一个新的回退处理器包装器
 // 参数：
 //   - getProxy: 获取反向代理的函数，支持延迟初始化（在路由创建后创建代理时很有用）
+//
 // 返回：新的FallbackHandler实例
 func NewFallbackHandler(getProxy func() *httputil.ReverseProxy) *FallbackHandler {
 	return &FallbackHandler{
@@ -118,6 +119,7 @@ func NewFallbackHandler(getProxy func() *httputil.ReverseProxy) *FallbackHandler
 //   - getProxy: 获取反向代理的函数
 //   - mapper: 模型映射器实例
 //   - forceModelMappings: 是否强制使用模型映射的函数
+//
 // 返回：新的FallbackHandler实例
 func NewFallbackHandlerWithMapper(getProxy func() *httputil.ReverseProxy, mapper ModelMapper, forceModelMappings func() bool) *FallbackHandler {
 	// 如果forceModelMappings为nil，设置默认值为false
@@ -142,6 +144,7 @@ func (fh *FallbackHandler) SetModelMapper(mapper ModelMapper) {
 // 如果模型的提供商在CLIProxyAPI中未配置，则转发到ampcode.com
 // 参数：
 //   - handler: 原始的Gin处理器函数
+//
 // 返回：包装后的处理器函数
 func (fh *FallbackHandler) WrapHandler(handler gin.HandlerFunc) gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -333,6 +336,7 @@ func filterAntropicBetaHeader(c *gin.Context) {
 // 参数：
 //   - body: 原始请求体字节
 //   - newModel: 新的模型名称
+//
 // 返回：修改后的请求体字节
 func rewriteModelInRequest(body []byte, newModel string) []byte {
 	// 检查请求体中是否存在"model"字段
@@ -352,9 +356,11 @@ func rewriteModelInRequest(body []byte, newModel string) []byte {
 // 支持的格式：
 //   - JSON请求体中的"model"字段（OpenAI、Claude等）
 //   - URL路径中的模型名称（Gemini）
+//
 // 参数：
 //   - body: 请求体字节
 //   - c: Gin上下文
+//
 // 返回：提取的模型名称，如果无法提取则返回空字符串
 func extractModelFromRequest(body []byte, c *gin.Context) string {
 	// 首先尝试从JSON请求体解析（OpenAI、Claude等）
@@ -388,4 +394,3 @@ func extractModelFromRequest(body []byte, c *gin.Context) string {
 
 	return ""
 }
-
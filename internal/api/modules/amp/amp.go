@@ -5,12 +5,16 @@ package amp
 import (
 	"fmt"
 	"net/http/httputil"
+	"net/url"
+	"reflect"
 	"strings"
 	"sync"
+	"sync/atomic"
 
 	"github.com/gin-gonic/gin"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/api/modules"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/offline"
 	sdkaccess "github.com/router-for-me/CLIProxyAPI/v6/sdk/access"
 	log "github.com/sirupsen/logrus"
 )
@@ -41,6 +45,15 @@ type AmpModule struct {
 	// configMu protects lastConfig for partial reload comparison
 	configMu   sync.RWMutex
 	lastConfig *config.AmpCode
+
+	// creditRewrite post-processes responses served by ampcode.com (hot-reloadable)
+	creditRewrite atomic.Pointer[creditRewrite]
+
+	// creditPricing estimates the credits spent on AMP_CREDITS requests (hot-reloadable)
+	creditPricing atomic.Pointer[creditPricing]
+
+	// errorFallbackBudget counts today's fallbacks of failed local requests to ampcode.com
+	errorFallbackBudget *errorFallbackBudget
 }
 
 // New creates a new Amp routing module with the given options.
@@ -55,7 +68,8 @@ type AmpModule struct {
 //	)
 func New(opts ...Option) *AmpModule {
 	m := &AmpModule{
-		secretSource: nil, // Will be created on demand if not provided
+		secretSource:        nil, // Will be created on demand if not provided
+		errorFallbackBudget: newErrorFallbackBudget(),
 	}
 	for _, opt := range opts {
 		opt(m)
@@ -110,6 +124,66 @@ func (m *AmpModule) forceModelMappings() bool {
 	return m.lastConfig.ForceModelMappings
 }
 
+// contextCompaction returns the compaction settings for remapped conversations.
+func (m *AmpModule) contextCompaction() config.AmpContextCompaction {
+	m.configMu.RLock()
+	defer m.configMu.RUnlock()
+	if m.lastConfig == nil {
+		return config.AmpContextCompaction{}
+	}
+	return m.lastConfig.ContextCompaction
+}
+
+// errorFallback returns the settings of the fallback to ampcode.com for failed local requests.
+func (m *AmpModule) errorFallback() config.AmpErrorFallback {
+	m.configMu.RLock()
+	defer m.configMu.RUnlock()
+	if m.lastConfig == nil {
+		return config.AmpErrorFallback{}
+	}
+	return m.lastConfig.ErrorFallback
+}
+
+// disabledModels returns the models never served by local providers.
+func (m *AmpModule) disabledModels() []string {
+	m.configMu.RLock()
+	defer m.configMu.RUnlock()
+	if m.lastConfig == nil {
+		return nil
+	}
+	return m.lastConfig.DisabledModels
+}
+
+// modelFields returns the extra body paths searched for the requested model.
+func (m *AmpModule) modelFields() []string {
+	m.configMu.RLock()
+	defer m.configMu.RUnlock()
+	if m.lastConfig == nil {
+		return nil
+	}
+	return m.lastConfig.ModelFields
+}
+
+// missingModel returns the handling of requests without an extractable model.
+func (m *AmpModule) missingModel() config.AmpMissingModel {
+	m.configMu.RLock()
+	defer m.configMu.RUnlock()
+	if m.lastConfig == nil {
+		return config.AmpMissingModel{}
+	}
+	return m.lastConfig.MissingModel
+}
+
+// noProviderResponse returns the settings of the NO_PROVIDER reply.
+func (m *AmpModule) noProviderResponse() config.AmpNoProviderResponse {
+	m.configMu.RLock()
+	defer m.configMu.RUnlock()
+	if m.lastConfig == nil {
+		return config.AmpNoProviderResponse{}
+	}
+	return m.lastConfig.NoProviderResponse
+}
+
 // Register sets up Amp routes if configured.
 // This implements the RouteModuleV2 interface with Context.
 // Routes are registered only once via sync.Once for idempotent behavior.
@@ -133,6 +207,10 @@ func (m *AmpModule) Register(ctx modules.Context) error {
 		// Initialize localhost restriction setting (hot-reloadable)
 		m.setRestrictToLocalhost(settings.RestrictManagementToLocalhost)
 
+		// Compile the response rewrite applied to AMP-credit routes (hot-reloadable)
+		m.creditRewrite.Store(newCreditRewrite(settings.CreditResponseRewrite))
+		m.creditPricing.Store(newCreditPricing(settings.CreditPricing))
+
 		// Always register provider aliases - these work without an upstream
 		m.registerProviderAliases(ctx.Engine, ctx.BaseHandler, auth)
 
@@ -177,7 +255,9 @@ func (m *AmpModule) getAuthMiddleware(ctx modules.Context) gin.HandlerFunc {
 
 // OnConfigUpdated handles configuration updates with partial reload support.
 // Only updates components that have actually changed to avoid unnecessary work.
-// Supports hot-reload for: model-mappings, upstream-api-key, upstream-url, restrict-management-to-localhost.
+// Supports hot-reload for: model-mappings, upstream-api-key, upstream-url, fallback-upstreams,
+// upstream-headers, credit-response-rewrite, credit-pricing, no-provider-response,
+// restrict-management-to-localhost.
 func (m *AmpModule) OnConfigUpdated(cfg *config.Config) error {
 	newSettings := cfg.AmpCode
 
@@ -190,16 +270,25 @@ func (m *AmpModule) OnConfigUpdated(cfg *config.Config) error {
 		m.setRestrictToLocalhost(newSettings.RestrictManagementToLocalhost)
 	}
 
+	if oldSettings == nil || !reflect.DeepEqual(oldSettings.CreditResponseRewrite, newSettings.CreditResponseRewrite) {
+		m.creditRewrite.Store(newCreditRewrite(newSettings.CreditResponseRewrite))
+	}
+	if oldSettings == nil || !reflect.DeepEqual(oldSettings.CreditPricing, newSettings.CreditPricing) {
+		m.creditPricing.Store(newCreditPricing(newSettings.CreditPricing))
+	}
+
 	newUpstreamURL := strings.TrimSpace(newSettings.UpstreamURL)
 	oldUpstreamURL := ""
 	if oldSettings != nil {
 		oldUpstreamURL = strings.TrimSpace(oldSettings.UpstreamURL)
 	}
 
+	justEnabled := false
 	if !m.enabled && newUpstreamURL != "" {
 		if err := m.enableUpstreamProxy(newUpstreamURL, &newSettings); err != nil {
 			log.Errorf("amp config: failed to enable upstream proxy for %s: %v", newUpstreamURL, err)
 		}
+		justEnabled = true
 	}
 
 	// Check model mappings change
@@ -217,9 +306,9 @@ func (m *AmpModule) OnConfigUpdated(cfg *config.Config) error {
 		if newUpstreamURL == "" && oldUpstreamURL != "" {
 			m.setProxy(nil)
 			m.enabled = false
-		} else if oldUpstreamURL != "" && newUpstreamURL != oldUpstreamURL && newUpstreamURL != "" {
-			// Recreate proxy with new URL
-			proxy, err := createReverseProxy(newUpstreamURL, m.secretSource)
+		} else if newUpstreamURL != "" && !justEnabled && (newUpstreamURL != oldUpstreamURL || m.hasUpstreamFailoverChanged(oldSettings, &newSettings)) {
+			// Recreate proxy with new URL or failover upstreams
+			proxy, err := m.newUpstreamProxy(newUpstreamURL, &newSettings)
 			if err != nil {
 				log.Errorf("amp config: failed to create proxy for new upstream URL %s: %v", newUpstreamURL, err)
 			} else {
@@ -278,7 +367,7 @@ func (m *AmpModule) enableUpstreamProxy(upstreamURL string, settings *config.Amp
 		m.secretSource = mappedSource
 	}
 
-	proxy, err := createReverseProxy(upstreamURL, m.secretSource)
+	proxy, err := m.newUpstreamProxy(upstreamURL, settings)
 	if err != nil {
 		return err
 	}
@@ -290,6 +379,49 @@ func (m *AmpModule) enableUpstreamProxy(upstreamURL string, settings *config.Amp
 	return nil
 }
 
+// newUpstreamProxy creates the reverse proxy for upstreamURL. When fallback upstreams or
+// upstream headers are configured, an upstreamPool becomes its transport.
+func (m *AmpModule) newUpstreamProxy(upstreamURL string, settings *config.AmpCode) (*httputil.ReverseProxy, error) {
+	proxy, err := createReverseProxy(upstreamURL, m.secretSource)
+	if err != nil {
+		return nil, err
+	}
+	if proxyTuningEnabled(settings.UpstreamProxy) {
+		proxy.Transport = newTunedTransport(settings.UpstreamProxy)
+	}
+	if len(settings.FallbackUpstreams) > 0 || len(settings.UpstreamHeaders) > 0 {
+		primary, errParse := url.Parse(upstreamURL)
+		if errParse != nil {
+			return nil, fmt.Errorf("invalid amp upstream url: %w", errParse)
+		}
+		pool, errPool := newUpstreamPool(primary, settings)
+		if errPool != nil {
+			return nil, errPool
+		}
+		if proxy.Transport != nil {
+			pool.transport = proxy.Transport
+		}
+		proxy.Transport = pool
+		if len(pool.targets) > 1 {
+			log.Infof("amp upstream failover enabled with %d fallback upstream(s)", len(pool.targets)-1)
+		}
+	}
+	applyProxyTuning(proxy, settings.UpstreamProxy)
+	return proxy, nil
+}
+
+// hasUpstreamFailoverChanged compares the fallback upstreams, upstream headers, health
+// check interval and proxy tuning.
+func (m *AmpModule) hasUpstreamFailoverChanged(old *config.AmpCode, new *config.AmpCode) bool {
+	if old == nil {
+		return len(new.FallbackUpstreams) > 0 || len(new.UpstreamHeaders) > 0 || proxyTuningEnabled(new.UpstreamProxy)
+	}
+	return !reflect.DeepEqual(old.FallbackUpstreams, new.FallbackUpstreams) ||
+		!reflect.DeepEqual(old.UpstreamHeaders, new.UpstreamHeaders) ||
+		old.UpstreamHealthCheckSeconds != new.UpstreamHealthCheckSeconds ||
+		!reflect.DeepEqual(old.UpstreamProxy, new.UpstreamProxy)
+}
+
 // hasModelMappingsChanged compares old and new model mappings.
 func (m *AmpModule) hasModelMappingsChanged(old *config.AmpCode, new *config.AmpCode) bool {
 	if old == nil {
@@ -302,21 +434,27 @@ func (m *AmpModule) hasModelMappingsChanged(old *config.AmpCode, new *config.Amp
 
 	// Build map for efficient and robust comparison
 	type mappingInfo struct {
-		to    string
-		regex bool
+		to     string
+		regex  bool
+		params map[string]any
+		prompt string
+		post   []config.AmpPostProcessor
 	}
 	oldMap := make(map[string]mappingInfo, len(old.ModelMappings))
 	for _, mapping := range old.ModelMappings {
 		oldMap[strings.TrimSpace(mapping.From)] = mappingInfo{
-			to:    strings.TrimSpace(mapping.To),
-			regex: mapping.Regex,
+			to:     strings.TrimSpace(mapping.To),
+			regex:  mapping.Regex,
+			params: mapping.Params,
+			prompt: mapping.PromptAdapter,
+			post:   mapping.PostProcess,
 		}
 	}
 
 	for _, mapping := range new.ModelMappings {
 		from := strings.TrimSpace(mapping.From)
 		to := strings.TrimSpace(mapping.To)
-		if oldVal, exists := oldMap[from]; !exists || oldVal.to != to || oldVal.regex != mapping.Regex {
+		if oldVal, exists := oldMap[from]; !exists || oldVal.to != to || oldVal.regex != mapping.Regex || !reflect.DeepEqual(oldVal.params, mapping.Params) || oldVal.prompt != mapping.PromptAdapter || !reflect.DeepEqual(oldVal.post, mapping.PostProcess) {
 			return true
 		}
 	}
@@ -399,17 +537,37 @@ func (m *AmpModule) GetModelMapper() *DefaultModelMapper {
 	return m.modelMapper
 }
 
-// getProxy returns the current proxy instance (thread-safe for hot-reload).
+// getCreditRewrite returns the response rewrite for AMP-credit routes, or nil.
+func (m *AmpModule) getCreditRewrite() *creditRewrite {
+	return m.creditRewrite.Load()
+}
+
+// getCreditPricing returns the prices used to estimate AMP_CREDITS spend.
+func (m *AmpModule) getCreditPricing() *creditPricing {
+	return m.creditPricing.Load()
+}
+
+// getProxy returns the current ampcode.com proxy (thread-safe for hot-reload), or nil
+// when none is configured or offline mode is on.
 func (m *AmpModule) getProxy() *httputil.ReverseProxy {
+	if offline.Default().Enabled() {
+		return nil
+	}
 	m.proxyMu.RLock()
 	defer m.proxyMu.RUnlock()
 	return m.proxy
 }
 
-// setProxy updates the proxy instance (thread-safe for hot-reload).
+// setProxy updates the proxy instance (thread-safe for hot-reload) and stops the health
+// checks of the replaced proxy's upstream pool.
 func (m *AmpModule) setProxy(proxy *httputil.ReverseProxy) {
 	m.proxyMu.Lock()
 	defer m.proxyMu.Unlock()
+	if m.proxy != nil && m.proxy != proxy {
+		if pool, ok := m.proxy.Transport.(*upstreamPool); ok {
+			pool.Close()
+		}
+	}
 	m.proxy = proxy
 }
 
@@ -0,0 +1,252 @@
+package amp
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/thinking"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers"
+	log "github.com/sirupsen/logrus"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+const (
+	defaultCompactionReserveTokens = 2048
+	defaultCompactionSummaryTokens = 2048
+
+	compactionSystemPrompt = "You compress conversations between a user and a coding assistant. " +
+		"Summarize the transcript so the assistant can continue the work without it: keep the user's goals " +
+		"and constraints, decisions made, files and identifiers involved, tool results that still matter and " +
+		"open questions. Answer with the summary only."
+	compactionSummaryPrefix = "Summary of the earlier conversation:\n"
+)
+
+// summarizeFunc runs a non-streaming Claude messages request and returns the response body.
+type summarizeFunc func(ctx context.Context, model string, body []byte) ([]byte, error)
+
+// contextCompactor shrinks Claude conversations that a model mapping sends to a model with
+// a smaller context window, replacing the oldest turns with a summary written by the
+// configured summary model.
+type contextCompactor struct {
+	settings  func() config.AmpContextCompaction
+	summarize summarizeFunc
+}
+
+func newContextCompactor(settings func() config.AmpContextCompaction, baseHandler *handlers.BaseAPIHandler) *contextCompactor {
+	return &contextCompactor{
+		settings: settings,
+		summarize: func(ctx context.Context, model string, body []byte) ([]byte, error) {
+			resp, errMsg := baseHandler.ExecuteWithAuthManager(ctx, "claude", model, body, "")
+			if errMsg != nil {
+				return nil, errMsg.Error
+			}
+			return resp, nil
+		},
+	}
+}
+
+// compact returns body unchanged unless it is a Claude messages request that does not fit
+// the context window of targetModel, in which case the oldest turns are summarized. Errors
+// leave the body unchanged so the upstream reports the context length as before.
+func (cc *contextCompactor) compact(c *gin.Context, body []byte, requestPath, targetModel string) []byte {
+	if cc == nil || cc.settings == nil {
+		return body
+	}
+	settings := cc.settings()
+	settings.SummaryModel = strings.TrimSpace(settings.SummaryModel)
+	if settings.SummaryModel == "" || !strings.HasSuffix(requestPath, "/messages") {
+		return body
+	}
+	window := modelContextWindow(thinking.ParseSuffix(targetModel).ModelName)
+	if window <= 0 {
+		return body
+	}
+	reserve := settings.ReserveTokens
+	if reserve <= 0 {
+		reserve = defaultCompactionReserveTokens
+	}
+	summaryTokens := settings.SummaryMaxTokens
+	if summaryTokens <= 0 {
+		summaryTokens = defaultCompactionSummaryTokens
+	}
+	budget := window - int(gjson.GetBytes(body, "max_tokens").Int()) - reserve
+	messages := gjson.GetBytes(body, "messages").Array()
+	if budget <= 0 || len(messages) < 2 {
+		return body
+	}
+	fixed := countCompactionTokens(gjson.GetBytes(body, "system").Raw) + countCompactionTokens(gjson.GetBytes(body, "tools").Raw)
+	sizes := make([]int, len(messages))
+	total := fixed
+	for i, message := range messages {
+		sizes[i] = countCompactionTokens(message.Raw)
+		total += sizes[i]
+	}
+	if total <= budget {
+		return body
+	}
+	cut := compactionCut(messages, sizes, budget-fixed-summaryTokens)
+	if cut <= 0 {
+		log.Warn(logging.Msgf(logging.MsgAmpCompactionNoFit, total, targetModel, window))
+		return body
+	}
+
+	summary, err := cc.summarizeTurns(c.Request.Context(), settings.SummaryModel, messages[:cut], summaryTokens)
+	if err != nil {
+		log.Warnf("amp context compaction: summarizing with %s failed: %v", settings.SummaryModel, err)
+		return body
+	}
+	compacted, err := replaceWithSummary(body, messages[cut:], summary)
+	if err != nil {
+		log.Warnf("amp context compaction: rebuilding request failed: %v", err)
+		return body
+	}
+	log.Infof("amp context compaction: summarized %d of %d messages (~%d tokens) for %s (%d tokens)", cut, len(messages), total, targetModel, window)
+	return compacted
+}
+
+// compactionCut returns the index of the first message kept: the newest messages fitting
+// budget, starting at a user turn that does not answer a tool call of a compacted turn.
+func compactionCut(messages []gjson.Result, sizes []int, budget int) int {
+	kept := 0
+	start := len(messages)
+	for start > 0 && kept+sizes[start-1] <= budget {
+		start--
+		kept += sizes[start]
+	}
+	for cut := start; cut < len(messages); cut++ {
+		if cut > 0 && isCompactionBoundary(messages[cut]) {
+			return cut
+		}
+	}
+	return 0
+}
+
+// isCompactionBoundary reports whether a conversation can resume at message.
+func isCompactionBoundary(message gjson.Result) bool {
+	if message.Get("role").String() != "user" {
+		return false
+	}
+	boundary := true
+	message.Get("content").ForEach(func(_, block gjson.Result) bool {
+		if block.Get("type").String() == "tool_result" {
+			boundary = false
+		}
+		return boundary
+	})
+	return boundary
+}
+
+func (cc *contextCompactor) summarizeTurns(ctx context.Context, model string, turns []gjson.Result, maxTokens int) (string, error) {
+	request := []byte(`{"model":"","max_tokens":0,"system":"","messages":[{"role":"user","content":""}]}`)
+	request, _ = sjson.SetBytes(request, "model", model)
+	request, _ = sjson.SetBytes(request, "max_tokens", maxTokens)
+	request, _ = sjson.SetBytes(request, "system", compactionSystemPrompt)
+	request, _ = sjson.SetBytes(request, "messages.0.content", renderTranscript(turns))
+	resp, err := cc.summarize(ctx, model, request)
+	if err != nil {
+		return "", err
+	}
+	var parts []string
+	gjson.GetBytes(resp, "content").ForEach(func(_, block gjson.Result) bool {
+		if block.Get("type").String() == "text" {
+			parts = append(parts, block.Get("text").String())
+		}
+		return true
+	})
+	summary := strings.TrimSpace(strings.Join(parts, "\n"))
+	if summary == "" {
+		return "", fmt.Errorf("empty summary")
+	}
+	return summary, nil
+}
+
+// renderTranscript writes Claude messages as plain text for the summary model.
+func renderTranscript(turns []gjson.Result) string {
+	var b strings.Builder
+	for _, turn := range turns {
+		b.WriteString(turn.Get("role").String())
+		b.WriteString(":\n")
+		content := turn.Get("content")
+		if content.Type == gjson.String {
+			b.WriteString(content.String())
+			b.WriteString("\n")
+		}
+		content.ForEach(func(_, block gjson.Result) bool {
+			switch block.Get("type").String() {
+			case "text":
+				b.WriteString(block.Get("text").String())
+			case "tool_use":
+				fmt.Fprintf(&b, "[tool call %s: %s]", block.Get("name").String(), block.Get("input").Raw)
+			case "tool_result":
+				b.WriteString("[tool result: ")
+				if result := block.Get("content"); result.Type == gjson.String {
+					b.WriteString(result.String())
+				} else {
+					result.ForEach(func(_, part gjson.Result) bool {
+						b.WriteString(part.Get("text").String())
+						return true
+					})
+				}
+				b.WriteString("]")
+			default:
+				return true
+			}
+			b.WriteString("\n")
+			return true
+		})
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// replaceWithSummary keeps the kept messages, the first of which gets the summary
+// prepended as a text block.
+func replaceWithSummary(body []byte, kept []gjson.Result, summary string) ([]byte, error) {
+	first := []byte(kept[0].Raw)
+	block := map[string]string{"type": "text", "text": compactionSummaryPrefix + summary}
+	content := kept[0].Get("content")
+	blocks := []byte(`[]`)
+	blocks, _ = sjson.SetBytes(blocks, "-1", block)
+	if content.Type == gjson.String {
+		blocks, _ = sjson.SetBytes(blocks, "-1", map[string]string{"type": "text", "text": content.String()})
+	} else {
+		for _, existing := range content.Array() {
+			blocks, _ = sjson.SetRawBytes(blocks, "-1", []byte(existing.Raw))
+		}
+	}
+	first, err := sjson.SetRawBytes(first, "content", blocks)
+	if err != nil {
+		return nil, err
+	}
+	messages := []byte(`[]`)
+	messages, _ = sjson.SetRawBytes(messages, "-1", first)
+	for _, message := range kept[1:] {
+		messages, _ = sjson.SetRawBytes(messages, "-1", []byte(message.Raw))
+	}
+	return sjson.SetRawBytes(body, "messages", messages)
+}
+
+// modelContextWindow returns the input context size of model, or 0 when unknown.
+func modelContextWindow(model string) int {
+	info := registry.LookupModelInfo(model)
+	if info == nil {
+		return 0
+	}
+	if info.InputTokenLimit > 0 {
+		return info.InputTokenLimit
+	}
+	return info.ContextLength
+}
+
+// countCompactionTokens estimates the tokens of raw JSON. Counting the JSON rather than the
+// text overestimates a little, which errs on the side of compacting.
+func countCompactionTokens(raw string) int {
+	return int(util.CountTokens(raw))
+}
@@ -0,0 +1,85 @@
+package amp
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+func TestContextCompactorSummarizesOldestTurns(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	reg := registry.GetGlobalRegistry()
+	reg.RegisterClient("test-client-amp-compaction", "gemini", []*registry.ModelInfo{
+		{ID: "small-context-model", OwnedBy: "google", Type: "gemini", InputTokenLimit: 3000},
+	})
+	defer reg.UnregisterClient("test-client-amp-compaction")
+
+	body := []byte(`{"model":"small-context-model","max_tokens":500,"messages":[]}`)
+	filler := strings.Repeat("lorem ipsum dolor sit amet ", 300)
+	turns := []string{
+		`{"role":"user","content":"first question ` + filler + `"}`,
+		`{"role":"assistant","content":[{"type":"tool_use","id":"t1","name":"read","input":{"path":"a.go"}}]}`,
+		`{"role":"user","content":[{"type":"tool_result","tool_use_id":"t1","content":"` + filler + `"}]}`,
+		`{"role":"assistant","content":"` + filler + `"}`,
+		`{"role":"user","content":"latest question"}`,
+	}
+	for _, turn := range turns {
+		body, _ = sjson.SetRawBytes(body, "messages.-1", []byte(turn))
+	}
+
+	var summaryRequest []byte
+	compactor := &contextCompactor{
+		settings: func() config.AmpContextCompaction {
+			return config.AmpContextCompaction{SummaryModel: "cheap-model", ReserveTokens: 100, SummaryMaxTokens: 200}
+		},
+		summarize: func(_ context.Context, model string, req []byte) ([]byte, error) {
+			if model != "cheap-model" {
+				t.Fatalf("summary model = %s", model)
+			}
+			summaryRequest = req
+			return []byte(`{"content":[{"type":"text","text":"User asked about a.go."}]}`), nil
+		},
+	}
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest("POST", "/api/provider/anthropic/v1/messages", nil)
+
+	out := compactor.compact(c, body, c.Request.URL.Path, "small-context-model")
+	messages := gjson.GetBytes(out, "messages").Array()
+	if len(messages) != 1 {
+		t.Fatalf("messages = %d, want 1: %s", len(messages), out)
+	}
+	if got := messages[0].Get("content.0.text").String(); got != compactionSummaryPrefix+"User asked about a.go." {
+		t.Fatalf("summary block = %q", got)
+	}
+	if got := messages[0].Get("content.1.text").String(); got != "latest question" {
+		t.Fatalf("kept text = %q", got)
+	}
+	transcript := gjson.GetBytes(summaryRequest, "messages.0.content").String()
+	if !strings.Contains(transcript, "[tool call read:") || !strings.Contains(transcript, "first question") {
+		t.Fatalf("transcript = %q", transcript)
+	}
+
+	// Conversations that fit are left alone.
+	small := []byte(`{"max_tokens":500,"messages":[{"role":"user","content":"hi"},{"role":"assistant","content":"hello"}]}`)
+	if got := compactor.compact(c, small, c.Request.URL.Path, "small-context-model"); string(got) != string(small) {
+		t.Fatalf("fitting conversation changed: %s", got)
+	}
+}
+
+func TestCompactionCutSkipsToolResults(t *testing.T) {
+	messages := gjson.Parse(`[{"role":"user","content":"a"},{"role":"assistant","content":"b"},{"role":"user","content":[{"type":"tool_result","content":"c"}]},{"role":"assistant","content":"d"},{"role":"user","content":"e"}]`).Array()
+	sizes := []int{10, 10, 10, 10, 10}
+	if got := compactionCut(messages, sizes, 30); got != 4 {
+		t.Fatalf("cut = %d, want 4", got)
+	}
+	if got := compactionCut(messages, sizes, 5); got != 0 {
+		t.Fatalf("cut = %d, want 0 when even the last turn does not fit", got)
+	}
+}
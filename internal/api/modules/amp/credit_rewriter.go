@@ -0,0 +1,191 @@
+package amp
+
+import (
+	"bytes"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	log "github.com/sirupsen/logrus"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+type creditReplacement struct {
+	re   *regexp.Regexp
+	with []byte
+}
+
+// creditRewrite is the compiled form of ampcode.credit-response-rewrite.
+type creditRewrite struct {
+	aliases map[string]string
+	remove  []string
+	replace []creditReplacement
+}
+
+// newCreditRewrite compiles cfg. It returns nil when no rewrite is configured.
+func newCreditRewrite(cfg config.AmpResponseRewrite) *creditRewrite {
+	rewrite := &creditRewrite{aliases: make(map[string]string, len(cfg.ModelAliases))}
+	for from, to := range cfg.ModelAliases {
+		if from, to = strings.TrimSpace(from), strings.TrimSpace(to); from != "" && to != "" {
+			rewrite.aliases[from] = to
+		}
+	}
+	for _, path := range cfg.RemoveFields {
+		if path = strings.TrimSpace(path); path != "" {
+			rewrite.remove = append(rewrite.remove, path)
+		}
+	}
+	for _, replacement := range cfg.Replace {
+		re, err := regexp.Compile(replacement.Pattern)
+		if err != nil || replacement.Pattern == "" {
+			log.Warnf("amp credit response rewrite: invalid pattern %q: %v", replacement.Pattern, err)
+			continue
+		}
+		rewrite.replace = append(rewrite.replace, creditReplacement{re: re, with: []byte(replacement.With)})
+	}
+	if len(rewrite.aliases) == 0 && len(rewrite.remove) == 0 && len(rewrite.replace) == 0 {
+		return nil
+	}
+	return rewrite
+}
+
+// apply rewrites one JSON document (a response body or an SSE data payload).
+func (r *creditRewrite) apply(data []byte) []byte {
+	for _, replacement := range r.replace {
+		data = replacement.re.ReplaceAll(data, replacement.with)
+	}
+	if !gjson.ValidBytes(data) {
+		return data
+	}
+	for _, path := range modelFieldPaths {
+		if alias, ok := r.aliases[gjson.GetBytes(data, path).String()]; ok {
+			data, _ = sjson.SetBytes(data, path, alias)
+		}
+	}
+	for _, path := range r.remove {
+		if gjson.GetBytes(data, path).Exists() {
+			data, _ = sjson.DeleteBytes(data, path)
+		}
+	}
+	return data
+}
+
+// creditResponseWriter applies a creditRewrite to a response proxied from the Amp upstream.
+// JSON bodies are buffered and rewritten once complete; event streams are rewritten line
+// by line; other content types pass through untouched.
+type creditResponseWriter struct {
+	gin.ResponseWriter
+	rewrite   *creditRewrite
+	mode      int
+	body      bytes.Buffer
+	remainder []byte
+}
+
+const (
+	creditModeUndecided = iota
+	creditModePassthrough
+	creditModeBuffered
+	creditModeStream
+)
+
+func newCreditResponseWriter(w gin.ResponseWriter, rewrite *creditRewrite) *creditResponseWriter {
+	return &creditResponseWriter{ResponseWriter: w, rewrite: rewrite}
+}
+
+func (w *creditResponseWriter) decide() {
+	if w.mode != creditModeUndecided {
+		return
+	}
+	header := w.ResponseWriter.Header()
+	contentType := header.Get("Content-Type")
+	switch {
+	case header.Get("Content-Encoding") != "":
+		w.mode = creditModePassthrough
+	case strings.Contains(contentType, "text/event-stream"):
+		w.mode = creditModeStream
+	case strings.Contains(contentType, "json"):
+		w.mode = creditModeBuffered
+	default:
+		w.mode = creditModePassthrough
+	}
+	if w.mode != creditModePassthrough {
+		// The body length changes with the rewrite.
+		header.Del("Content-Length")
+	}
+}
+
+// WriteHeader implements http.ResponseWriter.
+func (w *creditResponseWriter) WriteHeader(code int) {
+	w.decide()
+	w.ResponseWriter.WriteHeader(code)
+}
+
+// Write implements http.ResponseWriter.
+func (w *creditResponseWriter) Write(data []byte) (int, error) {
+	w.decide()
+	switch w.mode {
+	case creditModeBuffered:
+		return w.body.Write(data)
+	case creditModeStream:
+		buf := append(w.remainder, data...)
+		cut := bytes.LastIndexByte(buf, '\n')
+		if cut < 0 {
+			w.remainder = buf
+			return len(data), nil
+		}
+		w.remainder = append([]byte(nil), buf[cut+1:]...)
+		if _, err := w.ResponseWriter.Write(w.rewriteLines(buf[:cut+1])); err != nil {
+			return 0, err
+		}
+		return len(data), nil
+	default:
+		return w.ResponseWriter.Write(data)
+	}
+}
+
+// Flush implements http.Flusher. Buffered bodies are only written by finish.
+func (w *creditResponseWriter) Flush() {
+	if w.mode != creditModeBuffered {
+		w.ResponseWriter.Flush()
+	}
+}
+
+// rewriteLines rewrites the JSON payload of every "data:" line in complete SSE lines.
+func (w *creditResponseWriter) rewriteLines(chunk []byte) []byte {
+	lines := bytes.SplitAfter(chunk, []byte("\n"))
+	out := make([]byte, 0, len(chunk))
+	for _, line := range lines {
+		payload, ok := bytes.CutPrefix(line, []byte("data:"))
+		if !ok {
+			out = append(out, line...)
+			continue
+		}
+		rest := bytes.TrimLeft(payload, " \t")
+		core := bytes.TrimRight(rest, " \t\r\n")
+		out = append(out, "data:"...)
+		out = append(out, payload[:len(payload)-len(rest)]...)
+		out = append(out, w.rewrite.apply(core)...)
+		out = append(out, rest[len(core):]...)
+	}
+	return out
+}
+
+// finish writes what is still buffered. It must be called after the proxy returns.
+func (w *creditResponseWriter) finish() {
+	switch w.mode {
+	case creditModeBuffered:
+		if _, err := w.ResponseWriter.Write(w.rewrite.apply(w.body.Bytes())); err != nil {
+			log.Warnf("amp credit response rewrite: failed to write response: %v", err)
+		}
+	case creditModeStream:
+		if len(w.remainder) > 0 {
+			_, _ = w.ResponseWriter.Write(w.rewriteLines(w.remainder))
+			w.remainder = nil
+		}
+	}
+}
+
+var _ http.Flusher = (*creditResponseWriter)(nil)
@@ -0,0 +1,76 @@
+package amp
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+func TestNewCreditRewrite_EmptyConfig(t *testing.T) {
+	if rw := newCreditRewrite(config.AmpResponseRewrite{}); rw != nil {
+		t.Fatalf("expected nil rewrite for empty config, got %+v", rw)
+	}
+	if rw := newCreditRewrite(config.AmpResponseRewrite{Replace: []config.AmpResponseReplacement{{Pattern: "("}}}); rw != nil {
+		t.Fatalf("expected invalid patterns to be skipped, got %+v", rw)
+	}
+}
+
+func TestCreditRewrite_Apply(t *testing.T) {
+	rw := newCreditRewrite(config.AmpResponseRewrite{
+		ModelAliases: map[string]string{"claude-sonnet-4-20250514": "sonnet"},
+		RemoveFields: []string{"billing"},
+		Replace:      []config.AmpResponseReplacement{{Pattern: `Amp credits used: \d+`, With: ""}},
+	})
+	in := `{"model":"claude-sonnet-4-20250514","billing":{"credits":3},"text":"hi Amp credits used: 12"}`
+	got := string(rw.apply([]byte(in)))
+	want := `{"model":"sonnet","text":"hi "}`
+	if got != want {
+		t.Fatalf("apply = %s, want %s", got, want)
+	}
+}
+
+func TestFallbackHandler_CreditRewriteStream(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		flusher := w.(http.Flusher)
+		// Split an event across writes to exercise line buffering.
+		_, _ = w.Write([]byte("event: message_start\ndata: {\"message\":{\"model\":\"up"))
+		flusher.Flush()
+		_, _ = w.Write([]byte("stream-model\"}}\n\ndata: [DONE]\n\n"))
+	}))
+	defer upstream.Close()
+	target, _ := url.Parse(upstream.URL)
+	proxy := httputil.NewSingleHostReverseProxy(target)
+
+	rw := newCreditRewrite(config.AmpResponseRewrite{ModelAliases: map[string]string{"upstream-model": "local-alias"}})
+	fallback := NewFallbackHandler(func() *httputil.ReverseProxy { return proxy })
+	fallback.setCreditRewrite(func() *creditRewrite { return rw })
+
+	r := gin.New()
+	r.POST("/v1/messages", fallback.WrapHandler(func(c *gin.Context) {
+		c.String(http.StatusOK, "local")
+	}))
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	res, err := http.Post(srv.URL+"/v1/messages", "application/json", strings.NewReader(`{"model":"unregistered-credit-model"}`))
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	body, _ := io.ReadAll(res.Body)
+	_ = res.Body.Close()
+
+	want := "event: message_start\ndata: {\"message\":{\"model\":\"local-alias\"}}\n\ndata: [DONE]\n\n"
+	if string(body) != want {
+		t.Fatalf("body = %q, want %q", body, want)
+	}
+}
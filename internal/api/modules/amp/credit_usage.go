@@ -0,0 +1,265 @@
+package amp
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/pricing"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/thinking"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/usage"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	log "github.com/sirupsen/logrus"
+	"github.com/tidwall/gjson"
+)
+
+// maxCreditUsageBody bounds how much of a non-streaming or compressed response is kept to
+// read its usage.
+const maxCreditUsageBody = 8 << 20
+
+// creditPricing resolves the estimated credit price of a model.
+type creditPricing struct {
+	prices []config.AmpCreditPrice
+}
+
+// newCreditPricing puts the configured prices ahead of the model prices of the pricing
+// section (Amp bills credits at provider list price).
+func newCreditPricing(configured []config.AmpCreditPrice) *creditPricing {
+	prices := make([]config.AmpCreditPrice, 0, len(configured))
+	for _, price := range configured {
+		if price.Model = strings.TrimSpace(price.Model); price.Model != "" {
+			prices = append(prices, price)
+		}
+	}
+	return &creditPricing{prices: prices}
+}
+
+// estimate returns the credit cost of tokens for model, or false when no price matches.
+func (p *creditPricing) estimate(model string, tokens usage.TokenStats) (float64, bool) {
+	if p == nil {
+		return 0, false
+	}
+	model = thinking.ParseSuffix(model).ModelName
+	for _, price := range p.prices {
+		if !util.MatchWildcard(price.Model, model) {
+			continue
+		}
+		cachedRate := price.CachedInputCostPerMillion
+		if cachedRate == 0 {
+			cachedRate = price.InputCostPerMillion
+		}
+		uncached := max(tokens.InputTokens-tokens.CachedTokens, 0)
+		cost := float64(uncached)*price.InputCostPerMillion +
+			float64(tokens.CachedTokens)*cachedRate +
+			float64(tokens.OutputTokens)*price.OutputCostPerMillion
+		return cost / 1e6, true
+	}
+	// Reasoning tokens are part of the output in the usage Amp responses report.
+	return pricing.Default().Cost(model, pricing.Usage{
+		InputTokens:  tokens.InputTokens,
+		CachedTokens: tokens.CachedTokens,
+		OutputTokens: tokens.OutputTokens,
+	})
+}
+
+// usageRoots are the objects that carry usage in the response formats Amp proxies: the
+// top level (OpenAI, Claude), Claude message_start events, OpenAI Responses events and
+// Gemini CLI envelopes.
+var usageRoots = []string{"", "message.", "response."}
+
+// mergeCreditUsage copies the usage fields found in one JSON document into tokens. Later
+// documents of a stream override earlier values, since providers report running totals.
+func mergeCreditUsage(tokens *usage.TokenStats, data []byte) {
+	if !gjson.ValidBytes(data) {
+		return
+	}
+	set := func(dst *int64, value int64) {
+		if value > 0 {
+			*dst = value
+		}
+	}
+	for _, root := range usageRoots {
+		if u := gjson.GetBytes(data, root+"usage"); u.IsObject() {
+			if u.Get("prompt_tokens").Exists() {
+				// OpenAI Chat Completions
+				set(&tokens.InputTokens, u.Get("prompt_tokens").Int())
+				set(&tokens.CachedTokens, u.Get("prompt_tokens_details.cached_tokens").Int())
+				set(&tokens.OutputTokens, u.Get("completion_tokens").Int())
+				set(&tokens.ReasoningTokens, u.Get("completion_tokens_details.reasoning_tokens").Int())
+			} else if u.Get("input_tokens_details").Exists() || u.Get("output_tokens_details").Exists() {
+				// OpenAI Responses
+				set(&tokens.InputTokens, u.Get("input_tokens").Int())
+				set(&tokens.CachedTokens, u.Get("input_tokens_details.cached_tokens").Int())
+				set(&tokens.OutputTokens, u.Get("output_tokens").Int())
+				set(&tokens.ReasoningTokens, u.Get("output_tokens_details.reasoning_tokens").Int())
+			} else {
+				// Claude reports cache reads and writes next to the uncached input
+				cacheRead := u.Get("cache_read_input_tokens").Int()
+				input := u.Get("input_tokens").Int() + cacheRead + u.Get("cache_creation_input_tokens").Int()
+				set(&tokens.InputTokens, input)
+				set(&tokens.CachedTokens, cacheRead)
+				set(&tokens.OutputTokens, u.Get("output_tokens").Int())
+			}
+		}
+		if u := gjson.GetBytes(data, root+"usageMetadata"); u.IsObject() {
+			// Gemini counts thoughts separately from candidates
+			thoughts := u.Get("thoughtsTokenCount").Int()
+			set(&tokens.InputTokens, u.Get("promptTokenCount").Int())
+			set(&tokens.CachedTokens, u.Get("cachedContentTokenCount").Int())
+			set(&tokens.OutputTokens, u.Get("candidatesTokenCount").Int()+thoughts)
+			set(&tokens.ReasoningTokens, thoughts)
+		}
+	}
+	tokens.TotalTokens = tokens.InputTokens + tokens.OutputTokens
+}
+
+// creditUsageWriter passes a response from the Amp upstream through unchanged while reading
+// the token usage it reports.
+type creditUsageWriter struct {
+	gin.ResponseWriter
+	tokens    usage.TokenStats
+	stream    bool
+	gzipped   bool
+	decided   bool
+	skip      bool
+	body      bytes.Buffer
+	remainder []byte
+}
+
+func newCreditUsageWriter(w gin.ResponseWriter) *creditUsageWriter {
+	return &creditUsageWriter{ResponseWriter: w}
+}
+
+func (w *creditUsageWriter) decide() {
+	if w.decided {
+		return
+	}
+	w.decided = true
+	header := w.ResponseWriter.Header()
+	contentType := header.Get("Content-Type")
+	w.stream = strings.Contains(contentType, "text/event-stream")
+	w.skip = !w.stream && !strings.Contains(contentType, "json")
+	switch encoding := strings.ToLower(strings.TrimSpace(header.Get("Content-Encoding"))); encoding {
+	case "", "identity":
+	case "gzip":
+		// The client negotiated compression with the upstream; the body is decoded once the
+		// response is complete.
+		w.gzipped = true
+	default:
+		w.skip = true
+	}
+}
+
+// WriteHeader implements http.ResponseWriter.
+func (w *creditUsageWriter) WriteHeader(code int) {
+	w.decide()
+	w.ResponseWriter.WriteHeader(code)
+}
+
+// Write implements http.ResponseWriter.
+func (w *creditUsageWriter) Write(data []byte) (int, error) {
+	w.decide()
+	switch {
+	case w.skip:
+	case w.stream && !w.gzipped:
+		buf := append(w.remainder, data...)
+		cut := bytes.LastIndexByte(buf, '\n')
+		if cut < 0 {
+			w.remainder = buf
+			break
+		}
+		w.scanLines(buf[:cut])
+		w.remainder = append([]byte(nil), buf[cut+1:]...)
+	case w.body.Len()+len(data) <= maxCreditUsageBody:
+		w.body.Write(data)
+	default:
+		w.skip = true
+		w.body.Reset()
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+// Flush implements http.Flusher.
+func (w *creditUsageWriter) Flush() {
+	w.ResponseWriter.Flush()
+}
+
+func (w *creditUsageWriter) scanLines(chunk []byte) {
+	for _, line := range bytes.Split(chunk, []byte("\n")) {
+		if payload, ok := bytes.CutPrefix(line, []byte("data:")); ok {
+			mergeCreditUsage(&w.tokens, bytes.TrimSpace(payload))
+		}
+	}
+}
+
+// tokenUsage returns the token usage reported by the response. It must be called after the
+// proxy returns.
+func (w *creditUsageWriter) tokenUsage() usage.TokenStats {
+	if w.gzipped && !w.skip && w.body.Len() > 0 {
+		decoded, err := gunzip(w.body.Bytes())
+		w.body.Reset()
+		if err != nil {
+			return w.tokens
+		}
+		if w.stream {
+			w.scanLines(decoded)
+		} else {
+			mergeCreditUsage(&w.tokens, decoded)
+		}
+		return w.tokens
+	}
+	if w.stream && len(w.remainder) > 0 {
+		w.scanLines(w.remainder)
+		w.remainder = nil
+	}
+	if !w.stream && !w.skip && w.body.Len() > 0 {
+		mergeCreditUsage(&w.tokens, w.body.Bytes())
+		w.body.Reset()
+	}
+	return w.tokens
+}
+
+// gunzip decodes a gzip-encoded response body, up to maxCreditUsageBody bytes.
+func gunzip(data []byte) ([]byte, error) {
+	reader, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = reader.Close() }()
+	return io.ReadAll(io.LimitReader(reader, maxCreditUsageBody))
+}
+
+// recordCreditUsage logs the estimated credit cost of an AMP_CREDITS request and adds it to
+// the usage statistics.
+func recordCreditUsage(pricing *creditPricing, model, path string, tokens usage.TokenStats) {
+	if model == "" {
+		model = "unknown"
+	}
+	cost, priced := pricing.estimate(model, tokens)
+	usage.GetRequestStatistics().RecordAmpCredits(model, time.Now(), tokens, cost, priced)
+	if tokens.InputTokens == 0 && tokens.OutputTokens == 0 {
+		// Error responses and formats without usage still count as AMP_CREDITS traffic.
+		return
+	}
+
+	fields := log.Fields{
+		"component":     "amp-routing",
+		"route_type":    string(RouteTypeAmpCredits),
+		"model_id":      model,
+		"path":          path,
+		"input_tokens":  tokens.InputTokens,
+		"output_tokens": tokens.OutputTokens,
+		"cached_tokens": tokens.CachedTokens,
+	}
+	if !priced {
+		log.WithFields(fields).Info(logging.Msgf(logging.MsgAmpCreditsUsedUnpriced, model, tokens.InputTokens, tokens.OutputTokens))
+		return
+	}
+	fields["estimated_cost"] = cost
+	log.WithFields(fields).Info(logging.Msgf(logging.MsgAmpCreditsUsed, model, tokens.InputTokens, tokens.OutputTokens, cost))
+}
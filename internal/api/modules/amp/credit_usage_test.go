@@ -0,0 +1,118 @@
+package amp
+
+import (
+	"bytes"
+	"compress/gzip"
+	"math"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/usage"
+)
+
+func TestMergeCreditUsage_Formats(t *testing.T) {
+	tests := []struct {
+		name   string
+		events []string
+		want   usage.TokenStats
+	}{
+		{
+			name:   "openai chat",
+			events: []string{`{"usage":{"prompt_tokens":100,"completion_tokens":20,"prompt_tokens_details":{"cached_tokens":40}}}`},
+			want:   usage.TokenStats{InputTokens: 100, OutputTokens: 20, CachedTokens: 40, TotalTokens: 120},
+		},
+		{
+			name: "claude stream",
+			events: []string{
+				`{"type":"message_start","message":{"usage":{"input_tokens":10,"cache_read_input_tokens":90,"output_tokens":1}}}`,
+				`{"type":"message_delta","usage":{"output_tokens":55}}`,
+			},
+			want: usage.TokenStats{InputTokens: 100, OutputTokens: 55, CachedTokens: 90, TotalTokens: 155},
+		},
+		{
+			name:   "openai responses",
+			events: []string{`{"type":"response.completed","response":{"usage":{"input_tokens":30,"output_tokens":12,"input_tokens_details":{"cached_tokens":0},"output_tokens_details":{"reasoning_tokens":8}}}}`},
+			want:   usage.TokenStats{InputTokens: 30, OutputTokens: 12, ReasoningTokens: 8, TotalTokens: 42},
+		},
+		{
+			name:   "gemini",
+			events: []string{`{"usageMetadata":{"promptTokenCount":7,"candidatesTokenCount":3,"thoughtsTokenCount":2}}`},
+			want:   usage.TokenStats{InputTokens: 7, OutputTokens: 5, ReasoningTokens: 2, TotalTokens: 12},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got usage.TokenStats
+			for _, event := range tt.events {
+				mergeCreditUsage(&got, []byte(event))
+			}
+			if got != tt.want {
+				t.Fatalf("tokens = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCreditPricing_Estimate(t *testing.T) {
+	pricing := newCreditPricing([]config.AmpCreditPrice{
+		{Model: "claude-sonnet-4*", InputCostPerMillion: 10, OutputCostPerMillion: 20},
+	})
+	tokens := usage.TokenStats{InputTokens: 1_000_000, CachedTokens: 500_000, OutputTokens: 100_000}
+
+	// The configured entry wins over the built-in table; cache reads use the input price.
+	cost, ok := pricing.estimate("claude-sonnet-4-20250514(high)", tokens)
+	if !ok || math.Abs(cost-12) > 1e-9 {
+		t.Fatalf("configured estimate = %v, %v; want 12, true", cost, ok)
+	}
+	cost, ok = pricing.estimate("gpt-5-mini", tokens)
+	if !ok || math.Abs(cost-(0.125+0.0125+0.2)) > 1e-9 {
+		t.Fatalf("built-in estimate = %v, %v", cost, ok)
+	}
+	if _, ok = pricing.estimate("unknown-model", tokens); ok {
+		t.Fatal("expected no price for unknown model")
+	}
+}
+
+func TestRecordCreditUsage_AggregatesStats(t *testing.T) {
+	model := "test-credit-usage-model"
+	pricing := newCreditPricing([]config.AmpCreditPrice{{Model: model, InputCostPerMillion: 1, OutputCostPerMillion: 2}})
+	tokens := usage.TokenStats{InputTokens: 1000, OutputTokens: 500, TotalTokens: 1500}
+	recordCreditUsage(pricing, model, "/api/provider/anthropic/v1/messages", tokens)
+	recordCreditUsage(pricing, model, "/api/provider/anthropic/v1/messages", tokens)
+
+	var found usage.AmpCreditStats
+	for _, models := range usage.GetRequestStatistics().Snapshot().AmpCreditsByDay {
+		if stats, ok := models[model]; ok {
+			found.Requests += stats.Requests
+			found.InputTokens += stats.InputTokens
+			found.EstimatedCost += stats.EstimatedCost
+		}
+	}
+	if found.Requests != 2 || found.InputTokens != 2000 || math.Abs(found.EstimatedCost-0.004) > 1e-9 {
+		t.Fatalf("aggregated stats = %+v", found)
+	}
+}
+
+func TestCreditUsageWriter_ReadsCompressedResponses(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	var compressed bytes.Buffer
+	zw := gzip.NewWriter(&compressed)
+	_, _ = zw.Write([]byte(`{"usage":{"prompt_tokens":100,"completion_tokens":20}}`))
+	_ = zw.Close()
+
+	rec := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(rec)
+	w := newCreditUsageWriter(c.Writer)
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Encoding", "gzip")
+	_, _ = w.Write(compressed.Bytes())
+
+	if got := w.tokenUsage(); got.InputTokens != 100 || got.OutputTokens != 20 {
+		t.Fatalf("tokens = %+v", got)
+	}
+	if !bytes.Equal(rec.Body.Bytes(), compressed.Bytes()) {
+		t.Fatal("compressed body should reach the client unchanged")
+	}
+}
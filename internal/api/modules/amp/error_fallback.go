@@ -0,0 +1,223 @@
+package amp
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httputil"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/routing"
+	log "github.com/sirupsen/logrus"
+)
+
+// defaultErrorFallbackClasses are used when error-fallback.on is empty.
+var defaultErrorFallbackClasses = []string{"quota", "5xx"}
+
+// errorFallbackMatches reports whether status belongs to one of classes.
+func errorFallbackMatches(classes []string, status int) bool {
+	if len(classes) == 0 {
+		classes = defaultErrorFallbackClasses
+	}
+	for _, class := range classes {
+		switch strings.ToLower(strings.TrimSpace(class)) {
+		case "quota":
+			if status == http.StatusTooManyRequests {
+				return true
+			}
+		case "5xx":
+			if status >= 500 && status <= 599 {
+				return true
+			}
+		case "timeout":
+			if status == http.StatusRequestTimeout || status == http.StatusGatewayTimeout {
+				return true
+			}
+		case "auth":
+			if status == http.StatusUnauthorized || status == http.StatusForbidden {
+				return true
+			}
+		default:
+			if code, err := strconv.Atoi(strings.TrimSpace(class)); err == nil && code == status {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// errorFallbackBudget counts the error fallbacks of the current UTC day. It is shared by
+// every fallback handler of the module.
+type errorFallbackBudget struct {
+	mu   sync.Mutex
+	day  string
+	used int
+	now  func() time.Time
+}
+
+func newErrorFallbackBudget() *errorFallbackBudget {
+	return &errorFallbackBudget{now: time.Now}
+}
+
+// take reserves one fallback and reports whether the daily limit allowed it.
+func (b *errorFallbackBudget) take(limit int) bool {
+	if b == nil || limit <= 0 {
+		return true
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	day := b.now().UTC().Format(time.DateOnly)
+	if day != b.day {
+		b.day, b.used = day, 0
+	}
+	if b.used >= limit {
+		return false
+	}
+	b.used++
+	return true
+}
+
+// errorFallbackWriter holds back a response whose status is a fallback error class, so the
+// request can still be forwarded to ampcode.com. Other responses pass through unchanged.
+type errorFallbackWriter struct {
+	gin.ResponseWriter
+	classes []string
+	header  http.Header
+	status  int
+	held    bool
+	body    bytes.Buffer
+}
+
+func newErrorFallbackWriter(w gin.ResponseWriter, classes []string) *errorFallbackWriter {
+	return &errorFallbackWriter{ResponseWriter: w, classes: classes, header: w.Header().Clone()}
+}
+
+func (w *errorFallbackWriter) WriteHeader(code int) {
+	if w.held {
+		w.status = code
+		return
+	}
+	if !w.ResponseWriter.Written() && errorFallbackMatches(w.classes, code) {
+		w.held, w.status = true, code
+		return
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *errorFallbackWriter) WriteHeaderNow() {
+	if !w.held {
+		w.ResponseWriter.WriteHeaderNow()
+	}
+}
+
+func (w *errorFallbackWriter) Write(data []byte) (int, error) {
+	if w.held {
+		return w.body.Write(data)
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *errorFallbackWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+func (w *errorFallbackWriter) Status() int {
+	if w.held {
+		return w.status
+	}
+	return w.ResponseWriter.Status()
+}
+
+func (w *errorFallbackWriter) Written() bool {
+	return w.held || w.ResponseWriter.Written()
+}
+
+func (w *errorFallbackWriter) Flush() {
+	if !w.held {
+		w.ResponseWriter.Flush()
+	}
+}
+
+// release sends the held error response to the client.
+func (w *errorFallbackWriter) release() {
+	w.ResponseWriter.WriteHeader(w.status)
+	if _, err := w.ResponseWriter.Write(w.body.Bytes()); err != nil {
+		log.Warnf("amp error fallback: failed to write held response: %v", err)
+	}
+}
+
+// reset drops the held response and the headers the failed handler set.
+func (w *errorFallbackWriter) reset() {
+	header := w.ResponseWriter.Header()
+	for name := range header {
+		delete(header, name)
+	}
+	for name, values := range w.header {
+		header[name] = values
+	}
+}
+
+// setErrorFallback installs the source of the error fallback settings and the daily budget
+// shared with the module's other fallback handlers.
+func (fh *FallbackHandler) setErrorFallback(settings func() config.AmpErrorFallback, budget *errorFallbackBudget) {
+	fh.errorFallback = settings
+	fh.errorFallbackBudget = budget
+}
+
+// serveLocal runs handler for a request served by a local provider. With error fallback
+// enabled, a failure of a configured error class is forwarded to ampcode.com with the
+// original request body instead of being returned. mapped requests get their model names
+// rewritten back to the requested model and their text post-processed by post.
+func (fh *FallbackHandler) serveLocal(c *gin.Context, handler gin.HandlerFunc, body, originalBody []byte, model string, mapped bool, post *PostProcessors) {
+	var settings config.AmpErrorFallback
+	if fh.errorFallback != nil {
+		settings = fh.errorFallback()
+	}
+	var proxy *httputil.ReverseProxy
+	if settings.Enabled {
+		proxy = fh.getProxy()
+	}
+	original := c.Writer
+	betaHeader := c.Request.Header.Values("Anthropic-Beta")
+	var guard *errorFallbackWriter
+	if proxy != nil {
+		guard = newErrorFallbackWriter(c.Writer, settings.On)
+		c.Writer = guard
+	}
+
+	// Filter Anthropic-Beta header only for local handling paths
+	filterAntropicBetaHeader(c)
+	c.Request.Body = io.NopCloser(bytes.NewReader(body))
+	if mapped {
+		rewriter := NewResponseRewriter(c.Writer, model)
+		rewriter.postProcess = post
+		c.Writer = rewriter
+		handler(c)
+		rewriter.Flush()
+	} else {
+		handler(c)
+	}
+	if guard == nil || !guard.held {
+		return
+	}
+	c.Writer = original
+	if !fh.errorFallbackBudget.take(settings.DailyLimit) {
+		log.Warnf("amp error fallback: daily limit of %d reached; returning local %d for model %s", settings.DailyLimit, guard.status, model)
+		guard.release()
+		return
+	}
+	guard.reset()
+	if len(betaHeader) > 0 {
+		c.Request.Header["Anthropic-Beta"] = betaHeader
+	}
+	c.Request.Body = io.NopCloser(bytes.NewReader(originalBody))
+	log.Warnf("amp error fallback: local provider failed with %d for model %s; forwarding to ampcode.com", guard.status, model)
+	routing.Trace(c, "amp: local provider failed with %d for %s; forwarded to ampcode.com", guard.status, model)
+	logAmpRouting(c, RouteTypeAmpCredits, model, "", "", c.Request.URL.Path)
+	fh.forwardToAmp(c, proxy, model)
+}
@@ -0,0 +1,108 @@
+package amp
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+)
+
+func TestErrorFallbackMatches(t *testing.T) {
+	cases := []struct {
+		classes []string
+		status  int
+		want    bool
+	}{
+		{nil, http.StatusTooManyRequests, true},
+		{nil, http.StatusBadGateway, true},
+		{nil, http.StatusBadRequest, false},
+		{[]string{"auth"}, http.StatusUnauthorized, true},
+		{[]string{"timeout"}, http.StatusGatewayTimeout, true},
+		{[]string{"quota"}, http.StatusInternalServerError, false},
+		{[]string{"418"}, http.StatusTeapot, true},
+	}
+	for _, tc := range cases {
+		if got := errorFallbackMatches(tc.classes, tc.status); got != tc.want {
+			t.Errorf("errorFallbackMatches(%v, %d) = %v, want %v", tc.classes, tc.status, got, tc.want)
+		}
+	}
+}
+
+func TestFallbackHandler_ErrorFallbackForwardsFailedLocalRequests(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	reg := registry.GetGlobalRegistry()
+	reg.RegisterClient("test-client-amp-error-fallback", "claude", []*registry.ModelInfo{
+		{ID: "test-error-fallback-model", OwnedBy: "anthropic", Type: "claude"},
+	})
+	defer reg.UnregisterClient("test-client-amp-error-fallback")
+
+	var upstreamBody string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		upstreamBody = string(body)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"served_by":"upstream"}`))
+	}))
+	defer upstream.Close()
+	target, _ := url.Parse(upstream.URL)
+	proxy := httputil.NewSingleHostReverseProxy(target)
+
+	localStatus := http.StatusTooManyRequests
+	fallback := NewFallbackHandler(func() *httputil.ReverseProxy { return proxy })
+	fallback.setErrorFallback(func() config.AmpErrorFallback {
+		return config.AmpErrorFallback{Enabled: true, DailyLimit: 1}
+	}, newErrorFallbackBudget())
+	handler := func(c *gin.Context) {
+		c.Header("X-Local", "1")
+		c.JSON(localStatus, gin.H{"served_by": "local"})
+	}
+
+	r := gin.New()
+	r.POST("/v1/messages", fallback.WrapHandler(handler))
+	// The reverse proxy needs a real connection, so serve the engine over HTTP.
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	send := func() (int, string, http.Header) {
+		t.Helper()
+		res, err := http.Post(srv.URL+"/v1/messages", "application/json", bytes.NewReader([]byte(`{"model":"test-error-fallback-model"}`)))
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		defer func() { _ = res.Body.Close() }()
+		body, _ := io.ReadAll(res.Body)
+		return res.StatusCode, string(body), res.Header
+	}
+
+	status, body, header := send()
+	if status != http.StatusOK || body != `{"served_by":"upstream"}` {
+		t.Fatalf("first request: got %d %s, want the upstream response", status, body)
+	}
+	if header.Get("X-Local") != "" {
+		t.Fatalf("headers of the failed local response leaked into the fallback")
+	}
+	if upstreamBody != `{"model":"test-error-fallback-model"}` {
+		t.Fatalf("upstream received %q, want the original request body", upstreamBody)
+	}
+
+	// The daily limit of one is used up: the local error goes back to the client.
+	status, body, _ = send()
+	if status != http.StatusTooManyRequests || body != `{"served_by":"local"}` {
+		t.Fatalf("second request: got %d %s, want the local 429", status, body)
+	}
+
+	// Errors outside the configured classes are never held.
+	localStatus = http.StatusBadRequest
+	status, _, _ = send()
+	if status != http.StatusBadRequest {
+		t.Fatalf("400 response: got %d, want 400", status)
+	}
+}
@@ -2,38 +2,51 @@ package amp
 
 import (
 	"bytes"
+	"fmt"
 	"io"
+	"net/http"
 	"net/http/httputil"
+	"sort"
 	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/metrics"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/routeinfo"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/routing"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/thinking"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers"
 	log "github.com/sirupsen/logrus"
 	"github.com/tidwall/gjson"
 	"github.com/tidwall/sjson"
 )
 
 // AmpRouteType represents the type of routing decision made for an Amp request
-type AmpRouteType string
+type AmpRouteType = routeinfo.RouteType
 
 const (
 	// RouteTypeLocalProvider indicates the request is handled by a local OAuth provider (free)
-	RouteTypeLocalProvider AmpRouteType = "LOCAL_PROVIDER"
+	RouteTypeLocalProvider = routeinfo.LocalProvider
 	// RouteTypeModelMapping indicates the request was remapped to another available model (free)
-	RouteTypeModelMapping AmpRouteType = "MODEL_MAPPING"
+	RouteTypeModelMapping = routeinfo.ModelMapping
 	// RouteTypeAmpCredits indicates the request is forwarded to ampcode.com (uses Amp credits)
-	RouteTypeAmpCredits AmpRouteType = "AMP_CREDITS"
+	RouteTypeAmpCredits = routeinfo.AmpCredits
 	// RouteTypeNoProvider indicates no provider or fallback available
-	RouteTypeNoProvider AmpRouteType = "NO_PROVIDER"
+	RouteTypeNoProvider = routeinfo.NoProvider
 )
 
-// MappedModelContextKey is the Gin context key for passing mapped model names.
-const MappedModelContextKey = "mapped_model"
-
-// logAmpRouting logs the routing decision for an Amp request with structured fields
-func logAmpRouting(routeType AmpRouteType, requestedModel, resolvedModel, provider, path string) {
+// logAmpRouting records the routing decision for an Amp request on c (see routeinfo) and logs
+// it with structured fields
+func logAmpRouting(c *gin.Context, routeType AmpRouteType, requestedModel, resolvedModel, provider, path string) {
+	routeinfo.Set(c, routeinfo.Info{
+		RequestedModel: requestedModel,
+		ResolvedModel:  resolvedModel,
+		RouteType:      routeType,
+		Provider:       provider,
+	})
 	fields := log.Fields{
 		"component":       "amp-routing",
 		"route_type":      string(routeType),
@@ -65,24 +78,39 @@ func logAmpRouting(routeType AmpRouteType, requestedModel, resolvedModel, provid
 		fields["cost"] = "amp_credits"
 		fields["source"] = "ampcode.com"
 		fields["model_id"] = requestedModel // Explicit model_id for easy config reference
-		log.WithFields(fields).Warnf("forwarding to ampcode.com (uses amp credits) - model_id: %s | To use local provider, add to config: ampcode.model-mappings: [{from: \"%s\", to: \"<your-local-model>\"}]", requestedModel, requestedModel)
+		log.WithFields(fields).Warn(logging.Msgf(logging.MsgAmpCreditsForward, requestedModel, requestedModel))
 
 	case RouteTypeNoProvider:
 		fields["cost"] = "none"
 		fields["source"] = "error"
 		fields["model_id"] = requestedModel // Explicit model_id for easy config reference
-		log.WithFields(fields).Warnf("no provider available for model_id: %s", requestedModel)
+		log.WithFields(fields).Warn(logging.Msgf(logging.MsgAmpNoProvider, requestedModel))
 	}
 }
 
 // FallbackHandler wraps a standard handler with fallback logic to ampcode.com
 // when the model's provider is not available in CLIProxyAPI
 type FallbackHandler struct {
-	getProxy           func() *httputil.ReverseProxy
-	modelMapper        ModelMapper
-	forceModelMappings func() bool
+	getProxy            func() *httputil.ReverseProxy
+	modelMapper         ModelMapper
+	forceModelMappings  func() bool
+	providerFilter      ProviderFilter
+	creditRewrite       func() *creditRewrite
+	creditPricing       func() *creditPricing
+	compactor           *contextCompactor
+	noProviderResponse  func() config.AmpNoProviderResponse
+	errorFallback       func() config.AmpErrorFallback
+	errorFallbackBudget *errorFallbackBudget
+	disabledModels      func() []string
+	modelFields         func() []string
+	missingModel        func() config.AmpMissingModel
 }
 
+// ProviderFilter reports whether provider can currently serve model. Providers rejected by
+// the filter are treated as unavailable, so the request falls through to model mappings or
+// ampcode.com.
+type ProviderFilter func(c *gin.Context, provider, model string) bool
+
 // NewFallbackHandler creates a new fallback handler wrapper
 // The getProxy function allows lazy evaluation of the proxy (useful when proxy is created after routes)
 func NewFallbackHandler(getProxy func() *httputil.ReverseProxy) *FallbackHandler {
@@ -109,11 +137,117 @@ func (fh *FallbackHandler) SetModelMapper(mapper ModelMapper) {
 	fh.modelMapper = mapper
 }
 
+// SetProviderFilter installs an optional provider availability check.
+func (fh *FallbackHandler) SetProviderFilter(filter ProviderFilter) {
+	fh.providerFilter = filter
+}
+
+// setCreditRewrite installs the source of the response rewrite applied to requests
+// forwarded to ampcode.com.
+func (fh *FallbackHandler) setCreditRewrite(rewrite func() *creditRewrite) {
+	fh.creditRewrite = rewrite
+}
+
+// setCreditPricing installs the source of the prices used to estimate the credits spent
+// by requests forwarded to ampcode.com.
+func (fh *FallbackHandler) setCreditPricing(pricing func() *creditPricing) {
+	fh.creditPricing = pricing
+}
+
+// setContextCompactor installs the compaction of remapped conversations that do not fit
+// the target model's context window.
+func (fh *FallbackHandler) setContextCompactor(compactor *contextCompactor) {
+	fh.compactor = compactor
+}
+
+// setDisabledModels installs the source of the models never served by local providers.
+func (fh *FallbackHandler) setDisabledModels(models func() []string) {
+	fh.disabledModels = models
+}
+
+// setModelFields installs the source of the extra body paths searched for the model.
+func (fh *FallbackHandler) setModelFields(fields func() []string) {
+	fh.modelFields = fields
+}
+
+// setMissingModel installs the source of the handling of requests without a model.
+func (fh *FallbackHandler) setMissingModel(settings func() config.AmpMissingModel) {
+	fh.missingModel = settings
+}
+
+// modelDisabled reports whether model matches one of the disabled models.
+func (fh *FallbackHandler) modelDisabled(model string) bool {
+	if fh.disabledModels == nil {
+		return false
+	}
+	for _, pattern := range fh.disabledModels() {
+		if pattern = strings.TrimSpace(pattern); pattern != "" && util.MatchWildcard(pattern, model) {
+			return true
+		}
+	}
+	return false
+}
+
+// forwardToAmp proxies the request for model to ampcode.com. The configured response
+// rewrite is applied so clients see the same model ids as for locally served requests,
+// and the reported token usage is logged and recorded with its estimated credit cost.
+func (fh *FallbackHandler) forwardToAmp(c *gin.Context, proxy *httputil.ReverseProxy, model string) {
+	var rewrite *creditRewrite
+	if fh.creditRewrite != nil {
+		rewrite = fh.creditRewrite()
+	}
+	var pricing *creditPricing
+	if fh.creditPricing != nil {
+		pricing = fh.creditPricing()
+	}
+
+	var writer gin.ResponseWriter = c.Writer
+	var rewriter *creditResponseWriter
+	if rewrite != nil {
+		// The rewrite edits the body, so drop the client encoding and let the transport
+		// negotiate and decode compression itself.
+		c.Request.Header.Del("Accept-Encoding")
+		rewriter = newCreditResponseWriter(writer, rewrite)
+		writer = rewriter
+	}
+	usageWriter := newCreditUsageWriter(writer)
+	proxy.ServeHTTP(usageWriter, c.Request)
+	if rewriter != nil {
+		rewriter.finish()
+	}
+	recordCreditUsage(pricing, model, c.Request.URL.Path, usageWriter.tokenUsage())
+}
+
+// providersFor returns the providers registered for model that pass the provider filter and
+// the provider pinned by a routing rule, if any.
+func (fh *FallbackHandler) providersFor(c *gin.Context, model string) []string {
+	if fh.modelDisabled(model) {
+		routing.Trace(c, "amp: model %s is disabled locally", model)
+		return nil
+	}
+	providers := util.GetProviderName(model)
+	pinned := c.GetString(routing.ProviderContextKey)
+	if (fh.providerFilter == nil && pinned == "") || len(providers) == 0 {
+		return providers
+	}
+	filtered := providers[:0:0]
+	for _, provider := range providers {
+		if pinned != "" && !strings.EqualFold(provider, pinned) {
+			continue
+		}
+		if fh.providerFilter == nil || fh.providerFilter(c, provider, model) {
+			filtered = append(filtered, provider)
+		}
+	}
+	return filtered
+}
+
 // WrapHandler wraps a gin.HandlerFunc with fallback logic
 // If the model's provider is not configured in CLIProxyAPI, it forwards to ampcode.com
 func (fh *FallbackHandler) WrapHandler(handler gin.HandlerFunc) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		requestPath := c.Request.URL.Path
+		defer trackAmpRoute(c)()
 
 		// Read the request body to extract the model name
 		bodyBytes, err := io.ReadAll(c.Request.Body)
@@ -125,13 +259,43 @@ func (fh *FallbackHandler) WrapHandler(handler gin.HandlerFunc) gin.HandlerFunc
 
 		// Restore the body for the handler to read
 		c.Request.Body = io.NopCloser(bytes.NewReader(bodyBytes))
+		originalBody := bodyBytes
 
 		// Try to extract model from request body or URL path (for Gemini)
-		modelName := extractModelFromRequest(bodyBytes, c)
+		var fields []string
+		if fh.modelFields != nil {
+			fields = fh.modelFields()
+		}
+		modelName, field := extractModelFromRequest(bodyBytes, c, fields)
+
+		// A routing rule can send the request to ampcode.com regardless of local providers
+		if c.GetBool(routing.ForceAmpContextKey) {
+			if proxy := fh.getProxy(); proxy != nil {
+				log.Debugf("amp routing rule %s: forcing upstream for model %s", c.GetString(routing.RuleContextKey), modelName)
+				routing.Trace(c, "amp: rule %s forced ampcode.com for model %s", c.GetString(routing.RuleContextKey), modelName)
+				logAmpRouting(c, RouteTypeAmpCredits, modelName, "", "", requestPath)
+				fh.forwardToAmp(c, proxy, modelName)
+				return
+			}
+		}
 		if modelName == "" {
-			// Can't determine model, proceed with normal handler
-			handler(c)
-			return
+			if !fh.handleMissingModel(c, &bodyBytes) {
+				return
+			}
+			if modelName = gjson.GetBytes(bodyBytes, "model").String(); modelName == "" {
+				// Can't determine model, proceed with normal handler
+				handler(c)
+				return
+			}
+			// The assumed model is part of the request, also for ampcode.com.
+			originalBody = bodyBytes
+		}
+		if field != "" && field != "model" && c.Param("action") == "" && c.Param("path") == "" {
+			// Local handlers read the top-level model, so promote the one found elsewhere.
+			if promoted, errSet := sjson.SetBytes(bodyBytes, "model", modelName); errSet == nil {
+				bodyBytes = promoted
+				c.Request.Body = io.NopCloser(bytes.NewReader(bodyBytes))
+			}
 		}
 
 		// Normalize model (handles dynamic thinking suffixes)
@@ -142,18 +306,24 @@ func (fh *FallbackHandler) WrapHandler(handler gin.HandlerFunc) gin.HandlerFunc
 			thinkingSuffix = "(" + suffixResult.RawSuffix + ")"
 		}
 
-		resolveMappedModel := func() (string, []string) {
+		// unavailableMapping records a mapping whose target had no provider so the final error
+		// can name it.
+		unavailableMapping := ""
+		resolveMappedModel := func() (string, []string, MappingOverrides) {
 			if fh.modelMapper == nil {
-				return "", nil
+				return "", nil, MappingOverrides{}
 			}
 
+			mappedFrom := modelName
 			mappedModel := fh.modelMapper.MapModel(modelName)
 			if mappedModel == "" {
+				mappedFrom = normalizedModel
 				mappedModel = fh.modelMapper.MapModel(normalizedModel)
 			}
 			mappedModel = strings.TrimSpace(mappedModel)
 			if mappedModel == "" {
-				return "", nil
+				routing.Trace(c, "amp: no model mapping for %s", normalizedModel)
+				return "", nil, MappingOverrides{}
 			}
 
 			// Preserve dynamic thinking suffix (e.g. "(xhigh)") when mapping applies, unless the target
@@ -166,17 +336,20 @@ func (fh *FallbackHandler) WrapHandler(handler gin.HandlerFunc) gin.HandlerFunc
 			}
 
 			mappedBaseModel := thinking.ParseSuffix(mappedModel).ModelName
-			mappedProviders := util.GetProviderName(mappedBaseModel)
+			mappedProviders := fh.providersFor(c, mappedBaseModel)
 			if len(mappedProviders) == 0 {
-				return "", nil
+				routing.Trace(c, "amp: model mapping %s -> %s skipped: no available provider for %s", normalizedModel, mappedModel, mappedBaseModel)
+				unavailableMapping = fmt.Sprintf("model mapping %s -> %s has no available provider for %s", normalizedModel, mappedModel, mappedBaseModel)
+				return "", nil, MappingOverrides{}
 			}
 
-			return mappedModel, mappedProviders
+			return mappedModel, mappedProviders, fh.modelMapper.MappingOverrides(mappedFrom)
 		}
 
 		// Track resolved model for logging (may change if mapping is applied)
 		resolvedModel := normalizedModel
 		usedMapping := false
+		var postProcess *PostProcessors
 		var providers []string
 
 		// Check if model mappings should be forced ahead of local API keys
@@ -185,35 +358,39 @@ func (fh *FallbackHandler) WrapHandler(handler gin.HandlerFunc) gin.HandlerFunc
 		if forceMappings {
 			// FORCE MODE: Check model mappings FIRST (takes precedence over local API keys)
 			// This allows users to route Amp requests to their preferred OAuth providers
-			if mappedModel, mappedProviders := resolveMappedModel(); mappedModel != "" {
+			if mappedModel, mappedProviders, overrides := resolveMappedModel(); mappedModel != "" {
 				// Mapping found and provider available - rewrite the model in request body
-				bodyBytes = rewriteModelInRequest(bodyBytes, mappedModel)
+				bodyBytes = rewriteModelInRequest(bodyBytes, mappedModel, overrides.Params)
+				bodyBytes = applyPromptAdapter(bodyBytes, requestPath, overrides.PromptAdapter)
+				bodyBytes = fh.compactor.compact(c, bodyBytes, requestPath, mappedModel)
 				c.Request.Body = io.NopCloser(bytes.NewReader(bodyBytes))
-				// Store mapped model in context for handlers that check it (like gemini bridge)
-				c.Set(MappedModelContextKey, mappedModel)
+				rewriteModelInPath(c, mappedModel)
 				resolvedModel = mappedModel
 				usedMapping = true
+				postProcess = overrides.PostProcess
 				providers = mappedProviders
 			}
 
 			// If no mapping applied, check for local providers
 			if !usedMapping {
-				providers = util.GetProviderName(normalizedModel)
+				providers = fh.providersFor(c, normalizedModel)
 			}
 		} else {
 			// DEFAULT MODE: Check local providers first, then mappings as fallback
-			providers = util.GetProviderName(normalizedModel)
+			providers = fh.providersFor(c, normalizedModel)
 
 			if len(providers) == 0 {
 				// No providers configured - check if we have a model mapping
-				if mappedModel, mappedProviders := resolveMappedModel(); mappedModel != "" {
+				if mappedModel, mappedProviders, overrides := resolveMappedModel(); mappedModel != "" {
 					// Mapping found and provider available - rewrite the model in request body
-					bodyBytes = rewriteModelInRequest(bodyBytes, mappedModel)
+					bodyBytes = rewriteModelInRequest(bodyBytes, mappedModel, overrides.Params)
+					bodyBytes = applyPromptAdapter(bodyBytes, requestPath, overrides.PromptAdapter)
+					bodyBytes = fh.compactor.compact(c, bodyBytes, requestPath, mappedModel)
 					c.Request.Body = io.NopCloser(bytes.NewReader(bodyBytes))
-					// Store mapped model in context for handlers that check it (like gemini bridge)
-					c.Set(MappedModelContextKey, mappedModel)
+					rewriteModelInPath(c, mappedModel)
 					resolvedModel = mappedModel
 					usedMapping = true
+					postProcess = overrides.PostProcess
 					providers = mappedProviders
 				}
 			}
@@ -224,18 +401,20 @@ func (fh *FallbackHandler) WrapHandler(handler gin.HandlerFunc) gin.HandlerFunc
 			proxy := fh.getProxy()
 			if proxy != nil {
 				// Log: Forwarding to ampcode.com (uses Amp credits)
-				logAmpRouting(RouteTypeAmpCredits, modelName, "", "", requestPath)
+				logAmpRouting(c, RouteTypeAmpCredits, modelName, "", "", requestPath)
+				routing.Trace(c, "amp: no local provider for %s; forwarded to ampcode.com", normalizedModel)
 
-				// Restore body again for the proxy
-				c.Request.Body = io.NopCloser(bytes.NewReader(bodyBytes))
+				// Restore the client's body for the proxy
+				c.Request.Body = io.NopCloser(bytes.NewReader(originalBody))
 
 				// Forward to ampcode.com
-				proxy.ServeHTTP(c.Writer, c.Request)
+				fh.forwardToAmp(c, proxy, modelName)
 				return
 			}
 
 			// No proxy available, let the normal handler return the error
-			logAmpRouting(RouteTypeNoProvider, modelName, "", "", requestPath)
+			logAmpRouting(c, RouteTypeNoProvider, modelName, "", "", requestPath)
+			routing.Trace(c, "amp: no provider for %s and no ampcode.com upstream configured", normalizedModel)
 		}
 
 		// Log the routing decision
@@ -247,30 +426,48 @@ func (fh *FallbackHandler) WrapHandler(handler gin.HandlerFunc) gin.HandlerFunc
 		if usedMapping {
 			// Log: Model was mapped to another model
 			log.Debugf("amp model mapping: request %s -> %s", normalizedModel, resolvedModel)
-			logAmpRouting(RouteTypeModelMapping, modelName, resolvedModel, providerName, requestPath)
-			rewriter := NewResponseRewriter(c.Writer, modelName)
-			c.Writer = rewriter
-			// Filter Anthropic-Beta header only for local handling paths
-			filterAntropicBetaHeader(c)
-			c.Request.Body = io.NopCloser(bytes.NewReader(bodyBytes))
-			handler(c)
-			rewriter.Flush()
+			logAmpRouting(c, RouteTypeModelMapping, modelName, resolvedModel, providerName, requestPath)
+			routing.Trace(c, "amp: model mapping %s -> %s via %s (force-model-mappings=%t)", normalizedModel, resolvedModel, providerName, forceMappings)
+			fh.serveLocal(c, handler, bodyBytes, originalBody, modelName, true, postProcess)
 			log.Debugf("amp model mapping: response %s -> %s", resolvedModel, modelName)
 		} else if len(providers) > 0 {
 			// Log: Using local provider (free)
-			logAmpRouting(RouteTypeLocalProvider, modelName, resolvedModel, providerName, requestPath)
-			// Filter Anthropic-Beta header only for local handling paths
-			filterAntropicBetaHeader(c)
-			c.Request.Body = io.NopCloser(bytes.NewReader(bodyBytes))
-			handler(c)
+			logAmpRouting(c, RouteTypeLocalProvider, modelName, resolvedModel, providerName, requestPath)
+			routing.Trace(c, "amp: local provider %s for %s", providerName, resolvedModel)
+			fh.serveLocal(c, handler, bodyBytes, originalBody, modelName, false, nil)
 		} else {
-			// No provider, no mapping, no proxy: fall back to the wrapped handler so it can return an error response
+			// No provider, no mapping, no proxy: answer with the configured explanation, or fall
+			// back to the wrapped handler so it can return an error response
+			if fh.writeNoProviderResponse(c, bodyBytes, modelName, unavailableMapping) {
+				return
+			}
+			if unavailableMapping != "" {
+				handlers.SetErrorHint(c, handlers.ErrorHint{Code: handlers.ErrorCodeMappingTargetUnavailable, Message: unavailableMapping})
+			}
 			c.Request.Body = io.NopCloser(bytes.NewReader(bodyBytes))
 			handler(c)
 		}
 	}
 }
 
+// trackAmpRoute counts the request in flight on its route until the returned function runs,
+// which records the final routing decision of the request for the fairness report.
+func trackAmpRoute(c *gin.Context) func() {
+	done := metrics.StartAmpRequest(c.FullPath())
+	return func() {
+		info, ok := routeinfo.Get(c)
+		if !ok {
+			done("", "", "", "")
+			return
+		}
+		served := info.ResolvedModel
+		if served != "" {
+			served = thinking.ParseSuffix(served).ModelName
+		}
+		done(string(info.RouteType), thinking.ParseSuffix(info.RequestedModel).ModelName, served, info.Provider)
+	}
+}
+
 // filterAntropicBetaHeader filters Anthropic-Beta header to remove features requiring special subscription
 // This is needed when using local providers (bypassing the Amp proxy)
 func filterAntropicBetaHeader(c *gin.Context) {
@@ -283,49 +480,222 @@ func filterAntropicBetaHeader(c *gin.Context) {
 	}
 }
 
-// rewriteModelInRequest replaces the model name in a JSON request body
-func rewriteModelInRequest(body []byte, newModel string) []byte {
-	if !gjson.GetBytes(body, "model").Exists() {
+// geminiMappingParams are the generationConfig fields that common sampling parameter names
+// of mapping overrides are written to in Gemini request bodies.
+var geminiMappingParams = map[string]string{
+	"temperature": "generationConfig.temperature",
+	"top_p":       "generationConfig.topP",
+	"top_k":       "generationConfig.topK",
+	"max_tokens":  "generationConfig.maxOutputTokens",
+}
+
+// rewriteModelInRequest replaces the model name in a JSON request body and applies the
+// parameter overrides of the mapping.
+func rewriteModelInRequest(body []byte, newModel string, params map[string]any) []byte {
+	if gjson.GetBytes(body, "model").Exists() {
+		result, err := sjson.SetBytes(body, "model", newModel)
+		if err != nil {
+			log.Warnf("amp model mapping: failed to rewrite model in request body: %v", err)
+		} else {
+			body = result
+		}
+	}
+	if len(params) == 0 || !gjson.ValidBytes(body) {
+		return body
+	}
+	gemini := gjson.GetBytes(body, "contents").Exists()
+	keys := make([]string, 0, len(params))
+	for key := range params {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		path := strings.TrimSpace(key)
+		if mapped, ok := geminiMappingParams[path]; ok && gemini {
+			path = mapped
+		}
+		if path == "" {
+			continue
+		}
+		var (
+			result []byte
+			err    error
+		)
+		if params[key] == nil {
+			result, err = sjson.DeleteBytes(body, path)
+		} else {
+			result, err = sjson.SetBytes(body, path, params[key])
+		}
+		if err != nil {
+			log.Warnf("amp model mapping: failed to apply parameter %s: %v", key, err)
+			continue
+		}
+		body = result
+	}
+	return body
+}
+
+// applyPromptAdapter prepends a mapping's prompt adapter to the system prompt of the request
+// body, in the shape of the API requestPath belongs to.
+func applyPromptAdapter(body []byte, requestPath, adapter string) []byte {
+	if adapter == "" || !gjson.ValidBytes(body) {
+		return body
+	}
+	var (
+		result []byte
+		err    error
+	)
+	switch {
+	case gjson.GetBytes(body, "contents").Exists():
+		field := "systemInstruction"
+		if gjson.GetBytes(body, "system_instruction").Exists() {
+			field = "system_instruction"
+		}
+		part := map[string]string{"text": adapter}
+		if parts := gjson.GetBytes(body, field+".parts"); parts.IsArray() {
+			result, err = prependJSONArray(body, field+".parts", parts, part)
+		} else {
+			result, err = sjson.SetBytes(body, field+".parts", []any{part})
+		}
+	case strings.Contains(requestPath, "/messages"):
+		switch system := gjson.GetBytes(body, "system"); {
+		case system.IsArray():
+			result, err = prependJSONArray(body, "system", system, map[string]string{"type": "text", "text": adapter})
+		case system.Type == gjson.String && system.String() != "":
+			result, err = sjson.SetBytes(body, "system", adapter+"\n\n"+system.String())
+		default:
+			result, err = sjson.SetBytes(body, "system", adapter)
+		}
+	case strings.Contains(requestPath, "/responses"):
+		instructions := gjson.GetBytes(body, "instructions").String()
+		if instructions != "" {
+			instructions = "\n\n" + instructions
+		}
+		result, err = sjson.SetBytes(body, "instructions", adapter+instructions)
+	case gjson.GetBytes(body, "messages").IsArray():
+		result, err = prependJSONArray(body, "messages", gjson.GetBytes(body, "messages"), map[string]string{"role": "system", "content": adapter})
+	default:
 		return body
 	}
-	result, err := sjson.SetBytes(body, "model", newModel)
 	if err != nil {
-		log.Warnf("amp model mapping: failed to rewrite model in request body: %v", err)
+		log.Warnf("amp model mapping: failed to apply prompt adapter: %v", err)
 		return body
 	}
 	return result
 }
 
-// extractModelFromRequest attempts to extract the model name from various request formats
-func extractModelFromRequest(body []byte, c *gin.Context) string {
-	// First try to parse from JSON body (OpenAI, Claude, etc.)
-	// Check common model field names
-	if result := gjson.GetBytes(body, "model"); result.Exists() && result.Type == gjson.String {
-		return result.String()
+// prependJSONArray sets path to the array current with item inserted first.
+func prependJSONArray(body []byte, path string, current gjson.Result, item any) ([]byte, error) {
+	result, err := sjson.SetRawBytes(body, path, []byte("[]"))
+	if err != nil {
+		return nil, err
+	}
+	if result, err = sjson.SetBytes(result, path+".-1", item); err != nil {
+		return nil, err
+	}
+	for _, existing := range current.Array() {
+		if result, err = sjson.SetRawBytes(result, path+".-1", []byte(existing.Raw)); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// rewriteModelInPath points the Gemini-style action or path parameter, and the request URL,
+// at model, so Gemini handlers downstream see the mapped model in the path as well as in
+// the body.
+func rewriteModelInPath(c *gin.Context, model string) {
+	for i := range c.Params {
+		key := c.Params[i].Key
+		if key != "action" && key != "path" {
+			continue
+		}
+		current := c.Params[i].Value
+		if _, method, ok := util.ParseGeminiModelPath(current); !ok || (key == "path" && method == "") {
+			continue
+		}
+		replaced, ok := util.ReplaceGeminiModelPath(current, model)
+		if !ok {
+			continue
+		}
+		c.Params[i].Value = replaced
+		if u := c.Request.URL; u != nil && strings.HasSuffix(u.Path, current) {
+			u.Path = strings.TrimSuffix(u.Path, current) + replaced
+			u.RawPath = ""
+		}
+	}
+}
+
+// handleMissingModel applies the missing-model policy to a request without an extractable
+// model and counts it. It returns false after rejecting the request; under the default-model
+// policy the default model is written to the body, except on Gemini routes, whose model is
+// part of the path.
+func (fh *FallbackHandler) handleMissingModel(c *gin.Context, body *[]byte) bool {
+	var settings config.AmpMissingModel
+	if fh.missingModel != nil {
+		settings = fh.missingModel()
+	}
+	policy := settings.Policy
+	if policy == "" {
+		policy = config.AmpMissingModelPassthrough
+	}
+	metrics.CountModelExtractionFailure(c.FullPath(), policy)
+	log.Debugf("amp: no model in request to %s, applying %s policy", c.Request.URL.Path, policy)
+	routing.Trace(c, "amp: no model in request, applying %s policy", policy)
+	switch policy {
+	case config.AmpMissingModelReject:
+		dialect := handlers.DialectOpenAI
+		switch detectNoProviderDialect(c.Request.URL.Path) {
+		case dialectClaude:
+			dialect = handlers.DialectClaude
+		case dialectGemini:
+			dialect = handlers.DialectGemini
+		}
+		c.Data(http.StatusBadRequest, "application/json", handlers.BuildDialectErrorBody(dialect, http.StatusBadRequest, "", "the request names no model"))
+		c.Abort()
+		return false
+	case config.AmpMissingModelDefault:
+		if c.Param("action") != "" || c.Param("path") != "" || !gjson.ValidBytes(*body) {
+			return true
+		}
+		if updated, err := sjson.SetBytes(*body, "model", settings.DefaultModel); err == nil {
+			*body = updated
+			c.Request.Body = io.NopCloser(bytes.NewReader(updated))
+			c.Request.ContentLength = int64(len(updated))
+		}
+	}
+	return true
+}
+
+// extractModelFromRequest attempts to extract the model name from various request formats.
+// It returns the model and the body path it was found at, or "" for models taken from the
+// URL path.
+func extractModelFromRequest(body []byte, c *gin.Context, fields []string) (string, string) {
+	// First try to parse from JSON body (OpenAI, Claude, etc.), then the configured paths
+	for _, field := range append([]string{"model"}, fields...) {
+		if field = strings.TrimSpace(field); field == "" {
+			continue
+		}
+		if result := gjson.GetBytes(body, field); result.Type == gjson.String && result.String() != "" {
+			return result.String(), field
+		}
 	}
 
 	// For Gemini requests, model is in the URL path
 	// Standard format: /models/{model}:generateContent -> :action parameter
 	if action := c.Param("action"); action != "" {
-		// Split by colon to get model name (e.g., "gemini-pro:generateContent" -> "gemini-pro")
-		parts := strings.Split(action, ":")
-		if len(parts) > 0 && parts[0] != "" {
-			return parts[0]
+		if model, _, ok := util.ParseGeminiModelPath(action); ok {
+			return model, ""
 		}
 	}
 
 	// AMP CLI format: /publishers/google/models/{model}:method -> *path parameter
 	// Example: /publishers/google/models/gemini-3-pro-preview:streamGenerateContent
 	if path := c.Param("path"); path != "" {
-		// Look for /models/{model}:method pattern
-		if idx := strings.Index(path, "/models/"); idx >= 0 {
-			modelPart := path[idx+8:] // Skip "/models/"
-			// Split by colon to get model name
-			if colonIdx := strings.Index(modelPart, ":"); colonIdx > 0 {
-				return modelPart[:colonIdx]
-			}
+		if model, method, ok := util.ParseGeminiModelPath(path); ok && method != "" {
+			return model, ""
 		}
 	}
 
-	return ""
+	return "", ""
 }
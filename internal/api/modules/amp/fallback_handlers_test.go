@@ -6,11 +6,15 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"net/http/httputil"
+	"net/url"
 	"testing"
 
 	"github.com/gin-gonic/gin"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/metrics"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/routeinfo"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/routing"
 )
 
 func TestFallbackHandler_ModelMapping_PreservesThinkingSuffixAndRewritesResponse(t *testing.T) {
@@ -71,3 +75,298 @@ func TestFallbackHandler_ModelMapping_PreservesThinkingSuffixAndRewritesResponse
 		t.Errorf("Expected handler to see test/gpt-5.2(xhigh), got %s", resp.SeenModel)
 	}
 }
+
+func TestFallbackHandler_RoutingRuleForcesUpstream(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	reg := registry.GetGlobalRegistry()
+	reg.RegisterClient("test-client-amp-force", "claude", []*registry.ModelInfo{
+		{ID: "test-force-model", OwnedBy: "anthropic", Type: "claude"},
+	})
+	defer reg.UnregisterClient("test-client-amp-force")
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"served_by":"upstream"}`))
+	}))
+	defer upstream.Close()
+	target, _ := url.Parse(upstream.URL)
+	proxy := httputil.NewSingleHostReverseProxy(target)
+
+	fallback := NewFallbackHandler(func() *httputil.ReverseProxy { return proxy })
+	handler := func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"served_by": "local"})
+	}
+
+	for _, force := range []bool{false, true} {
+		r := gin.New()
+		r.POST("/chat/completions", func(c *gin.Context) {
+			if force {
+				c.Set(routing.ForceAmpContextKey, true)
+			}
+			c.Next()
+		}, fallback.WrapHandler(handler))
+
+		// The reverse proxy needs a real connection, so serve the engine over HTTP.
+		srv := httptest.NewServer(r)
+		res, err := http.Post(srv.URL+"/chat/completions", "application/json", bytes.NewReader([]byte(`{"model":"test-force-model"}`)))
+		if err != nil {
+			srv.Close()
+			t.Fatalf("force=%v: request failed: %v", force, err)
+		}
+		var resp struct {
+			ServedBy string `json:"served_by"`
+		}
+		err = json.NewDecoder(res.Body).Decode(&resp)
+		_ = res.Body.Close()
+		srv.Close()
+
+		want := "local"
+		if force {
+			want = "upstream"
+		}
+		if err != nil || resp.ServedBy != want {
+			t.Fatalf("force=%v: served_by = %q (err %v), want %q", force, resp.ServedBy, err, want)
+		}
+	}
+}
+
+func TestFallbackHandler_DisabledModelSkipsLocalProvider(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	reg := registry.GetGlobalRegistry()
+	reg.RegisterClient("test-client-amp-disabled", "claude", []*registry.ModelInfo{
+		{ID: "test-disabled-model", OwnedBy: "anthropic", Type: "claude"},
+		{ID: "test-disabled-target", OwnedBy: "anthropic", Type: "claude"},
+	})
+	defer reg.UnregisterClient("test-client-amp-disabled")
+
+	mapper := NewModelMapper([]config.AmpModelMapping{
+		{From: "test-disabled-model", To: "test-disabled-target"},
+	})
+	fallback := NewFallbackHandlerWithMapper(func() *httputil.ReverseProxy { return nil }, mapper, nil)
+	disabled := []string{"test-disabled-m*"}
+	fallback.setDisabledModels(func() []string { return disabled })
+
+	handler := func(c *gin.Context) {
+		var req struct {
+			Model string `json:"model"`
+		}
+		_ = c.ShouldBindJSON(&req)
+		c.JSON(http.StatusOK, gin.H{"seen_model": req.Model})
+	}
+	r := gin.New()
+	r.POST("/chat/completions", fallback.WrapHandler(handler))
+
+	send := func() string {
+		req := httptest.NewRequest(http.MethodPost, "/chat/completions", bytes.NewReader([]byte(`{"model":"test-disabled-model"}`)))
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		var resp struct {
+			SeenModel string `json:"seen_model"`
+		}
+		_ = json.Unmarshal(w.Body.Bytes(), &resp)
+		return resp.SeenModel
+	}
+
+	// The locally available model is disabled, so the mapping serves the request.
+	if got := send(); got != "test-disabled-target" {
+		t.Fatalf("seen_model = %q, want the mapping target", got)
+	}
+	disabled = nil
+	if got := send(); got != "test-disabled-model" {
+		t.Fatalf("seen_model = %q, want the requested model once re-enabled", got)
+	}
+}
+
+func TestFallbackHandler_ModelFieldsFindNestedModel(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	reg := registry.GetGlobalRegistry()
+	reg.RegisterClient("test-client-amp-fields", "claude", []*registry.ModelInfo{
+		{ID: "test-nested-source", OwnedBy: "anthropic", Type: "claude"},
+		{ID: "test-nested-target", OwnedBy: "anthropic", Type: "claude"},
+	})
+	defer reg.UnregisterClient("test-client-amp-fields")
+
+	mapper := NewModelMapper([]config.AmpModelMapping{
+		{From: "test-nested-mapped", To: "test-nested-target"},
+	})
+	fallback := NewFallbackHandlerWithMapper(func() *httputil.ReverseProxy { return nil }, mapper, nil)
+	fallback.setModelFields(func() []string { return []string{"request.model", "generation_config.model"} })
+
+	handler := func(c *gin.Context) {
+		var req struct {
+			Model string `json:"model"`
+		}
+		_ = c.ShouldBindJSON(&req)
+		c.JSON(http.StatusOK, gin.H{"seen_model": req.Model})
+	}
+	r := gin.New()
+	r.POST("/chat/completions", fallback.WrapHandler(handler))
+
+	cases := map[string]string{
+		`{"request":{"model":"test-nested-source"}}`:                                        "test-nested-source",
+		`{"generation_config":{"model":"test-nested-mapped"}}`:                              "test-nested-target",
+		`{"model":"test-nested-source","generation_config":{"model":"test-nested-mapped"}}`: "test-nested-source",
+	}
+	for body, want := range cases {
+		req := httptest.NewRequest(http.MethodPost, "/chat/completions", bytes.NewReader([]byte(body)))
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		var resp struct {
+			SeenModel string `json:"seen_model"`
+		}
+		_ = json.Unmarshal(w.Body.Bytes(), &resp)
+		if resp.SeenModel != want {
+			t.Errorf("body %s: seen_model = %q, want %q", body, resp.SeenModel, want)
+		}
+	}
+}
+
+func TestFallbackHandler_MissingModelPolicies(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	reg := registry.GetGlobalRegistry()
+	reg.RegisterClient("test-client-amp-missing", "claude", []*registry.ModelInfo{
+		{ID: "test-missing-target", OwnedBy: "anthropic", Type: "claude"},
+	})
+	defer reg.UnregisterClient("test-client-amp-missing")
+
+	mapper := NewModelMapper([]config.AmpModelMapping{{From: "test-missing-alias", To: "test-missing-target"}})
+	serve := func(settings config.AmpMissingModel) *httptest.ResponseRecorder {
+		fallback := NewFallbackHandlerWithMapper(func() *httputil.ReverseProxy { return nil }, mapper, nil)
+		fallback.setMissingModel(func() config.AmpMissingModel { return settings })
+		r := gin.New()
+		r.POST("/v1/messages", fallback.WrapHandler(func(c *gin.Context) {
+			var req struct {
+				Model string `json:"model"`
+			}
+			_ = c.ShouldBindJSON(&req)
+			c.JSON(http.StatusOK, gin.H{"seen_model": req.Model})
+		}))
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/v1/messages", bytes.NewReader([]byte(`{"messages":[]}`))))
+		return w
+	}
+	failures := func() int64 {
+		for _, f := range metrics.ModelExtractionFailures() {
+			if f.Route == "/v1/messages" && f.Policy == config.AmpMissingModelReject {
+				return f.Count
+			}
+		}
+		return 0
+	}
+
+	if w := serve(config.AmpMissingModel{}); w.Code != http.StatusOK || !bytes.Contains(w.Body.Bytes(), []byte(`"seen_model":""`)) {
+		t.Fatalf("pass-through: %d %s", w.Code, w.Body.String())
+	}
+
+	before := failures()
+	w := serve(config.AmpMissingModel{Policy: config.AmpMissingModelReject})
+	if w.Code != http.StatusBadRequest || !bytes.Contains(w.Body.Bytes(), []byte(`"invalid_request_error"`)) {
+		t.Fatalf("reject: %d %s", w.Code, w.Body.String())
+	}
+	if got := failures(); got != before+1 {
+		t.Fatalf("reject failures = %d, want %d", got, before+1)
+	}
+
+	// The assumed model goes through the model mappings like a requested one.
+	w = serve(config.AmpMissingModel{Policy: config.AmpMissingModelDefault, DefaultModel: "test-missing-alias"})
+	if !bytes.Contains(w.Body.Bytes(), []byte(`"seen_model":"test-missing-target"`)) {
+		t.Fatalf("default-model: %d %s", w.Code, w.Body.String())
+	}
+}
+
+func TestFallbackHandler_ModelMappingRewritesGeminiPath(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	reg := registry.GetGlobalRegistry()
+	reg.RegisterClient("test-client-amp-gemini-path", "gemini", []*registry.ModelInfo{
+		{ID: "test-gemini-target", OwnedBy: "google", Type: "gemini"},
+	})
+	defer reg.UnregisterClient("test-client-amp-gemini-path")
+
+	mapper := NewModelMapper([]config.AmpModelMapping{
+		{From: "test-gemini-source", To: "test-gemini-target"},
+	})
+	fallback := NewFallbackHandlerWithMapper(func() *httputil.ReverseProxy { return nil }, mapper, nil)
+
+	var action, path string
+	var info routeinfo.Info
+	handler := func(c *gin.Context) {
+		action, path = c.Param("action"), c.Request.URL.Path
+		info, _ = routeinfo.Get(c)
+		c.Status(http.StatusOK)
+	}
+	r := gin.New()
+	r.POST("/v1beta/models/*action", fallback.WrapHandler(handler))
+
+	req := httptest.NewRequest(http.MethodPost, "/v1beta/models/test-gemini-source:generateContent", bytes.NewReader([]byte(`{"contents":[]}`)))
+	r.ServeHTTP(httptest.NewRecorder(), req)
+	if action != "/test-gemini-target:generateContent" {
+		t.Errorf("action = %q, want the mapped model", action)
+	}
+	if path != "/v1beta/models/test-gemini-target:generateContent" {
+		t.Errorf("path = %q, want the mapped model", path)
+	}
+	want := routeinfo.Info{
+		RequestedModel: "test-gemini-source",
+		ResolvedModel:  "test-gemini-target",
+		RouteType:      routeinfo.ModelMapping,
+		Provider:       "gemini",
+	}
+	if info != want || !info.Mapped() {
+		t.Errorf("route info = %+v, want %+v", info, want)
+	}
+}
+
+func TestRewriteModelInRequest_AppliesMappingParams(t *testing.T) {
+	params := map[string]any{"temperature": 0.4, "top_p": 0.95, "top_k": nil}
+
+	claude := rewriteModelInRequest([]byte(`{"model":"claude-x","temperature":1,"top_k":40}`), "gemini-y", params)
+	var got map[string]any
+	if err := json.Unmarshal(claude, &got); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if got["model"] != "gemini-y" || got["temperature"] != 0.4 || got["top_p"] != 0.95 {
+		t.Fatalf("rewritten body = %s", claude)
+	}
+	if _, ok := got["top_k"]; ok {
+		t.Fatalf("top_k not removed: %s", claude)
+	}
+
+	gemini := rewriteModelInRequest([]byte(`{"contents":[],"generationConfig":{"topK":40}}`), "gemini-y", params)
+	want := `{"contents":[],"generationConfig":{"temperature":0.4,"topP":0.95}}`
+	if !bytes.Equal(gemini, []byte(want)) {
+		t.Fatalf("gemini body = %s, want %s", gemini, want)
+	}
+}
+
+func TestApplyPromptAdapter(t *testing.T) {
+	const adapter = "Call tools with JSON arguments."
+	cases := []struct {
+		name string
+		path string
+		body string
+		want string
+	}{
+		{"claude string", "/api/provider/anthropic/v1/messages", `{"system":"Be brief.","messages":[]}`, `{"system":"Call tools with JSON arguments.\n\nBe brief.","messages":[]}`},
+		{"claude blocks", "/api/provider/anthropic/v1/messages", `{"system":[{"type":"text","text":"Be brief."}],"messages":[]}`, `{"system":[{"text":"Call tools with JSON arguments.","type":"text"},{"type":"text","text":"Be brief."}],"messages":[]}`},
+		{"claude none", "/api/provider/anthropic/v1/messages", `{"messages":[]}`, `{"messages":[],"system":"Call tools with JSON arguments."}`},
+		{"openai", "/api/provider/openai/v1/chat/completions", `{"messages":[{"role":"user","content":"hi"}]}`, `{"messages":[{"content":"Call tools with JSON arguments.","role":"system"},{"role":"user","content":"hi"}]}`},
+		{"responses", "/api/provider/openai/v1/responses", `{"input":"hi","instructions":"Be brief."}`, `{"input":"hi","instructions":"Call tools with JSON arguments.\n\nBe brief."}`},
+		{"gemini", "/api/provider/google/v1beta/models/gemini-y:generateContent", `{"contents":[],"systemInstruction":{"parts":[{"text":"Be brief."}]}}`, `{"contents":[],"systemInstruction":{"parts":[{"text":"Call tools with JSON arguments."},{"text":"Be brief."}]}}`},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := applyPromptAdapter([]byte(tc.body), tc.path, adapter)
+			if string(got) != tc.want {
+				t.Fatalf("body = %s, want %s", got, tc.want)
+			}
+		})
+	}
+	if got := applyPromptAdapter([]byte(`{"messages":[]}`), "/v1/messages", ""); string(got) != `{"messages":[]}` {
+		t.Fatalf("empty adapter changed body: %s", got)
+	}
+}
@@ -1,9 +1,8 @@
 package amp
 
 import (
-	"strings"
-
 	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 )
 
 // createGeminiBridgeHandler creates a handler that bridges AMP CLI's non-standard Gemini paths
@@ -13,7 +12,8 @@ import (
 // Standard format: /models/gemini-3-pro-preview:streamGenerateContent
 //
 // This extracts the model+method from the AMP path and sets it as the :action parameter
-// so the standard Gemini handler can process it.
+// so the standard Gemini handler can process it. Model mappings applied by FallbackHandler
+// have already rewritten the path, so the action names the mapped model.
 //
 // The handler parameter should be a Gemini-compatible handler that expects the :action param.
 func createGeminiBridgeHandler(handler gin.HandlerFunc) gin.HandlerFunc {
@@ -23,27 +23,11 @@ func createGeminiBridgeHandler(handler gin.HandlerFunc) gin.HandlerFunc {
 
 		// Extract model:method from AMP CLI path format
 		// Example: /publishers/google/models/gemini-3-pro-preview:streamGenerateContent
-		const modelsPrefix = "/models/"
-		if idx := strings.Index(path, modelsPrefix); idx >= 0 {
-			// Extract everything after modelsPrefix
-			actionPart := path[idx+len(modelsPrefix):]
-
-			// Check if model was mapped by FallbackHandler
-			if mappedModel, exists := c.Get(MappedModelContextKey); exists {
-				if strModel, ok := mappedModel.(string); ok && strModel != "" {
-					// Replace the model part in the action
-					// actionPart is like "model-name:method"
-					if colonIdx := strings.Index(actionPart, ":"); colonIdx > 0 {
-						method := actionPart[colonIdx:] // ":method"
-						actionPart = strModel + method
-					}
-				}
-			}
-
+		if model, method, ok := util.ParseGeminiModelPath(path); ok && method != "" {
 			// Set this as the :action parameter that the Gemini handler expects
 			c.Params = append(c.Params, gin.Param{
 				Key:   "action",
-				Value: actionPart,
+				Value: model + ":" + method,
 			})
 
 			// Call the handler
@@ -35,6 +35,21 @@ func TestCreateGeminiBridgeHandler_ActionParameterExtraction(t *testing.T) {
 			mappedModel:    "gemini-flash",
 			expectedAction: "gemini-flash:streamGenerateContent",
 		},
+		{
+			name:           "vertex_resource_path",
+			path:           "/projects/p/locations/us-central1/publishers/google/models/gemini-2.5-pro:generateContent",
+			expectedAction: "gemini-2.5-pro:generateContent",
+		},
+		{
+			name:           "url_encoded_method",
+			path:           "/publishers/google/models/gemini-2.5-pro%3AstreamGenerateContent",
+			expectedAction: "gemini-2.5-pro:streamGenerateContent",
+		},
+		{
+			name:           "tuned_model",
+			path:           "/tunedModels/my-model:generateContent",
+			expectedAction: "tunedModels/my-model:generateContent",
+		},
 	}
 
 	for _, tt := range tests {
@@ -52,7 +67,7 @@ func TestCreateGeminiBridgeHandler_ActionParameterExtraction(t *testing.T) {
 			r := gin.New()
 			if tt.mappedModel != "" {
 				r.Use(func(c *gin.Context) {
-					c.Set(MappedModelContextKey, tt.mappedModel)
+					rewriteModelInPath(c, tt.mappedModel)
 					c.Next()
 				})
 			}
@@ -8,6 +8,7 @@ import (
 	"sync"
 
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/thinking"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	log "github.com/sirupsen/logrus"
@@ -21,6 +22,10 @@ type ModelMapper interface {
 	// model has available providers. Returns empty string if no mapping applies.
 	MapModel(requestedModel string) string
 
+	// MappingOverrides returns the request adjustments of the mapping MapModel applies to
+	// requestedModel besides the model rewrite.
+	MappingOverrides(requestedModel string) MappingOverrides
+
 	// UpdateMappings refreshes the mapping configuration (for hot-reload).
 	UpdateMappings(mappings []config.AmpModelMapping)
 }
@@ -28,14 +33,30 @@ type ModelMapper interface {
 // DefaultModelMapper implements ModelMapper with thread-safe mapping storage.
 type DefaultModelMapper struct {
 	mu       sync.RWMutex
-	mappings map[string]string // exact: from -> to (normalized lowercase keys)
-	regexps  []regexMapping    // regex rules evaluated in order
+	mappings map[string]mappingTarget // exact: from -> target (normalized lowercase keys)
+	regexps  []regexMapping           // regex rules evaluated in order
+}
+
+// MappingOverrides are the request adjustments a mapping applies besides the model rewrite.
+type MappingOverrides struct {
+	// Params overrides request parameters.
+	Params map[string]any
+	// PromptAdapter is prepended to the system prompt.
+	PromptAdapter string
+	// PostProcess rewrites the generated text of the response; nil leaves it unchanged.
+	PostProcess *PostProcessors
+}
+
+// mappingTarget is what a mapping rewrites a request to.
+type mappingTarget struct {
+	to        string
+	overrides MappingOverrides
 }
 
 // NewModelMapper creates a new model mapper with the given initial mappings.
 func NewModelMapper(mappings []config.AmpModelMapping) *DefaultModelMapper {
 	m := &DefaultModelMapper{
-		mappings: make(map[string]string),
+		mappings: make(map[string]mappingTarget),
 		regexps:  nil,
 	}
 	m.UpdateMappings(mappings)
@@ -60,27 +81,11 @@ func (m *DefaultModelMapper) MapModel(requestedModel string) string {
 
 	// Extract thinking suffix from requested model using ParseSuffix
 	requestResult := thinking.ParseSuffix(requestedModel)
-	baseModel := requestResult.ModelName
-
-	// Normalize the base model for lookup (case-insensitive)
-	normalizedBase := strings.ToLower(strings.TrimSpace(baseModel))
-
-	// Check for direct mapping using base model name
-	targetModel, exists := m.mappings[normalizedBase]
+	target, exists := m.lookupLocked(requestResult.ModelName)
 	if !exists {
-		// Try regex mappings in order using base model only
-		// (suffix is handled separately via ParseSuffix)
-		for _, rm := range m.regexps {
-			if rm.re.MatchString(baseModel) {
-				targetModel = rm.to
-				exists = true
-				break
-			}
-		}
-		if !exists {
-			return ""
-		}
+		return ""
 	}
+	targetModel := target.to
 
 	// Check if target model already has a thinking suffix (config priority)
 	targetResult := thinking.ParseSuffix(targetModel)
@@ -108,6 +113,33 @@ func (m *DefaultModelMapper) MapModel(requestedModel string) string {
 	return targetModel
 }
 
+// MappingOverrides returns the request adjustments of the mapping matching requestedModel.
+// Unlike MapModel it does not check that the target has providers.
+func (m *DefaultModelMapper) MappingOverrides(requestedModel string) MappingOverrides {
+	if requestedModel == "" {
+		return MappingOverrides{}
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	target, _ := m.lookupLocked(thinking.ParseSuffix(requestedModel).ModelName)
+	return target.overrides
+}
+
+// lookupLocked finds the mapping of baseModel: exact mappings first, then regex mappings
+// in order. The suffix is handled separately by callers via ParseSuffix.
+func (m *DefaultModelMapper) lookupLocked(baseModel string) (mappingTarget, bool) {
+	// Normalize the base model for lookup (case-insensitive)
+	if target, exists := m.mappings[strings.ToLower(strings.TrimSpace(baseModel))]; exists {
+		return target, true
+	}
+	for _, rm := range m.regexps {
+		if rm.re.MatchString(baseModel) {
+			return rm.target, true
+		}
+	}
+	return mappingTarget{}, false
+}
+
 // UpdateMappings refreshes the mapping configuration from config.
 // This is called during initialization and on config hot-reload.
 func (m *DefaultModelMapper) UpdateMappings(mappings []config.AmpModelMapping) {
@@ -115,7 +147,7 @@ func (m *DefaultModelMapper) UpdateMappings(mappings []config.AmpModelMapping) {
 	defer m.mu.Unlock()
 
 	// Clear and rebuild mappings
-	m.mappings = make(map[string]string, len(mappings))
+	m.mappings = make(map[string]mappingTarget, len(mappings))
 	m.regexps = make([]regexMapping, 0, len(mappings))
 
 	for _, mapping := range mappings {
@@ -123,9 +155,14 @@ func (m *DefaultModelMapper) UpdateMappings(mappings []config.AmpModelMapping) {
 		to := strings.TrimSpace(mapping.To)
 
 		if from == "" || to == "" {
-			log.Warnf("amp model mapping: skipping invalid mapping (from=%q, to=%q)", from, to)
+			log.Warn(logging.Msgf(logging.MsgAmpMappingInvalid, from, to))
 			continue
 		}
+		target := mappingTarget{to: to, overrides: MappingOverrides{
+			Params:        mapping.Params,
+			PromptAdapter: strings.TrimSpace(mapping.PromptAdapter),
+			PostProcess:   compilePostProcessors(mapping.PostProcess),
+		}}
 
 		if mapping.Regex {
 			// Compile case-insensitive regex; wrap with (?i) to match behavior of exact lookups
@@ -135,12 +172,12 @@ func (m *DefaultModelMapper) UpdateMappings(mappings []config.AmpModelMapping) {
 				log.Warnf("amp model mapping: invalid regex %q: %v", from, err)
 				continue
 			}
-			m.regexps = append(m.regexps, regexMapping{re: re, to: to})
+			m.regexps = append(m.regexps, regexMapping{re: re, target: target})
 			log.Debugf("amp model regex mapping registered: /%s/ -> %s", from, to)
 		} else {
 			// Store with normalized lowercase key for case-insensitive lookup
 			normalizedFrom := strings.ToLower(from)
-			m.mappings[normalizedFrom] = to
+			m.mappings[normalizedFrom] = target
 			log.Debugf("amp model mapping registered: %s -> %s", from, to)
 		}
 	}
@@ -160,12 +197,12 @@ func (m *DefaultModelMapper) GetMappings() map[string]string {
 
 	result := make(map[string]string, len(m.mappings))
 	for k, v := range m.mappings {
-		result[k] = v
+		result[k] = v.to
 	}
 	return result
 }
 
 type regexMapping struct {
-	re *regexp.Regexp
-	to string
+	re     *regexp.Regexp
+	target mappingTarget
 }
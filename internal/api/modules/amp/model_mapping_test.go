@@ -373,3 +373,21 @@ func TestModelMapper_SuffixPreservation(t *testing.T) {
 		})
 	}
 }
+
+func TestModelMapper_MappingParams(t *testing.T) {
+	mapper := NewModelMapper([]config.AmpModelMapping{
+		{From: "claude-x", To: "gemini-y", Params: map[string]any{"temperature": 0.4}},
+		{From: "^gpt-.*$", To: "gemini-z", Regex: true, Params: map[string]any{"top_p": 0.9}},
+		{From: "plain", To: "other"},
+	})
+
+	if got := mapper.MappingOverrides("Claude-X(high)").Params; got["temperature"] != 0.4 {
+		t.Errorf("exact mapping params = %v", got)
+	}
+	if got := mapper.MappingOverrides("gpt-5").Params; got["top_p"] != 0.9 {
+		t.Errorf("regex mapping params = %v", got)
+	}
+	if got := mapper.MappingOverrides("plain").Params; got != nil {
+		t.Errorf("expected no params, got %v", got)
+	}
+}
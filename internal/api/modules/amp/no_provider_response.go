@@ -0,0 +1,256 @@
+package amp
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	"github.com/tidwall/gjson"
+)
+
+// noProviderModelsListed caps the available models named in the synthesized reply.
+const noProviderModelsListed = 8
+
+// defaultNoProviderMessage is the built-in reply of the NO_PROVIDER route.
+const defaultNoProviderMessage = "CLIProxyAPI has no provider for the model \"{model}\" and no ampcode.com upstream is configured.{reason}\n\n" +
+	"To serve this model with a local provider, add a model mapping to the proxy config:\n\n" +
+	"ampcode:\n  model-mappings:\n    - from: \"{model}\"\n      to: \"<an available model>\"\n\n" +
+	"Available models: {models}"
+
+// synthesizedHeader marks replies produced by the proxy rather than a provider.
+const synthesizedHeader = "X-CLIProxy-Synthesized"
+
+// noProviderDialect is the API shape a synthesized reply is rendered in.
+type noProviderDialect int
+
+const (
+	dialectUnknown noProviderDialect = iota
+	dialectClaude
+	dialectOpenAIChat
+	dialectOpenAIResponses
+	dialectGemini
+)
+
+// setNoProviderResponse installs the source of the NO_PROVIDER reply settings.
+func (fh *FallbackHandler) setNoProviderResponse(settings func() config.AmpNoProviderResponse) {
+	fh.noProviderResponse = settings
+}
+
+// writeNoProviderResponse answers the request with the configured assistant message and
+// reports whether it did. Requests of unknown dialects, e.g. token counting, are left to
+// the wrapped handler.
+func (fh *FallbackHandler) writeNoProviderResponse(c *gin.Context, body []byte, model, reason string) bool {
+	if fh.noProviderResponse == nil {
+		return false
+	}
+	settings := fh.noProviderResponse()
+	if !settings.Enabled {
+		return false
+	}
+	path := c.Request.URL.Path
+	dialect := detectNoProviderDialect(path)
+	if dialect == dialectUnknown {
+		return false
+	}
+	// Gemini routes capture the action with a leading slash.
+	model = strings.TrimPrefix(model, "/")
+	text := renderNoProviderMessage(settings.Message, model, reason)
+	c.Header(synthesizedHeader, "no-provider")
+	switch dialect {
+	case dialectClaude:
+		writeClaudeSynthesized(c, model, text, gjson.GetBytes(body, "stream").Bool())
+	case dialectOpenAIChat:
+		writeOpenAIChatSynthesized(c, model, text, gjson.GetBytes(body, "stream").Bool())
+	case dialectOpenAIResponses:
+		writeOpenAIResponsesSynthesized(c, model, text, gjson.GetBytes(body, "stream").Bool())
+	case dialectGemini:
+		writeGeminiSynthesized(c, model, text, strings.Contains(path, ":streamGenerateContent"), c.Query("alt") == "sse")
+	}
+	return true
+}
+
+func detectNoProviderDialect(path string) noProviderDialect {
+	switch {
+	case strings.HasSuffix(path, "/messages"):
+		return dialectClaude
+	case strings.HasSuffix(path, "/chat/completions"):
+		return dialectOpenAIChat
+	case strings.HasSuffix(path, "/responses"):
+		return dialectOpenAIResponses
+	case strings.Contains(path, ":generateContent"), strings.Contains(path, ":streamGenerateContent"):
+		return dialectGemini
+	default:
+		return dialectUnknown
+	}
+}
+
+// renderNoProviderMessage fills the placeholders of message, the built-in one when empty.
+func renderNoProviderMessage(message, model, reason string) string {
+	if strings.TrimSpace(message) == "" {
+		message = defaultNoProviderMessage
+	}
+	if reason != "" && message == defaultNoProviderMessage {
+		reason = " The " + reason + "."
+	}
+	models := availableModelIDs(noProviderModelsListed)
+	listed := "none; add a provider credential first"
+	if len(models) > 0 {
+		listed = strings.Join(models, ", ")
+	}
+	return strings.NewReplacer("{model}", model, "{reason}", reason, "{models}", listed).Replace(message)
+}
+
+func availableModelIDs(limit int) []string {
+	var ids []string
+	for _, model := range registry.GetGlobalRegistry().GetAvailableModels("openai") {
+		if id, ok := model["id"].(string); ok && id != "" {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	if len(ids) > limit {
+		ids = append(ids[:limit], "...")
+	}
+	return ids
+}
+
+func synthesizedID(prefix string) string {
+	buf := make([]byte, 12)
+	_, _ = rand.Read(buf)
+	return prefix + hex.EncodeToString(buf)
+}
+
+func writeClaudeSynthesized(c *gin.Context, model, text string, stream bool) {
+	id := synthesizedID("msg_")
+	usage := gin.H{"input_tokens": 0, "output_tokens": 0}
+	if !stream {
+		c.JSON(http.StatusOK, gin.H{
+			"id": id, "type": "message", "role": "assistant", "model": model,
+			"content":     []gin.H{{"type": "text", "text": text}},
+			"stop_reason": "end_turn", "stop_sequence": nil, "usage": usage,
+		})
+		return
+	}
+	startSSE(c)
+	writeSSEEvent(c, "message_start", gin.H{"type": "message_start", "message": gin.H{
+		"id": id, "type": "message", "role": "assistant", "model": model,
+		"content": []gin.H{}, "stop_reason": nil, "stop_sequence": nil, "usage": usage,
+	}})
+	writeSSEEvent(c, "content_block_start", gin.H{"type": "content_block_start", "index": 0, "content_block": gin.H{"type": "text", "text": ""}})
+	writeSSEEvent(c, "content_block_delta", gin.H{"type": "content_block_delta", "index": 0, "delta": gin.H{"type": "text_delta", "text": text}})
+	writeSSEEvent(c, "content_block_stop", gin.H{"type": "content_block_stop", "index": 0})
+	writeSSEEvent(c, "message_delta", gin.H{"type": "message_delta", "delta": gin.H{"stop_reason": "end_turn", "stop_sequence": nil}, "usage": gin.H{"output_tokens": 0}})
+	writeSSEEvent(c, "message_stop", gin.H{"type": "message_stop"})
+}
+
+func writeOpenAIChatSynthesized(c *gin.Context, model, text string, stream bool) {
+	id := synthesizedID("chatcmpl-")
+	created := time.Now().Unix()
+	if !stream {
+		c.JSON(http.StatusOK, gin.H{
+			"id": id, "object": "chat.completion", "created": created, "model": model,
+			"choices": []gin.H{{"index": 0, "message": gin.H{"role": "assistant", "content": text}, "finish_reason": "stop"}},
+			"usage":   gin.H{"prompt_tokens": 0, "completion_tokens": 0, "total_tokens": 0},
+		})
+		return
+	}
+	chunk := func(delta gin.H, finish any) gin.H {
+		return gin.H{
+			"id": id, "object": "chat.completion.chunk", "created": created, "model": model,
+			"choices": []gin.H{{"index": 0, "delta": delta, "finish_reason": finish}},
+		}
+	}
+	startSSE(c)
+	writeSSEEvent(c, "", chunk(gin.H{"role": "assistant", "content": text}, nil))
+	writeSSEEvent(c, "", chunk(gin.H{}, "stop"))
+	writeSSEData(c, "[DONE]")
+}
+
+func writeOpenAIResponsesSynthesized(c *gin.Context, model, text string, stream bool) {
+	part := gin.H{"type": "output_text", "text": text, "annotations": []any{}}
+	item := gin.H{"id": synthesizedID("msg_"), "type": "message", "status": "completed", "role": "assistant", "content": []gin.H{part}}
+	response := gin.H{
+		"id": synthesizedID("resp_"), "object": "response", "created_at": time.Now().Unix(),
+		"status": "completed", "model": model, "output": []gin.H{item},
+		"usage": gin.H{"input_tokens": 0, "output_tokens": 0, "total_tokens": 0},
+	}
+	if !stream {
+		c.JSON(http.StatusOK, response)
+		return
+	}
+	inProgress := gin.H{}
+	for key, value := range response {
+		inProgress[key] = value
+	}
+	inProgress["status"], inProgress["output"] = "in_progress", []gin.H{}
+	itemID := item["id"]
+	events := []gin.H{
+		{"type": "response.created", "response": inProgress},
+		{"type": "response.output_item.added", "output_index": 0, "item": gin.H{"id": itemID, "type": "message", "status": "in_progress", "role": "assistant", "content": []gin.H{}}},
+		{"type": "response.content_part.added", "item_id": itemID, "output_index": 0, "content_index": 0, "part": gin.H{"type": "output_text", "text": "", "annotations": []any{}}},
+		{"type": "response.output_text.delta", "item_id": itemID, "output_index": 0, "content_index": 0, "delta": text},
+		{"type": "response.output_text.done", "item_id": itemID, "output_index": 0, "content_index": 0, "text": text},
+		{"type": "response.content_part.done", "item_id": itemID, "output_index": 0, "content_index": 0, "part": part},
+		{"type": "response.output_item.done", "output_index": 0, "item": item},
+		{"type": "response.completed", "response": response},
+	}
+	startSSE(c)
+	for i, event := range events {
+		event["sequence_number"] = i
+		writeSSEEvent(c, event["type"].(string), event)
+	}
+}
+
+func writeGeminiSynthesized(c *gin.Context, model, text string, stream, sse bool) {
+	reply := gin.H{
+		"candidates": []gin.H{{
+			"content":      gin.H{"role": "model", "parts": []gin.H{{"text": text}}},
+			"finishReason": "STOP",
+			"index":        0,
+		}},
+		"usageMetadata": gin.H{"promptTokenCount": 0, "candidatesTokenCount": 0, "totalTokenCount": 0},
+		"modelVersion":  model,
+	}
+	switch {
+	case !stream:
+		c.JSON(http.StatusOK, reply)
+	case sse:
+		startSSE(c)
+		writeSSEEvent(c, "", reply)
+	default:
+		// Without alt=sse, Gemini streams a JSON array of responses.
+		c.JSON(http.StatusOK, []gin.H{reply})
+	}
+}
+
+func startSSE(c *gin.Context) {
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Status(http.StatusOK)
+}
+
+// writeSSEEvent writes payload as one SSE event, with an event line when event is set.
+func writeSSEEvent(c *gin.Context, event string, payload any) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return
+	}
+	if event != "" {
+		_, _ = fmt.Fprintf(c.Writer, "event: %s\n", event)
+	}
+	writeSSEData(c, string(data))
+}
+
+func writeSSEData(c *gin.Context, data string) {
+	_, _ = fmt.Fprintf(c.Writer, "data: %s\n\n", data)
+	c.Writer.Flush()
+}
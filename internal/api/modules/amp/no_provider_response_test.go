@@ -0,0 +1,91 @@
+package amp
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/tidwall/gjson"
+)
+
+func TestFallbackHandler_NoProviderResponse(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	settings := config.AmpNoProviderResponse{}
+	fallback := NewFallbackHandlerWithMapper(func() *httputil.ReverseProxy { return nil }, NewModelMapper(nil), nil)
+	fallback.setNoProviderResponse(func() config.AmpNoProviderResponse { return settings })
+	handler := func(c *gin.Context) {
+		c.JSON(http.StatusBadGateway, gin.H{"error": "unknown provider"})
+	}
+
+	r := gin.New()
+	r.POST("/api/provider/:provider/v1/messages", fallback.WrapHandler(handler))
+	r.POST("/api/provider/:provider/v1/messages/count_tokens", fallback.WrapHandler(handler))
+	r.POST("/api/provider/:provider/v1/chat/completions", fallback.WrapHandler(handler))
+	r.POST("/api/provider/:provider/v1/responses", fallback.WrapHandler(handler))
+	r.POST("/api/provider/:provider/v1beta/models/*action", fallback.WrapHandler(handler))
+
+	serve := func(path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, path, bytes.NewReader([]byte(body)))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := serve("/api/provider/anthropic/v1/messages", `{"model":"claude-none"}`); w.Code != http.StatusBadGateway {
+		t.Fatalf("disabled replies should keep the error, got %d", w.Code)
+	}
+
+	settings = config.AmpNoProviderResponse{Enabled: true}
+	w := serve("/api/provider/anthropic/v1/messages", `{"model":"claude-none"}`)
+	text := gjson.Get(w.Body.String(), "content.0.text").String()
+	if w.Code != http.StatusOK || gjson.Get(w.Body.String(), "type").String() != "message" || !strings.Contains(text, `from: "claude-none"`) {
+		t.Fatalf("claude reply = %d %s", w.Code, w.Body.String())
+	}
+	if w.Header().Get(synthesizedHeader) != "no-provider" {
+		t.Fatal("synthesized replies should be marked")
+	}
+
+	w = serve("/api/provider/anthropic/v1/messages", `{"model":"claude-none","stream":true}`)
+	if body := w.Body.String(); !strings.Contains(body, "event: message_start") || !strings.Contains(body, "text_delta") || !strings.HasSuffix(body, "event: message_stop\ndata: {\"type\":\"message_stop\"}\n\n") {
+		t.Fatalf("claude stream = %s", body)
+	}
+
+	if w = serve("/api/provider/anthropic/v1/messages/count_tokens", `{"model":"claude-none"}`); w.Code != http.StatusBadGateway {
+		t.Fatalf("token counting should keep the error, got %d", w.Code)
+	}
+
+	settings.Message = "No route for {model}."
+	w = serve("/api/provider/openai/v1/chat/completions", `{"model":"gpt-none"}`)
+	if got := gjson.Get(w.Body.String(), "choices.0.message.content").String(); got != "No route for gpt-none." {
+		t.Fatalf("openai reply = %s", w.Body.String())
+	}
+	w = serve("/api/provider/openai/v1/chat/completions", `{"model":"gpt-none","stream":true}`)
+	if body := w.Body.String(); !strings.Contains(body, `"chat.completion.chunk"`) || !strings.HasSuffix(body, "data: [DONE]\n\n") {
+		t.Fatalf("openai stream = %s", body)
+	}
+
+	w = serve("/api/provider/openai/v1/responses", `{"model":"gpt-none"}`)
+	if got := gjson.Get(w.Body.String(), "output.0.content.0.text").String(); got != "No route for gpt-none." {
+		t.Fatalf("responses reply = %s", w.Body.String())
+	}
+	w = serve("/api/provider/openai/v1/responses", `{"model":"gpt-none","stream":true}`)
+	if body := w.Body.String(); !strings.Contains(body, "event: response.output_text.delta") || !strings.Contains(body, "event: response.completed") {
+		t.Fatalf("responses stream = %s", body)
+	}
+
+	w = serve("/api/provider/google/v1beta/models/gemini-none:generateContent", `{"contents":[]}`)
+	if got := gjson.Get(w.Body.String(), "candidates.0.content.parts.0.text").String(); got != "No route for gemini-none." {
+		t.Fatalf("gemini reply = %s", w.Body.String())
+	}
+	w = serve("/api/provider/google/v1beta/models/gemini-none:streamGenerateContent?alt=sse", `{"contents":[]}`)
+	if body := w.Body.String(); !strings.HasPrefix(body, "data: {") {
+		t.Fatalf("gemini stream = %s", body)
+	}
+}
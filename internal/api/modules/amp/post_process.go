@@ -0,0 +1,324 @@
+package amp
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	log "github.com/sirupsen/logrus"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// fenceLine matches a markdown code fence line: its indentation, the fence, and the
+// language tag. fencePrefix matches the start of a line that may still become one.
+var (
+	fenceLine   = regexp.MustCompile("^([ \t]*)(`{3,}|~{3,})[ \t]*([^ \t`~]*)[ \t]*$")
+	fencePrefix = regexp.MustCompile("^[ \t]*(?:(?:`+|~+)[ \t]*[^ \t`~]*[ \t]*)?$")
+)
+
+// PostProcessors rewrite the generated text of responses served through a mapping.
+type PostProcessors struct {
+	steps []postProcessStep
+}
+
+type postProcessStep struct {
+	kind    string
+	re      *regexp.Regexp
+	replace string
+	stop    []string
+}
+
+// postProcessState carries the post-processing state across the text deltas of one stream.
+type postProcessState struct {
+	stopped bool
+	// tail is the end of the text received so far, held back because a stop string or a
+	// fence line may continue in the next delta.
+	tail string
+	// fenceOpen and midLine describe the text sent so far: whether a fence is open and
+	// whether its last line is unfinished.
+	fenceOpen bool
+	midLine   bool
+}
+
+// compilePostProcessors compiles the post-processing steps of a mapping. Invalid steps are
+// skipped with a warning; nil is returned when no step remains.
+func compilePostProcessors(specs []config.AmpPostProcessor) *PostProcessors {
+	var steps []postProcessStep
+	for _, spec := range specs {
+		kind := strings.ToLower(strings.TrimSpace(spec.Type))
+		step := postProcessStep{kind: kind}
+		switch kind {
+		case config.AmpPostProcessRegex:
+			re, err := regexp.Compile(spec.Pattern)
+			if err != nil || spec.Pattern == "" {
+				log.Warnf("amp post-process: invalid regex %q: %v", spec.Pattern, err)
+				continue
+			}
+			step.re, step.replace = re, spec.Replace
+		case config.AmpPostProcessTrimStop:
+			for _, stop := range spec.Stop {
+				if stop != "" {
+					step.stop = append(step.stop, stop)
+				}
+			}
+			if len(step.stop) == 0 {
+				log.Warn("amp post-process: trim-stop step without stop strings")
+				continue
+			}
+		case config.AmpPostProcessNormalizeFences:
+		default:
+			log.Warnf("amp post-process: unknown step type %q", spec.Type)
+			continue
+		}
+		steps = append(steps, step)
+	}
+	if len(steps) == 0 {
+		return nil
+	}
+	return &PostProcessors{steps: steps}
+}
+
+// apply runs the steps over text. A trim-stop match marks state stopped, after which the
+// text of later calls is dropped. complete is false for stream deltas: their text is
+// processed after the tail held back from the previous delta, and a new tail that may be
+// the start of a stop string or of a fence line is held back in turn. complete texts flush
+// the tail and close a fence left open.
+func (p *PostProcessors) apply(text string, state *postProcessState, complete bool) string {
+	if p == nil {
+		return text
+	}
+	if state.stopped {
+		return ""
+	}
+	text, state.tail = state.tail+text, ""
+	if !complete {
+		keep := p.holdBack(text, !state.midLine)
+		text, state.tail = text[:len(text)-keep], text[len(text)-keep:]
+	}
+	for _, step := range p.steps {
+		switch step.kind {
+		case config.AmpPostProcessRegex:
+			text = step.re.ReplaceAllString(text, step.replace)
+		case config.AmpPostProcessTrimStop:
+			cut := -1
+			for _, stop := range step.stop {
+				if i := strings.Index(text, stop); i >= 0 && (cut < 0 || i < cut) {
+					cut = i
+				}
+			}
+			if cut >= 0 {
+				state.stopped, state.tail = true, ""
+				text = text[:cut]
+			}
+		case config.AmpPostProcessNormalizeFences:
+			text, state.fenceOpen = normalizeFences(text, state.fenceOpen, !state.midLine, complete)
+		}
+	}
+	if complete {
+		state.midLine = false
+	} else if text != "" {
+		state.midLine = !strings.HasSuffix(text, "\n")
+	}
+	return text
+}
+
+// holdBack returns how many bytes at the end of a stream delta to keep for the next one:
+// the longest end that is the start of a stop string, or an unfinished line that may be a
+// fence. lineStart reports whether text starts a line. Nothing is kept when text contains
+// a stop string, as everything after it is dropped.
+func (p *PostProcessors) holdBack(text string, lineStart bool) int {
+	keep := 0
+	for _, step := range p.steps {
+		switch step.kind {
+		case config.AmpPostProcessTrimStop:
+			for _, stop := range step.stop {
+				if strings.Contains(text, stop) {
+					return 0
+				}
+				for n := min(len(stop)-1, len(text)); n > keep; n-- {
+					if strings.HasSuffix(text, stop[:n]) {
+						keep = n
+						break
+					}
+				}
+			}
+		case config.AmpPostProcessNormalizeFences:
+			start := strings.LastIndex(text, "\n") + 1
+			if (start > 0 || lineStart) && len(text)-start > keep && fencePrefix.MatchString(text[start:]) {
+				keep = len(text) - start
+			}
+		}
+	}
+	return keep
+}
+
+// normalizeFences rewrites ~~~ and longer fences to ``` with a lowercase language tag and,
+// for complete texts, closes a fence left open at the end. open reports whether a fence is
+// open before text and lineStart whether text starts a line; the returned flag reports
+// whether a fence is open after it.
+func normalizeFences(text string, open, lineStart, complete bool) (string, bool) {
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		if i == 0 && !lineStart {
+			continue
+		}
+		m := fenceLine.FindStringSubmatch(strings.TrimRight(line, "\r"))
+		if m == nil {
+			continue
+		}
+		if open {
+			lines[i] = m[1] + "```"
+		} else {
+			lines[i] = m[1] + "```" + strings.ToLower(m[3])
+		}
+		open = !open
+	}
+	text = strings.Join(lines, "\n")
+	if complete && open {
+		if !strings.HasSuffix(text, "\n") && (text != "" || !lineStart) {
+			text += "\n"
+		}
+		text += "```"
+		open = false
+	}
+	return text, open
+}
+
+// rewriteResponse post-processes the text of a non-streaming Claude, OpenAI Chat
+// Completions, OpenAI Responses or Gemini response.
+func (p *PostProcessors) rewriteResponse(data []byte) []byte {
+	return p.rewriteTexts(data, &postProcessState{}, true)
+}
+
+// rewriteStreamEvent post-processes the text delta of one stream event. The event ending a
+// text flushes the tail held back from its deltas: OpenAI Chat Completions and Gemini
+// chunks carry it themselves, while for Claude and OpenAI Responses it is returned as a
+// text delta event in flush, to be sent before the event.
+func (p *PostProcessors) rewriteStreamEvent(data []byte, state *postProcessState) (flush, out []byte) {
+	root := gjson.ParseBytes(data)
+	switch root.Get("type").String() {
+	case "response.output_text.delta":
+		return nil, p.setText(data, "delta", state, false)
+	case "response.output_text.done":
+		return p.flushEvent(root, state, "response.output_text.delta", "delta", "item_id", "output_index", "content_index"), data
+	case "content_block_stop":
+		return p.flushEvent(root, state, "content_block_delta", "delta.text", "index"), data
+	}
+	if root.Get("delta.type").String() == "text_delta" {
+		return nil, p.setText(data, "delta.text", state, false)
+	}
+
+	complete, fallback := false, ""
+	root.Get("choices").ForEach(func(i, choice gjson.Result) bool {
+		if choice.Get("finish_reason").String() != "" {
+			complete, fallback = true, fmt.Sprintf("choices.%d.delta.content", i.Int())
+		}
+		return !complete
+	})
+	for _, prefix := range []string{"", "response."} {
+		root.Get(prefix + "candidates").ForEach(func(i, candidate gjson.Result) bool {
+			if candidate.Get("finishReason").String() != "" {
+				complete, fallback = true, fmt.Sprintf("%scandidates.%d.content.parts.-1.text", prefix, i.Int())
+			}
+			return !complete
+		})
+	}
+	paths := textPaths(root)
+	for _, path := range paths {
+		data = p.setText(data, path, state, complete)
+	}
+	if complete && len(paths) == 0 {
+		if text := p.apply("", state, true); text != "" {
+			if rewritten, err := sjson.SetBytes(data, fallback, text); err == nil {
+				data = rewritten
+			}
+		}
+	}
+	return nil, data
+}
+
+// flushEvent builds the text delta event of kind carrying the tail held back for the text
+// that ends, copying the fields identifying the text from end. It returns nil when nothing
+// is held back.
+func (p *PostProcessors) flushEvent(end gjson.Result, state *postProcessState, kind, textPath string, fields ...string) []byte {
+	text := p.apply("", state, true)
+	if text == "" {
+		return nil
+	}
+	event, _ := sjson.SetBytes([]byte(`{}`), "type", kind)
+	for _, field := range fields {
+		if value := end.Get(field); value.Exists() {
+			event, _ = sjson.SetRawBytes(event, field, []byte(value.Raw))
+		}
+	}
+	if textPath == "delta.text" {
+		event, _ = sjson.SetBytes(event, "delta.type", "text_delta")
+	}
+	event, _ = sjson.SetBytes(event, textPath, text)
+	return event
+}
+
+// rewriteTexts post-processes every text part of data, in document order.
+func (p *PostProcessors) rewriteTexts(data []byte, state *postProcessState, complete bool) []byte {
+	for _, path := range textPaths(gjson.ParseBytes(data)) {
+		data = p.setText(data, path, state, complete)
+	}
+	return data
+}
+
+// textPaths lists the paths of the text parts of a response or stream chunk, in document
+// order.
+func textPaths(root gjson.Result) []string {
+	var paths []string
+	root.Get("content").ForEach(func(i, part gjson.Result) bool {
+		if part.Get("type").String() == "text" {
+			paths = append(paths, fmt.Sprintf("content.%d.text", i.Int()))
+		}
+		return true
+	})
+	root.Get("choices").ForEach(func(i, choice gjson.Result) bool {
+		for _, field := range []string{"message", "delta"} {
+			if choice.Get(field+".content").Type == gjson.String {
+				paths = append(paths, fmt.Sprintf("choices.%d.%s.content", i.Int(), field))
+			}
+		}
+		return true
+	})
+	root.Get("output").ForEach(func(i, item gjson.Result) bool {
+		item.Get("content").ForEach(func(j, part gjson.Result) bool {
+			if part.Get("type").String() == "output_text" {
+				paths = append(paths, fmt.Sprintf("output.%d.content.%d.text", i.Int(), j.Int()))
+			}
+			return true
+		})
+		return true
+	})
+	for _, prefix := range []string{"", "response."} {
+		root.Get(prefix + "candidates").ForEach(func(i, candidate gjson.Result) bool {
+			candidate.Get("content.parts").ForEach(func(j, part gjson.Result) bool {
+				if part.Get("text").Exists() && !part.Get("thought").Bool() {
+					paths = append(paths, fmt.Sprintf("%scandidates.%d.content.parts.%d.text", prefix, i.Int(), j.Int()))
+				}
+				return true
+			})
+			return true
+		})
+	}
+	return paths
+}
+
+func (p *PostProcessors) setText(data []byte, path string, state *postProcessState, complete bool) []byte {
+	text := gjson.GetBytes(data, path).String()
+	rewritten := p.apply(text, state, complete)
+	if rewritten == text {
+		return data
+	}
+	out, err := sjson.SetBytes(data, path, rewritten)
+	if err != nil {
+		log.Warnf("amp post-process: failed to rewrite %s: %v", path, err)
+		return data
+	}
+	return out
+}
@@ -0,0 +1,110 @@
+package amp
+
+import (
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/tidwall/gjson"
+)
+
+func TestPostProcessorsRewriteResponse(t *testing.T) {
+	mapper := NewModelMapper([]config.AmpModelMapping{{From: "claude-opus", To: "gemini-2.5-pro", PostProcess: []config.AmpPostProcessor{
+		{Type: "trim-stop", Stop: []string{"<|end|>"}},
+		{Type: "normalize-fences"},
+		{Type: "regex", Pattern: "(?m)^Answer: ", Replace: ""},
+		{Type: "regex", Pattern: "("},
+	}}})
+	post := mapper.MappingOverrides("claude-opus").PostProcess
+	if post == nil || len(post.steps) != 3 {
+		t.Fatalf("compiled steps = %+v, want 3 valid steps", post)
+	}
+
+	recorder := httptest.NewRecorder()
+	ginCtx, _ := gin.CreateTestContext(recorder)
+	rewriter := NewResponseRewriter(ginCtx.Writer, "claude-opus")
+	rewriter.postProcess = post
+	rewriter.Header().Set("Content-Type", "application/json")
+	_, _ = rewriter.Write([]byte(`{"model":"gemini-2.5-pro","content":[{"type":"thinking","thinking":"Answer: x"},{"type":"text","text":"Answer: see\n~~~Python\nprint(1)\n<|end|>junk"}]}`))
+	rewriter.Flush()
+
+	body := recorder.Body.Bytes()
+	if got := gjson.GetBytes(body, "content.1.text").String(); got != "see\n```python\nprint(1)\n```" {
+		t.Fatalf("text = %q", got)
+	}
+	if gjson.GetBytes(body, "content.0.thinking").String() != "Answer: x" || gjson.GetBytes(body, "model").String() != "claude-opus" {
+		t.Fatalf("unexpected rewrite: %s", body)
+	}
+}
+
+func TestPostProcessorsTrimStopAcrossStream(t *testing.T) {
+	post := compilePostProcessors([]config.AmpPostProcessor{{Type: "trim-stop", Stop: []string{"STOP"}}})
+	recorder := httptest.NewRecorder()
+	ginCtx, _ := gin.CreateTestContext(recorder)
+	rewriter := NewResponseRewriter(ginCtx.Writer, "gpt-5")
+	rewriter.postProcess = post
+	rewriter.Header().Set("Content-Type", "text/event-stream")
+
+	for _, event := range []string{
+		`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"keep STOP drop"}}`,
+		`{"choices":[{"index":0,"delta":{"content":"more"}}]}`,
+	} {
+		_, _ = rewriter.Write([]byte("data: " + event + "\n\n"))
+	}
+	want := "data: {\"type\":\"content_block_delta\",\"index\":0,\"delta\":{\"type\":\"text_delta\",\"text\":\"keep \"}}\n\n" +
+		"data: {\"choices\":[{\"index\":0,\"delta\":{\"content\":\"\"}}]}\n\n"
+	if got := recorder.Body.String(); got != want {
+		t.Fatalf("stream =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestPostProcessorsKeepTailAcrossDeltas(t *testing.T) {
+	post := compilePostProcessors([]config.AmpPostProcessor{{Type: "trim-stop", Stop: []string{"<|end|>"}}, {Type: "normalize-fences"}})
+	stream := func(events ...string) string {
+		recorder := httptest.NewRecorder()
+		ginCtx, _ := gin.CreateTestContext(recorder)
+		rewriter := NewResponseRewriter(ginCtx.Writer, "")
+		rewriter.postProcess = post
+		rewriter.Header().Set("Content-Type", "text/event-stream")
+		for _, event := range events {
+			_, _ = rewriter.Write([]byte(event))
+		}
+		return recorder.Body.String()
+	}
+	claudeDelta := func(text string) string {
+		return "event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"index\":0,\"delta\":{\"type\":\"text_delta\",\"text\":" + strconv.Quote(text) + "}}\n\n"
+	}
+	claudeText := func(body string) string {
+		var out strings.Builder
+		for _, line := range strings.Split(body, "\n") {
+			out.WriteString(gjson.Get(strings.TrimPrefix(line, "data: "), "delta.text").String())
+		}
+		return out.String()
+	}
+
+	body := stream(claudeDelta("done <|e"), claudeDelta("nd|> junk"), "event: content_block_stop\ndata: {\"type\":\"content_block_stop\",\"index\":0}\n\n")
+	if got := claudeText(body); got != "done " {
+		t.Fatalf("split stop string: text = %q", got)
+	}
+
+	body = stream(claudeDelta("see\n~~"), claudeDelta("~Py"), claudeDelta("thon\nprint(1) <"), "event: content_block_stop\ndata: {\"type\":\"content_block_stop\",\"index\":0}\n\n")
+	if got := claudeText(body); got != "see\n```python\nprint(1) <\n```" {
+		t.Fatalf("split fence: text = %q\n%s", got, body)
+	}
+	if !strings.Contains(body, "event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"index\":0,") || !strings.HasSuffix(body, "event: content_block_stop\ndata: {\"type\":\"content_block_stop\",\"index\":0}\n\n") {
+		t.Fatalf("flushed tail is not its own event before the stop:\n%s", body)
+	}
+
+	body = stream("data: {\"choices\":[{\"index\":0,\"delta\":{\"content\":\"a <|\"}}]}\n\n", "data: {\"choices\":[{\"index\":0,\"delta\":{},\"finish_reason\":\"stop\"}]}\n\n")
+	if !strings.Contains(body, `"delta":{"content":"<|"},"finish_reason":"stop"`) {
+		t.Fatalf("chat completion tail not flushed on finish:\n%s", body)
+	}
+
+	body = stream("data: {\"candidates\":[{\"content\":{\"parts\":[{\"text\":\"a <|\"}]}}]}\n\n", "data: {\"candidates\":[{\"content\":{\"parts\":[]},\"finishReason\":\"STOP\"}]}\n\n")
+	if !strings.Contains(body, `"parts":[{"text":"<|"}]},"finishReason":"STOP"`) {
+		t.Fatalf("gemini tail not flushed on finish:\n%s", body)
+	}
+}
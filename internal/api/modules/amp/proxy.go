@@ -3,6 +3,8 @@ package amp
 import (
 	"bytes"
 	"compress/gzip"
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -12,6 +14,7 @@ import (
 	"strings"
 
 	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers"
 	log "github.com/sirupsen/logrus"
 )
 
@@ -42,6 +45,9 @@ func removeQueryValuesMatching(req *http.Request, key string, match string) {
 	req.URL.RawQuery = q.Encode()
 }
 
+// statusClientClosedRequest records requests abandoned by the client (nginx convention).
+const statusClientClosedRequest = 499
+
 // readCloser wraps a reader and forwards Close to a separate closer.
 // Used to restore peeked bytes while preserving upstream body Close behavior.
 type readCloser struct {
@@ -188,10 +194,29 @@ func createReverseProxy(upstreamURL string, secretSource SecretSource) (*httputi
 
 	// Error handler for proxy failures
 	proxy.ErrorHandler = func(rw http.ResponseWriter, req *http.Request, err error) {
+		// The client went away; the upstream request was cancelled with it.
+		if errors.Is(err, context.Canceled) {
+			log.Debugf("amp upstream proxy request cancelled by client for %s %s", req.Method, req.URL.Path)
+			rw.WriteHeader(statusClientClosedRequest)
+			return
+		}
+		status, message := http.StatusBadGateway, "Failed to reach Amp upstream"
+		var maxBytesErr *http.MaxBytesError
+		switch {
+		case errors.As(err, &maxBytesErr):
+			status, message = http.StatusRequestEntityTooLarge, fmt.Sprintf("Request body exceeds the %d byte limit of the Amp upstream proxy", maxBytesErr.Limit)
+		case errors.Is(err, context.DeadlineExceeded):
+			status, message = http.StatusGatewayTimeout, "Amp upstream did not answer in time"
+		}
 		log.Errorf("amp upstream proxy error for %s %s: %v", req.Method, req.URL.Path, err)
 		rw.Header().Set("Content-Type", "application/json")
-		rw.WriteHeader(http.StatusBadGateway)
-		_, _ = rw.Write([]byte(`{"error":"amp_upstream_proxy_error","message":"Failed to reach Amp upstream"}`))
+		rw.WriteHeader(status)
+		// Provider API calls get an error in the envelope their client parses.
+		if dialect, ok := proxyErrorDialect(req.URL.Path); ok {
+			_, _ = rw.Write(handlers.BuildDialectErrorBody(dialect, status, "amp_upstream_proxy_error", message))
+			return
+		}
+		_, _ = rw.Write([]byte(fmt.Sprintf(`{"error":"amp_upstream_proxy_error","message":%q}`, message)))
 	}
 
 	return proxy, nil
@@ -0,0 +1,162 @@
+package amp
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httputil"
+	"strings"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers"
+)
+
+// proxyTuningEnabled reports whether tuning departs from the Go defaults.
+func proxyTuningEnabled(tuning config.AmpUpstreamProxy) bool {
+	return tuning.FlushIntervalMs != 0 || tuning.ResponseHeaderTimeoutSeconds > 0 || tuning.MaxIdleConns > 0 ||
+		tuning.MaxIdleConnsPerHost > 0 || tuning.IdleConnTimeoutSeconds > 0 || tuning.MaxRequestBodyBytes > 0 ||
+		tuning.TimeoutSeconds > 0 || len(tuning.Routes) > 0
+}
+
+// newTunedTransport returns the upstream transport configured by tuning, or
+// http.DefaultTransport when it leaves the connection settings unset.
+func newTunedTransport(tuning config.AmpUpstreamProxy) http.RoundTripper {
+	if tuning.ResponseHeaderTimeoutSeconds <= 0 && tuning.MaxIdleConns <= 0 && tuning.MaxIdleConnsPerHost <= 0 && tuning.IdleConnTimeoutSeconds <= 0 {
+		return http.DefaultTransport
+	}
+	base, ok := http.DefaultTransport.(*http.Transport)
+	if !ok {
+		return http.DefaultTransport
+	}
+	transport := base.Clone()
+	if tuning.ResponseHeaderTimeoutSeconds > 0 {
+		transport.ResponseHeaderTimeout = time.Duration(tuning.ResponseHeaderTimeoutSeconds) * time.Second
+	}
+	if tuning.MaxIdleConns > 0 {
+		transport.MaxIdleConns = tuning.MaxIdleConns
+	}
+	if tuning.MaxIdleConnsPerHost > 0 {
+		transport.MaxIdleConnsPerHost = tuning.MaxIdleConnsPerHost
+	}
+	if tuning.IdleConnTimeoutSeconds > 0 {
+		transport.IdleConnTimeout = time.Duration(tuning.IdleConnTimeoutSeconds) * time.Second
+	}
+	return transport
+}
+
+// applyProxyTuning sets the flush interval of proxy and wraps its transport with the body
+// size and timeout limits of tuning.
+func applyProxyTuning(proxy *httputil.ReverseProxy, tuning config.AmpUpstreamProxy) {
+	if tuning.FlushIntervalMs != 0 {
+		proxy.FlushInterval = time.Duration(tuning.FlushIntervalMs) * time.Millisecond
+	}
+	if tuning.MaxRequestBodyBytes <= 0 && tuning.TimeoutSeconds <= 0 && len(tuning.Routes) == 0 {
+		return
+	}
+	base := proxy.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	proxy.Transport = &limitedTransport{base: base, tuning: tuning}
+}
+
+// limitedTransport enforces the per-route request body size and timeout limits.
+type limitedTransport struct {
+	base   http.RoundTripper
+	tuning config.AmpUpstreamProxy
+}
+
+// limits returns the body size and timeout limits for path.
+func (t *limitedTransport) limits(path string) (int64, time.Duration) {
+	maxBody, timeout := t.tuning.MaxRequestBodyBytes, t.tuning.TimeoutSeconds
+	matched := -1
+	for _, route := range t.tuning.Routes {
+		if route.PathPrefix == "" || !strings.HasPrefix(path, route.PathPrefix) || len(route.PathPrefix) <= matched {
+			continue
+		}
+		matched = len(route.PathPrefix)
+		if route.MaxRequestBodyBytes != 0 {
+			maxBody = route.MaxRequestBodyBytes
+		}
+		if route.TimeoutSeconds != 0 {
+			timeout = route.TimeoutSeconds
+		}
+	}
+	if timeout < 0 {
+		timeout = 0
+	}
+	return maxBody, time.Duration(timeout) * time.Second
+}
+
+func (t *limitedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	maxBody, timeout := t.limits(req.URL.Path)
+	if maxBody > 0 {
+		if req.ContentLength > maxBody {
+			return nil, &http.MaxBytesError{Limit: maxBody}
+		}
+		if req.Body != nil && req.Body != http.NoBody {
+			req = req.Clone(req.Context())
+			req.Body = &maxBytesBody{ReadCloser: req.Body, remaining: maxBody, limit: maxBody}
+		}
+	}
+	if timeout <= 0 {
+		return t.base.RoundTrip(req)
+	}
+	ctx, cancel := context.WithTimeout(req.Context(), timeout)
+	resp, err := t.base.RoundTrip(req.WithContext(ctx))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	resp.Body = &cancelOnClose{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+// maxBytesBody fails reads past limit with *http.MaxBytesError.
+type maxBytesBody struct {
+	io.ReadCloser
+	remaining int64
+	limit     int64
+}
+
+func (b *maxBytesBody) Read(p []byte) (int, error) {
+	if b.remaining < 0 {
+		return 0, &http.MaxBytesError{Limit: b.limit}
+	}
+	if int64(len(p)) > b.remaining+1 {
+		p = p[:b.remaining+1]
+	}
+	n, err := b.ReadCloser.Read(p)
+	b.remaining -= int64(n)
+	if b.remaining < 0 {
+		return 0, &http.MaxBytesError{Limit: b.limit}
+	}
+	return n, err
+}
+
+// cancelOnClose releases the timeout context of a response when its body is closed.
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelOnClose) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
+// proxyErrorDialect returns the error dialect of a provider API path and whether the path
+// is one; the Amp management routes keep the legacy error body.
+func proxyErrorDialect(path string) (handlers.Dialect, bool) {
+	switch {
+	case strings.HasSuffix(path, "/messages"), strings.HasSuffix(path, "/messages/count_tokens"):
+		return handlers.DialectClaude, true
+	case strings.Contains(path, ":generateContent"), strings.Contains(path, ":streamGenerateContent"), strings.Contains(path, ":countTokens"):
+		return handlers.DialectGemini, true
+	case strings.HasSuffix(path, "/chat/completions"), strings.HasSuffix(path, "/completions"), strings.HasSuffix(path, "/responses"):
+		return handlers.DialectOpenAI, true
+	}
+	return "", false
+}
@@ -0,0 +1,98 @@
+package amp
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/tidwall/gjson"
+)
+
+func TestProxyTuningLimitsRoutes(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.Contains(r.URL.Path, "/slow/") {
+			time.Sleep(1500 * time.Millisecond)
+		}
+		_, _ = io.Copy(io.Discard, r.Body)
+		_, _ = w.Write([]byte(`{"ok":true}`))
+	}))
+	defer upstream.Close()
+
+	proxy, err := createReverseProxy(upstream.URL, NewStaticSecretSource("k"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	applyProxyTuning(proxy, config.AmpUpstreamProxy{
+		MaxRequestBodyBytes: 8,
+		Routes: []config.AmpUpstreamProxyRoute{
+			{PathPrefix: "/api/provider/anthropic", TimeoutSeconds: 1, MaxRequestBodyBytes: -1},
+			{PathPrefix: "/api/threads", MaxRequestBodyBytes: 1024},
+		},
+	})
+	srv := httptest.NewServer(proxy)
+	defer srv.Close()
+	post := func(path, body string) (int, string) {
+		resp, errPost := http.Post(srv.URL+path, "application/json", strings.NewReader(body))
+		if errPost != nil {
+			t.Fatal(errPost)
+		}
+		defer func() { _ = resp.Body.Close() }()
+		data, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, string(data)
+	}
+
+	status, body := post("/api/provider/openai/v1/chat/completions", `{"model":"gpt-5"}`)
+	if status != http.StatusRequestEntityTooLarge || gjson.Get(body, "error.code").String() != "amp_upstream_proxy_error" {
+		t.Fatalf("oversized openai request = %d %s", status, body)
+	}
+	if status, body = post("/api/threads", `{"title":"a longer thread body"}`); status != http.StatusOK {
+		t.Fatalf("route body override = %d %s", status, body)
+	}
+	if status, body = post("/api/provider/anthropic/v1/messages", `{"model":"claude-sonnet-4-5"}`); status != http.StatusOK {
+		t.Fatalf("route without body limit = %d %s", status, body)
+	}
+	status, body = post("/api/provider/anthropic/v1/slow/messages", `{}`)
+	if status != http.StatusGatewayTimeout || gjson.Get(body, "type").String() != "error" {
+		t.Fatalf("route timeout = %d %s", status, body)
+	}
+}
+
+func TestProxyErrorHandlerUsesDialect(t *testing.T) {
+	proxy, err := createReverseProxy("http://127.0.0.1:1", NewStaticSecretSource(""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(proxy)
+	defer srv.Close()
+
+	cases := map[string]string{
+		"/api/provider/anthropic/v1/messages":                           "error.type",
+		"/api/provider/google/v1beta/models/gemini-pro:generateContent": "error.status",
+		"/api/provider/openai/v1/responses":                             "error.code",
+	}
+	for path, field := range cases {
+		resp, errPost := http.Post(srv.URL+path, "application/json", strings.NewReader(`{}`))
+		if errPost != nil {
+			t.Fatal(errPost)
+		}
+		data, _ := io.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+		if resp.StatusCode != http.StatusBadGateway || !gjson.GetBytes(data, field).Exists() {
+			t.Fatalf("%s = %d %s", path, resp.StatusCode, data)
+		}
+	}
+}
+
+func TestNewTunedTransport(t *testing.T) {
+	if newTunedTransport(config.AmpUpstreamProxy{FlushIntervalMs: 50}) != http.DefaultTransport {
+		t.Fatal("transport without connection settings should be the default one")
+	}
+	transport, ok := newTunedTransport(config.AmpUpstreamProxy{ResponseHeaderTimeoutSeconds: 30, MaxIdleConns: 7, MaxIdleConnsPerHost: 3, IdleConnTimeoutSeconds: 9}).(*http.Transport)
+	if !ok || transport.ResponseHeaderTimeout != 30*time.Second || transport.MaxIdleConns != 7 || transport.MaxIdleConnsPerHost != 3 || transport.IdleConnTimeout != 9*time.Second {
+		t.Fatalf("tuned transport = %+v", transport)
+	}
+}
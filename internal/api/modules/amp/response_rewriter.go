@@ -2,29 +2,35 @@ package amp
 
 import (
 	"bytes"
+	"io"
 	"net/http"
 	"strings"
 
 	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/spill"
 	log "github.com/sirupsen/logrus"
 	"github.com/tidwall/gjson"
 	"github.com/tidwall/sjson"
 )
 
 // ResponseRewriter wraps a gin.ResponseWriter to intercept and modify the response body
-// It's used to rewrite model names in responses when model mapping is used
+// It's used to rewrite model names in responses when model mapping is used.
+// Non-streaming bodies are buffered in a spill.Buffer; bodies that spilled to disk are
+// too large to rewrite in memory and are forwarded unchanged.
 type ResponseRewriter struct {
 	gin.ResponseWriter
-	body          *bytes.Buffer
+	body          *spill.Buffer
 	originalModel string
 	isStreaming   bool
+	postProcess   *PostProcessors
+	postState     postProcessState
 }
 
 // NewResponseRewriter creates a new response rewriter for model name substitution
 func NewResponseRewriter(w gin.ResponseWriter, originalModel string) *ResponseRewriter {
 	return &ResponseRewriter{
 		ResponseWriter: w,
-		body:           &bytes.Buffer{},
+		body:           spill.New(),
 		originalModel:  originalModel,
 	}
 }
@@ -58,10 +64,30 @@ func (rw *ResponseRewriter) Flush() {
 		}
 		return
 	}
-	if rw.body.Len() > 0 {
-		if _, err := rw.ResponseWriter.Write(rw.rewriteModelInResponse(rw.body.Bytes())); err != nil {
-			log.Warnf("amp response rewriter: failed to write rewritten response: %v", err)
+	if rw.body.Len() == 0 {
+		return
+	}
+	body := rw.body
+	rw.body = spill.New()
+	defer func() { _ = body.Close() }()
+	if body.Spilled() {
+		log.Debugf("amp response rewriter: forwarding %d byte response without rewriting", body.Len())
+		if _, err := io.Copy(rw.ResponseWriter, body.Reader()); err != nil {
+			log.Warnf("amp response rewriter: failed to write spilled response: %v", err)
 		}
+		return
+	}
+	data, err := body.Bytes()
+	if err != nil {
+		log.Warnf("amp response rewriter: failed to read buffered response: %v", err)
+		return
+	}
+	data = rw.rewriteModelInResponse(data)
+	if rw.postProcess != nil {
+		data = rw.postProcess.rewriteResponse(data)
+	}
+	if _, err = rw.ResponseWriter.Write(data); err != nil {
+		log.Warnf("amp response rewriter: failed to write rewritten response: %v", err)
 	}
 }
 
@@ -104,24 +130,45 @@ func (rw *ResponseRewriter) rewriteModelInResponse(data []byte) []byte {
 	return data
 }
 
-// rewriteStreamChunk rewrites model names in SSE stream chunks
+// rewriteStreamChunk rewrites model names and post-processes text deltas in SSE stream chunks
 func (rw *ResponseRewriter) rewriteStreamChunk(chunk []byte) []byte {
-	if rw.originalModel == "" {
+	if rw.originalModel == "" && rw.postProcess == nil {
 		return chunk
 	}
 
 	// SSE format: "data: {json}\n\n"
 	lines := bytes.Split(chunk, []byte("\n"))
-	for i, line := range lines {
+	out := make([][]byte, 0, len(lines))
+	for _, line := range lines {
 		if bytes.HasPrefix(line, []byte("data: ")) {
 			jsonData := bytes.TrimPrefix(line, []byte("data: "))
 			if len(jsonData) > 0 && jsonData[0] == '{' {
 				// Rewrite JSON in the data line
 				rewritten := rw.rewriteModelInResponse(jsonData)
-				lines[i] = append([]byte("data: "), rewritten...)
+				var flush []byte
+				if rw.postProcess != nil {
+					flush, rewritten = rw.postProcess.rewriteStreamEvent(rewritten, &rw.postState)
+				}
+				if flush != nil {
+					out = insertStreamEvent(out, flush)
+				}
+				line = append([]byte("data: "), rewritten...)
 			}
 		}
+		out = append(out, line)
 	}
 
-	return bytes.Join(lines, []byte("\n"))
+	return bytes.Join(out, []byte("\n"))
+}
+
+// insertStreamEvent inserts the event data as a separate SSE event before the event whose
+// data line comes next, naming it after its type when that event is named.
+func insertStreamEvent(lines [][]byte, data []byte) [][]byte {
+	start := len(lines)
+	event := [][]byte{append([]byte("data: "), data...), nil}
+	if start > 0 && bytes.HasPrefix(lines[start-1], []byte("event:")) {
+		start--
+		event = append([][]byte{[]byte("event: " + gjson.GetBytes(data, "type").String())}, event...)
+	}
+	return append(lines[:start], append(event, lines[start:]...)...)
 }
@@ -9,7 +9,10 @@ import (
 	"strings"
 
 	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/modelcache"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/spendlimit"
 	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers"
 	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers/claude"
 	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers/gemini"
@@ -81,7 +84,7 @@ func (m *AmpModule) localhostOnlyMiddleware() gin.HandlerFunc {
 
 		// Check if IP is loopback (127.0.0.1 or ::1)
 		if !ip.IsLoopback() {
-			log.Warnf("amp management: non-localhost connection from %s attempted access, denying", remoteAddr)
+			log.Warn(logging.Msgf(logging.MsgAmpManagementDenied, remoteAddr))
 			c.AbortWithStatusJSON(403, gin.H{
 				"error": "Access denied: management routes restricted to localhost",
 			})
@@ -237,6 +240,14 @@ func (m *AmpModule) registerManagementRoutes(engine *gin.Engine, baseHandler *ha
 	geminiV1Beta1Fallback := NewFallbackHandlerWithMapper(func() *httputil.ReverseProxy {
 		return m.getProxy()
 	}, m.modelMapper, m.forceModelMappings)
+	geminiV1Beta1Fallback.SetProviderFilter(spendLimitProviderFilter(baseHandler))
+	geminiV1Beta1Fallback.setCreditRewrite(m.getCreditRewrite)
+	geminiV1Beta1Fallback.setCreditPricing(m.getCreditPricing)
+	geminiV1Beta1Fallback.setNoProviderResponse(m.noProviderResponse)
+	geminiV1Beta1Fallback.setErrorFallback(m.errorFallback, m.errorFallbackBudget)
+	geminiV1Beta1Fallback.setDisabledModels(m.disabledModels)
+	geminiV1Beta1Fallback.setModelFields(m.modelFields)
+	geminiV1Beta1Fallback.setMissingModel(m.missingModel)
 	geminiV1Beta1Handler := geminiV1Beta1Fallback.WrapHandler(geminiBridge)
 
 	// Route POST model calls through Gemini bridge with FallbackHandler.
@@ -256,6 +267,22 @@ func (m *AmpModule) registerManagementRoutes(engine *gin.Engine, baseHandler *ha
 	})
 }
 
+// spendLimitProviderFilter treats providers whose credentials have all hit their monthly
+// spend limit as unavailable, so Amp requests fall through to mappings or ampcode.com.
+// It is a no-op when the spend limit policy is "reject".
+func spendLimitProviderFilter(baseHandler *handlers.BaseAPIHandler) ProviderFilter {
+	return func(c *gin.Context, provider, model string) bool {
+		tracker := spendlimit.Default()
+		if baseHandler == nil || baseHandler.AuthManager == nil || !tracker.HasProviderLimit(provider) {
+			return true
+		}
+		if tracker.Policy() != config.SpendLimitPolicyFallthrough {
+			return true
+		}
+		return baseHandler.AuthManager.HasEligibleAuth(c.Request.Context(), provider, model)
+	}
+}
+
 // registerProviderAliases registers /api/provider/{provider}/... routes
 // These allow Amp CLI to route requests like:
 //
@@ -275,6 +302,15 @@ func (m *AmpModule) registerProviderAliases(engine *gin.Engine, baseHandler *han
 	fallbackHandler := NewFallbackHandlerWithMapper(func() *httputil.ReverseProxy {
 		return m.getProxy()
 	}, m.modelMapper, m.forceModelMappings)
+	fallbackHandler.SetProviderFilter(spendLimitProviderFilter(baseHandler))
+	fallbackHandler.setCreditRewrite(m.getCreditRewrite)
+	fallbackHandler.setCreditPricing(m.getCreditPricing)
+	fallbackHandler.setNoProviderResponse(m.noProviderResponse)
+	fallbackHandler.setErrorFallback(m.errorFallback, m.errorFallbackBudget)
+	fallbackHandler.setDisabledModels(m.disabledModels)
+	fallbackHandler.setModelFields(m.modelFields)
+	fallbackHandler.setMissingModel(m.missingModel)
+	fallbackHandler.setContextCompactor(newContextCompactor(m.contextCompaction, baseHandler))
 
 	// Provider-specific routes under /api/provider/:provider
 	ampProviders := engine.Group("/api/provider")
@@ -290,14 +326,15 @@ func (m *AmpModule) registerProviderAliases(engine *gin.Engine, baseHandler *han
 	ampModelsHandler := func(c *gin.Context) {
 		providerName := strings.ToLower(c.Param("provider"))
 
+		// Served pre-rendered: the Amp CLI lists models on every window open.
 		switch providerName {
 		case "anthropic":
-			claudeCodeHandlers.ClaudeModels(c)
+			modelcache.Default().Serve(c, modelcache.Claude, claudeCodeHandlers.ClaudeModels)
 		case "google":
-			geminiHandlers.GeminiModels(c)
+			modelcache.Default().Serve(c, modelcache.Gemini, geminiHandlers.GeminiModels)
 		default:
 			// Default to OpenAI-compatible (works for openai, groq, cerebras, etc.)
-			openaiHandlers.OpenAIModels(c)
+			modelcache.Default().Serve(c, modelcache.OpenAI, openaiHandlers.OpenAIModels)
 		}
 	}
 
@@ -327,7 +364,9 @@ func (m *AmpModule) registerProviderAliases(engine *gin.Engine, baseHandler *han
 	// Note: Gemini handler extracts model from URL path, so fallback logic needs special handling
 	v1betaAmp := provider.Group("/v1beta")
 	{
-		v1betaAmp.GET("/models", geminiHandlers.GeminiModels)
+		v1betaAmp.GET("/models", func(c *gin.Context) {
+			modelcache.Default().Serve(c, modelcache.Gemini, geminiHandlers.GeminiModels)
+		})
 		v1betaAmp.POST("/models/*action", fallbackHandler.WrapHandler(geminiHandlers.GeminiHandler))
 		v1betaAmp.GET("/models/*action", geminiHandlers.GeminiGetHandler)
 	}
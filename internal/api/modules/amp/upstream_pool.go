@@ -0,0 +1,245 @@
+package amp
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	defaultUpstreamHealthInterval = 30 * time.Second
+	upstreamHealthTimeout         = 5 * time.Second
+)
+
+// upstreamTarget is one Amp upstream together with the credentials injected for it.
+type upstreamTarget struct {
+	url     *url.URL
+	apiKey  string
+	headers map[string]string
+	healthy atomic.Bool
+}
+
+// upstreamPool is the transport of the Amp reverse proxy when several upstreams are
+// configured. Requests go to the first healthy upstream in configured order; transport
+// errors and 502/503/504 responses mark an upstream unhealthy and the request is replayed
+// against the next one. Active health checks bring upstreams back.
+type upstreamPool struct {
+	targets   []*upstreamTarget
+	transport http.RoundTripper
+	client    *http.Client
+	stop      chan struct{}
+	stopOnce  sync.Once
+}
+
+// newUpstreamPool builds a pool whose first target is primary. The reverse proxy Director
+// has already pointed outgoing requests at primary and set its credentials.
+func newUpstreamPool(primary *url.URL, settings *config.AmpCode) (*upstreamPool, error) {
+	primaryTarget := &upstreamTarget{url: primary, headers: settings.UpstreamHeaders}
+	primaryTarget.healthy.Store(true)
+	pool := &upstreamPool{
+		targets:   []*upstreamTarget{primaryTarget},
+		transport: http.DefaultTransport,
+		client:    &http.Client{Timeout: upstreamHealthTimeout},
+		stop:      make(chan struct{}),
+	}
+	for _, upstream := range settings.FallbackUpstreams {
+		raw := strings.TrimSpace(upstream.URL)
+		if raw == "" {
+			continue
+		}
+		parsed, err := url.Parse(raw)
+		if err != nil || parsed.Host == "" {
+			return nil, fmt.Errorf("invalid amp fallback upstream url %q", raw)
+		}
+		target := &upstreamTarget{url: parsed, apiKey: strings.TrimSpace(upstream.APIKey), headers: upstream.Headers}
+		target.healthy.Store(true)
+		pool.targets = append(pool.targets, target)
+	}
+	interval := time.Duration(settings.UpstreamHealthCheckSeconds) * time.Second
+	if settings.UpstreamHealthCheckSeconds == 0 {
+		interval = defaultUpstreamHealthInterval
+	}
+	if interval > 0 && len(pool.targets) > 1 {
+		go pool.runHealthChecks(interval)
+	}
+	return pool, nil
+}
+
+// Close stops the health checks.
+func (p *upstreamPool) Close() {
+	if p == nil {
+		return
+	}
+	p.stopOnce.Do(func() { close(p.stop) })
+}
+
+// order returns the healthy targets in configured order followed by the unhealthy ones,
+// so a request is still attempted when every upstream looks down.
+func (p *upstreamPool) order() []*upstreamTarget {
+	out := make([]*upstreamTarget, 0, len(p.targets))
+	for _, target := range p.targets {
+		if target.healthy.Load() {
+			out = append(out, target)
+		}
+	}
+	for _, target := range p.targets {
+		if !target.healthy.Load() {
+			out = append(out, target)
+		}
+	}
+	return out
+}
+
+// RoundTrip implements http.RoundTripper. A request is only replayed against the next
+// upstream when replaying is safe: idempotent methods always are, other requests only when
+// the failed attempt never wrote anything to the upstream (dial and TLS errors), so a POST
+// the upstream may have started processing is never sent twice.
+func (p *upstreamPool) RoundTrip(req *http.Request) (*http.Response, error) {
+	targets := p.order()
+	var body []byte
+	if len(targets) > 1 && req.Body != nil && req.Body != http.NoBody {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		_ = req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var lastErr error
+	for i, target := range targets {
+		out := p.prepare(req, target)
+		if body != nil {
+			out.Body = io.NopCloser(bytes.NewReader(body))
+			out.ContentLength = int64(len(body))
+		}
+		var sent atomic.Bool
+		out = out.WithContext(httptrace.WithClientTrace(out.Context(), &httptrace.ClientTrace{
+			WroteHeaders: func() { sent.Store(true) },
+		}))
+		resp, err := p.transport.RoundTrip(out)
+		replayable := idempotentMethod(req.Method) || !sent.Load()
+		last := i == len(targets)-1 || !replayable
+		if err == nil && !upstreamUnavailable(resp.StatusCode) {
+			p.setHealthy(target, true, "")
+			return resp, nil
+		}
+		if err == nil && last {
+			p.setHealthy(target, false, fmt.Sprintf("status %d", resp.StatusCode))
+			return resp, nil
+		}
+		if err == nil {
+			err = fmt.Errorf("status %d", resp.StatusCode)
+			_, _ = io.Copy(io.Discard, resp.Body)
+			_ = resp.Body.Close()
+		} else if req.Context().Err() != nil {
+			// The client went away; that says nothing about the upstream.
+			return nil, err
+		}
+		p.setHealthy(target, false, err.Error())
+		lastErr = err
+		if last {
+			break
+		}
+		log.Warn(logging.Msgf(logging.MsgAmpUpstreamFailover, target.url.Host, req.Method, req.URL.Path, err))
+	}
+	return nil, lastErr
+}
+
+// idempotentMethod reports whether a request with method may be sent twice (RFC 9110 9.2.2).
+func idempotentMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// prepare rewrites req for target and injects its credentials and headers.
+func (p *upstreamPool) prepare(req *http.Request, target *upstreamTarget) *http.Request {
+	out := req.Clone(req.Context())
+	if primary := p.targets[0]; target != primary {
+		rel := strings.TrimPrefix(req.URL.Path, strings.TrimSuffix(primary.url.Path, "/"))
+		out.URL.Scheme = target.url.Scheme
+		out.URL.Host = target.url.Host
+		out.URL.Path = strings.TrimSuffix(target.url.Path, "/") + "/" + strings.TrimPrefix(rel, "/")
+		out.URL.RawPath = ""
+		out.Host = target.url.Host
+	}
+	if target.apiKey != "" {
+		out.Header.Set("X-Api-Key", target.apiKey)
+		out.Header.Set("Authorization", "Bearer "+target.apiKey)
+	}
+	for name, value := range target.headers {
+		out.Header.Set(name, value)
+	}
+	return out
+}
+
+func upstreamUnavailable(status int) bool {
+	return status == http.StatusBadGateway || status == http.StatusServiceUnavailable || status == http.StatusGatewayTimeout
+}
+
+func (p *upstreamPool) setHealthy(target *upstreamTarget, healthy bool, reason string) {
+	if target.healthy.Swap(healthy) == healthy || len(p.targets) < 2 {
+		return
+	}
+	if healthy {
+		log.Info(logging.Msgf(logging.MsgAmpUpstreamHealthy, target.url.Host))
+	} else {
+		log.Warn(logging.Msgf(logging.MsgAmpUpstreamUnhealthy, target.url.Host, reason))
+	}
+}
+
+func (p *upstreamPool) runHealthChecks(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-p.stop:
+			return
+		case <-ticker.C:
+			for _, target := range p.targets {
+				p.check(target)
+			}
+		}
+	}
+}
+
+// check probes the upstream base URL. Any response below 500 counts as healthy; Amp answers
+// unauthenticated requests with 401, which still proves the upstream is reachable.
+func (p *upstreamPool) check(target *upstreamTarget) {
+	ctx, cancel := context.WithTimeout(context.Background(), upstreamHealthTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target.url.String(), nil)
+	if err != nil {
+		return
+	}
+	for name, value := range target.headers {
+		req.Header.Set(name, value)
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		p.setHealthy(target, false, err.Error())
+		return
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	_ = resp.Body.Close()
+	if resp.StatusCode >= http.StatusInternalServerError {
+		p.setHealthy(target, false, fmt.Sprintf("health check status %d", resp.StatusCode))
+		return
+	}
+	p.setHealthy(target, true, "")
+}
@@ -0,0 +1,88 @@
+package amp
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+func TestUpstreamPool_FailsOverAndInjectsCredentials(t *testing.T) {
+	primaryStatus := http.StatusServiceUnavailable
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(primaryStatus)
+	}))
+	defer primary.Close()
+
+	var gotPath, gotKey, gotRegion, gotBody string
+	fallbackHits := 0
+	fallback := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		fallbackHits++
+		gotPath, gotKey, gotRegion, gotBody = r.URL.Path, r.Header.Get("X-Api-Key"), r.Header.Get("X-Region"), string(body)
+		_, _ = w.Write([]byte(`{"ok":true}`))
+	}))
+	defer fallback.Close()
+
+	m := New(WithSecretSource(NewStaticSecretSource("primary-key")))
+	settings := &config.AmpCode{
+		UpstreamHealthCheckSeconds: -1,
+		FallbackUpstreams: []config.AmpUpstream{
+			{URL: fallback.URL + "/relay", APIKey: "relay-key", Headers: map[string]string{"X-Region": "eu"}},
+		},
+	}
+	proxy, err := m.newUpstreamProxy(primary.URL, settings)
+	if err != nil {
+		t.Fatalf("newUpstreamProxy: %v", err)
+	}
+	pool := proxy.Transport.(*upstreamPool)
+	defer pool.Close()
+	front := httptest.NewServer(proxy)
+	defer front.Close()
+
+	send := func(method, body string) int {
+		req, _ := http.NewRequest(method, front.URL+"/api/internal", strings.NewReader(body))
+		resp, errDo := http.DefaultClient.Do(req)
+		if errDo != nil {
+			t.Fatalf("request: %v", errDo)
+		}
+		_ = resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	if status := send(http.MethodGet, ""); status != http.StatusOK {
+		t.Fatalf("status = %d, want 200 from the fallback", status)
+	}
+	if gotPath != "/relay/api/internal" || gotKey != "relay-key" || gotRegion != "eu" {
+		t.Fatalf("fallback saw path=%q key=%q region=%q", gotPath, gotKey, gotRegion)
+	}
+	if pool.targets[0].healthy.Load() {
+		t.Fatal("primary should be marked unhealthy after a 503")
+	}
+	if order := pool.order(); order[0] != pool.targets[1] {
+		t.Fatal("healthy fallback should be tried first")
+	}
+
+	// A successful health check brings the primary back to the front.
+	primaryStatus = http.StatusUnauthorized
+	pool.check(pool.targets[0])
+	if order := pool.order(); order[0] != pool.targets[0] {
+		t.Fatal("recovered primary should be tried first again")
+	}
+
+	// The primary received the POST before failing, so it is not replayed elsewhere.
+	primaryStatus = http.StatusServiceUnavailable
+	if status := send(http.MethodPost, `{"q":1}`); status != http.StatusServiceUnavailable || fallbackHits != 1 {
+		t.Fatalf("POST after a 503 = %d with %d fallback hits, want the 503 and no replay", status, fallbackHits)
+	}
+
+	// A primary that cannot be reached never saw the POST, so it fails over.
+	pool.targets[0].healthy.Store(true)
+	primary.Close()
+	if status := send(http.MethodPost, `{"q":1}`); status != http.StatusOK || gotBody != `{"q":1}` {
+		t.Fatalf("POST to an unreachable primary = %d, fallback body %q", status, gotBody)
+	}
+}
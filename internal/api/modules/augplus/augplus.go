@@ -4,6 +4,10 @@
 package augplus
 
 import (
+	"crypto/subtle"
+	"net"
+	"net/http"
+	"strings"
 	"sync"
 
 	"github.com/gin-gonic/gin"
@@ -30,6 +34,13 @@ func (m *Module) Name() string {
 	return "augplus"
 }
 
+// After registers the module after Amp routing, which owns the /api prefix the AugPlus
+// routes are added to.
+func (m *Module) After() []string { return []string{"amp-routing"} }
+
+// Before lists no modules.
+func (m *Module) Before() []string { return nil }
+
 // Register sets up the AugPlus compatible routes.
 func (m *Module) Register(ctx modules.Context) error {
 	m.mu.Lock()
@@ -39,6 +50,11 @@ func (m *Module) Register(ctx modules.Context) error {
 		return nil
 	}
 
+	if !ctx.Config.LoopbackOnly() && !ctx.Config.AugPlus.RemoteAllowed() {
+		log.Warn("AugPlus compatible API module not registered: it trusts any caller, so it needs a loopback host or augplus.allow-remote with augplus.auth-token")
+		return nil
+	}
+
 	m.cfg = ctx.Config
 	m.registerRoutes(ctx.Engine, ctx.BaseHandler)
 	m.registered = true
@@ -51,25 +67,80 @@ func (m *Module) Register(ctx modules.Context) error {
 func (m *Module) OnConfigUpdated(cfg *config.Config) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
+	if !m.registered && (cfg.LoopbackOnly() || cfg.AugPlus.RemoteAllowed()) {
+		log.Warn("AugPlus compatible API module is now allowed; restart the server to register it")
+	}
 	m.cfg = cfg
 	return nil
 }
 
 // registerRoutes sets up all AugPlus compatible API routes.
 func (m *Module) registerRoutes(engine *gin.Engine, _ *handlers.BaseAPIHandler) {
+	api := engine.Group("/api", m.accessMiddleware())
+
 	// User endpoints
-	engine.POST("/api/users/card-login", m.cardLogin)
-	engine.POST("/api/users/whoami", m.whoami)
-	engine.POST("/api/users/logout", m.logout)
-	engine.POST("/api/users/vips", m.getVips)
+	api.POST("/users/card-login", m.cardLogin)
+	api.POST("/users/whoami", m.whoami)
+	api.POST("/users/logout", m.logout)
+	api.POST("/users/vips", m.getVips)
 
 	// Pool endpoints
-	engine.POST("/api/pools/gain", m.poolGain)
-	engine.POST("/api/pools/gain_list", m.poolList)
+	api.POST("/pools/gain", m.poolGain)
+	api.POST("/pools/gain_list", m.poolList)
 
 	// Proxy endpoint
-	engine.POST("/api/v1/get-proxy", m.getProxy)
+	api.POST("/v1/get-proxy", m.getProxy)
 
 	// VIP merge endpoint
-	engine.POST("/api/vips/merge", m.vipMerge)
+	api.POST("/vips/merge", m.vipMerge)
+}
+
+// remoteToken returns the token the caller of r must present, or "" when the server is
+// configured for loopback only and r arrived on a loopback address. The connection's
+// local address is checked as well as the configured host, because a socket-activated or
+// injected listener may bind other addresses. ok is false when a remote caller is not
+// allowed.
+func (m *Module) remoteToken(r *http.Request) (token string, ok bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if m.cfg.LoopbackOnly() && loopbackConn(r) {
+		return "", true
+	}
+	if !m.cfg.AugPlus.RemoteAllowed() {
+		return "", false
+	}
+	return m.cfg.AugPlus.AuthToken, true
+}
+
+// loopbackConn reports whether r arrived on a TCP connection accepted on a loopback
+// address; other listeners, unix sockets included, count as remote.
+func loopbackConn(r *http.Request) bool {
+	addr, ok := r.Context().Value(http.LocalAddrContextKey).(*net.TCPAddr)
+	return ok && addr.IP.IsLoopback()
+}
+
+// accessMiddleware enforces augplus.auth-token when the caller is remote. The
+// token is read from X-Auth-Token or an Authorization bearer header; card-login checks
+// the card instead, as that is all the extension lets users enter.
+func (m *Module) accessMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token, ok := m.remoteToken(c.Request)
+		if !ok {
+			c.AbortWithStatusJSON(http.StatusForbidden, Response{Code: -1, Msg: "remote access disabled"})
+			return
+		}
+		if token == "" || c.FullPath() == "/api/users/card-login" {
+			c.Next()
+			return
+		}
+		presented := c.GetHeader("X-Auth-Token")
+		if presented == "" {
+			presented = strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+		}
+		if subtle.ConstantTimeCompare([]byte(presented), []byte(token)) != 1 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, Response{Code: -1, Msg: "invalid auth token"})
+			return
+		}
+		c.Next()
+	}
 }
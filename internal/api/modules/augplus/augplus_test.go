@@ -0,0 +1,105 @@
+package augplus
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/api/modules"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+func registerWith(t *testing.T, cfg *config.Config) *gin.Engine {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	if err := New().Register(modules.Context{Engine: engine, Config: cfg}); err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	return engine
+}
+
+func post(engine *gin.Engine, path, body string, header http.Header) *httptest.ResponseRecorder {
+	return postOn(engine, &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 8317}, path, body, header)
+}
+
+// postOn sends a request as if it arrived on a connection accepted at local.
+func postOn(engine *gin.Engine, local net.Addr, path, body string, header http.Header) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	req = req.WithContext(context.WithValue(req.Context(), http.LocalAddrContextKey, local))
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	rec := httptest.NewRecorder()
+	engine.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestRegisterRequiresLoopbackOrToken(t *testing.T) {
+	for _, host := range []string{"", "0.0.0.0", "192.168.1.5"} {
+		engine := registerWith(t, &config.Config{Host: host, AugPlus: config.AugPlusConfig{AllowRemote: true}})
+		if rec := post(engine, "/api/users/vips", "", nil); rec.Code != http.StatusNotFound {
+			t.Fatalf("host %q: routes registered without loopback or token (status %d)", host, rec.Code)
+		}
+	}
+	for _, host := range []string{"127.0.0.1", "localhost", "::1"} {
+		engine := registerWith(t, &config.Config{Host: host})
+		if rec := post(engine, "/api/users/vips", "", nil); rec.Code != http.StatusOK {
+			t.Fatalf("host %q: status %d, want 200", host, rec.Code)
+		}
+	}
+}
+
+func TestRemoteAccessNeedsToken(t *testing.T) {
+	engine := registerWith(t, &config.Config{AugPlus: config.AugPlusConfig{AllowRemote: true, AuthToken: "secret"}})
+
+	if rec := post(engine, "/api/users/vips", "", nil); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("missing token: status %d, want 401", rec.Code)
+	}
+	if rec := post(engine, "/api/users/vips", "", http.Header{"X-Auth-Token": {"secret"}}); rec.Code != http.StatusOK {
+		t.Fatalf("valid token: status %d, want 200", rec.Code)
+	}
+	if rec := post(engine, "/api/users/card-login", `{"card":"guess"}`, nil); !strings.Contains(rec.Body.String(), "invalid card") {
+		t.Fatalf("wrong card accepted: %s", rec.Body.String())
+	}
+	rec := post(engine, "/api/users/card-login", `{"card":"secret"}`, nil)
+	if !strings.Contains(rec.Body.String(), `"token":"secret"`) {
+		t.Fatalf("card login did not return the auth token: %s", rec.Body.String())
+	}
+}
+
+func TestLoopbackHostChecksListenerAddress(t *testing.T) {
+	activated := &net.TCPAddr{IP: net.IPv4(10, 0, 0, 5), Port: 8317}
+	engine := registerWith(t, &config.Config{Host: "127.0.0.1"})
+	if rec := postOn(engine, activated, "/api/users/vips", "", nil); rec.Code != http.StatusForbidden {
+		t.Fatalf("non-loopback listener without allow-remote: status %d, want 403", rec.Code)
+	}
+	if rec := postOn(engine, &net.UnixAddr{Name: "/run/cliproxy.sock", Net: "unix"}, "/api/users/vips", "", nil); rec.Code != http.StatusForbidden {
+		t.Fatalf("unix listener without allow-remote: status %d, want 403", rec.Code)
+	}
+
+	engine = registerWith(t, &config.Config{Host: "127.0.0.1", AugPlus: config.AugPlusConfig{AllowRemote: true, AuthToken: "secret"}})
+	if rec := postOn(engine, activated, "/api/users/vips", "", nil); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("non-loopback listener without token: status %d, want 401", rec.Code)
+	}
+	if rec := postOn(engine, activated, "/api/users/vips", "", http.Header{"X-Auth-Token": {"secret"}}); rec.Code != http.StatusOK {
+		t.Fatalf("non-loopback listener with token: status %d, want 200", rec.Code)
+	}
+	if rec := post(engine, "/api/users/vips", "", nil); rec.Code != http.StatusOK {
+		t.Fatalf("loopback listener: status %d, want 200", rec.Code)
+	}
+}
+
+func TestPoolGainBracketsIPv6Host(t *testing.T) {
+	engine := registerWith(t, &config.Config{Host: "::1", Port: 8317})
+	if rec := post(engine, "/api/pools/gain", `{"product":"augment"}`, nil); !strings.Contains(rec.Body.String(), `"host":"[::1]:8317"`) {
+		t.Fatalf("pool gain host: %s", rec.Body.String())
+	}
+	if rec := post(engine, "/api/v1/get-proxy", "", nil); !strings.Contains(rec.Body.String(), `"proxy":"http://[::1]:8317/"`) {
+		t.Fatalf("proxy url: %s", rec.Body.String())
+	}
+}
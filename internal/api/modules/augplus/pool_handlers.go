@@ -1,9 +1,11 @@
 package augplus
 
 import (
-	"fmt"
+	"net"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
 )
 
 // PoolGainRequest is the request body for pool gain.
@@ -35,9 +37,7 @@ func (m *Module) poolGain(c *gin.Context) {
 	host := "127.0.0.1"
 	port := 8317
 	if cfg != nil {
-		if cfg.Host != "" && cfg.Host != "0.0.0.0" {
-			host = cfg.Host
-		}
+		host = config.AdvertisedHost(cfg.Host)
 		if cfg.Port > 0 {
 			port = cfg.Port
 		}
@@ -61,7 +61,7 @@ func (m *Module) poolGain(c *gin.Context) {
 	// Default: augment product
 	success(c, PoolAccount{
 		Token: apiKey,
-		Host:  fmt.Sprintf("%s:%d", host, port),
+		Host:  net.JoinHostPort(host, strconv.Itoa(port)),
 		Email: "local@cliproxyapi.local",
 	})
 }
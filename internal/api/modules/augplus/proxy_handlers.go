@@ -1,9 +1,11 @@
 package augplus
 
 import (
-	"fmt"
+	"net"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
 )
 
 // getProxy handles POST /api/v1/get-proxy
@@ -17,15 +19,13 @@ func (m *Module) getProxy(c *gin.Context) {
 	host := "127.0.0.1"
 	port := 8317
 	if cfg != nil {
-		if cfg.Host != "" && cfg.Host != "0.0.0.0" {
-			host = cfg.Host
-		}
+		host = config.AdvertisedHost(cfg.Host)
 		if cfg.Port > 0 {
 			port = cfg.Port
 		}
 	}
 
-	proxyURL := fmt.Sprintf("http://%s:%d/", host, port)
+	proxyURL := "http://" + net.JoinHostPort(host, strconv.Itoa(port)) + "/"
 
 	success(c, gin.H{"proxy": proxyURL})
 }
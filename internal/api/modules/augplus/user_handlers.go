@@ -2,6 +2,7 @@ package augplus
 
 import (
 	"crypto/rand"
+	"crypto/subtle"
 	"encoding/hex"
 	"time"
 
@@ -36,10 +37,20 @@ func (m *Module) cardLogin(c *gin.Context) {
 		return
 	}
 
+	// Remote callers log in with the auth token as card and use it as their user token.
+	token := generateToken()
+	if remote, _ := m.remoteToken(c.Request); remote != "" {
+		if subtle.ConstantTimeCompare([]byte(req.Card), []byte(remote)) != 1 {
+			fail(c, "invalid card")
+			return
+		}
+		token = remote
+	}
+
 	// Generate a local user with unlimited credits
 	user := User{
 		ID:    "local_user_" + time.Now().Format("20060102150405"),
-		Token: generateToken(),
+		Token: token,
 		Email: req.Email,
 		VIP: &VIP{
 			Product:   "augment",
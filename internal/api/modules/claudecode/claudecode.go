@@ -0,0 +1,166 @@
+// Package claudecode serves the endpoints the Claude Code CLI calls when its
+// ANTHROPIC_BASE_URL points at the proxy's /claude-code prefix. Requests go through the
+// provider pool like /v1/messages, with the configured model mappings applied, the
+// anthropic-beta flags the credentials cannot use removed, and responses reporting the model
+// Claude Code asked for in the stream event shapes it expects.
+package claudecode
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/api/modules"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/api/modules/clientcompat"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/modelcache"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers/claude"
+	log "github.com/sirupsen/logrus"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// Prefix is the path Claude Code's ANTHROPIC_BASE_URL points at.
+const Prefix = "/claude-code"
+
+// Module implements the Claude Code compatible routes.
+type Module struct {
+	mu         sync.RWMutex
+	cfg        config.ClaudeCodeConfig
+	registered bool
+}
+
+// New creates a new Claude Code module instance.
+func New() *Module {
+	return &Module{}
+}
+
+// Name returns the module identifier.
+func (m *Module) Name() string {
+	return "claude-code"
+}
+
+// Register sets up the Claude Code compatible routes behind the API key middleware.
+func (m *Module) Register(ctx modules.Context) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.registered {
+		return nil
+	}
+	if ctx.Config != nil {
+		m.cfg = ctx.Config.ClaudeCode
+	}
+	handler := claude.NewClaudeCodeAPIHandler(ctx.BaseHandler)
+	group := ctx.Engine.Group(Prefix)
+	if ctx.AuthMiddleware != nil {
+		group.Use(ctx.AuthMiddleware)
+	}
+	group.POST("/v1/messages", m.shape(handler.ClaudeMessages))
+	group.POST("/v1/messages/count_tokens", m.shape(handler.ClaudeCountTokens))
+	group.GET("/v1/models", func(c *gin.Context) {
+		modelcache.Default().Serve(c, modelcache.Claude, handler.ClaudeModels)
+	})
+	// Telemetry batches have nowhere to go; accepting them keeps the CLI from retrying.
+	group.POST("/api/event_logging/batch", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{})
+	})
+	m.registered = true
+	log.Infof("Claude Code compatible API module registered under %s", Prefix)
+	return nil
+}
+
+// OnConfigUpdated applies new model mappings and beta filters.
+func (m *Module) OnConfigUpdated(cfg *config.Config) error {
+	if cfg == nil {
+		return nil
+	}
+	m.mu.Lock()
+	m.cfg = cfg.ClaudeCode
+	m.mu.Unlock()
+	return nil
+}
+
+func (m *Module) settings() config.ClaudeCodeConfig {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.cfg
+}
+
+// shape applies the beta filter and model mapping to a request before next handles it. The
+// response reports the requested model and its stream events have the shape of shapeEvent.
+func (m *Module) shape(next gin.HandlerFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		cfg := m.settings()
+		dropBetas(c.Request.Header, cfg.DropBetas)
+		clientcompat.Shape(c, clientcompat.Options{
+			Name:     "claude-code",
+			Dialect:  handlers.DialectClaude,
+			Mappings: cfg.ModelMappings,
+			Event:    shapeEvent,
+		}, next)
+	}
+}
+
+// shapeEvent gives a stream event the shape Claude Code relies on, whichever provider and
+// translator produced it. Every event is named after its type, since the Anthropic SDK
+// skips unnamed events; message_start carries a message id and input and output usage, and
+// message_delta the output tokens.
+func shapeEvent(name string, data []byte) (string, []byte) {
+	kind := gjson.GetBytes(data, "type").String()
+	if name == "" {
+		name = kind
+	}
+	switch kind {
+	case "message_start":
+		if gjson.GetBytes(data, "message.id").String() == "" {
+			data, _ = sjson.SetBytes(data, "message.id", "msg_"+strings.ReplaceAll(uuid.NewString(), "-", ""))
+		}
+		for _, path := range []string{"message.usage.input_tokens", "message.usage.output_tokens"} {
+			if !gjson.GetBytes(data, path).Exists() {
+				data, _ = sjson.SetBytes(data, path, 0)
+			}
+		}
+	case "message_delta":
+		if !gjson.GetBytes(data, "usage.output_tokens").Exists() {
+			data, _ = sjson.SetBytes(data, "usage.output_tokens", 0)
+		}
+	}
+	return name, data
+}
+
+// dropBetas removes the listed flags from the anthropic-beta header.
+func dropBetas(header http.Header, drop []string) {
+	if len(drop) == 0 {
+		return
+	}
+	values := header.Values("Anthropic-Beta")
+	if len(values) == 0 {
+		return
+	}
+	kept := make([]string, 0, len(values))
+	for _, value := range values {
+		for _, flag := range strings.Split(value, ",") {
+			flag = strings.TrimSpace(flag)
+			if flag == "" || containsFold(drop, flag) {
+				continue
+			}
+			kept = append(kept, flag)
+		}
+	}
+	header.Del("Anthropic-Beta")
+	if len(kept) > 0 {
+		header.Set("Anthropic-Beta", strings.Join(kept, ","))
+	}
+}
+
+func containsFold(list []string, value string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, value) {
+			return true
+		}
+	}
+	return false
+}
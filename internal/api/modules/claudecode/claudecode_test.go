@@ -0,0 +1,101 @@
+package claudecode
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/tidwall/gjson"
+)
+
+// echoModel answers like an Anthropic upstream serving the model of the request.
+func echoModel(seen *http.Header) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		body, _ := io.ReadAll(c.Request.Body)
+		*seen = c.Request.Header.Clone()
+		c.JSON(http.StatusOK, gin.H{"type": "message", "model": gjson.GetBytes(body, "model").String()})
+	}
+}
+
+func TestShapeMapsModelAndRewritesResponse(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	m := New()
+	m.cfg = config.ClaudeCodeConfig{
+		ModelMappings: []config.ClientModelMapping{{From: "claude-3-5-haiku-*", To: "gemini-2.5-flash"}},
+		DropBetas:     []string{"context-1m-2025-08-07"},
+	}
+	var seen http.Header
+	var upstreamModel string
+	engine := gin.New()
+	engine.POST("/v1/messages", m.shape(func(c *gin.Context) {
+		body, _ := io.ReadAll(c.Request.Body)
+		upstreamModel = gjson.GetBytes(body, "model").String()
+		seen = c.Request.Header.Clone()
+		c.JSON(http.StatusOK, gin.H{"type": "message", "model": upstreamModel})
+	}))
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/messages?beta=true", strings.NewReader(`{"model":"claude-3-5-haiku-20241022","messages":[]}`))
+	req.Header.Set("Anthropic-Beta", "claude-code-20250219, context-1m-2025-08-07,interleaved-thinking-2025-05-14")
+	rec := httptest.NewRecorder()
+	engine.ServeHTTP(rec, req)
+
+	if upstreamModel != "gemini-2.5-flash" {
+		t.Fatalf("upstream model = %q, want the mapped model", upstreamModel)
+	}
+	if got := gjson.Get(rec.Body.String(), "model").String(); got != "claude-3-5-haiku-20241022" {
+		t.Fatalf("response model = %q, want the requested model; body %s", got, rec.Body.String())
+	}
+	if got := seen.Get("Anthropic-Beta"); got != "claude-code-20250219,interleaved-thinking-2025-05-14" {
+		t.Fatalf("anthropic-beta = %q", got)
+	}
+}
+
+func TestShapeLeavesUnmappedModels(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	m := New()
+	m.cfg = config.ClaudeCodeConfig{ModelMappings: []config.ClientModelMapping{{From: "claude-3-5-haiku-*", To: "gemini-2.5-flash"}}}
+	var seen http.Header
+	engine := gin.New()
+	engine.POST("/v1/messages", m.shape(echoModel(&seen)))
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/messages", strings.NewReader(`{"model":"claude-sonnet-4-5","messages":[]}`))
+	req.Header.Set("Anthropic-Beta", "context-1m-2025-08-07")
+	rec := httptest.NewRecorder()
+	engine.ServeHTTP(rec, req)
+	if got := gjson.Get(rec.Body.String(), "model").String(); got != "claude-sonnet-4-5" {
+		t.Fatalf("response model = %q", got)
+	}
+	if got := seen.Get("Anthropic-Beta"); got != "context-1m-2025-08-07" {
+		t.Fatalf("anthropic-beta = %q, want it untouched without drop-betas", got)
+	}
+}
+
+func TestShapeNamesStreamEventsAndFillsUsage(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	m := New()
+	engine := gin.New()
+	engine.POST("/v1/messages", m.shape(func(c *gin.Context) {
+		c.Header("Content-Type", "text/event-stream")
+		c.Status(http.StatusOK)
+		_, _ = c.Writer.Write([]byte("data: {\"type\":\"message_start\",\"message\":{\"model\":\"m\"}}\n\n"))
+		_, _ = c.Writer.Write([]byte("data: {\"type\":\"message_delta\",\"delta\":{\"stop_reason\":\"end_turn\"}}\n\n"))
+	}))
+
+	rec := httptest.NewRecorder()
+	engine.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/messages", strings.NewReader(`{"model":"m","stream":true}`)))
+	events := strings.Split(strings.TrimSpace(rec.Body.String()), "\n\n")
+	if len(events) != 2 || !strings.HasPrefix(events[0], "event: message_start\n") || !strings.HasPrefix(events[1], "event: message_delta\n") {
+		t.Fatalf("events = %q", events)
+	}
+	start := strings.TrimPrefix(strings.SplitN(events[0], "\n", 2)[1], "data: ")
+	if !strings.HasPrefix(gjson.Get(start, "message.id").String(), "msg_") || !gjson.Get(start, "message.usage.input_tokens").Exists() {
+		t.Fatalf("message_start = %s", start)
+	}
+	if delta := strings.TrimPrefix(strings.SplitN(events[1], "\n", 2)[1], "data: "); !gjson.Get(delta, "usage.output_tokens").Exists() {
+		t.Fatalf("message_delta = %s", delta)
+	}
+}
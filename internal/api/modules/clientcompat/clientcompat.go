@@ -0,0 +1,100 @@
+// Package clientcompat holds what the client compatible modules (claude-code, jetbrains)
+// share: the model mappings applied to their requests and the response writer that reports
+// the requested model back and reshapes streamed events.
+package clientcompat
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers"
+	log "github.com/sirupsen/logrus"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// maxBodyBytes bounds the request bodies read to map their model. A lower
+// max-request-body-bytes has already rejected larger bodies.
+const maxBodyBytes = 32 << 20
+
+// Options configures Shape.
+type Options struct {
+	// Name prefixes the log lines, e.g. "claude-code".
+	Name string
+	// Dialect is the error format of the routes.
+	Dialect handlers.Dialect
+	// Mappings route the requested model.
+	Mappings []config.ClientModelMapping
+	// Event, when set, reshapes every event of streamed responses, mapped or not.
+	Event EventFunc
+}
+
+// Shape applies the first mapping matching the model of the request body before next
+// handles it and, when the model was mapped or opts.Event is set, writes the response
+// through a ResponseWriter.
+func Shape(c *gin.Context, opts Options, next gin.HandlerFunc) {
+	body, err := ReadBody(c)
+	if err != nil {
+		status, code, message := http.StatusBadRequest, handlers.ErrorCode(""), "failed to read request body"
+		if TooLarge(err) {
+			code = handlers.ErrorCodeRequestTooLarge
+			status, message = code.StatusFor(), "request body exceeds the limit of the "+opts.Name+" routes"
+			c.Header(handlers.ErrorCodeHeader, string(code))
+		}
+		c.Data(status, "application/json", handlers.BuildDialectErrorBody(opts.Dialect, status, code, message))
+		c.Abort()
+		return
+	}
+	requested := gjson.GetBytes(body, "model").String()
+	restore := ""
+	if target := MappedModel(opts.Mappings, requested); target != "" {
+		if rewritten, errSet := sjson.SetBytes(body, "model", target); errSet == nil {
+			body, restore = rewritten, requested
+			log.Debugf("%s: mapped model %s to %s", opts.Name, requested, target)
+		}
+	}
+	c.Request.Body = io.NopCloser(bytes.NewReader(body))
+	c.Request.ContentLength = int64(len(body))
+	if restore == "" && opts.Event == nil {
+		next(c)
+		return
+	}
+	writer := NewResponseWriter(c.Writer, restore, opts.Event)
+	c.Writer = writer
+	next(c)
+	writer.Finish()
+}
+
+// ReadBody reads the request body, at most maxBodyBytes of it.
+func ReadBody(c *gin.Context) ([]byte, error) {
+	return io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxBodyBytes))
+}
+
+// TooLarge reports whether err is the error of ReadBody for a body beyond the limit.
+func TooLarge(err error) bool {
+	var tooLarge *http.MaxBytesError
+	return errors.As(err, &tooLarge)
+}
+
+// MappedModel returns the target of the first mapping matching model, or "" when none does
+// or the mapping would not change it.
+func MappedModel(mappings []config.ClientModelMapping, model string) string {
+	if model == "" {
+		return ""
+	}
+	for _, mapping := range mappings {
+		if util.MatchWildcard(mapping.From, model) {
+			if strings.EqualFold(mapping.To, model) {
+				return ""
+			}
+			return mapping.To
+		}
+	}
+	return ""
+}
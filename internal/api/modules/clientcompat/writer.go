@@ -0,0 +1,178 @@
+package clientcompat
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/spill"
+	log "github.com/sirupsen/logrus"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// EventFunc reshapes one event of a streamed response. name is its event field, "" when
+// absent; data is its JSON data.
+type EventFunc func(name string, data []byte) (string, []byte)
+
+// modelPaths are the fields of OpenAI and Claude responses and stream events naming the
+// model.
+var modelPaths = []string{"model", "message.model"}
+
+// ResponseWriter reports a requested model in place of the mapped one in the responses
+// written through it, and hands every event of streamed responses to an EventFunc. Stream
+// events are written once complete, so chunks splitting an event are fine. Non-streaming
+// bodies are buffered in a spill.Buffer when a model is restored; bodies that spilled to
+// disk are forwarded unchanged.
+type ResponseWriter struct {
+	gin.ResponseWriter
+	model     string
+	event     EventFunc
+	decided   bool
+	streaming bool
+	pending   []byte
+	body      *spill.Buffer
+}
+
+// NewResponseWriter wraps w. model is the requested model to report, "" to keep the
+// models of the responses; event may be nil.
+func NewResponseWriter(w gin.ResponseWriter, model string, event EventFunc) *ResponseWriter {
+	return &ResponseWriter{ResponseWriter: w, model: model, event: event}
+}
+
+// Write rewrites the events of streamed responses and buffers other bodies.
+func (w *ResponseWriter) Write(data []byte) (int, error) {
+	if !w.decided {
+		w.decided = true
+		w.streaming = strings.Contains(w.Header().Get("Content-Type"), "text/event-stream")
+	}
+	switch {
+	case w.streaming:
+		w.pending = append(w.pending, data...)
+		if err := w.writeEvents(false); err != nil {
+			return 0, err
+		}
+		return len(data), nil
+	case w.model == "":
+		return w.ResponseWriter.Write(data)
+	}
+	if w.body == nil {
+		w.body = spill.New()
+	}
+	return w.body.Write(data)
+}
+
+// WriteString writes s like Write.
+func (w *ResponseWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// Finish writes what is still held back: the last stream event when it lacks its blank
+// line, or the buffered body with its model rewritten.
+func (w *ResponseWriter) Finish() {
+	if w.streaming {
+		if err := w.writeEvents(true); err != nil {
+			log.Debugf("client compat: failed to write stream tail: %v", err)
+		}
+		return
+	}
+	if w.body == nil {
+		return
+	}
+	body := w.body
+	w.body = nil
+	defer func() { _ = body.Close() }()
+	if body.Spilled() {
+		if _, err := io.Copy(w.ResponseWriter, body.Reader()); err != nil {
+			log.Warnf("client compat: failed to write spilled response: %v", err)
+		}
+		return
+	}
+	data, err := body.Bytes()
+	if err != nil {
+		log.Warnf("client compat: failed to read buffered response: %v", err)
+		return
+	}
+	if _, err = w.ResponseWriter.Write(w.restoreModel(data)); err != nil {
+		log.Warnf("client compat: failed to write rewritten response: %v", err)
+	}
+}
+
+// writeEvents writes the complete events of pending, and with final also the rest.
+func (w *ResponseWriter) writeEvents(final bool) error {
+	var out []byte
+	for {
+		end := bytes.Index(w.pending, []byte("\n\n"))
+		if end < 0 {
+			break
+		}
+		out = append(out, w.shapeEvent(w.pending[:end])...)
+		w.pending = append(w.pending[:0], w.pending[end+2:]...)
+	}
+	if final && len(bytes.TrimSpace(w.pending)) > 0 {
+		out = append(out, w.shapeEvent(w.pending)...)
+		w.pending = nil
+	}
+	if len(out) == 0 {
+		return nil
+	}
+	if _, err := w.ResponseWriter.Write(out); err != nil {
+		return err
+	}
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+	return nil
+}
+
+// shapeEvent rewrites one event, given without its terminating blank line. Events without
+// JSON data, like comments and [DONE], pass unchanged.
+func (w *ResponseWriter) shapeEvent(raw []byte) []byte {
+	var name string
+	var data, other []string
+	for _, line := range strings.Split(strings.TrimRight(string(raw), "\n"), "\n") {
+		line = strings.TrimSuffix(line, "\r")
+		switch {
+		case strings.HasPrefix(line, "event:"):
+			name = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+		case strings.HasPrefix(line, "data:"):
+			data = append(data, strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " "))
+		default:
+			other = append(other, line)
+		}
+	}
+	payload := []byte(strings.Join(data, "\n"))
+	if len(data) == 0 || !gjson.ValidBytes(payload) {
+		return append(append([]byte(nil), raw...), '\n', '\n')
+	}
+	payload = w.restoreModel(payload)
+	if w.event != nil {
+		name, payload = w.event(name, payload)
+	}
+	var b bytes.Buffer
+	for _, line := range other {
+		b.WriteString(line)
+		b.WriteByte('\n')
+	}
+	if name != "" {
+		b.WriteString("event: " + name + "\n")
+	}
+	b.WriteString("data: ")
+	b.Write(payload)
+	b.WriteString("\n\n")
+	return b.Bytes()
+}
+
+func (w *ResponseWriter) restoreModel(data []byte) []byte {
+	if w.model == "" {
+		return data
+	}
+	for _, path := range modelPaths {
+		if gjson.GetBytes(data, path).Exists() {
+			data, _ = sjson.SetBytes(data, path, w.model)
+		}
+	}
+	return data
+}
@@ -0,0 +1,58 @@
+package clientcompat
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestResponseWriterRewritesEventsSplitAcrossWrites(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	rec := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(rec)
+	var names []string
+	w := NewResponseWriter(c.Writer, "gpt-5", func(name string, data []byte) (string, []byte) {
+		names = append(names, name)
+		return name, data
+	})
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.WriteHeader(http.StatusOK)
+	for _, part := range []string{
+		": keep-alive\n\n",
+		"event: chunk\ndata: {\"model\":\"claude-",
+		"sonnet-4\",\"n\":1}\n\ndata: [DONE]",
+	} {
+		if _, err := w.Write([]byte(part)); err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+	}
+	w.Finish()
+
+	want := ": keep-alive\n\nevent: chunk\ndata: {\"model\":\"gpt-5\",\"n\":1}\n\ndata: [DONE]\n\n"
+	if rec.Body.String() != want {
+		t.Fatalf("body = %q, want %q", rec.Body.String(), want)
+	}
+	if len(names) != 1 || names[0] != "chunk" {
+		t.Fatalf("events = %v, want the JSON event only", names)
+	}
+}
+
+func TestResponseWriterRestoresModelOfBufferedBodies(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	rec := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(rec)
+	w := NewResponseWriter(c.Writer, "gpt-5", nil)
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write([]byte(`{"model":"claude-`))
+	_, _ = w.Write([]byte(`sonnet-4"}`))
+	if rec.Body.Len() != 0 {
+		t.Fatalf("body written before Finish: %q", rec.Body.String())
+	}
+	w.Finish()
+	if got := rec.Body.String(); !strings.Contains(got, `"model":"gpt-5"`) {
+		t.Fatalf("body = %s", got)
+	}
+}
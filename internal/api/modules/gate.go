@@ -0,0 +1,97 @@
+package modules
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Gate enables and disables modules at runtime. Gin cannot remove routes once registered,
+// so the gate records which routes each module registers and answers 404 for the routes of
+// disabled modules, as if they did not exist.
+type Gate struct {
+	mu       sync.RWMutex
+	owners   map[string]string
+	disabled map[string]bool
+}
+
+// NewGate constructs a gate with every module enabled.
+func NewGate() *Gate {
+	return &Gate{owners: map[string]string{}, disabled: map[string]bool{}}
+}
+
+// Track runs fn, typically a module's Register or OnConfigUpdated, and attributes the routes
+// it adds to engine to the module name.
+func (g *Gate) Track(engine *gin.Engine, name string, fn func() error) error {
+	if g == nil || engine == nil {
+		return fn()
+	}
+	before := make(map[string]struct{})
+	for _, route := range engine.Routes() {
+		before[routeKey(route.Method, route.Path)] = struct{}{}
+	}
+	err := fn()
+	name = strings.ToLower(name)
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for _, route := range engine.Routes() {
+		key := routeKey(route.Method, route.Path)
+		if _, existed := before[key]; !existed {
+			g.owners[key] = name
+		}
+	}
+	return err
+}
+
+// SetEnabled enables or disables the routes of the module name.
+func (g *Gate) SetEnabled(name string, enabled bool) {
+	if g == nil {
+		return
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if enabled {
+		delete(g.disabled, strings.ToLower(name))
+	} else {
+		g.disabled[strings.ToLower(name)] = true
+	}
+}
+
+// Enabled reports whether the module name is enabled.
+func (g *Gate) Enabled(name string) bool {
+	if g == nil {
+		return true
+	}
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return !g.disabled[strings.ToLower(name)]
+}
+
+// Owner returns the module that registered the route, if any.
+func (g *Gate) Owner(method, path string) (string, bool) {
+	if g == nil {
+		return "", false
+	}
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	name, ok := g.owners[routeKey(method, path)]
+	return name, ok
+}
+
+// Middleware answers 404 for the routes of disabled modules. It must be installed on the
+// engine before the modules register their routes.
+func (g *Gate) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if name, ok := g.Owner(c.Request.Method, c.FullPath()); ok && !g.Enabled(name) {
+			c.AbortWithStatus(http.StatusNotFound)
+			return
+		}
+		c.Next()
+	}
+}
+
+func routeKey(method, path string) string {
+	return method + " " + path
+}
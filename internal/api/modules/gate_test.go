@@ -0,0 +1,47 @@
+package modules
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestGateHidesRoutesOfDisabledModules(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	gate := NewGate()
+	engine.Use(gate.Middleware())
+	engine.GET("/core", func(c *gin.Context) { c.Status(http.StatusOK) })
+	if err := gate.Track(engine, "Extra", func() error {
+		engine.GET("/extra/:id", func(c *gin.Context) { c.Status(http.StatusOK) })
+		return nil
+	}); err != nil {
+		t.Fatalf("Track: %v", err)
+	}
+
+	get := func(path string) int {
+		rr := httptest.NewRecorder()
+		engine.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, path, nil))
+		return rr.Code
+	}
+	if owner, _ := gate.Owner(http.MethodGet, "/extra/:id"); owner != "extra" {
+		t.Fatalf("owner = %q, want extra", owner)
+	}
+	if _, ok := gate.Owner(http.MethodGet, "/core"); ok {
+		t.Fatal("core route should have no owner")
+	}
+
+	gate.SetEnabled("extra", false)
+	if code := get("/extra/1"); code != http.StatusNotFound {
+		t.Fatalf("disabled module route = %d, want 404", code)
+	}
+	if code := get("/core"); code != http.StatusOK {
+		t.Fatalf("core route = %d, want 200", code)
+	}
+	gate.SetEnabled("EXTRA", true)
+	if code := get("/extra/1"); code != http.StatusOK {
+		t.Fatalf("re-enabled module route = %d, want 200", code)
+	}
+}
@@ -0,0 +1,150 @@
+// Package jetbrains serves the endpoints the JetBrains AI Assistant plugin calls for its
+// third-party providers, so IDE users can point it at the proxy's /jetbrains prefix. The
+// Ollama API is served under /jetbrains/api and the OpenAI-compatible API used by the LM
+// Studio and OpenAI-compatible providers under /jetbrains/v1. Requests go through the
+// provider pool with the configured model mappings applied, and responses report the model
+// the IDE asked for.
+//
+// The plugin sends no API key to Ollama and LM Studio, so both APIs are also served under
+// /jetbrains/key/<api-key>, which authenticates with the key of the path. Logs, request logs
+// and transcripts mask the key (util.MaskSensitivePath).
+package jetbrains
+
+import (
+	"net/http"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/api/modules"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/api/modules/clientcompat"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers/openai"
+	log "github.com/sirupsen/logrus"
+)
+
+// Prefix is the path the plugin's provider URL points at.
+const Prefix = "/jetbrains"
+
+// Module implements the JetBrains AI Assistant compatible routes.
+type Module struct {
+	mu         sync.RWMutex
+	cfg        config.JetBrainsConfig
+	handler    *openai.OpenAIAPIHandler
+	registered bool
+}
+
+// New creates a new JetBrains module instance.
+func New() *Module {
+	return &Module{}
+}
+
+// Name returns the module identifier.
+func (m *Module) Name() string {
+	return "jetbrains"
+}
+
+// Register sets up the JetBrains compatible routes behind the API key middleware.
+func (m *Module) Register(ctx modules.Context) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.registered {
+		return nil
+	}
+	if ctx.Config != nil {
+		m.cfg = ctx.Config.JetBrains
+	}
+	m.handler = openai.NewOpenAIAPIHandler(ctx.BaseHandler)
+	// Ollama answers its root path, which the plugin probes before listing models.
+	running := func(c *gin.Context) { c.String(http.StatusOK, "Ollama is running") }
+	ctx.Engine.GET(Prefix, running)
+	ctx.Engine.HEAD(Prefix, running)
+
+	group := ctx.Engine.Group(Prefix)
+	if ctx.AuthMiddleware != nil {
+		group.Use(ctx.AuthMiddleware)
+	}
+	m.registerRoutes(group)
+
+	keyed := ctx.Engine.Group(Prefix+"/key/:key", pathKey)
+	keyed.GET("", running)
+	keyed.HEAD("", running)
+	if ctx.AuthMiddleware != nil {
+		keyed.Use(ctx.AuthMiddleware)
+	}
+	m.registerRoutes(keyed)
+
+	m.registered = true
+	log.Infof("JetBrains AI Assistant compatible API module registered under %s", Prefix)
+	return nil
+}
+
+func (m *Module) registerRoutes(group *gin.RouterGroup) {
+	group.GET("/api/version", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"version": ollamaVersion})
+	})
+	group.GET("/api/tags", m.ollamaTags)
+	group.GET("/api/ps", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"models": []any{}})
+	})
+	group.POST("/api/show", m.ollamaShow)
+	group.POST("/api/chat", m.ollamaChat)
+
+	group.GET("/v1/models", m.models)
+	group.POST("/v1/chat/completions", m.shape(m.handler.ChatCompletions))
+	group.POST("/v1/completions", m.shape(m.handler.Completions))
+}
+
+// OnConfigUpdated applies new model mappings.
+func (m *Module) OnConfigUpdated(cfg *config.Config) error {
+	if cfg == nil {
+		return nil
+	}
+	m.mu.Lock()
+	m.cfg = cfg.JetBrains
+	m.mu.Unlock()
+	return nil
+}
+
+func (m *Module) settings() config.JetBrainsConfig {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.cfg
+}
+
+// pathKey authenticates requests of the keyed routes with the API key of their path.
+func pathKey(c *gin.Context) {
+	if key := c.Param("key"); key != "" {
+		c.Request.Header.Set("Authorization", "Bearer "+key)
+	}
+	c.Next()
+}
+
+// models lists the available models and the mapped model names, in the OpenAI format.
+func (m *Module) models(c *gin.Context) {
+	data := make([]gin.H, 0)
+	for _, model := range m.modelList() {
+		data = append(data, gin.H{"id": model.id, "object": "model", "created": model.created, "owned_by": model.ownedBy})
+	}
+	c.JSON(http.StatusOK, gin.H{"object": "list", "data": data})
+}
+
+// shape applies the model mapping to an OpenAI request before next handles it and, when the
+// model was mapped, rewrites the model of the response back.
+func (m *Module) shape(next gin.HandlerFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		clientcompat.Shape(c, clientcompat.Options{
+			Name:     "jetbrains",
+			Dialect:  handlers.DialectOpenAI,
+			Mappings: m.settings().ModelMappings,
+		}, next)
+	}
+}
+
+// resolveModel returns the model a request for model is served by.
+func (m *Module) resolveModel(model string) string {
+	if target := clientcompat.MappedModel(m.settings().ModelMappings, model); target != "" {
+		return target
+	}
+	return model
+}
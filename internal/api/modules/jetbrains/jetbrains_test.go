@@ -0,0 +1,141 @@
+package jetbrains
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/api/modules"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers"
+	sdkconfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	"github.com/tidwall/gjson"
+)
+
+func TestOllamaChatConvertsToChatCompletions(t *testing.T) {
+	body := []byte(`{"model":"ide-chat:latest","stream":false,"format":"json",
+		"options":{"temperature":0.2,"num_predict":256,"top_k":40},
+		"tools":[{"type":"function","function":{"name":"read_file","parameters":{"type":"object"}}}],
+		"messages":[
+			{"role":"user","content":"look","images":["aGVsbG8="]},
+			{"role":"assistant","content":"","tool_calls":[{"function":{"name":"read_file","arguments":{"path":"a.go"}}}]},
+			{"role":"tool","content":"package a"}
+		]}`)
+	out, err := ollamaToChatCompletions(body, "gemini-2.5-pro", false)
+	if err != nil {
+		t.Fatalf("ollamaToChatCompletions: %v", err)
+	}
+	root := gjson.ParseBytes(out)
+	checks := map[string]string{
+		"model":                              "gemini-2.5-pro",
+		"temperature":                        "0.2",
+		"max_tokens":                         "256",
+		"response_format.type":               "json_object",
+		"tools.0.function.name":              "read_file",
+		"messages.0.content.1.image_url.url": "data:image/png;base64,aGVsbG8=",
+		"messages.1.tool_calls.0.id":         "call_1",
+		"messages.1.tool_calls.0.function.arguments": `{"path":"a.go"}`,
+		"messages.2.tool_call_id":                    "call_1",
+	}
+	for path, want := range checks {
+		if got := root.Get(path).String(); got != want {
+			t.Errorf("%s = %q, want %q", path, got, want)
+		}
+	}
+	if root.Get("top_k").Exists() {
+		t.Error("unsupported option top_k forwarded")
+	}
+}
+
+func TestChatCompletionToOllama(t *testing.T) {
+	resp := []byte(`{"choices":[{"message":{"role":"assistant","content":"hi","tool_calls":[{"id":"x","type":"function","function":{"name":"grep","arguments":"{\"q\":\"a\"}"}}]},"finish_reason":"tool_calls"}],"usage":{"prompt_tokens":12,"completion_tokens":3}}`)
+	out := gjson.ParseBytes(chatCompletionToOllama(resp, "ide-chat", time.Second))
+	if out.Get("model").String() != "ide-chat" || !out.Get("done").Bool() || out.Get("done_reason").String() != "stop" {
+		t.Fatalf("response = %s", out.Raw)
+	}
+	if out.Get("message.content").String() != "hi" || out.Get("message.tool_calls.0.function.arguments.q").String() != "a" {
+		t.Fatalf("message = %s", out.Get("message").Raw)
+	}
+	if out.Get("prompt_eval_count").Int() != 12 || out.Get("eval_count").Int() != 3 {
+		t.Fatalf("counts = %s", out.Raw)
+	}
+}
+
+func TestOllamaStreamAssemblesToolCalls(t *testing.T) {
+	s := &ollamaStream{model: "ide-chat", start: time.Now()}
+	var lines [][]byte
+	for _, chunk := range []string{
+		`{"choices":[{"delta":{"content":"Let me check"}}]}`,
+		`{"choices":[{"delta":{"tool_calls":[{"index":0,"function":{"name":"grep","arguments":"{\"q\":"}}]}}]}`,
+		`{"choices":[{"delta":{"tool_calls":[{"index":0,"function":{"arguments":"\"a\"}"}}]},"finish_reason":"tool_calls"}]}`,
+		`{"choices":[],"usage":{"prompt_tokens":7,"completion_tokens":5}}`,
+	} {
+		lines = append(lines, s.chunk([]byte(chunk))...)
+	}
+	lines = append(lines, s.done()...)
+	if len(lines) != 3 {
+		t.Fatalf("lines = %q, want text, tool calls and final", lines)
+	}
+	if got := gjson.GetBytes(lines[0], "message.content").String(); got != "Let me check" {
+		t.Fatalf("text line = %s", lines[0])
+	}
+	if got := gjson.GetBytes(lines[1], "message.tool_calls.0.function.arguments.q").String(); got != "a" {
+		t.Fatalf("tool call line = %s", lines[1])
+	}
+	final := gjson.ParseBytes(lines[2])
+	if !final.Get("done").Bool() || final.Get("eval_count").Int() != 5 || final.Get("prompt_eval_count").Int() != 7 {
+		t.Fatalf("final line = %s", lines[2])
+	}
+}
+
+func TestKeyedRoutesAuthenticateWithPathKey(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	m := New()
+	cfg := &config.Config{JetBrains: config.JetBrainsConfig{ModelMappings: []config.ClientModelMapping{
+		{From: "ide-chat", To: "gemini-2.5-pro"},
+		{From: "ide-*", To: "gemini-2.5-flash"},
+	}}}
+	auth := func(c *gin.Context) {
+		if c.GetHeader("Authorization") != "Bearer secret" {
+			c.AbortWithStatus(http.StatusUnauthorized)
+			return
+		}
+		c.Next()
+	}
+	err := m.Register(modules.Context{
+		Engine:         engine,
+		BaseHandler:    handlers.NewBaseAPIHandlers(&sdkconfig.SDKConfig{}, nil),
+		Config:         cfg,
+		AuthMiddleware: auth,
+	})
+	if err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+
+	serve := func(method, path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		engine.ServeHTTP(rec, httptest.NewRequest(method, path, bytes.NewReader(nil)))
+		return rec
+	}
+	if rec := serve(http.MethodGet, "/jetbrains/api/tags"); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("unkeyed tags status = %d, want 401", rec.Code)
+	}
+	rec := serve(http.MethodGet, "/jetbrains/key/secret/api/tags")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("keyed tags status = %d", rec.Code)
+	}
+	names := gjson.Get(rec.Body.String(), "models.#.name").Array()
+	if len(names) != 1 || names[0].String() != "ide-chat" {
+		t.Fatalf("listed models = %s, want the mapped name only", rec.Body.String())
+	}
+	if rec = serve(http.MethodGet, "/jetbrains/key/wrong/api/version"); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("wrong key status = %d, want 401", rec.Code)
+	}
+	if rec = serve(http.MethodGet, "/jetbrains"); rec.Body.String() != "Ollama is running" {
+		t.Fatalf("root = %q", rec.Body.String())
+	}
+}
@@ -0,0 +1,499 @@
+package jetbrains
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/api/modules/clientcompat"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/thinking"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// ollamaVersion is the Ollama version reported to the plugin, recent enough for it to use
+// tool calling.
+const ollamaVersion = "0.9.0"
+
+// ollamaOptions maps Ollama request options to Chat Completions fields.
+var ollamaOptions = map[string]string{
+	"temperature":       "temperature",
+	"top_p":             "top_p",
+	"num_predict":       "max_tokens",
+	"stop":              "stop",
+	"seed":              "seed",
+	"presence_penalty":  "presence_penalty",
+	"frequency_penalty": "frequency_penalty",
+}
+
+type listedModel struct {
+	id      string
+	ownedBy string
+	created int64
+}
+
+// modelList returns the available models and the mapped model names without wildcards,
+// sorted by id.
+func (m *Module) modelList() []listedModel {
+	seen := make(map[string]bool)
+	var models []listedModel
+	for _, entry := range registry.GetGlobalRegistry().GetAvailableModels("openai") {
+		id, _ := entry["id"].(string)
+		if id == "" || seen[id] {
+			continue
+		}
+		seen[id] = true
+		model := listedModel{id: id}
+		model.ownedBy, _ = entry["owned_by"].(string)
+		switch created := entry["created"].(type) {
+		case int64:
+			model.created = created
+		case int:
+			model.created = int64(created)
+		}
+		models = append(models, model)
+	}
+	for _, mapping := range m.settings().ModelMappings {
+		if strings.Contains(mapping.From, "*") || seen[mapping.From] {
+			continue
+		}
+		seen[mapping.From] = true
+		models = append(models, listedModel{id: mapping.From, ownedBy: "cliproxy"})
+	}
+	sort.Slice(models, func(i, j int) bool { return models[i].id < models[j].id })
+	return models
+}
+
+// ollamaModelName drops the ":latest" tag Ollama clients may append to model names.
+func ollamaModelName(name string) string {
+	return strings.TrimSuffix(strings.TrimSpace(name), ":latest")
+}
+
+func ollamaDetails(family string) gin.H {
+	return gin.H{"parent_model": "", "format": "api", "family": family, "families": []string{family}, "parameter_size": "", "quantization_level": ""}
+}
+
+// ollamaTags lists the models in the Ollama format.
+func (m *Module) ollamaTags(c *gin.Context) {
+	models := make([]gin.H, 0)
+	for _, model := range m.modelList() {
+		modified := time.Unix(model.created, 0).UTC()
+		if model.created == 0 {
+			modified = time.Now().UTC()
+		}
+		models = append(models, gin.H{
+			"name":        model.id,
+			"model":       model.id,
+			"modified_at": modified.Format(time.RFC3339),
+			"size":        0,
+			"digest":      "",
+			"details":     ollamaDetails(model.ownedBy),
+		})
+	}
+	c.JSON(http.StatusOK, gin.H{"models": models})
+}
+
+// ollamaShow describes a model in the Ollama format, with the context length of the model it
+// is served by.
+func (m *Module) ollamaShow(c *gin.Context) {
+	body, err := clientcompat.ReadBody(c)
+	if err != nil {
+		ollamaBodyError(c, err)
+		return
+	}
+	name := gjson.GetBytes(body, "model").String()
+	if name == "" {
+		name = gjson.GetBytes(body, "name").String()
+	}
+	name = ollamaModelName(name)
+	if name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "model is required"})
+		return
+	}
+	family := "cliproxy"
+	modelInfo := gin.H{"general.architecture": family}
+	if info := registry.LookupModelInfo(thinking.ParseSuffix(m.resolveModel(name)).ModelName); info != nil {
+		contextLength := info.ContextLength
+		if info.InputTokenLimit > 0 {
+			contextLength = info.InputTokenLimit
+		}
+		if contextLength > 0 {
+			modelInfo[family+".context_length"] = contextLength
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"modelfile":    "",
+		"parameters":   "",
+		"template":     "",
+		"details":      ollamaDetails(family),
+		"model_info":   modelInfo,
+		"capabilities": []string{"completion", "tools"},
+	})
+}
+
+// ollamaChat serves /api/chat through the Chat Completions pipeline.
+func (m *Module) ollamaChat(c *gin.Context) {
+	body, err := clientcompat.ReadBody(c)
+	if err != nil {
+		ollamaBodyError(c, err)
+		return
+	}
+	if !gjson.ValidBytes(body) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+	requested := ollamaModelName(gjson.GetBytes(body, "model").String())
+	if requested == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "model is required"})
+		return
+	}
+	stream := true
+	if value := gjson.GetBytes(body, "stream"); value.Exists() {
+		stream = value.Bool()
+	}
+	model := m.resolveModel(requested)
+	request, err := ollamaToChatCompletions(body, model, stream)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	h := m.handler
+	start := time.Now()
+	cliCtx, cliCancel := h.GetContextWithCancel(h, c, context.Background())
+	if !stream {
+		resp, errMsg := h.ExecuteWithAuthManager(cliCtx, h.HandlerType(), model, request, "")
+		if errMsg != nil {
+			writeOllamaError(c, errMsg)
+			cliCancel(errMsg.Error)
+			return
+		}
+		c.Data(http.StatusOK, "application/json", chatCompletionToOllama(resp, requested, time.Since(start)))
+		cliCancel()
+		return
+	}
+
+	flusher, ok := c.Writer.(http.Flusher)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "streaming not supported"})
+		cliCancel(nil)
+		return
+	}
+	dataChan, errChan := h.ExecuteStreamWithAuthManager(cliCtx, h.HandlerType(), model, request, "")
+	// Fail with a status while nothing is written; the first chunk commits the stream.
+	var first []byte
+	for first == nil {
+		select {
+		case <-c.Request.Context().Done():
+			cliCancel(c.Request.Context().Err())
+			return
+		case errMsg, okErr := <-errChan:
+			if !okErr {
+				errChan = nil
+				continue
+			}
+			writeOllamaError(c, errMsg)
+			if errMsg != nil {
+				cliCancel(errMsg.Error)
+			} else {
+				cliCancel(nil)
+			}
+			return
+		case chunk, okData := <-dataChan:
+			if !okData {
+				dataChan = nil
+				first = []byte{}
+				continue
+			}
+			first = chunk
+		}
+	}
+
+	c.Header("Content-Type", "application/x-ndjson")
+	c.Status(http.StatusOK)
+	conv := &ollamaStream{model: requested, start: start}
+	writeLines := func(lines [][]byte) {
+		for _, line := range lines {
+			_, _ = c.Writer.Write(line)
+			_, _ = c.Writer.Write([]byte("\n"))
+		}
+	}
+	writeLines(conv.chunk(first))
+	flusher.Flush()
+	if dataChan == nil {
+		writeLines(conv.done())
+		flusher.Flush()
+		cliCancel(nil)
+		return
+	}
+	noKeepAlive := time.Duration(0)
+	h.ForwardStream(c, flusher, func(err error) { cliCancel(err) }, dataChan, errChan, handlers.StreamForwardOptions{
+		KeepAliveInterval: &noKeepAlive,
+		WriteChunk:        func(chunk []byte) { writeLines(conv.chunk(chunk)) },
+		WriteTerminalError: func(errMsg *interfaces.ErrorMessage) {
+			if errMsg != nil && errMsg.Error != nil {
+				line, _ := json.Marshal(gin.H{"error": errMsg.Error.Error()})
+				writeLines([][]byte{line})
+			}
+		},
+		WriteDone: func() { writeLines(conv.done()) },
+	})
+}
+
+// writeOllamaError writes errMsg in Ollama's {"error": message} envelope.
+func writeOllamaError(c *gin.Context, errMsg *interfaces.ErrorMessage) {
+	code, status := handlers.ClassifyError(errMsg)
+	text := http.StatusText(status)
+	if errMsg != nil && errMsg.Error != nil && errMsg.Error.Error() != "" {
+		text = errMsg.Error.Error()
+		if message := gjson.Get(text, "error.message"); message.Exists() {
+			text = message.String()
+		}
+	}
+	if code != "" {
+		c.Header(handlers.ErrorCodeHeader, string(code))
+	}
+	c.JSON(status, gin.H{"error": text})
+}
+
+// ollamaToChatCompletions converts an Ollama chat request to a Chat Completions request for
+// model.
+func ollamaToChatCompletions(body []byte, model string, stream bool) ([]byte, error) {
+	root := gjson.ParseBytes(body)
+	out := []byte(`{}`)
+	out, _ = sjson.SetBytes(out, "model", model)
+	out, _ = sjson.SetBytes(out, "stream", stream)
+	if stream {
+		out, _ = sjson.SetBytes(out, "stream_options.include_usage", true)
+	}
+
+	messages := []byte(`[]`)
+	var pendingCalls []string
+	callSeq := 0
+	for _, message := range root.Get("messages").Array() {
+		role := message.Get("role").String()
+		msg := []byte(`{}`)
+		msg, _ = sjson.SetBytes(msg, "role", role)
+		content := message.Get("content").String()
+		if images := message.Get("images").Array(); len(images) > 0 {
+			parts := []byte(`[]`)
+			if content != "" {
+				parts, _ = sjson.SetRawBytes(parts, "-1", mustJSON(gin.H{"type": "text", "text": content}))
+			}
+			for _, image := range images {
+				url := "data:image/png;base64," + image.String()
+				parts, _ = sjson.SetRawBytes(parts, "-1", mustJSON(gin.H{"type": "image_url", "image_url": gin.H{"url": url}}))
+			}
+			msg, _ = sjson.SetRawBytes(msg, "content", parts)
+		} else {
+			msg, _ = sjson.SetBytes(msg, "content", content)
+		}
+		switch role {
+		case "assistant":
+			calls := message.Get("tool_calls").Array()
+			for _, call := range calls {
+				callSeq++
+				id := fmt.Sprintf("call_%d", callSeq)
+				pendingCalls = append(pendingCalls, id)
+				arguments := call.Get("function.arguments")
+				args := arguments.String()
+				if arguments.IsObject() {
+					args = arguments.Raw
+				}
+				msg, _ = sjson.SetRawBytes(msg, "tool_calls.-1", mustJSON(gin.H{
+					"id":       id,
+					"type":     "function",
+					"function": gin.H{"name": call.Get("function.name").String(), "arguments": args},
+				}))
+			}
+		case "tool":
+			// Ollama answers tool calls in order without ids.
+			id := fmt.Sprintf("call_%d", callSeq)
+			if len(pendingCalls) > 0 {
+				id, pendingCalls = pendingCalls[0], pendingCalls[1:]
+			}
+			msg, _ = sjson.SetBytes(msg, "tool_call_id", id)
+		}
+		messages, _ = sjson.SetRawBytes(messages, "-1", msg)
+	}
+	out, _ = sjson.SetRawBytes(out, "messages", messages)
+
+	if tools := root.Get("tools"); tools.IsArray() && len(tools.Array()) > 0 {
+		out, _ = sjson.SetRawBytes(out, "tools", []byte(tools.Raw))
+	}
+	for key, field := range ollamaOptions {
+		if value := root.Get("options." + key); value.Exists() {
+			out, _ = sjson.SetRawBytes(out, field, []byte(value.Raw))
+		}
+	}
+	switch format := root.Get("format"); {
+	case format.IsObject():
+		out, _ = sjson.SetRawBytes(out, "response_format", mustJSON(gin.H{
+			"type":        "json_schema",
+			"json_schema": gin.H{"name": "response", "schema": json.RawMessage(format.Raw)},
+		}))
+	case format.String() == "json":
+		out, _ = sjson.SetRawBytes(out, "response_format", []byte(`{"type":"json_object"}`))
+	}
+	if !gjson.ValidBytes(out) {
+		return nil, fmt.Errorf("invalid chat request")
+	}
+	return out, nil
+}
+
+func mustJSON(value any) []byte {
+	data, _ := json.Marshal(value)
+	return data
+}
+
+// ollamaDoneReason maps a Chat Completions finish reason to Ollama's done_reason.
+func ollamaDoneReason(finish string) string {
+	if finish == "length" {
+		return "length"
+	}
+	return "stop"
+}
+
+// ollamaToolCalls converts Chat Completions tool calls to Ollama's, which carry the
+// arguments as an object.
+func ollamaToolCalls(calls []gjson.Result) []gin.H {
+	out := make([]gin.H, 0, len(calls))
+	for _, call := range calls {
+		out = append(out, ollamaToolCall(call.Get("function.name").String(), call.Get("function.arguments").String()))
+	}
+	return out
+}
+
+func ollamaToolCall(name, arguments string) gin.H {
+	var args any = map[string]any{}
+	if arguments != "" && gjson.Valid(arguments) {
+		args = json.RawMessage(arguments)
+	}
+	return gin.H{"function": gin.H{"name": name, "arguments": args}}
+}
+
+// chatCompletionToOllama converts a Chat Completions response to an Ollama chat response
+// reporting model.
+func chatCompletionToOllama(resp []byte, model string, elapsed time.Duration) []byte {
+	root := gjson.ParseBytes(resp)
+	choice := root.Get("choices.0")
+	message := gin.H{"role": "assistant", "content": choice.Get("message.content").String()}
+	if reasoning := choice.Get("message.reasoning_content").String(); reasoning != "" {
+		message["thinking"] = reasoning
+	}
+	if calls := choice.Get("message.tool_calls").Array(); len(calls) > 0 {
+		message["tool_calls"] = ollamaToolCalls(calls)
+	}
+	return mustJSON(gin.H{
+		"model":             model,
+		"created_at":        time.Now().UTC().Format(time.RFC3339Nano),
+		"message":           message,
+		"done":              true,
+		"done_reason":       ollamaDoneReason(choice.Get("finish_reason").String()),
+		"total_duration":    elapsed.Nanoseconds(),
+		"load_duration":     0,
+		"prompt_eval_count": root.Get("usage.prompt_tokens").Int(),
+		"eval_count":        root.Get("usage.completion_tokens").Int(),
+	})
+}
+
+// ollamaStream converts Chat Completions stream chunks to Ollama's NDJSON chat stream. Tool
+// calls arrive in fragments and are sent whole before the final line.
+type ollamaStream struct {
+	model        string
+	start        time.Time
+	finish       string
+	promptTokens int64
+	outputTokens int64
+	calls        []ollamaStreamCall
+}
+
+type ollamaStreamCall struct {
+	name      string
+	arguments strings.Builder
+}
+
+func (s *ollamaStream) line(message gin.H, done bool) []byte {
+	return mustJSON(gin.H{
+		"model":      s.model,
+		"created_at": time.Now().UTC().Format(time.RFC3339Nano),
+		"message":    message,
+		"done":       done,
+	})
+}
+
+// chunk returns the lines of one stream chunk.
+func (s *ollamaStream) chunk(data []byte) [][]byte {
+	root := gjson.ParseBytes(data)
+	if usage := root.Get("usage"); usage.IsObject() {
+		s.promptTokens = usage.Get("prompt_tokens").Int()
+		s.outputTokens = usage.Get("completion_tokens").Int()
+	}
+	var lines [][]byte
+	root.Get("choices").ForEach(func(_, choice gjson.Result) bool {
+		if finish := choice.Get("finish_reason").String(); finish != "" {
+			s.finish = finish
+		}
+		delta := choice.Get("delta")
+		for _, call := range delta.Get("tool_calls").Array() {
+			index := int(call.Get("index").Int())
+			for len(s.calls) <= index {
+				s.calls = append(s.calls, ollamaStreamCall{})
+			}
+			if name := call.Get("function.name").String(); name != "" {
+				s.calls[index].name = name
+			}
+			s.calls[index].arguments.WriteString(call.Get("function.arguments").String())
+		}
+		message := gin.H{"role": "assistant", "content": delta.Get("content").String()}
+		if reasoning := delta.Get("reasoning_content").String(); reasoning != "" {
+			message["thinking"] = reasoning
+		}
+		if message["content"] != "" || message["thinking"] != nil {
+			lines = append(lines, s.line(message, false))
+		}
+		return true
+	})
+	return lines
+}
+
+// done returns the final lines of the stream.
+func (s *ollamaStream) done() [][]byte {
+	var lines [][]byte
+	if len(s.calls) > 0 {
+		calls := make([]gin.H, 0, len(s.calls))
+		for i := range s.calls {
+			calls = append(calls, ollamaToolCall(s.calls[i].name, s.calls[i].arguments.String()))
+		}
+		lines = append(lines, s.line(gin.H{"role": "assistant", "content": "", "tool_calls": calls}, false))
+	}
+	final := gin.H{
+		"model":             s.model,
+		"created_at":        time.Now().UTC().Format(time.RFC3339Nano),
+		"message":           gin.H{"role": "assistant", "content": ""},
+		"done":              true,
+		"done_reason":       ollamaDoneReason(s.finish),
+		"total_duration":    time.Since(s.start).Nanoseconds(),
+		"load_duration":     0,
+		"prompt_eval_count": s.promptTokens,
+		"eval_count":        s.outputTokens,
+	}
+	return append(lines, mustJSON(final))
+}
+
+// ollamaBodyError answers a request whose body could not be read.
+func ollamaBodyError(c *gin.Context, err error) {
+	if clientcompat.TooLarge(err) {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "request body too large"})
+		return
+	}
+	c.JSON(http.StatusBadRequest, gin.H{"error": "failed to read request body"})
+}
@@ -47,7 +47,8 @@ type RouteModule interface {
 // OnConfigUpdated.
 //
 // This is the preferred interface for new modules. It uses Context for cleaner
-// dependency injection and supports idempotent registration.
+// dependency injection and supports idempotent registration. Modules can also
+// implement Dependent and Ordered to constrain their registration order; see Order.
 type RouteModuleV2 interface {
 	// Name returns a unique identifier for logging and diagnostics.
 	Name() string
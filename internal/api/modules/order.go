@@ -0,0 +1,140 @@
+package modules
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Named is implemented by every routing module.
+type Named interface {
+	Name() string
+}
+
+// Dependent is implemented by modules that need other modules. A module is registered after
+// its dependencies, and is not registered when one of them is missing.
+type Dependent interface {
+	// Dependencies returns the names of the modules this module needs.
+	Dependencies() []string
+}
+
+// Ordered is implemented by modules whose routes or middleware must be attached before or
+// after those of other modules. Constraints naming absent modules are ignored.
+type Ordered interface {
+	// After returns the names of the modules that must be registered before this module.
+	After() []string
+	// Before returns the names of the modules that must be registered after this module.
+	Before() []string
+}
+
+// Order sorts mods into registration order: every module comes after its dependencies and
+// the modules it must follow, and the given order is kept otherwise. Modules with duplicate
+// names, missing dependencies or cyclic constraints are left out, and the returned error
+// describes each of them.
+func Order[M Named](mods []M) ([]M, error) {
+	var errs []error
+	index := make(map[string]int, len(mods))
+	var kept []int
+	for i, mod := range mods {
+		name := strings.ToLower(mod.Name())
+		if _, dup := index[name]; dup {
+			errs = append(errs, fmt.Errorf("module %s: registered twice", name))
+			continue
+		}
+		index[name] = i
+		kept = append(kept, i)
+	}
+
+	// Drop modules with missing dependencies until the remaining set is closed.
+	present := make(map[string]bool, len(kept))
+	for _, i := range kept {
+		present[strings.ToLower(mods[i].Name())] = true
+	}
+	for changed := true; changed; {
+		changed = false
+		for _, i := range kept {
+			name := strings.ToLower(mods[i].Name())
+			if !present[name] {
+				continue
+			}
+			for _, dep := range dependencies(mods[i]) {
+				if !present[dep] {
+					errs = append(errs, fmt.Errorf("module %s: missing dependency %s", name, dep))
+					present[name] = false
+					changed = true
+					break
+				}
+			}
+		}
+	}
+
+	// edges[a] lists the modules that must come after a.
+	edges := make(map[string][]string)
+	indegree := make(map[string]int)
+	addEdge := func(from, to string) {
+		if present[from] && present[to] && from != to {
+			edges[from] = append(edges[from], to)
+			indegree[to]++
+		}
+	}
+	for _, i := range kept {
+		name := strings.ToLower(mods[i].Name())
+		for _, dep := range dependencies(mods[i]) {
+			addEdge(dep, name)
+		}
+		if ordered, ok := any(mods[i]).(Ordered); ok {
+			for _, after := range ordered.After() {
+				addEdge(strings.ToLower(after), name)
+			}
+			for _, before := range ordered.Before() {
+				addEdge(name, strings.ToLower(before))
+			}
+		}
+	}
+
+	// Repeatedly take the first module, in the given order, whose predecessors are placed.
+	out := make([]M, 0, len(kept))
+	placed := make(map[string]bool, len(kept))
+	for {
+		next := -1
+		for _, i := range kept {
+			name := strings.ToLower(mods[i].Name())
+			if present[name] && !placed[name] && indegree[name] == 0 {
+				next = i
+				break
+			}
+		}
+		if next < 0 {
+			break
+		}
+		name := strings.ToLower(mods[next].Name())
+		placed[name] = true
+		out = append(out, mods[next])
+		for _, after := range edges[name] {
+			indegree[after]--
+		}
+	}
+	var cycle []string
+	for _, i := range kept {
+		if name := strings.ToLower(mods[i].Name()); present[name] && !placed[name] {
+			cycle = append(cycle, name)
+		}
+	}
+	if len(cycle) > 0 {
+		errs = append(errs, fmt.Errorf("modules %s: cyclic ordering constraints", strings.Join(cycle, ", ")))
+	}
+	return out, errors.Join(errs...)
+}
+
+func dependencies(mod Named) []string {
+	dependent, ok := mod.(Dependent)
+	if !ok {
+		return nil
+	}
+	deps := dependent.Dependencies()
+	out := make([]string, 0, len(deps))
+	for _, dep := range deps {
+		out = append(out, strings.ToLower(dep))
+	}
+	return out
+}
@@ -0,0 +1,61 @@
+package modules
+
+import (
+	"strings"
+	"testing"
+)
+
+type testModule struct {
+	name          string
+	deps          []string
+	after, before []string
+}
+
+func (m testModule) Name() string           { return m.name }
+func (m testModule) Dependencies() []string { return m.deps }
+func (m testModule) After() []string        { return m.after }
+func (m testModule) Before() []string       { return m.before }
+
+func names(mods []testModule) string {
+	out := make([]string, 0, len(mods))
+	for _, mod := range mods {
+		out = append(out, mod.name)
+	}
+	return strings.Join(out, ",")
+}
+
+func TestOrderHonorsConstraints(t *testing.T) {
+	mods := []testModule{
+		{name: "translation", after: []string{"fallback"}},
+		{name: "fallback", deps: []string{"auth"}},
+		{name: "auth"},
+		{name: "metrics", before: []string{"auth"}, after: []string{"absent"}},
+	}
+	ordered, err := Order(mods)
+	if err != nil {
+		t.Fatalf("Order: %v", err)
+	}
+	if got := names(ordered); got != "metrics,auth,fallback,translation" {
+		t.Fatalf("order = %s", got)
+	}
+}
+
+func TestOrderRejectsInvalidDeclarations(t *testing.T) {
+	mods := []testModule{
+		{name: "a", after: []string{"b"}},
+		{name: "b", after: []string{"a"}},
+		{name: "c", deps: []string{"missing"}},
+		{name: "d", deps: []string{"c"}},
+		{name: "e"},
+		{name: "E"},
+	}
+	ordered, err := Order(mods)
+	if got := names(ordered); got != "e" {
+		t.Fatalf("order = %s, want only e", got)
+	}
+	for _, want := range []string{"a, b: cyclic", "c: missing dependency missing", "d: missing dependency c", "e: registered twice"} {
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("error %v does not mention %q", err, want)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/offline"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/vhost"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	log "github.com/sirupsen/logrus"
+)
+
+// passthroughDefaultBaseURLs are the public APIs of providers whose auths may carry no
+// base URL.
+var passthroughDefaultBaseURLs = map[string]string{
+	"claude": "https://api.anthropic.com",
+	"gemini": "https://generativelanguage.googleapis.com",
+	"codex":  "https://chatgpt.com/backend-api/codex",
+}
+
+// passthroughClientCredentials are the client's proxy credentials, never sent upstream.
+var passthroughClientCredentials = []string{"Authorization", "X-Api-Key", "X-Goog-Api-Key", "Proxy-Authorization"}
+
+// passthroughTransport executes upstream requests with the credentials of auth.
+type passthroughTransport struct {
+	manager *coreauth.Manager
+	auth    *coreauth.Auth
+}
+
+func (t passthroughTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := offline.Default().CheckHost(req.URL.Host); err != nil {
+		return nil, err
+	}
+	return t.manager.HttpRequest(req.Context(), t.auth, req)
+}
+
+// passthroughHandler serves /passthrough/:name/*path for the routes of cfg.Passthrough.
+// The request is forwarded to the route's upstream unchanged apart from the credentials,
+// which are replaced by those of one of the provider's auths; the response is streamed
+// back as is.
+func (s *Server) passthroughHandler(c *gin.Context) {
+	route, ok := s.cfg.PassthroughRoute(c.Param("name"))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": gin.H{"message": "unknown passthrough route " + c.Param("name"), "type": "invalid_request_error"}})
+		return
+	}
+	var manager *coreauth.Manager
+	if s.handlers != nil {
+		manager = s.handlers.AuthManager
+	}
+	auth := s.pickPassthroughAuth(c.Request.Context(), manager, route.Provider)
+	if auth == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": gin.H{"message": "no available credential for provider " + route.Provider, "type": "server_error"}})
+		return
+	}
+	target, err := url.Parse(passthroughBaseURL(route, auth))
+	if err != nil || target.Host == "" {
+		c.JSON(http.StatusBadGateway, gin.H{"error": gin.H{"message": "passthrough route " + route.Name + " has no valid base-url", "type": "server_error"}})
+		return
+	}
+
+	path := c.Param("path")
+	proxy := &httputil.ReverseProxy{
+		Rewrite: func(pr *httputil.ProxyRequest) {
+			pr.SetURL(target)
+			pr.Out.URL.Path = strings.TrimRight(target.Path, "/") + path
+			pr.Out.URL.RawPath = ""
+			query := pr.Out.URL.Query()
+			query.Del("key")
+			pr.Out.URL.RawQuery = query.Encode()
+			for _, header := range passthroughClientCredentials {
+				pr.Out.Header.Del(header)
+			}
+		},
+		Transport:     passthroughTransport{manager: manager, auth: auth},
+		FlushInterval: -1,
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, errProxy error) {
+			log.Warnf("passthrough %s: %s %s failed: %v", route.Name, r.Method, path, errProxy)
+			c.JSON(http.StatusBadGateway, gin.H{"error": gin.H{"message": "passthrough upstream request failed", "type": "server_error"}})
+		},
+	}
+	proxy.ServeHTTP(c.Writer, c.Request)
+}
+
+// pickPassthroughAuth returns the next usable auth of provider in round-robin order,
+// skipping credentials the request's virtual host may not use.
+func (s *Server) pickPassthroughAuth(ctx context.Context, manager *coreauth.Manager, provider string) *coreauth.Auth {
+	if manager == nil {
+		return nil
+	}
+	now := time.Now()
+	var candidates []*coreauth.Auth
+	for _, auth := range manager.List() {
+		if auth == nil || !strings.EqualFold(auth.Provider, provider) || auth.Disabled || auth.Status == coreauth.StatusDisabled {
+			continue
+		}
+		if auth.Unavailable && now.Before(auth.NextRetryAfter) {
+			continue
+		}
+		if vhost.Default().FilterAuth(ctx, auth, "") != nil {
+			continue
+		}
+		candidates = append(candidates, auth)
+	}
+	if len(candidates) == 0 {
+		return nil
+	}
+	sort.Slice(candidates, func(i, j int) bool { return candidates[i].ID < candidates[j].ID })
+	return candidates[(s.passthroughNext.Add(1)-1)%uint64(len(candidates))]
+}
+
+// passthroughBaseURL resolves the upstream of route for auth.
+func passthroughBaseURL(route config.PassthroughRoute, auth *coreauth.Auth) string {
+	if route.BaseURL != "" {
+		return route.BaseURL
+	}
+	if auth.Attributes != nil {
+		if base := strings.TrimSpace(auth.Attributes["base_url"]); base != "" {
+			return base
+		}
+	}
+	return passthroughDefaultBaseURLs[route.Provider]
+}
@@ -0,0 +1,99 @@
+package api
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+)
+
+// passthroughTestExecutor injects a fixed upstream key into raw requests.
+type passthroughTestExecutor struct{}
+
+func (passthroughTestExecutor) Identifier() string { return "fake" }
+
+func (passthroughTestExecutor) Execute(context.Context, *coreauth.Auth, cliproxyexecutor.Request, cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+	return cliproxyexecutor.Response{}, nil
+}
+
+func (passthroughTestExecutor) ExecuteStream(context.Context, *coreauth.Auth, cliproxyexecutor.Request, cliproxyexecutor.Options) (<-chan cliproxyexecutor.StreamChunk, error) {
+	return nil, nil
+}
+
+func (passthroughTestExecutor) Refresh(_ context.Context, auth *coreauth.Auth) (*coreauth.Auth, error) {
+	return auth, nil
+}
+
+func (passthroughTestExecutor) CountTokens(context.Context, *coreauth.Auth, cliproxyexecutor.Request, cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+	return cliproxyexecutor.Response{}, nil
+}
+
+func (passthroughTestExecutor) HttpRequest(ctx context.Context, auth *coreauth.Auth, req *http.Request) (*http.Response, error) {
+	req = req.WithContext(ctx)
+	req.Header.Set("X-Upstream-Key", auth.ID)
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestPassthroughHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("X-Seen-Path", r.URL.RequestURI())
+		w.Header().Set("X-Seen-Key", r.Header.Get("X-Upstream-Key"))
+		w.Header().Set("X-Seen-Client-Auth", r.Header.Get("Authorization")+r.Header.Get("X-Api-Key"))
+		w.WriteHeader(http.StatusTeapot)
+		_, _ = w.Write(body)
+	}))
+	defer upstream.Close()
+
+	manager := coreauth.NewManager(nil, nil, nil)
+	manager.RegisterExecutor(passthroughTestExecutor{})
+	if _, err := manager.Register(context.Background(), &coreauth.Auth{ID: "fake-1", Provider: "fake", Attributes: map[string]string{"base_url": upstream.URL + "/api"}}); err != nil {
+		t.Fatal(err)
+	}
+	cfg := &config.Config{Passthrough: []config.PassthroughRoute{{Name: "fake", Provider: "fake"}, {Name: "empty", Provider: "none"}}}
+	s := &Server{engine: gin.New(), cfg: cfg, handlers: handlers.NewBaseAPIHandlers(&cfg.SDKConfig, manager)}
+	s.engine.Any("/passthrough/:name/*path", s.passthroughHandler)
+
+	proxy := httptest.NewServer(s.engine)
+	defer proxy.Close()
+
+	serve := func(path string) (*http.Response, string) {
+		req, _ := http.NewRequest(http.MethodPost, proxy.URL+path, strings.NewReader(`{"new_feature":true}`))
+		req.Header.Set("Authorization", "Bearer client-key")
+		req.Header.Set("X-Api-Key", "client-key")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = resp.Body.Close() }()
+		body, _ := io.ReadAll(resp.Body)
+		return resp, string(body)
+	}
+
+	resp, body := serve("/passthrough/fake/v1/messages?beta=true&key=client-key")
+	if resp.StatusCode != http.StatusTeapot || body != `{"new_feature":true}` {
+		t.Fatalf("passthrough = %d %s", resp.StatusCode, body)
+	}
+	if got := resp.Header.Get("X-Seen-Path"); got != "/api/v1/messages?beta=true" {
+		t.Fatalf("upstream path = %q", got)
+	}
+	if resp.Header.Get("X-Seen-Key") != "fake-1" || resp.Header.Get("X-Seen-Client-Auth") != "" {
+		t.Fatalf("credentials = %q, client %q", resp.Header.Get("X-Seen-Key"), resp.Header.Get("X-Seen-Client-Auth"))
+	}
+
+	if resp, _ = serve("/passthrough/unknown/v1/messages"); resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("unknown route = %d", resp.StatusCode)
+	}
+	if resp, _ = serve("/passthrough/empty/v1/messages"); resp.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("route without credentials = %d", resp.StatusCode)
+	}
+}
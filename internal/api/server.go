@@ -9,10 +9,14 @@ import (
 	"crypto/subtle"
 	"errors"
 	"fmt"
+	"math"
+	"net"
 	"net/http"
 	"os"
 	"path/filepath"
 	"reflect"
+	"slices"
+	"strconv"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -20,23 +24,58 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/access"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/agentbudget"
 	managementHandlers "github.com/router-for-me/CLIProxyAPI/v6/internal/api/handlers/management"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/api/middleware"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/api/modules"
 	ampmodule "github.com/router-for-me/CLIProxyAPI/v6/internal/api/modules/amp"
 	augplusmodule "github.com/router-for-me/CLIProxyAPI/v6/internal/api/modules/augplus"
+	claudecodemodule "github.com/router-for-me/CLIProxyAPI/v6/internal/api/modules/claudecode"
+	jetbrainsmodule "github.com/router-for-me/CLIProxyAPI/v6/internal/api/modules/jetbrains"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/asyncjob"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/authguard"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/backup"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/connections"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/dnscache"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/faultinject"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/featureflag"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/ipaccess"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/maintenance"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/managementasset"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/metrics"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/mirror"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/modelcache"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/moderation"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/offline"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/pricing"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/probes"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/project"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/resume"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/routing"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/scheduledprompt"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/sharedstate"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/slo"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/spendlimit"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/spill"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/storage"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/systemd"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/tempkey"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/transcript"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/usage"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/usagereport"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/vhost"
 	sdkaccess "github.com/router-for-me/CLIProxyAPI/v6/sdk/access"
 	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers"
 	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers/claude"
 	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers/gemini"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers/mcp"
 	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers/openai"
 	sdkAuth "github.com/router-for-me/CLIProxyAPI/v6/sdk/auth"
 	"github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	sdktranslator "github.com/router-for-me/CLIProxyAPI/v6/sdk/translator"
 	log "github.com/sirupsen/logrus"
 	"gopkg.in/yaml.v3"
 )
@@ -52,6 +91,8 @@ type serverOptionConfig struct {
 	keepAliveEnabled     bool
 	keepAliveTimeout     time.Duration
 	keepAliveOnTimeout   func()
+	listener             net.Listener
+	routeModules         []modules.RouteModuleV2
 }
 
 // ServerOption customises HTTP server construction.
@@ -105,6 +146,22 @@ func WithKeepAliveEndpoint(timeout time.Duration, onTimeout func()) ServerOption
 	}
 }
 
+// WithListener serves on ln instead of binding the configured host and port.
+func WithListener(ln net.Listener) ServerOption {
+	return func(cfg *serverOptionConfig) {
+		cfg.listener = ln
+	}
+}
+
+// WithRouteModules registers additional routing modules after the built-in ones. They take
+// part in the dependency ordering and can be disabled through the modules config like the
+// built-in modules.
+func WithRouteModules(mods ...modules.RouteModuleV2) ServerOption {
+	return func(cfg *serverOptionConfig) {
+		cfg.routeModules = append(cfg.routeModules, mods...)
+	}
+}
+
 // WithRequestLoggerFactory customises request logger creation.
 func WithRequestLoggerFactory(factory func(*config.Config, string) logging.RequestLogger) ServerOption {
 	return func(cfg *serverOptionConfig) {
@@ -153,9 +210,34 @@ type Server struct {
 	// management handler
 	mgmt *managementHandlers.Handler
 
+	// cors holds the hot-reloadable CORS policies.
+	cors *middleware.CORS
+
 	// ampModule is the Amp routing module for model mapping hot-reload
 	ampModule *ampmodule.AmpModule
 
+	// augplusModule is the AugPlus compatible routing module.
+	augplusModule *augplusmodule.Module
+
+	// claudeCodeModule is the Claude Code CLI compatible routing module.
+	claudeCodeModule *claudecodemodule.Module
+
+	// jetbrainsModule is the JetBrains AI Assistant compatible routing module.
+	jetbrainsModule *jetbrainsmodule.Module
+
+	// extraModules are the routing modules added through WithRouteModules.
+	extraModules []modules.RouteModuleV2
+
+	// routeModules lists the names of the registered routing modules.
+	routeModules []string
+
+	// moduleErr reports invalid module declarations and failed registrations; Start
+	// refuses to serve with it.
+	moduleErr error
+
+	// moduleGate answers 404 for the routes of the routing modules disabled in the config.
+	moduleGate *modules.Gate
+
 	// managementRoutesRegistered tracks whether the management routes have been attached to the engine.
 	managementRoutesRegistered atomic.Bool
 	// managementRoutesEnabled controls whether management endpoints serve real handlers.
@@ -171,6 +253,27 @@ type Server struct {
 	keepAliveOnTimeout func()
 	keepAliveHeartbeat chan struct{}
 	keepAliveStop      chan struct{}
+
+	// listener, when set, replaces binding cfg.Host/cfg.Port.
+	listener net.Listener
+
+	// started latches once the readiness checks first pass; it backs the startup probe.
+	started atomic.Bool
+
+	// storeCheck caches the readiness check of token stores that cannot be pinged.
+	storeCheck storeCheckCache
+
+	// maxRequestBodyBytes is the body limit of requestBodyLimitMiddleware; <= 0 is unlimited.
+	maxRequestBodyBytes *atomic.Int64
+
+	// grpcIngress serves the chat and completion APIs over gRPC when enabled.
+	grpcIngress *grpcIngress
+
+	// asyncJobs runs and persists the jobs of the long-running job API.
+	asyncJobs *asyncjob.Store
+
+	// passthroughNext rotates the auths of raw passthrough routes.
+	passthroughNext atomic.Uint64
 }
 
 // NewServer creates and initializes a new API server instance.
@@ -204,9 +307,25 @@ func NewServer(cfg *config.Config, authManager *auth.Manager, accessManager *sdk
 	// Add middleware
 	engine.Use(logging.GinLogrusLogger())
 	engine.Use(logging.GinLogrusRecovery())
+	// The body limit runs before every middleware that reads the body.
+	maxRequestBodyBytes := new(atomic.Int64)
+	maxRequestBodyBytes.Store(cfg.MaxRequestBodyBytes)
+	engine.Use(requestBodyLimitMiddleware(maxRequestBodyBytes))
 	for _, mw := range optionState.extraMiddleware {
 		engine.Use(mw)
 	}
+	dnscache.Default().Configure(cfg.DNSCache)
+	offline.Default().Configure(cfg.Offline)
+	featureflag.Default().Configure(cfg.FeatureFlags)
+	ipaccess.Default().Configure(cfg.IPAccess)
+	authguard.Default().Configure(cfg.AuthGuard)
+	engine.Use(middleware.IPAccessMiddleware(ipaccess.Default()))
+	if len(cfg.IPAccess.TrustedProxies) > 0 {
+		// Resolve c.ClientIP() (management localhost checks, bans) through the same proxies.
+		if err := engine.SetTrustedProxies(cfg.IPAccess.TrustedProxies); err != nil {
+			log.Warnf("ip-access: invalid trusted-proxies: %v", err)
+		}
+	}
 
 	// Add request logging middleware (positioned after recovery, before auth)
 	// Resolve logs directory relative to the configuration file directory.
@@ -224,7 +343,20 @@ func NewServer(cfg *config.Config, authManager *auth.Manager, accessManager *sdk
 		}
 	}
 
-	engine.Use(corsMiddleware())
+	storage.Configure(cfg.Storage)
+	transcript.GetStore().Configure(cfg.Transcripts, transcriptFallbackDir(cfg))
+	configureUsagePersistence(cfg)
+	engine.Use(middleware.TranscriptMiddleware(transcript.GetStore()))
+	mirror.Default().Configure(cfg.Mirror)
+	engine.Use(middleware.MirrorMiddleware(mirror.Default()))
+	engine.Use(middleware.ConnectionsMiddleware(connections.Default()))
+	faultinject.Default().Configure(cfg.FaultInjection)
+	engine.Use(middleware.FaultInjectionMiddleware(faultinject.Default()))
+	engine.Use(middleware.CancellationMiddleware(usage.GetRequestStatistics()))
+	engine.Use(middleware.CostHeadersMiddleware(pricing.Default()))
+
+	corsPolicy := middleware.NewCORS(cfg.CORS)
+	engine.Use(corsPolicy.Handler())
 	wd, err := os.Getwd()
 	if err != nil {
 		wd = configFilePath
@@ -244,9 +376,14 @@ func NewServer(cfg *config.Config, authManager *auth.Manager, accessManager *sdk
 		loggerToggle:        toggle,
 		configFilePath:      configFilePath,
 		currentPath:         wd,
+		cors:                corsPolicy,
 		envManagementSecret: envManagementSecret,
 		wsRoutes:            make(map[string]struct{}),
+		listener:            optionState.listener,
+		grpcIngress:         newGRPCIngress(vhost.Handler(vhost.Default(), engine)),
+		maxRequestBodyBytes: maxRequestBodyBytes,
 	}
+	s.asyncJobs = asyncjob.NewStore(s.executeAsyncJob)
 	s.wsAuthEnabled.Store(cfg.WebsocketAuth)
 	// Save initial YAML snapshot
 	s.oldConfigYaml, _ = yaml.Marshal(cfg)
@@ -254,6 +391,43 @@ func NewServer(cfg *config.Config, authManager *auth.Manager, accessManager *sdk
 	if authManager != nil {
 		authManager.SetRetryConfig(cfg.RequestRetry, time.Duration(cfg.MaxRetryInterval)*time.Second)
 	}
+	pricing.Default().Configure(cfg.Pricing)
+	sdktranslator.SetStrictStreams(cfg.StreamParsing == config.StreamParsingStrict)
+	usagereport.Default().Configure(cfg.UsageReports, usageReportFallbackDir(cfg))
+	modelcache.Default().Configure(cfg.ModelsCache)
+	backup.Default().Configure(cfg, configFilePath)
+	metrics.Default().Configure(cfg.Metrics)
+	spendlimit.Default().Configure(cfg.SpendLimits)
+	spendlimit.Default().SetResetSchedules(cfg.QuotaExceeded)
+	spendlimit.Default().Persist(spendLimitStatePath(cfg))
+	slo.Default().Configure(cfg.SLO)
+	agentbudget.Default().Configure(cfg.AgentBudget)
+	agentbudget.DefaultBreaker().Configure(cfg.LoopBreaker)
+	resume.Default().Configure(cfg.StreamResumption)
+	spill.Configure(cfg.ResponseSpill)
+	if authManager != nil {
+		probes.Default().SetExecutor(authManager)
+	}
+	probes.Default().Configure(cfg.SyntheticProbes)
+	scheduledprompt.Default().SetExecutor(s.replayRequest)
+	scheduledprompt.Default().Configure(cfg.ScheduledPrompts, scheduledPromptFallbackDir(cfg))
+	maintenance.Default().Configure(cfg.Maintenance)
+	project.GetRegistry().Configure(cfg.Projects)
+	tempkey.Default().Configure(cfg.TemporaryKeys)
+	vhost.Default().Configure(cfg.VirtualHosts)
+	routing.Default().Configure(cfg.Routing.Rules)
+	routing.Default().SetTraceEnabled(cfg.Routing.Trace)
+	moderation.Default().Configure(cfg.Moderation, cfg.ProxyURL)
+	if authManager != nil {
+		authManager.RegisterAuthFilter("spend-limit", spendlimit.Default())
+		authManager.RegisterAuthFilter("project-pinning", project.GetRegistry())
+		authManager.RegisterAuthFilter("virtual-hosts", vhost.Default())
+		authManager.RegisterAuthFilter("routing-rules", routing.Default())
+		authManager.RegisterAuthFilter("maintenance", maintenance.Default())
+		authManager.RegisterAuthFilter("offline", offline.Default())
+		authManager.SetExecutorWrapper(faultinject.Default().Wrap)
+	}
+	applySharedState(cfg, authManager)
 	managementasset.SetCurrentConfig(cfg)
 	auth.SetQuotaCooldownDisabled(cfg.DisableCooling)
 	// Initialize management handler
@@ -263,28 +437,46 @@ func NewServer(cfg *config.Config, authManager *auth.Manager, accessManager *sdk
 	}
 	logDir := logging.ResolveLogDirectory(cfg)
 	s.mgmt.SetLogDirectory(logDir)
+	s.mgmt.SetReplayExecutor(s.replayRequest)
+	s.mgmt.SetModules(func() []string { return s.versionInfo().Modules })
 	s.localPassword = optionState.localPassword
 
+	// Browsers get an HTML status page instead of raw API errors.
+	engine.Use(middleware.HTMLErrorPage(s.renderStatusPage))
+
+	// Routing modules can be disabled through the config without a rebuild.
+	s.moduleGate = modules.NewGate()
+	engine.Use(s.moduleGate.Middleware())
+
 	// Setup routes
 	s.setupRoutes()
 
-	// Register Amp module using V2 interface with Context
+	// Register the routing modules in the order their dependencies and ordering
+	// constraints require: the Amp module, the AugPlus compatible module for VS Code
+	// extension support, the Claude Code and JetBrains AI Assistant compatible modules, and
+	// the modules added through WithRouteModules.
 	s.ampModule = ampmodule.NewLegacy(accessManager, AuthMiddleware(accessManager))
+	s.augplusModule = augplusmodule.New()
+	s.claudeCodeModule = claudecodemodule.New()
+	s.jetbrainsModule = jetbrainsmodule.New()
+	s.extraModules = optionState.routeModules
 	ctx := modules.Context{
 		Engine:         engine,
 		BaseHandler:    s.handlers,
 		Config:         cfg,
 		AuthMiddleware: AuthMiddleware(accessManager),
 	}
-	if err := modules.RegisterModule(ctx, s.ampModule); err != nil {
-		log.Errorf("Failed to register Amp module: %v", err)
-	}
-
-	// Register AugPlus compatible module for VS Code extension support
-	augplusModule := augplusmodule.New()
-	if err := modules.RegisterModule(ctx, augplusModule); err != nil {
-		log.Errorf("Failed to register AugPlus module: %v", err)
+	ordered, errOrder := modules.Order(s.routeModuleList())
+	moduleErrs := []error{errOrder}
+	for _, mod := range ordered {
+		if err := s.moduleGate.Track(engine, mod.Name(), func() error { return modules.RegisterModule(ctx, mod) }); err != nil {
+			moduleErrs = append(moduleErrs, fmt.Errorf("register %s module: %w", mod.Name(), err))
+			continue
+		}
+		s.routeModules = append(s.routeModules, mod.Name())
 	}
+	s.moduleErr = errors.Join(moduleErrs...)
+	s.applyModuleToggles(cfg)
 
 	// Apply additional router configurators from options
 	if optionState.routerConfigurator != nil {
@@ -292,7 +484,7 @@ func NewServer(cfg *config.Config, authManager *auth.Manager, accessManager *sdk
 	}
 
 	// Register management routes when configuration or environment secrets are available.
-	hasManagementSecret := cfg.RemoteManagement.SecretKey != "" || envManagementSecret
+	hasManagementSecret := managementConfigured(cfg) || envManagementSecret
 	s.managementRoutesEnabled.Store(hasManagementSecret)
 	if hasManagementSecret {
 		s.registerManagementRoutes()
@@ -304,8 +496,8 @@ func NewServer(cfg *config.Config, authManager *auth.Manager, accessManager *sdk
 
 	// Create HTTP server
 	s.server = &http.Server{
-		Addr:    fmt.Sprintf("%s:%d", cfg.Host, cfg.Port),
-		Handler: engine,
+		Addr:    cfg.ListenAddress(),
+		Handler: vhost.Handler(vhost.Default(), engine),
 	}
 
 	return s
@@ -315,11 +507,16 @@ func NewServer(cfg *config.Config, authManager *auth.Manager, accessManager *sdk
 // It defines the endpoints and associates them with their respective handlers.
 func (s *Server) setupRoutes() {
 	s.engine.GET("/management.html", s.serveManagementControlPanel)
+	s.registerHealthRoutes()
+	s.engine.GET("/version", s.handleVersion)
+	s.engine.GET("/v0/capabilities", AuthMiddleware(s.accessManager), s.handleCapabilities)
+	s.engine.GET("/metrics", s.handleMetrics)
 	openaiHandlers := openai.NewOpenAIAPIHandler(s.handlers)
 	geminiHandlers := gemini.NewGeminiAPIHandler(s.handlers)
 	geminiCLIHandlers := gemini.NewGeminiCLIAPIHandler(s.handlers)
 	claudeCodeHandlers := claude.NewClaudeCodeAPIHandler(s.handlers)
 	openaiResponsesHandlers := openai.NewOpenAIResponsesAPIHandler(s.handlers)
+	mcpHandlers := mcp.NewMCPAPIHandler(s.handlers)
 
 	// OpenAI compatible API routes
 	v1 := s.engine.Group("/v1")
@@ -328,21 +525,57 @@ func (s *Server) setupRoutes() {
 		v1.GET("/models", s.unifiedModelsHandler(openaiHandlers, claudeCodeHandlers))
 		v1.POST("/chat/completions", openaiHandlers.ChatCompletions)
 		v1.POST("/completions", openaiHandlers.Completions)
+		v1.POST("/fanout", openaiHandlers.FanOut)
+		v1.GET("/prompt-templates", openaiHandlers.PromptTemplates)
+		v1.POST("/prompt-templates/completions", openaiHandlers.PromptTemplateCompletions)
+		v1.POST("/moderations", s.moderationsHandler)
+		v1.GET("/ws-bridge/:dialect", s.wsBridgeHandler)
 		v1.POST("/messages", claudeCodeHandlers.ClaudeMessages)
 		v1.POST("/messages/count_tokens", claudeCodeHandlers.ClaudeCountTokens)
+		v1.POST("/messages/batches", s.createMessageBatch)
+		v1.GET("/messages/batches", s.listMessageBatches)
+		v1.GET("/messages/batches/:id", s.getMessageBatch)
+		v1.POST("/messages/batches/:id/cancel", s.cancelMessageBatch)
+		v1.GET("/messages/batches/:id/results", s.messageBatchResults)
+		v1.DELETE("/messages/batches/:id", s.deleteMessageBatch)
+		v1.POST("/jobs", s.submitAsyncJob)
+		v1.GET("/jobs", s.listAsyncJobs)
+		v1.GET("/jobs/:id", s.getAsyncJob)
+		v1.GET("/jobs/:id/result", s.asyncJobResult)
+		v1.POST("/jobs/:id/cancel", s.cancelAsyncJob)
+		v1.DELETE("/jobs/:id", s.deleteAsyncJob)
+		v1.POST("/temporary-keys", s.createTemporaryKey)
+		v1.GET("/temporary-keys", s.listTemporaryKeys)
+		v1.DELETE("/temporary-keys/:id", s.deleteTemporaryKey)
 		v1.POST("/responses", openaiResponsesHandlers.Responses)
 		v1.POST("/responses/compact", openaiResponsesHandlers.Compact)
+		v1.GET("/usage", s.legacyUsageHandler)
+		v1.GET("/organization/usage/completions", s.organizationUsageHandler)
 	}
 
+	// Raw passthrough routes, configured under passthrough
+	s.engine.Any("/passthrough/:name/*path", AuthMiddleware(s.accessManager), s.passthroughHandler)
+
 	// Gemini compatible API routes
 	v1beta := s.engine.Group("/v1beta")
 	v1beta.Use(AuthMiddleware(s.accessManager))
 	{
-		v1beta.GET("/models", geminiHandlers.GeminiModels)
+		v1beta.GET("/models", cachedModels(modelcache.Gemini, geminiHandlers.GeminiModels))
+		v1beta.GET("/openai/models", cachedModels(modelcache.GeminiCompat, openaiHandlers.GeminiCompatModels))
+		v1beta.POST("/openai/chat/completions", openaiHandlers.GeminiCompatChatCompletions)
 		v1beta.POST("/models/*action", geminiHandlers.GeminiHandler)
 		v1beta.GET("/models/*action", geminiHandlers.GeminiGetHandler)
 	}
 
+	// Model Context Protocol server (Streamable HTTP transport)
+	mcpGroup := s.engine.Group("/mcp")
+	mcpGroup.Use(AuthMiddleware(s.accessManager))
+	{
+		mcpGroup.POST("", mcpHandlers.Handle)
+		mcpGroup.GET("", mcpHandlers.Handle)
+		mcpGroup.DELETE("", mcpHandlers.Handle)
+	}
+
 	// Root endpoint
 	s.engine.GET("/", func(c *gin.Context) {
 		c.JSON(http.StatusOK, gin.H{
@@ -479,15 +712,70 @@ func (s *Server) registerManagementRoutes() {
 
 	log.Info("management routes registered after secret key configuration")
 
+	// The OIDC login endpoints authenticate the user themselves.
+	oidcLogin := s.engine.Group("/v0/management/oidc", s.managementAvailabilityMiddleware())
+	oidcLogin.GET("/login", s.mgmt.OIDCLogin)
+	oidcLogin.GET("/callback", s.mgmt.OIDCCallback)
+	oidcLogin.POST("/logout", s.mgmt.OIDCLogout)
+
 	mgmt := s.engine.Group("/v0/management")
-	mgmt.Use(s.managementAvailabilityMiddleware(), s.mgmt.Middleware())
+	mgmt.Use(s.managementAvailabilityMiddleware(), s.mgmt.Middleware(), managementHandlers.ConditionalGET(), s.mgmt.AuditMiddleware(), s.mgmt.ConfirmationMiddleware())
 	{
+		mgmt.GET("/info", s.mgmt.GetInfo)
+		mgmt.GET("/feature-flags", s.mgmt.GetFeatureFlags)
+		mgmt.PUT("/feature-flags/:name", s.mgmt.PutFeatureFlag)
+		mgmt.PATCH("/feature-flags/:name", s.mgmt.PutFeatureFlag)
+		mgmt.DELETE("/feature-flags/:name", s.mgmt.DeleteFeatureFlag)
+		mgmt.GET("/audit-log", s.mgmt.GetAuditLog)
+		mgmt.GET("/connections", s.mgmt.ListConnections)
+		mgmt.DELETE("/connections/:id", s.mgmt.TerminateConnection)
+		mgmt.GET("/auth-bans", s.mgmt.GetAuthBans)
+		mgmt.DELETE("/auth-bans", s.mgmt.DeleteAuthBans)
 		mgmt.GET("/usage", s.mgmt.GetUsageStatistics)
+		mgmt.GET("/usage-reports", s.mgmt.ListUsageReports)
+		mgmt.GET("/usage-reports/:month", s.mgmt.GetUsageReport)
 		mgmt.GET("/usage/export", s.mgmt.ExportUsageStatistics)
+		mgmt.GET("/usage/records", s.mgmt.ExportUsageRecords)
 		mgmt.POST("/usage/import", s.mgmt.ImportUsageStatistics)
+		mgmt.GET("/state/export", s.mgmt.ExportState)
+		mgmt.POST("/state/import", s.mgmt.ImportState)
+		mgmt.GET("/transcripts", s.mgmt.ListTranscriptSessions)
+		mgmt.GET("/transcripts/search", s.mgmt.SearchTranscripts)
+		mgmt.POST("/transcripts/prune", s.mgmt.PruneTranscripts)
+		mgmt.GET("/transcripts/:id", s.mgmt.GetTranscriptSession)
+		mgmt.GET("/transcripts/:id/export", s.mgmt.ExportTranscriptSession)
+		mgmt.DELETE("/transcripts/:id", s.mgmt.DeleteTranscriptSession)
+		mgmt.GET("/maintenance", s.mgmt.GetMaintenance)
+		mgmt.PUT("/maintenance", s.mgmt.PutMaintenance)
+		mgmt.PUT("/maintenance/providers/:provider", s.mgmt.DrainProvider)
+		mgmt.DELETE("/maintenance/providers/:provider", s.mgmt.ResumeProvider)
+		mgmt.GET("/synthetic-probes", s.mgmt.GetSyntheticProbes)
+		mgmt.POST("/synthetic-probes/run", s.mgmt.RunSyntheticProbes)
+		mgmt.GET("/scheduled-prompts", s.mgmt.GetScheduledPrompts)
+		mgmt.POST("/scheduled-prompts/run", s.mgmt.RunScheduledPrompts)
+		mgmt.POST("/response-diffs", s.mgmt.StartResponseDiff)
+		mgmt.GET("/response-diffs", s.mgmt.ListResponseDiffs)
+		mgmt.GET("/response-diffs/:id", s.mgmt.GetResponseDiff)
+
+		mgmt.GET("/projects", s.mgmt.ListProjects)
+		mgmt.GET("/projects/:name/usage", s.mgmt.GetProjectUsage)
+		mgmt.GET("/temporary-keys", s.mgmt.ListTemporaryKeys)
+		mgmt.DELETE("/temporary-keys/:id", s.mgmt.DeleteTemporaryKey)
+
+		mgmt.GET("/spend-limits/status", s.mgmt.GetSpendLimitStatus)
+		mgmt.DELETE("/spend-limits/status", s.mgmt.ResetSpendLimitStatus)
+		mgmt.GET("/slo", s.mgmt.GetSLOStatus)
+		mgmt.GET("/agent-budget/sessions", s.mgmt.GetAgentBudgetSessions)
+		mgmt.DELETE("/agent-budget/sessions", s.mgmt.ResetAgentBudgetSessions)
+
+		mgmt.GET("/shared-state", s.mgmt.GetSharedState)
+
 		mgmt.GET("/config", s.mgmt.GetConfig)
 		mgmt.GET("/config.yaml", s.mgmt.GetConfigYAML)
 		mgmt.PUT("/config.yaml", s.mgmt.PutConfigYAML)
+		mgmt.POST("/config/preview", s.mgmt.PreviewConfig)
+		mgmt.POST("/config/preview/:id/apply", s.mgmt.ApplyConfigPreview)
+		mgmt.DELETE("/config/preview/:id", s.mgmt.AbortConfigPreview)
 		mgmt.GET("/latest-version", s.mgmt.GetLatestVersion)
 
 		mgmt.GET("/debug", s.mgmt.GetDebug)
@@ -570,6 +858,20 @@ func (s *Server) registerManagementRoutes() {
 		mgmt.PUT("/ampcode/upstream-api-keys", s.mgmt.PutAmpUpstreamAPIKeys)
 		mgmt.PATCH("/ampcode/upstream-api-keys", s.mgmt.PatchAmpUpstreamAPIKeys)
 		mgmt.DELETE("/ampcode/upstream-api-keys", s.mgmt.DeleteAmpUpstreamAPIKeys)
+		mgmt.GET("/ampcode/model-mapping-suggestions", s.mgmt.GetAmpModelMappingSuggestions)
+		mgmt.GET("/ampcode/fairness", s.mgmt.GetAmpFairness)
+		mgmt.DELETE("/ampcode/fairness", s.mgmt.ResetAmpFairness)
+
+		mgmt.GET("/prompt-templates", s.mgmt.GetPromptTemplates)
+		mgmt.PUT("/prompt-templates", s.mgmt.PutPromptTemplates)
+		mgmt.PATCH("/prompt-templates", s.mgmt.PatchPromptTemplates)
+		mgmt.DELETE("/prompt-templates", s.mgmt.DeletePromptTemplates)
+
+		mgmt.GET("/client-fingerprint", s.mgmt.GetClientFingerprint)
+		mgmt.PUT("/client-fingerprint", s.mgmt.PutClientFingerprint)
+		mgmt.GET("/client-fingerprint/enable", s.mgmt.GetClientFingerprintEnable)
+		mgmt.PUT("/client-fingerprint/enable", s.mgmt.PutClientFingerprintEnable)
+		mgmt.PATCH("/client-fingerprint/enable", s.mgmt.PutClientFingerprintEnable)
 
 		mgmt.GET("/request-retry", s.mgmt.GetRequestRetry)
 		mgmt.PUT("/request-retry", s.mgmt.PutRequestRetry)
@@ -621,7 +923,11 @@ func (s *Server) registerManagementRoutes() {
 		mgmt.GET("/model-definitions/:channel", s.mgmt.GetStaticModelDefinitions)
 		mgmt.GET("/auth-files/download", s.mgmt.DownloadAuthFile)
 		mgmt.POST("/auth-files", s.mgmt.UploadAuthFile)
+		mgmt.POST("/auth-files/import", s.mgmt.ImportAuthFiles)
 		mgmt.DELETE("/auth-files", s.mgmt.DeleteAuthFile)
+		mgmt.GET("/auth-files/trash", s.mgmt.ListTrashedAuthFiles)
+		mgmt.DELETE("/auth-files/trash", s.mgmt.PurgeTrashedAuthFiles)
+		mgmt.POST("/auth-files/restore", s.mgmt.RestoreAuthFile)
 		mgmt.PATCH("/auth-files/status", s.mgmt.PatchAuthFileStatus)
 		mgmt.POST("/vertex/import", s.mgmt.ImportVertexCredential)
 
@@ -637,6 +943,12 @@ func (s *Server) registerManagementRoutes() {
 	}
 }
 
+// managementConfigured reports whether cfg enables the management API: with a management
+// key or OIDC login.
+func managementConfigured(cfg *config.Config) bool {
+	return cfg.RemoteManagement.SecretKey != "" || cfg.RemoteManagement.OIDC.Enabled()
+}
+
 func (s *Server) managementAvailabilityMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		if !s.managementRoutesEnabled.Load() {
@@ -763,14 +1075,21 @@ func (s *Server) unifiedModelsHandler(openaiHandler *openai.OpenAIAPIHandler, cl
 		// Route to Claude handler if User-Agent starts with "claude-cli"
 		if strings.HasPrefix(userAgent, "claude-cli") {
 			// log.Debugf("Routing /v1/models to Claude handler for User-Agent: %s", userAgent)
-			claudeHandler.ClaudeModels(c)
+			modelcache.Default().Serve(c, modelcache.Claude, claudeHandler.ClaudeModels)
 		} else {
 			// log.Debugf("Routing /v1/models to OpenAI handler for User-Agent: %s", userAgent)
-			openaiHandler.OpenAIModels(c)
+			modelcache.Default().Serve(c, modelcache.OpenAI, openaiHandler.OpenAIModels)
 		}
 	}
 }
 
+// cachedModels serves a model listing handler through the pre-rendered listing cache.
+func cachedModels(variant string, render gin.HandlerFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		modelcache.Default().Serve(c, variant, render)
+	}
+}
+
 // Start begins listening for and serving HTTP or HTTPS requests.
 // It's a blocking call and will only return on an unrecoverable error.
 //
@@ -780,6 +1099,34 @@ func (s *Server) Start() error {
 	if s == nil || s.server == nil {
 		return fmt.Errorf("failed to start HTTP server: server not initialized")
 	}
+	if s.moduleErr != nil {
+		return fmt.Errorf("failed to start HTTP server: routing modules: %w", s.moduleErr)
+	}
+
+	// Under systemd socket activation the unit owns the listening socket, so restarts of the
+	// service never drop it; otherwise bind the configured address.
+	listener := s.listener
+	var listeners []net.Listener
+	if listener == nil {
+		var errListeners error
+		if listeners, errListeners = systemd.Listeners(); errListeners != nil {
+			return fmt.Errorf("failed to start HTTP server: %v", errListeners)
+		}
+	}
+	if len(listeners) > 0 {
+		listener = listeners[0]
+		for _, extra := range listeners[1:] {
+			log.Warnf("ignoring additional socket-activated listener on %s", extra.Addr())
+			_ = extra.Close()
+		}
+		log.Infof("using systemd socket-activated listener on %s", listener.Addr())
+	}
+
+	s.grpcIngress.apply(s.cfg)
+	// Jobs persisted by an earlier run resume once the server is about to serve.
+	if s.cfg != nil {
+		s.asyncJobs.Configure(s.cfg.AsyncJobs, asyncJobFallbackDir(s.cfg))
+	}
 
 	useTLS := s.cfg != nil && s.cfg.TLS.Enable
 	if useTLS {
@@ -789,14 +1136,26 @@ func (s *Server) Start() error {
 			return fmt.Errorf("failed to start HTTPS server: tls.cert or tls.key is empty")
 		}
 		log.Debugf("Starting API server on %s with TLS", s.server.Addr)
-		if errServeTLS := s.server.ListenAndServeTLS(cert, key); errServeTLS != nil && !errors.Is(errServeTLS, http.ErrServerClosed) {
+		var errServeTLS error
+		if listener != nil {
+			errServeTLS = s.server.ServeTLS(listener, cert, key)
+		} else {
+			errServeTLS = s.server.ListenAndServeTLS(cert, key)
+		}
+		if errServeTLS != nil && !errors.Is(errServeTLS, http.ErrServerClosed) {
 			return fmt.Errorf("failed to start HTTPS server: %v", errServeTLS)
 		}
 		return nil
 	}
 
 	log.Debugf("Starting API server on %s", s.server.Addr)
-	if errServe := s.server.ListenAndServe(); errServe != nil && !errors.Is(errServe, http.ErrServerClosed) {
+	var errServe error
+	if listener != nil {
+		errServe = s.server.Serve(listener)
+	} else {
+		errServe = s.server.ListenAndServe()
+	}
+	if errServe != nil && !errors.Is(errServe, http.ErrServerClosed) {
 		return fmt.Errorf("failed to start HTTP server: %v", errServe)
 	}
 
@@ -826,27 +1185,101 @@ func (s *Server) Stop(ctx context.Context) error {
 		return fmt.Errorf("failed to shutdown HTTP server: %v", err)
 	}
 
+	if coordinator := sharedstate.Active(); coordinator != nil {
+		coordinator.Stop()
+	}
+	probes.Default().Stop()
+	scheduledprompt.Default().Stop()
+	mirror.Default().Stop()
+	usagereport.Default().Stop()
+	spendlimit.Default().Stop()
+	modelcache.Default().Stop()
+	s.asyncJobs.Stop()
+	backup.Default().Stop()
+	metrics.Default().Stop()
+	s.grpcIngress.stop()
+
 	log.Debug("API server stopped")
 	return nil
 }
 
-// corsMiddleware returns a Gin middleware handler that adds CORS headers
-// to every response, allowing cross-origin requests.
-//
-// Returns:
-//   - gin.HandlerFunc: The CORS middleware handler
-func corsMiddleware() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		c.Header("Access-Control-Allow-Origin", "*")
-		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
-		c.Header("Access-Control-Allow-Headers", "*")
+// applySharedState (re)connects the high-availability backend and routes quota and spend
+// counters through it. Connection failures leave the instance running with local state.
+func applySharedState(cfg *config.Config, authManager *auth.Manager) {
+	coordinator, err := sharedstate.Apply(cfg.SharedState, authManager)
+	if err != nil {
+		log.Errorf("shared state disabled: %v", err)
+	}
+	if coordinator == nil {
+		project.GetRegistry().SetCounter(nil)
+		spendlimit.Default().SetCounter(nil)
+		return
+	}
+	project.GetRegistry().SetCounter(coordinator)
+	spendlimit.Default().SetCounter(coordinator)
+}
 
-		if c.Request.Method == "OPTIONS" {
-			c.AbortWithStatus(http.StatusNoContent)
-			return
+// replayRequest executes a stored request for response-diff jobs through the regular
+// handler pipeline, so replays share credentials, aliases and routing with live traffic.
+func (s *Server) replayRequest(ctx context.Context, handlerType, model string, body []byte) ([]byte, error) {
+	resp, errMsg := s.handlers.ExecuteWithAuthManager(ctx, handlerType, model, body, "")
+	if errMsg != nil {
+		if errMsg.Error != nil {
+			return nil, fmt.Errorf("status %d: %w", errMsg.StatusCode, errMsg.Error)
 		}
+		return nil, fmt.Errorf("status %d", errMsg.StatusCode)
+	}
+	return resp, nil
+}
 
-		c.Next()
+// transcriptFallbackDir places transcripts next to the resolved logs directory.
+func transcriptFallbackDir(cfg *config.Config) string {
+	return filepath.Join(filepath.Dir(logging.ResolveLogDirectory(cfg)), "transcripts")
+}
+
+// scheduledPromptFallbackDir places the answer files of scheduled prompts next to the
+// resolved logs directory.
+func scheduledPromptFallbackDir(cfg *config.Config) string {
+	return filepath.Join(filepath.Dir(logging.ResolveLogDirectory(cfg)), "scheduled-prompts")
+}
+
+// asyncJobFallbackDir places persisted jobs next to the resolved logs directory.
+func asyncJobFallbackDir(cfg *config.Config) string {
+	return filepath.Join(filepath.Dir(logging.ResolveLogDirectory(cfg)), "async-jobs")
+}
+
+// usageReportFallbackDir places usage reports next to the resolved logs directory.
+func usageReportFallbackDir(cfg *config.Config) string {
+	return filepath.Join(filepath.Dir(logging.ResolveLogDirectory(cfg)), "usage-reports")
+}
+
+// spendLimitStatePath keeps the running spend of limited credentials next to the resolved
+// logs directory.
+func spendLimitStatePath(cfg *config.Config) string {
+	return filepath.Join(filepath.Dir(logging.ResolveLogDirectory(cfg)), "spend-limits.json")
+}
+
+// configureUsagePersistence mirrors the usage statistics to the storage backend when
+// storage.persist-usage is set, restoring the history it holds.
+func configureUsagePersistence(cfg *config.Config) {
+	var backend storage.Backend
+	if cfg.Storage.PersistUsage {
+		dir := cfg.Storage.UsageDir
+		if dir == "" {
+			dir = filepath.Join(filepath.Dir(logging.ResolveLogDirectory(cfg)), "usage")
+		}
+		var err error
+		if backend, err = storage.Open(storage.Usage, dir); err != nil {
+			log.Errorf("usage persistence disabled: %v", err)
+		}
+	}
+	loaded, err := usage.GetRequestStatistics().Persist(backend, cfg.Storage.UsageRetentionDays)
+	if err != nil {
+		log.Errorf("failed to restore usage history: %v", err)
+		return
+	}
+	if loaded > 0 {
+		log.Infof("restored %d usage records from the %s storage backend", loaded, storage.BackendName())
 	}
 }
 
@@ -885,7 +1318,8 @@ func (s *Server) UpdateClients(cfg *config.Config) {
 		}
 	}
 
-	if oldCfg == nil || oldCfg.LoggingToFile != cfg.LoggingToFile || oldCfg.LogsMaxTotalSizeMB != cfg.LogsMaxTotalSizeMB {
+	if oldCfg == nil || oldCfg.LoggingToFile != cfg.LoggingToFile || oldCfg.LogsMaxTotalSizeMB != cfg.LogsMaxTotalSizeMB ||
+		oldCfg.LogLanguage != cfg.LogLanguage || oldCfg.Debug != cfg.Debug || !reflect.DeepEqual(oldCfg.Logging, cfg.Logging) {
 		if err := logging.ConfigureLogOutput(cfg); err != nil {
 			log.Errorf("failed to reconfigure log output: %v", err)
 		}
@@ -895,12 +1329,147 @@ func (s *Server) UpdateClients(cfg *config.Config) {
 		usage.SetStatisticsEnabled(cfg.UsageStatisticsEnabled)
 	}
 
+	if oldCfg == nil || oldCfg.StreamParsing != cfg.StreamParsing {
+		sdktranslator.SetStrictStreams(cfg.StreamParsing == config.StreamParsingStrict)
+	}
+
+	storageChanged := oldCfg == nil || !reflect.DeepEqual(oldCfg.Storage, cfg.Storage)
+	if storageChanged {
+		storage.Configure(cfg.Storage)
+		configureUsagePersistence(cfg)
+	}
+	if storageChanged || !reflect.DeepEqual(oldCfg.Transcripts, cfg.Transcripts) {
+		transcript.GetStore().Configure(cfg.Transcripts, transcriptFallbackDir(cfg))
+	}
+
+	if oldCfg == nil || !reflect.DeepEqual(oldCfg.Pricing, cfg.Pricing) {
+		pricing.Default().Configure(cfg.Pricing)
+	}
+
+	if oldCfg == nil || !reflect.DeepEqual(oldCfg.UsageReports, cfg.UsageReports) {
+		usagereport.Default().Configure(cfg.UsageReports, usageReportFallbackDir(cfg))
+	}
+
+	if oldCfg == nil || oldCfg.ModelsCache != cfg.ModelsCache {
+		modelcache.Default().Configure(cfg.ModelsCache)
+	}
+
+	if oldCfg == nil || !reflect.DeepEqual(oldCfg.Backup, cfg.Backup) || oldCfg.AuthDir != cfg.AuthDir {
+		backup.Default().Configure(cfg, s.configFilePath)
+	}
+
+	if oldCfg == nil || !reflect.DeepEqual(oldCfg.Metrics, cfg.Metrics) {
+		metrics.Default().Configure(cfg.Metrics)
+	}
+
+	if oldCfg != nil && !reflect.DeepEqual(oldCfg.DNSCache, cfg.DNSCache) {
+		dnscache.Default().Configure(cfg.DNSCache)
+	}
+
+	if oldCfg != nil && !reflect.DeepEqual(oldCfg.Offline, cfg.Offline) {
+		offline.Default().Configure(cfg.Offline)
+	}
+	if oldCfg != nil && !reflect.DeepEqual(oldCfg.FaultInjection, cfg.FaultInjection) {
+		faultinject.Default().Configure(cfg.FaultInjection)
+	}
+	if oldCfg != nil && !reflect.DeepEqual(oldCfg.FeatureFlags, cfg.FeatureFlags) {
+		featureflag.Default().Configure(cfg.FeatureFlags)
+	}
+
+	if oldCfg == nil || !reflect.DeepEqual(oldCfg.SpendLimits, cfg.SpendLimits) {
+		spendlimit.Default().Configure(cfg.SpendLimits)
+	}
+	spendlimit.Default().Persist(spendLimitStatePath(cfg))
+	if oldCfg == nil || !reflect.DeepEqual(oldCfg.QuotaExceeded.ResetSchedules, cfg.QuotaExceeded.ResetSchedules) {
+		spendlimit.Default().SetResetSchedules(cfg.QuotaExceeded)
+	}
+	if oldCfg == nil || !reflect.DeepEqual(oldCfg.SLO, cfg.SLO) {
+		slo.Default().Configure(cfg.SLO)
+	}
+
+	if oldCfg == nil || !reflect.DeepEqual(oldCfg.AgentBudget, cfg.AgentBudget) {
+		agentbudget.Default().Configure(cfg.AgentBudget)
+	}
+
+	if oldCfg == nil || !reflect.DeepEqual(oldCfg.LoopBreaker, cfg.LoopBreaker) {
+		agentbudget.DefaultBreaker().Configure(cfg.LoopBreaker)
+	}
+
+	if oldCfg == nil || !reflect.DeepEqual(oldCfg.StreamResumption, cfg.StreamResumption) {
+		resume.Default().Configure(cfg.StreamResumption)
+	}
+
+	if oldCfg == nil || !reflect.DeepEqual(oldCfg.ResponseSpill, cfg.ResponseSpill) {
+		spill.Configure(cfg.ResponseSpill)
+	}
+
+	if oldCfg == nil || !reflect.DeepEqual(oldCfg.SyntheticProbes, cfg.SyntheticProbes) {
+		probes.Default().Configure(cfg.SyntheticProbes)
+	}
+
+	if oldCfg == nil || !reflect.DeepEqual(oldCfg.ScheduledPrompts, cfg.ScheduledPrompts) {
+		scheduledprompt.Default().Configure(cfg.ScheduledPrompts, scheduledPromptFallbackDir(cfg))
+	}
+
+	if oldCfg == nil || !reflect.DeepEqual(oldCfg.Maintenance, cfg.Maintenance) {
+		maintenance.Default().Configure(cfg.Maintenance)
+	}
+
+	if oldCfg == nil || !reflect.DeepEqual(oldCfg.Mirror, cfg.Mirror) {
+		mirror.Default().Configure(cfg.Mirror)
+	}
+
+	if oldCfg != nil && (!reflect.DeepEqual(oldCfg.GRPC, cfg.GRPC) || oldCfg.TLS != cfg.TLS) {
+		s.grpcIngress.apply(cfg)
+	}
+
+	if oldCfg != nil && !reflect.DeepEqual(oldCfg.AsyncJobs, cfg.AsyncJobs) {
+		s.asyncJobs.Configure(cfg.AsyncJobs, asyncJobFallbackDir(cfg))
+	}
+
+	if oldCfg == nil || !reflect.DeepEqual(oldCfg.Projects, cfg.Projects) {
+		project.GetRegistry().Configure(cfg.Projects)
+	}
+
+	if oldCfg == nil || !reflect.DeepEqual(oldCfg.TemporaryKeys, cfg.TemporaryKeys) {
+		tempkey.Default().Configure(cfg.TemporaryKeys)
+	}
+
+	if oldCfg == nil || !reflect.DeepEqual(oldCfg.VirtualHosts, cfg.VirtualHosts) {
+		vhost.Default().Configure(cfg.VirtualHosts)
+	}
+
+	if oldCfg == nil || !reflect.DeepEqual(oldCfg.Routing.Rules, cfg.Routing.Rules) {
+		routing.Default().Configure(cfg.Routing.Rules)
+	}
+	routing.Default().SetTraceEnabled(cfg.Routing.Trace)
+
+	if oldCfg == nil || !reflect.DeepEqual(oldCfg.Moderation, cfg.Moderation) || oldCfg.ProxyURL != cfg.ProxyURL {
+		moderation.Default().Configure(cfg.Moderation, cfg.ProxyURL)
+	}
+
+	if oldCfg != nil && !reflect.DeepEqual(oldCfg.SharedState, cfg.SharedState) {
+		applySharedState(cfg, s.handlers.AuthManager)
+	}
+
 	if s.requestLogger != nil && (oldCfg == nil || oldCfg.ErrorLogsMaxFiles != cfg.ErrorLogsMaxFiles) {
 		if setter, ok := s.requestLogger.(interface{ SetErrorLogsMaxFiles(int) }); ok {
 			setter.SetErrorLogsMaxFiles(cfg.ErrorLogsMaxFiles)
 		}
 	}
 
+	if oldCfg != nil && !reflect.DeepEqual(oldCfg.IPAccess, cfg.IPAccess) {
+		ipaccess.Default().Configure(cfg.IPAccess)
+		if !reflect.DeepEqual(oldCfg.IPAccess.TrustedProxies, cfg.IPAccess.TrustedProxies) {
+			log.Warn("ip-access: trusted-proxies changes apply to the IP rules now and to management client IP checks after a restart")
+		}
+	}
+	if oldCfg != nil && s.cors != nil && !reflect.DeepEqual(oldCfg.CORS, cfg.CORS) {
+		s.cors.Configure(cfg.CORS)
+	}
+	if oldCfg != nil && !reflect.DeepEqual(oldCfg.AuthGuard, cfg.AuthGuard) {
+		authguard.Default().Configure(cfg.AuthGuard)
+	}
 	if oldCfg == nil || oldCfg.DisableCooling != cfg.DisableCooling {
 		auth.SetQuotaCooldownDisabled(cfg.DisableCooling)
 	}
@@ -909,16 +1478,16 @@ func (s *Server) UpdateClients(cfg *config.Config) {
 		s.handlers.AuthManager.SetRetryConfig(cfg.RequestRetry, time.Duration(cfg.MaxRetryInterval)*time.Second)
 	}
 
-	// Update log level dynamically when debug flag changes
-	if oldCfg == nil || oldCfg.Debug != cfg.Debug {
+	// Update log level dynamically when debug flag or component levels change
+	if oldCfg == nil || oldCfg.Debug != cfg.Debug || !reflect.DeepEqual(oldCfg.Logging.Components, cfg.Logging.Components) {
 		util.SetLogLevel(cfg)
 	}
 
 	prevSecretEmpty := true
 	if oldCfg != nil {
-		prevSecretEmpty = oldCfg.RemoteManagement.SecretKey == ""
+		prevSecretEmpty = !managementConfigured(oldCfg)
 	}
-	newSecretEmpty := cfg.RemoteManagement.SecretKey == ""
+	newSecretEmpty := !managementConfigured(cfg)
 	if s.envManagementSecret {
 		s.registerManagementRoutes()
 		if s.managementRoutesEnabled.CompareAndSwap(false, true) {
@@ -948,6 +1517,7 @@ func (s *Server) UpdateClients(cfg *config.Config) {
 
 	s.applyAccessConfig(oldCfg, cfg)
 	s.cfg = cfg
+	s.maxRequestBodyBytes.Store(cfg.MaxRequestBodyBytes)
 	s.wsAuthEnabled.Store(cfg.WebsocketAuth)
 	if oldCfg != nil && s.wsAuthChanged != nil && oldCfg.WebsocketAuth != cfg.WebsocketAuth {
 		s.wsAuthChanged(oldCfg.WebsocketAuth, cfg.WebsocketAuth)
@@ -972,7 +1542,7 @@ func (s *Server) UpdateClients(cfg *config.Config) {
 	if ampConfigChanged {
 		if s.ampModule != nil {
 			log.Debugf("triggering amp module config update")
-			if err := s.ampModule.OnConfigUpdated(cfg); err != nil {
+			if err := s.moduleGate.Track(s.engine, s.ampModule.Name(), func() error { return s.ampModule.OnConfigUpdated(cfg) }); err != nil {
 				log.Errorf("failed to update Amp module config: %v", err)
 			}
 		} else {
@@ -980,6 +1550,32 @@ func (s *Server) UpdateClients(cfg *config.Config) {
 		}
 	}
 
+	if s.claudeCodeModule != nil && (oldCfg == nil || !reflect.DeepEqual(oldCfg.ClaudeCode, cfg.ClaudeCode)) {
+		if err := s.claudeCodeModule.OnConfigUpdated(cfg); err != nil {
+			log.Errorf("failed to update Claude Code module config: %v", err)
+		}
+	}
+
+	if s.jetbrainsModule != nil && (oldCfg == nil || !reflect.DeepEqual(oldCfg.JetBrains, cfg.JetBrains)) {
+		if err := s.jetbrainsModule.OnConfigUpdated(cfg); err != nil {
+			log.Errorf("failed to update JetBrains module config: %v", err)
+		}
+	}
+
+	if oldCfg != nil && !reflect.DeepEqual(oldCfg.Modules, cfg.Modules) {
+		s.applyModuleToggles(cfg)
+	}
+	// Modules added through WithRouteModules have no config section of their own and are
+	// notified of every reload.
+	for _, mod := range s.extraModules {
+		if mod == nil || !s.moduleGate.Enabled(mod.Name()) {
+			continue
+		}
+		if err := s.moduleGate.Track(s.engine, mod.Name(), func() error { return mod.OnConfigUpdated(cfg) }); err != nil {
+			log.Errorf("failed to update %s module config: %v", mod.Name(), err)
+		}
+	}
+
 	// Count client sources from configuration and auth store.
 	tokenStore := sdkAuth.GetTokenStore()
 	if dirSetter, ok := tokenStore.(interface{ SetBaseDir(string) }); ok {
@@ -1022,20 +1618,78 @@ func (s *Server) SetWebsocketAuthChangeHandler(fn func(bool, bool)) {
 // it allows all requests (legacy behaviour).
 func AuthMiddleware(manager *sdkaccess.Manager) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		if manager == nil {
+		if !middleware.ApplyMaintenance(c, maintenance.Default()) {
+			return
+		}
+		done := maintenance.Default().Begin()
+		defer done()
+
+		access := middleware.VirtualHostAccess(c, vhost.Default(), manager)
+		if access == nil {
+			middleware.ScopeVirtualHost(c, vhost.Default())
+			middleware.TrackConnectionModel(c)
+			if !middleware.ApplyRoutingRules(c, routing.Default(), "") {
+				return
+			}
+			if !middleware.ApplyLoopBreaker(c, agentbudget.DefaultBreaker(), "") {
+				return
+			}
+			if !middleware.ApplyAgentBudget(c, agentbudget.Default(), "") {
+				return
+			}
+			finish, ok := middleware.ApplyStreamResumption(c, resume.Default(), "")
+			if !ok {
+				return
+			}
+			defer finish()
 			c.Next()
 			return
 		}
 
-		result, err := manager.Authenticate(c.Request.Context(), c.Request)
+		clientIP := ipaccess.Default().ClientIP(c.Request).String()
+		if remaining, banned := authguard.Default().Banned(clientIP); banned {
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(remaining.Seconds()))))
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": "Too many failed authentication attempts"})
+			return
+		}
+
+		result, grant, err := middleware.AuthenticateTemporaryKey(c, tempkey.Default(), access)
+		if result == nil && err == nil {
+			result, err = access.Authenticate(c.Request.Context(), c.Request)
+		}
 		if err == nil {
+			authguard.Default().Succeed(clientIP)
+			principal := ""
 			if result != nil {
+				principal = result.Principal
 				c.Set("apiKey", result.Principal)
 				c.Set("accessProvider", result.Provider)
 				if len(result.Metadata) > 0 {
 					c.Set("accessMetadata", result.Metadata)
 				}
+				if !middleware.ApplyTemporaryKey(c, tempkey.Default(), grant) {
+					return
+				}
+				if !middleware.ScopeProject(c, project.GetRegistry(), result.Principal) {
+					return
+				}
+			}
+			middleware.ScopeVirtualHost(c, vhost.Default())
+			middleware.TrackConnectionModel(c)
+			if !middleware.ApplyRoutingRules(c, routing.Default(), principal) {
+				return
+			}
+			if !middleware.ApplyLoopBreaker(c, agentbudget.DefaultBreaker(), principal) {
+				return
+			}
+			if !middleware.ApplyAgentBudget(c, agentbudget.Default(), principal) {
+				return
 			}
+			finish, ok := middleware.ApplyStreamResumption(c, resume.Default(), principal)
+			if !ok {
+				return
+			}
+			defer finish()
 			c.Next()
 			return
 		}
@@ -1044,10 +1698,65 @@ func AuthMiddleware(manager *sdkaccess.Manager) gin.HandlerFunc {
 		case errors.Is(err, sdkaccess.ErrNoCredentials):
 			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Missing API key"})
 		case errors.Is(err, sdkaccess.ErrInvalidCredential):
+			if authguard.Default().Fail(clientIP) {
+				log.Warnf("auth guard: banning %s after repeated invalid API keys", clientIP)
+			}
 			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid API key"})
+		case errors.Is(err, tempkey.ErrQuotaExceeded):
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": "Temporary key quota exceeded"})
 		default:
 			log.Errorf("authentication middleware error: %v", err)
 			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Authentication service error"})
 		}
 	}
 }
+
+// routeModuleList returns the registered routing modules.
+func (s *Server) routeModuleList() []modules.RouteModuleV2 {
+	var list []modules.RouteModuleV2
+	if s.ampModule != nil {
+		list = append(list, s.ampModule)
+	}
+	if s.augplusModule != nil {
+		list = append(list, s.augplusModule)
+	}
+	if s.claudeCodeModule != nil {
+		list = append(list, s.claudeCodeModule)
+	}
+	if s.jetbrainsModule != nil {
+		list = append(list, s.jetbrainsModule)
+	}
+	for _, mod := range s.extraModules {
+		if mod != nil {
+			list = append(list, mod)
+		}
+	}
+	return list
+}
+
+// applyModuleToggles enables and disables the routing modules as configured. A module
+// enabled again is notified through OnConfigUpdated so it can pick up settings changed while
+// it was disabled.
+func (s *Server) applyModuleToggles(cfg *config.Config) {
+	for name := range cfg.Modules {
+		if !slices.Contains(s.routeModules, name) {
+			log.Warnf("modules: unknown or unregistered routing module %q", name)
+		}
+	}
+	for _, mod := range s.routeModuleList() {
+		name := mod.Name()
+		enabled, was := cfg.ModuleEnabled(name), s.moduleGate.Enabled(name)
+		if enabled == was {
+			continue
+		}
+		s.moduleGate.SetEnabled(name, enabled)
+		if !enabled {
+			log.Infof("routing module %s disabled", name)
+			continue
+		}
+		log.Infof("routing module %s enabled", name)
+		if err := s.moduleGate.Track(s.engine, name, func() error { return mod.OnConfigUpdated(cfg) }); err != nil {
+			log.Errorf("failed to update %s module config: %v", name, err)
+		}
+	}
+}
@@ -5,17 +5,19 @@ import (
 	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"testing"
 
 	gin "github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/api/modules"
 	proxyconfig "github.com/router-for-me/CLIProxyAPI/v6/internal/config"
 	sdkaccess "github.com/router-for-me/CLIProxyAPI/v6/sdk/access"
 	"github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
 	sdkconfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
 )
 
-func newTestServer(t *testing.T) *Server {
+func newTestServer(t *testing.T, opts ...ServerOption) *Server {
 	t.Helper()
 
 	gin.SetMode(gin.TestMode)
@@ -41,7 +43,7 @@ func newTestServer(t *testing.T) *Server {
 	accessManager := sdkaccess.NewManager()
 
 	configPath := filepath.Join(tmpDir, "config.yaml")
-	return NewServer(cfg, authManager, accessManager, configPath)
+	return NewServer(cfg, authManager, accessManager, configPath, opts...)
 }
 
 func TestAmpProviderModelRoutes(t *testing.T) {
@@ -109,3 +111,54 @@ func TestAmpProviderModelRoutes(t *testing.T) {
 		})
 	}
 }
+
+func TestModuleToggleOnConfigReload(t *testing.T) {
+	server := newTestServer(t)
+	get := func() int {
+		req := httptest.NewRequest(http.MethodGet, "/api/provider/openai/models", nil)
+		req.Header.Set("Authorization", "Bearer test-key")
+		rr := httptest.NewRecorder()
+		server.engine.ServeHTTP(rr, req)
+		return rr.Code
+	}
+
+	cfg := *server.cfg
+	cfg.Modules = map[string]bool{"amp-routing": false}
+	server.UpdateClients(&cfg)
+	if code := get(); code != http.StatusNotFound {
+		t.Fatalf("status with amp disabled = %d, want 404", code)
+	}
+	if modules := server.versionInfo().Modules; slices.Contains(modules, "amp-routing") {
+		t.Fatalf("modules = %v, amp should be hidden while disabled", modules)
+	}
+
+	enabled := cfg
+	enabled.Modules = map[string]bool{"amp-routing": true}
+	server.UpdateClients(&enabled)
+	if code := get(); code != http.StatusOK {
+		t.Fatalf("status with amp enabled again = %d, want 200", code)
+	}
+}
+
+// dependentModule is a routing module needing a module that is not there.
+type dependentModule struct{}
+
+func (dependentModule) Name() string                              { return "needs-missing" }
+func (dependentModule) Dependencies() []string                    { return []string{"missing"} }
+func (dependentModule) Register(modules.Context) error            { return nil }
+func (dependentModule) OnConfigUpdated(*proxyconfig.Config) error { return nil }
+
+func TestRoutingModulesRegisterInOrderAndFailStartup(t *testing.T) {
+	server := newTestServer(t)
+	if got := server.routeModules; !slices.Equal(got[:2], []string{"amp-routing", "augplus"}) {
+		t.Fatalf("route modules = %v, want amp-routing before augplus", got)
+	}
+	if server.moduleErr != nil {
+		t.Fatalf("module error = %v", server.moduleErr)
+	}
+
+	broken := newTestServer(t, WithRouteModules(dependentModule{}))
+	if err := broken.Start(); err == nil || !strings.Contains(err.Error(), "missing dependency") {
+		t.Fatalf("Start error = %v, want missing dependency", err)
+	}
+}
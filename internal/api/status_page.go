@@ -0,0 +1,85 @@
+package api
+
+import (
+	"bytes"
+	"html/template"
+	"net/http"
+	"runtime"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/buildinfo"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/offline"
+)
+
+// docsURL is the documentation linked from the status page.
+const docsURL = "https://help.router-for.me/"
+
+// versionInfo is the build information reported by /version for support triage.
+type versionInfo struct {
+	Version   string   `json:"version"`
+	Commit    string   `json:"commit"`
+	BuildDate string   `json:"build_date"`
+	GoVersion string   `json:"go_version"`
+	Platform  string   `json:"platform"`
+	Modules   []string `json:"modules"`
+}
+
+// versionInfo returns the build information and the enabled modules.
+func (s *Server) versionInfo() versionInfo {
+	var modules []string
+	for _, name := range s.routeModules {
+		if s.moduleGate.Enabled(name) {
+			modules = append(modules, name)
+		}
+	}
+	if s.managementRoutesEnabled.Load() {
+		modules = append(modules, "management")
+	}
+	if offline.Default().Enabled() {
+		modules = append(modules, "offline")
+	}
+	return versionInfo{
+		Version:   buildinfo.Version,
+		Commit:    buildinfo.Commit,
+		BuildDate: buildinfo.BuildDate,
+		GoVersion: runtime.Version(),
+		Platform:  runtime.GOOS + "/" + runtime.GOARCH,
+		Modules:   modules,
+	}
+}
+
+// handleVersion reports the build information.
+func (s *Server) handleVersion(c *gin.Context) {
+	c.JSON(http.StatusOK, s.versionInfo())
+}
+
+var statusPageTemplate = template.Must(template.New("status").Parse(`<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>{{.Status}} {{.StatusText}} - CLI Proxy API</title>
+<style>body{font-family:system-ui,sans-serif;max-width:40em;margin:4em auto;padding:0 1em;color:#222}code{background:#f2f2f2;padding:0 .2em}dt{font-weight:600}</style>
+</head><body>
+<h1>{{.Status}} {{.StatusText}}</h1>
+{{if .Message}}<p>{{.Message}}</p>{{end}}
+<p>This is a CLI Proxy API server. Its endpoints are meant for API clients, not browsers.</p>
+<dl>
+<dt>Version</dt><dd><code>{{.Info.Version}}</code> (commit <code>{{.Info.Commit}}</code>, built {{.Info.BuildDate}})</dd>
+<dt>Modules</dt><dd>{{range $i, $m := .Info.Modules}}{{if $i}}, {{end}}{{$m}}{{else}}none{{end}}</dd>
+</dl>
+<p><a href="{{.DocsURL}}">Documentation</a> &middot; <a href="/version">Build information</a></p>
+</body></html>
+`))
+
+// renderStatusPage renders the HTML status page shown to browsers instead of an API error.
+func (s *Server) renderStatusPage(status int, message string) string {
+	var buf bytes.Buffer
+	err := statusPageTemplate.Execute(&buf, struct {
+		Status     int
+		StatusText string
+		Message    string
+		Info       versionInfo
+		DocsURL    string
+	}{status, http.StatusText(status), message, s.versionInfo(), docsURL})
+	if err != nil {
+		return http.StatusText(status)
+	}
+	return buf.String()
+}
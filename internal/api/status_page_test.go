@@ -0,0 +1,49 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestBrowsersGetStatusPage(t *testing.T) {
+	server := newTestServer(t)
+
+	cases := []struct {
+		name, path, accept string
+		wantStatus         int
+		wantHTML           bool
+		wantContains       string
+	}{
+		{"browser unknown route", "/nope", "text/html,application/xhtml+xml,*/*;q=0.8", http.StatusNotFound, true, "404 Not Found"},
+		{"api client unknown route", "/nope", "application/json", http.StatusNotFound, false, "404 page not found"},
+		{"browser success", "/healthz", "text/html", http.StatusOK, false, `"ok"`},
+	}
+	for _, tc := range cases {
+		req := httptest.NewRequest(http.MethodGet, tc.path, nil)
+		req.Header.Set("Accept", tc.accept)
+		rr := httptest.NewRecorder()
+		server.engine.ServeHTTP(rr, req)
+
+		isHTML := strings.HasPrefix(rr.Header().Get("Content-Type"), "text/html")
+		if rr.Code != tc.wantStatus || isHTML != tc.wantHTML || !strings.Contains(rr.Body.String(), tc.wantContains) {
+			t.Errorf("%s: status %d, content type %q, body %s", tc.name, rr.Code, rr.Header().Get("Content-Type"), rr.Body.String())
+		}
+	}
+}
+
+func TestVersionEndpoint(t *testing.T) {
+	server := newTestServer(t)
+
+	rr := httptest.NewRecorder()
+	server.engine.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/version", nil))
+	var info versionInfo
+	if err := json.Unmarshal(rr.Body.Bytes(), &info); err != nil || rr.Code != http.StatusOK {
+		t.Fatalf("status %d, body %s, err %v", rr.Code, rr.Body.String(), err)
+	}
+	if info.Version == "" || info.GoVersion == "" || len(info.Modules) == 0 || info.Modules[0] != "amp-routing" {
+		t.Fatalf("version info = %+v", info)
+	}
+}
@@ -0,0 +1,74 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/tempkey"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers"
+)
+
+func temporaryKeyError(c *gin.Context, status int, message string) {
+	c.Data(status, "application/json", handlers.BuildDialectErrorBody(handlers.DialectOpenAI, status, "", message))
+}
+
+// temporaryKeysAllowed answers 404 while temporary keys are disabled and 403 to requests
+// authenticated by a temporary key, which cannot mint or manage keys.
+func temporaryKeysAllowed(c *gin.Context) bool {
+	if !tempkey.Default().Enabled() {
+		temporaryKeyError(c, http.StatusNotFound, "temporary keys are disabled")
+		return false
+	}
+	if c.GetString(tempkey.ContextKey) != "" {
+		temporaryKeyError(c, http.StatusForbidden, "temporary keys cannot manage temporary keys")
+		return false
+	}
+	return true
+}
+
+// createTemporaryKey serves POST /v1/temporary-keys. It mints a key derived from the
+// caller's API key; the key is only returned in this response.
+func (s *Server) createTemporaryKey(c *gin.Context) {
+	if !temporaryKeysAllowed(c) {
+		return
+	}
+	var req tempkey.MintRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			temporaryKeyError(c, http.StatusBadRequest, "invalid body")
+			return
+		}
+	}
+	key, grant, err := tempkey.Default().Mint(c.GetString("apiKey"), req)
+	switch {
+	case errors.Is(err, tempkey.ErrLimit):
+		temporaryKeyError(c, http.StatusTooManyRequests, err.Error())
+		return
+	case err != nil:
+		temporaryKeyError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"object": "temporary_key", "key": key, "temporary_key": grant})
+}
+
+// listTemporaryKeys serves GET /v1/temporary-keys with the caller's live keys.
+func (s *Server) listTemporaryKeys(c *gin.Context) {
+	if !temporaryKeysAllowed(c) {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"object": "list", "data": tempkey.Default().List(c.GetString("apiKey"))})
+}
+
+// deleteTemporaryKey serves DELETE /v1/temporary-keys/:id for keys of the caller.
+func (s *Server) deleteTemporaryKey(c *gin.Context) {
+	if !temporaryKeysAllowed(c) {
+		return
+	}
+	id := c.Param("id")
+	if !tempkey.Default().Revoke(id, c.GetString("apiKey")) {
+		temporaryKeyError(c, http.StatusNotFound, "temporary key "+id+" not found")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"id": id, "object": "temporary_key", "deleted": true})
+}
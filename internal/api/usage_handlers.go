@@ -0,0 +1,144 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/usage"
+)
+
+// openAIUsageBucketWidths maps OpenAI bucket_width values to durations and default bucket limits.
+var openAIUsageBucketWidths = map[string]struct {
+	width        time.Duration
+	defaultLimit int
+	maxLimit     int
+}{
+	"1m": {width: time.Minute, defaultLimit: 60, maxLimit: 1440},
+	"1h": {width: time.Hour, defaultLimit: 24, maxLimit: 168},
+	"1d": {width: 24 * time.Hour, defaultLimit: 7, maxLimit: 31},
+}
+
+// usageScopeKeys restricts usage reports to the authenticated client key, when there is one.
+func usageScopeKeys(c *gin.Context) []string {
+	if value, exists := c.Get("apiKey"); exists {
+		if key, ok := value.(string); ok && key != "" {
+			return []string{key}
+		}
+	}
+	return nil
+}
+
+// queryValues collects repeated and comma-separated query values (e.g. group_by=model,api_key_id).
+func queryValues(c *gin.Context, names ...string) []string {
+	var out []string
+	for _, name := range names {
+		for _, raw := range c.QueryArray(name) {
+			for _, part := range strings.Split(raw, ",") {
+				if part = strings.TrimSpace(part); part != "" {
+					out = append(out, part)
+				}
+			}
+		}
+	}
+	return out
+}
+
+func openAIUsageError(c *gin.Context, message string) {
+	c.JSON(http.StatusBadRequest, gin.H{"error": gin.H{
+		"message": message,
+		"type":    "invalid_request_error",
+	}})
+}
+
+// organizationUsageHandler serves /v1/organization/usage/completions in OpenAI's format.
+func (s *Server) organizationUsageHandler(c *gin.Context) {
+	startRaw := strings.TrimSpace(c.Query("start_time"))
+	if startRaw == "" {
+		openAIUsageError(c, "start_time is required")
+		return
+	}
+	startUnix, err := strconv.ParseInt(startRaw, 10, 64)
+	if err != nil {
+		openAIUsageError(c, "start_time must be a unix timestamp")
+		return
+	}
+	query := usage.OpenAIUsageQuery{
+		StartTime: time.Unix(startUnix, 0),
+		APIKeys:   usageScopeKeys(c),
+		Models:    queryValues(c, "models", "models[]"),
+	}
+	if endRaw := strings.TrimSpace(c.Query("end_time")); endRaw != "" {
+		endUnix, errEnd := strconv.ParseInt(endRaw, 10, 64)
+		if errEnd != nil {
+			openAIUsageError(c, "end_time must be a unix timestamp")
+			return
+		}
+		query.EndTime = time.Unix(endUnix, 0)
+	}
+
+	widthName := c.DefaultQuery("bucket_width", "1d")
+	width, ok := openAIUsageBucketWidths[widthName]
+	if !ok {
+		openAIUsageError(c, "bucket_width must be one of 1m, 1h, 1d")
+		return
+	}
+	query.BucketWidth = width.width
+	query.Limit = width.defaultLimit
+	if limitRaw := strings.TrimSpace(c.Query("limit")); limitRaw != "" {
+		limit, errLimit := strconv.Atoi(limitRaw)
+		if errLimit != nil || limit <= 0 || limit > width.maxLimit {
+			openAIUsageError(c, "limit is out of range for bucket_width")
+			return
+		}
+		query.Limit = limit
+	}
+	if page := strings.TrimSpace(c.Query("page")); page != "" {
+		next, errPage := time.Parse(time.RFC3339, page)
+		if errPage != nil {
+			openAIUsageError(c, "invalid page cursor")
+			return
+		}
+		query.StartTime = next
+	}
+
+	for _, group := range queryValues(c, "group_by", "group_by[]") {
+		switch group {
+		case "model":
+			query.GroupByModel = true
+		case "api_key_id":
+			query.GroupByAPIKey = true
+		case "project_id", "user_id", "batch":
+			// Not tracked by the proxy; results are reported with null values.
+		default:
+			openAIUsageError(c, "unsupported group_by value: "+group)
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, usage.BuildOpenAIUsagePage(usage.GetRequestStatistics().Snapshot(), query))
+}
+
+// legacyUsageHandler serves the legacy /v1/usage?date=YYYY-MM-DD report.
+func (s *Server) legacyUsageHandler(c *gin.Context) {
+	day := time.Now().UTC()
+	if raw := strings.TrimSpace(c.Query("date")); raw != "" {
+		parsed, err := time.Parse("2006-01-02", raw)
+		if err != nil {
+			openAIUsageError(c, "date must be formatted as YYYY-MM-DD")
+			return
+		}
+		day = parsed
+	}
+	data := usage.BuildLegacyUsage(usage.GetRequestStatistics().Snapshot(), day, usageScopeKeys(c))
+	c.JSON(http.StatusOK, gin.H{
+		"object":           "list",
+		"data":             data,
+		"ft_data":          []any{},
+		"dalle_api_data":   []any{},
+		"whisper_api_data": []any{},
+		"tts_api_data":     []any{},
+	})
+}
@@ -0,0 +1,343 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/vhost"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers"
+	log "github.com/sirupsen/logrus"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// wsBridgeDialects maps the dialects of /v1/ws-bridge/:dialect to the streaming endpoint a
+// bridged request is served by and the dialect its errors are rendered in.
+var wsBridgeDialects = map[string]struct {
+	path    string
+	dialect handlers.Dialect
+}{
+	"openai":    {path: "/v1/chat/completions", dialect: handlers.DialectOpenAI},
+	"claude":    {path: "/v1/messages", dialect: handlers.DialectClaude},
+	"responses": {path: "/v1/responses", dialect: handlers.DialectOpenAI},
+	"gemini":    {path: "/v1beta/models/%s:streamGenerateContent", dialect: handlers.DialectGemini},
+}
+
+// wsBridgeQueueSize bounds the requests a client may send ahead of the one being served.
+const wsBridgeQueueSize = 8
+
+// wsBridgeSkipHeaders are upgrade request headers not copied onto bridged requests.
+var wsBridgeSkipHeaders = map[string]bool{
+	"Connection":               true,
+	"Upgrade":                  true,
+	"Content-Length":           true,
+	"Sec-Websocket-Key":        true,
+	"Sec-Websocket-Version":    true,
+	"Sec-Websocket-Extensions": true,
+	"Sec-Websocket-Protocol":   true,
+}
+
+// wsBridgeUpgrader admits same-origin upgrades and the origins the CORS policy of the
+// bridge path allows.
+func (s *Server) wsBridgeUpgrader() *websocket.Upgrader {
+	return &websocket.Upgrader{
+		ReadBufferSize:  4096,
+		WriteBufferSize: 4096,
+		CheckOrigin: func(r *http.Request) bool {
+			origin := r.Header.Get("Origin")
+			if u, err := url.Parse(origin); err == nil && u.Host != "" && strings.EqualFold(u.Host, r.Host) {
+				return true
+			}
+			return s.cors.AllowsOrigin(r.URL.Path, origin)
+		},
+	}
+}
+
+// wsBridgeDone is the frame sent after the frames of every bridged request.
+type wsBridgeDone struct {
+	Type   string `json:"type"`
+	Status int    `json:"status"`
+}
+
+// wsBridgeHandler serves /v1/ws-bridge/:dialect for clients that only consume WebSocket
+// streams. Every text frame from the client is a request body of the dialect; it is served
+// by the regular streaming endpoint with the upgrade request's credentials, and each SSE
+// event of the response is sent back as one frame holding the event's data. Requests on one
+// connection are served in order, each followed by a {"type":"bridge.done"} frame carrying
+// the HTTP status.
+func (s *Server) wsBridgeHandler(c *gin.Context) {
+	dialect := c.Param("dialect")
+	if _, ok := wsBridgeDialects[dialect]; !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": gin.H{
+			"message": "unknown websocket bridge dialect " + dialect + "; use openai, claude, responses or gemini",
+			"type":    "invalid_request_error",
+		}})
+		return
+	}
+	conn, err := s.wsBridgeUpgrader().Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		log.Warnf("ws bridge: upgrade failed: %v", err)
+		return
+	}
+	defer func() {
+		if errClose := conn.Close(); errClose != nil {
+			log.Debugf("ws bridge: close connection: %v", errClose)
+		}
+	}()
+	if limit := s.maxRequestBodyBytes.Load(); limit > 0 {
+		// Frames are request bodies; larger ones close the connection with 1009.
+		conn.SetReadLimit(limit)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	requests := make(chan []byte, wsBridgeQueueSize)
+	go func() {
+		defer close(requests)
+		defer cancel()
+		for {
+			messageType, data, errRead := conn.ReadMessage()
+			if errRead != nil {
+				return
+			}
+			if messageType != websocket.TextMessage && messageType != websocket.BinaryMessage {
+				continue
+			}
+			select {
+			case requests <- data:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	var writeMu sync.Mutex
+	send := func(frame []byte) error {
+		writeMu.Lock()
+		defer writeMu.Unlock()
+		return conn.WriteMessage(websocket.TextMessage, frame)
+	}
+	for body := range requests {
+		if errServe := s.serveWSBridgeRequest(ctx, c.Request, dialect, body, send); errServe != nil {
+			log.Debugf("ws bridge: send failed: %v", errServe)
+			return
+		}
+	}
+}
+
+// serveWSBridgeRequest runs one bridged request through the engine and sends its frames.
+func (s *Server) serveWSBridgeRequest(ctx context.Context, upgrade *http.Request, dialect string, body []byte, send func([]byte) error) error {
+	target := wsBridgeDialects[dialect]
+	writer := newWSBridgeWriter(send)
+
+	req, errReq := buildWSBridgeRequest(ctx, upgrade, dialect, body)
+	if errReq != nil {
+		if err := send(handlers.BuildDialectErrorBody(target.dialect, http.StatusBadRequest, "", errReq.Error())); err != nil {
+			return err
+		}
+		return sendWSBridgeDone(send, http.StatusBadRequest)
+	}
+	s.serveBridged(writer, req, vhost.FromContext(upgrade.Context()))
+	if err := writer.finish(); err != nil {
+		return err
+	}
+	return sendWSBridgeDone(send, writer.status)
+}
+
+func sendWSBridgeDone(send func([]byte) error, status int) error {
+	frame, _ := json.Marshal(wsBridgeDone{Type: "bridge.done", Status: status})
+	return send(frame)
+}
+
+// buildWSBridgeRequest turns a client frame into a streaming request for the dialect's
+// endpoint, carrying the headers and query of the upgrade request.
+func buildWSBridgeRequest(ctx context.Context, upgrade *http.Request, dialect string, body []byte) (*http.Request, error) {
+	req, err := newBridgedRequest(ctx, dialect, body, true, upgrade.URL.Query())
+	if err != nil {
+		return nil, err
+	}
+	for key, values := range upgrade.Header {
+		if !wsBridgeSkipHeaders[http.CanonicalHeaderKey(key)] {
+			req.Header[key] = append([]string(nil), values...)
+		}
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "text/event-stream")
+	req.Host = upgrade.Host
+	req.RemoteAddr = upgrade.RemoteAddr
+	return req, nil
+}
+
+// serveBridged serves a request made on behalf of a client, such as a WebSocket frame or a
+// batch item, through the handler of the listener. vhostName is the virtual host of the
+// originating request; it is kept because the bridged path alone may no longer select it.
+func (s *Server) serveBridged(w http.ResponseWriter, req *http.Request, vhostName string) {
+	if vhostName != "" {
+		s.engine.ServeHTTP(w, req.WithContext(vhost.WithName(req.Context(), vhostName)))
+		return
+	}
+	vhost.Handler(vhost.Default(), s.engine).ServeHTTP(w, req)
+}
+
+// newBridgedRequest builds the request serving body on the dialect's endpoint, streaming or
+// not, without headers. Gemini bodies name the model in "model".
+func newBridgedRequest(ctx context.Context, dialect string, body []byte, stream bool, query url.Values) (*http.Request, error) {
+	target, ok := wsBridgeDialects[dialect]
+	if !ok {
+		return nil, fmt.Errorf("unknown dialect %q; use openai, claude, responses or gemini", dialect)
+	}
+	body = bytes.TrimSpace(body)
+	if !gjson.ValidBytes(body) || !gjson.ParseBytes(body).IsObject() {
+		return nil, fmt.Errorf("request body must be a JSON object")
+	}
+	path := target.path
+	if query == nil {
+		query = url.Values{}
+	}
+	if dialect == "gemini" {
+		model := strings.TrimSpace(gjson.GetBytes(body, "model").String())
+		if model == "" {
+			return nil, fmt.Errorf("gemini requests must name the model in \"model\"")
+		}
+		if !stream {
+			path = strings.Replace(path, ":streamGenerateContent", ":generateContent", 1)
+		}
+		path = fmt.Sprintf(path, url.PathEscape(strings.TrimPrefix(model, "models/")))
+		body, _ = sjson.DeleteBytes(body, "model")
+		if stream {
+			query.Set("alt", "sse")
+		}
+	} else {
+		body, _ = sjson.SetBytes(body, "stream", stream)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, path, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.URL.RawQuery = query.Encode()
+	return req, nil
+}
+
+// wsBridgeWriter is the http.ResponseWriter of a bridged request. SSE responses are split
+// into events whose data is sent as one frame each; comments, keep-alives and the OpenAI
+// [DONE] sentinel are dropped. Other responses, such as JSON errors, are sent as a single
+// frame once the request completes.
+type wsBridgeWriter struct {
+	send   func([]byte) error
+	mu     sync.Mutex
+	header http.Header
+	status int
+	sse    bool
+	buf    bytes.Buffer
+	err    error
+}
+
+func newWSBridgeWriter(send func([]byte) error) *wsBridgeWriter {
+	return &wsBridgeWriter{send: send, header: make(http.Header)}
+}
+
+func (w *wsBridgeWriter) Header() http.Header { return w.header }
+
+func (w *wsBridgeWriter) WriteHeader(status int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.writeHeaderLocked(status)
+}
+
+func (w *wsBridgeWriter) writeHeaderLocked(status int) {
+	if w.status != 0 {
+		return
+	}
+	w.status = status
+	w.sse = strings.HasPrefix(w.header.Get("Content-Type"), "text/event-stream")
+}
+
+func (w *wsBridgeWriter) Write(data []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.writeHeaderLocked(http.StatusOK)
+	if w.err != nil {
+		return 0, w.err
+	}
+	w.buf.Write(data)
+	if w.sse {
+		w.sendEventsLocked(false)
+	}
+	if w.err != nil {
+		return 0, w.err
+	}
+	return len(data), nil
+}
+
+// Flush implements http.Flusher; complete events are already sent by Write.
+func (w *wsBridgeWriter) Flush() {}
+
+// sendEventsLocked sends every complete SSE event in the buffer, or everything left when
+// final is set.
+func (w *wsBridgeWriter) sendEventsLocked(final bool) {
+	for w.err == nil {
+		raw := bytes.ReplaceAll(w.buf.Bytes(), []byte("\r\n"), []byte("\n"))
+		end := bytes.Index(raw, []byte("\n\n"))
+		var event []byte
+		switch {
+		case end >= 0:
+			event = raw[:end]
+			w.buf.Reset()
+			w.buf.Write(raw[end+2:])
+		case final && len(bytes.TrimSpace(raw)) > 0:
+			event = raw
+			w.buf.Reset()
+		default:
+			return
+		}
+		if data, ok := sseEventData(event); ok {
+			w.err = w.send(data)
+		}
+	}
+}
+
+// sseEventData joins the data lines of an SSE event.
+func sseEventData(event []byte) ([]byte, bool) {
+	var lines [][]byte
+	for _, line := range bytes.Split(event, []byte("\n")) {
+		if !bytes.HasPrefix(line, []byte("data:")) {
+			continue
+		}
+		line = bytes.TrimPrefix(line[len("data:"):], []byte(" "))
+		lines = append(lines, line)
+	}
+	data := bytes.Join(lines, []byte("\n"))
+	if len(lines) == 0 || len(bytes.TrimSpace(data)) == 0 || string(bytes.TrimSpace(data)) == "[DONE]" {
+		return nil, false
+	}
+	return data, true
+}
+
+// finish sends what the request left in the buffer.
+func (w *wsBridgeWriter) finish() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.writeHeaderLocked(http.StatusOK)
+	if w.err != nil {
+		return w.err
+	}
+	if w.sse {
+		w.sendEventsLocked(true)
+		return w.err
+	}
+	// Non-streaming bodies may carry keep-alive blank lines before the payload.
+	if body := bytes.TrimSpace(w.buf.Bytes()); len(body) > 0 {
+		w.err = w.send(body)
+	}
+	w.buf.Reset()
+	return w.err
+}
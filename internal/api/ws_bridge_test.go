@@ -0,0 +1,144 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/vhost"
+	"github.com/tidwall/gjson"
+)
+
+func TestWSBridgeWriterMapsSSEEventsToFrames(t *testing.T) {
+	var frames []string
+	w := newWSBridgeWriter(func(frame []byte) error {
+		frames = append(frames, string(frame))
+		return nil
+	})
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.WriteHeader(http.StatusOK)
+	for _, part := range []string{
+		": keep-alive\n\n",
+		"event: message_start\ndata: {\"type\":\"message_start\"}\n\n",
+		"data: {\"choices\":[{\"delta\":{\"content\":\"he",
+		"llo\"}}]}\n\ndata: [DONE]\n\n",
+		"event: response.completed\ndata: {\"type\":\"response.completed\"}",
+	} {
+		if _, err := w.Write([]byte(part)); err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+	}
+	if err := w.finish(); err != nil {
+		t.Fatalf("finish: %v", err)
+	}
+	want := []string{
+		`{"type":"message_start"}`,
+		`{"choices":[{"delta":{"content":"hello"}}]}`,
+		`{"type":"response.completed"}`,
+	}
+	if strings.Join(frames, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("frames = %q", frames)
+	}
+}
+
+func TestWSBridgeServesRequestsOverWebSocket(t *testing.T) {
+	server := newTestServer(t)
+	ts := httptest.NewServer(server.engine)
+	defer ts.Close()
+
+	header := http.Header{"Authorization": []string{"Bearer test-key"}}
+	wsURL := "ws" + strings.TrimPrefix(ts.URL, "http") + "/v1/ws-bridge/openai"
+	conn, resp, err := websocket.DefaultDialer.Dial(wsURL, header)
+	if err != nil {
+		t.Fatalf("dial: %v (response %v)", err, resp)
+	}
+	defer func() { _ = conn.Close() }()
+
+	read := func() gjson.Result {
+		t.Helper()
+		_, data, errRead := conn.ReadMessage()
+		if errRead != nil {
+			t.Fatalf("read: %v", errRead)
+		}
+		return gjson.ParseBytes(data)
+	}
+
+	if err = conn.WriteMessage(websocket.TextMessage, []byte("not json")); err != nil {
+		t.Fatal(err)
+	}
+	if frame := read(); !strings.Contains(frame.Get("error.message").String(), "JSON object") {
+		t.Fatalf("invalid frame error = %s", frame.Raw)
+	}
+	if done := read(); done.Get("type").String() != "bridge.done" || done.Get("status").Int() != http.StatusBadRequest {
+		t.Fatalf("done frame = %s", done.Raw)
+	}
+
+	// No provider serves the model: the handler's JSON error is relayed as one frame.
+	if err = conn.WriteMessage(websocket.TextMessage, []byte(`{"model":"no-such-model","messages":[{"role":"user","content":"hi"}]}`)); err != nil {
+		t.Fatal(err)
+	}
+	if frame := read(); !frame.Get("error.message").Exists() {
+		t.Fatalf("error frame = %s", frame.Raw)
+	}
+	if done := read(); done.Get("type").String() != "bridge.done" || done.Get("status").Int() < http.StatusBadRequest {
+		t.Fatalf("done frame = %s", done.Raw)
+	}
+}
+
+func TestWSBridgeRejectsUnknownDialect(t *testing.T) {
+	server := newTestServer(t)
+	req := httptest.NewRequest(http.MethodGet, "/v1/ws-bridge/cobol", nil)
+	req.Header.Set("Authorization", "Bearer test-key")
+	rec := httptest.NewRecorder()
+	server.engine.ServeHTTP(rec, req)
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("status = %d, want 404", rec.Code)
+	}
+}
+
+func TestWSBridgeChecksOrigin(t *testing.T) {
+	server := newTestServer(t)
+	server.cors.Configure(config.CORSConfig{CORSPolicy: config.CORSPolicy{AllowOrigins: []string{"https://ide.example.com"}}})
+	ts := httptest.NewServer(server.engine)
+	defer ts.Close()
+
+	wsURL := "ws" + strings.TrimPrefix(ts.URL, "http") + "/v1/ws-bridge/openai"
+	for origin, want := range map[string]bool{
+		"":                        true,
+		"https://ide.example.com": true,
+		ts.URL:                    true,
+		"https://evil.example":    false,
+	} {
+		header := http.Header{"Authorization": []string{"Bearer test-key"}}
+		if origin != "" {
+			header.Set("Origin", origin)
+		}
+		conn, _, err := websocket.DefaultDialer.Dial(wsURL, header)
+		if (err == nil) != want {
+			t.Fatalf("origin %q: dial err = %v, want admitted %v", origin, err, want)
+		}
+		if conn != nil {
+			_ = conn.Close()
+		}
+	}
+}
+
+func TestServeBridgedKeepsTheVirtualHostOfTheOrigin(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	var got string
+	s := &Server{engine: gin.New()}
+	s.engine.POST("/v1/messages", func(c *gin.Context) { got = vhost.FromContext(c.Request.Context()) })
+	req, err := newBridgedRequest(context.Background(), "claude", []byte(`{"model":"m"}`), false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.serveBridged(httptest.NewRecorder(), req, "org-a")
+	if got != "org-a" {
+		t.Fatalf("virtual host = %q, want org-a", got)
+	}
+}
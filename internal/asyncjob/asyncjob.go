@@ -0,0 +1,623 @@
+// Package asyncjob runs generation requests in the background, for runs that outlast HTTP
+// and edge timeouts and for the Message Batches API. A submitted job is polled, or announced
+// to a webhook when it ends, and its result is fetched afterwards; a batch is a group of jobs
+// reported together. While the job API is enabled every job is persisted as a JSON file, so
+// jobs still queued or running when the proxy stops run again once it restarts; otherwise
+// batches are kept in memory.
+package asyncjob
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/offline"
+	log "github.com/sirupsen/logrus"
+	"github.com/tidwall/gjson"
+)
+
+const (
+	// IDPrefix starts the ids of jobs.
+	IDPrefix = "job_"
+	// SignatureHeader carries the HMAC-SHA256 signature of webhook payloads.
+	SignatureHeader = "X-CLIProxy-Signature"
+	// maxAttempts is how many runs a job gets before restarts interrupting it fail it.
+	maxAttempts = 3
+	// sweepInterval is how often ended jobs past their retention are removed.
+	sweepInterval = time.Minute
+	// webhookAttempts is how often a webhook delivery is tried.
+	webhookAttempts = 3
+	// fileSuffix ends the names of job files.
+	fileSuffix = ".json"
+)
+
+// Statuses of a job.
+const (
+	StatusQueued    = "queued"
+	StatusRunning   = "running"
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed"
+	StatusCanceled  = "canceled"
+	// StatusExpired ends batch jobs that had not run when their batch expired.
+	StatusExpired = "expired"
+)
+
+var (
+	// ErrDisabled is returned for submissions while the job API is disabled.
+	ErrDisabled = errors.New("async jobs are disabled")
+	// ErrNotFound is returned for unknown job ids and jobs of other owners.
+	ErrNotFound = errors.New("job not found")
+	// ErrNotEnded is returned when the result or deletion of a job is requested before it ends.
+	ErrNotEnded = errors.New("job has not ended")
+)
+
+// ExecuteFunc runs the request of a job and returns the HTTP status and body of its response.
+type ExecuteFunc func(ctx context.Context, job Job) (int, []byte, error)
+
+// Job is a submitted generation request and, once it ended, its response.
+type Job struct {
+	ID          string          `json:"id"`
+	Owner       string          `json:"owner"`
+	VirtualHost string          `json:"virtual_host,omitempty"`
+	Dialect     string          `json:"dialect"`
+	Body        json.RawMessage `json:"body"`
+	Header      http.Header     `json:"header,omitempty"`
+	WebhookURL  string          `json:"webhook_url,omitempty"`
+	// Batch, CustomID and Index place a job in its message batch; Batch is empty for jobs
+	// of the job API.
+	Batch    string `json:"batch,omitempty"`
+	CustomID string `json:"custom_id,omitempty"`
+	Index    int    `json:"index,omitempty"`
+	// ExpiresAt ends the job as expired when it has not run by then.
+	ExpiresAt         *time.Time `json:"expires_at,omitempty"`
+	CancelRequestedAt *time.Time `json:"cancel_requested_at,omitempty"`
+	Status            string     `json:"status"`
+	Attempts          int        `json:"attempts"`
+	ResultStatus      int        `json:"result_status,omitempty"`
+	Result            []byte     `json:"result,omitempty"`
+	Error             string     `json:"error,omitempty"`
+	CreatedAt         time.Time  `json:"created_at"`
+	StartedAt         *time.Time `json:"started_at,omitempty"`
+	EndedAt           *time.Time `json:"ended_at,omitempty"`
+}
+
+// Ended reports whether j reached a final status.
+func (j Job) Ended() bool {
+	return j.Status == StatusSucceeded || j.Status == StatusFailed || j.Status == StatusCanceled || j.Status == StatusExpired
+}
+
+// Summary renders the client-visible fields of j; the request, its headers and the result
+// are left out.
+func (j Job) Summary() map[string]any {
+	out := map[string]any{
+		"id":            j.ID,
+		"object":        "job",
+		"dialect":       j.Dialect,
+		"model":         gjson.GetBytes(j.Body, "model").String(),
+		"status":        j.Status,
+		"attempts":      j.Attempts,
+		"created_at":    j.CreatedAt.UTC().Format(time.RFC3339),
+		"started_at":    nil,
+		"ended_at":      nil,
+		"result_status": nil,
+		"error":         nil,
+	}
+	if j.StartedAt != nil {
+		out["started_at"] = j.StartedAt.UTC().Format(time.RFC3339)
+	}
+	if j.EndedAt != nil {
+		out["ended_at"] = j.EndedAt.UTC().Format(time.RFC3339)
+	}
+	if j.ResultStatus != 0 {
+		out["result_status"] = j.ResultStatus
+	}
+	if j.Error != "" {
+		out["error"] = j.Error
+	}
+	return out
+}
+
+// expired reports whether the batch of j expired at now.
+func (j Job) expired(now time.Time) bool {
+	return j.ExpiresAt != nil && !now.Before(*j.ExpiresAt)
+}
+
+type entry struct {
+	job      Job
+	cancel   context.CancelFunc
+	canceled bool
+}
+
+// Store runs jobs and, while the job API is enabled, persists them to a directory.
+type Store struct {
+	mu      sync.Mutex
+	cfg     config.AsyncJobsConfig
+	dir     string
+	jobs    map[string]*entry
+	batches map[string]*batchEntry
+	queue   []string
+	running int
+	execute ExecuteFunc
+	ctx     context.Context
+	stop    context.CancelFunc
+	client  *http.Client
+	now     func() time.Time
+}
+
+// NewStore constructs a store executing jobs with execute. It runs batches in memory until
+// Configure enables the job API.
+func NewStore(execute ExecuteFunc) *Store {
+	s := &Store{
+		execute: execute,
+		client:  offline.Default().Client(&http.Client{Timeout: 10 * time.Second}),
+		now:     time.Now,
+	}
+	s.startLocked("")
+	return s
+}
+
+// Configure applies cfg. fallbackDir is used when cfg.Dir is empty. Enabling the job API
+// persists the batches kept in memory so far, loads the persisted jobs and runs the pending
+// ones again; moving it to another directory or disabling it interrupts the running jobs,
+// which stay persisted in the old directory.
+func (s *Store) Configure(cfg config.AsyncJobsConfig, fallbackDir string) {
+	if s == nil {
+		return
+	}
+	var dir string
+	if cfg.Enable {
+		dir = cfg.Dir
+		if dir == "" {
+			dir = fallbackDir
+		}
+		if dir != "" && !filepath.IsAbs(dir) {
+			if abs, err := filepath.Abs(dir); err == nil {
+				dir = abs
+			}
+		}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cfg = cfg
+	switch {
+	case s.stop == nil:
+		s.startLocked(dir)
+	case dir == s.dir:
+		// Same directory: a higher concurrency may start queued jobs.
+		s.dispatchLocked()
+	case s.dir == "":
+		s.attachLocked(dir)
+	default:
+		s.stopLocked()
+		s.startLocked(dir)
+	}
+}
+
+func (s *Store) startLocked(dir string) {
+	s.jobs = make(map[string]*entry)
+	s.batches = make(map[string]*batchEntry)
+	s.ctx, s.stop = context.WithCancel(context.Background())
+	go s.sweep(s.ctx)
+	s.attachLocked(dir)
+}
+
+// attachLocked starts persisting to dir, writing the jobs kept in memory so far and loading
+// the jobs persisted there. Without dir, or when it cannot be created, jobs stay in memory.
+func (s *Store) attachLocked(dir string) {
+	if dir == "" {
+		s.dispatchLocked()
+		return
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		log.Errorf("async jobs: create %s: %v", dir, err)
+		s.dispatchLocked()
+		return
+	}
+	s.dir = dir
+	for _, e := range s.jobs {
+		s.persistLocked(e.job)
+	}
+	s.loadLocked()
+}
+
+// Stop interrupts the running jobs and halts the store. Interrupted jobs stay persisted as
+// running and run again when the store is enabled next.
+func (s *Store) Stop() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stopLocked()
+}
+
+func (s *Store) stopLocked() {
+	if s.stop == nil {
+		return
+	}
+	s.stop()
+	s.stop = nil
+	s.jobs = make(map[string]*entry)
+	s.batches = make(map[string]*batchEntry)
+	s.queue = nil
+	s.running = 0
+	s.dir = ""
+}
+
+// Enabled reports whether the job API accepts jobs.
+func (s *Store) Enabled() bool {
+	if s == nil {
+		return false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.stop != nil && s.cfg.Enable && s.dir != ""
+}
+
+// AllowsClientWebhooks reports whether submitted jobs may name their own webhook.
+func (s *Store) AllowsClientWebhooks() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.cfg.AllowClientWebhooks
+}
+
+// loadLocked reads the persisted jobs not known yet. Jobs interrupted while running are
+// queued again unless they used up their attempts.
+func (s *Store) loadLocked() {
+	files, err := os.ReadDir(s.dir)
+	if err != nil {
+		log.Errorf("async jobs: read %s: %v", s.dir, err)
+		return
+	}
+	var pending []*entry
+	for _, file := range files {
+		if file.IsDir() || !strings.HasSuffix(file.Name(), fileSuffix) {
+			continue
+		}
+		data, errRead := os.ReadFile(filepath.Join(s.dir, file.Name()))
+		if errRead != nil {
+			log.Warnf("async jobs: read %s: %v", file.Name(), errRead)
+			continue
+		}
+		var j Job
+		if errUnmarshal := json.Unmarshal(data, &j); errUnmarshal != nil || j.ID+fileSuffix != file.Name() {
+			log.Warnf("async jobs: skip malformed job file %s", file.Name())
+			continue
+		}
+		if _, known := s.jobs[j.ID]; known {
+			continue
+		}
+		e := &entry{job: j}
+		s.jobs[j.ID] = e
+		s.indexBatchLocked(j)
+		switch j.Status {
+		case StatusRunning:
+			if j.Attempts >= maxAttempts {
+				s.endLocked(e, StatusFailed, 0, nil, fmt.Sprintf("interrupted by restarts %d times", j.Attempts))
+				continue
+			}
+			e.job.Status = StatusQueued
+			s.persistLocked(e.job)
+			pending = append(pending, e)
+		case StatusQueued:
+			pending = append(pending, e)
+		}
+	}
+	sort.Slice(pending, func(a, b int) bool { return pending[a].job.CreatedAt.Before(pending[b].job.CreatedAt) })
+	for _, e := range pending {
+		s.queue = append(s.queue, e.job.ID)
+	}
+	if len(pending) > 0 {
+		log.Infof("async jobs: resuming %d pending jobs", len(pending))
+	}
+	s.sortBatchesLocked()
+	s.sweepLocked()
+	s.dispatchLocked()
+}
+
+// Submit persists a job for owner and queues it. header and the virtual host the job was
+// submitted through are stored with the job and replayed on every run.
+func (s *Store) Submit(owner, virtualHost, dialect string, body []byte, header http.Header, webhookURL string) (Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.stop == nil || !s.cfg.Enable || s.dir == "" {
+		return Job{}, ErrDisabled
+	}
+	j := Job{
+		ID:          IDPrefix + uuid.NewString(),
+		Owner:       owner,
+		VirtualHost: virtualHost,
+		Dialect:     dialect,
+		Body:        append(json.RawMessage(nil), body...),
+		Header:      header.Clone(),
+		WebhookURL:  webhookURL,
+		Status:      StatusQueued,
+		CreatedAt:   s.now(),
+	}
+	if err := s.persistLocked(j); err != nil {
+		return Job{}, err
+	}
+	s.jobs[j.ID] = &entry{job: j}
+	s.queue = append(s.queue, j.ID)
+	s.dispatchLocked()
+	return s.jobs[j.ID].job, nil
+}
+
+// dispatchLocked starts queued jobs while fewer than the configured concurrency run.
+func (s *Store) dispatchLocked() {
+	concurrency := s.cfg.Concurrency
+	if concurrency <= 0 {
+		concurrency = config.DefaultAsyncJobConcurrency
+	}
+	for len(s.queue) > 0 && s.running < concurrency {
+		id := s.queue[0]
+		s.queue = s.queue[1:]
+		e := s.jobs[id]
+		if e == nil || e.job.Status != StatusQueued {
+			continue
+		}
+		if e.job.expired(s.now()) {
+			s.endLocked(e, StatusExpired, 0, nil, "")
+			continue
+		}
+		timeout := time.Duration(s.cfg.TimeoutSeconds) * time.Second
+		if timeout <= 0 {
+			timeout = config.DefaultAsyncJobTimeoutSeconds * time.Second
+		}
+		deadline := time.Now().Add(timeout)
+		if e.job.ExpiresAt != nil && e.job.ExpiresAt.Before(deadline) {
+			deadline = *e.job.ExpiresAt
+		}
+		ctx, cancel := context.WithDeadline(s.ctx, deadline)
+		now := s.now()
+		e.cancel = cancel
+		e.job.Status = StatusRunning
+		e.job.StartedAt = &now
+		e.job.Attempts++
+		s.persistLocked(e.job)
+		s.running++
+		go s.run(s.ctx, ctx, e)
+	}
+}
+
+// run executes a job. When the store stopped meanwhile the job is left as it is persisted.
+func (s *Store) run(storeCtx, ctx context.Context, e *entry) {
+	s.mu.Lock()
+	job := e.job
+	s.mu.Unlock()
+	status, body, err := s.execute(ctx, job)
+	timedOut := errors.Is(ctx.Err(), context.DeadlineExceeded)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e.cancel()
+	if storeCtx.Err() != nil {
+		return
+	}
+	s.running--
+	switch {
+	case e.canceled:
+		s.endLocked(e, StatusCanceled, 0, nil, "")
+	case timedOut && e.job.expired(s.now()):
+		s.endLocked(e, StatusExpired, 0, nil, "")
+	case timedOut:
+		s.endLocked(e, StatusFailed, status, body, "job exceeded the run timeout")
+	case err != nil:
+		s.endLocked(e, StatusFailed, status, body, err.Error())
+	case status >= 200 && status < 300:
+		s.endLocked(e, StatusSucceeded, status, body, "")
+	default:
+		s.endLocked(e, StatusFailed, status, body, "")
+	}
+	s.dispatchLocked()
+}
+
+// endLocked records the final status of a job, persists it and posts the webhook.
+func (s *Store) endLocked(e *entry, status string, resultStatus int, result []byte, errText string) {
+	now := s.now()
+	e.job.Status = status
+	e.job.ResultStatus = resultStatus
+	e.job.Result = result
+	e.job.Error = errText
+	e.job.EndedAt = &now
+	s.persistLocked(e.job)
+	if e.job.Batch != "" {
+		// Batches are polled; their requests announce nothing.
+		return
+	}
+	url := s.cfg.WebhookURL
+	if e.job.WebhookURL != "" {
+		url = e.job.WebhookURL
+	}
+	if url != "" {
+		go s.notify(url, s.cfg.WebhookSecret, e.job.Summary())
+	}
+}
+
+// persistLocked writes j to its file; without a directory jobs are kept in memory only.
+func (s *Store) persistLocked(j Job) error {
+	if s.dir == "" {
+		return nil
+	}
+	data, err := json.Marshal(j)
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(s.dir, j.ID+fileSuffix)
+	tmp := path + ".tmp"
+	if err = os.WriteFile(tmp, data, 0o600); err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		log.Errorf("async jobs: persist %s: %v", j.ID, err)
+		_ = os.Remove(tmp)
+	}
+	return err
+}
+
+func (s *Store) removeLocked(id string) {
+	delete(s.jobs, id)
+	if s.dir == "" {
+		return
+	}
+	if err := os.Remove(filepath.Join(s.dir, id+fileSuffix)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Warnf("async jobs: remove %s: %v", id, err)
+	}
+}
+
+func (s *Store) sweep(ctx context.Context) {
+	ticker := time.NewTicker(sweepInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.mu.Lock()
+			if ctx.Err() == nil {
+				s.sweepLocked()
+			}
+			s.mu.Unlock()
+		}
+	}
+}
+
+// sweepLocked removes ended jobs past their retention and expires the queued jobs of
+// expired batches. Batches are kept until they are deleted or evicted.
+func (s *Store) sweepLocked() {
+	retention := time.Duration(s.cfg.RetentionHours) * time.Hour
+	if retention <= 0 {
+		retention = config.DefaultAsyncJobRetentionHours * time.Hour
+	}
+	now := s.now()
+	cutoff := now.Add(-retention)
+	for id, e := range s.jobs {
+		switch {
+		case e.job.Status == StatusQueued && e.job.expired(now):
+			s.endLocked(e, StatusExpired, 0, nil, "")
+		case e.job.Batch == "" && e.job.EndedAt != nil && e.job.EndedAt.Before(cutoff):
+			s.removeLocked(id)
+		}
+	}
+}
+
+func (s *Store) notify(url, secret string, summary map[string]any) {
+	payload, err := json.Marshal(map[string]any{"event": "job.ended", "job": summary})
+	if err != nil {
+		return
+	}
+	for attempt := 1; attempt <= webhookAttempts; attempt++ {
+		req, errReq := http.NewRequest(http.MethodPost, url, bytes.NewReader(payload))
+		if errReq != nil {
+			log.Errorf("async jobs: invalid webhook url: %v", errReq)
+			return
+		}
+		req.Header.Set("Content-Type", "application/json")
+		if secret != "" {
+			mac := hmac.New(sha256.New, []byte(secret))
+			mac.Write(payload)
+			req.Header.Set(SignatureHeader, "sha256="+hex.EncodeToString(mac.Sum(nil)))
+		}
+		resp, errDo := s.client.Do(req)
+		if errDo == nil {
+			_ = resp.Body.Close()
+			if resp.StatusCode < 300 {
+				return
+			}
+			errDo = fmt.Errorf("status %d", resp.StatusCode)
+		}
+		if attempt == webhookAttempts {
+			log.Errorf("async jobs: webhook delivery for %v failed: %v", summary["id"], errDo)
+			return
+		}
+		time.Sleep(time.Duration(attempt) * time.Second)
+	}
+}
+
+// lookupLocked returns the job id of owner; the jobs of batches are not served by the job
+// API.
+func (s *Store) lookupLocked(owner, id string) (*entry, error) {
+	e, ok := s.jobs[id]
+	if !ok || e.job.Owner != owner || e.job.Batch != "" {
+		return nil, ErrNotFound
+	}
+	return e, nil
+}
+
+// Get returns the job id of owner.
+func (s *Store) Get(owner, id string) (Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, err := s.lookupLocked(owner, id)
+	if err != nil {
+		return Job{}, err
+	}
+	return e.job, nil
+}
+
+// List returns the jobs of owner, newest first.
+func (s *Store) List(owner string) []Job {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out []Job
+	for _, e := range s.jobs {
+		if e.job.Owner == owner && e.job.Batch == "" {
+			out = append(out, e.job)
+		}
+	}
+	sort.Slice(out, func(a, b int) bool { return out[a].CreatedAt.After(out[b].CreatedAt) })
+	return out
+}
+
+// Cancel stops a job: a queued job is canceled at once and a running one is interrupted.
+// Ended jobs are returned unchanged.
+func (s *Store) Cancel(owner, id string) (Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, err := s.lookupLocked(owner, id)
+	if err != nil {
+		return Job{}, err
+	}
+	s.cancelLocked(e)
+	return e.job, nil
+}
+
+// cancelLocked cancels a queued job at once and interrupts a running one.
+func (s *Store) cancelLocked(e *entry) {
+	switch e.job.Status {
+	case StatusQueued:
+		s.endLocked(e, StatusCanceled, 0, nil, "")
+	case StatusRunning:
+		e.canceled = true
+		e.cancel()
+	}
+}
+
+// Delete removes an ended job and its result.
+func (s *Store) Delete(owner, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, err := s.lookupLocked(owner, id)
+	if err != nil {
+		return err
+	}
+	if !e.job.Ended() {
+		return ErrNotEnded
+	}
+	s.removeLocked(id)
+	return nil
+}
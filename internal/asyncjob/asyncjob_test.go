@@ -0,0 +1,134 @@
+package asyncjob
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/tidwall/gjson"
+)
+
+func waitFor(t *testing.T, s *Store, owner, id string, status string) Job {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		j, err := s.Get(owner, id)
+		if err == nil && j.Status == status {
+			return j
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("job %s = %+v, %v; want status %s", id, j, err, status)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestStoreResumesPendingJobsAfterRestart(t *testing.T) {
+	dir := t.TempDir()
+	cfg := config.AsyncJobsConfig{Enable: true, Dir: dir, Concurrency: 1, TimeoutSeconds: 60, RetentionHours: 1}
+	started := make(chan struct{}, 1)
+	blocking := NewStore(func(ctx context.Context, job Job) (int, []byte, error) {
+		started <- struct{}{}
+		<-ctx.Done()
+		return 0, nil, ctx.Err()
+	})
+	blocking.Configure(cfg, "")
+	first, err := blocking.Submit("key", "", "openai", []byte(`{"model":"m"}`), http.Header{"Authorization": {"Bearer key"}}, "")
+	if err != nil {
+		t.Fatalf("Submit: %v", err)
+	}
+	second, _ := blocking.Submit("key", "", "openai", []byte(`{"model":"m"}`), nil, "")
+	<-started
+	blocking.Stop()
+
+	var authorization string
+	resumed := NewStore(func(ctx context.Context, job Job) (int, []byte, error) {
+		if job.ID == first.ID {
+			authorization = job.Header.Get("Authorization")
+		}
+		return http.StatusOK, []byte(`{"id":"` + job.ID + `"}`), nil
+	})
+	resumed.Configure(cfg, "")
+	defer resumed.Stop()
+	j := waitFor(t, resumed, "key", first.ID, StatusSucceeded)
+	if j.Attempts != 2 || gjson.GetBytes(j.Result, "id").String() != first.ID {
+		t.Fatalf("resumed job = %+v", j)
+	}
+	if authorization != "Bearer key" {
+		t.Fatalf("resumed job header = %q", authorization)
+	}
+	if j = waitFor(t, resumed, "key", second.ID, StatusSucceeded); j.Attempts != 1 {
+		t.Fatalf("queued job attempts = %d, want 1", j.Attempts)
+	}
+	if _, err = resumed.Get("other", first.ID); err != ErrNotFound {
+		t.Fatalf("job of another owner: %v", err)
+	}
+}
+
+func TestStoreFailsJobsInterruptedTooOften(t *testing.T) {
+	dir := t.TempDir()
+	cfg := config.AsyncJobsConfig{Enable: true, Dir: dir, Concurrency: 1, TimeoutSeconds: 60, RetentionHours: 1}
+	s := NewStore(nil)
+	s.Configure(cfg, "")
+	now := time.Now()
+	if err := s.persistLocked(Job{ID: IDPrefix + "x", Owner: "key", Status: StatusRunning, Attempts: maxAttempts, CreatedAt: now, StartedAt: &now}); err != nil {
+		t.Fatalf("persist: %v", err)
+	}
+	s.Stop()
+	s.Configure(cfg, "")
+	defer s.Stop()
+	if j, _ := s.Get("key", IDPrefix+"x"); j.Status != StatusFailed || j.Error == "" {
+		t.Fatalf("exhausted job = %+v", j)
+	}
+}
+
+func TestStoreCancelsAndNotifiesWebhook(t *testing.T) {
+	payloads := make(chan []byte, 1)
+	var signature string
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		signature = r.Header.Get(SignatureHeader)
+		payloads <- body
+	}))
+	defer hook.Close()
+
+	s := NewStore(func(ctx context.Context, job Job) (int, []byte, error) {
+		<-ctx.Done()
+		return 0, nil, ctx.Err()
+	})
+	s.Configure(config.AsyncJobsConfig{Enable: true, Dir: t.TempDir(), Concurrency: 1, TimeoutSeconds: 60, RetentionHours: 1, WebhookURL: hook.URL, WebhookSecret: "s3cret"}, "")
+	defer s.Stop()
+	j, _ := s.Submit("key", "", "claude", []byte(`{"model":"m"}`), nil, "")
+	waitFor(t, s, "key", j.ID, StatusRunning)
+	if err := s.Delete("key", j.ID); err != ErrNotEnded {
+		t.Fatalf("Delete of a running job: %v", err)
+	}
+	if _, err := s.Cancel("key", j.ID); err != nil {
+		t.Fatalf("Cancel: %v", err)
+	}
+	waitFor(t, s, "key", j.ID, StatusCanceled)
+
+	select {
+	case payload := <-payloads:
+		if gjson.GetBytes(payload, "job.id").String() != j.ID || gjson.GetBytes(payload, "job.status").String() != StatusCanceled {
+			t.Fatalf("webhook payload = %s", payload)
+		}
+		mac := hmac.New(sha256.New, []byte("s3cret"))
+		mac.Write(payload)
+		if signature != "sha256="+hex.EncodeToString(mac.Sum(nil)) {
+			t.Fatalf("signature = %q", signature)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("webhook not called")
+	}
+	if err := s.Delete("key", j.ID); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+}
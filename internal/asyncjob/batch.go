@@ -0,0 +1,317 @@
+package asyncjob
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// maxBatches bounds how many batches are kept; the oldest ended ones are evicted first
+	// and new batches are refused while this many are unfinished.
+	maxBatches = 100
+	// MaxBatchRequests is the largest number of requests in one batch.
+	MaxBatchRequests = 10000
+	// maxCustomIDLength is the longest custom id of a request.
+	maxCustomIDLength = 64
+	// batchExpiry is how long a batch may process before its remaining requests expire.
+	batchExpiry = 24 * time.Hour
+)
+
+// Processing states of a batch.
+const (
+	BatchInProgress = "in_progress"
+	BatchCanceling  = "canceling"
+	BatchEnded      = "ended"
+)
+
+// ErrTooManyBatches is returned when a batch is submitted while maxBatches are unfinished.
+var ErrTooManyBatches = errors.New("too many message batches are processing")
+
+// BatchRequest is one request of a batch.
+type BatchRequest struct {
+	CustomID string
+	Body     []byte
+}
+
+// BatchCounts tallies the requests of a batch by state.
+type BatchCounts struct {
+	Processing int
+	Succeeded  int
+	Errored    int
+	Canceled   int
+	Expired    int
+}
+
+// Batch is a snapshot of a batch.
+type Batch struct {
+	ID                string
+	Status            string
+	Counts            BatchCounts
+	CreatedAt         time.Time
+	ExpiresAt         time.Time
+	EndedAt           *time.Time
+	CancelInitiatedAt *time.Time
+}
+
+// batchEntry indexes the jobs of a batch in request order.
+type batchEntry struct {
+	owner     string
+	createdAt time.Time
+	jobs      []string
+}
+
+// ValidateBatch checks the requests of a new batch: at least one, at most
+// MaxBatchRequests, and custom ids of 1 to 64 characters that are unique within the batch.
+func ValidateBatch(requests []BatchRequest) error {
+	if len(requests) == 0 {
+		return errors.New("requests must contain at least one request")
+	}
+	if len(requests) > MaxBatchRequests {
+		return fmt.Errorf("requests must contain at most %d requests", MaxBatchRequests)
+	}
+	seen := make(map[string]bool, len(requests))
+	for i, request := range requests {
+		if request.CustomID == "" || len(request.CustomID) > maxCustomIDLength {
+			return fmt.Errorf("requests.%d.custom_id must be 1 to %d characters", i, maxCustomIDLength)
+		}
+		if seen[request.CustomID] {
+			return fmt.Errorf("requests.%d.custom_id %q is not unique", i, request.CustomID)
+		}
+		seen[request.CustomID] = true
+	}
+	return nil
+}
+
+// SubmitBatch validates requests and queues them for owner as one batch of dialect
+// requests sharing header. The oldest ended batches are evicted to stay within maxBatches.
+func (s *Store) SubmitBatch(owner, virtualHost, dialect, idPrefix string, requests []BatchRequest, header http.Header) (Batch, error) {
+	if err := ValidateBatch(requests); err != nil {
+		return Batch{}, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.stop == nil {
+		return Batch{}, ErrDisabled
+	}
+	s.evictBatchesLocked()
+	if len(s.batches) >= maxBatches {
+		return Batch{}, ErrTooManyBatches
+	}
+	now := s.now()
+	expiresAt := now.Add(batchExpiry)
+	id := idPrefix + uuid.NewString()
+	b := &batchEntry{owner: owner, createdAt: now, jobs: make([]string, 0, len(requests))}
+	for i, request := range requests {
+		j := Job{
+			ID:          IDPrefix + uuid.NewString(),
+			Owner:       owner,
+			VirtualHost: virtualHost,
+			Dialect:     dialect,
+			Body:        append([]byte(nil), request.Body...),
+			Header:      header.Clone(),
+			Batch:       id,
+			CustomID:    request.CustomID,
+			Index:       i,
+			ExpiresAt:   &expiresAt,
+			Status:      StatusQueued,
+			CreatedAt:   now,
+		}
+		if err := s.persistLocked(j); err != nil {
+			for _, jobID := range b.jobs {
+				s.removeLocked(jobID)
+			}
+			return Batch{}, err
+		}
+		s.jobs[j.ID] = &entry{job: j}
+		b.jobs = append(b.jobs, j.ID)
+	}
+	s.batches[id] = b
+	s.queue = append(s.queue, b.jobs...)
+	s.dispatchLocked()
+	return s.batchLocked(id, b), nil
+}
+
+// indexBatchLocked adds a loaded job to the index of its batch.
+func (s *Store) indexBatchLocked(j Job) {
+	if j.Batch == "" {
+		return
+	}
+	b := s.batches[j.Batch]
+	if b == nil {
+		b = &batchEntry{owner: j.Owner, createdAt: j.CreatedAt}
+		s.batches[j.Batch] = b
+	}
+	b.jobs = append(b.jobs, j.ID)
+}
+
+// sortBatchesLocked puts the jobs of every batch back in request order after a load.
+func (s *Store) sortBatchesLocked() {
+	for _, b := range s.batches {
+		sort.SliceStable(b.jobs, func(i, k int) bool {
+			return s.jobs[b.jobs[i]].job.Index < s.jobs[b.jobs[k]].job.Index
+		})
+	}
+}
+
+// batchLocked derives the snapshot of batch id from the states of its jobs.
+func (s *Store) batchLocked(id string, b *batchEntry) Batch {
+	out := Batch{ID: id, Status: BatchEnded, CreatedAt: b.createdAt}
+	for _, jobID := range b.jobs {
+		j := s.jobs[jobID].job
+		if j.ExpiresAt != nil {
+			out.ExpiresAt = *j.ExpiresAt
+		}
+		if j.CancelRequestedAt != nil {
+			out.CancelInitiatedAt = j.CancelRequestedAt
+		}
+		switch j.Status {
+		case StatusSucceeded:
+			out.Counts.Succeeded++
+		case StatusFailed:
+			out.Counts.Errored++
+		case StatusCanceled:
+			out.Counts.Canceled++
+		case StatusExpired:
+			out.Counts.Expired++
+		default:
+			out.Counts.Processing++
+		}
+		if j.EndedAt != nil && (out.EndedAt == nil || j.EndedAt.After(*out.EndedAt)) {
+			out.EndedAt = j.EndedAt
+		}
+	}
+	switch {
+	case out.Counts.Processing == 0:
+	case out.CancelInitiatedAt != nil:
+		out.Status, out.EndedAt = BatchCanceling, nil
+	default:
+		out.Status, out.EndedAt = BatchInProgress, nil
+	}
+	return out
+}
+
+// evictBatchesLocked drops the oldest ended batches until fewer than maxBatches are kept.
+func (s *Store) evictBatchesLocked() {
+	if len(s.batches) < maxBatches {
+		return
+	}
+	var ended []Batch
+	for id, b := range s.batches {
+		if snapshot := s.batchLocked(id, b); snapshot.Status == BatchEnded {
+			ended = append(ended, snapshot)
+		}
+	}
+	sort.Slice(ended, func(i, k int) bool { return ended[i].CreatedAt.Before(ended[k].CreatedAt) })
+	for _, b := range ended {
+		if len(s.batches) < maxBatches {
+			return
+		}
+		s.removeBatchLocked(b.ID)
+	}
+}
+
+func (s *Store) removeBatchLocked(id string) {
+	for _, jobID := range s.batches[id].jobs {
+		s.removeLocked(jobID)
+	}
+	delete(s.batches, id)
+}
+
+func (s *Store) lookupBatchLocked(owner, id string) (*batchEntry, error) {
+	b, ok := s.batches[id]
+	if !ok || b.owner != owner {
+		return nil, ErrNotFound
+	}
+	return b, nil
+}
+
+// GetBatch returns the batch id of owner.
+func (s *Store) GetBatch(owner, id string) (Batch, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b, err := s.lookupBatchLocked(owner, id)
+	if err != nil {
+		return Batch{}, err
+	}
+	return s.batchLocked(id, b), nil
+}
+
+// ListBatches returns the batches of owner, newest first.
+func (s *Store) ListBatches(owner string) []Batch {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out []Batch
+	for id, b := range s.batches {
+		if b.owner == owner {
+			out = append(out, s.batchLocked(id, b))
+		}
+	}
+	sort.Slice(out, func(i, k int) bool { return out[i].CreatedAt.After(out[k].CreatedAt) })
+	return out
+}
+
+// CancelBatch stops a batch: requests not started yet are canceled and in-flight ones are
+// interrupted. Ended batches are returned unchanged.
+func (s *Store) CancelBatch(owner, id string) (Batch, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b, err := s.lookupBatchLocked(owner, id)
+	if err != nil {
+		return Batch{}, err
+	}
+	if s.batchLocked(id, b).Status != BatchInProgress {
+		return s.batchLocked(id, b), nil
+	}
+	now := s.now()
+	for _, jobID := range b.jobs {
+		e := s.jobs[jobID]
+		if e.job.Ended() {
+			continue
+		}
+		e.job.CancelRequestedAt = &now
+		if e.job.Status == StatusRunning {
+			// Queued jobs are persisted when they end below.
+			_ = s.persistLocked(e.job)
+		}
+		s.cancelLocked(e)
+	}
+	return s.batchLocked(id, b), nil
+}
+
+// BatchResults returns the jobs of an ended batch in request order.
+func (s *Store) BatchResults(owner, id string) ([]Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b, err := s.lookupBatchLocked(owner, id)
+	if err != nil {
+		return nil, err
+	}
+	if s.batchLocked(id, b).Status != BatchEnded {
+		return nil, ErrNotEnded
+	}
+	out := make([]Job, 0, len(b.jobs))
+	for _, jobID := range b.jobs {
+		out = append(out, s.jobs[jobID].job)
+	}
+	return out, nil
+}
+
+// DeleteBatch removes an ended batch and its results.
+func (s *Store) DeleteBatch(owner, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b, err := s.lookupBatchLocked(owner, id)
+	if err != nil {
+		return err
+	}
+	if s.batchLocked(id, b).Status != BatchEnded {
+		return ErrNotEnded
+	}
+	s.removeBatchLocked(id)
+	return nil
+}
@@ -0,0 +1,186 @@
+package asyncjob
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+func waitBatchEnded(t *testing.T, s *Store, owner, id string) Batch {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		b, err := s.GetBatch(owner, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if b.Status == BatchEnded {
+			return b
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatal("batch did not end")
+	return Batch{}
+}
+
+func TestBatchRunsRequestsAndKeepsResults(t *testing.T) {
+	s := NewStore(func(_ context.Context, job Job) (int, []byte, error) {
+		if string(job.Body) == "fail" {
+			return http.StatusBadRequest, []byte(`{"error":"bad"}`), nil
+		}
+		return http.StatusOK, append([]byte("ok:"), job.Body...), nil
+	})
+	defer s.Stop()
+	created, err := s.SubmitBatch("key-a", "", "claude", "msgbatch_", []BatchRequest{{CustomID: "a", Body: []byte("one")}, {CustomID: "b", Body: []byte("fail")}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if created.Status != BatchInProgress || created.Counts.Processing+created.Counts.Succeeded+created.Counts.Errored != 2 {
+		t.Fatalf("created = %+v", created)
+	}
+	if _, err = s.GetBatch("key-b", created.ID); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("other owners must not see the batch, got %v", err)
+	}
+
+	ended := waitBatchEnded(t, s, "key-a", created.ID)
+	if ended.Counts != (BatchCounts{Succeeded: 1, Errored: 1}) || ended.EndedAt == nil {
+		t.Fatalf("ended = %+v", ended)
+	}
+	results, err := s.BatchResults("key-a", created.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if results[0].CustomID != "a" || results[0].Status != StatusSucceeded || string(results[0].Result) != "ok:one" {
+		t.Fatalf("result a = %+v", results[0])
+	}
+	if results[1].Status != StatusFailed || results[1].ResultStatus != http.StatusBadRequest {
+		t.Fatalf("result b = %+v", results[1])
+	}
+	if len(s.List("key-a")) != 0 {
+		t.Fatal("batch requests must not be listed as jobs")
+	}
+
+	if err = s.DeleteBatch("key-a", created.ID); err != nil {
+		t.Fatal(err)
+	}
+	if len(s.ListBatches("key-a")) != 0 {
+		t.Fatal("deleted batch still listed")
+	}
+}
+
+func TestBatchCancelStopsPendingRequests(t *testing.T) {
+	started := make(chan struct{}, 1)
+	s := NewStore(func(ctx context.Context, _ Job) (int, []byte, error) {
+		started <- struct{}{}
+		<-ctx.Done()
+		return 0, nil, ctx.Err()
+	})
+	defer s.Stop()
+	s.Configure(config.AsyncJobsConfig{Concurrency: 1}, "")
+	created, err := s.SubmitBatch("", "", "claude", "b_", []BatchRequest{{CustomID: "a"}, {CustomID: "b"}, {CustomID: "c"}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-started
+	if _, err = s.BatchResults("", created.ID); !errors.Is(err, ErrNotEnded) {
+		t.Fatalf("results before end = %v", err)
+	}
+	canceled, err := s.CancelBatch("", created.ID)
+	if err != nil || canceled.Status != BatchCanceling || canceled.CancelInitiatedAt == nil {
+		t.Fatalf("cancel = %+v, %v", canceled, err)
+	}
+	ended := waitBatchEnded(t, s, "", created.ID)
+	if ended.Counts != (BatchCounts{Canceled: 3}) {
+		t.Fatalf("counts = %+v", ended.Counts)
+	}
+}
+
+func TestBatchesAreBounded(t *testing.T) {
+	release := make(chan struct{})
+	s := NewStore(func(ctx context.Context, _ Job) (int, []byte, error) {
+		select {
+		case <-release:
+			return http.StatusOK, []byte(`{}`), nil
+		case <-ctx.Done():
+			return 0, nil, ctx.Err()
+		}
+	})
+	defer s.Stop()
+	s.Configure(config.AsyncJobsConfig{Concurrency: maxBatches}, "")
+	var first string
+	for i := 0; i < maxBatches; i++ {
+		b, err := s.SubmitBatch("", "", "claude", "b_", []BatchRequest{{CustomID: strconv.Itoa(i)}}, nil)
+		if err != nil {
+			t.Fatalf("batch %d: %v", i, err)
+		}
+		if i == 0 {
+			first = b.ID
+		}
+	}
+	if _, err := s.SubmitBatch("", "", "claude", "b_", []BatchRequest{{CustomID: "x"}}, nil); !errors.Is(err, ErrTooManyBatches) {
+		t.Fatalf("submit beyond the unfinished bound = %v", err)
+	}
+
+	close(release)
+	waitBatchEnded(t, s, "", first)
+	for _, b := range s.ListBatches("") {
+		waitBatchEnded(t, s, "", b.ID)
+	}
+	if _, err := s.SubmitBatch("", "", "claude", "b_", []BatchRequest{{CustomID: "x"}}, nil); err != nil {
+		t.Fatalf("submit after batches ended: %v", err)
+	}
+	if _, err := s.GetBatch("", first); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("the oldest ended batch should be evicted, got %v", err)
+	}
+	if n := len(s.ListBatches("")); n != maxBatches {
+		t.Fatalf("kept %d batches, want %d", n, maxBatches)
+	}
+}
+
+func TestBatchesArePersistedOnceJobsAreEnabled(t *testing.T) {
+	dir := t.TempDir()
+	started := make(chan struct{}, 1)
+	s := NewStore(func(ctx context.Context, _ Job) (int, []byte, error) {
+		started <- struct{}{}
+		<-ctx.Done()
+		return 0, nil, ctx.Err()
+	})
+	s.Configure(config.AsyncJobsConfig{Concurrency: 1}, "")
+	created, err := s.SubmitBatch("key", "", "claude", "b_", []BatchRequest{{CustomID: "a"}, {CustomID: "b"}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-started
+	cfg := config.AsyncJobsConfig{Enable: true, Dir: dir, Concurrency: 1, TimeoutSeconds: 60, RetentionHours: 1}
+	s.Configure(cfg, "")
+	s.Stop()
+
+	resumed := NewStore(func(_ context.Context, job Job) (int, []byte, error) {
+		return http.StatusOK, []byte(job.CustomID), nil
+	})
+	defer resumed.Stop()
+	resumed.Configure(cfg, "")
+	waitBatchEnded(t, resumed, "key", created.ID)
+	results, err := resumed.BatchResults("key", created.ID)
+	if err != nil || len(results) != 2 || string(results[0].Result) != "a" || string(results[1].Result) != "b" {
+		t.Fatalf("results = %+v, %v", results, err)
+	}
+}
+
+func TestValidateBatch(t *testing.T) {
+	cases := [][]BatchRequest{
+		nil,
+		{{CustomID: ""}},
+		{{CustomID: "a"}, {CustomID: "a"}},
+	}
+	for _, requests := range cases {
+		if err := ValidateBatch(requests); err == nil {
+			t.Fatalf("ValidateBatch(%v) should fail", requests)
+		}
+	}
+}
@@ -0,0 +1,203 @@
+// Package audit records management API mutations in an append-only JSONL log.
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	"gopkg.in/yaml.v3"
+)
+
+// Entry is one recorded management mutation.
+type Entry struct {
+	Time     time.Time `json:"time"`
+	Actor    Actor     `json:"actor"`
+	ClientIP string    `json:"client_ip,omitempty"`
+	Method   string    `json:"method"`
+	Path     string    `json:"path"`
+	Query    string    `json:"query,omitempty"`
+	Status   int       `json:"status"`
+	Changes  []Change  `json:"changes,omitempty"`
+}
+
+// Actor identifies the management key a mutation was made with. The key itself is never
+// stored, only a fingerprint of it.
+type Actor struct {
+	// Kind is the kind of key: "local-password", "env-secret" or "secret-key".
+	Kind string `json:"kind"`
+	// Fingerprint is the first 12 hex digits of the key's SHA-256.
+	Fingerprint string `json:"fingerprint,omitempty"`
+	// Label is the self-declared X-Management-Actor header, if any.
+	Label string `json:"label,omitempty"`
+}
+
+// Change is one changed setting, identified by its dotted YAML path. Before is empty for
+// added settings and After for removed ones. Secrets are masked.
+type Change struct {
+	Path   string `json:"path"`
+	Before string `json:"before,omitempty"`
+	After  string `json:"after,omitempty"`
+}
+
+// Log is an append-only audit log file.
+type Log struct {
+	mu   sync.Mutex
+	path string
+}
+
+// NewLog returns the log stored at path.
+func NewLog(path string) *Log {
+	return &Log{path: path}
+}
+
+// Path returns the file of the log.
+func (l *Log) Path() string {
+	if l == nil {
+		return ""
+	}
+	return l.path
+}
+
+// Append writes entry at the end of the log.
+func (l *Log) Append(entry Entry) error {
+	if l == nil || l.path == "" {
+		return nil
+	}
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if err = os.MkdirAll(filepath.Dir(l.path), 0o700); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(l.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err = file.Write(append(line, '\n')); err != nil {
+		_ = file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// Entries returns the logged entries, oldest first. Lines that fail to parse are skipped.
+func (l *Log) Entries() ([]Entry, error) {
+	if l == nil || l.path == "" {
+		return nil, nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	file, err := os.Open(l.path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer func() { _ = file.Close() }()
+	var entries []Entry
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var entry Entry
+		if json.Unmarshal(scanner.Bytes(), &entry) == nil {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, scanner.Err()
+}
+
+// Snapshot flattens value (typically the config) into dotted YAML paths and their
+// rendered values, for Diff.
+func Snapshot(value any) map[string]string {
+	out := make(map[string]string)
+	data, err := yaml.Marshal(value)
+	if err != nil {
+		return out
+	}
+	var tree any
+	if err = yaml.Unmarshal(data, &tree); err != nil {
+		return out
+	}
+	flatten(out, "", tree)
+	return out
+}
+
+func flatten(out map[string]string, prefix string, node any) {
+	join := func(key string) string {
+		if prefix == "" {
+			return key
+		}
+		return prefix + "." + key
+	}
+	switch v := node.(type) {
+	case map[string]any:
+		for key, child := range v {
+			flatten(out, join(key), child)
+		}
+	case []any:
+		for i, child := range v {
+			flatten(out, join(fmt.Sprint(i)), child)
+		}
+	case nil:
+	default:
+		out[prefix] = fmt.Sprint(v)
+	}
+}
+
+// Diff returns the settings that differ between two snapshots, sorted by path.
+func Diff(before, after map[string]string) []Change {
+	if reflect.DeepEqual(before, after) {
+		return nil
+	}
+	var changes []Change
+	for path, old := range before {
+		if current, ok := after[path]; !ok || current != old {
+			changes = append(changes, Change{Path: path, Before: mask(path, old), After: mask(path, after[path])})
+		}
+	}
+	for path, current := range after {
+		if _, ok := before[path]; !ok {
+			changes = append(changes, Change{Path: path, After: mask(path, current)})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Path < changes[j].Path })
+	return changes
+}
+
+// mask hides the values of settings whose name (the last path segment that is not a list
+// index) names a secret.
+func mask(path, value string) string {
+	if value == "" {
+		return ""
+	}
+	segments := strings.Split(path, ".")
+	name := segments[len(segments)-1]
+	for i := len(segments) - 1; i >= 0; i-- {
+		if _, err := strconv.Atoi(segments[i]); err != nil {
+			name = segments[i]
+			break
+		}
+	}
+	lower := strings.ToLower(name)
+	for _, marker := range []string{"key", "secret", "token", "password", "authorization", "cookie"} {
+		if strings.Contains(lower, marker) {
+			return util.HideAPIKey(value)
+		}
+	}
+	return value
+}
@@ -0,0 +1,55 @@
+package audit
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestDiffMasksSecrets(t *testing.T) {
+	type upstream struct {
+		BaseURL string `yaml:"base-url"`
+		APIKey  string `yaml:"api-key"`
+	}
+	type settings struct {
+		APIKeys  []string   `yaml:"api-keys"`
+		Debug    bool       `yaml:"debug"`
+		Upstream []upstream `yaml:"upstream"`
+	}
+	before := Snapshot(settings{APIKeys: []string{"sk-old-0123456789"}, Upstream: []upstream{{BaseURL: "https://a", APIKey: "k-1234567890"}}})
+	after := Snapshot(settings{APIKeys: []string{"sk-new-0123456789"}, Debug: true, Upstream: []upstream{{BaseURL: "https://b", APIKey: "k-1234567890"}}})
+
+	changes := Diff(before, after)
+	want := []Change{
+		{Path: "api-keys.0", Before: "sk-o...6789", After: "sk-n...6789"},
+		{Path: "debug", Before: "false", After: "true"},
+		{Path: "upstream.0.base-url", Before: "https://a", After: "https://b"},
+	}
+	if len(changes) != len(want) {
+		t.Fatalf("changes = %+v, want %+v", changes, want)
+	}
+	for i := range want {
+		if changes[i] != want[i] {
+			t.Errorf("change %d = %+v, want %+v", i, changes[i], want[i])
+		}
+	}
+	if Diff(before, before) != nil {
+		t.Fatalf("identical snapshots must not report changes")
+	}
+}
+
+func TestLogAppendsEntries(t *testing.T) {
+	log := NewLog(filepath.Join(t.TempDir(), "audit", "management-audit.jsonl"))
+	for _, path := range []string{"/v0/management/api-keys", "/v0/management/debug"} {
+		if err := log.Append(Entry{Time: time.Now(), Method: "PUT", Path: path, Status: 200}); err != nil {
+			t.Fatalf("append: %v", err)
+		}
+	}
+	entries, err := log.Entries()
+	if err != nil {
+		t.Fatalf("entries: %v", err)
+	}
+	if len(entries) != 2 || entries[0].Path != "/v0/management/api-keys" || entries[1].Path != "/v0/management/debug" {
+		t.Fatalf("entries = %+v, want both appends in order", entries)
+	}
+}
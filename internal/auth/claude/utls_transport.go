@@ -9,6 +9,7 @@ import (
 	"sync"
 
 	tls "github.com/refraction-networking/utls"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/dnscache"
 	"github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
 	log "github.com/sirupsen/logrus"
 	"golang.org/x/net/http2"
@@ -30,13 +31,14 @@ type utlsRoundTripper struct {
 
 // newUtlsRoundTripper creates a new utls-based round tripper with optional proxy support
 func newUtlsRoundTripper(cfg *config.SDKConfig) *utlsRoundTripper {
-	var dialer proxy.Dialer = proxy.Direct
+	// Direct connections and proxy hops resolve through the DNS cache when it is enabled.
+	var dialer proxy.Dialer = dnscache.Default()
 	if cfg != nil && cfg.ProxyURL != "" {
 		proxyURL, err := url.Parse(cfg.ProxyURL)
 		if err != nil {
 			log.Errorf("failed to parse proxy URL %q: %v", cfg.ProxyURL, err)
 		} else {
-			pDialer, err := proxy.FromURL(proxyURL, proxy.Direct)
+			pDialer, err := proxy.FromURL(proxyURL, dnscache.Default())
 			if err != nil {
 				log.Errorf("failed to create proxy dialer for %q: %v", cfg.ProxyURL, err)
 			} else {
@@ -0,0 +1,241 @@
+// Package authguard tracks invalid API key attempts per client IP and bans IPs that keep
+// guessing, with bans doubling in length on every repeat.
+package authguard
+
+import (
+	"net/netip"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+// maxTrackedIPs bounds the tracked IPs. Beyond it idle entries are pruned, then the least
+// recently seen ones evicted.
+const maxTrackedIPs = 4096
+
+// ipv6BanBits is the prefix IPv6 clients are tracked by: one host usually owns a whole /64,
+// so tracking single addresses would let it rotate around a ban.
+const ipv6BanBits = 64
+
+// Guard holds the failure counters and bans.
+type Guard struct {
+	mu       sync.Mutex
+	settings config.AuthGuardConfig
+	entries  map[string]*entry
+	now      func() time.Time
+}
+
+type entry struct {
+	failures    []time.Time
+	bans        int
+	bannedUntil time.Time
+	lastSeen    time.Time
+}
+
+// Status describes one tracked IP, or IPv6 /64 network.
+type Status struct {
+	IP          string     `json:"ip"`
+	Failures    int        `json:"failures"`
+	Bans        int        `json:"bans"`
+	BannedUntil *time.Time `json:"banned_until,omitempty"`
+	LastFailure time.Time  `json:"last_failure"`
+}
+
+var defaultGuard = NewGuard()
+
+// Default returns the process-wide guard.
+func Default() *Guard { return defaultGuard }
+
+// NewGuard constructs a disabled guard.
+func NewGuard() *Guard {
+	return &Guard{entries: make(map[string]*entry), now: time.Now}
+}
+
+// Configure replaces the settings. Disabling forgets every tracked IP.
+func (g *Guard) Configure(cfg config.AuthGuardConfig) {
+	if g == nil {
+		return
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.settings = cfg
+	if cfg.MaxFailures <= 0 {
+		g.entries = make(map[string]*entry)
+	}
+}
+
+// Banned reports whether ip is banned and for how much longer.
+func (g *Guard) Banned(ip string) (time.Duration, bool) {
+	if g == nil {
+		return 0, false
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.settings.MaxFailures <= 0 {
+		return 0, false
+	}
+	e, ok := g.entries[trackingKey(ip)]
+	if !ok {
+		return 0, false
+	}
+	remaining := e.bannedUntil.Sub(g.now())
+	return remaining, remaining > 0
+}
+
+// Fail records an invalid API key from ip and reports whether it got ip banned.
+func (g *Guard) Fail(ip string) bool {
+	if g == nil {
+		return false
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.settings.MaxFailures <= 0 {
+		return false
+	}
+	now := g.now()
+	key := trackingKey(ip)
+	e, ok := g.entries[key]
+	if !ok {
+		if len(g.entries) >= maxTrackedIPs {
+			g.pruneLocked(now)
+		}
+		for len(g.entries) >= maxTrackedIPs {
+			g.evictLocked(now)
+		}
+		e = &entry{}
+		g.entries[key] = e
+	}
+	e.lastSeen = now
+	e.failures = append(g.recentLocked(e, now), now)
+	if len(e.failures) < g.settings.MaxFailures {
+		return false
+	}
+	ban := time.Duration(g.settings.BanSeconds) * time.Second
+	limit := time.Duration(g.settings.MaxBanSeconds) * time.Second
+	for i := 0; i < e.bans && ban < limit; i++ {
+		ban *= 2
+	}
+	e.bans++
+	e.bannedUntil = now.Add(min(ban, limit))
+	e.failures = nil
+	return true
+}
+
+// Succeed clears the failure count of ip after a valid API key. Earlier bans still count
+// towards the length of the next one until the entry goes idle.
+func (g *Guard) Succeed(ip string) {
+	if g == nil {
+		return
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if e, ok := g.entries[trackingKey(ip)]; ok {
+		e.failures = nil
+	}
+}
+
+// Snapshot returns the tracked IPs, banned ones first.
+func (g *Guard) Snapshot() []Status {
+	if g == nil {
+		return nil
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	now := g.now()
+	out := make([]Status, 0, len(g.entries))
+	for ip, e := range g.entries {
+		status := Status{IP: ip, Failures: len(g.recentLocked(e, now)), Bans: e.bans, LastFailure: e.lastSeen}
+		if e.bannedUntil.After(now) {
+			until := e.bannedUntil
+			status.BannedUntil = &until
+		}
+		out = append(out, status)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if (out[i].BannedUntil != nil) != (out[j].BannedUntil != nil) {
+			return out[i].BannedUntil != nil
+		}
+		return out[i].LastFailure.After(out[j].LastFailure)
+	})
+	return out
+}
+
+// Clear forgets ip (or the /64 network of an IPv6 address), or every IP when ip is empty.
+func (g *Guard) Clear(ip string) {
+	if g == nil {
+		return
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if ip == "" {
+		g.entries = make(map[string]*entry)
+		return
+	}
+	delete(g.entries, trackingKey(ip))
+}
+
+// trackingKey returns the key ip is tracked under: IPv4 addresses as they are, IPv6
+// addresses by their /64 network. Other values are used verbatim.
+func trackingKey(ip string) string {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return ip
+	}
+	addr = addr.Unmap()
+	if addr.Is4() {
+		return addr.String()
+	}
+	prefix, err := addr.WithZone("").Prefix(ipv6BanBits)
+	if err != nil {
+		return ip
+	}
+	return prefix.String()
+}
+
+// recentLocked returns the failures of e within the window.
+func (g *Guard) recentLocked(e *entry, now time.Time) []time.Time {
+	window := time.Duration(g.settings.WindowSeconds) * time.Second
+	if window <= 0 {
+		window = config.DefaultAuthGuardWindowSeconds * time.Second
+	}
+	kept := e.failures[:0]
+	for _, at := range e.failures {
+		if now.Sub(at) < window {
+			kept = append(kept, at)
+		}
+	}
+	return kept
+}
+
+// evictLocked drops the least recently seen entry that is not banned, or the ban ending
+// first when every entry is banned.
+func (g *Guard) evictLocked(now time.Time) {
+	victim, victimBanned := "", false
+	var victimAt time.Time
+	for ip, e := range g.entries {
+		banned := e.bannedUntil.After(now)
+		at := e.lastSeen
+		if banned {
+			at = e.bannedUntil
+		}
+		switch {
+		case victim == "",
+			victimBanned && !banned,
+			victimBanned == banned && at.Before(victimAt):
+			victim, victimBanned, victimAt = ip, banned, at
+		}
+	}
+	delete(g.entries, victim)
+}
+
+// pruneLocked drops the entries that are neither banned nor seen within the longest ban.
+func (g *Guard) pruneLocked(now time.Time) {
+	idle := time.Duration(max(g.settings.MaxBanSeconds, g.settings.WindowSeconds)) * time.Second
+	for ip, e := range g.entries {
+		if !e.bannedUntil.After(now) && now.Sub(e.lastSeen) > idle {
+			delete(g.entries, ip)
+		}
+	}
+}
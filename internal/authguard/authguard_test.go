@@ -0,0 +1,125 @@
+package authguard
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+func TestGuardBansWithBackoff(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	g := NewGuard()
+	g.now = func() time.Time { return now }
+	g.Configure(config.AuthGuardConfig{MaxFailures: 3, WindowSeconds: 60, BanSeconds: 10, MaxBanSeconds: 25})
+
+	fail := func(n int) bool {
+		banned := false
+		for i := 0; i < n; i++ {
+			banned = g.Fail("203.0.113.7")
+		}
+		return banned
+	}
+
+	if fail(2) {
+		t.Fatal("banned before max-failures")
+	}
+	if _, banned := g.Banned("203.0.113.7"); banned {
+		t.Fatal("Banned() = true before max-failures")
+	}
+	g.Succeed("203.0.113.7")
+	if fail(2) {
+		t.Fatal("success did not reset the failure count")
+	}
+	if !fail(1) {
+		t.Fatal("not banned at max-failures")
+	}
+	if remaining, banned := g.Banned("203.0.113.7"); !banned || remaining != 10*time.Second {
+		t.Fatalf("Banned() = %v, %v; want 10s, true", remaining, banned)
+	}
+	if _, banned := g.Banned("198.51.100.1"); banned {
+		t.Fatal("other IP banned")
+	}
+
+	now = now.Add(11 * time.Second)
+	if _, banned := g.Banned("203.0.113.7"); banned {
+		t.Fatal("ban did not expire")
+	}
+	fail(3)
+	if remaining, _ := g.Banned("203.0.113.7"); remaining != 20*time.Second {
+		t.Fatalf("second ban = %v, want 20s", remaining)
+	}
+	now = now.Add(21 * time.Second)
+	fail(3)
+	if remaining, _ := g.Banned("203.0.113.7"); remaining != 25*time.Second {
+		t.Fatalf("third ban = %v, want capped 25s", remaining)
+	}
+
+	snapshot := g.Snapshot()
+	if len(snapshot) != 1 || snapshot[0].Bans != 3 || snapshot[0].BannedUntil == nil {
+		t.Fatalf("unexpected snapshot %+v", snapshot)
+	}
+	g.Clear("203.0.113.7")
+	if _, banned := g.Banned("203.0.113.7"); banned || len(g.Snapshot()) != 0 {
+		t.Fatal("Clear did not lift the ban")
+	}
+}
+
+func TestGuardWindowAndDisable(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	g := NewGuard()
+	g.now = func() time.Time { return now }
+	g.Configure(config.AuthGuardConfig{MaxFailures: 2, WindowSeconds: 60, BanSeconds: 10, MaxBanSeconds: 60})
+
+	g.Fail("::1")
+	now = now.Add(61 * time.Second)
+	if g.Fail("::1") {
+		t.Fatal("failure outside the window counted")
+	}
+
+	g.Configure(config.AuthGuardConfig{})
+	for i := 0; i < 5; i++ {
+		if g.Fail("::1") {
+			t.Fatal("disabled guard banned")
+		}
+	}
+	if len(g.Snapshot()) != 0 {
+		t.Fatal("disabled guard tracks IPs")
+	}
+}
+
+func TestGuardBansIPv6Networks(t *testing.T) {
+	g := NewGuard()
+	g.Configure(config.AuthGuardConfig{MaxFailures: 2, WindowSeconds: 60, BanSeconds: 10, MaxBanSeconds: 10})
+	g.Fail("2001:db8:1:2::a")
+	if !g.Fail("2001:db8:1:2::b") {
+		t.Fatal("failures of one /64 were not counted together")
+	}
+	if _, banned := g.Banned("2001:db8:1:2:ffff::1"); !banned {
+		t.Fatal("another address of the banned /64 got through")
+	}
+	if _, banned := g.Banned("2001:db8:1:3::a"); banned {
+		t.Fatal("neighbouring /64 banned")
+	}
+	if snapshot := g.Snapshot(); len(snapshot) != 1 || snapshot[0].IP != "2001:db8:1:2::/64" {
+		t.Fatalf("snapshot = %+v", snapshot)
+	}
+}
+
+func TestGuardBoundsTrackedIPs(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	g := NewGuard()
+	g.now = func() time.Time { return now }
+	g.Configure(config.AuthGuardConfig{MaxFailures: 1000, WindowSeconds: 60, BanSeconds: 10, MaxBanSeconds: 3600})
+	for i := 0; i < maxTrackedIPs+10; i++ {
+		g.Fail(fmt.Sprintf("10.%d.%d.%d", i>>16&0xff, i>>8&0xff, i&0xff))
+		now = now.Add(time.Millisecond)
+	}
+	if len(g.entries) > maxTrackedIPs {
+		t.Fatalf("tracked %d IPs, want at most %d", len(g.entries), maxTrackedIPs)
+	}
+	if _, ok := g.entries["10.0.0.0"]; ok {
+		t.Fatal("least recently seen IP was not evicted")
+	}
+}
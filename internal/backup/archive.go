@@ -0,0 +1,235 @@
+package backup
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+const (
+	// archiveConfigName and archiveAuthPrefix name the config file and the auth files inside
+	// an archive.
+	archiveConfigName = "config.yaml"
+	archiveAuthPrefix = "auths/"
+
+	// authTrashDir holds soft-deleted credentials, which are not backed up.
+	authTrashDir = ".trash"
+
+	saltSize = 16
+	keySize  = 32
+)
+
+// magic starts every encrypted archive and versions its format.
+var magic = []byte("CLIPROXYBAK1")
+
+// ErrBadPassphrase is returned when an archive cannot be decrypted.
+var ErrBadPassphrase = errors.New("backup: wrong passphrase or corrupted archive")
+
+// Archive packs the config file and the files of the auth directory into a gzipped tar.
+// Missing paths are skipped; soft-deleted credentials and skipDir, when it lies inside the
+// auth directory, are left out.
+func Archive(configPath, authDir, skipDir string) ([]byte, error) {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	if configPath != "" {
+		if err := addFile(tw, configPath, archiveConfigName); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+	}
+	if skipDir != "" {
+		if abs, err := filepath.Abs(skipDir); err == nil {
+			skipDir = abs
+		}
+	}
+	if authDir != "" {
+		err := filepath.WalkDir(authDir, func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				if p == authDir && errors.Is(err, fs.ErrNotExist) {
+					return fs.SkipAll
+				}
+				return err
+			}
+			if d.IsDir() {
+				if d.Name() == authTrashDir || (skipDir != "" && sameDir(p, skipDir)) {
+					return fs.SkipDir
+				}
+				return nil
+			}
+			if !d.Type().IsRegular() {
+				return nil
+			}
+			rel, errRel := filepath.Rel(authDir, p)
+			if errRel != nil {
+				return errRel
+			}
+			return addFile(tw, p, archiveAuthPrefix+filepath.ToSlash(rel))
+		})
+		if err != nil {
+			return nil, fmt.Errorf("backup: archive auth dir: %w", err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// sameDir reports whether p names the absolute directory dir.
+func sameDir(p, dir string) bool {
+	abs, err := filepath.Abs(p)
+	return err == nil && abs == dir
+}
+
+func addFile(tw *tar.Writer, src, name string) error {
+	data, err := os.ReadFile(src)
+	if err != nil {
+		return err
+	}
+	info, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+	header := &tar.Header{Name: name, Mode: 0o600, Size: int64(len(data)), ModTime: info.ModTime(), Typeflag: tar.TypeReg}
+	if err = tw.WriteHeader(header); err != nil {
+		return err
+	}
+	_, err = tw.Write(data)
+	return err
+}
+
+// Contents is what an archive restores.
+type Contents struct {
+	// Config is the config file, nil when the archive has none.
+	Config []byte
+	// Auths maps the slash-separated paths of the auth files, relative to the auth
+	// directory, to their contents.
+	Auths map[string][]byte
+}
+
+// Unarchive reads an archive written by Archive, rejecting entries that would escape the
+// auth directory.
+func Unarchive(data []byte) (*Contents, error) {
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("backup: read archive: %w", err)
+	}
+	defer func() { _ = gz.Close() }()
+	tr := tar.NewReader(gz)
+	contents := &Contents{Auths: make(map[string][]byte)}
+	for {
+		header, errNext := tr.Next()
+		if errors.Is(errNext, io.EOF) {
+			return contents, nil
+		}
+		if errNext != nil {
+			return nil, fmt.Errorf("backup: read archive: %w", errNext)
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		body, errRead := io.ReadAll(tr)
+		if errRead != nil {
+			return nil, fmt.Errorf("backup: read %s: %w", header.Name, errRead)
+		}
+		switch {
+		case header.Name == archiveConfigName:
+			contents.Config = body
+		case strings.HasPrefix(header.Name, archiveAuthPrefix):
+			rel := path.Clean(strings.TrimPrefix(header.Name, archiveAuthPrefix))
+			if rel == "." || path.IsAbs(rel) || rel == ".." || strings.HasPrefix(rel, "../") {
+				return nil, fmt.Errorf("backup: invalid archive entry %q", header.Name)
+			}
+			contents.Auths[rel] = body
+		}
+	}
+}
+
+// Encrypt seals data with AES-256-GCM under a key derived from passphrase with scrypt.
+func Encrypt(data []byte, passphrase string) ([]byte, error) {
+	salt := make([]byte, saltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	aead, err := newAEAD(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err = rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	out := make([]byte, 0, len(magic)+saltSize+len(nonce)+len(data)+aead.Overhead())
+	out = append(out, magic...)
+	out = append(out, salt...)
+	out = append(out, nonce...)
+	return aead.Seal(out, nonce, data, magic), nil
+}
+
+// Decrypt opens an archive sealed by Encrypt.
+func Decrypt(data []byte, passphrase string) ([]byte, error) {
+	if !bytes.HasPrefix(data, magic) {
+		return nil, errors.New("backup: not a CLIProxyAPI backup")
+	}
+	data = data[len(magic):]
+	if len(data) < saltSize {
+		return nil, ErrBadPassphrase
+	}
+	aead, err := newAEAD(passphrase, data[:saltSize])
+	if err != nil {
+		return nil, err
+	}
+	data = data[saltSize:]
+	if len(data) < aead.NonceSize() {
+		return nil, ErrBadPassphrase
+	}
+	plain, err := aead.Open(nil, data[:aead.NonceSize()], data[aead.NonceSize():], magic)
+	if err != nil {
+		return nil, ErrBadPassphrase
+	}
+	return plain, nil
+}
+
+func newAEAD(passphrase string, salt []byte) (cipher.AEAD, error) {
+	key, err := scrypt.Key([]byte(passphrase), salt, 1<<15, 8, 1, keySize)
+	if err != nil {
+		return nil, fmt.Errorf("backup: derive key: %w", err)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// archiveName names the backup taken at t; names sort chronologically.
+func archiveName(t time.Time) string {
+	return namePrefix + t.UTC().Format("20060102T150405Z") + nameSuffix
+}
+
+const (
+	namePrefix = "cliproxy-backup-"
+	nameSuffix = ".tar.gz.enc"
+)
+
+// isArchiveName reports whether name was produced by archiveName.
+func isArchiveName(name string) bool {
+	return strings.HasPrefix(name, namePrefix) && strings.HasSuffix(name, nameSuffix)
+}
@@ -0,0 +1,230 @@
+// Package backup takes scheduled, encrypted backups of the auth directory and the config
+// file to a local directory, an S3-compatible bucket or a WebDAV collection, keeping the
+// newest few, and restores them.
+package backup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	log "github.com/sirupsen/logrus"
+)
+
+// backupTimeout bounds one backup, upload included.
+const backupTimeout = 10 * time.Minute
+
+var defaultScheduler = NewScheduler()
+
+// Default returns the process-wide backup scheduler.
+func Default() *Scheduler { return defaultScheduler }
+
+// Scheduler takes a backup at startup and then every interval while enabled.
+type Scheduler struct {
+	mu         sync.Mutex
+	cfg        config.BackupConfig
+	configPath string
+	authDir    string
+	cancel     context.CancelFunc
+	nowFunc    func() time.Time
+}
+
+// NewScheduler constructs a disabled scheduler.
+func NewScheduler() *Scheduler {
+	return &Scheduler{nowFunc: time.Now}
+}
+
+// Configure applies the backup settings of cfg and starts, restarts or stops the schedule.
+func (s *Scheduler) Configure(cfg *config.Config, configPath string) {
+	if s == nil || cfg == nil {
+		return
+	}
+	authDir, err := util.ResolveAuthDir(cfg.AuthDir)
+	if err != nil {
+		log.Warnf("backup: %v", err)
+		authDir = cfg.AuthDir
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.cancel != nil {
+		s.cancel()
+		s.cancel = nil
+	}
+	s.cfg, s.configPath, s.authDir = cfg.Backup, configPath, authDir
+	if !cfg.Backup.Enable {
+		return
+	}
+	if cfg.Backup.Passphrase == "" {
+		log.Warn("backup: enabled without a passphrase; no backups are taken")
+		return
+	}
+	hours := cfg.Backup.IntervalHours
+	if hours <= 0 {
+		hours = config.DefaultBackupIntervalHours
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+	go s.run(ctx, time.Duration(hours)*time.Hour)
+}
+
+// Stop halts the schedule.
+func (s *Scheduler) Stop() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.cancel != nil {
+		s.cancel()
+		s.cancel = nil
+	}
+}
+
+func (s *Scheduler) run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		s.mu.Lock()
+		cfg, configPath, authDir, now := s.cfg, s.configPath, s.authDir, s.nowFunc()
+		s.mu.Unlock()
+		runCtx, cancel := context.WithTimeout(ctx, backupTimeout)
+		if name, err := Run(runCtx, cfg, configPath, authDir, now); err != nil {
+			if ctx.Err() == nil {
+				log.Errorf("backup: %v", err)
+			}
+		} else {
+			log.Infof("backup: stored %s", name)
+		}
+		cancel()
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// FallbackDir is the local destination used when backup.dir is empty: a "backups"
+// directory next to the config file.
+func FallbackDir(configPath string) string {
+	return filepath.Join(filepath.Dir(configPath), "backups")
+}
+
+// Run takes one backup of configPath and authDir, stores it at the destination of cfg and
+// deletes the backups beyond the retention count. It returns the archive name.
+func Run(ctx context.Context, cfg config.BackupConfig, configPath, authDir string, now time.Time) (string, error) {
+	if cfg.Passphrase == "" {
+		return "", errors.New("backup: passphrase is required")
+	}
+	storage, err := NewStorage(cfg, FallbackDir(configPath))
+	if err != nil {
+		return "", err
+	}
+	// A local destination inside the auth directory must not back up earlier backups.
+	var skipDir string
+	if local, ok := storage.(*localStorage); ok {
+		skipDir = local.dir
+	}
+	archive, err := Archive(configPath, authDir, skipDir)
+	if err != nil {
+		return "", err
+	}
+	sealed, err := Encrypt(archive, cfg.Passphrase)
+	if err != nil {
+		return "", err
+	}
+	name := archiveName(now)
+	if err = storage.Put(ctx, name, sealed); err != nil {
+		return "", err
+	}
+	prune(ctx, storage, cfg.Retention)
+	return name, nil
+}
+
+// prune deletes the oldest archives beyond retention.
+func prune(ctx context.Context, storage Storage, retention int) {
+	if retention <= 0 {
+		return
+	}
+	names, err := storage.List(ctx)
+	if err != nil {
+		log.Warnf("backup: list for retention: %v", err)
+		return
+	}
+	for len(names) > retention {
+		if errDelete := storage.Delete(ctx, names[0]); errDelete != nil {
+			log.Warnf("backup: delete %s: %v", names[0], errDelete)
+		}
+		names = names[1:]
+	}
+}
+
+// Fetch downloads and decrypts the archive name, or the newest one when name is empty or
+// "latest".
+func Fetch(ctx context.Context, cfg config.BackupConfig, configPath, name string) (string, *Contents, error) {
+	if cfg.Passphrase == "" {
+		return "", nil, errors.New("backup: passphrase is required")
+	}
+	storage, err := NewStorage(cfg, FallbackDir(configPath))
+	if err != nil {
+		return "", nil, err
+	}
+	if name == "" || name == "latest" {
+		names, errList := storage.List(ctx)
+		if errList != nil {
+			return "", nil, errList
+		}
+		if len(names) == 0 {
+			return "", nil, errors.New("backup: no backups found")
+		}
+		name = names[len(names)-1]
+	}
+	sealed, err := storage.Get(ctx, name)
+	if err != nil {
+		return "", nil, err
+	}
+	archive, err := Decrypt(sealed, cfg.Passphrase)
+	if err != nil {
+		return "", nil, err
+	}
+	contents, err := Unarchive(archive)
+	if err != nil {
+		return "", nil, err
+	}
+	return name, contents, nil
+}
+
+// Restore writes the auth files of contents into authDir and, unless skipConfig is set,
+// the config file to configPath, keeping the current config next to it with a .bak
+// suffix. It returns the paths written.
+func Restore(contents *Contents, configPath, authDir string, skipConfig bool) ([]string, error) {
+	var written []string
+	for rel, data := range contents.Auths {
+		dst := filepath.Join(authDir, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(dst), 0o700); err != nil {
+			return written, fmt.Errorf("backup: create auth dir: %w", err)
+		}
+		if err := os.WriteFile(dst, data, 0o600); err != nil {
+			return written, fmt.Errorf("backup: restore %s: %w", rel, err)
+		}
+		written = append(written, dst)
+	}
+	if skipConfig || contents.Config == nil || configPath == "" {
+		return written, nil
+	}
+	if current, err := os.ReadFile(configPath); err == nil {
+		if err = os.WriteFile(configPath+".bak", current, 0o600); err != nil {
+			return written, fmt.Errorf("backup: keep current config: %w", err)
+		}
+	}
+	if err := os.WriteFile(configPath, contents.Config, 0o600); err != nil {
+		return written, fmt.Errorf("backup: restore config: %w", err)
+	}
+	return append(written, configPath), nil
+}
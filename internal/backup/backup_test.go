@@ -0,0 +1,116 @@
+package backup
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+func TestRunAndRestoreLocal(t *testing.T) {
+	root := t.TempDir()
+	configPath := filepath.Join(root, "config.yaml")
+	authDir := filepath.Join(root, "auths")
+	mustWrite(t, configPath, "port: 8317\n")
+	mustWrite(t, filepath.Join(authDir, "claude-user.json"), `{"type":"claude","refresh_token":"rt"}`)
+	mustWrite(t, filepath.Join(authDir, "team", "codex.json"), `{"type":"codex"}`)
+	mustWrite(t, filepath.Join(authDir, ".trash", "old.json.deleted"), `{}`)
+
+	cfg := config.BackupConfig{Destination: config.BackupDestinationLocal, Retention: 2, Passphrase: "secret"}
+	start := time.Date(2026, 10, 1, 3, 0, 0, 0, time.UTC)
+	for i := 0; i < 3; i++ {
+		if _, err := Run(context.Background(), cfg, configPath, authDir, start.Add(time.Duration(i)*time.Hour)); err != nil {
+			t.Fatalf("Run: %v", err)
+		}
+	}
+	storage, _ := NewStorage(cfg, FallbackDir(configPath))
+	names, err := storage.List(context.Background())
+	if err != nil || len(names) != 2 || names[1] != archiveName(start.Add(2*time.Hour)) {
+		t.Fatalf("stored = %v (%v), want the two newest", names, err)
+	}
+	sealed, _ := storage.Get(context.Background(), names[1])
+	if string(sealed[:len(magic)]) != string(magic) || bytes.Contains(sealed, []byte("refresh_token")) {
+		t.Fatal("archive is not encrypted")
+	}
+
+	if _, _, err = Fetch(context.Background(), config.BackupConfig{Passphrase: "wrong"}, configPath, ""); !errors.Is(err, ErrBadPassphrase) {
+		t.Fatalf("Fetch with wrong passphrase: %v", err)
+	}
+	name, contents, err := Fetch(context.Background(), cfg, configPath, "latest")
+	if err != nil || name != names[1] {
+		t.Fatalf("Fetch = %s, %v", name, err)
+	}
+	if len(contents.Auths) != 2 || string(contents.Config) != "port: 8317\n" {
+		t.Fatalf("contents = %d auths, config %q; want the trash left out", len(contents.Auths), contents.Config)
+	}
+
+	target := t.TempDir()
+	mustWrite(t, filepath.Join(target, "config.yaml"), "port: 1\n")
+	written, err := Restore(contents, filepath.Join(target, "config.yaml"), filepath.Join(target, "auths"), false)
+	if err != nil || len(written) != 3 {
+		t.Fatalf("Restore = %v, %v", written, err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(target, "auths", "team", "codex.json")); string(data) != `{"type":"codex"}` {
+		t.Fatalf("restored codex.json = %q", data)
+	}
+	if data, _ := os.ReadFile(filepath.Join(target, "config.yaml.bak")); string(data) != "port: 1\n" {
+		t.Fatalf("config backup = %q", data)
+	}
+}
+
+func TestRunSkipsBackupsInsideTheAuthDir(t *testing.T) {
+	// The config file sits in the auth directory, so the fallback "backups" dir does too.
+	authDir := t.TempDir()
+	configPath := filepath.Join(authDir, "config.yaml")
+	mustWrite(t, configPath, "port: 8317\n")
+	mustWrite(t, filepath.Join(authDir, "claude-user.json"), `{"type":"claude"}`)
+
+	cfg := config.BackupConfig{Passphrase: "secret"}
+	for i := 0; i < 2; i++ {
+		if _, err := Run(context.Background(), cfg, configPath, authDir, time.Date(2026, 10, 1, i, 0, 0, 0, time.UTC)); err != nil {
+			t.Fatalf("Run: %v", err)
+		}
+	}
+	_, contents, err := Fetch(context.Background(), cfg, configPath, "latest")
+	if err != nil {
+		t.Fatalf("Fetch: %v", err)
+	}
+	if len(contents.Auths) != 2 {
+		t.Fatalf("auths = %d entries, want claude-user.json and config.yaml only", len(contents.Auths))
+	}
+	for rel := range contents.Auths {
+		if filepath.Dir(rel) == "backups" {
+			t.Fatalf("earlier backup %s archived", rel)
+		}
+	}
+}
+
+func TestUnarchiveRejectsEscapingPaths(t *testing.T) {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	_ = tw.WriteHeader(&tar.Header{Name: archiveAuthPrefix + "../../etc/cron.d/x", Mode: 0o600, Size: 2, Typeflag: tar.TypeReg})
+	_, _ = tw.Write([]byte("{}"))
+	_ = tw.Close()
+	_ = gz.Close()
+	if _, err := Unarchive(buf.Bytes()); err == nil {
+		t.Fatal("Unarchive accepted an entry outside the auth dir")
+	}
+}
+
+func mustWrite(t *testing.T, path, data string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
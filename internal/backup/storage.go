@@ -0,0 +1,286 @@
+package backup
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+// Storage is a destination backups are written to. Names are archive file names without
+// any directory.
+type Storage interface {
+	Put(ctx context.Context, name string, data []byte) error
+	Get(ctx context.Context, name string) ([]byte, error)
+	// List returns the stored archives, oldest first.
+	List(ctx context.Context) ([]string, error)
+	Delete(ctx context.Context, name string) error
+}
+
+// NewStorage opens the destination of cfg. fallbackDir is the local directory used when
+// cfg.Dir is empty.
+func NewStorage(cfg config.BackupConfig, fallbackDir string) (Storage, error) {
+	switch cfg.Destination {
+	case config.BackupDestinationLocal, "":
+		dir := cfg.Dir
+		if dir == "" {
+			dir = fallbackDir
+		}
+		if dir == "" {
+			return nil, errors.New("backup: local destination needs a dir")
+		}
+		return &localStorage{dir: dir}, nil
+	case config.BackupDestinationS3:
+		return newS3Storage(cfg.S3)
+	case config.BackupDestinationWebDAV:
+		if cfg.WebDAV.URL == "" {
+			return nil, errors.New("backup: webdav destination needs a url")
+		}
+		if _, err := url.Parse(cfg.WebDAV.URL); err != nil {
+			return nil, fmt.Errorf("backup: invalid webdav url: %w", err)
+		}
+		return &webdavStorage{cfg: cfg.WebDAV, client: &http.Client{Timeout: time.Minute}}, nil
+	}
+	return nil, fmt.Errorf("backup: unknown destination %q", cfg.Destination)
+}
+
+// sortArchives keeps the archive names of names, sorted oldest first.
+func sortArchives(names []string) []string {
+	out := names[:0]
+	for _, name := range names {
+		if isArchiveName(name) {
+			out = append(out, name)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+type localStorage struct {
+	dir string
+}
+
+func (s *localStorage) Put(_ context.Context, name string, data []byte) error {
+	if err := os.MkdirAll(s.dir, 0o700); err != nil {
+		return fmt.Errorf("backup: create dir: %w", err)
+	}
+	tmp := filepath.Join(s.dir, "."+name+".tmp")
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filepath.Join(s.dir, name))
+}
+
+func (s *localStorage) Get(_ context.Context, name string) ([]byte, error) {
+	return os.ReadFile(filepath.Join(s.dir, filepath.Base(name)))
+}
+
+func (s *localStorage) List(context.Context) ([]string, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if entry.Type().IsRegular() {
+			names = append(names, entry.Name())
+		}
+	}
+	return sortArchives(names), nil
+}
+
+func (s *localStorage) Delete(_ context.Context, name string) error {
+	return os.Remove(filepath.Join(s.dir, filepath.Base(name)))
+}
+
+type s3Storage struct {
+	client *minio.Client
+	cfg    config.BackupS3
+}
+
+func newS3Storage(cfg config.BackupS3) (*s3Storage, error) {
+	if cfg.Endpoint == "" || cfg.Bucket == "" {
+		return nil, errors.New("backup: s3 destination needs an endpoint and a bucket")
+	}
+	options := &minio.Options{
+		Creds:  credentials.NewStaticV4(cfg.AccessKey, cfg.SecretKey, ""),
+		Secure: !cfg.Insecure,
+		Region: cfg.Region,
+	}
+	if cfg.PathStyle {
+		options.BucketLookup = minio.BucketLookupPath
+	}
+	client, err := minio.New(cfg.Endpoint, options)
+	if err != nil {
+		return nil, fmt.Errorf("backup: create s3 client: %w", err)
+	}
+	return &s3Storage{client: client, cfg: cfg}, nil
+}
+
+func (s *s3Storage) key(name string) string {
+	if s.cfg.Prefix == "" {
+		return name
+	}
+	return s.cfg.Prefix + "/" + name
+}
+
+func (s *s3Storage) Put(ctx context.Context, name string, data []byte) error {
+	_, err := s.client.PutObject(ctx, s.cfg.Bucket, s.key(name), bytes.NewReader(data), int64(len(data)), minio.PutObjectOptions{
+		ContentType: "application/octet-stream",
+	})
+	if err != nil {
+		return fmt.Errorf("backup: upload %s: %w", name, err)
+	}
+	return nil
+}
+
+func (s *s3Storage) Get(ctx context.Context, name string) ([]byte, error) {
+	object, err := s.client.GetObject(ctx, s.cfg.Bucket, s.key(name), minio.GetObjectOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("backup: download %s: %w", name, err)
+	}
+	defer func() { _ = object.Close() }()
+	data, err := io.ReadAll(object)
+	if err != nil {
+		return nil, fmt.Errorf("backup: download %s: %w", name, err)
+	}
+	return data, nil
+}
+
+func (s *s3Storage) List(ctx context.Context) ([]string, error) {
+	prefix := ""
+	if s.cfg.Prefix != "" {
+		prefix = s.cfg.Prefix + "/"
+	}
+	var names []string
+	for object := range s.client.ListObjects(ctx, s.cfg.Bucket, minio.ListObjectsOptions{Prefix: prefix}) {
+		if object.Err != nil {
+			return nil, fmt.Errorf("backup: list bucket: %w", object.Err)
+		}
+		names = append(names, strings.TrimPrefix(object.Key, prefix))
+	}
+	return sortArchives(names), nil
+}
+
+func (s *s3Storage) Delete(ctx context.Context, name string) error {
+	return s.client.RemoveObject(ctx, s.cfg.Bucket, s.key(name), minio.RemoveObjectOptions{})
+}
+
+type webdavStorage struct {
+	cfg    config.BackupWebDAV
+	client *http.Client
+}
+
+func (s *webdavStorage) do(ctx context.Context, method, name string, body []byte, header http.Header) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, s.cfg.URL+url.PathEscape(name), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	if s.cfg.Username != "" || s.cfg.Password != "" {
+		req.SetBasicAuth(s.cfg.Username, s.cfg.Password)
+	}
+	return s.client.Do(req)
+}
+
+// expect drains and closes resp, returning an error unless its status is a success.
+func expect(resp *http.Response, action string) error {
+	defer func() { _ = resp.Body.Close() }()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("backup: webdav %s: %s", action, resp.Status)
+	}
+	return nil
+}
+
+func (s *webdavStorage) Put(ctx context.Context, name string, data []byte) error {
+	resp, err := s.do(ctx, http.MethodPut, name, data, http.Header{"Content-Type": {"application/octet-stream"}})
+	if err != nil {
+		return fmt.Errorf("backup: webdav upload: %w", err)
+	}
+	if resp.StatusCode == http.StatusConflict || resp.StatusCode == http.StatusNotFound {
+		// The collection does not exist yet.
+		_ = expect(resp, "upload")
+		if resp, err = s.do(ctx, "MKCOL", "", nil, nil); err != nil {
+			return fmt.Errorf("backup: webdav create collection: %w", err)
+		}
+		_ = expect(resp, "create collection")
+		if resp, err = s.do(ctx, http.MethodPut, name, data, http.Header{"Content-Type": {"application/octet-stream"}}); err != nil {
+			return fmt.Errorf("backup: webdav upload: %w", err)
+		}
+	}
+	return expect(resp, "upload "+name)
+}
+
+func (s *webdavStorage) Get(ctx context.Context, name string) ([]byte, error) {
+	resp, err := s.do(ctx, http.MethodGet, name, nil, nil)
+	if err != nil {
+		return nil, fmt.Errorf("backup: webdav download: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("backup: webdav download %s: %s", name, resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// davMultistatus is the part of a PROPFIND response listing the collection members.
+type davMultistatus struct {
+	Responses []struct {
+		Href string `xml:"href"`
+	} `xml:"response"`
+}
+
+func (s *webdavStorage) List(ctx context.Context) ([]string, error) {
+	resp, err := s.do(ctx, "PROPFIND", "", nil, http.Header{"Depth": {"1"}})
+	if err != nil {
+		return nil, fmt.Errorf("backup: webdav list: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if resp.StatusCode != http.StatusMultiStatus {
+		return nil, fmt.Errorf("backup: webdav list: %s", resp.Status)
+	}
+	var listing davMultistatus
+	if err = xml.NewDecoder(resp.Body).Decode(&listing); err != nil {
+		return nil, fmt.Errorf("backup: webdav list: %w", err)
+	}
+	names := make([]string, 0, len(listing.Responses))
+	for _, member := range listing.Responses {
+		href := member.Href
+		if unescaped, errUnescape := url.PathUnescape(href); errUnescape == nil {
+			href = unescaped
+		}
+		names = append(names, path.Base(strings.TrimSuffix(href, "/")))
+	}
+	return sortArchives(names), nil
+}
+
+func (s *webdavStorage) Delete(ctx context.Context, name string) error {
+	resp, err := s.do(ctx, http.MethodDelete, name, nil, nil)
+	if err != nil {
+		return fmt.Errorf("backup: webdav delete: %w", err)
+	}
+	return expect(resp, "delete "+name)
+}
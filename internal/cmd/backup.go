@@ -0,0 +1,125 @@
+package cmd
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/backup"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+)
+
+// backupCommandTimeout bounds the backup and backup-restore subcommands.
+const backupCommandTimeout = 10 * time.Minute
+
+func init() {
+	registerSubcommand("backup", newBackupCommand)
+	registerSubcommand("backup-restore", newBackupRestoreCommand)
+}
+
+// BackupRestoreOptions controls the backup-restore subcommand.
+type BackupRestoreOptions struct {
+	// Name is the archive to restore; empty or "latest" picks the newest.
+	Name string
+	// List prints the stored archives instead of restoring one.
+	List bool
+	// SkipConfig restores the credentials only, leaving the config file untouched.
+	SkipConfig bool
+}
+
+func newBackupCommand() *Subcommand {
+	fs := flag.NewFlagSet("backup", flag.ExitOnError)
+	return &Subcommand{
+		Name:  "backup",
+		Usage: "backup    take an encrypted backup of the credentials and config now",
+		Flags: fs,
+		Run: func(cfg *config.Config, configPath string, _ []string) int {
+			return DoBackup(os.Stdout, cfg, configPath)
+		},
+	}
+}
+
+func newBackupRestoreCommand() *Subcommand {
+	fs := flag.NewFlagSet("backup-restore", flag.ExitOnError)
+	opts := &BackupRestoreOptions{}
+	fs.StringVar(&opts.Name, "name", "latest", "Archive to restore")
+	fs.BoolVar(&opts.List, "list", false, "List the stored backups")
+	fs.BoolVar(&opts.SkipConfig, "skip-config", false, "Restore the credentials only")
+	return &Subcommand{
+		Name:  "backup-restore",
+		Usage: "backup-restore [-list] [-name archive] [-skip-config]    restore credentials and config from a backup",
+		Flags: fs,
+		Run: func(cfg *config.Config, configPath string, _ []string) int {
+			return DoBackupRestore(os.Stdout, cfg, configPath, *opts)
+		},
+	}
+}
+
+// DoBackup takes one backup with the backup settings of cfg. It returns the process exit
+// code.
+func DoBackup(w io.Writer, cfg *config.Config, configPath string) int {
+	authDir, err := util.ResolveAuthDir(cfg.AuthDir)
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "backup: %v\n", err)
+		return 1
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), backupCommandTimeout)
+	defer cancel()
+	name, err := backup.Run(ctx, cfg.Backup, configPath, authDir, time.Now())
+	if err != nil {
+		_, _ = fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	_, _ = fmt.Fprintf(w, "stored %s (%s)\n", name, cfg.Backup.Destination)
+	return 0
+}
+
+// DoBackupRestore lists the stored backups or restores one with the backup settings of cfg.
+// A running server picks the restored files up through its file watcher. It returns the
+// process exit code.
+func DoBackupRestore(w io.Writer, cfg *config.Config, configPath string, opts BackupRestoreOptions) int {
+	ctx, cancel := context.WithTimeout(context.Background(), backupCommandTimeout)
+	defer cancel()
+	if opts.List {
+		storage, err := backup.NewStorage(cfg.Backup, backup.FallbackDir(configPath))
+		if err != nil {
+			_, _ = fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		names, err := storage.List(ctx)
+		if err != nil {
+			_, _ = fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		sort.Sort(sort.Reverse(sort.StringSlice(names)))
+		for _, name := range names {
+			_, _ = fmt.Fprintln(w, name)
+		}
+		return 0
+	}
+	authDir, err := util.ResolveAuthDir(cfg.AuthDir)
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "backup-restore: %v\n", err)
+		return 1
+	}
+	name, contents, err := backup.Fetch(ctx, cfg.Backup, configPath, opts.Name)
+	if err != nil {
+		_, _ = fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	written, err := backup.Restore(contents, configPath, authDir, opts.SkipConfig)
+	for _, path := range written {
+		_, _ = fmt.Fprintf(w, "restored %s\n", path)
+	}
+	if err != nil {
+		_, _ = fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	_, _ = fmt.Fprintf(w, "restored %d files from %s\n", len(written), name)
+	return 0
+}
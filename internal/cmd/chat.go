@@ -0,0 +1,256 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/api"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/project"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/routeinfo"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy"
+	coreusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+	log "github.com/sirupsen/logrus"
+	"github.com/tidwall/gjson"
+)
+
+func init() {
+	registerSubcommand("chat", newChatCommand)
+}
+
+// ChatOptions controls the chat subcommand.
+type ChatOptions struct {
+	// Model is the model name sent by the client.
+	Model string
+	// Prompt is the user message.
+	Prompt string
+	// Amp routes the request through the Amp provider route so Amp model mappings apply.
+	Amp bool
+	// APIKey is the client API key; defaults to the first configured api-keys entry.
+	APIKey string
+	// Timeout bounds start-up plus the request.
+	Timeout time.Duration
+}
+
+func newChatCommand() *Subcommand {
+	fs := flag.NewFlagSet("chat", flag.ExitOnError)
+	opts := &ChatOptions{}
+	fs.StringVar(&opts.Model, "model", "", "Model to request")
+	fs.BoolVar(&opts.Amp, "amp", false, "Send through the Amp provider route so Amp model mappings apply")
+	fs.StringVar(&opts.APIKey, "api-key", "", "Client API key (defaults to the first api-keys entry)")
+	fs.DurationVar(&opts.Timeout, "timeout", 2*time.Minute, "Overall timeout")
+	return &Subcommand{
+		Name:  "chat",
+		Usage: "chat -model <model> [-amp] [-api-key key] \"prompt\"    send a test request through the full pipeline",
+		Flags: fs,
+		Run: func(cfg *config.Config, configPath string, args []string) int {
+			opts.Prompt = strings.TrimSpace(strings.Join(args, " "))
+			return DoChat(cfg, configPath, *opts)
+		},
+	}
+}
+
+// chatRoute is what the proxy decided for the smoke-test request.
+type chatRoute struct {
+	Status      int
+	MappedModel string
+	Project     string
+	// Usage is the upstream attempt that served the request, if one did.
+	Usage *coreusage.Record
+}
+
+// chatRouteCapture reports the route decision of the request to path. It reads the usage
+// record the executor stashed on the request rather than subscribing to the global usage
+// pipeline, which would keep receiving every record after the smoke test finished.
+func chatRouteCapture(path string, routes chan<- chatRoute) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+		if c.Request.URL.Path != path {
+			return
+		}
+		route := chatRoute{Status: c.Writer.Status(), Project: c.GetString(project.ContextKey)}
+		if info, ok := routeinfo.Get(c); ok && info.Mapped() {
+			route.MappedModel = info.ResolvedModel
+		}
+		if value, ok := c.Get(util.UsageRecordKey); ok {
+			if record, okRecord := value.(coreusage.Record); okRecord {
+				route.Usage = &record
+			}
+		}
+		select {
+		case routes <- route:
+		default:
+		}
+	}
+}
+
+// DoChat starts the proxy in-process on a loopback port, sends one chat completion through
+// the regular HTTP pipeline (access control, projects, Amp mappings, credential selection)
+// and prints the route decision and the response. It returns the process exit code.
+func DoChat(cfg *config.Config, configPath string, opts ChatOptions) int {
+	if cfg == nil {
+		cfg = &config.Config{}
+	}
+	if opts.Model == "" || opts.Prompt == "" {
+		_, _ = fmt.Fprintln(os.Stderr, "usage: chat -model <model> \"prompt\"")
+		return 2
+	}
+	if opts.APIKey == "" && len(cfg.APIKeys) > 0 {
+		opts.APIKey = cfg.APIKeys[0]
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = 2 * time.Minute
+	}
+	if !cfg.Debug {
+		log.SetLevel(log.WarnLevel)
+	}
+
+	// A smoke test must not join the cluster or claim credential leases.
+	runCfg := *cfg
+	runCfg.SharedState = config.SharedStateConfig{}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "chat: listen: %v\n", err)
+		return 1
+	}
+	baseURL := "http://" + ln.Addr().String()
+	path := "/v1/chat/completions"
+	if opts.Amp {
+		path = "/api/provider/openai/v1/chat/completions"
+	}
+
+	routes := make(chan chatRoute, 1)
+	capture := chatRouteCapture(path, routes)
+
+	service, err := cliproxy.NewBuilder().
+		WithConfig(&runCfg).
+		WithConfigPath(configPath).
+		WithServerOptions(api.WithListener(ln), api.WithMiddleware(capture)).
+		Build()
+	if err != nil {
+		_ = ln.Close()
+		_, _ = fmt.Fprintf(os.Stderr, "chat: build service: %v\n", err)
+		return 1
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), opts.Timeout)
+	defer cancel()
+	runDone := make(chan struct{})
+	go func() {
+		defer close(runDone)
+		_ = service.Run(ctx)
+	}()
+	defer func() {
+		cancel()
+		<-runDone
+	}()
+
+	client := &http.Client{}
+	if errReady := waitForReady(ctx, client, baseURL); errReady != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "chat: proxy did not become ready: %v\n", errReady)
+		return 1
+	}
+
+	payload, _ := json.Marshal(map[string]any{
+		"model":    opts.Model,
+		"stream":   false,
+		"messages": []map[string]string{{"role": "user", "content": opts.Prompt}},
+	})
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, baseURL+path, bytes.NewReader(payload))
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "chat: %v\n", err)
+		return 1
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if opts.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+opts.APIKey)
+	}
+	started := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "chat: request failed: %v\n", err)
+		return 1
+	}
+	body, _ := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	elapsed := time.Since(started)
+
+	var route chatRoute
+	select {
+	case route = <-routes:
+	default:
+	}
+
+	printChatResult(os.Stdout, opts, path, resp.StatusCode, elapsed, route, body)
+	if resp.StatusCode >= 300 {
+		return 1
+	}
+	return 0
+}
+
+func waitForReady(ctx context.Context, client *http.Client, baseURL string) error {
+	var last string
+	for {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, baseURL+"/readyz", nil)
+		if err != nil {
+			return err
+		}
+		if resp, errDo := client.Do(req); errDo == nil {
+			body, _ := io.ReadAll(resp.Body)
+			_ = resp.Body.Close()
+			if resp.StatusCode == http.StatusOK {
+				return nil
+			}
+			last = strings.TrimSpace(string(body))
+		}
+		select {
+		case <-ctx.Done():
+			if last != "" {
+				return fmt.Errorf("%w (last readiness report: %s)", ctx.Err(), last)
+			}
+			return ctx.Err()
+		case <-time.After(200 * time.Millisecond):
+		}
+	}
+}
+
+func printChatResult(w io.Writer, opts ChatOptions, path string, status int, elapsed time.Duration, route chatRoute, body []byte) {
+	_, _ = fmt.Fprintf(w, "\nRoute\n")
+	_, _ = fmt.Fprintf(w, "  endpoint:   POST %s\n", path)
+	_, _ = fmt.Fprintf(w, "  requested:  %s\n", opts.Model)
+	if route.Project != "" {
+		_, _ = fmt.Fprintf(w, "  project:    %s\n", route.Project)
+	}
+	if route.MappedModel != "" {
+		_, _ = fmt.Fprintf(w, "  mapped to:  %s\n", route.MappedModel)
+	}
+	if record := route.Usage; record != nil {
+		_, _ = fmt.Fprintf(w, "  provider:   %s\n", record.Provider)
+		_, _ = fmt.Fprintf(w, "  model:      %s\n", record.Model)
+		_, _ = fmt.Fprintf(w, "  credential: %s\n", record.AuthID)
+		if record.Detail.InputTokens+record.Detail.OutputTokens > 0 {
+			_, _ = fmt.Fprintf(w, "  tokens:     %d in / %d out\n", record.Detail.InputTokens, record.Detail.OutputTokens)
+		}
+	} else if opts.Amp && status < 300 {
+		_, _ = fmt.Fprintf(w, "  provider:   none locally (forwarded to the Amp upstream)\n")
+	}
+	_, _ = fmt.Fprintf(w, "  status:     %d in %s\n", status, elapsed.Round(time.Millisecond))
+
+	_, _ = fmt.Fprintf(w, "\nResponse\n")
+	if content := gjson.GetBytes(body, "choices.0.message.content"); status < 300 && content.Exists() {
+		_, _ = fmt.Fprintf(w, "%s\n", content.String())
+		return
+	}
+	_, _ = fmt.Fprintf(w, "%s\n", strings.TrimSpace(string(body)))
+}
@@ -0,0 +1,51 @@
+package cmd
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/routeinfo"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	coreusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+)
+
+func TestChatRouteCaptureReadsTheServingAttempt(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	routes := make(chan chatRoute, 1)
+	engine := gin.New()
+	engine.Use(chatRouteCapture("/v1/chat/completions", routes))
+	engine.POST("/v1/chat/completions", func(c *gin.Context) {
+		routeinfo.Set(c, routeinfo.Info{RequestedModel: "gpt-5", ResolvedModel: "claude-sonnet-4", RouteType: routeinfo.ModelMapping})
+		c.Set(util.UsageRecordKey, coreusage.Record{Provider: "claude", Model: "claude-sonnet-4", AuthID: "claude-a.json"})
+		c.JSON(http.StatusOK, gin.H{"ok": true})
+	})
+	engine.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil))
+
+	route := <-routes
+	if route.Status != http.StatusOK || route.MappedModel != "claude-sonnet-4" || route.Usage == nil || route.Usage.AuthID != "claude-a.json" {
+		t.Fatalf("route = %+v", route)
+	}
+}
+
+func TestPrintChatResult(t *testing.T) {
+	var out bytes.Buffer
+	route := chatRoute{Status: http.StatusOK, MappedModel: "claude-sonnet-4", Usage: &coreusage.Record{Provider: "claude", Model: "claude-sonnet-4", AuthID: "claude-a.json"}}
+	body := []byte(`{"choices":[{"message":{"content":"pong"}}]}`)
+	printChatResult(&out, ChatOptions{Model: "gpt-5"}, "/v1/chat/completions", http.StatusOK, 120*time.Millisecond, route, body)
+	for _, want := range []string{"mapped to:  claude-sonnet-4", "credential: claude-a.json", "status:     200 in 120ms", "pong"} {
+		if !strings.Contains(out.String(), want) {
+			t.Fatalf("output missing %q:\n%s", want, out.String())
+		}
+	}
+}
+
+func TestDoChatRequiresModelAndPrompt(t *testing.T) {
+	if code := DoChat(nil, "", ChatOptions{Model: "gpt-5"}); code != 2 {
+		t.Fatalf("exit code = %d, want 2", code)
+	}
+}
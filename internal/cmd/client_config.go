@@ -0,0 +1,202 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	sdkAuth "github.com/router-for-me/CLIProxyAPI/v6/sdk/auth"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	"gopkg.in/yaml.v3"
+)
+
+func init() {
+	registerSubcommand("client-config", newClientConfigCommand)
+}
+
+// clientConfigTargets lists the supported tools in the order shown in errors and help.
+var clientConfigTargets = []string{"amp", "cline", "continue", "aider"}
+
+// recommendedModelFamilies ranks model name prefixes suited to coding assistants.
+var recommendedModelFamilies = []string{
+	"claude-sonnet-4-5", "claude-opus-4-5", "claude-sonnet-4", "gpt-5-codex", "gpt-5",
+	"gemini-2.5-pro", "gemini-3-pro", "qwen3-coder", "claude-haiku-4-5", "gemini-2.5-flash",
+}
+
+// ClientConfigOptions controls the client-config subcommand.
+type ClientConfigOptions struct {
+	// Target is the client tool: amp, cline, continue or aider.
+	Target string
+	// Host overrides the host in the base URL, e.g. for clients on other machines.
+	Host string
+	// APIKey overrides the client API key; defaults to the first api-keys entry.
+	APIKey string
+	// Model overrides the recommended model.
+	Model string
+}
+
+func newClientConfigCommand() *Subcommand {
+	fs := flag.NewFlagSet("client-config", flag.ExitOnError)
+	opts := &ClientConfigOptions{}
+	fs.StringVar(&opts.Target, "target", "", "Client tool: "+strings.Join(clientConfigTargets, "|"))
+	fs.StringVar(&opts.Host, "host", "", "Host clients use to reach the proxy (defaults to the configured host or 127.0.0.1)")
+	fs.StringVar(&opts.APIKey, "api-key", "", "Client API key (defaults to the first api-keys entry)")
+	fs.StringVar(&opts.Model, "model", "", "Model to configure (defaults to a recommended available model)")
+	return &Subcommand{
+		Name:  "client-config",
+		Usage: "client-config -target " + strings.Join(clientConfigTargets, "|") + "    print ready-to-paste client configuration",
+		Flags: fs,
+		Run: func(cfg *config.Config, _ string, _ []string) int {
+			var auths []*coreauth.Auth
+			tokenStore := sdkAuth.GetTokenStore()
+			if dirSetter, ok := tokenStore.(interface{ SetBaseDir(string) }); ok {
+				dirSetter.SetBaseDir(cfg.AuthDir)
+			}
+			if list, errList := tokenStore.List(context.Background()); errList == nil {
+				auths = list
+			}
+			if err := WriteClientConfig(os.Stdout, cfg, auths, *opts); err != nil {
+				_, _ = fmt.Fprintf(os.Stderr, "client-config: %v\n", err)
+				return 2
+			}
+			return 0
+		},
+	}
+}
+
+// proxyBaseURL is the root URL clients use to reach the proxy.
+func proxyBaseURL(cfg *config.Config, hostOverride string) string {
+	host := hostOverride
+	if host == "" {
+		host = cfg.Host
+	}
+	host = config.AdvertisedHost(host)
+	scheme := "http"
+	if cfg.TLS.Enable {
+		scheme = "https"
+	}
+	return scheme + "://" + net.JoinHostPort(host, strconv.Itoa(cfg.Port))
+}
+
+// recommendedModels returns available models ranked by recommendedModelFamilies, falling
+// back to the alphabetically first served models.
+func recommendedModels(cfg *config.Config, auths []*coreauth.Auth, limit int) []string {
+	served := servedModels(cfg, auths)
+	names := make([]string, 0, len(served))
+	for name := range served {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var out []string
+	picked := make(map[string]bool)
+	for _, family := range recommendedModelFamilies {
+		// Prefer the undated alias or the newest dated snapshot of a family.
+		for i := len(names) - 1; i >= 0; i-- {
+			if strings.HasPrefix(names[i], family) && !picked[names[i]] && !strings.Contains(names[i], "thinking") {
+				out = append(out, names[i])
+				picked[names[i]] = true
+				break
+			}
+		}
+		if len(out) >= limit {
+			return out
+		}
+	}
+	for _, name := range names {
+		if len(out) >= limit {
+			break
+		}
+		if !picked[name] {
+			out = append(out, name)
+			picked[name] = true
+		}
+	}
+	return out
+}
+
+// WriteClientConfig renders the configuration snippet for opts.Target to w.
+func WriteClientConfig(w io.Writer, cfg *config.Config, auths []*coreauth.Auth, opts ClientConfigOptions) error {
+	if cfg == nil {
+		cfg = &config.Config{}
+	}
+	target := strings.ToLower(strings.TrimSpace(opts.Target))
+	base := proxyBaseURL(cfg, opts.Host)
+	apiKey := opts.APIKey
+	if apiKey == "" && len(cfg.APIKeys) > 0 {
+		apiKey = cfg.APIKeys[0]
+	}
+	if apiKey == "" {
+		apiKey = "<your-proxy-api-key>"
+	}
+	models := recommendedModels(cfg, auths, 3)
+	if opts.Model != "" {
+		rest := models[:0]
+		for _, model := range models {
+			if model != opts.Model {
+				rest = append(rest, model)
+			}
+		}
+		models = append([]string{opts.Model}, rest...)
+	}
+	if len(models) == 0 {
+		models = []string{"<model-name>"}
+	}
+
+	switch target {
+	case "amp":
+		settings, _ := json.MarshalIndent(map[string]string{"amp.url": base}, "", "  ")
+		_, _ = fmt.Fprintf(w, "# ~/.config/amp/settings.json\n%s\n\n", settings)
+		_, _ = fmt.Fprintf(w, "# Shell environment\nexport AMP_URL=%s\nexport AMP_API_KEY=%s\n", base, apiKey)
+		if len(cfg.AmpCode.ModelMappings) == 0 {
+			_, _ = fmt.Fprintf(w, "\n# Amp picks its own models; add ampcode.model-mappings to route them to: %s\n", strings.Join(models, ", "))
+		}
+	case "cline":
+		_, _ = fmt.Fprintf(w, "# Cline > Settings > API Configuration\n")
+		_, _ = fmt.Fprintf(w, "API Provider: OpenAI Compatible\nBase URL:     %s/v1\nAPI Key:      %s\nModel ID:     %s\n", base, apiKey, models[0])
+		if len(models) > 1 {
+			_, _ = fmt.Fprintf(w, "\n# Other available models: %s\n", strings.Join(models[1:], ", "))
+		}
+	case "continue":
+		type continueModel struct {
+			Name     string   `yaml:"name"`
+			Provider string   `yaml:"provider"`
+			Model    string   `yaml:"model"`
+			APIBase  string   `yaml:"apiBase"`
+			APIKey   string   `yaml:"apiKey"`
+			Roles    []string `yaml:"roles"`
+		}
+		doc := struct {
+			Name    string          `yaml:"name"`
+			Version string          `yaml:"version"`
+			Schema  string          `yaml:"schema"`
+			Models  []continueModel `yaml:"models"`
+		}{Name: "CLIProxyAPI", Version: "1.0.0", Schema: "v1"}
+		for _, model := range models {
+			doc.Models = append(doc.Models, continueModel{
+				Name: "CLIProxyAPI " + model, Provider: "openai", Model: model,
+				APIBase: base + "/v1", APIKey: apiKey, Roles: []string{"chat", "edit", "apply"},
+			})
+		}
+		out, err := yaml.Marshal(doc)
+		if err != nil {
+			return err
+		}
+		_, _ = fmt.Fprintf(w, "# ~/.continue/config.yaml\n%s", out)
+	case "aider":
+		_, _ = fmt.Fprintf(w, "# .aider.conf.yml\nopenai-api-base: %s/v1\nopenai-api-key: %s\nmodel: openai/%s\n", base, apiKey, models[0])
+		if len(models) > 1 {
+			_, _ = fmt.Fprintf(w, "weak-model: openai/%s\n", models[len(models)-1])
+		}
+	default:
+		return fmt.Errorf("unknown target %q (supported: %s)", opts.Target, strings.Join(clientConfigTargets, ", "))
+	}
+	return nil
+}
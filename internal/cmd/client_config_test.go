@@ -0,0 +1,50 @@
+package cmd
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	"gopkg.in/yaml.v3"
+)
+
+func TestWriteClientConfig(t *testing.T) {
+	cfg := &config.Config{Port: 8317}
+	cfg.APIKeys = []string{"sk-proxy"}
+	auths := []*coreauth.Auth{{ID: "claude.json", Provider: "claude"}}
+
+	var buf bytes.Buffer
+	if err := WriteClientConfig(&buf, cfg, auths, ClientConfigOptions{Target: "continue"}); err != nil {
+		t.Fatalf("continue: %v", err)
+	}
+	var doc struct {
+		Models []struct {
+			Model   string `yaml:"model"`
+			APIBase string `yaml:"apiBase"`
+			APIKey  string `yaml:"apiKey"`
+		} `yaml:"models"`
+	}
+	if err := yaml.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatalf("continue output is not valid yaml: %v\n%s", err, buf.String())
+	}
+	if len(doc.Models) == 0 || doc.Models[0].APIBase != "http://127.0.0.1:8317/v1" || doc.Models[0].APIKey != "sk-proxy" {
+		t.Fatalf("unexpected continue config: %+v", doc)
+	}
+	if !strings.HasPrefix(doc.Models[0].Model, "claude-") {
+		t.Fatalf("expected a recommended claude model, got %q", doc.Models[0].Model)
+	}
+
+	buf.Reset()
+	if err := WriteClientConfig(&buf, cfg, auths, ClientConfigOptions{Target: "aider", Host: "proxy.lan", Model: "claude-opus-4-5-20251101"}); err != nil {
+		t.Fatalf("aider: %v", err)
+	}
+	if out := buf.String(); !strings.Contains(out, "openai-api-base: http://proxy.lan:8317/v1") || !strings.Contains(out, "model: openai/claude-opus-4-5-20251101") {
+		t.Fatalf("unexpected aider config:\n%s", out)
+	}
+
+	if err := WriteClientConfig(&buf, cfg, auths, ClientConfigOptions{Target: "vim"}); err == nil {
+		t.Fatal("expected unknown target to fail")
+	}
+}
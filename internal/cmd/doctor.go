@@ -0,0 +1,476 @@
+package cmd
+
+import (
+	"context"
+	"crypto/tls"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	sdkAuth "github.com/router-for-me/CLIProxyAPI/v6/sdk/auth"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	sdkconfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+)
+
+func init() {
+	registerSubcommand("doctor", newDoctorCommand)
+}
+
+// DoctorOptions controls the doctor subcommand.
+type DoctorOptions struct {
+	// Offline skips the endpoint reachability probes.
+	Offline bool
+	// Color enables ANSI colors in the report.
+	Color bool
+}
+
+func newDoctorCommand() *Subcommand {
+	fs := flag.NewFlagSet("doctor", flag.ExitOnError)
+	offline := fs.Bool("offline", false, "Skip endpoint reachability checks")
+	noColor := fs.Bool("no-color", false, "Disable colored output")
+	return &Subcommand{
+		Name:  "doctor",
+		Usage: "doctor [-offline] [-no-color]    check credentials, model mappings and listener settings",
+		Flags: fs,
+		Run: func(cfg *config.Config, _ string, _ []string) int {
+			return DoDoctor(cfg, DoctorOptions{Offline: *offline, Color: !*noColor && isTerminal(os.Stdout)})
+		},
+	}
+}
+
+type doctorLevel int
+
+const (
+	doctorOK doctorLevel = iota
+	doctorWarn
+	doctorFail
+)
+
+type doctorFinding struct {
+	Level   doctorLevel
+	Subject string
+	Message string
+}
+
+type doctorSection struct {
+	Title    string
+	Findings []doctorFinding
+}
+
+// providerEndpoints are the upstream hosts probed for credentials without a base URL.
+var providerEndpoints = map[string]string{
+	"claude":      "https://api.anthropic.com",
+	"codex":       "https://chatgpt.com/backend-api/codex",
+	"gemini":      "https://generativelanguage.googleapis.com",
+	"gemini-cli":  "https://cloudcode-pa.googleapis.com",
+	"vertex":      "https://aiplatform.googleapis.com",
+	"antigravity": "https://daily-cloudcode-pa.googleapis.com",
+	"qwen":        "https://portal.qwen.ai/v1",
+	"iflow":       "https://apis.iflow.cn/v1",
+}
+
+// DoDoctor checks the configured credentials, model mappings and listener settings and
+// prints a report. It returns 1 when any check failed and 0 otherwise.
+func DoDoctor(cfg *config.Config, opts DoctorOptions) int {
+	if cfg == nil {
+		cfg = &config.Config{}
+	}
+	ctx := context.Background()
+
+	var auths []*coreauth.Auth
+	var storeFinding *doctorFinding
+	tokenStore := sdkAuth.GetTokenStore()
+	if dirSetter, ok := tokenStore.(interface{ SetBaseDir(string) }); ok {
+		dirSetter.SetBaseDir(cfg.AuthDir)
+	}
+	listCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	list, errList := tokenStore.List(listCtx)
+	cancel()
+	if errList != nil {
+		storeFinding = &doctorFinding{Level: doctorFail, Subject: "auth store", Message: errList.Error()}
+	}
+	auths = list
+
+	var probe endpointProbe
+	if !opts.Offline {
+		probe = newEndpointProbe(cfg.ProxyURL)
+	}
+
+	credentials := checkCredentials(cfg, auths, probe, time.Now())
+	if storeFinding != nil {
+		credentials = append([]doctorFinding{*storeFinding}, credentials...)
+	}
+	sections := []doctorSection{
+		{Title: "Listener", Findings: checkListener(cfg)},
+		{Title: "Credentials", Findings: credentials},
+		{Title: "Model mappings", Findings: checkModelMappings(cfg, auths)},
+	}
+	if printDoctorReport(os.Stdout, sections, opts.Color) > 0 {
+		return 1
+	}
+	return 0
+}
+
+// checkListener validates host, port and TLS settings and whether the address is free.
+func checkListener(cfg *config.Config) []doctorFinding {
+	var out []doctorFinding
+	addr := cfg.ListenAddress()
+	if cfg.Port <= 0 || cfg.Port > 65535 {
+		return append(out, doctorFinding{Level: doctorFail, Subject: "port", Message: fmt.Sprintf("invalid port %d", cfg.Port)})
+	}
+	if cfg.Host == "" {
+		out = append(out, doctorFinding{Level: doctorOK, Subject: addr, Message: "binds all interfaces"})
+	} else if ip := net.ParseIP(cfg.Host); ip == nil && cfg.Host != "localhost" {
+		if _, errLookup := net.LookupHost(cfg.Host); errLookup != nil {
+			out = append(out, doctorFinding{Level: doctorFail, Subject: addr, Message: "host does not resolve: " + errLookup.Error()})
+		}
+	}
+	if ln, errListen := net.Listen("tcp", addr); errListen != nil {
+		out = append(out, doctorFinding{Level: doctorWarn, Subject: addr, Message: "address not available (is the proxy already running?): " + errListen.Error()})
+	} else {
+		_ = ln.Close()
+		out = append(out, doctorFinding{Level: doctorOK, Subject: addr, Message: "address is free"})
+	}
+	if cfg.TLS.Enable {
+		if _, errTLS := tls.LoadX509KeyPair(cfg.TLS.Cert, cfg.TLS.Key); errTLS != nil {
+			out = append(out, doctorFinding{Level: doctorFail, Subject: "tls", Message: errTLS.Error()})
+		} else {
+			out = append(out, doctorFinding{Level: doctorOK, Subject: "tls", Message: "certificate and key load"})
+		}
+	}
+	if len(cfg.APIKeys) == 0 {
+		out = append(out, doctorFinding{Level: doctorWarn, Subject: "api-keys", Message: "no client API keys configured"})
+	} else {
+		out = append(out, doctorFinding{Level: doctorOK, Subject: "api-keys", Message: fmt.Sprintf("%d client API key(s)", len(cfg.APIKeys))})
+	}
+	return out
+}
+
+// checkCredentials inspects auth files (token material, expiry, persisted quota state) and
+// API key entries, and probes each distinct upstream endpoint once when probe is set.
+func checkCredentials(cfg *config.Config, auths []*coreauth.Auth, probe endpointProbe, now time.Time) []doctorFinding {
+	var out []doctorFinding
+	sort.Slice(auths, func(i, j int) bool { return auths[i].ID < auths[j].ID })
+	for _, auth := range auths {
+		if auth == nil {
+			continue
+		}
+		subject := credentialSubject(auth)
+		if auth.Disabled {
+			out = append(out, doctorFinding{Level: doctorWarn, Subject: subject, Message: "disabled"})
+			continue
+		}
+		if !hasTokenMaterial(auth.Metadata) {
+			out = append(out, doctorFinding{Level: doctorFail, Subject: subject, Message: "no access token, refresh token or API key"})
+			continue
+		}
+		finding := doctorFinding{Level: doctorOK, Subject: subject, Message: "token present"}
+		if expiry, ok := auth.ExpirationTime(); ok {
+			switch {
+			case !expiry.After(now) && hasMetadataString(auth.Metadata, "refresh_token"):
+				finding = doctorFinding{Level: doctorWarn, Subject: subject, Message: fmt.Sprintf("access token expired %s ago; it will be refreshed on next use", now.Sub(expiry).Round(time.Minute))}
+			case !expiry.After(now):
+				finding = doctorFinding{Level: doctorFail, Subject: subject, Message: fmt.Sprintf("expired %s ago and cannot be refreshed", now.Sub(expiry).Round(time.Minute))}
+			default:
+				finding.Message = fmt.Sprintf("token valid for %s", expiry.Sub(now).Round(time.Minute))
+			}
+		}
+		if auth.Quota.Exceeded || auth.Unavailable {
+			finding.Level = max(finding.Level, doctorWarn)
+			finding.Message += "; last known quota state: exhausted"
+		}
+		out = append(out, finding)
+		out = append(out, probeFinding(probe, subject, providerEndpoints[strings.ToLower(auth.Provider)], auth.ProxyURL)...)
+	}
+
+	apiKeyEntry := func(kind string, index int, apiKey, baseURL, proxyURL, provider string) {
+		subject := fmt.Sprintf("%s[%d] %s", kind, index, util.HideAPIKey(apiKey))
+		if strings.TrimSpace(apiKey) == "" {
+			out = append(out, doctorFinding{Level: doctorFail, Subject: subject, Message: "empty api-key"})
+			return
+		}
+		endpoint := providerEndpoints[provider]
+		if baseURL != "" {
+			if parsed, errParse := url.Parse(baseURL); errParse != nil || parsed.Scheme == "" || parsed.Host == "" {
+				out = append(out, doctorFinding{Level: doctorFail, Subject: subject, Message: fmt.Sprintf("invalid base-url %q", baseURL)})
+				return
+			}
+			endpoint = baseURL
+		}
+		out = append(out, doctorFinding{Level: doctorOK, Subject: subject, Message: "configured"})
+		out = append(out, probeFinding(probe, subject, endpoint, proxyURL)...)
+	}
+	for i, key := range cfg.GeminiKey {
+		apiKeyEntry("gemini-api-key", i, key.APIKey, key.BaseURL, key.ProxyURL, "gemini")
+	}
+	for i, key := range cfg.ClaudeKey {
+		apiKeyEntry("claude-api-key", i, key.APIKey, key.BaseURL, key.ProxyURL, "claude")
+	}
+	for i, key := range cfg.CodexKey {
+		apiKeyEntry("codex-api-key", i, key.APIKey, key.BaseURL, key.ProxyURL, "codex")
+	}
+	for i, key := range cfg.VertexCompatAPIKey {
+		apiKeyEntry("vertex-api-key", i, key.APIKey, key.BaseURL, key.ProxyURL, "vertex")
+	}
+	for _, compat := range cfg.OpenAICompatibility {
+		for i, entry := range compat.APIKeyEntries {
+			apiKeyEntry("openai-compatibility "+compat.Name, i, entry.APIKey, compat.BaseURL, entry.ProxyURL, "")
+		}
+	}
+	if len(out) == 0 {
+		out = append(out, doctorFinding{Level: doctorFail, Subject: "credentials", Message: "no credentials configured; run a -login command or add API keys"})
+	}
+	return out
+}
+
+func credentialSubject(auth *coreauth.Auth) string {
+	name := auth.ID
+	if auth.FileName != "" {
+		name = filepath.Base(auth.FileName)
+	}
+	subject := auth.Provider + " " + name
+	if _, account := auth.AccountInfo(); account != "" && !strings.Contains(name, account) {
+		subject += " (" + account + ")"
+	}
+	return subject
+}
+
+func hasTokenMaterial(metadata map[string]any) bool {
+	for _, key := range []string{"access_token", "refresh_token", "api_key", "cookie", "id_token"} {
+		if hasMetadataString(metadata, key) {
+			return true
+		}
+	}
+	if token, ok := metadata["token"].(map[string]any); ok {
+		return hasTokenMaterial(token)
+	}
+	// Vertex service accounts carry their key under "service_account".
+	_, ok := metadata["service_account"].(map[string]any)
+	return ok
+}
+
+func hasMetadataString(metadata map[string]any, key string) bool {
+	v, ok := metadata[key].(string)
+	return ok && strings.TrimSpace(v) != ""
+}
+
+// endpointProbe reports whether endpoint answers through proxyURL.
+type endpointProbe func(endpoint, proxyURL string) error
+
+// newEndpointProbe returns a probe that treats any HTTP response as reachable and caches
+// results per endpoint and proxy. globalProxy applies when a credential sets none.
+func newEndpointProbe(globalProxy string) endpointProbe {
+	cache := make(map[string]error)
+	return func(endpoint, proxyURL string) error {
+		if proxyURL == "" {
+			proxyURL = globalProxy
+		}
+		key := endpoint + "|" + proxyURL
+		if err, ok := cache[key]; ok {
+			return err
+		}
+		client := util.SetProxy(&sdkconfig.SDKConfig{ProxyURL: proxyURL}, &http.Client{Timeout: 5 * time.Second})
+		var err error
+		resp, errGet := client.Get(endpoint)
+		if errGet != nil {
+			err = errGet
+		} else {
+			_ = resp.Body.Close()
+		}
+		cache[key] = err
+		return err
+	}
+}
+
+func probeFinding(probe endpointProbe, subject, endpoint, proxyURL string) []doctorFinding {
+	if probe == nil || endpoint == "" {
+		return nil
+	}
+	if err := probe(endpoint, proxyURL); err != nil {
+		return []doctorFinding{{Level: doctorFail, Subject: subject, Message: fmt.Sprintf("%s unreachable: %v", endpoint, err)}}
+	}
+	return []doctorFinding{{Level: doctorOK, Subject: subject, Message: endpoint + " reachable"}}
+}
+
+// servedModels maps every model name a client can request to the providers serving it,
+// derived from the static model catalogue of OAuth credentials and the model lists of API keys.
+func servedModels(cfg *config.Config, auths []*coreauth.Auth) map[string][]string {
+	served := make(map[string][]string)
+	add := func(model, provider string) {
+		if model = strings.TrimSpace(model); model == "" {
+			return
+		}
+		for _, existing := range served[model] {
+			if existing == provider {
+				return
+			}
+		}
+		served[model] = append(served[model], provider)
+	}
+	addStatic := func(provider string) {
+		for _, model := range registry.GetStaticModelDefinitionsByChannel(provider) {
+			add(model.ID, provider)
+		}
+		for _, alias := range cfg.OAuthModelAlias[provider] {
+			add(alias.Alias, provider)
+		}
+	}
+	for _, auth := range auths {
+		if auth != nil && !auth.Disabled {
+			addStatic(strings.ToLower(auth.Provider))
+		}
+	}
+	addModels := func(provider string, names, aliases []string) {
+		if len(names) == 0 {
+			addStatic(provider)
+			return
+		}
+		for i := range names {
+			add(names[i], provider)
+			add(aliases[i], provider)
+		}
+	}
+	for _, key := range cfg.GeminiKey {
+		names, aliases := modelNames(key.Models)
+		addModels("gemini", names, aliases)
+	}
+	for _, key := range cfg.ClaudeKey {
+		names, aliases := modelNames(key.Models)
+		addModels("claude", names, aliases)
+	}
+	for _, key := range cfg.CodexKey {
+		names, aliases := modelNames(key.Models)
+		addModels("codex", names, aliases)
+	}
+	for _, key := range cfg.VertexCompatAPIKey {
+		names, aliases := modelNames(key.Models)
+		addModels("vertex", names, aliases)
+	}
+	for _, compat := range cfg.OpenAICompatibility {
+		for _, model := range compat.Models {
+			add(model.Name, compat.Name)
+			add(model.Alias, compat.Name)
+		}
+	}
+	return served
+}
+
+func modelNames[T interface {
+	GetName() string
+	GetAlias() string
+}](models []T) ([]string, []string) {
+	names := make([]string, len(models))
+	aliases := make([]string, len(models))
+	for i, model := range models {
+		names[i] = model.GetName()
+		aliases[i] = model.GetAlias()
+	}
+	return names, aliases
+}
+
+// checkModelMappings verifies that every Amp and project mapping targets a served model and
+// flags targets that drop capabilities of the source model (thinking, context size).
+func checkModelMappings(cfg *config.Config, auths []*coreauth.Auth) []doctorFinding {
+	served := servedModels(cfg, auths)
+	var out []doctorFinding
+	check := func(scope string, mapping config.AmpModelMapping) {
+		subject := fmt.Sprintf("%s %s -> %s", scope, mapping.From, mapping.To)
+		if mapping.Regex {
+			if _, errRegex := regexp.Compile(mapping.From); errRegex != nil {
+				out = append(out, doctorFinding{Level: doctorFail, Subject: subject, Message: "invalid regex: " + errRegex.Error()})
+				return
+			}
+		}
+		providers := served[mapping.To]
+		if len(providers) == 0 {
+			level := doctorFail
+			message := "no configured provider serves the target model"
+			if scope == "ampcode" && cfg.AmpCode.UpstreamURL != "" {
+				level = doctorWarn
+				message += "; requests fall back to the Amp upstream"
+			}
+			out = append(out, doctorFinding{Level: level, Subject: subject, Message: message})
+			return
+		}
+		finding := doctorFinding{Level: doctorOK, Subject: subject, Message: "served by " + strings.Join(providers, ", ")}
+		if !mapping.Regex {
+			if problems := capabilityGaps(registry.LookupStaticModelInfo(mapping.From), registry.LookupStaticModelInfo(mapping.To)); len(problems) > 0 {
+				finding.Level = doctorWarn
+				finding.Message += "; " + strings.Join(problems, "; ")
+			}
+		}
+		out = append(out, finding)
+	}
+	for _, mapping := range cfg.AmpCode.ModelMappings {
+		check("ampcode", mapping)
+	}
+	for _, p := range cfg.Projects {
+		for _, mapping := range p.ModelMappings {
+			check("project "+p.Name, mapping)
+		}
+	}
+	if len(out) == 0 {
+		out = append(out, doctorFinding{Level: doctorOK, Subject: "mappings", Message: "none configured"})
+	}
+	return out
+}
+
+func capabilityGaps(from, to *registry.ModelInfo) []string {
+	if from == nil || to == nil {
+		return nil
+	}
+	var gaps []string
+	if from.Thinking != nil && to.Thinking == nil {
+		gaps = append(gaps, "target does not support thinking")
+	}
+	fromContext, toContext := max(from.ContextLength, from.InputTokenLimit), max(to.ContextLength, to.InputTokenLimit)
+	if toContext > 0 && fromContext > toContext {
+		gaps = append(gaps, fmt.Sprintf("target context window is smaller (%d < %d)", toContext, fromContext))
+	}
+	fromOutput, toOutput := max(from.MaxCompletionTokens, from.OutputTokenLimit), max(to.MaxCompletionTokens, to.OutputTokenLimit)
+	if toOutput > 0 && fromOutput > toOutput {
+		gaps = append(gaps, fmt.Sprintf("target output limit is smaller (%d < %d)", toOutput, fromOutput))
+	}
+	return gaps
+}
+
+// printDoctorReport writes the sections to w and returns the number of failures.
+func printDoctorReport(w io.Writer, sections []doctorSection, color bool) int {
+	labels := map[doctorLevel]string{doctorOK: " OK ", doctorWarn: "WARN", doctorFail: "FAIL"}
+	colors := map[doctorLevel]string{doctorOK: "\033[32m", doctorWarn: "\033[33m", doctorFail: "\033[31m"}
+	counts := make(map[doctorLevel]int)
+	for _, section := range sections {
+		_, _ = fmt.Fprintf(w, "\n%s\n", section.Title)
+		for _, finding := range section.Findings {
+			counts[finding.Level]++
+			label := labels[finding.Level]
+			if color {
+				label = colors[finding.Level] + label + "\033[0m"
+			}
+			_, _ = fmt.Fprintf(w, "  [%s] %s: %s\n", label, finding.Subject, finding.Message)
+		}
+	}
+	_, _ = fmt.Fprintf(w, "\n%d ok, %d warning(s), %d failure(s)\n", counts[doctorOK], counts[doctorWarn], counts[doctorFail])
+	return counts[doctorFail]
+}
+
+// isTerminal reports whether f is an interactive terminal and NO_COLOR is not set.
+func isTerminal(f *os.File) bool {
+	if os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
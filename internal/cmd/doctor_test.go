@@ -0,0 +1,74 @@
+package cmd
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+)
+
+func findingFor(findings []doctorFinding, subject string) (doctorFinding, bool) {
+	for _, f := range findings {
+		if strings.Contains(f.Subject, subject) {
+			return f, true
+		}
+	}
+	return doctorFinding{}, false
+}
+
+func TestCheckCredentials(t *testing.T) {
+	now := time.Date(2026, 1, 2, 12, 0, 0, 0, time.UTC)
+	auths := []*coreauth.Auth{
+		{ID: "claude-ok.json", Provider: "claude", Metadata: map[string]any{"access_token": "a", "expired": now.Add(time.Hour).Format(time.RFC3339)}},
+		{ID: "codex-stale.json", Provider: "codex", Metadata: map[string]any{"access_token": "a", "refresh_token": "r", "expired": now.Add(-time.Hour).Format(time.RFC3339)}},
+		{ID: "qwen-empty.json", Provider: "qwen", Metadata: map[string]any{"email": "x@example.com"}},
+	}
+	findings := checkCredentials(&config.Config{}, auths, nil, now)
+
+	if f, _ := findingFor(findings, "claude-ok.json"); f.Level != doctorOK {
+		t.Fatalf("expected valid claude token, got %+v", f)
+	}
+	if f, _ := findingFor(findings, "codex-stale.json"); f.Level != doctorWarn {
+		t.Fatalf("expected refreshable expired token to warn, got %+v", f)
+	}
+	if f, _ := findingFor(findings, "qwen-empty.json"); f.Level != doctorFail {
+		t.Fatalf("expected credential without tokens to fail, got %+v", f)
+	}
+}
+
+func TestCheckModelMappings(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.AmpCode.ModelMappings = []config.AmpModelMapping{
+		{From: "claude-opus-4-5-20251101", To: "claude-sonnet-4-5-20250929"},
+		{From: "gpt-5", To: "missing-model"},
+		{From: "(", To: "claude-sonnet-4-5-20250929", Regex: true},
+	}
+	auths := []*coreauth.Auth{{ID: "claude.json", Provider: "claude"}}
+	findings := checkModelMappings(cfg, auths)
+
+	if f, _ := findingFor(findings, "-> claude-sonnet-4-5-20250929"); f.Level == doctorFail {
+		t.Fatalf("expected served target to pass, got %+v", f)
+	}
+	if f, _ := findingFor(findings, "-> missing-model"); f.Level != doctorFail {
+		t.Fatalf("expected unserved target to fail, got %+v", f)
+	}
+	if f, _ := findingFor(findings, "ampcode ( ->"); f.Level != doctorFail || !strings.Contains(f.Message, "regex") {
+		t.Fatalf("expected invalid regex to fail, got %+v", f)
+	}
+
+	var buf bytes.Buffer
+	if failures := printDoctorReport(&buf, []doctorSection{{Title: "Model mappings", Findings: findings}}, false); failures != 2 {
+		t.Fatalf("expected 2 failures, got %d\n%s", failures, buf.String())
+	}
+}
+
+func TestParseSubcommandIgnoresUnknownArguments(t *testing.T) {
+	configPath := ""
+	sub, args, err := ParseSubcommand([]string{"start", "--verbose"}, &configPath)
+	if sub != nil || args != nil || err != nil {
+		t.Fatalf("ParseSubcommand(unknown) = %v, %v, %v; want the server to start", sub, args, err)
+	}
+}
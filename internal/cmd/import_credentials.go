@@ -0,0 +1,87 @@
+package cmd
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/credimport"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	sdkAuth "github.com/router-for-me/CLIProxyAPI/v6/sdk/auth"
+)
+
+func init() {
+	registerSubcommand("import-credentials", newImportCredentialsCommand)
+}
+
+// ImportCredentialsOptions controls the import-credentials subcommand.
+type ImportCredentialsOptions struct {
+	// Dir holds the token files to import.
+	Dir string
+	credimport.Options
+}
+
+func newImportCredentialsCommand() *Subcommand {
+	fs := flag.NewFlagSet("import-credentials", flag.ExitOnError)
+	opts := &ImportCredentialsOptions{}
+	fs.StringVar(&opts.Dir, "dir", "", "Directory of token JSON files")
+	fs.StringVar(&opts.ProjectID, "project-id", "", "Google Cloud project of gemini CLI tokens")
+	fs.BoolVar(&opts.Overwrite, "overwrite", false, "Replace credentials that already exist")
+	fs.BoolVar(&opts.DryRun, "dry-run", false, "Validate and report without saving")
+	return &Subcommand{
+		Name:  "import-credentials",
+		Usage: "import-credentials -dir <path> [-project-id id] [-overwrite] [-dry-run]    import gemini CLI, Claude CLI and Codex token files",
+		Flags: fs,
+		Run: func(cfg *config.Config, _ string, _ []string) int {
+			return DoImportCredentials(os.Stdout, cfg, *opts)
+		},
+	}
+}
+
+// DoImportCredentials imports the token files of opts.Dir into the credential store and
+// prints one line per file. It returns the process exit code: 1 when a file was invalid.
+func DoImportCredentials(w io.Writer, cfg *config.Config, opts ImportCredentialsOptions) int {
+	if strings.TrimSpace(opts.Dir) == "" {
+		_, _ = fmt.Fprintln(os.Stderr, "import-credentials: -dir is required")
+		return 2
+	}
+	authDir, err := util.ResolveAuthDir(cfg.AuthDir)
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "import-credentials: %v\n", err)
+		return 1
+	}
+	sources, err := credimport.ReadDir(opts.Dir)
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "import-credentials: %v\n", err)
+		return 1
+	}
+	store := sdkAuth.GetTokenStore()
+	if setter, ok := store.(interface{ SetBaseDir(string) }); ok {
+		setter.SetBaseDir(authDir)
+	}
+	results := credimport.Import(context.Background(), store, authDir, sources, opts.Options, time.Now())
+	code, imported := 0, 0
+	for _, result := range results {
+		switch result.Status {
+		case credimport.StatusImported:
+			imported++
+			_, _ = fmt.Fprintf(w, "%-9s %s -> %s (%s)\n", result.Status, result.Source, result.File, result.Provider)
+		case credimport.StatusExists:
+			_, _ = fmt.Fprintf(w, "%-9s %s -> %s (use -overwrite to replace)\n", result.Status, result.Source, result.File)
+		default:
+			code = 1
+			_, _ = fmt.Fprintf(w, "%-9s %s: %s\n", result.Status, result.Source, result.Error)
+		}
+	}
+	verb := "imported"
+	if opts.DryRun {
+		verb = "would import"
+	}
+	_, _ = fmt.Fprintf(w, "%s %d of %d files into %s\n", verb, imported, len(results), authDir)
+	return code
+}
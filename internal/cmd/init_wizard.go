@@ -0,0 +1,377 @@
+package cmd
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+)
+
+const (
+	defaultInitPort    = 8317
+	defaultInitAuthDir = "~/.cli-proxy-api"
+	defaultAmpUpstream = "https://ampcode.com"
+)
+
+func init() {
+	registerSubcommand("init", newInitCommand)
+}
+
+// initProvider is a credential type the wizard can log in to.
+type initProvider struct {
+	Name  string
+	Label string
+	// MappingTarget is the model Amp's Claude requests are mapped to when this is the
+	// primary provider and no Claude credential is added.
+	MappingTarget string
+	Login         func(cfg *config.Config, options *LoginOptions)
+}
+
+// initProviders lists the OAuth logins offered by the wizard, in menu order.
+var initProviders = []initProvider{
+	{Name: "claude", Label: "Claude (Anthropic account)", Login: DoClaudeLogin},
+	{Name: "codex", Label: "Codex (OpenAI account)", MappingTarget: "gpt-5", Login: DoCodexLogin},
+	{Name: "gemini", Label: "Gemini CLI (Google account)", MappingTarget: "gemini-2.5-pro", Login: func(cfg *config.Config, options *LoginOptions) {
+		DoLogin(cfg, "", options)
+	}},
+	{Name: "antigravity", Label: "Antigravity (Google account)", MappingTarget: "gemini-2.5-pro", Login: DoAntigravityLogin},
+	{Name: "qwen", Label: "Qwen", MappingTarget: "qwen3-coder-plus", Login: DoQwenLogin},
+	{Name: "iflow", Label: "iFlow", MappingTarget: "qwen3-coder-plus", Login: DoIFlowLogin},
+}
+
+// InitOptions controls the init subcommand.
+type InitOptions struct {
+	// Force overwrites an existing config without asking.
+	Force bool
+	// Login holds the browser and callback options passed to the OAuth flows.
+	Login LoginOptions
+}
+
+func newInitCommand() *Subcommand {
+	fs := flag.NewFlagSet("init", flag.ExitOnError)
+	opts := &InitOptions{}
+	fs.BoolVar(&opts.Force, "force", false, "Overwrite an existing config without asking")
+	fs.BoolVar(&opts.Login.NoBrowser, "no-browser", false, "Don't open the browser automatically for OAuth")
+	fs.IntVar(&opts.Login.CallbackPort, "oauth-callback-port", 0, "Override OAuth callback port (defaults to provider-specific port)")
+	return &Subcommand{
+		Name:  "init",
+		Usage: "init [-force] [-no-browser]    interactive first-run setup that writes config.yaml",
+		Flags: fs,
+		Run: func(_ *config.Config, configPath string, _ []string) int {
+			return DoInit(os.Stdin, os.Stdout, configPath, *opts)
+		},
+		SkipConfigLoad: true,
+	}
+}
+
+// initAnswers collects the wizard choices rendered into the config file.
+type initAnswers struct {
+	Port        int
+	AuthDir     string
+	APIKey      string
+	AmpUpstream string
+	AmpMappings []config.AmpModelMapping
+}
+
+// initPrompter reads answers from the wizard input.
+type initPrompter struct {
+	in  *bufio.Reader
+	out io.Writer
+}
+
+func (p *initPrompter) ask(question, def string) (string, error) {
+	if def != "" {
+		_, _ = fmt.Fprintf(p.out, "%s [%s]: ", question, def)
+	} else {
+		_, _ = fmt.Fprintf(p.out, "%s: ", question)
+	}
+	line, err := p.in.ReadString('\n')
+	if err != nil && !(errors.Is(err, io.EOF) && line != "") {
+		return "", err
+	}
+	if answer := strings.TrimSpace(line); answer != "" {
+		return answer, nil
+	}
+	return def, nil
+}
+
+func (p *initPrompter) confirm(question string, def bool) (bool, error) {
+	hint := "y/N"
+	if def {
+		hint = "Y/n"
+	}
+	answer, err := p.ask(question+" ("+hint+")", "")
+	if err != nil {
+		return false, err
+	}
+	switch strings.ToLower(answer) {
+	case "":
+		return def, nil
+	case "y", "yes":
+		return true, nil
+	default:
+		return false, nil
+	}
+}
+
+// DoInit runs the interactive first-run setup: it asks for the listen port and auth
+// directory, runs the OAuth logins the user picks, generates a client API key, offers Amp
+// model mappings for the added providers and writes config.yaml after loading it back to
+// validate it. It returns the process exit code.
+func DoInit(in io.Reader, out io.Writer, configPath string, opts InitOptions) int {
+	p := &initPrompter{in: bufio.NewReader(in), out: out}
+	if err := runInit(p, configPath, opts); err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "init: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+func runInit(p *initPrompter, configPath string, opts InitOptions) error {
+	_, _ = fmt.Fprintf(p.out, "CLIProxyAPI setup - writes %s\n\n", configPath)
+	if _, err := os.Stat(configPath); err == nil && !opts.Force {
+		overwrite, errConfirm := p.confirm(configPath+" already exists. Overwrite it? A backup is kept as "+configPath+".bak", false)
+		if errConfirm != nil {
+			return errConfirm
+		}
+		if !overwrite {
+			_, _ = fmt.Fprintln(p.out, "Nothing written.")
+			return nil
+		}
+	}
+
+	answers := initAnswers{}
+	portText, err := p.ask("Port to listen on", strconv.Itoa(defaultInitPort))
+	if err != nil {
+		return err
+	}
+	if answers.Port, err = strconv.Atoi(portText); err != nil || answers.Port <= 0 || answers.Port > 65535 {
+		return fmt.Errorf("invalid port %q", portText)
+	}
+	if answers.AuthDir, err = p.ask("Directory for provider credentials", defaultInitAuthDir); err != nil {
+		return err
+	}
+
+	added, err := initLogins(p, answers.AuthDir, opts.Login)
+	if err != nil {
+		return err
+	}
+
+	if answers.APIKey, err = generateClientAPIKey(); err != nil {
+		return err
+	}
+	_, _ = fmt.Fprintf(p.out, "\nGenerated client API key: %s\n", answers.APIKey)
+
+	if err = initAmp(p, &answers, added); err != nil {
+		return err
+	}
+
+	data := renderInitConfig(answers, added)
+	if err = writeValidatedConfig(configPath, data); err != nil {
+		return err
+	}
+	_, _ = fmt.Fprintf(p.out, "\nConfig written to %s\n", configPath)
+	_, _ = fmt.Fprintf(p.out, "Start the proxy with: cliproxyapi -config %s\n", configPath)
+	_, _ = fmt.Fprintf(p.out, "Clients use http://127.0.0.1:%d/v1 with API key %s\n", answers.Port, answers.APIKey)
+	return nil
+}
+
+// initLogins offers every provider login and returns the names of the ones that saved a
+// credential.
+func initLogins(p *initPrompter, authDir string, loginOpts LoginOptions) ([]string, error) {
+	resolved, err := util.ResolveAuthDir(authDir)
+	if err != nil {
+		return nil, fmt.Errorf("resolve auth directory: %w", err)
+	}
+	loginCfg := &config.Config{}
+	loginCfg.AuthDir = resolved
+	loginOpts.Prompt = func(prompt string) (string, error) {
+		return p.ask(strings.TrimSuffix(strings.TrimSpace(prompt), ":"), "")
+	}
+
+	_, _ = fmt.Fprintln(p.out, "\nProvider credentials (OAuth logins open a browser):")
+	var added []string
+	for _, provider := range initProviders {
+		run, errConfirm := p.confirm("  Add "+provider.Label+"?", false)
+		if errConfirm != nil {
+			return nil, errConfirm
+		}
+		if !run {
+			continue
+		}
+		// The login flows report failures only in the log; a login counts when it saved a
+		// credential file.
+		before := credentialFiles(resolved)
+		provider.Login(loginCfg, &loginOpts)
+		if !credentialSaved(before, credentialFiles(resolved)) {
+			_, _ = fmt.Fprintf(p.out, "  %s login did not save a credential; skipping it.\n", provider.Label)
+			continue
+		}
+		added = append(added, provider.Name)
+	}
+	if len(added) == 0 {
+		_, _ = fmt.Fprintln(p.out, "No credentials added; run cliproxyapi -login (or -claude-login, -codex-login, ...) later.")
+	}
+	return added, nil
+}
+
+// credentialFiles returns the modification times of the credential files in authDir.
+func credentialFiles(authDir string) map[string]time.Time {
+	files := make(map[string]time.Time)
+	entries, err := os.ReadDir(authDir)
+	if err != nil {
+		return files
+	}
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(strings.ToLower(entry.Name()), ".json") {
+			continue
+		}
+		if info, errInfo := entry.Info(); errInfo == nil {
+			files[entry.Name()] = info.ModTime()
+		}
+	}
+	return files
+}
+
+// credentialSaved reports whether after holds a credential file that is new or changed
+// since before.
+func credentialSaved(before, after map[string]time.Time) bool {
+	for name, modTime := range after {
+		if previous, ok := before[name]; !ok || !previous.Equal(modTime) {
+			return true
+		}
+	}
+	return false
+}
+
+// initAmp asks whether to enable the Amp integration and which mapping to use for Amp's
+// Claude requests when no Claude credential was added.
+func initAmp(p *initPrompter, answers *initAnswers, added []string) error {
+	enable, err := p.confirm("\nEnable the Amp CLI integration?", false)
+	if err != nil || !enable {
+		return err
+	}
+	answers.AmpUpstream = defaultAmpUpstream
+	for _, name := range added {
+		if name == "claude" {
+			return nil
+		}
+	}
+	target := ""
+	for _, name := range added {
+		for _, provider := range initProviders {
+			if provider.Name == name && provider.MappingTarget != "" && target == "" {
+				target = provider.MappingTarget
+			}
+		}
+	}
+	if target == "" {
+		_, _ = fmt.Fprintln(p.out, "Amp requests for models without a local provider will use Amp credits.")
+		return nil
+	}
+	mapIt, err := p.confirm("Route Amp's Claude requests to a local model instead of Amp credits?", true)
+	if err != nil || !mapIt {
+		return err
+	}
+	if target, err = p.ask("Local model for Claude requests", target); err != nil {
+		return err
+	}
+	answers.AmpMappings = []config.AmpModelMapping{{From: "^claude-.*", To: target, Regex: true}}
+	return nil
+}
+
+// generateClientAPIKey returns a random key for the api-keys list.
+func generateClientAPIKey() (string, error) {
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("generate api key: %w", err)
+	}
+	return "sk-" + hex.EncodeToString(buf), nil
+}
+
+// renderInitConfig writes the answers as a commented config.yaml.
+func renderInitConfig(answers initAnswers, added []string) []byte {
+	var b strings.Builder
+	b.WriteString("# Generated by \"cliproxyapi init\". See config.example.yaml for every option.\n\n")
+	b.WriteString("# Server host/interface to bind to. Empty binds all interfaces.\n")
+	b.WriteString("host: \"\"\n")
+	fmt.Fprintf(&b, "port: %d\n\n", answers.Port)
+	b.WriteString("# Directory holding provider credentials created by the login flows.\n")
+	fmt.Fprintf(&b, "auth-dir: %s\n", strconv.Quote(answers.AuthDir))
+	if len(added) > 0 {
+		fmt.Fprintf(&b, "# Logged in during setup: %s\n", strings.Join(added, ", "))
+	}
+	b.WriteString("\n# Keys clients send as Bearer token or X-Api-Key.\n")
+	b.WriteString("api-keys:\n")
+	fmt.Fprintf(&b, "  - %s\n\n", strconv.Quote(answers.APIKey))
+	b.WriteString("debug: false\n")
+	b.WriteString("request-retry: 3\n")
+	if answers.AmpUpstream != "" {
+		b.WriteString("\n# Amp CLI integration: unknown models are forwarded to ampcode.com (uses Amp credits).\n")
+		b.WriteString("ampcode:\n")
+		fmt.Fprintf(&b, "  upstream-url: %s\n", strconv.Quote(answers.AmpUpstream))
+		b.WriteString("  restrict-management-to-localhost: true\n")
+		if len(answers.AmpMappings) > 0 {
+			b.WriteString("  model-mappings:\n")
+			for _, mapping := range answers.AmpMappings {
+				fmt.Fprintf(&b, "    - from: %s\n", strconv.Quote(mapping.From))
+				fmt.Fprintf(&b, "      to: %s\n", strconv.Quote(mapping.To))
+				if mapping.Regex {
+					b.WriteString("      regex: true\n")
+				}
+			}
+		}
+	}
+	return []byte(b.String())
+}
+
+// writeValidatedConfig loads data through the regular config loader before replacing path,
+// keeping a .bak copy of a file that is overwritten.
+func writeValidatedConfig(path string, data []byte) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, ".config-init-*.yaml")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+	defer func() { _ = os.Remove(tmpPath) }()
+	if _, err = tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	cfg, err := config.LoadConfig(tmpPath)
+	if err != nil {
+		return fmt.Errorf("generated config is invalid: %w", err)
+	}
+	if cfg.Port == 0 || len(cfg.APIKeys) == 0 {
+		return fmt.Errorf("generated config is invalid: missing port or api-keys")
+	}
+	// The loader may have added migrated defaults to the file; keep its version.
+	validated, err := os.ReadFile(tmpPath)
+	if err != nil {
+		return err
+	}
+	if original, errRead := os.ReadFile(path); errRead == nil {
+		if err = os.WriteFile(path+".bak", original, 0o600); err != nil {
+			return fmt.Errorf("write backup: %w", err)
+		}
+	}
+	return os.WriteFile(path, validated, 0o600)
+}
@@ -0,0 +1,98 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+func TestDoInit_WritesValidatedConfig(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	if err := os.WriteFile(path, []byte("port: 1\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	// Overwrite, port, auth dir, decline all six logins, enable Amp.
+	input := "y\n9000\n" + filepath.Join(dir, "auths") + "\n" + strings.Repeat("n\n", len(initProviders)) + "y\n"
+
+	var out bytes.Buffer
+	if code := DoInit(strings.NewReader(input), &out, path, InitOptions{}); code != 0 {
+		t.Fatalf("exit code %d, output:\n%s", code, out.String())
+	}
+	cfg, err := config.LoadConfig(path)
+	if err != nil {
+		t.Fatalf("load written config: %v", err)
+	}
+	if cfg.Port != 9000 || len(cfg.APIKeys) != 1 || !strings.HasPrefix(cfg.APIKeys[0], "sk-") {
+		t.Fatalf("port = %d, api-keys = %v", cfg.Port, cfg.APIKeys)
+	}
+	if cfg.AmpCode.UpstreamURL != defaultAmpUpstream || len(cfg.AmpCode.ModelMappings) != 0 {
+		t.Fatalf("ampcode = %+v", cfg.AmpCode)
+	}
+	if backup, _ := os.ReadFile(path + ".bak"); string(backup) != "port: 1\n" {
+		t.Fatalf("backup = %q", backup)
+	}
+	if !strings.Contains(out.String(), cfg.APIKeys[0]) {
+		t.Fatalf("output does not show the generated key:\n%s", out.String())
+	}
+}
+
+func TestDoInit_KeepsExistingConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte("port: 1\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	if code := DoInit(strings.NewReader("\n"), &out, path, InitOptions{}); code != 0 {
+		t.Fatalf("exit code %d", code)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "port: 1\n" {
+		t.Fatalf("config was modified: %q", data)
+	}
+}
+
+func TestInitAmp_MapsClaudeToAddedProvider(t *testing.T) {
+	p := &initPrompter{in: bufio.NewReader(strings.NewReader("y\n\n\n")), out: &bytes.Buffer{}}
+	answers := initAnswers{}
+	if err := initAmp(p, &answers, []string{"gemini"}); err != nil {
+		t.Fatal(err)
+	}
+	want := config.AmpModelMapping{From: "^claude-.*", To: "gemini-2.5-pro", Regex: true}
+	if len(answers.AmpMappings) != 1 || !reflect.DeepEqual(answers.AmpMappings[0], want) {
+		t.Fatalf("mappings = %+v", answers.AmpMappings)
+	}
+	rendered := string(renderInitConfig(answers, []string{"gemini"}))
+	if !strings.Contains(rendered, "regex: true") || !strings.Contains(rendered, `to: "gemini-2.5-pro"`) {
+		t.Fatalf("rendered config:\n%s", rendered)
+	}
+}
+
+func TestInitLogins_SkipsFailedLogins(t *testing.T) {
+	authDir := t.TempDir()
+	saved := initProviders
+	defer func() { initProviders = saved }()
+	initProviders = []initProvider{
+		{Name: "claude", Label: "Claude", Login: func(*config.Config, *LoginOptions) {}},
+		{Name: "codex", Label: "Codex", Login: func(cfg *config.Config, _ *LoginOptions) {
+			_ = os.WriteFile(filepath.Join(cfg.AuthDir, "codex-user.json"), []byte("{}"), 0o600)
+		}},
+	}
+	var out bytes.Buffer
+	p := &initPrompter{in: bufio.NewReader(strings.NewReader("y\ny\n")), out: &out}
+	added, err := initLogins(p, authDir, LoginOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(added, []string{"codex"}) {
+		t.Fatalf("added = %v", added)
+	}
+	if !strings.Contains(out.String(), "Claude login did not save a credential") {
+		t.Fatalf("output:\n%s", out.String())
+	}
+}
@@ -0,0 +1,197 @@
+package cmd
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+// diffContextLines is the number of unchanged lines shown around each change.
+const diffContextLines = 3
+
+func init() {
+	registerSubcommand("migrate-config", newMigrateConfigCommand)
+}
+
+// MigrateConfigOptions controls the migrate-config subcommand.
+type MigrateConfigOptions struct {
+	// DryRun prints the changes as a diff without writing anything.
+	DryRun bool
+	// Output writes the migrated config to this path instead of replacing the input.
+	Output string
+}
+
+func newMigrateConfigCommand() *Subcommand {
+	fs := flag.NewFlagSet("migrate-config", flag.ExitOnError)
+	opts := &MigrateConfigOptions{}
+	fs.BoolVar(&opts.DryRun, "dry-run", false, "Print a diff of the migration without writing")
+	fs.StringVar(&opts.Output, "output", "", "Write the migrated config here instead of updating the file in place")
+	return &Subcommand{
+		Name:  "migrate-config",
+		Usage: "migrate-config [-dry-run] [-output file]    upgrade an older config.yaml to the current schema",
+		Flags: fs,
+		Run: func(_ *config.Config, configPath string, _ []string) int {
+			return DoMigrateConfig(os.Stdout, configPath, *opts)
+		},
+		SkipConfigLoad: true,
+	}
+}
+
+// DoMigrateConfig upgrades the config file at configPath. In-place updates keep a copy of
+// the original next to it with a .bak suffix. It returns the process exit code.
+func DoMigrateConfig(w io.Writer, configPath string, opts MigrateConfigOptions) int {
+	original, err := os.ReadFile(configPath)
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "migrate-config: %v\n", err)
+		return 1
+	}
+	migrated, changes, err := config.MigrateConfigData(original)
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "migrate-config: %s: %v\n", configPath, err)
+		return 1
+	}
+	if len(changes) == 0 || bytes.Equal(original, migrated) {
+		_, _ = fmt.Fprintf(w, "%s is already up to date\n", configPath)
+		return 0
+	}
+
+	target := configPath
+	if opts.Output != "" {
+		target = opts.Output
+	}
+	if opts.DryRun {
+		writeUnifiedDiff(w, configPath, target, string(original), string(migrated))
+		_, _ = fmt.Fprintln(w)
+	}
+	for _, change := range changes {
+		_, _ = fmt.Fprintf(w, "- %s\n", change)
+	}
+	if opts.DryRun {
+		_, _ = fmt.Fprintln(w, "dry run: no files written")
+		return 0
+	}
+
+	mode := os.FileMode(0o600)
+	if info, errStat := os.Stat(configPath); errStat == nil {
+		mode = info.Mode().Perm()
+	}
+	if target == configPath {
+		backup := configPath + ".bak"
+		if errBackup := os.WriteFile(backup, original, mode); errBackup != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "migrate-config: write backup: %v\n", errBackup)
+			return 1
+		}
+		_, _ = fmt.Fprintf(w, "original saved to %s\n", backup)
+	}
+	if errWrite := os.WriteFile(target, migrated, mode); errWrite != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "migrate-config: %v\n", errWrite)
+		return 1
+	}
+	_, _ = fmt.Fprintf(w, "migrated config written to %s\n", target)
+	return 0
+}
+
+// diffOp is one line of an edit script: ' ' kept, '-' removed, '+' added.
+type diffOp struct {
+	kind byte
+	text string
+}
+
+// diffLines computes a line edit script from a to b using the longest common subsequence.
+func diffLines(a, b []string) []diffOp {
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+	ops := make([]diffOp, 0, len(a)+len(b))
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			ops = append(ops, diffOp{' ', a[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			ops = append(ops, diffOp{'-', a[i]})
+			i++
+		default:
+			ops = append(ops, diffOp{'+', b[j]})
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		ops = append(ops, diffOp{'-', a[i]})
+	}
+	for ; j < len(b); j++ {
+		ops = append(ops, diffOp{'+', b[j]})
+	}
+	return ops
+}
+
+// writeUnifiedDiff prints the difference between before and after in unified diff format.
+func writeUnifiedDiff(w io.Writer, beforeName, afterName, before, after string) {
+	ops := diffLines(splitLines(before), splitLines(after))
+	_, _ = fmt.Fprintf(w, "--- %s\n+++ %s\n", beforeName, afterName)
+	for start := 0; start < len(ops); {
+		if ops[start].kind == ' ' {
+			start++
+			continue
+		}
+		// Grow the hunk until the gap to the next change exceeds twice the context.
+		end := start
+		for next := start; next < len(ops); next++ {
+			if ops[next].kind != ' ' {
+				end = next + 1
+			} else if next-end >= 2*diffContextLines {
+				break
+			}
+		}
+		from := max(start-diffContextLines, 0)
+		to := min(end+diffContextLines, len(ops))
+		oldLine, newLine := 1, 1
+		for _, op := range ops[:from] {
+			if op.kind != '+' {
+				oldLine++
+			}
+			if op.kind != '-' {
+				newLine++
+			}
+		}
+		oldCount, newCount := 0, 0
+		for _, op := range ops[from:to] {
+			if op.kind != '+' {
+				oldCount++
+			}
+			if op.kind != '-' {
+				newCount++
+			}
+		}
+		_, _ = fmt.Fprintf(w, "@@ -%d,%d +%d,%d @@\n", oldLine, oldCount, newLine, newCount)
+		for _, op := range ops[from:to] {
+			_, _ = fmt.Fprintf(w, "%c%s\n", op.kind, op.text)
+		}
+		start = to
+	}
+}
+
+func splitLines(s string) []string {
+	s = strings.TrimSuffix(s, "\n")
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, "\n")
+}
@@ -0,0 +1,52 @@
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDoMigrateConfig(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	legacy := "port: 8317\namp-upstream-url: \"https://ampcode.com\"\noauth-model-alias: {}\n"
+	if err := os.WriteFile(path, []byte(legacy), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	if code := DoMigrateConfig(&out, path, MigrateConfigOptions{DryRun: true}); code != 0 {
+		t.Fatalf("dry run exit code = %d\n%s", code, out.String())
+	}
+	if !strings.Contains(out.String(), "-amp-upstream-url: \"https://ampcode.com\"") || !strings.Contains(out.String(), "+  upstream-url: https://ampcode.com") {
+		t.Fatalf("dry run diff missing expected lines:\n%s", out.String())
+	}
+	if data, _ := os.ReadFile(path); string(data) != legacy {
+		t.Fatalf("dry run modified the config:\n%s", data)
+	}
+
+	out.Reset()
+	if code := DoMigrateConfig(&out, path, MigrateConfigOptions{}); code != 0 {
+		t.Fatalf("exit code = %d\n%s", code, out.String())
+	}
+	if backup, _ := os.ReadFile(path + ".bak"); string(backup) != legacy {
+		t.Fatalf("backup = %q, want the original config", backup)
+	}
+	if data, _ := os.ReadFile(path); strings.Contains(string(data), "amp-upstream-url") {
+		t.Fatalf("config was not migrated:\n%s", data)
+	}
+}
+
+func TestDiffLines(t *testing.T) {
+	ops := diffLines([]string{"a", "b", "c"}, []string{"a", "x", "c"})
+	var got strings.Builder
+	for _, op := range ops {
+		got.WriteByte(op.kind)
+		got.WriteString(op.text)
+	}
+	if got.String() != " a-b+x c" {
+		t.Fatalf("diff = %q", got.String())
+	}
+}
@@ -5,18 +5,18 @@ package cmd
 
 import (
 	"context"
-	"errors"
 	"os/signal"
 	"syscall"
 	"time"
 
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/api"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
-	"github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/osservice"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/proxy"
 	log "github.com/sirupsen/logrus"
 )
 
-// StartService builds and runs the proxy service using the exported SDK.
+// StartService builds and runs the proxy service using the embeddable proxy package.
 // It creates a new proxy service instance, sets up signal handling for graceful shutdown,
 // and starts the service with the provided configuration.
 //
@@ -25,32 +25,28 @@ import (
 //   - configPath: The path to the configuration file
 //   - localPassword: Optional password accepted for local management requests
 func StartService(cfg *config.Config, configPath string, localPassword string) {
-	builder := cliproxy.NewBuilder().
-		WithConfig(cfg).
-		WithConfigPath(configPath).
-		WithLocalManagementPassword(localPassword)
+	opts := []proxy.Option{
+		proxy.WithConfigPath(configPath),
+		proxy.WithLocalManagementPassword(localPassword),
+	}
 
 	ctxSignal, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
 	defer cancel()
+	// Under the Windows Service Control Manager a stop request replaces the signal.
+	ctxSignal, serviceDone := osservice.Context(ctxSignal)
+	defer serviceDone()
 
 	runCtx := ctxSignal
 	if localPassword != "" {
 		var keepAliveCancel context.CancelFunc
 		runCtx, keepAliveCancel = context.WithCancel(ctxSignal)
-		builder = builder.WithServerOptions(api.WithKeepAliveEndpoint(10*time.Second, func() {
+		opts = append(opts, proxy.WithServerOptions(api.WithKeepAliveEndpoint(10*time.Second, func() {
 			log.Warn("keep-alive endpoint idle for 10s, shutting down")
 			keepAliveCancel()
-		}))
-	}
-
-	service, err := builder.Build()
-	if err != nil {
-		log.Errorf("failed to build proxy service: %v", err)
-		return
+		})))
 	}
 
-	err = service.Run(runCtx)
-	if err != nil && !errors.Is(err, context.Canceled) {
+	if err := proxy.New(cfg, opts...).Run(runCtx); err != nil {
 		log.Errorf("proxy service exited with error: %v", err)
 	}
 }
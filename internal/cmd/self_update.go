@@ -0,0 +1,109 @@
+package cmd
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/buildinfo"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/selfupdate"
+)
+
+func init() {
+	registerSubcommand("self-update", newSelfUpdateCommand)
+}
+
+// SelfUpdateOptions controls the self-update subcommand.
+type SelfUpdateOptions struct {
+	// CheckOnly reports whether an update is available without installing it.
+	CheckOnly bool
+	// Force installs the latest release even when it is not newer, or when the running
+	// binary is a development build.
+	Force bool
+	// ReleaseURL overrides the release metadata endpoint derived from the config.
+	ReleaseURL string
+	// Executable overrides the binary that is replaced.
+	Executable string
+}
+
+func newSelfUpdateCommand() *Subcommand {
+	fs := flag.NewFlagSet("self-update", flag.ExitOnError)
+	opts := &SelfUpdateOptions{}
+	fs.BoolVar(&opts.CheckOnly, "check", false, "Only report whether a newer release is available")
+	fs.BoolVar(&opts.Force, "force", false, "Install the latest release even if it is not newer than this build")
+	return &Subcommand{
+		Name:  "self-update",
+		Usage: "self-update [-check] [-force]    download, verify and install the latest release",
+		Flags: fs,
+		Run: func(cfg *config.Config, _ string, _ []string) int {
+			return DoSelfUpdate(context.Background(), os.Stdout, cfg, *opts)
+		},
+	}
+}
+
+// DoSelfUpdate checks for the latest release and, unless opts.CheckOnly is set, replaces
+// the running executable with it after verifying the release checksums. A running proxy
+// keeps the old binary until it is restarted. It returns the process exit code; with
+// CheckOnly it returns 10 when an update is available so scripts can act on it.
+func DoSelfUpdate(ctx context.Context, w io.Writer, cfg *config.Config, opts SelfUpdateOptions) int {
+	var updateCfg config.UpdateCheckConfig
+	proxyURL := ""
+	if cfg != nil {
+		updateCfg = cfg.UpdateCheck
+		proxyURL = cfg.ProxyURL
+	}
+	releaseURL := opts.ReleaseURL
+	if releaseURL == "" {
+		releaseURL = selfupdate.ReleaseURL(updateCfg.Repository)
+	}
+	client := selfupdate.NewHTTPClient(proxyURL)
+
+	current := buildinfo.Version
+	release, err := selfupdate.FetchLatest(ctx, client, releaseURL)
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "self-update: %v\n", err)
+		return 1
+	}
+	latest := release.Version()
+	newer := selfupdate.IsNewer(latest, current)
+
+	if opts.CheckOnly {
+		switch {
+		case newer:
+			_, _ = fmt.Fprintf(w, "update available: %s (running %s)\n", latest, current)
+			if release.HTMLURL != "" {
+				_, _ = fmt.Fprintf(w, "release notes: %s\n", release.HTMLURL)
+			}
+			return 10
+		case !selfupdate.IsReleaseVersion(current):
+			_, _ = fmt.Fprintf(w, "latest release is %s; this is a development build (%s)\n", latest, current)
+		default:
+			_, _ = fmt.Fprintf(w, "%s is up to date\n", current)
+		}
+		return 0
+	}
+
+	if !newer && !opts.Force {
+		if !selfupdate.IsReleaseVersion(current) {
+			_, _ = fmt.Fprintf(os.Stderr, "self-update: running a development build (%s); use -force to replace it with %s\n", current, latest)
+			return 1
+		}
+		_, _ = fmt.Fprintf(w, "%s is up to date\n", current)
+		return 0
+	}
+
+	_, _ = fmt.Fprintf(w, "installing %s (running %s)...\n", latest, current)
+	path, err := selfupdate.Update(ctx, client, release, selfupdate.Options{
+		Executable: opts.Executable,
+		PublicKey:  updateCfg.PublicKey,
+	})
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "self-update: %v\n", err)
+		return 1
+	}
+	_, _ = fmt.Fprintf(w, "updated %s to %s; restart the proxy to run the new version\n", path, latest)
+	return 0
+}
@@ -0,0 +1,132 @@
+package cmd
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/osservice"
+)
+
+func init() {
+	registerSubcommand("service", newServiceCommand)
+}
+
+// ServiceOptions controls the service subcommand.
+type ServiceOptions struct {
+	// Name is the service name registered with the operating system.
+	Name string
+}
+
+func newServiceCommand() *Subcommand {
+	fs := flag.NewFlagSet("service", flag.ExitOnError)
+	opts := &ServiceOptions{}
+	fs.StringVar(&opts.Name, "name", osservice.DefaultName, "Service name")
+	return &Subcommand{
+		Name:  "service",
+		Usage: "service install|uninstall|start|stop|status [-name name]    run the proxy as a Windows service or macOS LaunchAgent",
+		Flags: fs,
+		Run: func(_ *config.Config, configPath string, args []string) int {
+			if len(args) == 0 {
+				_, _ = fmt.Fprintln(os.Stderr, "service: missing action (install, uninstall, start, stop or status)")
+				return 2
+			}
+			// Flags are accepted after the action as well.
+			if err := fs.Parse(args[1:]); err != nil {
+				return 2
+			}
+			return DoService(os.Stdout, args[0], configPath, *opts)
+		},
+		SkipConfigLoad: true,
+	}
+}
+
+// DoService runs a service management action against the operating system service manager.
+// install registers the current executable with the absolute path of configPath. It returns
+// the process exit code.
+func DoService(w io.Writer, action, configPath string, opts ServiceOptions) int {
+	name := opts.Name
+	if name == "" {
+		name = osservice.DefaultName
+	}
+	manager, err := osservice.New()
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "service: %v\n", err)
+		return 1
+	}
+
+	switch action {
+	case "install":
+		cfg, errCfg := serviceConfig(name, configPath)
+		if errCfg != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "service: %v\n", errCfg)
+			return 1
+		}
+		err = manager.Install(cfg)
+		if err == nil {
+			_, _ = fmt.Fprintf(w, "installed service %s using %s\n", name, cfg.Args[1])
+			_, _ = fmt.Fprintf(w, "run \"service start\" to start it\n")
+		}
+	case "uninstall":
+		if err = manager.Uninstall(name); err == nil {
+			_, _ = fmt.Fprintf(w, "uninstalled service %s\n", name)
+		}
+	case "start":
+		if err = manager.Start(name); err == nil {
+			_, _ = fmt.Fprintf(w, "started service %s\n", name)
+		}
+	case "stop":
+		if err = manager.Stop(name); err == nil {
+			_, _ = fmt.Fprintf(w, "stopped service %s\n", name)
+		}
+	case "status":
+		var state string
+		if state, err = manager.Status(name); err == nil {
+			_, _ = fmt.Fprintf(w, "%s: %s\n", name, state)
+		}
+	default:
+		_, _ = fmt.Fprintf(os.Stderr, "service: unknown action %q (install, uninstall, start, stop or status)\n", action)
+		return 2
+	}
+	if err != nil {
+		if errors.Is(err, osservice.ErrNotInstalled) {
+			_, _ = fmt.Fprintf(os.Stderr, "service: %s is not installed\n", name)
+			return 1
+		}
+		_, _ = fmt.Fprintf(os.Stderr, "service %s: %v\n", action, err)
+		return 1
+	}
+	return 0
+}
+
+// serviceConfig describes the service that runs this executable with the config at
+// configPath. Both paths are made absolute because service managers start jobs from an
+// unrelated working directory.
+func serviceConfig(name, configPath string) (osservice.Config, error) {
+	executable, err := os.Executable()
+	if err != nil {
+		return osservice.Config{}, fmt.Errorf("resolve executable: %w", err)
+	}
+	if resolved, errEval := filepath.EvalSymlinks(executable); errEval == nil {
+		executable = resolved
+	}
+	absConfig, err := filepath.Abs(configPath)
+	if err != nil {
+		return osservice.Config{}, fmt.Errorf("resolve config path: %w", err)
+	}
+	if _, err = os.Stat(absConfig); err != nil {
+		return osservice.Config{}, fmt.Errorf("config file: %w (run \"init\" to create one)", err)
+	}
+	return osservice.Config{
+		Name:             name,
+		DisplayName:      "CLIProxyAPI",
+		Description:      "CLIProxyAPI proxy server",
+		Executable:       executable,
+		Args:             []string{"-config", absConfig},
+		WorkingDirectory: filepath.Dir(absConfig),
+	}, nil
+}
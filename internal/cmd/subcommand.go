@@ -0,0 +1,79 @@
+package cmd
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+// Subcommand is an operation run instead of the proxy server, e.g. "cliproxyapi doctor".
+type Subcommand struct {
+	// Name is the word that selects the subcommand on the command line.
+	Name string
+	// Usage is the one-line synopsis printed in the help output.
+	Usage string
+	// Flags holds the subcommand specific flags.
+	Flags *flag.FlagSet
+	// Run executes the subcommand with the loaded configuration and the positional
+	// arguments left after flag parsing. It returns the process exit code.
+	Run func(cfg *config.Config, configPath string, args []string) int
+	// SkipConfigLoad runs the subcommand before the configuration is loaded, with a nil cfg,
+	// for commands that must see the file exactly as it is on disk.
+	SkipConfigLoad bool
+}
+
+var subcommandFactories = map[string]func() *Subcommand{}
+
+// registerSubcommand makes a subcommand available to ParseSubcommand. Factories are used
+// so each invocation gets a fresh flag set.
+func registerSubcommand(name string, factory func() *Subcommand) {
+	subcommandFactories[name] = factory
+}
+
+// ParseSubcommand resolves args[0] to a subcommand and parses its flags from the rest of
+// args. The global -config flag is accepted after the subcommand name as well and is
+// written to configPath before the configuration is loaded. Positional arguments that name
+// no subcommand return a nil subcommand, so invocations that passed stray arguments to the
+// server keep starting it as before.
+func ParseSubcommand(args []string, configPath *string) (*Subcommand, []string, error) {
+	if len(args) == 0 {
+		return nil, nil, nil
+	}
+	factory, ok := subcommandFactories[args[0]]
+	if !ok {
+		return nil, nil, nil
+	}
+	sub := factory()
+	if sub.Flags.Lookup("config") == nil {
+		sub.Flags.StringVar(configPath, "config", *configPath, "Configure File Path")
+	}
+	if err := sub.Flags.Parse(args[1:]); err != nil {
+		return nil, nil, err
+	}
+	return sub, sub.Flags.Args(), nil
+}
+
+// SubcommandNames lists the registered subcommands in alphabetical order.
+func SubcommandNames() []string {
+	names := make([]string, 0, len(subcommandFactories))
+	for name := range subcommandFactories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// PrintSubcommandUsage writes the synopsis of every subcommand to out.
+func PrintSubcommandUsage(out io.Writer) {
+	names := SubcommandNames()
+	if len(names) == 0 {
+		return
+	}
+	_, _ = fmt.Fprintln(out, "Commands:")
+	for _, name := range names {
+		_, _ = fmt.Fprintf(out, "  %s\n", subcommandFactories[name]().Usage)
+	}
+}
@@ -0,0 +1,109 @@
+package config
+
+import (
+	"strconv"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// AgentBudgetActionWarn only reports an exhausted budget in a response header.
+	AgentBudgetActionWarn = "warn"
+	// AgentBudgetActionDegrade sends requests over budget to the rule's degrade model.
+	AgentBudgetActionDegrade = "degrade"
+	// AgentBudgetActionBlock rejects requests over budget.
+	AgentBudgetActionBlock = "block"
+
+	// DefaultAgentBudgetSessionHeader groups requests into sessions when session-header is unset.
+	DefaultAgentBudgetSessionHeader = "X-Session-Id"
+	// DefaultAgentBudgetLoopGapSeconds is the longest pause between two steps of one loop.
+	DefaultAgentBudgetLoopGapSeconds = 30
+)
+
+// AgentBudgetConfig bounds agent loops: runs of requests on one session, each sent within
+// loop-gap-seconds of the previous one finishing. A longer pause ends the loop, and its
+// step and token counts start over.
+type AgentBudgetConfig struct {
+	// SessionHeader names the request header identifying a session. Requests without it
+	// share one session per client API key. Defaults to X-Session-Id.
+	SessionHeader string `yaml:"session-header,omitempty" json:"session-header,omitempty"`
+
+	// LoopGapSeconds is the longest pause between steps of one loop. Defaults to 30.
+	LoopGapSeconds int `yaml:"loop-gap-seconds,omitempty" json:"loop-gap-seconds,omitempty"`
+
+	// Rules set the budgets; the first rule matching the client API key and model applies.
+	Rules []AgentBudgetRule `yaml:"rules,omitempty" json:"rules,omitempty"`
+}
+
+// AgentBudgetRule is the step and token budget of the loops it matches.
+type AgentBudgetRule struct {
+	// Name identifies the rule in response headers and the management API.
+	Name string `yaml:"name,omitempty" json:"name,omitempty"`
+
+	// APIKeys restricts the rule to these client API keys. Empty matches every key.
+	APIKeys []string `yaml:"api-keys,omitempty" json:"api-keys,omitempty"`
+
+	// Models are model name patterns where '*' matches any run of characters. Empty
+	// matches every model.
+	Models []string `yaml:"models,omitempty" json:"models,omitempty"`
+
+	// MaxSteps bounds the requests of one loop. 0 leaves steps unbounded.
+	MaxSteps int `yaml:"max-steps,omitempty" json:"max-steps,omitempty"`
+
+	// MaxTokens bounds the total tokens of one loop. 0 leaves tokens unbounded.
+	MaxTokens int64 `yaml:"max-tokens,omitempty" json:"max-tokens,omitempty"`
+
+	// Action is taken once a budget is exhausted: warn (default), degrade or block.
+	Action string `yaml:"action,omitempty" json:"action,omitempty"`
+
+	// DegradeModel replaces the requested model when Action is degrade.
+	DegradeModel string `yaml:"degrade-model,omitempty" json:"degrade-model,omitempty"`
+}
+
+// SanitizeAgentBudget trims the agent budget rules, drops those without a budget, and
+// applies defaults.
+func (cfg *Config) SanitizeAgentBudget() {
+	if cfg == nil {
+		return
+	}
+	b := &cfg.AgentBudget
+	b.SessionHeader = strings.TrimSpace(b.SessionHeader)
+	if b.SessionHeader == "" {
+		b.SessionHeader = DefaultAgentBudgetSessionHeader
+	}
+	if b.LoopGapSeconds <= 0 {
+		b.LoopGapSeconds = DefaultAgentBudgetLoopGapSeconds
+	}
+	rules := make([]AgentBudgetRule, 0, len(b.Rules))
+	for i, rule := range b.Rules {
+		rule.Name = strings.TrimSpace(rule.Name)
+		rule.APIKeys = trimNonEmpty(rule.APIKeys)
+		rule.Models = trimNonEmpty(rule.Models)
+		rule.DegradeModel = strings.TrimSpace(rule.DegradeModel)
+		if rule.MaxSteps <= 0 && rule.MaxTokens <= 0 {
+			continue
+		}
+		if rule.MaxSteps < 0 {
+			rule.MaxSteps = 0
+		}
+		if rule.MaxTokens < 0 {
+			rule.MaxTokens = 0
+		}
+		switch rule.Action = strings.ToLower(strings.TrimSpace(rule.Action)); rule.Action {
+		case AgentBudgetActionBlock:
+		case AgentBudgetActionDegrade:
+			if rule.DegradeModel == "" {
+				log.Warnf("agent-budget rule %q: degrade action without degrade-model, warning instead", rule.Name)
+				rule.Action = AgentBudgetActionWarn
+			}
+		default:
+			rule.Action = AgentBudgetActionWarn
+		}
+		if rule.Name == "" {
+			rule.Name = "rule-" + strconv.Itoa(i+1)
+		}
+		rules = append(rules, rule)
+	}
+	b.Rules = rules
+}
@@ -0,0 +1,29 @@
+package config
+
+import (
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// SanitizeAmpMissingModel normalizes the missing-model policy, falling back to pass-through
+// (empty) for unknown policies and for default-model without a model.
+func (cfg *Config) SanitizeAmpMissingModel() {
+	if cfg == nil {
+		return
+	}
+	mm := &cfg.AmpCode.MissingModel
+	mm.Policy = strings.ToLower(strings.TrimSpace(mm.Policy))
+	mm.DefaultModel = strings.TrimSpace(mm.DefaultModel)
+	switch mm.Policy {
+	case "", AmpMissingModelPassthrough, AmpMissingModelReject:
+	case AmpMissingModelDefault:
+		if mm.DefaultModel == "" {
+			log.Warn("ampcode.missing-model: default-model policy without default-model, using pass-through")
+			mm.Policy = ""
+		}
+	default:
+		log.Warnf("ampcode.missing-model: unknown policy %q, using pass-through", mm.Policy)
+		mm.Policy = ""
+	}
+}
@@ -0,0 +1,65 @@
+package config
+
+import "strings"
+
+const (
+	// DefaultAsyncJobConcurrency is the number of jobs executed at once.
+	DefaultAsyncJobConcurrency = 4
+	// DefaultAsyncJobTimeoutSeconds bounds one run of a job.
+	DefaultAsyncJobTimeoutSeconds = 3600
+	// DefaultAsyncJobRetentionHours is how long ended jobs and their results are kept.
+	DefaultAsyncJobRetentionHours = 24
+)
+
+// AsyncJobsConfig enables the long-running job API under /v1/jobs. A job is one
+// non-streaming generation request submitted in the background: the client polls it or
+// waits for the webhook, then fetches the result, so runs can outlast HTTP and edge
+// timeouts. Jobs are persisted to Dir and pending ones run again after a restart. The
+// requests of message batches run as jobs too, and are persisted only while Enable is set.
+type AsyncJobsConfig struct {
+	// Enable turns the job API on. Disabled by default.
+	Enable bool `yaml:"enable" json:"enable"`
+
+	// Dir overrides where jobs are persisted. When empty, an "async-jobs" directory next to
+	// the logs directory is used. Job files hold the submitting request's headers,
+	// including its API key.
+	Dir string `yaml:"dir,omitempty" json:"dir,omitempty"`
+
+	// Concurrency is the number of jobs executed at once. Defaults to 4.
+	Concurrency int `yaml:"concurrency,omitempty" json:"concurrency,omitempty"`
+
+	// TimeoutSeconds bounds one run of a job. Defaults to 3600.
+	TimeoutSeconds int `yaml:"timeout-seconds,omitempty" json:"timeout-seconds,omitempty"`
+
+	// RetentionHours is how long ended jobs and their results are kept. Defaults to 24.
+	RetentionHours int `yaml:"retention-hours,omitempty" json:"retention-hours,omitempty"`
+
+	// WebhookURL receives a JSON POST whenever a job ends.
+	WebhookURL string `yaml:"webhook-url,omitempty" json:"webhook-url,omitempty"`
+
+	// WebhookSecret signs webhook payloads with HMAC-SHA256 in X-CLIProxy-Signature.
+	WebhookSecret string `yaml:"webhook-secret,omitempty" json:"webhook-secret,omitempty"`
+
+	// AllowClientWebhooks lets submitted jobs name their own webhook_url, used instead
+	// of WebhookURL. Disabled by default since clients could make the proxy POST anywhere.
+	AllowClientWebhooks bool `yaml:"allow-client-webhooks,omitempty" json:"allow-client-webhooks,omitempty"`
+}
+
+// SanitizeAsyncJobs applies the async job defaults.
+func (cfg *Config) SanitizeAsyncJobs() {
+	if cfg == nil {
+		return
+	}
+	aj := &cfg.AsyncJobs
+	aj.Dir = strings.TrimSpace(aj.Dir)
+	aj.WebhookURL = strings.TrimSpace(aj.WebhookURL)
+	if aj.Concurrency <= 0 {
+		aj.Concurrency = DefaultAsyncJobConcurrency
+	}
+	if aj.TimeoutSeconds <= 0 {
+		aj.TimeoutSeconds = DefaultAsyncJobTimeoutSeconds
+	}
+	if aj.RetentionHours <= 0 {
+		aj.RetentionHours = DefaultAsyncJobRetentionHours
+	}
+}
@@ -0,0 +1,45 @@
+package config
+
+import (
+	"net"
+	"strings"
+)
+
+// AugPlusConfig gates the AugPlus extension compatible endpoints, which hand out local
+// users without checking credentials.
+type AugPlusConfig struct {
+	// AllowRemote registers the endpoints even when the server listens on non-loopback
+	// interfaces. It has no effect without AuthToken.
+	AllowRemote bool `yaml:"allow-remote,omitempty" json:"allow-remote,omitempty"`
+
+	// AuthToken must accompany every request when the endpoints are reachable remotely.
+	AuthToken string `yaml:"auth-token,omitempty" json:"auth-token,omitempty"`
+}
+
+// RemoteAllowed reports whether remote access is enabled and protected by a token.
+func (a AugPlusConfig) RemoteAllowed() bool {
+	return a.AllowRemote && a.AuthToken != ""
+}
+
+// SanitizeAugPlus trims the AugPlus auth token.
+func (cfg *Config) SanitizeAugPlus() {
+	if cfg == nil {
+		return
+	}
+	cfg.AugPlus.AuthToken = strings.TrimSpace(cfg.AugPlus.AuthToken)
+}
+
+// LoopbackOnly reports whether the configured host binds to loopback interfaces only.
+// An empty host binds to every interface. A socket-activated or injected listener ignores
+// the host, so access checks must also look at the address a connection arrived on.
+func (cfg *Config) LoopbackOnly() bool {
+	if cfg == nil {
+		return false
+	}
+	host := strings.Trim(strings.TrimSpace(cfg.Host), "[]")
+	if strings.EqualFold(host, "localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
@@ -0,0 +1,48 @@
+package config
+
+// Defaults of the API key brute-force protection.
+const (
+	DefaultAuthGuardWindowSeconds = 600
+	DefaultAuthGuardBanSeconds    = 60
+	DefaultAuthGuardMaxBanSeconds = 3600
+)
+
+// AuthGuardConfig bans client IPs that keep presenting invalid API keys. Every ban of the
+// same IP doubles in length, up to MaxBanSeconds.
+type AuthGuardConfig struct {
+	// MaxFailures is the number of invalid API keys an IP may present within WindowSeconds
+	// before it is banned. <= 0 disables the protection.
+	MaxFailures int `yaml:"max-failures,omitempty" json:"max-failures,omitempty"`
+
+	// WindowSeconds is the period failures are counted over. Defaults to 600.
+	WindowSeconds int `yaml:"window-seconds,omitempty" json:"window-seconds,omitempty"`
+
+	// BanSeconds is the length of the first ban. Defaults to 60.
+	BanSeconds int `yaml:"ban-seconds,omitempty" json:"ban-seconds,omitempty"`
+
+	// MaxBanSeconds caps the doubling ban length. Defaults to 3600.
+	MaxBanSeconds int `yaml:"max-ban-seconds,omitempty" json:"max-ban-seconds,omitempty"`
+}
+
+// SanitizeAuthGuard applies the brute-force protection defaults.
+func (cfg *Config) SanitizeAuthGuard() {
+	if cfg == nil {
+		return
+	}
+	ag := &cfg.AuthGuard
+	if ag.MaxFailures < 0 {
+		ag.MaxFailures = 0
+	}
+	if ag.WindowSeconds <= 0 {
+		ag.WindowSeconds = DefaultAuthGuardWindowSeconds
+	}
+	if ag.BanSeconds <= 0 {
+		ag.BanSeconds = DefaultAuthGuardBanSeconds
+	}
+	if ag.MaxBanSeconds <= 0 {
+		ag.MaxBanSeconds = DefaultAuthGuardMaxBanSeconds
+	}
+	if ag.MaxBanSeconds < ag.BanSeconds {
+		ag.MaxBanSeconds = ag.BanSeconds
+	}
+}
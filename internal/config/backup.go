@@ -0,0 +1,99 @@
+package config
+
+import "strings"
+
+// Backup destinations.
+const (
+	BackupDestinationLocal  = "local"
+	BackupDestinationS3     = "s3"
+	BackupDestinationWebDAV = "webdav"
+)
+
+const (
+	// DefaultBackupIntervalHours is how often credential backups are taken.
+	DefaultBackupIntervalHours = 24
+	// DefaultBackupRetention is how many backups are kept at the destination.
+	DefaultBackupRetention = 7
+)
+
+// BackupConfig schedules encrypted backups of the auth directory and the config file, so
+// long-lived OAuth refresh tokens survive the loss of the disk. Archives are encrypted
+// with a key derived from Passphrase and restored with the backup-restore command.
+type BackupConfig struct {
+	// Enable toggles scheduled backups. Disabled by default.
+	Enable bool `yaml:"enable" json:"enable"`
+
+	// Destination is where archives are stored: local, s3 or webdav. Defaults to local.
+	Destination string `yaml:"destination,omitempty" json:"destination,omitempty"`
+
+	// IntervalHours is how often a backup is taken; the first runs at startup.
+	IntervalHours int `yaml:"interval-hours,omitempty" json:"interval-hours,omitempty"`
+
+	// Retention is how many backups are kept. Older ones are deleted after each backup.
+	Retention int `yaml:"retention,omitempty" json:"retention,omitempty"`
+
+	// Passphrase encrypts the archives. Backups are not taken without one.
+	Passphrase string `yaml:"passphrase,omitempty" json:"-"`
+
+	// Dir is the directory of the local destination.
+	Dir string `yaml:"dir,omitempty" json:"dir,omitempty"`
+
+	// S3 configures the s3 destination.
+	S3 BackupS3 `yaml:"s3,omitempty" json:"s3,omitempty"`
+
+	// WebDAV configures the webdav destination.
+	WebDAV BackupWebDAV `yaml:"webdav,omitempty" json:"webdav,omitempty"`
+}
+
+// BackupS3 is an S3-compatible bucket backups are uploaded to.
+type BackupS3 struct {
+	Endpoint  string `yaml:"endpoint,omitempty" json:"endpoint,omitempty"`
+	Bucket    string `yaml:"bucket,omitempty" json:"bucket,omitempty"`
+	Region    string `yaml:"region,omitempty" json:"region,omitempty"`
+	AccessKey string `yaml:"access-key,omitempty" json:"-"`
+	SecretKey string `yaml:"secret-key,omitempty" json:"-"`
+	// Prefix is the key prefix of the archives, e.g. "cliproxy/backups".
+	Prefix string `yaml:"prefix,omitempty" json:"prefix,omitempty"`
+	// Insecure talks plain HTTP to the endpoint.
+	Insecure bool `yaml:"insecure,omitempty" json:"insecure,omitempty"`
+	// PathStyle addresses the bucket in the path instead of the host name.
+	PathStyle bool `yaml:"path-style,omitempty" json:"path-style,omitempty"`
+}
+
+// BackupWebDAV is a WebDAV collection backups are uploaded to.
+type BackupWebDAV struct {
+	// URL is the collection, e.g. "https://dav.example.com/backups/".
+	URL      string `yaml:"url,omitempty" json:"url,omitempty"`
+	Username string `yaml:"username,omitempty" json:"-"`
+	Password string `yaml:"password,omitempty" json:"-"`
+}
+
+// SanitizeBackup normalizes the backup settings and applies defaults.
+func (cfg *Config) SanitizeBackup() {
+	if cfg == nil {
+		return
+	}
+	b := &cfg.Backup
+	b.Destination = strings.ToLower(strings.TrimSpace(b.Destination))
+	if b.Destination == "" {
+		b.Destination = BackupDestinationLocal
+	}
+	if b.IntervalHours <= 0 {
+		b.IntervalHours = DefaultBackupIntervalHours
+	}
+	if b.Retention <= 0 {
+		b.Retention = DefaultBackupRetention
+	}
+	b.Dir = strings.TrimSpace(b.Dir)
+	b.S3.Endpoint = strings.TrimSpace(b.S3.Endpoint)
+	b.S3.Bucket = strings.TrimSpace(b.S3.Bucket)
+	b.S3.Region = strings.TrimSpace(b.S3.Region)
+	b.S3.AccessKey = strings.TrimSpace(b.S3.AccessKey)
+	b.S3.SecretKey = strings.TrimSpace(b.S3.SecretKey)
+	b.S3.Prefix = strings.Trim(strings.TrimSpace(b.S3.Prefix), "/")
+	b.WebDAV.URL = strings.TrimSpace(b.WebDAV.URL)
+	if b.WebDAV.URL != "" && !strings.HasSuffix(b.WebDAV.URL, "/") {
+		b.WebDAV.URL += "/"
+	}
+	b.WebDAV.Username = strings.TrimSpace(b.WebDAV.Username)
+}
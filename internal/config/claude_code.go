@@ -0,0 +1,23 @@
+package config
+
+// ClaudeCodeConfig configures the Claude Code compatible routes under /claude-code, which
+// the Anthropic CLI reaches with ANTHROPIC_BASE_URL=http://<host>:<port>/claude-code.
+type ClaudeCodeConfig struct {
+	// ModelMappings route the models Claude Code requests to other models, e.g. its small
+	// fast model to a cheaper one. The first matching mapping wins; responses keep reporting
+	// the requested model.
+	ModelMappings []ClientModelMapping `yaml:"model-mappings,omitempty" json:"model-mappings,omitempty"`
+
+	// DropBetas lists anthropic-beta flags removed from Claude Code requests, for flags the
+	// configured Claude credentials cannot use (e.g. "context-1m-2025-08-07").
+	DropBetas []string `yaml:"drop-betas,omitempty" json:"drop-betas,omitempty"`
+}
+
+// SanitizeClaudeCode trims the Claude Code mappings and drops incomplete ones.
+func (cfg *Config) SanitizeClaudeCode() {
+	if cfg == nil {
+		return
+	}
+	cfg.ClaudeCode.ModelMappings = sanitizeClientModelMappings(cfg.ClaudeCode.ModelMappings)
+	cfg.ClaudeCode.DropBetas = trimNonEmpty(cfg.ClaudeCode.DropBetas)
+}
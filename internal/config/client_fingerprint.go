@@ -0,0 +1,72 @@
+package config
+
+import (
+	"net/http"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// ClientFingerprintConfig controls how upstream requests identify their client. Requests
+// without a User-Agent always get the CLIProxyAPI one instead of the Go default.
+type ClientFingerprintConfig struct {
+	// Enable presents every request of a provider with a fingerprint: the one of its
+	// official CLI for the OAuth providers (claude, codex, gemini-cli, qwen, iflow,
+	// antigravity), merged with Providers. Client User-Agent and identification headers
+	// the executors forward are replaced. Disabled, the executors keep their defaults and
+	// forward the client values.
+	Enable bool `yaml:"enable" json:"enable"`
+
+	// Providers overrides or adds fingerprints per provider key (e.g. "claude" or an
+	// openai-compatibility name).
+	Providers map[string]ClientFingerprint `yaml:"providers,omitempty" json:"providers,omitempty"`
+}
+
+// ClientFingerprint is the User-Agent and client identification headers presented to a
+// provider.
+type ClientFingerprint struct {
+	// UserAgent replaces the User-Agent. A "user_agent" attribute or metadata field of the
+	// credential still takes precedence.
+	UserAgent string `yaml:"user-agent,omitempty" json:"user-agent,omitempty"`
+
+	// Headers replaces these headers, e.g. X-App or X-Stainless-Os.
+	Headers map[string]string `yaml:"headers,omitempty" json:"headers,omitempty"`
+}
+
+// SanitizeClientFingerprint normalizes provider keys and header names, dropping reserved
+// headers and empty fingerprints.
+func (cfg *Config) SanitizeClientFingerprint() {
+	if cfg == nil || len(cfg.ClientFingerprint.Providers) == 0 {
+		return
+	}
+	providers := make(map[string]ClientFingerprint, len(cfg.ClientFingerprint.Providers))
+	for key, fingerprint := range cfg.ClientFingerprint.Providers {
+		key = strings.ToLower(strings.TrimSpace(key))
+		if key == "" {
+			continue
+		}
+		fingerprint.UserAgent = strings.TrimSpace(fingerprint.UserAgent)
+		headers := make(map[string]string, len(fingerprint.Headers))
+		for name, value := range fingerprint.Headers {
+			name = http.CanonicalHeaderKey(strings.TrimSpace(name))
+			value = strings.TrimSpace(value)
+			if name == "" || value == "" || headerRuleReserved[name] {
+				log.Warnf("client-fingerprint.providers.%s: ignoring header %q", key, name)
+				continue
+			}
+			if name == "User-Agent" {
+				if fingerprint.UserAgent == "" {
+					fingerprint.UserAgent = value
+				}
+				continue
+			}
+			headers[name] = value
+		}
+		fingerprint.Headers = headers
+		if fingerprint.UserAgent == "" && len(fingerprint.Headers) == 0 {
+			continue
+		}
+		providers[key] = fingerprint
+	}
+	cfg.ClientFingerprint.Providers = providers
+}
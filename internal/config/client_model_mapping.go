@@ -0,0 +1,26 @@
+package config
+
+import "strings"
+
+// ClientModelMapping routes the requested models matching From to To. The client compatible
+// routes (claude-code, jetbrains) use it for their model-mappings.
+type ClientModelMapping struct {
+	// From is a requested model name; "*" wildcards are allowed, e.g. "claude-3-5-haiku-*".
+	From string `yaml:"from" json:"from"`
+	// To is the model served instead, by any provider; thinking suffixes are allowed.
+	To string `yaml:"to" json:"to"`
+}
+
+// sanitizeClientModelMappings trims the mappings and drops incomplete ones.
+func sanitizeClientModelMappings(in []ClientModelMapping) []ClientModelMapping {
+	mappings := make([]ClientModelMapping, 0, len(in))
+	for _, mapping := range in {
+		mapping.From = strings.TrimSpace(mapping.From)
+		mapping.To = strings.TrimSpace(mapping.To)
+		if mapping.From == "" || mapping.To == "" {
+			continue
+		}
+		mappings = append(mappings, mapping)
+	}
+	return mappings
+}
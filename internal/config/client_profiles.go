@@ -0,0 +1,126 @@
+package config
+
+import (
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Client profile presets.
+const (
+	ClientProfileAider     = "aider"
+	ClientProfileOpenHands = "openhands"
+)
+
+// litellmModelPrefixes are the provider prefixes litellm-based agents put in front of model
+// names, e.g. "openai/gpt-5" or "litellm_proxy/claude-sonnet-4-5".
+var litellmModelPrefixes = []string{"litellm_proxy/", "openai/", "anthropic/", "gemini/", "openrouter/"}
+
+// clientProfilePresets are the built-in settings of each preset.
+var clientProfilePresets = map[string]ClientProfile{
+	// Aider streams by default and gives up on long silences before the first token of
+	// reasoning models, and reads Retry-After through litellm when rate limited.
+	ClientProfileAider: {
+		StripModelPrefixes:     litellmModelPrefixes,
+		StreamKeepAliveSeconds: 15,
+		BootstrapRetries:       2,
+		RetryAfterSeconds:      5,
+	},
+	// OpenHands sends non-streaming completions with long agent prompts, whose idle
+	// connections proxies and load balancers drop, and backs off on rate limits.
+	ClientProfileOpenHands: {
+		StripModelPrefixes:        litellmModelPrefixes,
+		NonStreamKeepAliveSeconds: 15,
+		BootstrapRetries:          1,
+		RetryAfterSeconds:         10,
+	},
+}
+
+// ClientProfile tunes the handling of requests from one agent tool, selected by the client
+// API keys it uses. Zero fields take the value of the preset, or the global setting without
+// one; a negative keep-alive, retry or Retry-After value switches it off for the profile.
+type ClientProfile struct {
+	// Name identifies the profile; "aider" and "openhands" select that preset.
+	Name string `yaml:"name" json:"name"`
+
+	// Preset is the preset a custom-named profile starts from: "aider" or "openhands".
+	Preset string `yaml:"preset,omitempty" json:"preset,omitempty"`
+
+	// APIKeys are the client API keys the profile applies to.
+	APIKeys []string `yaml:"api-keys" json:"api-keys"`
+
+	// StripModelPrefixes are removed from the front of requested model names that no
+	// provider serves as written.
+	StripModelPrefixes []string `yaml:"strip-model-prefixes,omitempty" json:"strip-model-prefixes,omitempty"`
+
+	// StreamKeepAliveSeconds overrides streaming.keepalive-seconds.
+	StreamKeepAliveSeconds int `yaml:"stream-keepalive-seconds,omitempty" json:"stream-keepalive-seconds,omitempty"`
+
+	// NonStreamKeepAliveSeconds overrides nonstream-keepalive-interval.
+	NonStreamKeepAliveSeconds int `yaml:"nonstream-keepalive-seconds,omitempty" json:"nonstream-keepalive-seconds,omitempty"`
+
+	// BootstrapRetries overrides streaming.bootstrap-retries.
+	BootstrapRetries int `yaml:"bootstrap-retries,omitempty" json:"bootstrap-retries,omitempty"`
+
+	// RetryAfterSeconds is sent as Retry-After on 429 and 503 errors that carry none.
+	RetryAfterSeconds int `yaml:"retry-after-seconds,omitempty" json:"retry-after-seconds,omitempty"`
+}
+
+// ClientProfileFor returns the profile of the client API key, or nil.
+func (c *SDKConfig) ClientProfileFor(apiKey string) *ClientProfile {
+	if c == nil || apiKey == "" {
+		return nil
+	}
+	for i := range c.ClientProfiles {
+		for _, key := range c.ClientProfiles[i].APIKeys {
+			if key == apiKey {
+				return &c.ClientProfiles[i]
+			}
+		}
+	}
+	return nil
+}
+
+// SanitizeClientProfiles applies the presets and drops profiles without API keys.
+func (cfg *Config) SanitizeClientProfiles() {
+	if cfg == nil {
+		return
+	}
+	profiles := make([]ClientProfile, 0, len(cfg.ClientProfiles))
+	for _, profile := range cfg.ClientProfiles {
+		profile.Name = strings.ToLower(strings.TrimSpace(profile.Name))
+		profile.Preset = strings.ToLower(strings.TrimSpace(profile.Preset))
+		profile.APIKeys = trimNonEmpty(profile.APIKeys)
+		if len(profile.APIKeys) == 0 {
+			continue
+		}
+		if profile.Preset == "" {
+			if _, ok := clientProfilePresets[profile.Name]; ok {
+				profile.Preset = profile.Name
+			}
+		}
+		if profile.Preset != "" {
+			preset, ok := clientProfilePresets[profile.Preset]
+			if !ok {
+				log.Warnf("client profile %q: unknown preset %q", profile.Name, profile.Preset)
+			}
+			if len(profile.StripModelPrefixes) == 0 {
+				profile.StripModelPrefixes = preset.StripModelPrefixes
+			}
+			profile.StreamKeepAliveSeconds = presetValue(profile.StreamKeepAliveSeconds, preset.StreamKeepAliveSeconds)
+			profile.NonStreamKeepAliveSeconds = presetValue(profile.NonStreamKeepAliveSeconds, preset.NonStreamKeepAliveSeconds)
+			profile.BootstrapRetries = presetValue(profile.BootstrapRetries, preset.BootstrapRetries)
+			profile.RetryAfterSeconds = presetValue(profile.RetryAfterSeconds, preset.RetryAfterSeconds)
+		}
+		profile.StripModelPrefixes = trimNonEmpty(profile.StripModelPrefixes)
+		profiles = append(profiles, profile)
+	}
+	cfg.ClientProfiles = profiles
+}
+
+func presetValue(value, preset int) int {
+	if value == 0 {
+		return preset
+	}
+	return value
+}
@@ -57,6 +57,13 @@ type Config struct {
 	// When exceeded, the oldest log files are deleted until within the limit. Set to 0 to disable.
 	LogsMaxTotalSizeMB int `yaml:"logs-max-total-size-mb" json:"logs-max-total-size-mb"`
 
+	// LogLanguage selects the language of operator-facing log messages such as the Amp
+	// routing warnings: "en" (default) or "zh".
+	LogLanguage string `yaml:"log-language,omitempty" json:"log-language,omitempty"`
+
+	// Logging sets the log format, file rotation and per-component levels.
+	Logging LoggingConfig `yaml:"logging,omitempty" json:"logging,omitempty"`
+
 	// ErrorLogsMaxFiles limits the number of error log files retained when request logging is disabled.
 	// When exceeded, the oldest error log files are deleted. Default is 10. Set to 0 to disable cleanup.
 	ErrorLogsMaxFiles int `yaml:"error-logs-max-files" json:"error-logs-max-files"`
@@ -81,6 +88,10 @@ type Config struct {
 	// WebsocketAuth enables or disables authentication for the WebSocket API.
 	WebsocketAuth bool `yaml:"ws-auth" json:"ws-auth"`
 
+	// MaxRequestBodyBytes rejects larger request bodies with 413, before any middleware reads
+	// them; it also caps WebSocket bridge frames. 0 is unlimited.
+	MaxRequestBodyBytes int64 `yaml:"max-request-body-bytes,omitempty" json:"max-request-body-bytes,omitempty"`
+
 	// GeminiKey defines Gemini API key configurations with optional routing overrides.
 	GeminiKey []GeminiKey `yaml:"gemini-api-key" json:"gemini-api-key"`
 
@@ -97,12 +108,32 @@ type Config struct {
 	// Used for services that use Vertex AI-style paths but with simple API key authentication.
 	VertexCompatAPIKey []VertexCompatKey `yaml:"vertex-api-key" json:"vertex-api-key"`
 
+	// ProviderGroups hold settings inherited by the credentials naming them in their group field.
+	ProviderGroups []ProviderGroup `yaml:"provider-groups,omitempty" json:"provider-groups,omitempty"`
+
+	// MockProvider defines built-in providers answering with deterministic synthetic responses.
+	MockProvider []MockProvider `yaml:"mock-provider,omitempty" json:"mock-provider,omitempty"`
+
 	// AmpCode contains Amp CLI upstream configuration, management restrictions, and model mappings.
 	AmpCode AmpCode `yaml:"ampcode" json:"ampcode"`
 
+	// AugPlus controls who may reach the AugPlus extension compatible endpoints.
+	AugPlus AugPlusConfig `yaml:"augplus,omitempty" json:"augplus,omitempty"`
+
+	// ClaudeCode configures the Claude Code CLI compatible routes.
+	ClaudeCode ClaudeCodeConfig `yaml:"claude-code,omitempty" json:"claude-code,omitempty"`
+
+	// JetBrains configures the JetBrains AI Assistant compatible routes.
+	JetBrains JetBrainsConfig `yaml:"jetbrains,omitempty" json:"jetbrains,omitempty"`
+
 	// OAuthExcludedModels defines per-provider global model exclusions applied to OAuth/file-backed auth entries.
 	OAuthExcludedModels map[string][]string `yaml:"oauth-excluded-models,omitempty" json:"oauth-excluded-models,omitempty"`
 
+	// OAuthBaseURL overrides the upstream API base URL of OAuth/file-backed auth entries per
+	// provider, e.g. to route through a gateway or a regional endpoint. An auth file may set
+	// its own "base_url", which takes precedence.
+	OAuthBaseURL map[string]string `yaml:"oauth-base-url,omitempty" json:"oauth-base-url,omitempty"`
+
 	// OAuthModelAlias defines global model name aliases for OAuth/file-backed auth channels.
 	// These aliases affect both model listing and model routing for supported channels:
 	// gemini-cli, vertex, aistudio, antigravity, claude, codex, qwen, iflow.
@@ -114,6 +145,143 @@ type Config struct {
 	// Payload defines default and override rules for provider payload parameters.
 	Payload PayloadConfig `yaml:"payload" json:"payload"`
 
+	// GeminiDefaults fills in safety settings and generation config of Gemini-routed requests.
+	GeminiDefaults GeminiDefaultsConfig `yaml:"gemini-defaults,omitempty" json:"gemini-defaults,omitempty"`
+
+	// OpenAIReasoning adapts the parameters of requests sent to OpenAI reasoning models.
+	OpenAIReasoning OpenAIReasoningConfig `yaml:"openai-reasoning,omitempty" json:"openai-reasoning,omitempty"`
+
+	// Transcripts configures the opt-in conversation transcript store.
+	Transcripts TranscriptConfig `yaml:"transcripts" json:"transcripts"`
+
+	// Storage selects the persistence backend of transcripts and usage statistics.
+	Storage StorageConfig `yaml:"storage,omitempty" json:"storage,omitempty"`
+
+	// ModelsCache controls the pre-rendered responses of the model listing endpoints.
+	ModelsCache ModelsCacheConfig `yaml:"models-cache,omitempty" json:"models-cache,omitempty"`
+
+	// SpendLimits configures monthly token/cost ceilings for provider credentials.
+	SpendLimits SpendLimitConfig `yaml:"spend-limits,omitempty" json:"spend-limits,omitempty"`
+
+	// SLO configures latency, error-rate and response-size objectives with alerts.
+	SLO SLOConfig `yaml:"slo,omitempty" json:"slo,omitempty"`
+
+	// Projects scopes client API keys into tenants with their own mappings, quotas and usage.
+	Projects []Project `yaml:"projects,omitempty" json:"projects,omitempty"`
+
+	// TemporaryKeys lets clients mint short-lived, scoped keys derived from their API key.
+	TemporaryKeys TemporaryKeysConfig `yaml:"temporary-keys,omitempty" json:"temporary-keys,omitempty"`
+
+	// VirtualHosts serves several logical proxy configurations keyed by Host header or URL prefix.
+	VirtualHosts []VirtualHost `yaml:"virtual-hosts,omitempty" json:"virtual-hosts,omitempty"`
+
+	// SharedState configures the optional backend used to coordinate several instances.
+	SharedState SharedStateConfig `yaml:"shared-state,omitempty" json:"shared-state,omitempty"`
+
+	// UpdateCheck configures the opt-in release checker used by self-update.
+	UpdateCheck UpdateCheckConfig `yaml:"update-check,omitempty" json:"update-check,omitempty"`
+
+	// Timeouts configures connect, first-byte and total timeouts for upstream requests.
+	Timeouts TimeoutConfig `yaml:"timeouts,omitempty" json:"timeouts,omitempty"`
+
+	// DNSCache caches upstream host lookups and races IPv6 and IPv4 connections.
+	DNSCache DNSCacheConfig `yaml:"dns-cache,omitempty" json:"dns-cache,omitempty"`
+
+	// Offline restricts upstream traffic to local backends such as mock providers and Ollama.
+	Offline OfflineConfig `yaml:"offline,omitempty" json:"offline,omitempty"`
+
+	// Moderation configures the /v1/moderations endpoint.
+	Moderation ModerationConfig `yaml:"moderation,omitempty" json:"moderation,omitempty"`
+
+	// GenerationPolicy clamps or defaults max_tokens and adds stop sequences to upstream requests.
+	GenerationPolicy GenerationPolicyConfig `yaml:"generation-policy,omitempty" json:"generation-policy,omitempty"`
+
+	// AgentBudget bounds the steps and tokens of agent loops per session.
+	AgentBudget AgentBudgetConfig `yaml:"agent-budget,omitempty" json:"agent-budget,omitempty"`
+
+	// LoopBreaker rejects requests of agents stuck repeating the same step.
+	LoopBreaker LoopBreakerConfig `yaml:"loop-breaker,omitempty" json:"loop-breaker,omitempty"`
+
+	// SyntheticProbes sends scheduled test prompts to provider credentials.
+	SyntheticProbes SyntheticProbesConfig `yaml:"synthetic-probes,omitempty" json:"synthetic-probes,omitempty"`
+
+	// ScheduledPrompts runs configured prompts on a schedule and delivers their answers.
+	ScheduledPrompts ScheduledPromptsConfig `yaml:"scheduled-prompts,omitempty" json:"scheduled-prompts,omitempty"`
+
+	// Maintenance sets the message and retry hint of maintenance and drain modes.
+	Maintenance MaintenanceConfig `yaml:"maintenance,omitempty" json:"maintenance,omitempty"`
+
+	// Mirror copies a sample of API requests to a secondary proxy instance.
+	Mirror MirrorConfig `yaml:"mirror,omitempty" json:"mirror,omitempty"`
+
+	// ModelDiscovery caches model lists fetched from providers.
+	ModelDiscovery ModelDiscoveryConfig `yaml:"model-discovery,omitempty" json:"model-discovery,omitempty"`
+
+	// MappingPreflight verifies model mapping targets after startup and reloads.
+	MappingPreflight MappingPreflightConfig `yaml:"mapping-preflight,omitempty" json:"mapping-preflight,omitempty"`
+
+	// Warmup sends a trivial request for the listed models right after startup.
+	Warmup WarmupConfig `yaml:"warmup,omitempty" json:"warmup,omitempty"`
+
+	// GRPC serves the chat and completion APIs over gRPC.
+	GRPC GRPCConfig `yaml:"grpc,omitempty" json:"grpc,omitempty"`
+
+	// Passthrough mounts raw provider routes that only inject credentials.
+	Passthrough []PassthroughRoute `yaml:"passthrough,omitempty" json:"passthrough,omitempty"`
+
+	// HeaderRules adds or removes headers of upstream requests per provider and model.
+	HeaderRules []HeaderRule `yaml:"header-rules,omitempty" json:"header-rules,omitempty"`
+
+	// ClientFingerprint sets the User-Agent and client identification headers presented
+	// to each provider.
+	ClientFingerprint ClientFingerprintConfig `yaml:"client-fingerprint,omitempty" json:"client-fingerprint,omitempty"`
+
+	// StreamParsing is "tolerant" (default) or "strict": whether upstream stream chunks the
+	// translators do not recognize are skipped with a warning or fail their stream.
+	StreamParsing string `yaml:"stream-parsing,omitempty" json:"stream-parsing,omitempty"`
+
+	// StreamResumption lets clients reconnect to interrupted streams.
+	StreamResumption StreamResumptionConfig `yaml:"stream-resumption,omitempty" json:"stream-resumption,omitempty"`
+
+	// ResponseSpill moves large response buffers to disk.
+	ResponseSpill ResponseSpillConfig `yaml:"response-spill,omitempty" json:"response-spill,omitempty"`
+
+	// AsyncJobs serves long-running generations as jobs under /v1/jobs.
+	AsyncJobs AsyncJobsConfig `yaml:"async-jobs,omitempty" json:"async-jobs,omitempty"`
+
+	// IPAccess restricts the clients reaching the inference and management endpoints.
+	IPAccess IPAccessConfig `yaml:"ip-access,omitempty" json:"ip-access,omitempty"`
+
+	// AuthGuard bans client IPs that keep presenting invalid API keys.
+	AuthGuard AuthGuardConfig `yaml:"auth-guard,omitempty" json:"auth-guard,omitempty"`
+
+	// CORS configures the cross-origin headers sent to browser-based clients.
+	CORS CORSConfig `yaml:"cors,omitempty" json:"cors,omitempty"`
+
+	// Pricing prices model token usage for cost accounting.
+	Pricing PricingConfig `yaml:"pricing,omitempty" json:"pricing,omitempty"`
+
+	// UsageReports writes a usage and cost report for every calendar month.
+	UsageReports UsageReportConfig `yaml:"usage-reports,omitempty" json:"usage-reports,omitempty"`
+
+	// Backup takes scheduled encrypted backups of the credentials and the config file.
+	Backup BackupConfig `yaml:"backup,omitempty" json:"backup,omitempty"`
+
+	// Metrics exposes Prometheus metrics and pushes them to statsd or OTLP collectors.
+	Metrics MetricsConfig `yaml:"metrics,omitempty" json:"metrics,omitempty"`
+
+	// FaultInjection injects latency, errors and broken streams for resilience testing.
+	FaultInjection FaultInjectionConfig `yaml:"fault-injection,omitempty" json:"fault-injection,omitempty"`
+
+	// FeatureFlags switches experimental behaviors on or off per deployment, keyed by flag
+	// name (see the featureflag package). Unlisted flags are on.
+	FeatureFlags map[string]bool `yaml:"feature-flags,omitempty" json:"feature-flags,omitempty"`
+
+	// Modules enables or disables the routing modules by the names listed by /version
+	// ("amp-routing", "augplus"). Unlisted modules are enabled; the routes of disabled
+	// modules answer 404 until enabled again.
+	Modules map[string]bool `yaml:"modules,omitempty" json:"modules,omitempty"`
+
 	legacyMigrationPending bool `yaml:"-" json:"-"`
 }
 
@@ -146,6 +314,21 @@ type RemoteManagement struct {
 	// PanelGitHubRepository overrides the GitHub repository used to fetch the management panel asset.
 	// Accepts either a repository URL (https://github.com/org/repo) or an API releases endpoint.
 	PanelGitHubRepository string `yaml:"panel-github-repository"`
+	// AuditLog is the append-only JSONL file recording management API mutations. Empty
+	// uses management-audit.jsonl in the log directory.
+	AuditLog string `yaml:"audit-log,omitempty"`
+	// DisableAuditLog stops recording management API mutations when true.
+	DisableAuditLog bool `yaml:"disable-audit-log,omitempty"`
+	// ConfirmDestructive requires destructive management actions (deleting credentials,
+	// keys, transcripts or logs, resetting counters, importing state) to be repeated with
+	// the confirmation token returned by a first attempt or a dry run.
+	ConfirmDestructive bool `yaml:"confirm-destructive,omitempty"`
+	// CredentialRetentionHours keeps credential files deleted through the management API in
+	// the .trash folder of the auth directory for this many hours, restorable through
+	// POST /v0/management/auth-files/restore. 0 (the default) deletes them permanently.
+	CredentialRetentionHours int `yaml:"credential-retention-hours,omitempty"`
+	// OIDC enables OpenID Connect login for the management API and control panel.
+	OIDC ManagementOIDC `yaml:"oidc,omitempty"`
 }
 
 // QuotaExceeded defines the behavior when API quota limits are exceeded.
@@ -156,6 +339,10 @@ type QuotaExceeded struct {
 
 	// SwitchPreviewModel indicates whether to automatically switch to a preview model when a quota is exceeded.
 	SwitchPreviewModel bool `yaml:"switch-preview-model" json:"switch-preview-model"`
+
+	// ResetSchedules align quota cooldowns and spend limit periods with the providers'
+	// daily or monthly quota resets.
+	ResetSchedules []QuotaResetSchedule `yaml:"reset-schedules,omitempty" json:"reset-schedules,omitempty"`
 }
 
 // RoutingConfig configures how credentials are selected for requests.
@@ -163,6 +350,13 @@ type RoutingConfig struct {
 	// Strategy selects the credential selection strategy.
 	// Supported values: "round-robin" (default), "fill-first".
 	Strategy string `yaml:"strategy,omitempty" json:"strategy,omitempty"`
+
+	// Rules are declarative routing rules evaluated in order for each request.
+	Rules []RoutingRule `yaml:"rules,omitempty" json:"rules,omitempty"`
+
+	// Trace adds an X-CLIProxy-Route-Trace response header (and a debug log line) listing
+	// the routing rules evaluated and the model mapping decisions taken for each request.
+	Trace bool `yaml:"trace,omitempty" json:"trace,omitempty"`
 }
 
 // OAuthModelAlias defines a model ID alias for a specific channel.
@@ -190,6 +384,46 @@ type AmpModelMapping struct {
 	// expression for matching model names. When true, this mapping is evaluated
 	// after exact matches and in the order provided. Defaults to false (exact match).
 	Regex bool `yaml:"regex,omitempty" json:"regex,omitempty"`
+
+	// Params overrides request parameters when the mapping applies, e.g. temperature: 0.4
+	// or top_p: 0.95. Keys are JSON paths of the request body; temperature, top_p, top_k
+	// and max_tokens are written to generationConfig for Gemini requests. A null value
+	// removes the parameter.
+	Params map[string]any `yaml:"params,omitempty" json:"params,omitempty"`
+
+	// PromptAdapter is prepended to the system prompt when the mapping applies, for
+	// instructions the target model needs that the requested one does not.
+	PromptAdapter string `yaml:"prompt-adapter,omitempty" json:"prompt-adapter,omitempty"`
+
+	// PostProcess rewrites the generated text of responses served through the mapping, in
+	// order, for target models that format their output differently than the client expects.
+	PostProcess []AmpPostProcessor `yaml:"post-process,omitempty" json:"post-process,omitempty"`
+}
+
+// Response post-processor types of model mappings.
+const (
+	// AmpPostProcessRegex replaces matches of Pattern with Replace.
+	AmpPostProcessRegex = "regex"
+	// AmpPostProcessTrimStop cuts the text at the first of the Stop strings.
+	AmpPostProcessTrimStop = "trim-stop"
+	// AmpPostProcessNormalizeFences rewrites markdown code fences to the ``` form with a
+	// lowercase language tag and closes a fence left open at the end of the response.
+	AmpPostProcessNormalizeFences = "normalize-fences"
+)
+
+// AmpPostProcessor is one step of a mapping's response post-processing.
+type AmpPostProcessor struct {
+	// Type is regex, trim-stop or normalize-fences.
+	Type string `yaml:"type" json:"type"`
+
+	// Pattern is the regular expression of a regex step.
+	Pattern string `yaml:"pattern,omitempty" json:"pattern,omitempty"`
+
+	// Replace is the replacement of a regex step; $1 expands to the first group.
+	Replace string `yaml:"replace,omitempty" json:"replace,omitempty"`
+
+	// Stop lists the stop strings of a trim-stop step.
+	Stop []string `yaml:"stop,omitempty" json:"stop,omitempty"`
 }
 
 // AmpCode groups Amp CLI integration settings including upstream routing,
@@ -219,6 +453,205 @@ type AmpCode struct {
 	// ForceModelMappings when true, model mappings take precedence over local API keys.
 	// When false (default), local API keys are used first if available.
 	ForceModelMappings bool `yaml:"force-model-mappings" json:"force-model-mappings"`
+
+	// UpstreamHeaders are added to every request proxied to UpstreamURL.
+	UpstreamHeaders map[string]string `yaml:"upstream-headers,omitempty" json:"upstream-headers,omitempty"`
+
+	// FallbackUpstreams are tried in order when UpstreamURL is unreachable or unhealthy,
+	// e.g. regional Amp relays.
+	FallbackUpstreams []AmpUpstream `yaml:"fallback-upstreams,omitempty" json:"fallback-upstreams,omitempty"`
+
+	// UpstreamHealthCheckSeconds is the interval of the active upstream health checks run
+	// when fallback upstreams are configured. 0 uses 30 seconds; negative disables them, and
+	// upstreams are then only marked unhealthy by failed requests.
+	UpstreamHealthCheckSeconds int `yaml:"upstream-health-check-seconds,omitempty" json:"upstream-health-check-seconds,omitempty"`
+
+	// UpstreamProxy tunes the reverse proxy forwarding requests to UpstreamURL.
+	UpstreamProxy AmpUpstreamProxy `yaml:"upstream-proxy,omitempty" json:"upstream-proxy,omitempty"`
+
+	// CreditResponseRewrite post-processes responses of requests forwarded to the Amp
+	// upstream because no local provider served them (the AMP_CREDITS route).
+	CreditResponseRewrite AmpResponseRewrite `yaml:"credit-response-rewrite,omitempty" json:"credit-response-rewrite,omitempty"`
+
+	// CreditPricing prices the token usage of AMP_CREDITS requests so the logs and usage
+	// statistics show an estimated credit cost. Entries take precedence over Pricing.
+	CreditPricing []AmpCreditPrice `yaml:"credit-pricing,omitempty" json:"credit-pricing,omitempty"`
+
+	// ContextCompaction summarizes the oldest turns of Claude conversations that a model
+	// mapping sends to a model whose context window they do not fit.
+	ContextCompaction AmpContextCompaction `yaml:"context-compaction,omitempty" json:"context-compaction,omitempty"`
+
+	// NoProviderResponse answers requests no local provider, model mapping or Amp upstream
+	// can serve (the NO_PROVIDER route) with an assistant message instead of an error.
+	NoProviderResponse AmpNoProviderResponse `yaml:"no-provider-response,omitempty" json:"no-provider-response,omitempty"`
+
+	// ErrorFallback forwards requests to UpstreamURL when the local provider serving them
+	// fails, not only when no local provider exists.
+	ErrorFallback AmpErrorFallback `yaml:"error-fallback,omitempty" json:"error-fallback,omitempty"`
+
+	// DisabledModels lists models ('*' wildcards allowed) that are never served by a local
+	// provider, even when one advertises them. Requests for them follow the model mappings
+	// or go to UpstreamURL, e.g. to reserve the quota of a local credential.
+	DisabledModels []string `yaml:"disabled-models,omitempty" json:"disabled-models,omitempty"`
+
+	// ModelFields lists further gjson paths searched in order for the requested model when the
+	// body has no top-level "model", e.g. "request.model" or "generation_config.model".
+	ModelFields []string `yaml:"model-fields,omitempty" json:"model-fields,omitempty"`
+
+	// MissingModel decides how requests are handled whose model is found neither in the body
+	// nor in the URL path.
+	MissingModel AmpMissingModel `yaml:"missing-model,omitempty" json:"missing-model,omitempty"`
+}
+
+// Policies for Amp requests without an extractable model.
+const (
+	// AmpMissingModelPassthrough hands the request to the local handler unchanged.
+	AmpMissingModelPassthrough = "pass-through"
+	// AmpMissingModelReject fails the request with 400.
+	AmpMissingModelReject = "reject"
+	// AmpMissingModelDefault serves the request with DefaultModel, through the model
+	// mappings and the ampcode.com fallback like any other request.
+	AmpMissingModelDefault = "default-model"
+)
+
+// AmpMissingModel configures Amp requests whose model cannot be extracted. Every such request
+// is counted in cliproxy_model_extraction_failures_total.
+type AmpMissingModel struct {
+	// Policy is "pass-through" (default), "reject" or "default-model".
+	Policy string `yaml:"policy,omitempty" json:"policy,omitempty"`
+
+	// DefaultModel is the model assumed by the "default-model" policy.
+	DefaultModel string `yaml:"default-model,omitempty" json:"default-model,omitempty"`
+}
+
+// AmpErrorFallback configures the fallback to Amp credits for failed local requests. Only
+// errors returned before any response bytes were sent can fall back.
+type AmpErrorFallback struct {
+	// Enabled turns the fallback on.
+	Enabled bool `yaml:"enabled" json:"enabled"`
+
+	// On lists the error classes that fall back: "quota" (429), "5xx" (500-599), "timeout"
+	// (408, 504), "auth" (401, 403) or a status code such as "529". Defaults to quota and 5xx.
+	On []string `yaml:"on,omitempty" json:"on,omitempty"`
+
+	// DailyLimit caps the fallbacks per UTC day to bound the credits spent. 0 is unlimited.
+	DailyLimit int `yaml:"daily-limit,omitempty" json:"daily-limit,omitempty"`
+}
+
+// AmpNoProviderResponse configures the assistant message synthesized for NO_PROVIDER
+// requests. It is rendered in the dialect of the request (Claude, OpenAI chat completions,
+// OpenAI Responses or Gemini), streamed when the client asked for a stream.
+type AmpNoProviderResponse struct {
+	// Enabled turns the synthesized response on.
+	Enabled bool `yaml:"enabled" json:"enabled"`
+
+	// Message is the text of the reply. {model} is replaced with the requested model,
+	// {models} with a few available models and {reason} with the model mapping that had no
+	// available provider, if any. Empty uses a built-in message suggesting a model mapping.
+	Message string `yaml:"message,omitempty" json:"message,omitempty"`
+}
+
+// AmpContextCompaction configures the compaction of remapped conversations. It is enabled
+// when SummaryModel is set.
+type AmpContextCompaction struct {
+	// SummaryModel is the local model that summarizes the compacted turns, ideally a cheap one.
+	SummaryModel string `yaml:"summary-model,omitempty" json:"summary-model,omitempty"`
+
+	// ReserveTokens is kept free in the target's context window besides max_tokens, for
+	// the estimate's error. 0 uses 2048.
+	ReserveTokens int `yaml:"reserve-tokens,omitempty" json:"reserve-tokens,omitempty"`
+
+	// SummaryMaxTokens caps the length of the summary. 0 uses 2048.
+	SummaryMaxTokens int `yaml:"summary-max-tokens,omitempty" json:"summary-max-tokens,omitempty"`
+}
+
+// AmpCreditPrice is the estimated Amp credit price of a model, in credits (USD) per million tokens.
+type AmpCreditPrice struct {
+	// Model is the model id; '*' matches any substring (e.g. "claude-sonnet-4*").
+	Model string `yaml:"model" json:"model"`
+
+	InputCostPerMillion  float64 `yaml:"input-cost-per-million" json:"input-cost-per-million"`
+	OutputCostPerMillion float64 `yaml:"output-cost-per-million" json:"output-cost-per-million"`
+
+	// CachedInputCostPerMillion prices cache reads. 0 prices them like regular input.
+	CachedInputCostPerMillion float64 `yaml:"cached-input-cost-per-million,omitempty" json:"cached-input-cost-per-million,omitempty"`
+}
+
+// AmpResponseRewrite describes edits applied to JSON and SSE response bodies so clients see
+// the same shape whether a local provider or the Amp upstream served the request.
+type AmpResponseRewrite struct {
+	// ModelAliases rewrites model ids in responses (upstream id -> id shown to clients).
+	ModelAliases map[string]string `yaml:"model-aliases,omitempty" json:"model-aliases,omitempty"`
+
+	// RemoveFields lists JSON paths (gjson syntax) deleted from responses and stream events.
+	RemoveFields []string `yaml:"remove-fields,omitempty" json:"remove-fields,omitempty"`
+
+	// Replace applies regular expression substitutions to the body, e.g. to strip billing banners.
+	Replace []AmpResponseReplacement `yaml:"replace,omitempty" json:"replace,omitempty"`
+}
+
+// AmpResponseReplacement is a regular expression substitution applied to response bodies.
+type AmpResponseReplacement struct {
+	// Pattern is a Go regular expression.
+	Pattern string `yaml:"pattern" json:"pattern"`
+
+	// With replaces each match; $1-style group references are expanded.
+	With string `yaml:"with" json:"with"`
+}
+
+// AmpUpstream is a secondary Amp upstream used for failover.
+type AmpUpstream struct {
+	// URL is the upstream base URL.
+	URL string `yaml:"url" json:"url"`
+
+	// APIKey replaces the Amp upstream API key for requests sent to this upstream.
+	// When empty, the key resolved for the primary upstream is used.
+	APIKey string `yaml:"api-key,omitempty" json:"api-key,omitempty"`
+
+	// Headers are added to every request proxied to this upstream.
+	Headers map[string]string `yaml:"headers,omitempty" json:"headers,omitempty"`
+}
+
+// AmpUpstreamProxy tunes the Amp upstream reverse proxy. Zero values keep the Go defaults.
+type AmpUpstreamProxy struct {
+	// FlushIntervalMs is how often buffered response bytes are flushed to the client.
+	// Event streams are always flushed immediately; a negative value flushes every write.
+	FlushIntervalMs int `yaml:"flush-interval-ms,omitempty" json:"flush-interval-ms,omitempty"`
+
+	// ResponseHeaderTimeoutSeconds bounds the wait for the upstream response headers.
+	ResponseHeaderTimeoutSeconds int `yaml:"response-header-timeout-seconds,omitempty" json:"response-header-timeout-seconds,omitempty"`
+
+	// MaxIdleConns caps the idle upstream connections kept open.
+	MaxIdleConns int `yaml:"max-idle-conns,omitempty" json:"max-idle-conns,omitempty"`
+
+	// MaxIdleConnsPerHost caps the idle connections kept open per upstream host.
+	MaxIdleConnsPerHost int `yaml:"max-idle-conns-per-host,omitempty" json:"max-idle-conns-per-host,omitempty"`
+
+	// IdleConnTimeoutSeconds closes idle upstream connections after this long.
+	IdleConnTimeoutSeconds int `yaml:"idle-conn-timeout-seconds,omitempty" json:"idle-conn-timeout-seconds,omitempty"`
+
+	// MaxRequestBodyBytes rejects larger request bodies with 413. 0 is unlimited.
+	MaxRequestBodyBytes int64 `yaml:"max-request-body-bytes,omitempty" json:"max-request-body-bytes,omitempty"`
+
+	// TimeoutSeconds bounds a whole proxied request, including the response body. 0 is unlimited.
+	TimeoutSeconds int `yaml:"timeout-seconds,omitempty" json:"timeout-seconds,omitempty"`
+
+	// Routes override the body size and timeout limits for request paths; the longest
+	// matching prefix applies.
+	Routes []AmpUpstreamProxyRoute `yaml:"routes,omitempty" json:"routes,omitempty"`
+}
+
+// AmpUpstreamProxyRoute overrides the Amp upstream proxy limits for one path prefix.
+// Zero values inherit the proxy-wide limit; negative values remove it.
+type AmpUpstreamProxyRoute struct {
+	// PathPrefix selects the requests, e.g. "/api/threads".
+	PathPrefix string `yaml:"path-prefix" json:"path-prefix"`
+
+	// MaxRequestBodyBytes rejects larger request bodies with 413.
+	MaxRequestBodyBytes int64 `yaml:"max-request-body-bytes,omitempty" json:"max-request-body-bytes,omitempty"`
+
+	// TimeoutSeconds bounds a whole proxied request.
+	TimeoutSeconds int `yaml:"timeout-seconds,omitempty" json:"timeout-seconds,omitempty"`
 }
 
 // AmpUpstreamAPIKeyEntry maps a set of client API keys to a specific upstream API key.
@@ -300,9 +733,16 @@ type ClaudeKey struct {
 	// Higher values are preferred; defaults to 0.
 	Priority int `yaml:"priority,omitempty" json:"priority,omitempty"`
 
+	// DisableStreaming serves streaming requests with a non-streaming upstream call whose
+	// response is replayed to the client as a synthesized stream.
+	DisableStreaming bool `yaml:"disable-streaming,omitempty" json:"disable-streaming,omitempty"`
+
 	// Prefix optionally namespaces models for this credential (e.g., "teamA/claude-sonnet-4").
 	Prefix string `yaml:"prefix,omitempty" json:"prefix,omitempty"`
 
+	// Group names the provider group this credential inherits settings from.
+	Group string `yaml:"group,omitempty" json:"group,omitempty"`
+
 	// BaseURL is the base URL for the Claude API endpoint.
 	// If empty, the default Claude API URL will be used.
 	BaseURL string `yaml:"base-url" json:"base-url"`
@@ -348,9 +788,16 @@ type CodexKey struct {
 	// Higher values are preferred; defaults to 0.
 	Priority int `yaml:"priority,omitempty" json:"priority,omitempty"`
 
+	// DisableStreaming serves streaming requests with a non-streaming upstream call whose
+	// response is replayed to the client as a synthesized stream.
+	DisableStreaming bool `yaml:"disable-streaming,omitempty" json:"disable-streaming,omitempty"`
+
 	// Prefix optionally namespaces models for this credential (e.g., "teamA/gpt-5-codex").
 	Prefix string `yaml:"prefix,omitempty" json:"prefix,omitempty"`
 
+	// Group names the provider group this credential inherits settings from.
+	Group string `yaml:"group,omitempty" json:"group,omitempty"`
+
 	// BaseURL is the base URL for the Codex API endpoint.
 	// If empty, the default Codex API URL will be used.
 	BaseURL string `yaml:"base-url" json:"base-url"`
@@ -393,9 +840,16 @@ type GeminiKey struct {
 	// Higher values are preferred; defaults to 0.
 	Priority int `yaml:"priority,omitempty" json:"priority,omitempty"`
 
+	// DisableStreaming serves streaming requests with a non-streaming upstream call whose
+	// response is replayed to the client as a synthesized stream.
+	DisableStreaming bool `yaml:"disable-streaming,omitempty" json:"disable-streaming,omitempty"`
+
 	// Prefix optionally namespaces models for this credential (e.g., "teamA/gemini-3-pro-preview").
 	Prefix string `yaml:"prefix,omitempty" json:"prefix,omitempty"`
 
+	// Group names the provider group this credential inherits settings from.
+	Group string `yaml:"group,omitempty" json:"group,omitempty"`
+
 	// BaseURL optionally overrides the Gemini API endpoint.
 	BaseURL string `yaml:"base-url,omitempty" json:"base-url,omitempty"`
 
@@ -437,9 +891,16 @@ type OpenAICompatibility struct {
 	// Higher values are preferred; defaults to 0.
 	Priority int `yaml:"priority,omitempty" json:"priority,omitempty"`
 
+	// DisableStreaming serves streaming requests with a non-streaming upstream call whose
+	// response is replayed to the client as a synthesized stream.
+	DisableStreaming bool `yaml:"disable-streaming,omitempty" json:"disable-streaming,omitempty"`
+
 	// Prefix optionally namespaces model aliases for this provider (e.g., "teamA/kimi-k2").
 	Prefix string `yaml:"prefix,omitempty" json:"prefix,omitempty"`
 
+	// Group names the provider group this provider inherits settings from.
+	Group string `yaml:"group,omitempty" json:"group,omitempty"`
+
 	// BaseURL is the base URL for the external OpenAI-compatible API endpoint.
 	BaseURL string `yaml:"base-url" json:"base-url"`
 
@@ -532,6 +993,8 @@ func LoadConfigOptional(configFile string, optional bool) (*Config, error) {
 	cfg.Pprof.Addr = DefaultPprofAddr
 	cfg.AmpCode.RestrictManagementToLocalhost = false // Default to false: API key auth is sufficient
 	cfg.RemoteManagement.PanelGitHubRepository = DefaultPanelGitHubRepository
+	cfg.Transcripts.RetentionDays = DefaultTranscriptRetentionDays
+	cfg.Storage.UsageRetentionDays = DefaultUsageRetentionDays
 	if err = yaml.Unmarshal(data, &cfg); err != nil {
 		if optional {
 			// In cloud deploy mode, if YAML parsing fails, return empty config instead of error.
@@ -603,15 +1066,236 @@ func LoadConfigOptional(configFile string, optional bool) (*Config, error) {
 	// Sanitize OpenAI compatibility providers: drop entries without base-url
 	cfg.SanitizeOpenAICompatibility()
 
+	// Normalize mock providers.
+	cfg.SanitizeMockProviders()
+
+	// Normalize provider groups and the group references of credentials.
+	cfg.SanitizeProviderGroups()
+
 	// Normalize OAuth provider model exclusion map.
 	cfg.OAuthExcludedModels = NormalizeOAuthExcludedModels(cfg.OAuthExcludedModels)
 
+	// Validate OAuth provider base URL overrides.
+	cfg.SanitizeOAuthBaseURL()
+
 	// Normalize global OAuth model name aliases.
 	cfg.SanitizeOAuthModelAlias()
 
 	// Validate raw payload rules and drop invalid entries.
 	cfg.SanitizePayloadRules()
 
+	// Normalize Gemini safety setting and generation config defaults.
+	cfg.SanitizeGeminiDefaults()
+
+	// Normalize the extra OpenAI reasoning model patterns.
+	cfg.SanitizeOpenAIReasoning()
+
+	// Normalize transcript store settings.
+	cfg.SanitizeTranscripts()
+
+	// Normalize storage backend settings.
+	if errStorage := cfg.SanitizeStorage(); errStorage != nil {
+		return nil, fmt.Errorf("invalid storage config: %w", errStorage)
+	}
+
+	// Apply the model listing cache defaults.
+	cfg.SanitizeModelsCache()
+
+	// Normalize spend limit entries.
+	cfg.SanitizeSpendLimits()
+
+	// Normalize SLO rules.
+	cfg.SanitizeSLO()
+
+	// Normalize project scoping entries.
+	cfg.SanitizeProjects()
+
+	// Apply temporary key defaults.
+	cfg.SanitizeTemporaryKeys()
+
+	// Normalize virtual host entries.
+	cfg.SanitizeVirtualHosts()
+
+	// Normalize high-availability shared state settings.
+	cfg.SanitizeSharedState()
+
+	// Normalize declarative routing rules.
+	cfg.SanitizeRoutingRules()
+
+	// Normalize the release checker settings.
+	cfg.SanitizeUpdateCheck()
+
+	// Normalize the Idempotency-Key cache settings.
+	cfg.SanitizeIdempotency()
+
+	// Normalize the upstream timeout policies.
+	cfg.SanitizeTimeouts()
+
+	// Normalize the DNS cache nameservers and defaults.
+	cfg.SanitizeDNSCache()
+
+	// Normalize offline mode settings.
+	cfg.SanitizeOffline()
+
+	// Normalize concurrency limits and priority classes.
+	cfg.SanitizeScheduling()
+
+	// Normalize the stream throttle rates.
+	cfg.SanitizeStreamThrottle()
+
+	// Drop speculative race rules without enough targets.
+	cfg.SanitizeSpeculativeRacing()
+
+	// Apply context-fit routing defaults and drop incomplete rules.
+	cfg.SanitizeContextFit()
+
+	// Drop incomplete refusal fallback rules.
+	cfg.SanitizeRefusalFallback()
+
+	// Apply the client profile presets.
+	cfg.SanitizeClientProfiles()
+
+	// Drop default model rules without a model.
+	cfg.SanitizeDefaultModels()
+
+	// Normalize the fan-out endpoint settings.
+	cfg.SanitizeFanOut()
+
+	// Normalize the moderation endpoint settings.
+	cfg.SanitizeModeration()
+
+	// Normalize output guardrail rules.
+	cfg.SanitizeOutputGuardrails()
+
+	// Normalize the max_tokens and stop sequence policy.
+	cfg.SanitizeGenerationPolicy()
+
+	// Normalize the semantic cache settings.
+	cfg.SanitizeSemanticCache()
+
+	// Normalize record-and-replay settings.
+	cfg.SanitizeRecording()
+
+	// Normalize the unknown thinking suffix policy.
+	cfg.SanitizeUnknownThinkingSuffix()
+
+	// Drop prompt templates without a name or prompt.
+	cfg.SanitizePromptTemplates()
+
+	// Normalize the brokered MCP tool servers.
+	cfg.SanitizeMCPTools()
+
+	// Normalize the agent loop budgets.
+	cfg.SanitizeAgentBudget()
+
+	// Normalize the agent loop breaker settings.
+	cfg.SanitizeLoopBreaker()
+
+	// Normalize the synthetic probe targets.
+	cfg.SanitizeSyntheticProbes()
+
+	// Normalize the scheduled prompts.
+	cfg.SanitizeScheduledPrompts()
+
+	// Apply the maintenance mode defaults.
+	cfg.SanitizeMaintenance()
+
+	// Normalize the log language.
+	cfg.SanitizeLogLanguage()
+
+	// Normalize the log format and component levels.
+	cfg.SanitizeLogging()
+
+	// Normalize the request mirroring target.
+	cfg.SanitizeMirror()
+
+	// Apply the model discovery cache defaults.
+	cfg.SanitizeModelDiscovery()
+
+	// Apply the mapping preflight defaults.
+	cfg.SanitizeMappingPreflight()
+
+	// Normalize the startup warm-up models.
+	cfg.SanitizeWarmup()
+
+	// Apply the gRPC listen address default.
+	cfg.SanitizeGRPC()
+
+	// Validate the listen addresses, so an IPv6 literal or a malformed address fails here
+	// rather than when binding.
+	if errListen := cfg.ValidateListenAddresses(); errListen != nil {
+		return nil, fmt.Errorf("invalid listen address: %w", errListen)
+	}
+
+	// Normalize the raw passthrough routes.
+	cfg.SanitizePassthrough()
+
+	// Normalize the upstream header rules.
+	cfg.SanitizeHeaderRules()
+
+	// Normalize the client fingerprints.
+	cfg.SanitizeClientFingerprint()
+
+	// Normalize the stream parsing mode.
+	cfg.SanitizeStreamParsing()
+
+	// Apply stream resumption defaults.
+	cfg.SanitizeStreamResumption()
+
+	// Apply response spill defaults.
+	cfg.SanitizeResponseSpill()
+
+	// Apply async job defaults.
+	cfg.SanitizeAsyncJobs()
+
+	// Normalize management OIDC login settings.
+	cfg.SanitizeManagementOIDC()
+
+	// Normalize IP access rules.
+	cfg.SanitizeIPAccess()
+
+	// Apply API key brute-force protection defaults.
+	cfg.SanitizeAuthGuard()
+
+	// Normalize the AugPlus remote access token.
+	cfg.SanitizeAugPlus()
+
+	// Normalize the Claude Code compatible route settings.
+	cfg.SanitizeClaudeCode()
+
+	// Normalize the JetBrains AI Assistant compatible route settings.
+	cfg.SanitizeJetBrains()
+
+	// Normalize the CORS policies.
+	cfg.SanitizeCORS()
+
+	// Normalize quota reset schedules.
+	cfg.SanitizeQuotaResetSchedules()
+
+	// Normalize model prices.
+	cfg.SanitizePricing()
+
+	// Normalize usage report settings.
+	cfg.SanitizeUsageReports()
+
+	// Normalize the credential backup settings.
+	cfg.SanitizeBackup()
+
+	// Normalize metrics label and exporter settings.
+	cfg.SanitizeMetrics()
+
+	// Normalize fault injection rules.
+	cfg.SanitizeFaultInjection()
+
+	// Normalize feature flag names.
+	cfg.SanitizeFeatureFlags()
+
+	// Normalize routing module names.
+	cfg.SanitizeModules()
+
+	// Normalize the Amp missing-model policy.
+	cfg.SanitizeAmpMissingModel()
+
 	if cfg.legacyMigrationPending {
 		fmt.Println("Detected legacy configuration keys, attempting to persist the normalized config...")
 		if !optional && configFile != "" {
@@ -917,38 +1601,55 @@ func hashSecret(secret string) (string, error) {
 // SaveConfigPreserveComments writes the config back to YAML while preserving existing comments
 // and key ordering by loading the original file into a yaml.Node tree and updating values in-place.
 func SaveConfigPreserveComments(configFile string, cfg *Config) error {
-	persistCfg := sanitizeConfigForPersist(cfg)
 	// Load original YAML as a node tree to preserve comments and ordering.
 	data, err := os.ReadFile(configFile)
 	if err != nil {
 		return err
 	}
+	data, err = renderConfigPreserveComments(data, cfg)
+	if err != nil {
+		return err
+	}
 
-	var original yaml.Node
-	if err = yaml.Unmarshal(data, &original); err != nil {
+	// Write back.
+	f, err := os.Create(configFile)
+	if err != nil {
 		return err
 	}
+	defer func() { _ = f.Close() }()
+	_, err = f.Write(data)
+	return err
+}
+
+// renderConfigPreserveComments merges cfg into the original YAML document and returns the
+// result, keeping the comments and key order of the original.
+func renderConfigPreserveComments(data []byte, cfg *Config) ([]byte, error) {
+	persistCfg := sanitizeConfigForPersist(cfg)
+	var original yaml.Node
+	if err := yaml.Unmarshal(data, &original); err != nil {
+		return nil, err
+	}
 	if original.Kind != yaml.DocumentNode || len(original.Content) == 0 {
-		return fmt.Errorf("invalid yaml document structure")
+		return nil, fmt.Errorf("invalid yaml document structure")
 	}
 	if original.Content[0] == nil || original.Content[0].Kind != yaml.MappingNode {
-		return fmt.Errorf("expected root mapping node")
+		return nil, fmt.Errorf("expected root mapping node")
 	}
 
 	// Marshal the current cfg to YAML, then unmarshal to a yaml.Node we can merge from.
 	rendered, err := yaml.Marshal(persistCfg)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	var generated yaml.Node
 	if err = yaml.Unmarshal(rendered, &generated); err != nil {
-		return err
+		return nil, err
 	}
 	if generated.Kind != yaml.DocumentNode || len(generated.Content) == 0 || generated.Content[0] == nil {
-		return fmt.Errorf("invalid generated yaml structure")
+		return nil, fmt.Errorf("invalid generated yaml structure")
 	}
 	if generated.Content[0].Kind != yaml.MappingNode {
-		return fmt.Errorf("expected generated root mapping node")
+		return nil, fmt.Errorf("expected generated root mapping node")
 	}
 
 	// Remove deprecated sections before merging back the sanitized config.
@@ -964,25 +1665,17 @@ func SaveConfigPreserveComments(configFile string, cfg *Config) error {
 	mergeMappingPreserve(original.Content[0], generated.Content[0])
 	normalizeCollectionNodeStyles(original.Content[0])
 
-	// Write back.
-	f, err := os.Create(configFile)
-	if err != nil {
-		return err
-	}
-	defer func() { _ = f.Close() }()
 	var buf bytes.Buffer
 	enc := yaml.NewEncoder(&buf)
 	enc.SetIndent(2)
 	if err = enc.Encode(&original); err != nil {
 		_ = enc.Close()
-		return err
+		return nil, err
 	}
 	if err = enc.Close(); err != nil {
-		return err
+		return nil, err
 	}
-	data = NormalizeCommentIndentation(buf.Bytes())
-	_, err = f.Write(data)
-	return err
+	return NormalizeCommentIndentation(buf.Bytes()), nil
 }
 
 func sanitizeConfigForPersist(cfg *Config) *Config {
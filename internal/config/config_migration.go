@@ -0,0 +1,105 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+
+	"gopkg.in/yaml.v3"
+)
+
+// legacyRootKeys lists top-level keys from older releases and how MigrateConfigData
+// rewrites them.
+var legacyRootKeys = []struct {
+	key  string
+	note string
+}{
+	{"generative-language-api-key", "moved generative-language-api-key entries to gemini-api-key"},
+	{"amp-upstream-url", "moved amp-upstream-url to ampcode.upstream-url"},
+	{"amp-upstream-api-key", "moved amp-upstream-api-key to ampcode.upstream-api-key"},
+	{"amp-restrict-management-to-localhost", "moved amp-restrict-management-to-localhost to ampcode.restrict-management-to-localhost"},
+	{"amp-model-mappings", "moved amp-model-mappings to ampcode.model-mappings"},
+	{"auth", "moved auth.providers API keys to api-keys and removed the auth block"},
+}
+
+// MigrateConfigData upgrades a config.yaml document written for an older release to the
+// current schema. It applies the same migrations LoadConfig performs on start-up but
+// returns the result instead of writing it, together with a description of each change.
+// Comments and key order are preserved. When nothing needs migrating the input is
+// returned unchanged with no changes.
+func MigrateConfigData(data []byte) ([]byte, []string, error) {
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return nil, nil, fmt.Errorf("parse config: %w", err)
+	}
+	if root.Kind != yaml.DocumentNode || len(root.Content) == 0 {
+		return data, nil, nil
+	}
+	rootMap := root.Content[0]
+	if rootMap == nil || rootMap.Kind != yaml.MappingNode {
+		return nil, nil, fmt.Errorf("parse config: expected a mapping at the top level")
+	}
+
+	var changes []string
+	hadOldAliases := findMapKeyIndex(rootMap, "oauth-model-mappings") >= 0
+	if migrateOAuthModelAliasNode(rootMap) {
+		if hadOldAliases {
+			changes = append(changes, "renamed oauth-model-mappings to oauth-model-alias")
+		} else {
+			changes = append(changes, "added the default antigravity oauth-model-alias entries")
+		}
+		var buf bytes.Buffer
+		enc := yaml.NewEncoder(&buf)
+		enc.SetIndent(2)
+		if err := enc.Encode(&root); err != nil {
+			_ = enc.Close()
+			return nil, nil, err
+		}
+		if err := enc.Close(); err != nil {
+			return nil, nil, err
+		}
+		data = buf.Bytes()
+	}
+
+	legacyFound := false
+	for _, legacyKey := range legacyRootKeys {
+		if findMapKeyIndex(rootMap, legacyKey.key) >= 0 {
+			changes = append(changes, legacyKey.note)
+			legacyFound = true
+		}
+	}
+	if idx := findMapKeyIndex(rootMap, "openai-compatibility"); idx >= 0 && idx+1 < len(rootMap.Content) {
+		for _, entry := range rootMap.Content[idx+1].Content {
+			if entry == nil || entry.Kind != yaml.MappingNode || findMapKeyIndex(entry, "api-keys") < 0 {
+				continue
+			}
+			name := "unnamed"
+			if nameIdx := findMapKeyIndex(entry, "name"); nameIdx >= 0 && nameIdx+1 < len(entry.Content) {
+				name = entry.Content[nameIdx+1].Value
+			}
+			changes = append(changes, fmt.Sprintf("moved openai-compatibility %q api-keys to api-key-entries", name))
+			legacyFound = true
+		}
+	}
+	if !legacyFound {
+		return data, changes, nil
+	}
+
+	var cfg Config
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return nil, nil, fmt.Errorf("parse config: %w", err)
+	}
+	var legacy legacyConfigData
+	if err := yaml.Unmarshal(data, &legacy); err != nil {
+		return nil, nil, fmt.Errorf("parse legacy config keys: %w", err)
+	}
+	cfg.migrateLegacyGeminiKeys(legacy.LegacyGeminiKeys)
+	cfg.migrateLegacyOpenAICompatibilityKeys(legacy.OpenAICompat)
+	cfg.migrateLegacyAmpConfig(&legacy)
+	syncInlineAccessProvider(&cfg)
+
+	migrated, err := renderConfigPreserveComments(data, &cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	return migrated, changes, nil
+}
@@ -0,0 +1,67 @@
+package config
+
+import (
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestMigrateConfigData_UpgradesLegacyLayout(t *testing.T) {
+	legacy := `# listen port
+port: 8317
+generative-language-api-key:
+  - "AIza-legacy"
+amp-upstream-url: "https://ampcode.com"
+amp-model-mappings:
+  - from: "claude-opus-4-5"
+    to: "gemini-2.5-pro"
+openai-compatibility:
+  - name: "openrouter"
+    base-url: "https://openrouter.ai/api/v1"
+    api-keys:
+      - "sk-or"
+oauth-model-mappings:
+  gemini-cli:
+    - name: "gemini-2.5-pro"
+      alias: "g25"
+`
+	migrated, changes, err := MigrateConfigData([]byte(legacy))
+	if err != nil {
+		t.Fatalf("MigrateConfigData: %v", err)
+	}
+	if len(changes) != 5 {
+		t.Fatalf("changes = %v, want 5 entries", changes)
+	}
+	out := string(migrated)
+	if !strings.Contains(out, "# listen port") {
+		t.Fatalf("comments were not preserved:\n%s", out)
+	}
+	for _, key := range []string{"generative-language-api-key", "amp-upstream-url", "amp-model-mappings", "oauth-model-mappings", "api-keys:"} {
+		if strings.Contains(out, key) {
+			t.Fatalf("legacy key %q still present:\n%s", key, out)
+		}
+	}
+
+	var cfg Config
+	if err = yaml.Unmarshal(migrated, &cfg); err != nil {
+		t.Fatalf("migrated config does not parse: %v", err)
+	}
+	if len(cfg.GeminiKey) != 1 || cfg.GeminiKey[0].APIKey != "AIza-legacy" {
+		t.Fatalf("gemini-api-key = %+v", cfg.GeminiKey)
+	}
+	if cfg.AmpCode.UpstreamURL != "https://ampcode.com" || len(cfg.AmpCode.ModelMappings) != 1 {
+		t.Fatalf("ampcode = %+v", cfg.AmpCode)
+	}
+	if len(cfg.OpenAICompatibility) != 1 || len(cfg.OpenAICompatibility[0].APIKeyEntries) != 1 {
+		t.Fatalf("openai-compatibility = %+v", cfg.OpenAICompatibility)
+	}
+	if aliases := cfg.OAuthModelAlias["gemini-cli"]; len(aliases) != 1 || aliases[0].Alias != "g25" {
+		t.Fatalf("oauth-model-alias = %+v", cfg.OAuthModelAlias)
+	}
+
+	again, changes, err := MigrateConfigData(migrated)
+	if err != nil || len(changes) != 0 || string(again) != out {
+		t.Fatalf("second migration should be a no-op, got changes %v err %v", changes, err)
+	}
+}
@@ -0,0 +1,61 @@
+package config
+
+// Defaults of context-fit routing.
+const (
+	DefaultContextFitCacheSeconds = 300
+)
+
+// ContextFitConfig reroutes requests whose prompt does not fit the context window of the
+// requested model to a model with a larger one, before any upstream request is sent.
+type ContextFitConfig struct {
+	// Enable turns the check on.
+	Enable bool `yaml:"enable" json:"enable"`
+
+	// CountTokens asks the provider of the requested model for the prompt size through its
+	// count-tokens endpoint. Without it, or when the provider cannot count, the size is
+	// estimated locally.
+	CountTokens bool `yaml:"count-tokens,omitempty" json:"count-tokens,omitempty"`
+
+	// CacheSeconds is how long a prompt's token count is reused. Default: 300.
+	CacheSeconds int `yaml:"cache-seconds,omitempty" json:"cache-seconds,omitempty"`
+
+	// ReserveTokens is kept free for the response when the request sets no output limit.
+	ReserveTokens int `yaml:"reserve-tokens,omitempty" json:"reserve-tokens,omitempty"`
+
+	// Rules list the larger-context models requests are rerouted to.
+	Rules []ContextFitRule `yaml:"rules,omitempty" json:"rules,omitempty"`
+}
+
+// ContextFitRule names the fallbacks of the models matching Models.
+type ContextFitRule struct {
+	// Models are the requested model names the rule applies to; "*" wildcards are allowed.
+	Models []string `yaml:"models" json:"models"`
+
+	// Fallbacks are tried in order; the first whose context window fits the request is used.
+	// Fallbacks without a known context window are assumed to fit.
+	Fallbacks []string `yaml:"fallbacks" json:"fallbacks"`
+}
+
+// SanitizeContextFit applies defaults and drops rules without models or fallbacks.
+func (cfg *Config) SanitizeContextFit() {
+	if cfg == nil {
+		return
+	}
+	fit := &cfg.ContextFit
+	if fit.CacheSeconds <= 0 {
+		fit.CacheSeconds = DefaultContextFitCacheSeconds
+	}
+	if fit.ReserveTokens < 0 {
+		fit.ReserveTokens = 0
+	}
+	rules := make([]ContextFitRule, 0, len(fit.Rules))
+	for _, rule := range fit.Rules {
+		rule.Models = trimNonEmpty(rule.Models)
+		rule.Fallbacks = trimNonEmpty(rule.Fallbacks)
+		if len(rule.Models) == 0 || len(rule.Fallbacks) == 0 {
+			continue
+		}
+		rules = append(rules, rule)
+	}
+	fit.Rules = rules
+}
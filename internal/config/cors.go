@@ -0,0 +1,103 @@
+package config
+
+import (
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// CORSPolicy lists the cross-origin headers sent to browser clients.
+type CORSPolicy struct {
+	// AllowOrigins lists the admitted origins ("https://ide.example.com"). "*" admits any
+	// origin and "https://*.example.com" any subdomain. Defaults to "*".
+	AllowOrigins []string `yaml:"allow-origins,omitempty" json:"allow-origins,omitempty"`
+
+	// AllowMethods defaults to GET, POST, PUT, PATCH, DELETE and OPTIONS.
+	AllowMethods []string `yaml:"allow-methods,omitempty" json:"allow-methods,omitempty"`
+
+	// AllowHeaders lists the request headers browsers may send. Defaults to "*".
+	AllowHeaders []string `yaml:"allow-headers,omitempty" json:"allow-headers,omitempty"`
+
+	// ExposeHeaders lists the response headers scripts may read.
+	ExposeHeaders []string `yaml:"expose-headers,omitempty" json:"expose-headers,omitempty"`
+
+	// AllowCredentials lets browsers send cookies and Authorization headers. It needs
+	// explicit origins: combined with "*" (or the "*" default) it is switched off. Wildcard
+	// headers are then answered with the request's own values.
+	AllowCredentials bool `yaml:"allow-credentials,omitempty" json:"allow-credentials,omitempty"`
+
+	// MaxAgeSeconds lets browsers cache preflight responses. 0 omits the header.
+	MaxAgeSeconds int `yaml:"max-age-seconds,omitempty" json:"max-age-seconds,omitempty"`
+}
+
+// CORSRoute overrides the CORS policy below a path prefix, e.g. "/v1" or "/v0/management".
+// Unset lists inherit the top-level policy.
+type CORSRoute struct {
+	PathPrefix string `yaml:"path-prefix" json:"path-prefix"`
+	CORSPolicy `yaml:",inline"`
+}
+
+// CORSConfig is the default CORS policy plus per route group overrides. The longest
+// matching path prefix wins.
+type CORSConfig struct {
+	CORSPolicy `yaml:",inline"`
+	Routes     []CORSRoute `yaml:"routes,omitempty" json:"routes,omitempty"`
+}
+
+// SanitizeCORS trims the CORS lists, drops routes without a path prefix and turns off
+// allow-credentials where any origin is admitted, since that would hand credentialed
+// responses to every site.
+func (cfg *Config) SanitizeCORS() {
+	if cfg == nil {
+		return
+	}
+	cfg.CORS.CORSPolicy.sanitize()
+	cfg.CORS.CORSPolicy.refuseCredentialedWildcard("cors", cfg.CORS.AllowOrigins)
+	routes := cfg.CORS.Routes[:0]
+	for _, route := range cfg.CORS.Routes {
+		route.PathPrefix = strings.TrimSpace(route.PathPrefix)
+		if route.PathPrefix == "" {
+			continue
+		}
+		if !strings.HasPrefix(route.PathPrefix, "/") {
+			route.PathPrefix = "/" + route.PathPrefix
+		}
+		route.CORSPolicy.sanitize()
+		origins := route.AllowOrigins
+		if len(origins) == 0 {
+			origins = cfg.CORS.AllowOrigins
+		}
+		route.CORSPolicy.refuseCredentialedWildcard("cors route "+route.PathPrefix, origins)
+		routes = append(routes, route)
+	}
+	cfg.CORS.Routes = routes
+}
+
+func (p *CORSPolicy) sanitize() {
+	p.AllowOrigins = trimList(p.AllowOrigins)
+	for i, origin := range p.AllowOrigins {
+		p.AllowOrigins[i] = strings.TrimRight(origin, "/")
+	}
+	p.AllowMethods = upperList(p.AllowMethods)
+	p.AllowHeaders = trimList(p.AllowHeaders)
+	p.ExposeHeaders = trimList(p.ExposeHeaders)
+	if p.MaxAgeSeconds < 0 {
+		p.MaxAgeSeconds = 0
+	}
+}
+
+// refuseCredentialedWildcard clears AllowCredentials when origins, the effective origins of
+// the policy, admit any origin.
+func (p *CORSPolicy) refuseCredentialedWildcard(name string, origins []string) {
+	if !p.AllowCredentials {
+		return
+	}
+	wildcard := len(origins) == 0
+	for _, origin := range origins {
+		wildcard = wildcard || origin == "*"
+	}
+	if wildcard {
+		log.Warnf("%s: allow-credentials needs explicit allow-origins, not \"*\"; credentials disabled", name)
+		p.AllowCredentials = false
+	}
+}
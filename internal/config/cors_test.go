@@ -0,0 +1,23 @@
+package config
+
+import "testing"
+
+func TestSanitizeCORSRefusesCredentialedWildcard(t *testing.T) {
+	cfg := &Config{CORS: CORSConfig{
+		CORSPolicy: CORSPolicy{AllowCredentials: true},
+		Routes: []CORSRoute{
+			{PathPrefix: "/v1", CORSPolicy: CORSPolicy{AllowOrigins: []string{"https://ide.example.com"}, AllowCredentials: true}},
+			{PathPrefix: "/v0/management", CORSPolicy: CORSPolicy{AllowOrigins: []string{"https://a.example.com", "*"}, AllowCredentials: true}},
+		},
+	}}
+	cfg.SanitizeCORS()
+	if cfg.CORS.AllowCredentials {
+		t.Error("credentials kept for the default \"*\" origin")
+	}
+	if !cfg.CORS.Routes[0].AllowCredentials {
+		t.Error("credentials dropped for explicit origins")
+	}
+	if cfg.CORS.Routes[1].AllowCredentials {
+		t.Error("credentials kept for a route admitting \"*\"")
+	}
+}
@@ -0,0 +1,60 @@
+package config
+
+import "strings"
+
+// DefaultModelConfig routes requests that name no model, which some minimal clients send, to
+// a default model of their client API key.
+type DefaultModelConfig struct {
+	// Rules name the default model of client API keys. The first rule listing the key wins;
+	// a rule without keys applies to every key no other rule lists.
+	Rules []DefaultModelRule `yaml:"rules,omitempty" json:"rules,omitempty"`
+
+	// SubstituteUnknown also serves requests for models no provider offers with the default
+	// model, instead of failing them.
+	SubstituteUnknown bool `yaml:"substitute-unknown,omitempty" json:"substitute-unknown,omitempty"`
+}
+
+// DefaultModelRule is the default model of the client API keys in APIKeys.
+type DefaultModelRule struct {
+	// APIKeys are the client API keys the rule applies to; empty applies to every key.
+	APIKeys []string `yaml:"api-keys,omitempty" json:"api-keys,omitempty"`
+
+	// Model is the model served; thinking suffixes are allowed.
+	Model string `yaml:"model" json:"model"`
+}
+
+// ModelFor returns the default model of apiKey, or "" when none is configured.
+func (c DefaultModelConfig) ModelFor(apiKey string) string {
+	fallback := ""
+	for _, rule := range c.Rules {
+		if len(rule.APIKeys) == 0 {
+			if fallback == "" {
+				fallback = rule.Model
+			}
+			continue
+		}
+		for _, key := range rule.APIKeys {
+			if key == apiKey && apiKey != "" {
+				return rule.Model
+			}
+		}
+	}
+	return fallback
+}
+
+// SanitizeDefaultModels trims the default model rules and drops those without a model.
+func (cfg *Config) SanitizeDefaultModels() {
+	if cfg == nil {
+		return
+	}
+	rules := make([]DefaultModelRule, 0, len(cfg.DefaultModels.Rules))
+	for _, rule := range cfg.DefaultModels.Rules {
+		rule.APIKeys = trimNonEmpty(rule.APIKeys)
+		rule.Model = strings.TrimSpace(rule.Model)
+		if rule.Model == "" {
+			continue
+		}
+		rules = append(rules, rule)
+	}
+	cfg.DefaultModels.Rules = rules
+}
@@ -0,0 +1,78 @@
+package config
+
+import (
+	"net"
+	"strings"
+)
+
+// DNS cache defaults.
+const (
+	DefaultDNSCacheMinTTLSeconds     = 5
+	DefaultDNSCacheMaxTTLSeconds     = 300
+	DefaultDNSCacheStaleSeconds      = 600
+	DefaultDNSCacheFallbackDelayMs   = 250
+	DefaultDNSCacheLookupTimeoutSecs = 5
+)
+
+// DNSCacheConfig enables the in-process DNS cache used when dialing upstream hosts. Answers
+// are kept for their record TTL, clamped to the configured bounds, and served past it while
+// the resolver fails. Connections race the IPv6 and IPv4 addresses of a host (happy eyeballs)
+// so a broken address family costs the fallback delay instead of a full connect timeout.
+type DNSCacheConfig struct {
+	// Enable turns the cache on.
+	Enable bool `yaml:"enable" json:"enable"`
+
+	// Servers lists nameservers as host or host:port. Defaults to the nameservers of
+	// /etc/resolv.conf; the system resolver is used when none are known. The search
+	// domains and ndots option of /etc/resolv.conf apply either way.
+	Servers []string `yaml:"servers,omitempty" json:"servers,omitempty"`
+
+	// MinTTLSeconds and MaxTTLSeconds clamp record TTLs. Default to 5 and 300.
+	MinTTLSeconds int `yaml:"min-ttl-seconds,omitempty" json:"min-ttl-seconds,omitempty"`
+	MaxTTLSeconds int `yaml:"max-ttl-seconds,omitempty" json:"max-ttl-seconds,omitempty"`
+
+	// StaleSeconds is how long an expired answer is still served when refreshing it
+	// fails. Defaults to 600; negative disables serving stale answers.
+	StaleSeconds int `yaml:"stale-seconds,omitempty" json:"stale-seconds,omitempty"`
+
+	// FallbackDelayMs is how long a connection attempt to the preferred address family
+	// runs before the other family is tried in parallel. Defaults to 250.
+	FallbackDelayMs int `yaml:"fallback-delay-ms,omitempty" json:"fallback-delay-ms,omitempty"`
+
+	// LookupTimeoutSeconds bounds one resolution. Defaults to 5.
+	LookupTimeoutSeconds int `yaml:"lookup-timeout-seconds,omitempty" json:"lookup-timeout-seconds,omitempty"`
+}
+
+// SanitizeDNSCache normalizes the nameservers and applies the DNS cache defaults.
+func (cfg *Config) SanitizeDNSCache() {
+	if cfg == nil {
+		return
+	}
+	c := &cfg.DNSCache
+	servers := make([]string, 0, len(c.Servers))
+	for _, server := range trimNonEmpty(c.Servers) {
+		if _, _, err := net.SplitHostPort(server); err != nil {
+			server = net.JoinHostPort(strings.Trim(server, "[]"), "53")
+		}
+		servers = append(servers, server)
+	}
+	c.Servers = servers
+	if c.MinTTLSeconds <= 0 {
+		c.MinTTLSeconds = DefaultDNSCacheMinTTLSeconds
+	}
+	if c.MaxTTLSeconds <= 0 {
+		c.MaxTTLSeconds = DefaultDNSCacheMaxTTLSeconds
+	}
+	if c.MaxTTLSeconds < c.MinTTLSeconds {
+		c.MaxTTLSeconds = c.MinTTLSeconds
+	}
+	if c.StaleSeconds == 0 {
+		c.StaleSeconds = DefaultDNSCacheStaleSeconds
+	}
+	if c.FallbackDelayMs <= 0 {
+		c.FallbackDelayMs = DefaultDNSCacheFallbackDelayMs
+	}
+	if c.LookupTimeoutSeconds <= 0 {
+		c.LookupTimeoutSeconds = DefaultDNSCacheLookupTimeoutSecs
+	}
+}
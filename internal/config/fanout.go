@@ -0,0 +1,38 @@
+package config
+
+import "strings"
+
+// DefaultFanOutMaxModels caps how many models one fan-out request may target.
+const DefaultFanOutMaxModels = 8
+
+// FanOutConfig configures the /v1/fanout endpoint, which sends one chat completion request
+// to several models and returns every response, optionally ranked by a judge model.
+type FanOutConfig struct {
+	// Models are fanned out to when the request does not list its own.
+	Models []string `yaml:"models,omitempty" json:"models,omitempty"`
+
+	// JudgeModel ranks the responses when set and the request does not choose another.
+	JudgeModel string `yaml:"judge-model,omitempty" json:"judge-model,omitempty"`
+
+	// MaxModels caps the models of one request. Defaults to 8.
+	MaxModels int `yaml:"max-models,omitempty" json:"max-models,omitempty"`
+}
+
+// SanitizeFanOut trims the fan-out model lists and applies defaults.
+func (cfg *Config) SanitizeFanOut() {
+	if cfg == nil {
+		return
+	}
+	f := &cfg.FanOut
+	models := make([]string, 0, len(f.Models))
+	for _, model := range f.Models {
+		if model = strings.TrimSpace(model); model != "" {
+			models = append(models, model)
+		}
+	}
+	f.Models = models
+	f.JudgeModel = strings.TrimSpace(f.JudgeModel)
+	if f.MaxModels <= 0 {
+		f.MaxModels = DefaultFanOutMaxModels
+	}
+}
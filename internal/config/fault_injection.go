@@ -0,0 +1,74 @@
+package config
+
+import "strings"
+
+// FaultInjectionConfig injects latency, upstream errors, malformed SSE chunks and dropped
+// connections so client resilience can be tested against the proxy. It is meant for test
+// environments only and is disabled by default.
+type FaultInjectionConfig struct {
+	// Enable turns fault injection on. A warning is logged on every reload while enabled.
+	Enable bool `yaml:"enable" json:"enable"`
+
+	// Rules are matched in order; the first rule matching the provider and model applies.
+	Rules []FaultInjectionRule `yaml:"rules,omitempty" json:"rules,omitempty"`
+}
+
+// FaultInjectionRule describes the faults injected into matching requests. Probabilities
+// range from 0 to 1.
+type FaultInjectionRule struct {
+	// Provider is the provider key the rule applies to; empty or "*" matches every provider.
+	Provider string `yaml:"provider,omitempty" json:"provider,omitempty"`
+
+	// Models optionally narrows the rule to matching models ('*' wildcard).
+	Models []string `yaml:"models,omitempty" json:"models,omitempty"`
+
+	// LatencyMs delays upstream attempts by this long plus up to LatencyJitterMs. The delay
+	// applies with LatencyProbability, which defaults to 1 when a latency is set.
+	LatencyMs          int     `yaml:"latency-ms,omitempty" json:"latency-ms,omitempty"`
+	LatencyJitterMs    int     `yaml:"latency-jitter-ms,omitempty" json:"latency-jitter-ms,omitempty"`
+	LatencyProbability float64 `yaml:"latency-probability,omitempty" json:"latency-probability,omitempty"`
+
+	// ErrorProbability fails upstream attempts with ErrorStatus (default 503) and
+	// ErrorMessage instead of calling the provider. Injected errors are recorded against the
+	// credential like real ones, so retries and cooldowns apply.
+	ErrorProbability float64 `yaml:"error-probability,omitempty" json:"error-probability,omitempty"`
+	ErrorStatus      int     `yaml:"error-status,omitempty" json:"error-status,omitempty"`
+	ErrorMessage     string  `yaml:"error-message,omitempty" json:"error-message,omitempty"`
+
+	// MalformedChunkProbability inserts an invalid SSE event before a streamed chunk.
+	MalformedChunkProbability float64 `yaml:"malformed-chunk-probability,omitempty" json:"malformed-chunk-probability,omitempty"`
+
+	// DisconnectProbability drops the client connection before a streamed chunk.
+	DisconnectProbability float64 `yaml:"disconnect-probability,omitempty" json:"disconnect-probability,omitempty"`
+}
+
+// SanitizeFaultInjection normalizes the fault injection rules.
+func (cfg *Config) SanitizeFaultInjection() {
+	if cfg == nil {
+		return
+	}
+	clamp := func(p float64) float64 { return min(max(p, 0), 1) }
+	for i := range cfg.FaultInjection.Rules {
+		rule := &cfg.FaultInjection.Rules[i]
+		rule.Provider = strings.ToLower(strings.TrimSpace(rule.Provider))
+		if rule.Provider == "*" {
+			rule.Provider = ""
+		}
+		rule.Models = trimList(rule.Models)
+		rule.LatencyMs = max(rule.LatencyMs, 0)
+		rule.LatencyJitterMs = max(rule.LatencyJitterMs, 0)
+		rule.LatencyProbability = clamp(rule.LatencyProbability)
+		if rule.LatencyProbability == 0 && rule.LatencyMs+rule.LatencyJitterMs > 0 {
+			rule.LatencyProbability = 1
+		}
+		rule.ErrorProbability = clamp(rule.ErrorProbability)
+		if rule.ErrorStatus < 400 || rule.ErrorStatus > 599 {
+			rule.ErrorStatus = 503
+		}
+		if rule.ErrorMessage = strings.TrimSpace(rule.ErrorMessage); rule.ErrorMessage == "" {
+			rule.ErrorMessage = "injected fault"
+		}
+		rule.MalformedChunkProbability = clamp(rule.MalformedChunkProbability)
+		rule.DisconnectProbability = clamp(rule.DisconnectProbability)
+	}
+}
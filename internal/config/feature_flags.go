@@ -0,0 +1,17 @@
+package config
+
+import "strings"
+
+// SanitizeFeatureFlags lowercases the feature flag names and drops empty ones.
+func (cfg *Config) SanitizeFeatureFlags() {
+	if cfg == nil || len(cfg.FeatureFlags) == 0 {
+		return
+	}
+	flags := make(map[string]bool, len(cfg.FeatureFlags))
+	for name, enabled := range cfg.FeatureFlags {
+		if name = strings.ToLower(strings.TrimSpace(name)); name != "" {
+			flags[name] = enabled
+		}
+	}
+	cfg.FeatureFlags = flags
+}
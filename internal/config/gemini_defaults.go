@@ -0,0 +1,65 @@
+package config
+
+import "strings"
+
+// GeminiDefaultsConfig fills in safetySettings and generationConfig of requests sent to
+// Gemini providers, whatever dialect the client spoke: OpenAI and Claude requests have no
+// way to carry them. Settings the request already has are kept.
+type GeminiDefaultsConfig struct {
+	// Rules apply in order; for each category or generationConfig field the first matching
+	// rule that sets it wins.
+	Rules []GeminiDefaultsRule `yaml:"rules,omitempty" json:"rules,omitempty"`
+}
+
+// GeminiDefaultsRule holds the defaults of the upstream models matching Models.
+type GeminiDefaultsRule struct {
+	// Models are the model names the rule applies to; "*" wildcards are allowed. Both the
+	// upstream model and the client-visible alias are matched.
+	Models []string `yaml:"models" json:"models"`
+
+	// SafetySettings are added for the harm categories the request does not set.
+	SafetySettings []GeminiSafetySetting `yaml:"safety-settings,omitempty" json:"safety-settings,omitempty"`
+
+	// GenerationConfig holds generationConfig fields set when missing, e.g.
+	// {"candidateCount": 1, "thinkingConfig": {"includeThoughts": true}}. Nested objects are
+	// merged field by field.
+	GenerationConfig map[string]any `yaml:"generation-config,omitempty" json:"generation-config,omitempty"`
+}
+
+// GeminiSafetySetting is one entry of a Gemini safetySettings list.
+type GeminiSafetySetting struct {
+	// Category is the harm category, e.g. "HARM_CATEGORY_HARASSMENT"; the
+	// "HARM_CATEGORY_" prefix may be omitted.
+	Category string `yaml:"category" json:"category"`
+	// Threshold is the block threshold, e.g. "BLOCK_ONLY_HIGH", "BLOCK_NONE" or "OFF".
+	Threshold string `yaml:"threshold" json:"threshold"`
+}
+
+// SanitizeGeminiDefaults normalizes categories and thresholds and drops empty rules.
+func (cfg *Config) SanitizeGeminiDefaults() {
+	if cfg == nil {
+		return
+	}
+	rules := make([]GeminiDefaultsRule, 0, len(cfg.GeminiDefaults.Rules))
+	for _, rule := range cfg.GeminiDefaults.Rules {
+		rule.Models = trimNonEmpty(rule.Models)
+		settings := make([]GeminiSafetySetting, 0, len(rule.SafetySettings))
+		for _, setting := range rule.SafetySettings {
+			setting.Category = strings.ToUpper(strings.TrimSpace(setting.Category))
+			setting.Threshold = strings.ToUpper(strings.TrimSpace(setting.Threshold))
+			if setting.Category == "" || setting.Threshold == "" {
+				continue
+			}
+			if !strings.HasPrefix(setting.Category, "HARM_CATEGORY_") {
+				setting.Category = "HARM_CATEGORY_" + setting.Category
+			}
+			settings = append(settings, setting)
+		}
+		rule.SafetySettings = settings
+		if len(rule.Models) == 0 || (len(rule.SafetySettings) == 0 && len(rule.GenerationConfig) == 0) {
+			continue
+		}
+		rules = append(rules, rule)
+	}
+	cfg.GeminiDefaults.Rules = rules
+}
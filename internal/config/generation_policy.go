@@ -0,0 +1,74 @@
+package config
+
+// GenerationPolicyConfig enforces output length limits and stop sequences on every
+// upstream request, whichever dialect the client used.
+type GenerationPolicyConfig struct {
+	// StopSequences are added to the stop sequences of every request.
+	StopSequences []string `yaml:"stop-sequences,omitempty" json:"stop-sequences,omitempty"`
+
+	// Rules set max_tokens limits and extra stop sequences for matching models and keys.
+	// The first matching rule decides the max_tokens policy; the stop sequences of all
+	// matching rules are added.
+	Rules []GenerationPolicyRule `yaml:"rules,omitempty" json:"rules,omitempty"`
+}
+
+// GenerationPolicyRule applies to requests for the listed models, optionally only those
+// authenticated with the listed client API keys.
+type GenerationPolicyRule struct {
+	// Models are model name patterns where '*' matches any run of characters. Empty
+	// matches every model.
+	Models []string `yaml:"models,omitempty" json:"models,omitempty"`
+
+	// APIKeys restricts the rule to these client API keys. Empty matches every key.
+	APIKeys []string `yaml:"api-keys,omitempty" json:"api-keys,omitempty"`
+
+	// DefaultMaxTokens is set when the request has no max_tokens of its own.
+	DefaultMaxTokens int `yaml:"default-max-tokens,omitempty" json:"default-max-tokens,omitempty"`
+
+	// MaxTokens clamps the max_tokens of the request. 0 leaves it unbounded.
+	MaxTokens int `yaml:"max-tokens,omitempty" json:"max-tokens,omitempty"`
+
+	// StopSequences are added to the request's stop sequences.
+	StopSequences []string `yaml:"stop-sequences,omitempty" json:"stop-sequences,omitempty"`
+}
+
+// SanitizeGenerationPolicy trims the policy lists and drops rules that change nothing.
+func (cfg *Config) SanitizeGenerationPolicy() {
+	if cfg == nil {
+		return
+	}
+	p := &cfg.GenerationPolicy
+	p.StopSequences = nonEmptyStops(p.StopSequences)
+	rules := make([]GenerationPolicyRule, 0, len(p.Rules))
+	for _, rule := range p.Rules {
+		rule.Models = trimNonEmpty(rule.Models)
+		rule.APIKeys = trimNonEmpty(rule.APIKeys)
+		rule.StopSequences = nonEmptyStops(rule.StopSequences)
+		if rule.MaxTokens < 0 {
+			rule.MaxTokens = 0
+		}
+		if rule.DefaultMaxTokens < 0 {
+			rule.DefaultMaxTokens = 0
+		}
+		if rule.MaxTokens > 0 && rule.DefaultMaxTokens > rule.MaxTokens {
+			rule.DefaultMaxTokens = rule.MaxTokens
+		}
+		if rule.MaxTokens == 0 && rule.DefaultMaxTokens == 0 && len(rule.StopSequences) == 0 {
+			continue
+		}
+		rules = append(rules, rule)
+	}
+	p.Rules = rules
+}
+
+// nonEmptyStops drops empty stop sequences. Unlike model names they are not trimmed, since
+// whitespace such as "\n\n" is a common stop sequence.
+func nonEmptyStops(values []string) []string {
+	out := make([]string, 0, len(values))
+	for _, value := range values {
+		if value != "" {
+			out = append(out, value)
+		}
+	}
+	return out
+}
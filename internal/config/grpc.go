@@ -0,0 +1,29 @@
+package config
+
+import "strings"
+
+// DefaultGRPCAddr is the listen address of the gRPC ingress when none is configured.
+const DefaultGRPCAddr = "127.0.0.1:8318"
+
+// GRPCConfig enables a gRPC listener serving the chat and completion APIs, so services
+// can call the proxy without HTTP or SSE parsing. Requests authenticate with the same API
+// keys as HTTP requests, passed as "authorization" metadata, and use the TLS settings of
+// the HTTP server.
+type GRPCConfig struct {
+	// Enable starts the gRPC listener.
+	Enable bool `yaml:"enable" json:"enable"`
+
+	// Addr is the host:port the listener binds. Defaults to 127.0.0.1:8318.
+	Addr string `yaml:"addr,omitempty" json:"addr,omitempty"`
+}
+
+// SanitizeGRPC fills the default listen address.
+func (cfg *Config) SanitizeGRPC() {
+	if cfg == nil {
+		return
+	}
+	cfg.GRPC.Addr = strings.TrimSpace(cfg.GRPC.Addr)
+	if cfg.GRPC.Addr == "" {
+		cfg.GRPC.Addr = DefaultGRPCAddr
+	}
+}
@@ -0,0 +1,86 @@
+package config
+
+import (
+	"net/http"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// headerRuleReserved are headers rules may not set or remove because the transport owns them.
+var headerRuleReserved = map[string]bool{
+	"Host":              true,
+	"Content-Length":    true,
+	"Transfer-Encoding": true,
+	"Connection":        true,
+}
+
+// HeaderRule adds, replaces or removes headers of upstream requests matching its
+// providers and models. Every matching rule applies, in order; within a rule, removals
+// run before sets.
+type HeaderRule struct {
+	// Providers restricts the rule to these provider keys (e.g. "claude", "codex" or an
+	// openai-compatibility name). Empty matches every provider.
+	Providers []string `yaml:"providers,omitempty" json:"providers,omitempty"`
+
+	// Models restricts the rule to requested models matching one of the patterns ('*'
+	// wildcards), i.e. the model or mapping alias the client asked for. Empty matches
+	// every model.
+	Models []string `yaml:"models,omitempty" json:"models,omitempty"`
+
+	// Set writes headers, replacing existing values. Values may use the placeholders
+	// {{model}}, {{provider}}, {{auth-id}} and {{header:Name}} (a header of the client
+	// request); a header whose value renders empty is not set.
+	Set map[string]string `yaml:"set,omitempty" json:"set,omitempty"`
+
+	// Remove deletes headers.
+	Remove []string `yaml:"remove,omitempty" json:"remove,omitempty"`
+}
+
+// SanitizeHeaderRules normalizes provider keys, model patterns and header names, dropping
+// reserved headers and rules left without effect.
+func (cfg *Config) SanitizeHeaderRules() {
+	if cfg == nil || len(cfg.HeaderRules) == 0 {
+		return
+	}
+	rules := cfg.HeaderRules[:0]
+	for i, rule := range cfg.HeaderRules {
+		rule.Providers = normalizeLowerList(rule.Providers)
+		rule.Models = normalizeLowerList(rule.Models)
+		set := make(map[string]string, len(rule.Set))
+		for name, value := range rule.Set {
+			name = http.CanonicalHeaderKey(strings.TrimSpace(name))
+			if name == "" || headerRuleReserved[name] {
+				log.Warnf("header-rules[%d]: ignoring header %q", i, name)
+				continue
+			}
+			set[name] = value
+		}
+		rule.Set = set
+		var remove []string
+		for _, name := range rule.Remove {
+			name = http.CanonicalHeaderKey(strings.TrimSpace(name))
+			if name == "" || headerRuleReserved[name] {
+				log.Warnf("header-rules[%d]: ignoring header %q", i, name)
+				continue
+			}
+			remove = append(remove, name)
+		}
+		rule.Remove = remove
+		if len(rule.Set) == 0 && len(rule.Remove) == 0 {
+			continue
+		}
+		rules = append(rules, rule)
+	}
+	cfg.HeaderRules = rules
+}
+
+func normalizeLowerList(values []string) []string {
+	var out []string
+	for _, value := range values {
+		if value = strings.ToLower(strings.TrimSpace(value)); value != "" {
+			out = append(out, value)
+		}
+	}
+	return out
+}
@@ -0,0 +1,28 @@
+package config
+
+// DefaultIdempotencyMaxEntries bounds the Idempotency-Key cache when max-entries is unset.
+const DefaultIdempotencyMaxEntries = 1000
+
+// IdempotencyConfig controls the Idempotency-Key cache. When a non-streaming request carries
+// an Idempotency-Key header, its successful response is kept for the window and returned
+// again to retries with the same key instead of running the generation twice.
+type IdempotencyConfig struct {
+	// WindowSeconds is how long a response stays replayable. <= 0 disables the cache.
+	WindowSeconds int `yaml:"window-seconds,omitempty" json:"window-seconds,omitempty"`
+
+	// MaxEntries caps the number of cached responses; the oldest are evicted first.
+	MaxEntries int `yaml:"max-entries,omitempty" json:"max-entries,omitempty"`
+}
+
+// SanitizeIdempotency clamps the Idempotency-Key cache settings.
+func (cfg *Config) SanitizeIdempotency() {
+	if cfg == nil {
+		return
+	}
+	if cfg.Idempotency.WindowSeconds < 0 {
+		cfg.Idempotency.WindowSeconds = 0
+	}
+	if cfg.Idempotency.MaxEntries <= 0 {
+		cfg.Idempotency.MaxEntries = DefaultIdempotencyMaxEntries
+	}
+}
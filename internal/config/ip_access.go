@@ -0,0 +1,93 @@
+package config
+
+import "strings"
+
+// IPAccessConfig restricts which clients reach the proxy. Inference (every route but the
+// management API) and management have separate rules, and each listener can add its own.
+type IPAccessConfig struct {
+	// TrustedProxies lists the proxies (IPs or CIDRs) whose X-Forwarded-For, X-Real-IP and
+	// country headers are honored. Without it the rules apply to the connecting address.
+	TrustedProxies []string `yaml:"trusted-proxies,omitempty" json:"trusted-proxies,omitempty"`
+
+	// CountryHeader names the header carrying the client's ISO country code set by a
+	// trusted proxy or CDN, e.g. "CF-IPCountry". Required for the country rules.
+	CountryHeader string `yaml:"country-header,omitempty" json:"country-header,omitempty"`
+
+	// Inference applies to the provider APIs and every other non-management route.
+	Inference IPAccessRules `yaml:"inference,omitempty" json:"inference,omitempty"`
+
+	// Management applies to /v0/management and the control panel.
+	Management IPAccessRules `yaml:"management,omitempty" json:"management,omitempty"`
+
+	// Listeners add rules for the requests arriving on one listening address (the API
+	// server, the gRPC ingress or a socket-activated listener). A request must pass both
+	// the rules of its listener and those of its surface.
+	Listeners []IPAccessListener `yaml:"listeners,omitempty" json:"listeners,omitempty"`
+}
+
+// IPAccessListener is the rules of one listening address.
+type IPAccessListener struct {
+	// Addr is the host:port the listener binds; an empty or unspecified host matches
+	// every address on the port.
+	Addr string `yaml:"addr" json:"addr"`
+
+	IPAccessRules `yaml:",inline"`
+}
+
+// IPAccessRules is one allowlist/denylist. Denials win; a non-empty allowlist refuses
+// every client it does not list.
+type IPAccessRules struct {
+	// Allow and Deny list IPs or CIDRs.
+	Allow []string `yaml:"allow,omitempty" json:"allow,omitempty"`
+	Deny  []string `yaml:"deny,omitempty" json:"deny,omitempty"`
+
+	// AllowCountries and DenyCountries list ISO 3166 country codes read from CountryHeader.
+	// With AllowCountries set, clients of unknown country are refused.
+	AllowCountries []string `yaml:"allow-countries,omitempty" json:"allow-countries,omitempty"`
+	DenyCountries  []string `yaml:"deny-countries,omitempty" json:"deny-countries,omitempty"`
+}
+
+// SanitizeIPAccess trims the IP access entries and upper-cases country codes.
+func (cfg *Config) SanitizeIPAccess() {
+	if cfg == nil {
+		return
+	}
+	ia := &cfg.IPAccess
+	ia.TrustedProxies = trimList(ia.TrustedProxies)
+	ia.CountryHeader = strings.TrimSpace(ia.CountryHeader)
+	all := []*IPAccessRules{&ia.Inference, &ia.Management}
+	listeners := ia.Listeners[:0]
+	for _, listener := range ia.Listeners {
+		if listener.Addr = strings.TrimSpace(listener.Addr); listener.Addr != "" {
+			listeners = append(listeners, listener)
+		}
+	}
+	ia.Listeners = listeners
+	for i := range ia.Listeners {
+		all = append(all, &ia.Listeners[i].IPAccessRules)
+	}
+	for _, rules := range all {
+		rules.Allow = trimList(rules.Allow)
+		rules.Deny = trimList(rules.Deny)
+		rules.AllowCountries = upperList(rules.AllowCountries)
+		rules.DenyCountries = upperList(rules.DenyCountries)
+	}
+}
+
+func trimList(values []string) []string {
+	var out []string
+	for _, value := range values {
+		if value = strings.TrimSpace(value); value != "" {
+			out = append(out, value)
+		}
+	}
+	return out
+}
+
+func upperList(values []string) []string {
+	out := trimList(values)
+	for i := range out {
+		out[i] = strings.ToUpper(out[i])
+	}
+	return out
+}
@@ -0,0 +1,18 @@
+package config
+
+// JetBrainsConfig configures the JetBrains AI Assistant compatible routes under /jetbrains,
+// which the plugin reaches as an Ollama, LM Studio or OpenAI-compatible third-party provider.
+type JetBrainsConfig struct {
+	// ModelMappings route the models the IDE requests to other models. The first matching
+	// mapping wins; responses keep reporting the requested model. Mappings without wildcards
+	// are listed as models, so the IDE's model picker offers them.
+	ModelMappings []ClientModelMapping `yaml:"model-mappings,omitempty" json:"model-mappings,omitempty"`
+}
+
+// SanitizeJetBrains trims the JetBrains mappings and drops incomplete ones.
+func (cfg *Config) SanitizeJetBrains() {
+	if cfg == nil {
+		return
+	}
+	cfg.JetBrains.ModelMappings = sanitizeClientModelMappings(cfg.JetBrains.ModelMappings)
+}
@@ -0,0 +1,123 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// NormalizeListenHost validates a bind host: empty (every interface), an IPv4 or IPv6
+// literal, optionally bracketed and with a zone, or a host name. It returns the host
+// without brackets, ready for net.JoinHostPort.
+func NormalizeListenHost(host string) (string, error) {
+	host = strings.TrimSpace(host)
+	if strings.HasPrefix(host, "[") || strings.HasSuffix(host, "]") {
+		if !strings.HasPrefix(host, "[") || !strings.HasSuffix(host, "]") {
+			return "", fmt.Errorf("host %q has unbalanced brackets", host)
+		}
+		host = host[1 : len(host)-1]
+		if !strings.Contains(host, ":") {
+			return "", fmt.Errorf("host %q: only IPv6 literals are bracketed", host)
+		}
+	}
+	if host == "" {
+		return "", nil
+	}
+	if strings.Contains(host, ":") {
+		literal, _, _ := strings.Cut(host, "%")
+		if net.ParseIP(literal) == nil {
+			return "", fmt.Errorf("host %q is neither an IPv6 literal nor a host name; set the port separately", host)
+		}
+		return host, nil
+	}
+	if net.ParseIP(host) != nil {
+		return host, nil
+	}
+	if !validHostName(host) {
+		return "", fmt.Errorf("host %q is not a valid IP address or host name", host)
+	}
+	return host, nil
+}
+
+// ValidateListenAddress checks a host:port listen address; IPv6 literals must be bracketed,
+// e.g. [::1]:8316.
+func ValidateListenAddress(addr string) error {
+	host, port, err := net.SplitHostPort(strings.TrimSpace(addr))
+	if err != nil {
+		return fmt.Errorf("address %q: %w", addr, err)
+	}
+	if n, errAtoi := strconv.Atoi(port); errAtoi != nil || n < 0 || n > 65535 {
+		return fmt.Errorf("address %q: invalid port %q", addr, port)
+	}
+	if _, errHost := NormalizeListenHost(host); errHost != nil {
+		return fmt.Errorf("address %q: %w", addr, errHost)
+	}
+	return nil
+}
+
+// ValidateListenAddresses normalizes the host of the API server and checks the port and
+// the addresses of the enabled pprof and gRPC listeners.
+func (cfg *Config) ValidateListenAddresses() error {
+	if cfg == nil {
+		return nil
+	}
+	host, err := NormalizeListenHost(cfg.Host)
+	if err != nil {
+		return fmt.Errorf("host: %w", err)
+	}
+	cfg.Host = host
+	if cfg.Port < 0 || cfg.Port > 65535 {
+		return fmt.Errorf("port: %d is out of range", cfg.Port)
+	}
+	if cfg.Pprof.Enable {
+		if err = ValidateListenAddress(cfg.Pprof.Addr); err != nil {
+			return fmt.Errorf("pprof.addr: %w", err)
+		}
+	}
+	if cfg.GRPC.Enable {
+		if err = ValidateListenAddress(cfg.GRPC.Addr); err != nil {
+			return fmt.Errorf("grpc.addr: %w", err)
+		}
+	}
+	return nil
+}
+
+// ListenAddress is the host:port the API server binds, with IPv6 hosts bracketed.
+func (cfg *Config) ListenAddress() string {
+	if cfg == nil {
+		return ""
+	}
+	return net.JoinHostPort(strings.Trim(cfg.Host, "[]"), strconv.Itoa(cfg.Port))
+}
+
+// AdvertisedHost is the host local clients use to reach a server bound to host: the IPv4
+// loopback for the wildcard addresses, otherwise host without brackets.
+func AdvertisedHost(host string) string {
+	host = strings.Trim(strings.TrimSpace(host), "[]")
+	switch host {
+	case "", "0.0.0.0", "::":
+		return "127.0.0.1"
+	}
+	return host
+}
+
+// validHostName reports whether name consists of dot-separated labels of letters, digits
+// and inner hyphens.
+func validHostName(name string) bool {
+	name = strings.TrimSuffix(name, ".")
+	if name == "" || len(name) > 253 {
+		return false
+	}
+	for _, label := range strings.Split(name, ".") {
+		if label == "" || len(label) > 63 || label[0] == '-' || label[len(label)-1] == '-' {
+			return false
+		}
+		for _, r := range label {
+			if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_') {
+				return false
+			}
+		}
+	}
+	return true
+}
@@ -0,0 +1,61 @@
+package config
+
+import "testing"
+
+func TestNormalizeListenHost(t *testing.T) {
+	valid := map[string]string{
+		"":                "",
+		" 127.0.0.1 ":     "127.0.0.1",
+		"localhost":       "localhost",
+		"proxy.internal":  "proxy.internal",
+		"::":              "::",
+		"[::1]":           "::1",
+		"fe80::1%eth0":    "fe80::1%eth0",
+		"[2001:db8::5]":   "2001:db8::5",
+		"::ffff:10.0.0.1": "::ffff:10.0.0.1",
+	}
+	for in, want := range valid {
+		got, err := NormalizeListenHost(in)
+		if err != nil || got != want {
+			t.Errorf("NormalizeListenHost(%q) = %q, %v; want %q", in, got, err, want)
+		}
+	}
+	for _, in := range []string{"127.0.0.1:8317", "[::1", "[127.0.0.1]", "::1:8317:x", "bad host", "-bad.example", "http://x"} {
+		if got, err := NormalizeListenHost(in); err == nil {
+			t.Errorf("NormalizeListenHost(%q) = %q, want an error", in, got)
+		}
+	}
+}
+
+func TestValidateListenAddresses(t *testing.T) {
+	cfg := &Config{Host: "[::1]", Port: 8317}
+	cfg.GRPC = GRPCConfig{Enable: true, Addr: "[::1]:8318"}
+	if err := cfg.ValidateListenAddresses(); err != nil {
+		t.Fatalf("ValidateListenAddresses: %v", err)
+	}
+	if got := cfg.ListenAddress(); got != "[::1]:8317" {
+		t.Fatalf("ListenAddress = %q", got)
+	}
+
+	for _, bad := range []*Config{
+		{Host: "0.0.0.0:8317"},
+		{Port: 70000},
+		{Pprof: PprofConfig{Enable: true, Addr: "::1:8316"}},
+		{GRPC: GRPCConfig{Enable: true, Addr: "127.0.0.1"}},
+	} {
+		if err := bad.ValidateListenAddresses(); err == nil {
+			t.Errorf("expected an error for %+v", bad)
+		}
+	}
+	if err := (&Config{Pprof: PprofConfig{Addr: "garbage"}}).ValidateListenAddresses(); err != nil {
+		t.Errorf("disabled pprof should not be validated: %v", err)
+	}
+}
+
+func TestAdvertisedHost(t *testing.T) {
+	for in, want := range map[string]string{"": "127.0.0.1", "0.0.0.0": "127.0.0.1", "::": "127.0.0.1", "[::1]": "::1", "10.1.2.3": "10.1.2.3"} {
+		if got := AdvertisedHost(in); got != want {
+			t.Errorf("AdvertisedHost(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
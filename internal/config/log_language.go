@@ -0,0 +1,21 @@
+package config
+
+import (
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// SanitizeLogLanguage lowercases the log language, resetting unsupported values to English.
+func (cfg *Config) SanitizeLogLanguage() {
+	if cfg == nil {
+		return
+	}
+	cfg.LogLanguage = strings.ToLower(strings.TrimSpace(cfg.LogLanguage))
+	switch cfg.LogLanguage {
+	case "", "en", "zh":
+	default:
+		log.Warnf("log-language %q is not supported, using en", cfg.LogLanguage)
+		cfg.LogLanguage = "en"
+	}
+}
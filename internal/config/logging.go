@@ -0,0 +1,138 @@
+package config
+
+import (
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// LogFormatText is the bracketed single-line log format.
+	LogFormatText = "text"
+	// LogFormatJSON writes one JSON object per log entry.
+	LogFormatJSON = "json"
+
+	// DefaultLogMaxSizeMB is the size at which log files are rotated.
+	DefaultLogMaxSizeMB = 10
+)
+
+// LoggingConfig sets the format, file rotation and per-component levels of application logs.
+type LoggingConfig struct {
+	// Format is "text" (default) or "json".
+	Format string `yaml:"format,omitempty" json:"format,omitempty"`
+
+	// File writes logs to this path instead of logs/main.log. Setting it enables file
+	// output regardless of logging-to-file.
+	File string `yaml:"file,omitempty" json:"file,omitempty"`
+
+	// MaxSizeMB rotates the log file once it reaches this size. Defaults to 10.
+	MaxSizeMB int `yaml:"max-size-mb,omitempty" json:"max-size-mb,omitempty"`
+
+	// MaxAgeDays deletes rotated files older than this many days. 0 keeps them.
+	MaxAgeDays int `yaml:"max-age-days,omitempty" json:"max-age-days,omitempty"`
+
+	// MaxBackups caps the number of rotated files kept. 0 keeps them all.
+	MaxBackups int `yaml:"max-backups,omitempty" json:"max-backups,omitempty"`
+
+	// Compress gzips rotated files.
+	Compress bool `yaml:"compress,omitempty" json:"compress,omitempty"`
+
+	// Components overrides the level per component, e.g. amp-routing: debug. A component is
+	// the "component" field of an entry or else the package directory it was logged from
+	// (e.g. executor, amp, watcher). Other entries use debug when debug is set, else info.
+	Components map[string]string `yaml:"components,omitempty" json:"components,omitempty"`
+
+	// Sampling caps the entries logged per minute for each model of a component, e.g.
+	// amp-routing: 20. Entries past the cap are counted and reported in a summary line
+	// at the end of the minute. Components are matched like in Components.
+	Sampling map[string]int `yaml:"sampling,omitempty" json:"sampling,omitempty"`
+}
+
+// SanitizeLogging normalizes the log format and drops component levels logrus cannot parse.
+func (cfg *Config) SanitizeLogging() {
+	if cfg == nil {
+		return
+	}
+	l := &cfg.Logging
+	l.Format = strings.ToLower(strings.TrimSpace(l.Format))
+	switch l.Format {
+	case "":
+		l.Format = LogFormatText
+	case LogFormatText, LogFormatJSON:
+	default:
+		log.Warnf("logging: unknown format %q, using text", l.Format)
+		l.Format = LogFormatText
+	}
+	l.File = strings.TrimSpace(l.File)
+	if l.MaxSizeMB <= 0 {
+		l.MaxSizeMB = DefaultLogMaxSizeMB
+	}
+	if l.MaxAgeDays < 0 {
+		l.MaxAgeDays = 0
+	}
+	if l.MaxBackups < 0 {
+		l.MaxBackups = 0
+	}
+	l.Sampling = sanitizeLogSampling(l.Sampling)
+	if len(l.Components) == 0 {
+		l.Components = nil
+		return
+	}
+	components := make(map[string]string, len(l.Components))
+	for component, level := range l.Components {
+		component = strings.ToLower(strings.TrimSpace(component))
+		level = strings.ToLower(strings.TrimSpace(level))
+		if component == "" {
+			continue
+		}
+		if _, err := log.ParseLevel(level); err != nil {
+			log.Warnf("logging: dropping component %s with invalid level %q", component, level)
+			continue
+		}
+		components[component] = level
+	}
+	l.Components = components
+}
+
+func sanitizeLogSampling(sampling map[string]int) map[string]int {
+	if len(sampling) == 0 {
+		return nil
+	}
+	out := make(map[string]int, len(sampling))
+	for component, perMinute := range sampling {
+		component = strings.ToLower(strings.TrimSpace(component))
+		if component == "" || perMinute <= 0 {
+			log.Warnf("logging: dropping sampling of component %q with limit %d", component, perMinute)
+			continue
+		}
+		out[component] = perMinute
+	}
+	if len(out) == 0 {
+		return nil
+	}
+	return out
+}
+
+// LogLevels returns the level of entries without a component override, debug when Debug
+// is set and info otherwise, and the parsed component overrides.
+func (cfg *Config) LogLevels() (log.Level, map[string]log.Level) {
+	base := log.InfoLevel
+	if cfg == nil {
+		return base, nil
+	}
+	if cfg.Debug {
+		base = log.DebugLevel
+	}
+	var components map[string]log.Level
+	for component, value := range cfg.Logging.Components {
+		level, err := log.ParseLevel(value)
+		if err != nil {
+			continue
+		}
+		if components == nil {
+			components = make(map[string]log.Level, len(cfg.Logging.Components))
+		}
+		components[strings.ToLower(component)] = level
+	}
+	return base, components
+}
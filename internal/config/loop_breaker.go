@@ -0,0 +1,73 @@
+package config
+
+import "strings"
+
+const (
+	// DefaultLoopBreakerThreshold is how many identical repetitions break a loop.
+	DefaultLoopBreakerThreshold = 5
+	// DefaultLoopBreakerWindowSeconds is how long a session's last prompt is remembered.
+	DefaultLoopBreakerWindowSeconds = 600
+)
+
+// LoopBreakerConfig detects pathological agent loops: the same tool call with the same
+// arguments repeated at the end of a conversation, or a near-identical last message sent
+// again and again on one session. Once a repetition count reaches the threshold, requests
+// are rejected with an error explaining the loop until the conversation moves on.
+type LoopBreakerConfig struct {
+	// Enabled turns loop detection on.
+	Enabled bool `yaml:"enabled" json:"enabled"`
+
+	// Threshold is the number of repetitions that breaks a loop. Defaults to 5.
+	Threshold int `yaml:"threshold,omitempty" json:"threshold,omitempty"`
+
+	// SessionHeader names the request header identifying a session. Requests without it
+	// share one session per client API key. Defaults to X-Session-Id.
+	SessionHeader string `yaml:"session-header,omitempty" json:"session-header,omitempty"`
+
+	// WindowSeconds is how long a session's last message is remembered. Defaults to 600.
+	WindowSeconds int `yaml:"window-seconds,omitempty" json:"window-seconds,omitempty"`
+
+	// Message replaces the error message returned for broken loops.
+	Message string `yaml:"message,omitempty" json:"message,omitempty"`
+
+	// Keys override the threshold for client API keys; the first entry listing the key
+	// applies. A threshold of 0 disables detection for those keys.
+	Keys []LoopBreakerKey `yaml:"keys,omitempty" json:"keys,omitempty"`
+}
+
+// LoopBreakerKey sets the threshold of the listed client API keys.
+type LoopBreakerKey struct {
+	APIKeys   []string `yaml:"api-keys" json:"api-keys"`
+	Threshold int      `yaml:"threshold" json:"threshold"`
+}
+
+// SanitizeLoopBreaker trims the loop breaker settings and applies defaults.
+func (cfg *Config) SanitizeLoopBreaker() {
+	if cfg == nil {
+		return
+	}
+	lb := &cfg.LoopBreaker
+	lb.SessionHeader = strings.TrimSpace(lb.SessionHeader)
+	if lb.SessionHeader == "" {
+		lb.SessionHeader = DefaultAgentBudgetSessionHeader
+	}
+	if lb.Threshold <= 0 {
+		lb.Threshold = DefaultLoopBreakerThreshold
+	}
+	if lb.WindowSeconds <= 0 {
+		lb.WindowSeconds = DefaultLoopBreakerWindowSeconds
+	}
+	lb.Message = strings.TrimSpace(lb.Message)
+	keys := make([]LoopBreakerKey, 0, len(lb.Keys))
+	for _, key := range lb.Keys {
+		key.APIKeys = trimNonEmpty(key.APIKeys)
+		if len(key.APIKeys) == 0 {
+			continue
+		}
+		if key.Threshold < 0 {
+			key.Threshold = 0
+		}
+		keys = append(keys, key)
+	}
+	lb.Keys = keys
+}
@@ -0,0 +1,35 @@
+package config
+
+import "strings"
+
+const (
+	// DefaultMaintenanceMessage is returned for requests rejected during maintenance.
+	DefaultMaintenanceMessage = "The proxy is under maintenance; please retry shortly."
+	// DefaultMaintenanceRetryAfterSeconds is the Retry-After hint of rejected requests.
+	DefaultMaintenanceRetryAfterSeconds = 60
+)
+
+// MaintenanceConfig sets the defaults of maintenance and drain modes, which are switched
+// on and off at runtime through the management API.
+type MaintenanceConfig struct {
+	// Message is returned for rejected requests when a toggle sets none.
+	Message string `yaml:"message,omitempty" json:"message,omitempty"`
+
+	// RetryAfterSeconds is sent in the Retry-After header of rejected requests.
+	// Defaults to 60.
+	RetryAfterSeconds int `yaml:"retry-after-seconds,omitempty" json:"retry-after-seconds,omitempty"`
+}
+
+// SanitizeMaintenance trims the maintenance message and applies defaults.
+func (cfg *Config) SanitizeMaintenance() {
+	if cfg == nil {
+		return
+	}
+	cfg.Maintenance.Message = strings.TrimSpace(cfg.Maintenance.Message)
+	if cfg.Maintenance.Message == "" {
+		cfg.Maintenance.Message = DefaultMaintenanceMessage
+	}
+	if cfg.Maintenance.RetryAfterSeconds <= 0 {
+		cfg.Maintenance.RetryAfterSeconds = DefaultMaintenanceRetryAfterSeconds
+	}
+}
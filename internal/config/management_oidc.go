@@ -0,0 +1,92 @@
+package config
+
+import "strings"
+
+// Management roles granted to OIDC users.
+const (
+	// ManagementRoleAdmin may use every management endpoint.
+	ManagementRoleAdmin = "admin"
+	// ManagementRoleViewer may only read (GET) management endpoints.
+	ManagementRoleViewer = "viewer"
+)
+
+// DefaultManagementOIDCSessionSeconds is the lifetime of an OIDC login session.
+const DefaultManagementOIDCSessionSeconds = 8 * 60 * 60
+
+// ManagementOIDC protects the management API and control panel with an OpenID Connect
+// login as an alternative to the management key. Users are granted the role mapped from
+// their groups; users without one are refused.
+type ManagementOIDC struct {
+	// Issuer is the OIDC issuer URL (e.g. https://accounts.google.com). Empty disables OIDC.
+	Issuer string `yaml:"issuer,omitempty" json:"issuer,omitempty"`
+
+	// ClientID and ClientSecret identify the proxy at the identity provider.
+	ClientID     string `yaml:"client-id,omitempty" json:"client-id,omitempty"`
+	ClientSecret string `yaml:"client-secret,omitempty" json:"client-secret,omitempty"`
+
+	// RedirectURL is the registered callback, https://<host>/v0/management/oidc/callback.
+	// Empty derives it from the login request.
+	RedirectURL string `yaml:"redirect-url,omitempty" json:"redirect-url,omitempty"`
+
+	// Scopes requested besides "openid". Defaults to email and profile.
+	Scopes []string `yaml:"scopes,omitempty" json:"scopes,omitempty"`
+
+	// GroupsClaim names the ID token claim listing the user's groups. Defaults to "groups".
+	GroupsClaim string `yaml:"groups-claim,omitempty" json:"groups-claim,omitempty"`
+
+	// RoleMapping maps groups (or email addresses) to a role: "admin" or "viewer". The
+	// most privileged match wins.
+	RoleMapping map[string]string `yaml:"role-mapping,omitempty" json:"role-mapping,omitempty"`
+
+	// DefaultRole is granted to authenticated users matching no mapping. Empty refuses them.
+	DefaultRole string `yaml:"default-role,omitempty" json:"default-role,omitempty"`
+
+	// SessionSeconds is the login session lifetime. Defaults to 8 hours.
+	SessionSeconds int `yaml:"session-seconds,omitempty" json:"session-seconds,omitempty"`
+}
+
+// Enabled reports whether OIDC login is configured.
+func (o ManagementOIDC) Enabled() bool {
+	return o.Issuer != "" && o.ClientID != ""
+}
+
+// SanitizeManagementOIDC normalizes the OIDC settings and drops unknown roles.
+func (cfg *Config) SanitizeManagementOIDC() {
+	if cfg == nil {
+		return
+	}
+	o := &cfg.RemoteManagement.OIDC
+	o.Issuer = strings.TrimRight(strings.TrimSpace(o.Issuer), "/")
+	o.ClientID = strings.TrimSpace(o.ClientID)
+	o.ClientSecret = strings.TrimSpace(o.ClientSecret)
+	o.RedirectURL = strings.TrimSpace(o.RedirectURL)
+	o.GroupsClaim = strings.TrimSpace(o.GroupsClaim)
+	if o.GroupsClaim == "" {
+		o.GroupsClaim = "groups"
+	}
+	if len(o.Scopes) == 0 {
+		o.Scopes = []string{"email", "profile"}
+	}
+	if len(o.RoleMapping) > 0 {
+		mapping := make(map[string]string, len(o.RoleMapping))
+		for group, role := range o.RoleMapping {
+			group = strings.TrimSpace(group)
+			if role = normalizeManagementRole(role); group != "" && role != "" {
+				mapping[group] = role
+			}
+		}
+		o.RoleMapping = mapping
+	}
+	o.DefaultRole = normalizeManagementRole(o.DefaultRole)
+	if o.SessionSeconds <= 0 {
+		o.SessionSeconds = DefaultManagementOIDCSessionSeconds
+	}
+}
+
+func normalizeManagementRole(role string) string {
+	switch role = strings.ToLower(strings.TrimSpace(role)); role {
+	case ManagementRoleAdmin, ManagementRoleViewer:
+		return role
+	}
+	return ""
+}
@@ -0,0 +1,28 @@
+package config
+
+// DefaultMappingPreflightTimeoutSeconds bounds each live check of a mapping target.
+const DefaultMappingPreflightTimeoutSeconds = 20
+
+// MappingPreflightConfig verifies the targets of model mappings after startup and every
+// config reload, logging a warning for each target that would fail at request time.
+type MappingPreflightConfig struct {
+	// Enabled turns the preflight on, reporting targets no credential serves.
+	Enabled bool `yaml:"enabled" json:"enabled"`
+
+	// Live additionally sends a token count request for each target to every provider
+	// serving it, reporting targets the provider answers with 404.
+	Live bool `yaml:"live,omitempty" json:"live,omitempty"`
+
+	// TimeoutSeconds bounds each live check. Defaults to 20.
+	TimeoutSeconds int `yaml:"timeout-seconds,omitempty" json:"timeout-seconds,omitempty"`
+}
+
+// SanitizeMappingPreflight applies the mapping preflight defaults.
+func (cfg *Config) SanitizeMappingPreflight() {
+	if cfg == nil {
+		return
+	}
+	if cfg.MappingPreflight.TimeoutSeconds <= 0 {
+		cfg.MappingPreflight.TimeoutSeconds = DefaultMappingPreflightTimeoutSeconds
+	}
+}
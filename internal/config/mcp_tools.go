@@ -0,0 +1,92 @@
+package config
+
+import "strings"
+
+const (
+	// DefaultMCPToolMaxRounds bounds how many times a request is re-run with tool results.
+	DefaultMCPToolMaxRounds = 8
+	// DefaultMCPToolCacheSeconds is how long the tool lists of MCP servers are reused.
+	DefaultMCPToolCacheSeconds = 300
+	// DefaultMCPToolTimeoutSeconds bounds one request to an MCP server.
+	DefaultMCPToolTimeoutSeconds = 60
+)
+
+// MCPToolsConfig configures MCP servers whose tools the proxy offers to models and
+// executes itself. Tools are added to non-streaming requests in the client's dialect; when
+// the model calls only brokered tools, the proxy runs them and continues the conversation
+// with their results until the model answers.
+type MCPToolsConfig struct {
+	// Servers are the MCP servers, reached over the Streamable HTTP transport.
+	Servers []MCPToolServer `yaml:"servers,omitempty" json:"servers,omitempty"`
+
+	// MaxRounds bounds the tool call rounds of one request. Defaults to 8.
+	MaxRounds int `yaml:"max-rounds,omitempty" json:"max-rounds,omitempty"`
+
+	// CacheSeconds is how long tool lists are reused before asking the servers again.
+	// Defaults to 300.
+	CacheSeconds int `yaml:"cache-seconds,omitempty" json:"cache-seconds,omitempty"`
+}
+
+// MCPToolServer is one upstream MCP server.
+type MCPToolServer struct {
+	// Name prefixes the server's tools as "<name>__<tool>", keeping tool names unique
+	// across servers.
+	Name string `yaml:"name" json:"name"`
+
+	// URL is the MCP endpoint of the server.
+	URL string `yaml:"url" json:"url"`
+
+	// Headers are sent with every request, e.g. an Authorization header.
+	Headers map[string]string `yaml:"headers,omitempty" json:"headers,omitempty"`
+
+	// Tools restricts the offered tools to these names. Empty offers every tool.
+	Tools []string `yaml:"tools,omitempty" json:"tools,omitempty"`
+
+	// TimeoutSeconds bounds one request to the server. Defaults to 60.
+	TimeoutSeconds int `yaml:"timeout-seconds,omitempty" json:"timeout-seconds,omitempty"`
+}
+
+// SanitizeMCPTools drops MCP servers without a name or URL, keeping the first of servers
+// sharing a name, and applies defaults.
+func (cfg *Config) SanitizeMCPTools() {
+	if cfg == nil {
+		return
+	}
+	m := &cfg.MCPTools
+	seen := make(map[string]bool, len(m.Servers))
+	servers := make([]MCPToolServer, 0, len(m.Servers))
+	for _, server := range m.Servers {
+		server.Name = sanitizeMCPServerName(server.Name)
+		server.URL = strings.TrimSpace(server.URL)
+		server.Tools = trimNonEmpty(server.Tools)
+		if server.Name == "" || server.URL == "" || seen[server.Name] {
+			continue
+		}
+		if server.TimeoutSeconds <= 0 {
+			server.TimeoutSeconds = DefaultMCPToolTimeoutSeconds
+		}
+		seen[server.Name] = true
+		servers = append(servers, server)
+	}
+	m.Servers = servers
+	if m.MaxRounds <= 0 {
+		m.MaxRounds = DefaultMCPToolMaxRounds
+	}
+	if m.CacheSeconds <= 0 {
+		m.CacheSeconds = DefaultMCPToolCacheSeconds
+	}
+}
+
+// sanitizeMCPServerName keeps the characters tool names may contain in every dialect.
+func sanitizeMCPServerName(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-':
+			return r
+		case r == '_' || r == ' ' || r == '.':
+			return '_'
+		default:
+			return -1
+		}
+	}, strings.TrimSpace(name))
+}
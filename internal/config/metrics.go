@@ -0,0 +1,128 @@
+package config
+
+import (
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// API key label modes of the metrics.
+const (
+	MetricsAPIKeyHash = "hash"
+	MetricsAPIKeyMask = "mask"
+	MetricsAPIKeyRaw  = "raw"
+	MetricsAPIKeyOmit = "omit"
+)
+
+// MetricsConfig exposes request, token and cost counters per provider, model and client
+// API key at /metrics in the Prometheus text format and optionally pushes them to statsd
+// or an OTLP collector.
+type MetricsConfig struct {
+	// Enable serves GET /metrics. The exporters below run independently of it.
+	Enable bool `yaml:"enable" json:"enable"`
+
+	// Token, when set, must be sent as "Authorization: Bearer <token>" to scrape /metrics.
+	Token string `yaml:"token,omitempty" json:"token,omitempty"`
+
+	// Labels limits the cardinality of the api_key and model labels.
+	Labels MetricsLabels `yaml:"labels,omitempty" json:"labels,omitempty"`
+
+	// Statsd pushes counter increments over UDP in the DogStatsD format.
+	Statsd StatsdExporter `yaml:"statsd,omitempty" json:"statsd,omitempty"`
+
+	// OTLP pushes cumulative counters to an OTLP/HTTP collector as JSON.
+	OTLP OTLPExporter `yaml:"otlp,omitempty" json:"otlp,omitempty"`
+}
+
+// MetricsLabels controls the label values of the metrics.
+type MetricsLabels struct {
+	// APIKey is how client API keys are labelled: "hash" (default, the first 12 hex digits
+	// of their SHA-256), "mask" (first and last four characters), "raw" or "omit".
+	APIKey string `yaml:"api-key,omitempty" json:"api-key,omitempty"`
+
+	// ModelBuckets map models onto fewer label values; the first matching pattern ('*'
+	// wildcard) names the bucket.
+	ModelBuckets []MetricsModelBucket `yaml:"model-buckets,omitempty" json:"model-buckets,omitempty"`
+
+	// MaxModels caps the distinct model labels; later models are reported as "other".
+	// Zero means unlimited.
+	MaxModels int `yaml:"max-models,omitempty" json:"max-models,omitempty"`
+
+	// MaxAPIKeys caps the distinct api_key labels the same way.
+	MaxAPIKeys int `yaml:"max-api-keys,omitempty" json:"max-api-keys,omitempty"`
+}
+
+// MetricsModelBucket labels every model matching Pattern as Name.
+type MetricsModelBucket struct {
+	Pattern string `yaml:"pattern" json:"pattern"`
+	Name    string `yaml:"name" json:"name"`
+}
+
+// StatsdExporter pushes metrics to a statsd agent.
+type StatsdExporter struct {
+	// Address is the host:port of the agent. Empty disables the exporter.
+	Address string `yaml:"address,omitempty" json:"address,omitempty"`
+	// Prefix is prepended to metric names. Defaults to "cliproxy.".
+	Prefix string `yaml:"prefix,omitempty" json:"prefix,omitempty"`
+	// IntervalSeconds is the flush interval. Defaults to 10.
+	IntervalSeconds int `yaml:"interval-seconds,omitempty" json:"interval-seconds,omitempty"`
+}
+
+// OTLPExporter pushes metrics to an OTLP/HTTP endpoint.
+type OTLPExporter struct {
+	// Endpoint is the full metrics URL, e.g. http://collector:4318/v1/metrics. Empty
+	// disables the exporter.
+	Endpoint string `yaml:"endpoint,omitempty" json:"endpoint,omitempty"`
+	// Headers are added to every export request, e.g. an API key of a hosted backend.
+	Headers map[string]string `yaml:"headers,omitempty" json:"headers,omitempty"`
+	// ServiceName is the service.name resource attribute. Defaults to "cli-proxy-api".
+	ServiceName string `yaml:"service-name,omitempty" json:"service-name,omitempty"`
+	// IntervalSeconds is the export interval. Defaults to 60.
+	IntervalSeconds int `yaml:"interval-seconds,omitempty" json:"interval-seconds,omitempty"`
+}
+
+// SanitizeMetrics normalizes the metrics settings.
+func (cfg *Config) SanitizeMetrics() {
+	if cfg == nil {
+		return
+	}
+	m := &cfg.Metrics
+	m.Token = strings.TrimSpace(m.Token)
+	labels := &m.Labels
+	labels.APIKey = strings.ToLower(strings.TrimSpace(labels.APIKey))
+	switch labels.APIKey {
+	case MetricsAPIKeyHash, MetricsAPIKeyMask, MetricsAPIKeyRaw, MetricsAPIKeyOmit:
+	case "":
+		labels.APIKey = MetricsAPIKeyHash
+	default:
+		log.Warnf("metrics.labels.api-key: unknown mode %q, using hash", labels.APIKey)
+		labels.APIKey = MetricsAPIKeyHash
+	}
+	buckets := labels.ModelBuckets[:0]
+	for _, bucket := range labels.ModelBuckets {
+		bucket.Pattern = strings.TrimSpace(bucket.Pattern)
+		bucket.Name = strings.TrimSpace(bucket.Name)
+		if bucket.Pattern == "" || bucket.Name == "" {
+			continue
+		}
+		buckets = append(buckets, bucket)
+	}
+	labels.ModelBuckets = buckets
+	labels.MaxModels = max(labels.MaxModels, 0)
+	labels.MaxAPIKeys = max(labels.MaxAPIKeys, 0)
+
+	m.Statsd.Address = strings.TrimSpace(m.Statsd.Address)
+	if m.Statsd.Prefix == "" {
+		m.Statsd.Prefix = "cliproxy."
+	}
+	if m.Statsd.IntervalSeconds <= 0 {
+		m.Statsd.IntervalSeconds = 10
+	}
+	m.OTLP.Endpoint = strings.TrimSpace(m.OTLP.Endpoint)
+	if m.OTLP.ServiceName = strings.TrimSpace(m.OTLP.ServiceName); m.OTLP.ServiceName == "" {
+		m.OTLP.ServiceName = "cli-proxy-api"
+	}
+	if m.OTLP.IntervalSeconds <= 0 {
+		m.OTLP.IntervalSeconds = 60
+	}
+}
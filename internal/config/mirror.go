@@ -0,0 +1,82 @@
+package config
+
+import (
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// DefaultMirrorTimeoutSeconds bounds each mirrored request.
+	DefaultMirrorTimeoutSeconds = 120
+	// DefaultMirrorQueueSize is the number of mirrored requests buffered before new ones are dropped.
+	DefaultMirrorQueueSize = 256
+	// DefaultMirrorWorkers is the number of concurrent mirrored requests.
+	DefaultMirrorWorkers = 4
+)
+
+// MirrorConfig copies a sample of API requests to a secondary proxy instance, e.g. a
+// staging deployment trying new routing rules. Mirrored requests are sent in the
+// background after secrets are scrubbed; their responses are discarded and never
+// delay the client.
+type MirrorConfig struct {
+	// Enabled turns mirroring on.
+	Enabled bool `yaml:"enabled" json:"enabled"`
+
+	// URL is the base URL of the secondary instance, e.g. "https://staging-proxy:8317".
+	// The request path and query are appended.
+	URL string `yaml:"url" json:"url"`
+
+	// APIKey is sent as the bearer token of mirrored requests in place of the client's key.
+	APIKey string `yaml:"api-key,omitempty" json:"api-key,omitempty"`
+
+	// SampleRate is the fraction of requests mirrored, from 0 to 1. Defaults to 1.
+	SampleRate float64 `yaml:"sample-rate,omitempty" json:"sample-rate,omitempty"`
+
+	// Paths limits mirroring to requests whose path starts with one of the prefixes.
+	// Empty mirrors every API request.
+	Paths []string `yaml:"paths,omitempty" json:"paths,omitempty"`
+
+	// ScrubFields are extra JSON body fields (gjson paths such as "metadata.user_id")
+	// removed before mirroring, on top of the built-in list of secret-bearing fields.
+	ScrubFields []string `yaml:"scrub-fields,omitempty" json:"scrub-fields,omitempty"`
+
+	// TimeoutSeconds bounds each mirrored request. Defaults to 120.
+	TimeoutSeconds int `yaml:"timeout-seconds,omitempty" json:"timeout-seconds,omitempty"`
+
+	// QueueSize is the number of mirrored requests buffered; when full, new requests
+	// are not mirrored. Defaults to 256.
+	QueueSize int `yaml:"queue-size,omitempty" json:"queue-size,omitempty"`
+
+	// Workers is the number of mirrored requests sent concurrently. Defaults to 4.
+	Workers int `yaml:"workers,omitempty" json:"workers,omitempty"`
+}
+
+// SanitizeMirror trims the mirror target, clamps the sample rate and applies defaults.
+// Mirroring without a target URL is disabled.
+func (cfg *Config) SanitizeMirror() {
+	if cfg == nil {
+		return
+	}
+	m := &cfg.Mirror
+	m.URL = strings.TrimRight(strings.TrimSpace(m.URL), "/")
+	m.APIKey = strings.TrimSpace(m.APIKey)
+	if m.Enabled && m.URL == "" {
+		log.Warnf("mirror: disabling mirroring without a url")
+		m.Enabled = false
+	}
+	if m.SampleRate <= 0 || m.SampleRate > 1 {
+		m.SampleRate = 1
+	}
+	m.Paths = trimNonEmpty(m.Paths)
+	m.ScrubFields = trimNonEmpty(m.ScrubFields)
+	if m.TimeoutSeconds <= 0 {
+		m.TimeoutSeconds = DefaultMirrorTimeoutSeconds
+	}
+	if m.QueueSize <= 0 {
+		m.QueueSize = DefaultMirrorQueueSize
+	}
+	if m.Workers <= 0 {
+		m.Workers = DefaultMirrorWorkers
+	}
+}
@@ -0,0 +1,88 @@
+package config
+
+import (
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Mock provider response modes.
+const (
+	// MockModeLorem answers with lorem ipsum text seeded by the request.
+	MockModeLorem = "lorem"
+	// MockModeEcho answers with the text of the last user message.
+	MockModeEcho = "echo"
+)
+
+// Defaults of the mock provider.
+const (
+	DefaultMockProviderName  = "mock"
+	DefaultMockProviderWords = 32
+)
+
+// MockProvider configures a built-in provider that answers without any upstream call.
+// Responses are deterministic: the same request always yields the same text, tool calls
+// and usage, in every API format the proxy serves, streaming or not.
+type MockProvider struct {
+	// Name labels the provider in logs and the management API. Defaults to "mock".
+	Name string `yaml:"name,omitempty" json:"name,omitempty"`
+
+	// Prefix optionally namespaces the models, e.g. "mock/".
+	Prefix string `yaml:"prefix,omitempty" json:"prefix,omitempty"`
+
+	// Models lists the model IDs served by this provider. Defaults to the provider name.
+	Models []string `yaml:"models,omitempty" json:"models,omitempty"`
+
+	// Mode is "lorem" (default) or "echo".
+	Mode string `yaml:"mode,omitempty" json:"mode,omitempty"`
+
+	// Words is the length of lorem answers. Defaults to 32.
+	Words int `yaml:"words,omitempty" json:"words,omitempty"`
+
+	// ToolCalls answers requests declaring tools with a synthetic call to the first tool,
+	// with arguments generated from its schema. Requests whose last message is a tool
+	// result get a text answer, so agent loops terminate.
+	ToolCalls bool `yaml:"tool-calls,omitempty" json:"tool-calls,omitempty"`
+
+	// ChunkDelayMs paces streamed chunks to exercise client rendering.
+	ChunkDelayMs int `yaml:"chunk-delay-ms,omitempty" json:"chunk-delay-ms,omitempty"`
+}
+
+// SanitizeMockProviders normalizes the mock providers and drops duplicates by name.
+func (cfg *Config) SanitizeMockProviders() {
+	if cfg == nil || len(cfg.MockProvider) == 0 {
+		return
+	}
+	seen := make(map[string]struct{}, len(cfg.MockProvider))
+	out := make([]MockProvider, 0, len(cfg.MockProvider))
+	for _, entry := range cfg.MockProvider {
+		if entry.Name = strings.TrimSpace(entry.Name); entry.Name == "" {
+			entry.Name = DefaultMockProviderName
+		}
+		key := strings.ToLower(entry.Name)
+		if _, dup := seen[key]; dup {
+			log.Warnf("mock-provider: ignoring duplicate entry %q", entry.Name)
+			continue
+		}
+		seen[key] = struct{}{}
+		entry.Prefix = strings.TrimSpace(entry.Prefix)
+		if entry.Models = trimNonEmpty(entry.Models); len(entry.Models) == 0 {
+			entry.Models = []string{entry.Name}
+		}
+		entry.Mode = strings.ToLower(strings.TrimSpace(entry.Mode))
+		switch entry.Mode {
+		case MockModeLorem, MockModeEcho:
+		case "":
+			entry.Mode = MockModeLorem
+		default:
+			log.Warnf("mock-provider %s: unknown mode %q, using %s", entry.Name, entry.Mode, MockModeLorem)
+			entry.Mode = MockModeLorem
+		}
+		if entry.Words <= 0 {
+			entry.Words = DefaultMockProviderWords
+		}
+		entry.ChunkDelayMs = max(entry.ChunkDelayMs, 0)
+		out = append(out, entry)
+	}
+	cfg.MockProvider = out
+}
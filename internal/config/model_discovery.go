@@ -0,0 +1,24 @@
+package config
+
+// DefaultModelDiscoveryCacheTTLSeconds keeps discovered model lists for an hour.
+const DefaultModelDiscoveryCacheTTLSeconds = 3600
+
+// ModelDiscoveryConfig controls the cache of model lists reported by providers, such as
+// Antigravity, whose models are discovered through an upstream call rather than built in.
+type ModelDiscoveryConfig struct {
+	// CacheTTLSeconds is how long a discovered model list is served before it is refreshed
+	// in the background; the stale list keeps being served until the refresh completes.
+	// Defaults to 3600. Negative values disable the cache so every credential update
+	// queries the provider.
+	CacheTTLSeconds int `yaml:"cache-ttl-seconds,omitempty" json:"cache-ttl-seconds,omitempty"`
+}
+
+// SanitizeModelDiscovery applies the model discovery cache defaults.
+func (cfg *Config) SanitizeModelDiscovery() {
+	if cfg == nil {
+		return
+	}
+	if cfg.ModelDiscovery.CacheTTLSeconds == 0 {
+		cfg.ModelDiscovery.CacheTTLSeconds = DefaultModelDiscoveryCacheTTLSeconds
+	}
+}
@@ -0,0 +1,27 @@
+package config
+
+// DefaultModelsCacheTTLSeconds bounds how long a pre-rendered model listing is served
+// before it is rebuilt in the background.
+const DefaultModelsCacheTTLSeconds = 30
+
+// ModelsCacheConfig controls the pre-rendered responses of the model listing endpoints
+// (/v1/models, /v1beta/models and their Amp provider aliases). Editors list models on
+// every window open, so listings are served from memory and rebuilt asynchronously when
+// the available models change or the TTL passes.
+type ModelsCacheConfig struct {
+	// Disable renders every listing on request.
+	Disable bool `yaml:"disable,omitempty" json:"disable,omitempty"`
+
+	// TTLSeconds is the maximum age of a served listing. Default: 30.
+	TTLSeconds int `yaml:"ttl-seconds,omitempty" json:"ttl-seconds,omitempty"`
+}
+
+// SanitizeModelsCache applies the model listing cache defaults.
+func (cfg *Config) SanitizeModelsCache() {
+	if cfg == nil {
+		return
+	}
+	if cfg.ModelsCache.TTLSeconds <= 0 {
+		cfg.ModelsCache.TTLSeconds = DefaultModelsCacheTTLSeconds
+	}
+}
@@ -0,0 +1,64 @@
+package config
+
+import "strings"
+
+const (
+	// ModerationModeLocal answers /v1/moderations with the configured rules.
+	ModerationModeLocal = "local"
+	// ModerationModeForward relays /v1/moderations to an upstream moderation endpoint.
+	ModerationModeForward = "forward"
+)
+
+// ModerationConfig configures the /v1/moderations endpoint.
+type ModerationConfig struct {
+	// Mode is "local" (default) or "forward". Forwarded requests fall back to the local rules
+	// when the upstream fails.
+	Mode string `yaml:"mode,omitempty" json:"mode,omitempty"`
+
+	// ForwardURL is the upstream moderation endpoint, e.g. https://api.openai.com/v1/moderations.
+	ForwardURL string `yaml:"forward-url,omitempty" json:"forward-url,omitempty"`
+
+	// APIKey is sent as a bearer token to ForwardURL.
+	APIKey string `yaml:"api-key,omitempty" json:"api-key,omitempty"`
+
+	// Rules flag input locally; each rule reports its category when one of its keywords or
+	// patterns matches.
+	Rules []ModerationRule `yaml:"rules,omitempty" json:"rules,omitempty"`
+}
+
+// ModerationRule flags input for one moderation category.
+type ModerationRule struct {
+	// Category is reported in the categories map, e.g. "harassment" or "self-harm".
+	Category string `yaml:"category" json:"category"`
+
+	// Keywords match case-insensitively anywhere in the input.
+	Keywords []string `yaml:"keywords,omitempty" json:"keywords,omitempty"`
+
+	// Patterns are regular expressions matched against the input.
+	Patterns []string `yaml:"patterns,omitempty" json:"patterns,omitempty"`
+}
+
+// SanitizeModeration normalizes the mode and drops rules without a category or matcher.
+func (cfg *Config) SanitizeModeration() {
+	if cfg == nil {
+		return
+	}
+	m := &cfg.Moderation
+	m.Mode = strings.ToLower(strings.TrimSpace(m.Mode))
+	m.ForwardURL = strings.TrimSpace(m.ForwardURL)
+	m.APIKey = strings.TrimSpace(m.APIKey)
+	if m.Mode != ModerationModeForward || m.ForwardURL == "" {
+		m.Mode = ModerationModeLocal
+	}
+	rules := make([]ModerationRule, 0, len(m.Rules))
+	for _, rule := range m.Rules {
+		rule.Category = strings.TrimSpace(rule.Category)
+		rule.Keywords = trimNonEmpty(rule.Keywords)
+		rule.Patterns = trimNonEmpty(rule.Patterns)
+		if rule.Category == "" || len(rule.Keywords)+len(rule.Patterns) == 0 {
+			continue
+		}
+		rules = append(rules, rule)
+	}
+	m.Rules = rules
+}
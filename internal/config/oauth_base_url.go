@@ -0,0 +1,76 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// oauthBaseURLProviders lists the OAuth/file-backed providers whose executors honor a base
+// URL override. AI Studio is served over the websocket relay and has no upstream URL.
+var oauthBaseURLProviders = map[string]bool{
+	"gemini-cli":  true,
+	"vertex":      true,
+	"antigravity": true,
+	"claude":      true,
+	"codex":       true,
+	"qwen":        true,
+	"iflow":       true,
+}
+
+// ValidateBaseURL checks that raw is an absolute http(s) URL without query or fragment and
+// returns it without trailing slashes.
+func ValidateBaseURL(raw string) (string, error) {
+	trimmed := strings.TrimSpace(raw)
+	if trimmed == "" {
+		return "", fmt.Errorf("base url is empty")
+	}
+	parsed, err := url.Parse(trimmed)
+	if err != nil {
+		return "", fmt.Errorf("invalid base url %q: %w", trimmed, err)
+	}
+	if parsed.Scheme != "http" && parsed.Scheme != "https" {
+		return "", fmt.Errorf("base url %q must use http or https", trimmed)
+	}
+	if parsed.Host == "" {
+		return "", fmt.Errorf("base url %q has no host", trimmed)
+	}
+	if parsed.User != nil {
+		return "", fmt.Errorf("base url %q must not contain credentials", trimmed)
+	}
+	if parsed.RawQuery != "" || parsed.Fragment != "" || strings.HasSuffix(trimmed, "?") {
+		return "", fmt.Errorf("base url %q must not contain a query or fragment", trimmed)
+	}
+	return strings.TrimRight(trimmed, "/"), nil
+}
+
+// SanitizeOAuthBaseURL normalizes provider keys of the OAuth base URL overrides and drops
+// overrides for unsupported providers or with invalid URLs.
+func (cfg *Config) SanitizeOAuthBaseURL() {
+	if cfg == nil || len(cfg.OAuthBaseURL) == 0 {
+		return
+	}
+	out := make(map[string]string, len(cfg.OAuthBaseURL))
+	for provider, raw := range cfg.OAuthBaseURL {
+		key := strings.ToLower(strings.TrimSpace(provider))
+		if key == "gemini" {
+			key = "gemini-cli"
+		}
+		if !oauthBaseURLProviders[key] {
+			log.Warnf("oauth-base-url: dropping override for unsupported provider %q", provider)
+			continue
+		}
+		baseURL, err := ValidateBaseURL(raw)
+		if err != nil {
+			log.Warnf("oauth-base-url: dropping override for %s: %v", key, err)
+			continue
+		}
+		out[key] = baseURL
+	}
+	if len(out) == 0 {
+		out = nil
+	}
+	cfg.OAuthBaseURL = out
+}
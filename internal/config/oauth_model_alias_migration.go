@@ -67,38 +67,48 @@ func MigrateOAuthModelAlias(configFile string) (bool, error) {
 		return false, nil
 	}
 
+	if !migrateOAuthModelAliasNode(rootMap) {
+		return false, nil
+	}
+	return writeYAMLNode(configFile, &root)
+}
+
+// migrateOAuthModelAliasNode applies the oauth-model-alias migration to the root mapping
+// in place and reports whether it changed anything.
+func migrateOAuthModelAliasNode(rootMap *yaml.Node) bool {
 	// Check if oauth-model-alias already exists
 	if findMapKeyIndex(rootMap, "oauth-model-alias") >= 0 {
-		return false, nil
+		return false
 	}
 
 	// Check if oauth-model-mappings exists
 	oldIdx := findMapKeyIndex(rootMap, "oauth-model-mappings")
 	if oldIdx >= 0 {
 		// Migrate from old field
-		return migrateFromOldField(configFile, &root, rootMap, oldIdx)
+		return migrateFromOldField(rootMap, oldIdx)
 	}
 
 	// Neither field exists - add default antigravity config
-	return addDefaultAntigravityConfig(configFile, &root, rootMap)
+	addDefaultAntigravityConfig(rootMap)
+	return true
 }
 
 // migrateFromOldField converts oauth-model-mappings to oauth-model-alias
-func migrateFromOldField(configFile string, root *yaml.Node, rootMap *yaml.Node, oldIdx int) (bool, error) {
+func migrateFromOldField(rootMap *yaml.Node, oldIdx int) bool {
 	if oldIdx+1 >= len(rootMap.Content) {
-		return false, nil
+		return false
 	}
 	oldValue := rootMap.Content[oldIdx+1]
 	if oldValue == nil || oldValue.Kind != yaml.MappingNode {
-		return false, nil
+		return false
 	}
 
 	// Parse the old aliases
 	oldAliases := parseOldAliasNode(oldValue)
 	if len(oldAliases) == 0 {
-		// Remove the old field and write
+		// Remove the old field
 		removeMapKeyByIndex(rootMap, oldIdx)
-		return writeYAMLNode(configFile, root)
+		return true
 	}
 
 	// Convert model names for antigravity channel
@@ -146,11 +156,11 @@ func migrateFromOldField(configFile string, root *yaml.Node, rootMap *yaml.Node,
 	rootMap.Content[oldIdx].Value = "oauth-model-alias"
 	rootMap.Content[oldIdx+1] = newNode
 
-	return writeYAMLNode(configFile, root)
+	return true
 }
 
 // addDefaultAntigravityConfig adds the default antigravity configuration
-func addDefaultAntigravityConfig(configFile string, root *yaml.Node, rootMap *yaml.Node) (bool, error) {
+func addDefaultAntigravityConfig(rootMap *yaml.Node) {
 	defaults := map[string][]OAuthModelAlias{
 		"antigravity": defaultAntigravityAliases(),
 	}
@@ -159,8 +169,6 @@ func addDefaultAntigravityConfig(configFile string, root *yaml.Node, rootMap *ya
 	// Add new key-value pair
 	keyNode := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: "oauth-model-alias"}
 	rootMap.Content = append(rootMap.Content, keyNode, newNode)
-
-	return writeYAMLNode(configFile, root)
 }
 
 // parseOldAliasNode parses the old oauth-model-mappings node structure
@@ -0,0 +1,34 @@
+package config
+
+import "strings"
+
+// OfflineConfig confines the proxy to local backends for testing and air-gapped use. Mock
+// providers and credentials whose base URL (and proxy, if any) point at a loopback address
+// or one of LocalHosts keep serving; every other credential fails fast with an "offline"
+// error in the client's dialect. Background OAuth token refreshes of remote credentials and
+// the Amp upstream proxy are suspended, and a proxy started offline skips release and
+// management panel update checks.
+type OfflineConfig struct {
+	// Enable turns offline mode on. The -offline flag forces it on regardless of this field.
+	Enable bool `yaml:"enable" json:"enable"`
+
+	// LocalHosts lists additional host names or addresses treated as local, e.g. an Ollama
+	// server on the LAN. "*.lan" matches any subdomain of lan.
+	LocalHosts []string `yaml:"local-hosts,omitempty" json:"local-hosts,omitempty"`
+}
+
+// SanitizeOffline lowercases the local hosts and strips ports from them.
+func (cfg *Config) SanitizeOffline() {
+	if cfg == nil {
+		return
+	}
+	hosts := make([]string, 0, len(cfg.Offline.LocalHosts))
+	for _, host := range trimNonEmpty(cfg.Offline.LocalHosts) {
+		host = strings.ToLower(host)
+		if i := strings.LastIndex(host, ":"); i > 0 && !strings.Contains(host[:i], ":") {
+			host = host[:i]
+		}
+		hosts = append(hosts, strings.Trim(host, "[]"))
+	}
+	cfg.Offline.LocalHosts = hosts
+}
@@ -0,0 +1,23 @@
+package config
+
+// OpenAIReasoningConfig controls how requests to OpenAI reasoning models (o1, o3, o4-mini,
+// gpt-5 and similar) are adapted before they are sent: max_tokens becomes
+// max_completion_tokens, sampling parameters those models reject are dropped, and a thinking
+// suffix becomes reasoning_effort.
+type OpenAIReasoningConfig struct {
+	// Disable forwards requests to reasoning models unchanged.
+	Disable bool `yaml:"disable,omitempty" json:"disable,omitempty"`
+
+	// Models are extra upstream model names treated as reasoning models, e.g. Azure
+	// deployment names; "*" wildcards are allowed. o-series and gpt-5 models are detected
+	// by name.
+	Models []string `yaml:"models,omitempty" json:"models,omitempty"`
+}
+
+// SanitizeOpenAIReasoning trims the extra reasoning model patterns.
+func (cfg *Config) SanitizeOpenAIReasoning() {
+	if cfg == nil {
+		return
+	}
+	cfg.OpenAIReasoning.Models = trimNonEmpty(cfg.OpenAIReasoning.Models)
+}
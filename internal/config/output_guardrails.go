@@ -0,0 +1,80 @@
+package config
+
+import (
+	"strconv"
+	"strings"
+)
+
+const (
+	// GuardrailActionRedact replaces matched output with the rule's replacement.
+	GuardrailActionRedact = "redact"
+	// GuardrailActionAnnotate leaves output unchanged and reports the rule in the
+	// X-CLIProxy-Guardrail header and the logs.
+	GuardrailActionAnnotate = "annotate"
+
+	// DefaultGuardrailReplacement is substituted for redacted output.
+	DefaultGuardrailReplacement = "[REDACTED]"
+	// DefaultGuardrailWindowBytes is how much streamed text is held back so matches split
+	// across chunks are still caught.
+	DefaultGuardrailWindowBytes = 256
+)
+
+// OutputGuardrailConfig configures scanning of generated content before it reaches clients.
+type OutputGuardrailConfig struct {
+	// Rules are applied to the text of every response, in order.
+	Rules []OutputGuardrailRule `yaml:"rules,omitempty" json:"rules,omitempty"`
+
+	// WindowBytes is how much streamed text is held back for matching across chunks. It must
+	// cover the longest expected match. Defaults to 256.
+	WindowBytes int `yaml:"window-bytes,omitempty" json:"window-bytes,omitempty"`
+}
+
+// OutputGuardrailRule matches generated text by keyword or regular expression.
+type OutputGuardrailRule struct {
+	// Name identifies the rule in logs and the X-CLIProxy-Guardrail header.
+	Name string `yaml:"name" json:"name"`
+
+	// Keywords match case-insensitively.
+	Keywords []string `yaml:"keywords,omitempty" json:"keywords,omitempty"`
+
+	// Patterns are regular expressions.
+	Patterns []string `yaml:"patterns,omitempty" json:"patterns,omitempty"`
+
+	// Action is "redact" (default) or "annotate".
+	Action string `yaml:"action,omitempty" json:"action,omitempty"`
+
+	// Replacement substitutes redacted matches. Defaults to "[REDACTED]".
+	Replacement string `yaml:"replacement,omitempty" json:"replacement,omitempty"`
+}
+
+// SanitizeOutputGuardrails normalizes guardrail rules and drops ones without a matcher.
+func (cfg *Config) SanitizeOutputGuardrails() {
+	if cfg == nil {
+		return
+	}
+	g := &cfg.OutputGuardrails
+	if g.WindowBytes <= 0 {
+		g.WindowBytes = DefaultGuardrailWindowBytes
+	}
+	rules := make([]OutputGuardrailRule, 0, len(g.Rules))
+	for i, rule := range g.Rules {
+		rule.Name = strings.TrimSpace(rule.Name)
+		rule.Keywords = trimNonEmpty(rule.Keywords)
+		rule.Patterns = trimNonEmpty(rule.Patterns)
+		if len(rule.Keywords)+len(rule.Patterns) == 0 {
+			continue
+		}
+		if rule.Name == "" {
+			rule.Name = "rule-" + strconv.Itoa(i+1)
+		}
+		rule.Action = strings.ToLower(strings.TrimSpace(rule.Action))
+		if rule.Action != GuardrailActionAnnotate {
+			rule.Action = GuardrailActionRedact
+		}
+		if rule.Replacement == "" {
+			rule.Replacement = DefaultGuardrailReplacement
+		}
+		rules = append(rules, rule)
+	}
+	g.Rules = rules
+}
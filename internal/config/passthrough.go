@@ -0,0 +1,66 @@
+package config
+
+import (
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// PassthroughRoute mounts /passthrough/<name>/* as a raw proxy to a provider: requests
+// get the credentials of one of the provider's auths and are otherwise forwarded as is,
+// with no translation, model mapping or payload rewriting.
+type PassthroughRoute struct {
+	// Name is the path segment of the route. Defaults to the provider.
+	Name string `yaml:"name,omitempty" json:"name,omitempty"`
+
+	// Provider selects the auths whose credentials are injected, e.g. "claude", "gemini",
+	// "codex" or the name of an openai-compatibility provider.
+	Provider string `yaml:"provider" json:"provider"`
+
+	// BaseURL is the upstream the request path is appended to. Defaults to the base URL
+	// of the selected auth, then to the provider's public API.
+	BaseURL string `yaml:"base-url,omitempty" json:"base-url,omitempty"`
+}
+
+// SanitizePassthrough normalizes passthrough routes, dropping those without a provider
+// and duplicate names.
+func (cfg *Config) SanitizePassthrough() {
+	if cfg == nil || len(cfg.Passthrough) == 0 {
+		return
+	}
+	seen := make(map[string]bool, len(cfg.Passthrough))
+	routes := cfg.Passthrough[:0]
+	for _, route := range cfg.Passthrough {
+		route.Provider = strings.ToLower(strings.TrimSpace(route.Provider))
+		route.Name = strings.ToLower(strings.Trim(strings.TrimSpace(route.Name), "/"))
+		route.BaseURL = strings.TrimRight(strings.TrimSpace(route.BaseURL), "/")
+		if route.Provider == "" {
+			log.Warnf("passthrough: dropping route %q without a provider", route.Name)
+			continue
+		}
+		if route.Name == "" {
+			route.Name = route.Provider
+		}
+		if seen[route.Name] {
+			log.Warnf("passthrough: dropping duplicate route %q", route.Name)
+			continue
+		}
+		seen[route.Name] = true
+		routes = append(routes, route)
+	}
+	cfg.Passthrough = routes
+}
+
+// PassthroughRoute returns the route mounted at name.
+func (cfg *Config) PassthroughRoute(name string) (PassthroughRoute, bool) {
+	if cfg == nil {
+		return PassthroughRoute{}, false
+	}
+	name = strings.ToLower(name)
+	for _, route := range cfg.Passthrough {
+		if route.Name == name {
+			return route, true
+		}
+	}
+	return PassthroughRoute{}, false
+}
@@ -0,0 +1,53 @@
+package config
+
+import "strings"
+
+// PricingConfig prices the token usage of models for cost accounting: the usage
+// statistics, spend limits without their own prices and Amp credit estimates. Entries are
+// matched in order ahead of the bundled list prices, so an entry overrides the default of
+// the same model.
+type PricingConfig struct {
+	Models []ModelPrice `yaml:"models,omitempty" json:"models,omitempty"`
+
+	// DisableDefaults drops the bundled list prices; only Models are priced.
+	DisableDefaults bool `yaml:"disable-defaults,omitempty" json:"disable-defaults,omitempty"`
+
+	// DisableResponseHeaders stops adding the X-CLIProxy-Cost and X-CLIProxy-Route headers,
+	// which report the estimated cost and the route of each request, to responses.
+	DisableResponseHeaders bool `yaml:"disable-response-headers,omitempty" json:"disable-response-headers,omitempty"`
+}
+
+// ModelPrice is the price of a model in USD per 1,000 tokens.
+type ModelPrice struct {
+	// Model is the model id; '*' matches any substring (e.g. "claude-sonnet-4*").
+	Model string `yaml:"model" json:"model"`
+
+	InputPer1K  float64 `yaml:"input-per-1k" json:"input-per-1k"`
+	OutputPer1K float64 `yaml:"output-per-1k" json:"output-per-1k"`
+
+	// CachedInputPer1K prices cache reads. 0 prices them like regular input.
+	CachedInputPer1K float64 `yaml:"cached-input-per-1k,omitempty" json:"cached-input-per-1k,omitempty"`
+
+	// ReasoningPer1K prices thinking tokens reported apart from the output. 0 prices them
+	// like output.
+	ReasoningPer1K float64 `yaml:"reasoning-per-1k,omitempty" json:"reasoning-per-1k,omitempty"`
+}
+
+// SanitizePricing drops price entries without a model and clamps negative prices.
+func (cfg *Config) SanitizePricing() {
+	if cfg == nil || len(cfg.Pricing.Models) == 0 {
+		return
+	}
+	out := make([]ModelPrice, 0, len(cfg.Pricing.Models))
+	for _, price := range cfg.Pricing.Models {
+		if price.Model = strings.TrimSpace(price.Model); price.Model == "" {
+			continue
+		}
+		price.InputPer1K = max(price.InputPer1K, 0)
+		price.OutputPer1K = max(price.OutputPer1K, 0)
+		price.CachedInputPer1K = max(price.CachedInputPer1K, 0)
+		price.ReasoningPer1K = max(price.ReasoningPer1K, 0)
+		out = append(out, price)
+	}
+	cfg.Pricing.Models = out
+}
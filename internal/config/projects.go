@@ -0,0 +1,95 @@
+package config
+
+import "strings"
+
+// Project groups client API keys so a single proxy instance can serve several teams.
+// Keys listed here must also be accepted by an access provider (e.g. top-level api-keys);
+// the project only scopes what an authenticated key can do.
+type Project struct {
+	// Name identifies the project in usage reports, transcripts and management endpoints.
+	Name string `yaml:"name" json:"name"`
+
+	// APIKeys lists the client API keys that belong to the project.
+	APIKeys []string `yaml:"api-keys" json:"api-keys"`
+
+	// ModelMappings rewrites requested models for this project before routing.
+	ModelMappings []AmpModelMapping `yaml:"model-mappings,omitempty" json:"model-mappings,omitempty"`
+
+	// Credentials pins provider credentials (auth IDs or auth file names) to this project.
+	// Pinned credentials are never selected for requests of other projects or unscoped keys.
+	Credentials []string `yaml:"credentials,omitempty" json:"credentials,omitempty"`
+
+	// CredentialsOnly restricts the project to its pinned credentials.
+	CredentialsOnly bool `yaml:"credentials-only,omitempty" json:"credentials-only,omitempty"`
+
+	// Quota limits the project's request volume and token consumption.
+	Quota ProjectQuota `yaml:"quota,omitempty" json:"quota,omitempty"`
+}
+
+// ProjectQuota bounds the traffic of a project. Zero values disable the corresponding limit.
+type ProjectQuota struct {
+	// RequestsPerMinute caps requests in a sliding one-minute window.
+	RequestsPerMinute int `yaml:"requests-per-minute,omitempty" json:"requests-per-minute,omitempty"`
+
+	// DailyRequests caps requests per UTC day.
+	DailyRequests int `yaml:"daily-requests,omitempty" json:"daily-requests,omitempty"`
+
+	// MonthlyTokens caps total tokens per UTC calendar month.
+	MonthlyTokens int64 `yaml:"monthly-tokens,omitempty" json:"monthly-tokens,omitempty"`
+}
+
+// SanitizeProjects trims project entries, drops unnamed ones and makes sure each API key
+// belongs to at most one project (the first one listing it).
+func (cfg *Config) SanitizeProjects() {
+	if cfg == nil || len(cfg.Projects) == 0 {
+		return
+	}
+	seenNames := make(map[string]struct{}, len(cfg.Projects))
+	seenKeys := make(map[string]struct{})
+	out := make([]Project, 0, len(cfg.Projects))
+	for _, project := range cfg.Projects {
+		project.Name = strings.TrimSpace(project.Name)
+		if project.Name == "" {
+			continue
+		}
+		if _, dup := seenNames[project.Name]; dup {
+			continue
+		}
+		seenNames[project.Name] = struct{}{}
+
+		keys := make([]string, 0, len(project.APIKeys))
+		for _, key := range project.APIKeys {
+			key = strings.TrimSpace(key)
+			if key == "" {
+				continue
+			}
+			if _, dup := seenKeys[key]; dup {
+				continue
+			}
+			seenKeys[key] = struct{}{}
+			keys = append(keys, key)
+		}
+		project.APIKeys = keys
+
+		mappings := make([]AmpModelMapping, 0, len(project.ModelMappings))
+		for _, mapping := range project.ModelMappings {
+			mapping.From = strings.TrimSpace(mapping.From)
+			mapping.To = strings.TrimSpace(mapping.To)
+			if mapping.From == "" || mapping.To == "" {
+				continue
+			}
+			mappings = append(mappings, mapping)
+		}
+		project.ModelMappings = mappings
+
+		credentials := make([]string, 0, len(project.Credentials))
+		for _, id := range project.Credentials {
+			if id = strings.TrimSpace(id); id != "" {
+				credentials = append(credentials, id)
+			}
+		}
+		project.Credentials = credentials
+		out = append(out, project)
+	}
+	cfg.Projects = out
+}
@@ -0,0 +1,45 @@
+package config
+
+import "strings"
+
+// PromptTemplate is a named prompt served by the /v1/prompt-templates endpoints. Template
+// and System may reference variables as {{name}}; clients supply the values when rendering.
+type PromptTemplate struct {
+	// Name identifies the template in render requests and management calls.
+	Name string `yaml:"name" json:"name"`
+
+	// Description is shown to clients listing the templates.
+	Description string `yaml:"description,omitempty" json:"description,omitempty"`
+
+	// Model is used when the render request names no model.
+	Model string `yaml:"model,omitempty" json:"model,omitempty"`
+
+	// System is rendered into a system message ahead of the prompt.
+	System string `yaml:"system,omitempty" json:"system,omitempty"`
+
+	// Template is rendered into the user message.
+	Template string `yaml:"template" json:"template"`
+
+	// Defaults are the values of variables a render request leaves out.
+	Defaults map[string]string `yaml:"defaults,omitempty" json:"defaults,omitempty"`
+}
+
+// SanitizePromptTemplates trims template names and drops templates without a name or
+// prompt, keeping the first of templates sharing a name.
+func (cfg *Config) SanitizePromptTemplates() {
+	if cfg == nil {
+		return
+	}
+	seen := make(map[string]bool, len(cfg.PromptTemplates))
+	templates := make([]PromptTemplate, 0, len(cfg.PromptTemplates))
+	for _, tmpl := range cfg.PromptTemplates {
+		tmpl.Name = strings.TrimSpace(tmpl.Name)
+		tmpl.Model = strings.TrimSpace(tmpl.Model)
+		if tmpl.Name == "" || strings.TrimSpace(tmpl.Template) == "" || seen[tmpl.Name] {
+			continue
+		}
+		seen[tmpl.Name] = true
+		templates = append(templates, tmpl)
+	}
+	cfg.PromptTemplates = templates
+}
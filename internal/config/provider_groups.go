@@ -0,0 +1,151 @@
+package config
+
+import (
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// ProviderGroup holds settings shared by the credentials that name it in their group
+// field, so deployments with many credentials configure proxies, headers and limits once.
+// Credential values take precedence over inherited ones; headers and excluded models are
+// merged. Routing rules send requests to a group with pin-group, and the credentials of the
+// group are then balanced by the configured routing strategy.
+type ProviderGroup struct {
+	// Name identifies the group in credential group fields and in pin-group actions.
+	Name string `yaml:"name" json:"name"`
+
+	// ProxyURL is the proxy of members without their own.
+	ProxyURL string `yaml:"proxy-url,omitempty" json:"proxy-url,omitempty"`
+
+	// Prefix namespaces the models of members without their own prefix.
+	Prefix string `yaml:"prefix,omitempty" json:"prefix,omitempty"`
+
+	// Priority is the selection priority of members that leave theirs at 0.
+	Priority int `yaml:"priority,omitempty" json:"priority,omitempty"`
+
+	// Headers are sent with the requests of every member; member headers win on conflicts.
+	Headers map[string]string `yaml:"headers,omitempty" json:"headers,omitempty"`
+
+	// ExcludedModels are excluded for every member in addition to its own list.
+	ExcludedModels []string `yaml:"excluded-models,omitempty" json:"excluded-models,omitempty"`
+
+	// MaxConcurrency caps the in-flight upstream requests across all members. 0 is unlimited.
+	MaxConcurrency int `yaml:"max-concurrency,omitempty" json:"max-concurrency,omitempty"`
+}
+
+// GroupSettings are the credential settings a provider group passes on to its members.
+type GroupSettings struct {
+	ProxyURL       string
+	Prefix         string
+	Priority       int
+	Headers        map[string]string
+	ExcludedModels []string
+}
+
+// ProviderGroupByName returns the group called name.
+func (cfg *Config) ProviderGroupByName(name string) (ProviderGroup, bool) {
+	name = strings.TrimSpace(name)
+	if cfg == nil || name == "" {
+		return ProviderGroup{}, false
+	}
+	for _, group := range cfg.ProviderGroups {
+		if strings.EqualFold(group.Name, name) {
+			return group, true
+		}
+	}
+	return ProviderGroup{}, false
+}
+
+// InheritGroup returns own completed with the settings of the group called name. own is
+// returned unchanged when no such group exists.
+func (cfg *Config) InheritGroup(name string, own GroupSettings) GroupSettings {
+	group, ok := cfg.ProviderGroupByName(name)
+	if !ok {
+		return own
+	}
+	if strings.TrimSpace(own.ProxyURL) == "" {
+		own.ProxyURL = group.ProxyURL
+	}
+	if strings.TrimSpace(own.Prefix) == "" {
+		own.Prefix = group.Prefix
+	}
+	if own.Priority == 0 {
+		own.Priority = group.Priority
+	}
+	if len(group.Headers) > 0 {
+		headers := make(map[string]string, len(group.Headers)+len(own.Headers))
+		for name, value := range group.Headers {
+			headers[name] = value
+		}
+		for name, value := range own.Headers {
+			headers[name] = value
+		}
+		own.Headers = headers
+	}
+	if len(group.ExcludedModels) > 0 {
+		own.ExcludedModels = append(append([]string(nil), own.ExcludedModels...), group.ExcludedModels...)
+	}
+	return own
+}
+
+// GroupExcludedModels returns the excluded models of a member of the group called name.
+func (cfg *Config) GroupExcludedModels(name string, own []string) []string {
+	return cfg.InheritGroup(name, GroupSettings{ExcludedModels: own}).ExcludedModels
+}
+
+// SanitizeProviderGroups trims the groups, drops unnamed and duplicate ones, and clears
+// member group fields naming no group.
+func (cfg *Config) SanitizeProviderGroups() {
+	if cfg == nil {
+		return
+	}
+	seen := make(map[string]struct{}, len(cfg.ProviderGroups))
+	groups := make([]ProviderGroup, 0, len(cfg.ProviderGroups))
+	for _, group := range cfg.ProviderGroups {
+		group.Name = strings.TrimSpace(group.Name)
+		key := strings.ToLower(group.Name)
+		if group.Name == "" {
+			continue
+		}
+		if _, dup := seen[key]; dup {
+			continue
+		}
+		seen[key] = struct{}{}
+		group.ProxyURL = strings.TrimSpace(group.ProxyURL)
+		group.Prefix = strings.Trim(strings.TrimSpace(group.Prefix), "/")
+		group.Headers = NormalizeHeaders(group.Headers)
+		group.ExcludedModels = NormalizeExcludedModels(group.ExcludedModels)
+		group.MaxConcurrency = max(group.MaxConcurrency, 0)
+		groups = append(groups, group)
+	}
+	cfg.ProviderGroups = groups
+
+	resolve := func(name *string) {
+		if strings.TrimSpace(*name) == "" {
+			*name = ""
+			return
+		}
+		if group, ok := cfg.ProviderGroupByName(*name); ok {
+			*name = group.Name
+			return
+		}
+		log.Warnf("provider-groups: ignoring unknown group %q", *name)
+		*name = ""
+	}
+	for i := range cfg.GeminiKey {
+		resolve(&cfg.GeminiKey[i].Group)
+	}
+	for i := range cfg.ClaudeKey {
+		resolve(&cfg.ClaudeKey[i].Group)
+	}
+	for i := range cfg.CodexKey {
+		resolve(&cfg.CodexKey[i].Group)
+	}
+	for i := range cfg.VertexCompatAPIKey {
+		resolve(&cfg.VertexCompatAPIKey[i].Group)
+	}
+	for i := range cfg.OpenAICompatibility {
+		resolve(&cfg.OpenAICompatibility[i].Group)
+	}
+}
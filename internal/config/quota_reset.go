@@ -0,0 +1,176 @@
+package config
+
+import (
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Quota reset periods.
+const (
+	QuotaResetDaily   = "daily"
+	QuotaResetMonthly = "monthly"
+)
+
+// QuotaResetSchedule describes when a provider resets the quota of its credentials, e.g.
+// daily at midnight Pacific time or monthly on the billing day.
+type QuotaResetSchedule struct {
+	// Provider is the credential provider key (e.g. "gemini-cli", "claude").
+	Provider string `yaml:"provider" json:"provider"`
+
+	// AuthID optionally narrows the schedule to a single credential (auth ID or file name).
+	AuthID string `yaml:"auth-id,omitempty" json:"auth-id,omitempty"`
+
+	// Period is "daily" or "monthly".
+	Period string `yaml:"period" json:"period"`
+
+	// Timezone is the IANA zone the reset happens in. Defaults to UTC.
+	Timezone string `yaml:"timezone,omitempty" json:"timezone,omitempty"`
+
+	// Day is the day of month of monthly resets (1-31, clamped to the month's last day).
+	Day int `yaml:"day,omitempty" json:"day,omitempty"`
+
+	// Hour is the local hour of the reset (0-23).
+	Hour int `yaml:"hour,omitempty" json:"hour,omitempty"`
+
+	// CooldownUntilReset cools a credential down until the next reset when it hits a quota
+	// error without Retry-After, instead of the exponential backoff. Backoffs never extend
+	// past the next reset either way.
+	CooldownUntilReset bool `yaml:"cooldown-until-reset,omitempty" json:"cooldown-until-reset,omitempty"`
+}
+
+// DefaultQuotaResetSchedule is the calendar month in UTC.
+var DefaultQuotaResetSchedule = QuotaResetSchedule{Period: QuotaResetMonthly, Day: 1}
+
+var locationCache sync.Map
+
+func (s QuotaResetSchedule) location() *time.Location {
+	if s.Timezone == "" {
+		return time.UTC
+	}
+	if loc, ok := locationCache.Load(s.Timezone); ok {
+		return loc.(*time.Location)
+	}
+	loc, err := time.LoadLocation(s.Timezone)
+	if err != nil {
+		loc = time.UTC
+	}
+	locationCache.Store(s.Timezone, loc)
+	return loc
+}
+
+// PeriodStart returns the start of the quota period containing now.
+func (s QuotaResetSchedule) PeriodStart(now time.Time) time.Time {
+	local := now.In(s.location())
+	if s.Period == QuotaResetDaily {
+		start := time.Date(local.Year(), local.Month(), local.Day(), s.Hour, 0, 0, 0, local.Location())
+		if start.After(local) {
+			start = start.AddDate(0, 0, -1)
+		}
+		return start
+	}
+	start := s.monthlyReset(local.Year(), local.Month(), local.Location())
+	if start.After(local) {
+		start = s.monthlyReset(local.Year(), local.Month()-1, local.Location())
+	}
+	return start
+}
+
+// NextReset returns the end of the quota period containing now.
+func (s QuotaResetSchedule) NextReset(now time.Time) time.Time {
+	start := s.PeriodStart(now)
+	if s.Period == QuotaResetDaily {
+		return time.Date(start.Year(), start.Month(), start.Day()+1, s.Hour, 0, 0, 0, start.Location())
+	}
+	return s.monthlyReset(start.Year(), start.Month()+1, start.Location())
+}
+
+// PeriodKey identifies the quota period containing now, e.g. "2026-10" for calendar
+// months or "2026-10-14T07" otherwise.
+func (s QuotaResetSchedule) PeriodKey(now time.Time) string {
+	start := s.PeriodStart(now)
+	if s == DefaultQuotaResetSchedule {
+		return start.Format("2006-01")
+	}
+	return start.UTC().Format("2006-01-02T15")
+}
+
+func (s QuotaResetSchedule) monthlyReset(year int, month time.Month, loc *time.Location) time.Time {
+	first := time.Date(year, month, 1, 0, 0, 0, 0, loc)
+	lastDay := first.AddDate(0, 1, -1).Day()
+	return time.Date(first.Year(), first.Month(), min(max(s.Day, 1), lastDay), s.Hour, 0, 0, 0, loc)
+}
+
+// ResetScheduleFor returns the schedule of a credential; an entry naming the auth ID takes
+// precedence over provider-wide entries.
+func (q QuotaExceeded) ResetScheduleFor(provider, authID string) (QuotaResetSchedule, bool) {
+	provider = strings.ToLower(strings.TrimSpace(provider))
+	var fallback *QuotaResetSchedule
+	for i := range q.ResetSchedules {
+		schedule := &q.ResetSchedules[i]
+		if schedule.Provider != provider {
+			continue
+		}
+		if schedule.AuthID == "" {
+			if fallback == nil {
+				fallback = schedule
+			}
+			continue
+		}
+		if MatchesAuthID(schedule.AuthID, authID) {
+			return *schedule, true
+		}
+	}
+	if fallback != nil {
+		return *fallback, true
+	}
+	return QuotaResetSchedule{}, false
+}
+
+// MatchesAuthID reports whether an auth-id setting names the credential authID, either by
+// its full ID or by the file name of a file-backed credential.
+func MatchesAuthID(configured, authID string) bool {
+	if configured == "" || authID == "" {
+		return false
+	}
+	return configured == authID || path.Base(filepath.ToSlash(authID)) == configured
+}
+
+// SanitizeQuotaResetSchedules normalizes reset schedules and drops invalid ones.
+func (cfg *Config) SanitizeQuotaResetSchedules() {
+	if cfg == nil || len(cfg.QuotaExceeded.ResetSchedules) == 0 {
+		return
+	}
+	out := make([]QuotaResetSchedule, 0, len(cfg.QuotaExceeded.ResetSchedules))
+	for _, schedule := range cfg.QuotaExceeded.ResetSchedules {
+		schedule.Provider = strings.ToLower(strings.TrimSpace(schedule.Provider))
+		schedule.AuthID = strings.TrimSpace(schedule.AuthID)
+		schedule.Period = strings.ToLower(strings.TrimSpace(schedule.Period))
+		schedule.Timezone = strings.TrimSpace(schedule.Timezone)
+		if schedule.Provider == "" {
+			continue
+		}
+		if schedule.Period != QuotaResetDaily && schedule.Period != QuotaResetMonthly {
+			log.Warnf("quota-exceeded.reset-schedules: ignoring %s entry with unknown period %q", schedule.Provider, schedule.Period)
+			continue
+		}
+		if schedule.Timezone != "" {
+			if _, err := time.LoadLocation(schedule.Timezone); err != nil {
+				log.Warnf("quota-exceeded.reset-schedules: invalid timezone %q for %s, using UTC", schedule.Timezone, schedule.Provider)
+				schedule.Timezone = ""
+			}
+		}
+		if schedule.Period == QuotaResetDaily {
+			schedule.Day = 0
+		} else {
+			schedule.Day = min(max(schedule.Day, 1), 31)
+		}
+		schedule.Hour = min(max(schedule.Hour, 0), 23)
+		out = append(out, schedule)
+	}
+	cfg.QuotaExceeded.ResetSchedules = out
+}
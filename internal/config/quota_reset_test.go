@@ -0,0 +1,19 @@
+package config
+
+import "testing"
+
+func TestResetScheduleForMatchesAuthIDOrFileName(t *testing.T) {
+	q := QuotaExceeded{ResetSchedules: []QuotaResetSchedule{
+		{Provider: "claude", Period: QuotaResetMonthly, Day: 1},
+		{Provider: "claude", AuthID: "team-b.json", Period: QuotaResetDaily, Hour: 8},
+	}}
+	for authID, want := range map[string]string{
+		"team-b.json":          QuotaResetDaily,
+		"accounts/team-b.json": QuotaResetDaily,
+		"team-a.json":          QuotaResetMonthly,
+	} {
+		if schedule, ok := q.ResetScheduleFor("claude", authID); !ok || schedule.Period != want {
+			t.Errorf("ResetScheduleFor(%q) = %+v, %v; want %s", authID, schedule, ok, want)
+		}
+	}
+}
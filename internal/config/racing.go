@@ -0,0 +1,56 @@
+package config
+
+import "strings"
+
+// RaceRule sends requests for Model to every target model at once and answers with the
+// first one that responds; the others are cancelled.
+type RaceRule struct {
+	// Model is the requested model name, after model mappings, that triggers the race.
+	Model string `yaml:"model" json:"model"`
+
+	// Targets lists the models raced against each other, usually served by different
+	// providers. At least two are required.
+	Targets []string `yaml:"targets" json:"targets"`
+}
+
+// RaceTargets returns the targets of the rule matching model, or nil.
+func (c *SDKConfig) RaceTargets(model string) []string {
+	if c == nil {
+		return nil
+	}
+	model = strings.TrimSpace(model)
+	for _, rule := range c.SpeculativeRacing {
+		if strings.EqualFold(rule.Model, model) {
+			return rule.Targets
+		}
+	}
+	return nil
+}
+
+// SanitizeSpeculativeRacing trims race rules and drops ones with fewer than two targets.
+func (cfg *Config) SanitizeSpeculativeRacing() {
+	if cfg == nil || len(cfg.SpeculativeRacing) == 0 {
+		return
+	}
+	out := make([]RaceRule, 0, len(cfg.SpeculativeRacing))
+	for _, rule := range cfg.SpeculativeRacing {
+		rule.Model = strings.TrimSpace(rule.Model)
+		targets := make([]string, 0, len(rule.Targets))
+		seen := make(map[string]struct{}, len(rule.Targets))
+		for _, target := range rule.Targets {
+			target = strings.TrimSpace(target)
+			key := strings.ToLower(target)
+			if _, dup := seen[key]; target == "" || dup || strings.EqualFold(target, rule.Model) {
+				continue
+			}
+			seen[key] = struct{}{}
+			targets = append(targets, target)
+		}
+		if rule.Model == "" || len(targets) < 2 {
+			continue
+		}
+		rule.Targets = targets
+		out = append(out, rule)
+	}
+	cfg.SpeculativeRacing = out
+}
@@ -0,0 +1,64 @@
+package config
+
+import (
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Recording modes.
+const (
+	// RecordingRecord forwards every request and stores its response, replacing older ones.
+	RecordingRecord = "record"
+	// RecordingReplay answers from stored responses only and fails requests without one.
+	RecordingReplay = "replay"
+	// RecordingReplayOrRecord answers from stored responses and records the misses.
+	RecordingReplayOrRecord = "replay-or-record"
+)
+
+// DefaultRecordingDir is where recordings are stored when dir is unset.
+const DefaultRecordingDir = "recordings"
+
+// RecordingConfig captures the responses of API requests keyed by a hash of the request and
+// serves them back later, so editor integrations can be developed offline without provider
+// credentials. Streams are replayed chunk by chunk.
+type RecordingConfig struct {
+	// Mode is "record", "replay" or "replay-or-record". Empty disables recording.
+	Mode string `yaml:"mode,omitempty" json:"mode,omitempty"`
+
+	// Dir holds one JSON file per recorded request. Defaults to "recordings".
+	Dir string `yaml:"dir,omitempty" json:"dir,omitempty"`
+
+	// IgnoreFields lists top-level request fields left out of the request hash, e.g. "user"
+	// or "metadata", so requests differing only in them share a recording.
+	IgnoreFields []string `yaml:"ignore-fields,omitempty" json:"ignore-fields,omitempty"`
+}
+
+// Records reports whether upstream responses are stored.
+func (c RecordingConfig) Records() bool {
+	return c.Mode == RecordingRecord || c.Mode == RecordingReplayOrRecord
+}
+
+// Replays reports whether stored responses are served.
+func (c RecordingConfig) Replays() bool {
+	return c.Mode == RecordingReplay || c.Mode == RecordingReplayOrRecord
+}
+
+// SanitizeRecording normalizes the recording settings.
+func (cfg *Config) SanitizeRecording() {
+	if cfg == nil {
+		return
+	}
+	r := &cfg.Recording
+	r.Mode = strings.ToLower(strings.TrimSpace(r.Mode))
+	switch r.Mode {
+	case "", RecordingRecord, RecordingReplay, RecordingReplayOrRecord:
+	default:
+		log.Warnf("recording: unknown mode %q, recording disabled", r.Mode)
+		r.Mode = ""
+	}
+	if r.Dir = strings.TrimSpace(r.Dir); r.Dir == "" {
+		r.Dir = DefaultRecordingDir
+	}
+	r.IgnoreFields = trimNonEmpty(r.IgnoreFields)
+}
@@ -0,0 +1,48 @@
+package config
+
+import "strings"
+
+// RefusalFallbackConfig retries non-streaming requests the provider refused on content-policy
+// grounds once on an alternate model.
+type RefusalFallbackConfig struct {
+	// Rules name the fallback model of each requested model. No rules disables the retry.
+	Rules []RefusalFallbackRule `yaml:"rules,omitempty" json:"rules,omitempty"`
+
+	// Patterns are extra case-insensitive phrases that mark a refusal when they appear in the
+	// first 300 characters of the response text, for providers that refuse in plain text,
+	// e.g. "I can't help with".
+	Patterns []string `yaml:"patterns,omitempty" json:"patterns,omitempty"`
+}
+
+// RefusalFallbackRule retries refusals of the models matching Models on Fallback.
+type RefusalFallbackRule struct {
+	// Models are the requested model names the rule applies to; "*" wildcards are allowed.
+	Models []string `yaml:"models" json:"models"`
+
+	// Fallback is the model the refused request is retried on, usually served by another
+	// provider.
+	Fallback string `yaml:"fallback" json:"fallback"`
+}
+
+// SanitizeRefusalFallback trims rules and patterns and drops incomplete rules.
+func (cfg *Config) SanitizeRefusalFallback() {
+	if cfg == nil {
+		return
+	}
+	fallback := &cfg.RefusalFallback
+	rules := make([]RefusalFallbackRule, 0, len(fallback.Rules))
+	for _, rule := range fallback.Rules {
+		rule.Models = trimNonEmpty(rule.Models)
+		rule.Fallback = strings.TrimSpace(rule.Fallback)
+		if len(rule.Models) == 0 || rule.Fallback == "" {
+			continue
+		}
+		rules = append(rules, rule)
+	}
+	fallback.Rules = rules
+	patterns := trimNonEmpty(fallback.Patterns)
+	for i, pattern := range patterns {
+		patterns[i] = strings.ToLower(pattern)
+	}
+	fallback.Patterns = patterns
+}
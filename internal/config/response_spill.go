@@ -0,0 +1,38 @@
+package config
+
+import "strings"
+
+// DefaultResponseSpillMaxBytes caps one spilled buffer when max-bytes is unset.
+const DefaultResponseSpillMaxBytes = 256 << 20
+
+// ResponseSpillConfig moves large response buffers to disk. The Amp response rewriter, the
+// Idempotency-Key cache and stream resumption keep responses in memory; with spilling
+// enabled, a buffer growing past MemoryBytes continues in a temporary file instead.
+type ResponseSpillConfig struct {
+	// MemoryBytes is the in-memory size of one buffer before it spills. <= 0 disables spilling.
+	MemoryBytes int64 `yaml:"memory-bytes,omitempty" json:"memory-bytes,omitempty"`
+
+	// Dir holds the spill files. Defaults to the system temporary directory.
+	Dir string `yaml:"dir,omitempty" json:"dir,omitempty"`
+
+	// MaxBytes caps one spilled buffer; writes beyond it fail. Defaults to 256 MiB.
+	MaxBytes int64 `yaml:"max-bytes,omitempty" json:"max-bytes,omitempty"`
+}
+
+// SanitizeResponseSpill applies the response spill defaults.
+func (cfg *Config) SanitizeResponseSpill() {
+	if cfg == nil {
+		return
+	}
+	rs := &cfg.ResponseSpill
+	rs.Dir = strings.TrimSpace(rs.Dir)
+	if rs.MemoryBytes < 0 {
+		rs.MemoryBytes = 0
+	}
+	if rs.MaxBytes <= 0 {
+		rs.MaxBytes = DefaultResponseSpillMaxBytes
+	}
+	if rs.MemoryBytes > 0 && rs.MaxBytes < rs.MemoryBytes {
+		rs.MaxBytes = rs.MemoryBytes
+	}
+}
@@ -0,0 +1,26 @@
+package config
+
+import "strings"
+
+// ModuleEnabled reports whether the routing module name is enabled. Unlisted modules are.
+func (cfg *Config) ModuleEnabled(name string) bool {
+	if cfg == nil {
+		return true
+	}
+	enabled, ok := cfg.Modules[strings.ToLower(name)]
+	return !ok || enabled
+}
+
+// SanitizeModules lowercases the routing module names and drops empty ones.
+func (cfg *Config) SanitizeModules() {
+	if cfg == nil || len(cfg.Modules) == 0 {
+		return
+	}
+	mods := make(map[string]bool, len(cfg.Modules))
+	for name, enabled := range cfg.Modules {
+		if name = strings.ToLower(strings.TrimSpace(name)); name != "" {
+			mods[name] = enabled
+		}
+	}
+	cfg.Modules = mods
+}
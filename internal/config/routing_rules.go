@@ -0,0 +1,121 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// RoutingRule is one entry of routing.rules. Rules are evaluated in order before project
+// credential selection and the Amp fallback logic; the first rule whose conditions all
+// match applies its action.
+type RoutingRule struct {
+	// Name identifies the rule in logs. Defaults to "rule-<n>".
+	Name string `yaml:"name,omitempty" json:"name,omitempty"`
+
+	// Match lists the conditions. A rule without conditions matches every request.
+	Match RoutingRuleMatch `yaml:"match,omitempty" json:"match,omitempty"`
+
+	// Action is applied to matching requests.
+	Action RoutingRuleAction `yaml:"action" json:"action"`
+}
+
+// RoutingRuleMatch holds the conditions of a routing rule. Patterns support '*' wildcards
+// and are matched case-insensitively.
+type RoutingRuleMatch struct {
+	// Model matches the requested model name, e.g. "claude-opus-*".
+	Model string `yaml:"model,omitempty" json:"model,omitempty"`
+
+	// Path matches the ingress request path, e.g. "/api/provider/*".
+	Path string `yaml:"path,omitempty" json:"path,omitempty"`
+
+	// Headers requires each listed request header to match its pattern.
+	Headers map[string]string `yaml:"headers,omitempty" json:"headers,omitempty"`
+
+	// APIKeys matches requests authenticated with one of these client API keys.
+	APIKeys []string `yaml:"api-keys,omitempty" json:"api-keys,omitempty"`
+
+	// Tags matches requests carrying any of these tags. Clients send tags in the
+	// X-Routing-Tags header (comma separated); scoped requests also carry "project:<name>".
+	Tags []string `yaml:"tags,omitempty" json:"tags,omitempty"`
+}
+
+// RoutingRuleAction describes what a matching rule does. Deny excludes the other actions.
+type RoutingRuleAction struct {
+	// MapModel rewrites the requested model.
+	MapModel string `yaml:"map-model,omitempty" json:"map-model,omitempty"`
+
+	// PinProvider restricts credential selection to this provider (e.g. "claude").
+	PinProvider string `yaml:"pin-provider,omitempty" json:"pin-provider,omitempty"`
+
+	// PinCredentials restricts credential selection to these credentials (auth IDs or auth
+	// file names), e.g. so the API keys of a team always use the team's OAuth account.
+	// Requests fail with 503 rather than falling back when none of them is available.
+	PinCredentials []string `yaml:"pin-credentials,omitempty" json:"pin-credentials,omitempty"`
+
+	// PinGroup restricts credential selection to the members of this provider group, which
+	// are balanced by the routing strategy. Requests fail with 503 when none is available.
+	PinGroup string `yaml:"pin-group,omitempty" json:"pin-group,omitempty"`
+
+	// Deny rejects the request with 403.
+	Deny bool `yaml:"deny,omitempty" json:"deny,omitempty"`
+
+	// Message is returned to the client when Deny is set.
+	Message string `yaml:"message,omitempty" json:"message,omitempty"`
+
+	// ForceAmp sends Amp provider requests to the Amp upstream even when a local provider
+	// serves the model.
+	ForceAmp bool `yaml:"force-amp,omitempty" json:"force-amp,omitempty"`
+}
+
+// SanitizeRoutingRules trims rule entries, names unnamed rules and drops rules without an action.
+func (cfg *Config) SanitizeRoutingRules() {
+	if cfg == nil || len(cfg.Routing.Rules) == 0 {
+		return
+	}
+	out := make([]RoutingRule, 0, len(cfg.Routing.Rules))
+	for i, rule := range cfg.Routing.Rules {
+		rule.Name = strings.TrimSpace(rule.Name)
+		if rule.Name == "" {
+			rule.Name = fmt.Sprintf("rule-%d", i+1)
+		}
+		match := &rule.Match
+		match.Model = strings.TrimSpace(match.Model)
+		match.Path = strings.TrimSpace(match.Path)
+		if len(match.Headers) > 0 {
+			headers := make(map[string]string, len(match.Headers))
+			for name, pattern := range match.Headers {
+				if name = strings.TrimSpace(name); name != "" {
+					headers[name] = strings.TrimSpace(pattern)
+				}
+			}
+			match.Headers = headers
+		}
+		match.APIKeys = trimNonEmpty(match.APIKeys)
+		match.Tags = trimNonEmpty(match.Tags)
+
+		action := &rule.Action
+		action.MapModel = strings.TrimSpace(action.MapModel)
+		action.PinProvider = strings.ToLower(strings.TrimSpace(action.PinProvider))
+		action.PinCredentials = trimNonEmpty(action.PinCredentials)
+		action.PinGroup = strings.TrimSpace(action.PinGroup)
+		action.Message = strings.TrimSpace(action.Message)
+		if !action.Deny && action.MapModel == "" && action.PinProvider == "" && len(action.PinCredentials) == 0 && action.PinGroup == "" && !action.ForceAmp {
+			continue
+		}
+		out = append(out, rule)
+	}
+	cfg.Routing.Rules = out
+}
+
+func trimNonEmpty(values []string) []string {
+	if len(values) == 0 {
+		return nil
+	}
+	out := make([]string, 0, len(values))
+	for _, value := range values {
+		if value = strings.TrimSpace(value); value != "" {
+			out = append(out, value)
+		}
+	}
+	return out
+}
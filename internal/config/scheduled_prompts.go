@@ -0,0 +1,98 @@
+package config
+
+import (
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// DefaultScheduledPromptTimeoutSeconds bounds one run of a scheduled prompt.
+const DefaultScheduledPromptTimeoutSeconds = 600
+
+// ScheduledPromptsConfig runs configured prompts on a schedule through the provider pool,
+// e.g. to generate a nightly report, and delivers each answer to a webhook and/or a file.
+type ScheduledPromptsConfig struct {
+	// Enabled turns the scheduler on.
+	Enabled bool `yaml:"enabled" json:"enabled"`
+
+	// Dir is where relative output files are written. When empty, a "scheduled-prompts"
+	// directory next to the logs directory is used.
+	Dir string `yaml:"dir,omitempty" json:"dir,omitempty"`
+
+	// TimeoutSeconds bounds each run. Defaults to 600.
+	TimeoutSeconds int `yaml:"timeout-seconds,omitempty" json:"timeout-seconds,omitempty"`
+
+	// Prompts are the scheduled prompts.
+	Prompts []ScheduledPrompt `yaml:"prompts,omitempty" json:"prompts,omitempty"`
+}
+
+// ScheduledPrompt is one prompt run on a schedule. Prompt, System and File may contain the
+// {date} (2006-01-02) and {datetime} (20060102-150405) placeholders, expanded in UTC at
+// run time.
+type ScheduledPrompt struct {
+	// Name identifies the prompt in logs, webhook payloads and the management API.
+	Name string `yaml:"name" json:"name"`
+
+	// Schedule is a five-field cron expression (minute hour day-of-month month
+	// day-of-week), a macro such as @daily, or "@every <duration>".
+	Schedule string `yaml:"schedule" json:"schedule"`
+
+	// Model is the model to request; any model or alias served by the proxy.
+	Model string `yaml:"model" json:"model"`
+
+	// System is an optional system message.
+	System string `yaml:"system,omitempty" json:"system,omitempty"`
+
+	// Prompt is the user message.
+	Prompt string `yaml:"prompt" json:"prompt"`
+
+	// MaxTokens bounds the answer when set.
+	MaxTokens int `yaml:"max-tokens,omitempty" json:"max-tokens,omitempty"`
+
+	// WebhookURL receives a JSON POST with the answer of every run.
+	WebhookURL string `yaml:"webhook-url,omitempty" json:"webhook-url,omitempty"`
+
+	// File is where the answer of every run is written; relative paths are resolved
+	// against Dir.
+	File string `yaml:"file,omitempty" json:"file,omitempty"`
+}
+
+// SanitizeScheduledPrompts trims scheduled prompts, drops incomplete or duplicate ones and
+// applies defaults.
+func (cfg *Config) SanitizeScheduledPrompts() {
+	if cfg == nil {
+		return
+	}
+	sp := &cfg.ScheduledPrompts
+	sp.Dir = strings.TrimSpace(sp.Dir)
+	if sp.TimeoutSeconds <= 0 {
+		sp.TimeoutSeconds = DefaultScheduledPromptTimeoutSeconds
+	}
+	seen := make(map[string]bool, len(sp.Prompts))
+	prompts := make([]ScheduledPrompt, 0, len(sp.Prompts))
+	for _, prompt := range sp.Prompts {
+		prompt.Name = strings.TrimSpace(prompt.Name)
+		prompt.Schedule = strings.TrimSpace(prompt.Schedule)
+		prompt.Model = strings.TrimSpace(prompt.Model)
+		prompt.Prompt = strings.TrimSpace(prompt.Prompt)
+		prompt.System = strings.TrimSpace(prompt.System)
+		prompt.WebhookURL = strings.TrimSpace(prompt.WebhookURL)
+		prompt.File = strings.TrimSpace(prompt.File)
+		if prompt.MaxTokens < 0 {
+			prompt.MaxTokens = 0
+		}
+		switch {
+		case prompt.Name == "" || prompt.Schedule == "" || prompt.Model == "" || prompt.Prompt == "":
+			log.Warnf("scheduled-prompts: dropping prompt %q without name, schedule, model or prompt", prompt.Name)
+			continue
+		case seen[prompt.Name]:
+			log.Warnf("scheduled-prompts: dropping duplicate prompt %q", prompt.Name)
+			continue
+		case prompt.WebhookURL == "" && prompt.File == "":
+			log.Warnf("scheduled-prompts: prompt %q has no webhook-url or file; its answers are only logged", prompt.Name)
+		}
+		seen[prompt.Name] = true
+		prompts = append(prompts, prompt)
+	}
+	sp.Prompts = prompts
+}
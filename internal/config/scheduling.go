@@ -0,0 +1,88 @@
+package config
+
+import "strings"
+
+// Priority classes for upstream request scheduling.
+const (
+	PriorityLow    = "low"
+	PriorityNormal = "normal"
+	PriorityHigh   = "high"
+)
+
+// SchedulingConfig limits concurrent upstream requests per provider and orders waiting
+// requests by priority class, so interactive traffic is served before background batches
+// once a provider is saturated.
+type SchedulingConfig struct {
+	// ProviderConcurrency caps in-flight upstream requests per provider key (e.g. "claude").
+	// Providers without an entry are not limited.
+	ProviderConcurrency map[string]int `yaml:"provider-concurrency,omitempty" json:"provider-concurrency,omitempty"`
+
+	// APIKeyPriorities assigns a priority class to client API keys. It takes precedence over
+	// the X-CLIProxy-Priority request header.
+	APIKeyPriorities map[string]string `yaml:"api-key-priorities,omitempty" json:"api-key-priorities,omitempty"`
+
+	// DefaultPriority applies when neither the API key nor the header sets one. Defaults to "normal".
+	DefaultPriority string `yaml:"default-priority,omitempty" json:"default-priority,omitempty"`
+}
+
+// NormalizePriority returns the priority class named by value, or "" when it names none.
+func NormalizePriority(value string) string {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case PriorityLow, "background", "batch":
+		return PriorityLow
+	case PriorityNormal:
+		return PriorityNormal
+	case PriorityHigh, "interactive":
+		return PriorityHigh
+	}
+	return ""
+}
+
+// PriorityFor resolves the priority class of a request from its client API key and its
+// X-CLIProxy-Priority header value.
+func (s SchedulingConfig) PriorityFor(apiKey, header string) string {
+	if priority := NormalizePriority(s.APIKeyPriorities[apiKey]); priority != "" && apiKey != "" {
+		return priority
+	}
+	if priority := NormalizePriority(header); priority != "" {
+		return priority
+	}
+	if priority := NormalizePriority(s.DefaultPriority); priority != "" {
+		return priority
+	}
+	return PriorityNormal
+}
+
+// SanitizeScheduling drops invalid concurrency limits and priority mappings.
+func (cfg *Config) SanitizeScheduling() {
+	if cfg == nil {
+		return
+	}
+	s := &cfg.Scheduling
+	if len(s.ProviderConcurrency) > 0 {
+		limits := make(map[string]int, len(s.ProviderConcurrency))
+		for provider, limit := range s.ProviderConcurrency {
+			provider = strings.ToLower(strings.TrimSpace(provider))
+			if provider == "" || limit <= 0 {
+				continue
+			}
+			limits[provider] = limit
+		}
+		s.ProviderConcurrency = limits
+	}
+	if len(s.APIKeyPriorities) > 0 {
+		priorities := make(map[string]string, len(s.APIKeyPriorities))
+		for key, priority := range s.APIKeyPriorities {
+			key = strings.TrimSpace(key)
+			if priority = NormalizePriority(priority); key == "" || priority == "" {
+				continue
+			}
+			priorities[key] = priority
+		}
+		s.APIKeyPriorities = priorities
+	}
+	s.DefaultPriority = NormalizePriority(s.DefaultPriority)
+	if s.DefaultPriority == "" {
+		s.DefaultPriority = PriorityNormal
+	}
+}
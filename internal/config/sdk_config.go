@@ -29,6 +29,57 @@ type SDKConfig struct {
 	// NonStreamKeepAliveInterval controls how often blank lines are emitted for non-streaming responses.
 	// <= 0 disables keep-alives. Value is in seconds.
 	NonStreamKeepAliveInterval int `yaml:"nonstream-keepalive-interval,omitempty" json:"nonstream-keepalive-interval,omitempty"`
+
+	// Idempotency configures replay of non-streaming responses for retried Idempotency-Key requests.
+	Idempotency IdempotencyConfig `yaml:"idempotency,omitempty" json:"idempotency,omitempty"`
+
+	// Scheduling configures per-provider concurrency limits and request priority classes.
+	Scheduling SchedulingConfig `yaml:"scheduling,omitempty" json:"scheduling,omitempty"`
+
+	// StreamThrottle caps the rate of streamed responses per client API key or priority class.
+	StreamThrottle StreamThrottleConfig `yaml:"stream-throttle,omitempty" json:"stream-throttle,omitempty"`
+
+	// SpeculativeRacing lists models whose requests are raced across several target models.
+	SpeculativeRacing []RaceRule `yaml:"speculative-racing,omitempty" json:"speculative-racing,omitempty"`
+
+	// ContextFit reroutes prompts too large for the requested model's context window to a
+	// model with a larger one.
+	ContextFit ContextFitConfig `yaml:"context-fit,omitempty" json:"context-fit,omitempty"`
+
+	// RefusalFallback retries requests refused on content-policy grounds on an alternate model.
+	RefusalFallback RefusalFallbackConfig `yaml:"refusal-fallback,omitempty" json:"refusal-fallback,omitempty"`
+
+	// FanOut configures the /v1/fanout model comparison endpoint.
+	FanOut FanOutConfig `yaml:"fanout,omitempty" json:"fanout,omitempty"`
+
+	// OutputGuardrails scans generated content for configured patterns and redacts or
+	// annotates matches.
+	OutputGuardrails OutputGuardrailConfig `yaml:"output-guardrails,omitempty" json:"output-guardrails,omitempty"`
+
+	// SemanticCache answers non-streaming requests whose prompts are near duplicates of
+	// recent ones with the cached response.
+	SemanticCache SemanticCacheConfig `yaml:"semantic-cache,omitempty" json:"semantic-cache,omitempty"`
+
+	// PromptTemplates are named prompts clients render and run through
+	// /v1/prompt-templates/completions.
+	PromptTemplates []PromptTemplate `yaml:"prompt-templates,omitempty" json:"prompt-templates,omitempty"`
+
+	// MCPTools offers the tools of upstream MCP servers to models and executes their calls.
+	MCPTools MCPToolsConfig `yaml:"mcp-tools,omitempty" json:"mcp-tools,omitempty"`
+
+	// Recording stores upstream responses and replays them for offline development.
+	Recording RecordingConfig `yaml:"recording,omitempty" json:"recording,omitempty"`
+
+	// ClientProfiles tune streaming, retries and model naming for agent tools such as Aider
+	// and OpenHands, per client API key.
+	ClientProfiles []ClientProfile `yaml:"client-profiles,omitempty" json:"client-profiles,omitempty"`
+
+	// DefaultModels name the model served to requests without one, per client API key.
+	DefaultModels DefaultModelConfig `yaml:"default-models,omitempty" json:"default-models,omitempty"`
+
+	// UnknownThinkingSuffix is how model names with an unrecognized thinking suffix are
+	// handled: "passthrough" (default), "strip", "reject" or "nearest".
+	UnknownThinkingSuffix string `yaml:"unknown-thinking-suffix,omitempty" json:"unknown-thinking-suffix,omitempty"`
 }
 
 // StreamingConfig holds server streaming behavior configuration.
@@ -41,6 +92,10 @@ type StreamingConfig struct {
 	// to allow auth rotation / transient recovery.
 	// <= 0 disables bootstrap retries. Default is 0.
 	BootstrapRetries int `yaml:"bootstrap-retries,omitempty" json:"bootstrap-retries,omitempty"`
+
+	// InjectUsage estimates token usage for streams the provider reported none for, appends
+	// it to the stream in the client's format and records it in the usage statistics.
+	InjectUsage bool `yaml:"inject-usage,omitempty" json:"inject-usage,omitempty"`
 }
 
 // AccessConfig groups request authentication providers.
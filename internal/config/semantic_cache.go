@@ -0,0 +1,81 @@
+package config
+
+import "strings"
+
+const (
+	// DefaultSemanticCacheThreshold is the cosine similarity a cached prompt needs to be served.
+	DefaultSemanticCacheThreshold = 0.95
+	// DefaultSemanticCacheTTLSeconds is how long cached responses are served when ttl-seconds is unset.
+	DefaultSemanticCacheTTLSeconds = 3600
+	// DefaultSemanticCacheMaxEntries bounds the semantic cache when max-entries is unset.
+	DefaultSemanticCacheMaxEntries = 1000
+	// DefaultSemanticCacheLookupTimeoutMS is how long a request waits for its prompt
+	// embedding when lookup-timeout-ms is unset.
+	DefaultSemanticCacheLookupTimeoutMS = 300
+)
+
+// SemanticCacheConfig controls the semantic response cache. Prompts of non-streaming
+// requests are embedded through an OpenAI-compatible embeddings endpoint, and a request
+// whose prompt is similar enough to a cached one, with otherwise identical parameters, is
+// answered with the cached response. Enabled when EmbeddingURL and EmbeddingModel are set.
+type SemanticCacheConfig struct {
+	// EmbeddingURL is the embeddings endpoint, e.g. https://api.openai.com/v1/embeddings.
+	EmbeddingURL string `yaml:"embedding-url,omitempty" json:"embedding-url,omitempty"`
+
+	// EmbeddingModel is the model sent to the embeddings endpoint.
+	EmbeddingModel string `yaml:"embedding-model,omitempty" json:"embedding-model,omitempty"`
+
+	// APIKey authenticates against the embeddings endpoint as a bearer token.
+	APIKey string `yaml:"api-key,omitempty" json:"api-key,omitempty"`
+
+	// Threshold is the minimum cosine similarity of a hit, in (0, 1]. Defaults to 0.95.
+	Threshold float64 `yaml:"threshold,omitempty" json:"threshold,omitempty"`
+
+	// TTLSeconds is how long a response stays cached. Defaults to 3600.
+	TTLSeconds int `yaml:"ttl-seconds,omitempty" json:"ttl-seconds,omitempty"`
+
+	// MaxEntries caps the number of cached responses; the oldest are evicted first.
+	MaxEntries int `yaml:"max-entries,omitempty" json:"max-entries,omitempty"`
+
+	// Models restricts the cache to these model name patterns ('*' wildcard). Empty caches
+	// every model.
+	Models []string `yaml:"models,omitempty" json:"models,omitempty"`
+
+	// LookupTimeoutMS is how long a request waits for the embedding of its prompt before it
+	// is sent upstream without a lookup. The response is still cached once the embedding
+	// arrives. Defaults to 300.
+	LookupTimeoutMS int `yaml:"lookup-timeout-ms,omitempty" json:"lookup-timeout-ms,omitempty"`
+
+	// Shared serves cached responses across client API keys. By default each key only
+	// sees responses cached for its own requests.
+	Shared bool `yaml:"shared,omitempty" json:"shared,omitempty"`
+}
+
+// Enabled reports whether the semantic cache has an embedding provider.
+func (c SemanticCacheConfig) Enabled() bool {
+	return c.EmbeddingURL != "" && c.EmbeddingModel != ""
+}
+
+// SanitizeSemanticCache trims the semantic cache settings and applies defaults.
+func (cfg *Config) SanitizeSemanticCache() {
+	if cfg == nil {
+		return
+	}
+	c := &cfg.SemanticCache
+	c.EmbeddingURL = strings.TrimSpace(c.EmbeddingURL)
+	c.EmbeddingModel = strings.TrimSpace(c.EmbeddingModel)
+	c.APIKey = strings.TrimSpace(c.APIKey)
+	c.Models = trimNonEmpty(c.Models)
+	if c.Threshold <= 0 || c.Threshold > 1 {
+		c.Threshold = DefaultSemanticCacheThreshold
+	}
+	if c.TTLSeconds <= 0 {
+		c.TTLSeconds = DefaultSemanticCacheTTLSeconds
+	}
+	if c.MaxEntries <= 0 {
+		c.MaxEntries = DefaultSemanticCacheMaxEntries
+	}
+	if c.LookupTimeoutMS <= 0 {
+		c.LookupTimeoutMS = DefaultSemanticCacheLookupTimeoutMS
+	}
+}
@@ -0,0 +1,83 @@
+package config
+
+import (
+	"os"
+	"strings"
+)
+
+const (
+	// SharedStateBackendRedis stores shared state in Redis.
+	SharedStateBackendRedis = "redis"
+	// SharedStateBackendPostgres stores shared state in PostgreSQL.
+	SharedStateBackendPostgres = "postgres"
+
+	// DefaultSharedStateKeyPrefix namespaces keys written to the shared backend.
+	DefaultSharedStateKeyPrefix = "cliproxy:"
+	// DefaultSharedStateSyncIntervalSeconds is how often remote cooldowns are pulled.
+	DefaultSharedStateSyncIntervalSeconds = 2
+	// DefaultCredentialLeaseSeconds is how long a credential lease survives without renewal.
+	DefaultCredentialLeaseSeconds = 30
+)
+
+// SharedStateConfig enables high-availability mode: several proxy instances behind a load
+// balancer share quota counters, credential cooldowns and refresh locks through one backend.
+type SharedStateConfig struct {
+	// Backend selects the shared store: "redis" or "postgres". Empty disables shared state.
+	Backend string `yaml:"backend,omitempty" json:"backend,omitempty"`
+
+	// RedisURL is the Redis connection URL (e.g. "redis://:password@host:6379/0").
+	RedisURL string `yaml:"redis-url,omitempty" json:"redis-url,omitempty"`
+
+	// PostgresDSN is the PostgreSQL connection string.
+	PostgresDSN string `yaml:"postgres-dsn,omitempty" json:"postgres-dsn,omitempty"`
+
+	// KeyPrefix namespaces keys (and the Postgres table names) so clusters can share a backend.
+	KeyPrefix string `yaml:"key-prefix,omitempty" json:"key-prefix,omitempty"`
+
+	// InstanceID identifies this instance as lock owner. Defaults to the host name.
+	InstanceID string `yaml:"instance-id,omitempty" json:"instance-id,omitempty"`
+
+	// SyncIntervalSeconds controls how often remote credential cooldowns are pulled.
+	SyncIntervalSeconds int `yaml:"sync-interval-seconds,omitempty" json:"sync-interval-seconds,omitempty"`
+
+	// ShardCredentials makes each instance lease a disjoint subset of the OAuth credentials so
+	// one account is never used from several instances at the same time.
+	ShardCredentials bool `yaml:"shard-credentials,omitempty" json:"shard-credentials,omitempty"`
+
+	// LeaseSeconds is the credential lease lifetime; leases are renewed on every sync.
+	LeaseSeconds int `yaml:"lease-seconds,omitempty" json:"lease-seconds,omitempty"`
+}
+
+// SanitizeSharedState normalizes the shared state settings and disables unknown backends.
+func (cfg *Config) SanitizeSharedState() {
+	if cfg == nil {
+		return
+	}
+	ss := &cfg.SharedState
+	ss.Backend = strings.ToLower(strings.TrimSpace(ss.Backend))
+	if ss.Backend != SharedStateBackendRedis && ss.Backend != SharedStateBackendPostgres {
+		ss.Backend = ""
+	}
+	ss.RedisURL = strings.TrimSpace(ss.RedisURL)
+	ss.PostgresDSN = strings.TrimSpace(ss.PostgresDSN)
+	ss.KeyPrefix = strings.TrimSpace(ss.KeyPrefix)
+	if ss.KeyPrefix == "" {
+		ss.KeyPrefix = DefaultSharedStateKeyPrefix
+	}
+	ss.InstanceID = strings.TrimSpace(ss.InstanceID)
+	if ss.InstanceID == "" {
+		if host, err := os.Hostname(); err == nil {
+			ss.InstanceID = host
+		}
+	}
+	if ss.SyncIntervalSeconds <= 0 {
+		ss.SyncIntervalSeconds = DefaultSharedStateSyncIntervalSeconds
+	}
+	if ss.LeaseSeconds <= 0 {
+		ss.LeaseSeconds = DefaultCredentialLeaseSeconds
+	}
+	// A lease must outlive several sync rounds or it would lapse between renewals.
+	if minLease := 3 * ss.SyncIntervalSeconds; ss.LeaseSeconds < minLease {
+		ss.LeaseSeconds = minLease
+	}
+}
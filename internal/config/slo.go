@@ -0,0 +1,130 @@
+package config
+
+import (
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// SLO rule metrics.
+const (
+	// SLOMetricLatency is a latency percentile in milliseconds, to the first byte for streams.
+	SLOMetricLatency = "latency"
+	// SLOMetricErrorRate is the share of failed requests, from 0 to 1.
+	SLOMetricErrorRate = "error-rate"
+	// SLOMetricOutputTokens is a percentile of the response size in output tokens.
+	SLOMetricOutputTokens = "output-tokens"
+)
+
+// Defaults of the SLO rules.
+const (
+	DefaultSLOWindowSeconds = 300
+	DefaultSLOPercentile    = 95
+	DefaultSLOMinRequests   = 10
+)
+
+// SLOConfig configures service level objectives evaluated over sliding windows of the
+// usage records. A rule breaches when its metric exceeds the threshold; breaches and
+// recoveries are logged and posted to the webhook.
+type SLOConfig struct {
+	// WebhookURL receives a JSON POST when a rule starts or stops breaching.
+	WebhookURL string `yaml:"webhook-url,omitempty" json:"webhook-url,omitempty"`
+
+	// Rules lists the objectives.
+	Rules []SLORule `yaml:"rules,omitempty" json:"rules,omitempty"`
+}
+
+// SLORule is one objective over the requests matching its provider and models.
+type SLORule struct {
+	// Name identifies the rule in alerts and metrics. Defaults to the metric and the scope.
+	Name string `yaml:"name,omitempty" json:"name,omitempty"`
+
+	// Metric is "latency", "error-rate" or "output-tokens".
+	Metric string `yaml:"metric" json:"metric"`
+
+	// Provider narrows the rule to one provider key; empty matches every provider.
+	Provider string `yaml:"provider,omitempty" json:"provider,omitempty"`
+
+	// Models narrows the rule to matching models ('*' wildcard).
+	Models []string `yaml:"models,omitempty" json:"models,omitempty"`
+
+	// Percentile of the latency and output-tokens metrics. Defaults to 95.
+	Percentile float64 `yaml:"percentile,omitempty" json:"percentile,omitempty"`
+
+	// Threshold is the highest acceptable value: milliseconds for latency, a ratio for
+	// error-rate and tokens for output-tokens.
+	Threshold float64 `yaml:"threshold" json:"threshold"`
+
+	// WindowSeconds is the sliding window the metric is computed over. Defaults to 300.
+	WindowSeconds int `yaml:"window-seconds,omitempty" json:"window-seconds,omitempty"`
+
+	// MinRequests is the number of requests in the window below which the rule is not
+	// evaluated. Defaults to 10.
+	MinRequests int `yaml:"min-requests,omitempty" json:"min-requests,omitempty"`
+}
+
+// SanitizeSLO normalizes the SLO rules and drops ones without a known metric or a
+// threshold.
+func (cfg *Config) SanitizeSLO() {
+	if cfg == nil {
+		return
+	}
+	cfg.SLO.WebhookURL = strings.TrimSpace(cfg.SLO.WebhookURL)
+	if len(cfg.SLO.Rules) == 0 {
+		return
+	}
+	out := make([]SLORule, 0, len(cfg.SLO.Rules))
+	seen := make(map[string]struct{}, len(cfg.SLO.Rules))
+	for _, rule := range cfg.SLO.Rules {
+		rule.Metric = strings.ToLower(strings.TrimSpace(rule.Metric))
+		switch rule.Metric {
+		case SLOMetricLatency, SLOMetricErrorRate, SLOMetricOutputTokens:
+		default:
+			log.Warnf("slo: ignoring rule %q with unknown metric %q", rule.Name, rule.Metric)
+			continue
+		}
+		if rule.Threshold <= 0 {
+			log.Warnf("slo: ignoring rule %q without a threshold", rule.Name)
+			continue
+		}
+		rule.Provider = strings.ToLower(strings.TrimSpace(rule.Provider))
+		if rule.Provider == "*" {
+			rule.Provider = ""
+		}
+		rule.Models = trimList(rule.Models)
+		if rule.Name = strings.TrimSpace(rule.Name); rule.Name == "" {
+			rule.Name = defaultSLORuleName(rule)
+		}
+		if _, dup := seen[rule.Name]; dup {
+			log.Warnf("slo: ignoring duplicate rule %q", rule.Name)
+			continue
+		}
+		seen[rule.Name] = struct{}{}
+		if rule.Percentile <= 0 || rule.Percentile > 100 {
+			rule.Percentile = DefaultSLOPercentile
+		}
+		if rule.WindowSeconds <= 0 {
+			rule.WindowSeconds = DefaultSLOWindowSeconds
+		}
+		if rule.MinRequests <= 0 {
+			rule.MinRequests = DefaultSLOMinRequests
+		}
+		out = append(out, rule)
+	}
+	cfg.SLO.Rules = out
+}
+
+// defaultSLORuleName names a rule after its metric and scope, e.g. "latency:claude/claude-*".
+func defaultSLORuleName(rule SLORule) string {
+	scope := rule.Provider
+	if len(rule.Models) > 0 {
+		if scope != "" {
+			scope += "/"
+		}
+		scope += strings.Join(rule.Models, ",")
+	}
+	if scope == "" {
+		return rule.Metric
+	}
+	return rule.Metric + ":" + scope
+}
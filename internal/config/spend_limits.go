@@ -0,0 +1,74 @@
+package config
+
+import "strings"
+
+const (
+	// SpendLimitPolicyFallthrough excludes the exhausted credential and lets routing continue
+	// to other credentials, model mappings, or the Amp upstream.
+	SpendLimitPolicyFallthrough = "fallthrough"
+	// SpendLimitPolicyReject rejects requests with 429 once every matching credential is exhausted.
+	SpendLimitPolicyReject = "reject"
+)
+
+// SpendLimitConfig configures monthly token/cost ceilings for provider credentials.
+type SpendLimitConfig struct {
+	// Policy selects what happens after a ceiling is reached: "fallthrough" (default) or "reject".
+	Policy string `yaml:"policy,omitempty" json:"policy,omitempty"`
+
+	// WebhookURL receives a JSON POST the first time a credential crosses its ceiling in a month.
+	WebhookURL string `yaml:"webhook-url,omitempty" json:"webhook-url,omitempty"`
+
+	// Limits lists the per-credential ceilings.
+	Limits []SpendLimit `yaml:"limits,omitempty" json:"limits,omitempty"`
+}
+
+// SpendLimit is a monthly ceiling applied to each matching provider credential.
+type SpendLimit struct {
+	// Provider is the credential provider key (e.g. "claude", "codex", "gemini-cli").
+	Provider string `yaml:"provider" json:"provider"`
+
+	// AuthID optionally narrows the limit to a single credential (auth ID or file name).
+	// When empty, every credential of the provider gets its own ceiling.
+	AuthID string `yaml:"auth-id,omitempty" json:"auth-id,omitempty"`
+
+	// MonthlyTokens caps total tokens per calendar month (UTC), or per period of the
+	// credential's quota-exceeded.reset-schedules entry. 0 disables the token ceiling.
+	MonthlyTokens int64 `yaml:"monthly-tokens,omitempty" json:"monthly-tokens,omitempty"`
+
+	// MonthlyCost caps the estimated cost per period like MonthlyTokens. 0 disables the cost ceiling.
+	MonthlyCost float64 `yaml:"monthly-cost,omitempty" json:"monthly-cost,omitempty"`
+
+	// InputCostPerMillion and OutputCostPerMillion price tokens for the cost ceiling. When
+	// both are 0 the model prices of the pricing section apply.
+	InputCostPerMillion  float64 `yaml:"input-cost-per-million,omitempty" json:"input-cost-per-million,omitempty"`
+	OutputCostPerMillion float64 `yaml:"output-cost-per-million,omitempty" json:"output-cost-per-million,omitempty"`
+}
+
+// SanitizeSpendLimits normalizes spend limit entries and drops ones without a provider or ceiling.
+func (cfg *Config) SanitizeSpendLimits() {
+	if cfg == nil {
+		return
+	}
+	sl := &cfg.SpendLimits
+	sl.Policy = strings.ToLower(strings.TrimSpace(sl.Policy))
+	if sl.Policy != SpendLimitPolicyReject {
+		sl.Policy = SpendLimitPolicyFallthrough
+	}
+	sl.WebhookURL = strings.TrimSpace(sl.WebhookURL)
+	if len(sl.Limits) == 0 {
+		return
+	}
+	out := make([]SpendLimit, 0, len(sl.Limits))
+	for _, limit := range sl.Limits {
+		limit.Provider = strings.ToLower(strings.TrimSpace(limit.Provider))
+		limit.AuthID = strings.TrimSpace(limit.AuthID)
+		if limit.Provider == "" {
+			continue
+		}
+		if limit.MonthlyTokens <= 0 && limit.MonthlyCost <= 0 {
+			continue
+		}
+		out = append(out, limit)
+	}
+	sl.Limits = out
+}
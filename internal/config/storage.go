@@ -0,0 +1,79 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	// StorageBackendFile keeps transcripts and usage history in local JSONL files.
+	StorageBackendFile = "file"
+	// StorageBackendSQLite keeps them in a SQLite database.
+	StorageBackendSQLite = "sqlite"
+	// StorageBackendPostgres keeps them in a PostgreSQL database.
+	StorageBackendPostgres = "postgres"
+
+	// DefaultStorageTable names the SQL table holding the stored records.
+	DefaultStorageTable = "cliproxy_records"
+	// DefaultUsageRetentionDays is the number of days persisted usage history is kept when unset.
+	DefaultUsageRetentionDays = 30
+)
+
+// StorageConfig selects the persistence layer of conversation transcripts and usage
+// statistics. The file backend needs nothing beyond the local disk; the SQL backends let
+// larger deployments keep that data in a shared database.
+type StorageConfig struct {
+	// Backend is "file" (default), "sqlite" or "postgres".
+	Backend string `yaml:"backend,omitempty" json:"backend,omitempty"`
+
+	// DSN is the database connection string of the SQL backends: a file path for sqlite,
+	// a postgres:// URL or key=value string for postgres.
+	DSN string `yaml:"dsn,omitempty" json:"dsn,omitempty"`
+
+	// Table names the SQL table holding the records. Default: cliproxy_records.
+	Table string `yaml:"table,omitempty" json:"table,omitempty"`
+
+	// PersistUsage mirrors every usage statistics request detail to the backend so the
+	// statistics survive restarts. Disabled by default.
+	PersistUsage bool `yaml:"persist-usage,omitempty" json:"persist-usage,omitempty"`
+
+	// UsageDir overrides where the file backend writes usage history.
+	// When empty, a "usage" directory next to the logs directory is used.
+	UsageDir string `yaml:"usage-dir,omitempty" json:"usage-dir,omitempty"`
+
+	// UsageRetentionDays removes persisted usage history older than this many days; the
+	// retained history is reloaded on start. Set to 0 to keep it forever.
+	UsageRetentionDays int `yaml:"usage-retention-days" json:"usage-retention-days"`
+}
+
+// SanitizeStorage normalizes storage backend settings and applies defaults. An unknown
+// backend is a configuration error rather than a silent switch to the file backend, which
+// would leave the configured database unused.
+func (cfg *Config) SanitizeStorage() error {
+	if cfg == nil {
+		return nil
+	}
+	s := &cfg.Storage
+	s.Backend = strings.ToLower(strings.TrimSpace(s.Backend))
+	switch s.Backend {
+	case StorageBackendFile, StorageBackendSQLite, StorageBackendPostgres:
+	case "postgresql", "pg":
+		s.Backend = StorageBackendPostgres
+	case "":
+		s.Backend = StorageBackendFile
+	case "sqlite3":
+		s.Backend = StorageBackendSQLite
+	default:
+		return fmt.Errorf("unsupported backend %q", s.Backend)
+	}
+	s.DSN = strings.TrimSpace(s.DSN)
+	s.Table = strings.TrimSpace(s.Table)
+	if s.Table == "" {
+		s.Table = DefaultStorageTable
+	}
+	s.UsageDir = strings.TrimSpace(s.UsageDir)
+	if s.UsageRetentionDays < 0 {
+		s.UsageRetentionDays = 0
+	}
+	return nil
+}
@@ -0,0 +1,16 @@
+package config
+
+import "testing"
+
+func TestSanitizeStorage(t *testing.T) {
+	for in, want := range map[string]string{"": StorageBackendFile, " SQLite ": StorageBackendSQLite, "sqlite3": StorageBackendSQLite, "pg": StorageBackendPostgres} {
+		cfg := &Config{Storage: StorageConfig{Backend: in}}
+		if err := cfg.SanitizeStorage(); err != nil || cfg.Storage.Backend != want {
+			t.Errorf("SanitizeStorage(%q) = %q, %v; want %q", in, cfg.Storage.Backend, err, want)
+		}
+	}
+	cfg := &Config{Storage: StorageConfig{Backend: "mysql"}}
+	if err := cfg.SanitizeStorage(); err == nil {
+		t.Errorf("SanitizeStorage accepted backend %q as %q", "mysql", cfg.Storage.Backend)
+	}
+}
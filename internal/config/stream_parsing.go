@@ -0,0 +1,32 @@
+package config
+
+import (
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// StreamParsingTolerant skips upstream stream chunks the translators do not recognize,
+	// warning once per event type.
+	StreamParsingTolerant = "tolerant"
+	// StreamParsingStrict fails a stream at its first unrecognized chunk.
+	StreamParsingStrict = "strict"
+)
+
+// SanitizeStreamParsing normalizes the stream parsing mode, defaulting to tolerant.
+func (cfg *Config) SanitizeStreamParsing() {
+	if cfg == nil {
+		return
+	}
+	mode := strings.ToLower(strings.TrimSpace(cfg.StreamParsing))
+	switch mode {
+	case StreamParsingTolerant, StreamParsingStrict:
+	case "":
+		mode = StreamParsingTolerant
+	default:
+		log.Warnf("stream-parsing: unknown mode %q, using %q", cfg.StreamParsing, StreamParsingTolerant)
+		mode = StreamParsingTolerant
+	}
+	cfg.StreamParsing = mode
+}
@@ -0,0 +1,47 @@
+package config
+
+const (
+	// DefaultStreamResumptionTTLSeconds is how long a finished stream stays resumable.
+	DefaultStreamResumptionTTLSeconds = 300
+	// DefaultStreamResumptionMaxBytes caps the events buffered for one stream.
+	DefaultStreamResumptionMaxBytes = 8 << 20
+	// DefaultStreamResumptionMaxStreams caps the number of buffered streams.
+	DefaultStreamResumptionMaxStreams = 256
+)
+
+// StreamResumptionConfig lets clients reconnect to interrupted streams. Every streamed
+// response carries a resumption token in the X-CLIProxy-Resume-Token header and keeps
+// running when the client disconnects; a request sending the token back within the TTL
+// replays the buffered events the client missed and then follows the live stream.
+type StreamResumptionConfig struct {
+	// Enable turns stream resumption on.
+	Enable bool `yaml:"enable" json:"enable"`
+
+	// TTLSeconds is how long a stream stays resumable after it finished. Defaults to 300.
+	TTLSeconds int `yaml:"ttl-seconds,omitempty" json:"ttl-seconds,omitempty"`
+
+	// MaxBufferBytes caps the events buffered per stream; larger streams are not resumable
+	// and are aborted when their client is gone. Defaults to 8 MiB.
+	MaxBufferBytes int `yaml:"max-buffer-bytes,omitempty" json:"max-buffer-bytes,omitempty"`
+
+	// MaxStreams caps the number of buffered streams; the oldest finished ones are evicted
+	// first. Defaults to 256.
+	MaxStreams int `yaml:"max-streams,omitempty" json:"max-streams,omitempty"`
+}
+
+// SanitizeStreamResumption applies the stream resumption defaults.
+func (cfg *Config) SanitizeStreamResumption() {
+	if cfg == nil {
+		return
+	}
+	sr := &cfg.StreamResumption
+	if sr.TTLSeconds <= 0 {
+		sr.TTLSeconds = DefaultStreamResumptionTTLSeconds
+	}
+	if sr.MaxBufferBytes <= 0 {
+		sr.MaxBufferBytes = DefaultStreamResumptionMaxBytes
+	}
+	if sr.MaxStreams <= 0 {
+		sr.MaxStreams = DefaultStreamResumptionMaxStreams
+	}
+}
@@ -0,0 +1,86 @@
+package config
+
+import "strings"
+
+// DefaultStreamThrottleBurstSeconds is how many seconds of the rate a stream may send at
+// once before it is paced.
+const DefaultStreamThrottleBurstSeconds = 1
+
+// StreamRate caps the rate of one streamed response. A zero field leaves that dimension
+// unlimited.
+type StreamRate struct {
+	// BytesPerSecond caps the bytes written to the client.
+	BytesPerSecond int `yaml:"bytes-per-second,omitempty" json:"bytes-per-second,omitempty"`
+
+	// TokensPerSecond caps the generated tokens, estimated from the streamed text.
+	TokensPerSecond int `yaml:"tokens-per-second,omitempty" json:"tokens-per-second,omitempty"`
+}
+
+// Limited reports whether r caps anything.
+func (r StreamRate) Limited() bool { return r.BytesPerSecond > 0 || r.TokensPerSecond > 0 }
+
+// StreamThrottleConfig paces streamed responses so one aggressive client cannot saturate the
+// uplink. The rate of a stream is the entry of its client API key, else the entry of its
+// scheduling priority class, else the global rate; an entry setting no limit exempts the
+// key or class.
+type StreamThrottleConfig struct {
+	StreamRate `yaml:",inline"`
+
+	// Priorities sets the rate per priority class ("low", "normal", "high").
+	Priorities map[string]StreamRate `yaml:"priorities,omitempty" json:"priorities,omitempty"`
+
+	// APIKeys sets the rate per client API key.
+	APIKeys map[string]StreamRate `yaml:"api-keys,omitempty" json:"api-keys,omitempty"`
+
+	// BurstSeconds is how many seconds of the rate may be sent at once. Defaults to 1.
+	BurstSeconds int `yaml:"burst-seconds,omitempty" json:"burst-seconds,omitempty"`
+}
+
+// RateFor returns the rate of a stream from apiKey at priority.
+func (c StreamThrottleConfig) RateFor(apiKey, priority string) StreamRate {
+	if rate, ok := c.APIKeys[apiKey]; ok && apiKey != "" {
+		return rate
+	}
+	if rate, ok := c.Priorities[NormalizePriority(priority)]; ok {
+		return rate
+	}
+	return c.StreamRate
+}
+
+// SanitizeStreamThrottle drops invalid rates and normalizes the priority classes.
+func (cfg *Config) SanitizeStreamThrottle() {
+	if cfg == nil {
+		return
+	}
+	t := &cfg.StreamThrottle
+	t.StreamRate = sanitizeStreamRate(t.StreamRate)
+	if len(t.Priorities) > 0 {
+		priorities := make(map[string]StreamRate, len(t.Priorities))
+		for priority, rate := range t.Priorities {
+			if priority = NormalizePriority(priority); priority == "" {
+				continue
+			}
+			priorities[priority] = sanitizeStreamRate(rate)
+		}
+		t.Priorities = priorities
+	}
+	if len(t.APIKeys) > 0 {
+		keys := make(map[string]StreamRate, len(t.APIKeys))
+		for key, rate := range t.APIKeys {
+			if key = strings.TrimSpace(key); key == "" {
+				continue
+			}
+			keys[key] = sanitizeStreamRate(rate)
+		}
+		t.APIKeys = keys
+	}
+	if t.BurstSeconds <= 0 {
+		t.BurstSeconds = DefaultStreamThrottleBurstSeconds
+	}
+}
+
+func sanitizeStreamRate(rate StreamRate) StreamRate {
+	rate.BytesPerSecond = max(rate.BytesPerSecond, 0)
+	rate.TokensPerSecond = max(rate.TokensPerSecond, 0)
+	return rate
+}
@@ -0,0 +1,94 @@
+package config
+
+import (
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// DefaultSyntheticProbeSchedule runs probes every 15 minutes.
+	DefaultSyntheticProbeSchedule = "*/15 * * * *"
+	// DefaultSyntheticProbePrompt is sent when a target sets no prompt.
+	DefaultSyntheticProbePrompt = "Reply with the single word: pong"
+	// DefaultSyntheticProbeMaxTokens bounds the reply of a probe.
+	DefaultSyntheticProbeMaxTokens = 16
+	// DefaultSyntheticProbeTimeoutSeconds bounds the duration of a probe.
+	DefaultSyntheticProbeTimeoutSeconds = 60
+)
+
+// SyntheticProbesConfig sends small test prompts to every credential of the listed
+// providers on a schedule. Results feed the credentials' health state like regular
+// requests, so a silently broken credential cools down before users are routed to it.
+type SyntheticProbesConfig struct {
+	// Enabled turns the probe scheduler on.
+	Enabled bool `yaml:"enabled" json:"enabled"`
+
+	// Schedule is the default schedule of targets: a five-field cron expression
+	// (minute hour day-of-month month day-of-week) or "@every <duration>".
+	// Defaults to every 15 minutes.
+	Schedule string `yaml:"schedule,omitempty" json:"schedule,omitempty"`
+
+	// Prompt is the default prompt sent by targets.
+	Prompt string `yaml:"prompt,omitempty" json:"prompt,omitempty"`
+
+	// MaxTokens bounds the reply of each probe. Defaults to 16.
+	MaxTokens int `yaml:"max-tokens,omitempty" json:"max-tokens,omitempty"`
+
+	// TimeoutSeconds bounds each probe. Defaults to 60.
+	TimeoutSeconds int `yaml:"timeout-seconds,omitempty" json:"timeout-seconds,omitempty"`
+
+	// Targets are the provider and model pairs to probe.
+	Targets []SyntheticProbeTarget `yaml:"targets,omitempty" json:"targets,omitempty"`
+}
+
+// SyntheticProbeTarget probes every enabled credential of Provider with Model.
+type SyntheticProbeTarget struct {
+	// Provider is the credential provider, e.g. gemini-cli, claude, codex or the name of
+	// an OpenAI compatibility provider.
+	Provider string `yaml:"provider" json:"provider"`
+
+	// Model is the model to request.
+	Model string `yaml:"model" json:"model"`
+
+	// Schedule overrides the default schedule for this target.
+	Schedule string `yaml:"schedule,omitempty" json:"schedule,omitempty"`
+
+	// Prompt overrides the default prompt for this target.
+	Prompt string `yaml:"prompt,omitempty" json:"prompt,omitempty"`
+}
+
+// SanitizeSyntheticProbes trims probe targets, drops incomplete ones and applies defaults.
+func (cfg *Config) SanitizeSyntheticProbes() {
+	if cfg == nil {
+		return
+	}
+	sp := &cfg.SyntheticProbes
+	sp.Schedule = strings.TrimSpace(sp.Schedule)
+	if sp.Schedule == "" {
+		sp.Schedule = DefaultSyntheticProbeSchedule
+	}
+	sp.Prompt = strings.TrimSpace(sp.Prompt)
+	if sp.Prompt == "" {
+		sp.Prompt = DefaultSyntheticProbePrompt
+	}
+	if sp.MaxTokens <= 0 {
+		sp.MaxTokens = DefaultSyntheticProbeMaxTokens
+	}
+	if sp.TimeoutSeconds <= 0 {
+		sp.TimeoutSeconds = DefaultSyntheticProbeTimeoutSeconds
+	}
+	targets := make([]SyntheticProbeTarget, 0, len(sp.Targets))
+	for _, target := range sp.Targets {
+		target.Provider = strings.ToLower(strings.TrimSpace(target.Provider))
+		target.Model = strings.TrimSpace(target.Model)
+		target.Schedule = strings.TrimSpace(target.Schedule)
+		target.Prompt = strings.TrimSpace(target.Prompt)
+		if target.Provider == "" || target.Model == "" {
+			log.Warnf("synthetic-probes: dropping target without provider or model")
+			continue
+		}
+		targets = append(targets, target)
+	}
+	sp.Targets = targets
+}
@@ -0,0 +1,38 @@
+package config
+
+// TemporaryKeysConfig lets clients mint short-lived API keys derived from their own key
+// via POST /v1/temporary-keys, for scripts and CI jobs. Temporary keys live in memory only
+// and end with the process.
+type TemporaryKeysConfig struct {
+	// Enable accepts temporary keys and serves the minting endpoint.
+	Enable bool `yaml:"enable" json:"enable"`
+
+	// DefaultTTLSeconds is the lifetime of keys minted without a ttl. Default: 3600.
+	DefaultTTLSeconds int `yaml:"default-ttl-seconds,omitempty" json:"default-ttl-seconds,omitempty"`
+
+	// MaxTTLSeconds caps the requested lifetime. Default: 86400.
+	MaxTTLSeconds int `yaml:"max-ttl-seconds,omitempty" json:"max-ttl-seconds,omitempty"`
+
+	// MaxPerKey caps the live temporary keys of one parent key. Default: 50.
+	MaxPerKey int `yaml:"max-per-key,omitempty" json:"max-per-key,omitempty"`
+}
+
+// SanitizeTemporaryKeys applies the temporary key defaults.
+func (cfg *Config) SanitizeTemporaryKeys() {
+	if cfg == nil {
+		return
+	}
+	t := &cfg.TemporaryKeys
+	if t.MaxTTLSeconds <= 0 {
+		t.MaxTTLSeconds = 86400
+	}
+	if t.DefaultTTLSeconds <= 0 {
+		t.DefaultTTLSeconds = 3600
+	}
+	if t.DefaultTTLSeconds > t.MaxTTLSeconds {
+		t.DefaultTTLSeconds = t.MaxTTLSeconds
+	}
+	if t.MaxPerKey <= 0 {
+		t.MaxPerKey = 50
+	}
+}
@@ -0,0 +1,37 @@
+package config
+
+import (
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Policies for model names whose thinking suffix is not recognized, e.g. "gpt-5(ultra)".
+const (
+	// UnknownSuffixPassthrough forwards the model name unchanged. This is the default.
+	UnknownSuffixPassthrough = "passthrough"
+	// UnknownSuffixStrip removes the suffix, logs a warning and serves the base model.
+	UnknownSuffixStrip = "strip"
+	// UnknownSuffixReject fails the request with an error listing the supported suffixes.
+	UnknownSuffixReject = "reject"
+	// UnknownSuffixNearest replaces the suffix with the closest supported one, e.g. "max"
+	// with "xhigh" or "16k" with "16384", and strips it when none is close.
+	UnknownSuffixNearest = "nearest"
+)
+
+// SanitizeUnknownThinkingSuffix normalizes the unknown thinking suffix policy.
+func (cfg *Config) SanitizeUnknownThinkingSuffix() {
+	if cfg == nil {
+		return
+	}
+	policy := strings.ToLower(strings.TrimSpace(cfg.UnknownThinkingSuffix))
+	switch policy {
+	case "", UnknownSuffixPassthrough:
+		policy = ""
+	case UnknownSuffixStrip, UnknownSuffixReject, UnknownSuffixNearest:
+	default:
+		log.Warnf("unknown-thinking-suffix: unknown policy %q, passing suffixes through", cfg.UnknownThinkingSuffix)
+		policy = ""
+	}
+	cfg.UnknownThinkingSuffix = policy
+}
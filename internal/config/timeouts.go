@@ -0,0 +1,87 @@
+package config
+
+import "strings"
+
+// DefaultConnectTimeoutSeconds bounds how long establishing an upstream connection may take
+// when no connect timeout is configured.
+const DefaultConnectTimeoutSeconds = 30
+
+// TimeoutPolicy holds upstream request timeouts in seconds. 0 inherits the value of the
+// enclosing level and a negative value disables the timeout at this level.
+type TimeoutPolicy struct {
+	// ConnectSeconds bounds dialing, proxy negotiation and the TLS handshake.
+	ConnectSeconds int `yaml:"connect-seconds,omitempty" json:"connect-seconds,omitempty"`
+
+	// FirstByteSeconds bounds the wait for the response headers once the request is sent.
+	FirstByteSeconds int `yaml:"first-byte-seconds,omitempty" json:"first-byte-seconds,omitempty"`
+
+	// TotalSeconds bounds the whole request, including reading a streamed body.
+	TotalSeconds int `yaml:"total-seconds,omitempty" json:"total-seconds,omitempty"`
+}
+
+// ModelTimeoutPolicy overrides the timeouts for models matching Name ('*' wildcards).
+type ModelTimeoutPolicy struct {
+	Name          string `yaml:"name" json:"name"`
+	TimeoutPolicy `yaml:",inline"`
+}
+
+// TimeoutConfig configures upstream timeouts. Levels apply from the global values through
+// the provider and the first matching model entry; clients can narrow them further with the
+// X-CLIProxy-Timeout request header.
+type TimeoutConfig struct {
+	TimeoutPolicy `yaml:",inline"`
+
+	// Providers overrides the global timeouts per provider key (e.g. "claude", "codex").
+	Providers map[string]TimeoutPolicy `yaml:"providers,omitempty" json:"providers,omitempty"`
+
+	// Models overrides the provider timeouts per model; the first matching entry applies.
+	Models []ModelTimeoutPolicy `yaml:"models,omitempty" json:"models,omitempty"`
+}
+
+// Merge returns p with every non-zero field of override applied.
+func (p TimeoutPolicy) Merge(override TimeoutPolicy) TimeoutPolicy {
+	if override.ConnectSeconds != 0 {
+		p.ConnectSeconds = override.ConnectSeconds
+	}
+	if override.FirstByteSeconds != 0 {
+		p.FirstByteSeconds = override.FirstByteSeconds
+	}
+	if override.TotalSeconds != 0 {
+		p.TotalSeconds = override.TotalSeconds
+	}
+	return p
+}
+
+// SanitizeTimeouts normalizes provider keys and model patterns and applies the default
+// connect timeout.
+func (cfg *Config) SanitizeTimeouts() {
+	if cfg == nil {
+		return
+	}
+	t := &cfg.Timeouts
+	if t.ConnectSeconds == 0 {
+		t.ConnectSeconds = DefaultConnectTimeoutSeconds
+	}
+	if len(t.Providers) > 0 {
+		providers := make(map[string]TimeoutPolicy, len(t.Providers))
+		for provider, policy := range t.Providers {
+			provider = strings.ToLower(strings.TrimSpace(provider))
+			if provider == "" {
+				continue
+			}
+			providers[provider] = policy
+		}
+		t.Providers = providers
+	}
+	if len(t.Models) > 0 {
+		models := make([]ModelTimeoutPolicy, 0, len(t.Models))
+		for _, entry := range t.Models {
+			entry.Name = strings.TrimSpace(entry.Name)
+			if entry.Name == "" {
+				continue
+			}
+			models = append(models, entry)
+		}
+		t.Models = models
+	}
+}
@@ -0,0 +1,64 @@
+package config
+
+import "strings"
+
+const (
+	// DefaultTranscriptRetentionDays is the number of days stored transcripts are kept when unset.
+	DefaultTranscriptRetentionDays = 30
+	// DefaultTranscriptMaxBodyBytes caps the captured request/response body size per exchange.
+	DefaultTranscriptMaxBodyBytes = 1 << 20
+)
+
+// TranscriptConfig controls the opt-in conversation transcript store.
+// When enabled, every proxied chat exchange is appended to a per-session JSONL file
+// so operators can later review or export what a client sent and received.
+type TranscriptConfig struct {
+	// Enable toggles transcript capture. Disabled by default.
+	Enable bool `yaml:"enable" json:"enable"`
+
+	// Dir overrides the directory where transcript files are written.
+	// When empty, a "transcripts" directory next to the logs directory is used.
+	Dir string `yaml:"dir,omitempty" json:"dir,omitempty"`
+
+	// RetentionDays removes sessions whose last activity is older than this many days.
+	// Set to 0 to keep transcripts forever.
+	RetentionDays int `yaml:"retention-days" json:"retention-days"`
+
+	// MaxSessions limits the number of stored sessions; the least recently active are removed first.
+	// Set to 0 to disable the limit.
+	MaxSessions int `yaml:"max-sessions" json:"max-sessions"`
+
+	// MaxBodyBytes truncates captured request and response bodies beyond this size.
+	MaxBodyBytes int `yaml:"max-body-bytes" json:"max-body-bytes"`
+
+	// SessionHeader names the request header used to group exchanges into sessions.
+	// Requests without the header are grouped per client API key. Default: X-Session-Id.
+	SessionHeader string `yaml:"session-header,omitempty" json:"session-header,omitempty"`
+
+	// StreamTee writes streamed responses to disk as their chunks flow instead of only
+	// after completion, so the partial output of a stream cut short by a crash is recovered
+	// as an incomplete exchange when the store is next enabled.
+	StreamTee bool `yaml:"stream-tee,omitempty" json:"stream-tee,omitempty"`
+}
+
+// SanitizeTranscripts normalizes transcript store settings and applies defaults.
+func (cfg *Config) SanitizeTranscripts() {
+	if cfg == nil {
+		return
+	}
+	t := &cfg.Transcripts
+	t.Dir = strings.TrimSpace(t.Dir)
+	t.SessionHeader = strings.TrimSpace(t.SessionHeader)
+	if t.SessionHeader == "" {
+		t.SessionHeader = "X-Session-Id"
+	}
+	if t.RetentionDays < 0 {
+		t.RetentionDays = 0
+	}
+	if t.MaxSessions < 0 {
+		t.MaxSessions = 0
+	}
+	if t.MaxBodyBytes <= 0 {
+		t.MaxBodyBytes = DefaultTranscriptMaxBodyBytes
+	}
+}
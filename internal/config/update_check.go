@@ -0,0 +1,36 @@
+package config
+
+import "strings"
+
+// DefaultUpdateCheckIntervalHours is how often the opt-in update checker polls for releases.
+const DefaultUpdateCheckIntervalHours = 24
+
+// UpdateCheckConfig controls the release checker and the self-update command.
+type UpdateCheckConfig struct {
+	// Enabled logs a notice at startup and periodically while a newer release is available.
+	Enabled bool `yaml:"enabled" json:"enabled"`
+
+	// IntervalHours controls how often the checker polls for a new release.
+	IntervalHours int `yaml:"interval-hours,omitempty" json:"interval-hours,omitempty"`
+
+	// Repository overrides the GitHub repository releases are fetched from
+	// (e.g. "https://github.com/router-for-me/CLIProxyAPI").
+	Repository string `yaml:"repository,omitempty" json:"repository,omitempty"`
+
+	// PublicKey is a base64 ed25519 public key. When set, self-update requires a
+	// checksums.txt.sig release asset signed with the matching private key.
+	PublicKey string `yaml:"public-key,omitempty" json:"public-key,omitempty"`
+}
+
+// SanitizeUpdateCheck trims the update checker settings and applies defaults.
+func (cfg *Config) SanitizeUpdateCheck() {
+	if cfg == nil {
+		return
+	}
+	uc := &cfg.UpdateCheck
+	if uc.IntervalHours <= 0 {
+		uc.IntervalHours = DefaultUpdateCheckIntervalHours
+	}
+	uc.Repository = strings.TrimSpace(uc.Repository)
+	uc.PublicKey = strings.TrimSpace(uc.PublicKey)
+}
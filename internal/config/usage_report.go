@@ -0,0 +1,77 @@
+package config
+
+import "strings"
+
+// Usage report formats.
+const (
+	UsageReportJSON = "json"
+	UsageReportCSV  = "csv"
+	UsageReportHTML = "html"
+)
+
+// UsageReportConfig controls the monthly usage and cost report. Once a calendar month
+// (UTC) ends, its requests, tokens and costs per client API key, project and provider
+// are written to Dir and optionally emailed.
+type UsageReportConfig struct {
+	// Enable toggles report collection. Disabled by default.
+	Enable bool `yaml:"enable" json:"enable"`
+
+	// Dir overrides where reports are stored. When empty, a "usage-reports" directory next
+	// to the logs directory is used.
+	Dir string `yaml:"dir,omitempty" json:"dir,omitempty"`
+
+	// Formats lists the files written per month: json, csv and/or html. Defaults to all.
+	// The JSON file is always written since the other formats are rendered from it.
+	Formats []string `yaml:"formats,omitempty" json:"formats,omitempty"`
+
+	// Email sends each finished report by SMTP.
+	Email UsageReportEmail `yaml:"email,omitempty" json:"email,omitempty"`
+}
+
+// UsageReportEmail delivers finished usage reports by email.
+type UsageReportEmail struct {
+	// SMTPHost and SMTPPort name the mail server. Empty SMTPHost disables email; the port
+	// defaults to 587 (STARTTLS when offered).
+	SMTPHost string `yaml:"smtp-host,omitempty" json:"smtp-host,omitempty"`
+	SMTPPort int    `yaml:"smtp-port,omitempty" json:"smtp-port,omitempty"`
+
+	// Username and Password authenticate with PLAIN auth when set.
+	Username string `yaml:"username,omitempty" json:"username,omitempty"`
+	Password string `yaml:"password,omitempty" json:"password,omitempty"`
+
+	From string   `yaml:"from,omitempty" json:"from,omitempty"`
+	To   []string `yaml:"to,omitempty" json:"to,omitempty"`
+}
+
+// Enabled reports whether email delivery is configured.
+func (e UsageReportEmail) Enabled() bool {
+	return e.SMTPHost != "" && e.From != "" && len(e.To) > 0
+}
+
+// SanitizeUsageReports normalizes the usage report settings.
+func (cfg *Config) SanitizeUsageReports() {
+	if cfg == nil {
+		return
+	}
+	r := &cfg.UsageReports
+	r.Dir = strings.TrimSpace(r.Dir)
+	formats := make([]string, 0, len(r.Formats))
+	for _, format := range r.Formats {
+		switch format = strings.ToLower(strings.TrimSpace(format)); format {
+		case UsageReportJSON, UsageReportCSV, UsageReportHTML:
+			formats = append(formats, format)
+		}
+	}
+	if len(formats) == 0 {
+		formats = []string{UsageReportJSON, UsageReportCSV, UsageReportHTML}
+	}
+	r.Formats = formats
+	e := &r.Email
+	e.SMTPHost = strings.TrimSpace(e.SMTPHost)
+	if e.SMTPPort <= 0 {
+		e.SMTPPort = 587
+	}
+	e.Username = strings.TrimSpace(e.Username)
+	e.From = strings.TrimSpace(e.From)
+	e.To = trimList(e.To)
+}
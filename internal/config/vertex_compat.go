@@ -17,9 +17,16 @@ type VertexCompatKey struct {
 	// Higher values are preferred; defaults to 0.
 	Priority int `yaml:"priority,omitempty" json:"priority,omitempty"`
 
+	// DisableStreaming serves streaming requests with a non-streaming upstream call whose
+	// response is replayed to the client as a synthesized stream.
+	DisableStreaming bool `yaml:"disable-streaming,omitempty" json:"disable-streaming,omitempty"`
+
 	// Prefix optionally namespaces model aliases for this credential (e.g., "teamA/vertex-pro").
 	Prefix string `yaml:"prefix,omitempty" json:"prefix,omitempty"`
 
+	// Group names the provider group this credential inherits settings from.
+	Group string `yaml:"group,omitempty" json:"group,omitempty"`
+
 	// BaseURL is the base URL for the Vertex-compatible API endpoint.
 	// The executor will append "/v1/publishers/google/models/{model}:action" to this.
 	// Example: "https://zenmux.ai/api" becomes "https://zenmux.ai/api/v1/publishers/google/models/..."
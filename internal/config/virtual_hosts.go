@@ -0,0 +1,100 @@
+package config
+
+import (
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// VirtualHost is a logical proxy configuration served by the same process. Requests are
+// assigned to the first virtual host whose Hosts patterns match the Host header and whose
+// PathPrefix starts the request path (either may be omitted). The prefix is stripped before
+// routing, so /org-a/v1/chat/completions is served as /v1/chat/completions for "org-a".
+// Credentials of the provider groups and credentials listed by a virtual host are reserved
+// for it and never serve requests outside it.
+type VirtualHost struct {
+	// Name identifies the virtual host in logs and routing tags ("vhost:<name>").
+	Name string `yaml:"name" json:"name"`
+
+	// Hosts lists Host header patterns, e.g. "api.org-a.example.com" or "*.org-a.example.com".
+	Hosts []string `yaml:"hosts,omitempty" json:"hosts,omitempty"`
+
+	// PathPrefix serves the virtual host under a URL prefix, e.g. "/org-a".
+	PathPrefix string `yaml:"path-prefix,omitempty" json:"path-prefix,omitempty"`
+
+	// APIKeys are the client keys accepted by the virtual host instead of the top-level ones.
+	// Empty keeps the top-level access providers.
+	APIKeys []string `yaml:"api-keys,omitempty" json:"api-keys,omitempty"`
+
+	// ModelMappings rewrites requested models for this virtual host before routing.
+	ModelMappings []AmpModelMapping `yaml:"model-mappings,omitempty" json:"model-mappings,omitempty"`
+
+	// ProviderGroups restricts the virtual host to the credentials of these provider groups.
+	ProviderGroups []string `yaml:"provider-groups,omitempty" json:"provider-groups,omitempty"`
+
+	// Credentials restricts the virtual host to these auth IDs or auth file names, in
+	// addition to its provider groups.
+	Credentials []string `yaml:"credentials,omitempty" json:"credentials,omitempty"`
+}
+
+// SanitizeVirtualHosts trims the virtual hosts and drops unnamed, duplicate and unreachable
+// ones (without hosts or path prefix).
+func (cfg *Config) SanitizeVirtualHosts() {
+	if cfg == nil || len(cfg.VirtualHosts) == 0 {
+		return
+	}
+	seen := make(map[string]struct{}, len(cfg.VirtualHosts))
+	out := make([]VirtualHost, 0, len(cfg.VirtualHosts))
+	for _, vhost := range cfg.VirtualHosts {
+		vhost.Name = strings.TrimSpace(vhost.Name)
+		if vhost.Name == "" {
+			continue
+		}
+		if _, dup := seen[vhost.Name]; dup {
+			continue
+		}
+		hosts := make([]string, 0, len(vhost.Hosts))
+		for _, host := range vhost.Hosts {
+			if host = strings.ToLower(strings.TrimSpace(host)); host != "" {
+				hosts = append(hosts, host)
+			}
+		}
+		vhost.Hosts = hosts
+		if prefix := strings.Trim(strings.TrimSpace(vhost.PathPrefix), "/"); prefix != "" {
+			vhost.PathPrefix = "/" + prefix
+		} else {
+			vhost.PathPrefix = ""
+		}
+		if len(vhost.Hosts) == 0 && vhost.PathPrefix == "" {
+			log.Warnf("virtual-hosts: ignoring %s without hosts or path-prefix", vhost.Name)
+			continue
+		}
+		seen[vhost.Name] = struct{}{}
+		vhost.APIKeys = trimNonEmpty(vhost.APIKeys)
+
+		mappings := make([]AmpModelMapping, 0, len(vhost.ModelMappings))
+		for _, mapping := range vhost.ModelMappings {
+			mapping.From = strings.TrimSpace(mapping.From)
+			mapping.To = strings.TrimSpace(mapping.To)
+			if mapping.From == "" || mapping.To == "" {
+				continue
+			}
+			mappings = append(mappings, mapping)
+		}
+		vhost.ModelMappings = mappings
+
+		groups := make([]string, 0, len(vhost.ProviderGroups))
+		for _, name := range trimNonEmpty(vhost.ProviderGroups) {
+			group, ok := cfg.ProviderGroupByName(name)
+			if !ok {
+				log.Warnf("virtual-hosts: %s references unknown provider group %q", vhost.Name, name)
+				continue
+			}
+			groups = append(groups, group.Name)
+		}
+		vhost.ProviderGroups = groups
+		vhost.Credentials = trimNonEmpty(vhost.Credentials)
+		out = append(out, vhost)
+	}
+	cfg.VirtualHosts = out
+}
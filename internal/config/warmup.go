@@ -0,0 +1,41 @@
+package config
+
+import "strings"
+
+// DefaultWarmupTimeoutSeconds bounds each warm-up request.
+const DefaultWarmupTimeoutSeconds = 30
+
+// WarmupConfig sends a trivial request for each listed model to every provider serving it
+// right after startup, so connections are open and credentials refreshed before the first
+// editor request arrives.
+type WarmupConfig struct {
+	// Models lists the models to warm up. Empty disables the warm-up.
+	Models []string `yaml:"models,omitempty" json:"models,omitempty"`
+
+	// TimeoutSeconds bounds each warm-up request. Defaults to 30.
+	TimeoutSeconds int `yaml:"timeout-seconds,omitempty" json:"timeout-seconds,omitempty"`
+}
+
+// Enabled reports whether any model is warmed up.
+func (c WarmupConfig) Enabled() bool { return len(c.Models) > 0 }
+
+// SanitizeWarmup drops blank and duplicate models and applies the warm-up defaults.
+func (cfg *Config) SanitizeWarmup() {
+	if cfg == nil {
+		return
+	}
+	seen := make(map[string]struct{}, len(cfg.Warmup.Models))
+	models := make([]string, 0, len(cfg.Warmup.Models))
+	for _, model := range trimNonEmpty(cfg.Warmup.Models) {
+		key := strings.ToLower(model)
+		if _, dup := seen[key]; dup {
+			continue
+		}
+		seen[key] = struct{}{}
+		models = append(models, model)
+	}
+	cfg.Warmup.Models = models
+	if cfg.Warmup.TimeoutSeconds <= 0 {
+		cfg.Warmup.TimeoutSeconds = DefaultWarmupTimeoutSeconds
+	}
+}
@@ -0,0 +1,154 @@
+// Package connections tracks the API requests currently being served, including the
+// upstream provider and credential they were routed to and the bytes written back so far,
+// so operators can spot hung streams and terminate them from the management API.
+package connections
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrTerminated is the cancellation cause of requests terminated by an operator.
+var ErrTerminated = errors.New("request terminated by operator")
+
+var defaultTracker = NewTracker()
+
+// Default returns the process-wide tracker.
+func Default() *Tracker { return defaultTracker }
+
+// Info describes one active request.
+type Info struct {
+	ID            string    `json:"id"`
+	Method        string    `json:"method"`
+	Path          string    `json:"path"`
+	Model         string    `json:"model,omitempty"`
+	Provider      string    `json:"provider,omitempty"`
+	AuthID        string    `json:"auth_id,omitempty"`
+	APIKey        string    `json:"api_key,omitempty"`
+	ClientIP      string    `json:"client_ip,omitempty"`
+	StartedAt     time.Time `json:"started_at"`
+	ElapsedMs     int64     `json:"elapsed_ms"`
+	BytesStreamed int64     `json:"bytes_streamed"`
+	Streaming     bool      `json:"streaming"`
+	// IdleMs is the time since the last byte was written, or since the start before the first.
+	IdleMs int64 `json:"idle_ms"`
+}
+
+// Conn is an active request. Its setters may be called from the request goroutines.
+type Conn struct {
+	info      Info
+	mu        sync.Mutex
+	apiKey    func() string
+	bytes     atomic.Int64
+	lastWrite atomic.Int64
+	streaming atomic.Bool
+	cancel    context.CancelCauseFunc
+}
+
+// SetUpstream records the provider and credential serving the request.
+func (c *Conn) SetUpstream(provider, authID string) {
+	c.mu.Lock()
+	c.info.Provider, c.info.AuthID = provider, authID
+	c.mu.Unlock()
+}
+
+// SetModel records the model the request asked for.
+func (c *Conn) SetModel(model string) {
+	c.mu.Lock()
+	c.info.Model = model
+	c.mu.Unlock()
+}
+
+// Wrote counts n bytes written to the client.
+func (c *Conn) Wrote(n int) {
+	if n <= 0 {
+		return
+	}
+	c.bytes.Add(int64(n))
+	c.lastWrite.Store(time.Now().UnixNano())
+}
+
+// SetStreaming marks the response as a stream.
+func (c *Conn) SetStreaming() { c.streaming.Store(true) }
+
+// Tracker holds the active requests.
+type Tracker struct {
+	mu    sync.Mutex
+	conns map[string]*Conn
+	seq   atomic.Uint64
+}
+
+// NewTracker constructs an empty tracker.
+func NewTracker() *Tracker {
+	return &Tracker{conns: make(map[string]*Conn)}
+}
+
+// Begin registers a request and returns its connection, the context to serve it with, and
+// the function to call when it ends. apiKey is evaluated lazily since authentication runs
+// after registration; it is called under the tracker lock only while the request is active.
+func (t *Tracker) Begin(ctx context.Context, info Info, apiKey func() string) (*Conn, context.Context, func()) {
+	ctx, cancel := context.WithCancelCause(ctx)
+	info.ID = strconv.FormatUint(t.seq.Add(1), 36)
+	if info.StartedAt.IsZero() {
+		info.StartedAt = time.Now()
+	}
+	conn := &Conn{info: info, apiKey: apiKey, cancel: cancel}
+	t.mu.Lock()
+	t.conns[info.ID] = conn
+	t.mu.Unlock()
+	return conn, ctx, func() {
+		t.mu.Lock()
+		delete(t.conns, info.ID)
+		t.mu.Unlock()
+		cancel(nil)
+	}
+}
+
+// Snapshot returns the active requests, longest-running first.
+func (t *Tracker) Snapshot() []Info {
+	if t == nil {
+		return nil
+	}
+	now := time.Now()
+	t.mu.Lock()
+	out := make([]Info, 0, len(t.conns))
+	for _, conn := range t.conns {
+		conn.mu.Lock()
+		info := conn.info
+		conn.mu.Unlock()
+		if conn.apiKey != nil {
+			info.APIKey = conn.apiKey()
+		}
+		info.ElapsedMs = now.Sub(info.StartedAt).Milliseconds()
+		info.BytesStreamed = conn.bytes.Load()
+		info.Streaming = conn.streaming.Load()
+		last := info.StartedAt
+		if nanos := conn.lastWrite.Load(); nanos > 0 {
+			last = time.Unix(0, nanos)
+		}
+		info.IdleMs = now.Sub(last).Milliseconds()
+		out = append(out, info)
+	}
+	t.mu.Unlock()
+	sort.Slice(out, func(i, j int) bool { return out[i].StartedAt.Before(out[j].StartedAt) })
+	return out
+}
+
+// Terminate cancels the request with the given ID and reports whether it was active.
+func (t *Tracker) Terminate(id string) bool {
+	if t == nil {
+		return false
+	}
+	t.mu.Lock()
+	conn, ok := t.conns[id]
+	t.mu.Unlock()
+	if ok {
+		conn.cancel(ErrTerminated)
+	}
+	return ok
+}
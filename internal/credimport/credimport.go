@@ -0,0 +1,365 @@
+// Package credimport converts token files written by other tools (the gemini CLI, the
+// Claude CLI and the Codex CLI) and credential files of this proxy into credentials of the
+// proxy's auth store.
+package credimport
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/auth/codex"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/auth/gemini"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+)
+
+// Import outcomes of a file.
+const (
+	StatusImported = "imported"
+	StatusExists   = "exists"
+	StatusInvalid  = "invalid"
+)
+
+// maxFileBytes bounds the size of a token file.
+const maxFileBytes = 1 << 20
+
+// nativeTypes are the credential types of this proxy imported unchanged.
+var nativeTypes = map[string]bool{
+	"gemini": true, "claude": true, "codex": true, "qwen": true,
+	"iflow": true, "antigravity": true, "vertex": true,
+}
+
+// Options controls an import.
+type Options struct {
+	// ProjectID is the Google Cloud project of imported gemini CLI tokens, which do not
+	// record one.
+	ProjectID string
+	// Overwrite replaces credentials of the same file name already in the store.
+	Overwrite bool
+	// DryRun validates and reports without saving.
+	DryRun bool
+}
+
+// Result is the outcome of one source file.
+type Result struct {
+	Source   string `json:"source"`
+	Provider string `json:"provider,omitempty"`
+	File     string `json:"file,omitempty"`
+	Email    string `json:"email,omitempty"`
+	Status   string `json:"status"`
+	Error    string `json:"error,omitempty"`
+}
+
+// Saver persists imported credentials, e.g. a coreauth.Store.
+type Saver interface {
+	Save(ctx context.Context, auth *coreauth.Auth) (string, error)
+}
+
+// Source is one token file to import.
+type Source struct {
+	Name string
+	Data []byte
+}
+
+// ReadDir returns the .json files directly inside dir, sorted by name.
+func ReadDir(dir string) ([]Source, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var sources []Source
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.EqualFold(filepath.Ext(entry.Name()), ".json") {
+			continue
+		}
+		info, errInfo := entry.Info()
+		if errInfo != nil {
+			return nil, errInfo
+		}
+		if info.Size() > maxFileBytes {
+			sources = append(sources, Source{Name: entry.Name()})
+			continue
+		}
+		data, errRead := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if errRead != nil {
+			return nil, errRead
+		}
+		sources = append(sources, Source{Name: entry.Name(), Data: data})
+	}
+	sort.Slice(sources, func(i, j int) bool { return sources[i].Name < sources[j].Name })
+	return sources, nil
+}
+
+// Import normalizes every source and saves the valid ones to store, whose credential files
+// live in authDir. Sources normalizing to the same file name are imported once.
+func Import(ctx context.Context, store Saver, authDir string, sources []Source, opts Options, now time.Time) []Result {
+	results := make([]Result, 0, len(sources))
+	seen := make(map[string]bool, len(sources))
+	for _, source := range sources {
+		result := Result{Source: source.Name}
+		record, err := Normalize(source.Name, source.Data, opts, now)
+		if err != nil {
+			result.Status, result.Error = StatusInvalid, err.Error()
+			results = append(results, result)
+			continue
+		}
+		result.Provider, result.File = record.Provider, record.FileName
+		result.Email, _ = record.Metadata["email"].(string)
+		if seen[record.FileName] || (!opts.Overwrite && fileExists(filepath.Join(authDir, record.FileName))) {
+			result.Status = StatusExists
+			results = append(results, result)
+			continue
+		}
+		seen[record.FileName] = true
+		if !opts.DryRun {
+			if _, errSave := store.Save(ctx, record); errSave != nil {
+				result.Status, result.Error = StatusInvalid, errSave.Error()
+				results = append(results, result)
+				continue
+			}
+		}
+		result.Status = StatusImported
+		results = append(results, result)
+	}
+	return results
+}
+
+// Normalize converts the token file data, named name, into a credential of the proxy.
+func Normalize(name string, data []byte, opts Options, now time.Time) (*coreauth.Auth, error) {
+	if len(data) == 0 {
+		return nil, fmt.Errorf("empty or larger than %d bytes", maxFileBytes)
+	}
+	var raw map[string]any
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("invalid JSON: %w", err)
+	}
+	var (
+		metadata map[string]any
+		fileName string
+		err      error
+	)
+	switch {
+	case nativeTypes[stringField(raw, "type")]:
+		metadata, fileName, err = normalizeNative(name, raw)
+	case raw["claudeAiOauth"] != nil:
+		metadata, fileName, err = normalizeClaudeCLI(raw, now)
+	case raw["tokens"] != nil:
+		metadata, fileName, err = normalizeCodexCLI(raw, now)
+	case stringField(raw, "refresh_token") != "" && (raw["expiry_date"] != nil || strings.Contains(stringField(raw, "scope"), "googleapis.com")):
+		metadata, fileName, err = normalizeGeminiCLI(raw, opts.ProjectID)
+	default:
+		return nil, fmt.Errorf("unrecognized token format")
+	}
+	if err != nil {
+		return nil, err
+	}
+	provider := stringField(metadata, "type")
+	return &coreauth.Auth{
+		ID:       fileName,
+		Provider: provider,
+		FileName: fileName,
+		Label:    stringField(metadata, "email"),
+		Metadata: metadata,
+	}, nil
+}
+
+// normalizeNative validates a credential file of this proxy and keeps its name.
+func normalizeNative(name string, raw map[string]any) (map[string]any, string, error) {
+	kind := stringField(raw, "type")
+	switch kind {
+	case "vertex":
+		if _, ok := raw["service_account"].(map[string]any); !ok {
+			return nil, "", fmt.Errorf("vertex credential without service_account")
+		}
+	case "gemini":
+		token, _ := raw["token"].(map[string]any)
+		if stringField(token, "refresh_token") == "" && stringField(token, "access_token") == "" {
+			return nil, "", fmt.Errorf("gemini credential without token")
+		}
+	default:
+		if stringField(raw, "refresh_token") == "" && stringField(raw, "access_token") == "" && stringField(raw, "api_key") == "" {
+			return nil, "", fmt.Errorf("%s credential without tokens", kind)
+		}
+	}
+	fileName := sanitizeFileName(filepath.Base(name))
+	if fileName == "" {
+		fileName = kind + "-" + fingerprint(stringField(raw, "refresh_token"), stringField(raw, "access_token")) + ".json"
+	}
+	return raw, fileName, nil
+}
+
+// normalizeClaudeCLI converts ~/.claude/.credentials.json.
+func normalizeClaudeCLI(raw map[string]any, now time.Time) (map[string]any, string, error) {
+	oauth, _ := raw["claudeAiOauth"].(map[string]any)
+	access, refresh := stringField(oauth, "accessToken"), stringField(oauth, "refreshToken")
+	if refresh == "" {
+		return nil, "", fmt.Errorf("claude credential without refreshToken")
+	}
+	email := stringField(oauth, "email")
+	metadata := map[string]any{
+		"type":          "claude",
+		"access_token":  access,
+		"refresh_token": refresh,
+		"id_token":      "",
+		"email":         email,
+		"last_refresh":  now.UTC().Format(time.RFC3339),
+		"expired":       millisToRFC3339(oauth["expiresAt"]),
+	}
+	label := email
+	if label == "" {
+		label = fingerprint(refresh)
+	}
+	return metadata, sanitizeFileName("claude-" + label + ".json"), nil
+}
+
+// normalizeCodexCLI converts ~/.codex/auth.json.
+func normalizeCodexCLI(raw map[string]any, now time.Time) (map[string]any, string, error) {
+	tokens, _ := raw["tokens"].(map[string]any)
+	access, refresh, idToken := stringField(tokens, "access_token"), stringField(tokens, "refresh_token"), stringField(tokens, "id_token")
+	if refresh == "" {
+		return nil, "", fmt.Errorf("codex credential without refresh_token")
+	}
+	accountID := stringField(tokens, "account_id")
+	var email, planType string
+	if idToken != "" {
+		claims, err := codex.ParseJWTToken(idToken)
+		if err != nil {
+			return nil, "", fmt.Errorf("codex id_token: %w", err)
+		}
+		email = strings.TrimSpace(claims.Email)
+		planType = strings.TrimSpace(claims.CodexAuthInfo.ChatgptPlanType)
+		if accountID == "" {
+			accountID = claims.GetAccountID()
+		}
+	}
+	lastRefresh := stringField(raw, "last_refresh")
+	if lastRefresh == "" {
+		lastRefresh = now.UTC().Format(time.RFC3339)
+	}
+	metadata := map[string]any{
+		"type":          "codex",
+		"id_token":      idToken,
+		"access_token":  access,
+		"refresh_token": refresh,
+		"account_id":    accountID,
+		"email":         email,
+		"last_refresh":  lastRefresh,
+		"expired":       "",
+	}
+	label := email
+	if label == "" {
+		label = fingerprint(refresh)
+	}
+	var hashAccountID string
+	if accountID != "" {
+		digest := sha256.Sum256([]byte(accountID))
+		hashAccountID = hex.EncodeToString(digest[:])[:8]
+	}
+	return metadata, sanitizeFileName(codex.CredentialFileName(label, planType, hashAccountID, true)), nil
+}
+
+// normalizeGeminiCLI converts ~/.gemini/oauth_creds.json, which lacks the project.
+func normalizeGeminiCLI(raw map[string]any, projectID string) (map[string]any, string, error) {
+	projectID = strings.TrimSpace(projectID)
+	if projectID == "" {
+		return nil, "", fmt.Errorf("gemini CLI token needs a project id")
+	}
+	var email string
+	if idToken := stringField(raw, "id_token"); idToken != "" {
+		if claims, err := codex.ParseJWTToken(idToken); err == nil {
+			email = strings.TrimSpace(claims.Email)
+		}
+	}
+	token := map[string]any{
+		"access_token":    stringField(raw, "access_token"),
+		"refresh_token":   stringField(raw, "refresh_token"),
+		"token_type":      firstNonEmpty(stringField(raw, "token_type"), "Bearer"),
+		"expiry":          millisToRFC3339(raw["expiry_date"]),
+		"token_uri":       "https://oauth2.googleapis.com/token",
+		"client_id":       gemini.ClientID,
+		"client_secret":   gemini.ClientSecret,
+		"scopes":          gemini.Scopes,
+		"universe_domain": "googleapis.com",
+	}
+	metadata := map[string]any{
+		"type":       "gemini",
+		"token":      token,
+		"project_id": projectID,
+		"email":      email,
+		"auto":       false,
+		"checked":    false,
+	}
+	label := email
+	if label == "" {
+		label = fingerprint(stringField(raw, "refresh_token"))
+	}
+	return metadata, sanitizeFileName(gemini.CredentialFileName(label, projectID, true)), nil
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+func stringField(m map[string]any, key string) string {
+	if m == nil {
+		return ""
+	}
+	s, _ := m[key].(string)
+	return strings.TrimSpace(s)
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// millisToRFC3339 formats a Unix time in milliseconds, as the CLIs record expiries.
+func millisToRFC3339(v any) string {
+	ms, ok := v.(float64)
+	if !ok || ms <= 0 {
+		return ""
+	}
+	return time.UnixMilli(int64(ms)).UTC().Format(time.RFC3339)
+}
+
+// fingerprint labels a credential without an email by a short hash of its first token.
+func fingerprint(tokens ...string) string {
+	for _, token := range tokens {
+		if token != "" {
+			digest := sha256.Sum256([]byte(token))
+			return "imported-" + hex.EncodeToString(digest[:])[:8]
+		}
+	}
+	return "imported"
+}
+
+// sanitizeFileName keeps a credential file name inside the auth directory.
+func sanitizeFileName(name string) string {
+	name = strings.TrimSpace(name)
+	name = strings.Map(func(r rune) rune {
+		switch r {
+		case '/', '\\', ':', 0:
+			return '_'
+		}
+		return r
+	}, name)
+	if name == "" || name == "." || name == ".." || strings.HasPrefix(name, ".") {
+		return ""
+	}
+	if !strings.EqualFold(filepath.Ext(name), ".json") {
+		name += ".json"
+	}
+	return name
+}
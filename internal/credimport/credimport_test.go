@@ -0,0 +1,70 @@
+package credimport
+
+import (
+	"context"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	sdkAuth "github.com/router-for-me/CLIProxyAPI/v6/sdk/auth"
+)
+
+func fakeJWT(claims string) string {
+	enc := base64.RawURLEncoding.EncodeToString
+	return enc([]byte(`{"alg":"none"}`)) + "." + enc([]byte(claims)) + ".sig"
+}
+
+func TestImport(t *testing.T) {
+	authDir := t.TempDir()
+	store := sdkAuth.NewFileTokenStore()
+	store.SetBaseDir(authDir)
+	if err := os.WriteFile(filepath.Join(authDir, "claude-taken@example.com.json"), []byte(`{"type":"claude"}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	idToken := fakeJWT(`{"email":"dev@example.com","https://api.openai.com/auth":{"chatgpt_account_id":"acct-1","chatgpt_plan_type":"plus"}}`)
+	sources := []Source{
+		{Name: "credentials.json", Data: []byte(`{"claudeAiOauth":{"accessToken":"at","refreshToken":"rt-claude","expiresAt":1760000000000}}`)},
+		{Name: "auth.json", Data: []byte(`{"OPENAI_API_KEY":null,"tokens":{"id_token":"` + idToken + `","access_token":"at","refresh_token":"rt-codex"}}`)},
+		{Name: "oauth_creds.json", Data: []byte(`{"access_token":"at","refresh_token":"rt-gemini","scope":"https://www.googleapis.com/auth/cloud-platform","expiry_date":1760000000000}`)},
+		{Name: "claude-taken@example.com.json", Data: []byte(`{"type":"claude","email":"taken@example.com","refresh_token":"rt"}`)},
+		{Name: "qwen-native.json", Data: []byte(`{"type":"qwen","access_token":"at"}`)},
+		{Name: "notes.json", Data: []byte(`{"hello":"world"}`)},
+	}
+	results := Import(context.Background(), store, authDir, sources, Options{}, time.Unix(1750000000, 0))
+
+	want := []struct{ status, provider, file string }{
+		{StatusImported, "claude", "claude-imported-"},
+		{StatusImported, "codex", "codex-dev@example.com-plus.json"},
+		{StatusInvalid, "", ""},
+		{StatusExists, "claude", "claude-taken@example.com.json"},
+		{StatusImported, "qwen", "qwen-native.json"},
+		{StatusInvalid, "", ""},
+	}
+	for i, w := range want {
+		got := results[i]
+		if got.Status != w.status || got.Provider != w.provider || !strings.HasPrefix(got.File, w.file) {
+			t.Fatalf("results[%d] = %+v, want %+v", i, got, w)
+		}
+	}
+
+	data, err := os.ReadFile(filepath.Join(authDir, "codex-dev@example.com-plus.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	record, err := Normalize("codex-dev@example.com-plus.json", data, Options{}, time.Now())
+	if err != nil || record.Provider != "codex" || record.Metadata["account_id"] != "acct-1" {
+		t.Fatalf("saved codex credential does not re-import: %v %+v", err, record)
+	}
+
+	results = Import(context.Background(), store, authDir, sources[2:3], Options{ProjectID: "my-project", DryRun: true}, time.Now())
+	if results[0].Status != StatusImported || !strings.HasPrefix(results[0].File, "gemini-imported-") || !strings.HasSuffix(results[0].File, "-my-project.json") {
+		t.Fatalf("gemini dry run = %+v", results[0])
+	}
+	if _, err = os.Stat(filepath.Join(authDir, results[0].File)); !os.IsNotExist(err) {
+		t.Fatalf("dry run wrote %s", results[0].File)
+	}
+}
@@ -0,0 +1,168 @@
+package dnscache
+
+import (
+	"context"
+	"net"
+	"net/netip"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+// DialContext connects to address like net.Dialer.DialContext. With the cache enabled, TCP
+// host names are resolved through the cache and the two address families are raced: the
+// family of the first address is dialed first and the other joins once the fallback delay
+// has passed or the first family has failed.
+func (r *Resolver) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	if !r.Enabled() {
+		return r.dial(ctx, network, address)
+	}
+	switch network {
+	case "tcp", "tcp4", "tcp6":
+	default:
+		return r.dial(ctx, network, address)
+	}
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return r.dial(ctx, network, address)
+	}
+	if _, errParse := netip.ParseAddr(host); errParse == nil {
+		return r.dial(ctx, network, address)
+	}
+	addrs, err := r.LookupNetIP(ctx, host)
+	if err != nil {
+		return nil, &net.OpError{Op: "dial", Net: network, Err: err}
+	}
+	primaries, fallbacks := partition(filterFamily(addrs, network))
+	if len(primaries) == 0 {
+		return nil, &net.OpError{Op: "dial", Net: network, Err: &net.AddrError{Err: "no suitable address found", Addr: host}}
+	}
+
+	r.mu.Lock()
+	delay := time.Duration(r.cfg.FallbackDelayMs) * time.Millisecond
+	r.mu.Unlock()
+	if delay <= 0 {
+		delay = config.DefaultDNSCacheFallbackDelayMs * time.Millisecond
+	}
+	return r.dialParallel(ctx, network, port, primaries, fallbacks, delay)
+}
+
+// Dial connects to address without a context; it lets the resolver serve as the forward
+// dialer of a SOCKS5 proxy.
+func (r *Resolver) Dial(network, address string) (net.Conn, error) {
+	return r.DialContext(context.Background(), network, address)
+}
+
+// dialParallel races the primary addresses against the fallbacks, which start after delay
+// or as soon as the primaries have failed. A connection won after the race is closed.
+func (r *Resolver) dialParallel(ctx context.Context, network, port string, primaries, fallbacks []netip.Addr, delay time.Duration) (net.Conn, error) {
+	if len(fallbacks) == 0 {
+		return r.dialSerial(ctx, network, port, primaries)
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type result struct {
+		conn    net.Conn
+		err     error
+		primary bool
+	}
+	results := make(chan result, 2)
+	race := func(primary bool, addrs []netip.Addr) {
+		conn, err := r.dialSerial(ctx, network, port, addrs)
+		results <- result{conn: conn, err: err, primary: primary}
+	}
+	go race(true, primaries)
+	pending := 1
+	fallbackStarted := false
+	startFallback := func() {
+		if !fallbackStarted {
+			fallbackStarted = true
+			pending++
+			go race(false, fallbacks)
+		}
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	var primaryErr, fallbackErr error
+	for {
+		select {
+		case <-timer.C:
+			startFallback()
+		case res := <-results:
+			pending--
+			if res.err == nil {
+				if pending > 0 {
+					go func(n int) {
+						for ; n > 0; n-- {
+							if late := <-results; late.conn != nil {
+								_ = late.conn.Close()
+							}
+						}
+					}(pending)
+				}
+				return res.conn, nil
+			}
+			if res.primary {
+				primaryErr = res.err
+			} else {
+				fallbackErr = res.err
+			}
+			startFallback()
+			if pending == 0 {
+				if primaryErr != nil {
+					return nil, primaryErr
+				}
+				return nil, fallbackErr
+			}
+		}
+	}
+}
+
+// dialSerial tries addrs in order and returns the first connection.
+func (r *Resolver) dialSerial(ctx context.Context, network, port string, addrs []netip.Addr) (net.Conn, error) {
+	var firstErr error
+	for _, addr := range addrs {
+		if errCtx := ctx.Err(); errCtx != nil {
+			if firstErr == nil {
+				firstErr = errCtx
+			}
+			break
+		}
+		conn, err := r.dial(ctx, network, net.JoinHostPort(addr.String(), port))
+		if err == nil {
+			return conn, nil
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	return nil, firstErr
+}
+
+// filterFamily keeps the addresses network can reach.
+func filterFamily(addrs []netip.Addr, network string) []netip.Addr {
+	if network == "tcp" {
+		return addrs
+	}
+	out := make([]netip.Addr, 0, len(addrs))
+	for _, addr := range addrs {
+		if (network == "tcp4") == addr.Is4() {
+			out = append(out, addr)
+		}
+	}
+	return out
+}
+
+// partition splits addrs into those of the first address's family and the rest.
+func partition(addrs []netip.Addr) (primaries, fallbacks []netip.Addr) {
+	for _, addr := range addrs {
+		if len(primaries) == 0 || addr.Is4() == primaries[0].Is4() {
+			primaries = append(primaries, addr)
+		} else {
+			fallbacks = append(fallbacks, addr)
+		}
+	}
+	return primaries, fallbacks
+}
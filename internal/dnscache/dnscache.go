@@ -0,0 +1,210 @@
+// Package dnscache resolves upstream hosts through an in-process cache and dials them with
+// happy eyeballs (RFC 8305). Answers are kept for their record TTL and served past it while
+// the resolver is failing, so a flaky resolver stalls at most the refreshes instead of every
+// request, and the IPv6 and IPv4 addresses of a host are raced so a broken address family
+// costs the fallback delay instead of a connect timeout.
+package dnscache
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	log "github.com/sirupsen/logrus"
+)
+
+var defaultResolver = New()
+
+// Default returns the process-wide resolver.
+func Default() *Resolver { return defaultResolver }
+
+// init replaces http.DefaultTransport, before any request can use it, with a copy dialing
+// through the default resolver, which dials directly while the cache is disabled. Enabling
+// the cache on reload then swaps the resolver settings instead of a transport in use, and
+// transports cloned from the default one dial through the cache as well.
+func init() {
+	if transport, ok := http.DefaultTransport.(*http.Transport); ok {
+		installed := transport.Clone()
+		installed.DialContext = defaultResolver.DialContext
+		http.DefaultTransport = installed
+	}
+}
+
+// Resolver caches host lookups and dials the cached addresses.
+type Resolver struct {
+	mu       sync.Mutex
+	cfg      config.DNSCacheConfig
+	conf     resolvConf
+	entries  map[string]*entry
+	inflight map[string]*call
+
+	now    func() time.Time
+	lookup func(ctx context.Context, host string, conf resolvConf) (answer, error)
+	dial   func(ctx context.Context, network, address string) (net.Conn, error)
+}
+
+// answer is a resolved host with the TTL of its records; 0 means unknown.
+type answer struct {
+	addrs []netip.Addr
+	ttl   time.Duration
+}
+
+type entry struct {
+	addrs   []netip.Addr
+	expires time.Time
+}
+
+// call is a lookup shared by concurrent callers.
+type call struct {
+	done  chan struct{}
+	addrs []netip.Addr
+	err   error
+}
+
+// New constructs a disabled resolver.
+func New() *Resolver {
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+	return &Resolver{
+		entries:  make(map[string]*entry),
+		inflight: make(map[string]*call),
+		now:      time.Now,
+		lookup:   resolve,
+		dial:     dialer.DialContext,
+	}
+}
+
+// Configure replaces the settings and drops the cached answers.
+func (r *Resolver) Configure(cfg config.DNSCacheConfig) {
+	if r == nil {
+		return
+	}
+	var conf resolvConf
+	if cfg.Enable {
+		// The search domains apply to the configured nameservers as well.
+		conf = readResolvConf(resolvConfPath)
+		if len(cfg.Servers) > 0 {
+			conf.servers = cfg.Servers
+		}
+	}
+	r.mu.Lock()
+	r.cfg = cfg
+	r.conf = conf
+	r.entries = make(map[string]*entry)
+	r.mu.Unlock()
+	if cfg.Enable {
+		if len(conf.servers) == 0 {
+			log.Infof("dns-cache: enabled, resolving through the system resolver")
+		} else {
+			log.Infof("dns-cache: enabled, resolving through %s", strings.Join(conf.servers, ", "))
+		}
+	}
+}
+
+// Enabled reports whether lookups are cached.
+func (r *Resolver) Enabled() bool {
+	if r == nil {
+		return false
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.cfg.Enable
+}
+
+// LookupNetIP returns the addresses of host, IPv6 first. Fresh answers come from the cache;
+// expired ones are refreshed once for all concurrent callers and still served within the
+// stale window when the refresh fails.
+func (r *Resolver) LookupNetIP(ctx context.Context, host string) ([]netip.Addr, error) {
+	if addr, err := netip.ParseAddr(host); err == nil {
+		return []netip.Addr{addr.Unmap()}, nil
+	}
+	key := strings.ToLower(strings.TrimSuffix(host, "."))
+
+	r.mu.Lock()
+	cfg := r.cfg
+	now := r.now()
+	cached := r.entries[key]
+	if cached != nil && now.Before(cached.expires) {
+		r.mu.Unlock()
+		return cached.addrs, nil
+	}
+	c := r.inflight[key]
+	if c == nil {
+		c = &call{done: make(chan struct{})}
+		r.inflight[key] = c
+		// The refresh is detached from ctx so one caller giving up does not fail the others.
+		go r.refresh(key, c)
+	}
+	r.mu.Unlock()
+
+	select {
+	case <-c.done:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	if c.err == nil {
+		return c.addrs, nil
+	}
+	if cached != nil && cfg.StaleSeconds > 0 && now.Before(cached.expires.Add(time.Duration(cfg.StaleSeconds)*time.Second)) {
+		log.Debugf("dns-cache: serving stale answer for %s: %v", key, c.err)
+		return cached.addrs, nil
+	}
+	return nil, c.err
+}
+
+func (r *Resolver) refresh(host string, c *call) {
+	r.mu.Lock()
+	cfg, conf := r.cfg, r.conf
+	r.mu.Unlock()
+
+	timeout := time.Duration(cfg.LookupTimeoutSeconds) * time.Second
+	if timeout <= 0 {
+		timeout = config.DefaultDNSCacheLookupTimeoutSecs * time.Second
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	ans, err := r.lookup(ctx, host, conf)
+	if err == nil && len(ans.addrs) == 0 {
+		err = &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+
+	r.mu.Lock()
+	if err == nil {
+		now := r.now()
+		r.entries[host] = &entry{addrs: ans.addrs, expires: now.Add(clampTTL(ans.ttl, cfg))}
+		r.pruneLocked(now, cfg)
+	}
+	if r.inflight[host] == c {
+		delete(r.inflight, host)
+	}
+	r.mu.Unlock()
+
+	c.addrs, c.err = ans.addrs, err
+	close(c.done)
+}
+
+// pruneLocked drops the answers that are past their stale window.
+func (r *Resolver) pruneLocked(now time.Time, cfg config.DNSCacheConfig) {
+	stale := time.Duration(max(cfg.StaleSeconds, 0)) * time.Second
+	for host, e := range r.entries {
+		if now.After(e.expires.Add(stale)) {
+			delete(r.entries, host)
+		}
+	}
+}
+
+func clampTTL(ttl time.Duration, cfg config.DNSCacheConfig) time.Duration {
+	minTTL := time.Duration(cfg.MinTTLSeconds) * time.Second
+	maxTTL := time.Duration(cfg.MaxTTLSeconds) * time.Second
+	if ttl < minTTL {
+		ttl = minTTL
+	}
+	if maxTTL > 0 && ttl > maxTTL {
+		ttl = maxTTL
+	}
+	return ttl
+}
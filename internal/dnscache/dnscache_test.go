@@ -0,0 +1,241 @@
+package dnscache
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+func enabledConfig() config.DNSCacheConfig {
+	cfg := &config.Config{DNSCache: config.DNSCacheConfig{Enable: true, Servers: []string{"127.0.0.1:1"}}}
+	cfg.SanitizeDNSCache()
+	return cfg.DNSCache
+}
+
+// serveDNS answers A and AAAA queries for any name with the given addresses and TTL.
+func serveDNS(t *testing.T, ttl uint32, v4, v6 string) (string, *atomic.Int32) {
+	t.Helper()
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	var queries atomic.Int32
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, addr, errRead := conn.ReadFrom(buf)
+			if errRead != nil {
+				return
+			}
+			queries.Add(1)
+			var msg dnsmessage.Message
+			if errUnpack := msg.Unpack(buf[:n]); errUnpack != nil {
+				continue
+			}
+			q := msg.Questions[0]
+			resp := dnsmessage.Message{
+				Header:    dnsmessage.Header{ID: msg.ID, Response: true, RecursionAvailable: true},
+				Questions: msg.Questions,
+			}
+			rh := dnsmessage.ResourceHeader{Name: q.Name, Type: q.Type, Class: dnsmessage.ClassINET, TTL: ttl}
+			switch {
+			case q.Type == dnsmessage.TypeA && v4 != "":
+				resp.Answers = append(resp.Answers, dnsmessage.Resource{Header: rh, Body: &dnsmessage.AResource{A: netip.MustParseAddr(v4).As4()}})
+			case q.Type == dnsmessage.TypeAAAA && v6 != "":
+				resp.Answers = append(resp.Answers, dnsmessage.Resource{Header: rh, Body: &dnsmessage.AAAAResource{AAAA: netip.MustParseAddr(v6).As16()}})
+			}
+			packed, _ := resp.Pack()
+			_, _ = conn.WriteTo(packed, addr)
+		}
+	}()
+	return conn.LocalAddr().String(), &queries
+}
+
+func TestResolveQueriesServersWithTTL(t *testing.T) {
+	server, _ := serveDNS(t, 42, "192.0.2.7", "2001:db8::7")
+	ans, err := resolve(context.Background(), "api.example.test", resolvConf{servers: []string{server}, ndots: 1})
+	if err != nil {
+		t.Fatalf("resolve: %v", err)
+	}
+	want := []netip.Addr{netip.MustParseAddr("2001:db8::7"), netip.MustParseAddr("192.0.2.7")}
+	if len(ans.addrs) != 2 || ans.addrs[0] != want[0] || ans.addrs[1] != want[1] {
+		t.Fatalf("addrs = %v, want %v", ans.addrs, want)
+	}
+	if ans.ttl != 42*time.Second {
+		t.Fatalf("ttl = %s, want 42s", ans.ttl)
+	}
+}
+
+func TestResolvConfSearchRules(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "resolv.conf")
+	content := "nameserver 10.0.0.2\nsearch svc.cluster.local cluster.local\noptions ndots:2 timeout:1\n"
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	conf := readResolvConf(path)
+	if len(conf.servers) != 1 || conf.servers[0] != "10.0.0.2:53" || conf.ndots != 2 || len(conf.search) != 2 {
+		t.Fatalf("conf = %+v", conf)
+	}
+	for host, want := range map[string]string{
+		"ollama":          "ollama.svc.cluster.local,ollama.cluster.local,ollama",
+		"api.example.com": "api.example.com,api.example.com.svc.cluster.local,api.example.com.cluster.local",
+		"api.example.":    "api.example.",
+	} {
+		if got := strings.Join(conf.names(host), ","); got != want {
+			t.Fatalf("names(%q) = %s, want %s", host, got, want)
+		}
+	}
+}
+
+func TestLookupCachesForTTLAndServesStale(t *testing.T) {
+	r := New()
+	r.Configure(enabledConfig())
+	now := time.Unix(1000, 0)
+	r.now = func() time.Time { return now }
+	var lookups int
+	var fail bool
+	r.lookup = func(context.Context, string, resolvConf) (answer, error) {
+		lookups++
+		if fail {
+			return answer{}, errors.New("resolver down")
+		}
+		return answer{addrs: []netip.Addr{netip.MustParseAddr("192.0.2.1")}, ttl: 60 * time.Second}, nil
+	}
+
+	for i := 0; i < 3; i++ {
+		if _, err := r.LookupNetIP(context.Background(), "api.example.test"); err != nil {
+			t.Fatalf("lookup: %v", err)
+		}
+	}
+	if lookups != 1 {
+		t.Fatalf("lookups = %d, want 1 while fresh", lookups)
+	}
+
+	now = now.Add(61 * time.Second)
+	fail = true
+	addrs, err := r.LookupNetIP(context.Background(), "API.example.test.")
+	if err != nil || len(addrs) != 1 {
+		t.Fatalf("stale lookup = %v, %v; want the cached answer", addrs, err)
+	}
+	if lookups != 2 {
+		t.Fatalf("lookups = %d, want a refresh once expired", lookups)
+	}
+
+	now = now.Add(time.Duration(config.DefaultDNSCacheStaleSeconds) * time.Second)
+	if _, err = r.LookupNetIP(context.Background(), "api.example.test"); err == nil {
+		t.Fatal("lookup past the stale window succeeded")
+	}
+}
+
+func TestLookupClampsTTL(t *testing.T) {
+	cfg := enabledConfig()
+	if got := clampTTL(0, cfg); got != time.Duration(cfg.MinTTLSeconds)*time.Second {
+		t.Fatalf("clampTTL(0) = %s", got)
+	}
+	if got := clampTTL(24*time.Hour, cfg); got != time.Duration(cfg.MaxTTLSeconds)*time.Second {
+		t.Fatalf("clampTTL(24h) = %s", got)
+	}
+}
+
+func TestLookupSharesConcurrentRefresh(t *testing.T) {
+	r := New()
+	r.Configure(enabledConfig())
+	release := make(chan struct{})
+	var lookups atomic.Int32
+	r.lookup = func(context.Context, string, resolvConf) (answer, error) {
+		lookups.Add(1)
+		<-release
+		return answer{addrs: []netip.Addr{netip.MustParseAddr("192.0.2.1")}}, nil
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, _ = r.LookupNetIP(context.Background(), "api.example.test")
+		}()
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	if got := lookups.Load(); got != 1 {
+		t.Fatalf("lookups = %d, want 1", got)
+	}
+}
+
+func TestDialFallsBackToOtherFamily(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer func() { _ = ln.Close() }()
+	go func() {
+		for {
+			conn, errAccept := ln.Accept()
+			if errAccept != nil {
+				return
+			}
+			_ = conn.Close()
+		}
+	}()
+	_, port, _ := net.SplitHostPort(ln.Addr().String())
+
+	r := New()
+	cfg := enabledConfig()
+	cfg.FallbackDelayMs = 50
+	r.Configure(cfg)
+	r.lookup = func(context.Context, string, resolvConf) (answer, error) {
+		return answer{addrs: []netip.Addr{netip.MustParseAddr("2001:db8::1"), netip.MustParseAddr("127.0.0.1")}}, nil
+	}
+	base := r.dial
+	r.dial = func(ctx context.Context, network, address string) (net.Conn, error) {
+		if host, _, _ := net.SplitHostPort(address); host == "2001:db8::1" {
+			// A black-holed IPv6 route: the attempt hangs until it is abandoned.
+			<-ctx.Done()
+			return nil, ctx.Err()
+		}
+		return base(ctx, network, address)
+	}
+
+	start := time.Now()
+	conn, err := r.DialContext(context.Background(), "tcp", net.JoinHostPort("api.example.test", port))
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	_ = conn.Close()
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond || elapsed > 2*time.Second {
+		t.Fatalf("dial took %s, want about the fallback delay", elapsed)
+	}
+	if got := conn.RemoteAddr().(*net.TCPAddr).IP.String(); got != "127.0.0.1" {
+		t.Fatalf("connected to %s, want the IPv4 fallback", got)
+	}
+}
+
+func TestDialPassesThroughWhenDisabled(t *testing.T) {
+	r := New()
+	var dialed string
+	r.dial = func(_ context.Context, _, address string) (net.Conn, error) {
+		dialed = address
+		return nil, errors.New("stop")
+	}
+	r.lookup = func(context.Context, string, resolvConf) (answer, error) {
+		t.Fatal("lookup called while disabled")
+		return answer{}, nil
+	}
+	_, _ = r.DialContext(context.Background(), "tcp", "api.example.test:443")
+	if dialed != "api.example.test:443" {
+		t.Fatalf("dialed %q, want the original address", dialed)
+	}
+}
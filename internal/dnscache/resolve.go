@@ -0,0 +1,331 @@
+package dnscache
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/netip"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// resolvConfPath is read for the nameservers when none are configured.
+const resolvConfPath = "/etc/resolv.conf"
+
+// udpResponseSize is the largest UDP response read; larger answers come back truncated
+// and are retried over TCP.
+const udpResponseSize = 1232
+
+// hostsResolver answers from the hosts file only: its DNS transport always fails.
+var hostsResolver = &net.Resolver{
+	PreferGo: true,
+	Dial: func(context.Context, string, string) (net.Conn, error) {
+		return nil, errors.New("dns-cache: hosts file lookup only")
+	},
+}
+
+// resolvConf holds the nameservers and the search rules of resolv.conf.
+type resolvConf struct {
+	servers []string
+	search  []string
+	ndots   int
+}
+
+// names lists the names to query for host in order, applying the search domains the way
+// the system resolver does: names with at least ndots dots are tried as given first.
+func (c resolvConf) names(host string) []string {
+	if strings.HasSuffix(host, ".") || len(c.search) == 0 {
+		return []string{host}
+	}
+	qualified := strings.Count(host, ".") >= c.ndots
+	names := make([]string, 0, len(c.search)+1)
+	if qualified {
+		names = append(names, host)
+	}
+	for _, domain := range c.search {
+		names = append(names, host+"."+domain)
+	}
+	if !qualified {
+		names = append(names, host)
+	}
+	return names
+}
+
+// resolve looks host up in the hosts file, then queries the A and AAAA records of each
+// search name from the nameservers until one exists. The system resolver, which reports no
+// TTL, is used when no nameserver is known.
+func resolve(ctx context.Context, host string, conf resolvConf) (answer, error) {
+	if addrs, err := hostsResolver.LookupNetIP(ctx, "ip", host); err == nil && len(addrs) > 0 {
+		return answer{addrs: unmapAll(addrs)}, nil
+	}
+	if len(conf.servers) == 0 {
+		addrs, err := net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+		return answer{addrs: unmapAll(addrs)}, err
+	}
+	var lastErr error
+	for _, name := range conf.names(host) {
+		ans, err := resolveName(ctx, name, conf.servers)
+		if err == nil {
+			return ans, nil
+		}
+		lastErr = err
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return answer{}, lastErr
+}
+
+// resolveName queries the A and AAAA records of name from servers.
+func resolveName(ctx context.Context, host string, servers []string) (answer, error) {
+
+	type result struct {
+		addrs []netip.Addr
+		ttl   time.Duration
+		err   error
+	}
+	query := func(qtype dnsmessage.Type, out chan<- result) {
+		addrs, ttl, err := queryServers(ctx, host, qtype, servers)
+		out <- result{addrs: addrs, ttl: ttl, err: err}
+	}
+	v6, v4 := make(chan result, 1), make(chan result, 1)
+	go query(dnsmessage.TypeAAAA, v6)
+	go query(dnsmessage.TypeA, v4)
+	r6, r4 := <-v6, <-v4
+	if r6.err != nil && r4.err != nil {
+		return answer{}, r4.err
+	}
+
+	var ans answer
+	for _, r := range []result{r6, r4} {
+		if r.err != nil || len(r.addrs) == 0 {
+			continue
+		}
+		ans.addrs = append(ans.addrs, r.addrs...)
+		if ans.ttl == 0 || r.ttl < ans.ttl {
+			ans.ttl = r.ttl
+		}
+	}
+	if len(ans.addrs) == 0 {
+		return answer{}, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+	return ans, nil
+}
+
+// queryServers asks each server in turn until one answers.
+func queryServers(ctx context.Context, host string, qtype dnsmessage.Type, servers []string) ([]netip.Addr, time.Duration, error) {
+	name, err := dnsmessage.NewName(strings.TrimSuffix(host, ".") + ".")
+	if err != nil {
+		return nil, 0, &net.DNSError{Err: err.Error(), Name: host}
+	}
+	id := uint16(rand.Uint32())
+	msg := dnsmessage.Message{
+		Header:    dnsmessage.Header{ID: id, RecursionDesired: true},
+		Questions: []dnsmessage.Question{{Name: name, Type: qtype, Class: dnsmessage.ClassINET}},
+	}
+	packed, err := msg.Pack()
+	if err != nil {
+		return nil, 0, &net.DNSError{Err: err.Error(), Name: host}
+	}
+
+	var lastErr error
+	for _, server := range servers {
+		resp, errExchange := exchange(ctx, server, packed, id)
+		if errExchange != nil {
+			lastErr = &net.DNSError{Err: errExchange.Error(), Name: host, Server: server, IsTimeout: isTimeout(errExchange)}
+			continue
+		}
+		addrs, ttl, errParse := parseAnswer(resp, id, qtype)
+		if errParse != nil {
+			var dnsErr *net.DNSError
+			if errors.As(errParse, &dnsErr) && dnsErr.IsNotFound {
+				dnsErr.Name, dnsErr.Server = host, server
+				return nil, 0, dnsErr
+			}
+			lastErr = &net.DNSError{Err: errParse.Error(), Name: host, Server: server}
+			continue
+		}
+		return addrs, ttl, nil
+	}
+	return nil, 0, lastErr
+}
+
+// exchange sends query to server over UDP and retries over TCP when the answer is truncated.
+func exchange(ctx context.Context, server string, query []byte, id uint16) ([]byte, error) {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "udp", server)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = conn.Close() }()
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+	if _, err = conn.Write(query); err != nil {
+		return nil, err
+	}
+	buf := make([]byte, udpResponseSize)
+	for {
+		n, errRead := conn.Read(buf)
+		if errRead != nil {
+			return nil, errRead
+		}
+		// Responses to other queries, e.g. late answers of a former socket owner, are skipped.
+		if n < 12 || binary.BigEndian.Uint16(buf) != id {
+			continue
+		}
+		if buf[2]&0x02 != 0 {
+			return exchangeTCP(ctx, server, query)
+		}
+		return buf[:n], nil
+	}
+}
+
+func exchangeTCP(ctx context.Context, server string, query []byte) ([]byte, error) {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", server)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = conn.Close() }()
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+	framed := binary.BigEndian.AppendUint16(make([]byte, 0, len(query)+2), uint16(len(query)))
+	if _, err = conn.Write(append(framed, query...)); err != nil {
+		return nil, err
+	}
+	var size [2]byte
+	if _, err = io.ReadFull(conn, size[:]); err != nil {
+		return nil, err
+	}
+	resp := make([]byte, binary.BigEndian.Uint16(size[:]))
+	if _, err = io.ReadFull(conn, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// parseAnswer returns the qtype addresses of resp and the lowest TTL of its answer records,
+// which covers the CNAME chain leading to them.
+func parseAnswer(resp []byte, id uint16, qtype dnsmessage.Type) ([]netip.Addr, time.Duration, error) {
+	var parser dnsmessage.Parser
+	header, err := parser.Start(resp)
+	if err != nil {
+		return nil, 0, err
+	}
+	if header.ID != id || !header.Response {
+		return nil, 0, errors.New("unexpected DNS response")
+	}
+	switch header.RCode {
+	case dnsmessage.RCodeSuccess:
+	case dnsmessage.RCodeNameError:
+		return nil, 0, &net.DNSError{Err: "no such host", IsNotFound: true}
+	default:
+		return nil, 0, fmt.Errorf("DNS server returned %s", header.RCode)
+	}
+	if err = parser.SkipAllQuestions(); err != nil {
+		return nil, 0, err
+	}
+
+	var (
+		addrs  []netip.Addr
+		minTTL uint32
+		seen   bool
+	)
+	for {
+		h, errHeader := parser.AnswerHeader()
+		if errors.Is(errHeader, dnsmessage.ErrSectionDone) {
+			break
+		}
+		if errHeader != nil {
+			return nil, 0, errHeader
+		}
+		if !seen || h.TTL < minTTL {
+			minTTL, seen = h.TTL, true
+		}
+		switch {
+		case h.Type == qtype && h.Type == dnsmessage.TypeA:
+			a, errA := parser.AResource()
+			if errA != nil {
+				return nil, 0, errA
+			}
+			addrs = append(addrs, netip.AddrFrom4(a.A))
+		case h.Type == qtype && h.Type == dnsmessage.TypeAAAA:
+			aaaa, errAAAA := parser.AAAAResource()
+			if errAAAA != nil {
+				return nil, 0, errAAAA
+			}
+			addrs = append(addrs, netip.AddrFrom16(aaaa.AAAA))
+		default:
+			if err = parser.SkipAnswer(); err != nil {
+				return nil, 0, err
+			}
+		}
+	}
+	return addrs, time.Duration(minTTL) * time.Second, nil
+}
+
+// readResolvConf returns the nameservers, search domains and ndots option of the
+// resolv.conf file at path. The last search or domain line wins, as with the system
+// resolver.
+func readResolvConf(path string) resolvConf {
+	conf := resolvConf{ndots: 1}
+	file, err := os.Open(path)
+	if err != nil {
+		return conf
+	}
+	defer func() { _ = file.Close() }()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+		switch fields[0] {
+		case "nameserver":
+			if _, err = netip.ParseAddr(fields[1]); err == nil {
+				conf.servers = append(conf.servers, net.JoinHostPort(fields[1], "53"))
+			}
+		case "domain":
+			conf.search = []string{strings.TrimSuffix(fields[1], ".")}
+		case "search":
+			conf.search = conf.search[:0:0]
+			for _, domain := range fields[1:] {
+				if domain = strings.TrimSuffix(domain, "."); domain != "" {
+					conf.search = append(conf.search, domain)
+				}
+			}
+		case "options":
+			for _, option := range fields[1:] {
+				if value, ok := strings.CutPrefix(option, "ndots:"); ok {
+					if n, errAtoi := strconv.Atoi(value); errAtoi == nil && n >= 0 {
+						conf.ndots = min(n, 15)
+					}
+				}
+			}
+		}
+	}
+	return conf
+}
+
+func unmapAll(addrs []netip.Addr) []netip.Addr {
+	for i, addr := range addrs {
+		addrs[i] = addr.Unmap()
+	}
+	return addrs
+}
+
+func isTimeout(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
@@ -0,0 +1,200 @@
+// Package faultinject injects faults into proxied requests for resilience testing: upstream
+// attempts are delayed or failed with configured status codes, and streamed responses get
+// malformed SSE events or lose their connection, each at configured probabilities per
+// provider and model.
+package faultinject
+
+import (
+	"context"
+	"math/rand/v2"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	log "github.com/sirupsen/logrus"
+)
+
+// StreamFault is the fault injected before a streamed chunk.
+type StreamFault int
+
+// Stream faults.
+const (
+	NoFault StreamFault = iota
+	MalformedChunk
+	Disconnect
+)
+
+// MalformedEvent is the invalid SSE event written for MalformedChunk.
+const MalformedEvent = "data: {\"injected_fault\": \"malformed chunk\n\n"
+
+var defaultInjector = NewInjector()
+
+// Default returns the process-wide injector.
+func Default() *Injector { return defaultInjector }
+
+// Injector decides which faults to inject.
+type Injector struct {
+	mu     sync.RWMutex
+	cfg    config.FaultInjectionConfig
+	random func() float64
+	sleep  func(ctx context.Context, d time.Duration) error
+}
+
+// NewInjector constructs a disabled injector.
+func NewInjector() *Injector {
+	return &Injector{random: rand.Float64, sleep: sleepContext}
+}
+
+// Configure replaces the rules.
+func (i *Injector) Configure(cfg config.FaultInjectionConfig) {
+	if i == nil {
+		return
+	}
+	if cfg.Enable && len(cfg.Rules) > 0 {
+		log.Warnf("fault-injection: enabled with %d rule(s); requests will be delayed, failed or cut on purpose", len(cfg.Rules))
+	}
+	i.mu.Lock()
+	i.cfg = cfg
+	i.mu.Unlock()
+}
+
+// Enabled reports whether any fault may be injected.
+func (i *Injector) Enabled() bool {
+	if i == nil {
+		return false
+	}
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	return i.cfg.Enable && len(i.cfg.Rules) > 0
+}
+
+// rule returns the first rule matching provider and model.
+func (i *Injector) rule(provider, model string) (config.FaultInjectionRule, bool) {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	if !i.cfg.Enable {
+		return config.FaultInjectionRule{}, false
+	}
+	provider = strings.ToLower(provider)
+	for _, rule := range i.cfg.Rules {
+		if rule.Provider != "" && rule.Provider != provider {
+			continue
+		}
+		if len(rule.Models) > 0 && !matchesAny(rule.Models, model) {
+			continue
+		}
+		return rule, true
+	}
+	return config.FaultInjectionRule{}, false
+}
+
+func matchesAny(patterns []string, model string) bool {
+	for _, pattern := range patterns {
+		if util.MatchWildcard(pattern, model) {
+			return true
+		}
+	}
+	return false
+}
+
+func (i *Injector) roll(p float64) bool {
+	return p > 0 && i.random() < p
+}
+
+// BeforeAttempt applies the latency and error faults of an upstream attempt. A non-nil
+// error replaces the provider call.
+func (i *Injector) BeforeAttempt(ctx context.Context, provider, model string) error {
+	if i == nil {
+		return nil
+	}
+	rule, ok := i.rule(provider, model)
+	if !ok {
+		return nil
+	}
+	if i.roll(rule.LatencyProbability) {
+		delay := time.Duration(rule.LatencyMs) * time.Millisecond
+		if rule.LatencyJitterMs > 0 {
+			delay += time.Duration(i.random() * float64(time.Duration(rule.LatencyJitterMs)*time.Millisecond))
+		}
+		if err := i.sleep(ctx, delay); err != nil {
+			return err
+		}
+	}
+	if i.roll(rule.ErrorProbability) {
+		log.Debugf("fault-injection: failing %s/%s with %d", provider, model, rule.ErrorStatus)
+		return &statusError{code: rule.ErrorStatus, message: rule.ErrorMessage}
+	}
+	return nil
+}
+
+// StreamChunkFault returns the fault to inject before the next streamed chunk.
+func (i *Injector) StreamChunkFault(provider, model string) StreamFault {
+	if i == nil || provider == "" {
+		return NoFault
+	}
+	rule, ok := i.rule(provider, model)
+	if !ok {
+		return NoFault
+	}
+	switch {
+	case i.roll(rule.DisconnectProbability):
+		return Disconnect
+	case i.roll(rule.MalformedChunkProbability):
+		return MalformedChunk
+	}
+	return NoFault
+}
+
+// Wrap implements coreauth.ExecutorWrapper.
+func (i *Injector) Wrap(provider string, executor coreauth.ProviderExecutor) coreauth.ProviderExecutor {
+	if !i.Enabled() {
+		return executor
+	}
+	return &faultyExecutor{ProviderExecutor: executor, injector: i, provider: provider}
+}
+
+type faultyExecutor struct {
+	coreauth.ProviderExecutor
+	injector *Injector
+	provider string
+}
+
+func (e *faultyExecutor) Execute(ctx context.Context, auth *coreauth.Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+	if err := e.injector.BeforeAttempt(ctx, e.provider, req.Model); err != nil {
+		return cliproxyexecutor.Response{}, err
+	}
+	return e.ProviderExecutor.Execute(ctx, auth, req, opts)
+}
+
+func (e *faultyExecutor) ExecuteStream(ctx context.Context, auth *coreauth.Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (<-chan cliproxyexecutor.StreamChunk, error) {
+	if err := e.injector.BeforeAttempt(ctx, e.provider, req.Model); err != nil {
+		return nil, err
+	}
+	return e.ProviderExecutor.ExecuteStream(ctx, auth, req, opts)
+}
+
+type statusError struct {
+	code    int
+	message string
+}
+
+func (e *statusError) Error() string   { return e.message }
+func (e *statusError) StatusCode() int { return e.code }
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,162 @@
+// Package featureflag gates experimental behaviors behind named flags that are set in the
+// config file and can be toggled at runtime through the management API. A flag gates a
+// behavior on top of the behavior's own settings: disabling speculative-racing stops races
+// even for models with a race rule, while enabling it changes nothing without one.
+package featureflag
+
+import (
+	"sort"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Known flags.
+const (
+	// SpeculativeRacing gates the speculative-racing rules.
+	SpeculativeRacing = "speculative-racing"
+	// SemanticCache gates the semantic response cache.
+	SemanticCache = "semantic-cache"
+	// ShadowTraffic gates mirroring of requests to the shadow upstream.
+	ShadowTraffic = "shadow-traffic"
+)
+
+// Known lists the flags gating behaviors of the proxy.
+func Known() []string {
+	return []string{SemanticCache, ShadowTraffic, SpeculativeRacing}
+}
+
+// IsKnown reports whether name is one of Known.
+func IsKnown(name string) bool { return known(name) }
+
+func known(name string) bool {
+	for _, flag := range Known() {
+		if flag == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Sources of a flag value.
+const (
+	SourceDefault = "default"
+	SourceConfig  = "config"
+	SourceRuntime = "runtime"
+)
+
+// Flag is the state of one flag.
+type Flag struct {
+	Name    string `json:"name"`
+	Enabled bool   `json:"enabled"`
+	// Source is where the value comes from: "default", "config" or "runtime".
+	Source string `json:"source"`
+}
+
+var defaultFlags = New()
+
+// Default returns the process-wide flags.
+func Default() *Flags { return defaultFlags }
+
+// Enabled reports whether the process-wide flag name is on.
+func Enabled(name string) bool { return defaultFlags.Enabled(name) }
+
+// Flags holds the configured flag values and the runtime overrides. Unset flags are on.
+type Flags struct {
+	mu         sync.RWMutex
+	configured map[string]bool
+	overrides  map[string]bool
+}
+
+// New constructs flags with every flag on.
+func New() *Flags {
+	return &Flags{configured: map[string]bool{}, overrides: map[string]bool{}}
+}
+
+// Configure replaces the values from the config file. Runtime overrides are kept.
+func (f *Flags) Configure(flags map[string]bool) {
+	if f == nil {
+		return
+	}
+	configured := make(map[string]bool, len(flags))
+	for name, enabled := range flags {
+		if !known(name) {
+			log.Warnf("feature-flags: unknown flag %q", name)
+		}
+		configured[name] = enabled
+	}
+	f.mu.Lock()
+	f.configured = configured
+	f.mu.Unlock()
+}
+
+// Set overrides the value of flag name until Reset, across config reloads.
+func (f *Flags) Set(name string, enabled bool) {
+	if f == nil {
+		return
+	}
+	f.mu.Lock()
+	f.overrides[name] = enabled
+	f.mu.Unlock()
+}
+
+// Reset drops the runtime override of flag name, returning it to its configured value.
+func (f *Flags) Reset(name string) {
+	if f == nil {
+		return
+	}
+	f.mu.Lock()
+	delete(f.overrides, name)
+	f.mu.Unlock()
+}
+
+// Enabled reports whether flag name is on.
+func (f *Flags) Enabled(name string) bool {
+	return f.Get(name).Enabled
+}
+
+// Get returns the state of flag name.
+func (f *Flags) Get(name string) Flag {
+	if f == nil {
+		return Flag{Name: name, Enabled: true, Source: SourceDefault}
+	}
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.getLocked(name)
+}
+
+func (f *Flags) getLocked(name string) Flag {
+	if enabled, ok := f.overrides[name]; ok {
+		return Flag{Name: name, Enabled: enabled, Source: SourceRuntime}
+	}
+	if enabled, ok := f.configured[name]; ok {
+		return Flag{Name: name, Enabled: enabled, Source: SourceConfig}
+	}
+	return Flag{Name: name, Enabled: true, Source: SourceDefault}
+}
+
+// List returns the state of the known flags and of any other configured or overridden
+// flag, sorted by name.
+func (f *Flags) List() []Flag {
+	names := map[string]struct{}{}
+	for _, name := range Known() {
+		names[name] = struct{}{}
+	}
+	if f == nil {
+		f = New()
+	}
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	for name := range f.configured {
+		names[name] = struct{}{}
+	}
+	for name := range f.overrides {
+		names[name] = struct{}{}
+	}
+	out := make([]Flag, 0, len(names))
+	for name := range names {
+		out = append(out, f.getLocked(name))
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
@@ -0,0 +1,31 @@
+package featureflag
+
+import "testing"
+
+func TestFlagsPrecedence(t *testing.T) {
+	f := New()
+	if got := f.Get(SemanticCache); !got.Enabled || got.Source != SourceDefault {
+		t.Fatalf("unset flag = %+v, want on by default", got)
+	}
+
+	f.Configure(map[string]bool{SemanticCache: false, "custom": true})
+	if f.Enabled(SemanticCache) {
+		t.Fatal("configured flag should be off")
+	}
+
+	f.Set(SemanticCache, true)
+	f.Configure(map[string]bool{SemanticCache: false})
+	if got := f.Get(SemanticCache); !got.Enabled || got.Source != SourceRuntime {
+		t.Fatalf("override after reload = %+v, want runtime on", got)
+	}
+
+	f.Reset(SemanticCache)
+	if got := f.Get(SemanticCache); got.Enabled || got.Source != SourceConfig {
+		t.Fatalf("after reset = %+v, want configured off", got)
+	}
+
+	list := f.List()
+	if len(list) != 3 || list[0].Name != SemanticCache || list[2].Name != SpeculativeRacing {
+		t.Fatalf("List = %+v", list)
+	}
+}
@@ -17,4 +17,8 @@ type ErrorMessage struct {
 
 	// Addon contains additional headers to be added to the response.
 	Addon http.Header
+
+	// Code optionally classifies the error (e.g. "no_provider"); when empty it is derived
+	// from Error and StatusCode when the response is written.
+	Code string
 }
@@ -0,0 +1,288 @@
+// Package ipaccess enforces the client IP and country allowlists and denylists of the
+// inference and management endpoints and of individual listeners.
+package ipaccess
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	log "github.com/sirupsen/logrus"
+)
+
+// Surface selects which rules apply to a request.
+type Surface int
+
+const (
+	// Inference covers every route but the management API.
+	Inference Surface = iota
+	// Management covers /v0/management and the control panel.
+	Management
+)
+
+// Filter holds the compiled rules. The zero value allows everything.
+type Filter struct {
+	state atomic.Pointer[state]
+}
+
+type state struct {
+	trusted       []netip.Prefix
+	countryHeader string
+	rules         [2]rules
+	listeners     []listenerRules
+}
+
+// listenerRules are the rules of one listening address. An invalid host matches every
+// local address on port.
+type listenerRules struct {
+	host  netip.Addr
+	port  uint16
+	rules rules
+}
+
+type rules struct {
+	allow          []netip.Prefix
+	deny           []netip.Prefix
+	allowCountries map[string]bool
+	denyCountries  map[string]bool
+}
+
+func (r rules) empty() bool {
+	return len(r.allow) == 0 && len(r.deny) == 0 && len(r.allowCountries) == 0 && len(r.denyCountries) == 0
+}
+
+var defaultFilter = &Filter{}
+
+// Default returns the process-wide filter.
+func Default() *Filter { return defaultFilter }
+
+// Configure compiles cfg. Invalid entries are logged and skipped.
+func (f *Filter) Configure(cfg config.IPAccessConfig) {
+	if f == nil {
+		return
+	}
+	st := &state{
+		trusted:       parsePrefixes("trusted-proxies", cfg.TrustedProxies),
+		countryHeader: cfg.CountryHeader,
+	}
+	for surface, src := range map[Surface]config.IPAccessRules{Inference: cfg.Inference, Management: cfg.Management} {
+		st.rules[surface] = compileRules(src)
+	}
+	for _, listener := range cfg.Listeners {
+		host, port, err := parseListenAddr(listener.Addr)
+		if err != nil {
+			log.Warnf("ip-access: ignoring listener %q: %v", listener.Addr, err)
+			continue
+		}
+		st.listeners = append(st.listeners, listenerRules{host: host, port: port, rules: compileRules(listener.IPAccessRules)})
+	}
+	f.state.Store(st)
+}
+
+func compileRules(src config.IPAccessRules) rules {
+	return rules{
+		allow:          parsePrefixes("allow", src.Allow),
+		deny:           parsePrefixes("deny", src.Deny),
+		allowCountries: countrySet(src.AllowCountries),
+		denyCountries:  countrySet(src.DenyCountries),
+	}
+}
+
+// parseListenAddr splits a listen address. Empty and unspecified hosts match any address.
+func parseListenAddr(addr string) (netip.Addr, uint16, error) {
+	host, portText, err := net.SplitHostPort(addr)
+	if err != nil {
+		return netip.Addr{}, 0, err
+	}
+	port, err := strconv.ParseUint(portText, 10, 16)
+	if err != nil {
+		return netip.Addr{}, 0, fmt.Errorf("invalid port %q", portText)
+	}
+	if host == "" {
+		return netip.Addr{}, uint16(port), nil
+	}
+	ip, err := netip.ParseAddr(host)
+	if err != nil {
+		return netip.Addr{}, 0, err
+	}
+	if ip.IsUnspecified() {
+		return netip.Addr{}, uint16(port), nil
+	}
+	return ip.Unmap(), uint16(port), nil
+}
+
+// ClientIP returns the client address of r. Forwarded headers are only honored from
+// trusted proxies: X-Forwarded-For is walked from the right, skipping trusted hops.
+func (f *Filter) ClientIP(r *http.Request) netip.Addr {
+	var st *state
+	if f != nil {
+		st = f.state.Load()
+	}
+	return clientIP(st, r)
+}
+
+// Allowed reports whether the client of r may reach surface through the listener the
+// request arrived on.
+func (f *Filter) Allowed(r *http.Request, surface Surface) bool {
+	if f == nil {
+		return true
+	}
+	st := f.state.Load()
+	if st == nil {
+		return true
+	}
+	if listener, ok := st.listenerOf(r); ok && !st.admits(listener, r) {
+		return false
+	}
+	return st.admits(st.rules[surface], r)
+}
+
+// listenerOf returns the rules of the listener r arrived on. Rules naming the exact host
+// win over those for every address on the port.
+func (st *state) listenerOf(r *http.Request) (rules, bool) {
+	if len(st.listeners) == 0 {
+		return rules{}, false
+	}
+	local, _ := r.Context().Value(http.LocalAddrContextKey).(net.Addr)
+	if local == nil {
+		return rules{}, false
+	}
+	addrPort, err := netip.ParseAddrPort(local.String())
+	if err != nil {
+		return rules{}, false
+	}
+	host, port := addrPort.Addr().Unmap(), addrPort.Port()
+	var wildcard *listenerRules
+	for i := range st.listeners {
+		listener := &st.listeners[i]
+		if listener.port != port {
+			continue
+		}
+		if listener.host == host {
+			return listener.rules, true
+		}
+		if !listener.host.IsValid() && wildcard == nil {
+			wildcard = listener
+		}
+	}
+	if wildcard != nil {
+		return wildcard.rules, true
+	}
+	return rules{}, false
+}
+
+// admits reports whether rs let the client of r through.
+func (st *state) admits(rs rules, r *http.Request) bool {
+	if rs.empty() {
+		return true
+	}
+	ip := clientIP(st, r)
+	if contains(rs.deny, ip) {
+		return false
+	}
+	if len(rs.allow) > 0 && !contains(rs.allow, ip) {
+		return false
+	}
+	if len(rs.allowCountries) == 0 && len(rs.denyCountries) == 0 {
+		return true
+	}
+	country := ""
+	if st.countryHeader != "" && fromTrustedProxy(st, r) {
+		country = strings.ToUpper(strings.TrimSpace(r.Header.Get(st.countryHeader)))
+	}
+	if rs.denyCountries[country] {
+		return false
+	}
+	return len(rs.allowCountries) == 0 || rs.allowCountries[country]
+}
+
+func clientIP(st *state, r *http.Request) netip.Addr {
+	remote := remoteAddr(r)
+	if st == nil || len(st.trusted) == 0 || !contains(st.trusted, remote) {
+		return remote
+	}
+	if forwarded := r.Header.Values("X-Forwarded-For"); len(forwarded) > 0 {
+		hops := strings.Split(strings.Join(forwarded, ","), ",")
+		for i := len(hops) - 1; i >= 0; i-- {
+			hop, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+			if err != nil {
+				break
+			}
+			hop = hop.Unmap()
+			if !contains(st.trusted, hop) || i == 0 {
+				return hop
+			}
+		}
+	}
+	if real, err := netip.ParseAddr(strings.TrimSpace(r.Header.Get("X-Real-IP"))); err == nil {
+		return real.Unmap()
+	}
+	return remote
+}
+
+func fromTrustedProxy(st *state, r *http.Request) bool {
+	return contains(st.trusted, remoteAddr(r))
+}
+
+func remoteAddr(r *http.Request) netip.Addr {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return netip.Addr{}
+	}
+	return addr.Unmap()
+}
+
+func contains(prefixes []netip.Prefix, ip netip.Addr) bool {
+	if !ip.IsValid() {
+		return false
+	}
+	for _, prefix := range prefixes {
+		if prefix.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func parsePrefixes(field string, values []string) []netip.Prefix {
+	var out []netip.Prefix
+	for _, value := range values {
+		if strings.Contains(value, "/") {
+			prefix, err := netip.ParsePrefix(value)
+			if err != nil {
+				log.Warnf("ip-access: ignoring invalid %s entry %q: %v", field, value, err)
+				continue
+			}
+			out = append(out, prefix.Masked())
+			continue
+		}
+		addr, err := netip.ParseAddr(value)
+		if err != nil {
+			log.Warnf("ip-access: ignoring invalid %s entry %q: %v", field, value, err)
+			continue
+		}
+		addr = addr.Unmap()
+		out = append(out, netip.PrefixFrom(addr, addr.BitLen()))
+	}
+	return out
+}
+
+func countrySet(codes []string) map[string]bool {
+	if len(codes) == 0 {
+		return nil
+	}
+	set := make(map[string]bool, len(codes))
+	for _, code := range codes {
+		set[strings.ToUpper(code)] = true
+	}
+	return set
+}
@@ -0,0 +1,87 @@
+package ipaccess
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+func TestFilterRules(t *testing.T) {
+	f := &Filter{}
+	f.Configure(config.IPAccessConfig{
+		TrustedProxies: []string{"10.0.0.0/8"},
+		CountryHeader:  "CF-IPCountry",
+		Inference:      config.IPAccessRules{Deny: []string{"203.0.113.7"}, DenyCountries: []string{"XX"}},
+		Management:     config.IPAccessRules{Allow: []string{"192.168.1.0/24", "::1"}},
+	})
+
+	cases := []struct {
+		name      string
+		remote    string
+		forwarded string
+		country   string
+		surface   Surface
+		want      bool
+	}{
+		{"inference from anywhere", "198.51.100.1:1234", "", "", Inference, true},
+		{"denied address", "203.0.113.7:1234", "", "", Inference, false},
+		{"denied address behind trusted proxy", "10.0.0.2:1234", "203.0.113.7, 10.0.0.9", "", Inference, false},
+		{"spoofed header from untrusted client", "198.51.100.1:1234", "203.0.113.7", "", Inference, true},
+		{"denied country via trusted proxy", "10.0.0.2:1234", "198.51.100.1", "xx", Inference, false},
+		{"country header from untrusted client", "198.51.100.1:1234", "", "XX", Inference, true},
+		{"management from allowed network", "192.168.1.20:1234", "", "", Management, true},
+		{"management from ipv6 loopback", "[::1]:1234", "", "", Management, true},
+		{"management from elsewhere", "198.51.100.1:1234", "", "", Management, false},
+		{"management spoofing allowed address", "198.51.100.1:1234", "192.168.1.20", "", Management, false},
+	}
+	for _, tc := range cases {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.RemoteAddr = tc.remote
+		if tc.forwarded != "" {
+			req.Header.Set("X-Forwarded-For", tc.forwarded)
+		}
+		if tc.country != "" {
+			req.Header.Set("CF-IPCountry", tc.country)
+		}
+		if got := f.Allowed(req, tc.surface); got != tc.want {
+			t.Errorf("%s: allowed = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestFilterListenerRules(t *testing.T) {
+	f := &Filter{}
+	f.Configure(config.IPAccessConfig{
+		Listeners: []config.IPAccessListener{
+			{Addr: ":8318", IPAccessRules: config.IPAccessRules{Allow: []string{"10.0.0.0/8"}}},
+			{Addr: "127.0.0.1:8318"},
+		},
+		Inference: config.IPAccessRules{Deny: []string{"10.0.0.66"}},
+	})
+	request := func(local, remote string) *http.Request {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.RemoteAddr = remote
+		addr, _ := net.ResolveTCPAddr("tcp", local)
+		return req.WithContext(context.WithValue(req.Context(), http.LocalAddrContextKey, addr))
+	}
+	cases := []struct {
+		name          string
+		local, remote string
+		want          bool
+	}{
+		{"other listener", "192.0.2.1:8317", "198.51.100.1:1234", true},
+		{"restricted listener refuses", "192.0.2.1:8318", "198.51.100.1:1234", false},
+		{"restricted listener admits", "192.0.2.1:8318", "10.1.2.3:1234", true},
+		{"exact host wins over the port", "127.0.0.1:8318", "198.51.100.1:1234", true},
+		{"surface rules still apply", "192.0.2.1:8318", "10.0.0.66:1234", false},
+	}
+	for _, tc := range cases {
+		if got := f.Allowed(request(tc.local, tc.remote), Inference); got != tc.want {
+			t.Errorf("%s: allowed = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
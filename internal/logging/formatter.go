@@ -0,0 +1,67 @@
+package logging
+
+import (
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	log "github.com/sirupsen/logrus"
+)
+
+// componentFormatter drops entries below the level of their component or past the sampling
+// cap of their component before formatting. The global logrus level is set to the most
+// verbose configured level so that entries of components raised above the base level reach it.
+type componentFormatter struct {
+	inner      log.Formatter
+	base       log.Level
+	components map[string]log.Level
+	sampler    *logSampler
+}
+
+// newFormatter builds the formatter selected by cfg.Logging.Format.
+func newFormatter(cfg *config.Config) log.Formatter {
+	var inner log.Formatter = &LogFormatter{}
+	if cfg.Logging.Format == config.LogFormatJSON {
+		inner = &log.JSONFormatter{
+			TimestampFormat: time.RFC3339Nano,
+			CallerPrettyfier: func(frame *runtime.Frame) (string, string) {
+				return "", fmt.Sprintf("%s:%d", filepath.Base(frame.File), frame.Line)
+			},
+		}
+	}
+	base, components := cfg.LogLevels()
+	sampler := configureSampler(cfg.Logging.Sampling)
+	if len(components) == 0 && sampler == nil {
+		return inner
+	}
+	return &componentFormatter{inner: inner, base: base, components: components, sampler: sampler}
+}
+
+// Format implements logrus.Formatter. Dropped entries produce no output.
+func (f *componentFormatter) Format(entry *log.Entry) ([]byte, error) {
+	if summary, _ := entry.Data[samplingSummaryField].(bool); summary {
+		delete(entry.Data, samplingSummaryField)
+		return f.inner.Format(entry)
+	}
+	threshold := f.base
+	if level, ok := f.components[entryComponent(entry)]; ok {
+		threshold = level
+	}
+	if entry.Level > threshold || !f.sampler.allow(entry) {
+		return nil, nil
+	}
+	return f.inner.Format(entry)
+}
+
+// entryComponent is the "component" field of entry, or else the directory of its caller.
+func entryComponent(entry *log.Entry) string {
+	if component, ok := entry.Data["component"].(string); ok && component != "" {
+		return component
+	}
+	if entry.Caller != nil {
+		return filepath.Base(filepath.Dir(entry.Caller.File))
+	}
+	return ""
+}
@@ -0,0 +1,51 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"runtime"
+	"testing"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	log "github.com/sirupsen/logrus"
+)
+
+func TestComponentFormatter_FiltersByComponentLevel(t *testing.T) {
+	cfg := &config.Config{Logging: config.LoggingConfig{
+		Format:     config.LogFormatJSON,
+		Components: map[string]string{"amp-routing": "debug", "executor": "warn"},
+	}}
+	logger := log.New()
+	var out bytes.Buffer
+	logger.SetOutput(&out)
+	logger.SetLevel(log.DebugLevel)
+	logger.SetFormatter(newFormatter(cfg))
+
+	logger.WithField("component", "amp-routing").Debug("routed")
+	logger.Debug("base debug")
+	logger.Info("base info")
+	entry := log.NewEntry(logger)
+	entry.Caller = &runtime.Frame{File: "/src/internal/runtime/executor/claude_executor.go", Line: 7}
+	entry.Level = log.InfoLevel
+	if formatted, err := logger.Formatter.Format(entry); err != nil || len(formatted) != 0 {
+		t.Fatalf("executor info should be dropped, got %q %v", formatted, err)
+	}
+
+	var messages []string
+	for _, line := range bytes.Split(bytes.TrimSpace(out.Bytes()), []byte("\n")) {
+		var decoded map[string]any
+		if err := json.Unmarshal(line, &decoded); err != nil {
+			t.Fatalf("line %q is not JSON: %v", line, err)
+		}
+		messages = append(messages, decoded["msg"].(string))
+	}
+	if len(messages) != 2 || messages[0] != "routed" || messages[1] != "base info" {
+		t.Fatalf("messages = %v", messages)
+	}
+}
+
+func TestNewFormatter_DefaultsToText(t *testing.T) {
+	if _, ok := newFormatter(&config.Config{}).(*LogFormatter); !ok {
+		t.Fatal("configs without components or json format should use the text formatter")
+	}
+}
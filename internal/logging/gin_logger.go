@@ -40,7 +40,7 @@ const skipGinLogKey = "__gin_skip_request_logging__"
 func GinLogrusLogger() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		start := time.Now()
-		path := c.Request.URL.Path
+		path := util.MaskSensitivePath(c.Request.URL.Path)
 		raw := util.MaskSensitiveQuery(c.Request.URL.RawQuery)
 
 		// Only generate request ID for AI API paths
@@ -121,7 +121,7 @@ func GinLogrusRecovery() gin.HandlerFunc {
 		log.WithFields(log.Fields{
 			"panic": recovered,
 			"stack": string(debug.Stack()),
-			"path":  c.Request.URL.Path,
+			"path":  util.MaskSensitivePath(c.Request.URL.Path),
 		}).Error("recovered from panic")
 
 		c.AbortWithStatus(http.StatusInternalServerError)
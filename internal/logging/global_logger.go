@@ -142,32 +142,43 @@ func ResolveLogDirectory(cfg *config.Config) string {
 	return logDir
 }
 
-// ConfigureLogOutput switches the global log destination between rotating files and stdout.
+// ConfigureLogOutput switches the global log destination between rotating files and stdout
+// and applies the log language, format and component levels.
 // When logsMaxTotalSizeMB > 0, a background cleaner removes the oldest log files in the logs directory
 // until the total size is within the limit.
 func ConfigureLogOutput(cfg *config.Config) error {
 	SetupBaseLogger()
 
+	SetLanguage(cfg.LogLanguage)
+	log.SetFormatter(newFormatter(cfg))
+
 	writerMu.Lock()
 	defer writerMu.Unlock()
 
 	logDir := ResolveLogDirectory(cfg)
 
 	protectedPath := ""
-	if cfg.LoggingToFile {
-		if err := os.MkdirAll(logDir, 0o755); err != nil {
+	if cfg.LoggingToFile || cfg.Logging.File != "" {
+		protectedPath = filepath.Join(logDir, "main.log")
+		if cfg.Logging.File != "" {
+			protectedPath = cfg.Logging.File
+		}
+		if err := os.MkdirAll(filepath.Dir(protectedPath), 0o755); err != nil {
 			return fmt.Errorf("logging: failed to create log directory: %w", err)
 		}
 		if logWriter != nil {
 			_ = logWriter.Close()
 		}
-		protectedPath = filepath.Join(logDir, "main.log")
+		maxSize := cfg.Logging.MaxSizeMB
+		if maxSize <= 0 {
+			maxSize = config.DefaultLogMaxSizeMB
+		}
 		logWriter = &lumberjack.Logger{
 			Filename:   protectedPath,
-			MaxSize:    10,
-			MaxBackups: 0,
-			MaxAge:     0,
-			Compress:   false,
+			MaxSize:    maxSize,
+			MaxBackups: cfg.Logging.MaxBackups,
+			MaxAge:     cfg.Logging.MaxAgeDays,
+			Compress:   cfg.Logging.Compress,
 		}
 		log.SetOutput(logWriter)
 	} else {
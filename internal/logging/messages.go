@@ -0,0 +1,88 @@
+package logging
+
+import (
+	"fmt"
+	"strings"
+	"sync/atomic"
+)
+
+// Supported log languages.
+const (
+	LanguageEnglish = "en"
+	LanguageChinese = "zh"
+)
+
+// MessageID identifies an operator-facing log message with translations.
+type MessageID string
+
+// Localized messages. The arguments of each translation are in the same order.
+const (
+	MsgAmpCreditsForward      MessageID = "amp.credits.forward"
+	MsgAmpNoProvider          MessageID = "amp.no_provider"
+	MsgAmpCreditsUsed         MessageID = "amp.credits.used"
+	MsgAmpCreditsUsedUnpriced MessageID = "amp.credits.used_unpriced"
+	MsgAmpCompactionNoFit     MessageID = "amp.compaction.no_fit"
+	MsgAmpMappingInvalid      MessageID = "amp.mapping.invalid"
+	MsgAmpManagementDenied    MessageID = "amp.management.denied"
+	MsgAmpUpstreamFailover    MessageID = "amp.upstream.failover"
+	MsgAmpUpstreamUnhealthy   MessageID = "amp.upstream.unhealthy"
+	MsgAmpUpstreamHealthy     MessageID = "amp.upstream.healthy"
+)
+
+var messageCatalog = map[string]map[MessageID]string{
+	LanguageEnglish: {
+		MsgAmpCreditsForward:      "forwarding to ampcode.com (uses amp credits) - model_id: %s | To use local provider, add to config: ampcode.model-mappings: [{from: \"%s\", to: \"<your-local-model>\"}]",
+		MsgAmpNoProvider:          "no provider available for model_id: %s",
+		MsgAmpCreditsUsed:         "amp credits used - model_id: %s | %d input / %d output tokens | estimated cost %.4f credits (saved by mapping it to a local model)",
+		MsgAmpCreditsUsedUnpriced: "amp credits used - model_id: %s | %d input / %d output tokens (no price known; add ampcode.credit-pricing to estimate cost)",
+		MsgAmpCompactionNoFit:     "amp context compaction: conversation of ~%d tokens does not fit %s (%d tokens) and has no turn to compact at",
+		MsgAmpMappingInvalid:      "amp model mapping: skipping invalid mapping (from=%q, to=%q)",
+		MsgAmpManagementDenied:    "amp management: non-localhost connection from %s attempted access, denying",
+		MsgAmpUpstreamFailover:    "amp upstream %s failed for %s %s (%v), failing over",
+		MsgAmpUpstreamUnhealthy:   "amp upstream %s marked unhealthy: %s",
+		MsgAmpUpstreamHealthy:     "amp upstream %s is healthy again",
+	},
+	LanguageChinese: {
+		MsgAmpCreditsForward:      "转发到 ampcode.com（消耗 AMP 积分）- model_id: %s | 如需使用本地提供商，请在配置中添加：ampcode.model-mappings: [{from: \"%s\", to: \"<你的本地模型>\"}]",
+		MsgAmpNoProvider:          "没有可用于 model_id 的提供商：%s",
+		MsgAmpCreditsUsed:         "已消耗 AMP 积分 - model_id: %s | 输入 %d / 输出 %d tokens | 预估费用 %.4f 积分（映射到本地模型即可节省）",
+		MsgAmpCreditsUsedUnpriced: "已消耗 AMP 积分 - model_id: %s | 输入 %d / 输出 %d tokens（价格未知；添加 ampcode.credit-pricing 以估算费用）",
+		MsgAmpCompactionNoFit:     "AMP 上下文压缩：约 %d tokens 的对话超出 %s 的上下文窗口（%d tokens），且没有可压缩的轮次",
+		MsgAmpMappingInvalid:      "AMP 模型映射：跳过无效映射（from=%q, to=%q）",
+		MsgAmpManagementDenied:    "AMP 管理接口：拒绝来自非本机地址 %s 的访问",
+		MsgAmpUpstreamFailover:    "AMP 上游 %s 处理 %s %s 失败（%v），切换到备用上游",
+		MsgAmpUpstreamUnhealthy:   "AMP 上游 %s 被标记为不健康：%s",
+		MsgAmpUpstreamHealthy:     "AMP 上游 %s 已恢复健康",
+	},
+}
+
+var logLanguage atomic.Value
+
+// SetLanguage selects the language of localized log messages. Unknown languages use English.
+func SetLanguage(language string) {
+	language = strings.ToLower(strings.TrimSpace(language))
+	if _, ok := messageCatalog[language]; !ok {
+		language = LanguageEnglish
+	}
+	logLanguage.Store(language)
+}
+
+// Language returns the language of localized log messages.
+func Language() string {
+	if language, ok := logLanguage.Load().(string); ok {
+		return language
+	}
+	return LanguageEnglish
+}
+
+// Msgf formats the message id in the configured language, falling back to English for
+// messages without a translation.
+func Msgf(id MessageID, args ...any) string {
+	format, ok := messageCatalog[Language()][id]
+	if !ok {
+		if format, ok = messageCatalog[LanguageEnglish][id]; !ok {
+			return string(id)
+		}
+	}
+	return fmt.Sprintf(format, args...)
+}
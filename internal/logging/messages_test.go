@@ -0,0 +1,43 @@
+package logging
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestMessageCatalog_TranslationsMatchEnglish(t *testing.T) {
+	verbs := regexp.MustCompile(`%[-+# 0-9.]*[a-zA-Z]`)
+	english := messageCatalog[LanguageEnglish]
+	for language, messages := range messageCatalog {
+		if len(messages) != len(english) {
+			t.Fatalf("%s has %d messages, en has %d", language, len(messages), len(english))
+		}
+		for id, format := range messages {
+			want := verbs.FindAllString(english[id], -1)
+			got := verbs.FindAllString(format, -1)
+			if strings.Join(got, " ") != strings.Join(want, " ") {
+				t.Fatalf("%s %s: verbs %v, en has %v", language, id, got, want)
+			}
+		}
+	}
+}
+
+func TestMsgf_UsesConfiguredLanguage(t *testing.T) {
+	defer SetLanguage(LanguageEnglish)
+
+	SetLanguage("ZH")
+	if got := Msgf(MsgAmpUpstreamHealthy, "ampcode.com"); got != "AMP 上游 ampcode.com 已恢复健康" {
+		t.Fatalf("zh message = %q", got)
+	}
+	SetLanguage("fr")
+	if Language() != LanguageEnglish {
+		t.Fatalf("unknown languages should fall back to en, got %s", Language())
+	}
+	if got := Msgf(MsgAmpNoProvider, "gpt-x"); got != "no provider available for model_id: gpt-x" {
+		t.Fatalf("en message = %q", got)
+	}
+	if got := Msgf("missing.id"); got != "missing.id" {
+		t.Fatalf("unknown ids should render as the id, got %q", got)
+	}
+}
@@ -0,0 +1,144 @@
+package logging
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// samplingWindow is the period of the per-model entry caps and of the summary lines.
+const samplingWindow = time.Minute
+
+// samplingSummaryField marks summary entries so they bypass sampling and level filters.
+const samplingSummaryField = "log_sampling_summary"
+
+// sampleModelFields are the entry fields a sampled entry's model is read from, in order.
+var sampleModelFields = []string{"model", "requested_model", "resolved_model"}
+
+type sampleKey struct {
+	component string
+	model     string
+}
+
+// logSampler logs the first entries per window of each component and model and counts
+// the rest, which are reported by one summary line per key when the window ends.
+type logSampler struct {
+	mu     sync.Mutex
+	limits map[string]int
+	seen   map[sampleKey]int
+	stop   chan struct{}
+	emit   func(key sampleKey, suppressed int)
+}
+
+var (
+	samplerMu     sync.Mutex
+	activeSampler *logSampler
+)
+
+// configureSampler replaces the process-wide sampler, stopping the previous one after
+// reporting its pending counts. It returns nil when limits is empty.
+func configureSampler(limits map[string]int) *logSampler {
+	samplerMu.Lock()
+	defer samplerMu.Unlock()
+	if activeSampler != nil {
+		activeSampler.close()
+		activeSampler = nil
+	}
+	if len(limits) == 0 {
+		return nil
+	}
+	activeSampler = newLogSampler(limits, emitSamplingSummary)
+	go activeSampler.run(samplingWindow)
+	return activeSampler
+}
+
+func newLogSampler(limits map[string]int, emit func(sampleKey, int)) *logSampler {
+	copied := make(map[string]int, len(limits))
+	for component, limit := range limits {
+		copied[strings.ToLower(component)] = limit
+	}
+	return &logSampler{limits: copied, seen: make(map[sampleKey]int), stop: make(chan struct{}), emit: emit}
+}
+
+// allow reports whether entry is within the cap of its component and model.
+func (s *logSampler) allow(entry *log.Entry) bool {
+	if s == nil {
+		return true
+	}
+	component := entryComponent(entry)
+	limit, ok := s.limits[strings.ToLower(component)]
+	if !ok {
+		return true
+	}
+	key := sampleKey{component: component, model: entryModel(entry)}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.seen[key]++
+	return s.seen[key] <= limit
+}
+
+func (s *logSampler) run(window time.Duration) {
+	ticker := time.NewTicker(window)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+			s.flush()
+		}
+	}
+}
+
+// flush reports the keys that exceeded their cap and starts a new window.
+func (s *logSampler) flush() {
+	s.mu.Lock()
+	seen := s.seen
+	s.seen = make(map[sampleKey]int)
+	s.mu.Unlock()
+
+	keys := make([]sampleKey, 0, len(seen))
+	for key := range seen {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].component != keys[j].component {
+			return keys[i].component < keys[j].component
+		}
+		return keys[i].model < keys[j].model
+	})
+	for _, key := range keys {
+		if suppressed := seen[key] - s.limits[strings.ToLower(key.component)]; suppressed > 0 {
+			s.emit(key, suppressed)
+		}
+	}
+}
+
+func (s *logSampler) close() {
+	close(s.stop)
+	s.flush()
+}
+
+func emitSamplingSummary(key sampleKey, suppressed int) {
+	model := key.model
+	if model == "" {
+		model = "-"
+	}
+	log.WithFields(log.Fields{
+		"component":          key.component,
+		"model":              model,
+		samplingSummaryField: true,
+	}).Infof("log sampling: suppressed %d %s entries for model %s in the last %s", suppressed, key.component, model, samplingWindow)
+}
+
+func entryModel(entry *log.Entry) string {
+	for _, field := range sampleModelFields {
+		if model, ok := entry.Data[field].(string); ok && model != "" {
+			return model
+		}
+	}
+	return ""
+}
@@ -0,0 +1,44 @@
+package logging
+
+import (
+	"testing"
+
+	log "github.com/sirupsen/logrus"
+)
+
+func TestLogSampler_CapsPerModelAndSummarizes(t *testing.T) {
+	summaries := make(map[sampleKey]int)
+	sampler := newLogSampler(map[string]int{"amp-routing": 2}, func(key sampleKey, suppressed int) {
+		summaries[key] = suppressed
+	})
+	logger := log.New()
+	entry := func(component, model string) *log.Entry {
+		return log.NewEntry(logger).WithFields(log.Fields{"component": component, "requested_model": model})
+	}
+
+	allowed := 0
+	for i := 0; i < 5; i++ {
+		if sampler.allow(entry("amp-routing", "gpt-5")) {
+			allowed++
+		}
+	}
+	if allowed != 2 {
+		t.Fatalf("allowed = %d, want the first 2", allowed)
+	}
+	if !sampler.allow(entry("amp-routing", "claude-sonnet-4")) {
+		t.Fatal("each model has its own cap")
+	}
+	for i := 0; i < 10; i++ {
+		if !sampler.allow(entry("watcher", "")) {
+			t.Fatal("components without sampling should always log")
+		}
+	}
+
+	sampler.flush()
+	if len(summaries) != 1 || summaries[sampleKey{component: "amp-routing", model: "gpt-5"}] != 3 {
+		t.Fatalf("summaries = %v", summaries)
+	}
+	if !sampler.allow(entry("amp-routing", "gpt-5")) {
+		t.Fatal("a new window should reset the cap")
+	}
+}
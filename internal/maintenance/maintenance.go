@@ -0,0 +1,251 @@
+// Package maintenance holds the runtime maintenance and drain toggles set through the
+// management API. Proxy-wide maintenance rejects new API requests while in-flight ones
+// finish; draining a provider either reroutes new requests to the other providers of a
+// model or rejects requests for the models it serves.
+package maintenance
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+)
+
+const (
+	// ActionReject answers new requests with 503 and the maintenance message.
+	ActionReject = "reject"
+	// ActionReroute skips a drained provider's credentials so other providers serve its
+	// models. Requests no other provider can serve are rejected.
+	ActionReroute = "reroute"
+
+	// ErrorCode is returned for requests rejected by maintenance.
+	ErrorCode = "maintenance"
+	// ErrorCodeProviderDraining is the coreauth.Error code returned for the credentials of
+	// a draining provider.
+	ErrorCodeProviderDraining = "provider_draining"
+)
+
+// Mode describes an active toggle.
+type Mode struct {
+	Action  string    `json:"action"`
+	Message string    `json:"message"`
+	Since   time.Time `json:"since"`
+}
+
+// State is the snapshot returned by the management API.
+type State struct {
+	Proxy     *Mode           `json:"proxy,omitempty"`
+	Providers map[string]Mode `json:"providers"`
+	InFlight  int64           `json:"in_flight"`
+}
+
+var defaultController = NewController()
+
+// Default returns the process-wide controller.
+func Default() *Controller { return defaultController }
+
+// Controller implements coreauth.AuthFilter.
+type Controller struct {
+	mu        sync.RWMutex
+	cfg       config.MaintenanceConfig
+	proxy     *Mode
+	providers map[string]Mode
+	inFlight  atomic.Int64
+	nowFunc   func() time.Time
+}
+
+// NewController constructs a controller with every toggle off.
+func NewController() *Controller {
+	return &Controller{providers: make(map[string]Mode), nowFunc: time.Now}
+}
+
+// Configure replaces the default message and retry hint. Active toggles are kept.
+func (c *Controller) Configure(cfg config.MaintenanceConfig) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	c.cfg = cfg
+	c.mu.Unlock()
+}
+
+// RetryAfterSeconds is the Retry-After hint of rejected requests.
+func (c *Controller) RetryAfterSeconds() int {
+	if c == nil {
+		return config.DefaultMaintenanceRetryAfterSeconds
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.cfg.RetryAfterSeconds <= 0 {
+		return config.DefaultMaintenanceRetryAfterSeconds
+	}
+	return c.cfg.RetryAfterSeconds
+}
+
+// SetProxy turns proxy-wide maintenance on with message, or the configured default.
+func (c *Controller) SetProxy(message string) Mode {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	mode := Mode{Action: ActionReject, Message: c.messageLocked(message), Since: c.nowFunc()}
+	c.proxy = &mode
+	return mode
+}
+
+// ClearProxy turns proxy-wide maintenance off.
+func (c *Controller) ClearProxy() {
+	c.mu.Lock()
+	c.proxy = nil
+	c.mu.Unlock()
+}
+
+// Proxy returns the proxy-wide mode when maintenance is on.
+func (c *Controller) Proxy() (Mode, bool) {
+	if c == nil {
+		return Mode{}, false
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.proxy == nil {
+		return Mode{}, false
+	}
+	return *c.proxy, true
+}
+
+// DrainProvider puts provider into drain mode with action, reroute by default.
+func (c *Controller) DrainProvider(provider, action, message string) (Mode, error) {
+	provider = strings.ToLower(strings.TrimSpace(provider))
+	if provider == "" {
+		return Mode{}, fmt.Errorf("provider is required")
+	}
+	action = strings.ToLower(strings.TrimSpace(action))
+	switch action {
+	case "":
+		action = ActionReroute
+	case ActionReroute, ActionReject:
+	default:
+		return Mode{}, fmt.Errorf("unknown action %q, use reroute or reject", action)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	mode := Mode{Action: action, Message: c.messageLocked(message), Since: c.nowFunc()}
+	c.providers[provider] = mode
+	return mode, nil
+}
+
+// ResumeProvider ends the drain of provider and reports whether it was draining.
+func (c *Controller) ResumeProvider(provider string) bool {
+	provider = strings.ToLower(strings.TrimSpace(provider))
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, ok := c.providers[provider]
+	delete(c.providers, provider)
+	return ok
+}
+
+// State returns the active toggles and the number of API requests in flight.
+func (c *Controller) State() State {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	state := State{Providers: make(map[string]Mode, len(c.providers)), InFlight: c.inFlight.Load()}
+	if c.proxy != nil {
+		proxy := *c.proxy
+		state.Proxy = &proxy
+	}
+	for provider, mode := range c.providers {
+		state.Providers[provider] = mode
+	}
+	return state
+}
+
+// Begin counts an API request as in flight; the returned function ends it.
+func (c *Controller) Begin() func() {
+	if c == nil {
+		return func() {}
+	}
+	c.inFlight.Add(1)
+	return func() { c.inFlight.Add(-1) }
+}
+
+// Draining reports whether any provider is draining.
+func (c *Controller) Draining() bool {
+	if c == nil {
+		return false
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return len(c.providers) > 0
+}
+
+// RejectModel reports the drain mode rejecting requests for model: a provider draining
+// with the reject action serves it, or every provider serving it is draining.
+func (c *Controller) RejectModel(model string) (Mode, bool) {
+	if c == nil || model == "" {
+		return Mode{}, false
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if len(c.providers) == 0 {
+		return Mode{}, false
+	}
+	providers := registry.GetGlobalRegistry().GetModelProviders(model)
+	if len(providers) == 0 {
+		return Mode{}, false
+	}
+	sort.Strings(providers)
+	var rerouted *Mode
+	allDrained := true
+	for _, provider := range providers {
+		mode, ok := c.providers[strings.ToLower(provider)]
+		if !ok {
+			allDrained = false
+			continue
+		}
+		if mode.Action == ActionReject {
+			return mode, true
+		}
+		if rerouted == nil {
+			rerouted = &mode
+		}
+	}
+	// Reroutes only reject when no provider of the model is left.
+	if allDrained && rerouted != nil {
+		return *rerouted, true
+	}
+	return Mode{}, false
+}
+
+// FilterAuth implements coreauth.AuthFilter, excluding credentials of draining providers.
+func (c *Controller) FilterAuth(_ context.Context, auth *coreauth.Auth, _ string) error {
+	if c == nil || auth == nil {
+		return nil
+	}
+	c.mu.RLock()
+	mode, ok := c.providers[strings.ToLower(auth.Provider)]
+	c.mu.RUnlock()
+	if !ok {
+		return nil
+	}
+	return &coreauth.Error{
+		Code:       ErrorCodeProviderDraining,
+		Message:    mode.Message,
+		HTTPStatus: http.StatusServiceUnavailable,
+	}
+}
+
+func (c *Controller) messageLocked(message string) string {
+	if message = strings.TrimSpace(message); message != "" {
+		return message
+	}
+	if c.cfg.Message != "" {
+		return c.cfg.Message
+	}
+	return config.DefaultMaintenanceMessage
+}
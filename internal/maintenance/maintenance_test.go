@@ -0,0 +1,88 @@
+package maintenance
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+)
+
+func TestController_ProxyMaintenance(t *testing.T) {
+	c := NewController()
+	c.Configure(config.MaintenanceConfig{Message: "rotating keys", RetryAfterSeconds: 30})
+	if _, active := c.Proxy(); active {
+		t.Fatal("maintenance should start off")
+	}
+	if mode := c.SetProxy(""); mode.Message != "rotating keys" || mode.Action != ActionReject {
+		t.Fatalf("mode = %+v", mode)
+	}
+	if mode := c.SetProxy("back at 10:00"); mode.Message != "back at 10:00" {
+		t.Fatalf("explicit message should win, got %+v", mode)
+	}
+	if c.RetryAfterSeconds() != 30 {
+		t.Fatalf("retry after = %d", c.RetryAfterSeconds())
+	}
+	done := c.Begin()
+	if state := c.State(); state.Proxy == nil || state.InFlight != 1 {
+		t.Fatalf("state = %+v", state)
+	}
+	done()
+	c.ClearProxy()
+	if state := c.State(); state.Proxy != nil || state.InFlight != 0 {
+		t.Fatalf("state after clear = %+v", state)
+	}
+}
+
+func TestController_DrainProvider(t *testing.T) {
+	reg := registry.GetGlobalRegistry()
+	reg.RegisterClient("drain-claude", "claude", []*registry.ModelInfo{{ID: "drain-sonnet"}, {ID: "drain-haiku"}})
+	reg.RegisterClient("drain-kiro", "kiro", []*registry.ModelInfo{{ID: "drain-sonnet"}})
+	defer reg.UnregisterClient("drain-claude")
+	defer reg.UnregisterClient("drain-kiro")
+
+	c := NewController()
+	if _, err := c.DrainProvider("claude", "pause", ""); err == nil {
+		t.Fatal("unknown actions should be rejected")
+	}
+	if _, err := c.DrainProvider("Claude", "", "rotating"); err != nil {
+		t.Fatal(err)
+	}
+	if !c.Draining() {
+		t.Fatal("claude should be draining")
+	}
+
+	var authErr *coreauth.Error
+	if err := c.FilterAuth(context.Background(), &coreauth.Auth{Provider: "claude"}, "drain-sonnet"); !errors.As(err, &authErr) || authErr.Code != ErrorCodeProviderDraining {
+		t.Fatalf("filter error = %v", err)
+	}
+	if err := c.FilterAuth(context.Background(), &coreauth.Auth{Provider: "kiro"}, "drain-sonnet"); err != nil {
+		t.Fatalf("other providers should pass, got %v", err)
+	}
+
+	if _, rejected := c.RejectModel("drain-sonnet"); rejected {
+		t.Fatal("a rerouted model with another provider should not be rejected")
+	}
+	if mode, rejected := c.RejectModel("drain-haiku"); !rejected || mode.Message != "rotating" {
+		t.Fatalf("a model without other providers should be rejected, got %+v %v", mode, rejected)
+	}
+
+	if _, err := c.DrainProvider("claude", ActionReject, ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, rejected := c.RejectModel("drain-sonnet"); !rejected {
+		t.Fatal("reject drains should reject every model the provider serves")
+	}
+	if _, rejected := c.RejectModel("unknown-model"); rejected {
+		t.Fatal("unknown models should pass")
+	}
+
+	if !c.ResumeProvider("claude") || c.ResumeProvider("claude") {
+		t.Fatal("resume should report whether the provider was draining")
+	}
+	if c.Draining() {
+		t.Fatal("no provider should be draining after resume")
+	}
+}
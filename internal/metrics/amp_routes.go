@@ -0,0 +1,233 @@
+package metrics
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/routeinfo"
+)
+
+// AmpRoute is the concurrency of one Amp route.
+type AmpRoute struct {
+	Route    string `json:"route"`
+	InFlight int64  `json:"in_flight"`
+	Peak     int64  `json:"peak"`
+	Requests int64  `json:"requests"`
+}
+
+// AmpTraffic counts the Amp requests for one requested model by how they were served.
+type AmpTraffic struct {
+	Model     string `json:"model"`
+	RouteType string `json:"route_type"`
+	Served    string `json:"served_model,omitempty"`
+	Provider  string `json:"provider,omitempty"`
+	Requests  int64  `json:"requests"`
+}
+
+// maxAmpTrafficModels bounds the requested models tracked by name. Requested models are
+// client-supplied, so later ones are counted under AmpOtherModel to keep the map and the
+// Prometheus label cardinality bounded.
+const maxAmpTrafficModels = 100
+
+// AmpOtherModel collects the Amp traffic of requested models beyond maxAmpTrafficModels.
+const AmpOtherModel = "other"
+
+type ampTrafficKey struct {
+	model, routeType, served, provider string
+}
+
+// ampRoutes tracks the Amp fallback handler independently of the usage series: requests
+// forwarded to ampcode.com never produce usage records.
+type ampRoutes struct {
+	mu      sync.Mutex
+	routes  map[string]*AmpRoute
+	traffic map[ampTrafficKey]int64
+	models  map[string]bool
+}
+
+var ampStats = &ampRoutes{routes: make(map[string]*AmpRoute), traffic: make(map[ampTrafficKey]int64), models: make(map[string]bool)}
+
+// StartAmpRequest counts a request in flight on route, the gin route pattern. The returned
+// function ends it and records how the requested model was served.
+func StartAmpRequest(route string) func(routeType, model, served, provider string) {
+	ampStats.mu.Lock()
+	r := ampStats.routes[route]
+	if r == nil {
+		r = &AmpRoute{Route: route}
+		ampStats.routes[route] = r
+	}
+	r.InFlight++
+	r.Requests++
+	r.Peak = max(r.Peak, r.InFlight)
+	ampStats.mu.Unlock()
+
+	var once sync.Once
+	return func(routeType, model, served, provider string) {
+		once.Do(func() {
+			ampStats.mu.Lock()
+			defer ampStats.mu.Unlock()
+			if r := ampStats.routes[route]; r != nil && r.InFlight > 0 {
+				r.InFlight--
+			}
+			if model == "" {
+				return
+			}
+			if served == model {
+				served = ""
+			}
+			if !ampStats.models[model] {
+				if len(ampStats.models) >= maxAmpTrafficModels {
+					model = AmpOtherModel
+				} else {
+					ampStats.models[model] = true
+				}
+			}
+			ampStats.traffic[ampTrafficKey{model: model, routeType: routeType, served: served, provider: provider}]++
+		})
+	}
+}
+
+// AmpRoutes returns the concurrency of every Amp route, by route.
+func AmpRoutes() []AmpRoute {
+	ampStats.mu.Lock()
+	out := make([]AmpRoute, 0, len(ampStats.routes))
+	for _, r := range ampStats.routes {
+		out = append(out, *r)
+	}
+	ampStats.mu.Unlock()
+	sort.Slice(out, func(i, j int) bool { return out[i].Route < out[j].Route })
+	return out
+}
+
+// AmpTrafficCounts returns the Amp requests by requested model and route, most requests
+// first.
+func AmpTrafficCounts() []AmpTraffic {
+	ampStats.mu.Lock()
+	out := make([]AmpTraffic, 0, len(ampStats.traffic))
+	for key, count := range ampStats.traffic {
+		out = append(out, AmpTraffic{Model: key.model, RouteType: key.routeType, Served: key.served, Provider: key.provider, Requests: count})
+	}
+	ampStats.mu.Unlock()
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Requests != out[j].Requests {
+			return out[i].Requests > out[j].Requests
+		}
+		if out[i].Model != out[j].Model {
+			return out[i].Model < out[j].Model
+		}
+		return out[i].RouteType+out[i].Provider < out[j].RouteType+out[j].Provider
+	})
+	return out
+}
+
+// ResetAmpRoutes clears the request counts and peaks; requests in flight stay counted.
+func ResetAmpRoutes() {
+	ampStats.mu.Lock()
+	defer ampStats.mu.Unlock()
+	for route, r := range ampStats.routes {
+		if r.InFlight == 0 {
+			delete(ampStats.routes, route)
+			continue
+		}
+		r.Peak, r.Requests = r.InFlight, 0
+	}
+	ampStats.traffic = make(map[ampTrafficKey]int64)
+	ampStats.models = make(map[string]bool)
+}
+
+// AmpModelShare is the fairness entry of one requested model: its share of the Amp traffic
+// served locally against its share of the local credentials able to serve it.
+type AmpModelShare struct {
+	Model          string           `json:"model"`
+	Requests       int64            `json:"requests"`
+	LocalRequests  int64            `json:"local_requests"`
+	AmpCredits     int64            `json:"amp_credits_requests"`
+	Providers      map[string]int64 `json:"providers,omitempty"`
+	ServedModels   []string         `json:"served_models,omitempty"`
+	Share          float64          `json:"share"`
+	LocalShare     float64          `json:"local_share"`
+	Capacity       int              `json:"capacity"`
+	CapacityShare  float64          `json:"capacity_share"`
+	CapacityFactor float64          `json:"capacity_factor"`
+}
+
+// AmpFairnessReport relates the Amp traffic of each requested model to provider capacity.
+type AmpFairnessReport struct {
+	TotalRequests int64           `json:"total_requests"`
+	LocalRequests int64           `json:"local_requests"`
+	TotalCapacity int             `json:"total_capacity"`
+	Models        []AmpModelShare `json:"models"`
+}
+
+// AmpFairness builds the fairness report. capacity returns the credentials currently able to
+// serve a model; each served model counts once towards the total capacity. A capacity
+// factor above 1 means the model takes more of the local traffic than its share of the
+// capacity, i.e. it dominates local quota.
+func AmpFairness(capacity func(model string) int) AmpFairnessReport {
+	report := AmpFairnessReport{Models: []AmpModelShare{}}
+	byModel := make(map[string]*AmpModelShare)
+	served := make(map[string]map[string]bool)
+	for _, t := range AmpTrafficCounts() {
+		entry := byModel[t.Model]
+		if entry == nil {
+			entry = &AmpModelShare{Model: t.Model}
+			byModel[t.Model] = entry
+			served[t.Model] = make(map[string]bool)
+		}
+		entry.Requests += t.Requests
+		report.TotalRequests += t.Requests
+		switch routeinfo.RouteType(t.RouteType) {
+		case routeinfo.LocalProvider, routeinfo.ModelMapping:
+			entry.LocalRequests += t.Requests
+			report.LocalRequests += t.Requests
+			if t.Provider != "" {
+				if entry.Providers == nil {
+					entry.Providers = make(map[string]int64)
+				}
+				entry.Providers[t.Provider] += t.Requests
+			}
+			servedModel := t.Served
+			if servedModel == "" {
+				servedModel = t.Model
+			}
+			served[t.Model][servedModel] = true
+		case routeinfo.AmpCredits:
+			entry.AmpCredits += t.Requests
+		}
+	}
+
+	capacityOf := make(map[string]int)
+	for model, entry := range byModel {
+		for servedModel := range served[model] {
+			entry.ServedModels = append(entry.ServedModels, servedModel)
+			if _, seen := capacityOf[servedModel]; !seen {
+				capacityOf[servedModel] = max(capacity(servedModel), 0)
+				report.TotalCapacity += capacityOf[servedModel]
+			}
+			entry.Capacity += capacityOf[servedModel]
+		}
+		sort.Strings(entry.ServedModels)
+	}
+	for _, entry := range byModel {
+		if report.TotalRequests > 0 {
+			entry.Share = float64(entry.Requests) / float64(report.TotalRequests)
+		}
+		if report.LocalRequests > 0 {
+			entry.LocalShare = float64(entry.LocalRequests) / float64(report.LocalRequests)
+		}
+		if report.TotalCapacity > 0 {
+			entry.CapacityShare = float64(entry.Capacity) / float64(report.TotalCapacity)
+		}
+		if entry.CapacityShare > 0 {
+			entry.CapacityFactor = entry.LocalShare / entry.CapacityShare
+		}
+		report.Models = append(report.Models, *entry)
+	}
+	sort.Slice(report.Models, func(i, j int) bool {
+		if report.Models[i].LocalRequests != report.Models[j].LocalRequests {
+			return report.Models[i].LocalRequests > report.Models[j].LocalRequests
+		}
+		return report.Models[i].Model < report.Models[j].Model
+	})
+	return report
+}
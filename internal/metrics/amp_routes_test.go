@@ -0,0 +1,88 @@
+package metrics
+
+import (
+	"bytes"
+	"math"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/routeinfo"
+)
+
+func TestAmpRoutesAndFairness(t *testing.T) {
+	ResetAmpRoutes()
+	defer ResetAmpRoutes()
+
+	const route = "/api/provider/:provider/v1/messages"
+	first := StartAmpRequest(route)
+	second := StartAmpRequest(route)
+	if routes := AmpRoutes(); len(routes) != 1 || routes[0].InFlight != 2 || routes[0].Peak != 2 {
+		t.Fatalf("routes while in flight = %+v", routes)
+	}
+	first(string(routeinfo.LocalProvider), "claude-opus-4", "claude-opus-4", "claude")
+	first(string(routeinfo.LocalProvider), "claude-opus-4", "claude-opus-4", "claude") // ending twice counts once
+	second(string(routeinfo.ModelMapping), "gpt-5", "claude-sonnet-4", "claude")
+	for i := 0; i < 2; i++ {
+		StartAmpRequest(route)(string(routeinfo.LocalProvider), "claude-opus-4", "", "claude")
+	}
+	StartAmpRequest(route)(string(routeinfo.AmpCredits), "gpt-5", "", "")
+
+	routes := AmpRoutes()
+	if len(routes) != 1 || routes[0].InFlight != 0 || routes[0].Peak != 2 || routes[0].Requests != 5 {
+		t.Fatalf("routes = %+v", routes)
+	}
+
+	capacity := map[string]int{"claude-opus-4": 1, "claude-sonnet-4": 3}
+	report := AmpFairness(func(model string) int { return capacity[model] })
+	if report.TotalRequests != 5 || report.LocalRequests != 4 || report.TotalCapacity != 4 || len(report.Models) != 2 {
+		t.Fatalf("report = %+v", report)
+	}
+	opus, gpt := report.Models[0], report.Models[1]
+	if opus.Model != "claude-opus-4" || opus.LocalRequests != 3 || opus.Capacity != 1 {
+		t.Fatalf("opus = %+v", opus)
+	}
+	// 75% of the local traffic on 25% of the capacity.
+	if math.Abs(opus.CapacityFactor-3) > 1e-9 {
+		t.Fatalf("opus capacity factor = %v, want 3", opus.CapacityFactor)
+	}
+	if gpt.Model != "gpt-5" || gpt.AmpCredits != 1 || gpt.LocalRequests != 1 || gpt.Providers["claude"] != 1 ||
+		len(gpt.ServedModels) != 1 || gpt.ServedModels[0] != "claude-sonnet-4" {
+		t.Fatalf("gpt = %+v", gpt)
+	}
+
+	c := NewCollector()
+	var buf bytes.Buffer
+	if err := c.WritePrometheus(&buf); err != nil {
+		t.Fatalf("WritePrometheus: %v", err)
+	}
+	out := buf.String()
+	for _, want := range []string{
+		`cliproxy_amp_in_flight_requests{route="` + route + `"} 0`,
+		`cliproxy_amp_route_requests_total{route="` + route + `"} 5`,
+		`cliproxy_amp_model_requests_total{model="claude-opus-4",served_model="",route_type="LOCAL_PROVIDER",provider="claude"} 3`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("prometheus output misses %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestAmpTrafficCollapsesModelsBeyondTheCap(t *testing.T) {
+	ResetAmpRoutes()
+	defer ResetAmpRoutes()
+
+	const route = "/api/provider/:provider/v1/messages"
+	for i := 0; i < maxAmpTrafficModels+5; i++ {
+		StartAmpRequest(route)(string(routeinfo.AmpCredits), "model-"+strconv.Itoa(i), "", "")
+	}
+	StartAmpRequest(route)(string(routeinfo.AmpCredits), "model-0", "", "")
+
+	models := make(map[string]int64)
+	for _, traffic := range AmpTrafficCounts() {
+		models[traffic.Model] += traffic.Requests
+	}
+	if len(models) != maxAmpTrafficModels+1 || models[AmpOtherModel] != 5 || models["model-0"] != 2 {
+		t.Fatalf("tracked %d models, other = %d, model-0 = %d", len(models), models[AmpOtherModel], models["model-0"])
+	}
+}
@@ -0,0 +1,302 @@
+// Package metrics counts requests, tokens and costs per provider, model and client API key
+// from the usage pipeline. The counters are served in the Prometheus text format and can
+// be pushed to a statsd agent or an OTLP collector; label values are bucketed and capped
+// as configured so that many keys or models do not explode the series count.
+package metrics
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/offline"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/pricing"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	coreusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+)
+
+// otherLabel replaces label values beyond the configured caps.
+const otherLabel = "other"
+
+var defaultCollector = NewCollector()
+
+func init() {
+	coreusage.RegisterPlugin(defaultCollector)
+}
+
+// Default returns the process-wide collector fed by the usage pipeline.
+func Default() *Collector { return defaultCollector }
+
+// Labels identify one series.
+type Labels struct {
+	Provider string
+	Model    string
+	APIKey   string
+	Status   string
+}
+
+// Counters are the totals of one series. OutputTokens include the reasoning tokens and
+// InputTokens the cache reads, so ReasoningTokens and CachedTokens are breakdowns and
+// InputTokens plus OutputTokens is the total.
+type Counters struct {
+	Requests        int64
+	InputTokens     int64
+	OutputTokens    int64
+	ReasoningTokens int64
+	CachedTokens    int64
+	Cost            float64
+}
+
+func (c *Counters) add(o Counters) {
+	c.Requests += o.Requests
+	c.InputTokens += o.InputTokens
+	c.OutputTokens += o.OutputTokens
+	c.ReasoningTokens += o.ReasoningTokens
+	c.CachedTokens += o.CachedTokens
+	c.Cost += o.Cost
+}
+
+// Series is a snapshot of one labelled series.
+type Series struct {
+	Labels
+	Counters
+}
+
+// Collector implements coreusage.Plugin.
+type Collector struct {
+	mu      sync.Mutex
+	cfg     config.MetricsConfig
+	series  map[Labels]*Counters
+	pending map[Labels]*Counters
+	models  map[string]bool
+	keys    map[string]bool
+	start   time.Time
+	cancel  context.CancelFunc
+	done    chan struct{}
+	client  *http.Client
+	nowFunc func() time.Time
+
+	// firstToken and interToken are the stream latency histograms.
+	firstToken map[latencyLabels]*histogram
+	interToken map[latencyLabels]*histogram
+}
+
+// NewCollector constructs a disabled collector.
+func NewCollector() *Collector {
+	c := &Collector{client: offline.Default().Client(&http.Client{Timeout: 10 * time.Second}), nowFunc: time.Now}
+	c.resetLocked()
+	return c
+}
+
+func (c *Collector) resetLocked() {
+	c.series = make(map[Labels]*Counters)
+	c.pending = make(map[Labels]*Counters)
+	c.firstToken = make(map[latencyLabels]*histogram)
+	c.interToken = make(map[latencyLabels]*histogram)
+	c.models = make(map[string]bool)
+	c.keys = make(map[string]bool)
+	c.start = c.nowFunc()
+}
+
+// Configure applies cfg and restarts the exporters. Changing the label settings resets the
+// counters since existing series would no longer match.
+func (c *Collector) Configure(cfg config.MetricsConfig) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !reflect.DeepEqual(c.cfg.Labels, cfg.Labels) {
+		c.resetLocked()
+	}
+	exportersChanged := !reflect.DeepEqual(c.cfg.Statsd, cfg.Statsd) || !reflect.DeepEqual(c.cfg.OTLP, cfg.OTLP)
+	c.cfg = cfg
+	if cfg.Statsd.Address == "" {
+		c.pending = make(map[Labels]*Counters)
+	}
+	if c.cancel != nil && exportersChanged {
+		c.cancel()
+		c.cancel, c.done = nil, nil
+	}
+	if c.cancel == nil && (cfg.Statsd.Address != "" || cfg.OTLP.Endpoint != "") {
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan struct{})
+		c.cancel, c.done = cancel, done
+		go func() {
+			defer close(done)
+			c.run(ctx, cfg.Statsd, cfg.OTLP)
+		}()
+	}
+}
+
+// Stop halts the exporters and returns once their final push is done.
+func (c *Collector) Stop() {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	done := c.done
+	if c.cancel != nil {
+		c.cancel()
+		c.cancel, c.done = nil, nil
+	}
+	c.mu.Unlock()
+	if done != nil {
+		<-done
+	}
+}
+
+// Enabled reports whether /metrics is served.
+func (c *Collector) Enabled() bool {
+	if c == nil {
+		return false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.cfg.Enable
+}
+
+// Token returns the bearer token required to scrape /metrics, if any.
+func (c *Collector) Token() string {
+	if c == nil {
+		return ""
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.cfg.Token
+}
+
+// HandleUsage implements coreusage.Plugin.
+func (c *Collector) HandleUsage(ctx context.Context, record coreusage.Record) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.cfg.Enable && c.cfg.Statsd.Address == "" && c.cfg.OTLP.Endpoint == "" {
+		return
+	}
+	d := record.Detail
+	delta := Counters{
+		Requests:        1,
+		InputTokens:     d.InputTokens,
+		OutputTokens:    d.GeneratedTokens(),
+		ReasoningTokens: d.ReasoningTokens,
+		CachedTokens:    d.CachedTokens,
+	}
+	delta.Cost, _ = pricing.Default().Cost(record.Model, pricing.UsageOf(d))
+	labels := Labels{
+		Provider: record.Provider,
+		Model:    c.modelLabelLocked(record.Model),
+		APIKey:   c.apiKeyLabelLocked(record.APIKey),
+		Status:   "success",
+	}
+	if record.Failed {
+		labels.Status = "failure"
+	}
+	if labels.Provider == "" {
+		labels.Provider = "unknown"
+	}
+	addTo(c.series, labels, delta)
+	if c.cfg.Statsd.Address != "" {
+		addTo(c.pending, labels, delta)
+	}
+}
+
+func addTo(m map[Labels]*Counters, labels Labels, delta Counters) {
+	counters := m[labels]
+	if counters == nil {
+		counters = &Counters{}
+		m[labels] = counters
+	}
+	counters.add(delta)
+}
+
+func (c *Collector) modelLabelLocked(model string) string {
+	if model == "" {
+		return "unknown"
+	}
+	for _, bucket := range c.cfg.Labels.ModelBuckets {
+		if util.MatchWildcard(bucket.Pattern, model) {
+			return bucket.Name
+		}
+	}
+	return capLabel(c.models, model, c.cfg.Labels.MaxModels)
+}
+
+func (c *Collector) apiKeyLabelLocked(key string) string {
+	if key == "" {
+		return ""
+	}
+	var label string
+	switch c.cfg.Labels.APIKey {
+	case config.MetricsAPIKeyOmit:
+		return ""
+	case config.MetricsAPIKeyRaw:
+		label = key
+	case config.MetricsAPIKeyMask:
+		label = util.HideAPIKey(key)
+	default:
+		sum := sha256.Sum256([]byte(key))
+		label = hex.EncodeToString(sum[:])[:12]
+	}
+	return capLabel(c.keys, label, c.cfg.Labels.MaxAPIKeys)
+}
+
+// capLabel returns value, or otherLabel once limit distinct values have been seen.
+func capLabel(seen map[string]bool, value string, limit int) string {
+	if seen[value] {
+		return value
+	}
+	if limit > 0 && len(seen) >= limit {
+		return otherLabel
+	}
+	seen[value] = true
+	return value
+}
+
+// Snapshot returns the series in label order.
+func (c *Collector) Snapshot() []Series {
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return sortedSeries(c.series)
+}
+
+// takePending returns and clears the increments not yet pushed to statsd.
+func (c *Collector) takePending() []Series {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	out := sortedSeries(c.pending)
+	c.pending = make(map[Labels]*Counters)
+	return out
+}
+
+func sortedSeries(m map[Labels]*Counters) []Series {
+	out := make([]Series, 0, len(m))
+	for labels, counters := range m {
+		out = append(out, Series{Labels: labels, Counters: *counters})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		a, b := out[i].Labels, out[j].Labels
+		if a.Provider != b.Provider {
+			return a.Provider < b.Provider
+		}
+		if a.Model != b.Model {
+			return a.Model < b.Model
+		}
+		if a.APIKey != b.APIKey {
+			return a.APIKey < b.APIKey
+		}
+		return a.Status < b.Status
+	})
+	return out
+}
@@ -0,0 +1,146 @@
+package metrics
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	coreusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+)
+
+func sanitized(m config.MetricsConfig) config.MetricsConfig {
+	cfg := &config.Config{Metrics: m}
+	cfg.SanitizeMetrics()
+	return cfg.Metrics
+}
+
+func TestCollectorLabelCardinality(t *testing.T) {
+	c := NewCollector()
+	c.Configure(sanitized(config.MetricsConfig{
+		Enable: true,
+		Labels: config.MetricsLabels{
+			MaxModels:    1,
+			ModelBuckets: []config.MetricsModelBucket{{Pattern: "claude-*", Name: "claude"}},
+		},
+	}))
+	for _, model := range []string{"claude-sonnet-4", "claude-opus-4", "gpt-5", "gemini-2.5-pro"} {
+		c.HandleUsage(context.Background(), coreusage.Record{Provider: "p", Model: model, APIKey: "sk-secret-key-1234"})
+	}
+	models := map[string]int64{}
+	for _, s := range c.Snapshot() {
+		models[s.Model] += s.Requests
+		if len(s.APIKey) != 12 || strings.Contains(s.APIKey, "secret") {
+			t.Fatalf("api key label should be a hash, got %q", s.APIKey)
+		}
+	}
+	if models["claude"] != 2 || models["gpt-5"] != 1 || models[otherLabel] != 1 || len(models) != 3 {
+		t.Fatalf("models = %v", models)
+	}
+
+	var out strings.Builder
+	if err := c.WritePrometheus(&out); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), `cliproxy_requests_total{provider="p",model="claude",api_key="`) ||
+		!strings.Contains(out.String(), `type="input"`) || strings.Contains(out.String(), `type="reasoning"`) ||
+		!strings.Contains(out.String(), "# TYPE cliproxy_reasoning_tokens_total counter") {
+		t.Fatalf("unexpected exposition:\n%s", out.String())
+	}
+
+	c.Configure(sanitized(config.MetricsConfig{Enable: true, Labels: config.MetricsLabels{APIKey: config.MetricsAPIKeyOmit}}))
+	if len(c.Snapshot()) != 0 {
+		t.Fatal("changing labels should reset the series")
+	}
+	c.HandleUsage(context.Background(), coreusage.Record{Provider: "p", Model: "m", APIKey: "k"})
+	out.Reset()
+	_ = c.WritePrometheus(&out)
+	if strings.Contains(out.String(), "api_key") {
+		t.Fatalf("omitted api_key label still exported:\n%s", out.String())
+	}
+}
+
+func TestCollectorExporters(t *testing.T) {
+	udp, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = udp.Close() }()
+	otlp := make(chan map[string]any, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload map[string]any
+		_ = json.NewDecoder(r.Body).Decode(&payload)
+		if r.Header.Get("X-Api-Key") == "k" {
+			otlp <- payload
+		}
+	}))
+	defer srv.Close()
+
+	c := NewCollector()
+	c.Configure(sanitized(config.MetricsConfig{
+		Statsd: config.StatsdExporter{Address: udp.LocalAddr().String()},
+		OTLP:   config.OTLPExporter{Endpoint: srv.URL, Headers: map[string]string{"X-Api-Key": "k"}},
+	}))
+	// Reasoning reported apart from the output is counted into it once.
+	c.HandleUsage(context.Background(), coreusage.Record{Provider: "p", Model: "m", Detail: coreusage.Detail{InputTokens: 5, OutputTokens: 2, ReasoningTokens: 4, TotalTokens: 11}})
+	c.Stop() // flushes both exporters before returning
+
+	buf := make([]byte, maxStatsdPacket)
+	_ = udp.SetReadDeadline(time.Now().Add(2 * time.Second))
+	n, _, err := udp.ReadFrom(buf)
+	if err != nil {
+		t.Fatalf("no statsd packet: %v", err)
+	}
+	packet := string(buf[:n])
+	if !strings.Contains(packet, "cliproxy.requests:1|c|#provider:p,model:m,status:success") ||
+		!strings.Contains(packet, "cliproxy.tokens:5|c|#provider:p,model:m,status:success,type:input") ||
+		!strings.Contains(packet, "cliproxy.tokens:6|c|#provider:p,model:m,status:success,type:output") ||
+		!strings.Contains(packet, "cliproxy.reasoning_tokens:4|c|#provider:p,model:m,status:success") {
+		t.Fatalf("statsd packet = %q", packet)
+	}
+	select {
+	case payload := <-otlp:
+		raw, _ := json.Marshal(payload)
+		if !strings.Contains(string(raw), `"name":"cliproxy.requests"`) || !strings.Contains(string(raw), `"asInt":"1"`) ||
+			!strings.Contains(string(raw), `"name":"cliproxy.tokens.reasoning"`) {
+			t.Fatalf("otlp payload = %s", raw)
+		}
+	default:
+		t.Fatal("Stop returned before the final OTLP export")
+	}
+}
+
+func TestCollectorStreamLatencyHistograms(t *testing.T) {
+	c := NewCollector()
+	c.Configure(sanitized(config.MetricsConfig{Enable: true}))
+	c.HandleStreamTiming(context.Background(), coreusage.StreamTiming{
+		Provider:   "codex",
+		Model:      "gpt-5",
+		FirstToken: 1500 * time.Millisecond,
+		Gaps:       []time.Duration{20 * time.Millisecond, 30 * time.Millisecond, 7 * time.Second},
+	})
+
+	var out strings.Builder
+	if err := c.WritePrometheus(&out); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"# TYPE cliproxy_stream_first_token_seconds histogram",
+		`cliproxy_stream_first_token_seconds_bucket{provider="codex",model="gpt-5",le="1"} 0`,
+		`cliproxy_stream_first_token_seconds_bucket{provider="codex",model="gpt-5",le="2"} 1`,
+		`cliproxy_stream_first_token_seconds_count{provider="codex",model="gpt-5"} 1`,
+		`cliproxy_stream_inter_token_seconds_bucket{provider="codex",model="gpt-5",le="0.025"} 1`,
+		`cliproxy_stream_inter_token_seconds_bucket{provider="codex",model="gpt-5",le="5"} 2`,
+		`cliproxy_stream_inter_token_seconds_bucket{provider="codex",model="gpt-5",le="+Inf"} 3`,
+		`cliproxy_stream_inter_token_seconds_sum{provider="codex",model="gpt-5"} 7.05`,
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Fatalf("missing %q in exposition:\n%s", want, out.String())
+		}
+	}
+}
@@ -0,0 +1,233 @@
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	log "github.com/sirupsen/logrus"
+)
+
+// maxStatsdPacket keeps UDP datagrams below common MTUs.
+const maxStatsdPacket = 1432
+
+func (c *Collector) run(ctx context.Context, statsd config.StatsdExporter, otlp config.OTLPExporter) {
+	var statsdTick, otlpTick <-chan time.Time
+	if statsd.Address != "" {
+		ticker := time.NewTicker(time.Duration(statsd.IntervalSeconds) * time.Second)
+		defer ticker.Stop()
+		statsdTick = ticker.C
+	}
+	if otlp.Endpoint != "" {
+		ticker := time.NewTicker(time.Duration(otlp.IntervalSeconds) * time.Second)
+		defer ticker.Stop()
+		otlpTick = ticker.C
+	}
+	flush := func(statsdDue, otlpDue bool) {
+		if statsdDue {
+			if err := c.pushStatsd(statsd); err != nil {
+				log.Warnf("metrics: statsd push failed: %v", err)
+			}
+		}
+		if otlpDue {
+			if err := c.pushOTLP(ctx, otlp); err != nil {
+				log.Warnf("metrics: OTLP export failed: %v", err)
+			}
+		}
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			// A final push with a fresh context so increments since the last tick are kept.
+			ctx = context.Background()
+			flush(statsd.Address != "", otlp.Endpoint != "")
+			return
+		case <-statsdTick:
+			flush(true, false)
+		case <-otlpTick:
+			flush(false, true)
+		}
+	}
+}
+
+// pushStatsd sends the pending increments as DogStatsD counters with tags.
+func (c *Collector) pushStatsd(cfg config.StatsdExporter) error {
+	pending := c.takePending()
+	if len(pending) == 0 {
+		return nil
+	}
+	conn, err := net.Dial("udp", cfg.Address)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = conn.Close() }()
+	var packet bytes.Buffer
+	send := func() error {
+		if packet.Len() == 0 {
+			return nil
+		}
+		_, errWrite := conn.Write(packet.Bytes())
+		packet.Reset()
+		return errWrite
+	}
+	for _, line := range StatsdLines(cfg.Prefix, pending) {
+		if packet.Len() > 0 && packet.Len()+1+len(line) > maxStatsdPacket {
+			if err = send(); err != nil {
+				return err
+			}
+		}
+		if packet.Len() > 0 {
+			packet.WriteByte('\n')
+		}
+		packet.WriteString(line)
+	}
+	return send()
+}
+
+// StatsdLines formats series increments as DogStatsD counter lines.
+func StatsdLines(prefix string, series []Series) []string {
+	var lines []string
+	for _, s := range series {
+		tags := "|#provider:" + statsdTag(s.Provider) + ",model:" + statsdTag(s.Model) + ",status:" + s.Status
+		if s.APIKey != "" {
+			tags += ",api_key:" + statsdTag(s.APIKey)
+		}
+		counter := func(name string, value int64, extra string) {
+			if value != 0 {
+				lines = append(lines, prefix+name+":"+strconv.FormatInt(value, 10)+"|c"+tags+extra)
+			}
+		}
+		counter("requests", s.Requests, "")
+		counter("tokens", s.InputTokens, ",type:input")
+		counter("tokens", s.OutputTokens, ",type:output")
+		counter("cached_tokens", s.CachedTokens, "")
+		counter("reasoning_tokens", s.ReasoningTokens, "")
+		if s.Cost != 0 {
+			// Sub-cent costs would round away as integer counters, so cost is sent in micro-USD.
+			lines = append(lines, prefix+"cost_micro_usd:"+strconv.FormatInt(int64(s.Cost*1e6), 10)+"|c"+tags)
+		}
+	}
+	return lines
+}
+
+var statsdTagEscaper = strings.NewReplacer("|", "_", ",", "_", "#", "_", ":", "_", "\n", "_")
+
+func statsdTag(value string) string {
+	return statsdTagEscaper.Replace(value)
+}
+
+// pushOTLP exports the cumulative counters to an OTLP/HTTP JSON endpoint.
+func (c *Collector) pushOTLP(ctx context.Context, cfg config.OTLPExporter) error {
+	c.mu.Lock()
+	start := c.start
+	c.mu.Unlock()
+	body, err := json.Marshal(OTLPPayload(cfg.ServiceName, c.Snapshot(), start, c.nowFunc()))
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, cfg.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range cfg.Headers {
+		req.Header.Set(name, value)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("collector returned %s", resp.Status)
+	}
+	return nil
+}
+
+type otlpAttribute struct {
+	Key   string            `json:"key"`
+	Value map[string]string `json:"value"`
+}
+
+type otlpDataPoint struct {
+	Attributes        []otlpAttribute `json:"attributes"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano"`
+	TimeUnixNano      string          `json:"timeUnixNano"`
+	AsInt             string          `json:"asInt,omitempty"`
+	AsDouble          *float64        `json:"asDouble,omitempty"`
+}
+
+type otlpMetric struct {
+	Name string `json:"name"`
+	Unit string `json:"unit"`
+	Sum  struct {
+		AggregationTemporality int             `json:"aggregationTemporality"`
+		IsMonotonic            bool            `json:"isMonotonic"`
+		DataPoints             []otlpDataPoint `json:"dataPoints"`
+	} `json:"sum"`
+}
+
+// OTLPPayload builds an ExportMetricsServiceRequest with cumulative sums.
+func OTLPPayload(serviceName string, series []Series, start, now time.Time) map[string]any {
+	startNano, nowNano := strconv.FormatInt(start.UnixNano(), 10), strconv.FormatInt(now.UnixNano(), 10)
+	attr := func(key, value string) otlpAttribute {
+		return otlpAttribute{Key: key, Value: map[string]string{"stringValue": value}}
+	}
+	newMetric := func(name, unit string) *otlpMetric {
+		m := &otlpMetric{Name: name, Unit: unit}
+		m.Sum.AggregationTemporality = 2 // cumulative
+		m.Sum.IsMonotonic = true
+		m.Sum.DataPoints = []otlpDataPoint{}
+		return m
+	}
+	requests, tokens, cost := newMetric("cliproxy.requests", "{request}"), newMetric("cliproxy.tokens", "{token}"), newMetric("cliproxy.cost", "USD")
+	cached, reasoning := newMetric("cliproxy.tokens.cached", "{token}"), newMetric("cliproxy.tokens.reasoning", "{token}")
+	for _, s := range series {
+		attrs := []otlpAttribute{attr("provider", s.Provider), attr("model", s.Model), attr("status", s.Status)}
+		if s.APIKey != "" {
+			attrs = append(attrs, attr("api_key", s.APIKey))
+		}
+		point := func(extra ...otlpAttribute) otlpDataPoint {
+			return otlpDataPoint{Attributes: append(append([]otlpAttribute{}, attrs...), extra...), StartTimeUnixNano: startNano, TimeUnixNano: nowNano}
+		}
+		p := point()
+		p.AsInt = strconv.FormatInt(s.Requests, 10)
+		requests.Sum.DataPoints = append(requests.Sum.DataPoints, p)
+		for _, t := range []struct {
+			metric *otlpMetric
+			value  int64
+			extra  []otlpAttribute
+		}{
+			{tokens, s.InputTokens, []otlpAttribute{attr("type", "input")}},
+			{tokens, s.OutputTokens, []otlpAttribute{attr("type", "output")}},
+			{cached, s.CachedTokens, nil},
+			{reasoning, s.ReasoningTokens, nil},
+		} {
+			p = point(t.extra...)
+			p.AsInt = strconv.FormatInt(t.value, 10)
+			t.metric.Sum.DataPoints = append(t.metric.Sum.DataPoints, p)
+		}
+		p = point()
+		value := s.Cost
+		p.AsDouble = &value
+		cost.Sum.DataPoints = append(cost.Sum.DataPoints, p)
+	}
+	return map[string]any{
+		"resourceMetrics": []any{map[string]any{
+			"resource": map[string]any{"attributes": []otlpAttribute{attr("service.name", serviceName)}},
+			"scopeMetrics": []any{map[string]any{
+				"scope":   map[string]string{"name": "cliproxyapi"},
+				"metrics": []*otlpMetric{requests, tokens, cached, reasoning, cost},
+			}},
+		}},
+	}
+}
@@ -0,0 +1,52 @@
+package metrics
+
+import (
+	"sort"
+	"sync"
+)
+
+// ExtractionFailure counts requests on one route whose model could not be extracted.
+type ExtractionFailure struct {
+	Route  string
+	Policy string
+	Count  int64
+}
+
+type extractionKey struct {
+	route  string
+	policy string
+}
+
+// extractionFailures counts independently of the usage series: the requests may never reach
+// a provider.
+type extractionFailures struct {
+	mu     sync.Mutex
+	counts map[extractionKey]int64
+}
+
+var modelExtraction = &extractionFailures{counts: make(map[extractionKey]int64)}
+
+// CountModelExtractionFailure records a request on route, the gin route pattern, whose model
+// could not be extracted and that was handled by policy.
+func CountModelExtractionFailure(route, policy string) {
+	modelExtraction.mu.Lock()
+	modelExtraction.counts[extractionKey{route: route, policy: policy}]++
+	modelExtraction.mu.Unlock()
+}
+
+// ModelExtractionFailures returns the failure counts by route and policy.
+func ModelExtractionFailures() []ExtractionFailure {
+	modelExtraction.mu.Lock()
+	out := make([]ExtractionFailure, 0, len(modelExtraction.counts))
+	for key, count := range modelExtraction.counts {
+		out = append(out, ExtractionFailure{Route: key.route, Policy: key.policy, Count: count})
+	}
+	modelExtraction.mu.Unlock()
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Route != out[j].Route {
+			return out[i].Route < out[j].Route
+		}
+		return out[i].Policy < out[j].Policy
+	})
+	return out
+}
@@ -0,0 +1,88 @@
+package metrics
+
+import (
+	"bufio"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+// ContentType is the Prometheus text exposition format.
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// WritePrometheus writes every series in the Prometheus text format.
+func (c *Collector) WritePrometheus(w io.Writer) error {
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	omitKey := c.cfg.Labels.APIKey == config.MetricsAPIKeyOmit
+	firstToken := snapshotHistograms(c.firstToken)
+	interToken := snapshotHistograms(c.interToken)
+	c.mu.Unlock()
+	series := c.Snapshot()
+
+	bw := bufio.NewWriter(w)
+	labelSet := func(s Series, extra string) string {
+		var b strings.Builder
+		b.WriteString(`{provider="` + labelEscaper.Replace(s.Provider) + `",model="` + labelEscaper.Replace(s.Model) + `"`)
+		if !omitKey {
+			b.WriteString(`,api_key="` + labelEscaper.Replace(s.APIKey) + `"`)
+		}
+		b.WriteString(`,status="` + s.Status + `"`)
+		if extra != "" {
+			b.WriteString("," + extra)
+		}
+		b.WriteString("}")
+		return b.String()
+	}
+	header := func(name, help string) {
+		bw.WriteString("# HELP " + name + " " + help + "\n# TYPE " + name + " counter\n")
+	}
+
+	header("cliproxy_requests_total", "Upstream requests by provider, model, client API key and outcome.")
+	for _, s := range series {
+		bw.WriteString("cliproxy_requests_total" + labelSet(s, "") + " " + strconv.FormatInt(s.Requests, 10) + "\n")
+	}
+	header("cliproxy_tokens_total", "Tokens by provider, model, client API key, outcome and direction (input or output).")
+	for _, s := range series {
+		bw.WriteString("cliproxy_tokens_total" + labelSet(s, `type="input"`) + " " + strconv.FormatInt(s.InputTokens, 10) + "\n")
+		bw.WriteString("cliproxy_tokens_total" + labelSet(s, `type="output"`) + " " + strconv.FormatInt(s.OutputTokens, 10) + "\n")
+	}
+	header("cliproxy_cached_tokens_total", "Input tokens read from the prompt cache, a part of the input tokens.")
+	for _, s := range series {
+		bw.WriteString("cliproxy_cached_tokens_total" + labelSet(s, "") + " " + strconv.FormatInt(s.CachedTokens, 10) + "\n")
+	}
+	header("cliproxy_reasoning_tokens_total", "Output tokens spent on reasoning, a part of the output tokens.")
+	for _, s := range series {
+		bw.WriteString("cliproxy_reasoning_tokens_total" + labelSet(s, "") + " " + strconv.FormatInt(s.ReasoningTokens, 10) + "\n")
+	}
+	header("cliproxy_cost_usd_total", "Cost in USD at the configured model prices.")
+	for _, s := range series {
+		bw.WriteString("cliproxy_cost_usd_total" + labelSet(s, "") + " " + strconv.FormatFloat(s.Cost, 'g', -1, 64) + "\n")
+	}
+	writeHistograms(bw, "cliproxy_stream_first_token_seconds", "Time from the start of an upstream attempt to the first streamed chunk, by provider and model.", firstTokenBuckets, firstToken)
+	writeHistograms(bw, "cliproxy_stream_inter_token_seconds", "Time between consecutive streamed chunks, by provider and model.", interTokenBuckets, interToken)
+	header("cliproxy_model_extraction_failures_total", "Requests whose model could not be extracted, by route and missing-model policy.")
+	for _, f := range ModelExtractionFailures() {
+		bw.WriteString(`cliproxy_model_extraction_failures_total{route="` + labelEscaper.Replace(f.Route) + `",policy="` + f.Policy + `"} ` + strconv.FormatInt(f.Count, 10) + "\n")
+	}
+	bw.WriteString("# HELP cliproxy_amp_in_flight_requests Amp requests being served, by route.\n# TYPE cliproxy_amp_in_flight_requests gauge\n")
+	ampRoutes := AmpRoutes()
+	for _, r := range ampRoutes {
+		bw.WriteString(`cliproxy_amp_in_flight_requests{route="` + labelEscaper.Replace(r.Route) + `"} ` + strconv.FormatInt(r.InFlight, 10) + "\n")
+	}
+	header("cliproxy_amp_route_requests_total", "Amp requests by route.")
+	for _, r := range ampRoutes {
+		bw.WriteString(`cliproxy_amp_route_requests_total{route="` + labelEscaper.Replace(r.Route) + `"} ` + strconv.FormatInt(r.Requests, 10) + "\n")
+	}
+	header("cliproxy_amp_model_requests_total", "Amp requests by requested model, served model (empty when unchanged), routing decision and serving provider.")
+	for _, t := range AmpTrafficCounts() {
+		bw.WriteString(`cliproxy_amp_model_requests_total{model="` + labelEscaper.Replace(t.Model) + `",served_model="` + labelEscaper.Replace(t.Served) + `",route_type="` + t.RouteType + `",provider="` + labelEscaper.Replace(t.Provider) + `"} ` + strconv.FormatInt(t.Requests, 10) + "\n")
+	}
+	return bw.Flush()
+}
@@ -0,0 +1,113 @@
+package metrics
+
+import (
+	"bufio"
+	"context"
+	"sort"
+	"strconv"
+
+	coreusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+)
+
+var (
+	// firstTokenBuckets are the upper bounds, in seconds, of the time to first token
+	// histogram.
+	firstTokenBuckets = []float64{0.1, 0.25, 0.5, 1, 2, 3, 5, 10, 20, 30, 60}
+	// interTokenBuckets are the upper bounds, in seconds, of the inter-token latency
+	// histogram.
+	interTokenBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+)
+
+// latencyLabels identify one stream latency histogram.
+type latencyLabels struct {
+	Provider string
+	Model    string
+}
+
+// histogram counts observations per bucket; counts[i] holds the observations up to
+// buckets[i], the last entry those above every bound.
+type histogram struct {
+	counts []int64
+	count  int64
+	sum    float64
+}
+
+func (h *histogram) observe(buckets []float64, seconds float64) {
+	if h.counts == nil {
+		h.counts = make([]int64, len(buckets)+1)
+	}
+	h.counts[sort.SearchFloat64s(buckets, seconds)]++
+	h.count++
+	h.sum += seconds
+}
+
+// HandleStreamTiming implements coreusage.StreamTimingPlugin. The histograms are served on
+// /metrics only; they are not pushed to statsd or OTLP.
+func (c *Collector) HandleStreamTiming(_ context.Context, timing coreusage.StreamTiming) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.cfg.Enable {
+		return
+	}
+	labels := latencyLabels{Provider: timing.Provider, Model: c.modelLabelLocked(timing.Model)}
+	if labels.Provider == "" {
+		labels.Provider = "unknown"
+	}
+	first := histogramFor(c.firstToken, labels)
+	first.observe(firstTokenBuckets, timing.FirstToken.Seconds())
+	inter := histogramFor(c.interToken, labels)
+	for _, gap := range timing.Gaps {
+		inter.observe(interTokenBuckets, gap.Seconds())
+	}
+}
+
+func histogramFor(m map[latencyLabels]*histogram, labels latencyLabels) *histogram {
+	h := m[labels]
+	if h == nil {
+		h = &histogram{}
+		m[labels] = h
+	}
+	return h
+}
+
+// writeHistograms writes the histograms of m as the Prometheus histogram name.
+func writeHistograms(bw *bufio.Writer, name, help string, buckets []float64, m map[latencyLabels]histogram) {
+	bw.WriteString("# HELP " + name + " " + help + "\n# TYPE " + name + " histogram\n")
+	keys := make([]latencyLabels, 0, len(m))
+	for labels := range m {
+		keys = append(keys, labels)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].Provider != keys[j].Provider {
+			return keys[i].Provider < keys[j].Provider
+		}
+		return keys[i].Model < keys[j].Model
+	})
+	for _, labels := range keys {
+		h := m[labels]
+		base := `provider="` + labelEscaper.Replace(labels.Provider) + `",model="` + labelEscaper.Replace(labels.Model) + `"`
+		var cumulative int64
+		for i, bound := range buckets {
+			cumulative += h.counts[i]
+			bw.WriteString(name + "_bucket{" + base + `,le="` + strconv.FormatFloat(bound, 'g', -1, 64) + `"} ` + strconv.FormatInt(cumulative, 10) + "\n")
+		}
+		bw.WriteString(name + "_bucket{" + base + `,le="+Inf"} ` + strconv.FormatInt(h.count, 10) + "\n")
+		bw.WriteString(name + "_sum{" + base + "} " + strconv.FormatFloat(h.sum, 'g', -1, 64) + "\n")
+		bw.WriteString(name + "_count{" + base + "} " + strconv.FormatInt(h.count, 10) + "\n")
+	}
+}
+
+// snapshotHistograms copies the histograms with observations. The caller holds c.mu.
+func snapshotHistograms(m map[latencyLabels]*histogram) map[latencyLabels]histogram {
+	out := make(map[latencyLabels]histogram, len(m))
+	for labels, h := range m {
+		if h.count == 0 {
+			continue
+		}
+		out[labels] = histogram{counts: append([]int64(nil), h.counts...), count: h.count, sum: h.sum}
+	}
+	return out
+}
@@ -0,0 +1,283 @@
+// Package mirror copies a sample of API requests to a secondary proxy instance in the
+// background. Credentials are scrubbed from the copies, which carry the configured
+// staging key instead, and responses of the secondary instance are discarded.
+package mirror
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/offline"
+	log "github.com/sirupsen/logrus"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// Header marks mirrored requests so the secondary instance can tell them apart.
+const Header = "X-CLIProxy-Mirror"
+
+// scrubbedBodyFields are the top-level body fields removed from every mirrored request.
+var scrubbedBodyFields = []string{"api_key", "apiKey", "access_token", "refresh_token", "password", "secret"}
+
+// Request is a scrubbed copy of an API request waiting to be mirrored.
+type Request struct {
+	Method   string
+	Path     string
+	RawQuery string
+	Header   http.Header
+	Body     []byte
+}
+
+var defaultMirror = NewMirror()
+
+// Default returns the process-wide mirror.
+func Default() *Mirror { return defaultMirror }
+
+// Mirror queues and sends mirrored requests.
+type Mirror struct {
+	mu         sync.RWMutex
+	cfg        config.MirrorConfig
+	client     *http.Client
+	queue      chan Request
+	stop       chan struct{}
+	workers    sync.WaitGroup
+	randFloat  func() float64
+	sendFunc   func(context.Context, Request)
+	dropLogged time.Time
+}
+
+// NewMirror constructs a disabled mirror.
+func NewMirror() *Mirror {
+	m := &Mirror{client: offline.Default().Client(&http.Client{}), randFloat: rand.Float64}
+	m.sendFunc = m.send
+	return m
+}
+
+// Configure replaces the mirror settings and restarts the workers. Queued requests of the
+// previous configuration are dropped.
+func (m *Mirror) Configure(cfg config.MirrorConfig) {
+	if m == nil {
+		return
+	}
+	m.Stop()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.cfg = cfg
+	m.client = offline.Default().Client(&http.Client{Timeout: time.Duration(cfg.TimeoutSeconds) * time.Second})
+	if !cfg.Enabled || cfg.URL == "" {
+		return
+	}
+	queue := make(chan Request, cfg.QueueSize)
+	stop := make(chan struct{})
+	m.queue, m.stop = queue, stop
+	workers := cfg.Workers
+	if workers <= 0 {
+		workers = 1
+	}
+	for i := 0; i < workers; i++ {
+		m.workers.Add(1)
+		go m.run(queue, stop)
+	}
+}
+
+// Stop ends the workers; requests still queued are dropped.
+func (m *Mirror) Stop() {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	stop := m.stop
+	m.queue, m.stop = nil, nil
+	m.mu.Unlock()
+	if stop != nil {
+		close(stop)
+	}
+	m.workers.Wait()
+}
+
+// Sample reports whether a request to path is picked for mirroring.
+func (m *Mirror) Sample(path string) bool {
+	if m == nil {
+		return false
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if m.queue == nil || !matchesPaths(m.cfg.Paths, path) {
+		return false
+	}
+	return m.cfg.SampleRate >= 1 || m.randFloat() < m.cfg.SampleRate
+}
+
+// Submit scrubs a request and queues it for mirroring without blocking. It reports false
+// when mirroring is off or the queue is full.
+func (m *Mirror) Submit(method, path, rawQuery string, header http.Header, body []byte) bool {
+	if m == nil {
+		return false
+	}
+	m.mu.RLock()
+	queue := m.queue
+	cfg := m.cfg
+	m.mu.RUnlock()
+	if queue == nil {
+		return false
+	}
+	req := Request{
+		Method:   method,
+		Path:     path,
+		RawQuery: scrubQuery(rawQuery),
+		Header:   scrubHeader(header, cfg.APIKey),
+		Body:     scrubBody(body, cfg.ScrubFields),
+	}
+	select {
+	case queue <- req:
+		return true
+	default:
+		m.logDrop()
+		return false
+	}
+}
+
+func (m *Mirror) run(queue <-chan Request, stop <-chan struct{}) {
+	defer m.workers.Done()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		<-stop
+		cancel()
+	}()
+	for {
+		select {
+		case <-stop:
+			return
+		case req := <-queue:
+			m.sendFunc(ctx, req)
+		}
+	}
+}
+
+func (m *Mirror) send(ctx context.Context, req Request) {
+	m.mu.RLock()
+	base, client := m.cfg.URL, m.client
+	m.mu.RUnlock()
+	target := base + req.Path
+	if req.RawQuery != "" {
+		target += "?" + req.RawQuery
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, req.Method, target, bytes.NewReader(req.Body))
+	if err != nil {
+		log.Debugf("mirror: failed to build request for %s: %v", req.Path, err)
+		return
+	}
+	httpReq.Header = req.Header
+	resp, err := client.Do(httpReq)
+	if err != nil {
+		log.Debugf("mirror: request to %s failed: %v", req.Path, err)
+		return
+	}
+	// Read streamed responses to the end so the secondary instance runs the full request.
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if errClose := resp.Body.Close(); errClose != nil {
+		log.Debugf("mirror: failed to close response body: %v", errClose)
+	}
+	if resp.StatusCode >= http.StatusBadRequest {
+		log.Debugf("mirror: %s answered %d", req.Path, resp.StatusCode)
+	}
+}
+
+// logDrop warns about a full queue at most once a minute.
+func (m *Mirror) logDrop() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if now := time.Now(); now.Sub(m.dropLogged) >= time.Minute {
+		m.dropLogged = now
+		log.Warnf("mirror: queue is full, dropping mirrored requests")
+	}
+}
+
+func matchesPaths(prefixes []string, path string) bool {
+	if len(prefixes) == 0 {
+		return true
+	}
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(path, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// scrubHeader drops credentials, cookies and hop-by-hop headers, then authenticates
+// with apiKey when set.
+func scrubHeader(header http.Header, apiKey string) http.Header {
+	out := make(http.Header, len(header)+2)
+	for key, values := range header {
+		if sensitiveName(key) {
+			continue
+		}
+		switch http.CanonicalHeaderKey(key) {
+		case "Cookie", "Connection", "Content-Length", "Accept-Encoding", "Transfer-Encoding", "Upgrade":
+			continue
+		}
+		out[key] = append([]string(nil), values...)
+	}
+	if apiKey != "" {
+		out.Set("Authorization", "Bearer "+apiKey)
+	}
+	out.Set(Header, "1")
+	return out
+}
+
+// scrubQuery removes key-like parameters such as Gemini's "key".
+func scrubQuery(raw string) string {
+	if raw == "" {
+		return ""
+	}
+	values, err := url.ParseQuery(raw)
+	if err != nil {
+		return ""
+	}
+	for key := range values {
+		if lower := strings.ToLower(key); lower == "key" || sensitiveName(lower) {
+			values.Del(key)
+		}
+	}
+	return values.Encode()
+}
+
+// scrubBody removes secret-bearing fields from JSON bodies. Other bodies are kept as is.
+func scrubBody(body []byte, extra []string) []byte {
+	if len(body) == 0 || !gjson.ValidBytes(body) {
+		return body
+	}
+	out := body
+	for _, fields := range [][]string{scrubbedBodyFields, extra} {
+		for _, field := range fields {
+			if !gjson.GetBytes(out, field).Exists() {
+				continue
+			}
+			if updated, err := sjson.DeleteBytes(out, field); err == nil {
+				out = updated
+			}
+		}
+	}
+	return out
+}
+
+func sensitiveName(name string) bool {
+	lower := strings.ToLower(name)
+	for _, marker := range []string{"authorization", "api-key", "api_key", "apikey", "token", "secret", "password"} {
+		if strings.Contains(lower, marker) {
+			return true
+		}
+	}
+	return false
+}
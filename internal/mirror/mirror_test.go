@@ -0,0 +1,121 @@
+package mirror
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/tidwall/gjson"
+)
+
+func TestMirror_SendsScrubbedCopies(t *testing.T) {
+	received := make(chan *http.Request, 1)
+	bodies := make(chan []byte, 1)
+	staging := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received <- r
+		bodies <- body
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer staging.Close()
+
+	m := NewMirror()
+	m.Configure(config.MirrorConfig{
+		Enabled:        true,
+		URL:            staging.URL,
+		APIKey:         "staging-key",
+		SampleRate:     1,
+		ScrubFields:    []string{"metadata.user_id"},
+		TimeoutSeconds: 5,
+		QueueSize:      4,
+		Workers:        1,
+	})
+	defer m.Stop()
+
+	header := http.Header{}
+	header.Set("Authorization", "Bearer sk-prod")
+	header.Set("X-Goog-Api-Key", "AIza-prod")
+	header.Set("Cookie", "session=abc")
+	header.Set("Content-Type", "application/json")
+	header.Set("X-Session-Id", "s1")
+	body := []byte(`{"model":"gpt-5","api_key":"sk-body","metadata":{"user_id":"u1","tag":"t"},"messages":[]}`)
+	if !m.Submit(http.MethodPost, "/v1/chat/completions", "key=AIza-query&alt=sse", header, body) {
+		t.Fatal("submit should queue the request")
+	}
+
+	var req *http.Request
+	select {
+	case req = <-received:
+	case <-time.After(5 * time.Second):
+		t.Fatal("mirrored request not received")
+	}
+	got := <-bodies
+	if req.URL.Path != "/v1/chat/completions" || req.URL.RawQuery != "alt=sse" {
+		t.Fatalf("url = %s", req.URL)
+	}
+	if req.Header.Get("Authorization") != "Bearer staging-key" || req.Header.Get("X-Goog-Api-Key") != "" || req.Header.Get("Cookie") != "" {
+		t.Fatalf("credentials not scrubbed: %v", req.Header)
+	}
+	if req.Header.Get("X-Session-Id") != "s1" || req.Header.Get(Header) != "1" {
+		t.Fatalf("headers = %v", req.Header)
+	}
+	if gjson.GetBytes(got, "api_key").Exists() || gjson.GetBytes(got, "metadata.user_id").Exists() {
+		t.Fatalf("body not scrubbed: %s", got)
+	}
+	if gjson.GetBytes(got, "model").String() != "gpt-5" || gjson.GetBytes(got, "metadata.tag").String() != "t" {
+		t.Fatalf("body = %s", got)
+	}
+	if string(body) == string(got) {
+		t.Fatal("the client body should not be altered")
+	}
+}
+
+func TestMirror_SampleAndQueue(t *testing.T) {
+	m := NewMirror()
+	if m.Sample("/v1/messages") || m.Submit(http.MethodPost, "/v1/messages", "", nil, nil) {
+		t.Fatal("a disabled mirror should neither sample nor queue")
+	}
+
+	block := make(chan struct{})
+	m.sendFunc = func(_ context.Context, _ Request) { <-block }
+	m.Configure(config.MirrorConfig{
+		Enabled:    true,
+		URL:        "http://staging.invalid",
+		SampleRate: 0.5,
+		Paths:      []string{"/v1/messages"},
+		QueueSize:  1,
+		Workers:    1,
+	})
+	defer func() {
+		close(block)
+		m.Stop()
+	}()
+
+	m.randFloat = func() float64 { return 0.7 }
+	if m.Sample("/v1/messages") {
+		t.Fatal("requests above the sample rate should be skipped")
+	}
+	m.randFloat = func() float64 { return 0.2 }
+	if !m.Sample("/v1/messages") {
+		t.Fatal("requests below the sample rate should be mirrored")
+	}
+	if m.Sample("/v1/chat/completions") {
+		t.Fatal("paths outside the prefixes should be skipped")
+	}
+
+	// One request occupies the worker and one fills the queue; the next is dropped.
+	accepted := 0
+	for i := 0; i < 3; i++ {
+		if m.Submit(http.MethodPost, "/v1/messages", "", nil, []byte(`{}`)) {
+			accepted++
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if accepted != 2 {
+		t.Fatalf("accepted = %d, want 2", accepted)
+	}
+}
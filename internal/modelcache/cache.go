@@ -0,0 +1,267 @@
+// Package modelcache serves the model listing endpoints from pre-rendered responses.
+// Editors such as the Amp CLI list models on every window open, so a shared instance sees
+// bursts of identical listing requests; each variant (OpenAI, Claude, Gemini, ...) is
+// rendered once, served from memory and rebuilt in the background whenever the model
+// registry changes or the TTL passes.
+package modelcache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+)
+
+// Variants of the listing, one per response format.
+const (
+	OpenAI       = "openai"
+	Claude       = "claude"
+	Gemini       = "gemini"
+	GeminiCompat = "gemini-compat"
+)
+
+// StatusHeader reports how a listing was served: hit, stale (served while it is being
+// rebuilt) or miss (rendered for this request).
+const StatusHeader = "X-CPA-Models-Cache"
+
+// watchInterval is how often the background loop checks for registry changes.
+const watchInterval = time.Second
+
+// snapshot is one pre-rendered listing.
+type snapshot struct {
+	contentType string
+	body        []byte
+	etag        string
+	generation  uint64
+	rendered    time.Time
+}
+
+type entry struct {
+	render     gin.HandlerFunc
+	mu         sync.Mutex // serializes renders
+	current    atomic.Pointer[snapshot]
+	refreshing atomic.Bool
+}
+
+// Cache holds the pre-rendered listings.
+type Cache struct {
+	mu         sync.Mutex
+	enabled    bool
+	ttl        time.Duration
+	entries    map[string]*entry
+	cancel     context.CancelFunc
+	generation func() uint64
+	now        func() time.Time
+}
+
+var defaultCache = New()
+
+// Default returns the process-wide listing cache.
+func Default() *Cache { return defaultCache }
+
+// New returns a disabled cache tracking the global model registry.
+func New() *Cache {
+	return &Cache{
+		entries:    make(map[string]*entry),
+		ttl:        config.DefaultModelsCacheTTLSeconds * time.Second,
+		generation: registry.GetGlobalRegistry().Generation,
+		now:        time.Now,
+	}
+}
+
+// Configure applies cfg and starts or stops the background refresh loop.
+func (c *Cache) Configure(cfg config.ModelsCacheConfig) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.enabled = !cfg.Disable
+	c.ttl = time.Duration(cfg.TTLSeconds) * time.Second
+	if c.ttl <= 0 {
+		c.ttl = config.DefaultModelsCacheTTLSeconds * time.Second
+	}
+	switch {
+	case c.enabled && c.cancel == nil:
+		ctx, cancel := context.WithCancel(context.Background())
+		c.cancel = cancel
+		go c.run(ctx)
+	case !c.enabled && c.cancel != nil:
+		c.cancel()
+		c.cancel = nil
+	}
+	if !c.enabled {
+		c.entries = make(map[string]*entry)
+	}
+}
+
+// Stop halts the background refresh loop.
+func (c *Cache) Stop() {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.cancel != nil {
+		c.cancel()
+		c.cancel = nil
+	}
+}
+
+// Serve writes the listing of variant. render produces it the uncached way; it is called
+// with a detached context, so it must depend on nothing but the variant. A request
+// arriving while the listing is outdated is answered with the previous listing while the
+// new one renders.
+func (c *Cache) Serve(ctx *gin.Context, variant string, render gin.HandlerFunc) {
+	e := c.entry(variant, render)
+	if e == nil {
+		render(ctx)
+		return
+	}
+	status := "hit"
+	snap := e.current.Load()
+	switch {
+	case snap == nil:
+		status = "miss"
+		if snap = c.renderNow(e); snap == nil {
+			render(ctx)
+			return
+		}
+	case c.outdated(snap):
+		status = "stale"
+		c.refreshAsync(e)
+	}
+	ctx.Header(StatusHeader, status)
+	ctx.Header("ETag", snap.etag)
+	if match := ctx.GetHeader("If-None-Match"); match != "" && match == snap.etag {
+		ctx.Status(http.StatusNotModified)
+		return
+	}
+	ctx.Data(http.StatusOK, snap.contentType, snap.body)
+}
+
+// entry returns the entry of variant, or nil when the cache is disabled.
+func (c *Cache) entry(variant string, render gin.HandlerFunc) *entry {
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.enabled {
+		return nil
+	}
+	e := c.entries[variant]
+	if e == nil {
+		e = &entry{render: render}
+		c.entries[variant] = e
+	}
+	return e
+}
+
+func (c *Cache) outdated(snap *snapshot) bool {
+	c.mu.Lock()
+	ttl := c.ttl
+	c.mu.Unlock()
+	return snap.generation != c.generation() || c.now().Sub(snap.rendered) >= ttl
+}
+
+// renderNow renders e unless a concurrent render already produced a current listing.
+func (c *Cache) renderNow(e *entry) *snapshot {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if snap := e.current.Load(); snap != nil && !c.outdated(snap) {
+		return snap
+	}
+	snap := c.renderSnapshot(e.render)
+	if snap != nil {
+		e.current.Store(snap)
+	}
+	return snap
+}
+
+func (c *Cache) refreshAsync(e *entry) {
+	if !e.refreshing.CompareAndSwap(false, true) {
+		return
+	}
+	go func() {
+		defer e.refreshing.Store(false)
+		c.renderNow(e)
+	}()
+}
+
+// renderSnapshot runs render against a recorder; listings answered with an error are not
+// kept.
+func (c *Cache) renderSnapshot(render gin.HandlerFunc) *snapshot {
+	generation := c.generation()
+	rec := &recorder{header: make(http.Header)}
+	ctx, _ := gin.CreateTestContext(rec)
+	ctx.Request = &http.Request{Method: http.MethodGet, URL: &url.URL{Path: "/"}, Header: make(http.Header)}
+	render(ctx)
+	ctx.Writer.WriteHeaderNow()
+	if rec.status != http.StatusOK {
+		return nil
+	}
+	sum := sha256.Sum256(rec.body)
+	return &snapshot{
+		contentType: rec.header.Get("Content-Type"),
+		body:        rec.body,
+		etag:        `"` + hex.EncodeToString(sum[:8]) + `"`,
+		generation:  generation,
+		rendered:    c.now(),
+	}
+}
+
+// run rebuilds the outdated listings in the background, so requests rarely see one.
+func (c *Cache) run(ctx context.Context) {
+	ticker := time.NewTicker(watchInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			c.mu.Lock()
+			entries := make([]*entry, 0, len(c.entries))
+			for _, e := range c.entries {
+				entries = append(entries, e)
+			}
+			c.mu.Unlock()
+			for _, e := range entries {
+				if snap := e.current.Load(); snap == nil || c.outdated(snap) {
+					c.refreshAsync(e)
+				}
+			}
+		}
+	}
+}
+
+// recorder captures a rendered listing.
+type recorder struct {
+	header http.Header
+	status int
+	body   []byte
+}
+
+func (r *recorder) Header() http.Header { return r.header }
+
+func (r *recorder) Write(p []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	r.body = append(r.body, p...)
+	return len(p), nil
+}
+
+func (r *recorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+}
@@ -0,0 +1,76 @@
+package modelcache
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+func TestCacheServesPreRenderedListing(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	var generation atomic.Uint64
+	var renders atomic.Int32
+	render := func(c *gin.Context) {
+		n := renders.Add(1)
+		c.JSON(http.StatusOK, gin.H{"object": "list", "render": n})
+	}
+
+	cache := New()
+	cache.generation = generation.Load
+	cache.Configure(config.ModelsCacheConfig{TTLSeconds: 60})
+	defer cache.Stop()
+
+	engine := gin.New()
+	engine.GET("/v1/models", func(c *gin.Context) { cache.Serve(c, OpenAI, render) })
+	get := func(etag string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/v1/models", nil)
+		if etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+		rec := httptest.NewRecorder()
+		engine.ServeHTTP(rec, req)
+		return rec
+	}
+
+	first := get("")
+	if first.Code != http.StatusOK || first.Header().Get(StatusHeader) != "miss" || first.Body.String() != `{"object":"list","render":1}` {
+		t.Fatalf("first = %d %s %q", first.Code, first.Header().Get(StatusHeader), first.Body.String())
+	}
+	if ct := first.Header().Get("Content-Type"); ct != "application/json; charset=utf-8" {
+		t.Fatalf("content type = %q", ct)
+	}
+	second := get("")
+	if second.Header().Get(StatusHeader) != "hit" || second.Body.String() != first.Body.String() || renders.Load() != 1 {
+		t.Fatalf("second = %s %q after %d renders", second.Header().Get(StatusHeader), second.Body.String(), renders.Load())
+	}
+	if notModified := get(first.Header().Get("ETag")); notModified.Code != http.StatusNotModified || notModified.Body.Len() != 0 {
+		t.Fatalf("conditional request = %d %q", notModified.Code, notModified.Body.String())
+	}
+
+	// A registry change serves the previous listing once while the new one renders.
+	generation.Add(1)
+	if stale := get(""); stale.Header().Get(StatusHeader) != "stale" || stale.Body.String() != first.Body.String() {
+		t.Fatalf("after change = %s %q", stale.Header().Get(StatusHeader), stale.Body.String())
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		rec := get("")
+		if rec.Header().Get(StatusHeader) == "hit" && rec.Body.String() == `{"object":"list","render":2}` {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("listing not refreshed: %s %q", rec.Header().Get(StatusHeader), rec.Body.String())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	cache.Configure(config.ModelsCacheConfig{Disable: true})
+	if rec := get(""); rec.Header().Get(StatusHeader) != "" || renders.Load() != 3 {
+		t.Fatalf("disabled cache = %s after %d renders", rec.Header().Get(StatusHeader), renders.Load())
+	}
+}
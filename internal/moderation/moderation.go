@@ -0,0 +1,242 @@
+// Package moderation answers OpenAI-compatible moderation requests, either with locally
+// configured keyword/regex rules or by forwarding them to an upstream moderation endpoint.
+package moderation
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/offline"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	sdkconfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	log "github.com/sirupsen/logrus"
+	"github.com/tidwall/gjson"
+)
+
+// LocalModel is reported as the model of locally classified responses when the request
+// does not name one.
+const LocalModel = "cliproxy-local-moderation"
+
+// forwardTimeout bounds a forwarded moderation request.
+const forwardTimeout = 30 * time.Second
+
+// Result is the moderation outcome of one input, in the OpenAI results[] shape.
+type Result struct {
+	Flagged        bool               `json:"flagged"`
+	Categories     map[string]bool    `json:"categories"`
+	CategoryScores map[string]float64 `json:"category_scores"`
+}
+
+// Response is an OpenAI-compatible moderation response.
+type Response struct {
+	ID      string   `json:"id"`
+	Model   string   `json:"model"`
+	Results []Result `json:"results"`
+}
+
+type compiledRule struct {
+	category string
+	keywords []string
+	patterns []*regexp.Regexp
+}
+
+// Classifier holds the moderation configuration.
+type Classifier struct {
+	mu         sync.RWMutex
+	rules      []compiledRule
+	categories []string
+	forwardURL string
+	apiKey     string
+	client     *http.Client
+}
+
+var defaultClassifier = NewClassifier()
+
+// Default returns the process-wide classifier.
+func Default() *Classifier { return defaultClassifier }
+
+// NewClassifier constructs a classifier without rules that classifies everything as safe.
+func NewClassifier() *Classifier {
+	return &Classifier{client: offline.Default().Client(&http.Client{Timeout: forwardTimeout})}
+}
+
+// Configure replaces the rules and forwarding target. Invalid patterns are logged and skipped.
+// proxyURL is used for forwarded requests.
+func (c *Classifier) Configure(cfg config.ModerationConfig, proxyURL string) {
+	if c == nil {
+		return
+	}
+	rules := make([]compiledRule, 0, len(cfg.Rules))
+	var categories []string
+	seen := make(map[string]bool)
+	for _, rule := range cfg.Rules {
+		compiled := compiledRule{category: rule.Category}
+		for _, keyword := range rule.Keywords {
+			compiled.keywords = append(compiled.keywords, strings.ToLower(keyword))
+		}
+		for _, pattern := range rule.Patterns {
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				log.Warnf("moderation: skipping invalid pattern %q for category %s: %v", pattern, rule.Category, err)
+				continue
+			}
+			compiled.patterns = append(compiled.patterns, re)
+		}
+		rules = append(rules, compiled)
+		if !seen[rule.Category] {
+			seen[rule.Category] = true
+			categories = append(categories, rule.Category)
+		}
+	}
+	forwardURL := ""
+	if cfg.Mode == config.ModerationModeForward {
+		forwardURL = cfg.ForwardURL
+	}
+	client := offline.Default().Client(util.SetProxy(&sdkconfig.SDKConfig{ProxyURL: proxyURL}, &http.Client{Timeout: forwardTimeout}))
+
+	c.mu.Lock()
+	c.rules = rules
+	c.categories = categories
+	c.forwardURL = forwardURL
+	c.apiKey = cfg.APIKey
+	c.client = client
+	c.mu.Unlock()
+}
+
+// Forwarding reports whether requests are relayed upstream before falling back to the rules.
+func (c *Classifier) Forwarding() bool {
+	if c == nil {
+		return false
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.forwardURL != ""
+}
+
+// Forward relays a moderation request body upstream and returns its status and body.
+func (c *Classifier) Forward(ctx context.Context, body []byte) (int, []byte, error) {
+	c.mu.RLock()
+	target, apiKey, client := c.forwardURL, c.apiKey, c.client
+	c.mu.RUnlock()
+	if target == "" {
+		return 0, nil, fmt.Errorf("moderation forwarding is not configured")
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, bytes.NewReader(body))
+	if err != nil {
+		return 0, nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+apiKey)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer func() {
+		if errClose := resp.Body.Close(); errClose != nil {
+			log.Errorf("moderation: close forward response body: %v", errClose)
+		}
+	}()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, nil, err
+	}
+	return resp.StatusCode, data, nil
+}
+
+// Classify flags input against the configured rules. Every configured category is present
+// in the result; matched categories score 1.
+func (c *Classifier) Classify(input string) Result {
+	result := Result{Categories: map[string]bool{}, CategoryScores: map[string]float64{}}
+	if c == nil {
+		return result
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	for _, category := range c.categories {
+		result.Categories[category] = false
+		result.CategoryScores[category] = 0
+	}
+	lower := strings.ToLower(input)
+	for _, rule := range c.rules {
+		if result.Categories[rule.category] || !rule.matches(input, lower) {
+			continue
+		}
+		result.Categories[rule.category] = true
+		result.CategoryScores[rule.category] = 1
+		result.Flagged = true
+	}
+	return result
+}
+
+func (r compiledRule) matches(input, lower string) bool {
+	for _, keyword := range r.keywords {
+		if strings.Contains(lower, keyword) {
+			return true
+		}
+	}
+	for _, re := range r.patterns {
+		if re.MatchString(input) {
+			return true
+		}
+	}
+	return false
+}
+
+// Inputs extracts the texts of a moderation request's "input": a string, an array of
+// strings, or an array of multimodal parts whose non-text parts are ignored. Parts form a
+// single input, like the upstream API. ok is false when input is missing or malformed.
+func Inputs(body []byte) ([]string, bool) {
+	input := gjson.GetBytes(body, "input")
+	switch {
+	case input.Type == gjson.String:
+		return []string{input.String()}, true
+	case !input.IsArray():
+		return nil, false
+	}
+	items := input.Array()
+	if len(items) > 0 && items[0].IsObject() {
+		var parts []string
+		for _, part := range items {
+			if part.Get("type").String() == "text" {
+				parts = append(parts, part.Get("text").String())
+			}
+		}
+		return []string{strings.Join(parts, "\n")}, true
+	}
+	texts := make([]string, 0, len(items))
+	for _, item := range items {
+		if item.Type != gjson.String {
+			return nil, false
+		}
+		texts = append(texts, item.String())
+	}
+	return texts, len(texts) > 0
+}
+
+// Moderate classifies every input of a moderation request body.
+func (c *Classifier) Moderate(body []byte) (*Response, bool) {
+	inputs, ok := Inputs(body)
+	if !ok {
+		return nil, false
+	}
+	model := strings.TrimSpace(gjson.GetBytes(body, "model").String())
+	if model == "" {
+		model = LocalModel
+	}
+	resp := &Response{ID: "modr-" + uuid.NewString(), Model: model, Results: make([]Result, len(inputs))}
+	for i, input := range inputs {
+		resp.Results[i] = c.Classify(input)
+	}
+	return resp, true
+}
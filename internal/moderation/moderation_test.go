@@ -0,0 +1,98 @@
+package moderation
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+func newTestClassifier() *Classifier {
+	c := NewClassifier()
+	c.Configure(config.ModerationConfig{
+		Mode: config.ModerationModeLocal,
+		Rules: []config.ModerationRule{
+			{Category: "violence", Keywords: []string{"Attack Plan"}},
+			{Category: "secrets", Patterns: []string{`sk-[A-Za-z0-9]{8,}`, `(`}},
+		},
+	}, "")
+	return c
+}
+
+func TestClassifyMatchesKeywordsAndPatterns(t *testing.T) {
+	c := newTestClassifier()
+
+	result := c.Classify("here is my ATTACK plan")
+	if !result.Flagged || !result.Categories["violence"] || result.CategoryScores["violence"] != 1 {
+		t.Fatalf("keyword result = %+v", result)
+	}
+	if flagged, ok := result.Categories["secrets"]; !ok || flagged {
+		t.Fatalf("secrets category = %v, %v; want present and false", flagged, ok)
+	}
+
+	if result = c.Classify("key sk-abcdefgh123"); !result.Flagged || !result.Categories["secrets"] {
+		t.Fatalf("pattern result = %+v", result)
+	}
+	if result = c.Classify("hello"); result.Flagged {
+		t.Fatalf("benign input flagged: %+v", result)
+	}
+}
+
+func TestModerateInputShapes(t *testing.T) {
+	c := newTestClassifier()
+	cases := []struct {
+		body    string
+		results int
+		flagged []bool
+	}{
+		{`{"input":"attack plan"}`, 1, []bool{true}},
+		{`{"input":["hello","attack plan"]}`, 2, []bool{false, true}},
+		{`{"input":[{"type":"image_url","image_url":{"url":"x"}},{"type":"text","text":"attack plan"}]}`, 1, []bool{true}},
+	}
+	for _, tc := range cases {
+		resp, ok := c.Moderate([]byte(tc.body))
+		if !ok || len(resp.Results) != tc.results {
+			t.Fatalf("Moderate(%s) = %+v, %v", tc.body, resp, ok)
+		}
+		for i, want := range tc.flagged {
+			if resp.Results[i].Flagged != want {
+				t.Errorf("Moderate(%s) result %d flagged = %v, want %v", tc.body, i, resp.Results[i].Flagged, want)
+			}
+		}
+		if resp.Model != LocalModel {
+			t.Errorf("model = %q, want %q", resp.Model, LocalModel)
+		}
+	}
+	for _, body := range []string{`{}`, `{"input":42}`, `{"input":[1,2]}`} {
+		if _, ok := c.Moderate([]byte(body)); ok {
+			t.Errorf("Moderate(%s) accepted malformed input", body)
+		}
+	}
+}
+
+func TestForwardRelaysRequest(t *testing.T) {
+	var gotAuth, gotBody string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotAuth = r.Header.Get("Authorization")
+		data, _ := io.ReadAll(r.Body)
+		gotBody = string(data)
+		_, _ = w.Write([]byte(`{"id":"modr-upstream","results":[]}`))
+	}))
+	defer upstream.Close()
+
+	c := NewClassifier()
+	c.Configure(config.ModerationConfig{Mode: config.ModerationModeForward, ForwardURL: upstream.URL, APIKey: "sk-up"}, "")
+	if !c.Forwarding() {
+		t.Fatal("Forwarding() = false")
+	}
+	status, body, err := c.Forward(context.Background(), []byte(`{"input":"hi"}`))
+	if err != nil || status != http.StatusOK || string(body) != `{"id":"modr-upstream","results":[]}` {
+		t.Fatalf("Forward = %d %s %v", status, body, err)
+	}
+	if gotAuth != "Bearer sk-up" || gotBody != `{"input":"hi"}` {
+		t.Fatalf("upstream saw auth %q body %q", gotAuth, gotBody)
+	}
+}
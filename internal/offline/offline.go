@@ -0,0 +1,189 @@
+// Package offline implements offline mode: only local backends (mock providers and
+// credentials pointing at loopback or configured local hosts) are used, and requests that
+// would need any other upstream fail fast instead of waiting for a network that is not there.
+package offline
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+)
+
+// ErrorCode is the coreauth.Error code returned for credentials unavailable offline.
+const ErrorCode = "offline"
+
+var defaultGuard = NewGuard()
+
+// Default returns the process-wide guard.
+func Default() *Guard { return defaultGuard }
+
+// Guard decides which upstreams may be used and implements coreauth.AuthFilter and
+// coreauth.RefreshFilter.
+type Guard struct {
+	mu     sync.RWMutex
+	cfg    config.OfflineConfig
+	forced atomic.Bool
+}
+
+// NewGuard constructs a guard with offline mode off.
+func NewGuard() *Guard { return &Guard{} }
+
+// Configure replaces the offline settings.
+func (g *Guard) Configure(cfg config.OfflineConfig) {
+	if g == nil {
+		return
+	}
+	g.mu.Lock()
+	g.cfg = cfg
+	g.mu.Unlock()
+}
+
+// Force turns offline mode on for the lifetime of the process, regardless of the config.
+func (g *Guard) Force() {
+	if g != nil {
+		g.forced.Store(true)
+	}
+}
+
+// Enabled reports whether offline mode is on.
+func (g *Guard) Enabled() bool {
+	if g == nil {
+		return false
+	}
+	if g.forced.Load() {
+		return true
+	}
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return g.cfg.Enable
+}
+
+// LocalHost reports whether host (optionally with a port) is a loopback address, localhost or
+// one of the configured local hosts.
+func (g *Guard) LocalHost(host string) bool {
+	host = strings.ToLower(strings.TrimSpace(host))
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.TrimSuffix(strings.Trim(host, "[]"), ".")
+	if host == "" {
+		return false
+	}
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return true
+	}
+	if ip := net.ParseIP(host); ip != nil && ip.IsLoopback() {
+		return true
+	}
+	if g == nil {
+		return false
+	}
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	for _, pattern := range g.cfg.LocalHosts {
+		if suffix, ok := strings.CutPrefix(pattern, "*"); ok {
+			if strings.HasSuffix(host, suffix) && len(host) > len(suffix) {
+				return true
+			}
+			continue
+		}
+		if host == pattern {
+			return true
+		}
+	}
+	return false
+}
+
+// localURL reports whether raw is a URL of a local host.
+func (g *Guard) localURL(raw string) bool {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil || u.Host == "" {
+		return false
+	}
+	return g.LocalHost(u.Host)
+}
+
+// LocalAuth reports whether auth is served without leaving the machine or the configured
+// local network: mock providers, and credentials whose base URL and proxy are local.
+// Credentials without a base URL talk to the provider's public endpoint.
+func (g *Guard) LocalAuth(auth *coreauth.Auth) bool {
+	if auth == nil {
+		return false
+	}
+	if strings.EqualFold(auth.Provider, "mock") {
+		return true
+	}
+	if auth.Attributes == nil || !g.localURL(auth.Attributes["base_url"]) {
+		return false
+	}
+	if proxy := strings.TrimSpace(auth.ProxyURL); proxy != "" && !g.localURL(proxy) {
+		return false
+	}
+	return true
+}
+
+// CheckHost returns an error when offline mode is on and host (optionally with a port) is
+// not local.
+func (g *Guard) CheckHost(host string) error {
+	if !g.Enabled() || g.LocalHost(host) {
+		return nil
+	}
+	return fmt.Errorf("offline mode: %s is not a local host", host)
+}
+
+// Client makes c fail requests to remote hosts while offline and returns it. Clients of
+// webhooks, mirrors, embeddings and other outbound integrations go through it.
+func (g *Guard) Client(c *http.Client) *http.Client {
+	if c == nil {
+		c = &http.Client{}
+	}
+	c.Transport = guardTransport{guard: g, next: c.Transport}
+	return c
+}
+
+// guardTransport checks the target host before handing requests to next, or to
+// http.DefaultTransport when next is nil.
+type guardTransport struct {
+	guard *Guard
+	next  http.RoundTripper
+}
+
+func (t guardTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := t.guard.CheckHost(req.URL.Host); err != nil {
+		if req.Body != nil {
+			_ = req.Body.Close()
+		}
+		return nil, err
+	}
+	if t.next == nil {
+		return http.DefaultTransport.RoundTrip(req)
+	}
+	return t.next.RoundTrip(req)
+}
+
+// FilterAuth implements coreauth.AuthFilter, excluding credentials of remote upstreams while
+// offline.
+func (g *Guard) FilterAuth(_ context.Context, auth *coreauth.Auth, _ string) error {
+	if !g.Enabled() || g.LocalAuth(auth) {
+		return nil
+	}
+	return &coreauth.Error{
+		Code:       ErrorCode,
+		Message:    fmt.Sprintf("offline mode: %s credentials need a remote upstream", auth.Provider),
+		HTTPStatus: http.StatusServiceUnavailable,
+	}
+}
+
+// FilterRefresh implements coreauth.RefreshFilter, suspending token refreshes of remote
+// credentials while offline.
+func (g *Guard) FilterRefresh(auth *coreauth.Auth) error {
+	return g.FilterAuth(context.Background(), auth, "")
+}
@@ -0,0 +1,78 @@
+package offline
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+)
+
+func TestLocalAuth(t *testing.T) {
+	cfg := &config.Config{Offline: config.OfflineConfig{Enable: true, LocalHosts: []string{"GPU-BOX.lan:11434", "*.internal"}}}
+	cfg.SanitizeOffline()
+	g := NewGuard()
+	g.Configure(cfg.Offline)
+
+	cases := []struct {
+		name string
+		auth *coreauth.Auth
+		want bool
+	}{
+		{"mock", &coreauth.Auth{Provider: "mock"}, true},
+		{"ollama", &coreauth.Auth{Provider: "ollama", Attributes: map[string]string{"base_url": "http://localhost:11434/v1"}}, true},
+		{"loopback ipv6", &coreauth.Auth{Provider: "vllm", Attributes: map[string]string{"base_url": "http://[::1]:8000/v1"}}, true},
+		{"local host", &coreauth.Auth{Provider: "ollama", Attributes: map[string]string{"base_url": "http://gpu-box.lan:11434/v1"}}, true},
+		{"wildcard", &coreauth.Auth{Provider: "vllm", Attributes: map[string]string{"base_url": "https://llm.corp.internal"}}, true},
+		{"remote proxy", &coreauth.Auth{Provider: "ollama", ProxyURL: "socks5://proxy.example.com:1080", Attributes: map[string]string{"base_url": "http://127.0.0.1:11434"}}, false},
+		{"public api", &coreauth.Auth{Provider: "claude", Attributes: map[string]string{"base_url": "https://api.anthropic.com"}}, false},
+		{"oauth", &coreauth.Auth{Provider: "codex"}, false},
+	}
+	for _, tc := range cases {
+		if got := g.LocalAuth(tc.auth); got != tc.want {
+			t.Errorf("%s: LocalAuth = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestFilterAuthFailsRemoteCredentialsOffline(t *testing.T) {
+	g := NewGuard()
+	remote := &coreauth.Auth{Provider: "claude"}
+	if err := g.FilterAuth(context.Background(), remote, "claude-sonnet"); err != nil {
+		t.Fatalf("online filter error = %v", err)
+	}
+	g.Force()
+	g.Configure(config.OfflineConfig{})
+	if !g.Enabled() {
+		t.Fatal("Force should survive a reload without offline.enable")
+	}
+	var authErr *coreauth.Error
+	if err := g.FilterAuth(context.Background(), remote, "claude-sonnet"); !errors.As(err, &authErr) || authErr.Code != ErrorCode {
+		t.Fatalf("offline filter error = %v, want %s", err, ErrorCode)
+	}
+	if err := g.FilterRefresh(remote); err == nil {
+		t.Fatal("refreshes of remote credentials should be suspended offline")
+	}
+	if err := g.FilterAuth(context.Background(), &coreauth.Auth{Provider: "mock"}, "mock-model"); err != nil {
+		t.Fatalf("mock filter error = %v", err)
+	}
+}
+
+func TestClientRefusesRemoteHostsOffline(t *testing.T) {
+	local := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {}))
+	defer local.Close()
+	g := NewGuard()
+	g.Configure(config.OfflineConfig{Enable: true})
+	client := g.Client(&http.Client{})
+	if _, err := client.Get("http://api.example.com/hook"); err == nil {
+		t.Fatal("request to a remote host should fail offline")
+	}
+	resp, err := client.Get(local.URL)
+	if err != nil {
+		t.Fatalf("request to a loopback host: %v", err)
+	}
+	_ = resp.Body.Close()
+}
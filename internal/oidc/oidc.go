@@ -0,0 +1,404 @@
+// Package oidc implements the OpenID Connect authorization code flow used to log in to
+// the management API: provider discovery, the code exchange and ID token verification.
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+const (
+	// jwksRefreshInterval bounds how often an unknown key id triggers a JWKS reload.
+	jwksRefreshInterval = time.Minute
+	// clockLeeway tolerates clock skew against the identity provider.
+	clockLeeway = time.Minute
+	// maxDocumentBytes bounds discovery documents and key sets.
+	maxDocumentBytes = 1 << 20
+	// minRSABits is the smallest RSA signing key accepted.
+	minRSABits = 2048
+)
+
+// signingCurves maps the accepted JWS algorithms to the key they require: an RSA key
+// (nil) or an EC key on the named curve.
+var signingCurves = map[string]elliptic.Curve{
+	"RS256": nil,
+	"RS384": nil,
+	"RS512": nil,
+	"ES256": elliptic.P256(),
+	"ES384": elliptic.P384(),
+	"ES512": elliptic.P521(),
+}
+
+// Claims are the verified ID token claims.
+type Claims struct {
+	Subject string
+	Email   string
+	Name    string
+	Groups  []string
+	Raw     map[string]any
+}
+
+// Provider is an OIDC identity provider discovered from its issuer URL.
+type Provider struct {
+	issuer     string
+	clientID   string
+	httpClient *http.Client
+
+	mu        sync.Mutex
+	discovery *discoveryDocument
+	keys      map[string]crypto.PublicKey
+	keysAt    time.Time
+	now       func() time.Time
+}
+
+type discoveryDocument struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// NewProvider returns the provider of issuer for clientID. Discovery happens lazily on
+// first use. A nil httpClient uses http.DefaultClient.
+func NewProvider(issuer, clientID string, httpClient *http.Client) *Provider {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	return &Provider{
+		issuer:     strings.TrimRight(issuer, "/"),
+		clientID:   clientID,
+		httpClient: httpClient,
+		now:        time.Now,
+	}
+}
+
+// OAuth2Config returns the authorization code flow configuration of the provider.
+func (p *Provider) OAuth2Config(ctx context.Context, clientSecret, redirectURL string, scopes []string) (*oauth2.Config, error) {
+	doc, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &oauth2.Config{
+		ClientID:     p.clientID,
+		ClientSecret: clientSecret,
+		RedirectURL:  redirectURL,
+		Scopes:       append([]string{"openid"}, scopes...),
+		Endpoint: oauth2.Endpoint{
+			AuthURL:  doc.AuthorizationEndpoint,
+			TokenURL: doc.TokenEndpoint,
+		},
+	}, nil
+}
+
+// HTTPContext returns ctx carrying the provider's HTTP client for oauth2 calls.
+func (p *Provider) HTTPContext(ctx context.Context) context.Context {
+	return context.WithValue(ctx, oauth2.HTTPClient, p.httpClient)
+}
+
+// Verify checks the signature, issuer, audience, authorized party, validity period and
+// nonce of a raw ID token and returns its claims. groupsClaim names the claim listing the
+// user's groups.
+func (p *Provider) Verify(ctx context.Context, rawIDToken, nonce, groupsClaim string) (*Claims, error) {
+	parts := strings.Split(rawIDToken, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("oidc: malformed id token")
+	}
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("oidc: invalid id token header: %w", err)
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("oidc: invalid id token signature: %w", err)
+	}
+	key, err := p.key(ctx, header.Kid)
+	if err != nil {
+		return nil, err
+	}
+	if err = verifySignature(header.Alg, key, []byte(parts[0]+"."+parts[1]), signature); err != nil {
+		return nil, err
+	}
+
+	var raw map[string]any
+	if err = decodeSegment(parts[1], &raw); err != nil {
+		return nil, fmt.Errorf("oidc: invalid id token claims: %w", err)
+	}
+	doc, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if iss, _ := raw["iss"].(string); iss != doc.Issuer {
+		return nil, fmt.Errorf("oidc: id token issued by %q, want %q", iss, doc.Issuer)
+	}
+	if !audienceContains(raw["aud"], p.clientID) {
+		return nil, errors.New("oidc: id token not issued for this client")
+	}
+	if azp, ok := raw["azp"].(string); ok && azp != p.clientID {
+		return nil, errors.New("oidc: id token authorized for another client")
+	}
+	if audiences, ok := raw["aud"].([]any); ok && len(audiences) > 1 && raw["azp"] == nil {
+		return nil, errors.New("oidc: id token for several audiences names no authorized party")
+	}
+	now := p.now()
+	exp, ok := raw["exp"].(float64)
+	if !ok || now.After(time.Unix(int64(exp), 0).Add(clockLeeway)) {
+		return nil, errors.New("oidc: id token expired")
+	}
+	if nbf, ok := raw["nbf"].(float64); ok && now.Add(clockLeeway).Before(time.Unix(int64(nbf), 0)) {
+		return nil, errors.New("oidc: id token not valid yet")
+	}
+	if got, _ := raw["nonce"].(string); nonce != "" && got != nonce {
+		return nil, errors.New("oidc: id token nonce mismatch")
+	}
+
+	claims := &Claims{Raw: raw}
+	claims.Subject, _ = raw["sub"].(string)
+	claims.Email, _ = raw["email"].(string)
+	claims.Name, _ = raw["name"].(string)
+	switch groups := raw[groupsClaim].(type) {
+	case []any:
+		for _, group := range groups {
+			if name, ok := group.(string); ok {
+				claims.Groups = append(claims.Groups, name)
+			}
+		}
+	case string:
+		claims.Groups = []string{groups}
+	}
+	return claims, nil
+}
+
+func (p *Provider) discover(ctx context.Context) (*discoveryDocument, error) {
+	p.mu.Lock()
+	doc := p.discovery
+	p.mu.Unlock()
+	if doc != nil {
+		return doc, nil
+	}
+	doc = &discoveryDocument{}
+	if err := p.getJSON(ctx, p.issuer+"/.well-known/openid-configuration", doc); err != nil {
+		return nil, fmt.Errorf("oidc: discovery failed: %w", err)
+	}
+	if strings.TrimRight(doc.Issuer, "/") != p.issuer {
+		return nil, fmt.Errorf("oidc: discovery issuer %q does not match %q", doc.Issuer, p.issuer)
+	}
+	if doc.AuthorizationEndpoint == "" || doc.TokenEndpoint == "" || doc.JWKSURI == "" {
+		return nil, errors.New("oidc: discovery document is missing endpoints")
+	}
+	p.mu.Lock()
+	p.discovery = doc
+	p.mu.Unlock()
+	return doc, nil
+}
+
+// key returns the signing key kid, reloading the JWKS when the key is unknown.
+func (p *Provider) key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	p.mu.Lock()
+	key, ok := p.lookupLocked(kid)
+	stale := p.now().Sub(p.keysAt) >= jwksRefreshInterval
+	p.mu.Unlock()
+	if ok {
+		return key, nil
+	}
+	if !stale {
+		return nil, fmt.Errorf("oidc: unknown signing key %q", kid)
+	}
+	doc, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err = p.getJSON(ctx, doc.JWKSURI, &set); err != nil {
+		return nil, fmt.Errorf("oidc: failed to load signing keys: %w", err)
+	}
+	keys := make(map[string]crypto.PublicKey, len(set.Keys))
+	for _, jwk := range set.Keys {
+		if public, errKey := jwk.publicKey(); errKey == nil {
+			keys[jwk.Kid] = public
+		}
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.keys, p.keysAt = keys, p.now()
+	if key, ok = p.lookupLocked(kid); !ok {
+		return nil, fmt.Errorf("oidc: unknown signing key %q", kid)
+	}
+	return key, nil
+}
+
+// lookupLocked finds kid, or the only key when the token names none.
+func (p *Provider) lookupLocked(kid string) (crypto.PublicKey, bool) {
+	if kid == "" && len(p.keys) == 1 {
+		for _, key := range p.keys {
+			return key, true
+		}
+	}
+	key, ok := p.keys[kid]
+	return key, ok
+}
+
+func (p *Provider) getJSON(ctx context.Context, url string, out any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: status %d", url, resp.StatusCode)
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, maxDocumentBytes)).Decode(out)
+}
+
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func (k jsonWebKey) publicKey() (crypto.PublicKey, error) {
+	if k.Use != "" && k.Use != "sig" {
+		return nil, errors.New("not a signing key")
+	}
+	switch k.Kty {
+	case "RSA":
+		n, errN := base64.RawURLEncoding.DecodeString(k.N)
+		e, errE := base64.RawURLEncoding.DecodeString(k.E)
+		if errN != nil || errE != nil || len(e) == 0 || len(e) > 4 {
+			return nil, errors.New("invalid RSA key")
+		}
+		exponent := 0
+		for _, b := range e {
+			exponent = exponent<<8 | int(b)
+		}
+		key := &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: exponent}
+		if key.N.BitLen() < minRSABits || exponent < 3 || exponent%2 == 0 {
+			return nil, errors.New("weak RSA key")
+		}
+		return key, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, errX := base64.RawURLEncoding.DecodeString(k.X)
+		y, errY := base64.RawURLEncoding.DecodeString(k.Y)
+		if errX != nil || errY != nil {
+			return nil, errors.New("invalid EC key")
+		}
+		key := &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if _, err := key.ECDH(); err != nil {
+			return nil, errors.New("EC key is not on its curve")
+		}
+		return key, nil
+	}
+	return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+}
+
+// verifySignature checks a JWS signature made with one of the RS* or ES* algorithms. The
+// algorithm must match the key: RS* for RSA keys, ES* for EC keys on its own curve.
+func verifySignature(alg string, key crypto.PublicKey, signed, signature []byte) error {
+	curve, ok := signingCurves[alg]
+	if !ok {
+		return fmt.Errorf("oidc: unsupported signing algorithm %q", alg)
+	}
+	var h hash.Hash
+	var id crypto.Hash
+	switch alg[2:] {
+	case "256":
+		h, id = sha256.New(), crypto.SHA256
+	case "384":
+		h, id = sha512.New384(), crypto.SHA384
+	default:
+		h, id = sha512.New(), crypto.SHA512
+	}
+	h.Write(signed)
+	digest := h.Sum(nil)
+
+	switch pub := key.(type) {
+	case *rsa.PublicKey:
+		if curve != nil {
+			break
+		}
+		if err := rsa.VerifyPKCS1v15(pub, id, digest, signature); err != nil {
+			return errors.New("oidc: invalid id token signature")
+		}
+		return nil
+	case *ecdsa.PublicKey:
+		if curve == nil || pub.Curve != curve {
+			break
+		}
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		if len(signature) != 2*size {
+			return errors.New("oidc: invalid id token signature")
+		}
+		r := new(big.Int).SetBytes(signature[:size])
+		s := new(big.Int).SetBytes(signature[size:])
+		if !ecdsa.Verify(pub, digest, r, s) {
+			return errors.New("oidc: invalid id token signature")
+		}
+		return nil
+	}
+	return fmt.Errorf("oidc: signing algorithm %q does not match the key", alg)
+}
+
+func decodeSegment(segment string, out any) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, out)
+}
+
+func audienceContains(aud any, clientID string) bool {
+	switch v := aud.(type) {
+	case string:
+		return v == clientID
+	case []any:
+		for _, item := range v {
+			if s, ok := item.(string); ok && s == clientID {
+				return true
+			}
+		}
+	}
+	return false
+}
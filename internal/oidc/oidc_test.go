@@ -0,0 +1,111 @@
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func signToken(t *testing.T, key *rsa.PrivateKey, kid string, claims map[string]any) string {
+	t.Helper()
+	encode := func(v any) string {
+		data, _ := json.Marshal(v)
+		return base64.RawURLEncoding.EncodeToString(data)
+	}
+	signed := encode(map[string]string{"alg": "RS256", "kid": kid}) + "." + encode(claims)
+	digest := sha256.Sum256([]byte(signed))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	if err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func unsignedToken(claims map[string]any) string {
+	header, _ := json.Marshal(map[string]string{"alg": "none", "kid": "k1"})
+	payload, _ := json.Marshal(claims)
+	return base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload) + "."
+}
+
+func newIssuer(t *testing.T, key *rsa.PrivateKey) *httptest.Server {
+	t.Helper()
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/.well-known/openid-configuration":
+			_ = json.NewEncoder(w).Encode(map[string]string{
+				"issuer":                 srv.URL,
+				"authorization_endpoint": srv.URL + "/authorize",
+				"token_endpoint":         srv.URL + "/token",
+				"jwks_uri":               srv.URL + "/jwks",
+			})
+		case "/jwks":
+			_ = json.NewEncoder(w).Encode(map[string]any{"keys": []map[string]string{{
+				"kty": "RSA", "kid": "k1", "use": "sig",
+				"n": base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e": base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			}}})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	return srv
+}
+
+func TestProviderVerify(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	srv := newIssuer(t, key)
+	defer srv.Close()
+	provider := NewProvider(srv.URL, "proxy", srv.Client())
+
+	claims := func(overrides map[string]any) map[string]any {
+		base := map[string]any{
+			"iss": srv.URL, "aud": "proxy", "sub": "u1", "email": "ops@example.com",
+			"exp": time.Now().Add(time.Hour).Unix(), "nonce": "n1", "groups": []string{"admins"},
+		}
+		for k, v := range overrides {
+			base[k] = v
+		}
+		return base
+	}
+
+	got, err := provider.Verify(context.Background(), signToken(t, key, "k1", claims(nil)), "n1", "groups")
+	if err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+	if got.Email != "ops@example.com" || len(got.Groups) != 1 || got.Groups[0] != "admins" {
+		t.Fatalf("claims = %+v", got)
+	}
+
+	for name, tc := range map[string]struct {
+		token string
+		nonce string
+	}{
+		"audience":  {signToken(t, key, "k1", claims(map[string]any{"aud": "other"})), "n1"},
+		"expired":   {signToken(t, key, "k1", claims(map[string]any{"exp": time.Now().Add(-time.Minute).Unix()})), "n1"},
+		"nonce":     {signToken(t, key, "k1", claims(nil)), "n2"},
+		"tampered":  {strings.Replace(signToken(t, key, "k1", claims(nil)), ".", ".e30", 1), "n1"},
+		"no expiry": {signToken(t, key, "k1", claims(map[string]any{"exp": nil})), "n1"},
+		"not yet":   {signToken(t, key, "k1", claims(map[string]any{"nbf": time.Now().Add(time.Hour).Unix()})), "n1"},
+		"azp":       {signToken(t, key, "k1", claims(map[string]any{"aud": []string{"proxy", "other"}, "azp": "other"})), "n1"},
+		"alg none":  {unsignedToken(claims(nil)), "n1"},
+	} {
+		if _, err := provider.Verify(context.Background(), tc.token, tc.nonce, "groups"); err == nil {
+			t.Errorf("%s: verify succeeded, want an error", name)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package osservice
+
+import (
+	"bytes"
+	"encoding/xml"
+)
+
+// launchdLabelPrefix namespaces the launchd job label.
+const launchdLabelPrefix = "com.router-for-me."
+
+// LaunchdLabel returns the launchd job label of the named service.
+func LaunchdLabel(name string) string {
+	return launchdLabelPrefix + name
+}
+
+// renderLaunchdPlist renders the LaunchAgent property list of cfg. The job runs at login and
+// is restarted when it exits with an error; logs go to logPath.
+func renderLaunchdPlist(cfg Config, logPath string) []byte {
+	var b bytes.Buffer
+	b.WriteString(xml.Header)
+	b.WriteString(`<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">` + "\n")
+	b.WriteString(`<plist version="1.0">` + "\n<dict>\n")
+	plistKey(&b, "Label")
+	plistString(&b, LaunchdLabel(cfg.Name))
+	plistKey(&b, "ProgramArguments")
+	b.WriteString("\t<array>\n")
+	for _, arg := range append([]string{cfg.Executable}, cfg.Args...) {
+		b.WriteString("\t")
+		plistString(&b, arg)
+	}
+	b.WriteString("\t</array>\n")
+	if cfg.WorkingDirectory != "" {
+		plistKey(&b, "WorkingDirectory")
+		plistString(&b, cfg.WorkingDirectory)
+	}
+	plistKey(&b, "RunAtLoad")
+	b.WriteString("\t<true/>\n")
+	// Restart after crashes but not after a clean shutdown.
+	plistKey(&b, "KeepAlive")
+	b.WriteString("\t<dict>\n\t\t<key>SuccessfulExit</key>\n\t\t<false/>\n\t</dict>\n")
+	if logPath != "" {
+		plistKey(&b, "StandardOutPath")
+		plistString(&b, logPath)
+		plistKey(&b, "StandardErrorPath")
+		plistString(&b, logPath)
+	}
+	b.WriteString("</dict>\n</plist>\n")
+	return b.Bytes()
+}
+
+func plistKey(b *bytes.Buffer, key string) {
+	b.WriteString("\t<key>")
+	_ = xml.EscapeText(b, []byte(key))
+	b.WriteString("</key>\n")
+}
+
+func plistString(b *bytes.Buffer, value string) {
+	b.WriteString("\t<string>")
+	_ = xml.EscapeText(b, []byte(value))
+	b.WriteString("</string>\n")
+}
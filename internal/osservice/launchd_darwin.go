@@ -0,0 +1,122 @@
+package osservice
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// launchdManager installs the proxy as a per-user LaunchAgent, so it runs without root and
+// can use the user's keychain, browser and home directory.
+type launchdManager struct {
+	home string
+}
+
+// New returns the launchd service manager.
+func New() (Manager, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil, fmt.Errorf("launchd: %w", err)
+	}
+	return &launchdManager{home: home}, nil
+}
+
+func (m *launchdManager) plistPath(name string) string {
+	return filepath.Join(m.home, "Library", "LaunchAgents", LaunchdLabel(name)+".plist")
+}
+
+func (m *launchdManager) domain() string {
+	return "gui/" + strconv.Itoa(os.Getuid())
+}
+
+func (m *launchdManager) target(name string) string {
+	return m.domain() + "/" + LaunchdLabel(name)
+}
+
+func (m *launchdManager) Install(cfg Config) error {
+	if err := cfg.validate(); err != nil {
+		return err
+	}
+	path := m.plistPath(cfg.Name)
+	if _, err := os.Stat(path); err == nil {
+		return fmt.Errorf("launchd: %s already exists; uninstall the service first", path)
+	}
+	logDir := filepath.Join(m.home, "Library", "Logs")
+	if err := os.MkdirAll(logDir, 0o755); err != nil {
+		return fmt.Errorf("launchd: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("launchd: %w", err)
+	}
+	plist := renderLaunchdPlist(cfg, filepath.Join(logDir, cfg.Name+".log"))
+	if err := os.WriteFile(path, plist, 0o644); err != nil {
+		return fmt.Errorf("launchd: %w", err)
+	}
+	return nil
+}
+
+func (m *launchdManager) Uninstall(name string) error {
+	path := m.plistPath(name)
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return ErrNotInstalled
+	}
+	if m.loaded(name) {
+		if err := m.Stop(name); err != nil {
+			return err
+		}
+	}
+	if err := os.Remove(path); err != nil {
+		return fmt.Errorf("launchd: %w", err)
+	}
+	return nil
+}
+
+func (m *launchdManager) Start(name string) error {
+	path := m.plistPath(name)
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return ErrNotInstalled
+	}
+	if !m.loaded(name) {
+		// Loading the job starts it because of RunAtLoad.
+		return launchctl("bootstrap", m.domain(), path)
+	}
+	return launchctl("kickstart", m.target(name))
+}
+
+func (m *launchdManager) Stop(name string) error {
+	if !m.loaded(name) {
+		return nil
+	}
+	return launchctl("bootout", m.target(name))
+}
+
+func (m *launchdManager) Status(name string) (string, error) {
+	if _, err := os.Stat(m.plistPath(name)); os.IsNotExist(err) {
+		return "", ErrNotInstalled
+	}
+	out, err := exec.Command("launchctl", "print", m.target(name)).CombinedOutput()
+	if err != nil {
+		return "stopped", nil
+	}
+	for _, line := range strings.Split(string(out), "\n") {
+		if state, ok := strings.CutPrefix(strings.TrimSpace(line), "state = "); ok {
+			return state, nil
+		}
+	}
+	return "loaded", nil
+}
+
+func (m *launchdManager) loaded(name string) bool {
+	return exec.Command("launchctl", "print", m.target(name)).Run() == nil
+}
+
+func launchctl(args ...string) error {
+	out, err := exec.Command("launchctl", args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("launchctl %s: %v: %s", strings.Join(args, " "), err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package osservice
+
+import (
+	"encoding/xml"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestRenderLaunchdPlist(t *testing.T) {
+	cfg := Config{
+		Name:             "cliproxyapi",
+		Executable:       "/Applications/CLI Proxy/cliproxyapi",
+		Args:             []string{"-config", "/Users/me/a&b/config.yaml"},
+		WorkingDirectory: "/Users/me/a&b",
+	}
+	out := string(renderLaunchdPlist(cfg, "/Users/me/Library/Logs/cliproxyapi.log"))
+
+	for _, want := range []string{
+		"<string>com.router-for-me.cliproxyapi</string>",
+		"<string>/Applications/CLI Proxy/cliproxyapi</string>",
+		"<string>-config</string>",
+		"<string>/Users/me/a&amp;b/config.yaml</string>",
+		"<key>WorkingDirectory</key>",
+		"<key>RunAtLoad</key>",
+		"<key>SuccessfulExit</key>",
+		"<string>/Users/me/Library/Logs/cliproxyapi.log</string>",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("plist missing %q:\n%s", want, out)
+		}
+	}
+	if !strings.Contains(out, "cliproxyapi</string>\n\t\t<string>-config") {
+		t.Errorf("executable must be the first program argument:\n%s", out)
+	}
+
+	decoder := xml.NewDecoder(strings.NewReader(out))
+	decoder.Strict = true
+	for {
+		if _, err := decoder.Token(); err != nil {
+			if !errors.Is(err, io.EOF) {
+				t.Fatalf("plist is not well-formed XML: %v", err)
+			}
+			break
+		}
+	}
+}
+
+func TestRenderLaunchdPlistOmitsEmptyFields(t *testing.T) {
+	out := string(renderLaunchdPlist(Config{Name: "p", Executable: "/bin/p"}, ""))
+	for _, unwanted := range []string{"WorkingDirectory", "StandardOutPath", "StandardErrorPath"} {
+		if strings.Contains(out, unwanted) {
+			t.Errorf("plist contains %s:\n%s", unwanted, out)
+		}
+	}
+}
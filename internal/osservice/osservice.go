@@ -0,0 +1,58 @@
+// Package osservice registers the proxy with the operating system's service manager so it
+// runs in the background: the Windows Service Control Manager and macOS launchd. On Linux
+// the systemd units under examples/systemd are used instead.
+package osservice
+
+import (
+	"errors"
+	"fmt"
+)
+
+// DefaultName is the service name used when none is given.
+const DefaultName = "cliproxyapi"
+
+// ErrUnsupported is returned by New on platforms without a supported service manager.
+var ErrUnsupported = errors.New("service management is only supported on Windows and macOS; on Linux use the systemd units in examples/systemd")
+
+// ErrNotInstalled is returned when the named service is not registered.
+var ErrNotInstalled = errors.New("service is not installed")
+
+// Config describes the service to register.
+type Config struct {
+	// Name identifies the service (Windows service name, suffix of the launchd label).
+	Name string
+	// DisplayName is the human-readable name shown by the service manager.
+	DisplayName string
+	// Description is shown next to the service where the platform supports it.
+	Description string
+	// Executable is the absolute path of the proxy binary.
+	Executable string
+	// Args are passed to Executable, typically "-config <absolute path>".
+	Args []string
+	// WorkingDirectory is the directory the service runs in.
+	WorkingDirectory string
+}
+
+// Manager installs and controls a service.
+type Manager interface {
+	// Install registers the service. It does not start it.
+	Install(cfg Config) error
+	// Uninstall stops the service if it runs and removes its registration.
+	Uninstall(name string) error
+	// Start starts the installed service.
+	Start(name string) error
+	// Stop stops the running service.
+	Stop(name string) error
+	// Status describes the service state, e.g. "running" or "stopped".
+	Status(name string) (string, error)
+}
+
+func (cfg Config) validate() error {
+	if cfg.Name == "" {
+		return fmt.Errorf("service name is required")
+	}
+	if cfg.Executable == "" {
+		return fmt.Errorf("service executable is required")
+	}
+	return nil
+}
@@ -0,0 +1,11 @@
+//go:build !windows
+
+package osservice
+
+import "context"
+
+// Context returns parent unchanged; only Windows services are stopped through the service
+// manager instead of a signal.
+func Context(parent context.Context) (context.Context, func()) {
+	return parent, func() {}
+}
@@ -0,0 +1,203 @@
+package osservice
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/sys/windows"
+	"golang.org/x/sys/windows/svc"
+	"golang.org/x/sys/windows/svc/mgr"
+)
+
+// stopTimeout bounds how long Stop waits for the service to exit.
+const stopTimeout = 30 * time.Second
+
+// scmManager registers the proxy with the Windows Service Control Manager. It needs an
+// elevated shell.
+type scmManager struct{}
+
+// New returns the Windows service manager.
+func New() (Manager, error) {
+	return scmManager{}, nil
+}
+
+func (scmManager) Install(cfg Config) error {
+	if err := cfg.validate(); err != nil {
+		return err
+	}
+	m, err := mgr.Connect()
+	if err != nil {
+		return fmt.Errorf("connect to service manager: %w", err)
+	}
+	defer func() { _ = m.Disconnect() }()
+	if s, errOpen := m.OpenService(cfg.Name); errOpen == nil {
+		_ = s.Close()
+		return fmt.Errorf("service %s already exists; uninstall it first", cfg.Name)
+	}
+	s, err := m.CreateService(cfg.Name, cfg.Executable, mgr.Config{
+		DisplayName: cfg.DisplayName,
+		Description: cfg.Description,
+		StartType:   mgr.StartAutomatic,
+	}, cfg.Args...)
+	if err != nil {
+		return fmt.Errorf("create service: %w", err)
+	}
+	defer func() { _ = s.Close() }()
+	// Restart after crashes.
+	_ = s.SetRecoveryActions([]mgr.RecoveryAction{
+		{Type: mgr.ServiceRestart, Delay: 5 * time.Second},
+		{Type: mgr.ServiceRestart, Delay: 30 * time.Second},
+	}, uint32((24 * time.Hour).Seconds()))
+	return nil
+}
+
+func (scmManager) Uninstall(name string) error {
+	return withService(name, func(s *mgr.Service) error {
+		if err := stopService(s); err != nil {
+			return err
+		}
+		return s.Delete()
+	})
+}
+
+func (scmManager) Start(name string) error {
+	return withService(name, func(s *mgr.Service) error {
+		return s.Start()
+	})
+}
+
+func (scmManager) Stop(name string) error {
+	return withService(name, stopService)
+}
+
+func (scmManager) Status(name string) (string, error) {
+	var state string
+	err := withService(name, func(s *mgr.Service) error {
+		status, err := s.Query()
+		if err != nil {
+			return err
+		}
+		state = stateName(status.State)
+		return nil
+	})
+	return state, err
+}
+
+func withService(name string, fn func(s *mgr.Service) error) error {
+	m, err := mgr.Connect()
+	if err != nil {
+		return fmt.Errorf("connect to service manager: %w", err)
+	}
+	defer func() { _ = m.Disconnect() }()
+	s, err := m.OpenService(name)
+	if err != nil {
+		if errors.Is(err, windows.ERROR_SERVICE_DOES_NOT_EXIST) {
+			return ErrNotInstalled
+		}
+		return fmt.Errorf("open service %s: %w", name, err)
+	}
+	defer func() { _ = s.Close() }()
+	return fn(s)
+}
+
+func stopService(s *mgr.Service) error {
+	status, err := s.Query()
+	if err != nil {
+		return err
+	}
+	if status.State == svc.Stopped {
+		return nil
+	}
+	if status, err = s.Control(svc.Stop); err != nil {
+		return fmt.Errorf("stop service: %w", err)
+	}
+	deadline := time.Now().Add(stopTimeout)
+	for status.State != svc.Stopped {
+		if time.Now().After(deadline) {
+			return fmt.Errorf("service did not stop within %s", stopTimeout)
+		}
+		time.Sleep(300 * time.Millisecond)
+		if status, err = s.Query(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func stateName(state svc.State) string {
+	switch state {
+	case svc.Stopped:
+		return "stopped"
+	case svc.StartPending:
+		return "starting"
+	case svc.StopPending:
+		return "stopping"
+	case svc.Running:
+		return "running"
+	case svc.ContinuePending, svc.PausePending, svc.Paused:
+		return "paused"
+	default:
+		return strings.ToLower(fmt.Sprint(state))
+	}
+}
+
+// Context returns a context that is cancelled when the Service Control Manager asks the
+// service to stop. The returned function must be called once the proxy has shut down so
+// the service reports "stopped". When the process is not run as a service, parent is
+// returned unchanged.
+func Context(parent context.Context) (context.Context, func()) {
+	isService, err := svc.IsWindowsService()
+	if err != nil || !isService {
+		return parent, func() {}
+	}
+	ctx, cancel := context.WithCancel(parent)
+	finished := make(chan struct{})
+	exited := make(chan struct{})
+	go func() {
+		defer close(exited)
+		// The service name is ignored for services running in their own process.
+		_ = svc.Run("", &scmHandler{cancel: cancel, finished: finished})
+		cancel()
+	}()
+	var once sync.Once
+	return ctx, func() {
+		once.Do(func() {
+			cancel()
+			close(finished)
+			<-exited
+		})
+	}
+}
+
+// scmHandler translates Service Control Manager requests into context cancellation.
+type scmHandler struct {
+	cancel   context.CancelFunc
+	finished chan struct{}
+}
+
+func (h *scmHandler) Execute(_ []string, requests <-chan svc.ChangeRequest, status chan<- svc.Status) (bool, uint32) {
+	const accepted = svc.AcceptStop | svc.AcceptShutdown
+	status <- svc.Status{State: svc.StartPending}
+	status <- svc.Status{State: svc.Running, Accepts: accepted}
+	for {
+		select {
+		case <-h.finished:
+			status <- svc.Status{State: svc.StopPending}
+			return false, 0
+		case request := <-requests:
+			switch request.Cmd {
+			case svc.Interrogate:
+				status <- request.CurrentStatus
+			case svc.Stop, svc.Shutdown:
+				status <- svc.Status{State: svc.StopPending, WaitHint: uint32(stopTimeout.Milliseconds())}
+				h.cancel()
+				<-h.finished
+				return false, 0
+			}
+		}
+	}
+}
@@ -0,0 +1,8 @@
+//go:build !windows && !darwin
+
+package osservice
+
+// New reports ErrUnsupported.
+func New() (Manager, error) {
+	return nil, ErrUnsupported
+}
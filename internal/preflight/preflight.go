@@ -0,0 +1,170 @@
+// Package preflight verifies the targets of model mappings against the providers that are
+// loaded, so mappings that will fail at request time are reported when the config is
+// applied instead of when a client first hits them.
+package preflight
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/thinking"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	sdktranslator "github.com/router-for-me/CLIProxyAPI/v6/sdk/translator"
+	log "github.com/sirupsen/logrus"
+)
+
+// Counter sends token count requests to the credentials of the given providers.
+// coreauth.Manager implements it.
+type Counter interface {
+	ExecuteCount(ctx context.Context, providers []string, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (cliproxyexecutor.Response, error)
+}
+
+// Mapping is one configured model mapping.
+type Mapping struct {
+	// Source names where the mapping is configured, e.g. "ampcode" or "project team-a".
+	Source string
+	From   string
+	To     string
+}
+
+// Finding reports a mapping target that will fail at request time.
+type Finding struct {
+	Mapping
+	// Provider is set when a live check failed against one provider.
+	Provider string
+	Problem  string
+}
+
+// String formats the finding as an actionable log line.
+func (f Finding) String() string {
+	if f.Provider != "" {
+		return fmt.Sprintf("%s mapping %q -> %q: %s answered: %s", f.Source, f.From, f.To, f.Provider, f.Problem)
+	}
+	return fmt.Sprintf("%s mapping %q -> %q: %s", f.Source, f.From, f.To, f.Problem)
+}
+
+// Mappings lists the model mappings of cfg in a stable order.
+func Mappings(cfg *config.Config) []Mapping {
+	if cfg == nil {
+		return nil
+	}
+	var out []Mapping
+	for _, m := range cfg.AmpCode.ModelMappings {
+		out = append(out, Mapping{Source: "ampcode", From: m.From, To: m.To})
+	}
+	for _, project := range cfg.Projects {
+		for _, m := range project.ModelMappings {
+			out = append(out, Mapping{Source: "project " + project.Name, From: m.From, To: m.To})
+		}
+	}
+	return out
+}
+
+// Check verifies each mapping target: a target must be served by a loaded credential and,
+// when counter is set, no provider serving it may answer a token count request with 404.
+// Other live errors, e.g. providers without token counting, are not reported.
+func Check(ctx context.Context, cfg *config.Config, counter Counter) []Finding {
+	mappings := Mappings(cfg)
+	if len(mappings) == 0 {
+		return nil
+	}
+	timeout := time.Duration(cfg.MappingPreflight.TimeoutSeconds) * time.Second
+	if timeout <= 0 {
+		timeout = config.DefaultMappingPreflightTimeoutSeconds * time.Second
+	}
+
+	// Live results are shared by every mapping with the same target.
+	liveProblems := make(map[string]map[string]string)
+	var findings []Finding
+	for _, mapping := range mappings {
+		target := strings.TrimSpace(thinking.ParseSuffix(mapping.To).ModelName)
+		if target == "" {
+			continue
+		}
+		providers := util.GetProviderName(target)
+		if len(providers) == 0 {
+			findings = append(findings, Finding{
+				Mapping: mapping,
+				Problem: "no loaded credential serves the target model; add a credential for it or change the mapping",
+			})
+			continue
+		}
+		if counter == nil {
+			continue
+		}
+		problems, ok := liveProblems[target]
+		if !ok {
+			problems = checkLive(ctx, counter, target, providers, timeout)
+			liveProblems[target] = problems
+		}
+		names := make([]string, 0, len(problems))
+		for provider := range problems {
+			names = append(names, provider)
+		}
+		sort.Strings(names)
+		for _, provider := range names {
+			findings = append(findings, Finding{Mapping: mapping, Provider: provider, Problem: problems[provider]})
+		}
+	}
+	return findings
+}
+
+// Run checks the mappings of cfg and logs a warning per finding.
+func Run(ctx context.Context, cfg *config.Config, counter Counter) []Finding {
+	findings := Check(ctx, cfg, counter)
+	for _, finding := range findings {
+		log.Warnf("mapping-preflight: %s", finding)
+	}
+	if len(findings) == 0 {
+		log.Debugf("mapping-preflight: %d model mappings verified", len(Mappings(cfg)))
+	}
+	return findings
+}
+
+func checkLive(ctx context.Context, counter Counter, model string, providers []string, timeout time.Duration) map[string]string {
+	payload, err := json.Marshal(map[string]any{
+		"model":    model,
+		"messages": []map[string]string{{"role": "user", "content": "ping"}},
+	})
+	if err != nil {
+		return nil
+	}
+	problems := make(map[string]string)
+	for _, provider := range providers {
+		checkCtx, cancel := context.WithTimeout(ctx, timeout)
+		_, errCount := counter.ExecuteCount(checkCtx, []string{provider}, cliproxyexecutor.Request{
+			Model:   model,
+			Payload: payload,
+		}, cliproxyexecutor.Options{
+			OriginalRequest: payload,
+			SourceFormat:    sdktranslator.FromString("openai"),
+			Metadata:        map[string]any{cliproxyexecutor.RequestedModelMetadataKey: model},
+		})
+		cancel()
+		if errCount == nil {
+			continue
+		}
+		var se cliproxyexecutor.StatusError
+		if errors.As(errCount, &se) && se != nil && se.StatusCode() == http.StatusNotFound {
+			problems[provider] = "404 " + truncate(strings.TrimSpace(errCount.Error()), 200)
+			continue
+		}
+		log.Debugf("mapping-preflight: could not verify %s on %s: %v", model, provider, errCount)
+	}
+	return problems
+}
+
+func truncate(s string, limit int) string {
+	if len(s) <= limit {
+		return s
+	}
+	return s[:limit] + "..."
+}
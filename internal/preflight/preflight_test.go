@@ -0,0 +1,74 @@
+package preflight
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+)
+
+type statusErr struct{ code int }
+
+func (e statusErr) Error() string   { return http.StatusText(e.code) }
+func (e statusErr) StatusCode() int { return e.code }
+
+type fakeCounter struct {
+	fail  map[string]error
+	calls []string
+}
+
+func (f *fakeCounter) ExecuteCount(_ context.Context, providers []string, req cliproxyexecutor.Request, _ cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+	key := providers[0] + "/" + req.Model
+	f.calls = append(f.calls, key)
+	if err := f.fail[key]; err != nil {
+		return cliproxyexecutor.Response{}, err
+	}
+	return cliproxyexecutor.Response{Payload: []byte(`{"input_tokens":3}`)}, nil
+}
+
+func TestCheck_ReportsUnservedAndMissingTargets(t *testing.T) {
+	reg := registry.GetGlobalRegistry()
+	reg.RegisterClient("preflight-claude", "claude", []*registry.ModelInfo{{ID: "preflight-sonnet"}, {ID: "preflight-retired"}})
+	reg.RegisterClient("preflight-kiro", "kiro", []*registry.ModelInfo{{ID: "preflight-retired"}})
+	defer reg.UnregisterClient("preflight-claude")
+	defer reg.UnregisterClient("preflight-kiro")
+
+	cfg := &config.Config{}
+	cfg.AmpCode.ModelMappings = []config.AmpModelMapping{
+		{From: "claude-opus-4.5", To: "preflight-sonnet(high)"},
+		{From: "gpt-5", To: "preflight-unknown"},
+	}
+	cfg.Projects = []config.Project{{Name: "team-a", ModelMappings: []config.AmpModelMapping{
+		{From: "old", To: "preflight-retired"},
+		{From: "older", To: "preflight-retired"},
+	}}}
+
+	if findings := Check(context.Background(), cfg, nil); len(findings) != 1 || findings[0].To != "preflight-unknown" {
+		t.Fatalf("registry-only findings = %+v", findings)
+	}
+
+	counter := &fakeCounter{fail: map[string]error{
+		"claude/preflight-retired": statusErr{code: http.StatusNotFound},
+		"kiro/preflight-retired":   errors.New("count tokens not supported"),
+	}}
+	findings := Check(context.Background(), cfg, counter)
+	if len(findings) != 3 {
+		t.Fatalf("findings = %+v", findings)
+	}
+	for _, finding := range findings[1:] {
+		if finding.Source != "project team-a" || finding.Provider != "claude" || !strings.HasPrefix(finding.Problem, "404") {
+			t.Fatalf("finding = %+v", finding)
+		}
+	}
+	if len(counter.calls) != 3 {
+		t.Fatalf("each target should be checked once per provider, calls = %v", counter.calls)
+	}
+	if counter.calls[0] != "claude/preflight-sonnet" {
+		t.Fatalf("thinking suffixes should be stripped, calls = %v", counter.calls)
+	}
+}
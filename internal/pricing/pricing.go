@@ -0,0 +1,148 @@
+// Package pricing prices model token usage for cost accounting. The bundled list prices
+// cover common models; the pricing config section overrides or extends them.
+package pricing
+
+import (
+	"strings"
+	"sync/atomic"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/thinking"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	coreusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+)
+
+// defaultPrices are public list prices in USD per 1,000 tokens. More specific patterns
+// come first since the first match wins.
+var defaultPrices = []config.ModelPrice{
+	{Model: "claude-opus-4-5*", InputPer1K: 0.005, OutputPer1K: 0.025, CachedInputPer1K: 0.0005},
+	{Model: "claude-opus-4*", InputPer1K: 0.015, OutputPer1K: 0.075, CachedInputPer1K: 0.0015},
+	{Model: "claude-sonnet-4*", InputPer1K: 0.003, OutputPer1K: 0.015, CachedInputPer1K: 0.0003},
+	{Model: "claude-3-7-sonnet*", InputPer1K: 0.003, OutputPer1K: 0.015, CachedInputPer1K: 0.0003},
+	{Model: "claude-haiku-4-5*", InputPer1K: 0.001, OutputPer1K: 0.005, CachedInputPer1K: 0.0001},
+	{Model: "claude-3-5-haiku*", InputPer1K: 0.0008, OutputPer1K: 0.004, CachedInputPer1K: 0.00008},
+	{Model: "gpt-5*-nano*", InputPer1K: 0.00005, OutputPer1K: 0.0004, CachedInputPer1K: 0.000005},
+	{Model: "gpt-5*-mini*", InputPer1K: 0.00025, OutputPer1K: 0.002, CachedInputPer1K: 0.000025},
+	{Model: "gpt-5*", InputPer1K: 0.00125, OutputPer1K: 0.01, CachedInputPer1K: 0.000125},
+	{Model: "gpt-4.1-mini*", InputPer1K: 0.0004, OutputPer1K: 0.0016, CachedInputPer1K: 0.0001},
+	{Model: "gpt-4.1*", InputPer1K: 0.002, OutputPer1K: 0.008, CachedInputPer1K: 0.0005},
+	{Model: "gpt-4o-mini*", InputPer1K: 0.00015, OutputPer1K: 0.0006, CachedInputPer1K: 0.000075},
+	{Model: "gpt-4o*", InputPer1K: 0.0025, OutputPer1K: 0.01, CachedInputPer1K: 0.00125},
+	{Model: "o4-mini*", InputPer1K: 0.0011, OutputPer1K: 0.0044, CachedInputPer1K: 0.000275},
+	{Model: "o3*", InputPer1K: 0.002, OutputPer1K: 0.008, CachedInputPer1K: 0.0005},
+	{Model: "gemini-3-pro*", InputPer1K: 0.002, OutputPer1K: 0.012, CachedInputPer1K: 0.0002},
+	{Model: "gemini-2.5-pro*", InputPer1K: 0.00125, OutputPer1K: 0.01, CachedInputPer1K: 0.000125},
+	{Model: "gemini-2.5-flash-lite*", InputPer1K: 0.0001, OutputPer1K: 0.0004, CachedInputPer1K: 0.00001},
+	{Model: "gemini-2.5-flash*", InputPer1K: 0.0003, OutputPer1K: 0.0025, CachedInputPer1K: 0.00003},
+}
+
+// Usage is the token usage of one request. CachedTokens are the part of InputTokens read
+// from the cache. ReasoningTokens are the thinking tokens; unless ReasoningApart is set they
+// are the part of OutputTokens spent thinking, as OpenAI-style usage reports them.
+type Usage struct {
+	InputTokens     int64
+	CachedTokens    int64
+	OutputTokens    int64
+	ReasoningTokens int64
+	ReasoningApart  bool
+}
+
+// UsageOf returns the billable usage of a usage record detail.
+func UsageOf(d coreusage.Detail) Usage {
+	return Usage{
+		InputTokens:     d.InputTokens,
+		CachedTokens:    d.CachedTokens,
+		OutputTokens:    d.OutputTokens,
+		ReasoningTokens: d.ReasoningTokens,
+		ReasoningApart:  d.ReasoningApart(),
+	}
+}
+
+// Table resolves model prices. The zero value prices nothing; use New or Default.
+type Table struct {
+	prices  atomic.Pointer[[]config.ModelPrice]
+	headers atomic.Bool
+}
+
+var defaultTable = New(config.PricingConfig{})
+
+// Default returns the process-wide table.
+func Default() *Table { return defaultTable }
+
+// New returns a table of cfg's prices ahead of the bundled ones.
+func New(cfg config.PricingConfig) *Table {
+	t := &Table{}
+	t.Configure(cfg)
+	return t
+}
+
+// Configure replaces the configured prices.
+func (t *Table) Configure(cfg config.PricingConfig) {
+	if t == nil {
+		return
+	}
+	prices := make([]config.ModelPrice, 0, len(cfg.Models)+len(defaultPrices))
+	prices = append(prices, cfg.Models...)
+	if !cfg.DisableDefaults {
+		prices = append(prices, defaultPrices...)
+	}
+	t.prices.Store(&prices)
+	t.headers.Store(!cfg.DisableResponseHeaders)
+}
+
+// ResponseHeaders reports whether responses carry the cost and route headers.
+func (t *Table) ResponseHeaders() bool {
+	return t != nil && t.headers.Load()
+}
+
+// Lookup returns the price of model. Thinking suffixes such as "(high)" are ignored.
+func (t *Table) Lookup(model string) (config.ModelPrice, bool) {
+	if t == nil {
+		return config.ModelPrice{}, false
+	}
+	prices := t.prices.Load()
+	if prices == nil {
+		return config.ModelPrice{}, false
+	}
+	model = thinking.ParseSuffix(strings.TrimSpace(model)).ModelName
+	for _, price := range *prices {
+		if util.MatchWildcard(price.Model, model) {
+			return price, true
+		}
+	}
+	return config.ModelPrice{}, false
+}
+
+// Cost returns the USD cost of usage for model, or false when no price matches.
+func (t *Table) Cost(model string, usage Usage) (float64, bool) {
+	price, ok := t.Lookup(model)
+	if !ok {
+		return 0, false
+	}
+	return Price(price, usage), true
+}
+
+// Price returns the USD cost of usage at price.
+func Price(price config.ModelPrice, usage Usage) float64 {
+	cachedRate := price.CachedInputPer1K
+	if cachedRate == 0 {
+		cachedRate = price.InputPer1K
+	}
+	reasoningRate := price.ReasoningPer1K
+	if reasoningRate == 0 {
+		reasoningRate = price.OutputPer1K
+	}
+	cached := min(max(usage.CachedTokens, 0), max(usage.InputTokens, 0))
+	uncached := max(usage.InputTokens-cached, 0)
+	reasoning := max(usage.ReasoningTokens, 0)
+	output := max(usage.OutputTokens, 0)
+	if !usage.ReasoningApart {
+		reasoning = min(reasoning, output)
+		output -= reasoning
+	}
+	cost := float64(uncached)*price.InputPer1K +
+		float64(cached)*cachedRate +
+		float64(output)*price.OutputPer1K +
+		float64(reasoning)*reasoningRate
+	return cost / 1000
+}
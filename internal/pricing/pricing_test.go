@@ -0,0 +1,53 @@
+package pricing
+
+import (
+	"math"
+	"testing"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	coreusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+)
+
+func TestTableCost(t *testing.T) {
+	table := New(config.PricingConfig{Models: []config.ModelPrice{
+		{Model: "claude-sonnet-4*", InputPer1K: 0.01, OutputPer1K: 0.02, ReasoningPer1K: 0.04},
+	}})
+	usage := Usage{InputTokens: 1000, CachedTokens: 500, OutputTokens: 100, ReasoningTokens: 50}
+
+	// The configured entry overrides the bundled price; cache reads use the input price and
+	// the reasoning part of the output uses the reasoning price.
+	cost, ok := table.Cost("claude-sonnet-4-20250514(high)", usage)
+	if want := 0.01 + 0.001 + 0.002; !ok || math.Abs(cost-want) > 1e-12 {
+		t.Fatalf("configured cost = %v, %v; want %v", cost, ok, want)
+	}
+	apart := usage
+	apart.ReasoningApart = true
+	cost, ok = table.Cost("claude-sonnet-4", apart)
+	if want := 0.01 + 0.002 + 0.002; !ok || math.Abs(cost-want) > 1e-12 {
+		t.Fatalf("cost with reasoning reported apart = %v, %v; want %v", cost, ok, want)
+	}
+	// Bundled price: reasoning is priced like output when no reasoning price is set.
+	cost, ok = table.Cost("gpt-5-mini", usage)
+	if want := 0.5*0.00025 + 0.5*0.000025 + 0.1*0.002; !ok || math.Abs(cost-want) > 1e-12 {
+		t.Fatalf("bundled cost = %v, %v; want %v", cost, ok, want)
+	}
+	if _, ok = table.Cost("unknown-model", usage); ok {
+		t.Fatal("expected no price for an unknown model")
+	}
+
+	table.Configure(config.PricingConfig{DisableDefaults: true})
+	if _, ok = table.Cost("gpt-5-mini", usage); ok {
+		t.Fatal("bundled prices still apply with disable-defaults")
+	}
+}
+
+func TestUsageOfTellsWhereReasoningIsCounted(t *testing.T) {
+	// OpenAI-style usage: the total holds reasoning once, inside the output tokens.
+	if u := UsageOf(coreusage.Detail{InputTokens: 10, OutputTokens: 30, ReasoningTokens: 20, TotalTokens: 40}); u.ReasoningApart {
+		t.Fatalf("openai usage = %+v, want reasoning inside output", u)
+	}
+	// Gemini-style usage: thoughts are added to the total on top of the output tokens.
+	if u := UsageOf(coreusage.Detail{InputTokens: 10, OutputTokens: 30, ReasoningTokens: 20, TotalTokens: 60}); !u.ReasoningApart {
+		t.Fatalf("gemini usage = %+v, want reasoning apart", u)
+	}
+}
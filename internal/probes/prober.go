@@ -0,0 +1,345 @@
+// Package probes runs scheduled synthetic requests against every credential of configured
+// providers. Each probe is executed on one specific credential and recorded through the
+// auth manager like live traffic, so failing credentials cool down before users reach
+// them; the outcome of the latest probes is kept for the management API.
+package probes
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/schedule"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	sdktranslator "github.com/router-for-me/CLIProxyAPI/v6/sdk/translator"
+	log "github.com/sirupsen/logrus"
+)
+
+// tickInterval is how often the scheduler looks for due targets.
+const tickInterval = 15 * time.Second
+
+var defaultProber = NewProber()
+
+// Default returns the process-wide prober.
+func Default() *Prober { return defaultProber }
+
+// Executor runs a request on one credential; *coreauth.Manager implements it.
+type Executor interface {
+	List() []*coreauth.Auth
+	ExecuteWithAuth(ctx context.Context, authID string, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (cliproxyexecutor.Response, error)
+}
+
+// Status is the probe history of one credential and model.
+type Status struct {
+	Provider            string    `json:"provider"`
+	Model               string    `json:"model"`
+	AuthID              string    `json:"auth_id"`
+	AuthLabel           string    `json:"auth_label,omitempty"`
+	LastRun             time.Time `json:"last_run"`
+	Success             bool      `json:"success"`
+	LatencyMs           int64     `json:"latency_ms"`
+	Error               string    `json:"error,omitempty"`
+	Successes           int64     `json:"successes"`
+	Failures            int64     `json:"failures"`
+	ConsecutiveFailures int       `json:"consecutive_failures"`
+	MeanLatencyMs       int64     `json:"mean_latency_ms"`
+
+	latencySum int64
+}
+
+type target struct {
+	cfg     config.SyntheticProbeTarget
+	prompt  string
+	sched   schedule.Schedule
+	next    time.Time
+	running bool
+}
+
+// Prober schedules and runs synthetic probes.
+type Prober struct {
+	mu       sync.Mutex
+	cfg      config.SyntheticProbesConfig
+	targets  []*target
+	executor Executor
+	statuses map[string]*Status
+	cancel   context.CancelFunc
+	nowFunc  func() time.Time
+	tick     time.Duration
+	inflight sync.WaitGroup
+}
+
+// NewProber constructs a stopped prober.
+func NewProber() *Prober {
+	return &Prober{statuses: make(map[string]*Status), nowFunc: time.Now, tick: tickInterval}
+}
+
+// SetExecutor installs the auth manager probes run through.
+func (p *Prober) SetExecutor(executor Executor) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	p.executor = executor
+	p.mu.Unlock()
+}
+
+// Configure replaces the probe targets and starts or stops the scheduler. Targets whose
+// provider, model and schedule are unchanged keep their next run time.
+func (p *Prober) Configure(cfg config.SyntheticProbesConfig) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	previous := make(map[string]*target, len(p.targets))
+	for _, t := range p.targets {
+		previous[targetKey(t.cfg)] = t
+	}
+	now := p.nowFunc()
+	targets := make([]*target, 0, len(cfg.Targets))
+	for _, tc := range cfg.Targets {
+		expr := tc.Schedule
+		if expr == "" {
+			expr = cfg.Schedule
+		}
+		if expr == "" {
+			expr = config.DefaultSyntheticProbeSchedule
+		}
+		tc.Schedule = expr
+		sched, err := schedule.Parse(expr)
+		if err != nil {
+			log.Warnf("synthetic-probes: skipping %s/%s: %v", tc.Provider, tc.Model, err)
+			continue
+		}
+		t := &target{cfg: tc, prompt: tc.Prompt, sched: sched, next: sched.Next(now)}
+		if t.prompt == "" {
+			t.prompt = cfg.Prompt
+		}
+		if t.prompt == "" {
+			t.prompt = config.DefaultSyntheticProbePrompt
+		}
+		if prev, ok := previous[targetKey(tc)]; ok {
+			// Keep the running target so its in-flight round still clears running.
+			prev.cfg, prev.prompt = tc, t.prompt
+			t = prev
+		}
+		targets = append(targets, t)
+	}
+	p.cfg = cfg
+	p.targets = targets
+	configured := make(map[string]bool, len(targets))
+	for _, t := range targets {
+		configured[t.cfg.Provider+"\x00"+t.cfg.Model] = true
+	}
+	for key, st := range p.statuses {
+		if !configured[st.Provider+"\x00"+st.Model] {
+			delete(p.statuses, key)
+		}
+	}
+
+	switch {
+	case cfg.Enabled && len(targets) > 0 && p.cancel == nil:
+		ctx, cancel := context.WithCancel(context.Background())
+		p.cancel = cancel
+		go p.run(ctx)
+	case (!cfg.Enabled || len(targets) == 0) && p.cancel != nil:
+		p.cancel()
+		p.cancel = nil
+	}
+}
+
+// Stop halts the scheduler. Probes in flight finish.
+func (p *Prober) Stop() {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.cancel != nil {
+		p.cancel()
+		p.cancel = nil
+	}
+}
+
+// Enabled reports whether the scheduler is on.
+func (p *Prober) Enabled() bool {
+	if p == nil {
+		return false
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.cfg.Enabled
+}
+
+// RunNow starts every target immediately, regardless of its schedule, and returns how many
+// targets were started. Targets still running from an earlier round are skipped.
+func (p *Prober) RunNow() int {
+	if p == nil {
+		return 0
+	}
+	return p.startDue(true)
+}
+
+// Snapshot returns the probe history per credential and model.
+func (p *Prober) Snapshot() []Status {
+	if p == nil {
+		return nil
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	out := make([]Status, 0, len(p.statuses))
+	for _, st := range p.statuses {
+		out = append(out, *st)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Provider != out[j].Provider {
+			return out[i].Provider < out[j].Provider
+		}
+		if out[i].Model != out[j].Model {
+			return out[i].Model < out[j].Model
+		}
+		return out[i].AuthID < out[j].AuthID
+	})
+	return out
+}
+
+func (p *Prober) run(ctx context.Context) {
+	ticker := time.NewTicker(p.tick)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			p.startDue(false)
+		}
+	}
+}
+
+// startDue launches the targets whose run time has come, or every idle target when all is set.
+func (p *Prober) startDue(all bool) int {
+	p.mu.Lock()
+	now := p.nowFunc()
+	executor := p.executor
+	maxTokens := p.cfg.MaxTokens
+	timeout := time.Duration(p.cfg.TimeoutSeconds) * time.Second
+	var due []*target
+	for _, t := range p.targets {
+		if t.running || (!all && (t.next.IsZero() || now.Before(t.next))) {
+			continue
+		}
+		t.running = true
+		t.next = t.sched.Next(now)
+		due = append(due, t)
+	}
+	p.mu.Unlock()
+	if executor == nil {
+		p.finish(due)
+		return 0
+	}
+	if maxTokens <= 0 {
+		maxTokens = config.DefaultSyntheticProbeMaxTokens
+	}
+	if timeout <= 0 {
+		timeout = config.DefaultSyntheticProbeTimeoutSeconds * time.Second
+	}
+	for _, t := range due {
+		p.inflight.Add(1)
+		go func(t *target) {
+			defer p.inflight.Done()
+			defer p.finish([]*target{t})
+			p.probeTarget(executor, t, maxTokens, timeout)
+		}(t)
+	}
+	return len(due)
+}
+
+func (p *Prober) finish(targets []*target) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, t := range targets {
+		t.running = false
+	}
+}
+
+// probeTarget probes the enabled credentials of the target's provider that serve its model,
+// one after the other.
+func (p *Prober) probeTarget(executor Executor, t *target, maxTokens int, timeout time.Duration) {
+	payload, err := json.Marshal(map[string]any{
+		"model":      t.cfg.Model,
+		"messages":   []map[string]string{{"role": "user", "content": t.prompt}},
+		"max_tokens": maxTokens,
+		"stream":     false,
+	})
+	if err != nil {
+		return
+	}
+	probed := 0
+	for _, auth := range executor.List() {
+		if auth == nil || auth.Disabled || auth.Status == coreauth.StatusDisabled || !strings.EqualFold(auth.Provider, t.cfg.Provider) {
+			continue
+		}
+		if !registry.GetGlobalRegistry().ClientSupportsModel(auth.ID, t.cfg.Model) {
+			continue
+		}
+		probed++
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		started := time.Now()
+		resp, errExec := executor.ExecuteWithAuth(ctx, auth.ID, cliproxyexecutor.Request{
+			Model:   t.cfg.Model,
+			Payload: payload,
+		}, cliproxyexecutor.Options{
+			OriginalRequest: payload,
+			SourceFormat:    sdktranslator.FromString("openai"),
+			Metadata:        map[string]any{cliproxyexecutor.RequestedModelMetadataKey: t.cfg.Model},
+		})
+		cancel()
+		latency := time.Since(started)
+		if errExec == nil && len(bytes.TrimSpace(resp.Payload)) == 0 {
+			errExec = fmt.Errorf("empty response")
+		}
+		p.record(t.cfg, auth, latency, errExec)
+	}
+	if probed == 0 {
+		log.Debugf("synthetic-probes: no credential of %s serves %s", t.cfg.Provider, t.cfg.Model)
+	}
+}
+
+func (p *Prober) record(tc config.SyntheticProbeTarget, auth *coreauth.Auth, latency time.Duration, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	key := auth.ID + "\x00" + tc.Model
+	st, ok := p.statuses[key]
+	if !ok {
+		st = &Status{Provider: tc.Provider, Model: tc.Model, AuthID: auth.ID}
+		p.statuses[key] = st
+	}
+	st.AuthLabel = auth.Label
+	st.LastRun = p.nowFunc()
+	st.LatencyMs = latency.Milliseconds()
+	st.Success = err == nil
+	if err != nil {
+		st.Error = err.Error()
+		st.Failures++
+		st.ConsecutiveFailures++
+		log.Warnf("synthetic-probes: %s %s on %s failed after %s: %v", tc.Provider, tc.Model, auth.ID, latency.Round(time.Millisecond), err)
+		return
+	}
+	st.Error = ""
+	st.Successes++
+	st.ConsecutiveFailures = 0
+	st.latencySum += st.LatencyMs
+	st.MeanLatencyMs = st.latencySum / st.Successes
+}
+
+func targetKey(tc config.SyntheticProbeTarget) string {
+	return tc.Provider + "\x00" + tc.Model + "\x00" + tc.Schedule
+}
@@ -0,0 +1,121 @@
+package probes
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	"github.com/tidwall/gjson"
+)
+
+type fakeExecutor struct {
+	mu    sync.Mutex
+	auths []*coreauth.Auth
+	fail  map[string]bool
+	calls []string
+}
+
+func (f *fakeExecutor) List() []*coreauth.Auth { return f.auths }
+
+func (f *fakeExecutor) ExecuteWithAuth(_ context.Context, authID string, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+	f.mu.Lock()
+	f.calls = append(f.calls, authID+" "+req.Model+" "+gjson.GetBytes(req.Payload, "messages.0.content").String())
+	f.mu.Unlock()
+	if opts.SourceFormat.String() != "openai" {
+		return cliproxyexecutor.Response{}, errors.New("unexpected source format")
+	}
+	if f.fail[authID] {
+		return cliproxyexecutor.Response{}, errors.New("401 unauthorized")
+	}
+	return cliproxyexecutor.Response{Payload: []byte(`{"choices":[{"message":{"content":"pong"}}]}`)}, nil
+}
+
+func TestProber_ProbesEveryCredentialOfProvider(t *testing.T) {
+	reg := registry.GetGlobalRegistry()
+	for _, id := range []string{"probe-a", "probe-b", "probe-c"} {
+		reg.RegisterClient(id, "claude", []*registry.ModelInfo{{ID: "claude-haiku-4-5"}})
+		defer reg.UnregisterClient(id)
+	}
+	executor := &fakeExecutor{
+		auths: []*coreauth.Auth{
+			{ID: "probe-a", Provider: "claude", Label: "a@example.com"},
+			{ID: "probe-b", Provider: "claude"},
+			{ID: "probe-c", Provider: "claude", Disabled: true},
+			{ID: "probe-d", Provider: "codex"},
+		},
+		fail: map[string]bool{"probe-b": true},
+	}
+
+	prober := NewProber()
+	prober.SetExecutor(executor)
+	prober.Configure(config.SyntheticProbesConfig{
+		Schedule: "@every 10m",
+		Prompt:   "ping",
+		Targets:  []config.SyntheticProbeTarget{{Provider: "claude", Model: "claude-haiku-4-5"}},
+	})
+	if started := prober.RunNow(); started != 1 {
+		t.Fatalf("started = %d, want 1", started)
+	}
+	prober.inflight.Wait()
+
+	if len(executor.calls) != 2 || executor.calls[0] != "probe-a claude-haiku-4-5 ping" {
+		t.Fatalf("calls = %v", executor.calls)
+	}
+	statuses := prober.Snapshot()
+	if len(statuses) != 2 {
+		t.Fatalf("statuses = %+v", statuses)
+	}
+	if a := statuses[0]; a.AuthID != "probe-a" || !a.Success || a.Successes != 1 || a.AuthLabel != "a@example.com" {
+		t.Fatalf("probe-a = %+v", a)
+	}
+	if b := statuses[1]; b.Success || b.Failures != 1 || b.ConsecutiveFailures != 1 || b.Error == "" {
+		t.Fatalf("probe-b = %+v", b)
+	}
+
+	prober.RunNow()
+	prober.inflight.Wait()
+	if b := prober.Snapshot()[1]; b.ConsecutiveFailures != 2 {
+		t.Fatalf("probe-b after second round = %+v", b)
+	}
+
+	prober.Configure(config.SyntheticProbesConfig{
+		Targets: []config.SyntheticProbeTarget{{Provider: "codex", Model: "gpt-5"}},
+	})
+	if statuses = prober.Snapshot(); len(statuses) != 0 {
+		t.Fatalf("results of removed targets should be dropped: %+v", statuses)
+	}
+}
+
+func TestProber_StartsDueTargets(t *testing.T) {
+	now := time.Date(2026, 1, 5, 10, 0, 30, 0, time.Local)
+	prober := NewProber()
+	prober.nowFunc = func() time.Time { return now }
+	prober.SetExecutor(&fakeExecutor{})
+	prober.Configure(config.SyntheticProbesConfig{
+		Targets: []config.SyntheticProbeTarget{
+			{Provider: "claude", Model: "m1", Schedule: "*/5 * * * *"},
+			{Provider: "claude", Model: "m2", Schedule: "0 12 * * *"},
+			{Provider: "claude", Model: "m3", Schedule: "bogus"},
+		},
+	})
+	if len(prober.targets) != 2 {
+		t.Fatalf("invalid schedules should be skipped, got %d targets", len(prober.targets))
+	}
+	if started := prober.startDue(false); started != 0 {
+		t.Fatalf("nothing is due yet, started %d", started)
+	}
+	now = now.Add(5 * time.Minute)
+	if started := prober.startDue(false); started != 1 {
+		t.Fatalf("started = %d, want the */5 target", started)
+	}
+	prober.inflight.Wait()
+	if next := prober.targets[0].next; !next.Equal(time.Date(2026, 1, 5, 10, 10, 0, 0, time.Local)) {
+		t.Fatalf("next run = %s", next)
+	}
+}
@@ -0,0 +1,53 @@
+package project
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"path/filepath"
+
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+)
+
+// ErrorCodeCredentialPinned is the coreauth.Error code returned when a credential is
+// reserved for another project.
+const ErrorCodeCredentialPinned = "credential_pinned"
+
+// pinOwnerLocked returns the project a credential is pinned to. Callers must hold r.mu.
+func (r *Registry) pinOwnerLocked(auth *coreauth.Auth) string {
+	if owner, ok := r.pins[auth.ID]; ok {
+		return owner
+	}
+	if auth.FileName != "" {
+		if owner, ok := r.pins[filepath.Base(auth.FileName)]; ok {
+			return owner
+		}
+	}
+	return ""
+}
+
+// FilterAuth implements coreauth.AuthFilter. Credentials pinned to a project are only offered
+// to that project, and projects configured with credentials-only never fall back to shared ones.
+func (r *Registry) FilterAuth(ctx context.Context, auth *coreauth.Auth, _ string) error {
+	if r == nil || auth == nil {
+		return nil
+	}
+	name := FromContext(ctx)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if owner := r.pinOwnerLocked(auth); owner != "" {
+		if owner == name {
+			return nil
+		}
+	} else {
+		st := r.projects[name]
+		if st == nil || !st.cfg.CredentialsOnly {
+			return nil
+		}
+	}
+	return &coreauth.Error{
+		Code:       ErrorCodeCredentialPinned,
+		Message:    fmt.Sprintf("no credential available for project %q", name),
+		HTTPStatus: http.StatusServiceUnavailable,
+	}
+}
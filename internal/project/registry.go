@@ -0,0 +1,413 @@
+// Package project implements multi-tenant scoping of client API keys. Each project owns a
+// set of API keys and may override model mappings, enforce quotas, and keep its usage and
+// transcripts separate from other projects served by the same proxy.
+package project
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	coreusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+	log "github.com/sirupsen/logrus"
+)
+
+// ContextKey is the gin context key holding the resolved project name.
+const ContextKey = "project"
+
+// Counter is a cluster-wide counter store (see internal/sharedstate) used to enforce quotas
+// across instances.
+type Counter interface {
+	Add(ctx context.Context, key string, delta int64, ttl time.Duration) (int64, error)
+}
+
+// ErrQuotaExceeded is returned by Admit when a project has used up one of its quotas.
+var ErrQuotaExceeded = errors.New("project quota exceeded")
+
+type projectContextKey struct{}
+
+// WithName returns a context carrying the project name.
+func WithName(ctx context.Context, name string) context.Context {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return context.WithValue(ctx, projectContextKey{}, name)
+}
+
+// FromContext returns the project name attached to ctx, either directly or via the
+// gin context stored under "gin" by the API handlers.
+func FromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	if name, ok := ctx.Value(projectContextKey{}).(string); ok && name != "" {
+		return name
+	}
+	if ginCtx, ok := ctx.Value("gin").(*gin.Context); ok && ginCtx != nil {
+		return ginCtx.GetString(ContextKey)
+	}
+	return ""
+}
+
+// Usage summarises a project's traffic.
+type Usage struct {
+	Requests      int64 `json:"requests"`
+	Tokens        int64 `json:"tokens"`
+	DailyRequests int   `json:"daily_requests"`
+	MonthlyTokens int64 `json:"monthly_tokens"`
+	LastMinute    int   `json:"last_minute_requests"`
+	Rejected      int64 `json:"rejected"`
+}
+
+// Status describes a configured project and its current usage.
+type Status struct {
+	Name     string              `json:"name"`
+	APIKeys  int                 `json:"api_keys"`
+	Mappings int                 `json:"model_mappings"`
+	Pinned   []string            `json:"credentials,omitempty"`
+	Quota    config.ProjectQuota `json:"quota"`
+	Usage    Usage               `json:"usage"`
+}
+
+type regexMapping struct {
+	re *regexp.Regexp
+	to string
+}
+
+type state struct {
+	cfg     config.Project
+	exact   map[string]string
+	regexps []regexMapping
+	minute  []time.Time
+	day     string
+	month   string
+	usage   Usage
+}
+
+// Registry resolves API keys to projects and tracks per-project quotas and usage.
+type Registry struct {
+	mu       sync.Mutex
+	projects map[string]*state
+	keys     map[string]string
+	pins     map[string]string
+	counter  Counter
+	nowFunc  func() time.Time
+}
+
+var defaultRegistry = NewRegistry()
+
+func init() {
+	coreusage.RegisterPlugin(defaultRegistry)
+}
+
+// GetRegistry returns the process-wide project registry.
+func GetRegistry() *Registry { return defaultRegistry }
+
+// NewRegistry constructs an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{
+		projects: make(map[string]*state),
+		keys:     make(map[string]string),
+		pins:     make(map[string]string),
+		nowFunc:  time.Now,
+	}
+}
+
+// Configure replaces the project definitions. Usage counters are kept for projects that
+// survive the reload.
+func (r *Registry) Configure(projects []config.Project) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	next := make(map[string]*state, len(projects))
+	keys := make(map[string]string)
+	pins := make(map[string]string)
+	for _, p := range projects {
+		st := r.projects[p.Name]
+		if st == nil {
+			st = &state{}
+		}
+		st.cfg = p
+		st.exact = make(map[string]string, len(p.ModelMappings))
+		st.regexps = st.regexps[:0]
+		for _, mapping := range p.ModelMappings {
+			if !mapping.Regex {
+				st.exact[strings.ToLower(mapping.From)] = mapping.To
+				continue
+			}
+			re, err := regexp.Compile("(?i)" + mapping.From)
+			if err != nil {
+				log.Warnf("project %s: invalid model mapping regex %q: %v", p.Name, mapping.From, err)
+				continue
+			}
+			st.regexps = append(st.regexps, regexMapping{re: re, to: mapping.To})
+		}
+		next[p.Name] = st
+		for _, key := range p.APIKeys {
+			keys[key] = p.Name
+		}
+		for _, id := range p.Credentials {
+			if owner, dup := pins[id]; dup {
+				log.Warnf("project %s: credential %s is already pinned to project %s", p.Name, id, owner)
+				continue
+			}
+			pins[id] = p.Name
+		}
+	}
+	r.projects = next
+	r.keys = keys
+	r.pins = pins
+}
+
+// SetCounter shares quota counters through counter. Nil reverts to per-instance counting.
+func (r *Registry) SetCounter(counter Counter) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	r.counter = counter
+	r.mu.Unlock()
+}
+
+// Enabled reports whether any project is configured.
+func (r *Registry) Enabled() bool {
+	if r == nil {
+		return false
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.projects) > 0
+}
+
+// Resolve returns the project owning apiKey.
+func (r *Registry) Resolve(apiKey string) (string, bool) {
+	if r == nil || apiKey == "" {
+		return "", false
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	name, ok := r.keys[apiKey]
+	return name, ok
+}
+
+// Keys returns the API keys of the named project.
+func (r *Registry) Keys(name string) []string {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	st := r.projects[name]
+	if st == nil {
+		return nil
+	}
+	return append([]string(nil), st.cfg.APIKeys...)
+}
+
+// rollLocked resets daily/monthly counters when the period changes. Callers must hold r.mu.
+func (r *Registry) rollLocked(st *state, now time.Time) {
+	day := now.UTC().Format("2006-01-02")
+	if st.day != day {
+		st.day = day
+		st.usage.DailyRequests = 0
+	}
+	month := now.UTC().Format("2006-01")
+	if st.month != month {
+		st.month = month
+		st.usage.MonthlyTokens = 0
+	}
+	cutoff := now.Add(-time.Minute)
+	trim := 0
+	for trim < len(st.minute) && !st.minute[trim].After(cutoff) {
+		trim++
+	}
+	st.minute = st.minute[trim:]
+	st.usage.LastMinute = len(st.minute)
+}
+
+// Admit resolves the project of apiKey and records the request against its quota.
+// It returns the project name ("" when the key belongs to none) and ErrQuotaExceeded
+// when the request must be rejected.
+func (r *Registry) Admit(apiKey string) (string, error) {
+	if r == nil || apiKey == "" {
+		return "", nil
+	}
+	r.mu.Lock()
+	name, ok := r.keys[apiKey]
+	if !ok {
+		r.mu.Unlock()
+		return "", nil
+	}
+	st := r.projects[name]
+	now := r.nowFunc()
+	r.rollLocked(st, now)
+	quota := st.cfg.Quota
+	counter := r.counter
+	if counter != nil {
+		r.mu.Unlock()
+		err := r.admitShared(counter, st, name, quota, now)
+		return name, err
+	}
+	defer r.mu.Unlock()
+	switch {
+	case quota.RequestsPerMinute > 0 && len(st.minute) >= quota.RequestsPerMinute:
+		st.usage.Rejected++
+		return name, fmt.Errorf("%w: %d requests per minute", ErrQuotaExceeded, quota.RequestsPerMinute)
+	case quota.DailyRequests > 0 && st.usage.DailyRequests >= quota.DailyRequests:
+		st.usage.Rejected++
+		return name, fmt.Errorf("%w: %d requests per day", ErrQuotaExceeded, quota.DailyRequests)
+	case quota.MonthlyTokens > 0 && st.usage.MonthlyTokens >= quota.MonthlyTokens:
+		st.usage.Rejected++
+		return name, fmt.Errorf("%w: %d tokens per month", ErrQuotaExceeded, quota.MonthlyTokens)
+	}
+	st.minute = append(st.minute, now)
+	st.usage.LastMinute = len(st.minute)
+	st.usage.DailyRequests++
+	st.usage.Requests++
+	return name, nil
+}
+
+// admitShared enforces quotas against cluster-wide counters. The per-minute limit uses fixed
+// one-minute windows. Each request counter is incremented and compared in one atomic step,
+// and a rejected request takes its increments back, so only admitted requests count and
+// concurrent requests cannot overshoot a limit. Backend errors admit the request rather
+// than failing closed.
+func (r *Registry) admitShared(counter Counter, st *state, name string, quota config.ProjectQuota, now time.Time) error {
+	ctx := context.Background()
+	errQuota := func() error {
+		if quota.MonthlyTokens > 0 {
+			if v, err := counter.Add(ctx, projectTokensKey(name, now), 0, 40*24*time.Hour); err == nil && v >= quota.MonthlyTokens {
+				return fmt.Errorf("%w: %d tokens per month", ErrQuotaExceeded, quota.MonthlyTokens)
+			}
+		}
+		taken := make(map[string]time.Duration)
+		release := func() {
+			for key, ttl := range taken {
+				_, _ = counter.Add(ctx, key, -1, ttl)
+			}
+		}
+		if quota.RequestsPerMinute > 0 {
+			key := "project:" + name + ":rpm:" + now.UTC().Format("200601021504")
+			if v, err := counter.Add(ctx, key, 1, 2*time.Minute); err == nil {
+				taken[key] = 2 * time.Minute
+				if v > int64(quota.RequestsPerMinute) {
+					release()
+					return fmt.Errorf("%w: %d requests per minute", ErrQuotaExceeded, quota.RequestsPerMinute)
+				}
+			}
+		}
+		if quota.DailyRequests > 0 {
+			key := "project:" + name + ":day:" + now.UTC().Format("20060102")
+			if v, err := counter.Add(ctx, key, 1, 48*time.Hour); err == nil {
+				taken[key] = 48 * time.Hour
+				if v > int64(quota.DailyRequests) {
+					release()
+					return fmt.Errorf("%w: %d requests per day", ErrQuotaExceeded, quota.DailyRequests)
+				}
+				r.mu.Lock()
+				st.usage.DailyRequests = int(v)
+				r.mu.Unlock()
+			}
+		}
+		return nil
+	}()
+	r.mu.Lock()
+	if errQuota != nil {
+		st.usage.Rejected++
+	} else {
+		st.usage.Requests++
+	}
+	r.mu.Unlock()
+	return errQuota
+}
+
+func projectTokensKey(name string, now time.Time) string {
+	return "project:" + name + ":tokens:" + now.UTC().Format("200601")
+}
+
+// MapModel applies the project's model mappings. It returns "" when no mapping matches.
+func (r *Registry) MapModel(name, model string) string {
+	if r == nil || name == "" || model == "" {
+		return ""
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	st := r.projects[name]
+	if st == nil {
+		return ""
+	}
+	if to, ok := st.exact[strings.ToLower(model)]; ok {
+		return to
+	}
+	for _, rm := range st.regexps {
+		if rm.re.MatchString(model) {
+			return rm.to
+		}
+	}
+	return ""
+}
+
+// HandleUsage implements coreusage.Plugin, attributing token usage to the key's project.
+func (r *Registry) HandleUsage(_ context.Context, record coreusage.Record) {
+	if r == nil || record.APIKey == "" {
+		return
+	}
+	tokens := record.Detail.TotalTokens
+	if tokens == 0 {
+		tokens = record.Detail.InputTokens + record.Detail.OutputTokens + record.Detail.ReasoningTokens
+	}
+	r.mu.Lock()
+	name, ok := r.keys[record.APIKey]
+	if !ok {
+		r.mu.Unlock()
+		return
+	}
+	st := r.projects[name]
+	now := r.nowFunc()
+	r.rollLocked(st, now)
+	st.usage.Tokens += tokens
+	st.usage.MonthlyTokens += tokens
+	counter := r.counter
+	r.mu.Unlock()
+
+	if counter != nil && tokens > 0 {
+		if v, err := counter.Add(context.Background(), projectTokensKey(name, now), tokens, 40*24*time.Hour); err == nil {
+			r.mu.Lock()
+			st.usage.MonthlyTokens = v
+			r.mu.Unlock()
+		}
+	}
+}
+
+// Snapshot returns every configured project with its current usage.
+func (r *Registry) Snapshot() []Status {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := r.nowFunc()
+	out := make([]Status, 0, len(r.projects))
+	for name, st := range r.projects {
+		r.rollLocked(st, now)
+		out = append(out, Status{
+			Name:     name,
+			APIKeys:  len(st.cfg.APIKeys),
+			Mappings: len(st.cfg.ModelMappings),
+			Pinned:   append([]string(nil), st.cfg.Credentials...),
+			Quota:    st.cfg.Quota,
+			Usage:    st.usage,
+		})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
@@ -0,0 +1,141 @@
+package project
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	coreusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+)
+
+func TestRegistry_AdmitEnforcesQuotas(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	r := NewRegistry()
+	r.nowFunc = func() time.Time { return now }
+	r.Configure([]config.Project{
+		{Name: "a", APIKeys: []string{"key-a"}, Quota: config.ProjectQuota{RequestsPerMinute: 2, MonthlyTokens: 100}},
+	})
+
+	for i := 0; i < 2; i++ {
+		if name, err := r.Admit("key-a"); err != nil || name != "a" {
+			t.Fatalf("admit %d: name=%q err=%v", i, name, err)
+		}
+	}
+	if _, err := r.Admit("key-a"); !errors.Is(err, ErrQuotaExceeded) {
+		t.Fatalf("expected per-minute quota error, got %v", err)
+	}
+	now = now.Add(61 * time.Second)
+	if _, err := r.Admit("key-a"); err != nil {
+		t.Fatalf("expected window to slide, got %v", err)
+	}
+
+	r.HandleUsage(context.Background(), coreusage.Record{APIKey: "key-a", Detail: coreusage.Detail{TotalTokens: 150}})
+	now = now.Add(2 * time.Minute)
+	if _, err := r.Admit("key-a"); !errors.Is(err, ErrQuotaExceeded) {
+		t.Fatalf("expected monthly token quota error, got %v", err)
+	}
+
+	if name, err := r.Admit("other"); name != "" || err != nil {
+		t.Fatalf("expected unscoped key to pass, got name=%q err=%v", name, err)
+	}
+}
+
+func TestRegistry_MapModel(t *testing.T) {
+	r := NewRegistry()
+	r.Configure([]config.Project{{
+		Name:    "a",
+		APIKeys: []string{"k"},
+		ModelMappings: []config.AmpModelMapping{
+			{From: "GPT-5", To: "gpt-5-mini"},
+			{From: "^claude-.*", To: "claude-sonnet-4", Regex: true},
+		},
+	}})
+	if got := r.MapModel("a", "gpt-5"); got != "gpt-5-mini" {
+		t.Fatalf("exact mapping: got %q", got)
+	}
+	if got := r.MapModel("a", "claude-opus-4"); got != "claude-sonnet-4" {
+		t.Fatalf("regex mapping: got %q", got)
+	}
+	if got := r.MapModel("b", "gpt-5"); got != "" {
+		t.Fatalf("unknown project should not map, got %q", got)
+	}
+}
+
+func TestRegistry_FilterAuthPinsCredentials(t *testing.T) {
+	r := NewRegistry()
+	r.Configure([]config.Project{
+		{Name: "a", APIKeys: []string{"ka"}, Credentials: []string{"claude-a.json"}},
+		{Name: "b", APIKeys: []string{"kb"}, Credentials: []string{"claude-b"}, CredentialsOnly: true},
+	})
+	pinnedA := &coreauth.Auth{ID: "x", FileName: "/auths/claude-a.json"}
+	pinnedB := &coreauth.Auth{ID: "claude-b"}
+	shared := &coreauth.Auth{ID: "shared"}
+
+	ctxA := WithName(context.Background(), "a")
+	ctxB := WithName(context.Background(), "b")
+	unscoped := context.Background()
+
+	cases := []struct {
+		name    string
+		ctx     context.Context
+		auth    *coreauth.Auth
+		allowed bool
+	}{
+		{"owner uses pinned", ctxA, pinnedA, true},
+		{"other project blocked", ctxB, pinnedA, false},
+		{"unscoped blocked", unscoped, pinnedB, false},
+		{"shared available", ctxA, shared, true},
+		{"credentials-only excludes shared", ctxB, shared, false},
+		{"credentials-only uses own", ctxB, pinnedB, true},
+	}
+	for _, tc := range cases {
+		err := r.FilterAuth(tc.ctx, tc.auth, "")
+		if (err == nil) != tc.allowed {
+			t.Errorf("%s: allowed=%v err=%v", tc.name, tc.allowed, err)
+		}
+	}
+}
+
+type fakeCounter struct {
+	mu     sync.Mutex
+	values map[string]int64
+}
+
+func (f *fakeCounter) Add(_ context.Context, key string, delta int64, _ time.Duration) (int64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.values[key] += delta
+	return f.values[key], nil
+}
+
+func TestRegistry_AdmitSharedCountsOnlyAdmittedRequests(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	counter := &fakeCounter{values: make(map[string]int64)}
+	r := NewRegistry()
+	r.nowFunc = func() time.Time { return now }
+	r.SetCounter(counter)
+	r.Configure([]config.Project{
+		{Name: "a", APIKeys: []string{"key-a"}, Quota: config.ProjectQuota{RequestsPerMinute: 2, DailyRequests: 3}},
+	})
+
+	for i := 0; i < 5; i++ {
+		_, _ = r.Admit("key-a")
+	}
+	if v := counter.values["project:a:rpm:202603011200"]; v != 2 {
+		t.Fatalf("per-minute counter = %d, want 2 admitted requests", v)
+	}
+	now = now.Add(time.Minute)
+	for i := 0; i < 2; i++ {
+		_, _ = r.Admit("key-a")
+	}
+	if v := counter.values["project:a:day:20260301"]; v != 3 {
+		t.Fatalf("daily counter = %d, want 3 admitted requests", v)
+	}
+	if v := counter.values["project:a:rpm:202603011201"]; v != 1 {
+		t.Fatalf("per-minute counter after daily rejection = %d, want 1", v)
+	}
+}
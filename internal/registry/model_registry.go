@@ -9,6 +9,7 @@ import (
 	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	misc "github.com/router-for-me/CLIProxyAPI/v6/internal/misc"
@@ -114,6 +115,8 @@ type ModelRegistry struct {
 	mutex *sync.RWMutex
 	// hook is an optional callback sink for model registration changes
 	hook ModelRegistryHook
+	// generation counts the changes that may alter the available models
+	generation atomic.Uint64
 }
 
 // Global model registry instance
@@ -207,6 +210,7 @@ func (r *ModelRegistry) triggerModelsUnregistered(provider, clientID string) {
 func (r *ModelRegistry) RegisterClient(clientID, clientProvider string, models []*ModelInfo) {
 	r.mutex.Lock()
 	defer r.mutex.Unlock()
+	r.generation.Add(1)
 
 	provider := strings.ToLower(clientProvider)
 	uniqueModelIDs := make([]string, 0, len(models))
@@ -527,6 +531,7 @@ func cloneModelInfosUnique(models []*ModelInfo) []*ModelInfo {
 func (r *ModelRegistry) UnregisterClient(clientID string) {
 	r.mutex.Lock()
 	defer r.mutex.Unlock()
+	r.generation.Add(1)
 	r.unregisterClientInternal(clientID)
 }
 
@@ -594,6 +599,7 @@ func (r *ModelRegistry) unregisterClientInternal(clientID string) {
 func (r *ModelRegistry) SetModelQuotaExceeded(clientID, modelID string) {
 	r.mutex.Lock()
 	defer r.mutex.Unlock()
+	r.generation.Add(1)
 
 	if registration, exists := r.models[modelID]; exists {
 		now := time.Now()
@@ -609,6 +615,7 @@ func (r *ModelRegistry) SetModelQuotaExceeded(clientID, modelID string) {
 func (r *ModelRegistry) ClearModelQuotaExceeded(clientID, modelID string) {
 	r.mutex.Lock()
 	defer r.mutex.Unlock()
+	r.generation.Add(1)
 
 	if registration, exists := r.models[modelID]; exists {
 		delete(registration.QuotaExceededClients, clientID)
@@ -627,6 +634,7 @@ func (r *ModelRegistry) SuspendClientModel(clientID, modelID, reason string) {
 	}
 	r.mutex.Lock()
 	defer r.mutex.Unlock()
+	r.generation.Add(1)
 
 	registration, exists := r.models[modelID]
 	if !exists || registration == nil {
@@ -657,6 +665,7 @@ func (r *ModelRegistry) ResumeClientModel(clientID, modelID string) {
 	}
 	r.mutex.Lock()
 	defer r.mutex.Unlock()
+	r.generation.Add(1)
 
 	registration, exists := r.models[modelID]
 	if !exists || registration == nil || registration.SuspendedClients == nil {
@@ -695,6 +704,15 @@ func (r *ModelRegistry) ClientSupportsModel(clientID, modelID string) bool {
 	return false
 }
 
+// Generation returns a counter that changes whenever registrations, quota or suspension
+// state change, so callers caching model listings can tell when to rebuild them.
+func (r *ModelRegistry) Generation() uint64 {
+	if r == nil {
+		return 0
+	}
+	return r.generation.Load()
+}
+
 // GetAvailableModels returns all models that have at least one available client
 // Parameters:
 //   - handlerType: The handler type to filter models for (e.g., "openai", "claude", "gemini")
@@ -1093,6 +1111,7 @@ func (r *ModelRegistry) convertModelToMap(model *ModelInfo, handlerType string)
 func (r *ModelRegistry) CleanupExpiredQuotas() {
 	r.mutex.Lock()
 	defer r.mutex.Unlock()
+	r.generation.Add(1)
 
 	now := time.Now()
 	quotaExpiredDuration := 5 * time.Minute
@@ -0,0 +1,377 @@
+// Package resume buffers streamed responses so clients can reconnect to an interrupted
+// stream and receive the events they missed.
+package resume
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/spill"
+)
+
+const (
+	// TokenHeader carries the resumption token: set on streamed responses and sent back by
+	// clients reconnecting to the stream.
+	TokenHeader = "X-CLIProxy-Resume-Token"
+	// LastEventHeader is the standard SSE reconnection header. Resuming clients send the
+	// id of the last event they received; the replay starts after it.
+	LastEventHeader = "Last-Event-ID"
+	// ErrorCodeUnknownToken is the error code returned for unknown or expired tokens.
+	ErrorCodeUnknownToken = "resume_token_unknown"
+)
+
+// Store holds the resumable streams.
+type Store struct {
+	mu       sync.Mutex
+	settings config.StreamResumptionConfig
+	streams  map[string]*entry
+	now      func() time.Time
+}
+
+type entry struct {
+	owner  string
+	stream *Stream
+}
+
+var defaultStore = NewStore()
+
+// Default returns the process-wide stream store.
+func Default() *Store { return defaultStore }
+
+// NewStore constructs a disabled store.
+func NewStore() *Store {
+	return &Store{streams: make(map[string]*entry), now: time.Now}
+}
+
+// Configure replaces the settings. Disabling drops every buffered stream.
+func (s *Store) Configure(cfg config.StreamResumptionConfig) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.settings = cfg
+	if !cfg.Enable {
+		for _, e := range s.streams {
+			e.stream.release()
+		}
+		s.streams = make(map[string]*entry)
+	}
+}
+
+// Enabled reports whether stream resumption is on.
+func (s *Store) Enabled() bool {
+	if s == nil {
+		return false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.settings.Enable
+}
+
+// Start registers a new stream of owner and returns it with its token. It returns nil
+// when resumption is disabled or the store is full of running streams.
+func (s *Store) Start(owner string) (*Stream, string) {
+	if s == nil {
+		return nil, ""
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.settings.Enable {
+		return nil, ""
+	}
+	s.evictLocked()
+	if len(s.streams) >= s.maxStreams() {
+		return nil, ""
+	}
+	var raw [16]byte
+	if _, err := rand.Read(raw[:]); err != nil {
+		return nil, ""
+	}
+	token := "rs_" + hex.EncodeToString(raw[:])
+	maxBytes := s.settings.MaxBufferBytes
+	if maxBytes <= 0 {
+		maxBytes = config.DefaultStreamResumptionMaxBytes
+	}
+	stream := &Stream{data: spill.New(), maxBytes: maxBytes, changed: make(chan struct{}), now: s.now}
+	s.streams[token] = &entry{owner: owner, stream: stream}
+	return stream, token
+}
+
+// Lookup returns the stream of token if it belongs to owner and is still resumable.
+func (s *Store) Lookup(owner, token string) (*Stream, bool) {
+	if s == nil || token == "" {
+		return nil, false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.evictLocked()
+	e, ok := s.streams[token]
+	if !ok || e.owner != owner || !e.stream.Resumable() {
+		return nil, false
+	}
+	return e.stream, true
+}
+
+// Drop forgets the stream of token.
+func (s *Store) Drop(token string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	if e, ok := s.streams[token]; ok {
+		e.stream.release()
+		delete(s.streams, token)
+	}
+	s.mu.Unlock()
+}
+
+func (s *Store) maxStreams() int {
+	if s.settings.MaxStreams > 0 {
+		return s.settings.MaxStreams
+	}
+	return config.DefaultStreamResumptionMaxStreams
+}
+
+// evictLocked drops expired and overflowed streams and, when the store is still full, the
+// finished streams that ended first. Running streams are never evicted.
+func (s *Store) evictLocked() {
+	ttl := time.Duration(s.settings.TTLSeconds) * time.Second
+	if ttl <= 0 {
+		ttl = config.DefaultStreamResumptionTTLSeconds * time.Second
+	}
+	now := s.now()
+	for token, e := range s.streams {
+		finished, done := e.stream.finishedAt()
+		if !e.stream.Resumable() || (done && now.Sub(finished) >= ttl) {
+			e.stream.release()
+			delete(s.streams, token)
+		}
+	}
+	for len(s.streams) >= s.maxStreams() {
+		oldestToken := ""
+		var oldest time.Time
+		for token, e := range s.streams {
+			finished, done := e.stream.finishedAt()
+			if done && (oldestToken == "" || finished.Before(oldest)) {
+				oldestToken, oldest = token, finished
+			}
+		}
+		if oldestToken == "" {
+			return
+		}
+		s.streams[oldestToken].stream.release()
+		delete(s.streams, oldestToken)
+	}
+}
+
+// Stream is the buffer of one streamed response, split into SSE events. The events are
+// kept in a spill.Buffer, so large streams move to disk when response spilling is on.
+type Stream struct {
+	mu       sync.Mutex
+	header   http.Header
+	data     *spill.Buffer
+	ends     []int64
+	partial  []byte
+	size     int
+	maxBytes int
+	overflow bool
+	done     bool
+	finished time.Time
+	changed  chan struct{}
+	now      func() time.Time
+}
+
+// SetHeader records the response headers replayed to resuming clients.
+func (st *Stream) SetHeader(header http.Header) {
+	st.mu.Lock()
+	st.header = header.Clone()
+	st.mu.Unlock()
+}
+
+// Header returns the recorded response headers.
+func (st *Stream) Header() http.Header {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	return st.header.Clone()
+}
+
+// Write appends response bytes and returns the bytes to send to the client: complete
+// events carry an "id:" line with their number, so clients can resume with Last-Event-ID,
+// and an incomplete event is held back until it ends. Once the stream exceeded its buffer
+// cap it is no longer resumable; Write then returns false along with the held-back bytes.
+func (st *Stream) Write(p []byte) ([]byte, bool) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if st.overflow {
+		return p, false
+	}
+	if st.size+len(p) > st.maxBytes {
+		pending := append(st.partial, p...)
+		st.overflowLocked()
+		return pending, false
+	}
+	st.size += len(p)
+	st.partial = append(st.partial, p...)
+	var out []byte
+	added := false
+	for {
+		end, sep := eventEnd(st.partial)
+		if end < 0 {
+			break
+		}
+		event := st.partial[:end+sep]
+		st.partial = st.partial[end+sep:]
+		if isComment(event[:end]) {
+			out = append(out, event...)
+			continue
+		}
+		numbered := withEventID(event[:end], len(st.ends)+1, event[end:])
+		rest := st.partial
+		if !st.appendLocked(numbered) {
+			return append(append(out, event...), rest...), false
+		}
+		out = append(out, numbered...)
+		added = true
+	}
+	if len(st.partial) == 0 {
+		st.partial = nil
+	}
+	if added {
+		st.notifyLocked()
+	}
+	return out, true
+}
+
+// Finish marks the stream complete, keeping trailing bytes as a last event. It returns the
+// trailing bytes, which Write held back from the client.
+func (st *Stream) Finish() []byte {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if st.done {
+		return nil
+	}
+	trailing := st.partial
+	if len(bytes.TrimSpace(st.partial)) > 0 && !st.overflow {
+		st.appendLocked(st.partial)
+	}
+	st.partial = nil
+	st.done = true
+	st.finished = st.now()
+	st.notifyLocked()
+	return trailing
+}
+
+// Resumable reports whether the stream still holds all of its events.
+func (st *Stream) Resumable() bool {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	return !st.overflow
+}
+
+// Next returns the events after the first from, that is the events following the one
+// with id from, whether the stream is complete, and a channel closed when more events
+// arrive.
+func (st *Stream) Next(from int) ([][]byte, bool, <-chan struct{}) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if st.overflow {
+		return nil, true, st.changed
+	}
+	var events [][]byte
+	for i := from; i < len(st.ends); i++ {
+		var start int64
+		if i > 0 {
+			start = st.ends[i-1]
+		}
+		event := make([]byte, st.ends[i]-start)
+		if _, err := st.data.ReadAt(event, start); err != nil {
+			st.overflowLocked()
+			return nil, true, st.changed
+		}
+		events = append(events, event)
+	}
+	return events, st.done, st.changed
+}
+
+// appendLocked stores one complete event. Callers must hold st.mu.
+func (st *Stream) appendLocked(event []byte) bool {
+	if _, err := st.data.Write(event); err != nil {
+		st.overflowLocked()
+		return false
+	}
+	st.ends = append(st.ends, st.data.Len())
+	return true
+}
+
+// overflowLocked makes the stream unresumable and drops its events. Callers must hold st.mu.
+func (st *Stream) overflowLocked() {
+	st.overflow = true
+	st.ends, st.partial = nil, nil
+	_ = st.data.Close()
+	st.notifyLocked()
+}
+
+// release drops the buffered events.
+func (st *Stream) release() {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	_ = st.data.Close()
+}
+
+func (st *Stream) finishedAt() (time.Time, bool) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	return st.finished, st.done
+}
+
+func (st *Stream) notifyLocked() {
+	close(st.changed)
+	st.changed = make(chan struct{})
+}
+
+// eventEnd returns the end of the first complete event in buf and the length of the blank
+// line terminating it, or -1.
+func eventEnd(buf []byte) (int, int) {
+	lf := bytes.Index(buf, []byte("\n\n"))
+	crlf := bytes.Index(buf, []byte("\r\n\r\n"))
+	switch {
+	case crlf >= 0 && (lf < 0 || crlf < lf):
+		return crlf, 4
+	case lf >= 0:
+		return lf, 2
+	}
+	return -1, 0
+}
+
+// withEventID appends an "id:" line to the event body. It goes last so it replaces any id
+// the upstream sent.
+func withEventID(body []byte, id int, terminator []byte) []byte {
+	out := make([]byte, 0, len(body)+len(terminator)+16)
+	out = append(out, body...)
+	if bytes.Equal(terminator, []byte("\r\n\r\n")) {
+		out = append(out, "\r\nid: "...)
+	} else {
+		out = append(out, "\nid: "...)
+	}
+	out = strconv.AppendInt(out, int64(id), 10)
+	return append(out, terminator...)
+}
+
+// isComment reports whether an event only holds SSE comments (keep-alives), which clients
+// do not count as events.
+func isComment(event []byte) bool {
+	for _, line := range bytes.Split(event, []byte("\n")) {
+		line = bytes.TrimRight(line, "\r")
+		if len(line) > 0 && line[0] != ':' {
+			return false
+		}
+	}
+	return true
+}
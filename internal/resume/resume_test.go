@@ -0,0 +1,59 @@
+package resume
+
+import (
+	"testing"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+func TestStoreExpiresAndCapsStreams(t *testing.T) {
+	now := time.Unix(1000, 0)
+	store := NewStore()
+	store.now = func() time.Time { return now }
+	store.Configure(config.StreamResumptionConfig{Enable: true, TTLSeconds: 60, MaxBufferBytes: 16, MaxStreams: 2})
+
+	big, bigToken := store.Start("k")
+	if _, ok := big.Write([]byte("data: 0123456789abcdef\n\n")); ok {
+		t.Fatal("write over the buffer cap should overflow")
+	}
+	if _, ok := store.Lookup("k", bigToken); ok {
+		t.Fatal("overflowed stream should not be resumable")
+	}
+
+	stream, token := store.Start("k")
+	stream.Write([]byte("data: a\n\n"))
+	stream.Finish()
+	if _, ok := store.Lookup("k", token); !ok {
+		t.Fatal("finished stream should be resumable within the ttl")
+	}
+	now = now.Add(61 * time.Second)
+	if _, ok := store.Lookup("k", token); ok {
+		t.Fatal("stream should expire after the ttl")
+	}
+
+	store.Start("k")
+	store.Start("k")
+	if extra, _ := store.Start("k"); extra != nil {
+		t.Fatal("store full of running streams should refuse new ones")
+	}
+}
+
+func TestStreamNumbersEvents(t *testing.T) {
+	store := NewStore()
+	store.Configure(config.StreamResumptionConfig{Enable: true, MaxBufferBytes: 1 << 10})
+	stream, _ := store.Start("k")
+	if out, _ := stream.Write([]byte("data: a\n\n: ping\n\ndata: b")); string(out) != "data: a\nid: 1\n\n: ping\n\n" {
+		t.Fatalf("first write = %q", out)
+	}
+	if out, _ := stream.Write([]byte("\r\n\r\ndata: c")); string(out) != "data: b\r\nid: 2\r\n\r\n" {
+		t.Fatalf("second write = %q", out)
+	}
+	if trailing := stream.Finish(); string(trailing) != "data: c" {
+		t.Fatalf("trailing = %q", trailing)
+	}
+	events, done, _ := stream.Next(1)
+	if !done || len(events) != 2 || string(events[0]) != "data: b\r\nid: 2\r\n\r\n" || string(events[1]) != "data: c" {
+		t.Fatalf("events after id 1 = %q, done = %v", events, done)
+	}
+}
@@ -0,0 +1,77 @@
+// Package routeinfo exposes the routing decision made for a request to the handlers and
+// middleware running after it, so they can act on it without re-parsing the request body.
+package routeinfo
+
+import (
+	"context"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RouteType classifies how a request is served.
+type RouteType string
+
+const (
+	// LocalProvider means a local provider serves the requested model.
+	LocalProvider RouteType = "LOCAL_PROVIDER"
+	// ModelMapping means the request was remapped to another model served by a local provider.
+	ModelMapping RouteType = "MODEL_MAPPING"
+	// AmpCredits means the request is forwarded to ampcode.com and uses Amp credits.
+	AmpCredits RouteType = "AMP_CREDITS"
+	// NoProvider means neither a local provider nor a fallback can serve the request.
+	NoProvider RouteType = "NO_PROVIDER"
+)
+
+// Info describes the routing decision of a request.
+type Info struct {
+	// RequestedModel is the model named by the client.
+	RequestedModel string `json:"requested_model"`
+	// ResolvedModel is the model actually served; it differs from RequestedModel after a
+	// model mapping.
+	ResolvedModel string `json:"resolved_model,omitempty"`
+	// RouteType is how the request is served.
+	RouteType RouteType `json:"route_type"`
+	// Provider is the first local provider serving ResolvedModel, if any.
+	Provider string `json:"provider,omitempty"`
+}
+
+// Mapped reports whether the request was remapped to another model.
+func (i Info) Mapped() bool {
+	return i.RouteType == ModelMapping && i.ResolvedModel != "" && i.ResolvedModel != i.RequestedModel
+}
+
+// contextKey is the gin context key holding the Info of a request.
+const contextKey = "cliproxy_route_info"
+
+// Set records info as the routing decision of the request, replacing an earlier one.
+func Set(c *gin.Context, info Info) {
+	if c != nil {
+		c.Set(contextKey, info)
+	}
+}
+
+// Get returns the routing decision of the request.
+func Get(c *gin.Context) (Info, bool) {
+	if c == nil {
+		return Info{}, false
+	}
+	value, exists := c.Get(contextKey)
+	if !exists {
+		return Info{}, false
+	}
+	info, ok := value.(Info)
+	return info, ok
+}
+
+// FromContext returns the routing decision of the request whose gin context is stored
+// under "gin" in ctx, as done by the API handlers.
+func FromContext(ctx context.Context) (Info, bool) {
+	if ctx == nil {
+		return Info{}, false
+	}
+	ginCtx, ok := ctx.Value("gin").(*gin.Context)
+	if !ok {
+		return Info{}, false
+	}
+	return Get(ginCtx)
+}
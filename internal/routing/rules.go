@@ -0,0 +1,335 @@
+// Package routing evaluates the declarative routing.rules configuration.
+package routing
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+)
+
+const (
+	// TagsHeader carries comma separated client tags matched by rule tag conditions.
+	TagsHeader = "X-Routing-Tags"
+	// RuleContextKey is the gin context key holding the name of the matched rule.
+	RuleContextKey = "routing_rule"
+	// ProviderContextKey is the gin context key holding the provider pinned by a rule.
+	ProviderContextKey = "routing_provider"
+	// CredentialsContextKey is the gin context key holding the credentials pinned by a rule.
+	CredentialsContextKey = "routing_credentials"
+	// GroupContextKey is the gin context key holding the provider group pinned by a rule.
+	GroupContextKey = "routing_group"
+	// ForceAmpContextKey is the gin context key set when a rule forces the Amp upstream.
+	ForceAmpContextKey = "routing_force_amp"
+	// ErrorCodeProviderPinned is the coreauth.Error code returned for credentials of a
+	// provider other than the one pinned by a routing rule.
+	ErrorCodeProviderPinned = "routing_provider_pinned"
+	// ErrorCodeCredentialsPinned is the coreauth.Error code returned for credentials other
+	// than those pinned by a routing rule.
+	ErrorCodeCredentialsPinned = "routing_credentials_pinned"
+	// ErrorCodeGroupPinned is the coreauth.Error code returned for credentials outside the
+	// provider group pinned by a routing rule.
+	ErrorCodeGroupPinned = "routing_group_pinned"
+)
+
+// Request describes the attributes of a request that rules can match.
+type Request struct {
+	Model  string
+	Path   string
+	APIKey string
+	Header http.Header
+	Tags   []string
+}
+
+// Engine holds the compiled routing rules.
+type Engine struct {
+	mu    sync.RWMutex
+	rules []config.RoutingRule
+	trace atomic.Bool
+}
+
+var defaultEngine = NewEngine()
+
+// Default returns the process-wide routing engine.
+func Default() *Engine { return defaultEngine }
+
+// NewEngine constructs an engine without rules.
+func NewEngine() *Engine { return &Engine{} }
+
+// Configure replaces the rule set.
+func (e *Engine) Configure(rules []config.RoutingRule) {
+	if e == nil {
+		return
+	}
+	e.mu.Lock()
+	e.rules = append([]config.RoutingRule(nil), rules...)
+	e.mu.Unlock()
+}
+
+// SetTraceEnabled toggles the route trace response header.
+func (e *Engine) SetTraceEnabled(enabled bool) {
+	if e != nil {
+		e.trace.Store(enabled)
+	}
+}
+
+// TraceEnabled reports whether routing decisions are traced.
+func (e *Engine) TraceEnabled() bool {
+	return e != nil && e.trace.Load()
+}
+
+// Enabled reports whether any rule is configured.
+func (e *Engine) Enabled() bool {
+	if e == nil {
+		return false
+	}
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return len(e.rules) > 0
+}
+
+// Match returns the first rule whose conditions all hold for req.
+func (e *Engine) Match(req Request) (config.RoutingRule, bool) {
+	rule, ok, _ := e.evaluate(req, false)
+	return rule, ok
+}
+
+// Explain is Match that also describes, for every rule evaluated, why it matched or not.
+func (e *Engine) Explain(req Request) (config.RoutingRule, bool, []string) {
+	return e.evaluate(req, true)
+}
+
+func (e *Engine) evaluate(req Request, explain bool) (config.RoutingRule, bool, []string) {
+	if e == nil {
+		return config.RoutingRule{}, false, nil
+	}
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	var trace []string
+	for _, rule := range e.rules {
+		reason := mismatch(rule.Match, req)
+		if reason == "" {
+			if explain {
+				trace = append(trace, fmt.Sprintf("rule %s matched (%s) -> %s", rule.Name, describeMatch(rule.Match), describeAction(rule.Action)))
+			}
+			return rule, true, trace
+		}
+		if explain {
+			trace = append(trace, fmt.Sprintf("rule %s skipped: %s", rule.Name, reason))
+		}
+	}
+	return config.RoutingRule{}, false, trace
+}
+
+// mismatch returns why req fails the conditions of m, or "" when it matches.
+func mismatch(m config.RoutingRuleMatch, req Request) string {
+	if m.Model != "" && !util.MatchWildcard(m.Model, req.Model) {
+		return fmt.Sprintf("model %q does not match %q", req.Model, m.Model)
+	}
+	if m.Path != "" && !util.MatchWildcard(m.Path, req.Path) {
+		return fmt.Sprintf("path %q does not match %q", req.Path, m.Path)
+	}
+	for name, pattern := range m.Headers {
+		if value := req.Header.Get(name); !util.MatchWildcard(pattern, value) {
+			return fmt.Sprintf("header %s %q does not match %q", name, value, pattern)
+		}
+	}
+	if len(m.APIKeys) > 0 && !containsString(m.APIKeys, req.APIKey, false) {
+		return "api key not listed"
+	}
+	if len(m.Tags) > 0 {
+		for _, tag := range req.Tags {
+			if containsString(m.Tags, tag, true) {
+				return ""
+			}
+		}
+		return fmt.Sprintf("tags %v do not include any of %v", req.Tags, m.Tags)
+	}
+	return ""
+}
+
+func describeMatch(m config.RoutingRuleMatch) string {
+	var conditions []string
+	if m.Model != "" {
+		conditions = append(conditions, "model "+m.Model)
+	}
+	if m.Path != "" {
+		conditions = append(conditions, "path "+m.Path)
+	}
+	headers := make([]string, 0, len(m.Headers))
+	for name := range m.Headers {
+		headers = append(headers, "header "+name)
+	}
+	sort.Strings(headers)
+	conditions = append(conditions, headers...)
+	if len(m.APIKeys) > 0 {
+		conditions = append(conditions, "api key")
+	}
+	if len(m.Tags) > 0 {
+		conditions = append(conditions, fmt.Sprintf("tags %v", m.Tags))
+	}
+	if len(conditions) == 0 {
+		return "no conditions"
+	}
+	return strings.Join(conditions, ", ")
+}
+
+func describeAction(a config.RoutingRuleAction) string {
+	if a.Deny {
+		return "deny"
+	}
+	var actions []string
+	if a.MapModel != "" {
+		actions = append(actions, "map-model "+a.MapModel)
+	}
+	if a.PinProvider != "" {
+		actions = append(actions, "pin-provider "+a.PinProvider)
+	}
+	if len(a.PinCredentials) > 0 {
+		actions = append(actions, fmt.Sprintf("pin-credentials %v", a.PinCredentials))
+	}
+	if a.PinGroup != "" {
+		actions = append(actions, "pin-group "+a.PinGroup)
+	}
+	if a.ForceAmp {
+		actions = append(actions, "force-amp")
+	}
+	return strings.Join(actions, ", ")
+}
+
+func containsString(values []string, value string, foldCase bool) bool {
+	for _, candidate := range values {
+		if candidate == value || (foldCase && strings.EqualFold(candidate, value)) {
+			return true
+		}
+	}
+	return false
+}
+
+type providerContextKey struct{}
+
+// WithProvider returns a context carrying the provider pinned by a routing rule.
+func WithProvider(ctx context.Context, provider string) context.Context {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return context.WithValue(ctx, providerContextKey{}, provider)
+}
+
+// ProviderFromContext returns the pinned provider attached to ctx, either directly or via
+// the gin context stored under "gin" by the API handlers.
+func ProviderFromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	if provider, ok := ctx.Value(providerContextKey{}).(string); ok && provider != "" {
+		return provider
+	}
+	if ginCtx, ok := ctx.Value("gin").(*gin.Context); ok && ginCtx != nil {
+		return ginCtx.GetString(ProviderContextKey)
+	}
+	return ""
+}
+
+type credentialsContextKey struct{}
+
+// WithCredentials returns a context carrying the credentials pinned by a routing rule.
+func WithCredentials(ctx context.Context, credentials []string) context.Context {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return context.WithValue(ctx, credentialsContextKey{}, credentials)
+}
+
+// CredentialsFromContext returns the pinned credentials attached to ctx, either directly
+// or via the gin context stored under "gin" by the API handlers.
+func CredentialsFromContext(ctx context.Context) []string {
+	if ctx == nil {
+		return nil
+	}
+	if credentials, ok := ctx.Value(credentialsContextKey{}).([]string); ok && len(credentials) > 0 {
+		return credentials
+	}
+	if ginCtx, ok := ctx.Value("gin").(*gin.Context); ok && ginCtx != nil {
+		if value, exists := ginCtx.Get(CredentialsContextKey); exists {
+			credentials, _ := value.([]string)
+			return credentials
+		}
+	}
+	return nil
+}
+
+type groupContextKey struct{}
+
+// WithGroup returns a context carrying the provider group pinned by a routing rule.
+func WithGroup(ctx context.Context, group string) context.Context {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return context.WithValue(ctx, groupContextKey{}, group)
+}
+
+// GroupFromContext returns the pinned provider group attached to ctx, either directly or
+// via the gin context stored under "gin" by the API handlers.
+func GroupFromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	if group, ok := ctx.Value(groupContextKey{}).(string); ok && group != "" {
+		return group
+	}
+	if ginCtx, ok := ctx.Value("gin").(*gin.Context); ok && ginCtx != nil {
+		return ginCtx.GetString(GroupContextKey)
+	}
+	return ""
+}
+
+// credentialPinned reports whether auth is one of credentials, by ID or auth file name.
+func credentialPinned(credentials []string, auth *coreauth.Auth) bool {
+	for _, id := range credentials {
+		if id == auth.ID || (auth.FileName != "" && id == filepath.Base(auth.FileName)) {
+			return true
+		}
+	}
+	return false
+}
+
+// FilterAuth implements coreauth.AuthFilter. Requests pinned to a provider, a provider group
+// or credentials by a routing rule only see those credentials.
+func (e *Engine) FilterAuth(ctx context.Context, auth *coreauth.Auth, _ string) error {
+	if auth == nil {
+		return nil
+	}
+	if credentials := CredentialsFromContext(ctx); len(credentials) > 0 && !credentialPinned(credentials, auth) {
+		return &coreauth.Error{
+			Code:       ErrorCodeCredentialsPinned,
+			Message:    fmt.Sprintf("routing rule pins credentials %v", credentials),
+			HTTPStatus: http.StatusServiceUnavailable,
+		}
+	}
+	if group := GroupFromContext(ctx); group != "" && !strings.EqualFold(coreauth.AuthGroup(auth), group) {
+		return &coreauth.Error{
+			Code:       ErrorCodeGroupPinned,
+			Message:    fmt.Sprintf("routing rule pins provider group %q", group),
+			HTTPStatus: http.StatusServiceUnavailable,
+		}
+	}
+	pinned := ProviderFromContext(ctx)
+	if pinned == "" || strings.EqualFold(auth.Provider, pinned) {
+		return nil
+	}
+	return &coreauth.Error{
+		Code:       ErrorCodeProviderPinned,
+		Message:    fmt.Sprintf("routing rule pins provider %q", pinned),
+		HTTPStatus: http.StatusServiceUnavailable,
+	}
+}
@@ -0,0 +1,132 @@
+package routing
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+)
+
+func TestEngine_MatchFirstRuleWins(t *testing.T) {
+	engine := NewEngine()
+	engine.Configure([]config.RoutingRule{
+		{Name: "deny-interns", Match: config.RoutingRuleMatch{APIKeys: []string{"sk-intern"}, Model: "claude-opus-*"}, Action: config.RoutingRuleAction{Deny: true}},
+		{Name: "batch", Match: config.RoutingRuleMatch{Tags: []string{"batch"}, Headers: map[string]string{"User-Agent": "*cron*"}}, Action: config.RoutingRuleAction{PinProvider: "gemini-cli"}},
+		{Name: "amp-opus", Match: config.RoutingRuleMatch{Path: "/api/provider/*", Model: "CLAUDE-OPUS-*"}, Action: config.RoutingRuleAction{ForceAmp: true}},
+	})
+
+	cases := []struct {
+		req  Request
+		want string
+	}{
+		{Request{Model: "claude-opus-4-5", APIKey: "sk-intern", Path: "/v1/messages"}, "deny-interns"},
+		{Request{Model: "claude-opus-4-5", APIKey: "sk-staff", Path: "/api/provider/anthropic/v1/messages"}, "amp-opus"},
+		{Request{Model: "gpt-5", Tags: []string{"Batch"}, Header: http.Header{"User-Agent": {"nightly-cron/1.0"}}}, "batch"},
+		{Request{Model: "gpt-5", Tags: []string{"batch"}, Header: http.Header{"User-Agent": {"curl"}}}, ""},
+		{Request{Model: "claude-sonnet-4-5", Path: "/api/provider/anthropic/v1/messages"}, ""},
+	}
+	for _, tc := range cases {
+		rule, ok := engine.Match(tc.req)
+		got := ""
+		if ok {
+			got = rule.Name
+		}
+		if got != tc.want {
+			t.Errorf("Match(%+v) = %q, want %q", tc.req, got, tc.want)
+		}
+	}
+}
+
+func TestEngine_FilterAuthHonoursPinnedProvider(t *testing.T) {
+	engine := NewEngine()
+	claude := &coreauth.Auth{ID: "claude.json", Provider: "claude"}
+	gemini := &coreauth.Auth{ID: "gemini.json", Provider: "gemini-cli"}
+
+	if err := engine.FilterAuth(context.Background(), gemini, "m"); err != nil {
+		t.Fatalf("unpinned request filtered: %v", err)
+	}
+	ctx := WithProvider(context.Background(), "claude")
+	if err := engine.FilterAuth(ctx, claude, "m"); err != nil {
+		t.Fatalf("pinned provider filtered: %v", err)
+	}
+	err := engine.FilterAuth(ctx, gemini, "m")
+	var authErr *coreauth.Error
+	if !errors.As(err, &authErr) || authErr.Code != ErrorCodeProviderPinned {
+		t.Fatalf("FilterAuth(other provider) = %v, want %s", err, ErrorCodeProviderPinned)
+	}
+}
+
+func TestEngine_FilterAuthHonoursPinnedCredentials(t *testing.T) {
+	engine := NewEngine()
+	teamA := &coreauth.Auth{ID: "a", Provider: "claude", FileName: "/auths/claude-team-a.json"}
+	teamB := &coreauth.Auth{ID: "b", Provider: "claude"}
+
+	ctx := WithCredentials(context.Background(), []string{"claude-team-a.json"})
+	if err := engine.FilterAuth(ctx, teamA, "m"); err != nil {
+		t.Fatalf("pinned credential filtered: %v", err)
+	}
+	err := engine.FilterAuth(ctx, teamB, "m")
+	var authErr *coreauth.Error
+	if !errors.As(err, &authErr) || authErr.Code != ErrorCodeCredentialsPinned {
+		t.Fatalf("FilterAuth(other credential) = %v, want %s", err, ErrorCodeCredentialsPinned)
+	}
+	if err := engine.FilterAuth(WithCredentials(context.Background(), []string{"b"}), teamB, "m"); err != nil {
+		t.Fatalf("credential pinned by ID filtered: %v", err)
+	}
+}
+
+func TestEngine_ExplainAndTrace(t *testing.T) {
+	engine := NewEngine()
+	engine.Configure([]config.RoutingRule{
+		{Name: "opus", Match: config.RoutingRuleMatch{Model: "claude-opus-*"}, Action: config.RoutingRuleAction{MapModel: "claude-sonnet-4-5"}},
+		{Name: "catch-all", Action: config.RoutingRuleAction{PinProvider: "claude"}},
+	})
+	_, ok, trace := engine.Explain(Request{Model: "gpt-5"})
+	if !ok || len(trace) != 2 {
+		t.Fatalf("Explain = %v, %v", ok, trace)
+	}
+	if trace[0] != `rule opus skipped: model "gpt-5" does not match "claude-opus-*"` {
+		t.Fatalf("trace[0] = %q", trace[0])
+	}
+	if trace[1] != "rule catch-all matched (no conditions) -> pin-provider claude" {
+		t.Fatalf("trace[1] = %q", trace[1])
+	}
+
+	gin.SetMode(gin.TestMode)
+	defer Default().SetTraceEnabled(false)
+	for _, enabled := range []bool{false, true} {
+		Default().SetTraceEnabled(enabled)
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+		Trace(c, "amp: local provider %s for %s", "claude", "claude-sonnet-4-5")
+		got := w.Header().Values(TraceHeader)
+		if enabled && (len(got) != 1 || got[0] != "amp: local provider claude for claude-sonnet-4-5") {
+			t.Fatalf("trace header = %v", got)
+		}
+		if !enabled && len(got) != 0 {
+			t.Fatalf("trace header set while disabled: %v", got)
+		}
+	}
+}
+
+func TestEngine_FilterAuthHonoursPinnedGroup(t *testing.T) {
+	engine := NewEngine()
+	member := &coreauth.Auth{ID: "a", Provider: "claude", Attributes: map[string]string{coreauth.GroupAttribute: "team-a"}}
+	other := &coreauth.Auth{ID: "b", Provider: "codex"}
+
+	ctx := WithGroup(context.Background(), "Team-A")
+	if err := engine.FilterAuth(ctx, member, "m"); err != nil {
+		t.Fatalf("group member filtered: %v", err)
+	}
+	err := engine.FilterAuth(ctx, other, "m")
+	var authErr *coreauth.Error
+	if !errors.As(err, &authErr) || authErr.Code != ErrorCodeGroupPinned {
+		t.Fatalf("FilterAuth(non-member) = %v, want %s", err, ErrorCodeGroupPinned)
+	}
+}
@@ -0,0 +1,23 @@
+package routing
+
+import (
+	"fmt"
+
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+)
+
+// TraceHeader lists the routing decisions taken for a request, one value per decision,
+// when routing.trace is enabled.
+const TraceHeader = "X-CLIProxy-Route-Trace"
+
+// Trace records a routing decision for the request: it is added to the response as a
+// TraceHeader value and logged at debug level. It is a no-op unless tracing is enabled.
+func Trace(c *gin.Context, format string, args ...any) {
+	if c == nil || !Default().TraceEnabled() {
+		return
+	}
+	entry := fmt.Sprintf(format, args...)
+	c.Writer.Header().Add(TraceHeader, entry)
+	log.Debugf("route trace %s %s: %s", c.Request.Method, c.Request.URL.Path, entry)
+}
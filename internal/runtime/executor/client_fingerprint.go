@@ -0,0 +1,104 @@
+package executor
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/buildinfo"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	cliproxyauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+)
+
+const (
+	claudeCLIUserAgent = "claude-cli/1.0.83 (external, cli)"
+	codexCLIUserAgent  = "codex_cli_rs/0.50.0 (Mac OS 26.0.1; arm64) Apple_Terminal/464"
+	geminiCLIUserAgent = "google-api-nodejs-client/9.15.1"
+	geminiCLIAPIClient = "gl-node/22.17.0"
+)
+
+// builtinClientFingerprints present the OAuth providers as their official CLIs when
+// client-fingerprint is enabled.
+var builtinClientFingerprints = map[string]config.ClientFingerprint{
+	"claude":      {UserAgent: claudeCLIUserAgent, Headers: map[string]string{"X-App": "cli"}},
+	"codex":       {UserAgent: codexCLIUserAgent},
+	"gemini-cli":  {UserAgent: geminiCLIUserAgent, Headers: map[string]string{"X-Goog-Api-Client": geminiCLIAPIClient}},
+	"qwen":        {UserAgent: qwenUserAgent},
+	"iflow":       {UserAgent: iflowUserAgent},
+	"antigravity": {UserAgent: defaultAntigravityAgent},
+}
+
+// defaultUserAgent replaces the Go default User-Agent of requests that set none.
+func defaultUserAgent() string {
+	return "CLIProxyAPI/" + buildinfo.Version
+}
+
+// resolveClientFingerprint returns the fingerprint presented for auth: the built-in one of
+// its provider merged with the configured one, and the credential User-Agent on top.
+func resolveClientFingerprint(cfg *config.Config, auth *cliproxyauth.Auth) config.ClientFingerprint {
+	if cfg == nil || !cfg.ClientFingerprint.Enable || auth == nil {
+		return config.ClientFingerprint{}
+	}
+	provider := strings.ToLower(auth.Provider)
+	builtin := builtinClientFingerprints[provider]
+	custom := cfg.ClientFingerprint.Providers[provider]
+	out := config.ClientFingerprint{UserAgent: builtin.UserAgent, Headers: make(map[string]string, len(builtin.Headers)+len(custom.Headers))}
+	for name, value := range builtin.Headers {
+		out.Headers[name] = value
+	}
+	for name, value := range custom.Headers {
+		out.Headers[name] = value
+	}
+	if custom.UserAgent != "" {
+		out.UserAgent = custom.UserAgent
+	}
+	if ua := credentialUserAgent(auth); ua != "" {
+		out.UserAgent = ua
+	}
+	return out
+}
+
+// credentialUserAgent returns the "user_agent" attribute or metadata field of auth.
+func credentialUserAgent(auth *cliproxyauth.Auth) string {
+	if auth == nil {
+		return ""
+	}
+	if ua := strings.TrimSpace(auth.Attributes["user_agent"]); ua != "" {
+		return ua
+	}
+	if ua, ok := auth.Metadata["user_agent"].(string); ok {
+		return strings.TrimSpace(ua)
+	}
+	return ""
+}
+
+// withClientFingerprint wraps base so every request presents the fingerprint of auth and
+// none goes out with the Go default User-Agent.
+func withClientFingerprint(base http.RoundTripper, cfg *config.Config, auth *cliproxyauth.Auth) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &clientFingerprintRoundTripper{base: base, fingerprint: resolveClientFingerprint(cfg, auth)}
+}
+
+type clientFingerprintRoundTripper struct {
+	base        http.RoundTripper
+	fingerprint config.ClientFingerprint
+}
+
+func (rt *clientFingerprintRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	fp := rt.fingerprint
+	if fp.UserAgent == "" && len(fp.Headers) == 0 && req.Header.Get("User-Agent") != "" {
+		return rt.base.RoundTrip(req)
+	}
+	out := req.Clone(req.Context())
+	for name, value := range fp.Headers {
+		out.Header.Set(name, value)
+	}
+	switch {
+	case fp.UserAgent != "":
+		out.Header.Set("User-Agent", fp.UserAgent)
+	case out.Header.Get("User-Agent") == "":
+		out.Header.Set("User-Agent", defaultUserAgent())
+	}
+	return rt.base.RoundTrip(out)
+}
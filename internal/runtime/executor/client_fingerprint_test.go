@@ -0,0 +1,63 @@
+package executor
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	cliproxyauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+)
+
+func TestClientFingerprint(t *testing.T) {
+	var seen http.Header
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = r.Header.Clone()
+	}))
+	defer upstream.Close()
+
+	send := func(cfg *config.Config, auth *cliproxyauth.Auth, userAgent string) http.Header {
+		t.Helper()
+		client := newProxyAwareHTTPClient(context.Background(), cfg, auth, 0)
+		req, _ := http.NewRequest(http.MethodPost, upstream.URL, nil)
+		if userAgent != "" {
+			req.Header.Set("User-Agent", userAgent)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = resp.Body.Close()
+		return seen
+	}
+
+	disabled := &config.Config{}
+	if got := send(disabled, &cliproxyauth.Auth{Provider: "gemini"}, "").Get("User-Agent"); got != defaultUserAgent() {
+		t.Fatalf("default User-Agent = %q", got)
+	}
+	if got := send(disabled, &cliproxyauth.Auth{Provider: "claude"}, "my-client/2").Get("User-Agent"); got != "my-client/2" {
+		t.Fatalf("disabled fingerprint changed User-Agent to %q", got)
+	}
+
+	cfg := &config.Config{ClientFingerprint: config.ClientFingerprintConfig{
+		Enable: true,
+		Providers: map[string]config.ClientFingerprint{
+			" Claude ":  {Headers: map[string]string{"x-stainless-os": "Linux", "Host": "evil"}},
+			"my-compat": {Headers: map[string]string{"User-Agent": "compat/1"}},
+		},
+	}}
+	cfg.SanitizeClientFingerprint()
+
+	headers := send(cfg, &cliproxyauth.Auth{Provider: "claude"}, "my-client/2")
+	if headers.Get("User-Agent") != claudeCLIUserAgent || headers.Get("X-App") != "cli" || headers.Get("X-Stainless-Os") != "Linux" {
+		t.Fatalf("claude fingerprint = %v", headers)
+	}
+	if got := send(cfg, &cliproxyauth.Auth{Provider: "my-compat"}, "").Get("User-Agent"); got != "compat/1" {
+		t.Fatalf("custom fingerprint User-Agent = %q", got)
+	}
+	auth := &cliproxyauth.Auth{Provider: "codex", Attributes: map[string]string{"user_agent": "pinned/1"}}
+	if got := send(cfg, auth, "").Get("User-Agent"); got != "pinned/1" {
+		t.Fatalf("credential User-Agent = %q", got)
+	}
+}
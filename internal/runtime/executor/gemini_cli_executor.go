@@ -133,7 +133,9 @@ func (e *GeminiCLIExecutor) Execute(ctx context.Context, auth *cliproxyauth.Auth
 
 	basePayload = fixGeminiCLIImageAspectRatio(baseModel, basePayload)
 	requestedModel := payloadRequestedModel(opts, req.Model)
+	basePayload = applyGeminiDefaults(e.cfg, baseModel, "request", basePayload, requestedModel)
 	basePayload = applyPayloadConfigWithRoot(e.cfg, baseModel, "gemini", "request", basePayload, originalTranslated, requestedModel)
+	basePayload = applyGenerationPolicy(ctx, e.cfg, baseModel, "gemini", "request", basePayload, requestedModel)
 
 	action := "generateContent"
 	if req.Metadata != nil {
@@ -176,7 +178,7 @@ func (e *GeminiCLIExecutor) Execute(ctx context.Context, auth *cliproxyauth.Auth
 		}
 		updateGeminiCLITokenMetadata(auth, baseTokenData, tok)
 
-		url := fmt.Sprintf("%s/%s:%s", codeAssistEndpoint, codeAssistVersion, action)
+		url := fmt.Sprintf("%s/%s:%s", resolveCodeAssistEndpoint(auth), codeAssistVersion, action)
 		if opts.Alt != "" && action != "countTokens" {
 			url = url + fmt.Sprintf("?$alt=%s", opts.Alt)
 		}
@@ -286,7 +288,9 @@ func (e *GeminiCLIExecutor) ExecuteStream(ctx context.Context, auth *cliproxyaut
 
 	basePayload = fixGeminiCLIImageAspectRatio(baseModel, basePayload)
 	requestedModel := payloadRequestedModel(opts, req.Model)
+	basePayload = applyGeminiDefaults(e.cfg, baseModel, "request", basePayload, requestedModel)
 	basePayload = applyPayloadConfigWithRoot(e.cfg, baseModel, "gemini", "request", basePayload, originalTranslated, requestedModel)
+	basePayload = applyGenerationPolicy(ctx, e.cfg, baseModel, "gemini", "request", basePayload, requestedModel)
 
 	projectID := resolveGeminiProjectID(auth)
 
@@ -318,7 +322,7 @@ func (e *GeminiCLIExecutor) ExecuteStream(ctx context.Context, auth *cliproxyaut
 		}
 		updateGeminiCLITokenMetadata(auth, baseTokenData, tok)
 
-		url := fmt.Sprintf("%s/%s:%s", codeAssistEndpoint, codeAssistVersion, "streamGenerateContent")
+		url := fmt.Sprintf("%s/%s:%s", resolveCodeAssistEndpoint(auth), codeAssistVersion, "streamGenerateContent")
 		if opts.Alt == "" {
 			url = url + "?alt=sse"
 		} else {
@@ -503,7 +507,7 @@ func (e *GeminiCLIExecutor) CountTokens(ctx context.Context, auth *cliproxyauth.
 		}
 		updateGeminiCLITokenMetadata(auth, baseTokenData, tok)
 
-		url := fmt.Sprintf("%s/%s:%s", codeAssistEndpoint, codeAssistVersion, "countTokens")
+		url := fmt.Sprintf("%s/%s:%s", resolveCodeAssistEndpoint(auth), codeAssistVersion, "countTokens")
 		if opts.Alt != "" {
 			url = url + fmt.Sprintf("?$alt=%s", opts.Alt)
 		}
@@ -694,6 +698,17 @@ func resolveGeminiProjectID(auth *cliproxyauth.Auth) string {
 	return strings.TrimSpace(stringValue(auth.Metadata, "project_id"))
 }
 
+// resolveCodeAssistEndpoint returns the base_url override of the auth, or the public Code
+// Assist endpoint.
+func resolveCodeAssistEndpoint(auth *cliproxyauth.Auth) string {
+	if auth != nil && auth.Attributes != nil {
+		if custom := strings.TrimSpace(auth.Attributes["base_url"]); custom != "" {
+			return strings.TrimRight(custom, "/")
+		}
+	}
+	return codeAssistEndpoint
+}
+
 func geminiOAuthMetadata(auth *cliproxyauth.Auth) map[string]any {
 	if auth == nil {
 		return nil
@@ -743,8 +758,8 @@ func applyGeminiCLIHeaders(r *http.Request) {
 		ginHeaders = ginCtx.Request.Header
 	}
 
-	misc.EnsureHeader(r.Header, ginHeaders, "User-Agent", "google-api-nodejs-client/9.15.1")
-	misc.EnsureHeader(r.Header, ginHeaders, "X-Goog-Api-Client", "gl-node/22.17.0")
+	misc.EnsureHeader(r.Header, ginHeaders, "User-Agent", geminiCLIUserAgent)
+	misc.EnsureHeader(r.Header, ginHeaders, "X-Goog-Api-Client", geminiCLIAPIClient)
 	misc.EnsureHeader(r.Header, ginHeaders, "Client-Metadata", geminiCLIClientMetadata())
 }
 
@@ -0,0 +1,88 @@
+package executor
+
+import (
+	"strings"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// applyGeminiDefaults adds the configured safety settings and generationConfig fields the
+// Gemini payload lacks. root is the path of the request object in the payload ("request"
+// for Gemini CLI and Antigravity). It runs before the payload rules, which may still
+// override the result.
+func applyGeminiDefaults(cfg *config.Config, model, root string, payload []byte, requestedModel string) []byte {
+	if cfg == nil || len(cfg.GeminiDefaults.Rules) == 0 || len(payload) == 0 {
+		return payload
+	}
+	candidates := payloadModelCandidates(model, requestedModel)
+	out := payload
+	for _, rule := range cfg.GeminiDefaults.Rules {
+		if !geminiDefaultsRuleMatches(rule.Models, candidates) {
+			continue
+		}
+		out = addSafetySettings(out, buildPayloadPath(root, "safetySettings"), rule.SafetySettings)
+		out = addGenerationDefaults(out, buildPayloadPath(root, "generationConfig"), rule.GenerationConfig)
+	}
+	return out
+}
+
+func geminiDefaultsRuleMatches(patterns []string, candidates []string) bool {
+	for _, candidate := range candidates {
+		for _, pattern := range patterns {
+			if matchModelPattern(pattern, candidate) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// addSafetySettings appends the settings whose category the list at path does not set yet.
+func addSafetySettings(payload []byte, path string, settings []config.GeminiSafetySetting) []byte {
+	if len(settings) == 0 {
+		return payload
+	}
+	present := make(map[string]bool)
+	gjson.GetBytes(payload, path).ForEach(func(_, setting gjson.Result) bool {
+		present[strings.ToUpper(setting.Get("category").String())] = true
+		return true
+	})
+	for _, setting := range settings {
+		if present[setting.Category] {
+			continue
+		}
+		entry := map[string]string{"category": setting.Category, "threshold": setting.Threshold}
+		if updated, err := sjson.SetBytes(payload, path+".-1", entry); err == nil {
+			payload = updated
+			present[setting.Category] = true
+		}
+	}
+	return payload
+}
+
+// addGenerationDefaults sets the fields of defaults missing under path, descending into
+// nested objects.
+func addGenerationDefaults(payload []byte, path string, defaults map[string]any) []byte {
+	for key, value := range defaults {
+		fieldPath := path + "." + escapePayloadKey(key)
+		if nested, ok := value.(map[string]any); ok && gjson.GetBytes(payload, fieldPath).IsObject() {
+			payload = addGenerationDefaults(payload, fieldPath, nested)
+			continue
+		}
+		if gjson.GetBytes(payload, fieldPath).Exists() {
+			continue
+		}
+		if updated, err := sjson.SetBytes(payload, fieldPath, value); err == nil {
+			payload = updated
+		}
+	}
+	return payload
+}
+
+// escapePayloadKey escapes the gjson path syntax in a field name.
+func escapePayloadKey(key string) string {
+	replacer := strings.NewReplacer(".", `\.`, "*", `\*`, "?", `\?`)
+	return replacer.Replace(key)
+}
@@ -0,0 +1,54 @@
+package executor
+
+import (
+	"testing"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/tidwall/gjson"
+)
+
+func geminiDefaultsTestConfig() *config.Config {
+	cfg := &config.Config{GeminiDefaults: config.GeminiDefaultsConfig{Rules: []config.GeminiDefaultsRule{
+		{
+			Models: []string{"gemini-2.5-*"},
+			SafetySettings: []config.GeminiSafetySetting{
+				{Category: "harassment", Threshold: "block_none"},
+				{Category: "HARM_CATEGORY_HATE_SPEECH", Threshold: "BLOCK_ONLY_HIGH"},
+			},
+			GenerationConfig: map[string]any{"candidateCount": 1, "thinkingConfig": map[string]any{"includeThoughts": true}},
+		},
+		{Models: []string{"*"}, GenerationConfig: map[string]any{"candidateCount": 2, "topK": 40}},
+	}}}
+	cfg.SanitizeGeminiDefaults()
+	return cfg
+}
+
+func TestApplyGeminiDefaultsKeepsRequestSettings(t *testing.T) {
+	payload := []byte(`{"contents":[],"safetySettings":[{"category":"HARM_CATEGORY_HATE_SPEECH","threshold":"BLOCK_LOW_AND_ABOVE"}],"generationConfig":{"thinkingConfig":{"thinkingBudget":1024}}}`)
+	out := applyGeminiDefaults(geminiDefaultsTestConfig(), "gemini-2.5-pro", "", payload, "")
+
+	settings := gjson.GetBytes(out, "safetySettings").Array()
+	if len(settings) != 2 || settings[0].Get("threshold").String() != "BLOCK_LOW_AND_ABOVE" ||
+		settings[1].Get("category").String() != "HARM_CATEGORY_HARASSMENT" || settings[1].Get("threshold").String() != "BLOCK_NONE" {
+		t.Fatalf("safetySettings = %s", gjson.GetBytes(out, "safetySettings").Raw)
+	}
+	gen := gjson.GetBytes(out, "generationConfig")
+	if gen.Get("candidateCount").Int() != 1 || gen.Get("topK").Int() != 40 ||
+		gen.Get("thinkingConfig.thinkingBudget").Int() != 1024 || !gen.Get("thinkingConfig.includeThoughts").Bool() {
+		t.Fatalf("generationConfig = %s", gen.Raw)
+	}
+}
+
+func TestApplyGeminiDefaultsUnderRoot(t *testing.T) {
+	out := applyGeminiDefaults(geminiDefaultsTestConfig(), "gemini-2.5-flash", "request", []byte(`{"model":"gemini-2.5-flash","request":{"contents":[]}}`), "")
+	if n := len(gjson.GetBytes(out, "request.safetySettings").Array()); n != 2 {
+		t.Fatalf("request.safetySettings has %d entries, want 2: %s", n, out)
+	}
+	if gjson.GetBytes(out, "safetySettings").Exists() {
+		t.Fatalf("defaults written outside the request root: %s", out)
+	}
+	other := applyGeminiDefaults(geminiDefaultsTestConfig(), "gemini-1.5-pro", "", []byte(`{}`), "")
+	if gjson.GetBytes(other, "safetySettings").Exists() || gjson.GetBytes(other, "generationConfig.candidateCount").Int() != 2 {
+		t.Fatalf("unmatched model got %s", other)
+	}
+}
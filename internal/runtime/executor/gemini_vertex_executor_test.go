@@ -0,0 +1,25 @@
+package executor
+
+import (
+	"testing"
+
+	cliproxyauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+)
+
+func TestVertexBaseURLHonorsOverride(t *testing.T) {
+	override := &cliproxyauth.Auth{Attributes: map[string]string{"base_url": "https://vertex.internal.example/"}}
+	for _, tc := range []struct {
+		auth     *cliproxyauth.Auth
+		location string
+		want     string
+	}{
+		{auth: nil, location: "", want: "https://us-central1-aiplatform.googleapis.com"},
+		{auth: &cliproxyauth.Auth{}, location: "europe-west4", want: "https://europe-west4-aiplatform.googleapis.com"},
+		{auth: nil, location: "global", want: "https://aiplatform.googleapis.com"},
+		{auth: override, location: "europe-west4", want: "https://vertex.internal.example"},
+	} {
+		if got := vertexBaseURL(tc.auth, tc.location); got != tc.want {
+			t.Fatalf("vertexBaseURL(%v, %q) = %q, want %q", tc.auth, tc.location, got, tc.want)
+		}
+	}
+}
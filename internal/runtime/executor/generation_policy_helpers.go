@@ -0,0 +1,153 @@
+package executor
+
+import (
+	"context"
+	"strings"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	log "github.com/sirupsen/logrus"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// generationPolicyFields names the max_tokens and stop sequence fields of a translated
+// payload and how many stop sequences the upstream accepts (0 for no limit). Protocols
+// without a stop field get no stop sequences.
+type generationPolicyFields struct {
+	maxTokens []string
+	stop      string
+	stopLimit int
+}
+
+// generationPolicyProtocols maps the protocols of translated payloads to their fields.
+// Payloads of other protocols, such as Codex, are left unchanged.
+var generationPolicyProtocols = map[string]generationPolicyFields{
+	"openai":          {maxTokens: []string{"max_completion_tokens", "max_tokens"}, stop: "stop", stopLimit: 4},
+	"openai-response": {maxTokens: []string{"max_output_tokens"}},
+	"claude":          {maxTokens: []string{"max_tokens"}, stop: "stop_sequences"},
+	"gemini":          {maxTokens: []string{"generationConfig.maxOutputTokens"}, stop: "generationConfig.stopSequences", stopLimit: 5},
+	"antigravity":     {maxTokens: []string{"generationConfig.maxOutputTokens"}, stop: "generationConfig.stopSequences", stopLimit: 5},
+}
+
+// applyGenerationPolicy enforces cfg.GenerationPolicy on a payload translated to protocol,
+// with field paths relative to root. The first rule matching the model and the client API
+// key defaults or clamps max_tokens; the stop sequences of the policy and of all matching
+// rules are added to the payload's own.
+func applyGenerationPolicy(ctx context.Context, cfg *config.Config, model, protocol, root string, payload []byte, requestedModel string) []byte {
+	if cfg == nil || len(payload) == 0 {
+		return payload
+	}
+	policy := cfg.GenerationPolicy
+	if len(policy.StopSequences) == 0 && len(policy.Rules) == 0 {
+		return payload
+	}
+	fields, ok := generationPolicyProtocols[protocol]
+	if !ok {
+		return payload
+	}
+	candidates := payloadModelCandidates(strings.TrimSpace(model), strings.TrimSpace(requestedModel))
+	apiKey := apiKeyFromContext(ctx)
+	stops := append([]string(nil), policy.StopSequences...)
+	limited := false
+	for i := range policy.Rules {
+		rule := &policy.Rules[i]
+		if !generationPolicyRuleMatches(rule, candidates, apiKey) {
+			continue
+		}
+		stops = append(stops, rule.StopSequences...)
+		if !limited && (rule.MaxTokens > 0 || rule.DefaultMaxTokens > 0) {
+			limited = true
+			payload = applyMaxTokensPolicy(payload, root, fields.maxTokens, rule)
+		}
+	}
+	if len(stops) > 0 && fields.stop != "" {
+		payload = addStopSequences(payload, buildPayloadPath(root, fields.stop), stops, fields.stopLimit)
+	}
+	return payload
+}
+
+func generationPolicyRuleMatches(rule *config.GenerationPolicyRule, models []string, apiKey string) bool {
+	if len(rule.APIKeys) > 0 {
+		found := false
+		for _, key := range rule.APIKeys {
+			if key == apiKey {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if len(rule.Models) == 0 {
+		return true
+	}
+	for _, pattern := range rule.Models {
+		for _, model := range models {
+			if matchModelPattern(pattern, model) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// applyMaxTokensPolicy clamps the first max_tokens field present in the payload, or sets
+// the first field to the rule's default when none is.
+func applyMaxTokensPolicy(payload []byte, root string, paths []string, rule *config.GenerationPolicyRule) []byte {
+	for _, path := range paths {
+		fullPath := buildPayloadPath(root, path)
+		value := gjson.GetBytes(payload, fullPath)
+		if !value.Exists() {
+			continue
+		}
+		if rule.MaxTokens > 0 && value.Int() > int64(rule.MaxTokens) {
+			if updated, err := sjson.SetBytes(payload, fullPath, rule.MaxTokens); err == nil {
+				payload = updated
+			}
+		}
+		return payload
+	}
+	if rule.DefaultMaxTokens > 0 {
+		if updated, err := sjson.SetBytes(payload, buildPayloadPath(root, paths[len(paths)-1]), rule.DefaultMaxTokens); err == nil {
+			payload = updated
+		}
+	}
+	return payload
+}
+
+// addStopSequences merges stops into the string or list at path, skipping duplicates and
+// keeping the list within limit.
+func addStopSequences(payload []byte, path string, stops []string, limit int) []byte {
+	existing := gjson.GetBytes(payload, path)
+	merged := make([]string, 0, len(stops)+1)
+	seen := make(map[string]bool)
+	if existing.Type == gjson.String {
+		merged = append(merged, existing.String())
+		seen[existing.String()] = true
+	} else if existing.IsArray() {
+		for _, item := range existing.Array() {
+			merged = append(merged, item.String())
+			seen[item.String()] = true
+		}
+	}
+	own := len(merged)
+	for _, stop := range stops {
+		if seen[stop] {
+			continue
+		}
+		if limit > 0 && len(merged) >= limit {
+			log.Debugf("generation policy: upstream accepts %d stop sequences, dropping %q", limit, stop)
+			continue
+		}
+		seen[stop] = true
+		merged = append(merged, stop)
+	}
+	if len(merged) == own {
+		return payload
+	}
+	if updated, err := sjson.SetBytes(payload, path, merged); err == nil {
+		return updated
+	}
+	return payload
+}
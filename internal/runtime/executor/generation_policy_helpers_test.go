@@ -0,0 +1,88 @@
+package executor
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	sdktranslator "github.com/router-for-me/CLIProxyAPI/v6/sdk/translator"
+	"github.com/tidwall/gjson"
+)
+
+func generationPolicyTestConfig() *config.Config {
+	cfg := &config.Config{GenerationPolicy: config.GenerationPolicyConfig{
+		StopSequences: []string{"<END>"},
+		Rules: []config.GenerationPolicyRule{
+			{Models: []string{"claude-*"}, APIKeys: []string{"team-a"}, MaxTokens: 1000},
+			{Models: []string{"claude-*", "gemini-*"}, DefaultMaxTokens: 2048, MaxTokens: 4096, StopSequences: []string{"\n\nHuman:"}},
+		},
+	}}
+	cfg.SanitizeGenerationPolicy()
+	return cfg
+}
+
+func TestApplyGenerationPolicyClaudeClampAndStops(t *testing.T) {
+	cfg := generationPolicyTestConfig()
+	payload := []byte(`{"model":"claude-sonnet","max_tokens":8000,"stop_sequences":["<END>"]}`)
+	out := applyGenerationPolicy(context.Background(), cfg, "claude-sonnet", "claude", "", payload, "")
+	if got := gjson.GetBytes(out, "max_tokens").Int(); got != 4096 {
+		t.Fatalf("max_tokens = %d, want 4096", got)
+	}
+	stops := gjson.GetBytes(out, "stop_sequences").Array()
+	if len(stops) != 2 || stops[0].String() != "<END>" || stops[1].String() != "\n\nHuman:" {
+		t.Fatalf("stop_sequences = %v", stops)
+	}
+}
+
+func TestApplyGenerationPolicyAPIKeyRuleWins(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Set("apiKey", "team-a")
+	ctx := context.WithValue(context.Background(), "gin", c)
+
+	out := applyGenerationPolicy(ctx, generationPolicyTestConfig(), "claude-sonnet", "claude", "", []byte(`{"max_tokens":8000}`), "")
+	if got := gjson.GetBytes(out, "max_tokens").Int(); got != 1000 {
+		t.Fatalf("max_tokens = %d, want 1000", got)
+	}
+}
+
+func TestApplyGenerationPolicyGeminiDefaultUnderRoot(t *testing.T) {
+	payload := []byte(`{"request":{"contents":[]}}`)
+	out := applyGenerationPolicy(context.Background(), generationPolicyTestConfig(), "gemini-2.5-pro", "gemini", "request", payload, "")
+	if got := gjson.GetBytes(out, "request.generationConfig.maxOutputTokens").Int(); got != 2048 {
+		t.Fatalf("maxOutputTokens = %d, want 2048", got)
+	}
+	if got := gjson.GetBytes(out, "request.generationConfig.stopSequences.#").Int(); got != 2 {
+		t.Fatalf("stopSequences = %s", gjson.GetBytes(out, "request.generationConfig.stopSequences").Raw)
+	}
+}
+
+func TestApplyGenerationPolicyOpenAIStopLimit(t *testing.T) {
+	cfg := &config.Config{GenerationPolicy: config.GenerationPolicyConfig{StopSequences: []string{"a", "b", "c"}}}
+	out := applyGenerationPolicy(context.Background(), cfg, "gpt-5", "openai", "", []byte(`{"stop":["x","y"],"max_completion_tokens":10}`), "")
+	if got := gjson.GetBytes(out, "stop").Raw; got != `["x","y","a","b"]` {
+		t.Fatalf("stop = %s", got)
+	}
+	if got := gjson.GetBytes(out, "max_completion_tokens").Int(); got != 10 {
+		t.Fatalf("max_completion_tokens = %d, want 10", got)
+	}
+	codex := []byte(`{"input":[]}`)
+	if got := applyGenerationPolicy(context.Background(), cfg, "gpt-5", "codex", "", codex, ""); string(got) != string(codex) {
+		t.Fatalf("codex payload changed: %s", got)
+	}
+}
+
+func TestAIStudioTranslateRequestKeepsPolicyMaxTokens(t *testing.T) {
+	e := NewAIStudioExecutor(generationPolicyTestConfig(), "aistudio", nil)
+	req := cliproxyexecutor.Request{Model: "gemini-2.5-pro", Payload: []byte(`{"contents":[],"generationConfig":{"maxOutputTokens":9000}}`)}
+	payload, _, err := e.translateRequest(context.Background(), req, cliproxyexecutor.Options{SourceFormat: sdktranslator.FromString("gemini")}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := gjson.GetBytes(payload, "generationConfig.maxOutputTokens").Int(); got != 2048 {
+		t.Fatalf("maxOutputTokens = %d, want the policy default 2048", got)
+	}
+}
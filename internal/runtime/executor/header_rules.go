@@ -0,0 +1,115 @@
+package executor
+
+import (
+	"context"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	cliproxyauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+)
+
+// headerRulePlaceholder matches the {{name}} and {{header:Name}} placeholders of header rule values.
+var headerRulePlaceholder = regexp.MustCompile(`\{\{\s*([a-zA-Z-]+)(?::\s*([^}\s]+))?\s*\}\}`)
+
+// headerEdit is one resolved header rule: removals followed by rendered values.
+type headerEdit struct {
+	remove []string
+	set    map[string]string
+}
+
+// resolveHeaderRules renders the cfg.HeaderRules matching the provider of auth and the
+// requested model of ctx.
+func resolveHeaderRules(ctx context.Context, cfg *config.Config, auth *cliproxyauth.Auth) []headerEdit {
+	if cfg == nil || len(cfg.HeaderRules) == 0 {
+		return nil
+	}
+	var provider, authID string
+	if auth != nil {
+		provider = strings.ToLower(auth.Provider)
+		authID = auth.ID
+	}
+	var model string
+	var client http.Header
+	if ctx != nil {
+		model, _ = ctx.Value(routeModelContextKey).(string)
+		if ginCtx := ginContextFrom(ctx); ginCtx != nil && ginCtx.Request != nil {
+			client = ginCtx.Request.Header
+		}
+	}
+	render := func(value string) string {
+		return headerRulePlaceholder.ReplaceAllStringFunc(value, func(match string) string {
+			parts := headerRulePlaceholder.FindStringSubmatch(match)
+			switch strings.ToLower(parts[1]) {
+			case "model":
+				return model
+			case "provider":
+				return provider
+			case "auth-id":
+				return authID
+			case "header":
+				return client.Get(parts[2])
+			}
+			return match
+		})
+	}
+
+	var edits []headerEdit
+	for _, rule := range cfg.HeaderRules {
+		if !headerRuleMatches(rule.Providers, provider, false) || !headerRuleMatches(rule.Models, strings.ToLower(model), true) {
+			continue
+		}
+		edit := headerEdit{remove: rule.Remove, set: make(map[string]string, len(rule.Set))}
+		for name, value := range rule.Set {
+			if rendered := strings.TrimSpace(render(value)); rendered != "" {
+				edit.set[name] = rendered
+			}
+		}
+		edits = append(edits, edit)
+	}
+	return edits
+}
+
+func headerRuleMatches(patterns []string, value string, glob bool) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	for _, pattern := range patterns {
+		if (glob && matchModelPattern(pattern, value)) || (!glob && pattern == value) {
+			return true
+		}
+	}
+	return false
+}
+
+// withHeaderRules wraps base so every request gets the header rules of cfg matching ctx
+// and auth. Rules run after the executor set its headers and may override them.
+func withHeaderRules(ctx context.Context, base http.RoundTripper, cfg *config.Config, auth *cliproxyauth.Auth) http.RoundTripper {
+	edits := resolveHeaderRules(ctx, cfg, auth)
+	if len(edits) == 0 {
+		return base
+	}
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &headerRuleRoundTripper{base: base, edits: edits}
+}
+
+type headerRuleRoundTripper struct {
+	base  http.RoundTripper
+	edits []headerEdit
+}
+
+func (rt *headerRuleRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	out := req.Clone(req.Context())
+	for _, edit := range rt.edits {
+		for _, name := range edit.remove {
+			out.Header.Del(name)
+		}
+		for name, value := range edit.set {
+			out.Header.Set(name, value)
+		}
+	}
+	return rt.base.RoundTrip(out)
+}
@@ -0,0 +1,72 @@
+package executor
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	cliproxyauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+)
+
+func TestWithHeaderRules(t *testing.T) {
+	var seen http.Header
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = r.Header.Clone()
+	}))
+	defer upstream.Close()
+
+	cfg := &config.Config{HeaderRules: []config.HeaderRule{
+		{Set: map[string]string{"X-Org": "acme", "X-Trace": "{{header:X-Request-Id}}", "X-Missing": "{{header:X-Absent}}"}},
+		{Providers: []string{"claude"}, Models: []string{"claude-sonnet-*"}, Set: map[string]string{"Anthropic-Beta": "context-1m", "X-Route": "{{provider}}/{{model}}/{{auth-id}}"}, Remove: []string{"X-Internal"}},
+		{Providers: []string{"codex"}, Set: map[string]string{"X-Codex": "1"}},
+	}}
+	cfg.SanitizeHeaderRules()
+
+	gin.SetMode(gin.TestMode)
+	ginCtx, _ := gin.CreateTestContext(httptest.NewRecorder())
+	ginCtx.Request = httptest.NewRequest(http.MethodPost, "/v1/messages", nil)
+	ginCtx.Request.Header.Set("X-Request-Id", "req-1")
+	ctx := context.WithValue(context.WithValue(context.Background(), "gin", ginCtx), routeModelContextKey, "claude-sonnet-4-5")
+
+	client := newProxyAwareHTTPClient(ctx, cfg, &cliproxyauth.Auth{ID: "auth-1", Provider: "claude"}, 0)
+	req, _ := http.NewRequestWithContext(ctx, http.MethodPost, upstream.URL, nil)
+	req.Header.Set("Anthropic-Beta", "executor")
+	req.Header.Set("X-Internal", "secret")
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+
+	want := map[string]string{
+		"X-Org":          "acme",
+		"X-Trace":        "req-1",
+		"X-Missing":      "",
+		"Anthropic-Beta": "context-1m",
+		"X-Route":        "claude/claude-sonnet-4-5/auth-1",
+		"X-Internal":     "",
+		"X-Codex":        "",
+	}
+	for name, value := range want {
+		if got := seen.Get(name); got != value {
+			t.Fatalf("%s = %q, want %q", name, got, value)
+		}
+	}
+	if req.Header.Get("X-Internal") != "secret" {
+		t.Fatal("header rules must not mutate the caller's request")
+	}
+}
+
+func TestSanitizeHeaderRulesDropsReserved(t *testing.T) {
+	cfg := &config.Config{HeaderRules: []config.HeaderRule{
+		{Set: map[string]string{"host": "evil", "content-length": "1"}},
+		{Providers: []string{" Claude "}, Set: map[string]string{"x-org": "acme"}},
+	}}
+	cfg.SanitizeHeaderRules()
+	if len(cfg.HeaderRules) != 1 || cfg.HeaderRules[0].Providers[0] != "claude" || cfg.HeaderRules[0].Set["X-Org"] != "acme" {
+		t.Fatalf("sanitized rules = %+v", cfg.HeaderRules)
+	}
+}
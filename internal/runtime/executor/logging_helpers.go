@@ -14,6 +14,7 @@ import (
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
 	log "github.com/sirupsen/logrus"
 	"github.com/tidwall/gjson"
 )
@@ -96,8 +97,12 @@ func recordAPIRequest(ctx context.Context, cfg *config.Config, info upstreamRequ
 	updateAggregatedRequest(ginCtx, attempts)
 }
 
-// recordAPIResponseMetadata captures upstream response status/header information for the latest attempt.
+// recordAPIResponseMetadata captures upstream response status/header information for the latest attempt
+// and stashes its rate limit headers for the client response. For streams it marks the first
+// byte, which the usage record measures latency to.
 func recordAPIResponseMetadata(ctx context.Context, cfg *config.Config, status int, headers http.Header) {
+	usage.MarkFirstByte(ctx, time.Now())
+	stashRateLimitHeaders(ctx, headers)
 	if cfg == nil || !cfg.RequestLog {
 		return
 	}
@@ -0,0 +1,400 @@
+package executor
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/thinking"
+	cliproxyauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	sdktranslator "github.com/router-for-me/CLIProxyAPI/v6/sdk/translator"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// loremWords is the vocabulary of lorem answers.
+var loremWords = strings.Fields(`lorem ipsum dolor sit amet consectetur adipiscing elit sed do eiusmod
+tempor incididunt ut labore et dolore magna aliqua enim ad minim veniam quis nostrud exercitation
+ullamco laboris nisi aliquip ex ea commodo consequat duis aute irure in reprehenderit voluptate velit
+esse cillum fugiat nulla pariatur excepteur sint occaecat cupidatat non proident sunt culpa qui
+officia deserunt mollit anim id est laborum`)
+
+// MockExecutor answers requests with deterministic synthetic responses instead of calling an
+// upstream. Requests are translated to the OpenAI chat format and the answers back to the
+// client format, so the translators, payload rules and rewriters run as for real providers.
+type MockExecutor struct {
+	cfg *config.Config
+}
+
+// NewMockExecutor creates the executor of the "mock" provider.
+func NewMockExecutor(cfg *config.Config) *MockExecutor {
+	return &MockExecutor{cfg: cfg}
+}
+
+// Identifier implements cliproxyauth.ProviderExecutor.
+func (e *MockExecutor) Identifier() string { return "mock" }
+
+// HttpRequest is not supported: mock providers have no upstream.
+func (e *MockExecutor) HttpRequest(context.Context, *cliproxyauth.Auth, *http.Request) (*http.Response, error) {
+	return nil, statusErr{code: http.StatusNotImplemented, msg: "mock executor: no upstream to send requests to"}
+}
+
+// Refresh is a no-op for mock providers.
+func (e *MockExecutor) Refresh(_ context.Context, auth *cliproxyauth.Auth) (*cliproxyauth.Auth, error) {
+	return auth, nil
+}
+
+func (e *MockExecutor) Execute(ctx context.Context, auth *cliproxyauth.Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (resp cliproxyexecutor.Response, err error) {
+	baseModel := thinking.ParseSuffix(req.Model).ModelName
+
+	reporter := newUsageReporter(ctx, e.Identifier(), baseModel, auth)
+	defer reporter.trackFailure(ctx, &err)
+
+	from := opts.SourceFormat
+	to := sdktranslator.FromString("openai")
+	translated, err := e.translateRequest(ctx, auth, req, opts, false)
+	if err != nil {
+		return resp, err
+	}
+	answer := newMockAnswer(mockSettingsFromAuth(auth), translated)
+	body := answer.completion(baseModel)
+	appendAPIResponseChunk(ctx, e.cfg, body)
+	reporter.publish(ctx, parseOpenAIUsage(body))
+
+	var param any
+	out := sdktranslator.TranslateNonStream(ctx, to, from, req.Model, bytes.Clone(opts.OriginalRequest), translated, body, &param)
+	return cliproxyexecutor.Response{Payload: []byte(out)}, nil
+}
+
+func (e *MockExecutor) ExecuteStream(ctx context.Context, auth *cliproxyauth.Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (stream <-chan cliproxyexecutor.StreamChunk, err error) {
+	baseModel := thinking.ParseSuffix(req.Model).ModelName
+
+	reporter := newUsageReporter(ctx, e.Identifier(), baseModel, auth)
+	defer reporter.trackFailure(ctx, &err)
+
+	from := opts.SourceFormat
+	to := sdktranslator.FromString("openai")
+	translated, err := e.translateRequest(ctx, auth, req, opts, true)
+	if err != nil {
+		return nil, err
+	}
+	settings := mockSettingsFromAuth(auth)
+	lines := newMockAnswer(settings, translated).chunks(baseModel)
+
+	out := make(chan cliproxyexecutor.StreamChunk)
+	go func() {
+		defer close(out)
+		var param any
+		for i, line := range lines {
+			if i > 0 && settings.chunkDelay > 0 {
+				select {
+				case <-ctx.Done():
+					return
+				case <-time.After(settings.chunkDelay):
+				}
+			}
+			appendAPIResponseChunk(ctx, e.cfg, line)
+			if detail, ok := parseOpenAIStreamUsage(line); ok {
+				reporter.publish(ctx, detail)
+			}
+			chunks := sdktranslator.TranslateStream(ctx, to, from, req.Model, bytes.Clone(opts.OriginalRequest), translated, line, &param)
+			for j := range chunks {
+				select {
+				case <-ctx.Done():
+					return
+				case out <- cliproxyexecutor.StreamChunk{Payload: []byte(chunks[j])}:
+				}
+			}
+		}
+		reporter.ensurePublished(ctx)
+	}()
+	return out, nil
+}
+
+func (e *MockExecutor) CountTokens(ctx context.Context, _ *cliproxyauth.Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+	baseModel := thinking.ParseSuffix(req.Model).ModelName
+	from := opts.SourceFormat
+	to := sdktranslator.FromString("openai")
+	translated := sdktranslator.TranslateRequest(from, to, baseModel, bytes.Clone(req.Payload), false)
+	enc, err := tokenizerForModel(baseModel)
+	if err != nil {
+		return cliproxyexecutor.Response{}, fmt.Errorf("mock executor: tokenizer init failed: %w", err)
+	}
+	count, err := countOpenAIChatTokens(enc, translated)
+	if err != nil {
+		return cliproxyexecutor.Response{}, fmt.Errorf("mock executor: token counting failed: %w", err)
+	}
+	translatedUsage := sdktranslator.TranslateTokenCount(ctx, to, from, count, buildOpenAIUsageJSON(count))
+	return cliproxyexecutor.Response{Payload: []byte(translatedUsage)}, nil
+}
+
+// translateRequest converts the request to the OpenAI chat format and applies the payload
+// rules, like the OpenAI-compatible executor does before sending it upstream.
+func (e *MockExecutor) translateRequest(ctx context.Context, auth *cliproxyauth.Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options, stream bool) ([]byte, error) {
+	baseModel := thinking.ParseSuffix(req.Model).ModelName
+	from := opts.SourceFormat
+	to := sdktranslator.FromString("openai")
+	originalPayload := bytes.Clone(req.Payload)
+	if len(opts.OriginalRequest) > 0 {
+		originalPayload = bytes.Clone(opts.OriginalRequest)
+	}
+	originalTranslated := sdktranslator.TranslateRequest(from, to, baseModel, originalPayload, stream)
+	translated := sdktranslator.TranslateRequest(from, to, baseModel, bytes.Clone(req.Payload), stream)
+	requestedModel := payloadRequestedModel(opts, req.Model)
+	translated = applyPayloadConfigWithRoot(e.cfg, baseModel, to.String(), "", translated, originalTranslated, requestedModel)
+	translated = applyGenerationPolicy(ctx, e.cfg, baseModel, to.String(), "", translated, requestedModel)
+	translated, err := thinking.ApplyThinking(translated, req.Model, from.String(), to.String(), e.Identifier())
+	if err != nil {
+		return nil, err
+	}
+	var authID, authLabel string
+	if auth != nil {
+		authID = auth.ID
+		authLabel = auth.Label
+	}
+	recordAPIRequest(ctx, e.cfg, upstreamRequestLog{
+		URL:       "mock://" + authLabel,
+		Method:    http.MethodPost,
+		Body:      translated,
+		Provider:  e.Identifier(),
+		AuthID:    authID,
+		AuthLabel: authLabel,
+	})
+	return translated, nil
+}
+
+// mockSettings are the response settings a mock provider auth was synthesized with.
+type mockSettings struct {
+	mode       string
+	words      int
+	toolCalls  bool
+	chunkDelay time.Duration
+}
+
+func mockSettingsFromAuth(auth *cliproxyauth.Auth) mockSettings {
+	settings := mockSettings{mode: config.MockModeLorem, words: config.DefaultMockProviderWords}
+	if auth == nil || auth.Attributes == nil {
+		return settings
+	}
+	attrs := auth.Attributes
+	if mode := attrs["mock_mode"]; mode != "" {
+		settings.mode = mode
+	}
+	if words, err := strconv.Atoi(attrs["mock_words"]); err == nil && words > 0 {
+		settings.words = words
+	}
+	settings.toolCalls, _ = strconv.ParseBool(attrs["mock_tool_calls"])
+	if delay, err := strconv.Atoi(attrs["mock_chunk_delay_ms"]); err == nil && delay > 0 {
+		settings.chunkDelay = time.Duration(delay) * time.Millisecond
+	}
+	return settings
+}
+
+// mockAnswer is the synthetic answer to one request.
+type mockAnswer struct {
+	id           string
+	text         string
+	toolID       string
+	toolName     string
+	toolArgs     string
+	promptTokens int
+}
+
+// newMockAnswer derives the answer from the messages and tools of an OpenAI chat request,
+// so streaming and non-streaming variants of a request get the same answer.
+func newMockAnswer(settings mockSettings, request []byte) mockAnswer {
+	root := gjson.ParseBytes(request)
+	messages := root.Get("messages")
+	tools := root.Get("tools")
+	sum := sha256.Sum256([]byte(messages.Raw + "\n" + tools.Raw))
+	rng := rand.New(rand.NewPCG(binary.BigEndian.Uint64(sum[:8]), binary.BigEndian.Uint64(sum[8:16])))
+
+	answer := mockAnswer{
+		id:           "chatcmpl-mock-" + hex.EncodeToString(sum[:12]),
+		promptTokens: len(strings.Fields(mockMessagesText(messages, ""))),
+	}
+	lastRole := ""
+	if list := messages.Array(); len(list) > 0 {
+		lastRole = list[len(list)-1].Get("role").String()
+	}
+	if settings.toolCalls && len(tools.Array()) > 0 && lastRole != "tool" {
+		tool := tools.Array()[0].Get("function")
+		answer.toolID = "call_mock_" + hex.EncodeToString(sum[12:20])
+		answer.toolName = tool.Get("name").String()
+		answer.toolArgs = mockSchemaValue(tool.Get("parameters"))
+		return answer
+	}
+	if settings.mode == config.MockModeEcho {
+		answer.text = mockMessagesText(messages, "user")
+		if answer.text == "" {
+			answer.text = "(empty)"
+		}
+		return answer
+	}
+	answer.text = loremText(rng, settings.words)
+	return answer
+}
+
+// mockMessagesText joins the text of the messages, or of the last message with role.
+func mockMessagesText(messages gjson.Result, role string) string {
+	var parts []string
+	for _, message := range messages.Array() {
+		if role != "" && message.Get("role").String() != role {
+			continue
+		}
+		var text []string
+		content := message.Get("content")
+		if content.IsArray() {
+			for _, part := range content.Array() {
+				if part.Get("type").String() == "text" {
+					text = append(text, part.Get("text").String())
+				}
+			}
+		} else {
+			text = append(text, content.String())
+		}
+		if role != "" {
+			parts = text
+			continue
+		}
+		parts = append(parts, text...)
+	}
+	return strings.TrimSpace(strings.Join(parts, "\n"))
+}
+
+// loremText returns words of lorem ipsum in sentences of 6 to 12 words.
+func loremText(rng *rand.Rand, words int) string {
+	var b strings.Builder
+	sentence := 0
+	for i := 0; i < words; i++ {
+		word := loremWords[rng.IntN(len(loremWords))]
+		if sentence == 0 {
+			word = strings.ToUpper(word[:1]) + word[1:]
+			sentence = 6 + rng.IntN(7)
+		}
+		if b.Len() > 0 {
+			b.WriteByte(' ')
+		}
+		b.WriteString(word)
+		if sentence--; sentence == 0 || i == words-1 {
+			b.WriteByte('.')
+			sentence = 0
+		}
+	}
+	return b.String()
+}
+
+// mockSchemaValue returns JSON satisfying a JSON schema: the first enum value or a
+// placeholder of the declared type, with every property of objects filled in.
+func mockSchemaValue(schema gjson.Result) string {
+	if enum := schema.Get("enum"); enum.IsArray() && len(enum.Array()) > 0 {
+		return enum.Array()[0].Raw
+	}
+	switch schema.Get("type").String() {
+	case "string":
+		return `"mock"`
+	case "integer", "number":
+		return "1"
+	case "boolean":
+		return "true"
+	case "array":
+		return "[" + mockSchemaValue(schema.Get("items")) + "]"
+	case "null":
+		return "null"
+	}
+	out := "{}"
+	schema.Get("properties").ForEach(func(key, value gjson.Result) bool {
+		out, _ = sjson.SetRaw(out, escapeSJSONPath(key.String()), mockSchemaValue(value))
+		return true
+	})
+	return out
+}
+
+func escapeSJSONPath(key string) string {
+	replacer := strings.NewReplacer(".", `\.`, "*", `\*`, "?", `\?`, "|", `\|`, "#", `\#`, "@", `\@`)
+	return replacer.Replace(key)
+}
+
+func (a mockAnswer) finishReason() string {
+	if a.toolName != "" {
+		return "tool_calls"
+	}
+	return "stop"
+}
+
+func (a mockAnswer) completionTokens() int {
+	return len(strings.Fields(a.text)) + len(strings.Fields(a.toolArgs))
+}
+
+func (a mockAnswer) usageJSON() string {
+	completion := a.completionTokens()
+	return fmt.Sprintf(`{"prompt_tokens":%d,"completion_tokens":%d,"total_tokens":%d}`, a.promptTokens, completion, a.promptTokens+completion)
+}
+
+// completion renders the answer as an OpenAI chat completion.
+func (a mockAnswer) completion(model string) []byte {
+	out := `{"object":"chat.completion","created":0,"choices":[{"index":0,"message":{"role":"assistant","content":null}}]}`
+	out, _ = sjson.Set(out, "id", a.id)
+	out, _ = sjson.Set(out, "model", model)
+	if a.toolName != "" {
+		call := `{"type":"function"}`
+		call, _ = sjson.Set(call, "id", a.toolID)
+		call, _ = sjson.Set(call, "function.name", a.toolName)
+		call, _ = sjson.Set(call, "function.arguments", a.toolArgs)
+		out, _ = sjson.SetRaw(out, "choices.0.message.tool_calls", "["+call+"]")
+	} else {
+		out, _ = sjson.Set(out, "choices.0.message.content", a.text)
+	}
+	out, _ = sjson.Set(out, "choices.0.finish_reason", a.finishReason())
+	out, _ = sjson.SetRaw(out, "usage", a.usageJSON())
+	return []byte(out)
+}
+
+// chunks renders the answer as OpenAI chat completion SSE lines: a role chunk, one chunk
+// per word or the tool call, the finish chunk, a usage chunk and [DONE].
+func (a mockAnswer) chunks(model string) [][]byte {
+	base := `{"object":"chat.completion.chunk","created":0,"choices":[]}`
+	base, _ = sjson.Set(base, "id", a.id)
+	base, _ = sjson.Set(base, "model", model)
+	line := func(delta string, finish string) []byte {
+		choice := `{"index":0,"finish_reason":null}`
+		choice, _ = sjson.SetRaw(choice, "delta", delta)
+		if finish != "" {
+			choice, _ = sjson.Set(choice, "finish_reason", finish)
+		}
+		chunk, _ := sjson.SetRaw(base, "choices.-1", choice)
+		return []byte("data: " + chunk)
+	}
+
+	lines := [][]byte{line(`{"role":"assistant","content":""}`, "")}
+	if a.toolName != "" {
+		call := `{"index":0,"type":"function","function":{"arguments":""}}`
+		call, _ = sjson.Set(call, "id", a.toolID)
+		call, _ = sjson.Set(call, "function.name", a.toolName)
+		lines = append(lines, line(`{"tool_calls":[`+call+`]}`, ""))
+		args, _ := sjson.Set(`{"index":0,"function":{}}`, "function.arguments", a.toolArgs)
+		lines = append(lines, line(`{"tool_calls":[`+args+`]}`, ""))
+	} else {
+		for i, word := range strings.Fields(a.text) {
+			if i > 0 {
+				word = " " + word
+			}
+			delta, _ := sjson.Set(`{}`, "content", word)
+			lines = append(lines, line(delta, ""))
+		}
+	}
+	lines = append(lines, line(`{}`, a.finishReason()))
+	usage, _ := sjson.SetRaw(base, "usage", a.usageJSON())
+	lines = append(lines, []byte("data: "+usage), []byte("data: [DONE]"))
+	return lines
+}
@@ -0,0 +1,101 @@
+package executor
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	_ "github.com/router-for-me/CLIProxyAPI/v6/internal/translator"
+	cliproxyauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	sdktranslator "github.com/router-for-me/CLIProxyAPI/v6/sdk/translator"
+	"github.com/tidwall/gjson"
+)
+
+func mockTestAuth(mode string, toolCalls bool) *cliproxyauth.Auth {
+	tools := "false"
+	if toolCalls {
+		tools = "true"
+	}
+	return &cliproxyauth.Auth{ID: "mock-1", Provider: "mock", Label: "mock", Attributes: map[string]string{
+		"mock_mode":       mode,
+		"mock_words":      "12",
+		"mock_tool_calls": tools,
+	}}
+}
+
+func mockExecute(t *testing.T, auth *cliproxyauth.Auth, format string, payload string) []byte {
+	t.Helper()
+	resp, err := NewMockExecutor(nil).Execute(context.Background(), auth, cliproxyexecutor.Request{Model: "mock", Payload: []byte(payload)}, cliproxyexecutor.Options{
+		SourceFormat:    sdktranslator.FromString(format),
+		OriginalRequest: []byte(payload),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp.Payload
+}
+
+func TestMockExecutorIsDeterministic(t *testing.T) {
+	auth := mockTestAuth("lorem", false)
+	payload := `{"model":"mock","messages":[{"role":"user","content":"hello"}]}`
+	first := mockExecute(t, auth, "openai", payload)
+	second := mockExecute(t, auth, "openai", payload)
+	if string(first) != string(second) {
+		t.Fatalf("responses differ:\n%s\n%s", first, second)
+	}
+	text := gjson.GetBytes(first, "choices.0.message.content").String()
+	if len(strings.Fields(text)) != 12 {
+		t.Fatalf("content = %q, want 12 words", text)
+	}
+	other := mockExecute(t, auth, "openai", `{"model":"mock","messages":[{"role":"user","content":"bye"}]}`)
+	if gjson.GetBytes(other, "choices.0.message.content").String() == text {
+		t.Fatal("different prompts got the same answer")
+	}
+}
+
+func TestMockExecutorEchoesInClientFormat(t *testing.T) {
+	payload := `{"model":"mock","max_tokens":64,"messages":[{"role":"user","content":[{"type":"text","text":"ping back"}]}]}`
+	resp := mockExecute(t, mockTestAuth("echo", false), "claude", payload)
+	if got := gjson.GetBytes(resp, "content.0.text").String(); got != "ping back" {
+		t.Fatalf("claude response text = %q in %s", got, resp)
+	}
+}
+
+func TestMockExecutorStreamsToolCalls(t *testing.T) {
+	payload := `{"model":"mock","stream":true,"messages":[{"role":"user","content":"weather?"}],"tools":[{"type":"function","function":{"name":"get_weather","parameters":{"type":"object","properties":{"city":{"type":"string"},"unit":{"enum":["c","f"]},"days":{"type":"integer"}}}}}]}`
+	stream, err := NewMockExecutor(nil).ExecuteStream(context.Background(), mockTestAuth("lorem", true), cliproxyexecutor.Request{Model: "mock", Payload: []byte(payload)}, cliproxyexecutor.Options{
+		Stream:          true,
+		SourceFormat:    sdktranslator.FromString("openai"),
+		OriginalRequest: []byte(payload),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var name, args, finish string
+	for chunk := range stream {
+		if chunk.Err != nil {
+			t.Fatal(chunk.Err)
+		}
+		data := strings.TrimPrefix(string(chunk.Payload), "data: ")
+		call := gjson.Get(data, "choices.0.delta.tool_calls.0.function")
+		name += call.Get("name").String()
+		args += call.Get("arguments").String()
+		if reason := gjson.Get(data, "choices.0.finish_reason").String(); reason != "" {
+			finish = reason
+		}
+	}
+	if name != "get_weather" || finish != "tool_calls" {
+		t.Fatalf("tool call %q finished with %q", name, finish)
+	}
+	if want := `{"city":"mock","unit":"c","days":1}`; args != want {
+		t.Fatalf("arguments = %s, want %s", args, want)
+	}
+
+	// A tool result gets a text answer.
+	followUp := `{"model":"mock","messages":[{"role":"user","content":"weather?"},{"role":"assistant","tool_calls":[{"id":"call_1","type":"function","function":{"name":"get_weather","arguments":"{}"}}]},{"role":"tool","tool_call_id":"call_1","content":"sunny"}],"tools":[{"type":"function","function":{"name":"get_weather"}}]}`
+	resp := mockExecute(t, mockTestAuth("lorem", true), "openai", followUp)
+	if gjson.GetBytes(resp, "choices.0.finish_reason").String() != "stop" {
+		t.Fatalf("tool result answered with %s", resp)
+	}
+}
@@ -0,0 +1,103 @@
+package executor
+
+import (
+	"strings"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/thinking"
+	log "github.com/sirupsen/logrus"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// reasoningUnsupportedParams are the sampling parameters OpenAI reasoning models reject.
+var reasoningUnsupportedParams = []string{"temperature", "top_p", "presence_penalty", "frequency_penalty", "logit_bias", "logprobs", "top_logprobs"}
+
+// isOpenAIReasoningModel reports whether model is an OpenAI reasoning model: an o-series or
+// gpt-5 model other than the chat variants, or one of the configured extra names. A
+// provider prefix such as "openai/" is ignored.
+func isOpenAIReasoningModel(cfg *config.Config, model string) bool {
+	model = strings.ToLower(strings.TrimSpace(model))
+	if cfg != nil {
+		for _, pattern := range cfg.OpenAIReasoning.Models {
+			if matchModelPattern(strings.ToLower(pattern), model) {
+				return true
+			}
+		}
+	}
+	if i := strings.LastIndex(model, "/"); i >= 0 {
+		model = model[i+1:]
+	}
+	switch {
+	case len(model) >= 2 && model[0] == 'o' && model[1] >= '1' && model[1] <= '9':
+		return true
+	case strings.HasPrefix(model, "gpt-5"):
+		return !strings.Contains(model, "-chat")
+	}
+	return false
+}
+
+// adaptOpenAIReasoningRequest rewrites a Chat Completions (format "openai") or Responses
+// (format "openai-response") payload for a reasoning model, so the upstream does not reject
+// it: max_tokens moves to max_completion_tokens, unsupported sampling parameters are dropped
+// and the thinking suffix of requestedModel sets reasoning_effort when the payload has none.
+func adaptOpenAIReasoningRequest(cfg *config.Config, model, format string, payload []byte, requestedModel string) []byte {
+	if (cfg != nil && cfg.OpenAIReasoning.Disable) || len(payload) == 0 || !isOpenAIReasoningModel(cfg, model) {
+		return payload
+	}
+	out := payload
+	var adapted []string
+	if format == "openai" {
+		if maxTokens := gjson.GetBytes(out, "max_tokens"); maxTokens.Exists() {
+			if !gjson.GetBytes(out, "max_completion_tokens").Exists() {
+				out, _ = sjson.SetRawBytes(out, "max_completion_tokens", []byte(maxTokens.Raw))
+			}
+			out, _ = sjson.DeleteBytes(out, "max_tokens")
+			adapted = append(adapted, "max_tokens")
+		}
+	}
+	for _, param := range reasoningUnsupportedParams {
+		if gjson.GetBytes(out, param).Exists() {
+			out, _ = sjson.DeleteBytes(out, param)
+			adapted = append(adapted, param)
+		}
+	}
+	effortPath := "reasoning_effort"
+	if format == "openai-response" {
+		effortPath = "reasoning.effort"
+	}
+	effort := gjson.GetBytes(out, effortPath)
+	switch {
+	case !effort.Exists():
+		if level := suffixReasoningEffort(requestedModel); level != "" {
+			out, _ = sjson.SetBytes(out, effortPath, level)
+			adapted = append(adapted, "thinking suffix")
+		}
+	case effort.String() == string(thinking.LevelAuto):
+		// Reasoning models have no "auto" effort; their default is used instead.
+		out, _ = sjson.DeleteBytes(out, effortPath)
+		adapted = append(adapted, effortPath)
+	}
+	if len(adapted) > 0 {
+		log.Debugf("openai reasoning: adapted %s for %s", strings.Join(adapted, ", "), model)
+	}
+	return out
+}
+
+// suffixReasoningEffort returns the reasoning effort of a thinking suffix such as
+// "o3(high)" or "o4-mini(8192)", or "" when the model has none.
+func suffixReasoningEffort(model string) string {
+	suffix := thinking.ParseSuffix(model)
+	if !suffix.HasSuffix {
+		return ""
+	}
+	if level, ok := thinking.ParseLevelSuffix(suffix.RawSuffix); ok {
+		return string(level)
+	}
+	if budget, ok := thinking.ParseNumericSuffix(suffix.RawSuffix); ok && budget > 0 {
+		if level, okLevel := thinking.ConvertBudgetToLevel(budget); okLevel {
+			return level
+		}
+	}
+	return ""
+}
@@ -0,0 +1,55 @@
+package executor
+
+import (
+	"testing"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/tidwall/gjson"
+)
+
+func TestIsOpenAIReasoningModel(t *testing.T) {
+	cfg := &config.Config{OpenAIReasoning: config.OpenAIReasoningConfig{Models: []string{"prod-reasoner-*"}}}
+	for model, want := range map[string]bool{
+		"o1": true, "o3-mini": true, "o4-mini-2025-04-16": true, "openai/o3": true,
+		"gpt-5": true, "gpt-5.1-codex": true, "gpt-5-chat-latest": false,
+		"gpt-4o": false, "omni-moderation-latest": false, "prod-reasoner-eu": true,
+	} {
+		if got := isOpenAIReasoningModel(cfg, model); got != want {
+			t.Errorf("isOpenAIReasoningModel(%q) = %v, want %v", model, got, want)
+		}
+	}
+}
+
+func TestAdaptOpenAIReasoningRequestChat(t *testing.T) {
+	payload := []byte(`{"model":"o3","max_tokens":2048,"temperature":0.2,"top_p":0.9,"messages":[]}`)
+	out := adaptOpenAIReasoningRequest(&config.Config{}, "o3", "openai", payload, "o3(high)")
+	if gjson.GetBytes(out, "max_tokens").Exists() || gjson.GetBytes(out, "max_completion_tokens").Int() != 2048 {
+		t.Fatalf("max tokens not moved: %s", out)
+	}
+	if gjson.GetBytes(out, "temperature").Exists() || gjson.GetBytes(out, "top_p").Exists() {
+		t.Fatalf("sampling parameters kept: %s", out)
+	}
+	if got := gjson.GetBytes(out, "reasoning_effort").String(); got != "high" {
+		t.Fatalf("reasoning_effort = %q, want high", got)
+	}
+
+	kept := adaptOpenAIReasoningRequest(&config.Config{}, "o3", "openai", []byte(`{"reasoning_effort":"low"}`), "o3(8192)")
+	if got := gjson.GetBytes(kept, "reasoning_effort").String(); got != "low" {
+		t.Fatalf("reasoning_effort = %q, want the request's low", got)
+	}
+	plain := []byte(`{"max_tokens":10,"temperature":0.5}`)
+	if out = adaptOpenAIReasoningRequest(&config.Config{}, "gpt-4o", "openai", plain, "gpt-4o"); string(out) != string(plain) {
+		t.Fatalf("non-reasoning request changed: %s", out)
+	}
+	disabled := &config.Config{OpenAIReasoning: config.OpenAIReasoningConfig{Disable: true}}
+	if out = adaptOpenAIReasoningRequest(disabled, "o3", "openai", plain, "o3"); string(out) != string(plain) {
+		t.Fatalf("disabled adaptation changed the request: %s", out)
+	}
+}
+
+func TestAdaptOpenAIReasoningRequestResponses(t *testing.T) {
+	out := adaptOpenAIReasoningRequest(&config.Config{}, "gpt-5", "openai-response", []byte(`{"max_output_tokens":100,"temperature":1,"reasoning":{"effort":"auto"}}`), "gpt-5")
+	if gjson.GetBytes(out, "max_output_tokens").Int() != 100 || gjson.GetBytes(out, "temperature").Exists() || gjson.GetBytes(out, "reasoning.effort").Exists() {
+		t.Fatalf("responses payload = %s", out)
+	}
+}
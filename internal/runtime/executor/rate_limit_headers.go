@@ -0,0 +1,30 @@
+package executor
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+)
+
+// stashRateLimitHeaders records the rate limit headers of an upstream response so the handler
+// can forward them to the client. Every attempt replaces the previous one, so the headers of
+// a credential that failed over do not leak into a response served by another.
+func stashRateLimitHeaders(ctx context.Context, headers http.Header) {
+	ginCtx := ginContextFrom(ctx)
+	if ginCtx == nil {
+		return
+	}
+	ginCtx.Set(util.UpstreamRateLimitHeadersKey, util.RateLimitHeaders(headers))
+}
+
+// newUpstreamStatusErr builds the error for a non-2xx upstream response. Rate limited and
+// unavailable responses carry the upstream retry hint into the credential cooldown.
+func newUpstreamStatusErr(resp *http.Response, body []byte) statusErr {
+	err := statusErr{code: resp.StatusCode, msg: string(body)}
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable {
+		err.retryAfter = util.RetryAfterFromHeaders(resp.Header, time.Now())
+	}
+	return err
+}
@@ -0,0 +1,256 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptrace"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	cliproxyauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+)
+
+// timeoutHeader lets a client shorten the upstream timeouts of one request. A bare value
+// ("90s" or "90") sets the total timeout; "connect=5s,first-byte=20s,total=2m" sets
+// individual phases.
+const timeoutHeader = "X-CLIProxy-Timeout"
+
+// routeModelContextKey carries the requested model of the current attempt so model level
+// timeouts can apply.
+const routeModelContextKey = "cliproxy.model"
+
+// upstreamTimeouts are the resolved timeouts of one upstream request; zero disables a phase.
+type upstreamTimeouts struct {
+	connect   time.Duration
+	firstByte time.Duration
+	total     time.Duration
+}
+
+func (t upstreamTimeouts) enabled() bool {
+	return t.connect > 0 || t.firstByte > 0 || t.total > 0
+}
+
+// resolveUpstreamTimeouts applies the global, provider, model and request level timeouts in
+// that order.
+func resolveUpstreamTimeouts(ctx context.Context, cfg *config.Config, auth *cliproxyauth.Auth) upstreamTimeouts {
+	var policy config.TimeoutPolicy
+	if cfg != nil {
+		policy = cfg.Timeouts.TimeoutPolicy
+		if auth != nil {
+			if override, ok := cfg.Timeouts.Providers[strings.ToLower(auth.Provider)]; ok {
+				policy = policy.Merge(override)
+			}
+		}
+		if model, _ := ctx.Value(routeModelContextKey).(string); model != "" {
+			for _, entry := range cfg.Timeouts.Models {
+				if matchModelPattern(strings.ToLower(entry.Name), strings.ToLower(model)) {
+					policy = policy.Merge(entry.TimeoutPolicy)
+					break
+				}
+			}
+		}
+	} else {
+		policy.ConnectSeconds = config.DefaultConnectTimeoutSeconds
+	}
+
+	timeouts := upstreamTimeouts{
+		connect:   secondsToDuration(policy.ConnectSeconds),
+		firstByte: secondsToDuration(policy.FirstByteSeconds),
+		total:     secondsToDuration(policy.TotalSeconds),
+	}
+	if ginCtx := ginContextFrom(ctx); ginCtx != nil && ginCtx.Request != nil {
+		applyTimeoutHeader(&timeouts, ginCtx.GetHeader(timeoutHeader))
+	}
+	return timeouts
+}
+
+func secondsToDuration(seconds int) time.Duration {
+	if seconds <= 0 {
+		return 0
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// applyTimeoutHeader applies the valid entries of an X-CLIProxy-Timeout value. A client may
+// only shorten the configured timeouts, never raise or disable them.
+func applyTimeoutHeader(timeouts *upstreamTimeouts, value string) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return
+	}
+	if !strings.Contains(value, "=") {
+		if d, ok := parseTimeoutValue(value); ok {
+			shortenTimeout(&timeouts.total, d)
+		}
+		return
+	}
+	for _, part := range strings.Split(value, ",") {
+		name, raw, found := strings.Cut(part, "=")
+		if !found {
+			continue
+		}
+		d, ok := parseTimeoutValue(raw)
+		if !ok {
+			continue
+		}
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case "connect":
+			shortenTimeout(&timeouts.connect, d)
+		case "first-byte":
+			shortenTimeout(&timeouts.firstByte, d)
+		case "total":
+			shortenTimeout(&timeouts.total, d)
+		}
+	}
+}
+
+// shortenTimeout sets *current to d when d is stricter; a zero current is disabled.
+func shortenTimeout(current *time.Duration, d time.Duration) {
+	if *current == 0 || d < *current {
+		*current = d
+	}
+}
+
+func parseTimeoutValue(raw string) (time.Duration, bool) {
+	raw = strings.TrimSpace(raw)
+	if d, err := time.ParseDuration(raw); err == nil && d > 0 {
+		return d, true
+	}
+	if seconds, err := strconv.ParseFloat(raw, 64); err == nil && seconds > 0 {
+		return time.Duration(seconds * float64(time.Second)), true
+	}
+	return 0, false
+}
+
+// withUpstreamTimeouts wraps base so requests are aborted when a phase exceeds its timeout.
+func withUpstreamTimeouts(base http.RoundTripper, timeouts upstreamTimeouts) http.RoundTripper {
+	if !timeouts.enabled() {
+		return base
+	}
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &timeoutRoundTripper{base: base, timeouts: timeouts}
+}
+
+// UpstreamTimeoutError reports an upstream request aborted by a timeout policy. It is a
+// net.Error whose Timeout method returns true.
+type UpstreamTimeoutError struct {
+	Phase string
+	After time.Duration
+}
+
+func (e *UpstreamTimeoutError) Error() string {
+	return fmt.Sprintf("upstream %s timeout after %s", e.Phase, e.After)
+}
+
+// Timeout reports true so callers classify the error as a timeout.
+func (e *UpstreamTimeoutError) Timeout() bool { return true }
+
+// Temporary reports true; the request can be retried.
+func (e *UpstreamTimeoutError) Temporary() bool { return true }
+
+type timeoutRoundTripper struct {
+	base     http.RoundTripper
+	timeouts upstreamTimeouts
+}
+
+// requestTimers arms the phase timers of one request. Trace callbacks run on transport
+// goroutines, so all access goes through mu.
+type requestTimers struct {
+	mu     sync.Mutex
+	cancel context.CancelCauseFunc
+	timers map[string]*time.Timer
+}
+
+func (rt *requestTimers) start(phase string, d time.Duration) {
+	if d <= 0 {
+		return
+	}
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	if _, exists := rt.timers[phase]; exists {
+		return
+	}
+	rt.timers[phase] = time.AfterFunc(d, func() { rt.cancel(&UpstreamTimeoutError{Phase: phase, After: d}) })
+}
+
+func (rt *requestTimers) stop(phases ...string) {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	for _, phase := range phases {
+		if timer := rt.timers[phase]; timer != nil {
+			timer.Stop()
+		}
+		// A stopped phase is never re-armed, even by trace callbacks arriving late.
+		rt.timers[phase] = nil
+	}
+}
+
+func (t *timeoutRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx, cancel := context.WithCancelCause(req.Context())
+	timers := &requestTimers{cancel: cancel, timers: make(map[string]*time.Timer, 3)}
+	timers.start("total", t.timeouts.total)
+	timers.start("connect", t.timeouts.connect)
+	trace := &httptrace.ClientTrace{
+		GotConn:              func(httptrace.GotConnInfo) { timers.stop("connect") },
+		WroteRequest:         func(httptrace.WroteRequestInfo) { timers.start("first byte", t.timeouts.firstByte) },
+		GotFirstResponseByte: func() { timers.stop("first byte") },
+	}
+	resp, err := t.base.RoundTrip(req.WithContext(httptrace.WithClientTrace(ctx, trace)))
+	if err != nil {
+		timers.stop("total", "connect", "first byte")
+		cause := timeoutCause(ctx)
+		cancel(nil)
+		if cause != nil {
+			return nil, cause
+		}
+		return nil, err
+	}
+	timers.stop("connect", "first byte")
+	resp.Body = &timeoutBody{ReadCloser: resp.Body, ctx: ctx, done: func() {
+		timers.stop("total")
+		cancel(nil)
+	}}
+	return resp, nil
+}
+
+// timeoutCause returns the timeout that cancelled ctx, if any.
+func timeoutCause(ctx context.Context) error {
+	var timeoutErr *UpstreamTimeoutError
+	if errors.As(context.Cause(ctx), &timeoutErr) {
+		return timeoutErr
+	}
+	return nil
+}
+
+// timeoutBody keeps the total timeout running while the body is read and reports it in
+// place of the bare cancellation error.
+type timeoutBody struct {
+	io.ReadCloser
+	ctx  context.Context
+	once sync.Once
+	done func()
+}
+
+func (b *timeoutBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if err != nil && err != io.EOF {
+		if cause := timeoutCause(b.ctx); cause != nil {
+			return n, cause
+		}
+	}
+	return n, err
+}
+
+func (b *timeoutBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.done)
+	return err
+}
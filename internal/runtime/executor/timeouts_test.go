@@ -0,0 +1,100 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	cliproxyauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+)
+
+func TestResolveUpstreamTimeoutsHierarchy(t *testing.T) {
+	cfg := &config.Config{Timeouts: config.TimeoutConfig{
+		TimeoutPolicy: config.TimeoutPolicy{ConnectSeconds: 10, FirstByteSeconds: 120, TotalSeconds: 600},
+		Providers: map[string]config.TimeoutPolicy{
+			"claude": {FirstByteSeconds: 60},
+		},
+		Models: []config.ModelTimeoutPolicy{
+			{Name: "claude-haiku-*", TimeoutPolicy: config.TimeoutPolicy{FirstByteSeconds: 15, TotalSeconds: -1}},
+		},
+	}}
+	auth := &cliproxyauth.Auth{Provider: "claude"}
+
+	got := resolveUpstreamTimeouts(context.Background(), cfg, auth)
+	if got != (upstreamTimeouts{connect: 10 * time.Second, firstByte: 60 * time.Second, total: 600 * time.Second}) {
+		t.Fatalf("provider level = %+v", got)
+	}
+
+	ctx := context.WithValue(context.Background(), routeModelContextKey, "claude-haiku-4-5")
+	got = resolveUpstreamTimeouts(ctx, cfg, auth)
+	if got != (upstreamTimeouts{connect: 10 * time.Second, firstByte: 15 * time.Second}) {
+		t.Fatalf("model level = %+v", got)
+	}
+
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/messages", nil)
+	c.Request.Header.Set(timeoutHeader, "connect=1m, first-byte=5s, total=30, bogus=1s")
+	got = resolveUpstreamTimeouts(context.WithValue(ctx, "gin", c), cfg, auth)
+	if got != (upstreamTimeouts{connect: 10 * time.Second, firstByte: 5 * time.Second, total: 30 * time.Second}) {
+		t.Fatalf("request level = %+v", got)
+	}
+
+	// The header can only shorten the configured timeouts.
+	c.Request.Header.Set(timeoutHeader, "1h")
+	got = resolveUpstreamTimeouts(context.WithValue(context.Background(), "gin", c), cfg, auth)
+	if got.total != 600*time.Second {
+		t.Fatalf("header raised the total timeout to %v", got.total)
+	}
+}
+
+func TestTimeoutRoundTripperFirstByte(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer server.Close()
+	defer close(release)
+
+	client := &http.Client{Transport: withUpstreamTimeouts(nil, upstreamTimeouts{firstByte: 50 * time.Millisecond})}
+	_, err := client.Get(server.URL)
+	var timeoutErr *UpstreamTimeoutError
+	if !errors.As(err, &timeoutErr) || timeoutErr.Phase != "first byte" {
+		t.Fatalf("err = %v, want first byte timeout", err)
+	}
+	var netErr net.Error
+	if !errors.As(err, &netErr) || !netErr.Timeout() {
+		t.Fatalf("err = %v is not reported as a timeout", err)
+	}
+}
+
+func TestTimeoutRoundTripperTotalCoversBody(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	client := &http.Client{Transport: withUpstreamTimeouts(nil, upstreamTimeouts{firstByte: time.Second, total: 100 * time.Millisecond})}
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	_, err = io.ReadAll(resp.Body)
+	var timeoutErr *UpstreamTimeoutError
+	if !errors.As(err, &timeoutErr) || timeoutErr.Phase != "total" {
+		t.Fatalf("read err = %v, want total timeout", err)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"strings"
 
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	"github.com/tidwall/gjson"
 	"github.com/tiktoken-go/tokenizer"
 )
@@ -33,7 +34,7 @@ func tokenizerForModel(model string) (tokenizer.Codec, error) {
 	case strings.HasPrefix(sanitized, "o4"):
 		return tokenizer.ForModel(tokenizer.O4Mini)
 	default:
-		return tokenizer.Get(tokenizer.O200kBase)
+		return util.TokenCodec()
 	}
 }
 
@@ -9,6 +9,7 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	cliproxyauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
 	"github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
 	"github.com/tidwall/gjson"
@@ -73,7 +74,7 @@ func (r *usageReporter) publishWithOutcome(ctx context.Context, detail usage.Det
 		return
 	}
 	r.once.Do(func() {
-		usage.PublishRecord(ctx, usage.Record{
+		record := usage.Record{
 			Provider:    r.provider,
 			Model:       r.model,
 			Source:      r.source,
@@ -81,12 +82,25 @@ func (r *usageReporter) publishWithOutcome(ctx context.Context, detail usage.Det
 			AuthID:      r.authID,
 			AuthIndex:   r.authIndex,
 			RequestedAt: r.requestedAt,
+			Latency:     r.latency(ctx),
 			Failed:      failed,
 			Detail:      detail,
-		})
+		}
+		if !failed {
+			stashUsageRecord(ctx, record)
+		}
+		usage.PublishRecord(ctx, record)
 	})
 }
 
+// latency returns the time to the first byte of a streamed response, or else to now.
+func (r *usageReporter) latency(ctx context.Context) time.Duration {
+	if first := usage.FirstByte(ctx); !first.Before(r.requestedAt) {
+		return first.Sub(r.requestedAt)
+	}
+	return time.Since(r.requestedAt)
+}
+
 // ensurePublished guarantees that a usage record is emitted exactly once.
 // It is safe to call multiple times; only the first call wins due to once.Do.
 // This is used to ensure request counting even when upstream responses do not
@@ -96,7 +110,7 @@ func (r *usageReporter) ensurePublished(ctx context.Context) {
 		return
 	}
 	r.once.Do(func() {
-		usage.PublishRecord(ctx, usage.Record{
+		record := usage.Record{
 			Provider:    r.provider,
 			Model:       r.model,
 			Source:      r.source,
@@ -104,12 +118,23 @@ func (r *usageReporter) ensurePublished(ctx context.Context) {
 			AuthID:      r.authID,
 			AuthIndex:   r.authIndex,
 			RequestedAt: r.requestedAt,
+			Latency:     r.latency(ctx),
 			Failed:      false,
 			Detail:      usage.Detail{},
-		})
+		}
+		stashUsageRecord(ctx, record)
+		usage.PublishRecord(ctx, record)
 	})
 }
 
+// stashUsageRecord records the usage of a successful upstream attempt on the request, so
+// the cost and route response headers describe the attempt that served it.
+func stashUsageRecord(ctx context.Context, record usage.Record) {
+	if ginCtx := ginContextFrom(ctx); ginCtx != nil {
+		ginCtx.Set(util.UsageRecordKey, record)
+	}
+}
+
 func apiKeyFromContext(ctx context.Context) string {
 	if ctx == nil {
 		return ""
@@ -267,9 +292,44 @@ func parseClaudeUsage(data []byte) usage.Detail {
 		detail.CachedTokens = usageNode.Get("cache_creation_input_tokens").Int()
 	}
 	detail.TotalTokens = detail.InputTokens + detail.OutputTokens
+	var thinking strings.Builder
+	for _, block := range gjson.GetBytes(data, "content").Array() {
+		if block.Get("type").String() == "thinking" {
+			thinking.WriteString(block.Get("thinking").String())
+		}
+	}
+	detail.ReasoningTokens = claudeReasoningTokens(thinking.String(), detail.OutputTokens)
 	return detail
 }
 
+// claudeThinking collects the thinking deltas of a Claude stream. Claude counts thinking
+// inside output_tokens without breaking it out, so reasoning tokens are estimated from the
+// thinking text.
+type claudeThinking struct {
+	text strings.Builder
+}
+
+func (t *claudeThinking) observe(line []byte) {
+	if !bytes.Contains(line, []byte("thinking_delta")) {
+		return
+	}
+	delta := gjson.GetBytes(jsonPayload(line), "delta")
+	if delta.Get("type").String() == "thinking_delta" {
+		t.text.WriteString(delta.Get("thinking").String())
+	}
+}
+
+// apply sets the reasoning tokens of detail, the usage of the stream's message_delta.
+func (t *claudeThinking) apply(detail *usage.Detail) {
+	detail.ReasoningTokens = claudeReasoningTokens(t.text.String(), detail.OutputTokens)
+}
+
+// claudeReasoningTokens estimates the tokens of thinking text, which are part of the
+// output tokens and never exceed them.
+func claudeReasoningTokens(text string, output int64) int64 {
+	return min(util.CountTokens(text), output)
+}
+
 func parseClaudeStreamUsage(line []byte) (usage.Detail, bool) {
 	payload := jsonPayload(line)
 	if len(payload) == 0 || !gjson.ValidBytes(payload) {
@@ -1,6 +1,12 @@
 package executor
 
-import "testing"
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+)
 
 func TestParseOpenAIUsageChatCompletions(t *testing.T) {
 	data := []byte(`{"usage":{"prompt_tokens":1,"completion_tokens":2,"total_tokens":3,"prompt_tokens_details":{"cached_tokens":4},"completion_tokens_details":{"reasoning_tokens":5}}}`)
@@ -41,3 +47,40 @@ func TestParseOpenAIUsageResponses(t *testing.T) {
 		t.Fatalf("reasoning tokens = %d, want %d", detail.ReasoningTokens, 9)
 	}
 }
+
+func TestClaudeUsageEstimatesReasoningFromThinking(t *testing.T) {
+	data := []byte(`{"content":[{"type":"thinking","thinking":"Let me add the numbers first."},{"type":"text","text":"4"}],"usage":{"input_tokens":10,"output_tokens":40}}`)
+	detail := parseClaudeUsage(data)
+	if detail.ReasoningTokens <= 0 || detail.ReasoningTokens > detail.OutputTokens {
+		t.Fatalf("reasoning tokens = %d, want within (0, %d]", detail.ReasoningTokens, detail.OutputTokens)
+	}
+
+	var thinking claudeThinking
+	thinking.observe([]byte(`data: {"type":"content_block_delta","index":0,"delta":{"type":"thinking_delta","thinking":"Let me add"}}`))
+	thinking.observe([]byte(`data: {"type":"content_block_delta","index":1,"delta":{"type":"text_delta","text":"4"}}`))
+	streamed, ok := parseClaudeStreamUsage([]byte(`data: {"type":"message_delta","usage":{"output_tokens":2}}`))
+	if !ok {
+		t.Fatal("message_delta usage not parsed")
+	}
+	thinking.apply(&streamed)
+	if streamed.ReasoningTokens != 2 {
+		t.Fatalf("stream reasoning tokens = %d, want capped at 2", streamed.ReasoningTokens)
+	}
+
+	if plain := parseClaudeUsage([]byte(`{"content":[{"type":"text","text":"4"}],"usage":{"input_tokens":10,"output_tokens":1}}`)); plain.ReasoningTokens != 0 {
+		t.Fatalf("reasoning tokens without thinking = %d", plain.ReasoningTokens)
+	}
+}
+
+func TestUsageReporterLatencyStopsAtFirstByteOfStreams(t *testing.T) {
+	start := time.Now().Add(-time.Minute)
+	reporter := &usageReporter{requestedAt: start}
+	ctx := usage.WithFirstByte(context.Background())
+	usage.MarkFirstByte(ctx, start.Add(time.Second))
+	if got := reporter.latency(ctx); got != time.Second {
+		t.Fatalf("stream latency = %v, want 1s", got)
+	}
+	if got := reporter.latency(context.Background()); got < time.Minute {
+		t.Fatalf("non-stream latency = %v, want the full minute", got)
+	}
+}
@@ -0,0 +1,35 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	base := time.Date(2026, 1, 5, 10, 7, 0, 0, time.Local) // a Monday
+	cases := []struct {
+		expr string
+		want time.Time
+	}{
+		{"*/15 * * * *", time.Date(2026, 1, 5, 10, 15, 0, 0, time.Local)},
+		{"0 9-17/4 * * *", time.Date(2026, 1, 5, 13, 0, 0, 0, time.Local)},
+		{"30 6 * * 0", time.Date(2026, 1, 11, 6, 30, 0, 0, time.Local)},
+		{"0 0 1,15 * *", time.Date(2026, 1, 15, 0, 0, 0, 0, time.Local)},
+		{"@hourly", time.Date(2026, 1, 5, 11, 0, 0, 0, time.Local)},
+		{"@every 90m", base.Add(90 * time.Minute)},
+	}
+	for _, tc := range cases {
+		sched, err := Parse(tc.expr)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.expr, err)
+		}
+		if got := sched.Next(base); !got.Equal(tc.want) {
+			t.Fatalf("%s: next = %s, want %s", tc.expr, got, tc.want)
+		}
+	}
+	for _, expr := range []string{"", "* * * *", "60 * * * *", "*/0 * * * *", "@every 10s", "a * * * *"} {
+		if _, err := Parse(expr); err == nil {
+			t.Fatalf("%q should be rejected", expr)
+		}
+	}
+}
@@ -0,0 +1,413 @@
+// Package scheduledprompt runs configured prompts on a schedule through the provider pool,
+// e.g. to generate nightly reports, and delivers every answer to a webhook and/or a file.
+// Runs go through the regular handler pipeline, so they share credentials, aliases and
+// routing with live traffic.
+package scheduledprompt
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/schedule"
+	log "github.com/sirupsen/logrus"
+	"github.com/tidwall/gjson"
+)
+
+// tickInterval is how often the scheduler looks for due prompts.
+const tickInterval = 15 * time.Second
+
+var defaultScheduler = NewScheduler()
+
+// Default returns the process-wide scheduler.
+func Default() *Scheduler { return defaultScheduler }
+
+// ExecuteFunc serves a request body of the handler type for model and returns the response
+// body.
+type ExecuteFunc func(ctx context.Context, handlerType, model string, body []byte) ([]byte, error)
+
+// Status is the run history of one scheduled prompt.
+type Status struct {
+	Name       string    `json:"name"`
+	Model      string    `json:"model"`
+	Schedule   string    `json:"schedule"`
+	NextRun    time.Time `json:"next_run,omitempty"`
+	LastRun    time.Time `json:"last_run,omitempty"`
+	Success    bool      `json:"success"`
+	DurationMs int64     `json:"duration_ms"`
+	Error      string    `json:"error,omitempty"`
+	File       string    `json:"file,omitempty"`
+	Runs       int64     `json:"runs"`
+	Failures   int64     `json:"failures"`
+}
+
+// Result is the outcome of one run, posted to the prompt's webhook.
+type Result struct {
+	Name       string    `json:"name"`
+	Model      string    `json:"model"`
+	RanAt      time.Time `json:"ran_at"`
+	DurationMs int64     `json:"duration_ms"`
+	Success    bool      `json:"success"`
+	Text       string    `json:"text,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	Usage      *Usage    `json:"usage,omitempty"`
+}
+
+// Usage is the token usage of a run.
+type Usage struct {
+	PromptTokens     int64 `json:"prompt_tokens"`
+	CompletionTokens int64 `json:"completion_tokens"`
+	TotalTokens      int64 `json:"total_tokens"`
+}
+
+type prompt struct {
+	cfg     config.ScheduledPrompt
+	sched   schedule.Schedule
+	next    time.Time
+	running bool
+	status  Status
+}
+
+// Scheduler runs the scheduled prompts.
+type Scheduler struct {
+	mu       sync.Mutex
+	cfg      config.ScheduledPromptsConfig
+	dir      string
+	prompts  []*prompt
+	execute  ExecuteFunc
+	client   *http.Client
+	cancel   context.CancelFunc
+	nowFunc  func() time.Time
+	tick     time.Duration
+	inflight sync.WaitGroup
+}
+
+// NewScheduler constructs a stopped scheduler.
+func NewScheduler() *Scheduler {
+	return &Scheduler{client: &http.Client{Timeout: 30 * time.Second}, nowFunc: time.Now, tick: tickInterval}
+}
+
+// SetExecutor installs the function runs are served by.
+func (s *Scheduler) SetExecutor(execute ExecuteFunc) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	s.execute = execute
+	s.mu.Unlock()
+}
+
+// Configure replaces the prompts and starts or stops the scheduler. fallbackDir is used
+// when cfg.Dir is empty. Prompts whose name and schedule are unchanged keep their next run
+// time and history.
+func (s *Scheduler) Configure(cfg config.ScheduledPromptsConfig, fallbackDir string) {
+	if s == nil {
+		return
+	}
+	dir := cfg.Dir
+	if dir == "" {
+		dir = fallbackDir
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	previous := make(map[string]*prompt, len(s.prompts))
+	for _, p := range s.prompts {
+		previous[p.cfg.Name+"\x00"+p.cfg.Schedule] = p
+	}
+	now := s.nowFunc()
+	prompts := make([]*prompt, 0, len(cfg.Prompts))
+	for _, pc := range cfg.Prompts {
+		sched, err := schedule.Parse(pc.Schedule)
+		if err != nil {
+			log.Warnf("scheduled-prompts: skipping %s: %v", pc.Name, err)
+			continue
+		}
+		p := &prompt{cfg: pc, sched: sched, next: sched.Next(now)}
+		if prev, ok := previous[pc.Name+"\x00"+pc.Schedule]; ok {
+			// Keep the running prompt so its in-flight run still clears running.
+			prev.cfg = pc
+			p = prev
+		}
+		p.status.Name, p.status.Model, p.status.Schedule = pc.Name, pc.Model, pc.Schedule
+		prompts = append(prompts, p)
+	}
+	s.cfg = cfg
+	s.dir = dir
+	s.prompts = prompts
+
+	switch {
+	case cfg.Enabled && len(prompts) > 0 && s.cancel == nil:
+		ctx, cancel := context.WithCancel(context.Background())
+		s.cancel = cancel
+		go s.run(ctx)
+	case (!cfg.Enabled || len(prompts) == 0) && s.cancel != nil:
+		s.cancel()
+		s.cancel = nil
+	}
+}
+
+// Stop halts the scheduler. Runs in flight finish.
+func (s *Scheduler) Stop() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.cancel != nil {
+		s.cancel()
+		s.cancel = nil
+	}
+}
+
+// Enabled reports whether the scheduler is on.
+func (s *Scheduler) Enabled() bool {
+	if s == nil {
+		return false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.cfg.Enabled
+}
+
+// RunNow starts the prompt name, or every prompt when name is empty, regardless of its
+// schedule and returns how many were started. Prompts still running are skipped.
+func (s *Scheduler) RunNow(name string) int {
+	if s == nil {
+		return 0
+	}
+	return s.startDue(true, name)
+}
+
+// Snapshot returns the run history of the prompts, sorted by name.
+func (s *Scheduler) Snapshot() []Status {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]Status, 0, len(s.prompts))
+	for _, p := range s.prompts {
+		st := p.status
+		st.NextRun = p.next
+		out = append(out, st)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+func (s *Scheduler) run(ctx context.Context) {
+	ticker := time.NewTicker(s.tick)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.startDue(false, "")
+		}
+	}
+}
+
+// startDue launches the prompts whose run time has come, or, when all is set, every idle
+// prompt named name (any name when empty).
+func (s *Scheduler) startDue(all bool, name string) int {
+	s.mu.Lock()
+	now := s.nowFunc()
+	execute := s.execute
+	dir := s.dir
+	timeout := time.Duration(s.cfg.TimeoutSeconds) * time.Second
+	var due []*prompt
+	for _, p := range s.prompts {
+		if p.running || (all && name != "" && p.cfg.Name != name) || (!all && (p.next.IsZero() || now.Before(p.next))) {
+			continue
+		}
+		p.running = true
+		if !all {
+			p.next = p.sched.Next(now)
+		}
+		due = append(due, p)
+	}
+	s.mu.Unlock()
+	if execute == nil {
+		s.finish(due, nil)
+		return 0
+	}
+	if timeout <= 0 {
+		timeout = config.DefaultScheduledPromptTimeoutSeconds * time.Second
+	}
+	for _, p := range due {
+		s.inflight.Add(1)
+		go func(p *prompt) {
+			defer s.inflight.Done()
+			s.runPrompt(execute, p, dir, timeout)
+		}(p)
+	}
+	return len(due)
+}
+
+func (s *Scheduler) finish(prompts []*prompt, record func(*Status)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, p := range prompts {
+		p.running = false
+		if record != nil {
+			record(&p.status)
+		}
+	}
+}
+
+// runPrompt requests the prompt's answer and delivers it.
+func (s *Scheduler) runPrompt(execute ExecuteFunc, p *prompt, dir string, timeout time.Duration) {
+	s.mu.Lock()
+	now := s.nowFunc()
+	cfg := p.cfg
+	s.mu.Unlock()
+	ranAt := now.UTC()
+
+	messages := make([]map[string]string, 0, 2)
+	if cfg.System != "" {
+		messages = append(messages, map[string]string{"role": "system", "content": expand(cfg.System, ranAt)})
+	}
+	messages = append(messages, map[string]string{"role": "user", "content": expand(cfg.Prompt, ranAt)})
+	request := map[string]any{"model": cfg.Model, "messages": messages, "stream": false}
+	if cfg.MaxTokens > 0 {
+		request["max_tokens"] = cfg.MaxTokens
+	}
+	body, _ := json.Marshal(request)
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	started := time.Now()
+	resp, err := execute(ctx, "openai", cfg.Model, body)
+	cancel()
+	result := Result{Name: cfg.Name, Model: cfg.Model, RanAt: ranAt, DurationMs: time.Since(started).Milliseconds()}
+	if err == nil {
+		result.Text, result.Usage, err = parseAnswer(resp)
+	}
+	result.Success = err == nil
+	if err != nil {
+		result.Error = err.Error()
+		log.Warnf("scheduled-prompts: %s on %s failed: %v", cfg.Name, cfg.Model, err)
+	} else {
+		log.Infof("scheduled-prompts: %s on %s answered in %dms", cfg.Name, cfg.Model, result.DurationMs)
+	}
+
+	var file string
+	var deliveryErrs []error
+	if result.Success && cfg.File != "" {
+		var errWrite error
+		if file, errWrite = writeAnswer(dir, expand(cfg.File, ranAt), result.Text); errWrite != nil {
+			deliveryErrs = append(deliveryErrs, errWrite)
+		}
+	}
+	if cfg.WebhookURL != "" {
+		if errPost := s.notify(cfg.WebhookURL, result); errPost != nil {
+			deliveryErrs = append(deliveryErrs, errPost)
+		}
+	}
+	deliveryErr := errors.Join(deliveryErrs...)
+	if deliveryErr != nil {
+		log.Errorf("scheduled-prompts: delivering %s: %v", cfg.Name, deliveryErr)
+	}
+
+	s.finish([]*prompt{p}, func(st *Status) {
+		st.LastRun, st.DurationMs, st.Runs = now, result.DurationMs, st.Runs+1
+		st.Success = result.Success && deliveryErr == nil
+		st.Error = result.Error
+		if deliveryErr != nil && st.Error == "" {
+			st.Error = deliveryErr.Error()
+		}
+		if file != "" {
+			st.File = file
+		}
+		if !st.Success {
+			st.Failures++
+		}
+	})
+}
+
+// parseAnswer returns the text and usage of a chat completion.
+func parseAnswer(resp []byte) (string, *Usage, error) {
+	root := gjson.ParseBytes(resp)
+	if !root.Get("choices").Exists() {
+		return "", nil, fmt.Errorf("unexpected response: %s", truncate(string(resp), 200))
+	}
+	content := root.Get("choices.0.message.content")
+	text := content.String()
+	if content.IsArray() {
+		var parts []string
+		for _, part := range content.Array() {
+			parts = append(parts, part.Get("text").String())
+		}
+		text = strings.Join(parts, "")
+	}
+	var usage *Usage
+	if u := root.Get("usage"); u.Exists() {
+		usage = &Usage{
+			PromptTokens:     u.Get("prompt_tokens").Int(),
+			CompletionTokens: u.Get("completion_tokens").Int(),
+			TotalTokens:      u.Get("total_tokens").Int(),
+		}
+	}
+	return text, usage, nil
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n] + "..."
+}
+
+// expand replaces the {date} and {datetime} placeholders with t.
+func expand(s string, t time.Time) string {
+	return strings.NewReplacer("{date}", t.Format("2006-01-02"), "{datetime}", t.Format("20060102-150405")).Replace(s)
+}
+
+// writeAnswer writes text to path, resolved against dir when relative, and returns the
+// written path.
+func writeAnswer(dir, path, text string) (string, error) {
+	if !filepath.IsAbs(path) {
+		if dir == "" {
+			return "", fmt.Errorf("no directory for relative file %s", path)
+		}
+		path = filepath.Join(dir, path)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(path, []byte(text), 0o600); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+func (s *Scheduler) notify(url string, result Result) error {
+	payload, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("invalid webhook url: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("webhook delivery failed: %w", err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+	return nil
+}
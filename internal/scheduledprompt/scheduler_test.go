@@ -0,0 +1,91 @@
+package scheduledprompt
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/tidwall/gjson"
+)
+
+func TestScheduler_RunsDuePromptsAndDelivers(t *testing.T) {
+	results := make(chan Result, 1)
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var result Result
+		_ = json.NewDecoder(r.Body).Decode(&result)
+		results <- result
+	}))
+	defer hook.Close()
+
+	now := time.Date(2026, 3, 9, 1, 59, 30, 0, time.UTC)
+	dir := t.TempDir()
+	var request []byte
+	s := NewScheduler()
+	s.nowFunc = func() time.Time { return now }
+	s.SetExecutor(func(ctx context.Context, handlerType, model string, body []byte) ([]byte, error) {
+		request = body
+		if model == "broken" {
+			return nil, errors.New("status 502: upstream down")
+		}
+		return []byte(`{"choices":[{"message":{"role":"assistant","content":"all quiet"}}],"usage":{"prompt_tokens":9,"completion_tokens":2,"total_tokens":11}}`), nil
+	})
+	s.Configure(config.ScheduledPromptsConfig{
+		TimeoutSeconds: 5,
+		Prompts: []config.ScheduledPrompt{
+			{Name: "nightly", Schedule: "0 2 * * *", Model: "m", System: "Be brief.", Prompt: "Report for {date}", MaxTokens: 64, File: "reports/{date}.md", WebhookURL: hook.URL},
+			{Name: "hourly", Schedule: "30 * * * *", Model: "broken", Prompt: "ping"},
+			{Name: "bad", Schedule: "bogus", Model: "m", Prompt: "x"},
+		},
+	}, dir)
+	if got := len(s.Snapshot()); got != 2 {
+		t.Fatalf("invalid schedules should be skipped, got %d prompts", got)
+	}
+	if started := s.startDue(false, ""); started != 0 {
+		t.Fatalf("nothing is due yet, started %d", started)
+	}
+
+	now = now.Add(time.Minute)
+	if started := s.startDue(false, ""); started != 1 {
+		t.Fatalf("started = %d, want the nightly prompt", started)
+	}
+	s.inflight.Wait()
+	if gjson.GetBytes(request, "messages.1.content").String() != "Report for 2026-03-09" || gjson.GetBytes(request, "max_tokens").Int() != 64 || gjson.GetBytes(request, "messages.0.role").String() != "system" {
+		t.Fatalf("request = %s", request)
+	}
+	written, err := os.ReadFile(filepath.Join(dir, "reports", "2026-03-09.md"))
+	if err != nil || string(written) != "all quiet" {
+		t.Fatalf("answer file = %q, %v", written, err)
+	}
+	select {
+	case result := <-results:
+		if !result.Success || result.Text != "all quiet" || result.Usage == nil || result.Usage.TotalTokens != 11 {
+			t.Fatalf("webhook result = %+v", result)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("webhook not called")
+	}
+
+	if started := s.RunNow("hourly"); started != 1 {
+		t.Fatalf("RunNow started %d", started)
+	}
+	s.inflight.Wait()
+	for _, st := range s.Snapshot() {
+		switch st.Name {
+		case "hourly":
+			if st.Success || st.Failures != 1 || st.Error == "" {
+				t.Fatalf("failed run status = %+v", st)
+			}
+		case "nightly":
+			if !st.Success || st.Runs != 1 || !st.NextRun.Equal(time.Date(2026, 3, 10, 2, 0, 0, 0, time.UTC)) {
+				t.Fatalf("nightly status = %+v", st)
+			}
+		}
+	}
+}
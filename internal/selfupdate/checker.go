@@ -0,0 +1,59 @@
+package selfupdate
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	log "github.com/sirupsen/logrus"
+)
+
+// StartChecker polls for releases newer than current in the background when
+// update-check.enabled is set, logging a notice once per new version. Development builds
+// are not checked. Changes to the settings take effect after a restart.
+func StartChecker(ctx context.Context, cfg *config.Config, current string) {
+	if cfg == nil || !cfg.UpdateCheck.Enabled {
+		return
+	}
+	if !IsReleaseVersion(current) {
+		log.Debugf("update check skipped: %q is not a release build", current)
+		return
+	}
+	interval := time.Duration(cfg.UpdateCheck.IntervalHours) * time.Hour
+	if interval <= 0 {
+		interval = config.DefaultUpdateCheckIntervalHours * time.Hour
+	}
+	releaseURL := ReleaseURL(cfg.UpdateCheck.Repository)
+	client := NewHTTPClient(cfg.ProxyURL)
+	client.Timeout = 15 * time.Second
+	go runChecker(ctx, client, releaseURL, current, interval)
+}
+
+func runChecker(ctx context.Context, client *http.Client, releaseURL, current string, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	notified := ""
+	for {
+		if latest, ok := checkOnce(ctx, client, releaseURL, current); ok && latest != notified {
+			notified = latest
+			log.Warnf("CLIProxyAPI %s is available (running %s); run \"self-update\" to install it", latest, current)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// checkOnce returns the latest release version when it is newer than current.
+func checkOnce(ctx context.Context, client *http.Client, releaseURL, current string) (string, bool) {
+	release, err := FetchLatest(ctx, client, releaseURL)
+	if err != nil {
+		log.WithError(err).Debug("update check failed")
+		return "", false
+	}
+	latest := release.Version()
+	return latest, IsNewer(latest, current)
+}
@@ -0,0 +1,127 @@
+package selfupdate
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// binaryNames lists the executable names release archives have shipped the proxy under.
+var binaryNames = []string{"cli-proxy-api", "cliproxyapi", "CLIProxyAPI"}
+
+func isBinaryName(name, goos string) bool {
+	base := path.Base(strings.ReplaceAll(name, "\\", "/"))
+	if goos == "windows" {
+		if !strings.HasSuffix(strings.ToLower(base), ".exe") {
+			return false
+		}
+		base = base[:len(base)-len(".exe")]
+	}
+	for _, candidate := range binaryNames {
+		if strings.EqualFold(base, candidate) {
+			return true
+		}
+	}
+	return false
+}
+
+// extractBinary returns the proxy executable from a .tar.gz or .zip release archive.
+func extractBinary(archiveName string, data []byte, goos string) ([]byte, error) {
+	if strings.HasSuffix(strings.ToLower(archiveName), ".zip") {
+		return extractZip(data, goos)
+	}
+	return extractTarGz(data, goos)
+}
+
+func extractTarGz(data []byte, goos string) ([]byte, error) {
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("open archive: %w", err)
+	}
+	defer func() {
+		_ = gz.Close()
+	}()
+	tr := tar.NewReader(gz)
+	for {
+		header, errNext := tr.Next()
+		if errors.Is(errNext, io.EOF) {
+			break
+		}
+		if errNext != nil {
+			return nil, fmt.Errorf("read archive: %w", errNext)
+		}
+		if header.Typeflag != tar.TypeReg || !isBinaryName(header.Name, goos) {
+			continue
+		}
+		return io.ReadAll(io.LimitReader(tr, maxArchiveSize))
+	}
+	return nil, fmt.Errorf("archive does not contain the proxy executable")
+}
+
+func extractZip(data []byte, goos string) ([]byte, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("open archive: %w", err)
+	}
+	for _, file := range zr.File {
+		if file.FileInfo().IsDir() || !isBinaryName(file.Name, goos) {
+			continue
+		}
+		rc, errOpen := file.Open()
+		if errOpen != nil {
+			return nil, fmt.Errorf("read archive: %w", errOpen)
+		}
+		binary, errRead := io.ReadAll(io.LimitReader(rc, maxArchiveSize))
+		_ = rc.Close()
+		return binary, errRead
+	}
+	return nil, fmt.Errorf("archive does not contain the proxy executable")
+}
+
+// replaceExecutable swaps binary in at executable. The running file is renamed rather than
+// overwritten, which works on Windows as well, and kept as "<executable>.old" so a broken
+// update can be rolled back by hand.
+func replaceExecutable(executable string, binary []byte) error {
+	mode := os.FileMode(0o755)
+	if info, err := os.Stat(executable); err == nil {
+		mode = info.Mode().Perm() | 0o100
+	}
+	dir := filepath.Dir(executable)
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(executable)+".new-*")
+	if err != nil {
+		return fmt.Errorf("stage update (is %s writable?): %w", dir, err)
+	}
+	tmpName := tmp.Name()
+	defer func() {
+		_ = tmp.Close()
+		_ = os.Remove(tmpName)
+	}()
+	if _, err = tmp.Write(binary); err != nil {
+		return fmt.Errorf("stage update: %w", err)
+	}
+	if err = tmp.Chmod(mode); err != nil {
+		return fmt.Errorf("stage update: %w", err)
+	}
+	if err = tmp.Close(); err != nil {
+		return fmt.Errorf("stage update: %w", err)
+	}
+
+	backup := executable + ".old"
+	_ = os.Remove(backup)
+	if err = os.Rename(executable, backup); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("move current executable aside: %w", err)
+	}
+	if err = os.Rename(tmpName, executable); err != nil {
+		_ = os.Rename(backup, executable)
+		return fmt.Errorf("install new executable: %w", err)
+	}
+	return nil
+}
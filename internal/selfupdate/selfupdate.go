@@ -0,0 +1,357 @@
+// Package selfupdate checks GitHub for newer proxy releases and replaces the running binary
+// with the release archive built for this platform after verifying its checksum.
+package selfupdate
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	sdkconfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+)
+
+const (
+	// DefaultReleaseURL is the GitHub API endpoint of the latest proxy release.
+	DefaultReleaseURL = "https://api.github.com/repos/router-for-me/CLIProxyAPI/releases/latest"
+
+	checksumsAssetName = "checksums.txt"
+	signatureAssetName = "checksums.txt.sig"
+	httpUserAgent      = "CLIProxyAPI-self-update"
+	// maxArchiveSize bounds release downloads.
+	maxArchiveSize = 200 << 20
+)
+
+// ErrNoAsset is returned when the release has no archive for the requested platform.
+var ErrNoAsset = errors.New("release has no archive for this platform")
+
+// Asset is a downloadable file attached to a release.
+type Asset struct {
+	Name               string `json:"name"`
+	BrowserDownloadURL string `json:"browser_download_url"`
+	Size               int64  `json:"size"`
+}
+
+// Release describes a published GitHub release.
+type Release struct {
+	TagName string  `json:"tag_name"`
+	Name    string  `json:"name"`
+	HTMLURL string  `json:"html_url"`
+	Assets  []Asset `json:"assets"`
+}
+
+// Version returns the release version, preferring the tag.
+func (r *Release) Version() string {
+	if tag := strings.TrimSpace(r.TagName); tag != "" {
+		return tag
+	}
+	return strings.TrimSpace(r.Name)
+}
+
+// asset returns the release asset with the given name.
+func (r *Release) asset(name string) (*Asset, bool) {
+	for i := range r.Assets {
+		if strings.EqualFold(r.Assets[i].Name, name) {
+			return &r.Assets[i], true
+		}
+	}
+	return nil, false
+}
+
+// ArchiveFor returns the release archive built for goos/goarch. Release archives are named
+// "<project>_<version>_<os>_<arch>.tar.gz" (.zip on Windows).
+func (r *Release) ArchiveFor(goos, goarch string) (*Asset, error) {
+	ext := ".tar.gz"
+	if goos == "windows" {
+		ext = ".zip"
+	}
+	suffix := strings.ToLower("_" + goos + "_" + goarch + ext)
+	for i := range r.Assets {
+		if strings.HasSuffix(strings.ToLower(r.Assets[i].Name), suffix) {
+			return &r.Assets[i], nil
+		}
+	}
+	return nil, fmt.Errorf("%w (%s/%s)", ErrNoAsset, goos, goarch)
+}
+
+// NewHTTPClient returns the client used for release requests, honouring proxyURL.
+func NewHTTPClient(proxyURL string) *http.Client {
+	client := &http.Client{Timeout: 5 * time.Minute}
+	if proxyURL = strings.TrimSpace(proxyURL); proxyURL != "" {
+		util.SetProxy(&sdkconfig.SDKConfig{ProxyURL: proxyURL}, client)
+	}
+	return client
+}
+
+// ReleaseURL resolves a repository override ("https://github.com/owner/repo" or an
+// api.github.com URL) to its latest release endpoint. Empty or unrecognised values fall back
+// to DefaultReleaseURL.
+func ReleaseURL(repository string) string {
+	repository = strings.TrimSpace(repository)
+	if repository == "" {
+		return DefaultReleaseURL
+	}
+	parsed, err := url.Parse(repository)
+	if err != nil || parsed.Host == "" {
+		return DefaultReleaseURL
+	}
+	path := strings.Trim(parsed.Path, "/")
+	switch strings.ToLower(parsed.Host) {
+	case "api.github.com":
+		if !strings.HasSuffix(strings.ToLower(path), "/releases/latest") {
+			path += "/releases/latest"
+		}
+		parsed.Path = "/" + path
+		return parsed.String()
+	case "github.com":
+		parts := strings.Split(path, "/")
+		if len(parts) >= 2 && parts[0] != "" && parts[1] != "" {
+			return fmt.Sprintf("https://api.github.com/repos/%s/%s/releases/latest", parts[0], strings.TrimSuffix(parts[1], ".git"))
+		}
+	}
+	return DefaultReleaseURL
+}
+
+// FetchLatest fetches the release metadata from releaseURL.
+func FetchLatest(ctx context.Context, client *http.Client, releaseURL string) (*Release, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, releaseURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("create release request: %w", err)
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("User-Agent", httpUserAgent)
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("execute release request: %w", err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("unexpected release status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	var release Release
+	if err = json.NewDecoder(resp.Body).Decode(&release); err != nil {
+		return nil, fmt.Errorf("decode release response: %w", err)
+	}
+	if release.Version() == "" {
+		return nil, fmt.Errorf("release response has no version")
+	}
+	return &release, nil
+}
+
+// IsNewer reports whether latest is a higher version than current. Versions are compared
+// numerically by their dot-separated components, ignoring a leading "v" and any pre-release
+// or build suffix. Unparseable versions (e.g. "dev") are never considered newer or older.
+func IsNewer(latest, current string) bool {
+	l, okLatest := parseVersion(latest)
+	c, okCurrent := parseVersion(current)
+	if !okLatest || !okCurrent {
+		return false
+	}
+	for i := 0; i < len(l) || i < len(c); i++ {
+		var lv, cv int
+		if i < len(l) {
+			lv = l[i]
+		}
+		if i < len(c) {
+			cv = c[i]
+		}
+		if lv != cv {
+			return lv > cv
+		}
+	}
+	return false
+}
+
+// IsReleaseVersion reports whether version looks like a released version rather than a
+// local development build.
+func IsReleaseVersion(version string) bool {
+	_, ok := parseVersion(version)
+	return ok
+}
+
+func parseVersion(version string) ([]int, bool) {
+	version = strings.TrimPrefix(strings.TrimSpace(version), "v")
+	if idx := strings.IndexAny(version, "-+"); idx >= 0 {
+		version = version[:idx]
+	}
+	if version == "" {
+		return nil, false
+	}
+	parts := strings.Split(version, ".")
+	out := make([]int, 0, len(parts))
+	for _, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return nil, false
+		}
+		out = append(out, n)
+	}
+	return out, true
+}
+
+// Options controls Update.
+type Options struct {
+	// Executable is the binary to replace. Defaults to the running executable.
+	Executable string
+	// PublicKey is a base64 ed25519 key; when set the checksums file must carry a valid
+	// signature.
+	PublicKey string
+	// GOOS and GOARCH select the archive. They default to the running platform.
+	GOOS   string
+	GOARCH string
+}
+
+// Update downloads the release archive for this platform, verifies it against the release
+// checksums (and signature when a public key is given) and replaces the executable. The
+// previous binary is kept next to it with an .old suffix until the next update.
+func Update(ctx context.Context, client *http.Client, release *Release, opts Options) (string, error) {
+	goos, goarch := opts.GOOS, opts.GOARCH
+	if goos == "" {
+		goos = runtime.GOOS
+	}
+	if goarch == "" {
+		goarch = runtime.GOARCH
+	}
+	executable := opts.Executable
+	if executable == "" {
+		var err error
+		if executable, err = currentExecutable(); err != nil {
+			return "", err
+		}
+	}
+
+	archive, err := release.ArchiveFor(goos, goarch)
+	if err != nil {
+		return "", err
+	}
+	checksumsAsset, ok := release.asset(checksumsAssetName)
+	if !ok {
+		return "", fmt.Errorf("release %s has no %s; refusing to install an unverified binary", release.Version(), checksumsAssetName)
+	}
+	checksums, err := download(ctx, client, checksumsAsset.BrowserDownloadURL, 1<<20)
+	if err != nil {
+		return "", fmt.Errorf("download %s: %w", checksumsAssetName, err)
+	}
+	if opts.PublicKey != "" {
+		if err = verifySignature(ctx, client, release, checksums, opts.PublicKey); err != nil {
+			return "", err
+		}
+	}
+	want, err := checksumFor(checksums, archive.Name)
+	if err != nil {
+		return "", err
+	}
+
+	data, err := download(ctx, client, archive.BrowserDownloadURL, maxArchiveSize)
+	if err != nil {
+		return "", fmt.Errorf("download %s: %w", archive.Name, err)
+	}
+	sum := sha256.Sum256(data)
+	if got := hex.EncodeToString(sum[:]); !strings.EqualFold(got, want) {
+		return "", fmt.Errorf("checksum mismatch for %s: expected %s, got %s", archive.Name, want, got)
+	}
+
+	binary, err := extractBinary(archive.Name, data, goos)
+	if err != nil {
+		return "", err
+	}
+	if err = replaceExecutable(executable, binary); err != nil {
+		return "", err
+	}
+	return executable, nil
+}
+
+func currentExecutable() (string, error) {
+	executable, err := os.Executable()
+	if err != nil {
+		return "", fmt.Errorf("resolve executable: %w", err)
+	}
+	if resolved, errEval := filepath.EvalSymlinks(executable); errEval == nil {
+		executable = resolved
+	}
+	return executable, nil
+}
+
+func verifySignature(ctx context.Context, client *http.Client, release *Release, checksums []byte, publicKey string) error {
+	key, err := base64.StdEncoding.DecodeString(publicKey)
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return fmt.Errorf("invalid update-check public key: expected a base64 ed25519 key")
+	}
+	sigAsset, ok := release.asset(signatureAssetName)
+	if !ok {
+		return fmt.Errorf("release %s has no %s but a public key is configured", release.Version(), signatureAssetName)
+	}
+	raw, err := download(ctx, client, sigAsset.BrowserDownloadURL, 4096)
+	if err != nil {
+		return fmt.Errorf("download %s: %w", signatureAssetName, err)
+	}
+	signature, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(raw)))
+	if err != nil {
+		// Accept raw binary signatures as well.
+		signature = raw
+	}
+	if !ed25519.Verify(ed25519.PublicKey(key), checksums, signature) {
+		return fmt.Errorf("signature of %s does not verify with the configured public key", checksumsAssetName)
+	}
+	return nil
+}
+
+// checksumFor looks up name in a "sha256  filename" checksums file.
+func checksumFor(checksums []byte, name string) (string, error) {
+	for _, line := range strings.Split(string(checksums), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		if strings.TrimPrefix(fields[1], "*") == name {
+			return strings.ToLower(fields[0]), nil
+		}
+	}
+	return "", fmt.Errorf("%s has no checksum for %s", checksumsAssetName, name)
+}
+
+func download(ctx context.Context, client *http.Client, downloadURL string, limit int64) ([]byte, error) {
+	if strings.TrimSpace(downloadURL) == "" {
+		return nil, fmt.Errorf("empty download url")
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, downloadURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("create download request: %w", err)
+	}
+	req.Header.Set("User-Agent", httpUserAgent)
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("execute download request: %w", err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected download status %d", resp.StatusCode)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, limit+1))
+	if err != nil {
+		return nil, fmt.Errorf("read download body: %w", err)
+	}
+	if int64(len(data)) > limit {
+		return nil, fmt.Errorf("download exceeds %d bytes", limit)
+	}
+	return data, nil
+}
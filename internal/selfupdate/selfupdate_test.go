@@ -0,0 +1,192 @@
+package selfupdate
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestIsNewer(t *testing.T) {
+	cases := []struct {
+		latest, current string
+		want            bool
+	}{
+		{"v6.6.10", "v6.6.9", true},
+		{"v6.6.9", "6.6.9", false},
+		{"v6.7", "v6.6.99", true},
+		{"v6.6.1", "v6.6.1-next", false},
+		{"v6.6.0", "v6.6.1", false},
+		{"v6.6.1", "dev", false},
+		{"nightly", "v6.6.1", false},
+	}
+	for _, tc := range cases {
+		if got := IsNewer(tc.latest, tc.current); got != tc.want {
+			t.Errorf("IsNewer(%q, %q) = %v, want %v", tc.latest, tc.current, got, tc.want)
+		}
+	}
+}
+
+func TestReleaseURL(t *testing.T) {
+	cases := map[string]string{
+		"":                                  DefaultReleaseURL,
+		"not a url":                         DefaultReleaseURL,
+		"https://github.com/acme/proxy.git": "https://api.github.com/repos/acme/proxy/releases/latest",
+		"https://api.github.com/repos/acme/proxy": "https://api.github.com/repos/acme/proxy/releases/latest",
+	}
+	for in, want := range cases {
+		if got := ReleaseURL(in); got != want {
+			t.Errorf("ReleaseURL(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+// releaseServer serves a release with a linux/amd64 archive containing binary.
+type releaseServer struct {
+	*httptest.Server
+	archive   []byte
+	checksums string
+	signature string
+}
+
+func newReleaseServer(t *testing.T, binary []byte) *releaseServer {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for name, data := range map[string][]byte{"README.md": []byte("readme"), "cli-proxy-api": binary} {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0o755, Size: int64(len(data)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write(data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	_ = tw.Close()
+	_ = gz.Close()
+
+	rs := &releaseServer{archive: buf.Bytes()}
+	sum := sha256.Sum256(rs.archive)
+	rs.checksums = fmt.Sprintf("%s  CLIProxyAPI_6.7.0_linux_amd64.tar.gz\n%s  CLIProxyAPI_6.7.0_darwin_arm64.tar.gz\n", hex.EncodeToString(sum[:]), strings.Repeat("0", 64))
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/release", func(w http.ResponseWriter, r *http.Request) {
+		assets := []Asset{
+			{Name: "CLIProxyAPI_6.7.0_linux_amd64.tar.gz", BrowserDownloadURL: rs.URL + "/archive"},
+			{Name: "checksums.txt", BrowserDownloadURL: rs.URL + "/checksums"},
+		}
+		if rs.signature != "" {
+			assets = append(assets, Asset{Name: "checksums.txt.sig", BrowserDownloadURL: rs.URL + "/sig"})
+		}
+		_ = json.NewEncoder(w).Encode(Release{TagName: "v6.7.0", Assets: assets})
+	})
+	mux.HandleFunc("/archive", func(w http.ResponseWriter, r *http.Request) { _, _ = w.Write(rs.archive) })
+	mux.HandleFunc("/checksums", func(w http.ResponseWriter, r *http.Request) { _, _ = w.Write([]byte(rs.checksums)) })
+	mux.HandleFunc("/sig", func(w http.ResponseWriter, r *http.Request) { _, _ = w.Write([]byte(rs.signature)) })
+	rs.Server = httptest.NewServer(mux)
+	t.Cleanup(rs.Close)
+	return rs
+}
+
+func (rs *releaseServer) update(t *testing.T, opts Options) (string, error) {
+	t.Helper()
+	ctx := context.Background()
+	release, err := FetchLatest(ctx, rs.Client(), rs.URL+"/release")
+	if err != nil {
+		t.Fatalf("FetchLatest: %v", err)
+	}
+	opts.GOOS, opts.GOARCH = "linux", "amd64"
+	return Update(ctx, rs.Client(), release, opts)
+}
+
+func writeExecutable(t *testing.T) string {
+	t.Helper()
+	exe := filepath.Join(t.TempDir(), "cli-proxy-api")
+	if err := os.WriteFile(exe, []byte("old binary"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return exe
+}
+
+func TestUpdateReplacesExecutable(t *testing.T) {
+	rs := newReleaseServer(t, []byte("new binary"))
+	exe := writeExecutable(t)
+
+	if _, err := rs.update(t, Options{Executable: exe}); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if got, _ := os.ReadFile(exe); string(got) != "new binary" {
+		t.Fatalf("executable = %q, want new binary", got)
+	}
+	if got, _ := os.ReadFile(exe + ".old"); string(got) != "old binary" {
+		t.Fatalf("backup = %q, want old binary", got)
+	}
+	if info, err := os.Stat(exe); err != nil || info.Mode().Perm()&0o100 == 0 {
+		t.Fatalf("new executable is not executable: %v %v", info, err)
+	}
+}
+
+func TestUpdateRejectsChecksumMismatch(t *testing.T) {
+	rs := newReleaseServer(t, []byte("new binary"))
+	rs.checksums = strings.Repeat("a", 64) + "  CLIProxyAPI_6.7.0_linux_amd64.tar.gz\n"
+	exe := writeExecutable(t)
+
+	_, err := rs.update(t, Options{Executable: exe})
+	if err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+		t.Fatalf("err = %v, want checksum mismatch", err)
+	}
+	if got, _ := os.ReadFile(exe); string(got) != "old binary" {
+		t.Fatalf("executable was modified: %q", got)
+	}
+}
+
+func TestUpdateVerifiesSignature(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicKey := base64.StdEncoding.EncodeToString(pub)
+	rs := newReleaseServer(t, []byte("new binary"))
+	exe := writeExecutable(t)
+
+	if _, err = rs.update(t, Options{Executable: exe, PublicKey: publicKey}); err == nil || !strings.Contains(err.Error(), "checksums.txt.sig") {
+		t.Fatalf("unsigned release: err = %v, want missing signature", err)
+	}
+
+	_, otherPriv, _ := ed25519.GenerateKey(nil)
+	rs.signature = base64.StdEncoding.EncodeToString(ed25519.Sign(otherPriv, []byte(rs.checksums)))
+	if _, err = rs.update(t, Options{Executable: exe, PublicKey: publicKey}); err == nil || !strings.Contains(err.Error(), "does not verify") {
+		t.Fatalf("wrong key: err = %v, want verification failure", err)
+	}
+
+	rs.signature = base64.StdEncoding.EncodeToString(ed25519.Sign(priv, []byte(rs.checksums)))
+	if _, err = rs.update(t, Options{Executable: exe, PublicKey: publicKey}); err != nil {
+		t.Fatalf("signed release: %v", err)
+	}
+	if got, _ := os.ReadFile(exe); string(got) != "new binary" {
+		t.Fatalf("executable = %q, want new binary", got)
+	}
+}
+
+func TestUpdateReportsMissingPlatform(t *testing.T) {
+	rs := newReleaseServer(t, []byte("new binary"))
+	release, err := FetchLatest(context.Background(), rs.Client(), rs.URL+"/release")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = release.ArchiveFor("windows", "arm64"); err == nil {
+		t.Fatal("expected ErrNoAsset for windows/arm64")
+	}
+}
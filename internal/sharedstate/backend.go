@@ -0,0 +1,148 @@
+// Package sharedstate coordinates several proxy instances through a shared Redis or
+// PostgreSQL backend: quota counters, credential cooldowns, and single-flight locks.
+package sharedstate
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+// Backend is the storage contract shared by every instance of a cluster.
+type Backend interface {
+	// Add atomically adds delta to the counter at key and returns the new total.
+	// A counter expires ttl after it was created.
+	Add(ctx context.Context, key string, delta int64, ttl time.Duration) (int64, error)
+	// SetUntil records a deadline under key, keeping the later one if a deadline already exists.
+	SetUntil(ctx context.Context, key string, until time.Time) error
+	// Deadlines returns every unexpired deadline whose key starts with prefix.
+	Deadlines(ctx context.Context, prefix string) (map[string]time.Time, error)
+	// ClearDeadline removes the deadline at key.
+	ClearDeadline(ctx context.Context, key string) error
+	// TryLock acquires (or extends, when already held by owner) the named lock for ttl.
+	TryLock(ctx context.Context, name, owner string, ttl time.Duration) (bool, error)
+	// Unlock releases the named lock when it is held by owner.
+	Unlock(ctx context.Context, name, owner string) error
+	// Close releases backend resources.
+	Close() error
+}
+
+// Open connects to the backend selected by cfg.
+func Open(ctx context.Context, cfg config.SharedStateConfig) (Backend, error) {
+	switch cfg.Backend {
+	case config.SharedStateBackendRedis:
+		return newRedisBackend(ctx, cfg.RedisURL)
+	case config.SharedStateBackendPostgres:
+		return newPostgresBackend(ctx, cfg.PostgresDSN, cfg.KeyPrefix)
+	default:
+		return nil, fmt.Errorf("shared state: unsupported backend %q", cfg.Backend)
+	}
+}
+
+// MemoryBackend is an in-process Backend, useful for tests and single-instance setups.
+type MemoryBackend struct {
+	mu        sync.Mutex
+	counters  map[string]memoryCounter
+	deadlines map[string]time.Time
+	locks     map[string]memoryLock
+	now       func() time.Time
+}
+
+type memoryCounter struct {
+	value   int64
+	expires time.Time
+}
+
+type memoryLock struct {
+	owner   string
+	expires time.Time
+}
+
+// NewMemoryBackend constructs an empty in-process backend.
+func NewMemoryBackend() *MemoryBackend {
+	return &MemoryBackend{
+		counters:  make(map[string]memoryCounter),
+		deadlines: make(map[string]time.Time),
+		locks:     make(map[string]memoryLock),
+		now:       time.Now,
+	}
+}
+
+// Add implements Backend.
+func (b *MemoryBackend) Add(_ context.Context, key string, delta int64, ttl time.Duration) (int64, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := b.now()
+	c, ok := b.counters[key]
+	if !ok || !now.Before(c.expires) {
+		c = memoryCounter{expires: now.Add(ttl)}
+	}
+	c.value += delta
+	b.counters[key] = c
+	return c.value, nil
+}
+
+// SetUntil implements Backend.
+func (b *MemoryBackend) SetUntil(_ context.Context, key string, until time.Time) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if current, ok := b.deadlines[key]; !ok || until.After(current) {
+		b.deadlines[key] = until
+	}
+	return nil
+}
+
+// Deadlines implements Backend.
+func (b *MemoryBackend) Deadlines(_ context.Context, prefix string) (map[string]time.Time, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := b.now()
+	out := make(map[string]time.Time)
+	for key, until := range b.deadlines {
+		if !until.After(now) {
+			delete(b.deadlines, key)
+			continue
+		}
+		if strings.HasPrefix(key, prefix) {
+			out[key] = until
+		}
+	}
+	return out, nil
+}
+
+// ClearDeadline implements Backend.
+func (b *MemoryBackend) ClearDeadline(_ context.Context, key string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.deadlines, key)
+	return nil
+}
+
+// TryLock implements Backend.
+func (b *MemoryBackend) TryLock(_ context.Context, name, owner string, ttl time.Duration) (bool, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := b.now()
+	if l, ok := b.locks[name]; ok && l.owner != owner && now.Before(l.expires) {
+		return false, nil
+	}
+	b.locks[name] = memoryLock{owner: owner, expires: now.Add(ttl)}
+	return true, nil
+}
+
+// Unlock implements Backend.
+func (b *MemoryBackend) Unlock(_ context.Context, name, owner string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if l, ok := b.locks[name]; ok && l.owner == owner {
+		delete(b.locks, name)
+	}
+	return nil
+}
+
+// Close implements Backend.
+func (b *MemoryBackend) Close() error { return nil }
@@ -0,0 +1,273 @@
+package sharedstate
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// ErrorCodeSharedCooldown is reported for credentials cooling down on another instance.
+	ErrorCodeSharedCooldown = "shared_cooldown"
+
+	refreshLockTTL = 2 * time.Minute
+	backendTimeout = 2 * time.Second
+)
+
+// Coordinator shares credential cooldowns, refresh locks and counters through a Backend.
+// It implements coreauth.AuthFilter and coreauth.RefreshLocker.
+type Coordinator struct {
+	backend  Backend
+	prefix   string
+	instance string
+	interval time.Duration
+	sharding bool
+	leaseTTL time.Duration
+
+	mu          sync.RWMutex
+	cooldowns   map[string]time.Time
+	source      CredentialSource
+	leases      map[string]Lease
+	leasesReady bool
+	cancel      context.CancelFunc
+}
+
+// NewCoordinator wraps backend using the prefix, instance ID and sync interval from cfg.
+func NewCoordinator(backend Backend, cfg config.SharedStateConfig) *Coordinator {
+	interval := time.Duration(cfg.SyncIntervalSeconds) * time.Second
+	if interval <= 0 {
+		interval = config.DefaultSharedStateSyncIntervalSeconds * time.Second
+	}
+	if cfg.LeaseSeconds <= 0 {
+		cfg.LeaseSeconds = config.DefaultCredentialLeaseSeconds
+	}
+	return &Coordinator{
+		backend:   backend,
+		prefix:    cfg.KeyPrefix,
+		instance:  cfg.InstanceID,
+		interval:  interval,
+		sharding:  cfg.ShardCredentials,
+		leaseTTL:  time.Duration(cfg.LeaseSeconds) * time.Second,
+		cooldowns: make(map[string]time.Time),
+		leases:    make(map[string]Lease),
+	}
+}
+
+// Instance returns the identifier this coordinator uses as lock owner.
+func (c *Coordinator) Instance() string { return c.instance }
+
+// Backend returns the underlying backend.
+func (c *Coordinator) Backend() Backend { return c.backend }
+
+func (c *Coordinator) key(parts ...string) string {
+	return c.prefix + strings.Join(parts, ":")
+}
+
+func cooldownID(authID, model string) string {
+	return authID + "|" + model
+}
+
+// Start launches the background loop that pulls cooldowns recorded by other instances.
+func (c *Coordinator) Start(ctx context.Context) {
+	ctx, cancel := context.WithCancel(ctx)
+	c.mu.Lock()
+	c.cancel = cancel
+	c.mu.Unlock()
+	go func() {
+		ticker := time.NewTicker(c.interval)
+		defer ticker.Stop()
+		for {
+			c.sync(ctx)
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Stop stops the sync loop, releases held credential leases and closes the backend.
+func (c *Coordinator) Stop() {
+	c.mu.Lock()
+	cancel := c.cancel
+	c.cancel = nil
+	c.mu.Unlock()
+	if cancel != nil {
+		cancel()
+	}
+	c.releaseLeases()
+	if err := c.backend.Close(); err != nil {
+		log.Debugf("shared state: close backend: %v", err)
+	}
+}
+
+func (c *Coordinator) sync(ctx context.Context) {
+	syncCtx, cancel := context.WithTimeout(ctx, backendTimeout)
+	defer cancel()
+	prefix := c.key("cooldown", "")
+	deadlines, err := c.backend.Deadlines(syncCtx, prefix)
+	if err != nil {
+		if ctx.Err() == nil {
+			log.Warnf("shared state: sync cooldowns: %v", err)
+		}
+		return
+	}
+	next := make(map[string]time.Time, len(deadlines))
+	for key, until := range deadlines {
+		next[strings.TrimPrefix(key, prefix)] = until
+	}
+	c.mu.Lock()
+	c.cooldowns = next
+	c.mu.Unlock()
+
+	if c.sharding {
+		c.syncLeases(ctx)
+	}
+}
+
+// FilterAuth implements coreauth.AuthFilter using the last synced cooldowns and leases, so
+// selection never waits on the backend.
+func (c *Coordinator) FilterAuth(_ context.Context, auth *coreauth.Auth, model string) error {
+	if c == nil || auth == nil {
+		return nil
+	}
+	now := time.Now()
+	c.mu.RLock()
+	until := c.cooldowns[cooldownID(auth.ID, "")]
+	if model != "" {
+		if modelUntil := c.cooldowns[cooldownID(auth.ID, model)]; modelUntil.After(until) {
+			until = modelUntil
+		}
+	}
+	errLease := c.filterLease(auth)
+	c.mu.RUnlock()
+	if !until.After(now) {
+		return errLease
+	}
+	return &coreauth.Error{
+		Code:       ErrorCodeSharedCooldown,
+		Message:    fmt.Sprintf("credential %s is cooling down until %s", auth.ID, until.UTC().Format(time.RFC3339)),
+		Retryable:  true,
+		HTTPStatus: http.StatusTooManyRequests,
+	}
+}
+
+// ObserveResult publishes cooldowns entered by a failed request. It is registered as a
+// coreauth.ResultObserver.
+func (c *Coordinator) ObserveResult(_ context.Context, result coreauth.Result, auth *coreauth.Auth) {
+	if c == nil || result.Success || auth == nil {
+		return
+	}
+	until := auth.NextRetryAfter
+	model := ""
+	if result.Model != "" {
+		if state := auth.ModelStates[result.Model]; state != nil {
+			until = state.NextRetryAfter
+			model = result.Model
+		}
+	}
+	if !until.After(time.Now()) {
+		return
+	}
+	id := cooldownID(auth.ID, model)
+	c.mu.Lock()
+	if until.After(c.cooldowns[id]) {
+		c.cooldowns[id] = until
+	}
+	c.mu.Unlock()
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), backendTimeout)
+		defer cancel()
+		if err := c.backend.SetUntil(ctx, c.key("cooldown", id), until); err != nil {
+			log.Warnf("shared state: publish cooldown for %s: %v", auth.ID, err)
+		}
+	}()
+}
+
+// TryLockRefresh implements coreauth.RefreshLocker. Backend failures fail open so a broken
+// backend never stops credentials from being refreshed.
+func (c *Coordinator) TryLockRefresh(ctx context.Context, authID string) (func(), bool) {
+	name := c.key("refresh", authID)
+	lockCtx, cancel := context.WithTimeout(ctx, backendTimeout)
+	defer cancel()
+	ok, err := c.backend.TryLock(lockCtx, name, c.instance, refreshLockTTL)
+	if err != nil {
+		log.Warnf("shared state: refresh lock for %s: %v", authID, err)
+		return func() {}, true
+	}
+	if !ok {
+		return nil, false
+	}
+	return func() {
+		unlockCtx, cancelUnlock := context.WithTimeout(context.Background(), backendTimeout)
+		defer cancelUnlock()
+		if errUnlock := c.backend.Unlock(unlockCtx, name, c.instance); errUnlock != nil {
+			log.Debugf("shared state: release refresh lock for %s: %v", authID, errUnlock)
+		}
+	}, true
+}
+
+// Add increments a shared counter namespaced under the coordinator prefix.
+func (c *Coordinator) Add(ctx context.Context, key string, delta int64, ttl time.Duration) (int64, error) {
+	addCtx, cancel := context.WithTimeout(ctx, backendTimeout)
+	defer cancel()
+	return c.backend.Add(addCtx, c.key("counter", key), delta, ttl)
+}
+
+var (
+	activeMu sync.Mutex
+	active   *Coordinator
+)
+
+// Apply connects to the backend described by cfg and installs the coordinator on manager,
+// replacing any previously active one. It returns nil when shared state is disabled.
+func Apply(cfg config.SharedStateConfig, manager *coreauth.Manager) (*Coordinator, error) {
+	activeMu.Lock()
+	defer activeMu.Unlock()
+	if active != nil {
+		active.Stop()
+		active = nil
+	}
+	if manager != nil {
+		manager.RegisterAuthFilter("shared-cooldown", nil)
+		manager.RegisterResultObserver("shared-cooldown", nil)
+		manager.SetRefreshLocker(nil)
+	}
+	if cfg.Backend == "" {
+		return nil, nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	backend, err := Open(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	coordinator := NewCoordinator(backend, cfg)
+	if manager != nil {
+		coordinator.SetCredentialSource(manager.List)
+	}
+	coordinator.Start(context.Background())
+	if manager != nil {
+		manager.RegisterAuthFilter("shared-cooldown", coordinator)
+		manager.RegisterResultObserver("shared-cooldown", coordinator.ObserveResult)
+		manager.SetRefreshLocker(coordinator)
+	}
+	active = coordinator
+	log.Infof("shared state: using %s backend as instance %s (credential sharding: %t)", cfg.Backend, cfg.InstanceID, cfg.ShardCredentials)
+	return coordinator, nil
+}
+
+// Active returns the currently installed coordinator, or nil.
+func Active() *Coordinator {
+	activeMu.Lock()
+	defer activeMu.Unlock()
+	return active
+}
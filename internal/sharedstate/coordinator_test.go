@@ -0,0 +1,143 @@
+package sharedstate
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+)
+
+func newTestPair(t *testing.T) (*Coordinator, *Coordinator) {
+	t.Helper()
+	backend := NewMemoryBackend()
+	a := NewCoordinator(backend, config.SharedStateConfig{KeyPrefix: "test:", InstanceID: "a"})
+	b := NewCoordinator(backend, config.SharedStateConfig{KeyPrefix: "test:", InstanceID: "b"})
+	return a, b
+}
+
+func TestCoordinator_SharesCooldowns(t *testing.T) {
+	a, b := newTestPair(t)
+	auth := &coreauth.Auth{
+		ID: "claude-1",
+		ModelStates: map[string]*coreauth.ModelState{
+			"claude-sonnet-4": {NextRetryAfter: time.Now().Add(time.Minute)},
+		},
+	}
+	a.ObserveResult(context.Background(), coreauth.Result{AuthID: auth.ID, Model: "claude-sonnet-4"}, auth)
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		b.sync(context.Background())
+		if b.FilterAuth(context.Background(), auth, "claude-sonnet-4") != nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("expected cooldown published by instance a to exclude the credential on b")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err := b.FilterAuth(context.Background(), auth, "claude-opus-4"); err != nil {
+		t.Fatalf("cooldown should be scoped to the failing model, got %v", err)
+	}
+}
+
+func TestCoordinator_RefreshLockIsExclusive(t *testing.T) {
+	a, b := newTestPair(t)
+	release, ok := a.TryLockRefresh(context.Background(), "codex-1")
+	if !ok {
+		t.Fatal("expected a to acquire the refresh lock")
+	}
+	if _, okB := b.TryLockRefresh(context.Background(), "codex-1"); okB {
+		t.Fatal("expected b to be locked out while a refreshes")
+	}
+	release()
+	if _, okB := b.TryLockRefresh(context.Background(), "codex-1"); !okB {
+		t.Fatal("expected b to acquire the lock after release")
+	}
+}
+
+func TestMemoryBackend_AddExpires(t *testing.T) {
+	backend := NewMemoryBackend()
+	now := time.Now()
+	backend.now = func() time.Time { return now }
+	ctx := context.Background()
+	if v, _ := backend.Add(ctx, "k", 2, time.Minute); v != 2 {
+		t.Fatalf("expected 2, got %d", v)
+	}
+	if v, _ := backend.Add(ctx, "k", 3, time.Minute); v != 5 {
+		t.Fatalf("expected 5, got %d", v)
+	}
+	now = now.Add(2 * time.Minute)
+	if v, _ := backend.Add(ctx, "k", 1, time.Minute); v != 1 {
+		t.Fatalf("expected counter to reset after ttl, got %d", v)
+	}
+}
+
+func TestCoordinator_ShardsCredentialsAcrossInstances(t *testing.T) {
+	backend := NewMemoryBackend()
+	pool := []*coreauth.Auth{
+		{ID: "claude-1", Provider: "claude"},
+		{ID: "claude-2", Provider: "claude"},
+		{ID: "claude-3", Provider: "claude"},
+		{ID: "claude-4", Provider: "claude"},
+		{ID: "gemini-key", Provider: "gemini", Attributes: map[string]string{"api_key": "k"}},
+	}
+	source := func() []*coreauth.Auth { return pool }
+	cfg := config.SharedStateConfig{KeyPrefix: "test:", ShardCredentials: true, LeaseSeconds: 30}
+	cfg.InstanceID = "a"
+	a := NewCoordinator(backend, cfg)
+	cfg.InstanceID = "b"
+	b := NewCoordinator(backend, cfg)
+	a.SetCredentialSource(source)
+	b.SetCredentialSource(source)
+
+	ctx := context.Background()
+	a.sync(ctx)
+	b.sync(ctx)
+	// a only learns about b on its second round and releases what moved to b; b claims those
+	// on its next round once they are free.
+	a.sync(ctx)
+	b.sync(ctx)
+
+	owners := make(map[string]string)
+	for _, c := range []*Coordinator{a, b} {
+		for _, lease := range c.Leases() {
+			if prev, dup := owners[lease.AuthID]; dup {
+				t.Fatalf("credential %s leased by both %s and %s", lease.AuthID, prev, c.Instance())
+			}
+			owners[lease.AuthID] = c.Instance()
+		}
+	}
+	for _, auth := range pool[:4] {
+		owner, ok := owners[auth.ID]
+		if !ok {
+			t.Fatalf("credential %s not leased by any instance", auth.ID)
+		}
+		other := a
+		if owner == "a" {
+			other = b
+		}
+		if other.FilterAuth(ctx, auth, "") == nil {
+			t.Fatalf("instance %s should not use %s leased by %s", other.Instance(), auth.ID, owner)
+		}
+	}
+	if _, ok := owners["gemini-key"]; ok {
+		t.Fatal("api key credentials must not be leased")
+	}
+	if err := a.FilterAuth(ctx, pool[4], ""); err != nil {
+		t.Fatalf("api key credential should stay shared, got %v", err)
+	}
+
+	released := a.Leases()
+	a.releaseLeases()
+	if alive, _ := backend.Deadlines(ctx, a.key("instance", "")); len(alive) != 1 {
+		t.Fatalf("instances alive after a left = %v, want only b", alive)
+	}
+	for _, lease := range released {
+		if ok, _ := backend.TryLock(ctx, "test:lease:"+lease.AuthID, "b", time.Minute); !ok {
+			t.Fatalf("expected lease on %s to be released on stop", lease.AuthID)
+		}
+	}
+}
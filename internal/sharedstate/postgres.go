@@ -0,0 +1,130 @@
+package sharedstate
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	_ "github.com/jackc/pgx/v5/stdlib"
+)
+
+type postgresBackend struct {
+	db        *sql.DB
+	counters  string
+	deadlines string
+	locks     string
+}
+
+// tablePrefix turns a key prefix such as "cliproxy:" into a safe identifier prefix.
+func tablePrefix(prefix string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(prefix) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			b.WriteRune(r)
+		} else {
+			b.WriteByte('_')
+		}
+	}
+	out := strings.TrimRight(b.String(), "_")
+	if out == "" {
+		out = "cliproxy"
+	}
+	return out + "_"
+}
+
+func newPostgresBackend(ctx context.Context, dsn, prefix string) (*postgresBackend, error) {
+	if dsn == "" {
+		return nil, fmt.Errorf("shared state: postgres-dsn is required")
+	}
+	db, err := sql.Open("pgx", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("shared state: open postgres: %w", err)
+	}
+	if err = db.PingContext(ctx); err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("shared state: ping postgres: %w", err)
+	}
+	p := tablePrefix(prefix)
+	b := &postgresBackend{db: db, counters: p + "counters", deadlines: p + "deadlines", locks: p + "locks"}
+	statements := []string{
+		fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (key TEXT PRIMARY KEY, value BIGINT NOT NULL, expires_at TIMESTAMPTZ NOT NULL)`, b.counters),
+		fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (key TEXT PRIMARY KEY, until TIMESTAMPTZ NOT NULL)`, b.deadlines),
+		fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (name TEXT PRIMARY KEY, owner TEXT NOT NULL, expires_at TIMESTAMPTZ NOT NULL)`, b.locks),
+	}
+	for _, stmt := range statements {
+		if _, err = db.ExecContext(ctx, stmt); err != nil {
+			_ = db.Close()
+			return nil, fmt.Errorf("shared state: create tables: %w", err)
+		}
+	}
+	return b, nil
+}
+
+func (b *postgresBackend) Add(ctx context.Context, key string, delta int64, ttl time.Duration) (int64, error) {
+	query := fmt.Sprintf(`
+		INSERT INTO %[1]s AS t (key, value, expires_at) VALUES ($1, $2, NOW() + $3 * INTERVAL '1 millisecond')
+		ON CONFLICT (key) DO UPDATE SET
+			value = CASE WHEN t.expires_at <= NOW() THEN EXCLUDED.value ELSE t.value + EXCLUDED.value END,
+			expires_at = CASE WHEN t.expires_at <= NOW() THEN EXCLUDED.expires_at ELSE t.expires_at END
+		RETURNING value`, b.counters)
+	var value int64
+	err := b.db.QueryRowContext(ctx, query, key, delta, ttl.Milliseconds()).Scan(&value)
+	return value, err
+}
+
+func (b *postgresBackend) SetUntil(ctx context.Context, key string, until time.Time) error {
+	query := fmt.Sprintf(`
+		INSERT INTO %[1]s AS t (key, until) VALUES ($1, $2)
+		ON CONFLICT (key) DO UPDATE SET until = GREATEST(t.until, EXCLUDED.until)`, b.deadlines)
+	_, err := b.db.ExecContext(ctx, query, key, until.UTC())
+	return err
+}
+
+func (b *postgresBackend) Deadlines(ctx context.Context, prefix string) (map[string]time.Time, error) {
+	if _, err := b.db.ExecContext(ctx, fmt.Sprintf(`DELETE FROM %s WHERE until < NOW() - INTERVAL '1 hour'`, b.deadlines)); err != nil {
+		return nil, err
+	}
+	rows, err := b.db.QueryContext(ctx, fmt.Sprintf(`SELECT key, until FROM %s WHERE left(key, length($1)) = $1 AND until > NOW()`, b.deadlines), prefix)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+	out := make(map[string]time.Time)
+	for rows.Next() {
+		var key string
+		var until time.Time
+		if err = rows.Scan(&key, &until); err != nil {
+			return nil, err
+		}
+		out[key] = until
+	}
+	return out, rows.Err()
+}
+
+func (b *postgresBackend) ClearDeadline(ctx context.Context, key string) error {
+	_, err := b.db.ExecContext(ctx, fmt.Sprintf(`DELETE FROM %s WHERE key = $1`, b.deadlines), key)
+	return err
+}
+
+func (b *postgresBackend) TryLock(ctx context.Context, name, owner string, ttl time.Duration) (bool, error) {
+	query := fmt.Sprintf(`
+		INSERT INTO %[1]s AS l (name, owner, expires_at) VALUES ($1, $2, NOW() + $3 * INTERVAL '1 millisecond')
+		ON CONFLICT (name) DO UPDATE SET owner = EXCLUDED.owner, expires_at = EXCLUDED.expires_at
+		WHERE l.owner = EXCLUDED.owner OR l.expires_at <= NOW()
+		RETURNING owner`, b.locks)
+	var got string
+	err := b.db.QueryRowContext(ctx, query, name, owner, ttl.Milliseconds()).Scan(&got)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (b *postgresBackend) Unlock(ctx context.Context, name, owner string) error {
+	_, err := b.db.ExecContext(ctx, fmt.Sprintf(`DELETE FROM %s WHERE name = $1 AND owner = $2`, b.locks), name, owner)
+	return err
+}
+
+func (b *postgresBackend) Close() error { return b.db.Close() }
@@ -0,0 +1,121 @@
+package sharedstate
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+var (
+	redisAddScript = redis.NewScript(`
+local v = redis.call('INCRBY', KEYS[1], ARGV[1])
+if redis.call('PTTL', KEYS[1]) < 0 then
+	redis.call('PEXPIRE', KEYS[1], ARGV[2])
+end
+return v`)
+
+	redisSetUntilScript = redis.NewScript(`
+local cur = redis.call('GET', KEYS[1])
+if (not cur) or tonumber(cur) < tonumber(ARGV[1]) then
+	redis.call('SET', KEYS[1], ARGV[1], 'PX', ARGV[2])
+end
+return 1`)
+
+	redisTryLockScript = redis.NewScript(`
+local cur = redis.call('GET', KEYS[1])
+if (not cur) or cur == ARGV[1] then
+	redis.call('SET', KEYS[1], ARGV[1], 'PX', ARGV[2])
+	return 1
+end
+return 0`)
+
+	redisUnlockScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0`)
+)
+
+type redisBackend struct {
+	client *redis.Client
+}
+
+func newRedisBackend(ctx context.Context, url string) (*redisBackend, error) {
+	if url == "" {
+		return nil, fmt.Errorf("shared state: redis-url is required")
+	}
+	opts, err := redis.ParseURL(url)
+	if err != nil {
+		return nil, fmt.Errorf("shared state: parse redis url: %w", err)
+	}
+	client := redis.NewClient(opts)
+	if err = client.Ping(ctx).Err(); err != nil {
+		_ = client.Close()
+		return nil, fmt.Errorf("shared state: ping redis: %w", err)
+	}
+	return &redisBackend{client: client}, nil
+}
+
+func (b *redisBackend) Add(ctx context.Context, key string, delta int64, ttl time.Duration) (int64, error) {
+	return redisAddScript.Run(ctx, b.client, []string{key}, delta, ttl.Milliseconds()).Int64()
+}
+
+func (b *redisBackend) SetUntil(ctx context.Context, key string, until time.Time) error {
+	ttl := time.Until(until)
+	if ttl <= 0 {
+		return nil
+	}
+	return redisSetUntilScript.Run(ctx, b.client, []string{key}, until.UnixMilli(), ttl.Milliseconds()).Err()
+}
+
+func (b *redisBackend) ClearDeadline(ctx context.Context, key string) error {
+	return b.client.Del(ctx, key).Err()
+}
+
+func (b *redisBackend) Deadlines(ctx context.Context, prefix string) (map[string]time.Time, error) {
+	out := make(map[string]time.Time)
+	iter := b.client.Scan(ctx, 0, prefix+"*", 256).Iterator()
+	var keys []string
+	for iter.Next(ctx) {
+		keys = append(keys, iter.Val())
+	}
+	if err := iter.Err(); err != nil {
+		return nil, err
+	}
+	if len(keys) == 0 {
+		return out, nil
+	}
+	values, err := b.client.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	for i, raw := range values {
+		s, ok := raw.(string)
+		if !ok {
+			continue
+		}
+		ms, errParse := strconv.ParseInt(s, 10, 64)
+		if errParse != nil {
+			continue
+		}
+		if until := time.UnixMilli(ms); until.After(now) {
+			out[keys[i]] = until
+		}
+	}
+	return out, nil
+}
+
+func (b *redisBackend) TryLock(ctx context.Context, name, owner string, ttl time.Duration) (bool, error) {
+	v, err := redisTryLockScript.Run(ctx, b.client, []string{name}, owner, ttl.Milliseconds()).Int64()
+	return v == 1, err
+}
+
+func (b *redisBackend) Unlock(ctx context.Context, name, owner string) error {
+	return redisUnlockScript.Run(ctx, b.client, []string{name}, owner).Err()
+}
+
+func (b *redisBackend) Close() error { return b.client.Close() }
@@ -0,0 +1,197 @@
+package sharedstate
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	log "github.com/sirupsen/logrus"
+)
+
+// ErrorCodeCredentialLeased is reported for credentials leased by another instance.
+const ErrorCodeCredentialLeased = "credential_leased_elsewhere"
+
+// CredentialSource lists the credentials considered for sharding.
+type CredentialSource func() []*coreauth.Auth
+
+// Lease describes a credential held by this instance.
+type Lease struct {
+	AuthID   string `json:"auth_id"`
+	Provider string `json:"provider"`
+}
+
+// SetCredentialSource configures where sharding reads the credential pool from.
+func (c *Coordinator) SetCredentialSource(source CredentialSource) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	c.source = source
+	c.mu.Unlock()
+}
+
+// shardable reports whether auth takes part in sharding. API-key credentials are stateless
+// and safe to share, so only account-backed credentials are leased.
+func shardable(auth *coreauth.Auth) bool {
+	if auth == nil || auth.Disabled {
+		return false
+	}
+	return auth.Attributes["api_key"] == ""
+}
+
+// rendezvousOwner picks the member with the highest hash for authID, so every instance
+// derives the same assignment from the same membership and a membership change only moves
+// the credentials of the instance that joined or left.
+func rendezvousOwner(authID string, members []string) string {
+	var owner string
+	var best uint64
+	for _, member := range members {
+		h := fnv.New64a()
+		_, _ = h.Write([]byte(member))
+		_, _ = h.Write([]byte{'|'})
+		_, _ = h.Write([]byte(authID))
+		if score := h.Sum64(); owner == "" || score > best || (score == best && member < owner) {
+			owner, best = member, score
+		}
+	}
+	return owner
+}
+
+// syncLeases heartbeats this instance, then claims the credentials assigned to it and
+// releases the ones that moved elsewhere. A claim only succeeds once the previous owner
+// released the lease or let it expire, so two instances never hold the same credential.
+func (c *Coordinator) syncLeases(ctx context.Context) {
+	c.mu.RLock()
+	source := c.source
+	held := make(map[string]Lease, len(c.leases))
+	for id, lease := range c.leases {
+		held[id] = lease
+	}
+	c.mu.RUnlock()
+	if source == nil {
+		return
+	}
+
+	syncCtx, cancel := context.WithTimeout(ctx, backendTimeout)
+	defer cancel()
+	now := time.Now()
+	if err := c.backend.SetUntil(syncCtx, c.key("instance", c.instance), now.Add(c.leaseTTL)); err != nil {
+		c.leaseFailure(ctx, "heartbeat", err)
+		return
+	}
+	prefix := c.key("instance", "")
+	alive, err := c.backend.Deadlines(syncCtx, prefix)
+	if err != nil {
+		c.leaseFailure(ctx, "list instances", err)
+		return
+	}
+	members := make([]string, 0, len(alive)+1)
+	members = append(members, c.instance)
+	for key := range alive {
+		if id := strings.TrimPrefix(key, prefix); id != c.instance {
+			members = append(members, id)
+		}
+	}
+
+	next := make(map[string]Lease)
+	for _, auth := range source() {
+		if !shardable(auth) {
+			continue
+		}
+		name := c.key("lease", auth.ID)
+		if rendezvousOwner(auth.ID, members) != c.instance {
+			if _, ok := held[auth.ID]; ok {
+				if errUnlock := c.backend.Unlock(syncCtx, name, c.instance); errUnlock != nil {
+					log.Debugf("shared state: release lease for %s: %v", auth.ID, errUnlock)
+				}
+			}
+			continue
+		}
+		ok, errLock := c.backend.TryLock(syncCtx, name, c.instance, c.leaseTTL)
+		if errLock != nil {
+			c.leaseFailure(ctx, "lease "+auth.ID, errLock)
+			return
+		}
+		if ok {
+			next[auth.ID] = Lease{AuthID: auth.ID, Provider: strings.ToLower(auth.Provider)}
+		}
+	}
+
+	c.mu.Lock()
+	c.leases = next
+	c.leasesReady = true
+	c.mu.Unlock()
+}
+
+// leaseFailure keeps enforcing the last synced leases until the backend recovers, so an
+// outage keeps this instance on its own shard instead of overlapping its peers.
+func (c *Coordinator) leaseFailure(ctx context.Context, step string, err error) {
+	if ctx.Err() == nil {
+		log.Warnf("shared state: credential sharding %s: %v", step, err)
+	}
+}
+
+// releaseLeases drops every lease held by this instance and its heartbeat, so peers see it
+// leave and claim its credentials right away.
+func (c *Coordinator) releaseLeases() {
+	c.mu.Lock()
+	held := c.leases
+	c.leases = make(map[string]Lease)
+	c.leasesReady = false
+	c.mu.Unlock()
+	ctx, cancel := context.WithTimeout(context.Background(), backendTimeout)
+	defer cancel()
+	if c.sharding {
+		if err := c.backend.ClearDeadline(ctx, c.key("instance", c.instance)); err != nil {
+			log.Debugf("shared state: clear heartbeat: %v", err)
+		}
+	}
+	for id := range held {
+		if err := c.backend.Unlock(ctx, c.key("lease", id), c.instance); err != nil {
+			log.Debugf("shared state: release lease for %s: %v", id, err)
+		}
+	}
+}
+
+// filterLease restricts routing to the credentials leased by this instance once the first
+// lease sync completed; an instance without a lease for a provider serves none of its
+// account credentials rather than overlapping the instances that hold them.
+// Callers must hold c.mu.
+func (c *Coordinator) filterLease(auth *coreauth.Auth) error {
+	if !c.sharding || !c.leasesReady || !shardable(auth) {
+		return nil
+	}
+	if _, ok := c.leases[auth.ID]; ok {
+		return nil
+	}
+	return &coreauth.Error{
+		Code:       ErrorCodeCredentialLeased,
+		Message:    fmt.Sprintf("credential %s is leased by another instance", auth.ID),
+		HTTPStatus: http.StatusServiceUnavailable,
+	}
+}
+
+// Leases returns the credentials currently leased by this instance.
+func (c *Coordinator) Leases() []Lease {
+	if c == nil {
+		return nil
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	out := make([]Lease, 0, len(c.leases))
+	for _, lease := range c.leases {
+		out = append(out, lease)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].AuthID < out[j].AuthID })
+	return out
+}
+
+// Sharding reports whether credential sharding is enabled.
+func (c *Coordinator) Sharding() bool {
+	return c != nil && c.sharding
+}
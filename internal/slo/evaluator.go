@@ -0,0 +1,352 @@
+// Package slo evaluates service level objectives (latency and response-size percentiles,
+// error rates) over sliding windows of the usage records, so breaches can alert without an
+// external monitoring stack. Rules are evaluated as records arrive; breaches and recoveries
+// are logged and posted to the configured webhook, and burn rates are exported as metrics.
+package slo
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"math"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/offline"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	coreusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// maxSamples caps the samples kept per rule; the oldest are dropped first.
+	maxSamples = 10000
+	// alertQueueSize bounds the webhook alerts waiting for delivery; further alerts are
+	// dropped and logged.
+	alertQueueSize = 32
+)
+
+var defaultEvaluator = NewEvaluator()
+
+func init() {
+	coreusage.RegisterPlugin(defaultEvaluator)
+}
+
+// Default returns the process-wide evaluator fed by the usage pipeline.
+func Default() *Evaluator { return defaultEvaluator }
+
+// Status is the current evaluation of one rule.
+type Status struct {
+	Name       string   `json:"name"`
+	Metric     string   `json:"metric"`
+	Provider   string   `json:"provider,omitempty"`
+	Models     []string `json:"models,omitempty"`
+	Percentile float64  `json:"percentile,omitempty"`
+	Threshold  float64  `json:"threshold"`
+	// Value is the metric over the window; it is 0 below the minimum request count.
+	Value float64 `json:"value"`
+	// BurnRate is Value divided by Threshold: above 1 the objective is breached.
+	BurnRate      float64 `json:"burn_rate"`
+	Requests      int     `json:"requests"`
+	WindowSeconds int     `json:"window_seconds"`
+	Breached      bool    `json:"breached"`
+	// Since is when the current breach started.
+	Since *time.Time `json:"since,omitempty"`
+}
+
+type sample struct {
+	at     time.Time
+	value  float64
+	failed bool
+}
+
+// pendingAlert is a webhook notification waiting for delivery.
+type pendingAlert struct {
+	url    string
+	event  string
+	status Status
+}
+
+type ruleState struct {
+	rule     config.SLORule
+	samples  []sample
+	breached bool
+	since    time.Time
+}
+
+// Evaluator implements coreusage.Plugin.
+type Evaluator struct {
+	mu      sync.Mutex
+	rules   []*ruleState
+	webhook string
+	client  *http.Client
+	nowFunc func() time.Time
+
+	alerts     chan pendingAlert
+	alertsOnce sync.Once
+}
+
+// NewEvaluator constructs an evaluator without rules.
+func NewEvaluator() *Evaluator {
+	return &Evaluator{
+		client:  offline.Default().Client(&http.Client{Timeout: 10 * time.Second}),
+		nowFunc: time.Now,
+		alerts:  make(chan pendingAlert, alertQueueSize),
+	}
+}
+
+// Configure replaces the rules. Rules kept unchanged keep their samples and breach state.
+func (e *Evaluator) Configure(cfg config.SLOConfig) {
+	if e == nil {
+		return
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	previous := make(map[string]*ruleState, len(e.rules))
+	for _, state := range e.rules {
+		previous[state.rule.Name] = state
+	}
+	rules := make([]*ruleState, 0, len(cfg.Rules))
+	for _, rule := range cfg.Rules {
+		if state, ok := previous[rule.Name]; ok && reflect.DeepEqual(state.rule, rule) {
+			rules = append(rules, state)
+			continue
+		}
+		rules = append(rules, &ruleState{rule: rule})
+	}
+	e.rules = rules
+	e.webhook = cfg.WebhookURL
+}
+
+// HandleUsage implements coreusage.Plugin.
+func (e *Evaluator) HandleUsage(_ context.Context, record coreusage.Record) {
+	if e == nil {
+		return
+	}
+	e.mu.Lock()
+	now := e.nowFunc()
+	var alerts []Status
+	for _, state := range e.rules {
+		if !matches(state.rule, record) {
+			continue
+		}
+		state.samples = append(state.samples, sample{at: now, value: value(state.rule.Metric, record), failed: record.Failed})
+		if len(state.samples) > maxSamples {
+			state.samples = append(state.samples[:0:0], state.samples[len(state.samples)-maxSamples:]...)
+		}
+		status := state.evaluate(now)
+		if status.Breached != state.breached {
+			state.breached = status.Breached
+			state.since = time.Time{}
+			if status.Breached {
+				state.since = now
+			}
+			status.Since = state.sinceRef()
+			alerts = append(alerts, status)
+		}
+	}
+	webhook := e.webhook
+	e.mu.Unlock()
+
+	for _, alert := range alerts {
+		event := "slo_recovered"
+		if alert.Breached {
+			event = "slo_breached"
+			log.Warnf("slo: rule %s breached: %s %.4g over threshold %.4g (%d requests)", alert.Name, alert.Metric, alert.Value, alert.Threshold, alert.Requests)
+		} else {
+			log.Infof("slo: rule %s recovered: %s %.4g within threshold %.4g", alert.Name, alert.Metric, alert.Value, alert.Threshold)
+		}
+		if webhook != "" {
+			e.enqueueAlert(pendingAlert{url: webhook, event: event, status: alert})
+		}
+	}
+}
+
+// Snapshot returns the current evaluation of every rule, in config order.
+func (e *Evaluator) Snapshot() []Status {
+	if e == nil {
+		return nil
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	now := e.nowFunc()
+	out := make([]Status, 0, len(e.rules))
+	for _, state := range e.rules {
+		status := state.evaluate(now)
+		// The breach state only changes on new records, so alerts and the snapshot agree.
+		status.Breached, status.Since = state.breached, state.sinceRef()
+		out = append(out, status)
+	}
+	return out
+}
+
+// sinceRef returns the start of the current breach, or nil outside a breach.
+func (s *ruleState) sinceRef() *time.Time {
+	if !s.breached {
+		return nil
+	}
+	since := s.since
+	return &since
+}
+
+// matches reports whether record falls in the scope of rule.
+func matches(rule config.SLORule, record coreusage.Record) bool {
+	if rule.Provider != "" && !strings.EqualFold(rule.Provider, record.Provider) {
+		return false
+	}
+	if len(rule.Models) == 0 {
+		return true
+	}
+	for _, pattern := range rule.Models {
+		if util.MatchWildcard(pattern, record.Model) {
+			return true
+		}
+	}
+	return false
+}
+
+// value is the sample of record for metric.
+func value(metric string, record coreusage.Record) float64 {
+	switch metric {
+	case config.SLOMetricLatency:
+		return float64(record.Latency.Milliseconds())
+	case config.SLOMetricOutputTokens:
+		return float64(record.Detail.OutputTokens)
+	}
+	return 0
+}
+
+// evaluate drops the samples outside the window and computes the metric over the rest.
+func (s *ruleState) evaluate(now time.Time) Status {
+	rule := s.rule
+	cutoff := now.Add(-time.Duration(rule.WindowSeconds) * time.Second)
+	first := sort.Search(len(s.samples), func(i int) bool { return s.samples[i].at.After(cutoff) })
+	s.samples = s.samples[first:]
+
+	status := Status{
+		Name:          rule.Name,
+		Metric:        rule.Metric,
+		Provider:      rule.Provider,
+		Models:        rule.Models,
+		Threshold:     rule.Threshold,
+		Requests:      len(s.samples),
+		WindowSeconds: rule.WindowSeconds,
+	}
+	var values []float64
+	switch rule.Metric {
+	case config.SLOMetricErrorRate:
+		if status.Requests >= rule.MinRequests {
+			failed := 0
+			for _, smp := range s.samples {
+				if smp.failed {
+					failed++
+				}
+			}
+			status.Value = float64(failed) / float64(status.Requests)
+		}
+	case config.SLOMetricOutputTokens:
+		status.Percentile = rule.Percentile
+		for _, smp := range s.samples {
+			// Failed requests have no response to measure.
+			if !smp.failed {
+				values = append(values, smp.value)
+			}
+		}
+	default:
+		status.Percentile = rule.Percentile
+		for _, smp := range s.samples {
+			values = append(values, smp.value)
+		}
+	}
+	if rule.Metric != config.SLOMetricErrorRate && len(values) >= rule.MinRequests {
+		status.Value = percentile(values, rule.Percentile)
+	}
+	status.BurnRate = status.Value / rule.Threshold
+	status.Breached = status.Value > rule.Threshold
+	return status
+}
+
+// percentile returns the nearest-rank percentile p of values, which it sorts.
+func percentile(values []float64, p float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sort.Float64s(values)
+	rank := int(math.Ceil(p/100*float64(len(values)))) - 1
+	return values[min(max(rank, 0), len(values)-1)]
+}
+
+// enqueueAlert hands an alert to the single delivery worker, so a slow webhook never
+// accumulates goroutines.
+func (e *Evaluator) enqueueAlert(alert pendingAlert) {
+	e.alertsOnce.Do(func() {
+		go func() {
+			for a := range e.alerts {
+				e.notify(a.url, a.event, a.status)
+			}
+		}()
+	})
+	select {
+	case e.alerts <- alert:
+	default:
+		log.Warnf("slo: webhook queue full, dropped %s alert for rule %s", alert.event, alert.status.Name)
+	}
+}
+
+func (e *Evaluator) notify(url, event string, status Status) {
+	payload, err := json.Marshal(map[string]any{"event": event, "status": status})
+	if err != nil {
+		return
+	}
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		log.Errorf("slo: invalid webhook url: %v", err)
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := e.client.Do(req)
+	if err != nil {
+		log.Errorf("slo: webhook delivery failed: %v", err)
+		return
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		log.Errorf("slo: webhook returned status %d", resp.StatusCode)
+	}
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// WritePrometheus writes the SLO gauges in the Prometheus text format.
+func (e *Evaluator) WritePrometheus(w io.Writer) error {
+	statuses := e.Snapshot()
+	if len(statuses) == 0 {
+		return nil
+	}
+	bw := bufio.NewWriter(w)
+	gauge := func(name, help string, get func(Status) float64) {
+		bw.WriteString("# HELP " + name + " " + help + "\n# TYPE " + name + " gauge\n")
+		for _, s := range statuses {
+			labels := `{rule="` + labelEscaper.Replace(s.Name) + `",metric="` + s.Metric + `"}`
+			bw.WriteString(name + labels + " " + strconv.FormatFloat(get(s), 'g', -1, 64) + "\n")
+		}
+	}
+	gauge("cliproxy_slo_value", "Current value of the SLO metric over its window.", func(s Status) float64 { return s.Value })
+	gauge("cliproxy_slo_threshold", "Threshold of the SLO rule.", func(s Status) float64 { return s.Threshold })
+	gauge("cliproxy_slo_burn_rate", "SLO metric divided by its threshold; above 1 the objective is breached.", func(s Status) float64 { return s.BurnRate })
+	gauge("cliproxy_slo_breached", "1 while the SLO rule is breached.", func(s Status) float64 {
+		if s.Breached {
+			return 1
+		}
+		return 0
+	})
+	return bw.Flush()
+}
@@ -0,0 +1,101 @@
+package slo
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	coreusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+)
+
+func newTestEvaluator(t *testing.T, rules ...config.SLORule) (*Evaluator, *time.Time) {
+	t.Helper()
+	cfg := &config.Config{SLO: config.SLOConfig{Rules: rules}}
+	cfg.SanitizeSLO()
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	e := NewEvaluator()
+	e.nowFunc = func() time.Time { return now }
+	e.Configure(cfg.SLO)
+	return e, &now
+}
+
+func TestLatencyPercentileBreachesAndRecovers(t *testing.T) {
+	alerts := make(chan string, 4)
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Event string `json:"event"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		alerts <- body.Event
+	}))
+	defer hook.Close()
+
+	e, now := newTestEvaluator(t, config.SLORule{Metric: "latency", Provider: "claude", Models: []string{"claude-*"}, Threshold: 1000, MinRequests: 4, WindowSeconds: 60})
+	e.webhook = hook.URL
+	record := func(model string, latency time.Duration) {
+		e.HandleUsage(context.Background(), coreusage.Record{Provider: "claude", Model: model, Latency: latency})
+	}
+
+	for i := 0; i < 3; i++ {
+		record("claude-sonnet", 5*time.Second)
+	}
+	record("gpt-5", 5*time.Second)
+	if status := e.Snapshot()[0]; status.Breached || status.Requests != 3 {
+		t.Fatalf("below min-requests: %+v", status)
+	}
+	record("claude-sonnet", 5*time.Second)
+	status := e.Snapshot()[0]
+	if !status.Breached || status.Value != 5000 || status.BurnRate != 5 || status.Since == nil || status.Name != "latency:claude/claude-*" {
+		t.Fatalf("breach: %+v", status)
+	}
+	if got := <-alerts; got != "slo_breached" {
+		t.Fatalf("alert = %q", got)
+	}
+
+	// Once the slow samples leave the window, fast ones recover the rule.
+	*now = now.Add(2 * time.Minute)
+	for i := 0; i < 4; i++ {
+		record("claude-sonnet", 100*time.Millisecond)
+	}
+	if status = e.Snapshot()[0]; status.Breached || status.Value != 100 {
+		t.Fatalf("recovery: %+v", status)
+	}
+	if got := <-alerts; got != "slo_recovered" {
+		t.Fatalf("alert = %q", got)
+	}
+}
+
+func TestErrorRateAndMetrics(t *testing.T) {
+	e, _ := newTestEvaluator(t,
+		config.SLORule{Name: "codex-errors", Metric: "error-rate", Provider: "codex", Threshold: 0.2, MinRequests: 5},
+		config.SLORule{Metric: "bogus", Threshold: 1},
+	)
+	if len(e.Snapshot()) != 1 {
+		t.Fatal("rules with an unknown metric should be dropped")
+	}
+	for i := 0; i < 5; i++ {
+		e.HandleUsage(context.Background(), coreusage.Record{Provider: "codex", Failed: i < 2})
+	}
+	status := e.Snapshot()[0]
+	if !status.Breached || status.Value != 0.4 {
+		t.Fatalf("error rate: %+v", status)
+	}
+
+	var out strings.Builder
+	if err := e.WritePrometheus(&out); err != nil {
+		t.Fatalf("WritePrometheus: %v", err)
+	}
+	for _, want := range []string{
+		`cliproxy_slo_burn_rate{rule="codex-errors",metric="error-rate"} 2`,
+		`cliproxy_slo_breached{rule="codex-errors",metric="error-rate"} 1`,
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("metrics missing %q:\n%s", want, out.String())
+		}
+	}
+}
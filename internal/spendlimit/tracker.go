@@ -0,0 +1,550 @@
+// Package spendlimit enforces monthly token and cost ceilings on provider credentials.
+// Months are calendar months in UTC unless quota-exceeded.reset-schedules aligns a
+// credential's period with its provider's quota resets.
+// The tracker accumulates usage records per credential and, once a ceiling is reached,
+// excludes the credential from routing and notifies the configured webhook. The running
+// periods are checkpointed to a state file so restarts do not forget the spend.
+package spendlimit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/offline"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/pricing"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	coreusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+	log "github.com/sirupsen/logrus"
+)
+
+// Counter is a cluster-wide counter store (see internal/sharedstate) so instances sharing
+// credentials also share their spend.
+type Counter interface {
+	Add(ctx context.Context, key string, delta int64, ttl time.Duration) (int64, error)
+}
+
+// costScale stores costs in shared counters as integer micro-units.
+const costScale = 1e6
+
+// ErrorCode is the coreauth.Error code reported for credentials over their ceiling.
+const ErrorCode = "spend_limit_exceeded"
+
+const (
+	// checkpointInterval is how often the running spend is saved to the state file.
+	checkpointInterval = time.Minute
+	// alertQueueSize bounds the webhook alerts waiting for delivery; further alerts are
+	// dropped and logged.
+	alertQueueSize = 32
+)
+
+var defaultTracker = NewTracker()
+
+func init() {
+	coreusage.RegisterPlugin(defaultTracker)
+}
+
+// Default returns the process-wide tracker fed by the usage pipeline.
+func Default() *Tracker { return defaultTracker }
+
+// Status describes the current month's spend of a limited credential.
+type Status struct {
+	AuthID        string  `json:"auth_id"`
+	Provider      string  `json:"provider"`
+	Month         string  `json:"month"`
+	Tokens        int64   `json:"tokens"`
+	Cost          float64 `json:"cost"`
+	MonthlyTokens int64   `json:"monthly_tokens,omitempty"`
+	MonthlyCost   float64 `json:"monthly_cost,omitempty"`
+	Exceeded      bool    `json:"exceeded"`
+	// ResetsAt is when the period named by Month ends.
+	ResetsAt time.Time `json:"resets_at"`
+}
+
+type spend struct {
+	provider string
+	month    string
+	tokens   int64
+	cost     float64
+	alerted  bool
+	// costCarry is the fraction of a micro-unit not yet added to the shared cost counter.
+	costCarry float64
+}
+
+// savedSpend is a spend entry of the state file.
+type savedSpend struct {
+	Provider string  `json:"provider"`
+	Month    string  `json:"month"`
+	Tokens   int64   `json:"tokens"`
+	Cost     float64 `json:"cost"`
+	Alerted  bool    `json:"alerted,omitempty"`
+}
+
+// pendingAlert is a webhook notification waiting for delivery.
+type pendingAlert struct {
+	url    string
+	status Status
+}
+
+// Tracker implements coreusage.Plugin and coreauth.AuthFilter.
+type Tracker struct {
+	mu      sync.RWMutex
+	cfg     config.SpendLimitConfig
+	resets  config.QuotaExceeded
+	spends  map[string]*spend
+	client  *http.Client
+	counter Counter
+	nowFunc func() time.Time
+
+	statePath string
+	dirty     bool
+	cancel    context.CancelFunc
+
+	alerts     chan pendingAlert
+	alertsOnce sync.Once
+}
+
+// NewTracker constructs an unconfigured tracker.
+func NewTracker() *Tracker {
+	return &Tracker{
+		spends:  make(map[string]*spend),
+		client:  offline.Default().Client(&http.Client{Timeout: 10 * time.Second}),
+		nowFunc: time.Now,
+		alerts:  make(chan pendingAlert, alertQueueSize),
+	}
+}
+
+// Persist checkpoints the running spend to path and restores the spend an earlier run
+// saved there; entries already tracked in memory are kept. An empty path stops persisting.
+func (t *Tracker) Persist(path string) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if path != t.statePath {
+		t.checkpointLocked()
+		t.statePath = path
+		if path != "" {
+			if err := t.restoreLocked(); err != nil {
+				log.Warnf("spend limit: failed to restore %s: %v", path, err)
+			}
+		}
+	}
+	switch {
+	case path != "" && t.cancel == nil:
+		ctx, cancel := context.WithCancel(context.Background())
+		t.cancel = cancel
+		go t.run(ctx)
+	case path == "" && t.cancel != nil:
+		t.cancel()
+		t.cancel = nil
+	}
+}
+
+// Stop saves the running spend and halts the checkpoint loop.
+func (t *Tracker) Stop() {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.cancel != nil {
+		t.cancel()
+		t.cancel = nil
+	}
+	t.checkpointLocked()
+}
+
+func (t *Tracker) run(ctx context.Context) {
+	ticker := time.NewTicker(checkpointInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			t.mu.Lock()
+			t.checkpointLocked()
+			t.mu.Unlock()
+		}
+	}
+}
+
+func (t *Tracker) restoreLocked() error {
+	data, err := os.ReadFile(t.statePath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	saved := make(map[string]savedSpend)
+	if err = json.Unmarshal(data, &saved); err != nil {
+		return err
+	}
+	for authID, entry := range saved {
+		if t.spends[authID] != nil {
+			continue
+		}
+		t.spends[authID] = &spend{provider: entry.Provider, month: entry.Month, tokens: entry.Tokens, cost: entry.Cost, alerted: entry.Alerted}
+	}
+	return nil
+}
+
+func (t *Tracker) checkpointLocked() {
+	if !t.dirty || t.statePath == "" {
+		return
+	}
+	saved := make(map[string]savedSpend, len(t.spends))
+	for authID, s := range t.spends {
+		saved[authID] = savedSpend{Provider: s.provider, Month: s.month, Tokens: s.tokens, Cost: s.cost, Alerted: s.alerted}
+	}
+	data, err := json.Marshal(saved)
+	if err == nil {
+		if err = os.MkdirAll(filepath.Dir(t.statePath), 0o700); err == nil {
+			tmp := t.statePath + ".tmp"
+			if err = os.WriteFile(tmp, data, 0o600); err == nil {
+				err = os.Rename(tmp, t.statePath)
+			}
+		}
+	}
+	if err != nil {
+		log.Warnf("spend limit: failed to save running spend: %v", err)
+		return
+	}
+	t.dirty = false
+}
+
+// Configure replaces the active limits. Accumulated spend is kept.
+func (t *Tracker) Configure(cfg config.SpendLimitConfig) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	t.cfg = cfg
+	t.mu.Unlock()
+}
+
+// SetResetSchedules aligns the spend periods of credentials with the quota reset
+// schedules in cfg. Credentials without a schedule use calendar months in UTC.
+func (t *Tracker) SetResetSchedules(cfg config.QuotaExceeded) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	t.resets = config.QuotaExceeded{ResetSchedules: cfg.ResetSchedules}
+	t.mu.Unlock()
+}
+
+// SetCounter shares spend totals through counter. Nil reverts to per-instance accounting.
+func (t *Tracker) SetCounter(counter Counter) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	t.counter = counter
+	t.mu.Unlock()
+}
+
+// Policy returns the configured exhaustion policy.
+func (t *Tracker) Policy() string {
+	if t == nil {
+		return config.SpendLimitPolicyFallthrough
+	}
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	if t.cfg.Policy == "" {
+		return config.SpendLimitPolicyFallthrough
+	}
+	return t.cfg.Policy
+}
+
+// Enabled reports whether any limit is configured.
+func (t *Tracker) Enabled() bool {
+	if t == nil {
+		return false
+	}
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return len(t.cfg.Limits) > 0
+}
+
+// HasProviderLimit reports whether any configured limit applies to provider.
+func (t *Tracker) HasProviderLimit(provider string) bool {
+	if t == nil {
+		return false
+	}
+	provider = strings.ToLower(strings.TrimSpace(provider))
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	for _, limit := range t.cfg.Limits {
+		if limit.Provider == provider {
+			return true
+		}
+	}
+	return false
+}
+
+// schedule returns the spend period schedule of a credential. Callers must hold t.mu.
+func (t *Tracker) schedule(provider, authID string) config.QuotaResetSchedule {
+	if schedule, ok := t.resets.ResetScheduleFor(provider, authID); ok {
+		return schedule
+	}
+	return config.DefaultQuotaResetSchedule
+}
+
+// month returns the current period key of a credential. Callers must hold t.mu.
+func (t *Tracker) month(provider, authID string) string {
+	return t.schedule(provider, authID).PeriodKey(t.nowFunc())
+}
+
+// limitFor returns the limit that applies to the credential; an entry naming the auth ID
+// takes precedence over provider-wide entries. Callers must hold t.mu.
+func (t *Tracker) limitFor(provider, authID string) (config.SpendLimit, bool) {
+	provider = strings.ToLower(strings.TrimSpace(provider))
+	var fallback *config.SpendLimit
+	for i := range t.cfg.Limits {
+		limit := &t.cfg.Limits[i]
+		if limit.Provider != provider {
+			continue
+		}
+		if limit.AuthID == "" {
+			if fallback == nil {
+				fallback = limit
+			}
+			continue
+		}
+		if config.MatchesAuthID(limit.AuthID, authID) {
+			return *limit, true
+		}
+	}
+	if fallback != nil {
+		return *fallback, true
+	}
+	return config.SpendLimit{}, false
+}
+
+func exceeded(limit config.SpendLimit, s *spend) bool {
+	if s == nil {
+		return false
+	}
+	if limit.MonthlyTokens > 0 && s.tokens >= limit.MonthlyTokens {
+		return true
+	}
+	return limit.MonthlyCost > 0 && s.cost >= limit.MonthlyCost
+}
+
+// HandleUsage implements coreusage.Plugin.
+func (t *Tracker) HandleUsage(ctx context.Context, record coreusage.Record) {
+	if t == nil || record.AuthID == "" {
+		return
+	}
+	t.mu.Lock()
+	limit, ok := t.limitFor(record.Provider, record.AuthID)
+	if !ok {
+		t.mu.Unlock()
+		return
+	}
+	month := t.month(record.Provider, record.AuthID)
+	s := t.spends[record.AuthID]
+	if s == nil || s.month != month {
+		s = &spend{provider: limit.Provider, month: month}
+		t.spends[record.AuthID] = s
+	}
+	tokens := record.Detail.TotalTokens
+	if tokens == 0 {
+		tokens = record.Detail.InputTokens + record.Detail.OutputTokens + record.Detail.ReasoningTokens
+	}
+	var cost float64
+	if limit.InputCostPerMillion > 0 || limit.OutputCostPerMillion > 0 {
+		cost = float64(record.Detail.InputTokens)*limit.InputCostPerMillion/1e6 +
+			float64(record.Detail.GeneratedTokens())*limit.OutputCostPerMillion/1e6
+	} else {
+		cost, _ = pricing.Default().Cost(record.Model, pricing.UsageOf(record.Detail))
+	}
+	s.tokens += tokens
+	s.cost += cost
+	t.dirty = true
+	counter := t.counter
+	if counter == nil {
+		alert, webhook := t.commitLocked(record.AuthID, limit, s), t.cfg.WebhookURL
+		t.mu.Unlock()
+		t.deliver(alert, webhook, nil, month)
+		return
+	}
+	// Shared counters hold whole micro-units; the remainder is carried to the next record.
+	micro := cost*costScale + s.costCarry
+	costDelta := int64(micro)
+	s.costCarry = micro - float64(costDelta)
+	t.mu.Unlock()
+
+	const ttl = 40 * 24 * time.Hour
+	base := "spend:" + month + ":" + record.AuthID
+	sharedTokens, errTokens := counter.Add(context.Background(), base+":tokens", tokens, ttl)
+	sharedCost, errCost := counter.Add(context.Background(), base+":cost", costDelta, ttl)
+	t.mu.Lock()
+	// Concurrent records may return their totals out of order; a total never decreases.
+	if errTokens == nil {
+		s.tokens = max(s.tokens, sharedTokens)
+	}
+	if errCost == nil {
+		s.cost = max(s.cost, float64(sharedCost)/costScale)
+	}
+	alert, webhook := t.commitLocked(record.AuthID, limit, s), t.cfg.WebhookURL
+	t.mu.Unlock()
+	t.deliver(alert, webhook, counter, month)
+}
+
+// commitLocked returns the alert to send when s just crossed its ceiling. Deciding in the
+// critical section that applied the usage keeps concurrent records from alerting twice.
+// Callers must hold t.mu.
+func (t *Tracker) commitLocked(authID string, limit config.SpendLimit, s *spend) *Status {
+	if s.alerted || !exceeded(limit, s) {
+		return nil
+	}
+	s.alerted = true
+	status := t.statusFor(authID, limit, s)
+	return &status
+}
+
+// deliver logs alert and queues its webhook.
+func (t *Tracker) deliver(alert *Status, webhook string, counter Counter, month string) {
+	if alert == nil {
+		return
+	}
+	// With shared counters only the first instance to observe the crossing alerts.
+	if counter != nil {
+		key := "spend:" + month + ":" + alert.AuthID + ":alerted"
+		if v, err := counter.Add(context.Background(), key, 1, 40*24*time.Hour); err == nil && v > 1 {
+			return
+		}
+	}
+	log.Warnf("spend limit: credential %s (%s) reached its monthly ceiling (tokens=%d cost=%.4f)", alert.AuthID, alert.Provider, alert.Tokens, alert.Cost)
+	if webhook != "" {
+		t.enqueueAlert(webhook, *alert)
+	}
+}
+
+// FilterAuth implements coreauth.AuthFilter.
+func (t *Tracker) FilterAuth(_ context.Context, auth *coreauth.Auth, _ string) error {
+	if t == nil || auth == nil {
+		return nil
+	}
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	limit, ok := t.limitFor(auth.Provider, auth.ID)
+	if !ok {
+		return nil
+	}
+	s := t.spends[auth.ID]
+	if s == nil || s.month != t.month(auth.Provider, auth.ID) || !exceeded(limit, s) {
+		return nil
+	}
+	return &coreauth.Error{
+		Code:       ErrorCode,
+		Message:    fmt.Sprintf("credential %s has reached its monthly spend limit", auth.ID),
+		HTTPStatus: http.StatusTooManyRequests,
+	}
+}
+
+// Snapshot returns the current month's spend for every tracked credential.
+func (t *Tracker) Snapshot() []Status {
+	if t == nil {
+		return nil
+	}
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	out := make([]Status, 0, len(t.spends))
+	for authID, s := range t.spends {
+		if s.month != t.month(s.provider, authID) {
+			continue
+		}
+		limit, _ := t.limitFor(s.provider, authID)
+		out = append(out, t.statusFor(authID, limit, s))
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].AuthID < out[j].AuthID })
+	return out
+}
+
+// Reset clears accumulated spend for authID, or for every credential when authID is empty.
+func (t *Tracker) Reset(authID string) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.dirty = true
+	if authID == "" {
+		t.spends = make(map[string]*spend)
+		return
+	}
+	delete(t.spends, authID)
+}
+
+// statusFor describes s. Callers must hold t.mu.
+func (t *Tracker) statusFor(authID string, limit config.SpendLimit, s *spend) Status {
+	return Status{
+		AuthID:        authID,
+		Provider:      s.provider,
+		Month:         s.month,
+		Tokens:        s.tokens,
+		Cost:          s.cost,
+		MonthlyTokens: limit.MonthlyTokens,
+		MonthlyCost:   limit.MonthlyCost,
+		Exceeded:      exceeded(limit, s),
+		ResetsAt:      t.schedule(s.provider, authID).NextReset(t.nowFunc()),
+	}
+}
+
+// enqueueAlert hands an alert to the single delivery worker, so a slow webhook never
+// accumulates goroutines.
+func (t *Tracker) enqueueAlert(url string, status Status) {
+	t.alertsOnce.Do(func() {
+		go func() {
+			for a := range t.alerts {
+				t.notify(a.url, a.status)
+			}
+		}()
+	})
+	select {
+	case t.alerts <- pendingAlert{url: url, status: status}:
+	default:
+		log.Warnf("spend limit: webhook queue full, dropped alert for %s", status.AuthID)
+	}
+}
+
+func (t *Tracker) notify(url string, status Status) {
+	payload, err := json.Marshal(map[string]any{
+		"event":  "spend_limit_exceeded",
+		"status": status,
+	})
+	if err != nil {
+		return
+	}
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		log.Errorf("spend limit: invalid webhook url: %v", err)
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := t.client.Do(req)
+	if err != nil {
+		log.Errorf("spend limit: webhook delivery failed: %v", err)
+		return
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		log.Errorf("spend limit: webhook returned status %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,178 @@
+package spendlimit
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	coreusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+)
+
+func TestTracker_ExcludesCredentialOverLimit(t *testing.T) {
+	tracker := NewTracker()
+	tracker.Configure(config.SpendLimitConfig{Limits: []config.SpendLimit{
+		{Provider: "claude", MonthlyTokens: 100},
+		{Provider: "codex", AuthID: "codex-a", MonthlyCost: 1, InputCostPerMillion: 1e6},
+	}})
+	ctx := context.Background()
+	claude := &coreauth.Auth{ID: "claude-a", Provider: "claude"}
+	codex := &coreauth.Auth{ID: "codex-a", Provider: "codex"}
+
+	tracker.HandleUsage(ctx, coreusage.Record{Provider: "claude", AuthID: "claude-a", Detail: coreusage.Detail{TotalTokens: 60}})
+	if err := tracker.FilterAuth(ctx, claude, ""); err != nil {
+		t.Fatalf("expected credential under limit to pass, got %v", err)
+	}
+	tracker.HandleUsage(ctx, coreusage.Record{Provider: "claude", AuthID: "claude-a", Detail: coreusage.Detail{TotalTokens: 60}})
+	err := tracker.FilterAuth(ctx, claude, "")
+	authErr, ok := err.(*coreauth.Error)
+	if !ok || authErr.Code != ErrorCode || authErr.StatusCode() != http.StatusTooManyRequests {
+		t.Fatalf("expected spend limit error, got %v", err)
+	}
+
+	tracker.HandleUsage(ctx, coreusage.Record{Provider: "codex", AuthID: "codex-a", Detail: coreusage.Detail{InputTokens: 1}})
+	if err = tracker.FilterAuth(ctx, codex, ""); err == nil {
+		t.Fatal("expected cost ceiling to exclude codex-a")
+	}
+	if err = tracker.FilterAuth(ctx, &coreauth.Auth{ID: "codex-b", Provider: "codex"}, ""); err != nil {
+		t.Fatalf("expected codex-b to be unaffected, got %v", err)
+	}
+}
+
+func TestTracker_ResetsMonthlyAndFiresWebhookOnce(t *testing.T) {
+	hits := make(chan map[string]any, 4)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		hits <- body
+	}))
+	defer srv.Close()
+
+	now := time.Date(2026, 1, 31, 23, 0, 0, 0, time.UTC)
+	tracker := NewTracker()
+	tracker.nowFunc = func() time.Time { return now }
+	tracker.Configure(config.SpendLimitConfig{WebhookURL: srv.URL, Limits: []config.SpendLimit{{Provider: "gemini", MonthlyTokens: 10}}})
+	ctx := context.Background()
+	auth := &coreauth.Auth{ID: "g1", Provider: "gemini"}
+
+	for i := 0; i < 3; i++ {
+		tracker.HandleUsage(ctx, coreusage.Record{Provider: "gemini", AuthID: "g1", Detail: coreusage.Detail{TotalTokens: 10}})
+	}
+	select {
+	case body := <-hits:
+		if body["event"] != "spend_limit_exceeded" {
+			t.Fatalf("unexpected webhook payload: %v", body)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected webhook delivery")
+	}
+	select {
+	case <-hits:
+		t.Fatal("webhook should fire once per month")
+	case <-time.After(100 * time.Millisecond):
+	}
+	if tracker.FilterAuth(ctx, auth, "") == nil {
+		t.Fatal("expected credential to be excluded")
+	}
+
+	now = now.Add(2 * time.Hour)
+	if err := tracker.FilterAuth(ctx, auth, ""); err != nil {
+		t.Fatalf("expected new month to reset spend, got %v", err)
+	}
+}
+
+func TestTracker_FollowsResetSchedule(t *testing.T) {
+	loc, err := time.LoadLocation("America/Los_Angeles")
+	if err != nil {
+		t.Skipf("tzdata unavailable: %v", err)
+	}
+	now := time.Date(2026, 3, 14, 23, 30, 0, 0, loc)
+	tracker := NewTracker()
+	tracker.nowFunc = func() time.Time { return now }
+	tracker.Configure(config.SpendLimitConfig{Limits: []config.SpendLimit{{Provider: "gemini-cli", MonthlyTokens: 10}}})
+	tracker.SetResetSchedules(config.QuotaExceeded{ResetSchedules: []config.QuotaResetSchedule{
+		{Provider: "gemini-cli", Period: config.QuotaResetDaily, Timezone: "America/Los_Angeles"},
+		{Provider: "claude", Period: config.QuotaResetMonthly, Day: 31, Hour: 6},
+	}})
+	ctx := context.Background()
+	auth := &coreauth.Auth{ID: "g1", Provider: "gemini-cli"}
+
+	tracker.HandleUsage(ctx, coreusage.Record{Provider: "gemini-cli", AuthID: "g1", Detail: coreusage.Detail{TotalTokens: 20}})
+	if tracker.FilterAuth(ctx, auth, "") == nil {
+		t.Fatal("expected credential over its daily ceiling to be excluded")
+	}
+	snapshot := tracker.Snapshot()
+	if len(snapshot) != 1 || !snapshot[0].ResetsAt.Equal(time.Date(2026, 3, 15, 0, 0, 0, 0, loc)) {
+		t.Fatalf("unexpected snapshot %+v", snapshot)
+	}
+
+	now = now.Add(time.Hour) // past Pacific midnight, still March 15 in UTC
+	if err = tracker.FilterAuth(ctx, auth, ""); err != nil {
+		t.Fatalf("expected the daily reset to clear the ceiling, got %v", err)
+	}
+
+	monthly, _ := tracker.resets.ResetScheduleFor("claude", "c1")
+	feb := time.Date(2026, 2, 10, 0, 0, 0, 0, time.UTC)
+	if got := monthly.NextReset(feb); !got.Equal(time.Date(2026, 2, 28, 6, 0, 0, 0, time.UTC)) {
+		t.Fatalf("monthly reset on day 31 in February = %v, want Feb 28 06:00", got)
+	}
+	if got := monthly.PeriodStart(feb); !got.Equal(time.Date(2026, 1, 31, 6, 0, 0, 0, time.UTC)) {
+		t.Fatalf("period start = %v, want Jan 31 06:00", got)
+	}
+}
+
+func TestTracker_PersistRestoresSpend(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "spend-limits.json")
+	limits := config.SpendLimitConfig{Limits: []config.SpendLimit{{Provider: "claude", MonthlyTokens: 100}}}
+	tracker := NewTracker()
+	tracker.Configure(limits)
+	tracker.Persist(path)
+	tracker.HandleUsage(context.Background(), coreusage.Record{Provider: "claude", AuthID: "claude-a", Detail: coreusage.Detail{TotalTokens: 120}})
+	tracker.Stop()
+
+	restarted := NewTracker()
+	restarted.Configure(limits)
+	restarted.Persist(path)
+	defer restarted.Stop()
+	if err := restarted.FilterAuth(context.Background(), &coreauth.Auth{ID: "claude-a", Provider: "claude"}, ""); err == nil {
+		t.Fatal("expected restored spend to keep the credential excluded")
+	}
+}
+
+type fakeCounter struct {
+	mu     sync.Mutex
+	values map[string]int64
+}
+
+func (f *fakeCounter) Add(_ context.Context, key string, delta int64, _ time.Duration) (int64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.values[key] += delta
+	return f.values[key], nil
+}
+
+func TestTracker_SharedCostKeepsSubMicroCharges(t *testing.T) {
+	counter := &fakeCounter{values: make(map[string]int64)}
+	tracker := NewTracker()
+	tracker.SetCounter(counter)
+	tracker.Configure(config.SpendLimitConfig{Limits: []config.SpendLimit{{Provider: "codex", MonthlyCost: 1, InputCostPerMillion: 0.4}}})
+	for i := 0; i < 1000; i++ {
+		tracker.HandleUsage(context.Background(), coreusage.Record{Provider: "codex", AuthID: "c", Detail: coreusage.Detail{InputTokens: 1}})
+	}
+	var shared int64
+	for key, v := range counter.values {
+		if strings.HasSuffix(key, ":cost") {
+			shared = v
+		}
+	}
+	if shared != 400 {
+		t.Fatalf("shared cost = %d micro-units, want 400", shared)
+	}
+}
@@ -0,0 +1,180 @@
+// Package spill provides append-only buffers that keep small payloads in memory and move
+// large ones to temporary files, bounding the memory held by buffered responses.
+package spill
+
+import (
+	"errors"
+	"io"
+	"os"
+	"sync"
+	"sync/atomic"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+// ErrTooLarge is returned by writes that would grow a buffer past its cap.
+var ErrTooLarge = errors.New("spill: buffer exceeds the configured max-bytes")
+
+var settings atomic.Pointer[config.ResponseSpillConfig]
+
+// Configure replaces the settings used by buffers created afterwards.
+func Configure(cfg config.ResponseSpillConfig) {
+	settings.Store(&cfg)
+}
+
+// Enabled reports whether buffers spill to disk.
+func Enabled() bool {
+	cfg := settings.Load()
+	return cfg != nil && cfg.MemoryBytes > 0
+}
+
+// MemoryLimit returns the in-memory size of a buffer before it spills, or 0 when spilling
+// is disabled.
+func MemoryLimit() int64 {
+	if cfg := settings.Load(); cfg != nil && cfg.MemoryBytes > 0 {
+		return cfg.MemoryBytes
+	}
+	return 0
+}
+
+// Buffer is an append-only byte buffer. Up to the configured memory size it lives in
+// memory; beyond that its content moves to a temporary file. Buffers created while
+// spilling is disabled stay in memory. A Buffer is safe for concurrent use; Close removes
+// its file.
+type Buffer struct {
+	mu       sync.Mutex
+	mem      []byte
+	file     *os.File
+	size     int64
+	memLimit int64
+	maxBytes int64
+	dir      string
+	err      error
+}
+
+// New returns an empty buffer using the current settings.
+func New() *Buffer {
+	b := &Buffer{}
+	if cfg := settings.Load(); cfg != nil && cfg.MemoryBytes > 0 {
+		b.memLimit = cfg.MemoryBytes
+		b.maxBytes = cfg.MaxBytes
+		b.dir = cfg.Dir
+		if b.maxBytes <= 0 {
+			b.maxBytes = config.DefaultResponseSpillMaxBytes
+		}
+	}
+	return b
+}
+
+// Write appends p.
+func (b *Buffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.err != nil {
+		return 0, b.err
+	}
+	if b.maxBytes > 0 && b.size+int64(len(p)) > b.maxBytes {
+		return 0, ErrTooLarge
+	}
+	if b.file == nil && (b.memLimit <= 0 || b.size+int64(len(p)) <= b.memLimit) {
+		b.mem = append(b.mem, p...)
+		b.size += int64(len(p))
+		return len(p), nil
+	}
+	if b.file == nil {
+		file, err := os.CreateTemp(b.dir, "cliproxy-spill-*")
+		if err != nil {
+			b.err = err
+			return 0, err
+		}
+		if _, err = file.Write(b.mem); err != nil {
+			_ = file.Close()
+			_ = os.Remove(file.Name())
+			b.err = err
+			return 0, err
+		}
+		b.file, b.mem = file, nil
+	}
+	n, err := b.file.WriteAt(p, b.size)
+	b.size += int64(n)
+	if err != nil {
+		b.err = err
+	}
+	return n, err
+}
+
+// Len returns the number of bytes written.
+func (b *Buffer) Len() int64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.size
+}
+
+// Spilled reports whether the content moved to disk.
+func (b *Buffer) Spilled() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.file != nil
+}
+
+// ReadAt implements io.ReaderAt over the written bytes.
+func (b *Buffer) ReadAt(p []byte, off int64) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if errors.Is(b.err, os.ErrClosed) {
+		return 0, b.err
+	}
+	if off >= b.size {
+		return 0, io.EOF
+	}
+	want := len(p)
+	if remaining := b.size - off; int64(want) > remaining {
+		p = p[:remaining]
+	}
+	var n int
+	var err error
+	if b.file != nil {
+		n, err = b.file.ReadAt(p, off)
+	} else {
+		n = copy(p, b.mem[off:])
+	}
+	if err == nil && n < want {
+		err = io.EOF
+	}
+	return n, err
+}
+
+// Reader returns a reader over the bytes written so far.
+func (b *Buffer) Reader() io.Reader {
+	return io.NewSectionReader(b, 0, b.Len())
+}
+
+// Bytes returns a copy of the content.
+func (b *Buffer) Bytes() ([]byte, error) {
+	out := make([]byte, b.Len())
+	n, err := b.ReadAt(out, 0)
+	if err == io.EOF && n == len(out) {
+		err = nil
+	}
+	return out[:n], err
+}
+
+// Close releases the buffer and removes its file. Later writes fail.
+func (b *Buffer) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.mem, b.size = nil, 0
+	if b.err == nil {
+		b.err = os.ErrClosed
+	}
+	if b.file == nil {
+		return nil
+	}
+	name := b.file.Name()
+	errClose := b.file.Close()
+	b.file = nil
+	if errRemove := os.Remove(name); errRemove != nil && errClose == nil {
+		errClose = errRemove
+	}
+	return errClose
+}
@@ -0,0 +1,47 @@
+package spill
+
+import (
+	"io"
+	"os"
+	"testing"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+func TestBufferSpillsToDisk(t *testing.T) {
+	dir := t.TempDir()
+	Configure(config.ResponseSpillConfig{MemoryBytes: 8, MaxBytes: 32, Dir: dir})
+	t.Cleanup(func() { Configure(config.ResponseSpillConfig{}) })
+
+	b := New()
+	if _, err := b.Write([]byte("0123")); err != nil || b.Spilled() {
+		t.Fatalf("small write: err %v spilled %v", err, b.Spilled())
+	}
+	if _, err := b.Write([]byte("456789abcdef")); err != nil || !b.Spilled() {
+		t.Fatalf("large write: err %v spilled %v", err, b.Spilled())
+	}
+	data, err := io.ReadAll(b.Reader())
+	if err != nil || string(data) != "0123456789abcdef" {
+		t.Fatalf("content = %q, %v", data, err)
+	}
+	if _, err = b.Write(make([]byte, 32)); err != ErrTooLarge {
+		t.Fatalf("write over max-bytes = %v, want ErrTooLarge", err)
+	}
+	if err = b.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Fatalf("spill file left behind: %v", entries)
+	}
+	if _, err = b.Bytes(); err == nil {
+		t.Fatal("closed buffer should not be readable")
+	}
+}
+
+func TestBufferStaysInMemoryWhenDisabled(t *testing.T) {
+	Configure(config.ResponseSpillConfig{})
+	b := New()
+	if _, err := b.Write(make([]byte, 1<<20)); err != nil || b.Spilled() {
+		t.Fatalf("disabled spill: err %v spilled %v", err, b.Spilled())
+	}
+}
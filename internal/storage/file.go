@@ -0,0 +1,136 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const fileExtension = ".jsonl"
+
+// FileBackend keeps the log of each key as <dir>/<key>.jsonl, one record per line.
+type FileBackend struct {
+	dir string
+}
+
+// NewFileBackend returns a file backend rooted at dir.
+func NewFileBackend(dir string) *FileBackend {
+	return &FileBackend{dir: dir}
+}
+
+// Dir returns the directory the logs are written to.
+func (b *FileBackend) Dir() string { return b.dir }
+
+// Append implements Backend.
+func (b *FileBackend) Append(key string, records ...[]byte) error {
+	path, err := b.path(key)
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	for _, record := range records {
+		buf.Write(bytes.TrimRight(record, "\n"))
+		buf.WriteByte('\n')
+	}
+	if err = os.MkdirAll(b.dir, 0o700); err != nil {
+		return fmt.Errorf("storage: create directory: %w", err)
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return fmt.Errorf("storage: open %s: %w", key, err)
+	}
+	if _, err = f.Write(buf.Bytes()); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("storage: write %s: %w", key, err)
+	}
+	if err = f.Close(); err != nil {
+		return fmt.Errorf("storage: close %s: %w", key, err)
+	}
+	return nil
+}
+
+// Read implements Backend.
+func (b *FileBackend) Read(key string) ([][]byte, error) {
+	path, err := b.path(key)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	var records [][]byte
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	for scanner.Scan() {
+		if line := scanner.Bytes(); len(line) > 0 {
+			records = append(records, append([]byte(nil), line...))
+		}
+	}
+	return records, scanner.Err()
+}
+
+// Keys implements Backend; a log's last activity is its modification time.
+func (b *FileBackend) Keys() ([]KeyInfo, error) {
+	if b.dir == "" {
+		return nil, nil
+	}
+	entries, err := os.ReadDir(b.dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	keys := make([]KeyInfo, 0, len(entries))
+	for _, de := range entries {
+		if de.IsDir() || !strings.HasSuffix(de.Name(), fileExtension) {
+			continue
+		}
+		info, errInfo := de.Info()
+		if errInfo != nil {
+			continue
+		}
+		keys = append(keys, KeyInfo{
+			Key:          strings.TrimSuffix(de.Name(), fileExtension),
+			Size:         info.Size(),
+			LastActivity: info.ModTime(),
+		})
+	}
+	return keys, nil
+}
+
+// Delete implements Backend.
+func (b *FileBackend) Delete(key string) error {
+	path, err := b.path(key)
+	if err != nil {
+		return err
+	}
+	if err = os.Remove(path); err != nil {
+		if os.IsNotExist(err) {
+			return ErrNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+// Close implements Backend.
+func (b *FileBackend) Close() error { return nil }
+
+func (b *FileBackend) path(key string) (string, error) {
+	if key == "" || key == "." || key == ".." || strings.ContainsAny(key, `/\`) {
+		return "", fmt.Errorf("storage: invalid key %q", key)
+	}
+	return filepath.Join(b.dir, key+fileExtension), nil
+}
@@ -0,0 +1,140 @@
+package storage
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// appendQueueSize bounds the appends waiting for a queued backend.
+const appendQueueSize = 4096
+
+// appendQueueWait bounds how long Append waits for room in a full queue before writing
+// the records itself, so an overloaded database slows requests down instead of losing
+// records.
+var appendQueueWait = 2 * time.Second
+
+type queuedAppend struct {
+	key     string
+	records [][]byte
+	flushed chan struct{}
+}
+
+// queuedBackend writes appends to its backend from one goroutine through a bounded queue,
+// so callers only wait on the database once the queue is full. Reads, listings and deletions first wait for the
+// queued appends, so they observe every append that returned before them.
+type queuedBackend struct {
+	backend Backend
+	queue   chan queuedAppend
+
+	mu         sync.RWMutex
+	closed     bool
+	done       chan struct{}
+	overloaded atomic.Bool
+}
+
+func newQueuedBackend(backend Backend) *queuedBackend {
+	q := &queuedBackend{backend: backend, queue: make(chan queuedAppend, appendQueueSize), done: make(chan struct{})}
+	go q.run()
+	return q
+}
+
+func (q *queuedBackend) run() {
+	defer close(q.done)
+	for item := range q.queue {
+		if item.flushed != nil {
+			close(item.flushed)
+			continue
+		}
+		if err := q.backend.Append(item.key, item.records...); err != nil {
+			log.Warnf("storage: queued append of %s failed: %v", item.key, err)
+		}
+		if len(q.queue) == 0 {
+			q.overloaded.Store(false)
+		}
+	}
+}
+
+// Append implements Backend; the records are written in the background. When the queue
+// stays full for appendQueueWait the records are written synchronously, possibly ahead of
+// appends still queued.
+func (q *queuedBackend) Append(key string, records ...[]byte) error {
+	if len(records) == 0 {
+		return nil
+	}
+	copied := make([][]byte, len(records))
+	for i, record := range records {
+		copied[i] = append([]byte(nil), record...)
+	}
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	if q.closed {
+		return errors.New("storage: backend closed")
+	}
+	item := queuedAppend{key: key, records: copied}
+	select {
+	case q.queue <- item:
+		return nil
+	default:
+	}
+	timer := time.NewTimer(appendQueueWait)
+	defer timer.Stop()
+	select {
+	case q.queue <- item:
+		return nil
+	case <-timer.C:
+	}
+	if q.overloaded.CompareAndSwap(false, true) {
+		// Warn once per overload; the flag clears once the queue drained.
+		log.Warnf("storage: append queue full, writing records synchronously until the backend catches up")
+	}
+	return q.backend.Append(key, records...)
+}
+
+// flush waits until the appends queued so far are written.
+func (q *queuedBackend) flush() {
+	q.mu.RLock()
+	if q.closed {
+		q.mu.RUnlock()
+		return
+	}
+	flushed := make(chan struct{})
+	q.queue <- queuedAppend{flushed: flushed}
+	q.mu.RUnlock()
+	<-flushed
+}
+
+// Read implements Backend.
+func (q *queuedBackend) Read(key string) ([][]byte, error) {
+	q.flush()
+	return q.backend.Read(key)
+}
+
+// Keys implements Backend.
+func (q *queuedBackend) Keys() ([]KeyInfo, error) {
+	q.flush()
+	return q.backend.Keys()
+}
+
+// Delete implements Backend.
+func (q *queuedBackend) Delete(key string) error {
+	q.flush()
+	return q.backend.Delete(key)
+}
+
+// Close implements Backend; the queued appends are written before the backend closes.
+func (q *queuedBackend) Close() error {
+	q.mu.Lock()
+	if q.closed {
+		q.mu.Unlock()
+		return nil
+	}
+	q.closed = true
+	close(q.queue)
+	q.mu.Unlock()
+	<-q.done
+	return q.backend.Close()
+}
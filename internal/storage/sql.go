@@ -0,0 +1,289 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	_ "github.com/jackc/pgx/v5/stdlib"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	_ "modernc.org/sqlite"
+)
+
+// sqlTimeout bounds each statement so a slow database cannot stall request handling.
+const sqlTimeout = 10 * time.Second
+
+// sqliteDriver is the database/sql driver name modernc.org/sqlite registers; the driver
+// is pure Go, so the sqlite backend needs no cgo toolchain.
+const sqliteDriver = "sqlite"
+
+type dialect struct {
+	driver   string
+	idColumn string
+	numbered bool
+}
+
+var dialects = map[string]dialect{
+	config.StorageBackendPostgres: {driver: "pgx", idColumn: "BIGSERIAL PRIMARY KEY", numbered: true},
+	config.StorageBackendSQLite:   {driver: sqliteDriver, idColumn: "INTEGER PRIMARY KEY AUTOINCREMENT"},
+}
+
+// placeholders rewrites the ? placeholders of query for the dialect.
+func (d dialect) placeholders(query string) string {
+	if !d.numbered {
+		return query
+	}
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			fmt.Fprintf(&b, "$%d", n)
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// sqlPool is a connection pool shared by every collection opened on the same database.
+type sqlPool struct {
+	db   *sql.DB
+	refs int
+
+	mu     sync.Mutex
+	tables map[string]bool
+}
+
+var (
+	poolsMu sync.Mutex
+	pools   = make(map[string]*sqlPool)
+)
+
+// SQLBackend stores records as rows of one table, keyed by collection and key.
+type SQLBackend struct {
+	poolKey    string
+	pool       *sqlPool
+	dialect    dialect
+	table      string
+	index      string
+	collection string
+	closeOnce  sync.Once
+}
+
+func openSQL(cfg config.StorageConfig, collection string) (*SQLBackend, error) {
+	d, ok := dialects[cfg.Backend]
+	if !ok {
+		return nil, fmt.Errorf("storage: unknown backend %q", cfg.Backend)
+	}
+	if cfg.DSN == "" {
+		return nil, fmt.Errorf("storage: %s backend requires a dsn", cfg.Backend)
+	}
+	if !slices.Contains(sql.Drivers(), d.driver) {
+		return nil, fmt.Errorf("storage: %s backend needs a database/sql driver registered as %q in this build", cfg.Backend, d.driver)
+	}
+	table, index, err := tableNames(cfg.Table)
+	if err != nil {
+		return nil, err
+	}
+
+	key := d.driver + "\x00" + cfg.DSN
+	poolsMu.Lock()
+	defer poolsMu.Unlock()
+	pool := pools[key]
+	if pool == nil {
+		db, errOpen := sql.Open(d.driver, cfg.DSN)
+		if errOpen != nil {
+			return nil, fmt.Errorf("storage: open %s database: %w", cfg.Backend, errOpen)
+		}
+		if d.driver == sqliteDriver {
+			// SQLite allows a single writer; serializing connections avoids busy errors.
+			db.SetMaxOpenConns(1)
+		}
+		pool = &sqlPool{db: db, tables: make(map[string]bool)}
+		pools[key] = pool
+	}
+	pool.refs++
+	return &SQLBackend{poolKey: key, pool: pool, dialect: d, table: table, index: index, collection: collection}, nil
+}
+
+// tableNames quotes the possibly schema-qualified table name and derives its index name.
+func tableNames(name string) (table, index string, err error) {
+	if name == "" {
+		name = config.DefaultStorageTable
+	}
+	parts := strings.Split(name, ".")
+	if len(parts) > 2 {
+		return "", "", fmt.Errorf("storage: invalid table name %q", name)
+	}
+	quoted := make([]string, len(parts))
+	for i, part := range parts {
+		if part == "" {
+			return "", "", fmt.Errorf("storage: invalid table name %q", name)
+		}
+		quoted[i] = quoteIdentifier(part)
+	}
+	return strings.Join(quoted, "."), quoteIdentifier(parts[len(parts)-1] + "_key_idx"), nil
+}
+
+func quoteIdentifier(identifier string) string {
+	return `"` + strings.ReplaceAll(identifier, `"`, `""`) + `"`
+}
+
+// ready creates the table on first use, so opening a backend never blocks on the database.
+func (b *SQLBackend) ready(ctx context.Context) error {
+	b.pool.mu.Lock()
+	defer b.pool.mu.Unlock()
+	if b.pool.tables[b.table] {
+		return nil
+	}
+	statements := []string{
+		fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+			id %s,
+			collection TEXT NOT NULL,
+			record_key TEXT NOT NULL,
+			created_at BIGINT NOT NULL,
+			data TEXT NOT NULL
+		)`, b.table, b.dialect.idColumn),
+		fmt.Sprintf(`CREATE INDEX IF NOT EXISTS %s ON %s (collection, record_key, id)`, b.index, b.table),
+	}
+	for _, statement := range statements {
+		if _, err := b.pool.db.ExecContext(ctx, statement); err != nil {
+			return fmt.Errorf("storage: create table: %w", err)
+		}
+	}
+	b.pool.tables[b.table] = true
+	return nil
+}
+
+// Append implements Backend.
+func (b *SQLBackend) Append(key string, records ...[]byte) error {
+	if len(records) == 0 {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), sqlTimeout)
+	defer cancel()
+	if err := b.ready(ctx); err != nil {
+		return err
+	}
+	tx, err := b.pool.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("storage: begin append: %w", err)
+	}
+	query := b.dialect.placeholders(fmt.Sprintf("INSERT INTO %s (collection, record_key, created_at, data) VALUES (?, ?, ?, ?)", b.table))
+	now := time.Now().UnixNano()
+	for _, record := range records {
+		if _, err = tx.ExecContext(ctx, query, b.collection, key, now, string(record)); err != nil {
+			_ = tx.Rollback()
+			return fmt.Errorf("storage: append %s: %w", key, err)
+		}
+	}
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("storage: commit append: %w", err)
+	}
+	return nil
+}
+
+// Read implements Backend.
+func (b *SQLBackend) Read(key string) ([][]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), sqlTimeout)
+	defer cancel()
+	if err := b.ready(ctx); err != nil {
+		return nil, err
+	}
+	query := b.dialect.placeholders(fmt.Sprintf("SELECT data FROM %s WHERE collection = ? AND record_key = ? ORDER BY id", b.table))
+	rows, err := b.pool.db.QueryContext(ctx, query, b.collection, key)
+	if err != nil {
+		return nil, fmt.Errorf("storage: read %s: %w", key, err)
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+	var records [][]byte
+	for rows.Next() {
+		var data string
+		if err = rows.Scan(&data); err != nil {
+			return nil, fmt.Errorf("storage: read %s: %w", key, err)
+		}
+		records = append(records, []byte(data))
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("storage: read %s: %w", key, err)
+	}
+	if len(records) == 0 {
+		return nil, ErrNotFound
+	}
+	return records, nil
+}
+
+// Keys implements Backend; a key's last activity is the time of its newest record.
+func (b *SQLBackend) Keys() ([]KeyInfo, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), sqlTimeout)
+	defer cancel()
+	if err := b.ready(ctx); err != nil {
+		return nil, err
+	}
+	query := b.dialect.placeholders(fmt.Sprintf(
+		"SELECT record_key, CAST(SUM(LENGTH(data)) AS BIGINT), MAX(created_at) FROM %s WHERE collection = ? GROUP BY record_key", b.table))
+	rows, err := b.pool.db.QueryContext(ctx, query, b.collection)
+	if err != nil {
+		return nil, fmt.Errorf("storage: list keys: %w", err)
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+	var keys []KeyInfo
+	for rows.Next() {
+		var info KeyInfo
+		var last int64
+		if err = rows.Scan(&info.Key, &info.Size, &last); err != nil {
+			return nil, fmt.Errorf("storage: list keys: %w", err)
+		}
+		info.LastActivity = time.Unix(0, last)
+		keys = append(keys, info)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("storage: list keys: %w", err)
+	}
+	return keys, nil
+}
+
+// Delete implements Backend.
+func (b *SQLBackend) Delete(key string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), sqlTimeout)
+	defer cancel()
+	if err := b.ready(ctx); err != nil {
+		return err
+	}
+	query := b.dialect.placeholders(fmt.Sprintf("DELETE FROM %s WHERE collection = ? AND record_key = ?", b.table))
+	result, err := b.pool.db.ExecContext(ctx, query, b.collection, key)
+	if err != nil {
+		return fmt.Errorf("storage: delete %s: %w", key, err)
+	}
+	if n, errRows := result.RowsAffected(); errRows == nil && n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// Close implements Backend; the shared pool closes with its last backend.
+func (b *SQLBackend) Close() error {
+	var err error
+	b.closeOnce.Do(func() {
+		poolsMu.Lock()
+		defer poolsMu.Unlock()
+		b.pool.refs--
+		if b.pool.refs > 0 {
+			return
+		}
+		if pools[b.poolKey] == b.pool {
+			delete(pools, b.poolKey)
+		}
+		err = b.pool.db.Close()
+	})
+	return err
+}
@@ -0,0 +1,270 @@
+package storage
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+// fakeDB is an in-memory database/sql driver understanding the statements of SQLBackend.
+type fakeDB struct {
+	mu   sync.Mutex
+	rows []fakeRow
+	next int64
+}
+
+type fakeRow struct {
+	id                    int64
+	collection, key, data string
+	createdAt             int64
+}
+
+func (d *fakeDB) Open(string) (driver.Conn, error) { return fakeConn{db: d}, nil }
+
+type fakeConn struct{ db *fakeDB }
+
+func (c fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return fakeStmt{db: c.db, query: query}, nil
+}
+func (c fakeConn) Close() error              { return nil }
+func (c fakeConn) Begin() (driver.Tx, error) { return fakeTx{}, nil }
+
+type fakeTx struct{}
+
+func (fakeTx) Commit() error   { return nil }
+func (fakeTx) Rollback() error { return nil }
+
+type fakeStmt struct {
+	db    *fakeDB
+	query string
+}
+
+func (s fakeStmt) Close() error  { return nil }
+func (s fakeStmt) NumInput() int { return -1 }
+
+func (s fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	d := s.db
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	switch {
+	case strings.HasPrefix(strings.TrimSpace(s.query), "CREATE"):
+		return driver.RowsAffected(0), nil
+	case strings.HasPrefix(s.query, "INSERT"):
+		d.next++
+		d.rows = append(d.rows, fakeRow{id: d.next, collection: args[0].(string), key: args[1].(string), createdAt: args[2].(int64), data: args[3].(string)})
+		return driver.RowsAffected(1), nil
+	case strings.HasPrefix(s.query, "DELETE"):
+		kept := d.rows[:0]
+		for _, row := range d.rows {
+			if row.collection != args[0] || row.key != args[1] {
+				kept = append(kept, row)
+			}
+		}
+		removed := len(d.rows) - len(kept)
+		d.rows = kept
+		return driver.RowsAffected(removed), nil
+	}
+	return nil, errors.New("fake: unsupported statement " + s.query)
+}
+
+func (s fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	d := s.db
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	out := &fakeRows{}
+	switch {
+	case strings.HasPrefix(s.query, "SELECT data"):
+		out.columns = []string{"data"}
+		for _, row := range d.rows {
+			if row.collection == args[0] && row.key == args[1] {
+				out.values = append(out.values, []driver.Value{row.data})
+			}
+		}
+	case strings.HasPrefix(s.query, "SELECT record_key"):
+		out.columns = []string{"record_key", "size", "last"}
+		index := make(map[string]int)
+		for _, row := range d.rows {
+			if row.collection != args[0] {
+				continue
+			}
+			i, ok := index[row.key]
+			if !ok {
+				i = len(out.values)
+				index[row.key] = i
+				out.values = append(out.values, []driver.Value{row.key, int64(0), int64(0)})
+			}
+			out.values[i][1] = out.values[i][1].(int64) + int64(len(row.data))
+			out.values[i][2] = max(out.values[i][2].(int64), row.createdAt)
+		}
+	default:
+		return nil, errors.New("fake: unsupported query " + s.query)
+	}
+	return out, nil
+}
+
+type fakeRows struct {
+	columns []string
+	values  [][]driver.Value
+}
+
+func (r *fakeRows) Columns() []string { return r.columns }
+func (r *fakeRows) Close() error      { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.values) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.values[0])
+	r.values = r.values[1:]
+	return nil
+}
+
+var registerFakeDriver sync.Once
+
+func TestSQLBackendRoundTrip(t *testing.T) {
+	registerFakeDriver.Do(func() { sql.Register("storage-fake", &fakeDB{}) })
+	dialects["fake"] = dialect{driver: "storage-fake", idColumn: "INTEGER PRIMARY KEY"}
+	defer delete(dialects, "fake")
+
+	cfg := config.StorageConfig{Backend: "fake", DSN: "memory"}
+	sqlBackend, err := openSQL(cfg, Transcripts)
+	if err != nil {
+		t.Fatalf("openSQL: %v", err)
+	}
+	b := newQueuedBackend(sqlBackend)
+	other, err := openSQL(cfg, Usage)
+	if err != nil {
+		t.Fatalf("openSQL: %v", err)
+	}
+	if sqlBackend.pool != other.pool {
+		t.Fatal("backends on the same database should share a pool")
+	}
+
+	if _, err = b.Read("s1"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("read missing = %v, want ErrNotFound", err)
+	}
+	if err = b.Append("s1", []byte(`{"n":1}`), []byte(`{"n":2}`)); err != nil {
+		t.Fatalf("append: %v", err)
+	}
+	if err = other.Append("s1", []byte(`{"other":true}`)); err != nil {
+		t.Fatalf("append other collection: %v", err)
+	}
+	records, err := b.Read("s1")
+	if err != nil || len(records) != 2 || string(records[1]) != `{"n":2}` {
+		t.Fatalf("read = %q, %v", records, err)
+	}
+	keys, err := b.Keys()
+	if err != nil || len(keys) != 1 || keys[0].Key != "s1" || keys[0].Size != 14 || keys[0].LastActivity.IsZero() {
+		t.Fatalf("keys = %+v, %v", keys, err)
+	}
+	if err = b.Delete("s1"); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if err = b.Delete("s1"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("second delete = %v, want ErrNotFound", err)
+	}
+	if records, err = other.Read("s1"); err != nil || len(records) != 1 {
+		t.Fatalf("other collection = %q, %v", records, err)
+	}
+
+	if err = b.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	if err = b.Append("s1", []byte("{}")); err == nil {
+		t.Fatal("append after close should fail")
+	}
+	if err = other.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	if len(pools) != 0 {
+		t.Fatalf("pools left open: %d", len(pools))
+	}
+}
+
+func TestPlaceholders(t *testing.T) {
+	query := "a = ? AND b = ?"
+	if got := dialects["postgres"].placeholders(query); got != "a = $1 AND b = $2" {
+		t.Fatalf("numbered placeholders = %q", got)
+	}
+	if got := (dialect{}).placeholders(query); got != query {
+		t.Fatalf("positional placeholders = %q", got)
+	}
+}
+
+func TestTableNames(t *testing.T) {
+	for _, tc := range []struct {
+		name, table, index string
+	}{
+		{name: "", table: `"cliproxy_records"`, index: `"cliproxy_records_key_idx"`},
+		{name: "stats.records", table: `"stats"."records"`, index: `"records_key_idx"`},
+		{name: `we"ird`, table: `"we""ird"`, index: `"we""ird_key_idx"`},
+	} {
+		table, index, err := tableNames(tc.name)
+		if err != nil || table != tc.table || index != tc.index {
+			t.Fatalf("tableNames(%q) = %s, %s, %v", tc.name, table, index, err)
+		}
+	}
+	for _, name := range []string{"a.b.c", "a.", ".b"} {
+		if _, _, err := tableNames(name); err == nil {
+			t.Fatalf("tableNames(%q) should fail", name)
+		}
+	}
+}
+
+// blockingBackend holds the first append until release is closed and counts the records
+// of every append.
+type blockingBackend struct {
+	FileBackend
+	started chan struct{}
+	release chan struct{}
+	blocked atomic.Bool
+
+	mu      sync.Mutex
+	records int
+}
+
+func (b *blockingBackend) Append(_ string, records ...[]byte) error {
+	if b.blocked.CompareAndSwap(false, true) {
+		close(b.started)
+		<-b.release
+	}
+	b.mu.Lock()
+	b.records += len(records)
+	b.mu.Unlock()
+	return nil
+}
+
+func TestQueuedBackendKeepsAppendsWhenFull(t *testing.T) {
+	defer func(wait time.Duration) { appendQueueWait = wait }(appendQueueWait)
+	appendQueueWait = time.Millisecond
+
+	slow := &blockingBackend{FileBackend: *NewFileBackend(t.TempDir()), started: make(chan struct{}), release: make(chan struct{})}
+	b := newQueuedBackend(slow)
+	// The first append occupies the writer, the next appendQueueSize fill the queue and
+	// the rest overflow it.
+	if err := b.Append("k", []byte("{}")); err != nil {
+		t.Fatalf("append: %v", err)
+	}
+	<-slow.started
+	total := appendQueueSize + 50
+	for i := 1; i < total; i++ {
+		if err := b.Append("k", []byte("{}")); err != nil {
+			t.Fatalf("append %d: %v", i, err)
+		}
+	}
+	close(slow.release)
+	if err := b.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	if slow.records != total {
+		t.Fatalf("records written = %d, want %d", slow.records, total)
+	}
+}
@@ -0,0 +1,92 @@
+// Package storage abstracts where transcripts and usage history are persisted. Records
+// are opaque JSON documents appended to per-key logs within a collection; the file
+// backend keeps each log as a local JSONL file and the SQL backends keep them as rows of
+// one shared table, so laptops stay dependency free while larger deployments can point
+// every instance at the same database. Appends to the SQL backends are queued and written
+// in the background, so a slow database never stalls the request path.
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+// Collections persisted through a backend.
+const (
+	Transcripts = "transcripts"
+	Usage       = "usage"
+)
+
+// ErrNotFound is returned when a key has no stored records.
+var ErrNotFound = errors.New("storage: key not found")
+
+// KeyInfo summarises the records stored under one key.
+type KeyInfo struct {
+	Key          string
+	Size         int64
+	LastActivity time.Time
+}
+
+// Backend persists append-only record logs, grouped by key, of one collection.
+type Backend interface {
+	// Append adds records to the end of the key's log.
+	Append(key string, records ...[]byte) error
+	// Read returns the records of key in the order they were appended, or ErrNotFound.
+	Read(key string) ([][]byte, error)
+	// Keys lists the stored keys.
+	Keys() ([]KeyInfo, error)
+	// Delete removes key and its records, or returns ErrNotFound.
+	Delete(key string) error
+	// Close releases the resources held by the backend.
+	Close() error
+}
+
+var (
+	configMu sync.Mutex
+	current  = config.StorageConfig{Backend: config.StorageBackendFile, Table: config.DefaultStorageTable}
+)
+
+// Configure selects the backend returned by later Open calls. Backends already opened
+// keep their settings until their owners reopen them.
+func Configure(cfg config.StorageConfig) {
+	configMu.Lock()
+	current = cfg
+	configMu.Unlock()
+}
+
+// BackendName returns the configured backend name.
+func BackendName() string {
+	configMu.Lock()
+	defer configMu.Unlock()
+	if current.Backend == "" {
+		return config.StorageBackendFile
+	}
+	return current.Backend
+}
+
+// Open returns the configured backend for collection. dir is where the file backend keeps
+// the collection; the SQL backends ignore it.
+func Open(collection, dir string) (Backend, error) {
+	configMu.Lock()
+	cfg := current
+	configMu.Unlock()
+	switch cfg.Backend {
+	case config.StorageBackendSQLite, config.StorageBackendPostgres:
+		backend, err := openSQL(cfg, collection)
+		if err != nil {
+			return nil, err
+		}
+		return newQueuedBackend(backend), nil
+	case "", config.StorageBackendFile:
+		if dir == "" {
+			return nil, fmt.Errorf("storage: no directory for %s", collection)
+		}
+		return NewFileBackend(dir), nil
+	default:
+		return nil, fmt.Errorf("storage: unknown backend %q", cfg.Backend)
+	}
+}
@@ -0,0 +1,110 @@
+package storage
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+func TestFileBackend(t *testing.T) {
+	b := NewFileBackend(t.TempDir())
+	if _, err := b.Read("missing"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("read missing = %v, want ErrNotFound", err)
+	}
+	if err := b.Append("s1", []byte(`{"n":1}`), []byte("{\"n\":2}\n")); err != nil {
+		t.Fatalf("append: %v", err)
+	}
+	if err := b.Append("s1", []byte(`{"n":3}`)); err != nil {
+		t.Fatalf("append: %v", err)
+	}
+	records, err := b.Read("s1")
+	if err != nil || len(records) != 3 || string(records[2]) != `{"n":3}` {
+		t.Fatalf("read = %q, %v", records, err)
+	}
+	keys, err := b.Keys()
+	if err != nil || len(keys) != 1 || keys[0].Key != "s1" || keys[0].Size == 0 {
+		t.Fatalf("keys = %+v, %v", keys, err)
+	}
+	if err = b.Append("../escape", []byte("{}")); err == nil {
+		t.Fatal("append accepted a key escaping the directory")
+	}
+	if err = b.Delete("s1"); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if err = b.Delete("s1"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("second delete = %v, want ErrNotFound", err)
+	}
+}
+
+func TestOpenSelectsConfiguredBackend(t *testing.T) {
+	defer Configure(config.StorageConfig{Backend: config.StorageBackendFile})
+
+	Configure(config.StorageConfig{Backend: config.StorageBackendFile})
+	b, err := Open(Transcripts, t.TempDir())
+	if err != nil {
+		t.Fatalf("open file backend: %v", err)
+	}
+	if _, ok := b.(*FileBackend); !ok {
+		t.Fatalf("backend = %T, want *FileBackend", b)
+	}
+
+	Configure(config.StorageConfig{Backend: config.StorageBackendPostgres})
+	if _, err = Open(Usage, ""); err == nil || !strings.Contains(err.Error(), "requires a dsn") {
+		t.Fatalf("open postgres without dsn = %v", err)
+	}
+
+	Configure(config.StorageConfig{Backend: config.StorageBackendPostgres, DSN: "postgres://localhost/cliproxy", Table: "stats.records"})
+	pg, err := Open(Usage, "")
+	if err != nil {
+		t.Fatalf("open postgres backend: %v", err)
+	}
+	backend := pg.(*queuedBackend).backend.(*SQLBackend)
+	if backend.table != `"stats"."records"` || backend.index != `"records_key_idx"` {
+		t.Fatalf("table = %s, index = %s", backend.table, backend.index)
+	}
+	if err = pg.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	if len(pools) != 0 {
+		t.Fatalf("pools left open: %d", len(pools))
+	}
+}
+
+func TestSQLiteBackend(t *testing.T) {
+	defer Configure(config.StorageConfig{Backend: config.StorageBackendFile})
+
+	Configure(config.StorageConfig{Backend: config.StorageBackendSQLite, DSN: filepath.Join(t.TempDir(), "stats.db")})
+	b, err := Open(Usage, "")
+	if err != nil {
+		t.Fatalf("open sqlite backend: %v", err)
+	}
+	defer func() {
+		_ = b.Close()
+	}()
+	if _, err = b.Read("missing"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("read missing = %v, want ErrNotFound", err)
+	}
+	if err = b.Append("2024-05-01", []byte(`{"n":1}`), []byte(`{"n":2}`)); err != nil {
+		t.Fatalf("append: %v", err)
+	}
+	if err = b.Append("2024-05-01", []byte(`{"n":3}`)); err != nil {
+		t.Fatalf("append: %v", err)
+	}
+	records, err := b.Read("2024-05-01")
+	if err != nil || len(records) != 3 || string(records[0]) != `{"n":1}` || string(records[2]) != `{"n":3}` {
+		t.Fatalf("read = %q, %v", records, err)
+	}
+	keys, err := b.Keys()
+	if err != nil || len(keys) != 1 || keys[0].Key != "2024-05-01" || keys[0].Size != 21 {
+		t.Fatalf("keys = %+v, %v", keys, err)
+	}
+	if err = b.Delete("2024-05-01"); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if err = b.Delete("2024-05-01"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("second delete = %v, want ErrNotFound", err)
+	}
+}
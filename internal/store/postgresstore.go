@@ -107,6 +107,14 @@ func (s *PostgresStore) Close() error {
 	return s.db.Close()
 }
 
+// Ping verifies the database connection; it backs the readiness probe.
+func (s *PostgresStore) Ping(ctx context.Context) error {
+	if s == nil || s.db == nil {
+		return fmt.Errorf("postgres store: not initialized")
+	}
+	return s.db.PingContext(ctx)
+}
+
 // EnsureSchema creates the required tables (and schema when provided).
 func (s *PostgresStore) EnsureSchema(ctx context.Context) error {
 	if s == nil || s.db == nil {
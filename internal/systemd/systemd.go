@@ -0,0 +1,86 @@
+// Package systemd implements the small subset of the systemd service protocol the proxy
+// needs: socket activation (LISTEN_FDS), readiness notification (sd_notify) and the
+// watchdog keep-alive. Every function is a no-op when the process is not run by systemd.
+package systemd
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// listenFDsStart is the first file descriptor passed by systemd (SD_LISTEN_FDS_START).
+const listenFDsStart = 3
+
+// Listeners returns the sockets passed by systemd socket activation, in the order of the
+// ListenStream= lines of the socket unit. It returns nil when the process was not activated.
+// The activation variables are cleared so child processes do not inherit them.
+func Listeners() ([]net.Listener, error) {
+	pid, errPID := strconv.Atoi(os.Getenv("LISTEN_PID"))
+	count, errCount := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if errPID != nil || errCount != nil || pid != os.Getpid() || count <= 0 {
+		return nil, nil
+	}
+	names := strings.Split(os.Getenv("LISTEN_FDNAMES"), ":")
+	_ = os.Unsetenv("LISTEN_PID")
+	_ = os.Unsetenv("LISTEN_FDS")
+	_ = os.Unsetenv("LISTEN_FDNAMES")
+
+	listeners := make([]net.Listener, 0, count)
+	for i := 0; i < count; i++ {
+		name := "LISTEN_FD_" + strconv.Itoa(listenFDsStart+i)
+		if i < len(names) && names[i] != "" {
+			name = names[i]
+		}
+		file := os.NewFile(uintptr(listenFDsStart+i), name)
+		// FileListener duplicates the descriptor with close-on-exec set.
+		ln, err := net.FileListener(file)
+		_ = file.Close()
+		if err != nil {
+			for _, opened := range listeners {
+				_ = opened.Close()
+			}
+			return nil, fmt.Errorf("systemd: socket %s: %w", name, err)
+		}
+		listeners = append(listeners, ln)
+	}
+	return listeners, nil
+}
+
+// Notify sends state (e.g. "READY=1", "STOPPING=1", "WATCHDOG=1") to the service manager.
+// It reports false without error when NOTIFY_SOCKET is not set.
+func Notify(state string) (bool, error) {
+	socket := os.Getenv("NOTIFY_SOCKET")
+	if socket == "" {
+		return false, nil
+	}
+	// A leading '@' denotes a Linux abstract socket.
+	if strings.HasPrefix(socket, "@") {
+		socket = "\x00" + socket[1:]
+	}
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		return false, fmt.Errorf("systemd: notify: %w", err)
+	}
+	defer func() { _ = conn.Close() }()
+	if _, err = conn.Write([]byte(state)); err != nil {
+		return false, fmt.Errorf("systemd: notify: %w", err)
+	}
+	return true, nil
+}
+
+// WatchdogInterval returns how often the service must send WATCHDOG=1, which is half of
+// the WatchdogSec= configured on the unit. It returns 0 when the watchdog is disabled.
+func WatchdogInterval() time.Duration {
+	usec, err := strconv.ParseInt(os.Getenv("WATCHDOG_USEC"), 10, 64)
+	if err != nil || usec <= 0 {
+		return 0
+	}
+	if pid := os.Getenv("WATCHDOG_PID"); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return 0
+	}
+	return time.Duration(usec) * time.Microsecond / 2
+}
@@ -0,0 +1,334 @@
+// Package tempkey issues short-lived API keys derived from a client's API key. A temporary
+// key authenticates as its parent key, restricted to its models, request and token quota
+// and lifetime, so scripts and CI jobs never see the long-lived key.
+package tempkey
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	coreusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+)
+
+const (
+	// Prefix starts every temporary key.
+	Prefix = "cpt-"
+	// ContextKey is the gin context key holding the ID of the temporary key of a request.
+	ContextKey = "temporaryKey"
+)
+
+var (
+	// ErrUnknown is returned for keys that were never minted, were revoked or expired
+	// long ago.
+	ErrUnknown = errors.New("unknown temporary key")
+	// ErrExpired is returned for keys past their expiry.
+	ErrExpired = errors.New("temporary key expired")
+	// ErrQuotaExceeded is returned for keys that used up their request or token quota.
+	ErrQuotaExceeded = errors.New("temporary key quota exceeded")
+	// ErrLimit is returned by Mint when the parent key holds too many live keys.
+	ErrLimit = errors.New("too many temporary keys for this API key")
+)
+
+// MintRequest describes a key to mint.
+type MintRequest struct {
+	// TTLSeconds is the lifetime; 0 uses the configured default.
+	TTLSeconds int `json:"ttl_seconds,omitempty"`
+	// Models restricts the key to models matching one of the patterns ('*' wildcards).
+	// Empty allows every model.
+	Models []string `json:"models,omitempty"`
+	// MaxRequests caps the requests of the key; 0 is unlimited.
+	MaxRequests int64 `json:"max_requests,omitempty"`
+	// MaxTokens caps the tokens of the key; 0 is unlimited.
+	MaxTokens int64 `json:"max_tokens,omitempty"`
+	// Label describes the key, e.g. the CI job using it.
+	Label string `json:"label,omitempty"`
+}
+
+// Grant describes a minted key, without its secret.
+type Grant struct {
+	ID          string    `json:"id"`
+	Parent      string    `json:"parent"`
+	Label       string    `json:"label,omitempty"`
+	Models      []string  `json:"models,omitempty"`
+	MaxRequests int64     `json:"max_requests,omitempty"`
+	MaxTokens   int64     `json:"max_tokens,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+	ExpiresAt   time.Time `json:"expires_at"`
+	Requests    int64     `json:"requests"`
+	Tokens      int64     `json:"tokens"`
+}
+
+type grant struct {
+	Grant
+	parent string
+	hash   string
+}
+
+func (g *grant) public() Grant {
+	out := g.Grant
+	out.Models = append([]string(nil), g.Models...)
+	return out
+}
+
+// Store holds the minted keys.
+type Store struct {
+	mu      sync.Mutex
+	cfg     config.TemporaryKeysConfig
+	byHash  map[string]*grant
+	byID    map[string]*grant
+	nowFunc func() time.Time
+}
+
+var defaultStore = NewStore()
+
+func init() {
+	coreusage.RegisterPlugin(defaultStore)
+}
+
+// Default returns the process-wide store.
+func Default() *Store { return defaultStore }
+
+// NewStore constructs a disabled store.
+func NewStore() *Store {
+	return &Store{byHash: make(map[string]*grant), byID: make(map[string]*grant), nowFunc: time.Now}
+}
+
+// Configure applies cfg. Disabling the store revokes every key.
+func (s *Store) Configure(cfg config.TemporaryKeysConfig) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cfg = cfg
+	if !cfg.Enable {
+		s.byHash = make(map[string]*grant)
+		s.byID = make(map[string]*grant)
+	}
+}
+
+// Enabled reports whether temporary keys are accepted.
+func (s *Store) Enabled() bool {
+	if s == nil {
+		return false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.cfg.Enable
+}
+
+// Mint issues a key derived from parent and returns it with its grant. The key is only
+// returned here.
+func (s *Store) Mint(parent string, req MintRequest) (string, Grant, error) {
+	if strings.TrimSpace(parent) == "" {
+		return "", Grant{}, fmt.Errorf("missing parent key")
+	}
+	if req.MaxRequests < 0 || req.MaxTokens < 0 || req.TTLSeconds < 0 {
+		return "", Grant{}, fmt.Errorf("ttl and quotas must not be negative")
+	}
+	models := make([]string, 0, len(req.Models))
+	for _, model := range req.Models {
+		if model = strings.TrimSpace(model); model != "" {
+			models = append(models, model)
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.cfg.Enable {
+		return "", Grant{}, fmt.Errorf("temporary keys are disabled")
+	}
+	ttl := req.TTLSeconds
+	if ttl == 0 {
+		ttl = s.cfg.DefaultTTLSeconds
+	}
+	if ttl > s.cfg.MaxTTLSeconds {
+		return "", Grant{}, fmt.Errorf("ttl_seconds exceeds the maximum of %d", s.cfg.MaxTTLSeconds)
+	}
+	now := s.nowFunc()
+	s.pruneLocked(now)
+	live := 0
+	for _, g := range s.byID {
+		if g.parent == parent {
+			live++
+		}
+	}
+	if live >= s.cfg.MaxPerKey {
+		return "", Grant{}, ErrLimit
+	}
+
+	secret := make([]byte, 24)
+	if _, err := rand.Read(secret); err != nil {
+		return "", Grant{}, fmt.Errorf("generate key: %w", err)
+	}
+	key := Prefix + hex.EncodeToString(secret)
+	hash := hashKey(key)
+	g := &grant{
+		Grant: Grant{
+			ID:          "tk_" + hash[:12],
+			Parent:      util.HideAPIKey(parent),
+			Label:       strings.TrimSpace(req.Label),
+			Models:      models,
+			MaxRequests: req.MaxRequests,
+			MaxTokens:   req.MaxTokens,
+			CreatedAt:   now,
+			ExpiresAt:   now.Add(time.Duration(ttl) * time.Second),
+		},
+		parent: parent,
+		hash:   hash,
+	}
+	s.byHash[hash] = g
+	s.byID[g.ID] = g
+	return key, g.public(), nil
+}
+
+// Lookup authenticates key and returns its parent key and grant. The request is not
+// counted against the grant until Commit, so requests rejected after the lookup do not
+// use up its quota.
+func (s *Store) Lookup(key string) (string, Grant, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.cfg.Enable {
+		return "", Grant{}, ErrUnknown
+	}
+	g, ok := s.byHash[hashKey(key)]
+	if !ok {
+		return "", Grant{}, ErrUnknown
+	}
+	if err := s.usableLocked(g); err != nil {
+		return "", Grant{}, err
+	}
+	return g.parent, g.public(), nil
+}
+
+// Commit counts one admitted request against the grant id. The quota is checked again,
+// as concurrent requests may have used it up since their lookup.
+func (s *Store) Commit(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	g, ok := s.byID[id]
+	if !ok || !s.cfg.Enable {
+		return ErrUnknown
+	}
+	if err := s.usableLocked(g); err != nil {
+		return err
+	}
+	g.Requests++
+	return nil
+}
+
+func (s *Store) usableLocked(g *grant) error {
+	if !s.nowFunc().Before(g.ExpiresAt) {
+		return ErrExpired
+	}
+	if (g.MaxRequests > 0 && g.Requests >= g.MaxRequests) || (g.MaxTokens > 0 && g.Tokens >= g.MaxTokens) {
+		return ErrQuotaExceeded
+	}
+	return nil
+}
+
+// AllowsModel reports whether the grant may use model.
+func (g Grant) AllowsModel(model string) bool {
+	if len(g.Models) == 0 {
+		return true
+	}
+	for _, pattern := range g.Models {
+		if util.MatchWildcard(pattern, model) {
+			return true
+		}
+	}
+	return false
+}
+
+// Revoke deletes the key id. A non-empty parent restricts the revocation to keys derived
+// from it. It reports whether a key was deleted.
+func (s *Store) Revoke(id, parent string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	g, ok := s.byID[id]
+	if !ok || (parent != "" && g.parent != parent) {
+		return false
+	}
+	delete(s.byID, id)
+	delete(s.byHash, g.hash)
+	return true
+}
+
+// List returns the live keys, of parent when it is not empty, oldest first.
+func (s *Store) List(parent string) []Grant {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pruneLocked(s.nowFunc())
+	out := make([]Grant, 0, len(s.byID))
+	for _, g := range s.byID {
+		if parent == "" || g.parent == parent {
+			out = append(out, g.public())
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].CreatedAt.Before(out[j].CreatedAt) })
+	return out
+}
+
+// HandleUsage implements coreusage.Plugin, charging the tokens of a request to the
+// temporary key that made it.
+func (s *Store) HandleUsage(ctx context.Context, record coreusage.Record) {
+	id := FromContext(ctx)
+	if s == nil || id == "" {
+		return
+	}
+	tokens := record.Detail.TotalTokens
+	if tokens == 0 {
+		tokens = record.Detail.InputTokens + record.Detail.OutputTokens + record.Detail.ReasoningTokens
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if g, ok := s.byID[id]; ok {
+		g.Tokens += tokens
+	}
+}
+
+// pruneLocked drops keys expired for an hour; recently expired ones still report
+// ErrExpired.
+func (s *Store) pruneLocked(now time.Time) {
+	for id, g := range s.byID {
+		if now.Sub(g.ExpiresAt) > time.Hour {
+			delete(s.byID, id)
+			delete(s.byHash, g.hash)
+		}
+	}
+}
+
+type tempKeyContextKey struct{}
+
+// WithID returns a context carrying the temporary key id.
+func WithID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, tempKeyContextKey{}, id)
+}
+
+// FromContext returns the temporary key id attached to ctx, either directly or via the gin
+// context stored under "gin" by the API handlers.
+func FromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	if id, ok := ctx.Value(tempKeyContextKey{}).(string); ok && id != "" {
+		return id
+	}
+	if ginCtx, ok := ctx.Value("gin").(*gin.Context); ok && ginCtx != nil {
+		return ginCtx.GetString(ContextKey)
+	}
+	return ""
+}
+
+func hashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
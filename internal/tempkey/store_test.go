@@ -0,0 +1,121 @@
+package tempkey
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	coreusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+)
+
+func newTestStore(now *time.Time) *Store {
+	s := NewStore()
+	s.nowFunc = func() time.Time { return *now }
+	cfg := &config.Config{TemporaryKeys: config.TemporaryKeysConfig{Enable: true, MaxPerKey: 2}}
+	cfg.SanitizeTemporaryKeys()
+	s.Configure(cfg.TemporaryKeys)
+	return s
+}
+
+// admit looks key up and counts the request, as the auth middleware does for admitted
+// requests.
+func admit(s *Store, key string) (string, Grant, error) {
+	parent, grant, err := s.Lookup(key)
+	if err == nil {
+		err = s.Commit(grant.ID)
+	}
+	return parent, grant, err
+}
+
+func TestStoreMintAndAdmit(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	s := newTestStore(&now)
+
+	key, grant, err := s.Mint("parent-key", MintRequest{TTLSeconds: 60, Models: []string{"gpt-5*", " "}, MaxRequests: 2, Label: "ci"})
+	if err != nil {
+		t.Fatalf("Mint: %v", err)
+	}
+	if !strings.HasPrefix(key, Prefix) || strings.Contains(grant.Parent, "parent-key") {
+		t.Fatalf("key %q, masked parent %q", key, grant.Parent)
+	}
+	if len(grant.Models) != 1 || !grant.AllowsModel("gpt-5-mini") || grant.AllowsModel("claude-sonnet-4") {
+		t.Fatalf("models = %v", grant.Models)
+	}
+
+	parent, admitted, err := admit(s, key)
+	if err != nil || parent != "parent-key" || admitted.ID != grant.ID {
+		t.Fatalf("Admit = %q, %q, %v", parent, admitted.ID, err)
+	}
+	if _, _, err = admit(s, key); err != nil {
+		t.Fatalf("second Admit: %v", err)
+	}
+	if _, _, err = admit(s, key); !errors.Is(err, ErrQuotaExceeded) {
+		t.Fatalf("third Admit error = %v, want quota", err)
+	}
+	if _, _, err = admit(s, Prefix+"unknown"); !errors.Is(err, ErrUnknown) {
+		t.Fatalf("unknown key error = %v", err)
+	}
+}
+
+func TestStoreExpiryAndLimits(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	s := newTestStore(&now)
+
+	if _, _, err := s.Mint("parent-key", MintRequest{TTLSeconds: 86401}); err == nil {
+		t.Fatal("ttl above the maximum was accepted")
+	}
+	key, _, err := s.Mint("parent-key", MintRequest{})
+	if err != nil {
+		t.Fatalf("Mint: %v", err)
+	}
+	if _, _, err = s.Mint("parent-key", MintRequest{}); err != nil {
+		t.Fatalf("second Mint: %v", err)
+	}
+	if _, _, err = s.Mint("parent-key", MintRequest{}); !errors.Is(err, ErrLimit) {
+		t.Fatalf("third Mint error = %v, want limit", err)
+	}
+	if _, _, err = s.Mint("other-key", MintRequest{}); err != nil {
+		t.Fatalf("Mint for another parent: %v", err)
+	}
+
+	now = now.Add(time.Hour)
+	if _, _, err = admit(s, key); !errors.Is(err, ErrExpired) {
+		t.Fatalf("expired Admit error = %v", err)
+	}
+	now = now.Add(2 * time.Hour)
+	if got := s.List(""); len(got) != 0 {
+		t.Fatalf("List after expiry = %v", got)
+	}
+	if _, _, err = admit(s, key); !errors.Is(err, ErrUnknown) {
+		t.Fatalf("pruned Admit error = %v", err)
+	}
+}
+
+func TestStoreTokenQuotaAndRevoke(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	s := newTestStore(&now)
+
+	key, grant, err := s.Mint("parent-key", MintRequest{MaxTokens: 100})
+	if err != nil {
+		t.Fatalf("Mint: %v", err)
+	}
+	s.HandleUsage(WithID(context.Background(), grant.ID), coreusage.Record{Detail: coreusage.Detail{InputTokens: 60, OutputTokens: 40}})
+	if _, _, err = admit(s, key); !errors.Is(err, ErrQuotaExceeded) {
+		t.Fatalf("Admit after token quota error = %v", err)
+	}
+
+	if s.Revoke(grant.ID, "other-key") {
+		t.Fatal("revoked a key of another parent")
+	}
+	if !s.Revoke(grant.ID, "parent-key") || len(s.List("parent-key")) != 0 {
+		t.Fatal("Revoke did not delete the key")
+	}
+
+	s.Configure(config.TemporaryKeysConfig{})
+	if _, _, err = s.Mint("parent-key", MintRequest{}); err == nil {
+		t.Fatal("Mint succeeded while disabled")
+	}
+}
@@ -0,0 +1,39 @@
+package thinking
+
+import "strings"
+
+// Requested returns the thinking setting a client asked for: the suffix of model when it has
+// a recognized one, else the thinking fields of body in the client's format (a handler type
+// such as "openai", "openai-response", "claude" or "gemini"). Budgets are reported with the
+// level ConvertBudgetToLevel assigns them and levels with the nominal budget of
+// ConvertLevelToBudget, so requests of both shapes can be compared; auto reports budget -1.
+// ok is false when the request sets no thinking.
+func Requested(body []byte, model, format string) (level string, budget int, ok bool) {
+	var config ThinkingConfig
+	if suffix := ParseSuffix(model); suffix.HasSuffix {
+		config = parseSuffixToConfig(suffix.RawSuffix, format, model)
+	}
+	if !hasThinkingConfig(config) {
+		format = strings.ToLower(strings.TrimSpace(format))
+		if format == "openai-response" {
+			format = "codex"
+		}
+		config = extractThinkingConfig(body, format)
+	}
+	switch config.Mode {
+	case ModeNone:
+		return string(LevelNone), 0, true
+	case ModeAuto:
+		return string(LevelAuto), -1, true
+	case ModeLevel:
+		level = strings.ToLower(string(config.Level))
+		budget, _ = ConvertLevelToBudget(level)
+		return level, budget, level != ""
+	default:
+		if config.Budget <= 0 {
+			return "", 0, false
+		}
+		level, _ = ConvertBudgetToLevel(config.Budget)
+		return level, config.Budget, true
+	}
+}
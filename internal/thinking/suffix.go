@@ -144,3 +144,97 @@ func ParseLevelSuffix(rawSuffix string) (level ThinkingLevel, ok bool) {
 		return "", false
 	}
 }
+
+// SupportedSuffixes lists the suffix values understood by parseSuffixToConfig, with
+// "<budget>" standing for any non-negative token budget.
+func SupportedSuffixes() []string {
+	return []string{"none", "auto", "-1", "minimal", "low", "medium", "high", "xhigh", "<budget>"}
+}
+
+// IsKnownSuffix reports whether rawSuffix is a special value, a level or a numeric budget.
+func IsKnownSuffix(rawSuffix string) bool {
+	if _, ok := ParseSpecialSuffix(rawSuffix); ok {
+		return true
+	}
+	if _, ok := ParseLevelSuffix(rawSuffix); ok {
+		return true
+	}
+	_, ok := ParseNumericSuffix(rawSuffix)
+	return ok
+}
+
+// suffixAliases maps common spellings of thinking settings to supported suffixes.
+var suffixAliases = map[string]string{
+	"off":       "none",
+	"disable":   "none",
+	"disabled":  "none",
+	"false":     "none",
+	"on":        "auto",
+	"enable":    "auto",
+	"enabled":   "auto",
+	"true":      "auto",
+	"dynamic":   "auto",
+	"min":       "minimal",
+	"minimum":   "minimal",
+	"lowest":    "minimal",
+	"lo":        "low",
+	"med":       "medium",
+	"mid":       "medium",
+	"normal":    "medium",
+	"default":   "medium",
+	"hi":        "high",
+	"max":       "xhigh",
+	"maximum":   "xhigh",
+	"highest":   "xhigh",
+	"ultra":     "xhigh",
+	"extra":     "xhigh",
+	"extrahigh": "xhigh",
+	"veryhigh":  "xhigh",
+}
+
+// NearestSuffix maps an unrecognized suffix to the closest supported one: known aliases
+// ("max" -> "xhigh", "off" -> "none"), budgets in thousands ("16k" -> "16384") and level
+// names within two edits ("meduim" -> "medium"). ok is false when nothing is close.
+func NearestSuffix(rawSuffix string) (suffix string, ok bool) {
+	normalized := strings.ToLower(strings.TrimSpace(rawSuffix))
+	if IsKnownSuffix(normalized) {
+		return normalized, true
+	}
+	normalized = strings.NewReplacer("-", "", "_", "", " ", "").Replace(normalized)
+	if alias, found := suffixAliases[normalized]; found {
+		return alias, true
+	}
+	if thousands, found := strings.CutSuffix(normalized, "k"); found {
+		if budget, okBudget := ParseNumericSuffix(thousands); okBudget {
+			return strconv.Itoa(budget * 1024), true
+		}
+	}
+	best, bestDistance := "", 3
+	for _, level := range []ThinkingLevel{LevelMinimal, LevelLow, LevelMedium, LevelHigh, LevelXHigh} {
+		if d := editDistance(normalized, string(level)); d < bestDistance {
+			best, bestDistance = string(level), d
+		}
+	}
+	return best, best != ""
+}
+
+// editDistance returns the Levenshtein distance between a and b.
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(b)]
+}
@@ -0,0 +1,417 @@
+package transcript
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/constant"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// maxDiffJobs bounds how many finished comparison reports are kept in memory.
+const maxDiffJobs = 50
+
+// Diff job states.
+const (
+	DiffStatusRunning = "running"
+	DiffStatusDone    = "done"
+	DiffStatusFailed  = "failed"
+)
+
+// ErrDiffJobNotFound is returned for unknown comparison job IDs.
+var ErrDiffJobNotFound = errors.New("diff job not found")
+
+// ExecuteFunc runs a non-streaming request in the format of handlerType against model and
+// returns the response body.
+type ExecuteFunc func(ctx context.Context, handlerType, model string, body []byte) ([]byte, error)
+
+// DiffRequest selects the exchanges to replay and the two models to compare.
+type DiffRequest struct {
+	SessionID string   `json:"session"`
+	EntryIDs  []string `json:"entry_ids,omitempty"`
+	Original  string   `json:"original,omitempty"`
+	Mapped    string   `json:"mapped"`
+	Limit     int      `json:"limit,omitempty"`
+}
+
+// ResponseMetrics describes one replayed response.
+type ResponseMetrics struct {
+	Model        string   `json:"model"`
+	Error        string   `json:"error,omitempty"`
+	LatencyMs    int64    `json:"latency_ms"`
+	OutputChars  int      `json:"output_chars"`
+	ToolCalls    []string `json:"tool_calls"`
+	InputTokens  int64    `json:"input_tokens"`
+	OutputTokens int64    `json:"output_tokens"`
+	TotalTokens  int64    `json:"total_tokens"`
+}
+
+// EntryDiff compares the two replays of one stored exchange. Deltas are mapped minus
+// original; LengthRatio is the mapped output length over the original's.
+type EntryDiff struct {
+	EntryID           string          `json:"entry_id"`
+	Path              string          `json:"path"`
+	Skipped           string          `json:"skipped,omitempty"`
+	Original          ResponseMetrics `json:"original"`
+	Mapped            ResponseMetrics `json:"mapped"`
+	LengthRatio       float64         `json:"length_ratio"`
+	LatencyDeltaMs    int64           `json:"latency_delta_ms"`
+	InputTokensDelta  int64           `json:"input_tokens_delta"`
+	OutputTokensDelta int64           `json:"output_tokens_delta"`
+	ToolCallsMatch    bool            `json:"tool_calls_match"`
+}
+
+// DiffSummary aggregates the exchanges both models answered.
+type DiffSummary struct {
+	Compared            int     `json:"compared"`
+	Skipped             int     `json:"skipped"`
+	OriginalErrors      int     `json:"original_errors"`
+	MappedErrors        int     `json:"mapped_errors"`
+	ToolCallAgreement   float64 `json:"tool_call_agreement"`
+	MeanLengthRatio     float64 `json:"mean_length_ratio"`
+	OriginalMeanLatency int64   `json:"original_mean_latency_ms"`
+	MappedMeanLatency   int64   `json:"mapped_mean_latency_ms"`
+	OriginalTotalTokens int64   `json:"original_total_tokens"`
+	MappedTotalTokens   int64   `json:"mapped_total_tokens"`
+}
+
+// DiffReport is the state and result of a comparison job.
+type DiffReport struct {
+	ID         string      `json:"id"`
+	Status     string      `json:"status"`
+	Error      string      `json:"error,omitempty"`
+	SessionID  string      `json:"session"`
+	Original   string      `json:"original"`
+	Mapped     string      `json:"mapped"`
+	CreatedAt  time.Time   `json:"created_at"`
+	FinishedAt *time.Time  `json:"finished_at,omitempty"`
+	Entries    []EntryDiff `json:"entries"`
+	Summary    DiffSummary `json:"summary"`
+}
+
+// DiffJobs runs comparison jobs in the background and keeps their reports.
+type DiffJobs struct {
+	mu      sync.Mutex
+	reports map[string]*DiffReport
+	order   []string
+}
+
+// NewDiffJobs constructs an empty job registry.
+func NewDiffJobs() *DiffJobs {
+	return &DiffJobs{reports: make(map[string]*DiffReport)}
+}
+
+// Start validates req against entries, the stored exchanges of its session, and replays
+// the selected ones in the background. The returned report is a snapshot of the job.
+func (j *DiffJobs) Start(req DiffRequest, entries []Entry, execute ExecuteFunc) (DiffReport, error) {
+	req.Mapped = strings.TrimSpace(req.Mapped)
+	req.Original = strings.TrimSpace(req.Original)
+	if req.Mapped == "" {
+		return DiffReport{}, errors.New("mapped model is required")
+	}
+	selected, err := selectDiffEntries(entries, req)
+	if err != nil {
+		return DiffReport{}, err
+	}
+
+	report := &DiffReport{
+		ID:        uuid.NewString(),
+		Status:    DiffStatusRunning,
+		SessionID: req.SessionID,
+		Original:  req.Original,
+		Mapped:    req.Mapped,
+		CreatedAt: time.Now(),
+		Entries:   []EntryDiff{},
+	}
+	j.mu.Lock()
+	j.reports[report.ID] = report
+	j.order = append(j.order, report.ID)
+	for len(j.order) > maxDiffJobs {
+		delete(j.reports, j.order[0])
+		j.order = j.order[1:]
+	}
+	snapshot := cloneReport(report)
+	j.mu.Unlock()
+
+	go func() {
+		diffs := make([]EntryDiff, 0, len(selected))
+		for _, entry := range selected {
+			diffs = append(diffs, DiffEntry(context.Background(), entry, req.Original, req.Mapped, execute))
+		}
+		finished := time.Now()
+		j.mu.Lock()
+		defer j.mu.Unlock()
+		report.Entries = diffs
+		report.Summary = summarizeDiffs(diffs)
+		report.FinishedAt = &finished
+		report.Status = DiffStatusDone
+		if report.Summary.Compared == 0 {
+			report.Status = DiffStatusFailed
+			report.Error = "no exchange could be compared"
+		}
+	}()
+	return snapshot, nil
+}
+
+// Get returns the report of job id.
+func (j *DiffJobs) Get(id string) (DiffReport, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	report, ok := j.reports[id]
+	if !ok {
+		return DiffReport{}, ErrDiffJobNotFound
+	}
+	return cloneReport(report), nil
+}
+
+// List returns the reports without their entries, newest first.
+func (j *DiffJobs) List() []DiffReport {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	out := make([]DiffReport, 0, len(j.order))
+	for i := len(j.order) - 1; i >= 0; i-- {
+		report := *j.reports[j.order[i]]
+		report.Entries = nil
+		out = append(out, report)
+	}
+	return out
+}
+
+func cloneReport(report *DiffReport) DiffReport {
+	out := *report
+	out.Entries = append([]EntryDiff(nil), report.Entries...)
+	return out
+}
+
+// selectDiffEntries returns the listed entries in order, or the latest successful
+// exchanges up to req.Limit when none are listed.
+func selectDiffEntries(entries []Entry, req DiffRequest) ([]Entry, error) {
+	if len(req.EntryIDs) > 0 {
+		byID := make(map[string]Entry, len(entries))
+		for _, entry := range entries {
+			byID[entry.ID] = entry
+		}
+		selected := make([]Entry, 0, len(req.EntryIDs))
+		for _, id := range req.EntryIDs {
+			entry, ok := byID[id]
+			if !ok {
+				return nil, fmt.Errorf("entry %s not found in session", id)
+			}
+			selected = append(selected, entry)
+		}
+		return selected, nil
+	}
+	limit := req.Limit
+	if limit <= 0 {
+		limit = 10
+	}
+	var selected []Entry
+	for i := len(entries) - 1; i >= 0 && len(selected) < limit; i-- {
+		if entries[i].StatusCode >= 200 && entries[i].StatusCode < 300 {
+			selected = append(selected, entries[i])
+		}
+	}
+	if len(selected) == 0 {
+		return nil, errors.New("session has no successful exchanges to replay")
+	}
+	sort.SliceStable(selected, func(a, b int) bool { return selected[a].RequestedAt.Before(selected[b].RequestedAt) })
+	return selected, nil
+}
+
+// DiffEntry replays entry against original and mapped, one after the other, and compares
+// the responses. An empty original replays the model the exchange was recorded with.
+func DiffEntry(ctx context.Context, entry Entry, original, mapped string, execute ExecuteFunc) EntryDiff {
+	if original == "" {
+		original = entry.Model
+	}
+	diff := EntryDiff{
+		EntryID:  entry.ID,
+		Path:     entry.Path,
+		Original: ResponseMetrics{Model: original, ToolCalls: []string{}},
+		Mapped:   ResponseMetrics{Model: mapped, ToolCalls: []string{}},
+	}
+	handlerType := replayHandlerType(entry.Path)
+	switch {
+	case entry.Truncated:
+		diff.Skipped = "request was truncated when recorded"
+	case handlerType == "":
+		diff.Skipped = "unsupported endpoint"
+	case original == "":
+		diff.Skipped = "original model unknown"
+	}
+	if diff.Skipped != "" {
+		return diff
+	}
+
+	diff.Original = replayMetrics(ctx, entry, handlerType, original, execute)
+	diff.Mapped = replayMetrics(ctx, entry, handlerType, mapped, execute)
+	if diff.Original.Error != "" || diff.Mapped.Error != "" {
+		return diff
+	}
+	if diff.Original.OutputChars > 0 {
+		diff.LengthRatio = float64(diff.Mapped.OutputChars) / float64(diff.Original.OutputChars)
+	}
+	diff.LatencyDeltaMs = diff.Mapped.LatencyMs - diff.Original.LatencyMs
+	diff.InputTokensDelta = diff.Mapped.InputTokens - diff.Original.InputTokens
+	diff.OutputTokensDelta = diff.Mapped.OutputTokens - diff.Original.OutputTokens
+	diff.ToolCallsMatch = strings.Join(diff.Original.ToolCalls, "\x00") == strings.Join(diff.Mapped.ToolCalls, "\x00")
+	return diff
+}
+
+func replayMetrics(ctx context.Context, entry Entry, handlerType, model string, execute ExecuteFunc) ResponseMetrics {
+	metrics := ResponseMetrics{Model: model, ToolCalls: []string{}}
+	body, err := replayBody(entry.Request, handlerType, model)
+	if err != nil {
+		metrics.Error = err.Error()
+		return metrics
+	}
+	started := time.Now()
+	resp, err := execute(ctx, handlerType, model, body)
+	metrics.LatencyMs = time.Since(started).Milliseconds()
+	if err != nil {
+		metrics.Error = err.Error()
+		return metrics
+	}
+	raw := string(resp)
+	metrics.OutputChars = len([]rune(extractResponse(raw, false)))
+	metrics.ToolCalls = responseToolCalls(raw)
+	metrics.InputTokens, metrics.OutputTokens, metrics.TotalTokens = responseTokens(raw)
+	return metrics
+}
+
+// replayHandlerType maps the path of a recorded exchange to the handler format it used.
+func replayHandlerType(path string) string {
+	switch {
+	case strings.HasSuffix(path, "/chat/completions"):
+		return constant.OpenAI
+	case strings.HasSuffix(path, "/messages"):
+		return constant.Claude
+	case strings.HasSuffix(path, "/responses"):
+		return constant.OpenaiResponse
+	case strings.Contains(path, "/models/") && (strings.HasSuffix(path, ":generateContent") || strings.HasSuffix(path, ":streamGenerateContent")):
+		return constant.Gemini
+	}
+	return ""
+}
+
+// replayBody prepares a recorded request for a non-streaming replay against model.
+func replayBody(request, handlerType, model string) ([]byte, error) {
+	if !gjson.Valid(request) {
+		return nil, errors.New("recorded request is not valid JSON")
+	}
+	body := []byte(request)
+	var err error
+	if handlerType != constant.Gemini {
+		if body, err = sjson.SetBytes(body, "model", model); err != nil {
+			return nil, err
+		}
+	}
+	if gjson.GetBytes(body, "stream").Exists() {
+		if body, err = sjson.DeleteBytes(body, "stream"); err != nil {
+			return nil, err
+		}
+	}
+	if gjson.GetBytes(body, "stream_options").Exists() {
+		if body, err = sjson.DeleteBytes(body, "stream_options"); err != nil {
+			return nil, err
+		}
+	}
+	return body, nil
+}
+
+// responseToolCalls lists the names of the tools a response calls, in order.
+func responseToolCalls(raw string) []string {
+	root := gjson.Parse(raw)
+	calls := []string{}
+	root.Get("choices.0.message.tool_calls").ForEach(func(_, tc gjson.Result) bool {
+		calls = append(calls, tc.Get("function.name").String())
+		return true
+	})
+	root.Get("content").ForEach(func(_, block gjson.Result) bool {
+		if block.Get("type").String() == "tool_use" {
+			calls = append(calls, block.Get("name").String())
+		}
+		return true
+	})
+	root.Get("output").ForEach(func(_, item gjson.Result) bool {
+		if item.Get("type").String() == "function_call" {
+			calls = append(calls, item.Get("name").String())
+		}
+		return true
+	})
+	root.Get("candidates.0.content.parts").ForEach(func(_, part gjson.Result) bool {
+		if name := part.Get("functionCall.name"); name.Exists() {
+			calls = append(calls, name.String())
+		}
+		return true
+	})
+	return calls
+}
+
+// responseTokens reads the input, output and total token counts of a response.
+func responseTokens(raw string) (int64, int64, int64) {
+	root := gjson.Parse(raw)
+	var input, output, total int64
+	if usage := root.Get("usageMetadata"); usage.Exists() {
+		input = usage.Get("promptTokenCount").Int()
+		output = usage.Get("candidatesTokenCount").Int() + usage.Get("thoughtsTokenCount").Int()
+		total = usage.Get("totalTokenCount").Int()
+	} else if usage = root.Get("usage"); usage.Exists() {
+		input = usage.Get("prompt_tokens").Int() + usage.Get("input_tokens").Int()
+		output = usage.Get("completion_tokens").Int() + usage.Get("output_tokens").Int()
+		total = usage.Get("total_tokens").Int()
+	}
+	if total == 0 {
+		total = input + output
+	}
+	return input, output, total
+}
+
+func summarizeDiffs(diffs []EntryDiff) DiffSummary {
+	var summary DiffSummary
+	var ratioSum float64
+	ratios, agreements := 0, 0
+	for _, diff := range diffs {
+		if diff.Skipped != "" {
+			summary.Skipped++
+			continue
+		}
+		if diff.Original.Error != "" {
+			summary.OriginalErrors++
+		}
+		if diff.Mapped.Error != "" {
+			summary.MappedErrors++
+		}
+		if diff.Original.Error != "" || diff.Mapped.Error != "" {
+			continue
+		}
+		summary.Compared++
+		if diff.ToolCallsMatch {
+			agreements++
+		}
+		if diff.Original.OutputChars > 0 {
+			ratioSum += diff.LengthRatio
+			ratios++
+		}
+		summary.OriginalMeanLatency += diff.Original.LatencyMs
+		summary.MappedMeanLatency += diff.Mapped.LatencyMs
+		summary.OriginalTotalTokens += diff.Original.TotalTokens
+		summary.MappedTotalTokens += diff.Mapped.TotalTokens
+	}
+	if summary.Compared > 0 {
+		summary.ToolCallAgreement = float64(agreements) / float64(summary.Compared)
+		summary.OriginalMeanLatency /= int64(summary.Compared)
+		summary.MappedMeanLatency /= int64(summary.Compared)
+	}
+	if ratios > 0 {
+		summary.MeanLengthRatio = ratioSum / float64(ratios)
+	}
+	return summary
+}
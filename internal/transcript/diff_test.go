@@ -0,0 +1,136 @@
+package transcript
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/tidwall/gjson"
+)
+
+func TestDiffEntry_ComparesReplays(t *testing.T) {
+	entry := Entry{
+		ID:         "e1",
+		Path:       "/v1/chat/completions",
+		Model:      "gpt-5",
+		StatusCode: 200,
+		Request:    `{"model":"gpt-5","stream":true,"stream_options":{"include_usage":true},"messages":[{"role":"user","content":"hi"}]}`,
+	}
+	execute := func(_ context.Context, handlerType, model string, body []byte) ([]byte, error) {
+		if handlerType != "openai" || gjson.GetBytes(body, "model").String() != model || gjson.GetBytes(body, "stream").Exists() {
+			t.Fatalf("unexpected replay %s %s %s", handlerType, model, body)
+		}
+		if model == "gpt-5" {
+			return []byte(`{"choices":[{"message":{"content":"hello there","tool_calls":[{"function":{"name":"search"}}]}}],"usage":{"prompt_tokens":10,"completion_tokens":4,"total_tokens":14}}`), nil
+		}
+		return []byte(`{"choices":[{"message":{"content":"hello there, friend!"}}],"usage":{"prompt_tokens":12,"completion_tokens":9,"total_tokens":21}}`), nil
+	}
+
+	diff := DiffEntry(context.Background(), entry, "", "glm-4.6", execute)
+	if diff.Skipped != "" || diff.Original.Error != "" || diff.Mapped.Error != "" {
+		t.Fatalf("diff = %+v", diff)
+	}
+	if diff.Original.Model != "gpt-5" || diff.Original.OutputChars != 11 || diff.Mapped.OutputChars != 20 {
+		t.Fatalf("metrics = %+v / %+v", diff.Original, diff.Mapped)
+	}
+	if diff.ToolCallsMatch || len(diff.Original.ToolCalls) != 1 || len(diff.Mapped.ToolCalls) != 0 {
+		t.Fatalf("tool calls = %v / %v", diff.Original.ToolCalls, diff.Mapped.ToolCalls)
+	}
+	if diff.InputTokensDelta != 2 || diff.OutputTokensDelta != 5 || diff.Mapped.TotalTokens != 21 {
+		t.Fatalf("token deltas = %d / %d", diff.InputTokensDelta, diff.OutputTokensDelta)
+	}
+	if diff.LengthRatio < 1.8 || diff.LengthRatio > 1.82 {
+		t.Fatalf("length ratio = %f", diff.LengthRatio)
+	}
+}
+
+func TestDiffEntry_ClaudeAndGeminiMetrics(t *testing.T) {
+	claude := `{"content":[{"type":"text","text":"ok"},{"type":"tool_use","name":"bash"}],"usage":{"input_tokens":5,"output_tokens":3}}`
+	if calls := responseToolCalls(claude); len(calls) != 1 || calls[0] != "bash" {
+		t.Fatalf("claude tool calls = %v", calls)
+	}
+	if in, out, total := responseTokens(claude); in != 5 || out != 3 || total != 8 {
+		t.Fatalf("claude tokens = %d %d %d", in, out, total)
+	}
+	gemini := `{"candidates":[{"content":{"parts":[{"functionCall":{"name":"ls"}}]}}],"usageMetadata":{"promptTokenCount":7,"candidatesTokenCount":2,"totalTokenCount":9}}`
+	if calls := responseToolCalls(gemini); len(calls) != 1 || calls[0] != "ls" {
+		t.Fatalf("gemini tool calls = %v", calls)
+	}
+	if got := replayHandlerType("/v1beta/models/gemini-2.5-pro:streamGenerateContent"); got != "gemini" {
+		t.Fatalf("gemini handler type = %q", got)
+	}
+	body, err := replayBody(`{"contents":[]}`, "gemini", "gemini-2.5-flash")
+	if err != nil || gjson.GetBytes(body, "model").Exists() {
+		t.Fatalf("gemini replay body = %s, %v", body, err)
+	}
+}
+
+func TestDiffJobs_RunsInBackground(t *testing.T) {
+	now := time.Unix(1000, 0)
+	entries := []Entry{
+		{ID: "a", Path: "/v1/messages", Model: "claude-sonnet-4", StatusCode: 200, RequestedAt: now, Request: `{"model":"claude-sonnet-4","messages":[]}`},
+		{ID: "b", Path: "/v1/messages", Model: "claude-sonnet-4", StatusCode: 500, RequestedAt: now.Add(time.Second), Request: `{}`},
+		{ID: "c", Path: "/v1/completions", Model: "gpt-3.5", StatusCode: 200, RequestedAt: now.Add(2 * time.Second), Request: `{}`},
+	}
+	execute := func(_ context.Context, _, model string, _ []byte) ([]byte, error) {
+		if model == "broken" {
+			return nil, errors.New("status 502")
+		}
+		return []byte(`{"content":[{"type":"text","text":"done"}],"usage":{"input_tokens":1,"output_tokens":1}}`), nil
+	}
+
+	jobs := NewDiffJobs()
+	if _, err := jobs.Start(DiffRequest{SessionID: "s"}, entries, execute); err == nil {
+		t.Fatal("a missing mapped model should be rejected")
+	}
+	if _, err := jobs.Start(DiffRequest{SessionID: "s", Mapped: "m", EntryIDs: []string{"zzz"}}, entries, execute); err == nil {
+		t.Fatal("unknown entry IDs should be rejected")
+	}
+
+	started, err := jobs.Start(DiffRequest{SessionID: "s", Mapped: "glm-4.6"}, entries, execute)
+	if err != nil || started.Status != DiffStatusRunning {
+		t.Fatalf("start = %+v, %v", started, err)
+	}
+	report := waitForDiff(t, jobs, started.ID)
+	if report.Status != DiffStatusDone || len(report.Entries) != 2 {
+		t.Fatalf("report = %+v", report)
+	}
+	if report.Entries[0].EntryID != "a" || report.Entries[1].Skipped == "" {
+		t.Fatalf("entries = %+v", report.Entries)
+	}
+	if s := report.Summary; s.Compared != 1 || s.Skipped != 1 || s.ToolCallAgreement != 1 || s.MeanLengthRatio != 1 {
+		t.Fatalf("summary = %+v", s)
+	}
+
+	failed, err := jobs.Start(DiffRequest{SessionID: "s", Mapped: "broken", EntryIDs: []string{"a"}}, entries, execute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report = waitForDiff(t, jobs, failed.ID); report.Status != DiffStatusFailed || report.Summary.MappedErrors != 1 {
+		t.Fatalf("failed report = %+v", report)
+	}
+	if list := jobs.List(); len(list) != 2 || list[0].ID != failed.ID || list[0].Entries != nil {
+		t.Fatalf("list = %+v", list)
+	}
+	if _, err = jobs.Get("missing"); !errors.Is(err, ErrDiffJobNotFound) {
+		t.Fatalf("get missing = %v", err)
+	}
+}
+
+func waitForDiff(t *testing.T, jobs *DiffJobs, id string) DiffReport {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		report, err := jobs.Get(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if report.Status != DiffStatusRunning {
+			return report
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatal("diff job did not finish")
+	return DiffReport{}
+}
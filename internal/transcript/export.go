@@ -0,0 +1,204 @@
+package transcript
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/tidwall/gjson"
+)
+
+// WriteJSONL writes entries as newline-delimited JSON.
+func WriteJSONL(w io.Writer, entries []Entry) error {
+	enc := json.NewEncoder(w)
+	for i := range entries {
+		if err := enc.Encode(entries[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteMarkdown renders entries as a human-readable markdown document.
+// Prompt and response text is extracted from the common OpenAI, Claude and Gemini
+// payload shapes; bodies that cannot be interpreted are included verbatim.
+func WriteMarkdown(w io.Writer, sessionID string, entries []Entry) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Transcript %s\n\n", sessionID)
+	for i := range entries {
+		e := entries[i]
+		fmt.Fprintf(&b, "## %s %s\n\n", e.RequestedAt.UTC().Format(time.RFC3339), e.Path)
+		if e.Model != "" {
+			fmt.Fprintf(&b, "- Model: `%s`\n", e.Model)
+		}
+		fmt.Fprintf(&b, "- Status: %d\n", e.StatusCode)
+		fmt.Fprintf(&b, "- Duration: %dms\n", e.DurationMs)
+		if e.Truncated {
+			b.WriteString("- Truncated: yes\n")
+		}
+		if e.Incomplete {
+			b.WriteString("- Incomplete: yes (stream interrupted)\n")
+		}
+		b.WriteString("\n")
+
+		prompts := extractPrompt(e.Request)
+		if len(prompts) == 0 && strings.TrimSpace(e.Request) != "" {
+			prompts = []message{{Role: "request", Text: e.Request}}
+		}
+		for _, m := range prompts {
+			fmt.Fprintf(&b, "### %s\n\n%s\n\n", m.Role, m.Text)
+		}
+
+		reply := extractResponse(e.Response, e.Streaming)
+		if reply == "" {
+			reply = e.Response
+		}
+		if strings.TrimSpace(reply) != "" {
+			fmt.Fprintf(&b, "### assistant\n\n%s\n\n", reply)
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+type message struct {
+	Role string
+	Text string
+}
+
+func extractPrompt(raw string) []message {
+	if !gjson.Valid(raw) {
+		return nil
+	}
+	root := gjson.Parse(raw)
+	var out []message
+	if system := root.Get("system"); system.Exists() {
+		if text := contentText(system); text != "" {
+			out = append(out, message{Role: "system", Text: text})
+		}
+	}
+	if instructions := root.Get("instructions"); instructions.Type == gjson.String && instructions.String() != "" {
+		out = append(out, message{Role: "system", Text: instructions.String()})
+	}
+	switch {
+	case root.Get("messages").IsArray():
+		root.Get("messages").ForEach(func(_, msg gjson.Result) bool {
+			out = appendMessage(out, msg.Get("role").String(), contentText(msg.Get("content")))
+			return true
+		})
+	case root.Get("contents").IsArray():
+		root.Get("contents").ForEach(func(_, msg gjson.Result) bool {
+			out = appendMessage(out, msg.Get("role").String(), contentText(msg.Get("parts")))
+			return true
+		})
+	case root.Get("input").Exists():
+		input := root.Get("input")
+		if input.Type == gjson.String {
+			out = appendMessage(out, "user", input.String())
+			break
+		}
+		input.ForEach(func(_, item gjson.Result) bool {
+			out = appendMessage(out, item.Get("role").String(), contentText(item.Get("content")))
+			return true
+		})
+	case root.Get("prompt").Exists():
+		out = appendMessage(out, "user", contentText(root.Get("prompt")))
+	}
+	return out
+}
+
+func appendMessage(out []message, role, text string) []message {
+	if text == "" {
+		return out
+	}
+	if role == "" {
+		role = "user"
+	}
+	return append(out, message{Role: role, Text: text})
+}
+
+// contentText flattens string, array-of-parts, and single-part content values.
+func contentText(v gjson.Result) string {
+	switch {
+	case v.Type == gjson.String:
+		return v.String()
+	case v.IsArray():
+		var parts []string
+		v.ForEach(func(_, part gjson.Result) bool {
+			if part.Type == gjson.String {
+				parts = append(parts, part.String())
+				return true
+			}
+			if text := part.Get("text"); text.Exists() {
+				parts = append(parts, text.String())
+			}
+			return true
+		})
+		return strings.Join(parts, "\n")
+	case v.IsObject():
+		return v.Get("text").String()
+	}
+	return ""
+}
+
+func extractResponse(raw string, streaming bool) string {
+	if streaming {
+		return extractStreamText(raw)
+	}
+	if !gjson.Valid(raw) {
+		return ""
+	}
+	root := gjson.Parse(raw)
+	if content := root.Get("choices.0.message.content"); content.Exists() {
+		return contentText(content)
+	}
+	if text := root.Get("choices.0.text"); text.Exists() {
+		return text.String()
+	}
+	if content := root.Get("content"); content.IsArray() {
+		return contentText(content)
+	}
+	if parts := root.Get("candidates.0.content.parts"); parts.Exists() {
+		return contentText(parts)
+	}
+	if output := root.Get("output"); output.IsArray() {
+		var texts []string
+		output.ForEach(func(_, item gjson.Result) bool {
+			if text := contentText(item.Get("content")); text != "" {
+				texts = append(texts, text)
+			}
+			return true
+		})
+		return strings.Join(texts, "\n")
+	}
+	return ""
+}
+
+// extractStreamText concatenates text deltas from an SSE body.
+func extractStreamText(raw string) string {
+	var b strings.Builder
+	for _, line := range strings.Split(raw, "\n") {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+		payload := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		if payload == "" || payload == "[DONE]" || !gjson.Valid(payload) {
+			continue
+		}
+		chunk := gjson.Parse(payload)
+		switch {
+		case chunk.Get("choices.0.delta.content").Exists():
+			b.WriteString(chunk.Get("choices.0.delta.content").String())
+		case chunk.Get("delta.text").Exists():
+			b.WriteString(chunk.Get("delta.text").String())
+		case chunk.Get("type").String() == "response.output_text.delta":
+			b.WriteString(chunk.Get("delta").String())
+		case chunk.Get("candidates.0.content.parts").Exists():
+			b.WriteString(contentText(chunk.Get("candidates.0.content.parts")))
+		}
+	}
+	return b.String()
+}
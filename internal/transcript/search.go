@@ -0,0 +1,145 @@
+package transcript
+
+import (
+	"strings"
+	"time"
+)
+
+const (
+	defaultSearchLimit = 50
+	snippetRadius      = 80
+)
+
+// Query filters a transcript search. Zero values disable the corresponding filter.
+type Query struct {
+	// Text is matched case-insensitively against request and response bodies.
+	Text string
+	// Model restricts matches to exchanges whose model contains this value (case-insensitive).
+	Model string
+	// SessionID restricts the search to a single session.
+	SessionID string
+	// Project restricts the search to sessions recorded for the named project.
+	Project string
+	// Since and Until bound the exchange timestamp.
+	Since time.Time
+	Until time.Time
+	// FailedOnly keeps only exchanges with a non-2xx status code.
+	FailedOnly bool
+	// Limit caps the number of returned matches (default 50).
+	Limit int
+}
+
+// Match is a single search hit.
+type Match struct {
+	SessionID   string    `json:"session_id"`
+	EntryID     string    `json:"entry_id"`
+	Model       string    `json:"model,omitempty"`
+	Path        string    `json:"path"`
+	StatusCode  int       `json:"status_code"`
+	RequestedAt time.Time `json:"requested_at"`
+	Field       string    `json:"field,omitempty"`
+	Snippet     string    `json:"snippet,omitempty"`
+}
+
+// Search scans stored sessions (most recent first) and returns exchanges matching the query.
+// It reports whether more matches exist beyond the limit.
+func (s *Store) Search(q Query) ([]Match, bool, error) {
+	if s == nil {
+		return nil, false, nil
+	}
+	limit := q.Limit
+	if limit <= 0 {
+		limit = defaultSearchLimit
+	}
+	needle := strings.ToLower(strings.TrimSpace(q.Text))
+	model := strings.ToLower(strings.TrimSpace(q.Model))
+
+	var sessions []SessionInfo
+	if id := NormalizeSessionID(q.SessionID); id != "" {
+		sessions = []SessionInfo{{ID: id}}
+	} else {
+		var err error
+		if sessions, err = s.Sessions(); err != nil {
+			return nil, false, err
+		}
+	}
+
+	matches := make([]Match, 0)
+	for _, session := range sessions {
+		if q.Project != "" && !InProject(session.ID, q.Project) {
+			continue
+		}
+		if !q.Since.IsZero() && !session.LastActivity.IsZero() && session.LastActivity.Before(q.Since) {
+			continue
+		}
+		entries, err := s.Entries(session.ID)
+		if err != nil {
+			if err == ErrSessionNotFound {
+				continue
+			}
+			return matches, false, err
+		}
+		for i := len(entries) - 1; i >= 0; i-- {
+			e := entries[i]
+			if !q.Since.IsZero() && e.RequestedAt.Before(q.Since) {
+				continue
+			}
+			if !q.Until.IsZero() && e.RequestedAt.After(q.Until) {
+				continue
+			}
+			if model != "" && !strings.Contains(strings.ToLower(e.Model), model) {
+				continue
+			}
+			if q.FailedOnly && e.StatusCode >= 200 && e.StatusCode < 300 {
+				continue
+			}
+			field, snippet := "", ""
+			if needle != "" {
+				var ok bool
+				if field, snippet, ok = findText(e, needle); !ok {
+					continue
+				}
+			}
+			if len(matches) >= limit {
+				return matches, true, nil
+			}
+			matches = append(matches, Match{
+				SessionID:   e.SessionID,
+				EntryID:     e.ID,
+				Model:       e.Model,
+				Path:        e.Path,
+				StatusCode:  e.StatusCode,
+				RequestedAt: e.RequestedAt,
+				Field:       field,
+				Snippet:     snippet,
+			})
+		}
+	}
+	return matches, false, nil
+}
+
+func findText(e Entry, needle string) (string, string, bool) {
+	for _, candidate := range []struct {
+		field string
+		text  string
+	}{{"request", e.Request}, {"response", e.Response}} {
+		lower := strings.ToLower(candidate.text)
+		idx := strings.Index(lower, needle)
+		if idx < 0 {
+			continue
+		}
+		start := idx - snippetRadius
+		if start < 0 {
+			start = 0
+		}
+		end := idx + len(needle) + snippetRadius
+		if end > len(candidate.text) {
+			end = len(candidate.text)
+		}
+		if start > end {
+			start = end
+		}
+		return candidate.field, strings.ToValidUTF8(candidate.text[start:end], ""), true
+	}
+	return "", "", false
+}
@@ -0,0 +1,406 @@
+// Package transcript implements the opt-in conversation transcript store.
+// Each proxied exchange is appended to a per-session log of the configured storage
+// backend (a JSONL file per session by default) so that operators can review, export,
+// and prune what clients sent to and received from upstream models.
+package transcript
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/storage"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	pruneInterval = 10 * time.Minute
+	maxSessionLen = 128
+)
+
+// ErrSessionNotFound is returned when the requested session has no stored transcript.
+var ErrSessionNotFound = errors.New("transcript session not found")
+
+// Entry is a single request/response exchange persisted to the store.
+type Entry struct {
+	ID          string    `json:"id"`
+	SessionID   string    `json:"session_id"`
+	Project     string    `json:"project,omitempty"`
+	APIKey      string    `json:"api_key,omitempty"`
+	Method      string    `json:"method"`
+	Path        string    `json:"path"`
+	Model       string    `json:"model,omitempty"`
+	StatusCode  int       `json:"status_code"`
+	Streaming   bool      `json:"streaming,omitempty"`
+	RequestedAt time.Time `json:"requested_at"`
+	DurationMs  int64     `json:"duration_ms"`
+	Request     string    `json:"request"`
+	Response    string    `json:"response"`
+	Truncated   bool      `json:"truncated,omitempty"`
+	// Incomplete marks streams recovered from their tee file after the proxy stopped
+	// before they completed.
+	Incomplete bool `json:"incomplete,omitempty"`
+}
+
+// SessionInfo summarises a stored session.
+type SessionInfo struct {
+	ID           string    `json:"id"`
+	Size         int64     `json:"size"`
+	LastActivity time.Time `json:"last_activity"`
+}
+
+// Store persists transcripts as one storage log per session.
+type Store struct {
+	mu            sync.Mutex
+	enabled       bool
+	dir           string
+	backend       storage.Backend
+	retention     time.Duration
+	maxSessions   int
+	maxBodyBytes  int
+	sessionHeader string
+	streamTee     bool
+	tees          map[string]struct{}
+	lastPrune     time.Time
+}
+
+var defaultStore = &Store{}
+
+// GetStore returns the process-wide transcript store.
+func GetStore() *Store { return defaultStore }
+
+// Configure applies transcript settings to the store. fallbackDir is used when cfg.Dir is empty.
+func (s *Store) Configure(cfg config.TranscriptConfig, fallbackDir string) {
+	if s == nil {
+		return
+	}
+	dir := strings.TrimSpace(cfg.Dir)
+	if dir == "" {
+		dir = fallbackDir
+	}
+	if dir != "" && !filepath.IsAbs(dir) {
+		if abs, err := filepath.Abs(dir); err == nil {
+			dir = abs
+		}
+	}
+	var backend storage.Backend
+	if dir != "" {
+		var err error
+		if backend, err = storage.Open(storage.Transcripts, dir); err != nil {
+			log.Errorf("transcript: storage backend unavailable, capture disabled: %v", err)
+		}
+	}
+	s.mu.Lock()
+	if s.backend != nil {
+		_ = s.backend.Close()
+	}
+	s.backend = backend
+	s.enabled = cfg.Enable && backend != nil
+	s.dir = dir
+	s.retention = time.Duration(cfg.RetentionDays) * 24 * time.Hour
+	s.maxSessions = cfg.MaxSessions
+	s.maxBodyBytes = cfg.MaxBodyBytes
+	s.sessionHeader = cfg.SessionHeader
+	s.streamTee = cfg.StreamTee
+	s.lastPrune = time.Time{}
+	if s.enabled {
+		s.recoverTeesLocked()
+	}
+	s.mu.Unlock()
+}
+
+// Enabled reports whether transcript capture is active.
+func (s *Store) Enabled() bool {
+	if s == nil {
+		return false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.enabled
+}
+
+// Dir returns the directory transcripts are written to by the file backend; stream tees
+// are kept there whatever the backend.
+func (s *Store) Dir() string {
+	if s == nil {
+		return ""
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.dir
+}
+
+// MaxBodyBytes returns the per-body capture limit.
+func (s *Store) MaxBodyBytes() int {
+	if s == nil {
+		return 0
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.maxBodyBytes <= 0 {
+		return config.DefaultTranscriptMaxBodyBytes
+	}
+	return s.maxBodyBytes
+}
+
+// SessionHeader returns the request header used to group exchanges into sessions.
+func (s *Store) SessionHeader() string {
+	if s == nil {
+		return ""
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.sessionHeader == "" {
+		return "X-Session-Id"
+	}
+	return s.sessionHeader
+}
+
+// SessionIDForKey derives a stable session identifier for requests without an explicit session.
+func SessionIDForKey(apiKey string) string {
+	apiKey = strings.TrimSpace(apiKey)
+	if apiKey == "" {
+		return "anonymous"
+	}
+	sum := sha256.Sum256([]byte(apiKey))
+	return "key-" + hex.EncodeToString(sum[:6])
+}
+
+// NormalizeSessionID strips characters that are unsafe in file names.
+// It returns an empty string when nothing usable remains.
+func NormalizeSessionID(id string) string {
+	id = strings.TrimSpace(id)
+	var b strings.Builder
+	for _, r := range id {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_', r == '.':
+			b.WriteRune(r)
+		default:
+			b.WriteByte('_')
+		}
+		if b.Len() >= maxSessionLen {
+			break
+		}
+	}
+	return strings.Trim(b.String(), "._")
+}
+
+// projectSeparator joins a project name and a client session ID in scoped session IDs.
+const projectSeparator = "--"
+
+// ScopeSessionID namespaces sessionID under project so sessions of different projects never
+// share a transcript file, even when clients reuse session header values.
+func ScopeSessionID(project, sessionID string) string {
+	project = NormalizeSessionID(project)
+	if project == "" {
+		return sessionID
+	}
+	return project + projectSeparator + sessionID
+}
+
+// InProject reports whether the (normalized) session ID belongs to project.
+func InProject(sessionID, project string) bool {
+	project = NormalizeSessionID(project)
+	return project != "" && strings.HasPrefix(sessionID, project+projectSeparator)
+}
+
+// Append writes the entry to its session file and opportunistically applies retention.
+func (s *Store) Append(entry Entry) error {
+	if s == nil {
+		return nil
+	}
+	entry = normalizeEntry(entry)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.enabled {
+		return nil
+	}
+	return s.appendLocked(entry)
+}
+
+// normalizeEntry fills the session, time and ID of entry.
+func normalizeEntry(entry Entry) Entry {
+	entry.SessionID = NormalizeSessionID(entry.SessionID)
+	if entry.SessionID == "" {
+		entry.SessionID = "anonymous"
+	}
+	if entry.RequestedAt.IsZero() {
+		entry.RequestedAt = time.Now()
+	}
+	if entry.ID == "" {
+		entry.ID = fmt.Sprintf("%d", entry.RequestedAt.UnixNano())
+	}
+	return entry
+}
+
+func (s *Store) appendLocked(entry Entry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("transcript: marshal entry: %w", err)
+	}
+	if s.backend == nil {
+		return fmt.Errorf("transcript: no storage backend")
+	}
+	if err = s.backend.Append(entry.SessionID, line); err != nil {
+		return fmt.Errorf("transcript: write entry: %w", err)
+	}
+
+	now := time.Now()
+	if now.Sub(s.lastPrune) >= pruneInterval {
+		// The sweep lists and deletes sessions, which may hit a database; it runs without
+		// the store lock so appends do not wait for it.
+		s.lastPrune = now
+		backend, retention, maxSessions := s.backend, s.retention, s.maxSessions
+		go func() {
+			if _, errPrune := pruneSessions(backend, retention, maxSessions, now); errPrune != nil {
+				log.Warnf("transcript: retention sweep failed: %v", errPrune)
+			}
+		}()
+	}
+	return nil
+}
+
+// Sessions lists stored sessions ordered by most recent activity.
+func (s *Store) Sessions() ([]SessionInfo, error) {
+	if s == nil {
+		return nil, nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.sessionsLocked()
+}
+
+// Entries loads every stored exchange for a session in the order it was recorded.
+func (s *Store) Entries(sessionID string) ([]Entry, error) {
+	if s == nil {
+		return nil, ErrSessionNotFound
+	}
+	sessionID = NormalizeSessionID(sessionID)
+	if sessionID == "" {
+		return nil, ErrSessionNotFound
+	}
+	s.mu.Lock()
+	backend := s.backend
+	s.mu.Unlock()
+	if backend == nil {
+		return nil, ErrSessionNotFound
+	}
+	records, err := backend.Read(sessionID)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return nil, ErrSessionNotFound
+		}
+		return nil, err
+	}
+	return decodeEntries(records), nil
+}
+
+// Delete removes a stored session.
+func (s *Store) Delete(sessionID string) error {
+	if s == nil {
+		return ErrSessionNotFound
+	}
+	sessionID = NormalizeSessionID(sessionID)
+	if sessionID == "" {
+		return ErrSessionNotFound
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.backend == nil {
+		return ErrSessionNotFound
+	}
+	if err := s.backend.Delete(sessionID); err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return ErrSessionNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+// Prune applies the retention policy immediately and returns the number of removed sessions.
+func (s *Store) Prune() (int, error) {
+	if s == nil {
+		return 0, nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	s.lastPrune = now
+	return s.pruneLocked(now)
+}
+
+func (s *Store) pruneLocked(now time.Time) (int, error) {
+	return pruneSessions(s.backend, s.retention, s.maxSessions, now)
+}
+
+// pruneSessions removes the sessions of backend idle past retention or beyond the
+// maxSessions most recently active ones.
+func pruneSessions(backend storage.Backend, retention time.Duration, maxSessions int, now time.Time) (int, error) {
+	sessions, err := listSessions(backend)
+	if err != nil {
+		return 0, err
+	}
+	removed := 0
+	kept := 0
+	for _, session := range sessions {
+		expired := retention > 0 && now.Sub(session.LastActivity) > retention
+		overflow := maxSessions > 0 && kept >= maxSessions
+		if !expired && !overflow {
+			kept++
+			continue
+		}
+		if errRemove := backend.Delete(session.ID); errRemove != nil && !errors.Is(errRemove, storage.ErrNotFound) {
+			return removed, errRemove
+		}
+		removed++
+	}
+	if removed > 0 {
+		log.Debugf("transcript: removed %d session(s) by retention policy", removed)
+	}
+	return removed, nil
+}
+
+func (s *Store) sessionsLocked() ([]SessionInfo, error) {
+	return listSessions(s.backend)
+}
+
+// listSessions lists the sessions of backend ordered by most recent activity.
+func listSessions(backend storage.Backend) ([]SessionInfo, error) {
+	if backend == nil {
+		return nil, nil
+	}
+	keys, err := backend.Keys()
+	if err != nil {
+		return nil, err
+	}
+	sessions := make([]SessionInfo, 0, len(keys))
+	for _, key := range keys {
+		sessions = append(sessions, SessionInfo{ID: key.Key, Size: key.Size, LastActivity: key.LastActivity})
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].LastActivity.After(sessions[j].LastActivity)
+	})
+	return sessions, nil
+}
+
+// decodeEntries parses stored records, skipping the ones that are not entries.
+func decodeEntries(records [][]byte) []Entry {
+	entries := make([]Entry, 0, len(records))
+	for _, record := range records {
+		var entry Entry
+		if errUnmarshal := json.Unmarshal(record, &entry); errUnmarshal != nil {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
@@ -0,0 +1,197 @@
+package transcript
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+func newTestStore(t *testing.T, cfg config.TranscriptConfig) *Store {
+	t.Helper()
+	cfg.Enable = true
+	s := &Store{}
+	s.Configure(cfg, t.TempDir())
+	return s
+}
+
+func TestStore_AppendAndEntries(t *testing.T) {
+	s := newTestStore(t, config.TranscriptConfig{})
+
+	for i := 0; i < 2; i++ {
+		if err := s.Append(Entry{SessionID: "sess/1", Path: "/v1/chat/completions", Request: `{"model":"gpt-5"}`}); err != nil {
+			t.Fatalf("append: %v", err)
+		}
+	}
+
+	entries, err := s.Entries("sess/1")
+	if err != nil {
+		t.Fatalf("entries: %v", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 entries, got %d", len(entries))
+	}
+	if entries[0].SessionID != "sess_1" {
+		t.Fatalf("expected normalized session id sess_1, got %q", entries[0].SessionID)
+	}
+
+	if _, err = s.Entries("missing"); err != ErrSessionNotFound {
+		t.Fatalf("expected ErrSessionNotFound, got %v", err)
+	}
+}
+
+func TestStore_PruneRetentionAndMaxSessions(t *testing.T) {
+	s := newTestStore(t, config.TranscriptConfig{RetentionDays: 1, MaxSessions: 1})
+
+	for _, id := range []string{"old", "newer", "newest"} {
+		if err := s.Append(Entry{SessionID: id}); err != nil {
+			t.Fatalf("append %s: %v", id, err)
+		}
+	}
+	past := time.Now().Add(-48 * time.Hour)
+	if err := os.Chtimes(filepath.Join(s.Dir(), "old.jsonl"), past, past); err != nil {
+		t.Fatalf("chtimes: %v", err)
+	}
+	recent := time.Now().Add(-time.Minute)
+	if err := os.Chtimes(filepath.Join(s.Dir(), "newer.jsonl"), recent, recent); err != nil {
+		t.Fatalf("chtimes: %v", err)
+	}
+
+	removed, err := s.Prune()
+	if err != nil {
+		t.Fatalf("prune: %v", err)
+	}
+	if removed != 2 {
+		t.Fatalf("expected 2 removed sessions, got %d", removed)
+	}
+	sessions, _ := s.Sessions()
+	if len(sessions) != 1 || sessions[0].ID != "newest" {
+		t.Fatalf("expected only newest session to remain, got %+v", sessions)
+	}
+}
+
+func TestStore_DisabledDoesNotWrite(t *testing.T) {
+	s := &Store{}
+	dir := t.TempDir()
+	s.Configure(config.TranscriptConfig{Enable: false}, dir)
+	if err := s.Append(Entry{SessionID: "a"}); err != nil {
+		t.Fatalf("append: %v", err)
+	}
+	if files, _ := os.ReadDir(dir); len(files) != 0 {
+		t.Fatalf("expected no files when disabled, got %d", len(files))
+	}
+}
+
+func TestWriteMarkdown_ExtractsMessages(t *testing.T) {
+	entries := []Entry{
+		{
+			Path:     "/v1/chat/completions",
+			Model:    "gpt-5",
+			Request:  `{"model":"gpt-5","messages":[{"role":"user","content":"hello there"}]}`,
+			Response: `{"choices":[{"message":{"role":"assistant","content":"general kenobi"}}]}`,
+		},
+		{
+			Path:      "/v1/messages",
+			Streaming: true,
+			Request:   `{"messages":[{"role":"user","content":[{"type":"text","text":"stream please"}]}]}`,
+			Response:  "event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"delta\":{\"type\":\"text_delta\",\"text\":\"chunk-a\"}}\n\ndata: {\"type\":\"content_block_delta\",\"delta\":{\"type\":\"text_delta\",\"text\":\"chunk-b\"}}\n\n",
+		},
+	}
+	var buf bytes.Buffer
+	if err := WriteMarkdown(&buf, "s1", entries); err != nil {
+		t.Fatalf("write markdown: %v", err)
+	}
+	out := buf.String()
+	for _, want := range []string{"# Transcript s1", "hello there", "general kenobi", "stream please", "chunk-achunk-b"} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("markdown output missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestStore_SearchFilters(t *testing.T) {
+	s := newTestStore(t, config.TranscriptConfig{})
+	base := time.Now().Add(-time.Hour)
+	fixtures := []Entry{
+		{SessionID: "a", Model: "gpt-5", StatusCode: 200, RequestedAt: base, Request: `{"messages":"deploy the SECRET token"}`},
+		{SessionID: "a", Model: "claude-sonnet-4", StatusCode: 429, RequestedAt: base.Add(time.Minute), Response: `{"error":"rate limited"}`},
+		{SessionID: "b", Model: "gpt-5", StatusCode: 200, RequestedAt: base.Add(2 * time.Minute), Response: `nothing to see`},
+	}
+	for _, e := range fixtures {
+		if err := s.Append(e); err != nil {
+			t.Fatalf("append: %v", err)
+		}
+	}
+
+	matches, more, err := s.Search(Query{Text: "secret"})
+	if err != nil || more {
+		t.Fatalf("search: err=%v more=%v", err, more)
+	}
+	if len(matches) != 1 || matches[0].SessionID != "a" || matches[0].Field != "request" {
+		t.Fatalf("unexpected text matches: %+v", matches)
+	}
+
+	matches, _, _ = s.Search(Query{Model: "gpt"})
+	if len(matches) != 2 {
+		t.Fatalf("expected 2 gpt matches, got %d", len(matches))
+	}
+
+	matches, _, _ = s.Search(Query{FailedOnly: true})
+	if len(matches) != 1 || matches[0].StatusCode != 429 {
+		t.Fatalf("expected single failed match, got %+v", matches)
+	}
+
+	matches, _, _ = s.Search(Query{Since: base.Add(90 * time.Second)})
+	if len(matches) != 1 || matches[0].SessionID != "b" {
+		t.Fatalf("expected date filter to keep only session b, got %+v", matches)
+	}
+
+	matches, more, _ = s.Search(Query{Limit: 1})
+	if len(matches) != 1 || !more {
+		t.Fatalf("expected limit to truncate results, got %d more=%v", len(matches), more)
+	}
+}
+
+func TestStore_StreamTeeRecoversInterruptedStreams(t *testing.T) {
+	s := newTestStore(t, config.TranscriptConfig{StreamTee: true, MaxBodyBytes: 1024})
+	if !s.StreamTee() {
+		t.Fatal("stream tee should be enabled")
+	}
+
+	done, err := s.StartTee(Entry{SessionID: "sess", Path: "/v1/messages", Streaming: true})
+	if err != nil || done == nil {
+		t.Fatalf("start tee: %v", err)
+	}
+	done.Write([]byte("data: complete\n\n"))
+	done.Close()
+
+	crashed, err := s.StartTee(Entry{SessionID: "sess", Path: "/v1/messages", Request: `{"model":"m"}`, Streaming: true})
+	if err != nil {
+		t.Fatalf("start tee: %v", err)
+	}
+	crashed.Write([]byte("data: partial"))
+
+	// A config reload must leave streams in flight alone.
+	s.Configure(config.TranscriptConfig{Enable: true, StreamTee: true, MaxBodyBytes: 1024}, s.Dir())
+	if _, err = s.Entries("sess"); err != ErrSessionNotFound {
+		t.Fatalf("in-flight stream recovered early: %v", err)
+	}
+
+	// A new store over the same directory finds the tee left behind by the "crash".
+	restarted := &Store{}
+	restarted.Configure(config.TranscriptConfig{Enable: true, MaxBodyBytes: 1024}, s.Dir())
+	entries, err := restarted.Entries("sess")
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("entries = %+v, %v", entries, err)
+	}
+	if !entries[0].Incomplete || entries[0].Response != "data: partial" || entries[0].Request != `{"model":"m"}` {
+		t.Fatalf("recovered entry = %+v", entries[0])
+	}
+	if files, _ := os.ReadDir(filepath.Join(s.Dir(), teeDir)); len(files) != 0 {
+		t.Fatalf("tee files left: %d", len(files))
+	}
+}
@@ -0,0 +1,168 @@
+package transcript
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// teeDir holds the tee files of streams in flight, below the transcript directory.
+	teeDir = "streams"
+	// teeExtension ends the names of tee files.
+	teeExtension = ".partial"
+)
+
+// Tee persists a streamed response while it flows. Its file starts with the exchange as a
+// JSON line, followed by the raw response bytes; when the stream completes the exchange is
+// appended to its session as usual and the file removed. Files left behind by a crash are
+// recovered as incomplete exchanges.
+type Tee struct {
+	store  *Store
+	mu     sync.Mutex
+	file   *os.File
+	path   string
+	failed bool
+}
+
+// StreamTee reports whether streamed responses are teed to disk as they flow.
+func (s *Store) StreamTee() bool {
+	if s == nil {
+		return false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.enabled && s.streamTee
+}
+
+// StartTee creates the tee file of a stream whose exchange is described by entry; the
+// response is left empty and written through the returned Tee.
+func (s *Store) StartTee(entry Entry) (*Tee, error) {
+	if s == nil {
+		return nil, nil
+	}
+	entry = normalizeEntry(entry)
+	entry.Response = ""
+	header, err := json.Marshal(entry)
+	if err != nil {
+		return nil, fmt.Errorf("transcript: marshal stream entry: %w", err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.enabled || !s.streamTee {
+		return nil, nil
+	}
+	dir := filepath.Join(s.dir, teeDir)
+	if err = os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("transcript: create stream directory: %w", err)
+	}
+	f, err := os.CreateTemp(dir, entry.SessionID+"-*"+teeExtension)
+	if err != nil {
+		return nil, fmt.Errorf("transcript: create stream file: %w", err)
+	}
+	if _, err = f.Write(append(header, '\n')); err != nil {
+		_ = f.Close()
+		_ = os.Remove(f.Name())
+		return nil, fmt.Errorf("transcript: write stream entry: %w", err)
+	}
+	if s.tees == nil {
+		s.tees = make(map[string]struct{})
+	}
+	s.tees[f.Name()] = struct{}{}
+	return &Tee{store: s, file: f, path: f.Name()}, nil
+}
+
+// Write appends response bytes. After the first failure the tee stops writing.
+func (t *Tee) Write(data []byte) {
+	if t == nil || len(data) == 0 {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.failed || t.file == nil {
+		return
+	}
+	if _, err := t.file.Write(data); err != nil {
+		t.failed = true
+		log.Warnf("transcript: stream tee write failed: %v", err)
+	}
+}
+
+// Close removes the tee file once the exchange was appended to its session.
+func (t *Tee) Close() {
+	if t == nil {
+		return
+	}
+	t.Detach()
+	if err := os.Remove(t.path); err != nil && !os.IsNotExist(err) {
+		log.Warnf("transcript: remove stream tee: %v", err)
+	}
+}
+
+// Detach closes the tee but keeps its file, so the exchange is recovered as incomplete
+// when the store is next configured.
+func (t *Tee) Detach() {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	if t.file != nil {
+		_ = t.file.Close()
+		t.file = nil
+	}
+	t.mu.Unlock()
+	t.store.mu.Lock()
+	delete(t.store.tees, t.path)
+	t.store.mu.Unlock()
+}
+
+// recoverTeesLocked appends the tee files of streams not in flight, left by a previous
+// run, to their sessions as incomplete exchanges.
+func (s *Store) recoverTeesLocked() {
+	dir := filepath.Join(s.dir, teeDir)
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	limit := s.maxBodyBytes
+	recovered := 0
+	for _, file := range files {
+		path := filepath.Join(dir, file.Name())
+		if _, active := s.tees[path]; active || file.IsDir() || !strings.HasSuffix(file.Name(), teeExtension) {
+			continue
+		}
+		data, errRead := os.ReadFile(path)
+		if errRead != nil {
+			log.Warnf("transcript: read stream tee %s: %v", file.Name(), errRead)
+			continue
+		}
+		header, response, _ := bytes.Cut(data, []byte{'\n'})
+		var entry Entry
+		if errUnmarshal := json.Unmarshal(header, &entry); errUnmarshal != nil {
+			log.Warnf("transcript: dropping malformed stream tee %s", file.Name())
+			_ = os.Remove(path)
+			continue
+		}
+		if limit > 0 && len(response) > limit {
+			response = response[:limit]
+			entry.Truncated = true
+		}
+		entry.Response = string(response)
+		entry.Incomplete = true
+		if errAppend := s.appendLocked(entry); errAppend != nil {
+			log.Warnf("transcript: recover stream tee %s: %v", file.Name(), errAppend)
+			continue
+		}
+		_ = os.Remove(path)
+		recovered++
+	}
+	if recovered > 0 {
+		log.Infof("transcript: recovered %d incomplete stream(s) from a previous run", recovered)
+	}
+}
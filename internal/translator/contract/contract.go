@@ -0,0 +1,363 @@
+// Package contract turns captured provider responses into golden tests of the response
+// translators. A fixture holds one sanitized upstream response, streamed or not, in the
+// provider's format; every client dialect with a translator from that format gets a golden
+// file of the translated output, so a translator change shows up as a golden diff instead
+// of a broken client. The client requests of a fixture likewise get a golden file of the
+// provider request the request translators build from them.
+//
+// Fixtures are ingested with cmd/contract-fixture, which sanitizes the capture, and the
+// golden files are (re)generated with
+//
+//	go test ./internal/translator/contract -update
+package contract
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	_ "github.com/router-for-me/CLIProxyAPI/v6/internal/translator"
+	sdktranslator "github.com/router-for-me/CLIProxyAPI/v6/sdk/translator"
+)
+
+// volatileValue replaces output values that change between runs, such as generated IDs.
+const volatileValue = "<volatile>"
+
+// dialects are the client formats the proxy serves.
+var dialects = []sdktranslator.Format{
+	sdktranslator.FormatOpenAI,
+	sdktranslator.FormatOpenAIResponse,
+	sdktranslator.FormatClaude,
+	sdktranslator.FormatGemini,
+	sdktranslator.FormatGeminiCLI,
+	sdktranslator.FormatCodex,
+	sdktranslator.FormatAntigravity,
+}
+
+// timeKeys name output fields holding the current time.
+var timeKeys = map[string]bool{"created": true, "created_at": true, "createTime": true}
+
+// generatedKeys name request fields generated once per process, such as the Claude
+// metadata user ID, which stay equal between two translations of one run.
+var generatedKeys = map[string]bool{"user_id": true}
+
+// Fixture is one captured upstream exchange.
+type Fixture struct {
+	// Name is the fixture file name without extension.
+	Name string `json:"-"`
+
+	// Provider is the format of the response, e.g. "claude" or "gemini".
+	Provider string `json:"provider"`
+
+	// Model is the model the client asked for.
+	Model string `json:"model"`
+
+	// Request is the upstream request in the provider format.
+	Request json.RawMessage `json:"request,omitempty"`
+
+	// ClientRequests optionally holds the client request per dialect; translators that
+	// echo request fields read them. The upstream request stands in when missing. Each is
+	// also translated into the provider format for a request golden file.
+	ClientRequests map[string]json.RawMessage `json:"client_requests,omitempty"`
+
+	// Response is the body of a non-streaming response.
+	Response json.RawMessage `json:"response,omitempty"`
+
+	// Stream lists the lines of a streamed response as the provider sent them.
+	Stream []string `json:"stream,omitempty"`
+}
+
+// Streaming reports whether the fixture holds a streamed response.
+func (f Fixture) Streaming() bool { return len(f.Stream) > 0 }
+
+// hasResponse reports whether the fixture holds a response, streamed or not. Fixtures of
+// client requests alone only check the request translators.
+func (f Fixture) hasResponse() bool { return len(f.Response) > 0 || f.Streaming() }
+
+// LoadFixtures reads every *.json fixture in dir, ordered by name.
+func LoadFixtures(dir string) ([]Fixture, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+	fixtures := make([]Fixture, 0, len(paths))
+	for _, path := range paths {
+		data, errRead := os.ReadFile(path)
+		if errRead != nil {
+			return nil, errRead
+		}
+		var f Fixture
+		if errRead = json.Unmarshal(data, &f); errRead != nil {
+			return nil, fmt.Errorf("%s: %w", path, errRead)
+		}
+		if f.Provider == "" || (!f.hasResponse() && len(f.ClientRequests) == 0) {
+			return nil, fmt.Errorf("%s: provider and a response, stream or client requests are required", path)
+		}
+		f.Name = strings.TrimSuffix(filepath.Base(path), ".json")
+		fixtures = append(fixtures, f)
+	}
+	return fixtures, nil
+}
+
+// Case is one golden comparison: a fixture's response translated to a dialect, streamed
+// or not, or with Request set, the dialect's client request translated to the provider.
+type Case struct {
+	Dialect sdktranslator.Format
+	Stream  bool
+	Request bool
+}
+
+// File names the golden file of the case.
+func (c Case) File() string {
+	if c.Request {
+		return c.Dialect.String() + ".request.golden"
+	}
+	if c.Stream {
+		return c.Dialect.String() + ".stream.golden"
+	}
+	return c.Dialect.String() + ".golden"
+}
+
+// Dialects lists the client formats with a response translator from provider, including the
+// provider's own format.
+func Dialects(provider string) []sdktranslator.Format {
+	from := sdktranslator.FromString(provider)
+	var out []sdktranslator.Format
+	for _, dialect := range dialects {
+		if dialect == from || sdktranslator.HasResponseTransformer(dialect, from) {
+			out = append(out, dialect)
+		}
+	}
+	return out
+}
+
+// Cases lists the golden comparisons of f. Streams are checked as streams in every
+// dialect and, where the provider's executor derives non-streaming responses from the
+// stream, as non-streaming responses too. Client requests are checked for every dialect
+// with a request translator to the provider.
+func (f Fixture) Cases() []Case {
+	var out []Case
+	provider := sdktranslator.FromString(f.Provider)
+	for _, dialect := range dialects {
+		if _, ok := f.ClientRequests[dialect.String()]; ok && dialect != provider && sdktranslator.HasRequestTransformer(dialect, provider) {
+			out = append(out, Case{Dialect: dialect, Request: true})
+		}
+	}
+	if !f.hasResponse() {
+		return out
+	}
+	for _, dialect := range Dialects(f.Provider) {
+		if f.Streaming() {
+			out = append(out, Case{Dialect: dialect, Stream: true})
+		}
+		if _, ok := f.nonStreamBody(dialect); ok {
+			out = append(out, Case{Dialect: dialect})
+		}
+	}
+	return out
+}
+
+// nonStreamBody returns the body the provider's executor hands to the non-streaming
+// translator of dialect. Claude executors stream the requests of other dialects and pass
+// the whole SSE body on; Codex executors pass the response.completed event.
+func (f Fixture) nonStreamBody(dialect sdktranslator.Format) ([]byte, bool) {
+	provider := sdktranslator.FromString(f.Provider)
+	if !f.Streaming() {
+		if provider == sdktranslator.FormatClaude && dialect != provider {
+			return nil, false
+		}
+		return f.Response, true
+	}
+	switch provider {
+	case sdktranslator.FormatClaude:
+		if dialect == provider {
+			return nil, false
+		}
+		return []byte(strings.Join(f.Stream, "\n")), true
+	case sdktranslator.FormatCodex:
+		for _, line := range f.Stream {
+			if payload := jsonPayload(line); strings.Contains(payload, `"response.completed"`) {
+				return []byte(payload), true
+			}
+		}
+	}
+	return nil, false
+}
+
+// Golden renders the response of f for c, or the translated request, with volatile values
+// masked.
+func (f Fixture) Golden(c Case) string {
+	if c.Request {
+		first, second := f.translateRequest(c), f.translateRequest(c)
+		return canonicalJSON(first, second, true) + "\n"
+	}
+	first, second := f.translate(c), f.translate(c)
+	var b strings.Builder
+	if !c.Stream {
+		b.WriteString(canonicalJSON(first[0], second[0], true))
+		b.WriteByte('\n')
+		return b.String()
+	}
+	for i, chunk := range first {
+		fmt.Fprintf(&b, "# chunk %d\n", i+1)
+		other := chunk
+		if len(second) == len(first) {
+			other = second[i]
+		}
+		lines, otherLines := strings.Split(chunk, "\n"), strings.Split(other, "\n")
+		for j, line := range lines {
+			otherLine := line
+			if len(otherLines) == len(lines) {
+				otherLine = otherLines[j]
+			}
+			b.WriteString(canonicalLine(line, otherLine))
+			b.WriteByte('\n')
+		}
+	}
+	return b.String()
+}
+
+func (f Fixture) translateRequest(c Case) string {
+	from := sdktranslator.FromString(f.Provider)
+	raw := bytes.Clone(f.ClientRequests[c.Dialect.String()])
+	return string(sdktranslator.TranslateRequest(c.Dialect, from, f.Model, raw, f.Streaming()))
+}
+
+func (f Fixture) translate(c Case) []string {
+	from := sdktranslator.FromString(f.Provider)
+	original := []byte(f.Request)
+	if req, ok := f.ClientRequests[c.Dialect.String()]; ok {
+		original = req
+	}
+	ctx := context.Background()
+	var param any
+	if !c.Stream {
+		body, _ := f.nonStreamBody(c.Dialect)
+		return []string{sdktranslator.TranslateNonStream(ctx, from, c.Dialect, f.Model, bytes.Clone(original), bytes.Clone(f.Request), body, &param)}
+	}
+	var out []string
+	for _, line := range FeedLines(f.Provider, f.Stream) {
+		out = append(out, sdktranslator.TranslateStream(ctx, from, c.Dialect, f.Model, bytes.Clone(original), bytes.Clone(f.Request), []byte(line), &param)...)
+	}
+	return out
+}
+
+// FeedLines returns the stream lines in the shape the provider's executor hands them to
+// the translators: Claude and Codex lines verbatim, OpenAI "data:" lines, and Gemini style
+// JSON payloads followed by a closing "[DONE]".
+func FeedLines(provider string, lines []string) []string {
+	var out []string
+	switch sdktranslator.FromString(provider) {
+	case sdktranslator.FormatClaude, sdktranslator.FormatCodex:
+		return lines
+	case sdktranslator.FormatOpenAI:
+		for _, line := range lines {
+			if strings.HasPrefix(line, "data:") {
+				out = append(out, line)
+			}
+		}
+	case sdktranslator.FormatGeminiCLI:
+		for _, line := range lines {
+			if strings.HasPrefix(line, "data:") {
+				out = append(out, line)
+			}
+		}
+		out = append(out, "[DONE]")
+	default:
+		for _, line := range lines {
+			if payload := jsonPayload(line); payload != "" {
+				out = append(out, payload)
+			}
+		}
+		out = append(out, "[DONE]")
+	}
+	return out
+}
+
+func jsonPayload(line string) string {
+	line = strings.TrimSpace(line)
+	line = strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+	if !strings.HasPrefix(line, "{") {
+		return ""
+	}
+	return line
+}
+
+// canonicalLine canonicalizes the JSON of an SSE data line or of a bare JSON chunk.
+func canonicalLine(line, other string) string {
+	if rest, ok := strings.CutPrefix(line, "data:"); ok {
+		otherRest, _ := strings.CutPrefix(other, "data:")
+		if body := strings.TrimSpace(rest); json.Valid([]byte(body)) {
+			return "data: " + canonicalJSON(body, strings.TrimSpace(otherRest), false)
+		}
+		return line
+	}
+	if json.Valid([]byte(line)) && strings.HasPrefix(strings.TrimSpace(line), "{") {
+		return canonicalJSON(line, other, false)
+	}
+	return line
+}
+
+// canonicalJSON re-encodes a with sorted keys, masking the values that differ in b and
+// the timestamps.
+func canonicalJSON(a, b string, indent bool) string {
+	va, errA := decodeJSON(a)
+	if errA != nil {
+		return a
+	}
+	vb, errB := decodeJSON(b)
+	if errB != nil {
+		vb = va
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if indent {
+		enc.SetIndent("", "  ")
+	}
+	if err := enc.Encode(mask(va, vb, "")); err != nil {
+		return a
+	}
+	return strings.TrimSuffix(buf.String(), "\n")
+}
+
+func decodeJSON(s string) (any, error) {
+	dec := json.NewDecoder(strings.NewReader(s))
+	dec.UseNumber()
+	var v any
+	err := dec.Decode(&v)
+	return v, err
+}
+
+func mask(a, b any, key string) any {
+	switch va := a.(type) {
+	case map[string]any:
+		vb, _ := b.(map[string]any)
+		out := make(map[string]any, len(va))
+		for k, v := range va {
+			out[k] = mask(v, vb[k], k)
+		}
+		return out
+	case []any:
+		vb, _ := b.([]any)
+		out := make([]any, len(va))
+		for i, v := range va {
+			var other any = v
+			if len(vb) == len(va) {
+				other = vb[i]
+			}
+			out[i] = mask(v, other, key)
+		}
+		return out
+	}
+	if timeKeys[key] || generatedKeys[key] || fmt.Sprint(a) != fmt.Sprint(b) {
+		return volatileValue
+	}
+	return a
+}
@@ -0,0 +1,62 @@
+package contract
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files from the fixtures")
+
+func TestContracts(t *testing.T) {
+	fixtures, err := LoadFixtures(filepath.Join("testdata", "fixtures"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fixtures) == 0 {
+		t.Fatal("no fixtures found")
+	}
+	for _, fixture := range fixtures {
+		for _, c := range fixture.Cases() {
+			t.Run(fixture.Name+"/"+c.File(), func(t *testing.T) {
+				path := filepath.Join("testdata", "golden", fixture.Name, c.File())
+				got := fixture.Golden(c)
+				if *update {
+					if errMkdir := os.MkdirAll(filepath.Dir(path), 0o755); errMkdir != nil {
+						t.Fatal(errMkdir)
+					}
+					if errWrite := os.WriteFile(path, []byte(got), 0o644); errWrite != nil {
+						t.Fatal(errWrite)
+					}
+					return
+				}
+				want, errRead := os.ReadFile(path)
+				if errRead != nil {
+					t.Fatalf("missing golden file, run go test ./internal/translator/contract -update: %v", errRead)
+				}
+				if got != string(want) {
+					t.Errorf("%s translated to %s changed; review and run with -update if intended\n--- want\n%s\n--- got\n%s", fixture.Name, c.File(), want, got)
+				}
+			})
+		}
+	}
+}
+
+func TestSanitizeRedactsSecrets(t *testing.T) {
+	in := `{"user":"alice","metadata":{"api_key":"abc"},"text":"key sk-ant-REDACTED and bob@example.com","n":1}`
+	out := string(Sanitize([]byte(in)))
+	for _, secret := range []string{"alice", "abc", "sk-ant-REALKEY", "bob@example.com"} {
+		if strings.Contains(out, secret) {
+			t.Fatalf("sanitized output still contains %q: %s", secret, out)
+		}
+	}
+	if !strings.Contains(out, `"n":1`) {
+		t.Fatalf("sanitize changed unrelated fields: %s", out)
+	}
+	lines := SanitizeStream([]string{"event: x", `data: {"token":"t0k3n"}`})
+	if lines[0] != "event: x" || strings.Contains(lines[1], "t0k3n") {
+		t.Fatalf("stream sanitized to %q", lines)
+	}
+}
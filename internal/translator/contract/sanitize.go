@@ -0,0 +1,100 @@
+package contract
+
+import (
+	"bytes"
+	"encoding/json"
+	"regexp"
+	"strings"
+)
+
+// redacted replaces sanitized values.
+const redacted = "REDACTED"
+
+// sensitiveKeys name JSON fields whose values are always redacted.
+var sensitiveKeys = map[string]bool{
+	"api_key":       true,
+	"apikey":        true,
+	"authorization": true,
+	"access_token":  true,
+	"refresh_token": true,
+	"id_token":      true,
+	"token":         true,
+	"secret":        true,
+	"password":      true,
+	"email":         true,
+	"user":          true,
+	"user_id":       true,
+	"project":       true,
+	"project_id":    true,
+	"session_id":    true,
+}
+
+// secretPatterns match credentials embedded in free text.
+var secretPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`sk-[A-Za-z0-9_-]{16,}`),
+	regexp.MustCompile(`AIza[0-9A-Za-z_-]{35}`),
+	regexp.MustCompile(`ya29\.[0-9A-Za-z_.-]+`),
+	regexp.MustCompile(`(?i)bearer\s+[A-Za-z0-9._~+/=-]{8,}`),
+	regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`),
+}
+
+// Sanitize redacts credentials and personal data from a JSON document: values of
+// sensitive fields and secrets embedded in strings. Invalid JSON is scrubbed as text.
+func Sanitize(data []byte) []byte {
+	v, err := decodeJSON(string(data))
+	if err != nil {
+		return []byte(scrub(string(data)))
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err = enc.Encode(sanitizeValue(v)); err != nil {
+		return []byte(scrub(string(data)))
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n"))
+}
+
+// SanitizeStream sanitizes the JSON of every SSE data line.
+func SanitizeStream(lines []string) []string {
+	out := make([]string, len(lines))
+	for i, line := range lines {
+		rest, ok := strings.CutPrefix(line, "data:")
+		if body := strings.TrimSpace(rest); ok && json.Valid([]byte(body)) {
+			out[i] = "data: " + string(Sanitize([]byte(body)))
+			continue
+		}
+		out[i] = scrub(line)
+	}
+	return out
+}
+
+func sanitizeValue(v any) any {
+	switch value := v.(type) {
+	case map[string]any:
+		for k, item := range value {
+			if sensitiveKeys[strings.ToLower(k)] {
+				if _, isString := item.(string); isString {
+					value[k] = redacted
+					continue
+				}
+			}
+			value[k] = sanitizeValue(item)
+		}
+		return value
+	case []any:
+		for i := range value {
+			value[i] = sanitizeValue(value[i])
+		}
+		return value
+	case string:
+		return scrub(value)
+	}
+	return v
+}
+
+func scrub(s string) string {
+	for _, pattern := range secretPatterns {
+		s = pattern.ReplaceAllString(s, redacted)
+	}
+	return s
+}
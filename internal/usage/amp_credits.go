@@ -0,0 +1,72 @@
+package usage
+
+import "time"
+
+// AmpCreditStats aggregates the requests of one model that were forwarded to ampcode.com
+// and paid with Amp credits. EstimatedCost is what a model mapping to a local provider
+// would have saved.
+type AmpCreditStats struct {
+	Requests         int64   `json:"requests"`
+	InputTokens      int64   `json:"input_tokens"`
+	OutputTokens     int64   `json:"output_tokens"`
+	CachedTokens     int64   `json:"cached_tokens"`
+	EstimatedCost    float64 `json:"estimated_cost"`
+	UnpricedRequests int64   `json:"unpriced_requests,omitempty"`
+}
+
+// RecordAmpCredits adds one AMP_CREDITS request to the per-day, per-model aggregates.
+// priced is false when no price is known for the model; its tokens are still counted.
+// Imported snapshots do not carry these aggregates back in.
+func (s *RequestStatistics) RecordAmpCredits(model string, at time.Time, tokens TokenStats, cost float64, priced bool) {
+	if s == nil || !statisticsEnabled.Load() {
+		return
+	}
+	if model == "" {
+		model = "unknown"
+	}
+	if at.IsZero() {
+		at = time.Now()
+	}
+	dayKey := at.Format("2006-01-02")
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.ampCreditsByDay == nil {
+		s.ampCreditsByDay = make(map[string]map[string]*AmpCreditStats)
+	}
+	models, ok := s.ampCreditsByDay[dayKey]
+	if !ok {
+		models = make(map[string]*AmpCreditStats)
+		s.ampCreditsByDay[dayKey] = models
+	}
+	stats, ok := models[model]
+	if !ok {
+		stats = &AmpCreditStats{}
+		models[model] = stats
+	}
+	stats.Requests++
+	stats.InputTokens += tokens.InputTokens
+	stats.OutputTokens += tokens.OutputTokens
+	stats.CachedTokens += tokens.CachedTokens
+	stats.EstimatedCost += cost
+	if !priced {
+		stats.UnpricedRequests++
+	}
+}
+
+// snapshotAmpCredits copies the Amp credit aggregates. The caller holds s.mu.
+func (s *RequestStatistics) snapshotAmpCredits() map[string]map[string]AmpCreditStats {
+	if len(s.ampCreditsByDay) == 0 {
+		return nil
+	}
+	out := make(map[string]map[string]AmpCreditStats, len(s.ampCreditsByDay))
+	for day, models := range s.ampCreditsByDay {
+		dayStats := make(map[string]AmpCreditStats, len(models))
+		for model, stats := range models {
+			dayStats[model] = *stats
+		}
+		out[day] = dayStats
+	}
+	return out
+}
@@ -0,0 +1,33 @@
+package usage
+
+// RecordCancelled counts a request abandoned by its client before the response completed,
+// keyed by route. Imported snapshots do not carry these counters back in.
+func (s *RequestStatistics) RecordCancelled(route string) {
+	if s == nil || !statisticsEnabled.Load() {
+		return
+	}
+	if route == "" {
+		route = "unmatched"
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.cancelledByRoute == nil {
+		s.cancelledByRoute = make(map[string]int64)
+	}
+	s.cancelledCount++
+	s.cancelledByRoute[route]++
+}
+
+// snapshotCancelled copies the cancellation counters. The caller holds s.mu.
+func (s *RequestStatistics) snapshotCancelled() (int64, map[string]int64) {
+	if len(s.cancelledByRoute) == 0 {
+		return s.cancelledCount, nil
+	}
+	out := make(map[string]int64, len(s.cancelledByRoute))
+	for route, count := range s.cancelledByRoute {
+		out[route] = count
+	}
+	return s.cancelledCount, out
+}
@@ -12,6 +12,7 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/pricing"
 	coreusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
 )
 
@@ -64,6 +65,7 @@ type RequestStatistics struct {
 	successCount  int64
 	failureCount  int64
 	totalTokens   int64
+	totalCost     float64
 
 	apis map[string]*apiStats
 
@@ -71,12 +73,25 @@ type RequestStatistics struct {
 	requestsByHour map[int]int64
 	tokensByDay    map[string]int64
 	tokensByHour   map[int]int64
+	costByDay      map[string]float64
+
+	ampCreditsByDay map[string]map[string]*AmpCreditStats
+
+	cancelledCount   int64
+	cancelledByRoute map[string]int64
+
+	thinking map[string]map[string]*ThinkingStats
+
+	streamLatency map[string]map[string]*streamLatency
+
+	persistence usagePersistence
 }
 
 // apiStats holds aggregated metrics for a single API key.
 type apiStats struct {
 	TotalRequests int64
 	TotalTokens   int64
+	TotalCost     float64
 	Models        map[string]*modelStats
 }
 
@@ -84,6 +99,7 @@ type apiStats struct {
 type modelStats struct {
 	TotalRequests int64
 	TotalTokens   int64
+	TotalCost     float64
 	Details       []RequestDetail
 }
 
@@ -94,6 +110,8 @@ type RequestDetail struct {
 	AuthIndex string     `json:"auth_index"`
 	Tokens    TokenStats `json:"tokens"`
 	Failed    bool       `json:"failed"`
+	// Cost is the USD cost of the tokens at the configured model prices; 0 when unpriced.
+	Cost float64 `json:"cost,omitempty"`
 }
 
 // TokenStats captures the token usage breakdown for a request.
@@ -105,25 +123,50 @@ type TokenStats struct {
 	TotalTokens     int64 `json:"total_tokens"`
 }
 
+// GeneratedTokens returns the output tokens including reasoning, counting reasoning once.
+func (t TokenStats) GeneratedTokens() int64 {
+	return coreusage.Detail(t).GeneratedTokens()
+}
+
 // StatisticsSnapshot represents an immutable view of the aggregated metrics.
 type StatisticsSnapshot struct {
 	TotalRequests int64 `json:"total_requests"`
 	SuccessCount  int64 `json:"success_count"`
 	FailureCount  int64 `json:"failure_count"`
 	TotalTokens   int64 `json:"total_tokens"`
+	// TotalCost is the USD cost of the priced requests (see the pricing config section).
+	TotalCost float64 `json:"total_cost,omitempty"`
 
 	APIs map[string]APISnapshot `json:"apis"`
 
-	RequestsByDay  map[string]int64 `json:"requests_by_day"`
-	RequestsByHour map[string]int64 `json:"requests_by_hour"`
-	TokensByDay    map[string]int64 `json:"tokens_by_day"`
-	TokensByHour   map[string]int64 `json:"tokens_by_hour"`
+	RequestsByDay  map[string]int64   `json:"requests_by_day"`
+	RequestsByHour map[string]int64   `json:"requests_by_hour"`
+	TokensByDay    map[string]int64   `json:"tokens_by_day"`
+	TokensByHour   map[string]int64   `json:"tokens_by_hour"`
+	CostByDay      map[string]float64 `json:"cost_by_day,omitempty"`
+
+	// AmpCreditsByDay holds the estimated Amp credit usage per day and requested model.
+	AmpCreditsByDay map[string]map[string]AmpCreditStats `json:"amp_credits_by_day,omitempty"`
+
+	// CancelledCount counts requests whose client disconnected before the response completed;
+	// CancelledByRoute breaks it down by route.
+	CancelledCount   int64            `json:"cancelled_count,omitempty"`
+	CancelledByRoute map[string]int64 `json:"cancelled_by_route,omitempty"`
+
+	// Thinking compares the requested thinking levels with the reasoning tokens reported,
+	// per model and requested level.
+	Thinking map[string]map[string]ThinkingStats `json:"thinking,omitempty"`
+
+	// StreamLatency holds the time to first token and inter-token latency of streamed
+	// responses, per provider and upstream model.
+	StreamLatency map[string]map[string]StreamLatencyStats `json:"stream_latency,omitempty"`
 }
 
 // APISnapshot summarises metrics for a single API key.
 type APISnapshot struct {
 	TotalRequests int64                    `json:"total_requests"`
 	TotalTokens   int64                    `json:"total_tokens"`
+	TotalCost     float64                  `json:"total_cost,omitempty"`
 	Models        map[string]ModelSnapshot `json:"models"`
 }
 
@@ -131,6 +174,7 @@ type APISnapshot struct {
 type ModelSnapshot struct {
 	TotalRequests int64           `json:"total_requests"`
 	TotalTokens   int64           `json:"total_tokens"`
+	TotalCost     float64         `json:"total_cost,omitempty"`
 	Details       []RequestDetail `json:"details"`
 }
 
@@ -147,6 +191,9 @@ func NewRequestStatistics() *RequestStatistics {
 		requestsByHour: make(map[int]int64),
 		tokensByDay:    make(map[string]int64),
 		tokensByHour:   make(map[int]int64),
+		costByDay:      make(map[string]float64),
+
+		ampCreditsByDay: make(map[string]map[string]*AmpCreditStats),
 	}
 }
 
@@ -179,6 +226,16 @@ func (s *RequestStatistics) Record(ctx context.Context, record coreusage.Record)
 	}
 	dayKey := timestamp.Format("2006-01-02")
 	hourKey := timestamp.Hour()
+	cost, _ := pricing.Default().Cost(record.Model, pricing.UsageOf(coreusage.Detail(detail)))
+	requestDetail := RequestDetail{
+		Timestamp: timestamp,
+		Source:    record.Source,
+		AuthIndex: record.AuthIndex,
+		Tokens:    detail,
+		Failed:    failed,
+		Cost:      cost,
+	}
+	s.persistDetail(statsKey, modelName, requestDetail)
 
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -196,23 +253,30 @@ func (s *RequestStatistics) Record(ctx context.Context, record coreusage.Record)
 		stats = &apiStats{Models: make(map[string]*modelStats)}
 		s.apis[statsKey] = stats
 	}
-	s.updateAPIStats(stats, modelName, RequestDetail{
-		Timestamp: timestamp,
-		Source:    record.Source,
-		AuthIndex: record.AuthIndex,
-		Tokens:    detail,
-		Failed:    failed,
-	})
+	s.updateAPIStats(stats, modelName, requestDetail)
 
 	s.requestsByDay[dayKey]++
 	s.requestsByHour[hourKey]++
 	s.tokensByDay[dayKey] += totalTokens
 	s.tokensByHour[hourKey] += totalTokens
+	s.addCost(dayKey, cost)
+	if request, ok := coreusage.ThinkingRequestFromContext(ctx); ok && success {
+		s.recordThinking(modelName, request, detail)
+	}
+}
+
+func (s *RequestStatistics) addCost(dayKey string, cost float64) {
+	if cost <= 0 {
+		return
+	}
+	s.totalCost += cost
+	s.costByDay[dayKey] += cost
 }
 
 func (s *RequestStatistics) updateAPIStats(stats *apiStats, model string, detail RequestDetail) {
 	stats.TotalRequests++
 	stats.TotalTokens += detail.Tokens.TotalTokens
+	stats.TotalCost += detail.Cost
 	modelStatsValue, ok := stats.Models[model]
 	if !ok {
 		modelStatsValue = &modelStats{}
@@ -220,6 +284,7 @@ func (s *RequestStatistics) updateAPIStats(stats *apiStats, model string, detail
 	}
 	modelStatsValue.TotalRequests++
 	modelStatsValue.TotalTokens += detail.Tokens.TotalTokens
+	modelStatsValue.TotalCost += detail.Cost
 	modelStatsValue.Details = append(modelStatsValue.Details, detail)
 }
 
@@ -237,12 +302,14 @@ func (s *RequestStatistics) Snapshot() StatisticsSnapshot {
 	result.SuccessCount = s.successCount
 	result.FailureCount = s.failureCount
 	result.TotalTokens = s.totalTokens
+	result.TotalCost = s.totalCost
 
 	result.APIs = make(map[string]APISnapshot, len(s.apis))
 	for apiName, stats := range s.apis {
 		apiSnapshot := APISnapshot{
 			TotalRequests: stats.TotalRequests,
 			TotalTokens:   stats.TotalTokens,
+			TotalCost:     stats.TotalCost,
 			Models:        make(map[string]ModelSnapshot, len(stats.Models)),
 		}
 		for modelName, modelStatsValue := range stats.Models {
@@ -251,6 +318,7 @@ func (s *RequestStatistics) Snapshot() StatisticsSnapshot {
 			apiSnapshot.Models[modelName] = ModelSnapshot{
 				TotalRequests: modelStatsValue.TotalRequests,
 				TotalTokens:   modelStatsValue.TotalTokens,
+				TotalCost:     modelStatsValue.TotalCost,
 				Details:       requestDetails,
 			}
 		}
@@ -279,6 +347,18 @@ func (s *RequestStatistics) Snapshot() StatisticsSnapshot {
 		result.TokensByHour[key] = v
 	}
 
+	if len(s.costByDay) > 0 {
+		result.CostByDay = make(map[string]float64, len(s.costByDay))
+		for k, v := range s.costByDay {
+			result.CostByDay[k] = v
+		}
+	}
+
+	result.AmpCreditsByDay = s.snapshotAmpCredits()
+	result.CancelledCount, result.CancelledByRoute = s.snapshotCancelled()
+	result.Thinking = s.snapshotThinking()
+	result.StreamLatency = s.snapshotStreamLatency()
+
 	return result
 }
 
@@ -373,6 +453,7 @@ func (s *RequestStatistics) recordImported(apiName, modelName string, stats *api
 	s.requestsByHour[hourKey]++
 	s.tokensByDay[dayKey] += totalTokens
 	s.tokensByHour[hourKey] += totalTokens
+	s.addCost(dayKey, detail.Cost)
 }
 
 func dedupKey(apiName, modelName string, detail RequestDetail) string {
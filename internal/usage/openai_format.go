@@ -0,0 +1,259 @@
+package usage
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+	"strings"
+	"time"
+)
+
+// OpenAIUsageQuery selects the records summarised by the OpenAI-compatible usage endpoints.
+type OpenAIUsageQuery struct {
+	// StartTime and EndTime bound the request timestamps (EndTime is exclusive; zero means now).
+	StartTime time.Time
+	EndTime   time.Time
+	// BucketWidth is the aggregation window: time.Minute, time.Hour or 24*time.Hour.
+	BucketWidth time.Duration
+	// GroupByModel and GroupByAPIKey split each bucket into per-model / per-key results.
+	GroupByModel  bool
+	GroupByAPIKey bool
+	// Models restricts results to the listed model names.
+	Models []string
+	// APIKeys restricts results to the listed client API keys.
+	APIKeys []string
+	// Limit caps the number of returned buckets.
+	Limit int
+}
+
+// OpenAIUsagePage mirrors the paginated response of OpenAI's organization usage API.
+type OpenAIUsagePage struct {
+	Object   string              `json:"object"`
+	Data     []OpenAIUsageBucket `json:"data"`
+	HasMore  bool                `json:"has_more"`
+	NextPage *string             `json:"next_page"`
+}
+
+// OpenAIUsageBucket aggregates results within one time window.
+type OpenAIUsageBucket struct {
+	Object    string              `json:"object"`
+	StartTime int64               `json:"start_time"`
+	EndTime   int64               `json:"end_time"`
+	Results   []OpenAIUsageResult `json:"results"`
+}
+
+// OpenAIUsageResult is a completions usage result in OpenAI's format.
+type OpenAIUsageResult struct {
+	Object            string  `json:"object"`
+	InputTokens       int64   `json:"input_tokens"`
+	OutputTokens      int64   `json:"output_tokens"`
+	InputCachedTokens int64   `json:"input_cached_tokens"`
+	InputAudioTokens  int64   `json:"input_audio_tokens"`
+	OutputAudioTokens int64   `json:"output_audio_tokens"`
+	NumModelRequests  int64   `json:"num_model_requests"`
+	ProjectID         *string `json:"project_id"`
+	UserID            *string `json:"user_id"`
+	APIKeyID          *string `json:"api_key_id"`
+	Model             *string `json:"model"`
+	Batch             *bool   `json:"batch"`
+}
+
+// LegacyUsageEntry mirrors an entry of the legacy /v1/usage "data" list.
+type LegacyUsageEntry struct {
+	AggregationTimestamp  int64  `json:"aggregation_timestamp"`
+	NRequests             int64  `json:"n_requests"`
+	Operation             string `json:"operation"`
+	SnapshotID            string `json:"snapshot_id"`
+	NContext              int64  `json:"n_context"`
+	NContextTokensTotal   int64  `json:"n_context_tokens_total"`
+	NGenerated            int64  `json:"n_generated"`
+	NGeneratedTokensTotal int64  `json:"n_generated_tokens_total"`
+	APIKeyID              string `json:"api_key_id,omitempty"`
+}
+
+// APIKeyID returns a stable, non-reversible identifier for a client API key.
+func APIKeyID(apiKey string) string {
+	sum := sha256.Sum256([]byte(apiKey))
+	return "key_" + hex.EncodeToString(sum[:8])
+}
+
+type usageRow struct {
+	apiKey string
+	model  string
+	detail RequestDetail
+}
+
+func collectRows(snapshot StatisticsSnapshot, start, end time.Time, models, apiKeys []string) []usageRow {
+	modelFilter := stringSet(models)
+	keyFilter := stringSet(apiKeys)
+	var rows []usageRow
+	for apiKey, api := range snapshot.APIs {
+		if keyFilter != nil {
+			if _, ok := keyFilter[apiKey]; !ok {
+				continue
+			}
+		}
+		for model, stats := range api.Models {
+			if modelFilter != nil {
+				if _, ok := modelFilter[model]; !ok {
+					continue
+				}
+			}
+			for _, detail := range stats.Details {
+				if !start.IsZero() && detail.Timestamp.Before(start) {
+					continue
+				}
+				if !end.IsZero() && !detail.Timestamp.Before(end) {
+					continue
+				}
+				rows = append(rows, usageRow{apiKey: apiKey, model: model, detail: detail})
+			}
+		}
+	}
+	return rows
+}
+
+// BuildOpenAIUsagePage converts the statistics snapshot into OpenAI's organization usage format.
+func BuildOpenAIUsagePage(snapshot StatisticsSnapshot, q OpenAIUsageQuery) OpenAIUsagePage {
+	width := q.BucketWidth
+	if width <= 0 {
+		width = 24 * time.Hour
+	}
+	end := q.EndTime
+	if end.IsZero() {
+		end = time.Now()
+	}
+	start := q.StartTime.Truncate(width)
+
+	type resultKey struct {
+		bucket int64
+		apiKey string
+		model  string
+	}
+	results := make(map[resultKey]*OpenAIUsageResult)
+	for _, row := range collectRows(snapshot, start, end, q.Models, q.APIKeys) {
+		key := resultKey{bucket: row.detail.Timestamp.Truncate(width).Unix()}
+		if q.GroupByAPIKey {
+			key.apiKey = row.apiKey
+		}
+		if q.GroupByModel {
+			key.model = row.model
+		}
+		res, ok := results[key]
+		if !ok {
+			res = &OpenAIUsageResult{Object: "organization.usage.completions.result"}
+			if q.GroupByAPIKey {
+				id := APIKeyID(row.apiKey)
+				res.APIKeyID = &id
+			}
+			if q.GroupByModel {
+				model := row.model
+				res.Model = &model
+			}
+			results[key] = res
+		}
+		res.InputTokens += row.detail.Tokens.InputTokens
+		res.OutputTokens += row.detail.Tokens.GeneratedTokens()
+		res.InputCachedTokens += row.detail.Tokens.CachedTokens
+		res.NumModelRequests++
+	}
+
+	buckets := make(map[int64]*OpenAIUsageBucket)
+	for key, res := range results {
+		bucket, ok := buckets[key.bucket]
+		if !ok {
+			bucket = &OpenAIUsageBucket{
+				Object:    "bucket",
+				StartTime: key.bucket,
+				EndTime:   time.Unix(key.bucket, 0).Add(width).Unix(),
+			}
+			buckets[key.bucket] = bucket
+		}
+		bucket.Results = append(bucket.Results, *res)
+	}
+
+	page := OpenAIUsagePage{Object: "page", Data: make([]OpenAIUsageBucket, 0, len(buckets))}
+	for _, bucket := range buckets {
+		sort.Slice(bucket.Results, func(i, j int) bool {
+			return derefString(bucket.Results[i].Model)+derefString(bucket.Results[i].APIKeyID) <
+				derefString(bucket.Results[j].Model)+derefString(bucket.Results[j].APIKeyID)
+		})
+		page.Data = append(page.Data, *bucket)
+	}
+	sort.Slice(page.Data, func(i, j int) bool { return page.Data[i].StartTime < page.Data[j].StartTime })
+	if q.Limit > 0 && len(page.Data) > q.Limit {
+		page.Data = page.Data[:q.Limit]
+		page.HasMore = true
+		next := time.Unix(page.Data[len(page.Data)-1].EndTime, 0).UTC().Format(time.RFC3339)
+		page.NextPage = &next
+	}
+	return page
+}
+
+// BuildLegacyUsage converts the snapshot into the legacy /v1/usage daily format,
+// aggregated per model in five-minute windows for the given UTC day.
+func BuildLegacyUsage(snapshot StatisticsSnapshot, day time.Time, apiKeys []string) []LegacyUsageEntry {
+	start := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, time.UTC)
+	end := start.Add(24 * time.Hour)
+	const window = 5 * time.Minute
+
+	type entryKey struct {
+		ts     int64
+		model  string
+		apiKey string
+	}
+	entries := make(map[entryKey]*LegacyUsageEntry)
+	for _, row := range collectRows(snapshot, start, end, nil, apiKeys) {
+		key := entryKey{ts: row.detail.Timestamp.UTC().Truncate(window).Unix(), model: row.model, apiKey: row.apiKey}
+		entry, ok := entries[key]
+		if !ok {
+			entry = &LegacyUsageEntry{
+				AggregationTimestamp: key.ts,
+				Operation:            "completion",
+				SnapshotID:           row.model,
+				APIKeyID:             APIKeyID(row.apiKey),
+			}
+			entries[key] = entry
+		}
+		entry.NRequests++
+		entry.NContext++
+		entry.NGenerated++
+		entry.NContextTokensTotal += row.detail.Tokens.InputTokens
+		entry.NGeneratedTokensTotal += row.detail.Tokens.GeneratedTokens()
+	}
+
+	out := make([]LegacyUsageEntry, 0, len(entries))
+	for _, entry := range entries {
+		out = append(out, *entry)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].AggregationTimestamp != out[j].AggregationTimestamp {
+			return out[i].AggregationTimestamp < out[j].AggregationTimestamp
+		}
+		return out[i].SnapshotID < out[j].SnapshotID
+	})
+	return out
+}
+
+func stringSet(values []string) map[string]struct{} {
+	if len(values) == 0 {
+		return nil
+	}
+	set := make(map[string]struct{}, len(values))
+	for _, v := range values {
+		if v = strings.TrimSpace(v); v != "" {
+			set[v] = struct{}{}
+		}
+	}
+	if len(set) == 0 {
+		return nil
+	}
+	return set
+}
+
+func derefString(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
@@ -0,0 +1,75 @@
+package usage
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBuildOpenAIUsagePage_GroupsByModelAndBucket(t *testing.T) {
+	day := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	snapshot := StatisticsSnapshot{APIs: map[string]APISnapshot{
+		"client-a": {Models: map[string]ModelSnapshot{
+			"gpt-5": {Details: []RequestDetail{
+				{Timestamp: day.Add(time.Hour), Tokens: TokenStats{InputTokens: 10, OutputTokens: 5, ReasoningTokens: 3, CachedTokens: 2, TotalTokens: 15}},
+				{Timestamp: day.Add(26 * time.Hour), Tokens: TokenStats{InputTokens: 1, OutputTokens: 1}},
+			}},
+			"claude-sonnet-4": {Details: []RequestDetail{
+				{Timestamp: day.Add(2 * time.Hour), Tokens: TokenStats{InputTokens: 7, OutputTokens: 3, ReasoningTokens: 4, TotalTokens: 14}},
+			}},
+		}},
+		"client-b": {Models: map[string]ModelSnapshot{
+			"gpt-5": {Details: []RequestDetail{
+				{Timestamp: day.Add(3 * time.Hour), Tokens: TokenStats{InputTokens: 100}},
+			}},
+		}},
+	}}
+
+	page := BuildOpenAIUsagePage(snapshot, OpenAIUsageQuery{
+		StartTime:    day,
+		EndTime:      day.Add(72 * time.Hour),
+		BucketWidth:  24 * time.Hour,
+		GroupByModel: true,
+		APIKeys:      []string{"client-a"},
+	})
+	if page.Object != "page" || len(page.Data) != 2 {
+		t.Fatalf("expected 2 daily buckets, got %+v", page)
+	}
+	first := page.Data[0]
+	if first.StartTime != day.Unix() || len(first.Results) != 2 {
+		t.Fatalf("unexpected first bucket: %+v", first)
+	}
+	claude := first.Results[0]
+	if claude.Model == nil || *claude.Model != "claude-sonnet-4" || claude.OutputTokens != 7 || claude.NumModelRequests != 1 {
+		t.Fatalf("unexpected claude result: %+v", claude)
+	}
+	gpt := first.Results[1]
+	if gpt.InputTokens != 10 || gpt.OutputTokens != 5 || gpt.InputCachedTokens != 2 {
+		t.Fatalf("expected scoped gpt-5 usage without client-b, got %+v", gpt)
+	}
+
+	limited := BuildOpenAIUsagePage(snapshot, OpenAIUsageQuery{StartTime: day, EndTime: day.Add(72 * time.Hour), Limit: 1})
+	if len(limited.Data) != 1 || !limited.HasMore || limited.NextPage == nil {
+		t.Fatalf("expected pagination cursor, got %+v", limited)
+	}
+}
+
+func TestBuildLegacyUsage_FiltersDay(t *testing.T) {
+	day := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	snapshot := StatisticsSnapshot{APIs: map[string]APISnapshot{
+		"k": {Models: map[string]ModelSnapshot{
+			"gpt-5": {Details: []RequestDetail{
+				{Timestamp: day.Add(time.Minute), Tokens: TokenStats{InputTokens: 3, OutputTokens: 4}},
+				{Timestamp: day.Add(2 * time.Minute), Tokens: TokenStats{InputTokens: 1, OutputTokens: 1}},
+				{Timestamp: day.Add(25 * time.Hour), Tokens: TokenStats{InputTokens: 50}},
+			}},
+		}},
+	}}
+
+	entries := BuildLegacyUsage(snapshot, day, nil)
+	if len(entries) != 1 {
+		t.Fatalf("expected one 5-minute aggregate, got %+v", entries)
+	}
+	if entries[0].NRequests != 2 || entries[0].NContextTokensTotal != 4 || entries[0].NGeneratedTokensTotal != 5 {
+		t.Fatalf("unexpected aggregate: %+v", entries[0])
+	}
+}
@@ -0,0 +1,258 @@
+package usage
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"math"
+)
+
+// The export writes a single row group of required columns, each in one PLAIN-encoded,
+// uncompressed data page, which every Parquet reader accepts. Files are small enough that
+// buffering the records is fine.
+
+// Parquet physical types and the enum values of the format's thrift definitions.
+const (
+	parquetBoolean   = 0
+	parquetInt64     = 2
+	parquetDouble    = 5
+	parquetByteArray = 6
+
+	parquetConvertedUTF8            = 0
+	parquetConvertedTimestampMillis = 9
+
+	parquetEncodingPlain = 0
+	parquetEncodingRLE   = 3
+)
+
+// parquetColumn is one column of the export with its values already PLAIN-encoded.
+type parquetColumn struct {
+	name      string
+	kind      int32
+	converted int32 // -1 when the column has no converted type
+	values    bytes.Buffer
+}
+
+func (c *parquetColumn) putInt64(v int64) {
+	_ = binary.Write(&c.values, binary.LittleEndian, v)
+}
+
+func (c *parquetColumn) putString(v string) {
+	_ = binary.Write(&c.values, binary.LittleEndian, uint32(len(v)))
+	c.values.WriteString(v)
+}
+
+func (c *parquetColumn) putDouble(v float64) {
+	_ = binary.Write(&c.values, binary.LittleEndian, math.Float64bits(v))
+}
+
+// WriteRecordsParquet writes records as a single Parquet file.
+func WriteRecordsParquet(w io.Writer, records []UsageRecord) error {
+	columns := []*parquetColumn{
+		{name: "timestamp", kind: parquetInt64, converted: parquetConvertedTimestampMillis},
+		{name: "api", kind: parquetByteArray, converted: parquetConvertedUTF8},
+		{name: "model", kind: parquetByteArray, converted: parquetConvertedUTF8},
+		{name: "source", kind: parquetByteArray, converted: parquetConvertedUTF8},
+		{name: "auth_index", kind: parquetByteArray, converted: parquetConvertedUTF8},
+		{name: "input_tokens", kind: parquetInt64, converted: -1},
+		{name: "output_tokens", kind: parquetInt64, converted: -1},
+		{name: "reasoning_tokens", kind: parquetInt64, converted: -1},
+		{name: "cached_tokens", kind: parquetInt64, converted: -1},
+		{name: "total_tokens", kind: parquetInt64, converted: -1},
+		{name: "failed", kind: parquetBoolean, converted: -1},
+		{name: "cost", kind: parquetDouble, converted: -1},
+	}
+	failed := make([]byte, (len(records)+7)/8)
+	for i, r := range records {
+		columns[0].putInt64(r.Timestamp.UnixMilli())
+		columns[1].putString(r.API)
+		columns[2].putString(r.Model)
+		columns[3].putString(r.Source)
+		columns[4].putString(r.AuthIndex)
+		columns[5].putInt64(r.InputTokens)
+		columns[6].putInt64(r.OutputTokens)
+		columns[7].putInt64(r.ReasoningTokens)
+		columns[8].putInt64(r.CachedTokens)
+		columns[9].putInt64(r.TotalTokens)
+		if r.Failed {
+			failed[i/8] |= 1 << (i % 8)
+		}
+		columns[11].putDouble(r.Cost)
+	}
+	columns[10].values.Write(failed)
+
+	var file bytes.Buffer
+	file.WriteString("PAR1")
+	chunks := make([]*thriftWriter, len(columns))
+	var totalSize int64
+	for i, col := range columns {
+		offset := int64(file.Len())
+		header := newThriftWriter()
+		header.i32(1, 0) // DATA_PAGE
+		header.i32(2, int32(col.values.Len()))
+		header.i32(3, int32(col.values.Len()))
+		header.beginStruct(5)
+		header.i32(1, int32(len(records)))
+		header.i32(2, parquetEncodingPlain)
+		header.i32(3, parquetEncodingRLE)
+		header.i32(4, parquetEncodingRLE)
+		header.endStruct()
+		header.stop()
+		file.Write(header.buf.Bytes())
+		file.Write(col.values.Bytes())
+		size := int64(file.Len()) - offset
+		totalSize += size
+
+		chunk := newThriftWriter()
+		chunk.i64(2, offset)
+		chunk.beginStruct(3)
+		chunk.i32(1, col.kind)
+		chunk.i32List(2, []int32{parquetEncodingPlain, parquetEncodingRLE})
+		chunk.stringList(3, []string{col.name})
+		chunk.i32(4, 0) // UNCOMPRESSED
+		chunk.i64(5, int64(len(records)))
+		chunk.i64(6, size)
+		chunk.i64(7, size)
+		chunk.i64(9, offset)
+		chunk.endStruct()
+		chunks[i] = chunk
+	}
+
+	meta := newThriftWriter()
+	meta.i32(1, 1)
+	meta.beginList(2, thriftStruct, len(columns)+1)
+	meta.beginElement()
+	meta.binary(4, "usage_record")
+	meta.i32(5, int32(len(columns)))
+	meta.endElement()
+	for _, col := range columns {
+		meta.beginElement()
+		meta.i32(1, col.kind)
+		meta.i32(3, 0) // REQUIRED
+		meta.binary(4, col.name)
+		if col.converted >= 0 {
+			meta.i32(6, col.converted)
+		}
+		meta.endElement()
+	}
+	meta.i64(3, int64(len(records)))
+	meta.beginList(4, thriftStruct, 1)
+	meta.beginElement()
+	meta.beginList(1, thriftStruct, len(chunks))
+	for _, chunk := range chunks {
+		meta.beginElement()
+		meta.buf.Write(chunk.buf.Bytes())
+		meta.endElement()
+	}
+	meta.i64(2, totalSize)
+	meta.i64(3, int64(len(records)))
+	meta.endElement()
+	meta.binary(6, "CLIProxyAPI")
+	meta.stop()
+
+	file.Write(meta.buf.Bytes())
+	_ = binary.Write(&file, binary.LittleEndian, uint32(meta.buf.Len()))
+	file.WriteString("PAR1")
+	_, err := w.Write(file.Bytes())
+	return err
+}
+
+// Thrift compact protocol type ids.
+const (
+	thriftI32    = 5
+	thriftI64    = 6
+	thriftBinary = 8
+	thriftList   = 9
+	thriftStruct = 12
+)
+
+// thriftWriter encodes the thrift compact protocol subset the Parquet metadata needs.
+// Fields are written in increasing id order within each struct.
+type thriftWriter struct {
+	buf    bytes.Buffer
+	last   int16
+	parent []int16
+}
+
+func newThriftWriter() *thriftWriter { return &thriftWriter{} }
+
+func (t *thriftWriter) field(id int16, kind byte) {
+	if delta := id - t.last; delta > 0 && delta <= 15 {
+		t.buf.WriteByte(byte(delta)<<4 | kind)
+	} else {
+		t.buf.WriteByte(kind)
+		t.varint(int64(id))
+	}
+	t.last = id
+}
+
+func (t *thriftWriter) varint(v int64) {
+	t.uvarint(uint64((v << 1) ^ (v >> 63)))
+}
+
+func (t *thriftWriter) uvarint(v uint64) {
+	var scratch [binary.MaxVarintLen64]byte
+	t.buf.Write(scratch[:binary.PutUvarint(scratch[:], v)])
+}
+
+func (t *thriftWriter) i32(id int16, v int32) {
+	t.field(id, thriftI32)
+	t.varint(int64(v))
+}
+
+func (t *thriftWriter) i64(id int16, v int64) {
+	t.field(id, thriftI64)
+	t.varint(v)
+}
+
+func (t *thriftWriter) binary(id int16, v string) {
+	t.field(id, thriftBinary)
+	t.uvarint(uint64(len(v)))
+	t.buf.WriteString(v)
+}
+
+func (t *thriftWriter) beginList(id int16, elem byte, size int) {
+	t.field(id, thriftList)
+	if size < 15 {
+		t.buf.WriteByte(byte(size)<<4 | elem)
+		return
+	}
+	t.buf.WriteByte(0xF0 | elem)
+	t.uvarint(uint64(size))
+}
+
+func (t *thriftWriter) i32List(id int16, values []int32) {
+	t.beginList(id, thriftI32, len(values))
+	for _, v := range values {
+		t.varint(int64(v))
+	}
+}
+
+func (t *thriftWriter) stringList(id int16, values []string) {
+	t.beginList(id, thriftBinary, len(values))
+	for _, v := range values {
+		t.uvarint(uint64(len(v)))
+		t.buf.WriteString(v)
+	}
+}
+
+func (t *thriftWriter) beginStruct(id int16) {
+	t.field(id, thriftStruct)
+	t.beginElement()
+}
+
+func (t *thriftWriter) endStruct() { t.endElement() }
+
+// beginElement starts a struct that is a list element, which has no field header.
+func (t *thriftWriter) beginElement() {
+	t.parent = append(t.parent, t.last)
+	t.last = 0
+}
+
+func (t *thriftWriter) endElement() {
+	t.stop()
+	t.last = t.parent[len(t.parent)-1]
+	t.parent = t.parent[:len(t.parent)-1]
+}
+
+func (t *thriftWriter) stop() { t.buf.WriteByte(0) }
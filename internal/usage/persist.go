@@ -0,0 +1,136 @@
+package usage
+
+import (
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/storage"
+	log "github.com/sirupsen/logrus"
+)
+
+const persistDayLayout = "2006-01-02"
+
+// persistedDetail is one request detail as stored in the usage collection.
+type persistedDetail struct {
+	API   string `json:"api"`
+	Model string `json:"model"`
+	RequestDetail
+}
+
+// usagePersistence mirrors recorded request details to a storage backend, one log per
+// UTC day, so the statistics survive restarts.
+type usagePersistence struct {
+	mu        sync.Mutex
+	backend   storage.Backend
+	retention time.Duration
+	day       string
+}
+
+// Persist mirrors every request detail recorded from now on to backend and reloads the
+// history it already holds, returning the number of request details restored. Days older
+// than retentionDays are removed; 0 keeps them forever. A nil backend stops persisting.
+func (s *RequestStatistics) Persist(backend storage.Backend, retentionDays int) (int64, error) {
+	if s == nil {
+		return 0, nil
+	}
+	p := &s.persistence
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.backend != nil {
+		_ = p.backend.Close()
+	}
+	p.backend = backend
+	p.retention = time.Duration(retentionDays) * 24 * time.Hour
+	p.day = ""
+	if backend == nil {
+		return 0, nil
+	}
+	days, err := pruneUsageDays(backend, p.retention, time.Now())
+	if err != nil {
+		return 0, err
+	}
+	snapshot := StatisticsSnapshot{APIs: make(map[string]APISnapshot)}
+	for _, day := range days {
+		records, errRead := backend.Read(day)
+		if errRead != nil {
+			if errors.Is(errRead, storage.ErrNotFound) {
+				continue
+			}
+			return 0, errRead
+		}
+		for _, record := range records {
+			var detail persistedDetail
+			if errUnmarshal := json.Unmarshal(record, &detail); errUnmarshal != nil || detail.API == "" {
+				continue
+			}
+			api := snapshot.APIs[detail.API]
+			if api.Models == nil {
+				api.Models = make(map[string]ModelSnapshot)
+			}
+			model := api.Models[detail.Model]
+			model.Details = append(model.Details, detail.RequestDetail)
+			api.Models[detail.Model] = model
+			snapshot.APIs[detail.API] = api
+		}
+	}
+	// Merging skips the details already in memory, so reconfiguring never double counts.
+	return s.MergeSnapshot(snapshot).Added, nil
+}
+
+// persistDetail appends a recorded request detail to the backend, if any. The SQL backends
+// queue the append, so the usage worker never waits on the database.
+func (s *RequestStatistics) persistDetail(api, model string, detail RequestDetail) {
+	p := &s.persistence
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.backend == nil {
+		return
+	}
+	data, err := json.Marshal(persistedDetail{API: api, Model: model, RequestDetail: detail})
+	if err != nil {
+		return
+	}
+	day := detail.Timestamp.UTC().Format(persistDayLayout)
+	if err = p.backend.Append(day, data); err != nil {
+		log.Warnf("usage: persist request detail: %v", err)
+		return
+	}
+	if day != p.day {
+		// Apply retention once per day rather than on every request, off the usage worker.
+		p.day = day
+		backend, retention := p.backend, p.retention
+		go func() {
+			if _, errPrune := pruneUsageDays(backend, retention, time.Now()); errPrune != nil {
+				log.Warnf("usage: retention sweep failed: %v", errPrune)
+			}
+		}()
+	}
+}
+
+// pruneUsageDays removes the days of backend past retention and returns the remaining ones.
+func pruneUsageDays(backend storage.Backend, retention time.Duration, now time.Time) ([]string, error) {
+	keys, err := backend.Keys()
+	if err != nil {
+		return nil, err
+	}
+	cutoff := ""
+	if retention > 0 {
+		cutoff = now.Add(-retention).UTC().Format(persistDayLayout)
+	}
+	days := make([]string, 0, len(keys))
+	for _, key := range keys {
+		if _, errParse := time.Parse(persistDayLayout, key.Key); errParse != nil {
+			continue
+		}
+		if cutoff != "" && key.Key < cutoff {
+			if errDelete := backend.Delete(key.Key); errDelete != nil && !errors.Is(errDelete, storage.ErrNotFound) {
+				return nil, errDelete
+			}
+			continue
+		}
+		days = append(days, key.Key)
+	}
+	return days, nil
+}
@@ -0,0 +1,49 @@
+package usage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/storage"
+	coreusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+)
+
+func TestPersistRestoresHistory(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now().UTC().Truncate(24 * time.Hour).Add(time.Hour)
+	old := storage.NewFileBackend(dir)
+	if err := old.Append(now.AddDate(0, 0, -40).Format(persistDayLayout), []byte(`{"api":"k","model":"m","timestamp":"2020-01-01T00:00:00Z"}`)); err != nil {
+		t.Fatalf("seed: %v", err)
+	}
+
+	stats := NewRequestStatistics()
+	if _, err := stats.Persist(storage.NewFileBackend(dir), 30); err != nil {
+		t.Fatalf("persist: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		stats.Record(context.Background(), coreusage.Record{
+			APIKey:      "key-1",
+			Model:       "claude-sonnet-4",
+			RequestedAt: now.Add(time.Duration(i) * time.Second),
+			Detail:      coreusage.Detail{InputTokens: 10, OutputTokens: 5},
+		})
+	}
+
+	restarted := NewRequestStatistics()
+	loaded, err := restarted.Persist(storage.NewFileBackend(dir), 30)
+	if err != nil || loaded != 2 {
+		t.Fatalf("restore = %d, %v; want 2", loaded, err)
+	}
+	if again, _ := restarted.Persist(storage.NewFileBackend(dir), 30); again != 0 {
+		t.Fatalf("reconfiguring restored %d details again", again)
+	}
+	snapshot := restarted.Snapshot()
+	if snapshot.TotalRequests != 2 || snapshot.TotalTokens != 30 || snapshot.APIs["key-1"].Models["claude-sonnet-4"].TotalRequests != 2 {
+		t.Fatalf("snapshot = %+v", snapshot)
+	}
+	keys, _ := storage.NewFileBackend(dir).Keys()
+	if len(keys) != 1 || keys[0].Key != now.Format(persistDayLayout) {
+		t.Fatalf("days kept = %+v, want only today", keys)
+	}
+}
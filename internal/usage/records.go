@@ -0,0 +1,91 @@
+package usage
+
+import (
+	"encoding/csv"
+	"io"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+)
+
+// UsageRecord is one request detail flattened for export to warehouses. API is the client
+// API key, masked.
+type UsageRecord struct {
+	Timestamp       time.Time `json:"timestamp"`
+	API             string    `json:"api"`
+	Model           string    `json:"model"`
+	Source          string    `json:"source"`
+	AuthIndex       string    `json:"auth_index"`
+	InputTokens     int64     `json:"input_tokens"`
+	OutputTokens    int64     `json:"output_tokens"`
+	ReasoningTokens int64     `json:"reasoning_tokens"`
+	CachedTokens    int64     `json:"cached_tokens"`
+	TotalTokens     int64     `json:"total_tokens"`
+	Failed          bool      `json:"failed"`
+	Cost            float64   `json:"cost"`
+}
+
+// Records returns the request details recorded in [from, to), oldest first. A zero bound
+// is open.
+func (s *RequestStatistics) Records(from, to time.Time) []UsageRecord {
+	if s == nil {
+		return nil
+	}
+	s.mu.RLock()
+	var out []UsageRecord
+	for apiName, stats := range s.apis {
+		for modelName, model := range stats.Models {
+			for _, detail := range model.Details {
+				if !from.IsZero() && detail.Timestamp.Before(from) {
+					continue
+				}
+				if !to.IsZero() && !detail.Timestamp.Before(to) {
+					continue
+				}
+				out = append(out, UsageRecord{
+					Timestamp:       detail.Timestamp.UTC(),
+					API:             util.HideAPIKey(apiName),
+					Model:           modelName,
+					Source:          detail.Source,
+					AuthIndex:       detail.AuthIndex,
+					InputTokens:     detail.Tokens.InputTokens,
+					OutputTokens:    detail.Tokens.OutputTokens,
+					ReasoningTokens: detail.Tokens.ReasoningTokens,
+					CachedTokens:    detail.Tokens.CachedTokens,
+					TotalTokens:     detail.Tokens.TotalTokens,
+					Failed:          detail.Failed,
+					Cost:            detail.Cost,
+				})
+			}
+		}
+	}
+	s.mu.RUnlock()
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Timestamp.Before(out[j].Timestamp) })
+	return out
+}
+
+// WriteRecordsCSV writes records as CSV with a header row.
+func WriteRecordsCSV(w io.Writer, records []UsageRecord) error {
+	cw := csv.NewWriter(w)
+	_ = cw.Write([]string{"timestamp", "api", "model", "source", "auth_index", "input_tokens", "output_tokens", "reasoning_tokens", "cached_tokens", "total_tokens", "failed", "cost"})
+	for _, r := range records {
+		_ = cw.Write([]string{
+			r.Timestamp.Format(time.RFC3339Nano),
+			r.API,
+			r.Model,
+			r.Source,
+			r.AuthIndex,
+			strconv.FormatInt(r.InputTokens, 10),
+			strconv.FormatInt(r.OutputTokens, 10),
+			strconv.FormatInt(r.ReasoningTokens, 10),
+			strconv.FormatInt(r.CachedTokens, 10),
+			strconv.FormatInt(r.TotalTokens, 10),
+			strconv.FormatBool(r.Failed),
+			strconv.FormatFloat(r.Cost, 'f', -1, 64),
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
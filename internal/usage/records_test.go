@@ -0,0 +1,135 @@
+package usage
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/csv"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+	coreusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+)
+
+func TestRecordsExport(t *testing.T) {
+	stats := NewRequestStatistics()
+	base := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	for i := 0; i < 3; i++ {
+		stats.Record(context.Background(), coreusage.Record{
+			Provider:    "claude",
+			APIKey:      "sk-abcdefgh12345678",
+			Model:       "claude-sonnet-4",
+			RequestedAt: base.Add(time.Duration(i) * 24 * time.Hour),
+			Failed:      i == 2,
+			Detail:      coreusage.Detail{InputTokens: 10, OutputTokens: int64(i)},
+		})
+	}
+
+	records := stats.Records(base.Add(time.Hour), base.Add(48*time.Hour))
+	if len(records) != 1 || records[0].OutputTokens != 1 || records[0].API != "sk-a...5678" {
+		t.Fatalf("Records = %+v", records)
+	}
+	all := stats.Records(time.Time{}, time.Time{})
+	if len(all) != 3 || !all[0].Timestamp.Equal(base) {
+		t.Fatalf("unbounded Records = %+v", all)
+	}
+
+	var buf bytes.Buffer
+	if err := WriteRecordsCSV(&buf, all); err != nil {
+		t.Fatal(err)
+	}
+	rows, err := csv.NewReader(&buf).ReadAll()
+	if err != nil || len(rows) != 4 || rows[1][2] != "claude-sonnet-4" {
+		t.Fatalf("csv rows = %v, %v", rows, err)
+	}
+
+	buf.Reset()
+	if err = WriteRecordsParquet(&buf, all); err != nil {
+		t.Fatal(err)
+	}
+	file := buf.Bytes()
+	if !bytes.HasPrefix(file, []byte("PAR1")) || !bytes.HasSuffix(file, []byte("PAR1")) {
+		t.Fatalf("parquet file lacks magic: %q", file)
+	}
+	footer := int(binary.LittleEndian.Uint32(file[len(file)-8:]))
+	if footer <= 0 || footer > len(file)-12 {
+		t.Fatalf("footer length = %d of %d bytes", footer, len(file))
+	}
+	if meta := file[len(file)-8-footer : len(file)-8]; !bytes.Contains(meta, []byte("reasoning_tokens")) {
+		t.Fatalf("footer lacks the schema: %q", meta)
+	}
+	checkParquetRoundTrip(t, file, all)
+}
+
+// parquetRow mirrors the columns WriteRecordsParquet writes.
+type parquetRow struct {
+	Timestamp       int64   `parquet:"timestamp"`
+	API             string  `parquet:"api"`
+	Model           string  `parquet:"model"`
+	Source          string  `parquet:"source"`
+	AuthIndex       string  `parquet:"auth_index"`
+	InputTokens     int64   `parquet:"input_tokens"`
+	OutputTokens    int64   `parquet:"output_tokens"`
+	ReasoningTokens int64   `parquet:"reasoning_tokens"`
+	CachedTokens    int64   `parquet:"cached_tokens"`
+	TotalTokens     int64   `parquet:"total_tokens"`
+	Failed          bool    `parquet:"failed"`
+	Cost            float64 `parquet:"cost"`
+}
+
+// checkParquetRoundTrip reads file back with an independent Parquet reader and compares
+// the schema, the row group metadata and every value with records.
+func checkParquetRoundTrip(t *testing.T, file []byte, records []UsageRecord) {
+	t.Helper()
+	f, err := parquet.OpenFile(bytes.NewReader(file), int64(len(file)))
+	if err != nil {
+		t.Fatalf("open parquet: %v", err)
+	}
+	if f.NumRows() != int64(len(records)) {
+		t.Fatalf("num rows = %d, want %d", f.NumRows(), len(records))
+	}
+	wantColumns := []string{"timestamp", "api", "model", "source", "auth_index", "input_tokens", "output_tokens",
+		"reasoning_tokens", "cached_tokens", "total_tokens", "failed", "cost"}
+	fields := f.Schema().Fields()
+	if len(fields) != len(wantColumns) {
+		t.Fatalf("schema has %d columns, want %d", len(fields), len(wantColumns))
+	}
+	for i, field := range fields {
+		if field.Name() != wantColumns[i] || !field.Required() {
+			t.Fatalf("column %d = %s (required %v), want required %s", i, field.Name(), field.Required(), wantColumns[i])
+		}
+	}
+	if kind := fields[0].Type().LogicalType(); kind == nil || kind.Timestamp == nil {
+		t.Fatalf("timestamp logical type = %v", kind)
+	}
+	if groups := f.RowGroups(); len(groups) != 1 || groups[0].NumRows() != int64(len(records)) {
+		t.Fatalf("row groups = %d", len(groups))
+	}
+	for i, chunk := range f.Metadata().RowGroups[0].Columns {
+		if chunk.MetaData.DataPageOffset <= 0 || chunk.MetaData.DataPageOffset >= int64(len(file)) || chunk.MetaData.NumValues != int64(len(records)) {
+			t.Fatalf("column chunk %d metadata = %+v", i, chunk.MetaData)
+		}
+	}
+
+	rows := make([]parquetRow, len(records)+1)
+	n, err := parquet.NewGenericReader[parquetRow](f).Read(rows)
+	if err != nil && !errors.Is(err, io.EOF) {
+		t.Fatalf("read rows: %v", err)
+	}
+	if n != len(records) {
+		t.Fatalf("read %d rows, want %d", n, len(records))
+	}
+	for i, r := range records {
+		want := parquetRow{
+			Timestamp: r.Timestamp.UnixMilli(), API: r.API, Model: r.Model, Source: r.Source, AuthIndex: r.AuthIndex,
+			InputTokens: r.InputTokens, OutputTokens: r.OutputTokens, ReasoningTokens: r.ReasoningTokens,
+			CachedTokens: r.CachedTokens, TotalTokens: r.TotalTokens, Failed: r.Failed, Cost: r.Cost,
+		}
+		if rows[i] != want {
+			t.Fatalf("row %d = %+v, want %+v", i, rows[i], want)
+		}
+	}
+}
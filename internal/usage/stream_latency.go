@@ -0,0 +1,161 @@
+package usage
+
+import (
+	"context"
+	"math"
+	"sort"
+	"time"
+
+	coreusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+)
+
+const (
+	// maxFirstTokenSamples and maxInterTokenSamples bound the recent samples kept per
+	// provider and model for the percentiles; the means cover every sample.
+	maxFirstTokenSamples = 1024
+	maxInterTokenSamples = 16384
+)
+
+// StreamLatencyStats describes the token pacing of the streams of one provider and model:
+// the time to first token of each stream and the latency between its tokens.
+type StreamLatencyStats struct {
+	Streams    int64          `json:"streams"`
+	FirstToken LatencySummary `json:"first_token"`
+	InterToken LatencySummary `json:"inter_token"`
+}
+
+// LatencySummary summarises a latency distribution in milliseconds. The percentiles are
+// taken over the most recent samples.
+type LatencySummary struct {
+	Samples int64   `json:"samples"`
+	MeanMs  float64 `json:"mean_ms"`
+	P50Ms   float64 `json:"p50_ms"`
+	P90Ms   float64 `json:"p90_ms"`
+	P99Ms   float64 `json:"p99_ms"`
+	MaxMs   float64 `json:"max_ms"`
+}
+
+// streamLatency accumulates the stream timings of one provider and model.
+type streamLatency struct {
+	streams    int64
+	firstToken latencySamples
+	interToken latencySamples
+}
+
+// latencySamples keeps the count, sum and maximum of a distribution and a ring of its most
+// recent samples.
+type latencySamples struct {
+	count int64
+	sum   time.Duration
+	max   time.Duration
+	ring  []time.Duration
+	next  int
+}
+
+func (l *latencySamples) add(d time.Duration, limit int) {
+	l.count++
+	l.sum += d
+	if d > l.max {
+		l.max = d
+	}
+	if len(l.ring) < limit {
+		l.ring = append(l.ring, d)
+		return
+	}
+	l.ring[l.next] = d
+	l.next = (l.next + 1) % limit
+}
+
+func (l *latencySamples) summary() LatencySummary {
+	if l.count == 0 {
+		return LatencySummary{}
+	}
+	sorted := append([]time.Duration(nil), l.ring...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	return LatencySummary{
+		Samples: l.count,
+		MeanMs:  milliseconds(l.sum / time.Duration(l.count)),
+		P50Ms:   milliseconds(nearestRank(sorted, 50)),
+		P90Ms:   milliseconds(nearestRank(sorted, 90)),
+		P99Ms:   milliseconds(nearestRank(sorted, 99)),
+		MaxMs:   milliseconds(l.max),
+	}
+}
+
+// nearestRank returns the nearest-rank percentile p of sorted.
+func nearestRank(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	return sorted[min(max(rank, 1), len(sorted))-1]
+}
+
+func milliseconds(d time.Duration) float64 {
+	return math.Round(float64(d)/float64(time.Microsecond)) / 1000
+}
+
+// HandleStreamTiming implements coreusage.StreamTimingPlugin.
+func (p *LoggerPlugin) HandleStreamTiming(_ context.Context, timing coreusage.StreamTiming) {
+	if !statisticsEnabled.Load() || p == nil || p.stats == nil {
+		return
+	}
+	p.stats.RecordStreamTiming(timing)
+}
+
+// RecordStreamTiming adds the timing of a completed stream to its provider and model.
+// Imported snapshots do not carry these aggregates back in.
+func (s *RequestStatistics) RecordStreamTiming(timing coreusage.StreamTiming) {
+	if s == nil {
+		return
+	}
+	provider, model := timing.Provider, timing.Model
+	if provider == "" {
+		provider = "unknown"
+	}
+	if model == "" {
+		model = "unknown"
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.streamLatency == nil {
+		s.streamLatency = make(map[string]map[string]*streamLatency)
+	}
+	models, ok := s.streamLatency[provider]
+	if !ok {
+		models = make(map[string]*streamLatency)
+		s.streamLatency[provider] = models
+	}
+	stats, ok := models[model]
+	if !ok {
+		stats = &streamLatency{}
+		models[model] = stats
+	}
+	stats.streams++
+	stats.firstToken.add(timing.FirstToken, maxFirstTokenSamples)
+	for _, gap := range timing.Gaps {
+		stats.interToken.add(gap, maxInterTokenSamples)
+	}
+}
+
+// snapshotStreamLatency summarises the stream timings. The caller holds s.mu.
+func (s *RequestStatistics) snapshotStreamLatency() map[string]map[string]StreamLatencyStats {
+	if len(s.streamLatency) == 0 {
+		return nil
+	}
+	out := make(map[string]map[string]StreamLatencyStats, len(s.streamLatency))
+	for provider, models := range s.streamLatency {
+		providerStats := make(map[string]StreamLatencyStats, len(models))
+		for model, stats := range models {
+			providerStats[model] = StreamLatencyStats{
+				Streams:    stats.streams,
+				FirstToken: stats.firstToken.summary(),
+				InterToken: stats.interToken.summary(),
+			}
+		}
+		out[provider] = providerStats
+	}
+	return out
+}
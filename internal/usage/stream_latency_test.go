@@ -0,0 +1,32 @@
+package usage
+
+import (
+	"testing"
+	"time"
+
+	coreusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+)
+
+func TestStreamLatencyPerProviderModel(t *testing.T) {
+	stats := NewRequestStatistics()
+	stats.RecordStreamTiming(coreusage.StreamTiming{
+		Provider:   "claude",
+		Model:      "claude-sonnet-4",
+		FirstToken: 400 * time.Millisecond,
+		Gaps:       []time.Duration{10 * time.Millisecond, 20 * time.Millisecond, 90 * time.Millisecond},
+	})
+	stats.RecordStreamTiming(coreusage.StreamTiming{Provider: "claude", Model: "claude-sonnet-4", FirstToken: 800 * time.Millisecond})
+	stats.RecordStreamTiming(coreusage.StreamTiming{FirstToken: time.Second})
+
+	latency := stats.Snapshot().StreamLatency
+	got := latency["claude"]["claude-sonnet-4"]
+	if got.Streams != 2 || got.FirstToken.Samples != 2 || got.FirstToken.MeanMs != 600 || got.FirstToken.MaxMs != 800 {
+		t.Fatalf("first token = %+v", got)
+	}
+	if got.InterToken.Samples != 3 || got.InterToken.P50Ms != 20 || got.InterToken.P99Ms != 90 || got.InterToken.MeanMs != 40 {
+		t.Fatalf("inter token = %+v", got.InterToken)
+	}
+	if latency["unknown"]["unknown"].Streams != 1 {
+		t.Fatalf("missing unknown bucket: %v", latency)
+	}
+}
@@ -0,0 +1,65 @@
+package usage
+
+import coreusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+
+// ThinkingStats compares the requests of one model asking for a thinking level with what the
+// provider reported. Honored counts the requests whose response matched the setting:
+// reasoning tokens for thinking levels, none for "none". Claude reports no reasoning tokens,
+// so its executor estimates them from the thinking blocks of the response.
+type ThinkingStats struct {
+	Requests           int64 `json:"requests"`
+	Honored            int64 `json:"honored"`
+	ReasoningTokens    int64 `json:"reasoning_tokens"`
+	MaxReasoningTokens int64 `json:"max_reasoning_tokens"`
+	// RequestedBudget sums the requested (or nominal) budgets; auto requests add nothing.
+	RequestedBudget int64 `json:"requested_budget"`
+}
+
+// recordThinking adds a successful request with thinking setting request to the per-model,
+// per-level aggregates. The caller holds s.mu. Imported snapshots do not carry these
+// aggregates back in.
+func (s *RequestStatistics) recordThinking(model string, request coreusage.ThinkingRequest, tokens TokenStats) {
+	if request.Level == "" {
+		return
+	}
+	if s.thinking == nil {
+		s.thinking = make(map[string]map[string]*ThinkingStats)
+	}
+	levels, ok := s.thinking[model]
+	if !ok {
+		levels = make(map[string]*ThinkingStats)
+		s.thinking[model] = levels
+	}
+	stats, ok := levels[request.Level]
+	if !ok {
+		stats = &ThinkingStats{}
+		levels[request.Level] = stats
+	}
+	stats.Requests++
+	if (request.Level == "none") == (tokens.ReasoningTokens == 0) {
+		stats.Honored++
+	}
+	stats.ReasoningTokens += tokens.ReasoningTokens
+	if tokens.ReasoningTokens > stats.MaxReasoningTokens {
+		stats.MaxReasoningTokens = tokens.ReasoningTokens
+	}
+	if request.Budget > 0 {
+		stats.RequestedBudget += request.Budget
+	}
+}
+
+// snapshotThinking copies the thinking aggregates. The caller holds s.mu.
+func (s *RequestStatistics) snapshotThinking() map[string]map[string]ThinkingStats {
+	if len(s.thinking) == 0 {
+		return nil
+	}
+	out := make(map[string]map[string]ThinkingStats, len(s.thinking))
+	for model, levels := range s.thinking {
+		modelStats := make(map[string]ThinkingStats, len(levels))
+		for level, stats := range levels {
+			modelStats[level] = *stats
+		}
+		out[model] = modelStats
+	}
+	return out
+}
@@ -0,0 +1,37 @@
+package usage
+
+import (
+	"context"
+	"testing"
+
+	coreusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+)
+
+func TestThinkingStatsCompareRequestedLevels(t *testing.T) {
+	stats := NewRequestStatistics()
+	record := func(level string, budget, reasoning int64, failed bool) {
+		ctx := coreusage.WithThinkingRequest(context.Background(), coreusage.ThinkingRequest{Level: level, Budget: budget})
+		stats.Record(ctx, coreusage.Record{
+			Model:  "claude-sonnet-4",
+			Failed: failed,
+			Detail: coreusage.Detail{InputTokens: 10, OutputTokens: 5, ReasoningTokens: reasoning},
+		})
+	}
+	record("high", 24576, 3000, false)
+	record("high", 24576, 0, false)
+	record("high", 24576, 0, true)
+	record("none", 0, 0, false)
+	stats.Record(context.Background(), coreusage.Record{Model: "claude-sonnet-4", Detail: coreusage.Detail{InputTokens: 1}})
+
+	thinking := stats.Snapshot().Thinking["claude-sonnet-4"]
+	want := ThinkingStats{Requests: 2, Honored: 1, ReasoningTokens: 3000, MaxReasoningTokens: 3000, RequestedBudget: 49152}
+	if got := thinking["high"]; got != want {
+		t.Fatalf("high = %+v, want %+v", got, want)
+	}
+	if got := thinking["none"]; got.Requests != 1 || got.Honored != 1 {
+		t.Fatalf("none = %+v", got)
+	}
+	if len(thinking) != 2 {
+		t.Fatalf("levels = %v", thinking)
+	}
+}
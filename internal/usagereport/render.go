@@ -0,0 +1,150 @@
+package usagereport
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"sort"
+	"strconv"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+// Dimensions of a report, in rendering order.
+var dimensions = []struct {
+	Name  string
+	Title string
+	rows  func(*Report) map[string]*Totals
+}{
+	{"provider", "Providers", func(r *Report) map[string]*Totals { return r.ByProvider }},
+	{"project", "Projects", func(r *Report) map[string]*Totals { return r.ByProject }},
+	{"key", "API keys", func(r *Report) map[string]*Totals { return r.ByKey }},
+}
+
+type row struct {
+	Name string
+	Totals
+}
+
+// sortedRows orders m by cost and then requests, descending.
+func sortedRows(m map[string]*Totals) []row {
+	rows := make([]row, 0, len(m))
+	for name, t := range m {
+		rows = append(rows, row{Name: name, Totals: *t})
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		if rows[i].Cost != rows[j].Cost {
+			return rows[i].Cost > rows[j].Cost
+		}
+		if rows[i].Requests != rows[j].Requests {
+			return rows[i].Requests > rows[j].Requests
+		}
+		return rows[i].Name < rows[j].Name
+	})
+	return rows
+}
+
+// ContentType returns the MIME type of a report format.
+func ContentType(format string) string {
+	switch format {
+	case config.UsageReportCSV:
+		return "text/csv; charset=utf-8"
+	case config.UsageReportHTML:
+		return "text/html; charset=utf-8"
+	default:
+		return "application/json"
+	}
+}
+
+// Render encodes report as json, csv or html.
+func Render(report *Report, format string) ([]byte, error) {
+	switch format {
+	case config.UsageReportJSON:
+		return json.MarshalIndent(report, "", "  ")
+	case config.UsageReportCSV:
+		return RenderCSV(report)
+	case config.UsageReportHTML:
+		return RenderHTML(report)
+	default:
+		return nil, fmt.Errorf("unsupported usage report format %q", format)
+	}
+}
+
+// RenderCSV writes one row per dimension entry plus a total row.
+func RenderCSV(report *Report) ([]byte, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	_ = w.Write([]string{"month", "dimension", "name", "requests", "failed", "input_tokens", "output_tokens", "reasoning_tokens", "cached_tokens", "total_tokens", "cost"})
+	record := func(dimension, name string, t Totals) {
+		_ = w.Write([]string{
+			report.Month, dimension, name,
+			strconv.FormatInt(t.Requests, 10),
+			strconv.FormatInt(t.Failed, 10),
+			strconv.FormatInt(t.InputTokens, 10),
+			strconv.FormatInt(t.OutputTokens, 10),
+			strconv.FormatInt(t.ReasoningTokens, 10),
+			strconv.FormatInt(t.CachedTokens, 10),
+			strconv.FormatInt(t.TotalTokens, 10),
+			strconv.FormatFloat(t.Cost, 'f', 6, 64),
+		})
+	}
+	record("total", "", report.Totals)
+	for _, d := range dimensions {
+		for _, r := range sortedRows(d.rows(report)) {
+			record(d.Name, r.Name, r.Totals)
+		}
+	}
+	w.Flush()
+	return buf.Bytes(), w.Error()
+}
+
+var htmlTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"cost": func(v float64) string { return strconv.FormatFloat(v, 'f', 2, 64) },
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Usage report {{.Month}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; margin-bottom: 2em; }
+th, td { border: 1px solid #ccc; padding: 4px 8px; text-align: right; }
+th:first-child, td:first-child { text-align: left; }
+</style>
+</head>
+<body>
+<h1>Usage report {{.Month}}</h1>
+<p>Generated {{.GeneratedAt.Format "2006-01-02 15:04 MST"}}{{if not .Final}} (month in progress){{end}}.
+{{.Totals.Requests}} requests ({{.Totals.Failed}} failed), {{.Totals.TotalTokens}} tokens, cost {{cost .Totals.Cost}}.</p>
+{{range .Sections}}
+<h2>{{.Title}}</h2>
+<table>
+<tr><th>Name</th><th>Requests</th><th>Failed</th><th>Input</th><th>Output</th><th>Reasoning</th><th>Cached</th><th>Total</th><th>Cost</th></tr>
+{{range .Rows}}<tr><td>{{.Name}}</td><td>{{.Requests}}</td><td>{{.Failed}}</td><td>{{.InputTokens}}</td><td>{{.OutputTokens}}</td><td>{{.ReasoningTokens}}</td><td>{{.CachedTokens}}</td><td>{{.TotalTokens}}</td><td>{{cost .Cost}}</td></tr>
+{{end}}</table>
+{{end}}
+</body>
+</html>
+`))
+
+// RenderHTML renders report as a standalone HTML page.
+func RenderHTML(report *Report) ([]byte, error) {
+	type section struct {
+		Title string
+		Rows  []row
+	}
+	data := struct {
+		*Report
+		Sections []section
+	}{Report: report}
+	for _, d := range dimensions {
+		data.Sections = append(data.Sections, section{Title: d.Title, Rows: sortedRows(d.rows(report))})
+	}
+	var buf bytes.Buffer
+	if err := htmlTemplate.Execute(&buf, data); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
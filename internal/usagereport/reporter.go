@@ -0,0 +1,470 @@
+// Package usagereport accumulates requests, tokens and costs per client API key, project
+// and provider over each calendar month (UTC). When a month ends its report is written to
+// disk as JSON, CSV and/or HTML and optionally emailed; the running month is checkpointed
+// so restarts do not lose it.
+package usagereport
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/pricing"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/project"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	coreusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// checkpointInterval is how often the running month is saved and rollover checked.
+	checkpointInterval = time.Minute
+	// checkpointFile holds the running month between restarts.
+	checkpointFile = "current.json"
+	// unassigned names requests without an API key or project.
+	unassigned = "(none)"
+)
+
+// ErrReportNotFound is returned when no report exists for the requested month.
+var ErrReportNotFound = errors.New("usage report not found")
+
+var monthPattern = regexp.MustCompile(`^\d{4}-\d{2}$`)
+
+var defaultReporter = NewReporter()
+
+func init() {
+	coreusage.RegisterPlugin(defaultReporter)
+}
+
+// Default returns the process-wide reporter fed by the usage pipeline.
+func Default() *Reporter { return defaultReporter }
+
+// Totals aggregates the usage of one key, project or provider.
+type Totals struct {
+	Requests        int64   `json:"requests"`
+	Failed          int64   `json:"failed"`
+	InputTokens     int64   `json:"input_tokens"`
+	OutputTokens    int64   `json:"output_tokens"`
+	ReasoningTokens int64   `json:"reasoning_tokens"`
+	CachedTokens    int64   `json:"cached_tokens"`
+	TotalTokens     int64   `json:"total_tokens"`
+	Cost            float64 `json:"cost"`
+}
+
+func (t *Totals) add(o Totals) {
+	t.Requests += o.Requests
+	t.Failed += o.Failed
+	t.InputTokens += o.InputTokens
+	t.OutputTokens += o.OutputTokens
+	t.ReasoningTokens += o.ReasoningTokens
+	t.CachedTokens += o.CachedTokens
+	t.TotalTokens += o.TotalTokens
+	t.Cost += o.Cost
+}
+
+// Report is the usage of one month. API keys are masked.
+type Report struct {
+	Month       string             `json:"month"`
+	GeneratedAt time.Time          `json:"generated_at"`
+	Final       bool               `json:"final"`
+	Totals      Totals             `json:"totals"`
+	ByKey       map[string]*Totals `json:"by_key"`
+	ByProject   map[string]*Totals `json:"by_project"`
+	ByProvider  map[string]*Totals `json:"by_provider"`
+}
+
+func newReport(month string) *Report {
+	return &Report{
+		Month:      month,
+		ByKey:      make(map[string]*Totals),
+		ByProject:  make(map[string]*Totals),
+		ByProvider: make(map[string]*Totals),
+	}
+}
+
+func (r *Report) clone() *Report {
+	out := *r
+	out.ByKey, out.ByProject, out.ByProvider = cloneTotals(r.ByKey), cloneTotals(r.ByProject), cloneTotals(r.ByProvider)
+	return &out
+}
+
+func cloneTotals(in map[string]*Totals) map[string]*Totals {
+	out := make(map[string]*Totals, len(in))
+	for name, t := range in {
+		copied := *t
+		out[name] = &copied
+	}
+	return out
+}
+
+func addTo(m map[string]*Totals, name string, t Totals) {
+	if name == "" {
+		name = unassigned
+	}
+	if m[name] == nil {
+		m[name] = &Totals{}
+	}
+	m[name].add(t)
+}
+
+// Info lists the stored files of one month.
+type Info struct {
+	Month   string   `json:"month"`
+	Formats []string `json:"formats"`
+}
+
+// Reporter implements coreusage.Plugin.
+type Reporter struct {
+	mu       sync.Mutex
+	cfg      config.UsageReportConfig
+	dir      string
+	current  *Report
+	finished *Report // an ended month whose report is not written yet
+	dirty    bool
+	cancel   context.CancelFunc
+	nowFunc  func() time.Time
+	sendMail func(config.UsageReportEmail, string, []byte) error
+}
+
+// NewReporter constructs a disabled reporter.
+func NewReporter() *Reporter {
+	return &Reporter{nowFunc: time.Now, sendMail: sendMail}
+}
+
+// Configure applies cfg and starts or stops the checkpoint loop. fallbackDir is used when
+// cfg.Dir is empty. A checkpoint left by an earlier run is resumed, or finalized when its
+// month has ended.
+func (r *Reporter) Configure(cfg config.UsageReportConfig, fallbackDir string) {
+	if r == nil {
+		return
+	}
+	dir := cfg.Dir
+	if dir == "" {
+		dir = fallbackDir
+	}
+	if dir != "" && !filepath.IsAbs(dir) {
+		if abs, err := filepath.Abs(dir); err == nil {
+			dir = abs
+		}
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	enabled := cfg.Enable && dir != ""
+	if r.dir != dir {
+		// Keep what was collected so far unless the new directory holds a checkpoint.
+		r.dir = dir
+		if enabled {
+			if saved, err := r.readLocked(checkpointFile); err == nil && (r.current == nil || saved.Month != r.current.Month) {
+				r.current = saved
+			}
+		}
+	}
+	r.cfg = cfg
+	if enabled {
+		r.rolloverLocked(r.nowFunc())
+	}
+	switch {
+	case enabled && r.cancel == nil:
+		ctx, cancel := context.WithCancel(context.Background())
+		r.cancel = cancel
+		go r.run(ctx)
+	case !enabled && r.cancel != nil:
+		r.cancel()
+		r.cancel = nil
+	}
+}
+
+// Stop saves the running month and halts the checkpoint loop.
+func (r *Reporter) Stop() {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.cancel != nil {
+		r.cancel()
+		r.cancel = nil
+		r.rolloverLocked(r.nowFunc())
+		r.checkpointLocked()
+	}
+}
+
+// Enabled reports whether reports are being collected.
+func (r *Reporter) Enabled() bool {
+	if r == nil {
+		return false
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.enabledLocked()
+}
+
+func (r *Reporter) enabledLocked() bool {
+	return r.cfg.Enable && r.dir != ""
+}
+
+// HandleUsage implements coreusage.Plugin.
+func (r *Reporter) HandleUsage(ctx context.Context, record coreusage.Record) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.enabledLocked() {
+		return
+	}
+	at := record.RequestedAt
+	if at.IsZero() {
+		at = r.nowFunc()
+	}
+	r.rolloverLocked(at)
+
+	d := record.Detail
+	t := Totals{
+		Requests:        1,
+		InputTokens:     d.InputTokens,
+		OutputTokens:    d.OutputTokens,
+		ReasoningTokens: d.ReasoningTokens,
+		CachedTokens:    d.CachedTokens,
+		TotalTokens:     d.TotalTokens,
+	}
+	if t.TotalTokens == 0 {
+		t.TotalTokens = d.InputTokens + d.OutputTokens + d.ReasoningTokens
+	}
+	if record.Failed {
+		t.Failed = 1
+	}
+	t.Cost, _ = pricing.Default().Cost(record.Model, pricing.UsageOf(d))
+	projectName, _ := project.GetRegistry().Resolve(record.APIKey)
+	provider := strings.ToLower(strings.TrimSpace(record.Provider))
+
+	report := r.current
+	report.Totals.add(t)
+	addTo(report.ByKey, util.HideAPIKey(record.APIKey), t)
+	addTo(report.ByProject, projectName, t)
+	addTo(report.ByProvider, provider, t)
+	r.dirty = true
+}
+
+// Current returns a copy of the running month's report.
+func (r *Reporter) Current() *Report {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.current == nil {
+		report := newReport(monthOf(r.nowFunc()))
+		report.GeneratedAt = r.nowFunc().UTC()
+		return report
+	}
+	report := r.current.clone()
+	report.GeneratedAt = r.nowFunc().UTC()
+	return report
+}
+
+// List returns the finished reports, newest first.
+func (r *Reporter) List() ([]Info, error) {
+	if r == nil {
+		return nil, nil
+	}
+	r.mu.Lock()
+	dir := r.dir
+	r.mu.Unlock()
+	if dir == "" {
+		return nil, nil
+	}
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	byMonth := make(map[string]*Info)
+	for _, file := range files {
+		if file.IsDir() {
+			continue
+		}
+		ext := filepath.Ext(file.Name())
+		month := strings.TrimSuffix(file.Name(), ext)
+		format := strings.TrimPrefix(ext, ".")
+		if !monthPattern.MatchString(month) || !validFormat(format) {
+			continue
+		}
+		if byMonth[month] == nil {
+			byMonth[month] = &Info{Month: month}
+		}
+		byMonth[month].Formats = append(byMonth[month].Formats, format)
+	}
+	out := make([]Info, 0, len(byMonth))
+	for _, info := range byMonth {
+		sort.Strings(info.Formats)
+		out = append(out, *info)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Month > out[j].Month })
+	return out, nil
+}
+
+// Load returns a finished month's report.
+func (r *Reporter) Load(month string) (*Report, error) {
+	if r == nil || !monthPattern.MatchString(month) {
+		return nil, ErrReportNotFound
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.readLocked(month + ".json")
+}
+
+func (r *Reporter) run(ctx context.Context) {
+	ticker := time.NewTicker(checkpointInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			r.mu.Lock()
+			r.rolloverLocked(r.nowFunc())
+			r.checkpointLocked()
+			r.mu.Unlock()
+		}
+	}
+}
+
+// rolloverLocked finalizes the running report once at falls into a later month. A
+// finished month whose report cannot be written is kept and retried on the next call.
+func (r *Reporter) rolloverLocked(at time.Time) {
+	month := monthOf(at)
+	if r.current == nil {
+		r.current = newReport(month)
+	} else if r.current.Month < month && r.finished == nil {
+		r.finished = r.current
+		r.finished.Final = true
+		r.current = newReport(month)
+		r.dirty = true
+	}
+	if r.finished == nil {
+		return
+	}
+	finished := r.finished
+	finished.GeneratedAt = r.nowFunc().UTC()
+	if err := r.writeLocked(finished); err != nil {
+		log.Errorf("usage-reports: failed to write %s report: %v", finished.Month, err)
+		return
+	}
+	r.finished = nil
+	log.Infof("usage-reports: wrote report for %s", finished.Month)
+	r.checkpointLocked()
+	if email := r.cfg.Email; email.Enabled() {
+		html, err := RenderHTML(finished)
+		if err != nil {
+			log.Errorf("usage-reports: failed to render %s report: %v", finished.Month, err)
+			return
+		}
+		send := r.sendMail
+		go func() {
+			if errSend := send(email, "Usage report "+finished.Month, html); errSend != nil {
+				log.Errorf("usage-reports: failed to email %s report: %v", finished.Month, errSend)
+			}
+		}()
+	}
+}
+
+// writeLocked stores report in every configured format.
+func (r *Reporter) writeLocked(report *Report) error {
+	if err := os.MkdirAll(r.dir, 0o700); err != nil {
+		return err
+	}
+	formats := r.cfg.Formats
+	if len(formats) == 0 {
+		formats = []string{config.UsageReportJSON, config.UsageReportCSV, config.UsageReportHTML}
+	}
+	if !containsFormat(formats, config.UsageReportJSON) {
+		formats = append([]string{config.UsageReportJSON}, formats...)
+	}
+	for _, format := range formats {
+		data, err := Render(report, format)
+		if err != nil {
+			return err
+		}
+		if err = writeFile(filepath.Join(r.dir, report.Month+"."+format), data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *Reporter) checkpointLocked() {
+	if !r.dirty || r.current == nil || r.dir == "" {
+		return
+	}
+	data, err := json.Marshal(r.current)
+	if err == nil {
+		if err = os.MkdirAll(r.dir, 0o700); err == nil {
+			err = writeFile(filepath.Join(r.dir, checkpointFile), data)
+		}
+	}
+	if err != nil {
+		log.Warnf("usage-reports: failed to save running month: %v", err)
+		return
+	}
+	r.dirty = false
+}
+
+func (r *Reporter) readLocked(name string) (*Report, error) {
+	if r.dir == "" {
+		return nil, ErrReportNotFound
+	}
+	data, err := os.ReadFile(filepath.Join(r.dir, name))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, ErrReportNotFound
+		}
+		return nil, err
+	}
+	report := newReport("")
+	if err = json.Unmarshal(data, report); err != nil {
+		return nil, err
+	}
+	for _, m := range []*map[string]*Totals{&report.ByKey, &report.ByProject, &report.ByProvider} {
+		if *m == nil {
+			*m = make(map[string]*Totals)
+		}
+	}
+	return report, nil
+}
+
+// writeFile replaces path atomically.
+func writeFile(path string, data []byte) error {
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func monthOf(t time.Time) string {
+	return t.UTC().Format("2006-01")
+}
+
+func validFormat(format string) bool {
+	return format == config.UsageReportJSON || format == config.UsageReportCSV || format == config.UsageReportHTML
+}
+
+func containsFormat(formats []string, format string) bool {
+	for _, f := range formats {
+		if f == format {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,144 @@
+package usagereport
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	coreusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+)
+
+func newTestReporter(t *testing.T, now *time.Time, cfg config.UsageReportConfig) (*Reporter, string) {
+	t.Helper()
+	dir := t.TempDir()
+	r := NewReporter()
+	r.nowFunc = func() time.Time { return *now }
+	cfg.Enable = true
+	cfg.Dir = dir
+	r.Configure(cfg, "")
+	t.Cleanup(r.Stop)
+	return r, dir
+}
+
+func TestReporterAggregatesAndRollsOver(t *testing.T) {
+	now := time.Date(2026, 9, 30, 23, 0, 0, 0, time.UTC)
+	sent := make(chan string, 1)
+	r, dir := newTestReporter(t, &now, config.UsageReportConfig{
+		Formats: []string{config.UsageReportCSV},
+		Email:   config.UsageReportEmail{SMTPHost: "smtp.test", From: "a@test", To: []string{"b@test"}},
+	})
+	r.sendMail = func(_ config.UsageReportEmail, subject string, _ []byte) error {
+		sent <- subject
+		return nil
+	}
+
+	record := coreusage.Record{Provider: "Claude", Model: "claude-sonnet-4", APIKey: "sk-abcdefgh12345678", RequestedAt: now}
+	record.Detail.InputTokens, record.Detail.OutputTokens = 1000, 1000
+	r.HandleUsage(context.Background(), record)
+	record.Failed = true
+	r.HandleUsage(context.Background(), record)
+
+	current := r.Current()
+	if current.Month != "2026-09" || current.Totals.Requests != 2 || current.Totals.Failed != 1 || current.Totals.TotalTokens != 4000 {
+		t.Fatalf("current = %+v", current.Totals)
+	}
+	if current.Totals.Cost <= 0 {
+		t.Fatalf("expected bundled price to produce a cost, got %v", current.Totals.Cost)
+	}
+	if current.ByKey["sk-a...5678"] == nil || current.ByProvider["claude"] == nil || current.ByProject[unassigned] == nil {
+		t.Fatalf("unexpected breakdown: %+v %+v %+v", current.ByKey, current.ByProvider, current.ByProject)
+	}
+
+	now = now.Add(2 * time.Hour)
+	record.RequestedAt, record.Failed = now, false
+	r.HandleUsage(context.Background(), record)
+
+	for _, name := range []string{"2026-09.json", "2026-09.csv"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Fatalf("expected %s: %v", name, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "2026-09.html")); !os.IsNotExist(err) {
+		t.Fatalf("html was not configured, stat err = %v", err)
+	}
+	select {
+	case subject := <-sent:
+		if subject != "Usage report 2026-09" {
+			t.Fatalf("subject = %q", subject)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("report was not emailed")
+	}
+
+	finished, err := r.Load("2026-09")
+	if err != nil || !finished.Final || finished.Totals.Requests != 2 {
+		t.Fatalf("Load = %+v, %v", finished, err)
+	}
+	if got := r.Current(); got.Month != "2026-10" || got.Totals.Requests != 1 {
+		t.Fatalf("new month = %+v", got)
+	}
+	reports, err := r.List()
+	if err != nil || len(reports) != 1 || reports[0].Month != "2026-09" || strings.Join(reports[0].Formats, ",") != "csv,json" {
+		t.Fatalf("List = %+v, %v", reports, err)
+	}
+	csv, err := RenderCSV(finished)
+	if err != nil || !strings.Contains(string(csv), "2026-09,key,sk-a...5678,2,1,") {
+		t.Fatalf("csv = %s, %v", csv, err)
+	}
+}
+
+func TestReporterKeepsFinishedMonthUntilWritten(t *testing.T) {
+	now := time.Date(2026, 9, 30, 23, 0, 0, 0, time.UTC)
+	r, dir := newTestReporter(t, &now, config.UsageReportConfig{})
+	r.HandleUsage(context.Background(), coreusage.Record{Provider: "gemini", RequestedAt: now})
+
+	blocked := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(blocked, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	r.mu.Lock()
+	r.dir = filepath.Join(blocked, "reports")
+	r.mu.Unlock()
+	now = now.Add(2 * time.Hour)
+	r.HandleUsage(context.Background(), coreusage.Record{Provider: "gemini", RequestedAt: now})
+
+	r.mu.Lock()
+	r.dir = dir
+	r.rolloverLocked(now)
+	r.mu.Unlock()
+	report, err := r.Load("2026-09")
+	if err != nil || !report.Final || report.Totals.Requests != 1 {
+		t.Fatalf("Load = %+v, %v", report, err)
+	}
+	if got := r.Current(); got.Month != "2026-10" || got.Totals.Requests != 1 {
+		t.Fatalf("current = %+v", got)
+	}
+}
+
+func TestReporterResumesCheckpoint(t *testing.T) {
+	now := time.Date(2026, 10, 5, 12, 0, 0, 0, time.UTC)
+	r, dir := newTestReporter(t, &now, config.UsageReportConfig{})
+	r.HandleUsage(context.Background(), coreusage.Record{Provider: "gemini", RequestedAt: now})
+	r.Stop()
+
+	now = time.Date(2026, 11, 1, 0, 5, 0, 0, time.UTC)
+	resumed := NewReporter()
+	resumed.nowFunc = func() time.Time { return now }
+	resumed.Configure(config.UsageReportConfig{Enable: true, Dir: dir}, "")
+	defer resumed.Stop()
+
+	report, err := resumed.Load("2026-10")
+	if err != nil || report.Totals.Requests != 1 || report.ByProvider["gemini"] == nil {
+		t.Fatalf("Load = %+v, %v", report, err)
+	}
+	if _, err = os.Stat(filepath.Join(dir, "2026-10.html")); err != nil {
+		t.Fatalf("default formats should include html: %v", err)
+	}
+	if got := resumed.Current(); got.Month != "2026-11" || got.Totals.Requests != 0 {
+		t.Fatalf("current = %+v", got)
+	}
+}
@@ -0,0 +1,83 @@
+package util
+
+import (
+	"net/url"
+	"strings"
+)
+
+// ParseGeminiModelPath extracts the model and method from a Google API model path such as
+// "gemini-2.5-pro:generateContent", "publishers/google/models/gemini-2.5-pro:streamGenerateContent",
+// "projects/p/locations/us-central1/publishers/google/models/gemini-2.5-pro:generateContent" or
+// "tunedModels/my-model:generateContent". Percent-encoded paths are decoded first. Tuned
+// models and Vertex endpoints keep their collection ("tunedModels/my-model"); paths without a
+// known collection are returned whole, so prefixed models like "team/gemini-2.5-pro" survive.
+// method is empty when the path has none; ok is false when no model can be found.
+func ParseGeminiModelPath(path string) (model, method string, ok bool) {
+	path = strings.TrimSpace(path)
+	if strings.Contains(path, "%") {
+		if unescaped, err := url.PathUnescape(path); err == nil {
+			path = unescaped
+		}
+	}
+	path = strings.Trim(path, "/")
+	if i := strings.LastIndex(path, ":"); i >= 0 && i > strings.LastIndex(path, "/") {
+		path, method = path[:i], path[i+1:]
+	}
+
+	segments := make([]string, 0, 8)
+	for _, segment := range strings.Split(path, "/") {
+		if segment != "" {
+			segments = append(segments, segment)
+		}
+	}
+	// Skip the resource parents of Vertex and publisher paths.
+	for len(segments) > 2 {
+		switch segments[0] {
+		case "projects", "locations", "publishers":
+			segments = segments[2:]
+			continue
+		}
+		break
+	}
+	if len(segments) >= 2 {
+		switch segments[0] {
+		case "models":
+			segments = segments[1:]
+		case "tunedModels", "endpoints":
+			model = segments[0] + "/" + strings.Join(segments[1:], "/")
+			return model, method, true
+		}
+	}
+	model = strings.Join(segments, "/")
+	return model, method, model != ""
+}
+
+// ReplaceGeminiModelPath returns path with its model replaced by model, keeping the resource
+// parents and the method, e.g. "/publishers/google/models/a:generateContent" becomes
+// "/publishers/google/models/b:generateContent". The result is decoded; ok is false when path
+// names no model.
+func ReplaceGeminiModelPath(path, model string) (string, bool) {
+	current, method, ok := ParseGeminiModelPath(path)
+	if !ok {
+		return path, false
+	}
+	decoded := strings.TrimSpace(path)
+	if strings.Contains(decoded, "%") {
+		if unescaped, err := url.PathUnescape(decoded); err == nil {
+			decoded = unescaped
+		}
+	}
+	suffix := current
+	if method != "" {
+		suffix += ":" + method
+	}
+	trimmed := strings.TrimRight(decoded, "/")
+	if !strings.HasSuffix(trimmed, suffix) {
+		return path, false
+	}
+	replaced := strings.TrimSuffix(trimmed, suffix) + model
+	if method != "" {
+		replaced += ":" + method
+	}
+	return replaced, true
+}
@@ -0,0 +1,57 @@
+package util
+
+import "testing"
+
+func TestParseGeminiModelPath(t *testing.T) {
+	tests := []struct {
+		name   string
+		path   string
+		model  string
+		method string
+		ok     bool
+	}{
+		{"action parameter", "/gemini-2.5-pro:generateContent", "gemini-2.5-pro", "generateContent", true},
+		{"models prefix", "models/gemini-2.5-pro:countTokens", "gemini-2.5-pro", "countTokens", true},
+		{"no method", "/gemini-2.5-pro", "gemini-2.5-pro", "", true},
+		{"encoded colon", "/gemini-2.5-pro%3AstreamGenerateContent", "gemini-2.5-pro", "streamGenerateContent", true},
+		{"double encoded", "/gemini-2.5-pro%253AgenerateContent", "gemini-2.5-pro%3AgenerateContent", "", true},
+		{"encoded slash", "/publishers%2Fgoogle%2Fmodels%2Fgemini-2.5-flash:generateContent", "gemini-2.5-flash", "generateContent", true},
+		{"publisher", "/publishers/google/models/gemini-3-pro-preview:streamGenerateContent", "gemini-3-pro-preview", "streamGenerateContent", true},
+		{"other publisher", "/publishers/anthropic/models/claude-sonnet-4@20250514:rawPredict", "claude-sonnet-4@20250514", "rawPredict", true},
+		{"vertex resource", "/projects/my-project/locations/us-central1/publishers/google/models/gemini-2.5-pro:generateContent", "gemini-2.5-pro", "generateContent", true},
+		{"tuned model", "/tunedModels/my-tuned-model-123:generateContent", "tunedModels/my-tuned-model-123", "generateContent", true},
+		{"vertex endpoint", "/projects/p/locations/europe-west4/endpoints/1234567890:predict", "endpoints/1234567890", "predict", true},
+		{"prefixed model", "/team-a/gemini-2.5-pro:generateContent", "team-a/gemini-2.5-pro", "generateContent", true},
+		{"dotted version", "/models/gemini-1.5-pro-002:generateContent", "gemini-1.5-pro-002", "generateContent", true},
+		{"empty", "/", "", "", false},
+		{"method only", "/:generateContent", "", "generateContent", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			model, method, ok := ParseGeminiModelPath(tt.path)
+			if model != tt.model || method != tt.method || ok != tt.ok {
+				t.Errorf("ParseGeminiModelPath(%q) = (%q, %q, %v), want (%q, %q, %v)", tt.path, model, method, ok, tt.model, tt.method, tt.ok)
+			}
+		})
+	}
+}
+
+func TestReplaceGeminiModelPath(t *testing.T) {
+	tests := []struct {
+		path, model, want string
+		ok                bool
+	}{
+		{"/gemini-exp:generateContent", "gemini-2.5-flash", "/gemini-2.5-flash:generateContent", true},
+		{"/publishers/google/models/gemini-exp:streamGenerateContent", "gemini-2.5-pro", "/publishers/google/models/gemini-2.5-pro:streamGenerateContent", true},
+		{"/projects/p/locations/l/publishers/google/models/gemini-exp%3AcountTokens", "gemini-2.5-pro", "/projects/p/locations/l/publishers/google/models/gemini-2.5-pro:countTokens", true},
+		{"/gemini-exp", "gemini-2.5-pro", "/gemini-2.5-pro", true},
+		{"/", "gemini-2.5-pro", "/", false},
+	}
+	for _, tt := range tests {
+		got, ok := ReplaceGeminiModelPath(tt.path, tt.model)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("ReplaceGeminiModelPath(%q, %q) = (%q, %v), want (%q, %v)", tt.path, tt.model, got, ok, tt.want, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,20 @@
+package util
+
+import "testing"
+
+func TestMaskSensitivePath(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"/jetbrains/key/sk-proxy-secret-1234/api/chat", "/jetbrains/key/sk-p...1234/api/chat"},
+		{"/jetbrains/key/sk-proxy-secret-1234", "/jetbrains/key/sk-p...1234"},
+		{"/jetbrains/key/", "/jetbrains/key/"},
+		{"/v1/chat/completions", "/v1/chat/completions"},
+	}
+	for _, tt := range tests {
+		if got := MaskSensitivePath(tt.input); got != tt.expected {
+			t.Errorf("MaskSensitivePath(%q) = %q, want %q", tt.input, got, tt.expected)
+		}
+	}
+}
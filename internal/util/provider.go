@@ -253,6 +253,25 @@ func MaskSensitiveQuery(raw string) string {
 	return strings.Join(parts, "&")
 }
 
+// MaskSensitivePath masks the API key segment of paths carrying one, such as the keyed
+// routes /jetbrains/key/<api-key>/... of clients that cannot send headers.
+func MaskSensitivePath(path string) string {
+	const marker = "/key/"
+	idx := strings.Index(path, marker)
+	if idx < 0 {
+		return path
+	}
+	start := idx + len(marker)
+	end := strings.IndexByte(path[start:], '/')
+	if end < 0 {
+		end = len(path) - start
+	}
+	if end == 0 {
+		return path
+	}
+	return path[:start] + HideAPIKey(path[start:start+end]) + path[start+end:]
+}
+
 func shouldMaskQueryParam(key string) bool {
 	key = strings.ToLower(strings.TrimSpace(key))
 	if key == "" {
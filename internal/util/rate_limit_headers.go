@@ -0,0 +1,136 @@
+package util
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// UpstreamRateLimitHeadersKey is the Gin context key holding the rate limit headers of the
+// latest upstream response, forwarded to the client when the response is written.
+const UpstreamRateLimitHeadersKey = "UPSTREAM_RATE_LIMIT_HEADERS"
+
+// rateLimitBuckets pairs the OpenAI-style bucket suffix with the Anthropic bucket names it is
+// derived from, in order of preference.
+var rateLimitBuckets = []struct {
+	suffix    string
+	anthropic []string
+}{
+	{suffix: "requests", anthropic: []string{"requests"}},
+	{suffix: "tokens", anthropic: []string{"tokens", "input-tokens"}},
+}
+
+// RateLimitHeaders extracts the rate limit headers of an upstream response: Retry-After,
+// retry-after-ms and every anthropic-ratelimit-* and x-ratelimit-* header. Anthropic limits
+// are additionally normalized to the x-ratelimit-{limit,remaining,reset}-{requests,tokens}
+// form used by OpenAI, with resets expressed as durations. It returns nil when the response
+// carries none.
+func RateLimitHeaders(h http.Header) http.Header {
+	if len(h) == 0 {
+		return nil
+	}
+	out := make(http.Header)
+	for key, values := range h {
+		lower := strings.ToLower(key)
+		if lower == "retry-after" || lower == "retry-after-ms" ||
+			strings.HasPrefix(lower, "anthropic-ratelimit-") || strings.HasPrefix(lower, "x-ratelimit-") {
+			out[http.CanonicalHeaderKey(key)] = append([]string(nil), values...)
+		}
+	}
+	if len(out) == 0 {
+		return nil
+	}
+	now := time.Now()
+	for _, bucket := range rateLimitBuckets {
+		for _, name := range bucket.anthropic {
+			prefix := "Anthropic-Ratelimit-" + name + "-"
+			limit := h.Get(prefix + "limit")
+			if limit == "" {
+				continue
+			}
+			setIfAbsent(out, "X-Ratelimit-Limit-"+bucket.suffix, limit)
+			if remaining := h.Get(prefix + "remaining"); remaining != "" {
+				setIfAbsent(out, "X-Ratelimit-Remaining-"+bucket.suffix, remaining)
+			}
+			if reset, err := time.Parse(time.RFC3339, h.Get(prefix+"reset")); err == nil {
+				wait := reset.Sub(now)
+				if wait < 0 {
+					wait = 0
+				}
+				setIfAbsent(out, "X-Ratelimit-Reset-"+bucket.suffix, wait.Round(time.Second).String())
+			}
+			break
+		}
+	}
+	return out
+}
+
+func setIfAbsent(h http.Header, key, value string) {
+	if h.Get(key) == "" {
+		h.Set(key, value)
+	}
+}
+
+// RetryAfterFromHeaders derives how long to wait before retrying from an upstream response:
+// retry-after-ms, Retry-After (seconds or HTTP date), or else the reset time of an exhausted
+// anthropic-ratelimit-* or x-ratelimit-* bucket. It returns nil when the headers give no hint.
+func RetryAfterFromHeaders(h http.Header, now time.Time) *time.Duration {
+	if len(h) == 0 {
+		return nil
+	}
+	if ms, err := strconv.ParseFloat(strings.TrimSpace(h.Get("Retry-After-Ms")), 64); err == nil && ms > 0 {
+		return durationPtr(time.Duration(ms * float64(time.Millisecond)))
+	}
+	if raw := strings.TrimSpace(h.Get("Retry-After")); raw != "" {
+		if seconds, err := strconv.ParseFloat(raw, 64); err == nil && seconds > 0 {
+			return durationPtr(time.Duration(seconds * float64(time.Second)))
+		}
+		if at, err := http.ParseTime(raw); err == nil && at.After(now) {
+			return durationPtr(at.Sub(now))
+		}
+	}
+
+	var longest time.Duration
+	for _, bucket := range []string{"requests", "tokens", "input-tokens", "output-tokens"} {
+		prefix := "Anthropic-Ratelimit-" + bucket + "-"
+		if strings.TrimSpace(h.Get(prefix+"remaining")) != "0" {
+			continue
+		}
+		if reset, err := time.Parse(time.RFC3339, h.Get(prefix+"reset")); err == nil && reset.Sub(now) > longest {
+			longest = reset.Sub(now)
+		}
+	}
+	for _, bucket := range []string{"requests", "tokens"} {
+		if strings.TrimSpace(h.Get("X-Ratelimit-Remaining-"+bucket)) != "0" {
+			continue
+		}
+		if wait, ok := parseResetDuration(h.Get("X-Ratelimit-Reset-" + bucket)); ok && wait > longest {
+			longest = wait
+		}
+	}
+	if longest <= 0 {
+		return nil
+	}
+	return durationPtr(longest)
+}
+
+// parseResetDuration parses x-ratelimit-reset-* values, which are durations ("6m0s", "20ms")
+// or plain seconds.
+func parseResetDuration(raw string) (time.Duration, bool) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return 0, false
+	}
+	if d, err := time.ParseDuration(raw); err == nil {
+		return d, true
+	}
+	if seconds, err := strconv.ParseFloat(raw, 64); err == nil {
+		return time.Duration(seconds * float64(time.Second)), true
+	}
+	return 0, false
+}
+
+func durationPtr(d time.Duration) *time.Duration {
+	return &d
+}
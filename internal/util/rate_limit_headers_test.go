@@ -0,0 +1,77 @@
+package util
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestRateLimitHeadersNormalizesAnthropic(t *testing.T) {
+	reset := time.Now().Add(30 * time.Second).UTC().Format(time.RFC3339)
+	h := http.Header{}
+	h.Set("Content-Type", "application/json")
+	h.Set("Retry-After", "12")
+	h.Set("Anthropic-Ratelimit-Requests-Limit", "50")
+	h.Set("Anthropic-Ratelimit-Requests-Remaining", "0")
+	h.Set("Anthropic-Ratelimit-Requests-Reset", reset)
+	h.Set("Anthropic-Ratelimit-Input-Tokens-Limit", "20000")
+	h.Set("Anthropic-Ratelimit-Input-Tokens-Remaining", "1500")
+
+	out := RateLimitHeaders(h)
+	if out.Get("Content-Type") != "" {
+		t.Fatalf("unexpected non rate limit header forwarded: %v", out)
+	}
+	if out.Get("Retry-After") != "12" || out.Get("Anthropic-Ratelimit-Requests-Limit") != "50" {
+		t.Fatalf("original headers not kept: %v", out)
+	}
+	if out.Get("X-Ratelimit-Limit-Requests") != "50" || out.Get("X-Ratelimit-Remaining-Requests") != "0" {
+		t.Fatalf("requests bucket not normalized: %v", out)
+	}
+	if got := out.Get("X-Ratelimit-Reset-Requests"); got == "" || got == "0s" {
+		t.Fatalf("X-Ratelimit-Reset-Requests = %q", got)
+	}
+	if out.Get("X-Ratelimit-Limit-Tokens") != "20000" || out.Get("X-Ratelimit-Remaining-Tokens") != "1500" {
+		t.Fatalf("tokens bucket not normalized from input tokens: %v", out)
+	}
+	if RateLimitHeaders(http.Header{"Content-Type": {"text/plain"}}) != nil {
+		t.Fatal("expected nil for responses without rate limit headers")
+	}
+}
+
+func TestRetryAfterFromHeaders(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	cases := []struct {
+		name   string
+		header http.Header
+		want   time.Duration
+	}{
+		{"milliseconds first", http.Header{"Retry-After-Ms": {"1500"}, "Retry-After": {"9"}}, 1500 * time.Millisecond},
+		{"seconds", http.Header{"Retry-After": {"7"}}, 7 * time.Second},
+		{"http date", http.Header{"Retry-After": {now.Add(time.Minute).Format(http.TimeFormat)}}, time.Minute},
+		{"exhausted anthropic bucket", http.Header{
+			"Anthropic-Ratelimit-Tokens-Remaining": {"0"},
+			"Anthropic-Ratelimit-Tokens-Reset":     {now.Add(40 * time.Second).Format(time.RFC3339)},
+		}, 40 * time.Second},
+		{"exhausted openai bucket", http.Header{
+			"X-Ratelimit-Remaining-Requests": {"0"},
+			"X-Ratelimit-Reset-Requests":     {"6m0s"},
+			"X-Ratelimit-Remaining-Tokens":   {"100"},
+			"X-Ratelimit-Reset-Tokens":       {"20m"},
+		}, 6 * time.Minute},
+		{"no hint", http.Header{"X-Ratelimit-Remaining-Requests": {"3"}, "X-Ratelimit-Reset-Requests": {"1s"}}, 0},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := RetryAfterFromHeaders(tc.header, now)
+			if tc.want == 0 {
+				if got != nil {
+					t.Fatalf("RetryAfterFromHeaders = %v, want nil", *got)
+				}
+				return
+			}
+			if got == nil || *got != tc.want {
+				t.Fatalf("RetryAfterFromHeaders = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
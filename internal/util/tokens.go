@@ -0,0 +1,40 @@
+package util
+
+import (
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/tiktoken-go/tokenizer"
+)
+
+var (
+	tokenCodecOnce sync.Once
+	tokenCodec     tokenizer.Codec
+	tokenCodecErr  error
+)
+
+// TokenCodec returns the o200k_base tokenizer shared by every token estimate, loading it
+// on first use.
+func TokenCodec() (tokenizer.Codec, error) {
+	tokenCodecOnce.Do(func() {
+		tokenCodec, tokenCodecErr = tokenizer.Get(tokenizer.O200kBase)
+		if tokenCodecErr != nil {
+			log.Warnf("load o200k_base tokenizer: %v", tokenCodecErr)
+		}
+	})
+	return tokenCodec, tokenCodecErr
+}
+
+// CountTokens estimates the tokens of text with TokenCodec, or at roughly four bytes per
+// token when the tokenizer is unavailable.
+func CountTokens(text string) int64 {
+	if text == "" {
+		return 0
+	}
+	if codec, err := TokenCodec(); err == nil {
+		if count, errCount := codec.Count(text); errCount == nil {
+			return int64(count)
+		}
+	}
+	return int64(len(text)+3) / 4
+}
@@ -0,0 +1,5 @@
+package util
+
+// UsageRecordKey is the Gin context key holding the usage record of the latest successful
+// upstream attempt, so response headers can report the cost and route of the request.
+const UsageRecordKey = "UPSTREAM_USAGE_RECORD"
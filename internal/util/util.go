@@ -55,14 +55,15 @@ func SanitizeFunctionName(name string) string {
 }
 
 // SetLogLevel configures the logrus log level based on the configuration.
-// It sets the log level to DebugLevel if debug mode is enabled, otherwise to InfoLevel.
+// It sets the log level to DebugLevel if debug mode is enabled, otherwise to InfoLevel,
+// raised to the most verbose per-component level so those entries reach the formatter.
 func SetLogLevel(cfg *config.Config) {
 	currentLevel := log.GetLevel()
-	var newLevel log.Level
-	if cfg.Debug {
-		newLevel = log.DebugLevel
-	} else {
-		newLevel = log.InfoLevel
+	newLevel, components := cfg.LogLevels()
+	for _, level := range components {
+		if level > newLevel {
+			newLevel = level
+		}
 	}
 
 	if currentLevel != newLevel {
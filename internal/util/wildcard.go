@@ -0,0 +1,26 @@
+package util
+
+import "strings"
+
+// MatchWildcard reports whether value matches pattern case-insensitively, where '*' matches
+// any run of characters. Model lists, routing rules and mappings all share this syntax.
+func MatchWildcard(pattern, value string) bool {
+	pattern = strings.ToLower(pattern)
+	value = strings.ToLower(value)
+	parts := strings.Split(pattern, "*")
+	if len(parts) == 1 {
+		return pattern == value
+	}
+	if !strings.HasPrefix(value, parts[0]) {
+		return false
+	}
+	value = value[len(parts[0]):]
+	for _, segment := range parts[1 : len(parts)-1] {
+		idx := strings.Index(value, segment)
+		if idx < 0 {
+			return false
+		}
+		value = value[idx+len(segment):]
+	}
+	return strings.HasSuffix(value, parts[len(parts)-1])
+}
@@ -0,0 +1,23 @@
+package util
+
+import "testing"
+
+func TestMatchWildcard(t *testing.T) {
+	for _, tc := range []struct {
+		pattern, value string
+		want           bool
+	}{
+		{"claude-sonnet-4", "Claude-Sonnet-4", true},
+		{"claude-*", "claude-opus-4", true},
+		{"*-mini", "gpt-5-mini", true},
+		{"gpt-*-mini", "gpt-5-codex-mini", true},
+		{"gpt-*-mini", "gpt-5", false},
+		{"*", "", true},
+		{"a*a", "a", false},
+		{"", "x", false},
+	} {
+		if got := MatchWildcard(tc.pattern, tc.value); got != tc.want {
+			t.Errorf("MatchWildcard(%q, %q) = %v, want %v", tc.pattern, tc.value, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,330 @@
+// Package vhost serves several logical proxy configurations from one process. A virtual
+// host is selected by Host header or URL prefix and brings its own client API keys, model
+// mappings and the provider credentials it may use.
+package vhost
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	configaccess "github.com/router-for-me/CLIProxyAPI/v6/internal/access/config_access"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	sdkaccess "github.com/router-for-me/CLIProxyAPI/v6/sdk/access"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	sdkconfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	log "github.com/sirupsen/logrus"
+)
+
+// ContextKey is the gin context key holding the resolved virtual host name.
+const ContextKey = "vhost"
+
+// ErrorCodeCredentialReserved is the coreauth.Error code returned when a credential is
+// reserved for another virtual host.
+const ErrorCodeCredentialReserved = "vhost_credential_reserved"
+
+type vhostContextKey struct{}
+
+// WithName returns a context carrying the virtual host name.
+func WithName(ctx context.Context, name string) context.Context {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return context.WithValue(ctx, vhostContextKey{}, name)
+}
+
+// FromContext returns the virtual host name attached to ctx, either directly or via the
+// gin context stored under "gin" by the API handlers.
+func FromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	if name, ok := ctx.Value(vhostContextKey{}).(string); ok && name != "" {
+		return name
+	}
+	if ginCtx, ok := ctx.Value("gin").(*gin.Context); ok && ginCtx != nil {
+		return ginCtx.GetString(ContextKey)
+	}
+	return ""
+}
+
+type regexMapping struct {
+	re *regexp.Regexp
+	to string
+}
+
+type host struct {
+	cfg         config.VirtualHost
+	access      *sdkaccess.Manager
+	exact       map[string]string
+	regexps     []regexMapping
+	groups      map[string]struct{}
+	credentials map[string]struct{}
+}
+
+// restricted reports whether the virtual host only uses its own credentials.
+func (h *host) restricted() bool { return len(h.groups) > 0 || len(h.credentials) > 0 }
+
+// owns reports whether auth belongs to the provider groups or credentials of the host.
+func (h *host) owns(auth *coreauth.Auth) bool {
+	if _, ok := h.groups[strings.ToLower(coreauth.AuthGroup(auth))]; ok {
+		return true
+	}
+	if _, ok := h.credentials[auth.ID]; ok {
+		return true
+	}
+	if auth.FileName != "" {
+		if _, ok := h.credentials[filepath.Base(auth.FileName)]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Registry matches requests to virtual hosts and implements coreauth.AuthFilter.
+type Registry struct {
+	mu    sync.RWMutex
+	hosts []*host
+	byKey map[string]*host
+}
+
+var defaultRegistry = NewRegistry()
+
+// Default returns the process-wide virtual host registry.
+func Default() *Registry { return defaultRegistry }
+
+// NewRegistry constructs an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{byKey: make(map[string]*host)}
+}
+
+// Configure replaces the virtual host definitions.
+func (r *Registry) Configure(vhosts []config.VirtualHost) {
+	if r == nil {
+		return
+	}
+	if len(vhosts) > 0 {
+		configaccess.Register()
+	}
+	hosts := make([]*host, 0, len(vhosts))
+	byKey := make(map[string]*host, len(vhosts))
+	for _, vh := range vhosts {
+		h := &host{
+			cfg:         vh,
+			exact:       make(map[string]string, len(vh.ModelMappings)),
+			groups:      make(map[string]struct{}, len(vh.ProviderGroups)),
+			credentials: make(map[string]struct{}, len(vh.Credentials)),
+		}
+		if len(vh.APIKeys) > 0 {
+			provider, err := sdkaccess.BuildProvider(&sdkconfig.AccessProvider{
+				Name:    "vhost:" + vh.Name,
+				Type:    sdkconfig.AccessProviderTypeConfigAPIKey,
+				APIKeys: vh.APIKeys,
+			}, nil)
+			if err != nil {
+				log.Warnf("virtual host %s: rejecting all requests: %v", vh.Name, err)
+				provider = rejectProvider{name: "vhost:" + vh.Name}
+			}
+			h.access = sdkaccess.NewManager()
+			h.access.SetProviders([]sdkaccess.Provider{provider})
+		}
+		for _, mapping := range vh.ModelMappings {
+			if !mapping.Regex {
+				h.exact[strings.ToLower(mapping.From)] = mapping.To
+				continue
+			}
+			re, err := regexp.Compile("(?i)" + mapping.From)
+			if err != nil {
+				log.Warnf("virtual host %s: invalid model mapping regex %q: %v", vh.Name, mapping.From, err)
+				continue
+			}
+			h.regexps = append(h.regexps, regexMapping{re: re, to: mapping.To})
+		}
+		for _, group := range vh.ProviderGroups {
+			h.groups[strings.ToLower(group)] = struct{}{}
+		}
+		for _, id := range vh.Credentials {
+			h.credentials[id] = struct{}{}
+		}
+		hosts = append(hosts, h)
+		byKey[vh.Name] = h
+	}
+	r.mu.Lock()
+	r.hosts = hosts
+	r.byKey = byKey
+	r.mu.Unlock()
+}
+
+// rejectProvider stands in for the API keys of a virtual host whose access provider could
+// not be built, so its requests are refused rather than served with the top-level keys.
+type rejectProvider struct{ name string }
+
+func (p rejectProvider) Identifier() string { return p.name }
+
+func (rejectProvider) Authenticate(context.Context, *http.Request) (*sdkaccess.Result, error) {
+	return nil, sdkaccess.ErrInvalidCredential
+}
+
+// Enabled reports whether any virtual host is configured.
+func (r *Registry) Enabled() bool {
+	if r == nil {
+		return false
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return len(r.hosts) > 0
+}
+
+// Match returns the virtual host serving a request for hostHeader and path, and the path
+// with the virtual host's prefix removed.
+func (r *Registry) Match(hostHeader, path string) (name, stripped string, ok bool) {
+	if r == nil {
+		return "", path, false
+	}
+	hostname := strings.ToLower(hostHeader)
+	if h, _, err := net.SplitHostPort(hostname); err == nil {
+		hostname = h
+	}
+	hostname = strings.TrimSuffix(strings.Trim(hostname, "[]"), ".")
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, h := range r.hosts {
+		if len(h.cfg.Hosts) > 0 && !matchHost(h.cfg.Hosts, hostname) {
+			continue
+		}
+		rest := path
+		if prefix := h.cfg.PathPrefix; prefix != "" {
+			if path != prefix && !strings.HasPrefix(path, prefix+"/") {
+				continue
+			}
+			if rest = strings.TrimPrefix(path, prefix); rest == "" {
+				rest = "/"
+			}
+		}
+		return h.cfg.Name, rest, true
+	}
+	return "", path, false
+}
+
+// matchHost reports whether hostname matches one of patterns. "*.example.com" matches any
+// subdomain of example.com.
+func matchHost(patterns []string, hostname string) bool {
+	for _, pattern := range patterns {
+		if suffix, ok := strings.CutPrefix(pattern, "*"); ok {
+			if strings.HasSuffix(hostname, suffix) && len(hostname) > len(suffix) {
+				return true
+			}
+			continue
+		}
+		if hostname == pattern {
+			return true
+		}
+	}
+	return false
+}
+
+// Access returns the access manager validating the API keys of the named virtual host, or
+// nil when it relies on the top-level access providers.
+func (r *Registry) Access(name string) *sdkaccess.Manager {
+	if r == nil {
+		return nil
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if h := r.byKey[name]; h != nil {
+		return h.access
+	}
+	return nil
+}
+
+// MapModel applies the virtual host's model mappings. It returns "" when no mapping matches.
+func (r *Registry) MapModel(name, model string) string {
+	if r == nil || name == "" || model == "" {
+		return ""
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	h := r.byKey[name]
+	if h == nil {
+		return ""
+	}
+	if to, ok := h.exact[strings.ToLower(model)]; ok {
+		return to
+	}
+	for _, rm := range h.regexps {
+		if rm.re.MatchString(model) {
+			return rm.to
+		}
+	}
+	return ""
+}
+
+// Handler strips the virtual host prefix from matching requests and attaches the virtual
+// host name to their context before next routes them.
+func Handler(r *Registry, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if !r.Enabled() || req.URL == nil {
+			next.ServeHTTP(w, req)
+			return
+		}
+		name, stripped, ok := r.Match(req.Host, req.URL.Path)
+		if !ok {
+			next.ServeHTTP(w, req)
+			return
+		}
+		req = req.WithContext(WithName(req.Context(), name))
+		if stripped != req.URL.Path {
+			u := *req.URL
+			u.Path = stripped
+			u.RawPath = ""
+			req.URL = &u
+		}
+		next.ServeHTTP(w, req)
+	})
+}
+
+// FilterAuth implements coreauth.AuthFilter. Virtual hosts listing provider groups or
+// credentials only use those, and such credentials never serve requests of other virtual
+// hosts or of the top-level configuration.
+func (r *Registry) FilterAuth(ctx context.Context, auth *coreauth.Auth, _ string) error {
+	if r == nil || auth == nil {
+		return nil
+	}
+	name := FromContext(ctx)
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if len(r.hosts) == 0 {
+		return nil
+	}
+	if h := r.byKey[name]; h != nil && h.restricted() {
+		if h.owns(auth) {
+			return nil
+		}
+	} else {
+		reserved := false
+		for _, other := range r.hosts {
+			if other.owns(auth) {
+				reserved = true
+				break
+			}
+		}
+		if !reserved {
+			return nil
+		}
+	}
+	scope := "the default configuration"
+	if name != "" {
+		scope = fmt.Sprintf("virtual host %q", name)
+	}
+	return &coreauth.Error{
+		Code:       ErrorCodeCredentialReserved,
+		Message:    "no credential available for " + scope,
+		HTTPStatus: http.StatusServiceUnavailable,
+	}
+}
@@ -0,0 +1,112 @@
+package vhost
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+)
+
+func testRegistry() *Registry {
+	cfg := &config.Config{
+		ProviderGroups: []config.ProviderGroup{{Name: "org-a-pool"}},
+		VirtualHosts: []config.VirtualHost{
+			{
+				Name:           "org-a",
+				PathPrefix:     "org-a/",
+				APIKeys:        []string{"key-a"},
+				ModelMappings:  []config.AmpModelMapping{{From: "gpt-5", To: "gpt-5-mini"}},
+				ProviderGroups: []string{"ORG-A-POOL"},
+			},
+			{Name: "org-b", Hosts: []string{"*.org-b.example.com"}, Credentials: []string{"claude-b.json"}},
+			{Name: "open", Hosts: []string{"open.example.com"}},
+		},
+	}
+	cfg.SanitizeVirtualHosts()
+	r := NewRegistry()
+	r.Configure(cfg.VirtualHosts)
+	return r
+}
+
+func TestMatchByPrefixAndHost(t *testing.T) {
+	r := testRegistry()
+	cases := []struct {
+		host, path, name, stripped string
+	}{
+		{"localhost:8317", "/org-a/v1/chat/completions", "org-a", "/v1/chat/completions"},
+		{"localhost", "/org-a", "org-a", "/"},
+		{"api.org-b.example.com:443", "/v1/models", "org-b", "/v1/models"},
+		{"org-b.example.com", "/v1/models", "", "/v1/models"},
+		{"localhost", "/org-ab/v1/models", "", "/org-ab/v1/models"},
+	}
+	for _, tc := range cases {
+		name, stripped, _ := r.Match(tc.host, tc.path)
+		if name != tc.name || stripped != tc.stripped {
+			t.Errorf("Match(%q, %q) = %q, %q; want %q, %q", tc.host, tc.path, name, stripped, tc.name, tc.stripped)
+		}
+	}
+}
+
+func TestHandlerStripsPrefixAndAuthenticatesOwnKeys(t *testing.T) {
+	r := testRegistry()
+	var gotPath, gotName string
+	handler := Handler(r, http.HandlerFunc(func(_ http.ResponseWriter, req *http.Request) {
+		gotPath, gotName = req.URL.Path, FromContext(req.Context())
+	}))
+	req := httptest.NewRequest(http.MethodPost, "/org-a/v1/chat/completions", nil)
+	req.Header.Set("Authorization", "Bearer key-a")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	if gotPath != "/v1/chat/completions" || gotName != "org-a" {
+		t.Fatalf("served %q for %q", gotPath, gotName)
+	}
+
+	access := r.Access("org-a")
+	if access == nil {
+		t.Fatal("org-a should authenticate with its own keys")
+	}
+	if _, err := access.Authenticate(context.Background(), req); err != nil {
+		t.Fatalf("authenticate: %v", err)
+	}
+	if r.Access("open") != nil {
+		t.Fatal("a virtual host without keys should use the top-level access providers")
+	}
+	if got := r.MapModel("org-a", "GPT-5"); got != "gpt-5-mini" {
+		t.Fatalf("MapModel = %q", got)
+	}
+}
+
+func TestFilterAuthReservesCredentials(t *testing.T) {
+	r := testRegistry()
+	pooled := &coreauth.Auth{ID: "a1", Attributes: map[string]string{coreauth.GroupAttribute: "org-a-pool"}}
+	pinned := &coreauth.Auth{ID: "b1", FileName: "/auths/claude-b.json"}
+	shared := &coreauth.Auth{ID: "shared"}
+
+	cases := []struct {
+		vhost string
+		auth  *coreauth.Auth
+		ok    bool
+	}{
+		{"org-a", pooled, true},
+		{"org-a", shared, false},
+		{"org-b", pinned, true},
+		{"org-b", pooled, false},
+		{"open", shared, true},
+		{"open", pooled, false},
+		{"", pinned, false},
+		{"", shared, true},
+	}
+	for _, tc := range cases {
+		err := r.FilterAuth(WithName(context.Background(), tc.vhost), tc.auth, "gpt-5")
+		var authErr *coreauth.Error
+		if tc.ok && err != nil {
+			t.Errorf("vhost %q auth %s: unexpected error %v", tc.vhost, tc.auth.ID, err)
+		}
+		if !tc.ok && (!errors.As(err, &authErr) || authErr.Code != ErrorCodeCredentialReserved) {
+			t.Errorf("vhost %q auth %s: error = %v, want %s", tc.vhost, tc.auth.ID, err, ErrorCodeCredentialReserved)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+// Package warmup sends a trivial request for configured models to every provider serving
+// them after startup, so the first editor request does not pay for cold connections, TLS
+// handshakes and credential refreshes.
+package warmup
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/thinking"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	sdktranslator "github.com/router-for-me/CLIProxyAPI/v6/sdk/translator"
+	log "github.com/sirupsen/logrus"
+)
+
+// registrationWait bounds how long the models may take to be registered by their credentials.
+var registrationWait = 15 * time.Second
+
+// registrationPoll is how often registration is checked.
+const registrationPoll = 250 * time.Millisecond
+
+// Executor sends requests to the credentials of the given providers. coreauth.Manager
+// implements it.
+type Executor interface {
+	Execute(ctx context.Context, providers []string, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (cliproxyexecutor.Response, error)
+}
+
+// Result reports one warm-up request.
+type Result struct {
+	Model    string
+	Provider string
+	Duration time.Duration
+	Err      error
+}
+
+// Run warms up the configured models concurrently and logs the outcome of each request.
+// Models no credential serves once registrationWait has passed are reported and skipped.
+func Run(ctx context.Context, cfg *config.Config, executor Executor) []Result {
+	if cfg == nil || executor == nil || !cfg.Warmup.Enabled() {
+		return nil
+	}
+	timeout := time.Duration(cfg.Warmup.TimeoutSeconds) * time.Second
+	if timeout <= 0 {
+		timeout = config.DefaultWarmupTimeoutSeconds * time.Second
+	}
+
+	deadline := time.Now().Add(registrationWait)
+	var (
+		mu      sync.Mutex
+		results []Result
+		wg      sync.WaitGroup
+	)
+	for _, model := range cfg.Warmup.Models {
+		providers := waitForProviders(ctx, model, deadline)
+		if len(providers) == 0 {
+			if ctx.Err() != nil {
+				break
+			}
+			log.Warnf("warmup: no loaded credential serves %s", model)
+			continue
+		}
+		for _, provider := range providers {
+			wg.Add(1)
+			go func(model, provider string) {
+				defer wg.Done()
+				result := warm(ctx, executor, model, provider, timeout)
+				if result.Err != nil {
+					log.Warnf("warmup: %s on %s failed after %s: %v", model, provider, result.Duration.Round(time.Millisecond), result.Err)
+				} else {
+					log.Infof("warmup: %s on %s ready in %s", model, provider, result.Duration.Round(time.Millisecond))
+				}
+				mu.Lock()
+				results = append(results, result)
+				mu.Unlock()
+			}(model, provider)
+		}
+	}
+	wg.Wait()
+	return results
+}
+
+// waitForProviders returns the providers serving model, waiting for credentials that are
+// still registering their models until deadline.
+func waitForProviders(ctx context.Context, model string, deadline time.Time) []string {
+	base := thinking.ParseSuffix(model).ModelName
+	for {
+		if providers := util.GetProviderName(base); len(providers) > 0 {
+			return providers
+		}
+		if time.Now().After(deadline) {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(registrationPoll):
+		}
+	}
+}
+
+func warm(ctx context.Context, executor Executor, model, provider string, timeout time.Duration) Result {
+	payload, _ := json.Marshal(map[string]any{
+		"model":      model,
+		"messages":   []map[string]string{{"role": "user", "content": "ping"}},
+		"max_tokens": 1,
+	})
+	warmCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	start := time.Now()
+	_, err := executor.Execute(warmCtx, []string{provider}, cliproxyexecutor.Request{
+		Model:   model,
+		Payload: payload,
+	}, cliproxyexecutor.Options{
+		OriginalRequest: payload,
+		SourceFormat:    sdktranslator.FromString("openai"),
+		Metadata:        map[string]any{cliproxyexecutor.RequestedModelMetadataKey: model},
+	})
+	return Result{Model: model, Provider: provider, Duration: time.Since(start), Err: err}
+}
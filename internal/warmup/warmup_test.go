@@ -0,0 +1,75 @@
+package warmup
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	"github.com/tidwall/gjson"
+)
+
+type fakeExecutor struct {
+	mu    sync.Mutex
+	fail  map[string]error
+	calls []string
+}
+
+func (f *fakeExecutor) Execute(_ context.Context, providers []string, req cliproxyexecutor.Request, _ cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+	key := providers[0] + "/" + req.Model
+	f.mu.Lock()
+	f.calls = append(f.calls, key)
+	f.mu.Unlock()
+	if gjson.GetBytes(req.Payload, "max_tokens").Int() != 1 {
+		return cliproxyexecutor.Response{}, errors.New("warm-up request is not trivial")
+	}
+	return cliproxyexecutor.Response{}, f.fail[key]
+}
+
+func TestRunWarmsEveryProviderOfEachModel(t *testing.T) {
+	previous := registrationWait
+	registrationWait = time.Second
+	defer func() { registrationWait = previous }()
+
+	reg := registry.GetGlobalRegistry()
+	reg.RegisterClient("warmup-claude", "claude", []*registry.ModelInfo{{ID: "warmup-sonnet"}})
+	reg.RegisterClient("warmup-kiro", "kiro", []*registry.ModelInfo{{ID: "warmup-sonnet"}})
+	defer reg.UnregisterClient("warmup-claude")
+	defer reg.UnregisterClient("warmup-kiro")
+	defer reg.UnregisterClient("warmup-gemini")
+	// A credential still registering its models when the warm-up starts.
+	go func() {
+		time.Sleep(300 * time.Millisecond)
+		reg.RegisterClient("warmup-gemini", "gemini", []*registry.ModelInfo{{ID: "warmup-flash"}})
+	}()
+
+	cfg := &config.Config{Warmup: config.WarmupConfig{Models: []string{"warmup-sonnet", "warmup-flash", "warmup-unknown"}}}
+	cfg.SanitizeWarmup()
+	executor := &fakeExecutor{fail: map[string]error{"kiro/warmup-sonnet": errors.New("unauthorized")}}
+	results := Run(context.Background(), cfg, executor)
+
+	sort.Strings(executor.calls)
+	want := []string{"claude/warmup-sonnet", "gemini/warmup-flash", "kiro/warmup-sonnet"}
+	if len(executor.calls) != len(want) {
+		t.Fatalf("calls = %v, want %v", executor.calls, want)
+	}
+	for i := range want {
+		if executor.calls[i] != want[i] {
+			t.Fatalf("calls = %v, want %v", executor.calls, want)
+		}
+	}
+	failed := 0
+	for _, result := range results {
+		if result.Err != nil {
+			failed++
+		}
+	}
+	if len(results) != 3 || failed != 1 {
+		t.Fatalf("results = %+v", results)
+	}
+}
@@ -242,6 +242,9 @@ func BuildConfigChangeDetails(oldCfg, newCfg *config.Config) []string {
 	if entries, _ := DiffOAuthModelAliasChanges(oldCfg.OAuthModelAlias, newCfg.OAuthModelAlias); len(entries) > 0 {
 		changes = append(changes, entries...)
 	}
+	if !reflect.DeepEqual(oldCfg.OAuthBaseURL, newCfg.OAuthBaseURL) {
+		changes = append(changes, fmt.Sprintf("oauth-base-url: updated (%d -> %d providers)", len(oldCfg.OAuthBaseURL), len(newCfg.OAuthBaseURL)))
+	}
 
 	// Remote management (never print the key)
 	if oldCfg.RemoteManagement.AllowRemote != newCfg.RemoteManagement.AllowRemote {
@@ -304,6 +307,28 @@ func BuildConfigChangeDetails(oldCfg, newCfg *config.Config) []string {
 		}
 	}
 
+	// Mock providers
+	if len(oldCfg.MockProvider) != len(newCfg.MockProvider) {
+		changes = append(changes, fmt.Sprintf("mock-provider count: %d -> %d", len(oldCfg.MockProvider), len(newCfg.MockProvider)))
+	} else {
+		for i := range oldCfg.MockProvider {
+			if !reflect.DeepEqual(oldCfg.MockProvider[i], newCfg.MockProvider[i]) {
+				changes = append(changes, fmt.Sprintf("mock-provider[%d]: updated", i))
+			}
+		}
+	}
+
+	// Provider groups
+	if len(oldCfg.ProviderGroups) != len(newCfg.ProviderGroups) {
+		changes = append(changes, fmt.Sprintf("provider-groups count: %d -> %d", len(oldCfg.ProviderGroups), len(newCfg.ProviderGroups)))
+	} else {
+		for i := range oldCfg.ProviderGroups {
+			if !reflect.DeepEqual(oldCfg.ProviderGroups[i], newCfg.ProviderGroups[i]) {
+				changes = append(changes, fmt.Sprintf("provider-groups[%s]: updated", newCfg.ProviderGroups[i].Name))
+			}
+		}
+	}
+
 	return changes
 }
 
@@ -0,0 +1,287 @@
+package diff
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+// ConfigImpact summarizes what applying a new config changes at runtime: the model
+// mappings that route requests differently, the API key providers added or removed, the
+// features switched on or off and the settings that only take effect after a restart.
+// Secrets are never included.
+type ConfigImpact struct {
+	ModelMappings    []string `json:"model_mappings"`
+	ProvidersAdded   []string `json:"providers_added"`
+	ProvidersRemoved []string `json:"providers_removed"`
+	FeaturesEnabled  []string `json:"features_enabled"`
+	FeaturesDisabled []string `json:"features_disabled"`
+	RestartRequired  []string `json:"restart_required"`
+	// Changes is the full list of BuildConfigChangeDetails.
+	Changes []string `json:"changes"`
+}
+
+// Empty reports whether applying the config changes nothing material.
+func (i ConfigImpact) Empty() bool {
+	return len(i.ModelMappings) == 0 && len(i.ProvidersAdded) == 0 && len(i.ProvidersRemoved) == 0 &&
+		len(i.FeaturesEnabled) == 0 && len(i.FeaturesDisabled) == 0 && len(i.RestartRequired) == 0 && len(i.Changes) == 0
+}
+
+// Summary renders the impact as one line for logs.
+func (i ConfigImpact) Summary() string {
+	if i.Empty() {
+		return "no material changes"
+	}
+	parts := make([]string, 0, 6)
+	for _, part := range []struct {
+		label string
+		items []string
+	}{
+		{"providers added", i.ProvidersAdded},
+		{"providers removed", i.ProvidersRemoved},
+		{"features enabled", i.FeaturesEnabled},
+		{"features disabled", i.FeaturesDisabled},
+		{"model mappings changed", i.ModelMappings},
+		{"restart required", i.RestartRequired},
+	} {
+		if len(part.items) > 0 {
+			parts = append(parts, fmt.Sprintf("%s: %d", part.label, len(part.items)))
+		}
+	}
+	parts = append(parts, fmt.Sprintf("changes: %d", len(i.Changes)))
+	return strings.Join(parts, ", ")
+}
+
+// BuildConfigImpact computes the impact of replacing oldCfg by newCfg.
+func BuildConfigImpact(oldCfg, newCfg *config.Config) ConfigImpact {
+	impact := ConfigImpact{
+		ModelMappings:    []string{},
+		ProvidersAdded:   []string{},
+		ProvidersRemoved: []string{},
+		FeaturesEnabled:  []string{},
+		FeaturesDisabled: []string{},
+		RestartRequired:  []string{},
+		Changes:          []string{},
+	}
+	if oldCfg == nil || newCfg == nil {
+		return impact
+	}
+	impact.Changes = BuildConfigChangeDetails(oldCfg, newCfg)
+	impact.ModelMappings = modelMappingChanges(oldCfg, newCfg)
+	impact.ProvidersAdded, impact.ProvidersRemoved = diffMultiset(providerIdentities(oldCfg), providerIdentities(newCfg))
+	impact.FeaturesEnabled, impact.FeaturesDisabled = featureToggles(oldCfg, newCfg)
+
+	if strings.TrimSpace(oldCfg.Host) != strings.TrimSpace(newCfg.Host) {
+		impact.RestartRequired = append(impact.RestartRequired, "host")
+	}
+	if oldCfg.Port != newCfg.Port {
+		impact.RestartRequired = append(impact.RestartRequired, "port")
+	}
+	if oldCfg.TLS != newCfg.TLS {
+		impact.RestartRequired = append(impact.RestartRequired, "tls")
+	}
+	return impact
+}
+
+// modelMappingChanges lists the added, removed and retargeted entries of every section
+// that rewrites requested models.
+func modelMappingChanges(oldCfg, newCfg *config.Config) []string {
+	changes := make([]string, 0)
+	changes = append(changes, diffMappings("ampcode.model-mappings", ampMappings(oldCfg.AmpCode.ModelMappings), ampMappings(newCfg.AmpCode.ModelMappings))...)
+
+	clientMappings := func(in []config.ClientModelMapping) map[string]string {
+		out := make(map[string]string, len(in))
+		for _, m := range in {
+			out[m.From] = m.To
+		}
+		return out
+	}
+	changes = append(changes, diffMappings("claude-code.model-mappings", clientMappings(oldCfg.ClaudeCode.ModelMappings), clientMappings(newCfg.ClaudeCode.ModelMappings))...)
+	changes = append(changes, diffMappings("jetbrains.model-mappings", clientMappings(oldCfg.JetBrains.ModelMappings), clientMappings(newCfg.JetBrains.ModelMappings))...)
+
+	for _, channel := range unionKeys(oldCfg.OAuthModelAlias, newCfg.OAuthModelAlias) {
+		aliases := func(in []config.OAuthModelAlias) map[string]string {
+			out := make(map[string]string, len(in))
+			for _, a := range in {
+				out[a.Alias] = a.Name
+			}
+			return out
+		}
+		changes = append(changes, diffMappings("oauth-model-alias."+channel, aliases(oldCfg.OAuthModelAlias[channel]), aliases(newCfg.OAuthModelAlias[channel]))...)
+	}
+
+	oldProjects, newProjects := map[string][]config.AmpModelMapping{}, map[string][]config.AmpModelMapping{}
+	for _, p := range oldCfg.Projects {
+		oldProjects[p.Name] = p.ModelMappings
+	}
+	for _, p := range newCfg.Projects {
+		newProjects[p.Name] = p.ModelMappings
+	}
+	for _, name := range unionKeys(oldProjects, newProjects) {
+		changes = append(changes, diffMappings("projects."+name+".model-mappings", ampMappings(oldProjects[name]), ampMappings(newProjects[name]))...)
+	}
+
+	oldHosts, newHosts := map[string][]config.AmpModelMapping{}, map[string][]config.AmpModelMapping{}
+	for _, h := range oldCfg.VirtualHosts {
+		oldHosts[h.Name] = h.ModelMappings
+	}
+	for _, h := range newCfg.VirtualHosts {
+		newHosts[h.Name] = h.ModelMappings
+	}
+	for _, name := range unionKeys(oldHosts, newHosts) {
+		changes = append(changes, diffMappings("virtual-hosts."+name+".model-mappings", ampMappings(oldHosts[name]), ampMappings(newHosts[name]))...)
+	}
+
+	if !reflect.DeepEqual(oldCfg.Routing.Rules, newCfg.Routing.Rules) {
+		changes = append(changes, fmt.Sprintf("routing.rules: updated (%d -> %d rules)", len(oldCfg.Routing.Rules), len(newCfg.Routing.Rules)))
+	}
+	return changes
+}
+
+func ampMappings(in []config.AmpModelMapping) map[string]string {
+	out := make(map[string]string, len(in))
+	for _, m := range in {
+		from := m.From
+		if m.Regex {
+			from = "/" + from + "/"
+		}
+		out[from] = m.To
+	}
+	return out
+}
+
+// diffMappings compares two from -> to maps of section.
+func diffMappings(section string, oldMap, newMap map[string]string) []string {
+	var changes []string
+	for _, from := range unionKeys(oldMap, newMap) {
+		oldTo, inOld := oldMap[from]
+		newTo, inNew := newMap[from]
+		switch {
+		case !inOld:
+			changes = append(changes, fmt.Sprintf("%s: added %s -> %s", section, from, newTo))
+		case !inNew:
+			changes = append(changes, fmt.Sprintf("%s: removed %s -> %s", section, from, oldTo))
+		case oldTo != newTo:
+			changes = append(changes, fmt.Sprintf("%s: %s -> %s (was %s)", section, from, newTo, oldTo))
+		}
+	}
+	return changes
+}
+
+// providerIdentities names every API key provider of cfg by kind, base URL and prefix,
+// without its key. Entries differing only in their key share an identity and are counted.
+func providerIdentities(cfg *config.Config) map[string]int {
+	out := make(map[string]int)
+	add := func(kind, name, baseURL, prefix string) {
+		id := kind
+		if name = strings.TrimSpace(name); name != "" {
+			id += " " + name
+		}
+		if baseURL = strings.TrimSpace(baseURL); baseURL != "" {
+			id += " " + formatProxyURL(baseURL)
+		}
+		if prefix = strings.TrimSpace(prefix); prefix != "" {
+			id += " prefix=" + prefix
+		}
+		out[id]++
+	}
+	for _, k := range cfg.GeminiKey {
+		add("gemini-api-key", "", k.BaseURL, k.Prefix)
+	}
+	for _, k := range cfg.ClaudeKey {
+		add("claude-api-key", "", k.BaseURL, k.Prefix)
+	}
+	for _, k := range cfg.CodexKey {
+		add("codex-api-key", "", k.BaseURL, k.Prefix)
+	}
+	for _, k := range cfg.VertexCompatAPIKey {
+		add("vertex-api-key", "", k.BaseURL, k.Prefix)
+	}
+	for _, p := range cfg.OpenAICompatibility {
+		add("openai-compatibility", p.Name, p.BaseURL, p.Prefix)
+	}
+	for _, p := range cfg.MockProvider {
+		add("mock-provider", p.Name, "", p.Prefix)
+	}
+	return out
+}
+
+// diffMultiset returns the identities whose count grew and shrank, with the difference
+// when it exceeds one.
+func diffMultiset(oldSet, newSet map[string]int) (added, removed []string) {
+	added, removed = []string{}, []string{}
+	for _, id := range unionKeys(oldSet, newSet) {
+		label := func(n int) string {
+			if n > 1 {
+				return fmt.Sprintf("%s (x%d)", id, n)
+			}
+			return id
+		}
+		switch delta := newSet[id] - oldSet[id]; {
+		case delta > 0:
+			added = append(added, label(delta))
+		case delta < 0:
+			removed = append(removed, label(-delta))
+		}
+	}
+	return added, removed
+}
+
+// featureToggles compares the enable switches of the config sections, the feature flags
+// and the modules.
+func featureToggles(oldCfg, newCfg *config.Config) (enabled, disabled []string) {
+	enabled, disabled = []string{}, []string{}
+	record := func(name string, was, is bool) {
+		switch {
+		case is && !was:
+			enabled = append(enabled, name)
+		case was && !is:
+			disabled = append(disabled, name)
+		}
+	}
+	oldValue, newValue := reflect.ValueOf(oldCfg).Elem(), reflect.ValueOf(newCfg).Elem()
+	typ := oldValue.Type()
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		name := strings.Split(field.Tag.Get("yaml"), ",")[0]
+		if !field.IsExported() || name == "" || name == "-" || field.Type.Kind() != reflect.Struct {
+			continue
+		}
+		for _, switchName := range []string{"Enable", "Enabled"} {
+			sf, ok := field.Type.FieldByName(switchName)
+			if !ok || sf.Type.Kind() != reflect.Bool || len(sf.Index) != 1 {
+				continue
+			}
+			record(name, oldValue.Field(i).Field(sf.Index[0]).Bool(), newValue.Field(i).Field(sf.Index[0]).Bool())
+		}
+	}
+	// Unlisted feature flags and modules are on.
+	onByDefault := func(m map[string]bool, name string) bool {
+		on, ok := m[name]
+		return !ok || on
+	}
+	for _, flag := range unionKeys(oldCfg.FeatureFlags, newCfg.FeatureFlags) {
+		record("feature-flags."+flag, onByDefault(oldCfg.FeatureFlags, flag), onByDefault(newCfg.FeatureFlags, flag))
+	}
+	for _, module := range unionKeys(oldCfg.Modules, newCfg.Modules) {
+		record("modules."+module, onByDefault(oldCfg.Modules, module), onByDefault(newCfg.Modules, module))
+	}
+	return enabled, disabled
+}
+
+func unionKeys[V any](a, b map[string]V) []string {
+	keys := make([]string, 0, len(a)+len(b))
+	for k := range a {
+		keys = append(keys, k)
+	}
+	for k := range b {
+		if _, ok := a[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
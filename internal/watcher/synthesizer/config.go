@@ -5,12 +5,13 @@ import (
 	"strconv"
 	"strings"
 
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/watcher/diff"
 	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
 )
 
 // ConfigSynthesizer generates Auth entries from configuration API keys.
-// It handles Gemini, Claude, Codex, OpenAI-compat, and Vertex-compat providers.
+// It handles Gemini, Claude, Codex, OpenAI-compat, Vertex-compat, and mock providers.
 type ConfigSynthesizer struct{}
 
 // NewConfigSynthesizer creates a new ConfigSynthesizer instance.
@@ -35,6 +36,8 @@ func (s *ConfigSynthesizer) Synthesize(ctx *SynthesisContext) ([]*coreauth.Auth,
 	out = append(out, s.synthesizeOpenAICompat(ctx)...)
 	// Vertex-compat
 	out = append(out, s.synthesizeVertexCompat(ctx)...)
+	// Mock providers
+	out = append(out, s.synthesizeMockProviders(ctx)...)
 
 	return out, nil
 }
@@ -52,16 +55,24 @@ func (s *ConfigSynthesizer) synthesizeGeminiKeys(ctx *SynthesisContext) []*corea
 		if key == "" {
 			continue
 		}
-		prefix := strings.TrimSpace(entry.Prefix)
+		inherited := cfg.InheritGroup(entry.Group, config.GroupSettings{
+			ProxyURL: entry.ProxyURL, Prefix: entry.Prefix, Priority: entry.Priority,
+			Headers: entry.Headers, ExcludedModels: entry.ExcludedModels,
+		})
+		prefix := strings.TrimSpace(inherited.Prefix)
 		base := strings.TrimSpace(entry.BaseURL)
-		proxyURL := strings.TrimSpace(entry.ProxyURL)
+		proxyURL := strings.TrimSpace(inherited.ProxyURL)
 		id, token := idGen.Next("gemini:apikey", key, base)
 		attrs := map[string]string{
 			"source":  fmt.Sprintf("config:gemini[%s]", token),
 			"api_key": key,
 		}
-		if entry.Priority != 0 {
-			attrs["priority"] = strconv.Itoa(entry.Priority)
+		if inherited.Priority != 0 {
+			attrs["priority"] = strconv.Itoa(inherited.Priority)
+		}
+		addGroupToAttrs(entry.Group, attrs)
+		if entry.DisableStreaming {
+			attrs[coreauth.DisableStreamingAttribute] = "true"
 		}
 		if base != "" {
 			attrs["base_url"] = base
@@ -69,7 +80,7 @@ func (s *ConfigSynthesizer) synthesizeGeminiKeys(ctx *SynthesisContext) []*corea
 		if hash := diff.ComputeGeminiModelsHash(entry.Models); hash != "" {
 			attrs["models_hash"] = hash
 		}
-		addConfigHeadersToAttrs(entry.Headers, attrs)
+		addConfigHeadersToAttrs(inherited.Headers, attrs)
 		a := &coreauth.Auth{
 			ID:         id,
 			Provider:   "gemini",
@@ -81,7 +92,7 @@ func (s *ConfigSynthesizer) synthesizeGeminiKeys(ctx *SynthesisContext) []*corea
 			CreatedAt:  now,
 			UpdatedAt:  now,
 		}
-		ApplyAuthExcludedModelsMeta(a, cfg, entry.ExcludedModels, "apikey")
+		ApplyAuthExcludedModelsMeta(a, cfg, inherited.ExcludedModels, "apikey")
 		out = append(out, a)
 	}
 	return out
@@ -100,15 +111,23 @@ func (s *ConfigSynthesizer) synthesizeClaudeKeys(ctx *SynthesisContext) []*corea
 		if key == "" {
 			continue
 		}
-		prefix := strings.TrimSpace(ck.Prefix)
+		inherited := cfg.InheritGroup(ck.Group, config.GroupSettings{
+			ProxyURL: ck.ProxyURL, Prefix: ck.Prefix, Priority: ck.Priority,
+			Headers: ck.Headers, ExcludedModels: ck.ExcludedModels,
+		})
+		prefix := strings.TrimSpace(inherited.Prefix)
 		base := strings.TrimSpace(ck.BaseURL)
 		id, token := idGen.Next("claude:apikey", key, base)
 		attrs := map[string]string{
 			"source":  fmt.Sprintf("config:claude[%s]", token),
 			"api_key": key,
 		}
-		if ck.Priority != 0 {
-			attrs["priority"] = strconv.Itoa(ck.Priority)
+		if inherited.Priority != 0 {
+			attrs["priority"] = strconv.Itoa(inherited.Priority)
+		}
+		addGroupToAttrs(ck.Group, attrs)
+		if ck.DisableStreaming {
+			attrs[coreauth.DisableStreamingAttribute] = "true"
 		}
 		if base != "" {
 			attrs["base_url"] = base
@@ -116,8 +135,8 @@ func (s *ConfigSynthesizer) synthesizeClaudeKeys(ctx *SynthesisContext) []*corea
 		if hash := diff.ComputeClaudeModelsHash(ck.Models); hash != "" {
 			attrs["models_hash"] = hash
 		}
-		addConfigHeadersToAttrs(ck.Headers, attrs)
-		proxyURL := strings.TrimSpace(ck.ProxyURL)
+		addConfigHeadersToAttrs(inherited.Headers, attrs)
+		proxyURL := strings.TrimSpace(inherited.ProxyURL)
 		a := &coreauth.Auth{
 			ID:         id,
 			Provider:   "claude",
@@ -129,7 +148,7 @@ func (s *ConfigSynthesizer) synthesizeClaudeKeys(ctx *SynthesisContext) []*corea
 			CreatedAt:  now,
 			UpdatedAt:  now,
 		}
-		ApplyAuthExcludedModelsMeta(a, cfg, ck.ExcludedModels, "apikey")
+		ApplyAuthExcludedModelsMeta(a, cfg, inherited.ExcludedModels, "apikey")
 		out = append(out, a)
 	}
 	return out
@@ -148,14 +167,22 @@ func (s *ConfigSynthesizer) synthesizeCodexKeys(ctx *SynthesisContext) []*coreau
 		if key == "" {
 			continue
 		}
-		prefix := strings.TrimSpace(ck.Prefix)
+		inherited := cfg.InheritGroup(ck.Group, config.GroupSettings{
+			ProxyURL: ck.ProxyURL, Prefix: ck.Prefix, Priority: ck.Priority,
+			Headers: ck.Headers, ExcludedModels: ck.ExcludedModels,
+		})
+		prefix := strings.TrimSpace(inherited.Prefix)
 		id, token := idGen.Next("codex:apikey", key, ck.BaseURL)
 		attrs := map[string]string{
 			"source":  fmt.Sprintf("config:codex[%s]", token),
 			"api_key": key,
 		}
-		if ck.Priority != 0 {
-			attrs["priority"] = strconv.Itoa(ck.Priority)
+		if inherited.Priority != 0 {
+			attrs["priority"] = strconv.Itoa(inherited.Priority)
+		}
+		addGroupToAttrs(ck.Group, attrs)
+		if ck.DisableStreaming {
+			attrs[coreauth.DisableStreamingAttribute] = "true"
 		}
 		if ck.BaseURL != "" {
 			attrs["base_url"] = ck.BaseURL
@@ -163,8 +190,8 @@ func (s *ConfigSynthesizer) synthesizeCodexKeys(ctx *SynthesisContext) []*coreau
 		if hash := diff.ComputeCodexModelsHash(ck.Models); hash != "" {
 			attrs["models_hash"] = hash
 		}
-		addConfigHeadersToAttrs(ck.Headers, attrs)
-		proxyURL := strings.TrimSpace(ck.ProxyURL)
+		addConfigHeadersToAttrs(inherited.Headers, attrs)
+		proxyURL := strings.TrimSpace(inherited.ProxyURL)
 		a := &coreauth.Auth{
 			ID:         id,
 			Provider:   "codex",
@@ -176,7 +203,7 @@ func (s *ConfigSynthesizer) synthesizeCodexKeys(ctx *SynthesisContext) []*coreau
 			CreatedAt:  now,
 			UpdatedAt:  now,
 		}
-		ApplyAuthExcludedModelsMeta(a, cfg, ck.ExcludedModels, "apikey")
+		ApplyAuthExcludedModelsMeta(a, cfg, inherited.ExcludedModels, "apikey")
 		out = append(out, a)
 	}
 	return out
@@ -191,7 +218,10 @@ func (s *ConfigSynthesizer) synthesizeOpenAICompat(ctx *SynthesisContext) []*cor
 	out := make([]*coreauth.Auth, 0)
 	for i := range cfg.OpenAICompatibility {
 		compat := &cfg.OpenAICompatibility[i]
-		prefix := strings.TrimSpace(compat.Prefix)
+		inherited := cfg.InheritGroup(compat.Group, config.GroupSettings{
+			Prefix: compat.Prefix, Priority: compat.Priority, Headers: compat.Headers,
+		})
+		prefix := strings.TrimSpace(inherited.Prefix)
 		providerName := strings.ToLower(strings.TrimSpace(compat.Name))
 		if providerName == "" {
 			providerName = "openai-compatibility"
@@ -206,14 +236,21 @@ func (s *ConfigSynthesizer) synthesizeOpenAICompat(ctx *SynthesisContext) []*cor
 			proxyURL := strings.TrimSpace(entry.ProxyURL)
 			idKind := fmt.Sprintf("openai-compatibility:%s", providerName)
 			id, token := idGen.Next(idKind, key, base, proxyURL)
+			if proxyURL == "" {
+				proxyURL = strings.TrimSpace(inherited.ProxyURL)
+			}
 			attrs := map[string]string{
 				"source":       fmt.Sprintf("config:%s[%s]", providerName, token),
 				"base_url":     base,
 				"compat_name":  compat.Name,
 				"provider_key": providerName,
 			}
-			if compat.Priority != 0 {
-				attrs["priority"] = strconv.Itoa(compat.Priority)
+			if inherited.Priority != 0 {
+				attrs["priority"] = strconv.Itoa(inherited.Priority)
+			}
+			addGroupToAttrs(compat.Group, attrs)
+			if compat.DisableStreaming {
+				attrs[coreauth.DisableStreamingAttribute] = "true"
 			}
 			if key != "" {
 				attrs["api_key"] = key
@@ -221,7 +258,7 @@ func (s *ConfigSynthesizer) synthesizeOpenAICompat(ctx *SynthesisContext) []*cor
 			if hash := diff.ComputeOpenAICompatModelsHash(compat.Models); hash != "" {
 				attrs["models_hash"] = hash
 			}
-			addConfigHeadersToAttrs(compat.Headers, attrs)
+			addConfigHeadersToAttrs(inherited.Headers, attrs)
 			a := &coreauth.Auth{
 				ID:         id,
 				Provider:   providerName,
@@ -246,19 +283,24 @@ func (s *ConfigSynthesizer) synthesizeOpenAICompat(ctx *SynthesisContext) []*cor
 				"compat_name":  compat.Name,
 				"provider_key": providerName,
 			}
-			if compat.Priority != 0 {
-				attrs["priority"] = strconv.Itoa(compat.Priority)
+			if inherited.Priority != 0 {
+				attrs["priority"] = strconv.Itoa(inherited.Priority)
+			}
+			addGroupToAttrs(compat.Group, attrs)
+			if compat.DisableStreaming {
+				attrs[coreauth.DisableStreamingAttribute] = "true"
 			}
 			if hash := diff.ComputeOpenAICompatModelsHash(compat.Models); hash != "" {
 				attrs["models_hash"] = hash
 			}
-			addConfigHeadersToAttrs(compat.Headers, attrs)
+			addConfigHeadersToAttrs(inherited.Headers, attrs)
 			a := &coreauth.Auth{
 				ID:         id,
 				Provider:   providerName,
 				Label:      compat.Name,
 				Prefix:     prefix,
 				Status:     coreauth.StatusActive,
+				ProxyURL:   strings.TrimSpace(inherited.ProxyURL),
 				Attributes: attrs,
 				CreatedAt:  now,
 				UpdatedAt:  now,
@@ -282,17 +324,25 @@ func (s *ConfigSynthesizer) synthesizeVertexCompat(ctx *SynthesisContext) []*cor
 		base := strings.TrimSpace(compat.BaseURL)
 
 		key := strings.TrimSpace(compat.APIKey)
-		prefix := strings.TrimSpace(compat.Prefix)
+		inherited := cfg.InheritGroup(compat.Group, config.GroupSettings{
+			ProxyURL: compat.ProxyURL, Prefix: compat.Prefix, Priority: compat.Priority, Headers: compat.Headers,
+		})
+		prefix := strings.TrimSpace(inherited.Prefix)
 		proxyURL := strings.TrimSpace(compat.ProxyURL)
 		idKind := "vertex:apikey"
 		id, token := idGen.Next(idKind, key, base, proxyURL)
+		proxyURL = strings.TrimSpace(inherited.ProxyURL)
 		attrs := map[string]string{
 			"source":       fmt.Sprintf("config:vertex-apikey[%s]", token),
 			"base_url":     base,
 			"provider_key": providerName,
 		}
-		if compat.Priority != 0 {
-			attrs["priority"] = strconv.Itoa(compat.Priority)
+		if inherited.Priority != 0 {
+			attrs["priority"] = strconv.Itoa(inherited.Priority)
+		}
+		addGroupToAttrs(compat.Group, attrs)
+		if compat.DisableStreaming {
+			attrs[coreauth.DisableStreamingAttribute] = "true"
 		}
 		if key != "" {
 			attrs["api_key"] = key
@@ -300,7 +350,7 @@ func (s *ConfigSynthesizer) synthesizeVertexCompat(ctx *SynthesisContext) []*cor
 		if hash := diff.ComputeVertexCompatModelsHash(compat.Models); hash != "" {
 			attrs["models_hash"] = hash
 		}
-		addConfigHeadersToAttrs(compat.Headers, attrs)
+		addConfigHeadersToAttrs(inherited.Headers, attrs)
 		a := &coreauth.Auth{
 			ID:         id,
 			Provider:   providerName,
@@ -312,8 +362,42 @@ func (s *ConfigSynthesizer) synthesizeVertexCompat(ctx *SynthesisContext) []*cor
 			CreatedAt:  now,
 			UpdatedAt:  now,
 		}
-		ApplyAuthExcludedModelsMeta(a, cfg, nil, "apikey")
+		ApplyAuthExcludedModelsMeta(a, cfg, cfg.GroupExcludedModels(compat.Group, nil), "apikey")
 		out = append(out, a)
 	}
 	return out
 }
+
+// synthesizeMockProviders creates Auth entries for the built-in mock providers. The
+// response settings travel in the attributes, so the executor needs no config lookup.
+func (s *ConfigSynthesizer) synthesizeMockProviders(ctx *SynthesisContext) []*coreauth.Auth {
+	cfg := ctx.Config
+	now := ctx.Now
+	idGen := ctx.IDGenerator
+
+	out := make([]*coreauth.Auth, 0, len(cfg.MockProvider))
+	for i := range cfg.MockProvider {
+		entry := &cfg.MockProvider[i]
+		id, token := idGen.Next("mock", entry.Name)
+		attrs := map[string]string{
+			"source":              fmt.Sprintf("config:mock[%s]", token),
+			"mock_name":           entry.Name,
+			"mock_models":         strings.Join(entry.Models, ","),
+			"mock_mode":           entry.Mode,
+			"mock_words":          strconv.Itoa(entry.Words),
+			"mock_tool_calls":     strconv.FormatBool(entry.ToolCalls),
+			"mock_chunk_delay_ms": strconv.Itoa(entry.ChunkDelayMs),
+		}
+		out = append(out, &coreauth.Auth{
+			ID:         id,
+			Provider:   "mock",
+			Label:      entry.Name,
+			Prefix:     entry.Prefix,
+			Status:     coreauth.StatusActive,
+			Attributes: attrs,
+			CreatedAt:  now,
+			UpdatedAt:  now,
+		})
+	}
+	return out
+}
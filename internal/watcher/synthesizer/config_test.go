@@ -611,3 +611,68 @@ func TestConfigSynthesizer_AllProviders(t *testing.T) {
 		}
 	}
 }
+
+func TestConfigSynthesizer_MockProviders(t *testing.T) {
+	synth := NewConfigSynthesizer()
+	ctx := &SynthesisContext{
+		Config: &config.Config{
+			MockProvider: []config.MockProvider{
+				{Name: "mock", Prefix: "dev", Models: []string{"mock-a", "mock-b"}, Mode: "echo", Words: 8, ToolCalls: true},
+			},
+		},
+		Now:         time.Now(),
+		IDGenerator: NewStableIDGenerator(),
+	}
+
+	auths, err := synth.Synthesize(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(auths) != 1 {
+		t.Fatalf("expected 1 auth, got %d", len(auths))
+	}
+	a := auths[0]
+	if a.Provider != "mock" || a.Label != "mock" || a.Prefix != "dev" {
+		t.Errorf("unexpected auth %s/%s/%s", a.Provider, a.Label, a.Prefix)
+	}
+	if a.Attributes["mock_models"] != "mock-a,mock-b" || a.Attributes["mock_mode"] != "echo" || a.Attributes["mock_tool_calls"] != "true" {
+		t.Errorf("unexpected attributes %v", a.Attributes)
+	}
+}
+
+func TestConfigSynthesizer_ProviderGroupInheritance(t *testing.T) {
+	cfg := &config.Config{
+		ProviderGroups: []config.ProviderGroup{{
+			Name:     "team-a",
+			ProxyURL: "socks5://proxy.team-a:1080",
+			Priority: 5,
+			Headers:  map[string]string{"X-Team": "a", "X-Env": "prod"},
+		}},
+		ClaudeKey: []config.ClaudeKey{
+			{APIKey: "k1", Group: "Team-A"},
+			{APIKey: "k2", Group: "team-a", ProxyURL: "http://own:8080", Priority: 1, Headers: map[string]string{"X-Env": "dev"}},
+			{APIKey: "k3"},
+		},
+	}
+	cfg.SanitizeProviderGroups()
+	synth := NewConfigSynthesizer()
+	auths, err := synth.Synthesize(&SynthesisContext{Config: cfg, Now: time.Now(), IDGenerator: NewStableIDGenerator()})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(auths) != 3 {
+		t.Fatalf("expected 3 auths, got %d", len(auths))
+	}
+	inherited, own, plain := auths[0], auths[1], auths[2]
+	if inherited.ProxyURL != "socks5://proxy.team-a:1080" || inherited.Attributes["priority"] != "5" ||
+		inherited.Attributes["header:X-Team"] != "a" || inherited.Attributes[coreauth.GroupAttribute] != "team-a" {
+		t.Errorf("inherited auth = %s %v", inherited.ProxyURL, inherited.Attributes)
+	}
+	if own.ProxyURL != "http://own:8080" || own.Attributes["priority"] != "1" ||
+		own.Attributes["header:X-Env"] != "dev" || own.Attributes["header:X-Team"] != "a" {
+		t.Errorf("member overrides lost: %s %v", own.ProxyURL, own.Attributes)
+	}
+	if plain.ProxyURL != "" || plain.Attributes[coreauth.GroupAttribute] != "" {
+		t.Errorf("ungrouped auth inherited settings: %s %v", plain.ProxyURL, plain.Attributes)
+	}
+}
@@ -109,6 +109,7 @@ func (s *FileSynthesizer) Synthesize(ctx *SynthesisContext) ([]*coreauth.Auth, e
 			UpdatedAt: now,
 		}
 		ApplyAuthExcludedModelsMeta(a, cfg, nil, "oauth")
+		ApplyOAuthBaseURL(a, cfg, metadata)
 		if provider == "gemini-cli" {
 			if virtuals := SynthesizeGeminiVirtualAuths(a, metadata, now); len(virtuals) > 0 {
 				for _, v := range virtuals {
@@ -167,6 +168,9 @@ func SynthesizeGeminiVirtualAuths(primary *coreauth.Auth, metadata map[string]an
 		if authPath != "" {
 			attrs["path"] = authPath
 		}
+		if baseURL := primary.Attributes["base_url"]; baseURL != "" {
+			attrs["base_url"] = baseURL
+		}
 		metadataCopy := map[string]any{
 			"email":             email,
 			"project_id":        projectID,
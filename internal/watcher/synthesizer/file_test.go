@@ -297,6 +297,53 @@ func TestFileSynthesizer_Synthesize_PrefixValidation(t *testing.T) {
 	}
 }
 
+func TestFileSynthesizer_Synthesize_BaseURLOverride(t *testing.T) {
+	cfg := &config.Config{OAuthBaseURL: map[string]string{
+		"claude":     "https://gateway.example.com/anthropic",
+		"gemini-cli": "https://codeassist.example.com",
+	}}
+	tests := []struct {
+		name        string
+		authData    map[string]any
+		wantBaseURL string
+	}{
+		{"provider override", map[string]any{"type": "claude"}, "https://gateway.example.com/anthropic"},
+		{"auth file wins", map[string]any{"type": "claude", "base_url": "https://eu.example.com/v1/"}, "https://eu.example.com/v1"},
+		{"invalid auth file value falls back", map[string]any{"type": "claude", "base_url": "ftp://eu.example.com"}, "https://gateway.example.com/anthropic"},
+		{"no override", map[string]any{"type": "codex"}, ""},
+		{"virtual gemini auths inherit", map[string]any{"type": "gemini", "project_id": "a,b"}, "https://codeassist.example.com"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tempDir := t.TempDir()
+			data, _ := json.Marshal(tt.authData)
+			_ = os.WriteFile(filepath.Join(tempDir, "auth.json"), data, 0644)
+
+			synth := NewFileSynthesizer()
+			ctx := &SynthesisContext{
+				Config:      cfg,
+				AuthDir:     tempDir,
+				Now:         time.Now(),
+				IDGenerator: NewStableIDGenerator(),
+			}
+
+			auths, err := synth.Synthesize(ctx)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(auths) == 0 {
+				t.Fatal("expected auths")
+			}
+			for _, a := range auths {
+				if got := a.Attributes["base_url"]; got != tt.wantBaseURL {
+					t.Errorf("auth %s: expected base_url %q, got %q", a.ID, tt.wantBaseURL, got)
+				}
+			}
+		})
+	}
+}
+
 func TestSynthesizeGeminiVirtualAuths_NilInputs(t *testing.T) {
 	now := time.Now()
 
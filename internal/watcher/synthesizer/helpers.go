@@ -10,6 +10,7 @@ import (
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/watcher/diff"
 	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	log "github.com/sirupsen/logrus"
 )
 
 // StableIDGenerator generates stable, deterministic IDs for auth entries.
@@ -93,6 +94,41 @@ func ApplyAuthExcludedModelsMeta(auth *coreauth.Auth, cfg *config.Config, perKey
 	}
 }
 
+// ApplyOAuthBaseURL sets the base_url attribute of an OAuth/file-backed auth entry from the
+// "base_url" of its auth file or, failing that, the oauth-base-url override of its provider.
+// An invalid base URL in the auth file is ignored with a warning.
+func ApplyOAuthBaseURL(auth *coreauth.Auth, cfg *config.Config, metadata map[string]any) {
+	if auth == nil {
+		return
+	}
+	baseURL := ""
+	if raw, ok := metadata["base_url"].(string); ok && strings.TrimSpace(raw) != "" {
+		validated, err := config.ValidateBaseURL(raw)
+		if err != nil {
+			log.Warnf("auth %s: ignoring base_url: %v", auth.ID, err)
+		} else {
+			baseURL = validated
+		}
+	}
+	if baseURL == "" && cfg != nil {
+		baseURL = cfg.OAuthBaseURL[strings.ToLower(strings.TrimSpace(auth.Provider))]
+	}
+	if baseURL == "" {
+		return
+	}
+	if auth.Attributes == nil {
+		auth.Attributes = make(map[string]string)
+	}
+	auth.Attributes["base_url"] = baseURL
+}
+
+// addGroupToAttrs records the provider group of a credential in its attributes.
+func addGroupToAttrs(group string, attrs map[string]string) {
+	if group = strings.TrimSpace(group); group != "" && attrs != nil {
+		attrs[coreauth.GroupAttribute] = group
+	}
+}
+
 // addConfigHeadersToAttrs adds header configuration to auth attributes.
 // Headers are prefixed with "header:" in the attributes map.
 func addConfigHeadersToAttrs(headers map[string]string, attrs map[string]string) {
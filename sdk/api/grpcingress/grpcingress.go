@@ -0,0 +1,182 @@
+// Package grpcingress defines the gRPC service of the proxy's gRPC ingress and a client for
+// it. Messages are JSON encoded with the "json" codec registered by this package, so callers
+// need no generated code: request and response bodies are those of the HTTP API of the
+// chosen dialect, and stream chunks are the data of its SSE events.
+package grpcingress
+
+import (
+	"context"
+	"encoding/json"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/encoding"
+)
+
+// ServiceName is the fully qualified name of the gRPC service.
+const ServiceName = "cliproxy.v1.Proxy"
+
+// Codec is the content subtype of the service's messages.
+const Codec = "json"
+
+const (
+	completeMethod = "/" + ServiceName + "/Complete"
+	streamMethod   = "/" + ServiceName + "/Stream"
+)
+
+// Request dialects, naming the HTTP endpoint a request is served by.
+const (
+	// DialectOpenAI serves OpenAI chat completion bodies (/v1/chat/completions). It is the default.
+	DialectOpenAI = "openai"
+	// DialectClaude serves Claude messages bodies (/v1/messages).
+	DialectClaude = "claude"
+	// DialectResponses serves OpenAI Responses bodies (/v1/responses).
+	DialectResponses = "responses"
+	// DialectGemini serves Gemini generateContent bodies, with the model named in "model".
+	DialectGemini = "gemini"
+)
+
+// Request is the input of both RPCs.
+type Request struct {
+	// Dialect selects the API the body belongs to. Empty means DialectOpenAI.
+	Dialect string `json:"dialect,omitempty"`
+	// Body is the JSON request body of the dialect. Its stream flag is set by the RPC.
+	Body json.RawMessage `json:"body"`
+}
+
+// Response is the result of Complete.
+type Response struct {
+	// Body is the JSON response body of the dialect.
+	Body json.RawMessage `json:"body"`
+}
+
+// Chunk is one message of a Stream response.
+type Chunk struct {
+	// Data is the data of one SSE event of the dialect's streaming response.
+	Data json.RawMessage `json:"data"`
+}
+
+// ProxyServer is the server API of the service. Failed requests return a status error
+// whose code follows the HTTP status and whose message is the dialect's error body.
+type ProxyServer interface {
+	// Complete serves a request without streaming.
+	Complete(context.Context, *Request) (*Response, error)
+	// Stream serves a streaming request, sending one chunk per SSE event.
+	Stream(*Request, StreamServer) error
+}
+
+// StreamServer is the server side of a Stream RPC.
+type StreamServer interface {
+	Send(*Chunk) error
+	grpc.ServerStream
+}
+
+// StreamClient is the client side of a Stream RPC.
+type StreamClient interface {
+	Recv() (*Chunk, error)
+	grpc.ClientStream
+}
+
+// RegisterProxyServer registers srv on s.
+func RegisterProxyServer(s grpc.ServiceRegistrar, srv ProxyServer) {
+	s.RegisterService(&serviceDesc, srv)
+}
+
+var serviceDesc = grpc.ServiceDesc{
+	ServiceName: ServiceName,
+	HandlerType: (*ProxyServer)(nil),
+	Methods:     []grpc.MethodDesc{{MethodName: "Complete", Handler: completeHandler}},
+	Streams:     []grpc.StreamDesc{{StreamName: "Stream", Handler: streamHandler, ServerStreams: true}},
+}
+
+func completeHandler(srv any, ctx context.Context, dec func(any) error, interceptor grpc.UnaryServerInterceptor) (any, error) {
+	in := new(Request)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ProxyServer).Complete(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{Server: srv, FullMethod: completeMethod}
+	return interceptor(ctx, in, info, func(ctx context.Context, req any) (any, error) {
+		return srv.(ProxyServer).Complete(ctx, req.(*Request))
+	})
+}
+
+func streamHandler(srv any, stream grpc.ServerStream) error {
+	in := new(Request)
+	if err := stream.RecvMsg(in); err != nil {
+		return err
+	}
+	return srv.(ProxyServer).Stream(in, &streamServer{stream})
+}
+
+type streamServer struct {
+	grpc.ServerStream
+}
+
+func (s *streamServer) Send(chunk *Chunk) error {
+	return s.SendMsg(chunk)
+}
+
+// Client calls the service over a connection.
+type Client struct {
+	conn grpc.ClientConnInterface
+}
+
+// NewClient returns a client using conn.
+func NewClient(conn grpc.ClientConnInterface) *Client {
+	return &Client{conn: conn}
+}
+
+// Complete serves req without streaming.
+func (c *Client) Complete(ctx context.Context, req *Request, opts ...grpc.CallOption) (*Response, error) {
+	out := new(Response)
+	if err := c.conn.Invoke(ctx, completeMethod, req, out, callOptions(opts)...); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Stream serves req as a stream; Recv returns io.EOF after the last chunk.
+func (c *Client) Stream(ctx context.Context, req *Request, opts ...grpc.CallOption) (StreamClient, error) {
+	stream, err := c.conn.NewStream(ctx, &serviceDesc.Streams[0], streamMethod, callOptions(opts)...)
+	if err != nil {
+		return nil, err
+	}
+	if err = stream.SendMsg(req); err != nil {
+		return nil, err
+	}
+	if err = stream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return &streamClient{stream}, nil
+}
+
+type streamClient struct {
+	grpc.ClientStream
+}
+
+func (s *streamClient) Recv() (*Chunk, error) {
+	chunk := new(Chunk)
+	if err := s.RecvMsg(chunk); err != nil {
+		return nil, err
+	}
+	return chunk, nil
+}
+
+func callOptions(opts []grpc.CallOption) []grpc.CallOption {
+	return append([]grpc.CallOption{grpc.CallContentSubtype(Codec)}, opts...)
+}
+
+func init() {
+	encoding.RegisterCodec(jsonCodec{})
+}
+
+// jsonCodec encodes messages as JSON.
+type jsonCodec struct{}
+
+func (jsonCodec) Marshal(v any) ([]byte, error) { return json.Marshal(v) }
+
+func (jsonCodec) Unmarshal(data []byte, v any) error { return json.Unmarshal(data, v) }
+
+func (jsonCodec) Name() string { return Codec }
@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/thinking"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+)
+
+// clientProfile returns the client profile of the request's API key, or nil.
+func (h *BaseAPIHandler) clientProfile(ctx context.Context) *config.ClientProfile {
+	if h == nil || h.Cfg == nil || len(h.Cfg.ClientProfiles) == 0 {
+		return nil
+	}
+	apiKey, _ := requestPriority(ctx, h.Cfg)
+	return h.Cfg.ClientProfileFor(apiKey)
+}
+
+// ginClientProfile returns the client profile of the API key authenticated on c, or nil.
+func (h *BaseAPIHandler) ginClientProfile(c *gin.Context) *config.ClientProfile {
+	if h == nil || h.Cfg == nil || len(h.Cfg.ClientProfiles) == 0 || c == nil {
+		return nil
+	}
+	apiKey, _ := c.Get("apiKey")
+	key, _ := apiKey.(string)
+	return h.Cfg.ClientProfileFor(key)
+}
+
+// profileModel strips the client profile's model prefixes from a model name no provider
+// serves as written, e.g. "openai/gpt-5" sent by a litellm-based agent.
+func (h *BaseAPIHandler) profileModel(ctx context.Context, modelName string) string {
+	profile := h.clientProfile(ctx)
+	if profile == nil || len(profile.StripModelPrefixes) == 0 {
+		return modelName
+	}
+	for len(util.GetProviderName(thinking.ParseSuffix(modelName).ModelName)) == 0 {
+		stripped := modelName
+		for _, prefix := range profile.StripModelPrefixes {
+			if len(modelName) > len(prefix) && strings.EqualFold(modelName[:len(prefix)], prefix) {
+				stripped = modelName[len(prefix):]
+				break
+			}
+		}
+		if stripped == modelName {
+			break
+		}
+		modelName = stripped
+	}
+	return modelName
+}
+
+// profileSeconds resolves a client profile override: 0 keeps the global value, a negative
+// value switches the setting off.
+func profileSeconds(override, global int) int {
+	switch {
+	case override < 0:
+		return 0
+	case override > 0:
+		return override
+	}
+	return global
+}
+
+// streamingKeepAliveInterval returns the SSE keep-alive interval of the request on c.
+func (h *BaseAPIHandler) streamingKeepAliveInterval(c *gin.Context) time.Duration {
+	profile := h.ginClientProfile(c)
+	if profile == nil {
+		return StreamingKeepAliveInterval(h.Cfg)
+	}
+	return time.Duration(profileSeconds(profile.StreamKeepAliveSeconds, h.Cfg.Streaming.KeepAliveSeconds)) * time.Second
+}
+
+// nonStreamingKeepAliveInterval returns the non-streaming keep-alive interval of the request
+// on c.
+func (h *BaseAPIHandler) nonStreamingKeepAliveInterval(c *gin.Context) time.Duration {
+	profile := h.ginClientProfile(c)
+	if profile == nil {
+		return NonStreamingKeepAliveInterval(h.Cfg)
+	}
+	return time.Duration(profileSeconds(profile.NonStreamKeepAliveSeconds, h.Cfg.NonStreamKeepAliveInterval)) * time.Second
+}
+
+// streamingBootstrapRetries returns the bootstrap retries of the streaming request.
+func (h *BaseAPIHandler) streamingBootstrapRetries(ctx context.Context) int {
+	profile := h.clientProfile(ctx)
+	if profile == nil {
+		return StreamingBootstrapRetries(h.Cfg)
+	}
+	return profileSeconds(profile.BootstrapRetries, h.Cfg.Streaming.BootstrapRetries)
+}
+
+// setProfileRetryAfter sets the client profile's Retry-After on rate limit and overload
+// errors that carry none, so agents back off instead of retrying at once.
+func (h *BaseAPIHandler) setProfileRetryAfter(c *gin.Context, status int) {
+	if status != http.StatusTooManyRequests && status != http.StatusServiceUnavailable {
+		return
+	}
+	profile := h.ginClientProfile(c)
+	if profile == nil || profile.RetryAfterSeconds <= 0 || c.Writer.Header().Get("Retry-After") != "" {
+		return
+	}
+	c.Writer.Header().Set("Retry-After", strconv.Itoa(profile.RetryAfterSeconds))
+}
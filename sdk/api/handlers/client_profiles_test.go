@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	sdkconfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+)
+
+func newProfileHandler(t *testing.T) *BaseAPIHandler {
+	t.Helper()
+	manager := coreauth.NewManager(nil, nil, nil)
+	manager.RegisterExecutor(&fitExecutor{})
+	auth := &coreauth.Auth{ID: "profile-auth", Provider: "claude", Status: coreauth.StatusActive}
+	if _, err := manager.Register(context.Background(), auth); err != nil {
+		t.Fatalf("manager.Register: %v", err)
+	}
+	registry.GetGlobalRegistry().RegisterClient(auth.ID, auth.Provider, []*registry.ModelInfo{{ID: "profile-model"}})
+	t.Cleanup(func() { registry.GetGlobalRegistry().UnregisterClient(auth.ID) })
+
+	cfg := &sdkconfig.Config{SDKConfig: sdkconfig.SDKConfig{
+		Streaming: sdkconfig.StreamingConfig{KeepAliveSeconds: 30},
+		ClientProfiles: []sdkconfig.ClientProfile{
+			{Name: "Aider", APIKeys: []string{"aider-key"}},
+			{Name: "agents", Preset: "openhands", APIKeys: []string{"openhands-key"}, RetryAfterSeconds: -1},
+			{Name: "aider", APIKeys: []string{" "}},
+		},
+	}}
+	cfg.SanitizeClientProfiles()
+	if len(cfg.ClientProfiles) != 2 {
+		t.Fatalf("profiles = %+v, want the two with keys", cfg.ClientProfiles)
+	}
+	return NewBaseAPIHandlers(&cfg.SDKConfig, manager)
+}
+
+func profileContext(apiKey string) (*gin.Context, *httptest.ResponseRecorder, context.Context) {
+	gin.SetMode(gin.TestMode)
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+	if apiKey != "" {
+		c.Set("apiKey", apiKey)
+	}
+	return c, recorder, context.WithValue(context.Background(), "gin", c)
+}
+
+func TestClientProfileStripsModelPrefixes(t *testing.T) {
+	h := newProfileHandler(t)
+
+	_, _, ctx := profileContext("aider-key")
+	resp, errMsg := h.ExecuteWithAuthManager(ctx, "claude", "litellm_proxy/openai/profile-model", []byte(`{}`), "")
+	if errMsg != nil {
+		t.Fatalf("ExecuteWithAuthManager: %v", errMsg.Error)
+	}
+	if string(resp) != "profile-model" {
+		t.Fatalf("served model = %q, want profile-model", resp)
+	}
+
+	_, _, ctx = profileContext("other-key")
+	if _, errMsg = h.ExecuteWithAuthManager(ctx, "claude", "openai/profile-model", []byte(`{}`), ""); errMsg == nil {
+		t.Fatal("prefix stripped for a key without a profile")
+	}
+}
+
+func TestClientProfileOverridesKeepAliveAndRetries(t *testing.T) {
+	h := newProfileHandler(t)
+
+	aider, _, aiderCtx := profileContext("aider-key")
+	if got := h.streamingKeepAliveInterval(aider); got != 15*time.Second {
+		t.Fatalf("aider stream keep-alive = %s, want 15s", got)
+	}
+	if got := h.streamingBootstrapRetries(aiderCtx); got != 2 {
+		t.Fatalf("aider bootstrap retries = %d, want 2", got)
+	}
+
+	openhands, _, _ := profileContext("openhands-key")
+	if got := h.nonStreamingKeepAliveInterval(openhands); got != 15*time.Second {
+		t.Fatalf("openhands non-stream keep-alive = %s, want 15s", got)
+	}
+	if got := h.streamingKeepAliveInterval(openhands); got != 30*time.Second {
+		t.Fatalf("openhands stream keep-alive = %s, want the global 30s", got)
+	}
+
+	other, _, _ := profileContext("")
+	if got := h.nonStreamingKeepAliveInterval(other); got != 0 {
+		t.Fatalf("default non-stream keep-alive = %s, want off", got)
+	}
+}
+
+func TestClientProfileSetsRetryAfter(t *testing.T) {
+	h := newProfileHandler(t)
+	limited := &interfaces.ErrorMessage{StatusCode: http.StatusTooManyRequests, Error: errors.New("rate limited")}
+
+	c, recorder, _ := profileContext("aider-key")
+	h.WriteErrorResponse(c, limited)
+	if got := recorder.Header().Get("Retry-After"); got != "5" {
+		t.Fatalf("aider Retry-After = %q, want 5", got)
+	}
+
+	c, recorder, _ = profileContext("openhands-key")
+	h.WriteErrorResponse(c, limited)
+	if got := recorder.Header().Get("Retry-After"); got != "" {
+		t.Fatalf("Retry-After = %q, want none when switched off", got)
+	}
+}
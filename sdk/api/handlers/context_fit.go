@@ -0,0 +1,167 @@
+package handlers
+
+import (
+	"context"
+	"crypto/sha256"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/thinking"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	log "github.com/sirupsen/logrus"
+	"github.com/tidwall/gjson"
+)
+
+// contextFitCacheSize caps the cached token counts; the cache is pruned when it is full.
+const contextFitCacheSize = 4096
+
+// contextFitOutputKeys hold the output token limit of a request, by request format.
+var contextFitOutputKeys = []string{"max_tokens", "max_completion_tokens", "max_output_tokens", "generationConfig.maxOutputTokens", "request.generationConfig.maxOutputTokens"}
+
+// contextFitCountKeys hold the prompt size in count-tokens responses, by response format.
+var contextFitCountKeys = []string{"input_tokens", "totalTokens", "response.totalTokens", "usage.prompt_tokens"}
+
+type contextFitEntry struct {
+	tokens  int
+	expires time.Time
+}
+
+// contextFitCache remembers prompt token counts so retried and repeated prompts do not
+// call the count-tokens endpoint again.
+type contextFitCache struct {
+	mu      sync.Mutex
+	entries map[[sha256.Size]byte]contextFitEntry
+}
+
+var fitCache = &contextFitCache{entries: make(map[[sha256.Size]byte]contextFitEntry)}
+
+func (c *contextFitCache) get(key [sha256.Size]byte, now time.Time) (int, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[key]
+	if !ok || now.After(entry.expires) {
+		return 0, false
+	}
+	return entry.tokens, true
+}
+
+func (c *contextFitCache) put(key [sha256.Size]byte, tokens int, expires time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.entries) >= contextFitCacheSize {
+		now := time.Now()
+		for k, entry := range c.entries {
+			if now.After(entry.expires) {
+				delete(c.entries, k)
+			}
+		}
+		if len(c.entries) >= contextFitCacheSize {
+			clear(c.entries)
+		}
+	}
+	c.entries[key] = contextFitEntry{tokens: tokens, expires: expires}
+}
+
+// contextFitModel returns the model the request is sent to: modelName when the prompt and
+// its output limit fit the model's context window, otherwise the first configured fallback
+// whose window fits. Models with an unknown window are assumed to fit.
+func (h *BaseAPIHandler) contextFitModel(ctx context.Context, handlerType, modelName string, rawJSON []byte, alt string) string {
+	if h.Cfg == nil || !h.Cfg.ContextFit.Enable {
+		return modelName
+	}
+	fallbacks := contextFitFallbacks(h.Cfg.ContextFit.Rules, modelName)
+	window := contextWindow(modelName)
+	if len(fallbacks) == 0 || window <= 0 {
+		return modelName
+	}
+	need := h.promptTokens(ctx, handlerType, modelName, rawJSON, alt) + outputReserve(rawJSON, h.Cfg.ContextFit.ReserveTokens)
+	if need <= window {
+		return modelName
+	}
+	for _, fallback := range fallbacks {
+		if fallbackWindow := contextWindow(fallback); fallbackWindow <= 0 || need <= fallbackWindow {
+			log.WithField("request_id", logging.GetRequestID(ctx)).Infof("context fit: %d tokens exceed the %d-token window of %s, rerouting to %s", need, window, modelName, fallback)
+			return fallback
+		}
+	}
+	log.WithField("request_id", logging.GetRequestID(ctx)).Warnf("context fit: %d tokens exceed the window of %s and of every fallback", need, modelName)
+	return modelName
+}
+
+// contextFitFallbacks returns the fallbacks of the first rule matching model.
+func contextFitFallbacks(rules []config.ContextFitRule, model string) []string {
+	model = strings.TrimSpace(model)
+	for _, rule := range rules {
+		for _, pattern := range rule.Models {
+			if util.MatchWildcard(pattern, model) {
+				return rule.Fallbacks
+			}
+		}
+	}
+	return nil
+}
+
+// contextWindow returns the input token limit of model, or 0 when it is unknown.
+func contextWindow(model string) int {
+	info := registry.LookupModelInfo(thinking.ParseSuffix(model).ModelName)
+	if info == nil {
+		return 0
+	}
+	if info.InputTokenLimit > 0 {
+		return info.InputTokenLimit
+	}
+	return info.ContextLength
+}
+
+// outputReserve returns the output token limit of the request, or reserve when it sets none.
+func outputReserve(rawJSON []byte, reserve int) int {
+	for _, key := range contextFitOutputKeys {
+		if limit := gjson.GetBytes(rawJSON, key).Int(); limit > 0 {
+			return int(limit)
+		}
+	}
+	return reserve
+}
+
+// promptTokens measures the prompt of the request, through the provider's count-tokens
+// endpoint when configured and falling back to the local estimate.
+func (h *BaseAPIHandler) promptTokens(ctx context.Context, handlerType, modelName string, rawJSON []byte, alt string) int {
+	key := sha256.Sum256([]byte(handlerType + "\x00" + modelName + "\x00" + string(rawJSON)))
+	now := time.Now()
+	if tokens, ok := fitCache.get(key, now); ok {
+		return tokens
+	}
+	tokens := 0
+	if h.Cfg.ContextFit.CountTokens && countsTokens(handlerType) {
+		if resp, errMsg := h.ExecuteCountWithAuthManager(ctx, handlerType, modelName, rawJSON, alt); errMsg == nil {
+			for _, key := range contextFitCountKeys {
+				if count := gjson.GetBytes(resp, key).Int(); count > 0 {
+					tokens = int(count)
+					break
+				}
+			}
+		} else {
+			log.WithField("request_id", logging.GetRequestID(ctx)).Debugf("context fit: count tokens for %s: %v", modelName, errMsg.Error)
+		}
+	}
+	if tokens == 0 {
+		var prompt strings.Builder
+		collectUsageText(gjson.ParseBytes(rawJSON), usagePromptKeys, &prompt)
+		tokens = int(util.CountTokens(prompt.String()))
+	}
+	fitCache.put(key, tokens, now.Add(time.Duration(h.Cfg.ContextFit.CacheSeconds)*time.Second))
+	return tokens
+}
+
+// countsTokens reports whether count-tokens responses can be translated to handlerType.
+func countsTokens(handlerType string) bool {
+	switch handlerType {
+	case "claude", "gemini", "gemini-cli":
+		return true
+	}
+	return false
+}
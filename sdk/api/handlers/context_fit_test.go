@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	coreexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	sdkconfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+)
+
+// fitExecutor answers with the model it was asked for and counts every prompt as 500 tokens.
+type fitExecutor struct {
+	counts atomic.Int32
+}
+
+func (e *fitExecutor) Identifier() string { return "claude" }
+
+func (e *fitExecutor) Execute(_ context.Context, _ *coreauth.Auth, req coreexecutor.Request, _ coreexecutor.Options) (coreexecutor.Response, error) {
+	return coreexecutor.Response{Payload: []byte(req.Model)}, nil
+}
+
+func (e *fitExecutor) ExecuteStream(_ context.Context, _ *coreauth.Auth, req coreexecutor.Request, _ coreexecutor.Options) (<-chan coreexecutor.StreamChunk, error) {
+	ch := make(chan coreexecutor.StreamChunk, 1)
+	ch <- coreexecutor.StreamChunk{Payload: []byte(req.Model)}
+	close(ch)
+	return ch, nil
+}
+
+func (e *fitExecutor) Refresh(_ context.Context, auth *coreauth.Auth) (*coreauth.Auth, error) {
+	return auth, nil
+}
+
+func (e *fitExecutor) CountTokens(context.Context, *coreauth.Auth, coreexecutor.Request, coreexecutor.Options) (coreexecutor.Response, error) {
+	e.counts.Add(1)
+	return coreexecutor.Response{Payload: []byte(`{"input_tokens":500}`)}, nil
+}
+
+func (e *fitExecutor) HttpRequest(context.Context, *coreauth.Auth, *http.Request) (*http.Response, error) {
+	return nil, &coreauth.Error{Code: "not_implemented", Message: "HttpRequest not implemented", HTTPStatus: http.StatusNotImplemented}
+}
+
+func newFitHandler(t *testing.T, countTokens bool) (*BaseAPIHandler, *fitExecutor) {
+	t.Helper()
+	executor := &fitExecutor{}
+	manager := coreauth.NewManager(nil, nil, nil)
+	manager.RegisterExecutor(executor)
+	auth := &coreauth.Auth{ID: "fit-auth", Provider: "claude", Status: coreauth.StatusActive}
+	if _, err := manager.Register(context.Background(), auth); err != nil {
+		t.Fatalf("manager.Register: %v", err)
+	}
+	registry.GetGlobalRegistry().RegisterClient(auth.ID, auth.Provider, []*registry.ModelInfo{
+		{ID: "fit-small", ContextLength: 1000},
+		{ID: "fit-medium", ContextLength: 1200},
+		{ID: "fit-large", InputTokenLimit: 100000},
+	})
+	t.Cleanup(func() { registry.GetGlobalRegistry().UnregisterClient(auth.ID) })
+
+	cfg := &sdkconfig.Config{SDKConfig: sdkconfig.SDKConfig{ContextFit: sdkconfig.ContextFitConfig{
+		Enable:        true,
+		CountTokens:   countTokens,
+		ReserveTokens: 100,
+		Rules:         []sdkconfig.ContextFitRule{{Models: []string{"fit-small*"}, Fallbacks: []string{"fit-medium", "fit-large"}}},
+	}}}
+	cfg.SanitizeContextFit()
+	return NewBaseAPIHandlers(&cfg.SDKConfig, manager), executor
+}
+
+func TestContextFitReroutesWithCachedCount(t *testing.T) {
+	handler, executor := newFitHandler(t, true)
+
+	fits := []byte(`{"model":"fit-small","max_tokens":400,"messages":[{"role":"user","content":"hi"}]}`)
+	resp, errMsg := handler.ExecuteWithAuthManager(context.Background(), "claude", "fit-small", fits, "")
+	if errMsg != nil || string(resp) != "fit-small" {
+		t.Fatalf("fitting request went to %q (%+v), want fit-small", resp, errMsg)
+	}
+
+	// 500 counted tokens plus 800 for the output exceed fit-small and fit-medium.
+	tooLarge := []byte(`{"model":"fit-small","max_tokens":800,"messages":[{"role":"user","content":"hi"}]}`)
+	for i := 0; i < 2; i++ {
+		dataChan, errChan := handler.ExecuteStreamWithAuthManager(context.Background(), "claude", "fit-small", tooLarge, "")
+		var got []byte
+		for chunk := range dataChan {
+			got = append(got, chunk...)
+		}
+		for msg := range errChan {
+			if msg != nil {
+				t.Fatalf("unexpected error: %+v", msg)
+			}
+		}
+		if string(got) != "fit-large" {
+			t.Fatalf("oversized stream went to %q, want fit-large", got)
+		}
+	}
+	if got := executor.counts.Load(); got != 2 {
+		t.Fatalf("count-tokens calls = %d, want 2 (one per distinct prompt)", got)
+	}
+}
+
+func TestContextFitEstimatesLocallyWithoutCountTokens(t *testing.T) {
+	handler, executor := newFitHandler(t, false)
+
+	long := []byte(`{"model":"fit-small","messages":[{"role":"user","content":"` + strings.Repeat("lorem ipsum ", 1000) + `"}]}`)
+	resp, errMsg := handler.ExecuteWithAuthManager(context.Background(), "openai", "fit-small", long, "")
+	if errMsg != nil || string(resp) != "fit-large" {
+		t.Fatalf("long prompt went to %q (%+v), want fit-large", resp, errMsg)
+	}
+	if executor.counts.Load() != 0 {
+		t.Fatal("count-tokens endpoint called although count-tokens is off")
+	}
+}
@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"context"
+	"strings"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/thinking"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	log "github.com/sirupsen/logrus"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// requestModel returns the model a request is served by, after the client profile's prefix
+// stripping and the default model of the client API key, with rawJSON naming it.
+func (h *BaseAPIHandler) requestModel(ctx context.Context, handlerType, modelName string, rawJSON []byte) (string, []byte) {
+	modelName = h.profileModel(ctx, modelName)
+	if h.Cfg == nil || len(h.Cfg.DefaultModels.Rules) == 0 {
+		return modelName, rawJSON
+	}
+	requested := strings.TrimSpace(modelName)
+	if requested != "" && (!h.Cfg.DefaultModels.SubstituteUnknown || servesModel(requested)) {
+		return modelName, rawJSON
+	}
+	apiKey, _ := requestPriority(ctx, h.Cfg)
+	fallback := h.Cfg.DefaultModels.ModelFor(apiKey)
+	if fallback == "" || strings.EqualFold(fallback, requested) {
+		return modelName, rawJSON
+	}
+	logger := log.WithField("request_id", logging.GetRequestID(ctx))
+	if requested == "" {
+		logger.Debugf("default model: request names no model, serving %s", fallback)
+	} else {
+		logger.Infof("default model: no provider serves %s, serving %s", requested, fallback)
+	}
+	// Gemini requests name the model in the path only.
+	if handlerType != "gemini" && handlerType != "gemini-cli" && gjson.ValidBytes(rawJSON) {
+		if updated, err := sjson.SetBytes(rawJSON, "model", fallback); err == nil {
+			rawJSON = updated
+		}
+	}
+	return fallback, rawJSON
+}
+
+// servesModel reports whether a provider serves model; "auto" is always served.
+func servesModel(model string) bool {
+	base := strings.TrimSpace(thinking.ParseSuffix(model).ModelName)
+	if base == "auto" {
+		return true
+	}
+	return len(util.GetProviderName(base)) > 0 || len(util.GetProviderName(model)) > 0
+}
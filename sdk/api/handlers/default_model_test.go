@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	sdkconfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	"github.com/tidwall/gjson"
+)
+
+func newDefaultModelHandler(t *testing.T, substitute bool) *BaseAPIHandler {
+	t.Helper()
+	manager := coreauth.NewManager(nil, nil, nil)
+	manager.RegisterExecutor(&fitExecutor{})
+	auth := &coreauth.Auth{ID: "default-model-auth", Provider: "claude", Status: coreauth.StatusActive}
+	if _, err := manager.Register(context.Background(), auth); err != nil {
+		t.Fatalf("manager.Register: %v", err)
+	}
+	registry.GetGlobalRegistry().RegisterClient(auth.ID, auth.Provider, []*registry.ModelInfo{{ID: "default-small"}, {ID: "default-large"}})
+	t.Cleanup(func() { registry.GetGlobalRegistry().UnregisterClient(auth.ID) })
+
+	cfg := &sdkconfig.Config{SDKConfig: sdkconfig.SDKConfig{DefaultModels: sdkconfig.DefaultModelConfig{
+		SubstituteUnknown: substitute,
+		Rules: []sdkconfig.DefaultModelRule{
+			{Model: "default-small"},
+			{APIKeys: []string{" vip-key "}, Model: "default-large"},
+			{APIKeys: []string{"other"}, Model: " "},
+		},
+	}}}
+	cfg.SanitizeDefaultModels()
+	if len(cfg.DefaultModels.Rules) != 2 {
+		t.Fatalf("rules = %+v, want the two with models", cfg.DefaultModels.Rules)
+	}
+	return NewBaseAPIHandlers(&cfg.SDKConfig, manager)
+}
+
+func TestDefaultModelServesRequestsWithoutModel(t *testing.T) {
+	h := newDefaultModelHandler(t, false)
+	for apiKey, want := range map[string]string{"vip-key": "default-large", "plain-key": "default-small"} {
+		_, _, ctx := profileContext(apiKey)
+		resp, errMsg := h.ExecuteWithAuthManager(ctx, "claude", "", []byte(`{"messages":[]}`), "")
+		if errMsg != nil {
+			t.Fatalf("%s: ExecuteWithAuthManager: %v", apiKey, errMsg.Error)
+		}
+		if string(resp) != want {
+			t.Fatalf("%s: served model = %q, want %s", apiKey, resp, want)
+		}
+	}
+
+	_, _, ctx := profileContext("plain-key")
+	if _, errMsg := h.ExecuteWithAuthManager(ctx, "claude", "missing-model", []byte(`{}`), ""); errMsg == nil {
+		t.Fatal("unknown model substituted without substitute-unknown")
+	}
+}
+
+func TestDefaultModelSubstitutesUnknownModels(t *testing.T) {
+	h := newDefaultModelHandler(t, true)
+	_, _, ctx := profileContext("vip-key")
+	model, rawJSON := h.requestModel(ctx, "claude", "missing-model", []byte(`{"model":"missing-model"}`))
+	if model != "default-large" || gjson.GetBytes(rawJSON, "model").String() != "default-large" {
+		t.Fatalf("substituted = %q, %s", model, rawJSON)
+	}
+	if model, _ = h.requestModel(ctx, "claude", "default-small", nil); model != "default-small" {
+		t.Fatalf("served model %q replaced with %q", "default-small", model)
+	}
+	if _, rawJSON = h.requestModel(ctx, "gemini", "", []byte(`{"contents":[]}`)); gjson.GetBytes(rawJSON, "model").Exists() {
+		t.Fatalf("model added to a Gemini body: %s", rawJSON)
+	}
+}
@@ -0,0 +1,353 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// ErrorCode identifies a proxy failure independently of the API dialect it is reported in.
+type ErrorCode string
+
+const (
+	// ErrorCodeNoProvider means no provider or credential can serve the requested model.
+	ErrorCodeNoProvider ErrorCode = "no_provider"
+	// ErrorCodeMappingTargetUnavailable means a model mapping applied but its target model
+	// has no available provider.
+	ErrorCodeMappingTargetUnavailable ErrorCode = "mapping_target_unavailable"
+	// ErrorCodeQuotaExceeded means the credentials are rate limited, cooling down or over
+	// their spend limit.
+	ErrorCodeQuotaExceeded ErrorCode = "quota_exceeded"
+	// ErrorCodeUpstreamTimeout means the upstream did not answer in time.
+	ErrorCodeUpstreamTimeout ErrorCode = "upstream_timeout"
+	// ErrorCodeTranslationFailed means a request or response could not be converted between
+	// API formats.
+	ErrorCodeTranslationFailed ErrorCode = "translation_failed"
+	// ErrorCodeOffline means offline mode is on and the request needs a remote upstream.
+	ErrorCodeOffline ErrorCode = "offline"
+	// ErrorCodeUnknownThinkingSuffix means the model's thinking suffix is not recognized and
+	// the unknown-thinking-suffix policy rejects it.
+	ErrorCodeUnknownThinkingSuffix ErrorCode = "unknown_thinking_suffix"
+	// ErrorCodeRequestTooLarge means the request body exceeds max-request-body-bytes.
+	ErrorCodeRequestTooLarge ErrorCode = "request_too_large"
+)
+
+// ErrorCodeHeader carries the ErrorCode of proxy generated errors in every dialect.
+const ErrorCodeHeader = "X-CLIProxy-Error-Code"
+
+// errorHintContextKey stores an ErrorHint set by routing layers in front of a handler.
+const errorHintContextKey = "cliproxy_error_hint"
+
+// ErrTranslationFailed can be wrapped by executors and translators to report a format
+// conversion failure.
+var ErrTranslationFailed = errors.New("translation failed")
+
+// errorCodeStatus is the HTTP status every dialect reports for an ErrorCode.
+var errorCodeStatus = map[ErrorCode]int{
+	ErrorCodeNoProvider:               http.StatusBadGateway,
+	ErrorCodeMappingTargetUnavailable: http.StatusBadGateway,
+	ErrorCodeQuotaExceeded:            http.StatusTooManyRequests,
+	ErrorCodeUpstreamTimeout:          http.StatusGatewayTimeout,
+	ErrorCodeTranslationFailed:        http.StatusBadGateway,
+	ErrorCodeOffline:                  http.StatusServiceUnavailable,
+	ErrorCodeUnknownThinkingSuffix:    http.StatusBadRequest,
+	ErrorCodeRequestTooLarge:          http.StatusRequestEntityTooLarge,
+}
+
+// StatusFor returns the HTTP status reported for code, or 0 for unknown codes.
+func (code ErrorCode) StatusFor() int {
+	return errorCodeStatus[code]
+}
+
+// Dialect is the API family whose error envelope a response uses.
+type Dialect string
+
+const (
+	// DialectOpenAI renders {"error":{"message","type","code"}}.
+	DialectOpenAI Dialect = "openai"
+	// DialectClaude renders {"type":"error","error":{"type","message"}}.
+	DialectClaude Dialect = "claude"
+	// DialectGemini renders {"error":{"code","message","status","details"}}.
+	DialectGemini Dialect = "gemini"
+)
+
+// DialectForHandlerType maps a handler type ("claude", "gemini-cli", "openai-response", ...)
+// to its error dialect.
+func DialectForHandlerType(handlerType string) Dialect {
+	switch {
+	case strings.HasPrefix(handlerType, "claude"):
+		return DialectClaude
+	case strings.HasPrefix(handlerType, "gemini"):
+		return DialectGemini
+	default:
+		return DialectOpenAI
+	}
+}
+
+// ErrorHint refines how a no_provider failure is reported. Routing layers that rewrite the
+// request before it reaches a handler (e.g. Amp model mappings) set it with SetErrorHint.
+type ErrorHint struct {
+	Code    ErrorCode
+	Message string
+}
+
+// SetErrorHint records hint on the request; it applies when the handler fails with
+// no_provider.
+func SetErrorHint(c *gin.Context, hint ErrorHint) {
+	if c != nil {
+		c.Set(errorHintContextKey, hint)
+	}
+}
+
+// ClassifyError maps msg to an ErrorCode and the status it is reported with. Errors outside
+// the taxonomy return an empty code and their own status.
+func ClassifyError(msg *interfaces.ErrorMessage) (ErrorCode, int) {
+	status := http.StatusInternalServerError
+	if msg != nil && msg.StatusCode > 0 {
+		status = msg.StatusCode
+	}
+	if msg == nil {
+		return "", status
+	}
+	code := ErrorCode(msg.Code)
+	if code == "" {
+		code = classifyCause(msg.Error, status)
+	}
+	if mapped := code.StatusFor(); mapped > 0 {
+		status = mapped
+	}
+	return code, status
+}
+
+func classifyCause(err error, status int) ErrorCode {
+	if err != nil {
+		if errors.Is(err, ErrTranslationFailed) {
+			return ErrorCodeTranslationFailed
+		}
+		if errors.Is(err, context.DeadlineExceeded) {
+			return ErrorCodeUpstreamTimeout
+		}
+		var netErr net.Error
+		if errors.As(err, &netErr) && netErr.Timeout() {
+			return ErrorCodeUpstreamTimeout
+		}
+		var authErr *coreauth.Error
+		if errors.As(err, &authErr) {
+			switch authErr.Code {
+			case "provider_not_found", "auth_not_found", "auth_unavailable", "executor_not_found":
+				return ErrorCodeNoProvider
+			case "offline":
+				return ErrorCodeOffline
+			}
+		}
+	}
+	switch status {
+	case http.StatusTooManyRequests:
+		return ErrorCodeQuotaExceeded
+	case http.StatusGatewayTimeout, http.StatusRequestTimeout:
+		return ErrorCodeUpstreamTimeout
+	}
+	return ""
+}
+
+// BuildDialectErrorBody renders an error in the envelope of dialect. Upstream payloads already
+// in that envelope keep their details and only gain the code; payloads of another dialect
+// are re-wrapped around their message.
+func BuildDialectErrorBody(dialect Dialect, status int, code ErrorCode, errText string) []byte {
+	if status <= 0 {
+		status = http.StatusInternalServerError
+	}
+	message := strings.TrimSpace(errText)
+	if message != "" && json.Valid([]byte(message)) {
+		if matchesDialect(dialect, message) {
+			return annotateUpstreamError(dialect, code, []byte(message))
+		}
+		if extracted := upstreamErrorMessage(message); extracted != "" {
+			message = extracted
+		}
+	}
+	if message == "" {
+		message = http.StatusText(status)
+	}
+
+	var payload any
+	switch dialect {
+	case DialectClaude:
+		payload = map[string]any{
+			"type":  "error",
+			"error": map[string]any{"type": claudeErrorType(status), "message": message},
+		}
+	case DialectGemini:
+		detail := map[string]any{"code": status, "message": message, "status": geminiErrorStatus(status)}
+		if code != "" {
+			detail["details"] = []any{map[string]any{
+				"@type":  "type.googleapis.com/google.rpc.ErrorInfo",
+				"reason": strings.ToUpper(string(code)),
+				"domain": "cliproxyapi",
+			}}
+		}
+		payload = map[string]any{"error": detail}
+	default:
+		errType, defaultCode := openAIErrorType(status)
+		if code != "" {
+			defaultCode = string(code)
+		}
+		payload = ErrorResponse{Error: ErrorDetail{Message: message, Type: errType, Code: defaultCode}}
+	}
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return []byte(fmt.Sprintf(`{"error":{"message":%q,"type":"server_error","code":"internal_server_error"}}`, message))
+	}
+	return data
+}
+
+// annotateUpstreamError adds code to an upstream payload that is already in the envelope of
+// dialect. Claude envelopes have no field for it; the ErrorCodeHeader carries it instead.
+func annotateUpstreamError(dialect Dialect, code ErrorCode, payload []byte) []byte {
+	if code == "" {
+		return payload
+	}
+	var out []byte
+	var err error
+	switch dialect {
+	case DialectOpenAI:
+		out, err = sjson.SetBytes(payload, "error.code", string(code))
+	case DialectGemini:
+		out, err = sjson.SetBytes(payload, "error.details.-1", map[string]any{
+			"@type":  "type.googleapis.com/google.rpc.ErrorInfo",
+			"reason": strings.ToUpper(string(code)),
+			"domain": "cliproxyapi",
+		})
+	default:
+		return payload
+	}
+	if err != nil {
+		return payload
+	}
+	return out
+}
+
+// matchesDialect reports whether payload is an error envelope of dialect.
+func matchesDialect(dialect Dialect, payload string) bool {
+	root := gjson.Parse(payload)
+	errNode := root.Get("error")
+	switch dialect {
+	case DialectClaude:
+		return root.Get("type").String() == "error" && errNode.Get("type").Exists()
+	case DialectGemini:
+		return errNode.IsObject() && errNode.Get("status").Type == gjson.String
+	default:
+		return errNode.IsObject() && root.Get("type").String() != "error" &&
+			errNode.Get("message").Exists() && !errNode.Get("status").Exists()
+	}
+}
+
+// upstreamErrorMessage extracts the human-readable message from a JSON error payload.
+func upstreamErrorMessage(payload string) string {
+	root := gjson.Parse(payload)
+	for _, path := range []string{"error.message", "message", "error.error.message", "detail"} {
+		if v := root.Get(path); v.Type == gjson.String && strings.TrimSpace(v.String()) != "" {
+			return strings.TrimSpace(v.String())
+		}
+	}
+	if v := root.Get("error"); v.Type == gjson.String {
+		return strings.TrimSpace(v.String())
+	}
+	// Array-wrapped Gemini errors: [{"error":{...}}].
+	if v := root.Get("0.error.message"); v.Type == gjson.String {
+		return strings.TrimSpace(v.String())
+	}
+	return ""
+}
+
+func openAIErrorType(status int) (string, string) {
+	switch status {
+	case http.StatusUnauthorized:
+		return "authentication_error", "invalid_api_key"
+	case http.StatusForbidden:
+		return "permission_error", "insufficient_quota"
+	case http.StatusTooManyRequests:
+		return "rate_limit_error", "rate_limit_exceeded"
+	case http.StatusNotFound:
+		return "invalid_request_error", "model_not_found"
+	}
+	if status >= http.StatusInternalServerError {
+		return "server_error", "internal_server_error"
+	}
+	return "invalid_request_error", ""
+}
+
+func claudeErrorType(status int) string {
+	switch status {
+	case http.StatusBadRequest:
+		return "invalid_request_error"
+	case http.StatusUnauthorized:
+		return "authentication_error"
+	case http.StatusForbidden:
+		return "permission_error"
+	case http.StatusNotFound:
+		return "not_found_error"
+	case http.StatusRequestEntityTooLarge:
+		return "request_too_large"
+	case http.StatusTooManyRequests:
+		return "rate_limit_error"
+	case http.StatusGatewayTimeout:
+		return "timeout_error"
+	case 529:
+		return "overloaded_error"
+	}
+	if status < http.StatusInternalServerError {
+		return "invalid_request_error"
+	}
+	return "api_error"
+}
+
+func geminiErrorStatus(status int) string {
+	switch status {
+	case http.StatusBadRequest:
+		return "INVALID_ARGUMENT"
+	case http.StatusUnauthorized:
+		return "UNAUTHENTICATED"
+	case http.StatusForbidden:
+		return "PERMISSION_DENIED"
+	case http.StatusNotFound:
+		return "NOT_FOUND"
+	case http.StatusConflict:
+		return "ABORTED"
+	case http.StatusTooManyRequests:
+		return "RESOURCE_EXHAUSTED"
+	case 499:
+		return "CANCELLED"
+	case http.StatusNotImplemented:
+		return "UNIMPLEMENTED"
+	case http.StatusBadGateway, http.StatusServiceUnavailable:
+		return "UNAVAILABLE"
+	case http.StatusGatewayTimeout:
+		return "DEADLINE_EXCEEDED"
+	}
+	if status < http.StatusInternalServerError {
+		return "FAILED_PRECONDITION"
+	}
+	return "INTERNAL"
+}
+
+// BuildErrorMessageBody classifies msg and renders it in the envelope of dialect, for errors
+// reported after a stream has started and headers can no longer change.
+func BuildErrorMessageBody(dialect Dialect, msg *interfaces.ErrorMessage) []byte {
+	code, status := ClassifyError(msg)
+	errText := http.StatusText(status)
+	if msg != nil && msg.Error != nil && msg.Error.Error() != "" {
+		errText = msg.Error.Error()
+	}
+	return BuildDialectErrorBody(dialect, status, code, errText)
+}
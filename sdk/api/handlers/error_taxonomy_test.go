@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	"github.com/tidwall/gjson"
+)
+
+func TestClassifyError(t *testing.T) {
+	cases := []struct {
+		name       string
+		msg        *interfaces.ErrorMessage
+		wantCode   ErrorCode
+		wantStatus int
+	}{
+		{"explicit code", &interfaces.ErrorMessage{StatusCode: http.StatusBadGateway, Error: errors.New("unknown provider"), Code: "no_provider"}, ErrorCodeNoProvider, http.StatusBadGateway},
+		{"auth not found", &interfaces.ErrorMessage{Error: &coreauth.Error{Code: "auth_not_found", Message: "no auth available"}}, ErrorCodeNoProvider, http.StatusBadGateway},
+		{"upstream 429", &interfaces.ErrorMessage{StatusCode: http.StatusTooManyRequests, Error: errors.New("slow down")}, ErrorCodeQuotaExceeded, http.StatusTooManyRequests},
+		{"deadline", &interfaces.ErrorMessage{StatusCode: http.StatusInternalServerError, Error: fmt.Errorf("request: %w", context.DeadlineExceeded)}, ErrorCodeUpstreamTimeout, http.StatusGatewayTimeout},
+		{"translation", &interfaces.ErrorMessage{Error: fmt.Errorf("%w: bad chunk", ErrTranslationFailed)}, ErrorCodeTranslationFailed, http.StatusBadGateway},
+		{"offline", &interfaces.ErrorMessage{StatusCode: http.StatusServiceUnavailable, Error: &coreauth.Error{Code: "offline", Message: "offline mode"}}, ErrorCodeOffline, http.StatusServiceUnavailable},
+		{"unclassified", &interfaces.ErrorMessage{StatusCode: http.StatusBadRequest, Error: errors.New("bad input")}, "", http.StatusBadRequest},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			code, status := ClassifyError(tc.msg)
+			if code != tc.wantCode || status != tc.wantStatus {
+				t.Fatalf("ClassifyError = (%q, %d), want (%q, %d)", code, status, tc.wantCode, tc.wantStatus)
+			}
+		})
+	}
+}
+
+func TestBuildDialectErrorBodyEnvelopes(t *testing.T) {
+	openai := gjson.ParseBytes(BuildDialectErrorBody(DialectOpenAI, http.StatusBadGateway, ErrorCodeNoProvider, "no provider for gpt-x"))
+	if openai.Get("error.code").String() != "no_provider" || openai.Get("error.type").String() != "server_error" || openai.Get("error.message").String() != "no provider for gpt-x" {
+		t.Errorf("openai envelope = %s", openai.Raw)
+	}
+
+	claude := gjson.ParseBytes(BuildDialectErrorBody(DialectClaude, http.StatusTooManyRequests, ErrorCodeQuotaExceeded, "cooling down"))
+	if claude.Get("type").String() != "error" || claude.Get("error.type").String() != "rate_limit_error" || claude.Get("error.message").String() != "cooling down" {
+		t.Errorf("claude envelope = %s", claude.Raw)
+	}
+
+	gemini := gjson.ParseBytes(BuildDialectErrorBody(DialectGemini, http.StatusGatewayTimeout, ErrorCodeUpstreamTimeout, "timed out"))
+	if gemini.Get("error.code").Int() != http.StatusGatewayTimeout || gemini.Get("error.status").String() != "DEADLINE_EXCEEDED" || gemini.Get("error.details.0.reason").String() != "UPSTREAM_TIMEOUT" {
+		t.Errorf("gemini envelope = %s", gemini.Raw)
+	}
+}
+
+func TestBuildDialectErrorBodyUpstreamPayloads(t *testing.T) {
+	claudeUpstream := `{"type":"error","error":{"type":"overloaded_error","message":"Overloaded"}}`
+
+	// Same dialect: the upstream payload is kept.
+	if got := string(BuildDialectErrorBody(DialectClaude, 529, "", claudeUpstream)); got != claudeUpstream {
+		t.Errorf("claude passthrough = %s", got)
+	}
+
+	// Other dialect: re-wrapped around the upstream message.
+	openai := gjson.ParseBytes(BuildDialectErrorBody(DialectOpenAI, http.StatusServiceUnavailable, "", claudeUpstream))
+	if openai.Get("type").Exists() || openai.Get("error.message").String() != "Overloaded" || openai.Get("error.type").String() != "server_error" {
+		t.Errorf("claude payload for openai client = %s", openai.Raw)
+	}
+
+	// Same dialect with a taxonomy code: details are kept and the code is added.
+	geminiUpstream := `{"error":{"code":429,"message":"Quota","status":"RESOURCE_EXHAUSTED","details":[{"@type":"type.googleapis.com/google.rpc.RetryInfo","retryDelay":"3s"}]}}`
+	gemini := gjson.ParseBytes(BuildDialectErrorBody(DialectGemini, http.StatusTooManyRequests, ErrorCodeQuotaExceeded, geminiUpstream))
+	if gemini.Get("error.details.0.retryDelay").String() != "3s" || gemini.Get("error.details.1.reason").String() != "QUOTA_EXCEEDED" {
+		t.Errorf("annotated gemini payload = %s", gemini.Raw)
+	}
+}
+
+func TestWriteDialectErrorResponseAppliesHint(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/messages", nil)
+	SetErrorHint(c, ErrorHint{Code: ErrorCodeMappingTargetUnavailable, Message: "model mapping a -> b has no available provider for b"})
+
+	h := &BaseAPIHandler{}
+	h.WriteDialectErrorResponse(c, DialectClaude, &interfaces.ErrorMessage{
+		StatusCode: http.StatusBadGateway,
+		Error:      errors.New("unknown provider for model b"),
+		Code:       string(ErrorCodeNoProvider),
+	})
+
+	if recorder.Code != http.StatusBadGateway {
+		t.Fatalf("status = %d, want 502", recorder.Code)
+	}
+	if got := recorder.Header().Get(ErrorCodeHeader); got != string(ErrorCodeMappingTargetUnavailable) {
+		t.Fatalf("%s = %q", ErrorCodeHeader, got)
+	}
+	body := gjson.ParseBytes(recorder.Body.Bytes())
+	if body.Get("type").String() != "error" || body.Get("error.message").String() != "model mapping a -> b has no available provider for b" {
+		t.Fatalf("body = %s", recorder.Body.String())
+	}
+}
@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	"github.com/tidwall/sjson"
+)
+
+// ModelResult is the outcome of one model of ExecuteModels.
+type ModelResult struct {
+	Model   string
+	Payload []byte
+	Err     *interfaces.ErrorMessage
+	Latency time.Duration
+}
+
+// ExecuteModels sends the same non-streaming request to every model concurrently and returns
+// the results in model order. The "model" field of rawJSON is replaced for each model.
+// Unlike ExecuteWithAuthManager it bypasses the Idempotency-Key cache and race rules, since
+// every model is an independent request.
+func (h *BaseAPIHandler) ExecuteModels(ctx context.Context, handlerType string, models []string, rawJSON []byte, alt string) []ModelResult {
+	results := make([]ModelResult, len(models))
+	var wg sync.WaitGroup
+	for i, model := range models {
+		wg.Add(1)
+		go func(i int, model string) {
+			defer wg.Done()
+			payload := rawJSON
+			if updated, err := sjson.SetBytes(rawJSON, "model", model); err == nil {
+				payload = updated
+			}
+			start := time.Now()
+			resp, errMsg := h.executeWithAuthManager(ctx, handlerType, model, payload, alt)
+			results[i] = ModelResult{Model: model, Payload: resp, Err: errMsg, Latency: time.Since(start)}
+		}(i, model)
+	}
+	wg.Wait()
+	for i := range results {
+		if results[i].Err == nil {
+			results[i].Payload = h.applyOutputGuardrails(ctx, results[i].Payload)
+		}
+	}
+	return results
+}
@@ -7,6 +7,7 @@ package gemini
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -50,11 +51,9 @@ func (h *GeminiCLIAPIHandler) Models() []map[string]any {
 // It restricts access to localhost only and routes requests to appropriate internal handlers.
 func (h *GeminiCLIAPIHandler) CLIHandler(c *gin.Context) {
 	if !strings.HasPrefix(c.Request.RemoteAddr, "127.0.0.1:") {
-		c.JSON(http.StatusForbidden, handlers.ErrorResponse{
-			Error: handlers.ErrorDetail{
-				Message: "CLI reply only allow local access",
-				Type:    "forbidden",
-			},
+		h.WriteDialectErrorResponse(c, handlers.DialectGemini, &interfaces.ErrorMessage{
+			StatusCode: http.StatusForbidden,
+			Error:      errors.New("CLI reply only allow local access"),
 		})
 		return
 	}
@@ -70,11 +69,9 @@ func (h *GeminiCLIAPIHandler) CLIHandler(c *gin.Context) {
 		reqBody := bytes.NewBuffer(rawJSON)
 		req, err := http.NewRequest("POST", fmt.Sprintf("https://cloudcode-pa.googleapis.com%s", c.Request.URL.RequestURI()), reqBody)
 		if err != nil {
-			c.JSON(http.StatusBadRequest, handlers.ErrorResponse{
-				Error: handlers.ErrorDetail{
-					Message: fmt.Sprintf("Invalid request: %v", err),
-					Type:    "invalid_request_error",
-				},
+			h.WriteDialectErrorResponse(c, handlers.DialectGemini, &interfaces.ErrorMessage{
+				StatusCode: http.StatusBadRequest,
+				Error:      fmt.Errorf("invalid request: %w", err),
 			})
 			return
 		}
@@ -86,11 +83,9 @@ func (h *GeminiCLIAPIHandler) CLIHandler(c *gin.Context) {
 
 		resp, err := httpClient.Do(req)
 		if err != nil {
-			c.JSON(http.StatusBadRequest, handlers.ErrorResponse{
-				Error: handlers.ErrorDetail{
-					Message: fmt.Sprintf("Invalid request: %v", err),
-					Type:    "invalid_request_error",
-				},
+			h.WriteDialectErrorResponse(c, handlers.DialectGemini, &interfaces.ErrorMessage{
+				StatusCode: http.StatusBadRequest,
+				Error:      fmt.Errorf("invalid request: %w", err),
 			})
 			return
 		}
@@ -103,11 +98,9 @@ func (h *GeminiCLIAPIHandler) CLIHandler(c *gin.Context) {
 			}()
 			bodyBytes, _ := io.ReadAll(resp.Body)
 
-			c.JSON(http.StatusBadRequest, handlers.ErrorResponse{
-				Error: handlers.ErrorDetail{
-					Message: string(bodyBytes),
-					Type:    "invalid_request_error",
-				},
+			h.WriteDialectErrorResponse(c, handlers.DialectGemini, &interfaces.ErrorMessage{
+				StatusCode: http.StatusBadRequest,
+				Error:      errors.New(string(bodyBytes)),
 			})
 			return
 		}
@@ -140,17 +133,14 @@ func (h *GeminiCLIAPIHandler) handleInternalStreamGenerateContent(c *gin.Context
 		c.Header("Content-Type", "text/event-stream")
 		c.Header("Cache-Control", "no-cache")
 		c.Header("Connection", "keep-alive")
-		c.Header("Access-Control-Allow-Origin", "*")
 	}
 
 	// Get the http.Flusher interface to manually flush the response.
 	flusher, ok := c.Writer.(http.Flusher)
 	if !ok {
-		c.JSON(http.StatusInternalServerError, handlers.ErrorResponse{
-			Error: handlers.ErrorDetail{
-				Message: "Streaming not supported",
-				Type:    "server_error",
-			},
+		h.WriteDialectErrorResponse(c, handlers.DialectGemini, &interfaces.ErrorMessage{
+			StatusCode: http.StatusInternalServerError,
+			Error:      errors.New("streaming not supported"),
 		})
 		return
 	}
@@ -174,7 +164,7 @@ func (h *GeminiCLIAPIHandler) handleInternalGenerateContent(c *gin.Context, rawJ
 	cliCtx, cliCancel := h.GetContextWithCancel(h, c, context.Background())
 	resp, errMsg := h.ExecuteWithAuthManager(cliCtx, h.HandlerType(), modelName, rawJSON, "")
 	if errMsg != nil {
-		h.WriteErrorResponse(c, errMsg)
+		h.WriteDialectErrorResponse(c, handlers.DialectGemini, errMsg)
 		cliCancel(errMsg.Error)
 		return
 	}
@@ -211,15 +201,7 @@ func (h *GeminiCLIAPIHandler) forwardCLIStream(c *gin.Context, flusher http.Flus
 			if errMsg == nil {
 				return
 			}
-			status := http.StatusInternalServerError
-			if errMsg.StatusCode > 0 {
-				status = errMsg.StatusCode
-			}
-			errText := http.StatusText(status)
-			if errMsg.Error != nil && errMsg.Error.Error() != "" {
-				errText = errMsg.Error.Error()
-			}
-			body := handlers.BuildErrorResponseBody(status, errText)
+			body := handlers.BuildErrorMessageBody(handlers.DialectGemini, errMsg)
 			if alt == "" {
 				_, _ = fmt.Fprintf(c.Writer, "event: error\ndata: %s\n\n", string(body))
 			} else {
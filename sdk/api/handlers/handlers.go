@@ -5,7 +5,7 @@ package handlers
 
 import (
 	"bytes"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"strings"
@@ -22,6 +22,7 @@ import (
 	coreexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
 	"github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
 	sdktranslator "github.com/router-for-me/CLIProxyAPI/v6/sdk/translator"
+	log "github.com/sirupsen/logrus"
 	"golang.org/x/net/context"
 )
 
@@ -47,59 +48,23 @@ type ErrorDetail struct {
 
 const idempotencyKeyMetadataKey = "idempotency_key"
 
+// PriorityHeader lets clients choose the scheduling priority class ("low", "normal", "high")
+// of a request whose API key has none configured.
+const PriorityHeader = "X-CLIProxy-Priority"
+
+// statusClientClosedRequest reports requests abandoned by the client (nginx convention).
+const statusClientClosedRequest = 499
+
 const (
 	defaultStreamingKeepAliveSeconds = 0
 	defaultStreamingBootstrapRetries = 0
 )
 
 // BuildErrorResponseBody builds an OpenAI-compatible JSON error response body.
-// If errText is already valid JSON, it is returned as-is to preserve upstream error payloads.
+// Upstream payloads that already use the OpenAI envelope are returned as-is; other JSON
+// payloads are re-wrapped around their message.
 func BuildErrorResponseBody(status int, errText string) []byte {
-	if status <= 0 {
-		status = http.StatusInternalServerError
-	}
-	if strings.TrimSpace(errText) == "" {
-		errText = http.StatusText(status)
-	}
-
-	trimmed := strings.TrimSpace(errText)
-	if trimmed != "" && json.Valid([]byte(trimmed)) {
-		return []byte(trimmed)
-	}
-
-	errType := "invalid_request_error"
-	var code string
-	switch status {
-	case http.StatusUnauthorized:
-		errType = "authentication_error"
-		code = "invalid_api_key"
-	case http.StatusForbidden:
-		errType = "permission_error"
-		code = "insufficient_quota"
-	case http.StatusTooManyRequests:
-		errType = "rate_limit_error"
-		code = "rate_limit_exceeded"
-	case http.StatusNotFound:
-		errType = "invalid_request_error"
-		code = "model_not_found"
-	default:
-		if status >= http.StatusInternalServerError {
-			errType = "server_error"
-			code = "internal_server_error"
-		}
-	}
-
-	payload, err := json.Marshal(ErrorResponse{
-		Error: ErrorDetail{
-			Message: errText,
-			Type:    errType,
-			Code:    code,
-		},
-	})
-	if err != nil {
-		return []byte(fmt.Sprintf(`{"error":{"message":%q,"type":"server_error","code":"internal_server_error"}}`, errText))
-	}
-	return payload
+	return BuildDialectErrorBody(DialectOpenAI, status, "", errText)
 }
 
 // StreamingKeepAliveInterval returns the SSE keep-alive interval for this server.
@@ -140,7 +105,7 @@ func StreamingBootstrapRetries(cfg *config.SDKConfig) int {
 	return retries
 }
 
-func requestExecutionMetadata(ctx context.Context) map[string]any {
+func requestExecutionMetadata(ctx context.Context, cfg *config.SDKConfig) map[string]any {
 	// Idempotency-Key is an optional client-supplied header used to correlate retries.
 	// It is forwarded as execution metadata; when absent we generate a UUID.
 	key := ""
@@ -152,7 +117,29 @@ func requestExecutionMetadata(ctx context.Context) map[string]any {
 	if key == "" {
 		key = uuid.NewString()
 	}
-	return map[string]any{idempotencyKeyMetadataKey: key}
+	_, priority := requestPriority(ctx, cfg)
+	return map[string]any{
+		idempotencyKeyMetadataKey:        key,
+		coreexecutor.PriorityMetadataKey: priority,
+	}
+}
+
+// requestPriority returns the client API key of the request and its scheduling priority class.
+func requestPriority(ctx context.Context, cfg *config.SDKConfig) (apiKey, priority string) {
+	priorityHeader := ""
+	if ctx != nil {
+		if ginCtx, ok := ctx.Value("gin").(*gin.Context); ok && ginCtx != nil && ginCtx.Request != nil {
+			priorityHeader = ginCtx.GetHeader(PriorityHeader)
+			if value, exists := ginCtx.Get("apiKey"); exists {
+				apiKey, _ = value.(string)
+			}
+		}
+	}
+	var scheduling config.SchedulingConfig
+	if cfg != nil {
+		scheduling = cfg.Scheduling
+	}
+	return apiKey, scheduling.PriorityFor(apiKey, priorityHeader)
 }
 
 func mergeMetadata(base, overlay map[string]any) map[string]any {
@@ -178,6 +165,18 @@ type BaseAPIHandler struct {
 
 	// Cfg holds the current application configuration.
 	Cfg *config.SDKConfig
+
+	// idempotency replays responses of retried Idempotency-Key requests.
+	idempotency *idempotencyCache
+
+	// guardrails holds the compiled output guardrail rules.
+	guardrails *guardrailCache
+
+	// semantic serves responses cached for similar prompts.
+	semantic *semanticCache
+
+	// tools brokers the tools of the configured MCP servers.
+	tools *toolBroker
 }
 
 // NewBaseAPIHandlers creates a new API handlers instance.
@@ -193,6 +192,10 @@ func NewBaseAPIHandlers(cfg *config.SDKConfig, authManager *coreauth.Manager) *B
 	return &BaseAPIHandler{
 		Cfg:         cfg,
 		AuthManager: authManager,
+		idempotency: newIdempotencyCache(),
+		guardrails:  newGuardrailCache(),
+		semantic:    newSemanticCache(),
+		tools:       newToolBroker(),
 	}
 }
 
@@ -265,6 +268,7 @@ func (h *BaseAPIHandler) GetContextWithCancel(handler interfaces.APIHandler, c *
 			}
 		}()
 	}
+	wrapRateLimitHeaders(c)
 	newCtx = context.WithValue(newCtx, "gin", c)
 	newCtx = context.WithValue(newCtx, "handler", handler)
 	return newCtx, func(params ...interface{}) {
@@ -314,7 +318,7 @@ func (h *BaseAPIHandler) StartNonStreamingKeepAlive(c *gin.Context, ctx context.
 	if h == nil || c == nil {
 		return func() {}
 	}
-	interval := NonStreamingKeepAliveInterval(h.Cfg)
+	interval := h.nonStreamingKeepAliveInterval(c)
 	if interval <= 0 {
 		return func() {}
 	}
@@ -383,13 +387,42 @@ func appendAPIResponse(c *gin.Context, data []byte) {
 }
 
 // ExecuteWithAuthManager executes a non-streaming request via the core auth manager.
-// This path is the only supported execution route.
+// This path is the only supported execution route. Model prefixes of the client profile
+// are stripped first, and requests without a model are served by the default model of
+// their API key. In the recording replay modes stored responses are served instead.
+// Requests carrying an Idempotency-Key header are answered from the idempotency cache when
+// configured, and tool calls to configured MCP servers are executed by the proxy.
+// Content-policy refusals are retried once on the configured fallback model; streaming
+// requests are not retried, as a refusal is only seen after the stream has started.
+// Output guardrails are applied to the response.
 func (h *BaseAPIHandler) ExecuteWithAuthManager(ctx context.Context, handlerType, modelName string, rawJSON []byte, alt string) ([]byte, *interfaces.ErrorMessage) {
+	modelName, rawJSON = h.requestModel(ctx, handlerType, modelName, rawJSON)
+	resp, errMsg := h.executeRecorded(ctx, handlerType, modelName, rawJSON, alt, func() ([]byte, *interfaces.ErrorMessage) {
+		return h.executeIdempotent(ctx, handlerType, modelName, rawJSON, alt, func() ([]byte, *interfaces.ErrorMessage) {
+			return h.executeSemanticCached(ctx, handlerType, modelName, rawJSON, alt, func() ([]byte, *interfaces.ErrorMessage) {
+				return h.executeToolBrokered(ctx, handlerType, rawJSON, func(request []byte) ([]byte, *interfaces.ErrorMessage) {
+					return h.executeRefusalFallback(ctx, h.contextFitModel(ctx, handlerType, modelName, request, alt), func(model string) ([]byte, *interfaces.ErrorMessage) {
+						if targets := h.raceTargets(model); len(targets) > 1 {
+							return h.executeRace(ctx, handlerType, targets, request, alt)
+						}
+						return h.executeWithAuthManager(ctx, handlerType, model, request, alt)
+					})
+				})
+			})
+		})
+	})
+	if errMsg != nil {
+		return nil, errMsg
+	}
+	return h.applyOutputGuardrails(ctx, resp), nil
+}
+
+func (h *BaseAPIHandler) executeWithAuthManager(ctx context.Context, handlerType, modelName string, rawJSON []byte, alt string) ([]byte, *interfaces.ErrorMessage) {
 	providers, normalizedModel, errMsg := h.getRequestDetails(modelName)
 	if errMsg != nil {
 		return nil, errMsg
 	}
-	reqMeta := requestExecutionMetadata(ctx)
+	reqMeta := requestExecutionMetadata(ctx, h.Cfg)
 	reqMeta[coreexecutor.RequestedModelMetadataKey] = normalizedModel
 	req := coreexecutor.Request{
 		Model:   normalizedModel,
@@ -402,9 +435,13 @@ func (h *BaseAPIHandler) ExecuteWithAuthManager(ctx context.Context, handlerType
 		SourceFormat:    sdktranslator.FromString(handlerType),
 	}
 	opts.Metadata = reqMeta
+	ctx = withThinkingRequest(ctx, handlerType, normalizedModel, rawJSON)
 	resp, err := h.AuthManager.Execute(ctx, providers, req, opts)
 	if err != nil {
 		status := http.StatusInternalServerError
+		if errors.Is(err, context.Canceled) {
+			status = statusClientClosedRequest
+		}
 		if se, ok := err.(interface{ StatusCode() int }); ok && se != nil {
 			if code := se.StatusCode(); code > 0 {
 				status = code
@@ -424,11 +461,12 @@ func (h *BaseAPIHandler) ExecuteWithAuthManager(ctx context.Context, handlerType
 // ExecuteCountWithAuthManager executes a non-streaming request via the core auth manager.
 // This path is the only supported execution route.
 func (h *BaseAPIHandler) ExecuteCountWithAuthManager(ctx context.Context, handlerType, modelName string, rawJSON []byte, alt string) ([]byte, *interfaces.ErrorMessage) {
+	modelName, rawJSON = h.requestModel(ctx, handlerType, modelName, rawJSON)
 	providers, normalizedModel, errMsg := h.getRequestDetails(modelName)
 	if errMsg != nil {
 		return nil, errMsg
 	}
-	reqMeta := requestExecutionMetadata(ctx)
+	reqMeta := requestExecutionMetadata(ctx, h.Cfg)
 	reqMeta[coreexecutor.RequestedModelMetadataKey] = normalizedModel
 	req := coreexecutor.Request{
 		Model:   normalizedModel,
@@ -444,6 +482,9 @@ func (h *BaseAPIHandler) ExecuteCountWithAuthManager(ctx context.Context, handle
 	resp, err := h.AuthManager.ExecuteCount(ctx, providers, req, opts)
 	if err != nil {
 		status := http.StatusInternalServerError
+		if errors.Is(err, context.Canceled) {
+			status = statusClientClosedRequest
+		}
 		if se, ok := err.(interface{ StatusCode() int }); ok && se != nil {
 			if code := se.StatusCode(); code > 0 {
 				status = code
@@ -461,8 +502,29 @@ func (h *BaseAPIHandler) ExecuteCountWithAuthManager(ctx context.Context, handle
 }
 
 // ExecuteStreamWithAuthManager executes a streaming request via the core auth manager.
-// This path is the only supported execution route.
+// This path is the only supported execution route. Models with a speculative race rule
+// stream from whichever target answers first. Output guardrails are applied to the
+// streamed chunks, which are paced to the stream throttle rate of the client. In the
+// recording replay modes stored streams are served instead.
 func (h *BaseAPIHandler) ExecuteStreamWithAuthManager(ctx context.Context, handlerType, modelName string, rawJSON []byte, alt string) (<-chan []byte, <-chan *interfaces.ErrorMessage) {
+	modelName, rawJSON = h.requestModel(ctx, handlerType, modelName, rawJSON)
+	if dataChan, errChan, ok := h.replayStream(ctx, handlerType, modelName, rawJSON, alt); ok {
+		return h.throttleStream(ctx, h.guardStream(ctx, dataChan)), errChan
+	}
+	var dataChan <-chan []byte
+	var errChan <-chan *interfaces.ErrorMessage
+	ctx, collector := h.streamUsageContext(ctx)
+	model := h.contextFitModel(ctx, handlerType, modelName, rawJSON, alt)
+	if targets := h.raceTargets(model); len(targets) > 1 {
+		dataChan, errChan = h.executeStreamRace(ctx, handlerType, targets, rawJSON, alt)
+	} else {
+		dataChan, errChan = h.executeStreamWithAuthManager(ctx, handlerType, model, rawJSON, alt)
+	}
+	dataChan, errChan = h.recordStream(ctx, handlerType, modelName, rawJSON, alt, dataChan, errChan)
+	return h.throttleStream(ctx, injectStreamUsage(ctx, collector, handlerType, rawJSON, h.guardStream(ctx, dataChan))), errChan
+}
+
+func (h *BaseAPIHandler) executeStreamWithAuthManager(ctx context.Context, handlerType, modelName string, rawJSON []byte, alt string) (<-chan []byte, <-chan *interfaces.ErrorMessage) {
 	providers, normalizedModel, errMsg := h.getRequestDetails(modelName)
 	if errMsg != nil {
 		errChan := make(chan *interfaces.ErrorMessage, 1)
@@ -470,7 +532,7 @@ func (h *BaseAPIHandler) ExecuteStreamWithAuthManager(ctx context.Context, handl
 		close(errChan)
 		return nil, errChan
 	}
-	reqMeta := requestExecutionMetadata(ctx)
+	reqMeta := requestExecutionMetadata(ctx, h.Cfg)
 	reqMeta[coreexecutor.RequestedModelMetadataKey] = normalizedModel
 	req := coreexecutor.Request{
 		Model:   normalizedModel,
@@ -483,10 +545,14 @@ func (h *BaseAPIHandler) ExecuteStreamWithAuthManager(ctx context.Context, handl
 		SourceFormat:    sdktranslator.FromString(handlerType),
 	}
 	opts.Metadata = reqMeta
+	ctx = withThinkingRequest(ctx, handlerType, normalizedModel, rawJSON)
 	chunks, err := h.AuthManager.ExecuteStream(ctx, providers, req, opts)
 	if err != nil {
 		errChan := make(chan *interfaces.ErrorMessage, 1)
 		status := http.StatusInternalServerError
+		if errors.Is(err, context.Canceled) {
+			status = statusClientClosedRequest
+		}
 		if se, ok := err.(interface{ StatusCode() int }); ok && se != nil {
 			if code := se.StatusCode(); code > 0 {
 				status = code
@@ -509,7 +575,7 @@ func (h *BaseAPIHandler) ExecuteStreamWithAuthManager(ctx context.Context, handl
 		defer close(errChan)
 		sentPayload := false
 		bootstrapRetries := 0
-		maxBootstrapRetries := StreamingBootstrapRetries(h.Cfg)
+		maxBootstrapRetries := h.streamingBootstrapRetries(ctx)
 
 		sendErr := func(msg *interfaces.ErrorMessage) bool {
 			if ctx == nil {
@@ -585,6 +651,9 @@ func (h *BaseAPIHandler) ExecuteStreamWithAuthManager(ctx context.Context, handl
 					}
 
 					status := http.StatusInternalServerError
+					if errors.Is(streamErr, context.Canceled) {
+						status = statusClientClosedRequest
+					}
 					if se, ok := streamErr.(interface{ StatusCode() int }); ok && se != nil {
 						if code := se.StatusCode(); code > 0 {
 							status = code
@@ -646,12 +715,21 @@ func (h *BaseAPIHandler) getRequestDetails(modelName string) (providers []string
 	// may be registered with their full suffixed name (e.g., "my-model(8192)").
 	// Evaluated in Story 11.8: This fallback is intentionally preserved to support
 	// custom model registrations that include thinking suffixes.
+	suffixInName := false
 	if len(providers) == 0 && baseModel != resolvedModelName {
 		providers = util.GetProviderName(resolvedModelName)
+		suffixInName = len(providers) > 0
 	}
 
 	if len(providers) == 0 {
-		return nil, "", &interfaces.ErrorMessage{StatusCode: http.StatusBadGateway, Error: fmt.Errorf("unknown provider for model %s", modelName)}
+		return nil, "", &interfaces.ErrorMessage{StatusCode: http.StatusBadGateway, Error: fmt.Errorf("unknown provider for model %s", modelName), Code: string(ErrorCodeNoProvider)}
+	}
+
+	if parsed.HasSuffix && !suffixInName && !thinking.IsKnownSuffix(parsed.RawSuffix) {
+		resolvedModelName, err = h.resolveUnknownSuffix(resolvedModelName, baseModel, parsed.RawSuffix)
+		if err != nil {
+			return nil, "", err
+		}
 	}
 
 	// The thinking suffix is preserved in the model name itself, so no
@@ -659,6 +737,36 @@ func (h *BaseAPIHandler) getRequestDetails(modelName string) (providers []string
 	return providers, resolvedModelName, nil
 }
 
+// resolveUnknownSuffix applies the unknown-thinking-suffix policy to a model whose thinking
+// suffix rawSuffix is not recognized, returning the model name to serve.
+func (h *BaseAPIHandler) resolveUnknownSuffix(modelName, baseModel, rawSuffix string) (string, *interfaces.ErrorMessage) {
+	policy := ""
+	if h.Cfg != nil {
+		policy = h.Cfg.UnknownThinkingSuffix
+	}
+	switch policy {
+	case config.UnknownSuffixStrip:
+		log.Warnf("model %s: unknown thinking suffix %q stripped", modelName, rawSuffix)
+		return baseModel, nil
+	case config.UnknownSuffixReject:
+		return "", &interfaces.ErrorMessage{
+			StatusCode: http.StatusBadRequest,
+			Error: fmt.Errorf("unknown thinking suffix %q in model %s; supported suffixes: %s",
+				rawSuffix, modelName, strings.Join(thinking.SupportedSuffixes(), ", ")),
+			Code: string(ErrorCodeUnknownThinkingSuffix),
+		}
+	case config.UnknownSuffixNearest:
+		if nearest, ok := thinking.NearestSuffix(rawSuffix); ok {
+			log.Debugf("model %s: unknown thinking suffix %q mapped to %q", modelName, rawSuffix, nearest)
+			return fmt.Sprintf("%s(%s)", baseModel, nearest), nil
+		}
+		log.Warnf("model %s: unknown thinking suffix %q has no close match, stripped", modelName, rawSuffix)
+		return baseModel, nil
+	default:
+		return modelName, nil
+	}
+}
+
 func cloneBytes(src []byte) []byte {
 	if len(src) == 0 {
 		return nil
@@ -679,11 +787,27 @@ func cloneMetadata(src map[string]any) map[string]any {
 	return dst
 }
 
-// WriteErrorResponse writes an error message to the response writer using the HTTP status embedded in the message.
+// WriteErrorResponse writes an error message in the OpenAI error envelope. See
+// WriteDialectErrorResponse.
 func (h *BaseAPIHandler) WriteErrorResponse(c *gin.Context, msg *interfaces.ErrorMessage) {
-	status := http.StatusInternalServerError
-	if msg != nil && msg.StatusCode > 0 {
-		status = msg.StatusCode
+	h.WriteDialectErrorResponse(c, DialectOpenAI, msg)
+}
+
+// WriteDialectErrorResponse writes an error message in the error envelope of dialect. Errors
+// in the ErrorCode taxonomy use the status assigned to their code and report the code in the
+// ErrorCodeHeader; other errors keep the HTTP status embedded in the message.
+func (h *BaseAPIHandler) WriteDialectErrorResponse(c *gin.Context, dialect Dialect, msg *interfaces.ErrorMessage) {
+	code, status := ClassifyError(msg)
+	hintMessage := ""
+	if code == ErrorCodeNoProvider {
+		if hint, ok := c.Get(errorHintContextKey); ok {
+			if hinted, okHint := hint.(ErrorHint); okHint && hinted.Code != "" {
+				code, hintMessage = hinted.Code, hinted.Message
+				if mapped := code.StatusFor(); mapped > 0 {
+					status = mapped
+				}
+			}
+		}
 	}
 	if msg != nil && msg.Addon != nil {
 		for key, values := range msg.Addon {
@@ -697,14 +821,22 @@ func (h *BaseAPIHandler) WriteErrorResponse(c *gin.Context, msg *interfaces.Erro
 		}
 	}
 
+	h.setProfileRetryAfter(c, status)
+
 	errText := http.StatusText(status)
 	if msg != nil && msg.Error != nil {
 		if v := strings.TrimSpace(msg.Error.Error()); v != "" {
 			errText = v
 		}
 	}
+	if hintMessage != "" {
+		errText = hintMessage
+	}
+	if code != "" {
+		c.Writer.Header().Set(ErrorCodeHeader, string(code))
+	}
 
-	body := BuildErrorResponseBody(status, errText)
+	body := BuildDialectErrorBody(dialect, status, code, errText)
 	// Append first to preserve upstream response logs, then drop duplicate payloads if already recorded.
 	var previous []byte
 	if existing, exists := c.Get("API_RESPONSE"); exists {
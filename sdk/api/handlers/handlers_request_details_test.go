@@ -116,3 +116,44 @@ func TestGetRequestDetails_PreservesSuffix(t *testing.T) {
 		})
 	}
 }
+
+func TestGetRequestDetails_UnknownSuffixPolicy(t *testing.T) {
+	modelRegistry := registry.GetGlobalRegistry()
+	modelRegistry.RegisterClient("test-request-details-unknown-suffix", "openai", []*registry.ModelInfo{
+		{ID: "gpt-5.2", Created: time.Now().Unix()},
+	})
+	t.Cleanup(func() { modelRegistry.UnregisterClient("test-request-details-unknown-suffix") })
+
+	tests := []struct {
+		policy    string
+		input     string
+		wantModel string
+		wantCode  ErrorCode
+	}{
+		{policy: "", input: "gpt-5.2(ultra)", wantModel: "gpt-5.2(ultra)"},
+		{policy: sdkconfig.UnknownSuffixStrip, input: "gpt-5.2(ultra)", wantModel: "gpt-5.2"},
+		{policy: sdkconfig.UnknownSuffixReject, input: "gpt-5.2(ultra)", wantCode: ErrorCodeUnknownThinkingSuffix},
+		{policy: sdkconfig.UnknownSuffixReject, input: "gpt-5.2(high)", wantModel: "gpt-5.2(high)"},
+		{policy: sdkconfig.UnknownSuffixNearest, input: "gpt-5.2(ultra)", wantModel: "gpt-5.2(xhigh)"},
+		{policy: sdkconfig.UnknownSuffixNearest, input: "gpt-5.2(meduim)", wantModel: "gpt-5.2(medium)"},
+		{policy: sdkconfig.UnknownSuffixNearest, input: "gpt-5.2(16k)", wantModel: "gpt-5.2(16384)"},
+		{policy: sdkconfig.UnknownSuffixNearest, input: "gpt-5.2(banana)", wantModel: "gpt-5.2"},
+	}
+	for _, tt := range tests {
+		handler := NewBaseAPIHandlers(&sdkconfig.SDKConfig{UnknownThinkingSuffix: tt.policy}, coreauth.NewManager(nil, nil, nil))
+		_, model, errMsg := handler.getRequestDetails(tt.input)
+		if tt.wantCode != "" {
+			if errMsg == nil || ErrorCode(errMsg.Code) != tt.wantCode {
+				t.Errorf("policy %q, %s: error = %v, want %s", tt.policy, tt.input, errMsg, tt.wantCode)
+			}
+			continue
+		}
+		if errMsg != nil {
+			t.Errorf("policy %q, %s: unexpected error %v", tt.policy, tt.input, errMsg.Error)
+			continue
+		}
+		if model != tt.wantModel {
+			t.Errorf("policy %q, %s: model = %q, want %q", tt.policy, tt.input, model, tt.wantModel)
+		}
+	}
+}
@@ -0,0 +1,224 @@
+package handlers
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/spill"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+)
+
+// IdempotentReplayedHeader is set on responses replayed from the Idempotency-Key cache.
+const IdempotentReplayedHeader = "Idempotent-Replayed"
+
+// errIdempotencyKeyReused rejects a request whose Idempotency-Key already belongs to a
+// different request.
+var errIdempotencyKeyReused = errors.New("idempotency key was already used with a different request")
+
+// idempotencyEntry is one Idempotency-Key request. While the first request runs, done is open
+// and retries wait on it; afterwards payload, or spilled for responses larger than the
+// response-spill memory size, holds the response until expires.
+type idempotencyEntry struct {
+	fingerprint [sha256.Size]byte
+	done        chan struct{}
+	payload     []byte
+	spilled     *spill.Buffer
+	expires     time.Time
+}
+
+// response returns the cached response and whether the request succeeded. Callers must
+// hold the cache lock, since eviction releases the spill file.
+func (e *idempotencyEntry) response() ([]byte, bool) {
+	if e.spilled != nil {
+		data, err := e.spilled.Bytes()
+		return data, err == nil
+	}
+	return cloneBytes(e.payload), e.payload != nil
+}
+
+// release drops the spill file of the entry.
+func (e *idempotencyEntry) release() {
+	if e.spilled != nil {
+		_ = e.spilled.Close()
+	}
+}
+
+// idempotencyCache keeps successful non-streaming responses by Idempotency-Key. Failed
+// requests are not cached, so a retry after an error runs again.
+type idempotencyCache struct {
+	mu      sync.Mutex
+	entries map[string]*idempotencyEntry
+	now     func() time.Time
+}
+
+func newIdempotencyCache() *idempotencyCache {
+	return &idempotencyCache{entries: make(map[string]*idempotencyEntry), now: time.Now}
+}
+
+// begin returns the entry for scope and whether the caller owns it and must run the request.
+// A completed entry with a different fingerprint means the key was reused for another request.
+func (ic *idempotencyCache) begin(scope string, fingerprint [sha256.Size]byte, maxEntries int) (entry *idempotencyEntry, owner, conflict bool) {
+	ic.mu.Lock()
+	defer ic.mu.Unlock()
+	now := ic.now()
+	if existing, ok := ic.entries[scope]; ok {
+		if existing.expires.IsZero() || now.Before(existing.expires) {
+			return existing, false, existing.fingerprint != fingerprint
+		}
+		existing.release()
+		delete(ic.entries, scope)
+	}
+	ic.evictLocked(now, maxEntries)
+	entry = &idempotencyEntry{fingerprint: fingerprint, done: make(chan struct{})}
+	ic.entries[scope] = entry
+	return entry, true, false
+}
+
+// finish completes an owned entry, keeping payload for window or dropping the entry when the
+// request failed.
+func (ic *idempotencyCache) finish(scope string, entry *idempotencyEntry, payload []byte, window time.Duration) {
+	ic.mu.Lock()
+	if payload == nil {
+		if ic.entries[scope] == entry {
+			delete(ic.entries, scope)
+		}
+	} else {
+		entry.payload = payload
+		if limit := spill.MemoryLimit(); limit > 0 && int64(len(payload)) > limit {
+			buf := spill.New()
+			if _, err := buf.Write(payload); err == nil {
+				entry.payload, entry.spilled = nil, buf
+			} else {
+				_ = buf.Close()
+			}
+		}
+		entry.expires = ic.now().Add(window)
+	}
+	ic.mu.Unlock()
+	close(entry.done)
+}
+
+// response returns the cached response of a completed entry.
+func (ic *idempotencyCache) response(entry *idempotencyEntry) ([]byte, bool) {
+	ic.mu.Lock()
+	defer ic.mu.Unlock()
+	return entry.response()
+}
+
+// evictLocked drops expired entries and, when the cache is still full, the completed entries
+// closest to expiry. Running requests are never evicted.
+func (ic *idempotencyCache) evictLocked(now time.Time, maxEntries int) {
+	for scope, entry := range ic.entries {
+		if !entry.expires.IsZero() && !now.Before(entry.expires) {
+			entry.release()
+			delete(ic.entries, scope)
+		}
+	}
+	for len(ic.entries) >= maxEntries {
+		oldestScope := ""
+		var oldest time.Time
+		for scope, entry := range ic.entries {
+			if entry.expires.IsZero() {
+				continue
+			}
+			if oldestScope == "" || entry.expires.Before(oldest) {
+				oldestScope, oldest = scope, entry.expires
+			}
+		}
+		if oldestScope == "" {
+			return
+		}
+		ic.entries[oldestScope].release()
+		delete(ic.entries, oldestScope)
+	}
+}
+
+// idempotencyScope returns the cache key of an Idempotency-Key request, scoped to the
+// authenticated client and endpoint so keys from different clients never collide. It returns
+// "" when the request carries no key.
+func idempotencyScope(ctx context.Context, handlerType string) (string, *gin.Context) {
+	ginCtx, ok := ctx.Value("gin").(*gin.Context)
+	if !ok || ginCtx == nil || ginCtx.Request == nil {
+		return "", nil
+	}
+	key := strings.TrimSpace(ginCtx.GetHeader("Idempotency-Key"))
+	if key == "" {
+		return "", ginCtx
+	}
+	principal := ""
+	if value, exists := ginCtx.Get("apiKey"); exists {
+		if s, okStr := value.(string); okStr {
+			principal = s
+		}
+	}
+	return principal + "\x00" + handlerType + "\x00" + ginCtx.FullPath() + "\x00" + key, ginCtx
+}
+
+func idempotencyFingerprint(modelName, alt string, rawJSON []byte) [sha256.Size]byte {
+	h := sha256.New()
+	h.Write([]byte(modelName))
+	h.Write([]byte{0})
+	h.Write([]byte(alt))
+	h.Write([]byte{0})
+	h.Write(rawJSON)
+	var sum [sha256.Size]byte
+	copy(sum[:], h.Sum(nil))
+	return sum
+}
+
+// executeIdempotent runs execute at most once per Idempotency-Key within the configured
+// window. Concurrent retries wait for the first request and share its response.
+func (h *BaseAPIHandler) executeIdempotent(ctx context.Context, handlerType, modelName string, rawJSON []byte, alt string, execute func() ([]byte, *interfaces.ErrorMessage)) ([]byte, *interfaces.ErrorMessage) {
+	var settings config.IdempotencyConfig
+	if h.Cfg != nil {
+		settings = h.Cfg.Idempotency
+	}
+	if h.idempotency == nil || settings.WindowSeconds <= 0 || ctx == nil {
+		return execute()
+	}
+	scope, ginCtx := idempotencyScope(ctx, handlerType)
+	if scope == "" {
+		return execute()
+	}
+	maxEntries := settings.MaxEntries
+	if maxEntries <= 0 {
+		maxEntries = config.DefaultIdempotencyMaxEntries
+	}
+	fingerprint := idempotencyFingerprint(modelName, alt, rawJSON)
+
+	for {
+		entry, owner, conflict := h.idempotency.begin(scope, fingerprint, maxEntries)
+		if conflict {
+			return nil, &interfaces.ErrorMessage{
+				StatusCode: http.StatusUnprocessableEntity,
+				Error:      errIdempotencyKeyReused,
+			}
+		}
+		if owner {
+			var payload []byte
+			defer func() { h.idempotency.finish(scope, entry, payload, time.Duration(settings.WindowSeconds)*time.Second) }()
+			resp, errMsg := execute()
+			if errMsg == nil {
+				payload = resp
+			}
+			return resp, errMsg
+		}
+		select {
+		case <-entry.done:
+		case <-ctx.Done():
+			return nil, &interfaces.ErrorMessage{StatusCode: http.StatusRequestTimeout, Error: ctx.Err()}
+		}
+		if payload, ok := h.idempotency.response(entry); ok {
+			ginCtx.Header(IdempotentReplayedHeader, "true")
+			return payload, nil
+		}
+		// The first request failed and was dropped; run this retry instead.
+	}
+}
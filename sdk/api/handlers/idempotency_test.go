@@ -0,0 +1,139 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+)
+
+func idempotentRequest(key string) (*gin.Context, *httptest.ResponseRecorder, context.Context) {
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+	if key != "" {
+		c.Request.Header.Set("Idempotency-Key", key)
+	}
+	c.Set("apiKey", "client-a")
+	return c, recorder, context.WithValue(context.Background(), "gin", c)
+}
+
+func newIdempotentHandler() *BaseAPIHandler {
+	return NewBaseAPIHandlers(&config.SDKConfig{Idempotency: config.IdempotencyConfig{WindowSeconds: 60}}, nil)
+}
+
+func TestExecuteIdempotentReplaysSuccess(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	h := newIdempotentHandler()
+	var calls int32
+	execute := func() ([]byte, *interfaces.ErrorMessage) {
+		atomic.AddInt32(&calls, 1)
+		return []byte(`{"id":"1"}`), nil
+	}
+
+	_, _, ctx := idempotentRequest("retry-1")
+	if _, errMsg := h.executeIdempotent(ctx, "openai", "gpt-x", []byte(`{}`), "", execute); errMsg != nil {
+		t.Fatalf("first request: %v", errMsg.Error)
+	}
+	c, _, ctx := idempotentRequest("retry-1")
+	resp, errMsg := h.executeIdempotent(ctx, "openai", "gpt-x", []byte(`{}`), "", execute)
+	if errMsg != nil || string(resp) != `{"id":"1"}` {
+		t.Fatalf("replay = %s, %v", resp, errMsg)
+	}
+	if calls != 1 {
+		t.Fatalf("execute ran %d times, want 1", calls)
+	}
+	if c.Writer.Header().Get(IdempotentReplayedHeader) != "true" {
+		t.Fatalf("missing %s header on replay", IdempotentReplayedHeader)
+	}
+
+	// A request without a key always runs.
+	_, _, ctx = idempotentRequest("")
+	_, _ = h.executeIdempotent(ctx, "openai", "gpt-x", []byte(`{}`), "", execute)
+	if calls != 2 {
+		t.Fatalf("request without key was not executed")
+	}
+}
+
+func TestExecuteIdempotentRejectsReusedKey(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	h := newIdempotentHandler()
+	execute := func() ([]byte, *interfaces.ErrorMessage) { return []byte(`{}`), nil }
+
+	_, _, ctx := idempotentRequest("retry-2")
+	_, _ = h.executeIdempotent(ctx, "openai", "gpt-x", []byte(`{"a":1}`), "", execute)
+	_, _, ctx = idempotentRequest("retry-2")
+	_, errMsg := h.executeIdempotent(ctx, "openai", "gpt-x", []byte(`{"a":2}`), "", execute)
+	if errMsg == nil || errMsg.StatusCode != http.StatusUnprocessableEntity {
+		t.Fatalf("reused key = %+v, want 422", errMsg)
+	}
+}
+
+func TestExecuteIdempotentRetriesAfterFailureAndSharesInFlight(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	h := newIdempotentHandler()
+
+	_, _, ctx := idempotentRequest("retry-3")
+	_, errMsg := h.executeIdempotent(ctx, "openai", "gpt-x", []byte(`{}`), "", func() ([]byte, *interfaces.ErrorMessage) {
+		return nil, &interfaces.ErrorMessage{StatusCode: http.StatusBadGateway, Error: errors.New("upstream failed")}
+	})
+	if errMsg == nil {
+		t.Fatal("expected the failure to be returned")
+	}
+
+	var calls int32
+	release := make(chan struct{})
+	execute := func() ([]byte, *interfaces.ErrorMessage) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		return []byte(`{"ok":true}`), nil
+	}
+	var wg sync.WaitGroup
+	results := make([]string, 3)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, _, ctx := idempotentRequest("retry-3")
+			resp, _ := h.executeIdempotent(ctx, "openai", "gpt-x", []byte(`{}`), "", execute)
+			results[i] = string(resp)
+		}(i)
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	if calls != 1 {
+		t.Fatalf("execute ran %d times for concurrent retries, want 1", calls)
+	}
+	for i, got := range results {
+		if got != `{"ok":true}` {
+			t.Fatalf("result %d = %q", i, got)
+		}
+	}
+}
+
+func TestIdempotencyCacheEvictsOldest(t *testing.T) {
+	ic := newIdempotencyCache()
+	now := time.Unix(0, 0)
+	ic.now = func() time.Time { return now }
+	for _, scope := range []string{"a", "b"} {
+		entry, _, _ := ic.begin(scope, [32]byte{}, 2)
+		ic.finish(scope, entry, []byte(scope), time.Minute)
+		now = now.Add(time.Second)
+	}
+	ic.begin("c", [32]byte{}, 2)
+	if _, ok := ic.entries["a"]; ok {
+		t.Fatal("oldest entry was not evicted")
+	}
+	if _, ok := ic.entries["b"]; !ok {
+		t.Fatal("newer entry was evicted")
+	}
+}
@@ -0,0 +1,315 @@
+// Package mcp exposes the proxy's models as a Model Context Protocol server. MCP clients
+// connect over the Streamable HTTP transport, list the available models and run chat
+// completions as tool calls, sharing the credential pool, model aliases and routing of the
+// regular API endpoints.
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/buildinfo"
+	. "github.com/router-for-me/CLIProxyAPI/v6/internal/constant"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers"
+	"github.com/tidwall/gjson"
+)
+
+// LatestProtocolVersion is the MCP revision offered to clients requesting an unknown one.
+const LatestProtocolVersion = "2025-06-18"
+
+var supportedProtocolVersions = map[string]bool{
+	"2024-11-05":          true,
+	"2025-03-26":          true,
+	LatestProtocolVersion: true,
+}
+
+// JSON-RPC error codes used by MCP.
+const (
+	codeParseError     = -32700
+	codeInvalidRequest = -32600
+	codeMethodNotFound = -32601
+	codeInvalidParams  = -32602
+)
+
+const (
+	toolListModels     = "list_models"
+	toolChatCompletion = "chat_completion"
+)
+
+type rpcRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+type rpcResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  any             `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+type toolContent struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+type toolResult struct {
+	Content           []toolContent `json:"content"`
+	StructuredContent any           `json:"structuredContent,omitempty"`
+	IsError           bool          `json:"isError,omitempty"`
+}
+
+// MCPAPIHandler serves the MCP endpoint. Completions run as OpenAI chat completions.
+type MCPAPIHandler struct {
+	*handlers.BaseAPIHandler
+}
+
+// NewMCPAPIHandler creates an MCP handler on top of apiHandlers.
+func NewMCPAPIHandler(apiHandlers *handlers.BaseAPIHandler) *MCPAPIHandler {
+	return &MCPAPIHandler{BaseAPIHandler: apiHandlers}
+}
+
+// HandlerType returns the identifier of the dialect tool calls are executed in.
+func (h *MCPAPIHandler) HandlerType() string {
+	return OpenAI
+}
+
+// Models returns the models offered to MCP clients.
+func (h *MCPAPIHandler) Models() []map[string]any {
+	return registry.GetGlobalRegistry().GetAvailableModels("openai")
+}
+
+// Handle serves POST requests of the Streamable HTTP transport. Requests are answered
+// with a JSON body; notifications and responses from the client are acknowledged with
+// 202 Accepted. The server offers no server-initiated stream, so GET is refused.
+func (h *MCPAPIHandler) Handle(c *gin.Context) {
+	if c.Request.Method != http.MethodPost {
+		c.Header("Allow", http.MethodPost)
+		c.Status(http.StatusMethodNotAllowed)
+		return
+	}
+	rawJSON, err := c.GetRawData()
+	if err != nil || !json.Valid(rawJSON) {
+		c.JSON(http.StatusBadRequest, rpcResponse{JSONRPC: "2.0", ID: json.RawMessage("null"), Error: &rpcError{Code: codeParseError, Message: "parse error"}})
+		return
+	}
+
+	trimmed := strings.TrimSpace(string(rawJSON))
+	if strings.HasPrefix(trimmed, "[") {
+		var batch []json.RawMessage
+		if err = json.Unmarshal(rawJSON, &batch); err != nil || len(batch) == 0 {
+			c.JSON(http.StatusBadRequest, rpcResponse{JSONRPC: "2.0", ID: json.RawMessage("null"), Error: &rpcError{Code: codeInvalidRequest, Message: "invalid batch"}})
+			return
+		}
+		responses := make([]rpcResponse, 0, len(batch))
+		for _, message := range batch {
+			if resp, ok := h.dispatch(c, message); ok {
+				responses = append(responses, resp)
+			}
+		}
+		if len(responses) == 0 {
+			c.Status(http.StatusAccepted)
+			return
+		}
+		c.JSON(http.StatusOK, responses)
+		return
+	}
+
+	resp, ok := h.dispatch(c, rawJSON)
+	if !ok {
+		c.Status(http.StatusAccepted)
+		return
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// dispatch handles one JSON-RPC message. It reports false for messages that take no
+// response: notifications and responses.
+func (h *MCPAPIHandler) dispatch(c *gin.Context, message json.RawMessage) (rpcResponse, bool) {
+	var req rpcRequest
+	if err := json.Unmarshal(message, &req); err != nil {
+		return rpcResponse{JSONRPC: "2.0", ID: json.RawMessage("null"), Error: &rpcError{Code: codeInvalidRequest, Message: "invalid request"}}, true
+	}
+	if len(req.ID) == 0 || string(req.ID) == "null" {
+		return rpcResponse{}, false
+	}
+	resp := rpcResponse{JSONRPC: "2.0", ID: req.ID}
+	if req.JSONRPC != "2.0" || req.Method == "" {
+		resp.Error = &rpcError{Code: codeInvalidRequest, Message: "invalid request"}
+		return resp, true
+	}
+
+	switch req.Method {
+	case "initialize":
+		resp.Result = initializeResult(gjson.GetBytes(req.Params, "protocolVersion").String())
+	case "ping":
+		resp.Result = map[string]any{}
+	case "tools/list":
+		resp.Result = map[string]any{"tools": toolDefinitions()}
+	case "tools/call":
+		result, errRPC := h.callTool(c, req.Params)
+		if errRPC != nil {
+			resp.Error = errRPC
+		} else {
+			resp.Result = result
+		}
+	default:
+		resp.Error = &rpcError{Code: codeMethodNotFound, Message: fmt.Sprintf("method %q not found", req.Method)}
+	}
+	return resp, true
+}
+
+func initializeResult(requested string) map[string]any {
+	version := LatestProtocolVersion
+	if supportedProtocolVersions[requested] {
+		version = requested
+	}
+	return map[string]any{
+		"protocolVersion": version,
+		"capabilities":    map[string]any{"tools": map[string]any{"listChanged": false}},
+		"serverInfo":      map[string]any{"name": "CLIProxyAPI", "version": buildinfo.Version},
+		"instructions":    "Call list_models to discover the available models, then chat_completion to run a prompt against one of them.",
+	}
+}
+
+func toolDefinitions() []map[string]any {
+	return []map[string]any{
+		{
+			"name":        toolListModels,
+			"description": "List the models available through the proxy.",
+			"inputSchema": map[string]any{"type": "object", "properties": map[string]any{}},
+		},
+		{
+			"name":        toolChatCompletion,
+			"description": "Run a chat completion against a model available through the proxy and return the reply.",
+			"inputSchema": map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"model":  map[string]any{"type": "string", "description": "Model ID from list_models."},
+					"prompt": map[string]any{"type": "string", "description": "User message. Ignored when messages is set."},
+					"system": map[string]any{"type": "string", "description": "Optional system prompt."},
+					"messages": map[string]any{
+						"type":        "array",
+						"description": "OpenAI-style conversation messages with role and content.",
+						"items":       map[string]any{"type": "object"},
+					},
+					"max_tokens":  map[string]any{"type": "integer"},
+					"temperature": map[string]any{"type": "number"},
+				},
+				"required": []string{"model"},
+			},
+		},
+	}
+}
+
+// callTool runs a tools/call request. Failures of the model call are reported as tool
+// results with isError set, so the client's model can see and react to them.
+func (h *MCPAPIHandler) callTool(c *gin.Context, params json.RawMessage) (*toolResult, *rpcError) {
+	name := gjson.GetBytes(params, "name").String()
+	args := gjson.GetBytes(params, "arguments")
+	switch name {
+	case toolListModels:
+		return h.listModels(), nil
+	case toolChatCompletion:
+		request, errMsg := chatCompletionRequest(args)
+		if errMsg != "" {
+			return nil, &rpcError{Code: codeInvalidParams, Message: errMsg}
+		}
+		return h.chatCompletion(c, args.Get("model").String(), request), nil
+	default:
+		return nil, &rpcError{Code: codeInvalidParams, Message: fmt.Sprintf("unknown tool %q", name)}
+	}
+}
+
+func (h *MCPAPIHandler) listModels() *toolResult {
+	ids := make([]string, 0)
+	for _, model := range h.Models() {
+		if id, ok := model["id"].(string); ok && id != "" {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	return &toolResult{
+		Content:           []toolContent{{Type: "text", Text: strings.Join(ids, "\n")}},
+		StructuredContent: map[string]any{"models": ids},
+	}
+}
+
+// chatCompletionRequest builds the Chat Completions request of a chat_completion call, or
+// returns a message describing the invalid arguments.
+func chatCompletionRequest(args gjson.Result) ([]byte, string) {
+	model := strings.TrimSpace(args.Get("model").String())
+	if model == "" {
+		return nil, "model is required"
+	}
+	var messages []any
+	if system := args.Get("system").String(); system != "" {
+		messages = append(messages, map[string]string{"role": "system", "content": system})
+	}
+	if raw := args.Get("messages"); raw.IsArray() && len(raw.Array()) > 0 {
+		for _, message := range raw.Array() {
+			if !message.IsObject() || message.Get("role").String() == "" {
+				return nil, "messages must be objects with a role"
+			}
+			messages = append(messages, json.RawMessage(message.Raw))
+		}
+	} else if prompt := args.Get("prompt").String(); prompt != "" {
+		messages = append(messages, map[string]string{"role": "user", "content": prompt})
+	} else {
+		return nil, "prompt or messages is required"
+	}
+
+	request := map[string]any{"model": model, "messages": messages}
+	if maxTokens := args.Get("max_tokens"); maxTokens.Exists() {
+		request["max_tokens"] = maxTokens.Int()
+	}
+	if temperature := args.Get("temperature"); temperature.Exists() {
+		request["temperature"] = temperature.Float()
+	}
+	out, err := json.Marshal(request)
+	if err != nil {
+		return nil, err.Error()
+	}
+	return out, ""
+}
+
+func (h *MCPAPIHandler) chatCompletion(c *gin.Context, model string, request []byte) *toolResult {
+	cliCtx, cliCancel := h.GetContextWithCancel(h, c, context.Background())
+	resp, errMsg := h.ExecuteWithAuthManager(cliCtx, h.HandlerType(), model, request, "")
+	if errMsg != nil {
+		cliCancel(errMsg.Error)
+		message := http.StatusText(errMsg.StatusCode)
+		if errMsg.Error != nil {
+			message = errMsg.Error.Error()
+		}
+		return &toolResult{Content: []toolContent{{Type: "text", Text: message}}, IsError: true}
+	}
+	cliCancel()
+
+	choice := gjson.GetBytes(resp, "choices.0")
+	structured := map[string]any{
+		"model":         gjson.GetBytes(resp, "model").String(),
+		"content":       choice.Get("message.content").String(),
+		"finish_reason": choice.Get("finish_reason").String(),
+	}
+	if usage := gjson.GetBytes(resp, "usage"); usage.IsObject() {
+		structured["usage"] = json.RawMessage(usage.Raw)
+	}
+	return &toolResult{
+		Content:           []toolContent{{Type: "text", Text: choice.Get("message.content").String()}},
+		StructuredContent: structured,
+	}
+}
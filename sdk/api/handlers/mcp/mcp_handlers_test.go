@@ -0,0 +1,133 @@
+package mcp
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	coreexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	sdkconfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	"github.com/tidwall/gjson"
+)
+
+// mcpExecutor echoes the last message back and fails "broken-model".
+type mcpExecutor struct{}
+
+func (e *mcpExecutor) Identifier() string { return "mcp-provider" }
+
+func (e *mcpExecutor) Execute(_ context.Context, _ *coreauth.Auth, req coreexecutor.Request, _ coreexecutor.Options) (coreexecutor.Response, error) {
+	if req.Model == "broken-model" {
+		return coreexecutor.Response{}, &coreauth.Error{Code: "upstream", Message: "boom", HTTPStatus: http.StatusServiceUnavailable}
+	}
+	last := gjson.GetBytes(req.Payload, "messages.@reverse.0.content").String()
+	return coreexecutor.Response{Payload: []byte(`{"model":"` + req.Model + `","choices":[{"message":{"role":"assistant","content":"echo: ` + last + `"},"finish_reason":"stop"}]}`)}, nil
+}
+
+func (e *mcpExecutor) ExecuteStream(context.Context, *coreauth.Auth, coreexecutor.Request, coreexecutor.Options) (<-chan coreexecutor.StreamChunk, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (e *mcpExecutor) Refresh(_ context.Context, auth *coreauth.Auth) (*coreauth.Auth, error) {
+	return auth, nil
+}
+
+func (e *mcpExecutor) CountTokens(context.Context, *coreauth.Auth, coreexecutor.Request, coreexecutor.Options) (coreexecutor.Response, error) {
+	return coreexecutor.Response{}, errors.New("not implemented")
+}
+
+func (e *mcpExecutor) HttpRequest(context.Context, *coreauth.Auth, *http.Request) (*http.Response, error) {
+	return nil, errors.New("not implemented")
+}
+
+func newMCPRouter(t *testing.T) *gin.Engine {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	executor := &mcpExecutor{}
+	manager := coreauth.NewManager(nil, nil, nil)
+	manager.RegisterExecutor(executor)
+	auth := &coreauth.Auth{ID: "mcp-auth", Provider: executor.Identifier(), Status: coreauth.StatusActive}
+	if _, err := manager.Register(context.Background(), auth); err != nil {
+		t.Fatalf("Register auth: %v", err)
+	}
+	registry.GetGlobalRegistry().RegisterClient(auth.ID, auth.Provider, []*registry.ModelInfo{{ID: "mcp-model"}, {ID: "broken-model"}})
+	t.Cleanup(func() { registry.GetGlobalRegistry().UnregisterClient(auth.ID) })
+
+	h := NewMCPAPIHandler(handlers.NewBaseAPIHandlers(&sdkconfig.SDKConfig{}, manager))
+	router := gin.New()
+	router.Any("/mcp", h.Handle)
+	return router
+}
+
+func postMCP(t *testing.T, router *gin.Engine, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/mcp", strings.NewReader(body)))
+	return rec
+}
+
+func TestMCPHandshakeAndToolList(t *testing.T) {
+	router := newMCPRouter(t)
+
+	rec := postMCP(t, router, `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2025-03-26","capabilities":{}}}`)
+	out := gjson.ParseBytes(rec.Body.Bytes())
+	if rec.Code != http.StatusOK || out.Get("id").Int() != 1 || out.Get("result.protocolVersion").String() != "2025-03-26" {
+		t.Fatalf("initialize: %d %s", rec.Code, rec.Body.String())
+	}
+	if !out.Get("result.capabilities.tools").Exists() {
+		t.Fatalf("initialize advertises no tools: %s", rec.Body.String())
+	}
+
+	if rec = postMCP(t, router, `{"jsonrpc":"2.0","method":"notifications/initialized"}`); rec.Code != http.StatusAccepted || rec.Body.Len() != 0 {
+		t.Fatalf("notification: %d %s", rec.Code, rec.Body.String())
+	}
+
+	rec = postMCP(t, router, `[{"jsonrpc":"2.0","id":"a","method":"tools/list"},{"jsonrpc":"2.0","id":"b","method":"resources/list"}]`)
+	out = gjson.ParseBytes(rec.Body.Bytes())
+	if got := out.Get("0.result.tools.#.name").String(); got != `["list_models","chat_completion"]` {
+		t.Fatalf("tools = %s", got)
+	}
+	if out.Get("1.id").String() != "b" || out.Get("1.error.code").Int() != codeMethodNotFound {
+		t.Fatalf("unknown method = %s", out.Get("1").Raw)
+	}
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/mcp", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("GET status = %d", rec.Code)
+	}
+}
+
+func TestMCPToolCalls(t *testing.T) {
+	router := newMCPRouter(t)
+
+	out := gjson.ParseBytes(postMCP(t, router, `{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"list_models","arguments":{}}}`).Body.Bytes())
+	models := out.Get("result.structuredContent.models").String()
+	if !strings.Contains(models, `"mcp-model"`) {
+		t.Fatalf("models = %s", out.Raw)
+	}
+
+	out = gjson.ParseBytes(postMCP(t, router, `{"jsonrpc":"2.0","id":2,"method":"tools/call","params":{"name":"chat_completion","arguments":{"model":"mcp-model","system":"be brief","prompt":"hello"}}}`).Body.Bytes())
+	if out.Get("result.isError").Bool() || out.Get("result.content.0.text").String() != "echo: hello" {
+		t.Fatalf("chat_completion = %s", out.Raw)
+	}
+	if out.Get("result.structuredContent.finish_reason").String() != "stop" {
+		t.Fatalf("structured content = %s", out.Get("result.structuredContent").Raw)
+	}
+
+	out = gjson.ParseBytes(postMCP(t, router, `{"jsonrpc":"2.0","id":3,"method":"tools/call","params":{"name":"chat_completion","arguments":{"model":"broken-model","prompt":"hello"}}}`).Body.Bytes())
+	if !out.Get("result.isError").Bool() || out.Get("error").Exists() {
+		t.Fatalf("failed completion = %s", out.Raw)
+	}
+
+	out = gjson.ParseBytes(postMCP(t, router, `{"jsonrpc":"2.0","id":4,"method":"tools/call","params":{"name":"chat_completion","arguments":{"model":"mcp-model"}}}`).Body.Bytes())
+	if out.Get("error.code").Int() != codeInvalidParams {
+		t.Fatalf("missing prompt = %s", out.Raw)
+	}
+}
@@ -0,0 +1,282 @@
+package handlers
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/buildinfo"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/offline"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	log "github.com/sirupsen/logrus"
+	"github.com/tidwall/gjson"
+)
+
+// mcpClientProtocolVersion is the MCP revision requested from upstream servers.
+const mcpClientProtocolVersion = "2025-06-18"
+
+// mcpSessionHeader carries the session assigned by a server during initialization.
+const mcpSessionHeader = "Mcp-Session-Id"
+
+// mcpTool is a tool offered by an MCP server.
+type mcpTool struct {
+	Name        string
+	Description string
+	InputSchema json.RawMessage
+}
+
+// mcpClient talks to one MCP server over the Streamable HTTP transport.
+type mcpClient struct {
+	server config.MCPToolServer
+	client *http.Client
+	nextID atomic.Int64
+
+	mu          sync.Mutex
+	session     string
+	initialized bool
+}
+
+func newMCPClient(server config.MCPToolServer, proxyURL string) *mcpClient {
+	timeout := time.Duration(server.TimeoutSeconds) * time.Second
+	if timeout <= 0 {
+		timeout = config.DefaultMCPToolTimeoutSeconds * time.Second
+	}
+	return &mcpClient{
+		server: server,
+		client: offline.Default().Client(util.SetProxy(&config.SDKConfig{ProxyURL: proxyURL}, &http.Client{Timeout: timeout})),
+	}
+}
+
+// listTools returns the tools of the server, following pagination cursors.
+func (mc *mcpClient) listTools(ctx context.Context) ([]mcpTool, error) {
+	var tools []mcpTool
+	cursor := ""
+	for page := 0; page < 100; page++ {
+		params := map[string]any{}
+		if cursor != "" {
+			params["cursor"] = cursor
+		}
+		result, err := mc.call(ctx, "tools/list", params)
+		if err != nil {
+			return nil, err
+		}
+		for _, tool := range gjson.GetBytes(result, "tools").Array() {
+			schema := tool.Get("inputSchema").Raw
+			if schema == "" {
+				schema = `{"type":"object","properties":{}}`
+			}
+			tools = append(tools, mcpTool{
+				Name:        tool.Get("name").String(),
+				Description: tool.Get("description").String(),
+				InputSchema: json.RawMessage(schema),
+			})
+		}
+		if cursor = gjson.GetBytes(result, "nextCursor").String(); cursor == "" {
+			return tools, nil
+		}
+	}
+	return tools, nil
+}
+
+// callTool runs a tool and returns its text content and whether the tool reported an error.
+func (mc *mcpClient) callTool(ctx context.Context, name string, arguments json.RawMessage) (string, bool, error) {
+	if len(bytes.TrimSpace(arguments)) == 0 || string(arguments) == "null" {
+		arguments = json.RawMessage(`{}`)
+	}
+	result, err := mc.call(ctx, "tools/call", map[string]any{"name": name, "arguments": arguments})
+	if err != nil {
+		return "", false, err
+	}
+	var parts []string
+	for _, item := range gjson.GetBytes(result, "content").Array() {
+		if item.Get("type").String() == "text" {
+			parts = append(parts, item.Get("text").String())
+		} else {
+			parts = append(parts, item.Raw)
+		}
+	}
+	if len(parts) == 0 {
+		if structured := gjson.GetBytes(result, "structuredContent"); structured.Exists() {
+			parts = append(parts, structured.Raw)
+		}
+	}
+	return strings.Join(parts, "\n"), gjson.GetBytes(result, "isError").Bool(), nil
+}
+
+// call sends a request, initializing the session first. A session the server no longer
+// knows is re-established once.
+func (mc *mcpClient) call(ctx context.Context, method string, params any) (json.RawMessage, error) {
+	if err := mc.ensureInitialized(ctx); err != nil {
+		return nil, err
+	}
+	result, status, err := mc.send(ctx, method, params, true)
+	if status == http.StatusNotFound && mc.hasSession() {
+		mc.reset()
+		if err = mc.ensureInitialized(ctx); err != nil {
+			return nil, err
+		}
+		result, _, err = mc.send(ctx, method, params, true)
+	}
+	return result, err
+}
+
+func (mc *mcpClient) ensureInitialized(ctx context.Context) error {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	if mc.initialized {
+		return nil
+	}
+	params := map[string]any{
+		"protocolVersion": mcpClientProtocolVersion,
+		"capabilities":    map[string]any{},
+		"clientInfo":      map[string]any{"name": "CLIProxyAPI", "version": buildinfo.Version},
+	}
+	_, _, session, err := mc.post(ctx, "", "initialize", params, true)
+	if err != nil {
+		return fmt.Errorf("initialize: %w", err)
+	}
+	if _, _, _, err = mc.post(ctx, session, "notifications/initialized", nil, false); err != nil {
+		return fmt.Errorf("initialized notification: %w", err)
+	}
+	mc.session = session
+	mc.initialized = true
+	return nil
+}
+
+func (mc *mcpClient) hasSession() bool {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	return mc.session != ""
+}
+
+func (mc *mcpClient) reset() {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	mc.session = ""
+	mc.initialized = false
+}
+
+func (mc *mcpClient) send(ctx context.Context, method string, params any, expectResult bool) (json.RawMessage, int, error) {
+	mc.mu.Lock()
+	session := mc.session
+	mc.mu.Unlock()
+	result, status, _, err := mc.post(ctx, session, method, params, expectResult)
+	return result, status, err
+}
+
+// post sends one JSON-RPC message in session and returns the session the server assigned,
+// if any. Calls wait for the response with their id, which the server may return as JSON
+// or within an event stream; notifications only wait for the server to accept them.
+func (mc *mcpClient) post(ctx context.Context, session, method string, params any, expectResult bool) (json.RawMessage, int, string, error) {
+	message := map[string]any{"jsonrpc": "2.0", "method": method}
+	if params != nil {
+		message["params"] = params
+	}
+	id := mc.nextID.Add(1)
+	if expectResult {
+		message["id"] = id
+	}
+	body, err := json.Marshal(message)
+	if err != nil {
+		return nil, 0, "", err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, mc.server.URL, bytes.NewReader(body))
+	if err != nil {
+		return nil, 0, "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json, text/event-stream")
+	req.Header.Set("MCP-Protocol-Version", mcpClientProtocolVersion)
+	for key, value := range mc.server.Headers {
+		req.Header.Set(key, value)
+	}
+	if session != "" {
+		req.Header.Set(mcpSessionHeader, session)
+	}
+	resp, err := mc.client.Do(req)
+	if err != nil {
+		return nil, 0, "", err
+	}
+	defer func() {
+		if errClose := resp.Body.Close(); errClose != nil {
+			log.Debugf("mcp tools: close response of %s: %v", mc.server.Name, errClose)
+		}
+	}()
+	assigned := resp.Header.Get(mcpSessionHeader)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return nil, resp.StatusCode, assigned, fmt.Errorf("%s returned status %d: %s", method, resp.StatusCode, strings.TrimSpace(string(data)))
+	}
+	if !expectResult {
+		return nil, resp.StatusCode, assigned, nil
+	}
+
+	var raw []byte
+	if strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream") {
+		raw, err = readMCPEventResponse(resp.Body, id)
+	} else {
+		raw, err = io.ReadAll(resp.Body)
+	}
+	if err != nil {
+		return nil, resp.StatusCode, assigned, err
+	}
+	if errObj := gjson.GetBytes(raw, "error"); errObj.Exists() {
+		return nil, resp.StatusCode, assigned, fmt.Errorf("%s failed: %s (code %d)", method, errObj.Get("message").String(), errObj.Get("code").Int())
+	}
+	result := gjson.GetBytes(raw, "result")
+	if !result.Exists() {
+		return nil, resp.StatusCode, assigned, fmt.Errorf("%s returned no result", method)
+	}
+	return json.RawMessage(result.Raw), resp.StatusCode, assigned, nil
+}
+
+// readMCPEventResponse returns the JSON-RPC response with id from an event stream,
+// skipping the server's requests and notifications sent before it.
+func readMCPEventResponse(body io.Reader, id int64) ([]byte, error) {
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	var data strings.Builder
+	flush := func() []byte {
+		event := data.String()
+		data.Reset()
+		if event == "" {
+			return nil
+		}
+		parsed := gjson.Parse(event)
+		if parsed.Get("id").Int() == id && (parsed.Get("result").Exists() || parsed.Get("error").Exists()) {
+			return []byte(event)
+		}
+		return nil
+	}
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			if event := flush(); event != nil {
+				return event, nil
+			}
+			continue
+		}
+		if value, ok := strings.CutPrefix(line, "data:"); ok {
+			if data.Len() > 0 {
+				data.WriteByte('\n')
+			}
+			data.WriteString(strings.TrimPrefix(value, " "))
+		}
+	}
+	if event := flush(); event != nil {
+		return event, nil
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return nil, fmt.Errorf("event stream ended without a response")
+}
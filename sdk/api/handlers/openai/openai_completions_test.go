@@ -0,0 +1,58 @@
+package openai
+
+import (
+	"testing"
+
+	"github.com/tidwall/gjson"
+)
+
+func TestConvertCompletionsRequestToChatCompletions(t *testing.T) {
+	out, err := convertCompletionsRequestToChatCompletions([]byte(`{"model":"gpt-test","prompt":["def add(a, b):"],"echo":true,"logprobs":3,"n":2,"max_tokens":16}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed := gjson.ParseBytes(out)
+	if got := parsed.Get("messages.0.content").String(); got != "def add(a, b):" || parsed.Get("messages.#").Int() != 1 {
+		t.Fatalf("messages = %s", parsed.Get("messages").Raw)
+	}
+	if parsed.Get("echo").Exists() {
+		t.Fatal("echo must not be sent upstream")
+	}
+	if !parsed.Get("logprobs").Bool() || parsed.Get("top_logprobs").Int() != 3 || parsed.Get("n").Int() != 2 {
+		t.Fatalf("parameters = %s", out)
+	}
+
+	out, err = convertCompletionsRequestToChatCompletions([]byte(`{"model":"gpt-test","prompt":"func main() {","suffix":"}"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed = gjson.ParseBytes(out)
+	if parsed.Get("messages.0.role").String() != "system" || parsed.Get("messages.1.content").String() != "<prefix>func main() {</prefix><suffix>}</suffix>" {
+		t.Fatalf("insert messages = %s", parsed.Get("messages").Raw)
+	}
+
+	for _, body := range []string{`{"prompt":["a","b"]}`, `{"prompt":[[1,2,3]]}`} {
+		if _, err = convertCompletionsRequestToChatCompletions([]byte(body)); err == nil {
+			t.Fatalf("%s should be rejected", body)
+		}
+	}
+}
+
+func TestCompletionsEcho(t *testing.T) {
+	if echo := newCompletionsEcho([]byte(`{"prompt":"Hello"}`)); echo != nil {
+		t.Fatal("echo should be nil when not requested")
+	}
+	echo := newCompletionsEcho([]byte(`{"prompt":"Hello","echo":true}`))
+
+	first := echo.apply([]byte(`{"choices":[{"index":0,"text":", world"}]}`))
+	if got := gjson.GetBytes(first, "choices.0.text").String(); got != "Hello, world" {
+		t.Fatalf("first chunk text = %q", got)
+	}
+	next := echo.apply([]byte(`{"choices":[{"index":0,"text":"!"},{"index":1,"text":" there"}]}`))
+	if got := gjson.GetBytes(next, "choices.0.text").String(); got != "!" {
+		t.Fatalf("echo repeated for choice 0: %q", got)
+	}
+	if got := gjson.GetBytes(next, "choices.1.text").String(); got != "Hello there" {
+		t.Fatalf("choice 1 text = %q", got)
+	}
+}
@@ -0,0 +1,218 @@
+package openai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers"
+	sdkconfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// fanOutResult is one model's entry in a fan-out response.
+type fanOutResult struct {
+	Model     string          `json:"model"`
+	LatencyMS int64           `json:"latency_ms"`
+	Response  json.RawMessage `json:"response,omitempty"`
+	Error     *fanOutError    `json:"error,omitempty"`
+}
+
+type fanOutError struct {
+	Status  int    `json:"status"`
+	Message string `json:"message"`
+}
+
+// fanOutRank is one judged position; Index points into the fan-out results.
+type fanOutRank struct {
+	Index  int     `json:"index"`
+	Model  string  `json:"model"`
+	Score  float64 `json:"score"`
+	Reason string  `json:"reason,omitempty"`
+}
+
+type fanOutJudge struct {
+	Model string       `json:"model"`
+	Error *fanOutError `json:"error,omitempty"`
+}
+
+type fanOutResponse struct {
+	Object  string         `json:"object"`
+	Results []fanOutResult `json:"results"`
+	Ranking []fanOutRank   `json:"ranking,omitempty"`
+	Judge   *fanOutJudge   `json:"judge,omitempty"`
+}
+
+// FanOut handles POST /v1/fanout. It accepts a Chat Completions request with an optional
+// "models" list (defaulting to fanout.models) and an optional "judge_model", sends the
+// request to every model and returns all responses. When a judge model is set, it ranks
+// the successful responses.
+func (h *OpenAIAPIHandler) FanOut(c *gin.Context) {
+	rawJSON, err := c.GetRawData()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, handlers.ErrorResponse{
+			Error: handlers.ErrorDetail{
+				Message: fmt.Sprintf("Invalid request: %v", err),
+				Type:    "invalid_request_error",
+			},
+		})
+		return
+	}
+
+	var settings sdkconfig.FanOutConfig
+	if h.Cfg != nil {
+		settings = h.Cfg.FanOut
+	}
+	models := settings.Models
+	if requested := gjson.GetBytes(rawJSON, "models"); requested.IsArray() {
+		models = nil
+		for _, model := range requested.Array() {
+			if name := strings.TrimSpace(model.String()); name != "" {
+				models = append(models, name)
+			}
+		}
+	}
+	maxModels := settings.MaxModels
+	if maxModels <= 0 {
+		maxModels = sdkconfig.DefaultFanOutMaxModels
+	}
+	if len(models) == 0 || len(models) > maxModels {
+		c.JSON(http.StatusBadRequest, handlers.ErrorResponse{
+			Error: handlers.ErrorDetail{
+				Message: fmt.Sprintf("fan-out needs between 1 and %d models", maxModels),
+				Type:    "invalid_request_error",
+			},
+		})
+		return
+	}
+	judgeModel := settings.JudgeModel
+	if judge := gjson.GetBytes(rawJSON, "judge_model"); judge.Exists() {
+		judgeModel = strings.TrimSpace(judge.String())
+	}
+
+	request := rawJSON
+	for _, path := range []string{"models", "judge_model"} {
+		request, _ = sjson.DeleteBytes(request, path)
+	}
+	request, _ = sjson.SetBytes(request, "stream", false)
+
+	cliCtx, cliCancel := h.GetContextWithCancel(h, c, context.Background())
+	results := h.ExecuteModels(cliCtx, h.HandlerType(), models, request, h.GetAlt(c))
+
+	out := fanOutResponse{Object: "fanout.result", Results: make([]fanOutResult, len(results))}
+	for i, result := range results {
+		entry := fanOutResult{Model: result.Model, LatencyMS: result.Latency.Milliseconds()}
+		if result.Err != nil {
+			entry.Error = newFanOutError(result.Err)
+		} else if json.Valid(result.Payload) {
+			entry.Response = json.RawMessage(result.Payload)
+		} else {
+			entry.Error = &fanOutError{Status: http.StatusBadGateway, Message: "model returned an invalid response"}
+		}
+		out.Results[i] = entry
+	}
+	if judgeModel != "" {
+		out.Judge = &fanOutJudge{Model: judgeModel}
+		out.Ranking, out.Judge.Error = h.judgeFanOut(cliCtx, judgeModel, request, out.Results)
+	}
+
+	c.JSON(http.StatusOK, out)
+	cliCancel()
+}
+
+func newFanOutError(msg *interfaces.ErrorMessage) *fanOutError {
+	status := msg.StatusCode
+	if status <= 0 {
+		status = http.StatusInternalServerError
+	}
+	message := http.StatusText(status)
+	if msg.Error != nil {
+		message = msg.Error.Error()
+	}
+	return &fanOutError{Status: status, Message: message}
+}
+
+const fanOutJudgePrompt = `You compare candidate answers to the same conversation. Rank every candidate from best to worst for correctness, helpfulness and clarity. Reply with JSON only, in the form {"ranking":[{"candidate":<number>,"score":<0-10>,"reason":"<short reason>"}]}.`
+
+// judgeFanOut asks judgeModel to rank the successful results and returns the ranking.
+func (h *OpenAIAPIHandler) judgeFanOut(ctx context.Context, judgeModel string, request []byte, results []fanOutResult) ([]fanOutRank, *fanOutError) {
+	var prompt strings.Builder
+	prompt.WriteString("Conversation:\n")
+	for _, message := range gjson.GetBytes(request, "messages").Array() {
+		fmt.Fprintf(&prompt, "[%s] %s\n", message.Get("role").String(), messageText(message.Get("content")))
+	}
+	candidates := 0
+	for i, result := range results {
+		if result.Error != nil {
+			continue
+		}
+		candidates++
+		answer := gjson.GetBytes(result.Response, "choices.0.message.content").String()
+		fmt.Fprintf(&prompt, "\nCandidate %d:\n%s\n", i, answer)
+	}
+	if candidates == 0 {
+		return nil, &fanOutError{Status: http.StatusBadGateway, Message: "no successful response to rank"}
+	}
+
+	judgeRequest, _ := json.Marshal(map[string]any{
+		"model": judgeModel,
+		"messages": []map[string]string{
+			{"role": "system", "content": fanOutJudgePrompt},
+			{"role": "user", "content": prompt.String()},
+		},
+	})
+	judged := h.ExecuteModels(ctx, h.HandlerType(), []string{judgeModel}, judgeRequest, "")[0]
+	if judged.Err != nil {
+		return nil, newFanOutError(judged.Err)
+	}
+	ranking, ok := parseFanOutRanking(gjson.GetBytes(judged.Payload, "choices.0.message.content").String(), results)
+	if !ok {
+		return nil, &fanOutError{Status: http.StatusBadGateway, Message: "judge model returned no usable ranking"}
+	}
+	return ranking, nil
+}
+
+// parseFanOutRanking extracts the judge's ranking, ignoring text around the JSON object and
+// candidates that do not name a successful result.
+func parseFanOutRanking(content string, results []fanOutResult) ([]fanOutRank, bool) {
+	start, end := strings.Index(content, "{"), strings.LastIndex(content, "}")
+	if start < 0 || end <= start {
+		return nil, false
+	}
+	parsed := gjson.Parse(content[start : end+1])
+	var ranking []fanOutRank
+	seen := make(map[int]bool)
+	for _, entry := range parsed.Get("ranking").Array() {
+		index := int(entry.Get("candidate").Int())
+		if index < 0 || index >= len(results) || results[index].Error != nil || seen[index] {
+			continue
+		}
+		seen[index] = true
+		ranking = append(ranking, fanOutRank{
+			Index:  index,
+			Model:  results[index].Model,
+			Score:  entry.Get("score").Float(),
+			Reason: entry.Get("reason").String(),
+		})
+	}
+	return ranking, len(ranking) > 0
+}
+
+// messageText flattens Chat Completions message content to plain text.
+func messageText(content gjson.Result) string {
+	if !content.IsArray() {
+		return content.String()
+	}
+	var parts []string
+	for _, part := range content.Array() {
+		if text := part.Get("text"); text.Exists() {
+			parts = append(parts, text.String())
+		}
+	}
+	return strings.Join(parts, "\n")
+}
@@ -0,0 +1,131 @@
+package openai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	coreexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	sdkconfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	"github.com/tidwall/gjson"
+)
+
+// fanOutExecutor answers each model with its own name, fails "broken-model" and ranks
+// candidates in reverse order for "judge-model".
+type fanOutExecutor struct{}
+
+func (e *fanOutExecutor) Identifier() string { return "fanout-provider" }
+
+func (e *fanOutExecutor) Execute(_ context.Context, _ *coreauth.Auth, req coreexecutor.Request, _ coreexecutor.Options) (coreexecutor.Response, error) {
+	switch req.Model {
+	case "broken-model":
+		return coreexecutor.Response{}, &coreauth.Error{Code: "upstream", Message: "boom", HTTPStatus: http.StatusServiceUnavailable}
+	case "judge-model":
+		if !strings.Contains(string(req.Payload), "answer from model-b") {
+			return coreexecutor.Response{}, errors.New("judge prompt is missing a candidate")
+		}
+		ranking := `{\"ranking\":[{\"candidate\":2,\"score\":9,\"reason\":\"clearer\"},{\"candidate\":1,\"score\":3},{\"candidate\":0,\"score\":1}]}`
+		return coreexecutor.Response{Payload: []byte(`{"choices":[{"message":{"role":"assistant","content":"` + ranking + `"}}]}`)}, nil
+	}
+	if gjson.GetBytes(req.Payload, "stream").Bool() || gjson.GetBytes(req.Payload, "models").Exists() {
+		return coreexecutor.Response{}, errors.New("fan-out fields leaked upstream")
+	}
+	return coreexecutor.Response{Payload: []byte(fmt.Sprintf(`{"choices":[{"message":{"role":"assistant","content":"answer from %s"}}]}`, req.Model))}, nil
+}
+
+func (e *fanOutExecutor) ExecuteStream(context.Context, *coreauth.Auth, coreexecutor.Request, coreexecutor.Options) (<-chan coreexecutor.StreamChunk, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (e *fanOutExecutor) Refresh(_ context.Context, auth *coreauth.Auth) (*coreauth.Auth, error) {
+	return auth, nil
+}
+
+func (e *fanOutExecutor) CountTokens(context.Context, *coreauth.Auth, coreexecutor.Request, coreexecutor.Options) (coreexecutor.Response, error) {
+	return coreexecutor.Response{}, errors.New("not implemented")
+}
+
+func (e *fanOutExecutor) HttpRequest(context.Context, *coreauth.Auth, *http.Request) (*http.Response, error) {
+	return nil, errors.New("not implemented")
+}
+
+func newFanOutRouter(t *testing.T, cfg *sdkconfig.SDKConfig) *gin.Engine {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	executor := &fanOutExecutor{}
+	manager := coreauth.NewManager(nil, nil, nil)
+	manager.RegisterExecutor(executor)
+	auth := &coreauth.Auth{ID: "fanout-auth", Provider: executor.Identifier(), Status: coreauth.StatusActive}
+	if _, err := manager.Register(context.Background(), auth); err != nil {
+		t.Fatalf("Register auth: %v", err)
+	}
+	registry.GetGlobalRegistry().RegisterClient(auth.ID, auth.Provider, []*registry.ModelInfo{
+		{ID: "model-a"}, {ID: "model-b"}, {ID: "broken-model"}, {ID: "judge-model"},
+	})
+	t.Cleanup(func() { registry.GetGlobalRegistry().UnregisterClient(auth.ID) })
+
+	h := NewOpenAIAPIHandler(handlers.NewBaseAPIHandlers(cfg, manager))
+	router := gin.New()
+	router.POST("/v1/fanout", h.FanOut)
+	return router
+}
+
+func TestFanOutReturnsEveryResponseAndRanking(t *testing.T) {
+	router := newFanOutRouter(t, &sdkconfig.SDKConfig{
+		FanOut: sdkconfig.FanOutConfig{Models: []string{"model-a", "broken-model", "model-b"}, JudgeModel: "judge-model"},
+	})
+	body := `{"stream":true,"messages":[{"role":"user","content":[{"type":"text","text":"hi"}]}]}`
+	req := httptest.NewRequest(http.MethodPost, "/v1/fanout", strings.NewReader(body))
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+
+	if resp.Code != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", resp.Code, resp.Body.String())
+	}
+	out := gjson.ParseBytes(resp.Body.Bytes())
+	if got := out.Get("results.#.model").String(); got != `["model-a","broken-model","model-b"]` {
+		t.Fatalf("result models = %s", got)
+	}
+	if out.Get("results.0.response.choices.0.message.content").String() != "answer from model-a" {
+		t.Fatalf("results = %s", out.Get("results").Raw)
+	}
+	if out.Get("results.1.error.status").Int() != http.StatusServiceUnavailable || out.Get("results.1.response").Exists() {
+		t.Fatalf("broken result = %s", out.Get("results.1").Raw)
+	}
+	// Candidate 1 failed and is dropped from the ranking.
+	if got := out.Get("ranking.#.model").String(); got != `["model-b","model-a"]` {
+		t.Fatalf("ranking = %s", out.Get("ranking").Raw)
+	}
+	if out.Get("ranking.0.reason").String() != "clearer" || out.Get("judge.error").Exists() {
+		t.Fatalf("ranking = %s judge = %s", out.Get("ranking").Raw, out.Get("judge").Raw)
+	}
+}
+
+func TestFanOutRequestModelsOverrideConfig(t *testing.T) {
+	router := newFanOutRouter(t, &sdkconfig.SDKConfig{
+		FanOut: sdkconfig.FanOutConfig{Models: []string{"model-a"}, JudgeModel: "judge-model", MaxModels: 2},
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/fanout", strings.NewReader(`{"models":["model-b"],"judge_model":"","messages":[]}`))
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+	out := gjson.ParseBytes(resp.Body.Bytes())
+	if resp.Code != http.StatusOK || out.Get("results.#").Int() != 1 || out.Get("results.0.model").String() != "model-b" || out.Get("judge").Exists() {
+		t.Fatalf("status = %d, body = %s", resp.Code, resp.Body.String())
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/v1/fanout", strings.NewReader(`{"models":["model-a","model-b","broken-model"],"messages":[]}`))
+	resp = httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+	if resp.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d over max-models, want 400", resp.Code)
+	}
+}
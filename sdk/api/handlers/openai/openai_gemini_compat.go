@@ -0,0 +1,136 @@
+package openai
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/thinking"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// geminiCompatThinkingBudgets are the thinking budgets Google's OpenAI compatibility layer
+// applies for each reasoning_effort.
+var geminiCompatThinkingBudgets = map[string]string{
+	"none":    "none",
+	"minimal": "1024",
+	"low":     "1024",
+	"medium":  "8192",
+	"high":    "24576",
+}
+
+// GeminiCompatChatCompletions handles /v1beta/openai/chat/completions, mirroring Google's
+// OpenAI compatibility endpoint so clients configured for it only change their base URL.
+// The request is adapted to the proxy's chat completions dialect and served like
+// /v1/chat/completions.
+//
+// Parameters:
+//   - c: The Gin context containing the HTTP request and response
+func (h *OpenAIAPIHandler) GeminiCompatChatCompletions(c *gin.Context) {
+	rawJSON, err := c.GetRawData()
+	if err == nil {
+		rawJSON, err = adaptGeminiCompatRequest(rawJSON)
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, handlers.ErrorResponse{
+			Error: handlers.ErrorDetail{
+				Message: fmt.Sprintf("Invalid request: %v", err),
+				Type:    "invalid_request_error",
+			},
+		})
+		return
+	}
+
+	if gjson.GetBytes(rawJSON, "stream").Type == gjson.True {
+		h.handleStreamingResponse(c, rawJSON)
+	} else {
+		h.handleNonStreamingResponse(c, rawJSON)
+	}
+}
+
+// GeminiCompatModels handles /v1beta/openai/models, listing models with the "models/"
+// prefix Google's compatibility endpoint uses.
+//
+// Parameters:
+//   - c: The Gin context containing the HTTP request and response
+func (h *OpenAIAPIHandler) GeminiCompatModels(c *gin.Context) {
+	models := h.Models()
+	data := make([]gin.H, 0, len(models))
+	for _, model := range models {
+		id, _ := model["id"].(string)
+		if id == "" {
+			continue
+		}
+		entry := gin.H{"id": "models/" + id, "object": "model"}
+		if ownedBy, ok := model["owned_by"]; ok {
+			entry["owned_by"] = ownedBy
+		}
+		data = append(data, entry)
+	}
+	c.JSON(http.StatusOK, gin.H{"object": "list", "data": data})
+}
+
+// adaptGeminiCompatRequest applies the request conventions of Google's compatibility layer:
+//   - "models/" prefixes are removed from the model;
+//   - reasoning_effort and extra_body.google.thinking_config.thinking_budget become a thinking
+//     suffix of the model, with Google's budgets per effort; setting both is rejected;
+//   - tool_choice "any" means "required", function choices may omit their type, and a
+//     tool_choice without tools is ignored.
+func adaptGeminiCompatRequest(rawJSON []byte) ([]byte, error) {
+	root := gjson.ParseBytes(rawJSON)
+	if !root.IsObject() {
+		return nil, fmt.Errorf("request body must be a JSON object")
+	}
+	out := rawJSON
+
+	model := strings.TrimPrefix(root.Get("model").String(), "models/")
+	thinkingConfig := root.Get("extra_body.google.thinking_config")
+	if !thinkingConfig.Exists() {
+		thinkingConfig = root.Get("google.thinking_config")
+	}
+	budget := thinkingConfig.Get("thinking_budget")
+	effort := root.Get("reasoning_effort")
+	if effort.Exists() && budget.Exists() {
+		return nil, fmt.Errorf("reasoning_effort and thinking_budget cannot both be set")
+	}
+	suffix := ""
+	switch {
+	case effort.Exists():
+		value, ok := geminiCompatThinkingBudgets[strings.ToLower(effort.String())]
+		if !ok {
+			return nil, fmt.Errorf("unsupported reasoning_effort %q; use none, minimal, low, medium or high", effort.String())
+		}
+		suffix = value
+	case budget.Exists():
+		if budget.Type != gjson.Number || budget.Int() < -1 {
+			return nil, fmt.Errorf("thinking_budget must be -1 (dynamic), 0 (disabled) or a positive number of tokens")
+		}
+		suffix = strconv.FormatInt(budget.Int(), 10)
+		if suffix == "0" {
+			suffix = "none"
+		}
+	}
+	if suffix != "" && !thinking.ParseSuffix(model).HasSuffix {
+		model = model + "(" + suffix + ")"
+	}
+	out, _ = sjson.SetBytes(out, "model", model)
+	for _, field := range []string{"reasoning_effort", "extra_body", "google"} {
+		out, _ = sjson.DeleteBytes(out, field)
+	}
+
+	toolChoice := root.Get("tool_choice")
+	switch {
+	case !toolChoice.Exists():
+	case len(root.Get("tools").Array()) == 0:
+		out, _ = sjson.DeleteBytes(out, "tool_choice")
+	case toolChoice.Type == gjson.String && strings.EqualFold(toolChoice.String(), "any"):
+		out, _ = sjson.SetBytes(out, "tool_choice", "required")
+	case toolChoice.IsObject() && toolChoice.Get("function.name").Exists() && !toolChoice.Get("type").Exists():
+		out, _ = sjson.SetBytes(out, "tool_choice.type", "function")
+	}
+	return out, nil
+}
@@ -0,0 +1,61 @@
+package openai
+
+import (
+	"testing"
+
+	"github.com/tidwall/gjson"
+)
+
+func TestAdaptGeminiCompatRequest(t *testing.T) {
+	cases := []struct {
+		name  string
+		body  string
+		model string
+	}{
+		{"effort", `{"model":"models/gemini-2.5-flash","reasoning_effort":"medium"}`, "gemini-2.5-flash(8192)"},
+		{"effort none", `{"model":"gemini-2.5-flash","reasoning_effort":"none"}`, "gemini-2.5-flash(none)"},
+		{"budget", `{"model":"gemini-2.5-pro","extra_body":{"google":{"thinking_config":{"thinking_budget":2048,"include_thoughts":true}}}}`, "gemini-2.5-pro(2048)"},
+		{"dynamic budget", `{"model":"gemini-2.5-pro","google":{"thinking_config":{"thinking_budget":-1}}}`, "gemini-2.5-pro(-1)"},
+		{"explicit suffix wins", `{"model":"gemini-2.5-pro(512)","reasoning_effort":"high"}`, "gemini-2.5-pro(512)"},
+		{"no thinking", `{"model":"gemini-2.5-pro"}`, "gemini-2.5-pro"},
+	}
+	for _, tc := range cases {
+		out, err := adaptGeminiCompatRequest([]byte(tc.body))
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		parsed := gjson.ParseBytes(out)
+		if got := parsed.Get("model").String(); got != tc.model {
+			t.Fatalf("%s: model = %q, want %q", tc.name, got, tc.model)
+		}
+		if parsed.Get("reasoning_effort").Exists() || parsed.Get("extra_body").Exists() || parsed.Get("google").Exists() {
+			t.Fatalf("%s: compat fields left in %s", tc.name, out)
+		}
+	}
+
+	for _, body := range []string{
+		`{"model":"g","reasoning_effort":"low","extra_body":{"google":{"thinking_config":{"thinking_budget":10}}}}`,
+		`{"model":"g","reasoning_effort":"extreme"}`,
+		`[]`,
+	} {
+		if _, err := adaptGeminiCompatRequest([]byte(body)); err == nil {
+			t.Fatalf("%s should be rejected", body)
+		}
+	}
+}
+
+func TestAdaptGeminiCompatRequest_ToolChoice(t *testing.T) {
+	tools := `"tools":[{"type":"function","function":{"name":"lookup"}}]`
+	out, _ := adaptGeminiCompatRequest([]byte(`{"model":"g","tool_choice":"any",` + tools + `}`))
+	if got := gjson.GetBytes(out, "tool_choice").String(); got != "required" {
+		t.Fatalf("any tool_choice = %q", got)
+	}
+	out, _ = adaptGeminiCompatRequest([]byte(`{"model":"g","tool_choice":{"function":{"name":"lookup"}},` + tools + `}`))
+	if got := gjson.GetBytes(out, "tool_choice.type").String(); got != "function" {
+		t.Fatalf("function tool_choice = %s", out)
+	}
+	out, _ = adaptGeminiCompatRequest([]byte(`{"model":"g","tool_choice":"auto"}`))
+	if gjson.GetBytes(out, "tool_choice").Exists() {
+		t.Fatalf("tool_choice without tools should be dropped: %s", out)
+	}
+}
@@ -163,29 +163,49 @@ func (h *OpenAIAPIHandler) Completions(c *gin.Context) {
 		return
 	}
 
+	// Convert completions request to chat completions format
+	chatCompletionsJSON, err := convertCompletionsRequestToChatCompletions(rawJSON)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, handlers.ErrorResponse{
+			Error: handlers.ErrorDetail{
+				Message: err.Error(),
+				Type:    "invalid_request_error",
+			},
+		})
+		return
+	}
+	echo := newCompletionsEcho(rawJSON)
+
 	// Check if the client requested a streaming response.
 	streamResult := gjson.GetBytes(rawJSON, "stream")
 	if streamResult.Type == gjson.True {
-		h.handleCompletionsStreamingResponse(c, rawJSON)
+		h.handleCompletionsStreamingResponse(c, chatCompletionsJSON, echo)
 	} else {
-		h.handleCompletionsNonStreamingResponse(c, rawJSON)
+		h.handleCompletionsNonStreamingResponse(c, chatCompletionsJSON, echo)
 	}
 
 }
 
 // convertCompletionsRequestToChatCompletions converts OpenAI completions API request to chat completions format.
 // This allows the completions endpoint to use the existing chat completions infrastructure.
+// The prompt becomes the user message; with a suffix, the model is asked for the text
+// between prompt and suffix instead. Echo is applied to the response by completionsEcho
+// and is not sent upstream.
 //
 // Parameters:
 //   - rawJSON: The raw JSON bytes of the completions request
 //
 // Returns:
 //   - []byte: The converted chat completions request
-func convertCompletionsRequestToChatCompletions(rawJSON []byte) []byte {
+//   - error: An error if the prompt cannot be expressed as a chat message
+func convertCompletionsRequestToChatCompletions(rawJSON []byte) ([]byte, error) {
 	root := gjson.ParseBytes(rawJSON)
 
 	// Extract prompt from completions request
-	prompt := root.Get("prompt").String()
+	prompt, err := completionsPrompt(root)
+	if err != nil {
+		return nil, err
+	}
 	if prompt == "" {
 		prompt = "Complete this:"
 	}
@@ -198,8 +218,14 @@ func convertCompletionsRequestToChatCompletions(rawJSON []byte) []byte {
 		out, _ = sjson.Set(out, "model", model.String())
 	}
 
-	// Set the prompt as user message content
-	out, _ = sjson.Set(out, "messages.0.content", prompt)
+	// Set the prompt as user message content, or ask for the middle of prompt and suffix.
+	if suffix := root.Get("suffix").String(); suffix != "" {
+		out, _ = sjson.SetRaw(out, "messages", `[{"role":"system","content":""},{"role":"user","content":""}]`)
+		out, _ = sjson.Set(out, "messages.0.content", completionsInsertInstruction)
+		out, _ = sjson.Set(out, "messages.1.content", "<prefix>"+prompt+"</prefix><suffix>"+suffix+"</suffix>")
+	} else {
+		out, _ = sjson.Set(out, "messages.0.content", prompt)
+	}
 
 	// Copy other parameters from completions to chat completions
 	if maxTokens := root.Get("max_tokens"); maxTokens.Exists() {
@@ -230,19 +256,85 @@ func convertCompletionsRequestToChatCompletions(rawJSON []byte) []byte {
 		out, _ = sjson.Set(out, "stream", stream.Bool())
 	}
 
-	if logprobs := root.Get("logprobs"); logprobs.Exists() {
+	// Legacy logprobs is the number of top tokens to return; chat splits it in two.
+	if logprobs := root.Get("logprobs"); logprobs.Exists() && logprobs.Type != gjson.Null {
 		out, _ = sjson.Set(out, "logprobs", logprobs.Bool())
+		if logprobs.Type == gjson.Number && logprobs.Int() > 0 && !root.Get("top_logprobs").Exists() {
+			out, _ = sjson.Set(out, "top_logprobs", logprobs.Int())
+		}
 	}
 
 	if topLogprobs := root.Get("top_logprobs"); topLogprobs.Exists() {
 		out, _ = sjson.Set(out, "top_logprobs", topLogprobs.Int())
 	}
 
-	if echo := root.Get("echo"); echo.Exists() {
-		out, _ = sjson.Set(out, "echo", echo.Bool())
+	for _, field := range []string{"n", "seed", "user", "logit_bias", "stream_options"} {
+		if value := root.Get(field); value.Exists() {
+			out, _ = sjson.SetRaw(out, field, value.Raw)
+		}
 	}
 
-	return []byte(out)
+	return []byte(out), nil
+}
+
+// completionsInsertInstruction is the system message of completions requests with a suffix.
+const completionsInsertInstruction = "Write the text that belongs between the prefix and the suffix given by the user. " +
+	"Reply with that text only, without repeating the prefix or the suffix and without any markup."
+
+// completionsPrompt returns the prompt of a completions request: a string or an array
+// holding one string. Several prompts per request and token id prompts are rejected.
+func completionsPrompt(root gjson.Result) (string, error) {
+	prompt := root.Get("prompt")
+	if !prompt.IsArray() {
+		return prompt.String(), nil
+	}
+	items := prompt.Array()
+	switch {
+	case len(items) == 0:
+		return "", nil
+	case len(items) > 1:
+		return "", fmt.Errorf("multiple prompts per request are not supported; send one request per prompt")
+	case items[0].Type != gjson.String:
+		return "", fmt.Errorf("token id prompts are not supported; send the prompt as text")
+	}
+	return items[0].String(), nil
+}
+
+// completionsEcho prepends the prompt to the text of every choice, once per choice index,
+// as the echo parameter of the completions API does. A nil echo leaves responses unchanged.
+type completionsEcho struct {
+	prompt string
+	seen   map[int64]bool
+}
+
+// newCompletionsEcho returns the echo of a completions request, nil when echo is not set.
+func newCompletionsEcho(rawJSON []byte) *completionsEcho {
+	root := gjson.ParseBytes(rawJSON)
+	if !root.Get("echo").Bool() {
+		return nil
+	}
+	prompt, _ := completionsPrompt(root)
+	if prompt == "" {
+		return nil
+	}
+	return &completionsEcho{prompt: prompt, seen: make(map[int64]bool)}
+}
+
+// apply prepends the prompt to the choices of a completions response or stream chunk
+// not echoed yet.
+func (e *completionsEcho) apply(completion []byte) []byte {
+	if e == nil {
+		return completion
+	}
+	for i, choice := range gjson.GetBytes(completion, "choices").Array() {
+		index := choice.Get("index").Int()
+		if e.seen[index] {
+			continue
+		}
+		e.seen[index] = true
+		completion, _ = sjson.SetBytes(completion, fmt.Sprintf("choices.%d.text", i), e.prompt+choice.Get("text").String())
+	}
+	return completion
 }
 
 // convertChatCompletionsResponseToCompletions converts chat completions API response back to completions format.
@@ -463,7 +555,6 @@ func (h *OpenAIAPIHandler) handleStreamingResponse(c *gin.Context, rawJSON []byt
 		c.Header("Content-Type", "text/event-stream")
 		c.Header("Cache-Control", "no-cache")
 		c.Header("Connection", "keep-alive")
-		c.Header("Access-Control-Allow-Origin", "*")
 	}
 
 	// Peek at the first chunk to determine success or failure before setting headers
@@ -515,13 +606,11 @@ func (h *OpenAIAPIHandler) handleStreamingResponse(c *gin.Context, rawJSON []byt
 //
 // Parameters:
 //   - c: The Gin context containing the HTTP request and response
-//   - rawJSON: The raw JSON bytes of the OpenAI-compatible completions request
-func (h *OpenAIAPIHandler) handleCompletionsNonStreamingResponse(c *gin.Context, rawJSON []byte) {
+//   - chatCompletionsJSON: The completions request converted to chat completions format
+//   - echo: The echo of the request's prompt, nil when echo is not requested
+func (h *OpenAIAPIHandler) handleCompletionsNonStreamingResponse(c *gin.Context, chatCompletionsJSON []byte, echo *completionsEcho) {
 	c.Header("Content-Type", "application/json")
 
-	// Convert completions request to chat completions format
-	chatCompletionsJSON := convertCompletionsRequestToChatCompletions(rawJSON)
-
 	modelName := gjson.GetBytes(chatCompletionsJSON, "model").String()
 	cliCtx, cliCancel := h.GetContextWithCancel(h, c, context.Background())
 	stopKeepAlive := h.StartNonStreamingKeepAlive(c, cliCtx)
@@ -532,7 +621,7 @@ func (h *OpenAIAPIHandler) handleCompletionsNonStreamingResponse(c *gin.Context,
 		cliCancel(errMsg.Error)
 		return
 	}
-	completionsResp := convertChatCompletionsResponseToCompletions(resp)
+	completionsResp := echo.apply(convertChatCompletionsResponseToCompletions(resp))
 	_, _ = c.Writer.Write(completionsResp)
 	cliCancel()
 }
@@ -543,8 +632,9 @@ func (h *OpenAIAPIHandler) handleCompletionsNonStreamingResponse(c *gin.Context,
 //
 // Parameters:
 //   - c: The Gin context containing the HTTP request and response
-//   - rawJSON: The raw JSON bytes of the OpenAI-compatible completions request
-func (h *OpenAIAPIHandler) handleCompletionsStreamingResponse(c *gin.Context, rawJSON []byte) {
+//   - chatCompletionsJSON: The completions request converted to chat completions format
+//   - echo: The echo of the request's prompt, nil when echo is not requested
+func (h *OpenAIAPIHandler) handleCompletionsStreamingResponse(c *gin.Context, chatCompletionsJSON []byte, echo *completionsEcho) {
 	// Get the http.Flusher interface to manually flush the response.
 	flusher, ok := c.Writer.(http.Flusher)
 	if !ok {
@@ -557,9 +647,6 @@ func (h *OpenAIAPIHandler) handleCompletionsStreamingResponse(c *gin.Context, ra
 		return
 	}
 
-	// Convert completions request to chat completions format
-	chatCompletionsJSON := convertCompletionsRequestToChatCompletions(rawJSON)
-
 	modelName := gjson.GetBytes(chatCompletionsJSON, "model").String()
 	cliCtx, cliCancel := h.GetContextWithCancel(h, c, context.Background())
 	dataChan, errChan := h.ExecuteStreamWithAuthManager(cliCtx, h.HandlerType(), modelName, chatCompletionsJSON, "")
@@ -568,7 +655,6 @@ func (h *OpenAIAPIHandler) handleCompletionsStreamingResponse(c *gin.Context, ra
 		c.Header("Content-Type", "text/event-stream")
 		c.Header("Cache-Control", "no-cache")
 		c.Header("Connection", "keep-alive")
-		c.Header("Access-Control-Allow-Origin", "*")
 	}
 
 	// Peek at the first chunk
@@ -605,6 +691,7 @@ func (h *OpenAIAPIHandler) handleCompletionsStreamingResponse(c *gin.Context, ra
 			// Write the first chunk
 			converted := convertChatCompletionsStreamChunkToCompletions(chunk)
 			if converted != nil {
+				converted = echo.apply(converted)
 				_, _ = fmt.Fprintf(c.Writer, "data: %s\n\n", string(converted))
 				flusher.Flush()
 			}
@@ -628,6 +715,7 @@ func (h *OpenAIAPIHandler) handleCompletionsStreamingResponse(c *gin.Context, ra
 						if converted == nil {
 							continue
 						}
+						converted = echo.apply(converted)
 						select {
 						case <-done:
 							return
@@ -654,15 +742,7 @@ func (h *OpenAIAPIHandler) handleStreamResult(c *gin.Context, flusher http.Flush
 			if errMsg == nil {
 				return
 			}
-			status := http.StatusInternalServerError
-			if errMsg.StatusCode > 0 {
-				status = errMsg.StatusCode
-			}
-			errText := http.StatusText(status)
-			if errMsg.Error != nil && errMsg.Error.Error() != "" {
-				errText = errMsg.Error.Error()
-			}
-			body := handlers.BuildErrorResponseBody(status, errText)
+			body := handlers.BuildErrorMessageBody(handlers.DialectOpenAI, errMsg)
 			_, _ = fmt.Fprintf(c.Writer, "data: %s\n\n", string(body))
 		},
 		WriteDone: func() {
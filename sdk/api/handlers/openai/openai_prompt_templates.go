@@ -0,0 +1,169 @@
+package openai
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers"
+	sdkconfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// promptVariablePattern matches {{name}} placeholders, allowing spaces inside the braces.
+var promptVariablePattern = regexp.MustCompile(`\{\{\s*([A-Za-z_][A-Za-z0-9_.-]*)\s*\}\}`)
+
+type promptTemplateInfo struct {
+	Name        string            `json:"name"`
+	Description string            `json:"description,omitempty"`
+	Model       string            `json:"model,omitempty"`
+	Variables   []string          `json:"variables"`
+	Defaults    map[string]string `json:"defaults,omitempty"`
+}
+
+// PromptTemplates handles GET /v1/prompt-templates, listing the configured templates and
+// the variables they take.
+func (h *OpenAIAPIHandler) PromptTemplates(c *gin.Context) {
+	data := make([]promptTemplateInfo, 0)
+	if h.Cfg != nil {
+		for _, tmpl := range h.Cfg.PromptTemplates {
+			data = append(data, promptTemplateInfo{
+				Name:        tmpl.Name,
+				Description: tmpl.Description,
+				Model:       tmpl.Model,
+				Variables:   promptTemplateVariables(tmpl),
+				Defaults:    tmpl.Defaults,
+			})
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"object": "list", "data": data})
+}
+
+// PromptTemplateCompletions handles POST /v1/prompt-templates/completions. It accepts a
+// Chat Completions request whose "template" names a configured prompt template and whose
+// "variables" object fills its placeholders. The rendered system and user messages replace
+// any messages of the request, which is then routed like /v1/chat/completions.
+func (h *OpenAIAPIHandler) PromptTemplateCompletions(c *gin.Context) {
+	rawJSON, err := c.GetRawData()
+	if err != nil {
+		promptTemplateError(c, http.StatusBadRequest, fmt.Sprintf("Invalid request: %v", err))
+		return
+	}
+	name := strings.TrimSpace(gjson.GetBytes(rawJSON, "template").String())
+	tmpl, ok := h.lookupPromptTemplate(name)
+	if !ok {
+		promptTemplateError(c, http.StatusNotFound, fmt.Sprintf("prompt template %q not found", name))
+		return
+	}
+	request, errMsg := renderPromptTemplateRequest(tmpl, rawJSON)
+	if errMsg != "" {
+		promptTemplateError(c, http.StatusBadRequest, errMsg)
+		return
+	}
+	if gjson.GetBytes(request, "stream").Type == gjson.True {
+		h.handleStreamingResponse(c, request)
+	} else {
+		h.handleNonStreamingResponse(c, request)
+	}
+}
+
+func (h *OpenAIAPIHandler) lookupPromptTemplate(name string) (sdkconfig.PromptTemplate, bool) {
+	if h.Cfg == nil || name == "" {
+		return sdkconfig.PromptTemplate{}, false
+	}
+	for _, tmpl := range h.Cfg.PromptTemplates {
+		if tmpl.Name == name {
+			return tmpl, true
+		}
+	}
+	return sdkconfig.PromptTemplate{}, false
+}
+
+// renderPromptTemplateRequest turns a render request into a Chat Completions request, or
+// returns a message describing why the request cannot be rendered.
+func renderPromptTemplateRequest(tmpl sdkconfig.PromptTemplate, rawJSON []byte) ([]byte, string) {
+	values := make(map[string]string, len(tmpl.Defaults))
+	for key, value := range tmpl.Defaults {
+		values[key] = value
+	}
+	variables := gjson.GetBytes(rawJSON, "variables")
+	if variables.Exists() && !variables.IsObject() {
+		return nil, "variables must be an object"
+	}
+	variables.ForEach(func(key, value gjson.Result) bool {
+		if value.Type == gjson.String {
+			values[key.String()] = value.String()
+		} else {
+			values[key.String()] = value.Raw
+		}
+		return true
+	})
+
+	var missing []string
+	for _, variable := range promptTemplateVariables(tmpl) {
+		if _, ok := values[variable]; !ok {
+			missing = append(missing, variable)
+		}
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Sprintf("prompt template %q is missing variables: %s", tmpl.Name, strings.Join(missing, ", "))
+	}
+
+	model := strings.TrimSpace(gjson.GetBytes(rawJSON, "model").String())
+	if model == "" {
+		model = tmpl.Model
+	}
+	if model == "" {
+		return nil, fmt.Sprintf("prompt template %q has no model; set model in the request", tmpl.Name)
+	}
+
+	messages := make([]map[string]string, 0, 2)
+	if strings.TrimSpace(tmpl.System) != "" {
+		messages = append(messages, map[string]string{"role": "system", "content": renderPromptText(tmpl.System, values)})
+	}
+	messages = append(messages, map[string]string{"role": "user", "content": renderPromptText(tmpl.Template, values)})
+
+	request := rawJSON
+	for _, path := range []string{"template", "variables"} {
+		request, _ = sjson.DeleteBytes(request, path)
+	}
+	request, _ = sjson.SetBytes(request, "model", model)
+	request, _ = sjson.SetBytes(request, "messages", messages)
+	return request, ""
+}
+
+// renderPromptText replaces the placeholders of text with their values.
+func renderPromptText(text string, values map[string]string) string {
+	return promptVariablePattern.ReplaceAllStringFunc(text, func(match string) string {
+		return values[promptVariablePattern.FindStringSubmatch(match)[1]]
+	})
+}
+
+// promptTemplateVariables returns the sorted variable names used by tmpl.
+func promptTemplateVariables(tmpl sdkconfig.PromptTemplate) []string {
+	seen := make(map[string]bool)
+	variables := make([]string, 0)
+	for _, text := range []string{tmpl.System, tmpl.Template} {
+		for _, match := range promptVariablePattern.FindAllStringSubmatch(text, -1) {
+			if !seen[match[1]] {
+				seen[match[1]] = true
+				variables = append(variables, match[1])
+			}
+		}
+	}
+	sort.Strings(variables)
+	return variables
+}
+
+func promptTemplateError(c *gin.Context, status int, message string) {
+	c.JSON(status, handlers.ErrorResponse{
+		Error: handlers.ErrorDetail{
+			Message: message,
+			Type:    "invalid_request_error",
+		},
+	})
+}
@@ -0,0 +1,75 @@
+package openai
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/api/handlers"
+	sdkconfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	"github.com/tidwall/gjson"
+)
+
+var reviewTemplate = sdkconfig.PromptTemplate{
+	Name:     "review",
+	Model:    "gpt-5",
+	System:   "You review {{ language }} code.",
+	Template: "Review this diff for {{focus}}:\n{{diff}}",
+	Defaults: map[string]string{"focus": "bugs"},
+}
+
+func TestRenderPromptTemplateRequest(t *testing.T) {
+	request, errMsg := renderPromptTemplateRequest(reviewTemplate, []byte(`{"template":"review","variables":{"language":"Go","diff":"+x := 1"},"temperature":0.1,"messages":[{"role":"user","content":"ignored"}]}`))
+	if errMsg != "" {
+		t.Fatal(errMsg)
+	}
+	parsed := gjson.ParseBytes(request)
+	if parsed.Get("template").Exists() || parsed.Get("variables").Exists() {
+		t.Fatalf("template fields leaked: %s", request)
+	}
+	if parsed.Get("model").String() != "gpt-5" || parsed.Get("temperature").Float() != 0.1 {
+		t.Fatalf("request = %s", request)
+	}
+	messages := parsed.Get("messages").Array()
+	if len(messages) != 2 || messages[0].Get("content").String() != "You review Go code." ||
+		messages[1].Get("content").String() != "Review this diff for bugs:\n+x := 1" {
+		t.Fatalf("messages = %s", parsed.Get("messages").Raw)
+	}
+
+	request, _ = renderPromptTemplateRequest(reviewTemplate, []byte(`{"model":"claude-sonnet","variables":{"language":"Go","diff":"d","focus":"style"}}`))
+	if gjson.GetBytes(request, "model").String() != "claude-sonnet" || !strings.Contains(gjson.GetBytes(request, "messages.1.content").String(), "for style") {
+		t.Fatalf("request = %s", request)
+	}
+
+	if _, errMsg = renderPromptTemplateRequest(reviewTemplate, []byte(`{"variables":{"language":"Go"}}`)); !strings.Contains(errMsg, "missing variables: diff") {
+		t.Fatalf("errMsg = %q", errMsg)
+	}
+}
+
+func TestPromptTemplateVariables(t *testing.T) {
+	if got, want := promptTemplateVariables(reviewTemplate), []string{"diff", "focus", "language"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("variables = %v, want %v", got, want)
+	}
+}
+
+func TestPromptTemplateCompletionsRejectsUnknownTemplate(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	h := NewOpenAIAPIHandler(handlers.NewBaseAPIHandlers(&sdkconfig.SDKConfig{PromptTemplates: []sdkconfig.PromptTemplate{reviewTemplate}}, nil))
+	router := gin.New()
+	router.POST("/v1/prompt-templates/completions", h.PromptTemplateCompletions)
+
+	for body, status := range map[string]int{
+		`{"template":"missing"}`:                      http.StatusNotFound,
+		`{"template":"review","variables":["Go"]}`:    http.StatusBadRequest,
+		`{"template":"review","variables":{"a":"b"}}`: http.StatusBadRequest,
+	} {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/prompt-templates/completions", strings.NewReader(body)))
+		if rec.Code != status {
+			t.Errorf("%s: status = %d, want %d (%s)", body, rec.Code, status, rec.Body.String())
+		}
+	}
+}
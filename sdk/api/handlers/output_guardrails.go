@@ -0,0 +1,465 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	log "github.com/sirupsen/logrus"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// GuardrailHeader lists the output guardrail rules that matched a non-streaming response.
+const GuardrailHeader = "X-CLIProxy-Guardrail"
+
+// guardrailTextKeys are the JSON keys whose string values carry generated text in the
+// OpenAI, Claude, Responses and Gemini formats.
+var guardrailTextKeys = map[string]bool{"content": true, "text": true, "delta": true}
+
+type guardrailRule struct {
+	name        string
+	annotate    bool
+	replacement string
+	patterns    []*regexp.Regexp
+}
+
+// outputGuardrails is the compiled form of config.OutputGuardrailConfig.
+type outputGuardrails struct {
+	rules  []guardrailRule
+	window int
+}
+
+// guardrailCache recompiles the guardrail rules when the configuration changes.
+type guardrailCache struct {
+	mu       sync.Mutex
+	source   config.OutputGuardrailConfig
+	compiled *outputGuardrails
+}
+
+func newGuardrailCache() *guardrailCache { return &guardrailCache{} }
+
+// get returns the compiled rules of cfg, or nil when no rule is configured.
+func (c *guardrailCache) get(cfg *config.SDKConfig) *outputGuardrails {
+	if c == nil || cfg == nil || len(cfg.OutputGuardrails.Rules) == 0 {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.compiled != nil && reflect.DeepEqual(c.source, cfg.OutputGuardrails) {
+		return c.compiled
+	}
+	c.source = cloneGuardrailConfig(cfg.OutputGuardrails)
+	c.compiled = compileGuardrails(c.source)
+	if len(c.compiled.rules) == 0 {
+		c.compiled = nil
+	}
+	return c.compiled
+}
+
+func cloneGuardrailConfig(cfg config.OutputGuardrailConfig) config.OutputGuardrailConfig {
+	out := cfg
+	out.Rules = make([]config.OutputGuardrailRule, len(cfg.Rules))
+	for i, rule := range cfg.Rules {
+		rule.Keywords = append([]string(nil), rule.Keywords...)
+		rule.Patterns = append([]string(nil), rule.Patterns...)
+		out.Rules[i] = rule
+	}
+	return out
+}
+
+func compileGuardrails(cfg config.OutputGuardrailConfig) *outputGuardrails {
+	g := &outputGuardrails{window: cfg.WindowBytes}
+	if g.window <= 0 {
+		g.window = config.DefaultGuardrailWindowBytes
+	}
+	for i, rule := range cfg.Rules {
+		compiled := guardrailRule{
+			name:        rule.Name,
+			annotate:    strings.EqualFold(rule.Action, config.GuardrailActionAnnotate),
+			replacement: rule.Replacement,
+		}
+		if compiled.name == "" {
+			compiled.name = "rule-" + strconv.Itoa(i+1)
+		}
+		if compiled.replacement == "" {
+			compiled.replacement = config.DefaultGuardrailReplacement
+		}
+		for _, keyword := range rule.Keywords {
+			if keyword = strings.TrimSpace(keyword); keyword != "" {
+				compiled.patterns = append(compiled.patterns, regexp.MustCompile("(?i)"+regexp.QuoteMeta(keyword)))
+			}
+		}
+		for _, pattern := range rule.Patterns {
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				log.Warnf("output guardrail %s: skipping invalid pattern %q: %v", compiled.name, pattern, err)
+				continue
+			}
+			compiled.patterns = append(compiled.patterns, re)
+		}
+		if len(compiled.patterns) > 0 {
+			g.rules = append(g.rules, compiled)
+		}
+	}
+	return g
+}
+
+type guardrailSpan struct {
+	start, end  int
+	replacement string
+}
+
+// scan applies the rules to texts as one contiguous string, so matches may span several
+// texts. Redacted text is replaced at the start of the match and removed from the texts it
+// continues into. It returns the rewritten texts and the names of the matching rules.
+func (g *outputGuardrails) scan(texts []string) ([]string, []string) {
+	full := strings.Join(texts, "")
+	var spans []guardrailSpan
+	var matched []string
+	for _, rule := range g.rules {
+		hit := false
+		for _, re := range rule.patterns {
+			for _, loc := range re.FindAllStringIndex(full, -1) {
+				if loc[1] <= loc[0] {
+					continue
+				}
+				hit = true
+				if !rule.annotate {
+					spans = append(spans, guardrailSpan{start: loc[0], end: loc[1], replacement: rule.replacement})
+				}
+			}
+		}
+		if hit {
+			matched = append(matched, rule.name)
+		}
+	}
+	if len(spans) == 0 {
+		return texts, matched
+	}
+
+	sort.SliceStable(spans, func(i, j int) bool { return spans[i].start < spans[j].start })
+	merged := spans[:1]
+	for _, span := range spans[1:] {
+		last := &merged[len(merged)-1]
+		if span.start < last.end {
+			if span.end > last.end {
+				last.end = span.end
+			}
+			continue
+		}
+		merged = append(merged, span)
+	}
+
+	out := make([]string, len(texts))
+	segStart, si := 0, 0
+	for i, text := range texts {
+		segEnd := segStart + len(text)
+		var b strings.Builder
+		pos := segStart
+		for pos < segEnd {
+			for si < len(merged) && merged[si].end <= pos {
+				si++
+			}
+			if si == len(merged) || merged[si].start >= segEnd {
+				b.WriteString(full[pos:segEnd])
+				break
+			}
+			span := merged[si]
+			if span.start > pos {
+				b.WriteString(full[pos:span.start])
+				pos = span.start
+			}
+			if pos == span.start {
+				b.WriteString(span.replacement)
+			}
+			pos = min(span.end, segEnd)
+		}
+		out[i] = b.String()
+		segStart = segEnd
+	}
+	return out, matched
+}
+
+// guardrailDoc is a JSON document inside a response chunk, either the whole chunk or the
+// payload of an SSE data line.
+type guardrailDoc struct {
+	start, end int
+	paths      []string
+	texts      []string
+}
+
+// parseGuardrailDocs finds the JSON documents of chunk and their generated text fields.
+func parseGuardrailDocs(chunk []byte) []guardrailDoc {
+	var docs []guardrailDoc
+	for _, bounds := range chunkJSONDocs(chunk) {
+		docs = collectGuardrailDoc(docs, chunk, bounds[0], bounds[1])
+	}
+	return docs
+}
+
+// chunkJSONDocs returns the start and end offsets of the JSON documents of a stream chunk,
+// which is either a bare document or SSE lines whose data are documents.
+func chunkJSONDocs(chunk []byte) [][2]int {
+	trimmed := bytes.TrimSpace(chunk)
+	if len(trimmed) > 0 && (trimmed[0] == '{' || trimmed[0] == '[') && gjson.ValidBytes(trimmed) {
+		start := bytes.Index(chunk, trimmed)
+		return [][2]int{{start, start + len(trimmed)}}
+	}
+	var bounds [][2]int
+	offset := 0
+	for _, line := range bytes.SplitAfter(chunk, []byte("\n")) {
+		lineStart := offset
+		offset += len(line)
+		if !bytes.HasPrefix(line, []byte("data:")) {
+			continue
+		}
+		payload := bytes.TrimSpace(line[len("data:"):])
+		if len(payload) == 0 || payload[0] != '{' || !gjson.ValidBytes(payload) {
+			continue
+		}
+		start := lineStart + bytes.Index(line, payload)
+		bounds = append(bounds, [2]int{start, start + len(payload)})
+	}
+	return bounds
+}
+
+func collectGuardrailDoc(docs []guardrailDoc, chunk []byte, start, end int) []guardrailDoc {
+	doc := guardrailDoc{start: start, end: end}
+	walkGuardrailText(gjson.ParseBytes(chunk[start:end]), "", &doc)
+	if len(doc.paths) == 0 {
+		return docs
+	}
+	return append(docs, doc)
+}
+
+func walkGuardrailText(node gjson.Result, prefix string, doc *guardrailDoc) {
+	index := 0
+	node.ForEach(func(key, value gjson.Result) bool {
+		segment := strconv.Itoa(index)
+		if node.IsObject() {
+			segment = escapeGuardrailKey(key.String())
+		}
+		index++
+		path := segment
+		if prefix != "" {
+			path = prefix + "." + segment
+		}
+		switch {
+		case value.Type == gjson.String && node.IsObject() && guardrailTextKeys[key.String()]:
+			doc.paths = append(doc.paths, path)
+			doc.texts = append(doc.texts, value.String())
+		case value.IsObject() || value.IsArray():
+			walkGuardrailText(value, path, doc)
+		}
+		return true
+	})
+}
+
+func escapeGuardrailKey(key string) string {
+	var b strings.Builder
+	for _, r := range key {
+		switch r {
+		case '.', '*', '?', '|', '#', '@', '\\', ':', '!', '=', '<', '>', '%':
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// renderGuardrailDocs writes the rewritten texts of docs back into chunk.
+func renderGuardrailDocs(chunk []byte, docs []guardrailDoc, texts [][]string) []byte {
+	var out bytes.Buffer
+	last := 0
+	for i, doc := range docs {
+		payload := chunk[doc.start:doc.end]
+		for j, path := range doc.paths {
+			if texts[i][j] == doc.texts[j] {
+				continue
+			}
+			if updated, err := sjson.SetBytes(payload, path, texts[i][j]); err == nil {
+				payload = updated
+			}
+		}
+		out.Write(chunk[last:doc.start])
+		out.Write(payload)
+		last = doc.end
+	}
+	out.Write(chunk[last:])
+	return out.Bytes()
+}
+
+// applyOutputGuardrails scans a non-streaming response. Each text field is scanned on its
+// own. Matching rule names are reported in GuardrailHeader.
+func (h *BaseAPIHandler) applyOutputGuardrails(ctx context.Context, payload []byte) []byte {
+	g := h.guardrails.get(h.Cfg)
+	if g == nil || len(payload) == 0 {
+		return payload
+	}
+	docs := parseGuardrailDocs(payload)
+	texts := make([][]string, len(docs))
+	var matched []string
+	for i, doc := range docs {
+		texts[i] = make([]string, len(doc.texts))
+		for j, text := range doc.texts {
+			out, names := g.scan([]string{text})
+			texts[i][j] = out[0]
+			matched = appendUnique(matched, names...)
+		}
+	}
+	if len(matched) == 0 {
+		return payload
+	}
+	log.Infof("output guardrails matched: %s", strings.Join(matched, ", "))
+	if ginCtx, ok := ctx.Value("gin").(*gin.Context); ok && ginCtx != nil && !ginCtx.Writer.Written() {
+		ginCtx.Header(GuardrailHeader, strings.Join(matched, ","))
+	}
+	return renderGuardrailDocs(payload, docs, texts)
+}
+
+// guardStream scans streamed chunks. Consecutive chunks carrying text form a run that is
+// scanned as a whole; a chunk is only released once at least the configured window of text
+// follows it, so a match split across chunks is redacted before any part of it is sent.
+// Headers are already sent for streams, so matches are only logged.
+func (h *BaseAPIHandler) guardStream(ctx context.Context, data <-chan []byte) <-chan []byte {
+	g := h.guardrails.get(h.Cfg)
+	if g == nil || data == nil {
+		return data
+	}
+	out := make(chan []byte)
+	go func() {
+		defer close(out)
+		s := &guardrailStream{g: g, out: out, ctx: ctx, matched: make(map[string]bool)}
+		for chunk := range data {
+			if !s.push(chunk) {
+				for range data {
+				}
+				return
+			}
+		}
+		s.flush()
+	}()
+	return out
+}
+
+type guardrailPending struct {
+	chunk []byte
+	docs  []guardrailDoc
+	size  int
+}
+
+type guardrailStream struct {
+	g       *outputGuardrails
+	out     chan<- []byte
+	ctx     context.Context
+	pending []guardrailPending
+	// context holds the already released text of the current run, so matches that started
+	// in it are still removed from the pending text.
+	context string
+	matched map[string]bool
+}
+
+func (s *guardrailStream) push(chunk []byte) bool {
+	docs := parseGuardrailDocs(chunk)
+	if len(docs) == 0 {
+		if !s.flush() {
+			return false
+		}
+		s.context = ""
+		return s.send(chunk)
+	}
+	size := 0
+	for _, doc := range docs {
+		for _, text := range doc.texts {
+			size += len(text)
+		}
+	}
+	s.pending = append(s.pending, guardrailPending{chunk: chunk, docs: docs, size: size})
+
+	release := 0
+	following := 0
+	for _, p := range s.pending {
+		following += p.size
+	}
+	for release < len(s.pending) && following-s.pending[release].size >= s.g.window {
+		following -= s.pending[release].size
+		release++
+	}
+	return s.release(release)
+}
+
+// flush releases every pending chunk.
+func (s *guardrailStream) flush() bool {
+	return s.release(len(s.pending))
+}
+
+// release scans the pending run and sends its first n chunks.
+func (s *guardrailStream) release(n int) bool {
+	if n == 0 {
+		return true
+	}
+	texts := []string{s.context}
+	for _, p := range s.pending {
+		for _, doc := range p.docs {
+			texts = append(texts, doc.texts...)
+		}
+	}
+	scanned, names := s.g.scan(texts)
+	for _, name := range names {
+		if !s.matched[name] {
+			s.matched[name] = true
+			log.Infof("output guardrail %s matched a streamed response", name)
+		}
+	}
+
+	next := 1
+	for _, p := range s.pending[:n] {
+		rewritten := make([][]string, len(p.docs))
+		for i, doc := range p.docs {
+			rewritten[i] = scanned[next : next+len(doc.texts)]
+			next += len(doc.texts)
+			s.context += strings.Join(doc.texts, "")
+		}
+		if !s.send(renderGuardrailDocs(p.chunk, p.docs, rewritten)) {
+			return false
+		}
+	}
+	if len(s.context) > s.g.window {
+		s.context = s.context[len(s.context)-s.g.window:]
+	}
+	s.pending = append(s.pending[:0], s.pending[n:]...)
+	return true
+}
+
+func (s *guardrailStream) send(chunk []byte) bool {
+	select {
+	case s.out <- chunk:
+		return true
+	case <-s.ctx.Done():
+		return false
+	}
+}
+
+func appendUnique(values []string, add ...string) []string {
+	for _, value := range add {
+		found := false
+		for _, existing := range values {
+			if existing == value {
+				found = true
+				break
+			}
+		}
+		if !found {
+			values = append(values, value)
+		}
+	}
+	return values
+}
@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	sdkconfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	"github.com/tidwall/gjson"
+)
+
+func newGuardrailHandler(window int) *BaseAPIHandler {
+	return NewBaseAPIHandlers(&sdkconfig.SDKConfig{OutputGuardrails: sdkconfig.OutputGuardrailConfig{
+		WindowBytes: window,
+		Rules: []sdkconfig.OutputGuardrailRule{
+			{Name: "api-keys", Patterns: []string{`sk-[A-Za-z0-9]{10}`}},
+			{Name: "codename", Keywords: []string{"Project Falcon"}, Action: sdkconfig.GuardrailActionAnnotate},
+		},
+	}}, nil)
+}
+
+func TestOutputGuardrailsScanAcrossTexts(t *testing.T) {
+	h := newGuardrailHandler(0)
+	g := h.guardrails.get(h.Cfg)
+	out, matched := g.scan([]string{"key: sk-abc", "defghij ok", " project falcon"})
+	if strings.Join(out, "|") != "key: [REDACTED]| ok| project falcon" {
+		t.Fatalf("scan = %q", out)
+	}
+	if strings.Join(matched, ",") != "api-keys,codename" {
+		t.Fatalf("matched = %v", matched)
+	}
+}
+
+func TestApplyOutputGuardrailsNonStreaming(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+	ctx := context.WithValue(context.Background(), "gin", c)
+
+	h := newGuardrailHandler(0)
+	payload := []byte(`{"id":"x","choices":[{"message":{"role":"assistant","content":"use sk-0123456789 for Project Falcon"}}]}`)
+	got := h.applyOutputGuardrails(ctx, payload)
+	if content := gjson.GetBytes(got, "choices.0.message.content").String(); content != "use [REDACTED] for Project Falcon" {
+		t.Fatalf("content = %q", content)
+	}
+	if header := c.Writer.Header().Get(GuardrailHeader); header != "api-keys,codename" {
+		t.Fatalf("%s = %q", GuardrailHeader, header)
+	}
+
+	clean := []byte(`{"choices":[{"message":{"content":"hello"}}]}`)
+	if got = h.applyOutputGuardrails(context.Background(), clean); string(got) != string(clean) {
+		t.Fatalf("clean payload rewritten: %s", got)
+	}
+}
+
+func TestGuardStreamRedactsMatchSplitAcrossChunks(t *testing.T) {
+	h := newGuardrailHandler(16)
+	data := make(chan []byte, 8)
+	for _, chunk := range []string{
+		`{"choices":[{"delta":{"role":"assistant"}}]}`,
+		`{"choices":[{"delta":{"content":"token sk-01"}}]}`,
+		`{"choices":[{"delta":{"content":"23456"}}]}`,
+		`{"choices":[{"delta":{"content":"789 is yours, enjoy it"}}]}`,
+		`{"choices":[{"delta":{},"finish_reason":"stop"}]}`,
+	} {
+		data <- []byte(chunk)
+	}
+	close(data)
+
+	var contents []string
+	chunks := 0
+	for chunk := range h.guardStream(context.Background(), data) {
+		chunks++
+		if content := gjson.GetBytes(chunk, "choices.0.delta.content"); content.Exists() {
+			contents = append(contents, content.String())
+		}
+	}
+	if chunks != 5 {
+		t.Fatalf("chunks = %d, want 5", chunks)
+	}
+	if got := strings.Join(contents, "|"); got != "token [REDACTED]|| is yours, enjoy it" {
+		t.Fatalf("streamed contents = %q", got)
+	}
+}
+
+func TestGuardStreamRewritesSSEEvents(t *testing.T) {
+	h := newGuardrailHandler(0)
+	data := make(chan []byte, 4)
+	data <- []byte("event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"delta\":{\"type\":\"text_delta\",\"text\":\"sk-AAAAAAAAAA\"}}\n\n")
+	data <- []byte("event: content_block_stop\ndata: {\"type\":\"content_block_stop\",\"index\":0}\n\n")
+	close(data)
+
+	var got []string
+	for chunk := range h.guardStream(context.Background(), data) {
+		got = append(got, string(chunk))
+	}
+	want := "event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"delta\":{\"type\":\"text_delta\",\"text\":\"[REDACTED]\"}}\n\n"
+	if len(got) != 2 || got[0] != want {
+		t.Fatalf("chunks = %q", got)
+	}
+}
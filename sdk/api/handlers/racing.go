@@ -0,0 +1,227 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/featureflag"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
+	log "github.com/sirupsen/logrus"
+)
+
+// errRaceNoResponse is reported when every raced target ended without a response.
+var errRaceNoResponse = errors.New("no raced target returned a response")
+
+// raceResult is the outcome of one raced target: a payload, or the first stream chunk.
+type raceResult struct {
+	index   int
+	payload []byte
+	errMsg  *interfaces.ErrorMessage
+}
+
+// raceTargets returns the targets modelName is raced across, or nil when it has no race
+// rule or the speculative-racing feature flag is off.
+func (h *BaseAPIHandler) raceTargets(modelName string) []string {
+	if h.Cfg == nil || !featureflag.Enabled(featureflag.SpeculativeRacing) {
+		return nil
+	}
+	return h.Cfg.RaceTargets(modelName)
+}
+
+// executeRace sends a non-streaming request to every target and returns the first
+// successful response, cancelling the others. Each target runs against its own copy of the
+// gin context; the winner's keys and headers are copied to the request afterwards. When all
+// targets fail the last error is returned.
+func (h *BaseAPIHandler) executeRace(ctx context.Context, handlerType string, targets []string, rawJSON []byte, alt string) ([]byte, *interfaces.ErrorMessage) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	raceCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	parent, _ := ctx.Value("gin").(*gin.Context)
+	attempts := make([]*gin.Context, len(targets))
+	results := make(chan raceResult, len(targets))
+	for i, target := range targets {
+		attemptCtx := raceCtx
+		if parent != nil {
+			attempts[i] = newRaceAttempt(parent)
+			attemptCtx = context.WithValue(raceCtx, "gin", attempts[i])
+		}
+		go func(i int, target string) {
+			payload, errMsg := h.executeWithAuthManager(attemptCtx, handlerType, target, rawJSON, alt)
+			results <- raceResult{index: i, payload: payload, errMsg: errMsg}
+		}(i, target)
+	}
+
+	var lastErr *interfaces.ErrorMessage
+	for range targets {
+		result := <-results
+		if result.errMsg == nil {
+			logRaceWinner(ctx, targets, result.index)
+			adoptRaceAttempt(parent, attempts[result.index])
+			return result.payload, nil
+		}
+		lastErr = result.errMsg
+	}
+	return nil, lastErr
+}
+
+// newRaceAttempt returns a gin context for one raced target: it shares the request and a
+// snapshot of the keys of parent but records headers and writes on its own.
+func newRaceAttempt(parent *gin.Context) *gin.Context {
+	attempt, _ := gin.CreateTestContext(httptest.NewRecorder())
+	attempt.Request = parent.Request
+	attempt.Keys = parent.Copy().Keys
+	return attempt
+}
+
+// adoptRaceAttempt copies the keys and response headers the winning attempt set to parent.
+func adoptRaceAttempt(parent, attempt *gin.Context) {
+	if parent == nil || attempt == nil {
+		return
+	}
+	for key, value := range attempt.Keys {
+		parent.Set(key, value)
+	}
+	for key, values := range attempt.Writer.Header() {
+		parent.Writer.Header()[key] = values
+	}
+}
+
+// raceContender is one streaming target of a race.
+type raceContender struct {
+	data   <-chan []byte
+	errs   <-chan *interfaces.ErrorMessage
+	cancel context.CancelFunc
+}
+
+// executeStreamRace opens a stream to every target and forwards the one that produces its
+// first chunk earliest; the other streams are cancelled as soon as a winner is known.
+func (h *BaseAPIHandler) executeStreamRace(ctx context.Context, handlerType string, targets []string, rawJSON []byte, alt string) (<-chan []byte, <-chan *interfaces.ErrorMessage) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	contenders := make([]raceContender, len(targets))
+	results := make(chan raceResult, len(targets))
+	for i, target := range targets {
+		contenderCtx, cancel := context.WithCancel(ctx)
+		data, errs := h.executeStreamWithAuthManager(contenderCtx, handlerType, target, rawJSON, alt)
+		contenders[i] = raceContender{data: data, errs: errs, cancel: cancel}
+		go func(i int) {
+			chunk, errMsg := firstStreamEvent(contenderCtx, data, errs)
+			results <- raceResult{index: i, payload: chunk, errMsg: errMsg}
+		}(i)
+	}
+
+	dataChan := make(chan []byte)
+	errChan := make(chan *interfaces.ErrorMessage, 1)
+	go func() {
+		defer close(dataChan)
+		defer close(errChan)
+		defer func() {
+			for _, contender := range contenders {
+				contender.cancel()
+			}
+		}()
+
+		var lastErr *interfaces.ErrorMessage
+		for range targets {
+			var result raceResult
+			select {
+			case <-ctx.Done():
+				return
+			case result = <-results:
+			}
+			if result.payload == nil {
+				contenders[result.index].cancel()
+				if result.errMsg != nil {
+					lastErr = result.errMsg
+				}
+				continue
+			}
+			for i, contender := range contenders {
+				if i != result.index {
+					contender.cancel()
+				}
+			}
+			logRaceWinner(ctx, targets, result.index)
+			winner := contenders[result.index]
+			forwardStream(ctx, result.payload, winner.data, winner.errs, dataChan, errChan)
+			return
+		}
+		if lastErr == nil {
+			lastErr = &interfaces.ErrorMessage{StatusCode: http.StatusBadGateway, Error: errRaceNoResponse}
+		}
+		errChan <- lastErr
+	}()
+	return dataChan, errChan
+}
+
+// firstStreamEvent waits for the first chunk or error of a stream. A nil chunk and error
+// mean the stream ended empty or ctx was cancelled.
+func firstStreamEvent(ctx context.Context, data <-chan []byte, errs <-chan *interfaces.ErrorMessage) ([]byte, *interfaces.ErrorMessage) {
+	for data != nil || errs != nil {
+		select {
+		case <-ctx.Done():
+			return nil, nil
+		case chunk, ok := <-data:
+			if !ok {
+				data = nil
+				continue
+			}
+			return chunk, nil
+		case errMsg, ok := <-errs:
+			if !ok {
+				errs = nil
+				continue
+			}
+			if errMsg != nil {
+				return nil, errMsg
+			}
+		}
+	}
+	return nil, nil
+}
+
+// forwardStream sends first and then the rest of the winning stream to the race output.
+func forwardStream(ctx context.Context, first []byte, data <-chan []byte, errs <-chan *interfaces.ErrorMessage, out chan<- []byte, outErrs chan<- *interfaces.ErrorMessage) {
+	select {
+	case <-ctx.Done():
+		return
+	case out <- first:
+	}
+	for data != nil || errs != nil {
+		select {
+		case <-ctx.Done():
+			return
+		case chunk, ok := <-data:
+			if !ok {
+				data = nil
+				continue
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case out <- chunk:
+			}
+		case errMsg, ok := <-errs:
+			if !ok {
+				errs = nil
+				continue
+			}
+			if errMsg != nil {
+				outErrs <- errMsg
+				return
+			}
+		}
+	}
+}
+
+func logRaceWinner(ctx context.Context, targets []string, winner int) {
+	log.WithField("request_id", logging.GetRequestID(ctx)).Debugf("speculative race %v won by %s", targets, targets[winner])
+}
@@ -0,0 +1,163 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/featureflag"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	coreexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	sdkconfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+)
+
+// raceExecutor answers "fast-model" immediately and holds "slow-model" until cancelled.
+type raceExecutor struct {
+	slowCancelled atomic.Int32
+}
+
+func (e *raceExecutor) Identifier() string { return "codex" }
+
+func (e *raceExecutor) wait(ctx context.Context, model string) ([]byte, error) {
+	if ginCtx, ok := ctx.Value("gin").(*gin.Context); ok {
+		ginCtx.Set("race-target", model)
+		ginCtx.Writer.Header().Set("X-Race-Target", model)
+	}
+	if model == "fast-model" {
+		return []byte("fast"), nil
+	}
+	select {
+	case <-ctx.Done():
+		e.slowCancelled.Add(1)
+		return nil, ctx.Err()
+	case <-time.After(2 * time.Second):
+		return []byte("slow"), nil
+	}
+}
+
+func (e *raceExecutor) Execute(ctx context.Context, _ *coreauth.Auth, req coreexecutor.Request, _ coreexecutor.Options) (coreexecutor.Response, error) {
+	payload, err := e.wait(ctx, req.Model)
+	return coreexecutor.Response{Payload: payload}, err
+}
+
+func (e *raceExecutor) ExecuteStream(ctx context.Context, _ *coreauth.Auth, req coreexecutor.Request, _ coreexecutor.Options) (<-chan coreexecutor.StreamChunk, error) {
+	ch := make(chan coreexecutor.StreamChunk, 2)
+	go func() {
+		defer close(ch)
+		payload, err := e.wait(ctx, req.Model)
+		if err != nil {
+			ch <- coreexecutor.StreamChunk{Err: err}
+			return
+		}
+		ch <- coreexecutor.StreamChunk{Payload: payload}
+		ch <- coreexecutor.StreamChunk{Payload: []byte("-done")}
+	}()
+	return ch, nil
+}
+
+func (e *raceExecutor) Refresh(_ context.Context, auth *coreauth.Auth) (*coreauth.Auth, error) {
+	return auth, nil
+}
+
+func (e *raceExecutor) CountTokens(context.Context, *coreauth.Auth, coreexecutor.Request, coreexecutor.Options) (coreexecutor.Response, error) {
+	return coreexecutor.Response{}, &coreauth.Error{Code: "not_implemented", Message: "CountTokens not implemented"}
+}
+
+func (e *raceExecutor) HttpRequest(context.Context, *coreauth.Auth, *http.Request) (*http.Response, error) {
+	return nil, &coreauth.Error{Code: "not_implemented", Message: "HttpRequest not implemented", HTTPStatus: http.StatusNotImplemented}
+}
+
+func newRaceHandler(t *testing.T) (*BaseAPIHandler, *raceExecutor) {
+	t.Helper()
+	executor := &raceExecutor{}
+	manager := coreauth.NewManager(nil, nil, nil)
+	manager.RegisterExecutor(executor)
+	auth := &coreauth.Auth{ID: "race-auth", Provider: "codex", Status: coreauth.StatusActive}
+	if _, err := manager.Register(context.Background(), auth); err != nil {
+		t.Fatalf("manager.Register: %v", err)
+	}
+	registry.GetGlobalRegistry().RegisterClient(auth.ID, auth.Provider, []*registry.ModelInfo{{ID: "fast-model"}, {ID: "slow-model"}})
+	t.Cleanup(func() { registry.GetGlobalRegistry().UnregisterClient(auth.ID) })
+
+	handler := NewBaseAPIHandlers(&sdkconfig.SDKConfig{
+		SpeculativeRacing: []sdkconfig.RaceRule{{Model: "tab", Targets: []string{"slow-model", "fast-model"}}},
+	}, manager)
+	return handler, executor
+}
+
+func TestExecuteStreamRaceForwardsFirstResponder(t *testing.T) {
+	handler, executor := newRaceHandler(t)
+	dataChan, errChan := handler.ExecuteStreamWithAuthManager(context.Background(), "openai", "tab", []byte(`{"model":"tab"}`), "")
+
+	var got []byte
+	for chunk := range dataChan {
+		got = append(got, chunk...)
+	}
+	for msg := range errChan {
+		if msg != nil {
+			t.Fatalf("unexpected error: %+v", msg)
+		}
+	}
+	if string(got) != "fast-done" {
+		t.Fatalf("payload = %q, want fast-done", got)
+	}
+	deadline := time.Now().Add(time.Second)
+	for executor.slowCancelled.Load() == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("losing stream was not cancelled")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestExecuteRaceReturnsFirstResponse(t *testing.T) {
+	handler, executor := newRaceHandler(t)
+	start := time.Now()
+	resp, errMsg := handler.ExecuteWithAuthManager(context.Background(), "openai", "tab", []byte(`{"model":"tab"}`), "")
+	if errMsg != nil {
+		t.Fatalf("unexpected error: %+v", errMsg)
+	}
+	if string(resp) != "fast" || time.Since(start) > time.Second {
+		t.Fatalf("response = %q after %v, want fast without waiting for the slow target", resp, time.Since(start))
+	}
+	deadline := time.Now().Add(time.Second)
+	for executor.slowCancelled.Load() == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("losing request was not cancelled")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestRaceTargetsHonorsFeatureFlag(t *testing.T) {
+	handler, _ := newRaceHandler(t)
+	featureflag.Default().Set(featureflag.SpeculativeRacing, false)
+	t.Cleanup(func() { featureflag.Default().Reset(featureflag.SpeculativeRacing) })
+	if targets := handler.raceTargets("tab"); targets != nil {
+		t.Fatalf("raceTargets = %v with speculative-racing off, want none", targets)
+	}
+}
+
+func TestExecuteRaceKeepsAttemptsApart(t *testing.T) {
+	handler, _ := newRaceHandler(t)
+	gin.SetMode(gin.TestMode)
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+	ctx := context.WithValue(context.Background(), "gin", c)
+
+	if _, errMsg := handler.ExecuteWithAuthManager(ctx, "openai", "tab", []byte(`{"model":"tab"}`), ""); errMsg != nil {
+		t.Fatalf("unexpected error: %+v", errMsg)
+	}
+	if target := c.GetString("race-target"); target != "fast-model" {
+		t.Fatalf("race-target = %q, want the winner's", target)
+	}
+	if target := c.Writer.Header().Get("X-Race-Target"); target != "fast-model" {
+		t.Fatalf("X-Race-Target = %q, want the winner's", target)
+	}
+}
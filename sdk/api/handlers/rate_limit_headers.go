@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+)
+
+// rateLimitHeaderWriter adds the rate limit headers stashed by the executor of the latest
+// upstream attempt to the client response just before its headers are sent. Headers set by
+// the handler itself, such as a cooldown Retry-After, take precedence.
+type rateLimitHeaderWriter struct {
+	gin.ResponseWriter
+	c       *gin.Context
+	applied bool
+}
+
+// wrapRateLimitHeaders installs the writer on c once.
+func wrapRateLimitHeaders(c *gin.Context) {
+	if c == nil || c.Writer == nil {
+		return
+	}
+	if _, ok := c.Writer.(*rateLimitHeaderWriter); ok {
+		return
+	}
+	c.Writer = &rateLimitHeaderWriter{ResponseWriter: c.Writer, c: c}
+}
+
+func (w *rateLimitHeaderWriter) apply() {
+	if w.applied || w.ResponseWriter.Written() {
+		return
+	}
+	w.applied = true
+	value, ok := w.c.Get(util.UpstreamRateLimitHeadersKey)
+	if !ok {
+		return
+	}
+	upstream, ok := value.(http.Header)
+	if !ok {
+		return
+	}
+	header := w.ResponseWriter.Header()
+	for key, values := range upstream {
+		if len(values) == 0 || header.Get(key) != "" {
+			continue
+		}
+		header[key] = append([]string(nil), values...)
+	}
+}
+
+func (w *rateLimitHeaderWriter) WriteHeader(code int) {
+	w.apply()
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *rateLimitHeaderWriter) WriteHeaderNow() {
+	w.apply()
+	w.ResponseWriter.WriteHeaderNow()
+}
+
+func (w *rateLimitHeaderWriter) Write(data []byte) (int, error) {
+	w.apply()
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *rateLimitHeaderWriter) WriteString(s string) (int, error) {
+	w.apply()
+	return w.ResponseWriter.WriteString(s)
+}
+
+func (w *rateLimitHeaderWriter) Flush() {
+	w.apply()
+	w.ResponseWriter.Flush()
+}
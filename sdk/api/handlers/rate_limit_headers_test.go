@@ -0,0 +1,36 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+)
+
+func TestRateLimitHeaderWriterForwardsStashedHeaders(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+
+	h := &BaseAPIHandler{Cfg: &config.SDKConfig{}}
+	_, cancel := h.GetContextWithCancel(nil, c, c.Request.Context())
+	defer cancel()
+
+	c.Set(util.UpstreamRateLimitHeadersKey, http.Header{
+		"X-Ratelimit-Remaining-Requests": {"0"},
+		"Retry-After":                    {"30"},
+	})
+	c.Header("Retry-After", "5")
+	c.Data(http.StatusTooManyRequests, "application/json", []byte(`{}`))
+
+	if got := recorder.Header().Get("X-Ratelimit-Remaining-Requests"); got != "0" {
+		t.Fatalf("X-Ratelimit-Remaining-Requests = %q, want 0", got)
+	}
+	if got := recorder.Header().Get("Retry-After"); got != "5" {
+		t.Fatalf("Retry-After = %q, want the handler value 5", got)
+	}
+}
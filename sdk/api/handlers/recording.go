@@ -0,0 +1,212 @@
+package handlers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	log "github.com/sirupsen/logrus"
+)
+
+// RecordingHeader names the recording a response was replayed from, or was stored as.
+const RecordingHeader = "X-CLIProxy-Recording"
+
+// errNoRecording fails replay-mode requests without a stored response.
+var errNoRecording = errors.New("no recorded response for this request")
+
+// recording is one stored response.
+type recording struct {
+	HandlerType string    `json:"handler_type"`
+	Model       string    `json:"model"`
+	Alt         string    `json:"alt,omitempty"`
+	Stream      bool      `json:"stream"`
+	RecordedAt  time.Time `json:"recorded_at"`
+	Request     string    `json:"request"`
+	Response    string    `json:"response,omitempty"`
+	Chunks      []string  `json:"chunks,omitempty"`
+}
+
+func (h *BaseAPIHandler) recordingConfig() config.RecordingConfig {
+	if h.Cfg == nil {
+		return config.RecordingConfig{}
+	}
+	return h.Cfg.Recording
+}
+
+// recordingKey hashes the request, ignoring the configured fields. Bodies that are not JSON
+// objects are hashed verbatim.
+func recordingKey(settings config.RecordingConfig, handlerType, modelName, alt string, stream bool, rawJSON []byte) string {
+	body := rawJSON
+	var fields map[string]any
+	if err := json.Unmarshal(rawJSON, &fields); err == nil {
+		for _, name := range settings.IgnoreFields {
+			delete(fields, name)
+		}
+		// Marshalling a map sorts the keys, so field order does not change the key.
+		if normalized, errMarshal := json.Marshal(fields); errMarshal == nil {
+			body = normalized
+		}
+	}
+	sum := sha256.New()
+	_, _ = fmt.Fprintf(sum, "%s\n%s\n%s\n%t\n", handlerType, modelName, alt, stream)
+	sum.Write(body)
+	return hex.EncodeToString(sum.Sum(nil))[:32]
+}
+
+func loadRecording(settings config.RecordingConfig, key string) (*recording, bool) {
+	data, err := os.ReadFile(filepath.Join(settings.Dir, key+".json"))
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			log.Warnf("recording: failed to read %s: %v", key, err)
+		}
+		return nil, false
+	}
+	var rec recording
+	if err = json.Unmarshal(data, &rec); err != nil {
+		log.Warnf("recording: ignoring corrupt recording %s: %v", key, err)
+		return nil, false
+	}
+	return &rec, true
+}
+
+func saveRecording(settings config.RecordingConfig, key string, rec *recording) {
+	rec.RecordedAt = time.Now().UTC()
+	data, err := json.MarshalIndent(rec, "", "  ")
+	if err == nil {
+		if err = os.MkdirAll(settings.Dir, 0o700); err == nil {
+			path := filepath.Join(settings.Dir, key+".json")
+			if err = os.WriteFile(path+".tmp", data, 0o600); err == nil {
+				err = os.Rename(path+".tmp", path)
+			}
+		}
+	}
+	if err != nil {
+		log.Warnf("recording: failed to store %s: %v", key, err)
+	}
+}
+
+func setRecordingHeader(ctx context.Context, value string) {
+	if ginCtx, ok := ctx.Value("gin").(*gin.Context); ok && ginCtx != nil {
+		ginCtx.Header(RecordingHeader, value)
+	}
+}
+
+func missingRecording(key string) *interfaces.ErrorMessage {
+	return &interfaces.ErrorMessage{StatusCode: http.StatusNotFound, Error: fmt.Errorf("%w (key %s)", errNoRecording, key)}
+}
+
+// executeRecorded answers non-streaming requests from recordings in the replay modes and
+// stores successful responses in the record modes.
+func (h *BaseAPIHandler) executeRecorded(ctx context.Context, handlerType, modelName string, rawJSON []byte, alt string, execute func() ([]byte, *interfaces.ErrorMessage)) ([]byte, *interfaces.ErrorMessage) {
+	settings := h.recordingConfig()
+	if settings.Mode == "" || ctx == nil {
+		return execute()
+	}
+	key := recordingKey(settings, handlerType, modelName, alt, false, rawJSON)
+	if settings.Replays() {
+		if rec, ok := loadRecording(settings, key); ok && !rec.Stream {
+			setRecordingHeader(ctx, "replayed; key="+key)
+			return []byte(rec.Response), nil
+		}
+		if !settings.Records() {
+			return nil, missingRecording(key)
+		}
+	}
+	resp, errMsg := execute()
+	if errMsg == nil {
+		saveRecording(settings, key, &recording{HandlerType: handlerType, Model: modelName, Alt: alt, Request: string(rawJSON), Response: string(resp)})
+		setRecordingHeader(ctx, "recorded; key="+key)
+	}
+	return resp, errMsg
+}
+
+// replayStream serves a recorded stream. ok is false when the request must go upstream.
+func (h *BaseAPIHandler) replayStream(ctx context.Context, handlerType, modelName string, rawJSON []byte, alt string) (<-chan []byte, <-chan *interfaces.ErrorMessage, bool) {
+	settings := h.recordingConfig()
+	if !settings.Replays() || ctx == nil {
+		return nil, nil, false
+	}
+	key := recordingKey(settings, handlerType, modelName, alt, true, rawJSON)
+	errChan := make(chan *interfaces.ErrorMessage, 1)
+	rec, found := loadRecording(settings, key)
+	if !found || !rec.Stream {
+		if settings.Records() {
+			return nil, nil, false
+		}
+		errChan <- missingRecording(key)
+		close(errChan)
+		return nil, errChan, true
+	}
+	setRecordingHeader(ctx, "replayed; key="+key)
+	dataChan := make(chan []byte)
+	go func() {
+		defer close(dataChan)
+		defer close(errChan)
+		for _, chunk := range rec.Chunks {
+			select {
+			case <-ctx.Done():
+				return
+			case dataChan <- []byte(chunk):
+			}
+		}
+	}()
+	return dataChan, errChan, true
+}
+
+// recordStream stores the chunks of a stream that completes without error in the record
+// modes, passing them through unchanged.
+func (h *BaseAPIHandler) recordStream(ctx context.Context, handlerType, modelName string, rawJSON []byte, alt string, data <-chan []byte, errs <-chan *interfaces.ErrorMessage) (<-chan []byte, <-chan *interfaces.ErrorMessage) {
+	settings := h.recordingConfig()
+	if !settings.Records() || ctx == nil || data == nil {
+		return data, errs
+	}
+	key := recordingKey(settings, handlerType, modelName, alt, true, rawJSON)
+	setRecordingHeader(ctx, "recorded; key="+key)
+	outData := make(chan []byte)
+	outErr := make(chan *interfaces.ErrorMessage, 1)
+	go func() {
+		defer close(outErr)
+		defer close(outData)
+		var chunks []string
+		failed := false
+		for data != nil || errs != nil {
+			select {
+			case chunk, ok := <-data:
+				if !ok {
+					data = nil
+					continue
+				}
+				chunks = append(chunks, string(chunk))
+				select {
+				case outData <- chunk:
+				case <-ctx.Done():
+					return
+				}
+			case errMsg, ok := <-errs:
+				if !ok {
+					errs = nil
+					continue
+				}
+				if errMsg != nil {
+					failed = true
+					outErr <- errMsg
+					errs = nil
+				}
+			}
+		}
+		if !failed && ctx.Err() == nil && len(chunks) > 0 {
+			saveRecording(settings, key, &recording{HandlerType: handlerType, Model: modelName, Alt: alt, Stream: true, Request: string(rawJSON), Chunks: chunks})
+		}
+	}()
+	return outData, outErr
+}
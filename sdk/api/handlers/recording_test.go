@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	sdkconfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+)
+
+func newRecordingTestHandler(t *testing.T, mode string) *BaseAPIHandler {
+	t.Helper()
+	return NewBaseAPIHandlers(&sdkconfig.SDKConfig{Recording: sdkconfig.RecordingConfig{
+		Mode:         mode,
+		Dir:          t.TempDir(),
+		IgnoreFields: []string{"user"},
+	}}, nil)
+}
+
+func TestRecordingReplaysRecordedResponses(t *testing.T) {
+	h := newRecordingTestHandler(t, sdkconfig.RecordingRecord)
+	calls := 0
+	execute := func() ([]byte, *interfaces.ErrorMessage) {
+		calls++
+		return []byte(`{"answer":"sunny"}`), nil
+	}
+	request := []byte(`{"model":"gpt-5","user":"alice","messages":[{"role":"user","content":"weather?"}]}`)
+
+	ctx, c := semanticTestContext("key-a")
+	if _, errMsg := h.executeRecorded(ctx, "openai", "gpt-5", request, "", execute); errMsg != nil {
+		t.Fatal(errMsg.Error)
+	}
+	if got := c.Writer.Header().Get(RecordingHeader); !strings.HasPrefix(got, "recorded") {
+		t.Fatalf("header = %q, want recorded", got)
+	}
+
+	// Same request with a different ignored field and key order.
+	h.Cfg.Recording.Mode = sdkconfig.RecordingReplay
+	reordered := []byte(`{"messages":[{"role":"user","content":"weather?"}],"model":"gpt-5","user":"bob"}`)
+	ctx, c = semanticTestContext("key-a")
+	resp, errMsg := h.executeRecorded(ctx, "openai", "gpt-5", reordered, "", execute)
+	if errMsg != nil {
+		t.Fatal(errMsg.Error)
+	}
+	if string(resp) != `{"answer":"sunny"}` || calls != 1 {
+		t.Fatalf("resp = %s after %d calls, want the recorded response", resp, calls)
+	}
+	if got := c.Writer.Header().Get(RecordingHeader); !strings.HasPrefix(got, "replayed") {
+		t.Fatalf("header = %q, want replayed", got)
+	}
+
+	other := []byte(`{"model":"gpt-5","messages":[{"role":"user","content":"news?"}]}`)
+	ctx, _ = semanticTestContext("key-a")
+	if _, errMsg = h.executeRecorded(ctx, "openai", "gpt-5", other, "", execute); errMsg == nil || errMsg.StatusCode != http.StatusNotFound {
+		t.Fatalf("errMsg = %+v, want 404 for a missing recording", errMsg)
+	}
+	if calls != 1 {
+		t.Fatalf("replay mode went upstream")
+	}
+}
+
+func TestRecordingReplaysStreams(t *testing.T) {
+	h := newRecordingTestHandler(t, sdkconfig.RecordingReplayOrRecord)
+	request := []byte(`{"model":"gpt-5","stream":true}`)
+	ctx, _ := semanticTestContext("key-a")
+
+	if _, _, ok := h.replayStream(ctx, "openai", "gpt-5", request, ""); ok {
+		t.Fatal("replay-or-record served a missing recording")
+	}
+	upstream := make(chan []byte, 2)
+	upstream <- []byte("data: one")
+	upstream <- []byte("data: two")
+	close(upstream)
+	upstreamErrs := make(chan *interfaces.ErrorMessage)
+	close(upstreamErrs)
+	data, errs := h.recordStream(ctx, "openai", "gpt-5", request, "", upstream, upstreamErrs)
+	var recorded []string
+	for chunk := range data {
+		recorded = append(recorded, string(chunk))
+	}
+	for range errs {
+	}
+	if strings.Join(recorded, ",") != "data: one,data: two" {
+		t.Fatalf("recorded stream passed through %q", recorded)
+	}
+
+	data, errs, ok := h.replayStream(ctx, "openai", "gpt-5", request, "")
+	if !ok {
+		t.Fatal("recorded stream was not replayed")
+	}
+	var replayed []string
+	for chunk := range data {
+		replayed = append(replayed, string(chunk))
+	}
+	for errMsg := range errs {
+		t.Fatal(errMsg.Error)
+	}
+	if strings.Join(replayed, ",") != "data: one,data: two" {
+		t.Fatalf("replayed %q", replayed)
+	}
+}
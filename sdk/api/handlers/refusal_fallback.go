@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"context"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	log "github.com/sirupsen/logrus"
+	"github.com/tidwall/gjson"
+)
+
+// RefusalFallbackHeader names the model that answered after the requested model refused.
+const RefusalFallbackHeader = "X-CLIProxy-Refusal-Fallback"
+
+// refusalPatternPrefix is how much of the response text refusal patterns are matched
+// against; refusals lead the response, while longer answers may quote the phrases.
+const refusalPatternPrefix = 300
+
+// geminiRefusalReasons are the Gemini finish reasons of policy blocks.
+var geminiRefusalReasons = map[string]bool{
+	"SAFETY": true, "PROHIBITED_CONTENT": true, "BLOCKLIST": true, "SPII": true, "IMAGE_SAFETY": true,
+}
+
+// executeRefusalFallback runs execute for modelName and, when the response is a
+// content-policy refusal and a fallback is configured, retries once on the fallback model.
+// The refusal is returned when the fallback fails.
+func (h *BaseAPIHandler) executeRefusalFallback(ctx context.Context, modelName string, execute func(model string) ([]byte, *interfaces.ErrorMessage)) ([]byte, *interfaces.ErrorMessage) {
+	resp, errMsg := execute(modelName)
+	if errMsg != nil || h.Cfg == nil || len(h.Cfg.RefusalFallback.Rules) == 0 {
+		return resp, errMsg
+	}
+	fallback := refusalFallbackModel(h.Cfg.RefusalFallback.Rules, modelName)
+	if fallback == "" || !isRefusal(resp, h.Cfg.RefusalFallback.Patterns) {
+		return resp, nil
+	}
+	logger := log.WithField("request_id", logging.GetRequestID(ctx))
+	retried, retryErr := execute(fallback)
+	if retryErr != nil {
+		logger.Warnf("refusal fallback: %s refused and fallback %s failed: %v", modelName, fallback, retryErr.Error)
+		return resp, nil
+	}
+	logger.Infof("refusal fallback: %s refused, answered by %s", modelName, fallback)
+	if ginCtx, _ := ctx.Value("gin").(*gin.Context); ginCtx != nil {
+		ginCtx.Header(RefusalFallbackHeader, fallback)
+	}
+	return retried, nil
+}
+
+// refusalFallbackModel returns the fallback of the first rule matching model.
+func refusalFallbackModel(rules []config.RefusalFallbackRule, model string) string {
+	model = strings.TrimSpace(model)
+	for _, rule := range rules {
+		if strings.EqualFold(rule.Fallback, model) {
+			continue
+		}
+		for _, pattern := range rule.Models {
+			if util.MatchWildcard(pattern, model) {
+				return rule.Fallback
+			}
+		}
+	}
+	return ""
+}
+
+// isRefusal reports whether a response, in any client format, refuses the request: through
+// the provider's refusal markers, or through one of the lowercase patterns near the start of
+// its text.
+func isRefusal(resp []byte, patterns []string) bool {
+	root := gjson.ParseBytes(resp)
+	if inner := root.Get("response"); inner.IsObject() {
+		// Gemini CLI wraps the Gemini response.
+		root = inner
+	}
+	if root.Get("stop_reason").String() == "refusal" || root.Get("promptFeedback.blockReason").String() != "" ||
+		root.Get("incomplete_details.reason").String() == "content_filter" {
+		return true
+	}
+	refused := false
+	root.Get("choices").ForEach(func(_, choice gjson.Result) bool {
+		refused = choice.Get("message.refusal").String() != "" || choice.Get("finish_reason").String() == "content_filter"
+		return !refused
+	})
+	root.Get("candidates").ForEach(func(_, candidate gjson.Result) bool {
+		refused = refused || geminiRefusalReasons[candidate.Get("finishReason").String()]
+		return !refused
+	})
+	root.Get("output").ForEach(func(_, item gjson.Result) bool {
+		item.Get("content").ForEach(func(_, part gjson.Result) bool {
+			refused = refused || part.Get("type").String() == "refusal"
+			return !refused
+		})
+		return !refused
+	})
+	if refused || len(patterns) == 0 {
+		return refused
+	}
+	var text strings.Builder
+	collectUsageText(root, usageOutputKeys, &text)
+	head := strings.ToLower(strings.TrimSpace(text.String()))
+	if len(head) > refusalPatternPrefix {
+		head = head[:refusalPatternPrefix]
+	}
+	for _, pattern := range patterns {
+		if strings.Contains(head, pattern) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	coreexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	sdkconfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+)
+
+// refusalExecutor refuses every request for "strict-model" and answers the others.
+type refusalExecutor struct {
+	fitExecutor
+}
+
+func (e *refusalExecutor) Execute(_ context.Context, _ *coreauth.Auth, req coreexecutor.Request, _ coreexecutor.Options) (coreexecutor.Response, error) {
+	if req.Model == "strict-model" {
+		return coreexecutor.Response{Payload: []byte(`{"type":"message","stop_reason":"refusal","content":[]}`)}, nil
+	}
+	return coreexecutor.Response{Payload: []byte(`{"type":"message","stop_reason":"end_turn","content":[{"type":"text","text":"` + req.Model + `"}]}`)}, nil
+}
+
+func TestRefusalRetriedOnFallback(t *testing.T) {
+	manager := coreauth.NewManager(nil, nil, nil)
+	manager.RegisterExecutor(&refusalExecutor{})
+	auth := &coreauth.Auth{ID: "refusal-auth", Provider: "claude", Status: coreauth.StatusActive}
+	if _, err := manager.Register(context.Background(), auth); err != nil {
+		t.Fatalf("manager.Register: %v", err)
+	}
+	registry.GetGlobalRegistry().RegisterClient(auth.ID, auth.Provider, []*registry.ModelInfo{{ID: "strict-model"}, {ID: "lenient-model"}})
+	t.Cleanup(func() { registry.GetGlobalRegistry().UnregisterClient(auth.ID) })
+
+	cfg := &sdkconfig.Config{SDKConfig: sdkconfig.SDKConfig{RefusalFallback: sdkconfig.RefusalFallbackConfig{
+		Rules: []sdkconfig.RefusalFallbackRule{{Models: []string{"strict-*"}, Fallback: " lenient-model "}},
+	}}}
+	cfg.SanitizeRefusalFallback()
+	handler := NewBaseAPIHandlers(&cfg.SDKConfig, manager)
+
+	recorder := httptest.NewRecorder()
+	ginCtx, _ := gin.CreateTestContext(recorder)
+	ctx := context.WithValue(context.Background(), "gin", ginCtx)
+	resp, errMsg := handler.ExecuteWithAuthManager(ctx, "claude", "strict-model", []byte(`{"model":"strict-model"}`), "")
+	if errMsg != nil {
+		t.Fatalf("unexpected error: %+v", errMsg)
+	}
+	if isRefusal(resp, nil) || recorder.Header().Get(RefusalFallbackHeader) != "lenient-model" {
+		t.Fatalf("response %s with fallback header %q, want the lenient-model answer", resp, recorder.Header().Get(RefusalFallbackHeader))
+	}
+}
+
+func TestIsRefusal(t *testing.T) {
+	patterns := []string{"i can't help with"}
+	cases := []struct {
+		resp string
+		want bool
+	}{
+		{`{"choices":[{"message":{"content":null,"refusal":"No."},"finish_reason":"stop"}]}`, true},
+		{`{"choices":[{"message":{"content":"ok"},"finish_reason":"content_filter"}]}`, true},
+		{`{"candidates":[{"finishReason":"SAFETY"}]}`, true},
+		{`{"response":{"promptFeedback":{"blockReason":"OTHER"}}}`, true},
+		{`{"output":[{"type":"message","content":[{"type":"refusal","refusal":"No."}]}]}`, true},
+		{`{"content":[{"type":"text","text":"Sorry, I can't help with that."}]}`, true},
+		{`{"choices":[{"message":{"content":"Here it is."},"finish_reason":"stop"}]}`, false},
+	}
+	for _, tc := range cases {
+		if got := isRefusal([]byte(tc.resp), patterns); got != tc.want {
+			t.Errorf("isRefusal(%s) = %v, want %v", tc.resp, got, tc.want)
+		}
+	}
+}
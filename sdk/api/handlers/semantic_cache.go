@@ -0,0 +1,291 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/featureflag"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/offline"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	log "github.com/sirupsen/logrus"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// SemanticCacheHeader reports whether a response was served from the semantic cache, and
+// for hits the similarity of the cached prompt.
+const SemanticCacheHeader = "X-CLIProxy-Semantic-Cache"
+
+// semanticPromptBytes caps the prompt text sent to the embeddings endpoint. Longer prompts
+// bypass the cache: embedding only part of them would let prompts that differ in the rest
+// share a response.
+const semanticPromptBytes = 24000
+
+const semanticEmbedTimeout = 10 * time.Second
+
+// semanticPromptPaths are removed from requests before fingerprinting their parameters,
+// so requests differing only in prompt text (or per-session metadata) share a scope.
+var semanticPromptPaths = []string{
+	"messages", "contents", "request.contents", "input", "system", "instructions", "prompt",
+	"systemInstruction", "request.systemInstruction", "metadata", "user",
+}
+
+type semanticEntry struct {
+	scope   string
+	vector  []float64
+	payload []byte
+	expires time.Time
+}
+
+// semanticCache keeps successful non-streaming responses with the embedding of their
+// prompt. Entries are kept oldest first.
+type semanticCache struct {
+	mu      sync.Mutex
+	entries []*semanticEntry
+	now     func() time.Time
+	// embed returns the normalized embedding of text.
+	embed func(ctx context.Context, settings config.SemanticCacheConfig, proxyURL, text string) ([]float64, error)
+
+	clientMu    sync.Mutex
+	client      *http.Client
+	clientProxy string
+}
+
+func newSemanticCache() *semanticCache {
+	c := &semanticCache{now: time.Now}
+	c.embed = c.fetchEmbedding
+	return c
+}
+
+// executeSemanticCached serves rawJSON from the semantic cache when a similar prompt with
+// the same parameters was answered recently, and caches the response of execute otherwise.
+// The request waits at most lookup-timeout-ms for the prompt embedding; when it is late or
+// fails the request goes upstream without a lookup, and the response is cached in the
+// background once the embedding is known.
+func (h *BaseAPIHandler) executeSemanticCached(ctx context.Context, handlerType, modelName string, rawJSON []byte, alt string, execute func() ([]byte, *interfaces.ErrorMessage)) ([]byte, *interfaces.ErrorMessage) {
+	if h.semantic == nil || h.Cfg == nil || !h.Cfg.SemanticCache.Enabled() || !featureflag.Enabled(featureflag.SemanticCache) ||
+		!semanticCacheModel(h.Cfg.SemanticCache.Models, modelName) {
+		return execute()
+	}
+	settings := h.Cfg.SemanticCache
+	prompt := semanticPromptText(rawJSON)
+	if prompt == "" || len(prompt) > semanticPromptBytes {
+		return execute()
+	}
+	ginCtx, _ := ctx.Value("gin").(*gin.Context)
+	scope := semanticScope(ginCtx, settings.Shared, handlerType, modelName, alt, rawJSON)
+
+	// The embedding outlives the request, so a late one can still cache the response.
+	vectors := make(chan []float64, 1)
+	proxyURL := h.Cfg.ProxyURL
+	go func() {
+		vector, err := h.semantic.embed(context.WithoutCancel(ctx), settings, proxyURL, prompt)
+		if err != nil {
+			log.Debugf("semantic cache: embedding failed, bypassing cache: %v", err)
+		}
+		vectors <- vector
+	}()
+
+	var vector []float64
+	waited := false
+	wait := time.NewTimer(time.Duration(settings.LookupTimeoutMS) * time.Millisecond)
+	select {
+	case vector = <-vectors:
+		waited = true
+		wait.Stop()
+	case <-wait.C:
+	}
+	if vector != nil {
+		if payload, similarity, ok := h.semantic.lookup(scope, vector, settings.Threshold); ok {
+			if ginCtx != nil {
+				ginCtx.Header(SemanticCacheHeader, fmt.Sprintf("hit; similarity=%.4f", similarity))
+			}
+			return payload, nil
+		}
+	}
+	resp, errMsg := execute()
+	if errMsg != nil {
+		return resp, errMsg
+	}
+	ttl := time.Duration(settings.TTLSeconds) * time.Second
+	if waited {
+		if vector != nil {
+			h.semantic.store(scope, vector, resp, ttl, settings.MaxEntries)
+		}
+	} else {
+		go func(resp []byte) {
+			if late := <-vectors; late != nil {
+				h.semantic.store(scope, late, resp, ttl, settings.MaxEntries)
+			}
+		}(bytes.Clone(resp))
+	}
+	if ginCtx != nil && (vector != nil || !waited) {
+		ginCtx.Header(SemanticCacheHeader, "miss")
+	}
+	return resp, nil
+}
+
+// lookup returns the payload of the live entry of scope most similar to vector, when its
+// similarity reaches threshold.
+func (sc *semanticCache) lookup(scope string, vector []float64, threshold float64) ([]byte, float64, bool) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	now := sc.now()
+	var best *semanticEntry
+	bestScore := -1.0
+	for _, entry := range sc.entries {
+		if entry.scope != scope || !now.Before(entry.expires) || len(entry.vector) != len(vector) {
+			continue
+		}
+		if score := dotProduct(entry.vector, vector); score > bestScore {
+			best, bestScore = entry, score
+		}
+	}
+	if best == nil || bestScore < threshold {
+		return nil, 0, false
+	}
+	return bytes.Clone(best.payload), bestScore, true
+}
+
+// store caches payload, evicting expired entries and then the oldest beyond maxEntries.
+func (sc *semanticCache) store(scope string, vector []float64, payload []byte, ttl time.Duration, maxEntries int) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	now := sc.now()
+	live := sc.entries[:0]
+	for _, entry := range sc.entries {
+		if now.Before(entry.expires) {
+			live = append(live, entry)
+		}
+	}
+	if maxEntries > 0 && len(live) >= maxEntries {
+		live = live[len(live)-maxEntries+1:]
+	}
+	sc.entries = append(live, &semanticEntry{scope: scope, vector: vector, payload: bytes.Clone(payload), expires: now.Add(ttl)})
+}
+
+// fetchEmbedding asks the OpenAI-compatible embeddings endpoint for the embedding of text.
+func (sc *semanticCache) fetchEmbedding(ctx context.Context, settings config.SemanticCacheConfig, proxyURL, text string) ([]float64, error) {
+	body, _ := sjson.SetBytes([]byte(`{}`), "model", settings.EmbeddingModel)
+	body, _ = sjson.SetBytes(body, "input", text)
+	ctx, cancel := context.WithTimeout(ctx, semanticEmbedTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, settings.EmbeddingURL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if settings.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+settings.APIKey)
+	}
+	resp, err := sc.httpClient(proxyURL).Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if errClose := resp.Body.Close(); errClose != nil {
+			log.Debugf("semantic cache: close embeddings response: %v", errClose)
+		}
+	}()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("embeddings endpoint returned status %d", resp.StatusCode)
+	}
+	values := gjson.GetBytes(data, "data.0.embedding").Array()
+	if len(values) == 0 {
+		return nil, fmt.Errorf("embeddings response has no embedding")
+	}
+	vector := make([]float64, len(values))
+	var norm float64
+	for i, value := range values {
+		vector[i] = value.Float()
+		norm += vector[i] * vector[i]
+	}
+	if norm == 0 {
+		return nil, fmt.Errorf("embedding is a zero vector")
+	}
+	norm = math.Sqrt(norm)
+	for i := range vector {
+		vector[i] /= norm
+	}
+	return vector, nil
+}
+
+func (sc *semanticCache) httpClient(proxyURL string) *http.Client {
+	sc.clientMu.Lock()
+	defer sc.clientMu.Unlock()
+	if sc.client == nil || sc.clientProxy != proxyURL {
+		sc.client = offline.Default().Client(util.SetProxy(&config.SDKConfig{ProxyURL: proxyURL}, &http.Client{Timeout: semanticEmbedTimeout}))
+		sc.clientProxy = proxyURL
+	}
+	return sc.client
+}
+
+// semanticPromptText returns the prompt text of a request in any dialect.
+func semanticPromptText(rawJSON []byte) string {
+	var b strings.Builder
+	collectUsageText(gjson.ParseBytes(rawJSON), usagePromptKeys, &b)
+	return strings.TrimSpace(b.String())
+}
+
+// semanticScope identifies the requests that may share cached responses: same endpoint,
+// model and parameters, and unless shared, the same client API key.
+func semanticScope(ginCtx *gin.Context, shared bool, handlerType, modelName, alt string, rawJSON []byte) string {
+	params := rawJSON
+	for _, path := range semanticPromptPaths {
+		if updated, err := sjson.DeleteBytes(params, path); err == nil {
+			params = updated
+		}
+	}
+	h := sha256.New()
+	if !shared && ginCtx != nil {
+		if value, exists := ginCtx.Get("apiKey"); exists {
+			if s, ok := value.(string); ok {
+				h.Write([]byte(s))
+			}
+		}
+	}
+	for _, part := range []string{handlerType, modelName, alt} {
+		h.Write([]byte{0})
+		h.Write([]byte(part))
+	}
+	h.Write([]byte{0})
+	h.Write(params)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// semanticCacheModel reports whether model matches one of patterns; no patterns match all.
+func semanticCacheModel(patterns []string, model string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	for _, pattern := range patterns {
+		if util.MatchWildcard(pattern, model) {
+			return true
+		}
+	}
+	return false
+}
+
+func dotProduct(a, b []float64) float64 {
+	var sum float64
+	for i := range a {
+		sum += a[i] * b[i]
+	}
+	return sum
+}
@@ -0,0 +1,161 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	sdkconfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	"github.com/tidwall/sjson"
+)
+
+// slowEmbeddings releases the embeddings of prompts mentioning "slow".
+var slowEmbeddings = make(chan struct{})
+
+func newSemanticTestHandler(t *testing.T) *BaseAPIHandler {
+	t.Helper()
+	h := NewBaseAPIHandlers(&sdkconfig.SDKConfig{SemanticCache: sdkconfig.SemanticCacheConfig{
+		EmbeddingURL:   "http://embeddings.invalid/v1/embeddings",
+		EmbeddingModel: "embed",
+		Threshold:      0.9,
+		TTLSeconds:     60,
+		MaxEntries:     10,
+		Models:         []string{"gpt-*"},
+		// Generous, so the lookups of these tests never time out.
+		LookupTimeoutMS: 5000,
+	}}, nil)
+	// Prompts mentioning "weather" point one way, everything else the other.
+	h.semantic.embed = func(_ context.Context, _ sdkconfig.SemanticCacheConfig, _ string, text string) ([]float64, error) {
+		switch {
+		case strings.Contains(text, "fail"):
+			return nil, errors.New("embedding unavailable")
+		case strings.Contains(text, "slow"):
+			<-slowEmbeddings
+			return []float64{1, 0}, nil
+		case strings.Contains(text, "weather"):
+			return []float64{0.96, 0.28}, nil
+		default:
+			return []float64{0, 1}, nil
+		}
+	}
+	return h
+}
+
+func semanticTestContext(apiKey string) (context.Context, *gin.Context) {
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+	c.Set("apiKey", apiKey)
+	return context.WithValue(context.Background(), "gin", c), c
+}
+
+func TestSemanticCacheServesSimilarPrompts(t *testing.T) {
+	h := newSemanticTestHandler(t)
+	calls := 0
+	execute := func() ([]byte, *interfaces.ErrorMessage) {
+		calls++
+		return []byte(`{"answer":"sunny"}`), nil
+	}
+	first := []byte(`{"model":"gpt-5","temperature":0.2,"messages":[{"role":"user","content":"what is the weather in Paris"}]}`)
+	similar := []byte(`{"model":"gpt-5","temperature":0.2,"messages":[{"role":"user","content":"what's the weather in Paris?"}]}`)
+
+	ctx, c := semanticTestContext("key-a")
+	if _, errMsg := h.executeSemanticCached(ctx, "openai", "gpt-5", first, "", execute); errMsg != nil {
+		t.Fatal(errMsg.Error)
+	}
+	if got := c.Writer.Header().Get(SemanticCacheHeader); got != "miss" {
+		t.Fatalf("%s = %q, want miss", SemanticCacheHeader, got)
+	}
+
+	ctx, c = semanticTestContext("key-a")
+	resp, _ := h.executeSemanticCached(ctx, "openai", "gpt-5", similar, "", execute)
+	if calls != 1 || string(resp) != `{"answer":"sunny"}` {
+		t.Fatalf("calls = %d, resp = %s", calls, resp)
+	}
+	if got := c.Writer.Header().Get(SemanticCacheHeader); !strings.HasPrefix(got, "hit; similarity=") {
+		t.Fatalf("%s = %q, want a hit", SemanticCacheHeader, got)
+	}
+
+	// Other keys, other parameters and dissimilar prompts miss.
+	ctx, _ = semanticTestContext("key-b")
+	h.executeSemanticCached(ctx, "openai", "gpt-5", similar, "", execute)
+	ctx, _ = semanticTestContext("key-a")
+	h.executeSemanticCached(ctx, "openai", "gpt-5", []byte(`{"model":"gpt-5","temperature":0.9,"messages":[{"role":"user","content":"weather in Paris"}]}`), "", execute)
+	h.executeSemanticCached(ctx, "openai", "gpt-5", []byte(`{"model":"gpt-5","temperature":0.2,"messages":[{"role":"user","content":"write a poem"}]}`), "", execute)
+	if calls != 4 {
+		t.Fatalf("calls = %d, want 4", calls)
+	}
+}
+
+func TestSemanticCacheBypasses(t *testing.T) {
+	h := newSemanticTestHandler(t)
+	calls := 0
+	execute := func() ([]byte, *interfaces.ErrorMessage) {
+		calls++
+		return []byte(`{}`), nil
+	}
+	ctx, _ := semanticTestContext("key-a")
+	for i := 0; i < 2; i++ {
+		h.executeSemanticCached(ctx, "claude", "claude-sonnet", []byte(`{"messages":[{"role":"user","content":"weather"}]}`), "", execute)
+		h.executeSemanticCached(ctx, "openai", "gpt-5", []byte(`{"messages":[{"role":"user","content":"fail weather"}]}`), "", execute)
+	}
+	if calls != 4 {
+		t.Fatalf("calls = %d, want 4 for unmatched models and failed embeddings", calls)
+	}
+}
+
+func TestSemanticCacheDoesNotWaitForSlowEmbeddings(t *testing.T) {
+	h := newSemanticTestHandler(t)
+	h.Cfg.SemanticCache.LookupTimeoutMS = 20
+	calls := 0
+	execute := func() ([]byte, *interfaces.ErrorMessage) {
+		calls++
+		return []byte(`{"answer":"late"}`), nil
+	}
+	body := []byte(`{"model":"gpt-5","messages":[{"role":"user","content":"a slow prompt"}]}`)
+	ctx, _ := semanticTestContext("key-a")
+	start := time.Now()
+	if resp, _ := h.executeSemanticCached(ctx, "openai", "gpt-5", body, "", execute); string(resp) != `{"answer":"late"}` || time.Since(start) > time.Second {
+		t.Fatalf("resp = %s after %v", resp, time.Since(start))
+	}
+
+	// The late embedding still caches the response.
+	slowEmbeddings <- struct{}{}
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		h.semantic.mu.Lock()
+		stored := len(h.semantic.entries)
+		h.semantic.mu.Unlock()
+		if stored == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("response was not cached after the embedding arrived")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if calls != 1 {
+		t.Fatalf("calls = %d, want 1", calls)
+	}
+}
+
+func TestSemanticCacheBypassesLongPrompts(t *testing.T) {
+	h := newSemanticTestHandler(t)
+	embedded := false
+	h.semantic.embed = func(context.Context, sdkconfig.SemanticCacheConfig, string, string) ([]float64, error) {
+		embedded = true
+		return []float64{1, 0}, nil
+	}
+	body, _ := sjson.SetBytes([]byte(`{"model":"gpt-5"}`), "messages.0.content", strings.Repeat("weather ", semanticPromptBytes))
+	ctx, _ := semanticTestContext("key-a")
+	h.executeSemanticCached(ctx, "openai", "gpt-5", body, "", func() ([]byte, *interfaces.ErrorMessage) { return []byte(`{}`), nil })
+	if embedded {
+		t.Fatal("a prompt over the embedding limit was embedded")
+	}
+}
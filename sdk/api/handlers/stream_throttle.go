@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	log "github.com/sirupsen/logrus"
+	"github.com/tidwall/gjson"
+)
+
+// throttleStream paces the chunks of data to the stream throttle rate of the request's API
+// key or priority class. Chunks are delayed, never dropped or split; a chunk larger than the
+// burst is sent once the bucket has caught up with it.
+func (h *BaseAPIHandler) throttleStream(ctx context.Context, data <-chan []byte) <-chan []byte {
+	if h.Cfg == nil || data == nil {
+		return data
+	}
+	apiKey, priority := requestPriority(ctx, h.Cfg)
+	rate := h.Cfg.StreamThrottle.RateFor(apiKey, priority)
+	if !rate.Limited() {
+		return data
+	}
+	burst := h.Cfg.StreamThrottle.BurstSeconds
+	if burst <= 0 {
+		burst = config.DefaultStreamThrottleBurstSeconds
+	}
+	now := time.Now()
+	bytesBucket := newRateBucket(rate.BytesPerSecond, burst, now)
+	tokensBucket := newRateBucket(rate.TokensPerSecond, burst, now)
+
+	out := make(chan []byte)
+	go func() {
+		defer close(out)
+		var throttled time.Duration
+		for chunk := range data {
+			now = time.Now()
+			wait := bytesBucket.reserve(float64(len(chunk)), now)
+			if tokensBucket != nil {
+				wait = max(wait, tokensBucket.reserve(float64(chunkTokens(chunk)), now))
+			}
+			if wait > 0 {
+				throttled += wait
+				timer := time.NewTimer(wait)
+				select {
+				case <-timer.C:
+				case <-ctx.Done():
+					timer.Stop()
+					for range data {
+					}
+					return
+				}
+			}
+			select {
+			case out <- chunk:
+			case <-ctx.Done():
+				for range data {
+				}
+				return
+			}
+		}
+		if throttled > 0 {
+			log.Debugf("stream throttle: delayed stream by %s (priority %s)", throttled.Round(time.Millisecond), priority)
+		}
+	}()
+	return out
+}
+
+// chunkTokens estimates the generated tokens carried by a stream chunk.
+func chunkTokens(chunk []byte) int64 {
+	var text strings.Builder
+	for _, bounds := range chunkJSONDocs(chunk) {
+		collectUsageText(gjson.ParseBytes(chunk[bounds[0]:bounds[1]]), usageOutputKeys, &text)
+	}
+	return util.CountTokens(strings.TrimSuffix(text.String(), "\n"))
+}
+
+// rateBucket is a token bucket refilled at rate units per second up to capacity. Reserving
+// more than is available drives the level negative; the caller waits until it is repaid.
+type rateBucket struct {
+	rate     float64
+	capacity float64
+	level    float64
+	last     time.Time
+}
+
+// newRateBucket returns a full bucket, or nil when rate is not positive.
+func newRateBucket(rate, burstSeconds int, now time.Time) *rateBucket {
+	if rate <= 0 {
+		return nil
+	}
+	capacity := float64(rate * burstSeconds)
+	return &rateBucket{rate: float64(rate), capacity: capacity, level: capacity, last: now}
+}
+
+// reserve takes n units and returns how long to wait before using them.
+func (b *rateBucket) reserve(n float64, now time.Time) time.Duration {
+	if b == nil || n <= 0 {
+		return 0
+	}
+	if elapsed := now.Sub(b.last); elapsed > 0 {
+		b.level = min(b.capacity, b.level+elapsed.Seconds()*b.rate)
+		b.last = now
+	}
+	b.level -= n
+	if b.level >= 0 {
+		return 0
+	}
+	return time.Duration(-b.level / b.rate * float64(time.Second))
+}
@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	sdkconfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+)
+
+func TestRateBucketPacesPastBurst(t *testing.T) {
+	start := time.Unix(0, 0)
+	b := newRateBucket(100, 1, start)
+	if wait := b.reserve(100, start); wait != 0 {
+		t.Fatalf("burst wait = %s, want 0", wait)
+	}
+	if wait := b.reserve(50, start); wait != 500*time.Millisecond {
+		t.Fatalf("wait = %s, want 500ms", wait)
+	}
+	// After repaying the debt the bucket refills up to its capacity only.
+	if wait := b.reserve(100, start.Add(10*time.Second)); wait != 0 {
+		t.Fatalf("wait after idle = %s, want 0", wait)
+	}
+	if wait := b.reserve(10, start.Add(10*time.Second)); wait != 100*time.Millisecond {
+		t.Fatalf("wait = %s, want 100ms", wait)
+	}
+}
+
+func TestStreamThrottleRateFor(t *testing.T) {
+	cfg := &config.Config{SDKConfig: config.SDKConfig{StreamThrottle: config.StreamThrottleConfig{
+		StreamRate: config.StreamRate{BytesPerSecond: 1000},
+		Priorities: map[string]config.StreamRate{"batch": {TokensPerSecond: 20}},
+		APIKeys:    map[string]config.StreamRate{" editor ": {}},
+	}}}
+	cfg.SanitizeStreamThrottle()
+	throttle := cfg.StreamThrottle
+	if got := throttle.RateFor("editor", config.PriorityLow); got.Limited() {
+		t.Fatalf("exempt key rate = %+v", got)
+	}
+	if got := throttle.RateFor("agent", config.PriorityLow); got.TokensPerSecond != 20 || got.BytesPerSecond != 0 {
+		t.Fatalf("low priority rate = %+v", got)
+	}
+	if got := throttle.RateFor("agent", config.PriorityNormal); got.BytesPerSecond != 1000 {
+		t.Fatalf("default rate = %+v", got)
+	}
+}
+
+func TestThrottleStreamDelaysChunks(t *testing.T) {
+	h := NewBaseAPIHandlers(&sdkconfig.SDKConfig{StreamThrottle: sdkconfig.StreamThrottleConfig{
+		APIKeys:      map[string]sdkconfig.StreamRate{"agent": {BytesPerSecond: 1000}},
+		BurstSeconds: 1,
+	}}, nil)
+	chunks := [][]byte{bytes.Repeat([]byte("a"), 1000), bytes.Repeat([]byte("b"), 200)}
+
+	run := func(apiKey string) time.Duration {
+		data := make(chan []byte, len(chunks))
+		for _, chunk := range chunks {
+			data <- chunk
+		}
+		close(data)
+		ctx, _ := semanticTestContext(apiKey)
+		start := time.Now()
+		var got int
+		for chunk := range h.throttleStream(ctx, data) {
+			got += len(chunk)
+		}
+		if got != 1200 {
+			t.Fatalf("received %d bytes, want 1200", got)
+		}
+		return time.Since(start)
+	}
+
+	if elapsed := run("agent"); elapsed < 150*time.Millisecond {
+		t.Fatalf("throttled stream took %s, want about 200ms", elapsed)
+	}
+	if elapsed := run("editor"); elapsed > 100*time.Millisecond {
+		t.Fatalf("unthrottled stream took %s", elapsed)
+	}
+}
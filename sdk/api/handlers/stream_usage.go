@@ -0,0 +1,317 @@
+package handlers
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/constant"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+	log "github.com/sirupsen/logrus"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// usagePromptKeys are the request keys whose string values are counted as prompt text.
+var usagePromptKeys = map[string]bool{
+	"content": true, "text": true, "system": true, "instructions": true, "input": true,
+	"prompt": true, "description": true, "arguments": true, "output": true,
+}
+
+// usageOutputKeys are the stream chunk keys whose string values are counted as output text.
+var usageOutputKeys = map[string]bool{
+	"content": true, "text": true, "delta": true, "thinking": true, "reasoning_content": true,
+	"arguments": true, "partial_json": true,
+}
+
+// streamUsageContext returns a context collecting the token-less usage records of a stream
+// when usage injection is enabled, and the collector or nil.
+func (h *BaseAPIHandler) streamUsageContext(ctx context.Context) (context.Context, *usage.StreamCollector) {
+	if h.Cfg == nil || !h.Cfg.Streaming.InjectUsage {
+		return ctx, nil
+	}
+	return usage.WithStreamCollector(ctx)
+}
+
+// injectStreamUsage watches a stream for the usage of the client's format. When the stream
+// ends without any, the usage reported to the accounting subsystem, or else an estimate
+// from the request and the streamed text, is added to the stream: as a trailing chunk for
+// OpenAI chat, and in the final message_delta, response.completed or last chunk for Claude,
+// Responses and Gemini. Held usage records are published with the same numbers.
+func injectStreamUsage(ctx context.Context, collector *usage.StreamCollector, handlerType string, rawJSON []byte, data <-chan []byte) <-chan []byte {
+	if collector == nil {
+		return data
+	}
+	if data == nil {
+		collector.Finish(usage.Detail{})
+		return data
+	}
+	out := make(chan []byte)
+	go func() {
+		defer close(out)
+		send := func(chunks [][]byte) bool {
+			for _, chunk := range chunks {
+				select {
+				case out <- chunk:
+				case <-ctx.Done():
+					return false
+				}
+			}
+			return true
+		}
+		s := &usageStream{format: handlerType}
+		for chunk := range data {
+			if !send(s.push(chunk)) {
+				for range data {
+				}
+				collector.Finish(usage.Detail{})
+				return
+			}
+		}
+		reported, hasReported := collector.Reported()
+		if !hasReported && !collector.Pending() {
+			// The stream failed or published nothing; there is nothing to complete.
+			collector.Finish(usage.Detail{})
+			send(s.held)
+			return
+		}
+		detail := s.detail
+		if !s.hasUsage {
+			detail = reported
+			if !hasReported {
+				detail = estimateStreamUsage(rawJSON, s.output.String())
+			}
+			log.Debugf("stream usage: injecting %d input and %d output tokens", detail.InputTokens, detail.OutputTokens)
+		}
+		collector.Finish(detail)
+		send(s.finish(detail))
+	}()
+	return out
+}
+
+// usageStream tracks the usage and output text of a stream in one client format. Chunks
+// from the one that can carry the final usage onward are held until the stream ends.
+type usageStream struct {
+	format   string
+	held     [][]byte
+	hasUsage bool
+	detail   usage.Detail
+	output   strings.Builder
+	// last is the most recent OpenAI chunk, whose id and model the injected chunk reuses.
+	last []byte
+}
+
+func (s *usageStream) push(chunk []byte) [][]byte {
+	anchor := false
+	for _, bounds := range chunkJSONDocs(chunk) {
+		doc := gjson.ParseBytes(chunk[bounds[0]:bounds[1]])
+		s.observe(doc)
+		if s.isAnchor(doc) {
+			anchor = true
+		}
+		if s.format == constant.OpenAI {
+			s.last = chunk[bounds[0]:bounds[1]]
+		}
+	}
+	if anchor {
+		ready := s.held
+		s.held = [][]byte{chunk}
+		return ready
+	}
+	if len(s.held) > 0 {
+		s.held = append(s.held, chunk)
+		return nil
+	}
+	return [][]byte{chunk}
+}
+
+// isAnchor reports whether doc is where the final usage of the format is written.
+func (s *usageStream) isAnchor(doc gjson.Result) bool {
+	switch s.format {
+	case constant.Claude:
+		return doc.Get("type").String() == "message_delta"
+	case constant.OpenaiResponse:
+		return doc.Get("type").String() == "response.completed"
+	case constant.Gemini, constant.GeminiCLI:
+		return true
+	}
+	return false
+}
+
+func (s *usageStream) observe(doc gjson.Result) {
+	var detail usage.Detail
+	textual := true
+	switch s.format {
+	case constant.OpenAI:
+		u := doc.Get("usage")
+		detail = usage.Detail{
+			InputTokens:     u.Get("prompt_tokens").Int(),
+			OutputTokens:    u.Get("completion_tokens").Int(),
+			ReasoningTokens: u.Get("completion_tokens_details.reasoning_tokens").Int(),
+			CachedTokens:    u.Get("prompt_tokens_details.cached_tokens").Int(),
+			TotalTokens:     u.Get("total_tokens").Int(),
+		}
+	case constant.Claude:
+		kind := doc.Get("type").String()
+		textual = kind == "content_block_delta"
+		u := doc.Get("usage")
+		if kind == "message_start" {
+			u = doc.Get("message.usage")
+		}
+		detail = s.detail
+		if v := u.Get("input_tokens").Int(); v > 0 {
+			detail.InputTokens = v
+		}
+		if v := u.Get("cache_read_input_tokens").Int(); v > 0 {
+			detail.CachedTokens = v
+		}
+		if v := u.Get("output_tokens").Int(); v > 0 {
+			detail.OutputTokens = v
+		}
+		detail.TotalTokens = detail.InputTokens + detail.OutputTokens
+	case constant.OpenaiResponse:
+		textual = strings.HasSuffix(doc.Get("type").String(), ".delta")
+		u := doc.Get("response.usage")
+		detail = usage.Detail{
+			InputTokens:     u.Get("input_tokens").Int(),
+			OutputTokens:    u.Get("output_tokens").Int(),
+			ReasoningTokens: u.Get("output_tokens_details.reasoning_tokens").Int(),
+			CachedTokens:    u.Get("input_tokens_details.cached_tokens").Int(),
+			TotalTokens:     u.Get("total_tokens").Int(),
+		}
+	case constant.Gemini, constant.GeminiCLI:
+		u := doc.Get("usageMetadata")
+		if !u.Exists() {
+			u = doc.Get("response.usageMetadata")
+		}
+		detail = usage.Detail{
+			InputTokens:     u.Get("promptTokenCount").Int(),
+			OutputTokens:    u.Get("candidatesTokenCount").Int(),
+			ReasoningTokens: u.Get("thoughtsTokenCount").Int(),
+			CachedTokens:    u.Get("cachedContentTokenCount").Int(),
+			TotalTokens:     u.Get("totalTokenCount").Int(),
+		}
+	}
+	if detail.OutputTokens > 0 || detail.TotalTokens > 0 {
+		s.hasUsage = true
+		s.detail = detail
+	}
+	if textual {
+		collectUsageText(doc, usageOutputKeys, &s.output)
+	}
+}
+
+// finish writes detail into the held chunks, or after them for OpenAI chat, unless the
+// stream carried usage of its own.
+func (s *usageStream) finish(detail usage.Detail) [][]byte {
+	ready := s.held
+	if s.hasUsage {
+		return ready
+	}
+	switch s.format {
+	case constant.OpenAI:
+		return append(ready, openAIUsageChunk(s.last, detail))
+	case constant.Claude, constant.OpenaiResponse, constant.Gemini, constant.GeminiCLI:
+		if len(ready) > 0 {
+			ready[0] = s.patch(ready[0], detail)
+		}
+	}
+	return ready
+}
+
+// patch sets detail on the anchor documents of chunk.
+func (s *usageStream) patch(chunk []byte, detail usage.Detail) []byte {
+	bounds := chunkJSONDocs(chunk)
+	var out []byte
+	last := 0
+	for _, b := range bounds {
+		payload := chunk[b[0]:b[1]]
+		if s.isAnchor(gjson.ParseBytes(payload)) {
+			payload = s.patchDoc(payload, detail)
+		}
+		out = append(out, chunk[last:b[0]]...)
+		out = append(out, payload...)
+		last = b[1]
+	}
+	return append(out, chunk[last:]...)
+}
+
+func (s *usageStream) patchDoc(doc []byte, detail usage.Detail) []byte {
+	set := func(path string, value int64) {
+		if updated, err := sjson.SetBytes(doc, path, value); err == nil {
+			doc = updated
+		}
+	}
+	switch s.format {
+	case constant.Claude:
+		set("usage.input_tokens", detail.InputTokens)
+		set("usage.output_tokens", detail.OutputTokens)
+	case constant.OpenaiResponse:
+		set("response.usage.input_tokens", detail.InputTokens)
+		set("response.usage.output_tokens", detail.OutputTokens)
+		set("response.usage.total_tokens", detail.TotalTokens)
+	case constant.Gemini, constant.GeminiCLI:
+		prefix := "usageMetadata."
+		if gjson.GetBytes(doc, "response").IsObject() {
+			prefix = "response.usageMetadata."
+		}
+		set(prefix+"promptTokenCount", detail.InputTokens)
+		set(prefix+"candidatesTokenCount", detail.OutputTokens)
+		set(prefix+"totalTokenCount", detail.TotalTokens)
+	}
+	return doc
+}
+
+// openAIUsageChunk builds the usage-only chunk OpenAI sends last when include_usage is set.
+func openAIUsageChunk(last []byte, detail usage.Detail) []byte {
+	chunk := []byte(`{"id":"","object":"chat.completion.chunk","created":0,"model":"","choices":[]}`)
+	chunk, _ = sjson.SetBytes(chunk, "id", gjson.GetBytes(last, "id").String())
+	created := gjson.GetBytes(last, "created").Int()
+	if created == 0 {
+		created = time.Now().Unix()
+	}
+	chunk, _ = sjson.SetBytes(chunk, "created", created)
+	chunk, _ = sjson.SetBytes(chunk, "model", gjson.GetBytes(last, "model").String())
+	chunk, _ = sjson.SetBytes(chunk, "usage.prompt_tokens", detail.InputTokens)
+	chunk, _ = sjson.SetBytes(chunk, "usage.completion_tokens", detail.OutputTokens)
+	chunk, _ = sjson.SetBytes(chunk, "usage.total_tokens", detail.TotalTokens)
+	return chunk
+}
+
+// estimateStreamUsage counts the tokens of the request's text and the streamed output.
+func estimateStreamUsage(rawJSON []byte, output string) usage.Detail {
+	var prompt strings.Builder
+	collectUsageText(gjson.ParseBytes(rawJSON), usagePromptKeys, &prompt)
+	detail := usage.Detail{
+		InputTokens:  util.CountTokens(prompt.String()),
+		OutputTokens: util.CountTokens(output),
+	}
+	detail.TotalTokens = detail.InputTokens + detail.OutputTokens
+	return detail
+}
+
+// collectUsageText appends the string values under keys in node to b, including the
+// strings of arrays held by those keys.
+func collectUsageText(node gjson.Result, keys map[string]bool, b *strings.Builder) {
+	node.ForEach(func(key, value gjson.Result) bool {
+		counted := node.IsObject() && keys[key.String()]
+		switch {
+		case value.Type == gjson.String && counted:
+			b.WriteString(value.String())
+			b.WriteByte('\n')
+		case value.IsArray() && counted:
+			value.ForEach(func(_, item gjson.Result) bool {
+				if item.Type == gjson.String {
+					b.WriteString(item.String())
+					b.WriteByte('\n')
+				}
+				return true
+			})
+			collectUsageText(value, keys, b)
+		case value.IsObject() || value.IsArray():
+			collectUsageText(value, keys, b)
+		}
+		return true
+	})
+}
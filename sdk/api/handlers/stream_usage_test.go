@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+	"github.com/tidwall/gjson"
+)
+
+type recordingUsagePlugin struct {
+	records chan usage.Record
+}
+
+func (p *recordingUsagePlugin) HandleUsage(_ context.Context, record usage.Record) {
+	p.records <- record
+}
+
+var streamUsagePlugin = func() *recordingUsagePlugin {
+	plugin := &recordingUsagePlugin{records: make(chan usage.Record, 16)}
+	usage.RegisterPlugin(plugin)
+	return plugin
+}()
+
+// runUsageStream serves chunks through injectStreamUsage, publishing record from the
+// stream's context the way executors do before they close their channel.
+func runUsageStream(t *testing.T, format string, record usage.Record, chunks ...string) []string {
+	t.Helper()
+	ctx, collector := usage.WithStreamCollector(context.Background())
+	data := make(chan []byte)
+	go func() {
+		defer close(data)
+		for _, chunk := range chunks {
+			data <- []byte(chunk)
+		}
+		usage.PublishRecord(ctx, record)
+	}()
+	var out []string
+	for chunk := range injectStreamUsage(ctx, collector, format, []byte(`{"messages":[{"role":"user","content":"Say hello to the world"}]}`), data) {
+		out = append(out, string(chunk))
+	}
+	return out
+}
+
+func nextUsageRecord(t *testing.T, model string) usage.Record {
+	t.Helper()
+	timeout := time.After(2 * time.Second)
+	for {
+		select {
+		case record := <-streamUsagePlugin.records:
+			if record.Model == model {
+				return record
+			}
+		case <-timeout:
+			t.Fatalf("no usage record published for %s", model)
+		}
+	}
+}
+
+func TestInjectStreamUsageAppendsOpenAIChunk(t *testing.T) {
+	out := runUsageStream(t, "openai", usage.Record{Model: "usage-openai"},
+		`{"id":"c1","created":7,"model":"m","choices":[{"delta":{"content":"Hello there, world!"}}]}`,
+		`{"id":"c1","created":7,"model":"m","choices":[{"delta":{},"finish_reason":"stop"}]}`,
+	)
+	if len(out) != 3 {
+		t.Fatalf("chunks = %d, want 3: %v", len(out), out)
+	}
+	last := gjson.Parse(out[2])
+	if last.Get("id").String() != "c1" || len(last.Get("choices").Array()) != 0 {
+		t.Fatalf("usage chunk = %s", out[2])
+	}
+	input, output := last.Get("usage.prompt_tokens").Int(), last.Get("usage.completion_tokens").Int()
+	if input == 0 || output == 0 || last.Get("usage.total_tokens").Int() != input+output {
+		t.Fatalf("usage = %s", last.Get("usage").Raw)
+	}
+	record := nextUsageRecord(t, "usage-openai")
+	if record.Detail.InputTokens != input || record.Detail.OutputTokens != output {
+		t.Fatalf("record detail = %+v, chunk usage = %s", record.Detail, last.Get("usage").Raw)
+	}
+}
+
+func TestInjectStreamUsagePatchesClaudeMessageDelta(t *testing.T) {
+	out := runUsageStream(t, "claude", usage.Record{Model: "usage-claude", Detail: usage.Detail{InputTokens: 11, OutputTokens: 5, TotalTokens: 16}},
+		"event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"delta\":{\"type\":\"text_delta\",\"text\":\"Hi\"}}\n\n",
+		"event: message_delta\ndata: {\"type\":\"message_delta\",\"delta\":{\"stop_reason\":\"end_turn\"},\"usage\":{\"output_tokens\":0}}\n\n",
+		"event: message_stop\ndata: {\"type\":\"message_stop\"}\n\n",
+	)
+	if len(out) != 3 {
+		t.Fatalf("chunks = %d, want 3: %v", len(out), out)
+	}
+	docs := chunkJSONDocs([]byte(out[1]))
+	delta := gjson.Parse(out[1][docs[0][0]:docs[0][1]])
+	if delta.Get("usage.input_tokens").Int() != 11 || delta.Get("usage.output_tokens").Int() != 5 {
+		t.Fatalf("message_delta = %s", delta.Raw)
+	}
+	if delta.Get("delta.stop_reason").String() != "end_turn" {
+		t.Fatalf("message_delta lost its stop reason: %s", delta.Raw)
+	}
+}
+
+func TestInjectStreamUsageKeepsReportedResponsesUsage(t *testing.T) {
+	completed := "event: response.completed\ndata: {\"type\":\"response.completed\",\"response\":{\"usage\":{\"input_tokens\":3,\"output_tokens\":4,\"total_tokens\":7}}}"
+	out := runUsageStream(t, "openai-response", usage.Record{Model: "usage-responses"},
+		"event: response.output_text.delta\ndata: {\"type\":\"response.output_text.delta\",\"delta\":\"Hi\"}",
+		completed,
+	)
+	if len(out) != 2 || out[1] != completed {
+		t.Fatalf("chunks = %v", out)
+	}
+	record := nextUsageRecord(t, "usage-responses")
+	if record.Detail.InputTokens != 3 || record.Detail.OutputTokens != 4 || record.Detail.TotalTokens != 7 {
+		t.Fatalf("record detail = %+v", record.Detail)
+	}
+}
+
+func TestInjectStreamUsageFinishesEmptyStreams(t *testing.T) {
+	ctx, collector := usage.WithStreamCollector(context.Background())
+	data := make(chan []byte)
+	close(data)
+	for range injectStreamUsage(ctx, collector, "openai", []byte(`{}`), data) {
+	}
+	// A record published after the stream ended is no longer held back.
+	usage.PublishRecord(ctx, usage.Record{Model: "usage-after-empty-stream"})
+	nextUsageRecord(t, "usage-after-empty-stream")
+	if collector.Pending() {
+		t.Fatal("collector still holds records after the stream ended")
+	}
+}
@@ -0,0 +1,18 @@
+package handlers
+
+import (
+	"context"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/thinking"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+)
+
+// withThinkingRequest attaches the thinking setting of the request to ctx for the thinking
+// statistics of the usage plugins.
+func withThinkingRequest(ctx context.Context, handlerType, modelName string, rawJSON []byte) context.Context {
+	level, budget, ok := thinking.Requested(rawJSON, modelName, handlerType)
+	if !ok {
+		return ctx
+	}
+	return usage.WithThinkingRequest(ctx, usage.ThinkingRequest{Level: level, Budget: int64(budget)})
+}
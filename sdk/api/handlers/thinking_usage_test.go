@@ -0,0 +1,29 @@
+package handlers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+)
+
+func TestWithThinkingRequest(t *testing.T) {
+	cases := []struct {
+		handlerType, model, body string
+		want                     usage.ThinkingRequest
+		ok                       bool
+	}{
+		{"openai", "gpt-5.2(high)", `{"reasoning_effort":"low"}`, usage.ThinkingRequest{Level: "high", Budget: 24576}, true},
+		{"openai-response", "gpt-5.2", `{"reasoning":{"effort":"medium"}}`, usage.ThinkingRequest{Level: "medium", Budget: 8192}, true},
+		{"claude", "claude-sonnet-4-5", `{"thinking":{"type":"enabled","budget_tokens":2000}}`, usage.ThinkingRequest{Level: "medium", Budget: 2000}, true},
+		{"gemini", "gemini-2.5-pro(none)", `{}`, usage.ThinkingRequest{Level: "none"}, true},
+		{"claude", "claude-sonnet-4-5", `{"messages":[]}`, usage.ThinkingRequest{}, false},
+	}
+	for _, tc := range cases {
+		ctx := withThinkingRequest(context.Background(), tc.handlerType, tc.model, []byte(tc.body))
+		got, ok := usage.ThinkingRequestFromContext(ctx)
+		if ok != tc.ok || got != tc.want {
+			t.Errorf("%s %s: got %+v, %v; want %+v, %v", tc.handlerType, tc.model, got, ok, tc.want, tc.ok)
+		}
+	}
+}
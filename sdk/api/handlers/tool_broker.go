@@ -0,0 +1,524 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	log "github.com/sirupsen/logrus"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// brokeredToolSeparator joins server and tool names in the tool names offered to models.
+const brokeredToolSeparator = "__"
+
+// maxToolNameLength is the longest tool name every dialect accepts.
+const maxToolNameLength = 64
+
+// toolListFailureTTL is how long a failed tool listing is remembered, so a server that is
+// down is not asked again by every request.
+const toolListFailureTTL = 30 * time.Second
+
+// brokeredTool is an MCP tool offered to models under Name.
+type brokeredTool struct {
+	Name   string
+	tool   mcpTool
+	client *mcpClient
+}
+
+// toolCall is a call of the model to a tool.
+type toolCall struct {
+	ID        string
+	Name      string
+	Arguments json.RawMessage
+}
+
+// toolResult is the outcome of a brokered tool call.
+type toolResult struct {
+	Text    string
+	IsError bool
+}
+
+type toolListEntry struct {
+	tools   []mcpTool
+	err     error
+	expires time.Time
+}
+
+// toolBroker keeps one MCP client per configured server and caches their tool lists.
+type toolBroker struct {
+	mu      sync.Mutex
+	clients map[string]*mcpClient
+	lists   map[string]toolListEntry
+	now     func() time.Time
+}
+
+func newToolBroker() *toolBroker {
+	return &toolBroker{
+		clients: make(map[string]*mcpClient),
+		lists:   make(map[string]toolListEntry),
+		now:     time.Now,
+	}
+}
+
+// executeToolBrokered offers the tools of the configured MCP servers with rawJSON. When
+// the response calls brokered tools only, they are executed and the conversation is run
+// again with their results, up to max-rounds times. Responses calling tools of the client
+// are returned as they are, as are requests of internal callers without a client
+// connection. Calls of brokered tools are removed from responses handed to the client,
+// which cannot run them.
+func (h *BaseAPIHandler) executeToolBrokered(ctx context.Context, handlerType string, rawJSON []byte, execute func([]byte) ([]byte, *interfaces.ErrorMessage)) ([]byte, *interfaces.ErrorMessage) {
+	dialect, ok := toolDialects[handlerType]
+	if h.tools == nil || h.Cfg == nil || len(h.Cfg.MCPTools.Servers) == 0 || !ok {
+		return execute(rawJSON)
+	}
+	if ginCtx, _ := ctx.Value("gin").(*gin.Context); ginCtx == nil {
+		return execute(rawJSON)
+	}
+	settings := h.Cfg.MCPTools
+	own := dialect.toolNames(rawJSON)
+	var offered []brokeredTool
+	for _, tool := range h.tools.available(ctx, settings, h.Cfg.ProxyURL) {
+		if !own[tool.Name] {
+			offered = append(offered, tool)
+		}
+	}
+	if len(offered) == 0 {
+		return execute(rawJSON)
+	}
+	byName := make(map[string]brokeredTool, len(offered))
+	for _, tool := range offered {
+		byName[tool.Name] = tool
+	}
+
+	maxRounds := settings.MaxRounds
+	if maxRounds <= 0 {
+		maxRounds = config.DefaultMCPToolMaxRounds
+	}
+	request := dialect.addTools(rawJSON, offered)
+	for round := 0; ; round++ {
+		resp, errMsg := execute(request)
+		if errMsg != nil {
+			return resp, errMsg
+		}
+		calls := dialect.toolCalls(resp)
+		if len(calls) == 0 {
+			return resp, nil
+		}
+		if !allBrokered(calls, byName) {
+			return dialect.removeCalls(resp, byName), nil
+		}
+		if round >= maxRounds {
+			log.Warnf("mcp tools: model still calling tools after %d rounds, returning its last response", maxRounds)
+			return dialect.removeCalls(resp, byName), nil
+		}
+		results := runToolCalls(ctx, calls, byName)
+		request = dialect.appendResults(request, resp, calls, results)
+	}
+}
+
+func allBrokered(calls []toolCall, tools map[string]brokeredTool) bool {
+	for _, call := range calls {
+		if _, ok := tools[call.Name]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// runToolCalls runs the calls concurrently. Failures become error results for the model.
+func runToolCalls(ctx context.Context, calls []toolCall, tools map[string]brokeredTool) []toolResult {
+	results := make([]toolResult, len(calls))
+	var wg sync.WaitGroup
+	for i, call := range calls {
+		wg.Add(1)
+		go func(i int, call toolCall) {
+			defer wg.Done()
+			tool := tools[call.Name]
+			text, isError, err := tool.client.callTool(ctx, tool.tool.Name, call.Arguments)
+			if err != nil {
+				log.Warnf("mcp tools: %s failed: %v", call.Name, err)
+				results[i] = toolResult{Text: fmt.Sprintf("tool call failed: %v", err), IsError: true}
+				return
+			}
+			results[i] = toolResult{Text: text, IsError: isError}
+		}(i, call)
+	}
+	wg.Wait()
+	return results
+}
+
+// available returns the tools of every configured server. Servers that cannot be reached
+// are skipped until toolListFailureTTL has passed.
+func (tb *toolBroker) available(ctx context.Context, settings config.MCPToolsConfig, proxyURL string) []brokeredTool {
+	cacheTTL := time.Duration(settings.CacheSeconds) * time.Second
+	if cacheTTL <= 0 {
+		cacheTTL = config.DefaultMCPToolCacheSeconds * time.Second
+	}
+	var out []brokeredTool
+	for _, server := range settings.Servers {
+		client, key := tb.client(server, proxyURL)
+		tools, err := tb.list(ctx, client, key, cacheTTL)
+		if err != nil {
+			log.Warnf("mcp tools: listing tools of %s failed: %v", server.Name, err)
+			continue
+		}
+		allowed := make(map[string]bool, len(server.Tools))
+		for _, name := range server.Tools {
+			allowed[name] = true
+		}
+		for _, tool := range tools {
+			if len(allowed) > 0 && !allowed[tool.Name] {
+				continue
+			}
+			name := server.Name + brokeredToolSeparator + sanitizeToolName(tool.Name)
+			if len(name) > maxToolNameLength {
+				log.Debugf("mcp tools: skipping %s, its name exceeds %d characters", name, maxToolNameLength)
+				continue
+			}
+			out = append(out, brokeredTool{Name: name, tool: tool, client: client})
+		}
+	}
+	return out
+}
+
+func (tb *toolBroker) client(server config.MCPToolServer, proxyURL string) (*mcpClient, string) {
+	encoded, _ := json.Marshal(server)
+	key := proxyURL + "\x00" + string(encoded)
+	tb.mu.Lock()
+	defer tb.mu.Unlock()
+	client, ok := tb.clients[key]
+	if !ok {
+		client = newMCPClient(server, proxyURL)
+		tb.clients[key] = client
+	}
+	return client, key
+}
+
+func (tb *toolBroker) list(ctx context.Context, client *mcpClient, key string, ttl time.Duration) ([]mcpTool, error) {
+	tb.mu.Lock()
+	entry, ok := tb.lists[key]
+	tb.mu.Unlock()
+	if ok && tb.now().Before(entry.expires) {
+		return entry.tools, entry.err
+	}
+	tools, err := client.listTools(ctx)
+	if err != nil {
+		tb.mu.Lock()
+		tb.lists[key] = toolListEntry{err: err, expires: tb.now().Add(toolListFailureTTL)}
+		tb.mu.Unlock()
+		return nil, err
+	}
+	tb.mu.Lock()
+	tb.lists[key] = toolListEntry{tools: tools, expires: tb.now().Add(ttl)}
+	tb.mu.Unlock()
+	return tools, nil
+}
+
+// sanitizeToolName replaces characters tool names may not contain in every dialect.
+func sanitizeToolName(name string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' || r == '-' {
+			return r
+		}
+		return '_'
+	}, name)
+}
+
+// toolDialect reads and writes tools, tool calls and tool results in one request format.
+type toolDialect interface {
+	// toolNames returns the names of the tools the client declared.
+	toolNames(request []byte) map[string]bool
+	// addTools declares tools in request.
+	addTools(request []byte, tools []brokeredTool) []byte
+	// toolCalls returns the tool calls of response.
+	toolCalls(response []byte) []toolCall
+	// appendResults continues request with the model turn of response and the results.
+	appendResults(request, response []byte, calls []toolCall, results []toolResult) []byte
+	// removeCalls drops the calls of brokered tools from response.
+	removeCalls(response []byte, brokered map[string]brokeredTool) []byte
+}
+
+var toolDialects = map[string]toolDialect{
+	"openai":          openAIToolDialect{},
+	"openai-response": responsesToolDialect{},
+	"claude":          claudeToolDialect{},
+	"gemini":          geminiToolDialect{},
+}
+
+func toolNamesAt(request []byte, path string) map[string]bool {
+	names := make(map[string]bool)
+	for _, name := range gjson.GetBytes(request, path).Array() {
+		names[name.String()] = true
+	}
+	return names
+}
+
+func appendJSON(doc []byte, path string, value any) []byte {
+	if updated, err := sjson.SetBytes(doc, path+".-1", value); err == nil {
+		return updated
+	}
+	return doc
+}
+
+func appendRawJSON(doc []byte, path, raw string) []byte {
+	if updated, err := sjson.SetRawBytes(doc, path+".-1", []byte(raw)); err == nil {
+		return updated
+	}
+	return doc
+}
+
+// filterJSONArray keeps the elements of the array at path for which keep returns true.
+func filterJSONArray(doc []byte, path string, keep func(gjson.Result) bool) []byte {
+	array := gjson.GetBytes(doc, path)
+	if !array.IsArray() {
+		return doc
+	}
+	kept := make([]string, 0)
+	for _, item := range array.Array() {
+		if keep(item) {
+			kept = append(kept, item.Raw)
+		}
+	}
+	if updated, err := sjson.SetRawBytes(doc, path, []byte("["+strings.Join(kept, ",")+"]")); err == nil {
+		return updated
+	}
+	return doc
+}
+
+// toolArguments parses arguments sent as a JSON string, as OpenAI dialects do.
+func toolArguments(value gjson.Result) json.RawMessage {
+	if value.Type == gjson.String {
+		return json.RawMessage(value.String())
+	}
+	return json.RawMessage(value.Raw)
+}
+
+type openAIToolDialect struct{}
+
+func (openAIToolDialect) toolNames(request []byte) map[string]bool {
+	return toolNamesAt(request, "tools.#.function.name")
+}
+
+func (openAIToolDialect) addTools(request []byte, tools []brokeredTool) []byte {
+	for _, tool := range tools {
+		request = appendJSON(request, "tools", map[string]any{
+			"type": "function",
+			"function": map[string]any{
+				"name":        tool.Name,
+				"description": tool.tool.Description,
+				"parameters":  tool.tool.InputSchema,
+			},
+		})
+	}
+	return request
+}
+
+func (openAIToolDialect) toolCalls(response []byte) []toolCall {
+	var calls []toolCall
+	for _, call := range gjson.GetBytes(response, "choices.0.message.tool_calls").Array() {
+		calls = append(calls, toolCall{
+			ID:        call.Get("id").String(),
+			Name:      call.Get("function.name").String(),
+			Arguments: toolArguments(call.Get("function.arguments")),
+		})
+	}
+	return calls
+}
+
+func (openAIToolDialect) appendResults(request, response []byte, calls []toolCall, results []toolResult) []byte {
+	request = appendRawJSON(request, "messages", gjson.GetBytes(response, "choices.0.message").Raw)
+	for i, call := range calls {
+		request = appendJSON(request, "messages", map[string]any{"role": "tool", "tool_call_id": call.ID, "content": results[i].Text})
+	}
+	return request
+}
+
+func (openAIToolDialect) removeCalls(response []byte, brokered map[string]brokeredTool) []byte {
+	response = filterJSONArray(response, "choices.0.message.tool_calls", func(call gjson.Result) bool {
+		_, ok := brokered[call.Get("function.name").String()]
+		return !ok
+	})
+	if len(gjson.GetBytes(response, "choices.0.message.tool_calls").Array()) == 0 {
+		response, _ = sjson.DeleteBytes(response, "choices.0.message.tool_calls")
+		if gjson.GetBytes(response, "choices.0.finish_reason").String() == "tool_calls" {
+			response, _ = sjson.SetBytes(response, "choices.0.finish_reason", "stop")
+		}
+	}
+	return response
+}
+
+type responsesToolDialect struct{}
+
+func (responsesToolDialect) toolNames(request []byte) map[string]bool {
+	return toolNamesAt(request, "tools.#.name")
+}
+
+func (responsesToolDialect) addTools(request []byte, tools []brokeredTool) []byte {
+	for _, tool := range tools {
+		request = appendJSON(request, "tools", map[string]any{
+			"type":        "function",
+			"name":        tool.Name,
+			"description": tool.tool.Description,
+			"parameters":  tool.tool.InputSchema,
+		})
+	}
+	return request
+}
+
+func (responsesToolDialect) toolCalls(response []byte) []toolCall {
+	var calls []toolCall
+	for _, item := range gjson.GetBytes(response, "output").Array() {
+		if item.Get("type").String() != "function_call" {
+			continue
+		}
+		calls = append(calls, toolCall{
+			ID:        item.Get("call_id").String(),
+			Name:      item.Get("name").String(),
+			Arguments: toolArguments(item.Get("arguments")),
+		})
+	}
+	return calls
+}
+
+func (responsesToolDialect) appendResults(request, response []byte, calls []toolCall, results []toolResult) []byte {
+	if input := gjson.GetBytes(request, "input"); input.Type == gjson.String {
+		request, _ = sjson.SetBytes(request, "input", []map[string]any{{"type": "message", "role": "user", "content": input.String()}})
+	}
+	for _, item := range gjson.GetBytes(response, "output").Array() {
+		request = appendRawJSON(request, "input", item.Raw)
+	}
+	for i, call := range calls {
+		request = appendJSON(request, "input", map[string]any{"type": "function_call_output", "call_id": call.ID, "output": results[i].Text})
+	}
+	return request
+}
+
+func (responsesToolDialect) removeCalls(response []byte, brokered map[string]brokeredTool) []byte {
+	return filterJSONArray(response, "output", func(item gjson.Result) bool {
+		_, ok := brokered[item.Get("name").String()]
+		return item.Get("type").String() != "function_call" || !ok
+	})
+}
+
+type claudeToolDialect struct{}
+
+func (claudeToolDialect) toolNames(request []byte) map[string]bool {
+	return toolNamesAt(request, "tools.#.name")
+}
+
+func (claudeToolDialect) addTools(request []byte, tools []brokeredTool) []byte {
+	for _, tool := range tools {
+		request = appendJSON(request, "tools", map[string]any{
+			"name":         tool.Name,
+			"description":  tool.tool.Description,
+			"input_schema": tool.tool.InputSchema,
+		})
+	}
+	return request
+}
+
+func (claudeToolDialect) toolCalls(response []byte) []toolCall {
+	var calls []toolCall
+	for _, block := range gjson.GetBytes(response, "content").Array() {
+		if block.Get("type").String() != "tool_use" {
+			continue
+		}
+		calls = append(calls, toolCall{
+			ID:        block.Get("id").String(),
+			Name:      block.Get("name").String(),
+			Arguments: json.RawMessage(block.Get("input").Raw),
+		})
+	}
+	return calls
+}
+
+func (claudeToolDialect) appendResults(request, response []byte, calls []toolCall, results []toolResult) []byte {
+	assistant, _ := sjson.SetRawBytes([]byte(`{"role":"assistant"}`), "content", []byte(gjson.GetBytes(response, "content").Raw))
+	request = appendRawJSON(request, "messages", string(assistant))
+	blocks := make([]map[string]any, len(calls))
+	for i, call := range calls {
+		blocks[i] = map[string]any{"type": "tool_result", "tool_use_id": call.ID, "content": results[i].Text}
+		if results[i].IsError {
+			blocks[i]["is_error"] = true
+		}
+	}
+	return appendJSON(request, "messages", map[string]any{"role": "user", "content": blocks})
+}
+
+func (claudeToolDialect) removeCalls(response []byte, brokered map[string]brokeredTool) []byte {
+	response = filterJSONArray(response, "content", func(block gjson.Result) bool {
+		_, ok := brokered[block.Get("name").String()]
+		return block.Get("type").String() != "tool_use" || !ok
+	})
+	if gjson.GetBytes(response, "stop_reason").String() == "tool_use" && !gjson.GetBytes(response, `content.#(type=="tool_use")`).Exists() {
+		response, _ = sjson.SetBytes(response, "stop_reason", "end_turn")
+	}
+	return response
+}
+
+type geminiToolDialect struct{}
+
+func (geminiToolDialect) toolNames(request []byte) map[string]bool {
+	return toolNamesAt(request, "tools.#.functionDeclarations.#.name|@flatten")
+}
+
+func (geminiToolDialect) addTools(request []byte, tools []brokeredTool) []byte {
+	declarations := make([]map[string]any, len(tools))
+	for i, tool := range tools {
+		declarations[i] = map[string]any{
+			"name":                 tool.Name,
+			"description":          tool.tool.Description,
+			"parametersJsonSchema": tool.tool.InputSchema,
+		}
+	}
+	return appendJSON(request, "tools", map[string]any{"functionDeclarations": declarations})
+}
+
+func (geminiToolDialect) toolCalls(response []byte) []toolCall {
+	var calls []toolCall
+	for _, part := range gjson.GetBytes(response, "candidates.0.content.parts").Array() {
+		call := part.Get("functionCall")
+		if !call.Exists() {
+			continue
+		}
+		calls = append(calls, toolCall{
+			ID:        call.Get("id").String(),
+			Name:      call.Get("name").String(),
+			Arguments: json.RawMessage(call.Get("args").Raw),
+		})
+	}
+	return calls
+}
+
+func (geminiToolDialect) appendResults(request, response []byte, calls []toolCall, results []toolResult) []byte {
+	model, _ := sjson.SetBytes([]byte(gjson.GetBytes(response, "candidates.0.content").Raw), "role", "model")
+	request = appendRawJSON(request, "contents", string(model))
+	parts := make([]map[string]any, len(calls))
+	for i, call := range calls {
+		key := "content"
+		if results[i].IsError {
+			key = "error"
+		}
+		functionResponse := map[string]any{"name": call.Name, "response": map[string]any{key: results[i].Text}}
+		if call.ID != "" {
+			functionResponse["id"] = call.ID
+		}
+		parts[i] = map[string]any{"functionResponse": functionResponse}
+	}
+	return appendJSON(request, "contents", map[string]any{"role": "user", "parts": parts})
+}
+
+func (geminiToolDialect) removeCalls(response []byte, brokered map[string]brokeredTool) []byte {
+	return filterJSONArray(response, "candidates.0.content.parts", func(part gjson.Result) bool {
+		_, ok := brokered[part.Get("functionCall.name").String()]
+		return !part.Get("functionCall").Exists() || !ok
+	})
+}
@@ -0,0 +1,205 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/interfaces"
+	sdkconfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	"github.com/tidwall/gjson"
+)
+
+// newFakeMCPServer serves a "weather" and a "time" tool. Tool calls are answered as an
+// event stream, preceded by a progress notification.
+func newFakeMCPServer(t *testing.T, listCalls *atomic.Int32) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		msg := gjson.ParseBytes(body)
+		method, id := msg.Get("method").String(), msg.Get("id").Raw
+		if method != "initialize" && r.Header.Get(mcpSessionHeader) != "session-1" {
+			http.Error(w, "unknown session", http.StatusNotFound)
+			return
+		}
+		switch method {
+		case "initialize":
+			w.Header().Set(mcpSessionHeader, "session-1")
+			fmt.Fprintf(w, `{"jsonrpc":"2.0","id":%s,"result":{"protocolVersion":"2025-06-18","capabilities":{"tools":{}}}}`, id)
+		case "notifications/initialized":
+			w.WriteHeader(http.StatusAccepted)
+		case "tools/list":
+			listCalls.Add(1)
+			fmt.Fprintf(w, `{"jsonrpc":"2.0","id":%s,"result":{"tools":[{"name":"weather","description":"Weather by city","inputSchema":{"type":"object","properties":{"city":{"type":"string"}}}},{"name":"time"}]}}`, id)
+		case "tools/call":
+			city := msg.Get("params.arguments.city").String()
+			w.Header().Set("Content-Type", "text/event-stream")
+			fmt.Fprintf(w, "event: message\ndata: {\"jsonrpc\":\"2.0\",\"method\":\"notifications/progress\"}\n\n")
+			fmt.Fprintf(w, "event: message\ndata: {\"jsonrpc\":\"2.0\",\"id\":%s,\"result\":{\"content\":[{\"type\":\"text\",\"text\":\"sunny in %s\"}]}}\n\n", id, city)
+		default:
+			fmt.Fprintf(w, `{"jsonrpc":"2.0","id":%s,"error":{"code":-32601,"message":"nope"}}`, id)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func newToolBrokerHandler(t *testing.T, listCalls *atomic.Int32) *BaseAPIHandler {
+	t.Helper()
+	server := newFakeMCPServer(t, listCalls)
+	return NewBaseAPIHandlers(&sdkconfig.SDKConfig{MCPTools: sdkconfig.MCPToolsConfig{
+		Servers: []sdkconfig.MCPToolServer{{Name: "wx", URL: server.URL, Tools: []string{"weather"}}},
+	}}, nil)
+}
+
+func toolBrokerContext() context.Context {
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/", nil)
+	return context.WithValue(context.Background(), "gin", c)
+}
+
+func TestToolBrokerRunsBrokeredCalls(t *testing.T) {
+	cases := []struct {
+		handlerType string
+		request     string
+		toolCall    string
+		toolsPath   string
+		resultPath  string
+	}{
+		{
+			handlerType: "openai",
+			request:     `{"model":"m","messages":[{"role":"user","content":"weather?"}]}`,
+			toolCall:    `{"choices":[{"message":{"role":"assistant","tool_calls":[{"id":"c1","type":"function","function":{"name":"wx__weather","arguments":"{\"city\":\"Paris\"}"}}]}}]}`,
+			toolsPath:   "tools.#.function.name",
+			resultPath:  "messages.2.content",
+		},
+		{
+			handlerType: "claude",
+			request:     `{"model":"m","messages":[{"role":"user","content":"weather?"}]}`,
+			toolCall:    `{"content":[{"type":"tool_use","id":"t1","name":"wx__weather","input":{"city":"Paris"}}]}`,
+			toolsPath:   "tools.#.name",
+			resultPath:  "messages.2.content.0.content",
+		},
+		{
+			handlerType: "gemini",
+			request:     `{"contents":[{"role":"user","parts":[{"text":"weather?"}]}]}`,
+			toolCall:    `{"candidates":[{"content":{"parts":[{"functionCall":{"name":"wx__weather","args":{"city":"Paris"}}}]}}]}`,
+			toolsPath:   "tools.#.functionDeclarations.#.name|@flatten",
+			resultPath:  "contents.2.parts.0.functionResponse.response.content",
+		},
+		{
+			handlerType: "openai-response",
+			request:     `{"model":"m","input":"weather?"}`,
+			toolCall:    `{"output":[{"type":"function_call","call_id":"c1","name":"wx__weather","arguments":"{\"city\":\"Paris\"}"}]}`,
+			toolsPath:   "tools.#.name",
+			resultPath:  "input.2.output",
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.handlerType, func(t *testing.T) {
+			var listCalls atomic.Int32
+			h := newToolBrokerHandler(t, &listCalls)
+			var requests []string
+			resp, errMsg := h.executeToolBrokered(toolBrokerContext(), tc.handlerType, []byte(tc.request), func(request []byte) ([]byte, *interfaces.ErrorMessage) {
+				requests = append(requests, string(request))
+				if len(requests) == 1 {
+					return []byte(tc.toolCall), nil
+				}
+				return []byte(`{"final":true}`), nil
+			})
+			if errMsg != nil {
+				t.Fatal(errMsg.Error)
+			}
+			if string(resp) != `{"final":true}` || len(requests) != 2 {
+				t.Fatalf("resp = %s after %d requests", resp, len(requests))
+			}
+			if got := gjson.Get(requests[0], tc.toolsPath).String(); got != `["wx__weather"]` {
+				t.Fatalf("offered tools = %s in %s", got, requests[0])
+			}
+			if got := gjson.Get(requests[1], tc.resultPath).String(); got != "sunny in Paris" {
+				t.Fatalf("tool result = %q in %s", got, requests[1])
+			}
+		})
+	}
+}
+
+func TestToolBrokerLeavesClientToolCalls(t *testing.T) {
+	var listCalls atomic.Int32
+	h := newToolBrokerHandler(t, &listCalls)
+	clientCall := `{"choices":[{"message":{"role":"assistant","tool_calls":[{"id":"c1","type":"function","function":{"name":"read_file","arguments":"{}"}}]}}]}`
+	calls := 0
+	for i := 0; i < 2; i++ {
+		resp, _ := h.executeToolBrokered(toolBrokerContext(), "openai", []byte(`{"messages":[],"tools":[{"type":"function","function":{"name":"read_file"}}]}`), func([]byte) ([]byte, *interfaces.ErrorMessage) {
+			calls++
+			return []byte(clientCall), nil
+		})
+		if string(resp) != clientCall {
+			t.Fatalf("resp = %s", resp)
+		}
+	}
+	if calls != 2 || listCalls.Load() != 1 {
+		t.Fatalf("executions = %d, tool list requests = %d; want 2 and 1 (cached)", calls, listCalls.Load())
+	}
+
+	// Internal requests without a client connection get no tools.
+	h.executeToolBrokered(context.Background(), "openai", []byte(`{"messages":[]}`), func(request []byte) ([]byte, *interfaces.ErrorMessage) {
+		if strings.Contains(string(request), "wx__weather") {
+			t.Fatalf("internal request was offered tools: %s", request)
+		}
+		return []byte(`{}`), nil
+	})
+}
+
+func TestToolBrokerStopsAfterMaxRounds(t *testing.T) {
+	var listCalls atomic.Int32
+	h := newToolBrokerHandler(t, &listCalls)
+	h.Cfg.MCPTools.MaxRounds = 2
+	call := `{"content":[{"type":"tool_use","id":"t1","name":"wx__weather","input":{"city":"Oslo"}}]}`
+	executions := 0
+	resp, _ := h.executeToolBrokered(toolBrokerContext(), "claude", []byte(`{"messages":[]}`), func([]byte) ([]byte, *interfaces.ErrorMessage) {
+		executions++
+		return []byte(call), nil
+	})
+	if executions != 3 || gjson.GetBytes(resp, `content.#(type=="tool_use")`).Exists() {
+		t.Fatalf("executions = %d, resp = %s", executions, resp)
+	}
+}
+
+func TestToolBrokerRemovesBrokeredCallsFromMixedResponses(t *testing.T) {
+	var listCalls atomic.Int32
+	h := newToolBrokerHandler(t, &listCalls)
+	mixed := `{"output":[{"type":"message","content":[]},{"type":"function_call","call_id":"c1","name":"wx__weather","arguments":"{}"},{"type":"function_call","call_id":"c2","name":"read_file","arguments":"{}"}]}`
+	resp, _ := h.executeToolBrokered(toolBrokerContext(), "openai-response", []byte(`{"input":"hi","tools":[{"type":"function","name":"read_file"}]}`), func([]byte) ([]byte, *interfaces.ErrorMessage) {
+		return []byte(mixed), nil
+	})
+	if got := gjson.GetBytes(resp, "output.#.type").String(); got != `["message","function_call"]` || gjson.GetBytes(resp, "output.1.name").String() != "read_file" {
+		t.Fatalf("resp = %s", resp)
+	}
+}
+
+func TestToolBrokerRemembersFailedListings(t *testing.T) {
+	var hits atomic.Int32
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		http.Error(w, "down", http.StatusBadGateway)
+	}))
+	defer down.Close()
+	h := NewBaseAPIHandlers(&sdkconfig.SDKConfig{MCPTools: sdkconfig.MCPToolsConfig{
+		Servers: []sdkconfig.MCPToolServer{{Name: "wx", URL: down.URL}},
+	}}, nil)
+	for i := 0; i < 3; i++ {
+		h.executeToolBrokered(toolBrokerContext(), "openai", []byte(`{"messages":[]}`), func([]byte) ([]byte, *interfaces.ErrorMessage) {
+			return []byte(`{}`), nil
+		})
+	}
+	if hits.Load() != 1 {
+		t.Fatalf("unreachable server was asked %d times, want 1", hits.Load())
+	}
+}
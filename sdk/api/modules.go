@@ -0,0 +1,23 @@
+package api
+
+import (
+	internalapi "github.com/router-for-me/CLIProxyAPI/v6/internal/api"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/api/modules"
+)
+
+// ModuleContext carries the dependencies handed to a routing module on registration.
+type ModuleContext = modules.Context
+
+// RouteModule is a pluggable bundle of routes registered on the embedded server.
+type RouteModule = modules.RouteModuleV2
+
+// ModuleDependent is implemented by routing modules that need other modules.
+type ModuleDependent = modules.Dependent
+
+// ModuleOrdered is implemented by routing modules constraining their registration order.
+type ModuleOrdered = modules.Ordered
+
+// WithRouteModules registers additional routing modules after the built-in ones.
+func WithRouteModules(mods ...RouteModule) ServerOption {
+	return internalapi.WithRouteModules(mods...)
+}
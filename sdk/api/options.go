@@ -5,6 +5,7 @@
 package api
 
 import (
+	"net"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -40,6 +41,11 @@ func WithKeepAliveEndpoint(timeout time.Duration, onTimeout func()) ServerOption
 	return internalapi.WithKeepAliveEndpoint(timeout, onTimeout)
 }
 
+// WithListener serves on ln instead of binding the configured host and port.
+func WithListener(ln net.Listener) ServerOption {
+	return internalapi.WithListener(ln)
+}
+
 // WithRequestLoggerFactory customises request logger creation.
 func WithRequestLoggerFactory(factory func(*config.Config, string) logging.RequestLogger) ServerOption {
 	return internalapi.WithRequestLoggerFactory(factory)
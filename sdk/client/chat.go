@@ -0,0 +1,207 @@
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// Message is one message of a chat conversation.
+type Message struct {
+	Role       string `json:"role"`
+	Content    string `json:"content"`
+	Name       string `json:"name,omitempty"`
+	ToolCallID string `json:"tool_call_id,omitempty"`
+}
+
+// ChatRequest is an OpenAI-compatible chat completion request. Use ChatCompletionRaw for
+// fields not covered here, such as tools.
+type ChatRequest struct {
+	Model           string    `json:"model"`
+	Messages        []Message `json:"messages"`
+	MaxTokens       int       `json:"max_tokens,omitempty"`
+	Temperature     *float64  `json:"temperature,omitempty"`
+	TopP            *float64  `json:"top_p,omitempty"`
+	Stop            []string  `json:"stop,omitempty"`
+	ReasoningEffort string    `json:"reasoning_effort,omitempty"`
+	User            string    `json:"user,omitempty"`
+	Stream          bool      `json:"stream,omitempty"`
+}
+
+// Usage is the token accounting of a completion.
+type Usage struct {
+	PromptTokens     int64 `json:"prompt_tokens"`
+	CompletionTokens int64 `json:"completion_tokens"`
+	TotalTokens      int64 `json:"total_tokens"`
+}
+
+// ChatChoice is one choice of a chat completion.
+type ChatChoice struct {
+	Index        int     `json:"index"`
+	Message      Message `json:"message"`
+	FinishReason string  `json:"finish_reason"`
+}
+
+// ChatResponse is a chat completion.
+type ChatResponse struct {
+	ID      string       `json:"id"`
+	Object  string       `json:"object"`
+	Created int64        `json:"created"`
+	Model   string       `json:"model"`
+	Choices []ChatChoice `json:"choices"`
+	Usage   *Usage       `json:"usage,omitempty"`
+}
+
+// Text returns the content of the first choice.
+func (r *ChatResponse) Text() string {
+	if r == nil || len(r.Choices) == 0 {
+		return ""
+	}
+	return r.Choices[0].Message.Content
+}
+
+// ChunkChoice is one choice of a streamed chat completion chunk.
+type ChunkChoice struct {
+	Index        int     `json:"index"`
+	Delta        Message `json:"delta"`
+	FinishReason string  `json:"finish_reason"`
+}
+
+// ChatChunk is one event of a streamed chat completion.
+type ChatChunk struct {
+	ID      string        `json:"id"`
+	Model   string        `json:"model"`
+	Choices []ChunkChoice `json:"choices"`
+	Usage   *Usage        `json:"usage,omitempty"`
+}
+
+// ChatCompletion sends a non-streaming chat completion request.
+func (c *Client) ChatCompletion(ctx context.Context, req ChatRequest) (*ChatResponse, error) {
+	req.Stream = false
+	var resp ChatResponse
+	if err := c.do(ctx, http.MethodPost, "/v1/chat/completions", req, &resp, false); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// ChatCompletionRaw sends body as a chat completion request and returns the raw response.
+func (c *Client) ChatCompletionRaw(ctx context.Context, body []byte) ([]byte, error) {
+	var out []byte
+	if err := c.do(ctx, http.MethodPost, "/v1/chat/completions", body, &out, false); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ChatCompletionStream sends a streaming chat completion request. The caller must Close the
+// returned stream.
+func (c *Client) ChatCompletionStream(ctx context.Context, req ChatRequest) (*ChatStream, error) {
+	req.Stream = true
+	httpReq, err := c.newRequest(ctx, http.MethodPost, "/v1/chat/completions", req, false)
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Accept", "text/event-stream")
+	resp, err := c.send(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	return &ChatStream{body: resp.Body, reader: bufio.NewReader(resp.Body)}, nil
+}
+
+// ChatStream reads the chunks of a streamed chat completion:
+//
+//	for stream.Next() {
+//	    fmt.Print(stream.Chunk().Choices[0].Delta.Content)
+//	}
+//	if err := stream.Err(); err != nil { ... }
+type ChatStream struct {
+	body   io.ReadCloser
+	reader *bufio.Reader
+	chunk  ChatChunk
+	err    error
+	done   bool
+}
+
+// Next advances to the next chunk, returning false at the end of the stream or on error.
+func (s *ChatStream) Next() bool {
+	if s.done {
+		return false
+	}
+	for {
+		line, err := s.reader.ReadBytes('\n')
+		line = bytes.TrimSpace(line)
+		if data, ok := bytes.CutPrefix(line, []byte("data:")); ok {
+			data = bytes.TrimSpace(data)
+			if bytes.Equal(data, []byte("[DONE]")) {
+				s.done = true
+				return false
+			}
+			if len(data) > 0 {
+				return s.decode(data)
+			}
+		}
+		if err != nil {
+			s.done = true
+			if err != io.EOF {
+				s.err = err
+			}
+			return false
+		}
+	}
+}
+
+// decode parses one data payload into the current chunk. Error events end the stream.
+func (s *ChatStream) decode(data []byte) bool {
+	var probe struct {
+		Error json.RawMessage `json:"error"`
+	}
+	if json.Unmarshal(data, &probe) == nil && len(probe.Error) > 0 && !bytes.Equal(probe.Error, []byte("null")) {
+		s.done = true
+		s.err = parseAPIError(http.StatusOK, data)
+		return false
+	}
+	s.chunk = ChatChunk{}
+	if err := json.Unmarshal(data, &s.chunk); err != nil {
+		s.done = true
+		s.err = fmt.Errorf("cliproxy: decode stream chunk: %w", err)
+		return false
+	}
+	return true
+}
+
+// Chunk returns the chunk read by the last successful Next.
+func (s *ChatStream) Chunk() ChatChunk { return s.chunk }
+
+// Err returns the error that ended the stream, if any.
+func (s *ChatStream) Err() error { return s.err }
+
+// Close releases the stream.
+func (s *ChatStream) Close() error {
+	s.done = true
+	return s.body.Close()
+}
+
+// Model is one model served by the proxy.
+type Model struct {
+	ID      string `json:"id"`
+	Object  string `json:"object"`
+	Created int64  `json:"created"`
+	OwnedBy string `json:"owned_by"`
+}
+
+// Models lists the models available to the API key.
+func (c *Client) Models(ctx context.Context) ([]Model, error) {
+	var resp struct {
+		Data []Model `json:"data"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/v1/models", nil, &resp, false); err != nil {
+		return nil, err
+	}
+	return resp.Data, nil
+}
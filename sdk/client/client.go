@@ -0,0 +1,182 @@
+// Package client provides a typed Go client for a running CLIProxyAPI server, covering the
+// OpenAI-compatible chat and model endpoints and the management API, so Go programs
+// orchestrating the proxy do not need to hand-roll HTTP calls.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// Client talks to one CLIProxyAPI server. It is safe for concurrent use.
+type Client struct {
+	baseURL       string
+	apiKey        string
+	managementKey string
+	httpClient    *http.Client
+	headers       http.Header
+}
+
+// Option configures a Client.
+type Option func(*Client)
+
+// WithAPIKey sets the API key sent with chat and model requests.
+func WithAPIKey(key string) Option {
+	return func(c *Client) { c.apiKey = key }
+}
+
+// WithManagementKey sets the secret sent with management API requests.
+func WithManagementKey(key string) Option {
+	return func(c *Client) { c.managementKey = key }
+}
+
+// WithHTTPClient replaces the HTTP client used for requests.
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		if httpClient != nil {
+			c.httpClient = httpClient
+		}
+	}
+}
+
+// WithHeader adds a header sent with every request, e.g. X-CLIProxy-Priority.
+func WithHeader(key, value string) Option {
+	return func(c *Client) { c.headers.Add(key, value) }
+}
+
+// New constructs a client for the server at baseURL, e.g. "http://127.0.0.1:8317".
+func New(baseURL string, opts ...Option) *Client {
+	c := &Client{
+		baseURL:    strings.TrimRight(strings.TrimSpace(baseURL), "/"),
+		httpClient: http.DefaultClient,
+		headers:    make(http.Header),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// APIError is returned for responses with a non-2xx status.
+type APIError struct {
+	StatusCode int
+	// Message, Type and Code come from the error body when it has the OpenAI error shape;
+	// management errors only carry a Message.
+	Message string
+	Type    string
+	Code    string
+	// Body is the raw response body.
+	Body []byte
+}
+
+func (e *APIError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("cliproxy: status %d", e.StatusCode)
+	}
+	return fmt.Sprintf("cliproxy: status %d: %s", e.StatusCode, e.Message)
+}
+
+// parseAPIError builds the error of a failed response from its status and body.
+func parseAPIError(status int, body []byte) *APIError {
+	apiErr := &APIError{StatusCode: status, Body: body}
+	var payload struct {
+		Error json.RawMessage `json:"error"`
+	}
+	if json.Unmarshal(body, &payload) != nil || len(payload.Error) == 0 {
+		apiErr.Message = strings.TrimSpace(string(body))
+		return apiErr
+	}
+	var detail struct {
+		Message string `json:"message"`
+		Type    string `json:"type"`
+		Code    string `json:"code"`
+	}
+	if json.Unmarshal(payload.Error, &detail) == nil {
+		apiErr.Message, apiErr.Type, apiErr.Code = detail.Message, detail.Type, detail.Code
+		return apiErr
+	}
+	_ = json.Unmarshal(payload.Error, &apiErr.Message)
+	return apiErr
+}
+
+// newRequest builds a request for path, authenticated with the management secret when
+// management is set and with the API key otherwise. body is JSON-encoded unless nil.
+func (c *Client) newRequest(ctx context.Context, method, path string, body any, management bool) (*http.Request, error) {
+	var reader io.Reader
+	if body != nil {
+		if raw, ok := body.([]byte); ok {
+			reader = bytes.NewReader(raw)
+		} else {
+			data, err := json.Marshal(body)
+			if err != nil {
+				return nil, fmt.Errorf("cliproxy: encode request: %w", err)
+			}
+			reader = bytes.NewReader(data)
+		}
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, reader)
+	if err != nil {
+		return nil, err
+	}
+	for key, values := range c.headers {
+		for _, value := range values {
+			req.Header.Add(key, value)
+		}
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	key := c.apiKey
+	if management {
+		key = c.managementKey
+	}
+	if key != "" {
+		req.Header.Set("Authorization", "Bearer "+key)
+	}
+	return req, nil
+}
+
+// send performs req and returns the response of a 2xx status; other statuses are read and
+// returned as an *APIError.
+func (c *Client) send(req *http.Request) (*http.Response, error) {
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		defer func() { _ = resp.Body.Close() }()
+		body, _ := io.ReadAll(resp.Body)
+		return nil, parseAPIError(resp.StatusCode, body)
+	}
+	return resp, nil
+}
+
+// do sends a request and decodes the JSON response into out, unless out is nil.
+func (c *Client) do(ctx context.Context, method, path string, body, out any, management bool) error {
+	req, err := c.newRequest(ctx, method, path, body, management)
+	if err != nil {
+		return err
+	}
+	resp, err := c.send(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if out == nil {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		return nil
+	}
+	if raw, ok := out.(*[]byte); ok {
+		*raw, err = io.ReadAll(resp.Body)
+		return err
+	}
+	if err = json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("cliproxy: decode response: %w", err)
+	}
+	return nil
+}
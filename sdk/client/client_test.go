@@ -0,0 +1,103 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func newTestClient(t *testing.T, handler http.HandlerFunc) *Client {
+	t.Helper()
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+	return New(srv.URL+"/", WithAPIKey("api-key"), WithManagementKey("mgmt-key"), WithHeader("X-CLIProxy-Priority", "high"))
+}
+
+func TestChatCompletionAndModels(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer api-key" || r.Header.Get("X-CLIProxy-Priority") != "high" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/v1/chat/completions":
+			_, _ = fmt.Fprint(w, `{"id":"c1","model":"gpt-5","choices":[{"index":0,"message":{"role":"assistant","content":"hi"},"finish_reason":"stop"}],"usage":{"total_tokens":3}}`)
+		case "/v1/models":
+			_, _ = fmt.Fprint(w, `{"object":"list","data":[{"id":"gpt-5","object":"model"}]}`)
+		}
+	})
+	resp, err := c.ChatCompletion(context.Background(), ChatRequest{Model: "gpt-5", Messages: []Message{{Role: "user", Content: "hello"}}})
+	if err != nil {
+		t.Fatalf("ChatCompletion: %v", err)
+	}
+	if resp.Text() != "hi" || resp.Usage == nil || resp.Usage.TotalTokens != 3 {
+		t.Fatalf("response = %+v", resp)
+	}
+	models, err := c.Models(context.Background())
+	if err != nil || len(models) != 1 || models[0].ID != "gpt-5" {
+		t.Fatalf("Models = %+v, %v", models, err)
+	}
+}
+
+func TestChatCompletionStream(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if !strings.Contains(r.Header.Get("Accept"), "text/event-stream") {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		_, _ = fmt.Fprint(w, "data: {\"choices\":[{\"delta\":{\"content\":\"he\"}}]}\n\n: keep-alive\n\ndata: {\"choices\":[{\"delta\":{\"content\":\"llo\"}}]}\n\ndata: [DONE]\n\n")
+	})
+	stream, err := c.ChatCompletionStream(context.Background(), ChatRequest{Model: "gpt-5"})
+	if err != nil {
+		t.Fatalf("ChatCompletionStream: %v", err)
+	}
+	defer func() { _ = stream.Close() }()
+	var text strings.Builder
+	for stream.Next() {
+		text.WriteString(stream.Chunk().Choices[0].Delta.Content)
+	}
+	if stream.Err() != nil || text.String() != "hello" {
+		t.Fatalf("streamed %q, err %v", text.String(), stream.Err())
+	}
+}
+
+func TestErrorsAndManagement(t *testing.T) {
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/v1/chat/completions":
+			w.WriteHeader(http.StatusTooManyRequests)
+			_, _ = fmt.Fprint(w, `{"error":{"message":"slow down","type":"rate_limit_error","code":"rate_limited"}}`)
+		case r.Header.Get("Authorization") != "Bearer mgmt-key":
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = fmt.Fprint(w, `{"error":"invalid management key"}`)
+		case r.URL.Path == "/v0/management/feature-flags/semantic-cache" && r.Method == http.MethodPut:
+			_, _ = fmt.Fprint(w, `{"name":"semantic-cache","enabled":false,"source":"runtime"}`)
+		case r.URL.Path == "/v0/management/auth-files" && r.URL.Query().Get("provider") == "codex":
+			_, _ = fmt.Fprint(w, `{"files":[{"id":"a1","name":"codex.json","provider":"codex","status":"active"}]}`)
+		}
+	})
+
+	_, err := c.ChatCompletion(context.Background(), ChatRequest{Model: "gpt-5"})
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusTooManyRequests || apiErr.Code != "rate_limited" || apiErr.Message != "slow down" {
+		t.Fatalf("error = %#v", err)
+	}
+
+	flag, err := c.SetFeatureFlag(context.Background(), "semantic-cache", false)
+	if err != nil || flag.Enabled || flag.Source != "runtime" {
+		t.Fatalf("SetFeatureFlag = %+v, %v", flag, err)
+	}
+	files, err := c.AuthFiles(context.Background(), "codex")
+	if err != nil || len(files) != 1 || files[0].Name != "codex.json" {
+		t.Fatalf("AuthFiles = %+v, %v", files, err)
+	}
+
+	unauthorized := New(c.baseURL, WithManagementKey("wrong"))
+	if _, err = unauthorized.Info(context.Background()); !errors.As(err, &apiErr) || apiErr.Message != "invalid management key" {
+		t.Fatalf("error = %#v", err)
+	}
+}
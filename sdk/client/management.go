@@ -0,0 +1,139 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+const managementPrefix = "/v0/management"
+
+// ProviderInfo counts the credentials of one provider.
+type ProviderInfo struct {
+	Credentials int `json:"credentials"`
+	Active      int `json:"active"`
+}
+
+// FeatureFlag is the state of one feature flag.
+type FeatureFlag struct {
+	Name    string `json:"name"`
+	Enabled bool   `json:"enabled"`
+	// Source is where the value comes from: "default", "config" or "runtime".
+	Source string `json:"source"`
+}
+
+// Info is the build and feature snapshot of the server.
+type Info struct {
+	Version      string                  `json:"version"`
+	Commit       string                  `json:"commit"`
+	BuildDate    string                  `json:"build_date"`
+	GoVersion    string                  `json:"go_version"`
+	Platform     string                  `json:"platform"`
+	Modules      []string                `json:"modules"`
+	Providers    map[string]ProviderInfo `json:"providers"`
+	Features     []string                `json:"features"`
+	FeatureFlags []FeatureFlag           `json:"feature_flags"`
+}
+
+// Info returns the build and feature snapshot of the server.
+func (c *Client) Info(ctx context.Context) (*Info, error) {
+	var info Info
+	if err := c.do(ctx, http.MethodGet, managementPrefix+"/info", nil, &info, true); err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
+
+// Usage returns the usage statistics snapshot as JSON.
+func (c *Client) Usage(ctx context.Context) (json.RawMessage, error) {
+	var raw []byte
+	if err := c.do(ctx, http.MethodGet, managementPrefix+"/usage", nil, &raw, true); err != nil {
+		return nil, err
+	}
+	return raw, nil
+}
+
+// APIKeys returns the API keys accepted by the proxy.
+func (c *Client) APIKeys(ctx context.Context) ([]string, error) {
+	var resp struct {
+		APIKeys []string `json:"api-keys"`
+	}
+	if err := c.do(ctx, http.MethodGet, managementPrefix+"/api-keys", nil, &resp, true); err != nil {
+		return nil, err
+	}
+	return resp.APIKeys, nil
+}
+
+// SetAPIKeys replaces the API keys accepted by the proxy.
+func (c *Client) SetAPIKeys(ctx context.Context, keys []string) error {
+	if keys == nil {
+		keys = []string{}
+	}
+	return c.do(ctx, http.MethodPut, managementPrefix+"/api-keys", keys, nil, true)
+}
+
+// AuthFile describes one credential known to the proxy.
+type AuthFile struct {
+	ID            string    `json:"id"`
+	Name          string    `json:"name"`
+	Provider      string    `json:"provider"`
+	Label         string    `json:"label,omitempty"`
+	Email         string    `json:"email,omitempty"`
+	Status        string    `json:"status"`
+	StatusMessage string    `json:"status_message,omitempty"`
+	Disabled      bool      `json:"disabled"`
+	Unavailable   bool      `json:"unavailable"`
+	RuntimeOnly   bool      `json:"runtime_only"`
+	Source        string    `json:"source"`
+	Path          string    `json:"path,omitempty"`
+	Size          int64     `json:"size"`
+	ModTime       time.Time `json:"modtime"`
+}
+
+// AuthFiles lists the credentials known to the proxy, optionally only those of provider.
+func (c *Client) AuthFiles(ctx context.Context, provider string) ([]AuthFile, error) {
+	path := managementPrefix + "/auth-files"
+	if provider != "" {
+		path += "?provider=" + url.QueryEscape(provider)
+	}
+	var resp struct {
+		Files []AuthFile `json:"files"`
+	}
+	if err := c.do(ctx, http.MethodGet, path, nil, &resp, true); err != nil {
+		return nil, err
+	}
+	return resp.Files, nil
+}
+
+// FeatureFlags lists the feature flags with their state.
+func (c *Client) FeatureFlags(ctx context.Context) ([]FeatureFlag, error) {
+	var resp struct {
+		FeatureFlags []FeatureFlag `json:"feature-flags"`
+	}
+	if err := c.do(ctx, http.MethodGet, managementPrefix+"/feature-flags", nil, &resp, true); err != nil {
+		return nil, err
+	}
+	return resp.FeatureFlags, nil
+}
+
+// SetFeatureFlag overrides the flag name at runtime until ResetFeatureFlag or a restart of
+// the proxy; unknown flags are rejected.
+func (c *Client) SetFeatureFlag(ctx context.Context, name string, enabled bool) (*FeatureFlag, error) {
+	var flag FeatureFlag
+	body := map[string]bool{"enabled": enabled}
+	if err := c.do(ctx, http.MethodPut, managementPrefix+"/feature-flags/"+url.PathEscape(name), body, &flag, true); err != nil {
+		return nil, err
+	}
+	return &flag, nil
+}
+
+// ResetFeatureFlag drops the runtime override of the flag name.
+func (c *Client) ResetFeatureFlag(ctx context.Context, name string) (*FeatureFlag, error) {
+	var flag FeatureFlag
+	if err := c.do(ctx, http.MethodDelete, managementPrefix+"/feature-flags/"+url.PathEscape(name), nil, &flag, true); err != nil {
+		return nil, err
+	}
+	return &flag, nil
+}
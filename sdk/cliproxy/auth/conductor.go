@@ -21,6 +21,8 @@ import (
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/thinking"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+	sdktranslator "github.com/router-for-me/CLIProxyAPI/v6/sdk/translator"
 	log "github.com/sirupsen/logrus"
 )
 
@@ -142,6 +144,24 @@ type Manager struct {
 	// Optional HTTP RoundTripper provider injected by host.
 	rtProvider RoundTripperProvider
 
+	// filters exclude credentials before selection; filterOrder keeps evaluation deterministic.
+	filters     map[string]AuthFilter
+	filterOrder []string
+
+	// executorWrapper decorates the executors of execution attempts.
+	executorWrapper ExecutorWrapper
+
+	// refreshLocker and observers coordinate state with other instances (optional).
+	refreshLocker RefreshLocker
+	observers     map[string]ResultObserver
+	observerOrder []string
+
+	// scheduler limits concurrent upstream requests per provider by priority class.
+	scheduler *slotScheduler
+
+	// reauthLocks serializes mid-stream token refreshes per auth ID.
+	reauthLocks sync.Map
+
 	// Auto refresh state
 	refreshCancel context.CancelFunc
 }
@@ -161,6 +181,7 @@ func NewManager(store Store, selector Selector, hook Hook) *Manager {
 		hook:            hook,
 		auths:           make(map[string]*Auth),
 		providerOffsets: make(map[string]int),
+		scheduler:       newSlotScheduler(),
 	}
 	// atomic.Value requires non-nil initial value.
 	manager.runtimeConfig.Store(&internalconfig.Config{})
@@ -562,6 +583,60 @@ func (m *Manager) ExecuteStream(ctx context.Context, providers []string, req cli
 	return nil, &Error{Code: "auth_not_found", Message: "no auth available"}
 }
 
+// ExecuteWithAuth performs a non-streaming execution on the auth with authID, bypassing the
+// selector, cooldowns and retries. The result is recorded like any other execution, so a
+// failure cools the auth down and a success clears earlier failures.
+func (m *Manager) ExecuteWithAuth(ctx context.Context, authID string, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+	auth, ok := m.GetByID(authID)
+	if !ok {
+		return cliproxyexecutor.Response{}, &Error{Code: "auth_not_found", Message: "auth not found"}
+	}
+	executor := m.executorFor(auth.Provider)
+	if executor == nil {
+		return cliproxyexecutor.Response{}, &Error{Code: "executor_not_found", Message: "executor not registered"}
+	}
+	routeModel := req.Model
+	opts = ensureRequestedModelMetadata(opts, routeModel)
+	execCtx := ctx
+	if rt := m.roundTripperFor(auth); rt != nil {
+		execCtx = context.WithValue(execCtx, roundTripperContextKey{}, rt)
+		execCtx = context.WithValue(execCtx, "cliproxy.roundtripper", rt)
+	}
+	execCtx = context.WithValue(execCtx, "cliproxy.model", routeModel)
+	execReq := req
+	execReq.Model = rewriteModelForAuth(routeModel, auth)
+	execReq.Model = m.applyOAuthModelAlias(auth, execReq.Model)
+	execReq.Model = m.applyAPIKeyModelAlias(auth, execReq.Model)
+	releaseSlot, errSlot := m.acquireSlots(execCtx, auth.Provider, auth, opts)
+	if errSlot != nil {
+		return cliproxyexecutor.Response{}, errSlot
+	}
+	resp, errExec := executor.Execute(execCtx, auth, execReq, opts)
+	releaseSlot()
+	result := Result{AuthID: auth.ID, Provider: auth.Provider, Model: routeModel, Success: errExec == nil}
+	if errExec != nil {
+		if errCtx := execCtx.Err(); errCtx != nil {
+			// A credential that did not answer before the deadline is recorded as failed;
+			// a caller that went away says nothing about it.
+			if errors.Is(errCtx, context.DeadlineExceeded) {
+				result.Error = &Error{Code: "timeout", Message: errCtx.Error(), HTTPStatus: http.StatusGatewayTimeout}
+				m.MarkResult(context.WithoutCancel(execCtx), result)
+			}
+			return cliproxyexecutor.Response{}, errCtx
+		}
+		result.Error = &Error{Message: errExec.Error()}
+		var se cliproxyexecutor.StatusError
+		if errors.As(errExec, &se) && se != nil {
+			result.Error.HTTPStatus = se.StatusCode()
+		}
+		if ra := retryAfterFromError(errExec); ra != nil {
+			result.RetryAfter = ra
+		}
+	}
+	m.MarkResult(execCtx, result)
+	return resp, errExec
+}
+
 func (m *Manager) executeMixedOnce(ctx context.Context, providers []string, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
 	if len(providers) == 0 {
 		return cliproxyexecutor.Response{}, &Error{Code: "provider_not_found", Message: "no provider supplied"}
@@ -581,6 +656,7 @@ func (m *Manager) executeMixedOnce(ctx context.Context, providers []string, req
 
 		entry := logEntryWithRequestID(ctx)
 		debugLogAuthSelection(entry, auth, provider, req.Model)
+		notifySelection(ctx, provider, auth)
 
 		tried[auth.ID] = struct{}{}
 		execCtx := ctx
@@ -588,11 +664,17 @@ func (m *Manager) executeMixedOnce(ctx context.Context, providers []string, req
 			execCtx = context.WithValue(execCtx, roundTripperContextKey{}, rt)
 			execCtx = context.WithValue(execCtx, "cliproxy.roundtripper", rt)
 		}
+		execCtx = context.WithValue(execCtx, "cliproxy.model", routeModel)
 		execReq := req
 		execReq.Model = rewriteModelForAuth(routeModel, auth)
 		execReq.Model = m.applyOAuthModelAlias(auth, execReq.Model)
 		execReq.Model = m.applyAPIKeyModelAlias(auth, execReq.Model)
+		releaseSlot, errSlot := m.acquireSlots(execCtx, provider, auth, opts)
+		if errSlot != nil {
+			return cliproxyexecutor.Response{}, errSlot
+		}
 		resp, errExec := executor.Execute(execCtx, auth, execReq, opts)
+		releaseSlot()
 		result := Result{AuthID: auth.ID, Provider: provider, Model: routeModel, Success: errExec == nil}
 		if errExec != nil {
 			if errCtx := execCtx.Err(); errCtx != nil {
@@ -634,6 +716,7 @@ func (m *Manager) executeCountMixedOnce(ctx context.Context, providers []string,
 
 		entry := logEntryWithRequestID(ctx)
 		debugLogAuthSelection(entry, auth, provider, req.Model)
+		notifySelection(ctx, provider, auth)
 
 		tried[auth.ID] = struct{}{}
 		execCtx := ctx
@@ -641,6 +724,7 @@ func (m *Manager) executeCountMixedOnce(ctx context.Context, providers []string,
 			execCtx = context.WithValue(execCtx, roundTripperContextKey{}, rt)
 			execCtx = context.WithValue(execCtx, "cliproxy.roundtripper", rt)
 		}
+		execCtx = context.WithValue(execCtx, "cliproxy.model", routeModel)
 		execReq := req
 		execReq.Model = rewriteModelForAuth(routeModel, auth)
 		execReq.Model = m.applyOAuthModelAlias(auth, execReq.Model)
@@ -687,6 +771,7 @@ func (m *Manager) executeStreamMixedOnce(ctx context.Context, providers []string
 
 		entry := logEntryWithRequestID(ctx)
 		debugLogAuthSelection(entry, auth, provider, req.Model)
+		notifySelection(ctx, provider, auth)
 
 		tried[auth.ID] = struct{}{}
 		execCtx := ctx
@@ -694,12 +779,37 @@ func (m *Manager) executeStreamMixedOnce(ctx context.Context, providers []string
 			execCtx = context.WithValue(execCtx, roundTripperContextKey{}, rt)
 			execCtx = context.WithValue(execCtx, "cliproxy.roundtripper", rt)
 		}
+		execCtx = context.WithValue(execCtx, "cliproxy.model", routeModel)
 		execReq := req
 		execReq.Model = rewriteModelForAuth(routeModel, auth)
 		execReq.Model = m.applyOAuthModelAlias(auth, execReq.Model)
 		execReq.Model = m.applyAPIKeyModelAlias(auth, execReq.Model)
-		chunks, errStream := executor.ExecuteStream(execCtx, auth, execReq, opts)
+		releaseSlot, errSlot := m.acquireSlots(execCtx, provider, auth, opts)
+		if errSlot != nil {
+			return nil, errSlot
+		}
+		timer := usage.NewStreamTimer(provider, execReq.Model)
+		execCtx = usage.WithFirstByte(execCtx)
+		var check *sdktranslator.StreamCheck
+		stopUpstream := context.CancelFunc(func() {})
+		if sdktranslator.StrictStreams() {
+			execCtx, check = sdktranslator.WithStreamCheck(execCtx)
+			execCtx, stopUpstream = context.WithCancel(execCtx)
+		}
+		chunks, errStream := executeStreamFor(execCtx, executor, auth, execReq, opts)
+		// An OAuth token that expired since selection is refreshed and the stream reopened
+		// once on the same credential rather than rejected.
+		reauthed := false
+		if errStream != nil && execCtx.Err() == nil && isAuthExpiredError(errStream) {
+			if refreshed, ok := m.refreshForRetry(execCtx, auth, time.Now()); ok {
+				reauthed = true
+				auth = refreshed
+				chunks, errStream = executeStreamFor(execCtx, executor, auth, execReq, opts)
+			}
+		}
 		if errStream != nil {
+			releaseSlot()
+			stopUpstream()
 			if errCtx := execCtx.Err(); errCtx != nil {
 				return nil, errCtx
 			}
@@ -717,11 +827,40 @@ func (m *Manager) executeStreamMixedOnce(ctx context.Context, providers []string
 		out := make(chan cliproxyexecutor.StreamChunk)
 		go func(streamCtx context.Context, streamAuth *Auth, streamProvider string, streamChunks <-chan cliproxyexecutor.StreamChunk) {
 			defer close(out)
-			var failed bool
+			defer releaseSlot()
+			defer stopUpstream()
+			var failed, emitted, formatFailed bool
 			forward := true
-			for chunk := range streamChunks {
+			for {
+				chunk, more := <-streamChunks
+				if !more {
+					break
+				}
+				if chunk.Err == nil && len(chunk.Payload) > 0 {
+					timer.Chunk(time.Now())
+				}
+				// The token expired before any content reached the client: refresh it and
+				// replay the request transparently instead of surfacing the 401.
+				if chunk.Err != nil && !failed && !emitted && !reauthed && streamCtx != nil && streamCtx.Err() == nil && isAuthExpiredError(chunk.Err) {
+					reauthed = true
+					if refreshed, ok := m.refreshForRetry(streamCtx, streamAuth, time.Now()); ok {
+						retried, errRetry := executeStreamFor(streamCtx, executor, refreshed, execReq, opts)
+						if errRetry == nil {
+							drainStream(streamChunks)
+							streamAuth, streamChunks = refreshed, retried
+							continue
+						}
+						chunk.Err = errRetry
+					}
+				}
 				if chunk.Err != nil && !failed {
 					failed = true
+					// A client that went away aborts the upstream read; that says nothing
+					// about the credential, so it is neither cooled down nor counted.
+					if streamCtx != nil && streamCtx.Err() != nil {
+						continue
+					}
+					timer.Fail(time.Now())
 					rerr := &Error{Message: chunk.Err.Error()}
 					var se cliproxyexecutor.StatusError
 					if errors.As(chunk.Err, &se) && se != nil {
@@ -732,19 +871,37 @@ func (m *Manager) executeStreamMixedOnce(ctx context.Context, providers []string
 				if !forward {
 					continue
 				}
+				if errFormat := check.Err(); errFormat != nil && chunk.Err == nil {
+					// Strict stream parsing: an unrecognized upstream chunk ends the stream
+					// for the client without counting against the credential.
+					chunk = cliproxyexecutor.StreamChunk{Err: &Error{Code: "stream_format", Message: errFormat.Error(), HTTPStatus: http.StatusBadGateway}}
+					forward, formatFailed = false, true
+				}
 				if streamCtx == nil {
 					out <- chunk
+					timer.Ready(time.Now())
+					emitted = emitted || len(chunk.Payload) > 0
 					continue
 				}
 				select {
 				case <-streamCtx.Done():
 					forward = false
 				case out <- chunk:
+					timer.Ready(time.Now())
+					emitted = emitted || len(chunk.Payload) > 0
+				}
+				if formatFailed {
+					// The client got the format error; stop reading the rest of the upstream
+					// response instead of draining it.
+					stopUpstream()
+					drainStream(streamChunks)
+					break
 				}
 			}
 			if !failed {
 				m.MarkResult(streamCtx, Result{AuthID: streamAuth.ID, Provider: streamProvider, Model: routeModel, Success: true})
 			}
+			timer.Finish(streamCtx)
 		}(execCtx, auth.Clone(), provider, chunks)
 		return out, nil
 	}
@@ -1142,6 +1299,8 @@ func (m *Manager) MarkResult(ctx context.Context, result Result) {
 	suspendReason := ""
 	clearModelQuota := false
 	setModelQuota := false
+	var observers []ResultObserver
+	var observed *Auth
 
 	m.mu.Lock()
 	if auth, ok := m.auths[result.AuthID]; ok && auth != nil {
@@ -1204,6 +1363,7 @@ func (m *Manager) MarkResult(ctx context.Context, result Result) {
 							next = now.Add(cooldown)
 						}
 						backoffLevel = nextLevel
+						next = alignQuotaCooldown(m.quotaResetSchedule(auth), next, now)
 					}
 					state.NextRetryAfter = next
 					state.Quota = QuotaState{
@@ -1230,11 +1390,14 @@ func (m *Manager) MarkResult(ctx context.Context, result Result) {
 				auth.UpdatedAt = now
 				updateAggregatedAvailability(auth, now)
 			} else {
-				applyAuthFailureState(auth, result.Error, result.RetryAfter, now)
+				applyAuthFailureState(auth, result.Error, result.RetryAfter, now, m.quotaResetSchedule(auth))
 			}
 		}
 
 		_ = m.persist(ctx, auth)
+		if observers = m.resultObserversLocked(); len(observers) > 0 {
+			observed = auth.Clone()
+		}
 	}
 	m.mu.Unlock()
 
@@ -1251,6 +1414,9 @@ func (m *Manager) MarkResult(ctx context.Context, result Result) {
 	}
 
 	m.hook.OnResult(ctx, result)
+	for _, observer := range observers {
+		observer(ctx, result, observed)
+	}
 }
 
 func ensureModelState(auth *Auth, model string) *ModelState {
@@ -1430,7 +1596,7 @@ func statusCodeFromResult(err *Error) int {
 	return err.StatusCode()
 }
 
-func applyAuthFailureState(auth *Auth, resultErr *Error, retryAfter *time.Duration, now time.Time) {
+func applyAuthFailureState(auth *Auth, resultErr *Error, retryAfter *time.Duration, now time.Time, schedule *internalconfig.QuotaResetSchedule) {
 	if auth == nil {
 		return
 	}
@@ -1467,6 +1633,7 @@ func applyAuthFailureState(auth *Auth, resultErr *Error, retryAfter *time.Durati
 				next = now.Add(cooldown)
 			}
 			auth.Quota.BackoffLevel = nextLevel
+			next = alignQuotaCooldown(schedule, next, now)
 		}
 		auth.Quota.NextRecoverAt = next
 		auth.NextRetryAfter = next
@@ -1484,6 +1651,36 @@ func applyAuthFailureState(auth *Auth, resultErr *Error, retryAfter *time.Durati
 	}
 }
 
+// quotaResetSchedule returns the quota reset schedule configured for auth, or nil.
+func (m *Manager) quotaResetSchedule(auth *Auth) *internalconfig.QuotaResetSchedule {
+	if m == nil || auth == nil {
+		return nil
+	}
+	cfg, _ := m.runtimeConfig.Load().(*internalconfig.Config)
+	if cfg == nil {
+		return nil
+	}
+	schedule, ok := cfg.QuotaExceeded.ResetScheduleFor(auth.Provider, auth.ID)
+	if !ok {
+		return nil
+	}
+	return &schedule
+}
+
+// alignQuotaCooldown ends a quota backoff at the credential's next quota reset, or
+// extends it to the reset when the schedule asks to cool down until then. A zero next
+// (cooling disabled) is kept.
+func alignQuotaCooldown(schedule *internalconfig.QuotaResetSchedule, next, now time.Time) time.Time {
+	if schedule == nil || next.IsZero() {
+		return next
+	}
+	reset := schedule.NextReset(now)
+	if schedule.CooldownUntilReset || next.After(reset) {
+		return reset
+	}
+	return next
+}
+
 // nextQuotaCooldown returns the next cooldown duration and updated backoff level for repeated quota errors.
 func nextQuotaCooldown(prevLevel int, disableCooling bool) (time.Duration, int) {
 	if prevLevel < 0 {
@@ -1535,6 +1732,7 @@ func (m *Manager) pickNext(ctx context.Context, provider, model string, opts cli
 		m.mu.RUnlock()
 		return nil, nil, &Error{Code: "executor_not_found", Message: "executor not registered"}
 	}
+	executor = m.wrapExecutorLocked(provider, executor)
 	candidates := make([]*Auth, 0, len(m.auths))
 	modelKey := strings.TrimSpace(model)
 	// Always use base model name (without thinking suffix) for auth matching.
@@ -1545,6 +1743,7 @@ func (m *Manager) pickNext(ctx context.Context, provider, model string, opts cli
 		}
 	}
 	registryRef := registry.GetGlobalRegistry()
+	var filterErr error
 	for _, candidate := range m.auths {
 		if candidate.Provider != provider || candidate.Disabled {
 			continue
@@ -1555,10 +1754,17 @@ func (m *Manager) pickNext(ctx context.Context, provider, model string, opts cli
 		if modelKey != "" && registryRef != nil && !registryRef.ClientSupportsModel(candidate.ID, modelKey) {
 			continue
 		}
+		if errFilter := m.filterAuthLocked(ctx, candidate, modelKey); errFilter != nil {
+			filterErr = errFilter
+			continue
+		}
 		candidates = append(candidates, candidate)
 	}
 	if len(candidates) == 0 {
 		m.mu.RUnlock()
+		if filterErr != nil {
+			return nil, nil, filterErr
+		}
 		return nil, nil, &Error{Code: "auth_not_found", Message: "no auth available"}
 	}
 	selected, errPick := m.selector.Pick(ctx, provider, model, opts, candidates)
@@ -1607,6 +1813,7 @@ func (m *Manager) pickNextMixed(ctx context.Context, providers []string, model s
 		}
 	}
 	registryRef := registry.GetGlobalRegistry()
+	var filterErr error
 	for _, candidate := range m.auths {
 		if candidate == nil || candidate.Disabled {
 			continue
@@ -1627,10 +1834,17 @@ func (m *Manager) pickNextMixed(ctx context.Context, providers []string, model s
 		if modelKey != "" && registryRef != nil && !registryRef.ClientSupportsModel(candidate.ID, modelKey) {
 			continue
 		}
+		if errFilter := m.filterAuthLocked(ctx, candidate, modelKey); errFilter != nil {
+			filterErr = errFilter
+			continue
+		}
 		candidates = append(candidates, candidate)
 	}
 	if len(candidates) == 0 {
 		m.mu.RUnlock()
+		if filterErr != nil {
+			return nil, nil, "", filterErr
+		}
 		return nil, nil, "", &Error{Code: "auth_not_found", Message: "no auth available"}
 	}
 	selected, errPick := m.selector.Pick(ctx, "mixed", model, opts, candidates)
@@ -1648,6 +1862,7 @@ func (m *Manager) pickNextMixed(ctx context.Context, providers []string, model s
 		m.mu.RUnlock()
 		return nil, nil, "", &Error{Code: "executor_not_found", Message: "executor not registered"}
 	}
+	executor = m.wrapExecutorLocked(providerKey, executor)
 	authCopy := selected.Clone()
 	m.mu.RUnlock()
 	if !selected.indexAssigned {
@@ -1729,6 +1944,9 @@ func (m *Manager) checkRefreshes(ctx context.Context) {
 			if !m.shouldRefresh(a, now) {
 				continue
 			}
+			if m.refreshFiltered(a) {
+				continue
+			}
 			log.Debugf("checking refresh for %s, %s, %s", a.Provider, a.ID, typ)
 
 			if exec := m.executorFor(a.Provider); exec == nil {
@@ -1982,10 +2200,24 @@ func (m *Manager) refreshAuth(ctx context.Context, id string) {
 	if auth != nil {
 		exec = m.executors[auth.Provider]
 	}
+	locker := m.refreshLocker
 	m.mu.RUnlock()
 	if auth == nil || exec == nil {
 		return
 	}
+	if locker != nil {
+		release, ok := locker.TryLockRefresh(ctx, id)
+		if !ok {
+			log.Debugf("refresh of %s, %s skipped: held by another instance", auth.Provider, auth.ID)
+			m.mu.Lock()
+			if current := m.auths[id]; current != nil {
+				current.NextRefreshAfter = time.Now().Add(refreshPendingBackoff)
+			}
+			m.mu.Unlock()
+			return
+		}
+		defer release()
+	}
 	cloned := auth.Clone()
 	updated, err := exec.Refresh(ctx, cloned)
 	if err != nil && errors.Is(err, context.Canceled) {
@@ -4,6 +4,8 @@ import (
 	"context"
 	"testing"
 	"time"
+
+	internalconfig "github.com/router-for-me/CLIProxyAPI/v6/internal/config"
 )
 
 func TestManager_ShouldRetryAfterError_RespectsAuthRequestRetryOverride(t *testing.T) {
@@ -95,3 +97,39 @@ func TestManager_MarkResult_RespectsAuthDisableCoolingOverride(t *testing.T) {
 		t.Fatalf("expected NextRetryAfter to be zero when disable_cooling=true, got %v", state.NextRetryAfter)
 	}
 }
+
+func TestManager_MarkResult_CoolsDownUntilQuotaReset(t *testing.T) {
+	prev := quotaCooldownDisabled.Load()
+	quotaCooldownDisabled.Store(false)
+	t.Cleanup(func() { quotaCooldownDisabled.Store(prev) })
+
+	m := NewManager(nil, nil, nil)
+	m.SetConfig(&internalconfig.Config{QuotaExceeded: internalconfig.QuotaExceeded{ResetSchedules: []internalconfig.QuotaResetSchedule{
+		{Provider: "gemini-cli", Period: internalconfig.QuotaResetDaily, Timezone: "America/Los_Angeles", CooldownUntilReset: true},
+	}}})
+	if _, errRegister := m.Register(context.Background(), &Auth{ID: "auth-1", Provider: "gemini-cli"}); errRegister != nil {
+		t.Fatalf("register auth: %v", errRegister)
+	}
+
+	model := "gemini-2.5-pro"
+	before := time.Now()
+	m.MarkResult(context.Background(), Result{
+		AuthID:   "auth-1",
+		Provider: "gemini-cli",
+		Model:    model,
+		Success:  false,
+		Error:    &Error{HTTPStatus: 429, Message: "quota"},
+	})
+
+	updated, _ := m.GetByID("auth-1")
+	state := updated.ModelStates[model]
+	if state == nil {
+		t.Fatalf("expected model state to be present")
+	}
+	loc, _ := time.LoadLocation("America/Los_Angeles")
+	local := before.In(loc)
+	want := time.Date(local.Year(), local.Month(), local.Day()+1, 0, 0, 0, 0, loc)
+	if !state.Quota.NextRecoverAt.Equal(want) {
+		t.Fatalf("NextRecoverAt = %v, want next Pacific midnight %v", state.Quota.NextRecoverAt, want)
+	}
+}
@@ -0,0 +1,70 @@
+package auth
+
+import (
+	"context"
+	"sort"
+	"strings"
+)
+
+// RefreshLocker coordinates credential refreshes between proxy instances sharing the same
+// credentials. TryLockRefresh returns ok=false when another instance holds the refresh lock;
+// otherwise the returned release function must be called once the refresh completes.
+type RefreshLocker interface {
+	TryLockRefresh(ctx context.Context, authID string) (release func(), ok bool)
+}
+
+// ResultObserver receives every recorded execution result together with a snapshot of the
+// auth after its state (cooldowns, quota) was updated. Observers run outside the manager lock.
+type ResultObserver func(ctx context.Context, result Result, auth *Auth)
+
+// SetRefreshLocker installs the locker consulted before refreshing a credential. Nil disables it.
+func (m *Manager) SetRefreshLocker(locker RefreshLocker) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	m.refreshLocker = locker
+	m.mu.Unlock()
+}
+
+// RegisterResultObserver installs (or replaces) a named result observer. A nil observer
+// removes the named entry.
+func (m *Manager) RegisterResultObserver(name string, observer ResultObserver) {
+	if m == nil {
+		return
+	}
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if observer == nil {
+		delete(m.observers, name)
+	} else {
+		if m.observers == nil {
+			m.observers = make(map[string]ResultObserver)
+		}
+		m.observers[name] = observer
+	}
+	names := make([]string, 0, len(m.observers))
+	for key := range m.observers {
+		names = append(names, key)
+	}
+	sort.Strings(names)
+	m.observerOrder = names
+}
+
+// resultObserversLocked returns the observers in evaluation order. Callers must hold m.mu.
+func (m *Manager) resultObserversLocked() []ResultObserver {
+	if len(m.observerOrder) == 0 {
+		return nil
+	}
+	out := make([]ResultObserver, 0, len(m.observerOrder))
+	for _, name := range m.observerOrder {
+		if observer := m.observers[name]; observer != nil {
+			out = append(out, observer)
+		}
+	}
+	return out
+}
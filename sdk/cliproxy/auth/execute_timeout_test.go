@@ -0,0 +1,61 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+)
+
+// hangingExecutor never answers before its context ends.
+type hangingExecutor struct{}
+
+func (hangingExecutor) Identifier() string { return "claude" }
+
+func (hangingExecutor) Execute(ctx context.Context, _ *Auth, _ cliproxyexecutor.Request, _ cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+	<-ctx.Done()
+	return cliproxyexecutor.Response{}, ctx.Err()
+}
+
+func (hangingExecutor) ExecuteStream(context.Context, *Auth, cliproxyexecutor.Request, cliproxyexecutor.Options) (<-chan cliproxyexecutor.StreamChunk, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (hangingExecutor) Refresh(_ context.Context, auth *Auth) (*Auth, error) { return auth, nil }
+
+func (hangingExecutor) CountTokens(context.Context, *Auth, cliproxyexecutor.Request, cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+	return cliproxyexecutor.Response{}, errors.New("not implemented")
+}
+
+func (hangingExecutor) HttpRequest(context.Context, *Auth, *http.Request) (*http.Response, error) {
+	return nil, errors.New("not implemented")
+}
+
+func TestExecuteWithAuthMarksTimedOutCredential(t *testing.T) {
+	m := NewManager(nil, nil, nil)
+	m.RegisterExecutor(hangingExecutor{})
+	for _, id := range []string{"hung", "abandoned"} {
+		if _, err := m.Register(context.Background(), &Auth{ID: id, Provider: "claude"}); err != nil {
+			t.Fatalf("register: %v", err)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := m.ExecuteWithAuth(ctx, "hung", cliproxyexecutor.Request{Model: "claude-haiku-4-5"}, cliproxyexecutor.Options{}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("err = %v, want deadline exceeded", err)
+	}
+	if auth, _ := m.GetByID("hung"); auth.Status != StatusError || auth.LastError == nil || auth.LastError.HTTPStatus != http.StatusGatewayTimeout {
+		t.Fatalf("timed out credential = %+v", auth)
+	}
+
+	canceled, cancelNow := context.WithCancel(context.Background())
+	cancelNow()
+	m.ExecuteWithAuth(canceled, "abandoned", cliproxyexecutor.Request{Model: "claude-haiku-4-5"}, cliproxyexecutor.Options{})
+	if auth, _ := m.GetByID("abandoned"); auth.Status == StatusError {
+		t.Fatalf("canceled request marked the credential: %+v", auth)
+	}
+}
@@ -0,0 +1,27 @@
+package auth
+
+// ExecutorWrapper decorates the executor of every execution attempt on provider, e.g. to
+// inject faults. It runs while the manager holds its read lock and must not call back
+// into the Manager.
+type ExecutorWrapper func(provider string, executor ProviderExecutor) ProviderExecutor
+
+// SetExecutorWrapper installs wrapper, replacing any previous one. Nil removes it.
+func (m *Manager) SetExecutorWrapper(wrapper ExecutorWrapper) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	m.executorWrapper = wrapper
+	m.mu.Unlock()
+}
+
+// wrapExecutorLocked applies the executor wrapper. Callers must hold m.mu.
+func (m *Manager) wrapExecutorLocked(provider string, executor ProviderExecutor) ProviderExecutor {
+	if m.executorWrapper == nil || executor == nil {
+		return executor
+	}
+	if wrapped := m.executorWrapper(provider, executor); wrapped != nil {
+		return wrapped
+	}
+	return executor
+}
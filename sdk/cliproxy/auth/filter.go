@@ -0,0 +1,123 @@
+package auth
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/thinking"
+)
+
+// AuthFilter excludes credentials from selection before the selector runs.
+// FilterAuth returns nil when the auth may serve the request, or an error describing
+// why it is excluded. When every candidate is excluded the last filter error is
+// returned to the caller instead of the generic "no auth available".
+//
+// Filters are invoked while the manager holds its read lock and must not call back
+// into the Manager.
+type AuthFilter interface {
+	FilterAuth(ctx context.Context, auth *Auth, model string) error
+}
+
+// RefreshFilter is implemented by AuthFilters that also hold back background token
+// refreshes. FilterRefresh returns an error when auth must not be refreshed now.
+type RefreshFilter interface {
+	FilterRefresh(auth *Auth) error
+}
+
+// AuthFilterFunc adapts a function to the AuthFilter interface.
+type AuthFilterFunc func(ctx context.Context, auth *Auth, model string) error
+
+// FilterAuth implements AuthFilter.
+func (f AuthFilterFunc) FilterAuth(ctx context.Context, auth *Auth, model string) error {
+	return f(ctx, auth, model)
+}
+
+// RegisterAuthFilter installs (or replaces) a named filter applied during credential selection.
+// Passing a nil filter removes the named entry.
+func (m *Manager) RegisterAuthFilter(name string, filter AuthFilter) {
+	if m == nil {
+		return
+	}
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if filter == nil {
+		delete(m.filters, name)
+	} else {
+		if m.filters == nil {
+			m.filters = make(map[string]AuthFilter)
+		}
+		m.filters[name] = filter
+	}
+	names := make([]string, 0, len(m.filters))
+	for key := range m.filters {
+		names = append(names, key)
+	}
+	sort.Strings(names)
+	m.filterOrder = names
+}
+
+// filterAuthLocked runs the registered filters against candidate. Callers must hold m.mu.
+func (m *Manager) filterAuthLocked(ctx context.Context, candidate *Auth, model string) error {
+	for _, name := range m.filterOrder {
+		filter := m.filters[name]
+		if filter == nil {
+			continue
+		}
+		if err := filter.FilterAuth(ctx, candidate, model); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// refreshFiltered reports whether a registered RefreshFilter holds back the refresh of auth.
+func (m *Manager) refreshFiltered(auth *Auth) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for _, name := range m.filterOrder {
+		if filter, ok := m.filters[name].(RefreshFilter); ok && filter.FilterRefresh(auth) != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// HasEligibleAuth reports whether at least one enabled credential of provider supports model
+// and passes every registered filter. Cooldown state is not considered.
+func (m *Manager) HasEligibleAuth(ctx context.Context, provider, model string) bool {
+	if m == nil {
+		return false
+	}
+	provider = strings.TrimSpace(strings.ToLower(provider))
+	modelKey := strings.TrimSpace(model)
+	if modelKey != "" {
+		if parsed := thinking.ParseSuffix(modelKey); parsed.ModelName != "" {
+			modelKey = strings.TrimSpace(parsed.ModelName)
+		}
+	}
+	registryRef := registry.GetGlobalRegistry()
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for _, candidate := range m.auths {
+		if candidate == nil || candidate.Disabled {
+			continue
+		}
+		if strings.TrimSpace(strings.ToLower(candidate.Provider)) != provider {
+			continue
+		}
+		if modelKey != "" && registryRef != nil && !registryRef.ClientSupportsModel(candidate.ID, modelKey) {
+			continue
+		}
+		if m.filterAuthLocked(ctx, candidate, modelKey) != nil {
+			continue
+		}
+		return true
+	}
+	return false
+}
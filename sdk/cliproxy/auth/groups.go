@@ -0,0 +1,54 @@
+package auth
+
+import (
+	"context"
+	"strings"
+
+	internalconfig "github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+)
+
+// GroupAttribute names the provider group a credential inherits its settings from.
+const GroupAttribute = "group"
+
+// AuthGroup returns the provider group of auth, or "".
+func AuthGroup(auth *Auth) string {
+	if auth == nil || auth.Attributes == nil {
+		return ""
+	}
+	return strings.TrimSpace(auth.Attributes[GroupAttribute])
+}
+
+// acquireSlots waits for a concurrency slot of provider and, for credentials of a provider
+// group with a concurrency cap, for a slot of the group. The returned release frees both.
+func (m *Manager) acquireSlots(ctx context.Context, provider string, auth *Auth, opts cliproxyexecutor.Options) (func(), error) {
+	releaseProvider, err := m.acquireProviderSlot(ctx, provider, opts)
+	if err != nil {
+		return nil, err
+	}
+	releaseGroup, err := m.acquireGroupSlot(ctx, auth, opts)
+	if err != nil {
+		releaseProvider()
+		return nil, err
+	}
+	return func() {
+		releaseGroup()
+		releaseProvider()
+	}, nil
+}
+
+// acquireGroupSlot waits for a concurrency slot of the provider group of auth using the
+// priority class in opts.Metadata.
+func (m *Manager) acquireGroupSlot(ctx context.Context, auth *Auth, opts cliproxyexecutor.Options) (func(), error) {
+	cfg, _ := m.runtimeConfig.Load().(*internalconfig.Config)
+	name := AuthGroup(auth)
+	if cfg == nil || m.scheduler == nil || name == "" {
+		return func() {}, nil
+	}
+	group, ok := cfg.ProviderGroupByName(name)
+	if !ok || group.MaxConcurrency <= 0 {
+		return func() {}, nil
+	}
+	priority, _ := opts.Metadata[cliproxyexecutor.PriorityMetadataKey].(string)
+	return m.scheduler.acquire(ctx, "group:"+group.Name, group.MaxConcurrency, priority)
+}
@@ -0,0 +1,143 @@
+package auth
+
+import (
+	"context"
+	"sync"
+
+	internalconfig "github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+)
+
+// priorityRank orders priority classes; higher ranks are served first.
+func priorityRank(priority string) int {
+	switch priority {
+	case internalconfig.PriorityHigh:
+		return 2
+	case internalconfig.PriorityLow:
+		return 0
+	default:
+		return 1
+	}
+}
+
+// slotWaiter is a request queued for a provider slot.
+type slotWaiter struct {
+	rank    int
+	seq     uint64
+	granted chan struct{}
+}
+
+// providerQueue tracks the in-flight requests and the waiters of one provider.
+type providerQueue struct {
+	active  int
+	waiters []*slotWaiter
+}
+
+// slotScheduler limits concurrent upstream requests per provider. When a provider is
+// saturated, freed slots go to the highest priority waiter, in arrival order within a class.
+type slotScheduler struct {
+	mu     sync.Mutex
+	seq    uint64
+	queues map[string]*providerQueue
+}
+
+func newSlotScheduler() *slotScheduler {
+	return &slotScheduler{queues: make(map[string]*providerQueue)}
+}
+
+// acquire blocks until provider has a free slot under limit or ctx is done. The returned
+// release must be called exactly once when the upstream request finishes.
+func (s *slotScheduler) acquire(ctx context.Context, provider string, limit int, priority string) (func(), error) {
+	if limit <= 0 {
+		return func() {}, nil
+	}
+	s.mu.Lock()
+	q := s.queues[provider]
+	if q == nil {
+		q = &providerQueue{}
+		s.queues[provider] = q
+	}
+	if q.active < limit && len(q.waiters) == 0 {
+		q.active++
+		s.mu.Unlock()
+		return s.releaseFunc(provider, limit), nil
+	}
+	s.seq++
+	w := &slotWaiter{rank: priorityRank(priority), seq: s.seq, granted: make(chan struct{})}
+	q.enqueue(w)
+	s.mu.Unlock()
+
+	select {
+	case <-w.granted:
+		return s.releaseFunc(provider, limit), nil
+	case <-ctx.Done():
+		s.mu.Lock()
+		removed := q.remove(w)
+		s.mu.Unlock()
+		if !removed {
+			// The slot was granted while the context ended; hand it on.
+			s.releaseFunc(provider, limit)()
+		}
+		return nil, ctx.Err()
+	}
+}
+
+func (s *slotScheduler) releaseFunc(provider string, limit int) func() {
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			q := s.queues[provider]
+			if q == nil {
+				return
+			}
+			q.active--
+			for q.active < limit && len(q.waiters) > 0 {
+				next := q.waiters[0]
+				q.waiters = q.waiters[1:]
+				q.active++
+				close(next.granted)
+			}
+		})
+	}
+}
+
+// enqueue inserts w after every waiter of the same or a higher rank.
+func (q *providerQueue) enqueue(w *slotWaiter) {
+	idx := len(q.waiters)
+	for i, existing := range q.waiters {
+		if existing.rank < w.rank {
+			idx = i
+			break
+		}
+	}
+	q.waiters = append(q.waiters, nil)
+	copy(q.waiters[idx+1:], q.waiters[idx:])
+	q.waiters[idx] = w
+}
+
+func (q *providerQueue) remove(w *slotWaiter) bool {
+	for i, existing := range q.waiters {
+		if existing == w {
+			q.waiters = append(q.waiters[:i], q.waiters[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// acquireProviderSlot waits for a concurrency slot of provider using the priority class in
+// opts.Metadata.
+func (m *Manager) acquireProviderSlot(ctx context.Context, provider string, opts cliproxyexecutor.Options) (func(), error) {
+	cfg, _ := m.runtimeConfig.Load().(*internalconfig.Config)
+	if cfg == nil || m.scheduler == nil {
+		return func() {}, nil
+	}
+	limit := cfg.Scheduling.ProviderConcurrency[provider]
+	if limit <= 0 {
+		return func() {}, nil
+	}
+	priority, _ := opts.Metadata[cliproxyexecutor.PriorityMetadataKey].(string)
+	return m.scheduler.acquire(ctx, provider, limit, priority)
+}
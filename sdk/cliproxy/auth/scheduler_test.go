@@ -0,0 +1,86 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	internalconfig "github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+func TestSlotSchedulerServesHigherPriorityFirst(t *testing.T) {
+	s := newSlotScheduler()
+	ctx := context.Background()
+	hold, err := s.acquire(ctx, "claude", 1, internalconfig.PriorityNormal)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	order := make(chan string, 3)
+	start := func(name, priority string) {
+		go func() {
+			release, errAcquire := s.acquire(ctx, "claude", 1, priority)
+			if errAcquire != nil {
+				t.Error(errAcquire)
+				return
+			}
+			order <- name
+			release()
+		}()
+		// Let the waiter enqueue before the next one arrives.
+		time.Sleep(20 * time.Millisecond)
+	}
+	start("batch", internalconfig.PriorityLow)
+	start("agent", internalconfig.PriorityNormal)
+	start("editor", internalconfig.PriorityHigh)
+
+	hold()
+	for _, want := range []string{"editor", "agent", "batch"} {
+		select {
+		case got := <-order:
+			if got != want {
+				t.Fatalf("served %s, want %s", got, want)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("timed out waiting for %s", want)
+		}
+	}
+}
+
+func TestSlotSchedulerCancelledWaiterLeavesQueue(t *testing.T) {
+	s := newSlotScheduler()
+	hold, _ := s.acquire(context.Background(), "codex", 1, "")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := s.acquire(ctx, "codex", 1, internalconfig.PriorityHigh); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("err = %v, want deadline exceeded", err)
+	}
+
+	hold()
+	release, err := s.acquire(context.Background(), "codex", 1, "")
+	if err != nil {
+		t.Fatalf("slot was not freed: %v", err)
+	}
+	release()
+	if q := s.queues["codex"]; q.active != 0 || len(q.waiters) != 0 {
+		t.Fatalf("queue = %+v, want empty", q)
+	}
+}
+
+func TestSchedulingPriorityFor(t *testing.T) {
+	cfg := internalconfig.SchedulingConfig{
+		APIKeyPriorities: map[string]string{"batch-key": "low"},
+		DefaultPriority:  internalconfig.PriorityNormal,
+	}
+	if got := cfg.PriorityFor("batch-key", "high"); got != internalconfig.PriorityLow {
+		t.Fatalf("key mapping = %q, want low", got)
+	}
+	if got := cfg.PriorityFor("editor-key", "interactive"); got != internalconfig.PriorityHigh {
+		t.Fatalf("header = %q, want high", got)
+	}
+	if got := cfg.PriorityFor("", "urgent"); got != internalconfig.PriorityNormal {
+		t.Fatalf("default = %q, want normal", got)
+	}
+}
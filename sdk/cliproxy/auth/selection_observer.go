@@ -0,0 +1,34 @@
+package auth
+
+import "context"
+
+type selectionObserverContextKey struct{}
+
+// SelectionObserver is told which credential the manager picked for a request, once per
+// attempt, so a retry on another credential reports again.
+type SelectionObserver func(provider string, auth *Auth)
+
+// WithSelectionObserver returns a derived context whose executions report their selected
+// credentials to observer. Observers installed on ctx earlier are still notified.
+func WithSelectionObserver(ctx context.Context, observer SelectionObserver) context.Context {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if outer, ok := ctx.Value(selectionObserverContextKey{}).(SelectionObserver); ok && outer != nil {
+		inner := observer
+		observer = func(provider string, auth *Auth) {
+			outer(provider, auth)
+			inner(provider, auth)
+		}
+	}
+	return context.WithValue(ctx, selectionObserverContextKey{}, observer)
+}
+
+func notifySelection(ctx context.Context, provider string, auth *Auth) {
+	if ctx == nil || auth == nil {
+		return
+	}
+	if observer, ok := ctx.Value(selectionObserverContextKey{}).(SelectionObserver); ok && observer != nil {
+		observer(provider, auth)
+	}
+}
@@ -0,0 +1,299 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// DisableStreamingAttribute marks credentials whose upstream must not be streamed from.
+// Streaming requests routed to them are executed without streaming and the response is
+// replayed as a synthesized stream in the client's format.
+const DisableStreamingAttribute = "disable_streaming"
+
+// synthesizedChunkRunes is the text length of each synthesized delta.
+const synthesizedChunkRunes = 64
+
+func streamingDisabled(auth *Auth) bool {
+	if auth == nil || auth.Attributes == nil {
+		return false
+	}
+	return strings.EqualFold(strings.TrimSpace(auth.Attributes[DisableStreamingAttribute]), "true")
+}
+
+// executeStreamFor streams from executor, or executes without streaming and synthesizes the
+// stream when the credential disables streaming.
+func executeStreamFor(ctx context.Context, executor ProviderExecutor, auth *Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (<-chan cliproxyexecutor.StreamChunk, error) {
+	if !streamingDisabled(auth) {
+		return executor.ExecuteStream(ctx, auth, req, opts)
+	}
+	req.Payload = disableStreamField(req.Payload)
+	opts.OriginalRequest = disableStreamField(opts.OriginalRequest)
+	opts.Stream = false
+	resp, err := executor.Execute(ctx, auth, req, opts)
+	if err != nil {
+		return nil, err
+	}
+	chunks := SynthesizeStream(opts.SourceFormat.String(), resp.Payload)
+	out := make(chan cliproxyexecutor.StreamChunk, len(chunks))
+	for _, chunk := range chunks {
+		out <- cliproxyexecutor.StreamChunk{Payload: chunk}
+	}
+	close(out)
+	return out, nil
+}
+
+func disableStreamField(payload []byte) []byte {
+	if !gjson.GetBytes(payload, "stream").Exists() {
+		return payload
+	}
+	if updated, err := sjson.SetBytes(payload, "stream", false); err == nil {
+		return updated
+	}
+	return payload
+}
+
+// SynthesizeStream converts a non-streaming response in the given client format ("openai",
+// "claude", "openai-response", ...) into the chunks a streaming response of that format
+// would have produced. Text is split into short deltas. Formats whose stream chunks share
+// the shape of the full response, such as Gemini, yield the response as a single chunk.
+func SynthesizeStream(format string, payload []byte) [][]byte {
+	if !gjson.ValidBytes(payload) {
+		return [][]byte{payload}
+	}
+	switch format {
+	case "openai":
+		return synthesizeOpenAIStream(payload)
+	case "claude":
+		return synthesizeClaudeStream(payload)
+	case "openai-response":
+		return synthesizeResponsesStream(payload)
+	default:
+		return [][]byte{payload}
+	}
+}
+
+// splitText splits text into pieces of at most synthesizedChunkRunes runes.
+func splitText(text string) []string {
+	var pieces []string
+	for len(text) > 0 {
+		end, runes := 0, 0
+		for end < len(text) && runes < synthesizedChunkRunes {
+			_, size := utf8.DecodeRuneInString(text[end:])
+			end += size
+			runes++
+		}
+		pieces = append(pieces, text[:end])
+		text = text[end:]
+	}
+	return pieces
+}
+
+func mustJSON(v any) []byte {
+	data, _ := json.Marshal(v)
+	return data
+}
+
+func rawOrNull(r gjson.Result) json.RawMessage {
+	if !r.Exists() {
+		return json.RawMessage("null")
+	}
+	return json.RawMessage(r.Raw)
+}
+
+func synthesizeOpenAIStream(payload []byte) [][]byte {
+	root := gjson.ParseBytes(payload)
+	base := func(choices any) map[string]any {
+		return map[string]any{
+			"id":      root.Get("id").String(),
+			"object":  "chat.completion.chunk",
+			"created": root.Get("created").Int(),
+			"model":   root.Get("model").String(),
+			"choices": choices,
+		}
+	}
+	chunk := func(index int64, delta map[string]any, finish gjson.Result) []byte {
+		choice := map[string]any{"index": index, "delta": delta, "finish_reason": rawOrNull(finish)}
+		return mustJSON(base([]any{choice}))
+	}
+
+	var out [][]byte
+	for _, choice := range root.Get("choices").Array() {
+		index := choice.Get("index").Int()
+		message := choice.Get("message")
+		role := message.Get("role").String()
+		if role == "" {
+			role = "assistant"
+		}
+		out = append(out, chunk(index, map[string]any{"role": role}, gjson.Result{}))
+		for _, piece := range splitText(message.Get("reasoning_content").String()) {
+			out = append(out, chunk(index, map[string]any{"reasoning_content": piece}, gjson.Result{}))
+		}
+		for _, piece := range splitText(message.Get("content").String()) {
+			out = append(out, chunk(index, map[string]any{"content": piece}, gjson.Result{}))
+		}
+		for i, call := range message.Get("tool_calls").Array() {
+			toolCall := map[string]any{
+				"index":    i,
+				"id":       call.Get("id").String(),
+				"type":     "function",
+				"function": map[string]any{"name": call.Get("function.name").String(), "arguments": call.Get("function.arguments").String()},
+			}
+			out = append(out, chunk(index, map[string]any{"tool_calls": []any{toolCall}}, gjson.Result{}))
+		}
+		out = append(out, chunk(index, map[string]any{}, choice.Get("finish_reason")))
+	}
+	if usage := root.Get("usage"); usage.Exists() {
+		final := base([]any{})
+		final["usage"] = json.RawMessage(usage.Raw)
+		out = append(out, mustJSON(final))
+	}
+	return out
+}
+
+func claudeEvent(name string, data any) []byte {
+	return []byte(fmt.Sprintf("event: %s\ndata: %s\n\n", name, mustJSON(data)))
+}
+
+func synthesizeClaudeStream(payload []byte) [][]byte {
+	root := gjson.ParseBytes(payload)
+	startUsage := map[string]any{"input_tokens": root.Get("usage.input_tokens").Int(), "output_tokens": 0}
+	for _, key := range []string{"cache_creation_input_tokens", "cache_read_input_tokens"} {
+		if v := root.Get("usage." + key); v.Exists() {
+			startUsage[key] = v.Int()
+		}
+	}
+	out := [][]byte{claudeEvent("message_start", map[string]any{
+		"type": "message_start",
+		"message": map[string]any{
+			"id":            root.Get("id").String(),
+			"type":          "message",
+			"role":          "assistant",
+			"model":         root.Get("model").String(),
+			"content":       []any{},
+			"stop_reason":   nil,
+			"stop_sequence": nil,
+			"usage":         startUsage,
+		},
+	})}
+
+	delta := func(index int, d map[string]any) []byte {
+		return claudeEvent("content_block_delta", map[string]any{"type": "content_block_delta", "index": index, "delta": d})
+	}
+	for index, block := range root.Get("content").Array() {
+		var start any
+		var deltas [][]byte
+		switch block.Get("type").String() {
+		case "text":
+			start = map[string]any{"type": "text", "text": ""}
+			for _, piece := range splitText(block.Get("text").String()) {
+				deltas = append(deltas, delta(index, map[string]any{"type": "text_delta", "text": piece}))
+			}
+		case "thinking":
+			start = map[string]any{"type": "thinking", "thinking": ""}
+			for _, piece := range splitText(block.Get("thinking").String()) {
+				deltas = append(deltas, delta(index, map[string]any{"type": "thinking_delta", "thinking": piece}))
+			}
+			if sig := block.Get("signature").String(); sig != "" {
+				deltas = append(deltas, delta(index, map[string]any{"type": "signature_delta", "signature": sig}))
+			}
+		case "tool_use":
+			start = map[string]any{"type": "tool_use", "id": block.Get("id").String(), "name": block.Get("name").String(), "input": map[string]any{}}
+			input := block.Get("input").Raw
+			if input == "" {
+				input = "{}"
+			}
+			deltas = append(deltas, delta(index, map[string]any{"type": "input_json_delta", "partial_json": input}))
+		default:
+			start = json.RawMessage(block.Raw)
+		}
+		out = append(out, claudeEvent("content_block_start", map[string]any{"type": "content_block_start", "index": index, "content_block": start}))
+		out = append(out, deltas...)
+		out = append(out, claudeEvent("content_block_stop", map[string]any{"type": "content_block_stop", "index": index}))
+	}
+
+	out = append(out, claudeEvent("message_delta", map[string]any{
+		"type":  "message_delta",
+		"delta": map[string]any{"stop_reason": rawOrNull(root.Get("stop_reason")), "stop_sequence": rawOrNull(root.Get("stop_sequence"))},
+		"usage": map[string]any{"output_tokens": root.Get("usage.output_tokens").Int()},
+	}))
+	out = append(out, claudeEvent("message_stop", map[string]any{"type": "message_stop"}))
+	return out
+}
+
+// responsesStream builds Responses API events, numbering them in order.
+type responsesStream struct {
+	events [][]byte
+	seq    int
+}
+
+func (s *responsesStream) emit(name string, fields map[string]any) {
+	fields["type"] = name
+	fields["sequence_number"] = s.seq
+	s.seq++
+	s.events = append(s.events, []byte(fmt.Sprintf("event: %s\ndata: %s", name, mustJSON(fields))))
+}
+
+func synthesizeResponsesStream(payload []byte) [][]byte {
+	root := gjson.ParseBytes(payload)
+	s := &responsesStream{}
+
+	inProgress, _ := sjson.SetBytes(payload, "status", "in_progress")
+	inProgress, _ = sjson.SetRawBytes(inProgress, "output", []byte("[]"))
+	inProgress, _ = sjson.DeleteBytes(inProgress, "usage")
+	s.emit("response.created", map[string]any{"response": json.RawMessage(inProgress)})
+	s.emit("response.in_progress", map[string]any{"response": json.RawMessage(inProgress)})
+
+	for outputIndex, item := range root.Get("output").Array() {
+		itemID := item.Get("id").String()
+		switch item.Get("type").String() {
+		case "message":
+			added, _ := sjson.SetBytes([]byte(item.Raw), "status", "in_progress")
+			added, _ = sjson.SetRawBytes(added, "content", []byte("[]"))
+			s.emit("response.output_item.added", map[string]any{"output_index": outputIndex, "item": json.RawMessage(added)})
+			for contentIndex, part := range item.Get("content").Array() {
+				text := part.Get("text").String()
+				emptyPart, _ := sjson.SetBytes([]byte(part.Raw), "text", "")
+				s.emit("response.content_part.added", map[string]any{"item_id": itemID, "output_index": outputIndex, "content_index": contentIndex, "part": json.RawMessage(emptyPart)})
+				if part.Get("type").String() == "output_text" {
+					for _, piece := range splitText(text) {
+						s.emit("response.output_text.delta", map[string]any{"item_id": itemID, "output_index": outputIndex, "content_index": contentIndex, "delta": piece})
+					}
+					s.emit("response.output_text.done", map[string]any{"item_id": itemID, "output_index": outputIndex, "content_index": contentIndex, "text": text})
+				}
+				s.emit("response.content_part.done", map[string]any{"item_id": itemID, "output_index": outputIndex, "content_index": contentIndex, "part": json.RawMessage(part.Raw)})
+			}
+		case "reasoning":
+			added, _ := sjson.SetRawBytes([]byte(item.Raw), "summary", []byte("[]"))
+			s.emit("response.output_item.added", map[string]any{"output_index": outputIndex, "item": json.RawMessage(added)})
+			for summaryIndex, summary := range item.Get("summary").Array() {
+				text := summary.Get("text").String()
+				s.emit("response.reasoning_summary_part.added", map[string]any{"item_id": itemID, "output_index": outputIndex, "summary_index": summaryIndex, "part": map[string]any{"type": "summary_text", "text": ""}})
+				for _, piece := range splitText(text) {
+					s.emit("response.reasoning_summary_text.delta", map[string]any{"item_id": itemID, "output_index": outputIndex, "summary_index": summaryIndex, "delta": piece})
+				}
+				s.emit("response.reasoning_summary_text.done", map[string]any{"item_id": itemID, "output_index": outputIndex, "summary_index": summaryIndex, "text": text})
+				s.emit("response.reasoning_summary_part.done", map[string]any{"item_id": itemID, "output_index": outputIndex, "summary_index": summaryIndex, "part": json.RawMessage(summary.Raw)})
+			}
+		case "function_call":
+			arguments := item.Get("arguments").String()
+			added, _ := sjson.SetBytes([]byte(item.Raw), "arguments", "")
+			added, _ = sjson.SetBytes(added, "status", "in_progress")
+			s.emit("response.output_item.added", map[string]any{"output_index": outputIndex, "item": json.RawMessage(added)})
+			s.emit("response.function_call_arguments.delta", map[string]any{"item_id": itemID, "output_index": outputIndex, "delta": arguments})
+			s.emit("response.function_call_arguments.done", map[string]any{"item_id": itemID, "output_index": outputIndex, "arguments": arguments})
+		default:
+			s.emit("response.output_item.added", map[string]any{"output_index": outputIndex, "item": json.RawMessage(item.Raw)})
+		}
+		s.emit("response.output_item.done", map[string]any{"output_index": outputIndex, "item": json.RawMessage(item.Raw)})
+	}
+
+	s.emit("response.completed", map[string]any{"response": json.RawMessage(payload)})
+	return s.events
+}
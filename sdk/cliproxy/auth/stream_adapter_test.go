@@ -0,0 +1,113 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	sdktranslator "github.com/router-for-me/CLIProxyAPI/v6/sdk/translator"
+	"github.com/tidwall/gjson"
+)
+
+// nonStreamExecutor records the non-streaming call made for credentials without streaming.
+type nonStreamExecutor struct {
+	payload []byte
+	stream  bool
+}
+
+func (e *nonStreamExecutor) Identifier() string { return "openai-compatibility" }
+
+func (e *nonStreamExecutor) Execute(_ context.Context, _ *Auth, req cliproxyexecutor.Request, opts cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+	e.payload, e.stream = req.Payload, opts.Stream
+	return cliproxyexecutor.Response{Payload: []byte(`{"id":"c1","created":1,"model":"m","choices":[{"index":0,"message":{"role":"assistant","content":"hello"},"finish_reason":"stop"}]}`)}, nil
+}
+
+func (e *nonStreamExecutor) ExecuteStream(context.Context, *Auth, cliproxyexecutor.Request, cliproxyexecutor.Options) (<-chan cliproxyexecutor.StreamChunk, error) {
+	return nil, errors.New("streaming must not be used")
+}
+
+func (e *nonStreamExecutor) Refresh(_ context.Context, auth *Auth) (*Auth, error) { return auth, nil }
+
+func (e *nonStreamExecutor) CountTokens(context.Context, *Auth, cliproxyexecutor.Request, cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+	return cliproxyexecutor.Response{}, errors.New("not implemented")
+}
+
+func (e *nonStreamExecutor) HttpRequest(context.Context, *Auth, *http.Request) (*http.Response, error) {
+	return nil, errors.New("not implemented")
+}
+
+func TestExecuteStreamForSynthesizesStreamWhenDisabled(t *testing.T) {
+	executor := &nonStreamExecutor{}
+	auth := &Auth{ID: "a", Attributes: map[string]string{DisableStreamingAttribute: "true"}}
+	chunks, err := executeStreamFor(context.Background(), executor, auth,
+		cliproxyexecutor.Request{Model: "m", Payload: []byte(`{"model":"m","stream":true}`)},
+		cliproxyexecutor.Options{Stream: true, SourceFormat: sdktranslator.FormatOpenAI})
+	if err != nil {
+		t.Fatalf("executeStreamFor: %v", err)
+	}
+	if executor.stream || gjson.GetBytes(executor.payload, "stream").Bool() {
+		t.Fatalf("upstream call streamed: opts.Stream=%v payload=%s", executor.stream, executor.payload)
+	}
+	var content strings.Builder
+	var finish string
+	for chunk := range chunks {
+		content.WriteString(gjson.GetBytes(chunk.Payload, "choices.0.delta.content").String())
+		if reason := gjson.GetBytes(chunk.Payload, "choices.0.finish_reason").String(); reason != "" {
+			finish = reason
+		}
+	}
+	if content.String() != "hello" || finish != "stop" {
+		t.Fatalf("content = %q finish = %q", content.String(), finish)
+	}
+}
+
+func TestSynthesizeClaudeStream(t *testing.T) {
+	long := strings.Repeat("ab", synthesizedChunkRunes)
+	payload := `{"id":"msg_1","type":"message","role":"assistant","model":"claude","content":[{"type":"text","text":"` + long + `"},{"type":"tool_use","id":"tu_1","name":"lookup","input":{"q":"x"}}],"stop_reason":"tool_use","stop_sequence":null,"usage":{"input_tokens":5,"output_tokens":7}}`
+	var events []string
+	var text, toolInput strings.Builder
+	for _, chunk := range SynthesizeStream("claude", []byte(payload)) {
+		lines := strings.SplitN(strings.TrimSpace(string(chunk)), "\n", 2)
+		events = append(events, strings.TrimPrefix(lines[0], "event: "))
+		data := gjson.Parse(strings.TrimPrefix(lines[1], "data: "))
+		text.WriteString(data.Get("delta.text").String())
+		toolInput.WriteString(data.Get("delta.partial_json").String())
+		if data.Get("type").String() == "message_delta" && (data.Get("delta.stop_reason").String() != "tool_use" || data.Get("usage.output_tokens").Int() != 7) {
+			t.Fatalf("message_delta = %s", data.Raw)
+		}
+	}
+	want := "message_start content_block_start content_block_delta content_block_delta content_block_stop " +
+		"content_block_start content_block_delta content_block_stop message_delta message_stop"
+	if strings.Join(events, " ") != want {
+		t.Fatalf("events = %v", events)
+	}
+	if text.String() != long || toolInput.String() != `{"q":"x"}` {
+		t.Fatalf("text = %q tool input = %q", text.String(), toolInput.String())
+	}
+}
+
+func TestSynthesizeResponsesStream(t *testing.T) {
+	payload := `{"id":"resp_1","object":"response","status":"completed","output":[{"id":"msg_1","type":"message","status":"completed","role":"assistant","content":[{"type":"output_text","text":"hi there","annotations":[]}]}],"usage":{"input_tokens":1,"output_tokens":2}}`
+	var names []string
+	var delta string
+	for i, chunk := range SynthesizeStream("openai-response", []byte(payload)) {
+		lines := strings.SplitN(string(chunk), "\n", 2)
+		data := gjson.Parse(strings.TrimPrefix(lines[1], "data: "))
+		if data.Get("sequence_number").Int() != int64(i) {
+			t.Fatalf("event %d sequence_number = %d", i, data.Get("sequence_number").Int())
+		}
+		names = append(names, data.Get("type").String())
+		if data.Get("type").String() == "response.output_text.delta" {
+			delta += data.Get("delta").String()
+		}
+		if data.Get("type").String() == "response.created" && data.Get("response.status").String() != "in_progress" {
+			t.Fatalf("response.created = %s", data.Raw)
+		}
+	}
+	if names[len(names)-1] != "response.completed" || delta != "hi there" {
+		t.Fatalf("events = %v delta = %q", names, delta)
+	}
+}
@@ -0,0 +1,66 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	log "github.com/sirupsen/logrus"
+)
+
+// isAuthExpiredError reports whether err is the upstream rejecting the credential, which
+// for OAuth tokens usually means the access token expired since it was selected.
+func isAuthExpiredError(err error) bool {
+	var se cliproxyexecutor.StatusError
+	return errors.As(err, &se) && se != nil && se.StatusCode() == http.StatusUnauthorized
+}
+
+// refreshableMidStream reports whether auth carries OAuth tokens the executor can refresh.
+func refreshableMidStream(auth *Auth) bool {
+	if auth == nil || len(auth.Metadata) == 0 {
+		return false
+	}
+	kind, _ := auth.AccountInfo()
+	return !strings.EqualFold(strings.TrimSpace(kind), "api_key")
+}
+
+// refreshForRetry refreshes the OAuth tokens of auth after the upstream rejected them and
+// returns the refreshed credential. Concurrent streams failing on the same credential share
+// one refresh: a caller finding the credential refreshed after failedAt reuses it.
+func (m *Manager) refreshForRetry(ctx context.Context, auth *Auth, failedAt time.Time) (*Auth, bool) {
+	if !refreshableMidStream(auth) {
+		return nil, false
+	}
+	lock, _ := m.reauthLocks.LoadOrStore(auth.ID, &sync.Mutex{})
+	mu := lock.(*sync.Mutex)
+	mu.Lock()
+	defer mu.Unlock()
+
+	current, ok := m.GetByID(auth.ID)
+	if !ok {
+		return nil, false
+	}
+	if current.LastRefreshedAt.After(failedAt) {
+		return current, true
+	}
+	m.refreshAuth(ctx, auth.ID)
+	refreshed, ok := m.GetByID(auth.ID)
+	if !ok || !refreshed.LastRefreshedAt.After(failedAt) {
+		log.Debugf("mid-stream refresh of %s, %s failed; surfacing the upstream error", auth.Provider, auth.ID)
+		return nil, false
+	}
+	log.Debugf("refreshed %s, %s after the upstream rejected its token; retrying the stream", auth.Provider, auth.ID)
+	return refreshed, true
+}
+
+// drainStream discards the rest of an abandoned upstream stream so its producer can exit.
+func drainStream(chunks <-chan cliproxyexecutor.StreamChunk) {
+	go func() {
+		for range chunks {
+		}
+	}()
+}
@@ -0,0 +1,125 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+)
+
+// expiringExecutor rejects the "stale" access token with a 401, at stream open or as the
+// first chunk, and streams normally once Refresh issued a fresh token.
+type expiringExecutor struct {
+	mu        sync.Mutex
+	atOpen    bool
+	afterData bool
+	refreshes int
+}
+
+func (e *expiringExecutor) Identifier() string { return "claude" }
+
+func (e *expiringExecutor) Execute(context.Context, *Auth, cliproxyexecutor.Request, cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+	return cliproxyexecutor.Response{}, errors.New("not implemented")
+}
+
+func (e *expiringExecutor) ExecuteStream(_ context.Context, auth *Auth, _ cliproxyexecutor.Request, _ cliproxyexecutor.Options) (<-chan cliproxyexecutor.StreamChunk, error) {
+	expired := &Error{Message: "token expired", HTTPStatus: http.StatusUnauthorized}
+	stale := auth.Metadata["access_token"] == "stale"
+	if stale && e.atOpen {
+		return nil, expired
+	}
+	out := make(chan cliproxyexecutor.StreamChunk, 2)
+	switch {
+	case stale && e.afterData:
+		out <- cliproxyexecutor.StreamChunk{Payload: []byte("data: partial\n\n")}
+		out <- cliproxyexecutor.StreamChunk{Err: expired}
+	case stale:
+		out <- cliproxyexecutor.StreamChunk{Err: expired}
+	default:
+		out <- cliproxyexecutor.StreamChunk{Payload: []byte("data: hello\n\n")}
+	}
+	close(out)
+	return out, nil
+}
+
+func (e *expiringExecutor) Refresh(_ context.Context, auth *Auth) (*Auth, error) {
+	e.mu.Lock()
+	e.refreshes++
+	e.mu.Unlock()
+	auth.Metadata["access_token"] = "fresh"
+	return auth, nil
+}
+
+func (e *expiringExecutor) CountTokens(context.Context, *Auth, cliproxyexecutor.Request, cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+	return cliproxyexecutor.Response{}, errors.New("not implemented")
+}
+
+func (e *expiringExecutor) HttpRequest(context.Context, *Auth, *http.Request) (*http.Response, error) {
+	return nil, errors.New("not implemented")
+}
+
+func TestExecuteStreamRefreshesExpiredOAuthToken(t *testing.T) {
+	cases := []struct {
+		name       string
+		executor   *expiringExecutor
+		attributes map[string]string
+		want       string
+		wantErr    bool
+		refreshes  int
+	}{
+		{name: "expired at open", executor: &expiringExecutor{atOpen: true}, want: "data: hello\n\n", refreshes: 1},
+		{name: "expired before content", executor: &expiringExecutor{}, want: "data: hello\n\n", refreshes: 1},
+		{name: "expired after content", executor: &expiringExecutor{afterData: true}, want: "data: partial\n\n", wantErr: true},
+		{name: "api key", executor: &expiringExecutor{}, attributes: map[string]string{"api_key": "k"}, wantErr: true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			m := NewManager(nil, nil, nil)
+			m.RegisterExecutor(tc.executor)
+			authID := "reauth-" + strings.ReplaceAll(tc.name, " ", "-")
+			auth := &Auth{ID: authID, Provider: "claude", Attributes: tc.attributes, Metadata: map[string]any{"access_token": "stale"}}
+			if tc.attributes == nil {
+				auth.Metadata["email"] = "user@example.com"
+			}
+			if _, err := m.Register(context.Background(), auth); err != nil {
+				t.Fatalf("register: %v", err)
+			}
+			registry.GetGlobalRegistry().RegisterClient(authID, "claude", []*registry.ModelInfo{{ID: "claude-sonnet-4"}})
+			defer registry.GetGlobalRegistry().UnregisterClient(authID)
+
+			chunks, err := m.ExecuteStream(context.Background(), []string{"claude"}, cliproxyexecutor.Request{Model: "claude-sonnet-4"}, cliproxyexecutor.Options{Stream: true})
+			var got strings.Builder
+			var streamErr error
+			if err == nil {
+				for chunk := range chunks {
+					if chunk.Err != nil {
+						streamErr = chunk.Err
+						continue
+					}
+					got.Write(chunk.Payload)
+				}
+			} else {
+				streamErr = err
+			}
+			if got.String() != tc.want {
+				t.Fatalf("payload = %q, want %q", got.String(), tc.want)
+			}
+			if (streamErr != nil) != tc.wantErr {
+				t.Fatalf("stream error = %v, want error %v", streamErr, tc.wantErr)
+			}
+			if tc.executor.refreshes != tc.refreshes {
+				t.Fatalf("refreshes = %d, want %d", tc.executor.refreshes, tc.refreshes)
+			}
+			if tc.refreshes > 0 {
+				if current, _ := m.GetByID(authID); current.Unavailable || current.LastError != nil {
+					t.Fatalf("refreshed credential marked failed: %+v", current)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,99 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+	sdktranslator "github.com/router-for-me/CLIProxyAPI/v6/sdk/translator"
+)
+
+// unknownEventExecutor streams an event type the translators do not know, then keeps
+// streaming until its context ends.
+type unknownEventExecutor struct {
+	translators *sdktranslator.Registry
+	stopped     chan struct{}
+}
+
+func (e *unknownEventExecutor) Identifier() string { return "claude" }
+
+func (e *unknownEventExecutor) Execute(context.Context, *Auth, cliproxyexecutor.Request, cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+	return cliproxyexecutor.Response{}, errors.New("not implemented")
+}
+
+func (e *unknownEventExecutor) ExecuteStream(ctx context.Context, _ *Auth, _ cliproxyexecutor.Request, _ cliproxyexecutor.Options) (<-chan cliproxyexecutor.StreamChunk, error) {
+	out := make(chan cliproxyexecutor.StreamChunk)
+	go func() {
+		defer close(out)
+		defer close(e.stopped)
+		for _, raw := range []string{`data: {"type":"message_start"}`, `data: {"type":"brand_new_event"}`} {
+			var param any
+			for _, line := range e.translators.TranslateStream(ctx, sdktranslator.FormatClaude, sdktranslator.FormatOpenAI, "m", nil, nil, []byte(raw), &param) {
+				out <- cliproxyexecutor.StreamChunk{Payload: []byte(line)}
+			}
+		}
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case out <- cliproxyexecutor.StreamChunk{Payload: []byte("data: more\n\n")}:
+			}
+		}
+	}()
+	return out, nil
+}
+
+func (e *unknownEventExecutor) Refresh(_ context.Context, auth *Auth) (*Auth, error) {
+	return auth, nil
+}
+
+func (e *unknownEventExecutor) CountTokens(context.Context, *Auth, cliproxyexecutor.Request, cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+	return cliproxyexecutor.Response{}, errors.New("not implemented")
+}
+
+func (e *unknownEventExecutor) HttpRequest(context.Context, *Auth, *http.Request) (*http.Response, error) {
+	return nil, errors.New("not implemented")
+}
+
+func TestStrictStreamStopsReadingUpstreamAfterFormatError(t *testing.T) {
+	sdktranslator.SetStrictStreams(true)
+	defer sdktranslator.SetStrictStreams(false)
+	translators := sdktranslator.NewRegistry()
+	translators.Register(sdktranslator.FormatOpenAI, sdktranslator.FormatClaude, nil, sdktranslator.ResponseTransform{
+		Stream: func(_ context.Context, _ string, _, _, raw []byte, _ *any) []string {
+			return []string{string(raw)}
+		},
+	})
+	executor := &unknownEventExecutor{translators: translators, stopped: make(chan struct{})}
+	m := NewManager(nil, nil, nil)
+	m.RegisterExecutor(executor)
+	auth := &Auth{ID: "strict-stream", Provider: "claude", Attributes: map[string]string{"api_key": "k"}}
+	if _, err := m.Register(context.Background(), auth); err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	registry.GetGlobalRegistry().RegisterClient(auth.ID, "claude", []*registry.ModelInfo{{ID: "claude-sonnet-4"}})
+	defer registry.GetGlobalRegistry().UnregisterClient(auth.ID)
+
+	chunks, err := m.ExecuteStream(context.Background(), []string{"claude"}, cliproxyexecutor.Request{Model: "claude-sonnet-4"}, cliproxyexecutor.Options{Stream: true})
+	if err != nil {
+		t.Fatalf("ExecuteStream: %v", err)
+	}
+	var formatErr *Error
+	for chunk := range chunks {
+		if chunk.Err != nil && !errors.As(chunk.Err, &formatErr) {
+			t.Fatalf("stream error = %v", chunk.Err)
+		}
+	}
+	if formatErr == nil || formatErr.Code != "stream_format" {
+		t.Fatalf("format error = %v", formatErr)
+	}
+	select {
+	case <-executor.stopped:
+	case <-time.After(2 * time.Second):
+		t.Fatal("upstream kept streaming after the format error")
+	}
+}
@@ -10,6 +10,10 @@ import (
 // RequestedModelMetadataKey stores the client-requested model name in Options.Metadata.
 const RequestedModelMetadataKey = "requested_model"
 
+// PriorityMetadataKey stores the scheduling priority class ("low", "normal", "high") in
+// Options.Metadata.
+const PriorityMetadataKey = "priority"
+
 // Request encapsulates the translated payload that will be sent to a provider executor.
 type Request struct {
 	// Model is the upstream model identifier after translation.
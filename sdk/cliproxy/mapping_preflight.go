@@ -0,0 +1,44 @@
+package cliproxy
+
+import (
+	"context"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/preflight"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+)
+
+// mappingPreflightDelay lets credentials load and register their models before mapping
+// targets are checked against them.
+const mappingPreflightDelay = 10 * time.Second
+
+// scheduleMappingPreflight verifies the model mappings of cfg in the background, cancelling
+// a check still pending for a previous config.
+func (s *Service) scheduleMappingPreflight(cfg *config.Config) {
+	if cfg == nil {
+		return
+	}
+	s.preflightMu.Lock()
+	defer s.preflightMu.Unlock()
+	if s.preflightCancel != nil {
+		s.preflightCancel()
+		s.preflightCancel = nil
+	}
+	if !cfg.MappingPreflight.Enabled {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	s.preflightCancel = cancel
+	go func() {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(mappingPreflightDelay):
+		}
+		var counter preflight.Counter
+		if cfg.MappingPreflight.Live && s.coreManager != nil {
+			counter = s.coreManager
+		}
+		preflight.Run(ctx, cfg, counter)
+	}()
+}
@@ -0,0 +1,142 @@
+package cliproxy
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	log "github.com/sirupsen/logrus"
+)
+
+// modelDiscoveryTimeout bounds a single upstream model list request.
+const modelDiscoveryTimeout = 15 * time.Second
+
+// modelFetcher queries a provider for the models available to auth.
+type modelFetcher func(ctx context.Context, auth *coreauth.Auth) []*ModelInfo
+
+// modelDiscoveryCache keeps the last model list each credential reported.
+type modelDiscoveryCache struct {
+	mu      sync.Mutex
+	entries map[string]*discoveredModels
+	nowFunc func() time.Time
+}
+
+type discoveredModels struct {
+	models     []*ModelInfo
+	fetchedAt  time.Time
+	refreshing bool
+}
+
+// discoverModels returns the models reported by the provider of a. The first call per
+// credential fetches synchronously; later calls serve the cached list and, once it is
+// older than the configured TTL, refresh it in the background and re-register the models.
+func (s *Service) discoverModels(a *coreauth.Auth, fetch modelFetcher) []*ModelInfo {
+	ttl := time.Duration(0)
+	if s.cfg != nil {
+		ttl = time.Duration(s.cfg.ModelDiscovery.CacheTTLSeconds) * time.Second
+	}
+	if ttl <= 0 {
+		s.modelCache.forget(a.ID)
+		return fetchModels(a, fetch)
+	}
+	models, stale, ok := s.modelCache.lookup(a.ID, ttl)
+	if !ok {
+		models = fetchModels(a, fetch)
+		s.modelCache.store(a.ID, models)
+		return models
+	}
+	if stale {
+		go s.refreshDiscoveredModels(a.ID, a.Clone(), fetch)
+	}
+	return models
+}
+
+// refreshDiscoveredModels refetches the models of one credential and re-registers them
+// when the credential is still active.
+func (s *Service) refreshDiscoveredModels(authID string, a *coreauth.Auth, fetch modelFetcher) {
+	models := fetchModels(a, fetch)
+	if len(models) == 0 {
+		log.Debugf("model discovery: refresh for %s returned no models, keeping the cached list", authID)
+	}
+	s.modelCache.store(authID, models)
+	if s.coreManager == nil {
+		return
+	}
+	if current, ok := s.coreManager.GetByID(authID); ok && current != nil && !current.Disabled {
+		s.registerModelsForAuth(current)
+	}
+}
+
+func fetchModels(a *coreauth.Auth, fetch modelFetcher) []*ModelInfo {
+	ctx, cancel := context.WithTimeout(context.Background(), modelDiscoveryTimeout)
+	defer cancel()
+	return fetch(ctx, a)
+}
+
+// lookup returns the cached models of authID and whether they are older than ttl. A stale
+// hit marks the entry as refreshing, so only the first caller starts a refresh.
+func (c *modelDiscoveryCache) lookup(authID string, ttl time.Duration) ([]*ModelInfo, bool, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[authID]
+	if !ok {
+		return nil, false, false
+	}
+	stale := !entry.refreshing && c.now().Sub(entry.fetchedAt) >= ttl
+	if stale {
+		entry.refreshing = true
+	}
+	return cloneModels(entry.models), stale, true
+}
+
+// store records a fetched list. Empty results, usually failed requests, keep the previous
+// list but still reset its age so a failing provider is not queried on every update.
+func (c *modelDiscoveryCache) store(authID string, models []*ModelInfo) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.entries == nil {
+		c.entries = make(map[string]*discoveredModels)
+	}
+	entry, ok := c.entries[authID]
+	if !ok {
+		if len(models) == 0 {
+			return
+		}
+		entry = &discoveredModels{}
+		c.entries[authID] = entry
+	}
+	if len(models) > 0 {
+		entry.models = cloneModels(models)
+	}
+	entry.fetchedAt = c.now()
+	entry.refreshing = false
+}
+
+func (c *modelDiscoveryCache) forget(authID string) {
+	c.mu.Lock()
+	delete(c.entries, authID)
+	c.mu.Unlock()
+}
+
+func (c *modelDiscoveryCache) now() time.Time {
+	if c.nowFunc != nil {
+		return c.nowFunc()
+	}
+	return time.Now()
+}
+
+func cloneModels(models []*ModelInfo) []*ModelInfo {
+	if len(models) == 0 {
+		return nil
+	}
+	out := make([]*ModelInfo, 0, len(models))
+	for _, model := range models {
+		if model == nil {
+			continue
+		}
+		copyModel := *model
+		out = append(out, &copyModel)
+	}
+	return out
+}
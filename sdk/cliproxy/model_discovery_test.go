@@ -0,0 +1,79 @@
+package cliproxy
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	coreauth "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/auth"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+)
+
+func TestDiscoverModels_ServesCacheAndRefreshesInBackground(t *testing.T) {
+	now := time.Date(2026, 1, 5, 10, 0, 0, 0, time.UTC)
+	s := &Service{cfg: &config.Config{}}
+	s.cfg.ModelDiscovery.CacheTTLSeconds = 600
+	s.modelCache.nowFunc = func() time.Time { return now }
+
+	calls := make(chan string, 4)
+	reply := "m1"
+	fetch := func(_ context.Context, a *coreauth.Auth) []*ModelInfo {
+		calls <- a.ID
+		if reply == "" {
+			return nil
+		}
+		return []*ModelInfo{{ID: reply}}
+	}
+	auth := &coreauth.Auth{ID: "ag-1", Provider: "antigravity"}
+
+	if models := s.discoverModels(auth, fetch); len(models) != 1 || models[0].ID != "m1" {
+		t.Fatalf("first call should fetch, got %+v", models)
+	}
+	<-calls
+	if models := s.discoverModels(auth, fetch); len(models) != 1 || models[0].ID != "m1" {
+		t.Fatalf("fresh cache should be served, got %+v", models)
+	}
+	select {
+	case <-calls:
+		t.Fatal("a fresh cache should not query the provider")
+	default:
+	}
+
+	now = now.Add(11 * time.Minute)
+	reply = "m2"
+	if models := s.discoverModels(auth, fetch); len(models) != 1 || models[0].ID != "m1" {
+		t.Fatalf("stale cache should be served while refreshing, got %+v", models)
+	}
+	select {
+	case <-calls:
+	case <-time.After(5 * time.Second):
+		t.Fatal("stale cache should refresh in the background")
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if models, _, _ := s.modelCache.lookup("ag-1", time.Hour); len(models) == 1 && models[0].ID == "m2" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("refreshed models were not stored")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestModelDiscoveryCache_KeepsListOnEmptyRefresh(t *testing.T) {
+	var cache modelDiscoveryCache
+	cache.store("a", nil)
+	if _, _, ok := cache.lookup("a", time.Minute); ok {
+		t.Fatal("empty first results should not be cached")
+	}
+	cache.store("a", []*ModelInfo{{ID: "m1"}})
+	cache.store("a", nil)
+	if models, stale, ok := cache.lookup("a", time.Minute); !ok || stale || len(models) != 1 {
+		t.Fatalf("failed refreshes should keep the previous list, got %+v stale=%v", models, stale)
+	}
+	cache.forget("a")
+	if _, _, ok := cache.lookup("a", time.Minute); ok {
+		t.Fatal("forgotten entries should be gone")
+	}
+}
@@ -15,6 +15,7 @@ import (
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/api"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/registry"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/runtime/executor"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/systemd"
 	_ "github.com/router-for-me/CLIProxyAPI/v6/internal/usage"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/watcher"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/wsrelay"
@@ -89,6 +90,15 @@ type Service struct {
 
 	// wsGateway manages websocket Gemini providers.
 	wsGateway *wsrelay.Manager
+
+	// modelCache keeps model lists discovered from providers.
+	modelCache modelDiscoveryCache
+
+	// preflightMu guards preflightCancel.
+	preflightMu sync.Mutex
+
+	// preflightCancel stops the pending model mapping preflight.
+	preflightCancel context.CancelFunc
 }
 
 // RegisterUsagePlugin registers a usage plugin on the global usage manager.
@@ -319,6 +329,7 @@ func (s *Service) applyCoreAuthRemoval(ctx context.Context, id string) {
 		return
 	}
 	GlobalModelRegistry().UnregisterClient(id)
+	s.modelCache.forget(id)
 	if existing, ok := s.coreManager.GetByID(id); ok && existing != nil {
 		existing.Disabled = true
 		existing.Status = coreauth.StatusDisabled
@@ -398,6 +409,8 @@ func (s *Service) ensureExecutorsForAuth(a *coreauth.Auth) {
 		s.coreManager.RegisterExecutor(executor.NewQwenExecutor(s.cfg))
 	case "iflow":
 		s.coreManager.RegisterExecutor(executor.NewIFlowExecutor(s.cfg))
+	case "mock":
+		s.coreManager.RegisterExecutor(executor.NewMockExecutor(s.cfg))
 	default:
 		providerKey := strings.ToLower(strings.TrimSpace(a.Provider))
 		if providerKey == "" {
@@ -517,7 +530,7 @@ func (s *Service) Run(ctx context.Context) error {
 	}()
 
 	time.Sleep(100 * time.Millisecond)
-	fmt.Printf("API server started successfully on: %s:%d\n", s.cfg.Host, s.cfg.Port)
+	fmt.Printf("API server started successfully on: %s\n", s.cfg.ListenAddress())
 
 	s.applyPprofConfig(s.cfg)
 
@@ -578,6 +591,7 @@ func (s *Service) Run(ctx context.Context) error {
 			s.coreManager.SetOAuthModelAlias(newCfg.OAuthModelAlias)
 		}
 		s.rebindExecutors()
+		s.scheduleMappingPreflight(newCfg)
 	}
 
 	watcherWrapper, err = s.watcherFactory(s.configPath, s.cfg.AuthDir, reloadCallback)
@@ -605,6 +619,10 @@ func (s *Service) Run(ctx context.Context) error {
 		log.Infof("core auth auto-refresh started (interval=%s)", interval)
 	}
 
+	s.scheduleMappingPreflight(s.cfg)
+	s.startWarmup(ctx)
+	s.notifySystemdReady(ctx)
+
 	select {
 	case <-ctx.Done():
 		log.Debug("service context cancelled, shutting down...")
@@ -614,6 +632,38 @@ func (s *Service) Run(ctx context.Context) error {
 	}
 }
 
+// notifySystemdReady tells systemd (Type=notify units) that the service is up and, when the
+// unit sets WatchdogSec=, keeps the watchdog fed until ctx is cancelled.
+func (s *Service) notifySystemdReady(ctx context.Context) {
+	sent, err := systemd.Notify("READY=1")
+	if err != nil {
+		log.Warnf("systemd readiness notification failed: %v", err)
+		return
+	}
+	if !sent {
+		return
+	}
+	log.Debug("notified systemd readiness")
+	interval := systemd.WatchdogInterval()
+	if interval <= 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if _, errNotify := systemd.Notify("WATCHDOG=1"); errNotify != nil {
+					log.Debugf("systemd watchdog notification failed: %v", errNotify)
+				}
+			}
+		}
+	}()
+}
+
 // Shutdown gracefully stops background workers and the HTTP server.
 // It ensures all resources are properly cleaned up and connections are closed.
 // The shutdown is idempotent and can be called multiple times safely.
@@ -633,6 +683,10 @@ func (s *Service) Shutdown(ctx context.Context) error {
 			ctx = context.Background()
 		}
 
+		if _, err := systemd.Notify("STOPPING=1"); err != nil {
+			log.Debugf("systemd notify failed: %v", err)
+		}
+
 		// legacy refresh loop removed; only stopping core auth manager below
 
 		if s.watcherCancel != nil {
@@ -747,7 +801,7 @@ func (s *Service) registerModelsForAuth(a *coreauth.Auth) {
 				models = buildGeminiConfigModels(entry)
 			}
 			if authKind == "apikey" {
-				excluded = entry.ExcludedModels
+				excluded = s.cfg.GroupExcludedModels(entry.Group, entry.ExcludedModels)
 			}
 		}
 		models = applyExcludedModels(models, excluded)
@@ -755,8 +809,11 @@ func (s *Service) registerModelsForAuth(a *coreauth.Auth) {
 		// Vertex AI Gemini supports the same model identifiers as Gemini.
 		models = registry.GetGeminiVertexModels()
 		if authKind == "apikey" {
-			if entry := s.resolveConfigVertexCompatKey(a); entry != nil && len(entry.Models) > 0 {
-				models = buildVertexCompatConfigModels(entry)
+			if entry := s.resolveConfigVertexCompatKey(a); entry != nil {
+				if len(entry.Models) > 0 {
+					models = buildVertexCompatConfigModels(entry)
+				}
+				excluded = s.cfg.GroupExcludedModels(entry.Group, nil)
 			}
 		}
 		models = applyExcludedModels(models, excluded)
@@ -767,9 +824,9 @@ func (s *Service) registerModelsForAuth(a *coreauth.Auth) {
 		models = registry.GetAIStudioModels()
 		models = applyExcludedModels(models, excluded)
 	case "antigravity":
-		ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
-		models = executor.FetchAntigravityModels(ctx, a, s.cfg)
-		cancel()
+		models = s.discoverModels(a, func(ctx context.Context, auth *coreauth.Auth) []*ModelInfo {
+			return executor.FetchAntigravityModels(ctx, auth, s.cfg)
+		})
 		models = applyExcludedModels(models, excluded)
 	case "claude":
 		models = registry.GetClaudeModels()
@@ -778,7 +835,7 @@ func (s *Service) registerModelsForAuth(a *coreauth.Auth) {
 				models = buildClaudeConfigModels(entry)
 			}
 			if authKind == "apikey" {
-				excluded = entry.ExcludedModels
+				excluded = s.cfg.GroupExcludedModels(entry.Group, entry.ExcludedModels)
 			}
 		}
 		models = applyExcludedModels(models, excluded)
@@ -789,7 +846,7 @@ func (s *Service) registerModelsForAuth(a *coreauth.Auth) {
 				models = buildCodexConfigModels(entry)
 			}
 			if authKind == "apikey" {
-				excluded = entry.ExcludedModels
+				excluded = s.cfg.GroupExcludedModels(entry.Group, entry.ExcludedModels)
 			}
 		}
 		models = applyExcludedModels(models, excluded)
@@ -799,6 +856,8 @@ func (s *Service) registerModelsForAuth(a *coreauth.Auth) {
 	case "iflow":
 		models = registry.GetIFlowModels()
 		models = applyExcludedModels(models, excluded)
+	case "mock":
+		models = buildMockModels(a)
 	default:
 		// Handle OpenAI-compatibility providers by name using config
 		if s.cfg != nil {
@@ -861,6 +920,7 @@ func (s *Service) registerModelsForAuth(a *coreauth.Auth) {
 							UserDefined: true,
 						})
 					}
+					ms = applyExcludedModels(ms, s.cfg.GroupExcludedModels(compat.Group, nil))
 					// Register and return
 					if len(ms) > 0 {
 						if providerKey == "" {
@@ -1217,6 +1277,30 @@ func buildGeminiConfigModels(entry *config.GeminiKey) []*ModelInfo {
 	return buildConfigModels(entry.Models, "google", "gemini")
 }
 
+// buildMockModels lists the models a mock provider auth was synthesized with.
+func buildMockModels(auth *coreauth.Auth) []*ModelInfo {
+	if auth == nil || auth.Attributes == nil {
+		return nil
+	}
+	now := time.Now().Unix()
+	var out []*ModelInfo
+	for _, id := range strings.Split(auth.Attributes["mock_models"], ",") {
+		if id = strings.TrimSpace(id); id == "" {
+			continue
+		}
+		out = append(out, &ModelInfo{
+			ID:          id,
+			Object:      "model",
+			Created:     now,
+			OwnedBy:     auth.Label,
+			Type:        "mock",
+			DisplayName: id,
+			UserDefined: true,
+		})
+	}
+	return out
+}
+
 func buildClaudeConfigModels(entry *config.ClaudeKey) []*ModelInfo {
 	if entry == nil {
 		return nil
@@ -17,8 +17,11 @@ type Record struct {
 	AuthIndex   string
 	Source      string
 	RequestedAt time.Time
-	Failed      bool
-	Detail      Detail
+	// Latency is the time from the start of the upstream attempt to the complete response,
+	// or for streams to the first byte of the response.
+	Latency time.Duration
+	Failed  bool
+	Detail  Detail
 }
 
 // Detail holds the token usage breakdown.
@@ -30,6 +33,21 @@ type Detail struct {
 	TotalTokens     int64
 }
 
+// ReasoningApart reports whether ReasoningTokens come on top of OutputTokens. OpenAI-style
+// usage counts reasoning inside the output tokens, while Gemini reports thoughts apart and
+// adds them to the total.
+func (d Detail) ReasoningApart() bool {
+	return d.ReasoningTokens > 0 && d.TotalTokens >= d.InputTokens+d.OutputTokens+d.ReasoningTokens
+}
+
+// GeneratedTokens returns the output tokens including reasoning, counting reasoning once.
+func (d Detail) GeneratedTokens() int64 {
+	if d.ReasoningApart() {
+		return d.OutputTokens + d.ReasoningTokens
+	}
+	return d.OutputTokens
+}
+
 // Plugin consumes usage records emitted by the proxy runtime.
 type Plugin interface {
 	HandleUsage(ctx context.Context, record Record)
@@ -38,6 +56,8 @@ type Plugin interface {
 type queueItem struct {
 	ctx    context.Context
 	record Record
+	// timing, when set, makes the item a stream timing instead of a record.
+	timing *StreamTiming
 }
 
 // Manager maintains a queue of usage records and delivers them to registered plugins.
@@ -109,6 +129,9 @@ func (m *Manager) Publish(ctx context.Context, record Record) {
 	if m == nil {
 		return
 	}
+	if collector := streamCollectorFrom(ctx); collector != nil && collector.hold(m, ctx, record) {
+		return
+	}
 	// ensure worker is running even if Start was not called explicitly
 	m.Start(context.Background())
 	m.mu.Lock()
@@ -150,6 +173,12 @@ func (m *Manager) dispatch(item queueItem) {
 		if plugin == nil {
 			continue
 		}
+		if item.timing != nil {
+			if timingPlugin, ok := plugin.(StreamTimingPlugin); ok {
+				safeInvokeTiming(timingPlugin, item.ctx, *item.timing)
+			}
+			continue
+		}
 		safeInvoke(plugin, item.ctx, item.record)
 	}
 }
@@ -157,12 +186,16 @@ func (m *Manager) dispatch(item queueItem) {
 func safeInvoke(plugin Plugin, ctx context.Context, record Record) {
 	defer func() {
 		if r := recover(); r != nil {
-			log.Errorf("usage: plugin panic recovered: %v", r)
+			logPluginPanic(r)
 		}
 	}()
 	plugin.HandleUsage(ctx, record)
 }
 
+func logPluginPanic(r any) {
+	log.Errorf("usage: plugin panic recovered: %v", r)
+}
+
 var defaultManager = NewManager(512)
 
 // DefaultManager returns the global usage manager instance.
@@ -0,0 +1,88 @@
+package usage
+
+import (
+	"context"
+	"sync"
+)
+
+type streamCollectorKey struct{}
+
+// StreamCollector holds back the usage records published without token counts while a
+// stream is served, so the caller can complete them once the stream has ended.
+type StreamCollector struct {
+	mu       sync.Mutex
+	held     []queueItem
+	manager  *Manager
+	reported Detail
+	finished bool
+}
+
+// WithStreamCollector returns a context whose token-less usage records are collected
+// instead of published.
+func WithStreamCollector(ctx context.Context) (context.Context, *StreamCollector) {
+	collector := &StreamCollector{}
+	return context.WithValue(ctx, streamCollectorKey{}, collector), collector
+}
+
+func streamCollectorFrom(ctx context.Context) *StreamCollector {
+	if ctx == nil {
+		return nil
+	}
+	collector, _ := ctx.Value(streamCollectorKey{}).(*StreamCollector)
+	return collector
+}
+
+// hold keeps record when it carries no token counts and reports whether it was kept.
+// Records with counts are published as usual and remembered as the provider's report.
+func (c *StreamCollector) hold(m *Manager, ctx context.Context, record Record) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.finished || record.Failed {
+		return false
+	}
+	if record.Detail != (Detail{}) {
+		c.reported = record.Detail
+		return false
+	}
+	c.manager = m
+	c.held = append(c.held, queueItem{ctx: ctx, record: record})
+	return true
+}
+
+// Reported returns the token counts the provider reported for the stream, if any.
+func (c *StreamCollector) Reported() (Detail, bool) {
+	if c == nil {
+		return Detail{}, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.reported, c.reported != (Detail{})
+}
+
+// Pending reports whether a token-less record is waiting for Finish.
+func (c *StreamCollector) Pending() bool {
+	if c == nil {
+		return false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.held) > 0
+}
+
+// Finish publishes the held records, the first of them with detail, and stops collecting.
+func (c *StreamCollector) Finish(detail Detail) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	held, manager := c.held, c.manager
+	c.held = nil
+	c.finished = true
+	c.mu.Unlock()
+	for i, item := range held {
+		if i == 0 {
+			item.record.Detail = detail
+		}
+		manager.Publish(item.ctx, item.record)
+	}
+}
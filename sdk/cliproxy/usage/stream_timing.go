@@ -0,0 +1,147 @@
+package usage
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+// maxStreamGaps bounds the inter-chunk gaps kept for one stream; longer streams report
+// their first gaps only.
+const maxStreamGaps = 8192
+
+// StreamTiming holds the pacing of one streamed upstream response: the time from the start
+// of the attempt to its first chunk (time to first token) and the gaps between consecutive
+// chunks (inter-token latency).
+type StreamTiming struct {
+	Provider   string
+	Model      string
+	FirstToken time.Duration
+	Gaps       []time.Duration
+}
+
+// StreamTimingPlugin is implemented by plugins that also consume stream timings.
+type StreamTimingPlugin interface {
+	HandleStreamTiming(ctx context.Context, timing StreamTiming)
+}
+
+// StreamTimer measures a stream as its chunks arrive. Gaps run from the moment the reader
+// was ready for the next chunk, so time spent waiting on a slow client is not counted as
+// upstream latency. It is not safe for concurrent use.
+type StreamTimer struct {
+	start    time.Time
+	ready    time.Time
+	received bool
+	timing   StreamTiming
+}
+
+// NewStreamTimer starts timing a stream of provider serving model.
+func NewStreamTimer(provider, model string) *StreamTimer {
+	return &StreamTimer{start: time.Now(), timing: StreamTiming{Provider: provider, Model: model}}
+}
+
+// Chunk records a chunk received at now.
+func (t *StreamTimer) Chunk(now time.Time) {
+	if t == nil {
+		return
+	}
+	if !t.received {
+		t.timing.FirstToken = now.Sub(t.start)
+		t.received = true
+	} else {
+		t.gap(now)
+	}
+	t.ready = now
+}
+
+// Ready records that the previous chunk was handed to the client at now and the next one
+// is awaited from then on.
+func (t *StreamTimer) Ready(now time.Time) {
+	if t == nil || !t.received {
+		return
+	}
+	t.ready = now
+}
+
+// Fail records the upstream failure of a stream at now. The wait for the chunk that never
+// came counts as a gap, so streams that stall before failing show in the latencies.
+func (t *StreamTimer) Fail(now time.Time) {
+	if t == nil || !t.received {
+		return
+	}
+	t.gap(now)
+	t.ready = now
+}
+
+func (t *StreamTimer) gap(now time.Time) {
+	if len(t.timing.Gaps) < maxStreamGaps {
+		t.timing.Gaps = append(t.timing.Gaps, now.Sub(t.ready))
+	}
+}
+
+// Finish publishes the timing of a stream that produced at least one chunk, whether it
+// completed or failed.
+func (t *StreamTimer) Finish(ctx context.Context) {
+	if t == nil || !t.received {
+		return
+	}
+	DefaultManager().PublishStreamTiming(ctx, t.timing)
+}
+
+type firstByteKey struct{}
+
+// WithFirstByte returns a context for a streamed attempt in which MarkFirstByte records
+// when the upstream response started, so the usage record of the stream reports the latency
+// to its first byte rather than to its end.
+func WithFirstByte(ctx context.Context) context.Context {
+	return context.WithValue(ctx, firstByteKey{}, new(atomic.Int64))
+}
+
+// MarkFirstByte records now as the start of the upstream response of ctx. An attempt that
+// retries upstream keeps the last mark, the one of the response it streams.
+func MarkFirstByte(ctx context.Context, now time.Time) {
+	if ctx == nil {
+		return
+	}
+	if at, ok := ctx.Value(firstByteKey{}).(*atomic.Int64); ok {
+		at.Store(now.UnixNano())
+	}
+}
+
+// FirstByte returns the last MarkFirstByte time of ctx, or the zero time when ctx is not a
+// streamed attempt or no response arrived yet.
+func FirstByte(ctx context.Context) time.Time {
+	if ctx == nil {
+		return time.Time{}
+	}
+	at, ok := ctx.Value(firstByteKey{}).(*atomic.Int64)
+	if !ok || at.Load() == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, at.Load())
+}
+
+// PublishStreamTiming enqueues timing for the plugins implementing StreamTimingPlugin.
+func (m *Manager) PublishStreamTiming(ctx context.Context, timing StreamTiming) {
+	if m == nil {
+		return
+	}
+	m.Start(context.Background())
+	m.mu.Lock()
+	if m.closed {
+		m.mu.Unlock()
+		return
+	}
+	m.queue = append(m.queue, queueItem{ctx: ctx, timing: &timing})
+	m.mu.Unlock()
+	m.cond.Signal()
+}
+
+func safeInvokeTiming(plugin StreamTimingPlugin, ctx context.Context, timing StreamTiming) {
+	defer func() {
+		if r := recover(); r != nil {
+			logPluginPanic(r)
+		}
+	}()
+	plugin.HandleStreamTiming(ctx, timing)
+}
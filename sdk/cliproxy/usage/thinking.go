@@ -0,0 +1,32 @@
+package usage
+
+import "context"
+
+type thinkingRequestKey struct{}
+
+// ThinkingRequest is the thinking setting a client asked for, attached to the request
+// context so usage plugins can compare it with the reasoning tokens the provider reported.
+type ThinkingRequest struct {
+	// Level is the requested level ("none", "auto", "minimal" ... "xhigh"); budgets are
+	// reported with their nearest level.
+	Level string
+	// Budget is the requested token budget, or the nominal budget of Level; -1 for auto.
+	Budget int64
+}
+
+// WithThinkingRequest returns a context carrying the thinking setting of the request.
+func WithThinkingRequest(ctx context.Context, request ThinkingRequest) context.Context {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return context.WithValue(ctx, thinkingRequestKey{}, request)
+}
+
+// ThinkingRequestFromContext returns the thinking setting attached by WithThinkingRequest.
+func ThinkingRequestFromContext(ctx context.Context) (ThinkingRequest, bool) {
+	if ctx == nil {
+		return ThinkingRequest{}, false
+	}
+	request, ok := ctx.Value(thinkingRequestKey{}).(ThinkingRequest)
+	return request, ok
+}
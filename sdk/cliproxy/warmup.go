@@ -0,0 +1,16 @@
+package cliproxy
+
+import (
+	"context"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/warmup"
+)
+
+// startWarmup warms up the configured models in the background once the server is up.
+// It runs at startup only; models added by a reload are warmed by their first request.
+func (s *Service) startWarmup(ctx context.Context) {
+	if s == nil || s.coreManager == nil || s.cfg == nil || !s.cfg.Warmup.Enabled() {
+		return
+	}
+	go warmup.Run(ctx, s.cfg, s.coreManager)
+}
@@ -13,6 +13,28 @@ type AccessProvider = internalconfig.AccessProvider
 type Config = internalconfig.Config
 
 type StreamingConfig = internalconfig.StreamingConfig
+type IdempotencyConfig = internalconfig.IdempotencyConfig
+type SchedulingConfig = internalconfig.SchedulingConfig
+type StreamThrottleConfig = internalconfig.StreamThrottleConfig
+type StreamRate = internalconfig.StreamRate
+type RaceRule = internalconfig.RaceRule
+type ContextFitConfig = internalconfig.ContextFitConfig
+type ContextFitRule = internalconfig.ContextFitRule
+type RefusalFallbackConfig = internalconfig.RefusalFallbackConfig
+type RefusalFallbackRule = internalconfig.RefusalFallbackRule
+type ClientProfile = internalconfig.ClientProfile
+type DefaultModelConfig = internalconfig.DefaultModelConfig
+type DefaultModelRule = internalconfig.DefaultModelRule
+type FanOutConfig = internalconfig.FanOutConfig
+type OutputGuardrailConfig = internalconfig.OutputGuardrailConfig
+type OutputGuardrailRule = internalconfig.OutputGuardrailRule
+type SemanticCacheConfig = internalconfig.SemanticCacheConfig
+type PromptTemplate = internalconfig.PromptTemplate
+type MCPToolsConfig = internalconfig.MCPToolsConfig
+type MCPToolServer = internalconfig.MCPToolServer
+type RecordingConfig = internalconfig.RecordingConfig
+type MockProvider = internalconfig.MockProvider
+type ProviderGroup = internalconfig.ProviderGroup
 type TLSConfig = internalconfig.TLSConfig
 type RemoteManagement = internalconfig.RemoteManagement
 type AmpCode = internalconfig.AmpCode
@@ -37,6 +59,27 @@ const (
 	AccessProviderTypeConfigAPIKey = internalconfig.AccessProviderTypeConfigAPIKey
 	DefaultAccessProviderName      = internalconfig.DefaultAccessProviderName
 	DefaultPanelGitHubRepository   = internalconfig.DefaultPanelGitHubRepository
+	DefaultIdempotencyMaxEntries   = internalconfig.DefaultIdempotencyMaxEntries
+	DefaultFanOutMaxModels         = internalconfig.DefaultFanOutMaxModels
+	DefaultGuardrailReplacement    = internalconfig.DefaultGuardrailReplacement
+	DefaultGuardrailWindowBytes    = internalconfig.DefaultGuardrailWindowBytes
+	GuardrailActionRedact          = internalconfig.GuardrailActionRedact
+	GuardrailActionAnnotate        = internalconfig.GuardrailActionAnnotate
+	DefaultSemanticCacheThreshold  = internalconfig.DefaultSemanticCacheThreshold
+	DefaultSemanticCacheTTLSeconds = internalconfig.DefaultSemanticCacheTTLSeconds
+	DefaultSemanticCacheMaxEntries = internalconfig.DefaultSemanticCacheMaxEntries
+	DefaultMCPToolMaxRounds        = internalconfig.DefaultMCPToolMaxRounds
+	DefaultMCPToolCacheSeconds     = internalconfig.DefaultMCPToolCacheSeconds
+	DefaultMCPToolTimeoutSeconds   = internalconfig.DefaultMCPToolTimeoutSeconds
+	RecordingRecord                = internalconfig.RecordingRecord
+	RecordingReplay                = internalconfig.RecordingReplay
+	RecordingReplayOrRecord        = internalconfig.RecordingReplayOrRecord
+	UnknownSuffixPassthrough       = internalconfig.UnknownSuffixPassthrough
+	UnknownSuffixStrip             = internalconfig.UnknownSuffixStrip
+	UnknownSuffixReject            = internalconfig.UnknownSuffixReject
+	UnknownSuffixNearest           = internalconfig.UnknownSuffixNearest
+	MockModeLorem                  = internalconfig.MockModeLorem
+	MockModeEcho                   = internalconfig.MockModeEcho
 )
 
 func MakeInlineAPIKeyProvider(keys []string) *AccessProvider {
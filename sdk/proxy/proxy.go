@@ -0,0 +1,144 @@
+// Package proxy embeds the whole CLIProxyAPI server in another Go application:
+//
+//	p := proxy.New(cfg, proxy.WithRouteModules(myModule))
+//	if err := p.Run(ctx); err != nil { ... }
+//
+// It is the entry point the standalone binary uses too; cliproxy.Builder remains available
+// for callers that need to replace the client providers or the auth managers.
+package proxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	configaccess "github.com/router-for-me/CLIProxyAPI/v6/internal/access/config_access"
+	signatureaccess "github.com/router-for-me/CLIProxyAPI/v6/internal/access/signature_access"
+	sdkapi "github.com/router-for-me/CLIProxyAPI/v6/sdk/api"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	_ "github.com/router-for-me/CLIProxyAPI/v6/sdk/translator/builtin"
+	"gopkg.in/yaml.v3"
+)
+
+// Proxy is an embedded proxy server.
+type Proxy struct {
+	cfg           *config.Config
+	configPath    string
+	hooks         cliproxy.Hooks
+	localPassword string
+	serverOptions []sdkapi.ServerOption
+	customize     []func(*cliproxy.Builder)
+}
+
+// Option configures a Proxy.
+type Option func(*Proxy)
+
+// WithConfigPath sets the config file the proxy watches for changes and persists management
+// edits to. Without it the proxy runs from a private copy of the config that is discarded
+// when Run returns.
+func WithConfigPath(path string) Option {
+	return func(p *Proxy) { p.configPath = path }
+}
+
+// WithRouteModules registers routing modules on the server after the built-in ones.
+func WithRouteModules(mods ...sdkapi.RouteModule) Option {
+	return func(p *Proxy) { p.serverOptions = append(p.serverOptions, sdkapi.WithRouteModules(mods...)) }
+}
+
+// WithServerOptions adds HTTP server options such as middleware or a listener.
+func WithServerOptions(opts ...sdkapi.ServerOption) Option {
+	return func(p *Proxy) { p.serverOptions = append(p.serverOptions, opts...) }
+}
+
+// WithHooks sets the lifecycle callbacks of the service.
+func WithHooks(hooks cliproxy.Hooks) Option {
+	return func(p *Proxy) { p.hooks = hooks }
+}
+
+// WithLocalManagementPassword sets a runtime-only management password accepted for
+// localhost requests.
+func WithLocalManagementPassword(password string) Option {
+	return func(p *Proxy) { p.localPassword = password }
+}
+
+// WithBuilder customizes the underlying service builder, e.g. to replace the client
+// providers or the auth managers.
+func WithBuilder(fn func(*cliproxy.Builder)) Option {
+	return func(p *Proxy) {
+		if fn != nil {
+			p.customize = append(p.customize, fn)
+		}
+	}
+}
+
+// New constructs a proxy serving cfg.
+func New(cfg *config.Config, opts ...Option) *Proxy {
+	p := &Proxy{cfg: cfg}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
+}
+
+// Run serves until ctx is cancelled, returning nil in that case, or until the server fails.
+func (p *Proxy) Run(ctx context.Context) error {
+	if p == nil || p.cfg == nil {
+		return fmt.Errorf("proxy: configuration is required")
+	}
+	cfg, configPath := p.cfg, p.configPath
+	if configPath == "" {
+		dir, err := os.MkdirTemp("", "cliproxy-")
+		if err != nil {
+			return fmt.Errorf("proxy: create config dir: %w", err)
+		}
+		defer func() { _ = os.RemoveAll(dir) }()
+		if cfg, configPath, err = privateConfig(cfg, dir); err != nil {
+			return err
+		}
+	}
+
+	// Register built-in access providers before constructing the service.
+	configaccess.Register()
+	signatureaccess.Register()
+
+	builder := cliproxy.NewBuilder().
+		WithConfig(cfg).
+		WithConfigPath(configPath).
+		WithHooks(p.hooks).
+		WithServerOptions(p.serverOptions...)
+	if p.localPassword != "" {
+		builder = builder.WithLocalManagementPassword(p.localPassword)
+	}
+	for _, fn := range p.customize {
+		fn(builder)
+	}
+	service, err := builder.Build()
+	if err != nil {
+		return err
+	}
+	if err = service.Run(ctx); err != nil && !errors.Is(err, context.Canceled) {
+		return err
+	}
+	return nil
+}
+
+// privateConfig writes cfg to dir and loads it back, so a config built in code is watched
+// and normalized exactly like one read from a file.
+func privateConfig(cfg *config.Config, dir string) (*config.Config, string, error) {
+	data, err := yaml.Marshal(cfg)
+	if err != nil {
+		return nil, "", fmt.Errorf("proxy: encode config: %w", err)
+	}
+	path := filepath.Join(dir, "config.yaml")
+	if err = os.WriteFile(path, data, 0o600); err != nil {
+		return nil, "", fmt.Errorf("proxy: write config: %w", err)
+	}
+	loaded, err := config.LoadConfig(path)
+	if err != nil {
+		return nil, "", fmt.Errorf("proxy: load config: %w", err)
+	}
+	return loaded, path, nil
+}
@@ -0,0 +1,74 @@
+package proxy
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	sdkapi "github.com/router-for-me/CLIProxyAPI/v6/sdk/api"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+)
+
+type helloModule struct{}
+
+func (helloModule) Name() string { return "hello" }
+
+func (helloModule) Register(ctx sdkapi.ModuleContext) error {
+	ctx.Engine.GET("/hello", func(c *gin.Context) { c.String(http.StatusOK, "hello") })
+	return nil
+}
+
+func (helloModule) OnConfigUpdated(*config.Config) error { return nil }
+
+func TestRunServesEmbeddedProxyWithModules(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	cfg := &config.Config{SDKConfig: config.SDKConfig{APIKeys: []string{"k"}}, AuthDir: t.TempDir()}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- New(cfg, WithRouteModules(helloModule{}), WithServerOptions(sdkapi.WithListener(ln))).Run(ctx)
+	}()
+
+	url := "http://" + ln.Addr().String() + "/hello"
+	deadline := time.Now().Add(5 * time.Second)
+	client := &http.Client{Timeout: time.Second}
+	for {
+		select {
+		case err = <-done:
+			t.Fatalf("Run returned early: %v", err)
+		default:
+		}
+		resp, errGet := client.Get(url)
+		if errGet == nil {
+			body, _ := io.ReadAll(resp.Body)
+			_ = resp.Body.Close()
+			if resp.StatusCode != http.StatusOK || string(body) != "hello" {
+				t.Fatalf("GET /hello = %d %q", resp.StatusCode, body)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("proxy did not start: %v", errGet)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	cancel()
+	select {
+	case err = <-done:
+		if err != nil {
+			t.Fatalf("Run returned %v after cancel, want nil", err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("Run did not return after cancel")
+	}
+}
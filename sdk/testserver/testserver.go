@@ -0,0 +1,180 @@
+// Package testserver starts the full proxy pipeline on an ephemeral port for integration
+// tests. The default server answers from an echo mock provider, so tests need no upstream,
+// credentials or config file:
+//
+//	srv := testserver.Start(t)
+//	resp, err := srv.Client().ChatCompletion(ctx, client.ChatRequest{Model: testserver.MockModel, ...})
+//
+// The proxy keeps its model registry process-wide, so servers started in the same test
+// binary see each other's models; run them one at a time.
+package testserver
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"slices"
+	"testing"
+	"time"
+
+	sdkapi "github.com/router-for-me/CLIProxyAPI/v6/sdk/api"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/client"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/proxy"
+)
+
+// Defaults of the test server.
+const (
+	// APIKey is the API key accepted by the server.
+	APIKey = "test-api-key"
+	// ManagementKey is the management API secret.
+	ManagementKey = "test-management-key"
+	// MockModel is the model served by the default mock provider, echoing the last user
+	// message.
+	MockModel = "mock-echo"
+)
+
+// startTimeout bounds the wait for the server to serve the configured mock models.
+const startTimeout = 10 * time.Second
+
+// Server is a running test proxy.
+type Server struct {
+	// URL is the base URL of the server, e.g. "http://127.0.0.1:41234".
+	URL string
+	// Config is the configuration the server was started with.
+	Config *config.Config
+
+	cancel context.CancelFunc
+	done   chan error
+}
+
+type options struct {
+	configure     []func(*config.Config)
+	proxyOptions  []proxy.Option
+	serverOptions []sdkapi.ServerOption
+}
+
+// Option configures a test server.
+type Option func(*options)
+
+// WithConfig edits the default configuration before the server starts, e.g. to add mock
+// providers or enable features under test.
+func WithConfig(fn func(*config.Config)) Option {
+	return func(o *options) {
+		if fn != nil {
+			o.configure = append(o.configure, fn)
+		}
+	}
+}
+
+// WithRouteModules registers routing modules on the server.
+func WithRouteModules(mods ...sdkapi.RouteModule) Option {
+	return func(o *options) { o.proxyOptions = append(o.proxyOptions, proxy.WithRouteModules(mods...)) }
+}
+
+// WithServerOptions adds HTTP server options.
+func WithServerOptions(opts ...sdkapi.ServerOption) Option {
+	return func(o *options) { o.serverOptions = append(o.serverOptions, opts...) }
+}
+
+// DefaultConfig returns the configuration of a test server: loopback only, the APIKey and
+// ManagementKey secrets, no control panel download, and one echo mock provider serving
+// MockModel.
+func DefaultConfig(authDir string) *config.Config {
+	return &config.Config{
+		SDKConfig: config.SDKConfig{APIKeys: []string{APIKey}},
+		Host:      "127.0.0.1",
+		AuthDir:   authDir,
+		RemoteManagement: config.RemoteManagement{
+			SecretKey:           ManagementKey,
+			DisableControlPanel: true,
+		},
+		MockProvider: []config.MockProvider{{Name: "mock", Models: []string{MockModel}, Mode: config.MockModeEcho}},
+	}
+}
+
+// Start runs a test server until the test ends. It fails the test when the server cannot
+// start or does not serve its mock models in time.
+func Start(t testing.TB, opts ...Option) *Server {
+	t.Helper()
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+	cfg := DefaultConfig(t.TempDir())
+	for _, fn := range o.configure {
+		fn(cfg)
+	}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("testserver: listen: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &Server{URL: "http://" + ln.Addr().String(), Config: cfg, cancel: cancel, done: make(chan error, 1)}
+	proxyOptions := append(o.proxyOptions, proxy.WithServerOptions(append(o.serverOptions, sdkapi.WithListener(ln))...))
+	go func() { s.done <- proxy.New(cfg, proxyOptions...).Run(ctx) }()
+	t.Cleanup(s.Close)
+
+	if err = s.waitReady(); err != nil {
+		t.Fatalf("testserver: %v", err)
+	}
+	return s
+}
+
+// waitReady polls the model list until every mock model is served.
+func (s *Server) waitReady() error {
+	var want []string
+	for _, mock := range s.Config.MockProvider {
+		for _, model := range mock.Models {
+			want = append(want, mock.Prefix+model)
+		}
+	}
+	c := s.Client(client.WithHTTPClient(&http.Client{Timeout: time.Second}))
+	deadline := time.Now().Add(startTimeout)
+	var lastErr error
+	for time.Now().Before(deadline) {
+		select {
+		case err := <-s.done:
+			s.done <- err
+			return fmt.Errorf("server stopped during startup: %v", err)
+		default:
+		}
+		models, err := c.Models(context.Background())
+		if err == nil && servesAll(models, want) {
+			return nil
+		}
+		lastErr = err
+		time.Sleep(20 * time.Millisecond)
+	}
+	return fmt.Errorf("server not ready after %s: %v", startTimeout, lastErr)
+}
+
+func servesAll(models []client.Model, want []string) bool {
+	for _, id := range want {
+		if !slices.ContainsFunc(models, func(m client.Model) bool { return m.ID == id }) {
+			return false
+		}
+	}
+	return true
+}
+
+// Client returns a client for the server authenticated with APIKey and ManagementKey.
+func (s *Server) Client(opts ...client.Option) *client.Client {
+	base := []client.Option{client.WithAPIKey(APIKey), client.WithManagementKey(ManagementKey)}
+	return client.New(s.URL, append(base, opts...)...)
+}
+
+// Close stops the server and waits for it to shut down. It is safe to call more than once.
+func (s *Server) Close() {
+	if s == nil || s.cancel == nil {
+		return
+	}
+	s.cancel()
+	select {
+	case err := <-s.done:
+		s.done <- err
+	case <-time.After(startTimeout):
+	}
+}
@@ -0,0 +1,46 @@
+package testserver
+
+import (
+	"context"
+	"testing"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/client"
+)
+
+func TestStartServesMockModel(t *testing.T) {
+	srv := Start(t)
+	c := srv.Client()
+
+	resp, err := c.ChatCompletion(context.Background(), client.ChatRequest{
+		Model:    MockModel,
+		Messages: []client.Message{{Role: "user", Content: "ping"}},
+	})
+	if err != nil {
+		t.Fatalf("ChatCompletion: %v", err)
+	}
+	if resp.Text() != "ping" {
+		t.Fatalf("echo answer = %q, want ping", resp.Text())
+	}
+
+	stream, err := c.ChatCompletionStream(context.Background(), client.ChatRequest{
+		Model:    MockModel,
+		Messages: []client.Message{{Role: "user", Content: "pong"}},
+	})
+	if err != nil {
+		t.Fatalf("ChatCompletionStream: %v", err)
+	}
+	defer func() { _ = stream.Close() }()
+	var text string
+	for stream.Next() {
+		for _, choice := range stream.Chunk().Choices {
+			text += choice.Delta.Content
+		}
+	}
+	if stream.Err() != nil || text != "pong" {
+		t.Fatalf("streamed %q, err %v", text, stream.Err())
+	}
+
+	if _, err = c.Info(context.Background()); err != nil {
+		t.Fatalf("Info: %v", err)
+	}
+}
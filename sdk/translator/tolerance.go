@@ -0,0 +1,196 @@
+package translator
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/tidwall/gjson"
+)
+
+// Upstream streams change as providers add event types and fields. The registry skips
+// chunks it does not recognize instead of handing them to the translators, recovers
+// translator panics and warns once per format and event type about the skipped chunks. In
+// strict mode the first such chunk of a stream is recorded on its StreamCheck so the caller
+// can fail the stream, surfacing format changes during development.
+
+// strictStreams reports whether unrecognized stream chunks fail their stream.
+var strictStreams atomic.Bool
+
+// SetStrictStreams switches between tolerant (false, the default) and strict parsing of
+// upstream streams.
+func SetStrictStreams(strict bool) { strictStreams.Store(strict) }
+
+// StrictStreams reports whether strict stream parsing is enabled.
+func StrictStreams() bool { return strictStreams.Load() }
+
+// StreamFormatError describes an upstream stream chunk the translators do not recognize.
+type StreamFormatError struct {
+	Format Format
+	Event  string
+	Reason string
+}
+
+func (e *StreamFormatError) Error() string {
+	if e.Event != "" {
+		return fmt.Sprintf("%s stream: %s %q", e.Format, e.Reason, e.Event)
+	}
+	return fmt.Sprintf("%s stream: %s", e.Format, e.Reason)
+}
+
+// StreamCheck collects the first unrecognized chunk of a stream.
+type StreamCheck struct {
+	mu  sync.Mutex
+	err *StreamFormatError
+}
+
+// Err returns the first unrecognized chunk of the stream, or nil.
+func (c *StreamCheck) Err() error {
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err == nil {
+		return nil
+	}
+	return c.err
+}
+
+func (c *StreamCheck) record(err *StreamFormatError) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err == nil {
+		c.err = err
+	}
+}
+
+type streamCheckKey struct{}
+
+// WithStreamCheck returns a context whose stream translations report unrecognized chunks
+// to the returned StreamCheck.
+func WithStreamCheck(ctx context.Context) (context.Context, *StreamCheck) {
+	check := &StreamCheck{}
+	return context.WithValue(ctx, streamCheckKey{}, check), check
+}
+
+// StreamEventClassifier returns the event type of a decoded stream payload and whether the
+// translators of its format know it.
+type StreamEventClassifier func(payload []byte) (event string, known bool)
+
+var (
+	classifiersMu sync.RWMutex
+	classifiers   = map[Format]StreamEventClassifier{}
+	warned        sync.Map
+)
+
+// RegisterStreamEventClassifier sets the classifier of upstream chunks of format. Formats
+// without one only have malformed JSON reported.
+func RegisterStreamEventClassifier(format Format, classify StreamEventClassifier) {
+	classifiersMu.Lock()
+	defer classifiersMu.Unlock()
+	classifiers[format] = classify
+}
+
+// inspectStreamChunk classifies the upstream chunk raw of format: an SSE line, a bare JSON
+// payload or the [DONE] sentinel.
+func inspectStreamChunk(format Format, raw []byte) *StreamFormatError {
+	payload := bytes.TrimSpace(raw)
+	if bytes.HasPrefix(payload, []byte("data:")) {
+		payload = bytes.TrimSpace(payload[len("data:"):])
+	} else if len(payload) == 0 || payload[0] != '{' && payload[0] != '[' {
+		// "event:", "id:" and comment lines, blank separators and sentinels.
+		return nil
+	}
+	if len(payload) == 0 || bytes.Equal(payload, []byte("[DONE]")) {
+		return nil
+	}
+	if !gjson.ValidBytes(payload) {
+		return &StreamFormatError{Format: format, Reason: "malformed JSON chunk"}
+	}
+	classifiersMu.RLock()
+	classify := classifiers[format]
+	classifiersMu.RUnlock()
+	if classify == nil {
+		return nil
+	}
+	if event, known := classify(payload); !known {
+		return &StreamFormatError{Format: format, Event: event, Reason: "unknown event type"}
+	}
+	return nil
+}
+
+// reportStreamChunk warns about err once per format and event and records it on the
+// StreamCheck of ctx in strict mode.
+func reportStreamChunk(ctx context.Context, err *StreamFormatError) {
+	key := string(err.Format) + "\x00" + err.Reason + "\x00" + err.Event
+	if _, seen := warned.LoadOrStore(key, struct{}{}); seen {
+		log.Debugf("translator: %v", err)
+	} else {
+		log.Warnf("translator: %v; skipping it (further occurrences are logged at debug level)", err)
+	}
+	if !StrictStreams() || ctx == nil {
+		return
+	}
+	if check, ok := ctx.Value(streamCheckKey{}).(*StreamCheck); ok && check != nil {
+		check.record(err)
+	}
+}
+
+// translateStreamTolerant runs fn on raw unless raw is a chunk the translators of format do
+// not recognize, which is skipped, recovering a panic of the translator as a dropped chunk.
+func translateStreamTolerant(ctx context.Context, format Format, fn ResponseStreamTransform, model string, originalRequestRawJSON, requestRawJSON, rawJSON []byte, param *any) (out []string) {
+	if errChunk := inspectStreamChunk(format, rawJSON); errChunk != nil {
+		reportStreamChunk(ctx, errChunk)
+		return nil
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			reportStreamChunk(ctx, &StreamFormatError{Format: format, Reason: fmt.Sprintf("translator panic: %v", r)})
+			out = nil
+		}
+	}()
+	return fn(ctx, model, originalRequestRawJSON, requestRawJSON, rawJSON, param)
+}
+
+func init() {
+	RegisterStreamEventClassifier(FormatClaude, typeClassifier(
+		"message_start", "message_delta", "message_stop",
+		"content_block_start", "content_block_delta", "content_block_stop",
+		"ping", "error",
+	))
+	responses := typeClassifier(
+		"response.created", "response.in_progress", "response.completed", "response.failed",
+		"response.incomplete", "response.queued",
+		"response.output_item.added", "response.output_item.done",
+		"response.content_part.added", "response.content_part.done",
+		"response.output_text.delta", "response.output_text.done", "response.output_text.annotation.added",
+		"response.refusal.delta", "response.refusal.done",
+		"response.function_call_arguments.delta", "response.function_call_arguments.done",
+		"response.reasoning_summary_part.added", "response.reasoning_summary_part.done",
+		"response.reasoning_summary_text.delta", "response.reasoning_summary_text.done",
+		"response.reasoning_text.delta", "response.reasoning_text.done",
+		"response.web_search_call.in_progress", "response.web_search_call.searching", "response.web_search_call.completed",
+		"error",
+	)
+	RegisterStreamEventClassifier(FormatCodex, responses)
+	RegisterStreamEventClassifier(FormatOpenAIResponse, responses)
+}
+
+// typeClassifier classifies payloads by their "type" field; payloads without one are known.
+func typeClassifier(types ...string) StreamEventClassifier {
+	known := make(map[string]bool, len(types))
+	for _, t := range types {
+		known[t] = true
+	}
+	return func(payload []byte) (string, bool) {
+		event := gjson.GetBytes(payload, "type")
+		if !event.Exists() {
+			return "", true
+		}
+		return event.String(), known[event.String()]
+	}
+}
@@ -0,0 +1,53 @@
+package translator
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestTranslateStreamTolerance(t *testing.T) {
+	r := NewRegistry()
+	r.Register(FormatOpenAI, FormatClaude, nil, ResponseTransform{
+		Stream: func(_ context.Context, _ string, _, _, raw []byte, _ *any) []string {
+			if string(raw) == "data: {\"type\":\"content_block_delta\",\"boom\":true}" {
+				var m map[string]int
+				m["boom"]++
+			}
+			return []string{"ok"}
+		},
+	})
+	translate := func(ctx context.Context, raw string) []string {
+		var param any
+		return r.TranslateStream(ctx, FormatClaude, FormatOpenAI, "m", nil, nil, []byte(raw), &param)
+	}
+
+	ctx, check := WithStreamCheck(context.Background())
+	if got := translate(ctx, `data: {"type":"content_block_delta","boom":true}`); got != nil {
+		t.Fatalf("panicking translator returned %v", got)
+	}
+	if got := translate(ctx, `data: {"type":"brand_new_event"}`); got != nil {
+		t.Fatalf("unknown event should be skipped, got %v", got)
+	}
+	if got := translate(ctx, `data: {"type":"message_start"}`); len(got) != 1 {
+		t.Fatalf("known event should reach the translator, got %v", got)
+	}
+	if check.Err() != nil {
+		t.Fatalf("tolerant mode recorded %v", check.Err())
+	}
+
+	SetStrictStreams(true)
+	defer SetStrictStreams(false)
+	for _, raw := range []string{"event: message_start", `data: {"type":"message_start"}`, "data: [DONE]", ""} {
+		translate(ctx, raw)
+	}
+	if check.Err() != nil {
+		t.Fatalf("known chunks recorded %v", check.Err())
+	}
+	translate(ctx, `data: {"type":"brand_new_event"}`)
+	translate(ctx, `data: {not json`)
+	var errFormat *StreamFormatError
+	if !errors.As(check.Err(), &errFormat) || errFormat.Event != "brand_new_event" || errFormat.Format != FormatClaude {
+		t.Fatalf("strict mode error = %v", check.Err())
+	}
+}